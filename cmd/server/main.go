@@ -2,24 +2,42 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
 	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/indexer"
+	"github.com/my-mcp/code-indexer/internal/logging"
+	"github.com/my-mcp/code-indexer/internal/parser"
 	"github.com/my-mcp/code-indexer/internal/server"
+	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
 var (
-	configPath string
-	logLevel   string
-	port       int
-	host       string
+	configPath  string
+	logLevel    string
+	port        int
+	host        string
+	memoryIndex bool
+	dataDir     string
+	httpPath    string
+	tlsCert     string
+	tlsKey      string
 )
 
 func main() {
@@ -33,12 +51,27 @@ repositories and provides powerful search capabilities for LLM applications.`,
 	// Add flags
 	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "", "Path to configuration file")
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "", "Log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().BoolVar(&memoryIndex, "memory-index", false, "Keep the index in memory and clone into a temporary directory removed on exit (for CI and one-shot sessions)")
+	rootCmd.PersistentFlags().StringVar(&dataDir, "data-dir", "", "Directory holding the index and cloned repositories unless index_dir and repo_dir are set (overrides indexer.data_dir; defaults to the user data directory)")
+
+	// Bind the flags so they take effect before the configured index and
+	// repository directories would be created
+	viper.BindPFlag("indexer.memory_index", rootCmd.PersistentFlags().Lookup("memory-index"))
+	viper.BindPFlag("indexer.data_dir", rootCmd.PersistentFlags().Lookup("data-dir"))
 
 	// Add commands
 	rootCmd.AddCommand(serveCmd())
 	rootCmd.AddCommand(mcpServerCmd())
 	rootCmd.AddCommand(daemonCmd())
+	rootCmd.AddCommand(serveHTTPCmd())
 	rootCmd.AddCommand(versionCmd())
+	rootCmd.AddCommand(configCmd())
+	rootCmd.AddCommand(benchCmd())
+	rootCmd.AddCommand(indexCmd())
+	rootCmd.AddCommand(searchCmd())
+	rootCmd.AddCommand(statsCmd())
+	rootCmd.AddCommand(exportCmd())
+	rootCmd.AddCommand(importCmd())
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -84,10 +117,52 @@ func daemonCmd() *cobra.Command {
 	// Add daemon-specific flags
 	cmd.Flags().IntVarP(&port, "port", "p", 8080, "Port to listen on")
 	cmd.Flags().StringVarP(&host, "host", "H", "localhost", "Host to bind to")
+	addTLSFlags(cmd)
+
+	return cmd
+}
+
+func serveHTTPCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve-http",
+		Short: "Start the MCP server on an HTTP transport",
+		Long: `Start the MCP server and serve the MCP protocol over HTTP, for web-based
+clients and remote agents. The Streamable HTTP transport is served at --path,
+and the older HTTP+SSE transport at /sse and /message.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runHTTPServer()
+		},
+	}
+
+	cmd.Flags().IntVarP(&port, "port", "p", 8080, "Port to listen on")
+	cmd.Flags().StringVarP(&host, "host", "H", "localhost", "Host to bind to")
+	cmd.Flags().StringVar(&httpPath, "path", "/mcp", "Endpoint path of the Streamable HTTP transport")
+	addTLSFlags(cmd)
 
 	return cmd
 }
 
+// addTLSFlags adds the flags serving a network command over HTTPS
+func addTLSFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "PEM certificate file; serves HTTPS together with --tls-key (overrides server.tls.cert_file)")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "PEM private key file of --tls-cert (overrides server.tls.key_file)")
+}
+
+// applyTLSFlags overrides the configured certificate and key with the
+// --tls-cert and --tls-key flags
+func applyTLSFlags(cfg *config.Config) error {
+	if tlsCert != "" {
+		cfg.Server.TLS.CertFile = tlsCert
+	}
+	if tlsKey != "" {
+		cfg.Server.TLS.KeyFile = tlsKey
+	}
+	if (cfg.Server.TLS.CertFile == "") != (cfg.Server.TLS.KeyFile == "") {
+		return fmt.Errorf("--tls-cert and --tls-key must be given together")
+	}
+	return nil
+}
+
 func runMCPServer() error {
 	// Load configuration with uvx-optimized defaults
 	cfg, err := config.Load(configPath)
@@ -126,7 +201,8 @@ func runMCPServer() error {
 		zap.Bool("multi_session_enabled", cfg.Server.MultiSession.Enabled),
 		zap.Bool("multi_ide_enabled", cfg.Server.MultiIDE.Enabled),
 		zap.String("index_dir", cfg.Indexer.IndexDir),
-		zap.String("repo_dir", cfg.Indexer.RepoDir))
+		zap.String("repo_dir", cfg.Indexer.RepoDir),
+		zap.Bool("memory_index", cfg.Indexer.MemoryIndex))
 
 	logger.Info("🔧 Initializing MCP server components...")
 
@@ -142,14 +218,23 @@ func runMCPServer() error {
 		zap.String("protocol_version", "2024-11-05"),
 		zap.String("transport", "stdio"),
 		zap.Bool("tools_capability", true),
-		zap.Bool("resources_capability", false),
-		zap.Bool("prompts_capability", false))
+		zap.Bool("resources_capability", true),
+		zap.Bool("prompts_capability", true))
 
 	logger.Info("📡 Starting MCP server on stdio transport...")
 	logger.Info("⏳ Waiting for MCP client connection...")
 
+	// Index the configured repositories in the background
+	mcpServer.StartConfiguredRepositories()
+
 	// Start server directly for better stdio handling
-	return mcpServer.ServeStdio()
+	serveErr := mcpServer.ServeStdio()
+
+	// Release the index and remove temporary directories of memory mode
+	if err := mcpServer.Close(); err != nil {
+		logger.Error("Error during server shutdown", zap.Error(err))
+	}
+	return serveErr
 }
 
 func versionCmd() *cobra.Command {
@@ -162,6 +247,559 @@ func versionCmd() *cobra.Command {
 	}
 }
 
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and validate configuration",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration file",
+		Long: `Load the configuration (from --config or the default search locations),
+report every invalid or conflicting value and every key no setting reads, with
+the line of the file it is on, and exit non-zero if any were found.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigValidate()
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "defaults",
+		Short: "Print all configuration keys with their defaults",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return config.WriteDefaults(os.Stdout)
+		},
+	})
+
+	return cmd
+}
+
+func runConfigValidate() error {
+	cfg, err := config.Read(configPath)
+	if err != nil {
+		return err
+	}
+
+	source := config.UsedConfigFile()
+	if source == "" {
+		source = "built-in defaults"
+	}
+
+	if err := cfg.ValidateFile(config.UsedConfigFile()); err != nil {
+		var validationErr *config.ValidationError
+		if errors.As(err, &validationErr) {
+			fmt.Printf("Configuration %s has %d problem(s):\n", source, len(validationErr.Errors))
+			for _, fieldErr := range validationErr.Errors {
+				fmt.Printf("  ✗ %s\n", fieldErr.Error())
+			}
+			return fmt.Errorf("configuration is invalid")
+		}
+		return err
+	}
+
+	fmt.Printf("Configuration %s is valid\n", source)
+	return nil
+}
+
+func benchCmd() *cobra.Command {
+	var (
+		baselinePath  string
+		maxRegression float64
+		benchTime     time.Duration
+		count         int
+		update        bool
+		jsonOutput    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Benchmark parser throughput against a baseline",
+		Long: `Parse representative Go, Python, JavaScript and Java sources with the regex
+and tree-sitter parsers and report throughput. When a baseline file exists the
+command exits non-zero if any parser is slower than the baseline by more than
+--max-regression. Use --update to record a new baseline.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBench(baselinePath, maxRegression, benchTime, count, update, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringVar(&baselinePath, "baseline", "parser-bench.json", "Baseline file to compare against or update")
+	cmd.Flags().Float64Var(&maxRegression, "max-regression", 0.2, "Allowed throughput drop as a fraction of the baseline (0.2 = 20%)")
+	cmd.Flags().DurationVar(&benchTime, "benchtime", time.Second, "Minimum run time of each measurement")
+	cmd.Flags().IntVar(&count, "count", 3, "Measurements per parser; the fastest is kept")
+	cmd.Flags().BoolVar(&update, "update", false, "Write the results as the new baseline")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print results as JSON")
+
+	return cmd
+}
+
+func runBench(baselinePath string, maxRegression float64, benchTime time.Duration, count int, update, jsonOutput bool) error {
+	if maxRegression < 0 {
+		return fmt.Errorf("--max-regression must not be negative")
+	}
+
+	results, err := parser.RunBenchmarks(parser.BenchmarkSamples(), benchTime, count)
+	if err != nil {
+		return err
+	}
+
+	if update {
+		if err := parser.WriteBenchmarkBaseline(baselinePath, parser.NewBenchmarkBaseline(results)); err != nil {
+			return fmt.Errorf("failed to write baseline: %w", err)
+		}
+	}
+
+	var regressions []parser.BenchmarkRegression
+	baseline, err := parser.LoadBenchmarkBaseline(baselinePath)
+	switch {
+	case err == nil:
+		regressions = parser.CompareBenchmarks(results, baseline, maxRegression)
+	case errors.Is(err, os.ErrNotExist):
+		baseline = nil
+	default:
+		return err
+	}
+
+	if jsonOutput {
+		output := map[string]interface{}{
+			"results":        results,
+			"regressions":    regressions,
+			"baseline":       baselinePath,
+			"max_regression": maxRegression,
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(output); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("%-26s %12s %12s %10s\n", "BENCHMARK", "NS/OP", "MB/S", "BASELINE")
+		for _, result := range results {
+			base := "-"
+			if baseline != nil {
+				if value, ok := baseline.Results[result.Name]; ok {
+					base = fmt.Sprintf("%.2f", value)
+				}
+			}
+			fmt.Printf("%-26s %12d %12.2f %10s\n", result.Name, result.NsPerOp, result.MBPerSecond, base)
+		}
+
+		switch {
+		case update:
+			fmt.Printf("\nBaseline written to %s\n", baselinePath)
+		case baseline == nil:
+			fmt.Printf("\nNo baseline at %s; run with --update to create one\n", baselinePath)
+		}
+		for _, regression := range regressions {
+			fmt.Printf("  ✗ %s is %.1f%% slower (%.2f MB/s, baseline %.2f MB/s)\n",
+				regression.Name, regression.RegressionPercent, regression.CurrentMBPerSec, regression.BaselineMBPerSec)
+		}
+	}
+
+	if len(regressions) > 0 {
+		return fmt.Errorf("%d parser benchmark(s) regressed by more than %.0f%%", len(regressions), maxRegression*100)
+	}
+	return nil
+}
+
+func indexCmd() *cobra.Command {
+	var (
+		name       string
+		ref        string
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "index <path>",
+		Short: "Index a local directory or Git URL without an MCP client",
+		Long: `Index a repository into the configured index directory, the same way the
+index_repository tool does, and print a summary. Local directories given here
+may be indexed even when they are outside server.allowed_paths.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runIndex(args[0], name, ref, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringVar(&name, "name", "", "Repository name (defaults to the directory or URL name)")
+	cmd.Flags().StringVar(&ref, "ref", "", "Branch, tag or commit to check out in a clone of the repository and index")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the indexed repository as JSON")
+
+	return cmd
+}
+
+func runIndex(path, name, ref string, jsonOutput bool) error {
+	var allowed string
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		if allowed, err = filepath.Abs(path); err != nil {
+			return fmt.Errorf("invalid path %s: %w", path, err)
+		}
+	}
+	mcpServer, err := openOfflineServer(allowed)
+	if err != nil {
+		return err
+	}
+	defer mcpServer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	startTime := time.Now()
+	repo, err := mcpServer.Indexer().IndexRepositoryWithSettings(ctx, types.RepositorySettings{Source: path, Name: name, Ref: ref})
+	if err != nil {
+		return fmt.Errorf("failed to index %s: %w", path, err)
+	}
+
+	if jsonOutput {
+		return printJSON(repo)
+	}
+	fmt.Printf("Indexed %s (%s)\n", repo.Name, repo.ID)
+	fmt.Printf("  Path:      %s\n", repo.Path)
+	fmt.Printf("  Files:     %d\n", repo.FileCount)
+	fmt.Printf("  Lines:     %d\n", repo.TotalLines)
+	fmt.Printf("  Languages: %s\n", strings.Join(repo.Languages, ", "))
+	fmt.Printf("  Took:      %s\n", time.Since(startTime).Round(time.Millisecond))
+	return nil
+}
+
+func searchCmd() *cobra.Command {
+	var (
+		query      types.SearchQuery
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Search the index without an MCP client",
+		Long: `Search the configured index with the same query syntax as the search_code
+tool and print the matches, best first.`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			query.Query = strings.Join(args, " ")
+			return runSearch(query, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringVar(&query.Type, "type", "", "Only return documents of this type (function, class, file, ...)")
+	cmd.Flags().StringVar(&query.Language, "language", "", "Only return matches in this language")
+	cmd.Flags().StringVar(&query.Repository, "repository", "", "Only return matches in this repository")
+	cmd.Flags().StringVar(&query.Ref, "ref", "", "Only return matches in repositories indexed at this ref")
+	cmd.Flags().StringVar(&query.Project, "project", "", "Only return matches in this sub-project, e.g. services/api")
+	cmd.Flags().StringVar(&query.PathPrefix, "path-prefix", "", "Only return matches in files under this path prefix")
+	cmd.Flags().StringSliceVar(&query.ExcludePaths, "exclude", nil, "Skip files matching these globs, e.g. **/testdata/**")
+	cmd.Flags().BoolVar(&query.Syntax, "syntax", true, "Parse qualifiers such as lang:go and name:Parse*, +required and -excluded terms")
+	cmd.Flags().BoolVar(&query.CaseSensitive, "case-sensitive", false, "Match identifiers in names and content as written")
+	cmd.Flags().BoolVar(&query.WholeWord, "whole-word", false, "Only match whole identifiers in names and content")
+	cmd.Flags().StringVar(&query.Profile, "profile", "", "Scoring profile: default, symbols, recent or text (default search.ranking.profile)")
+	cmd.Flags().IntVarP(&query.MaxResults, "limit", "n", 20, "Maximum number of results")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the results as JSON")
+
+	return cmd
+}
+
+func runSearch(query types.SearchQuery, jsonOutput bool) error {
+	if query.MaxResults <= 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+	if query.Profile != "" && !slices.Contains(types.ScoringProfiles, query.Profile) {
+		return fmt.Errorf("--profile must be one of %s", strings.Join(types.ScoringProfiles, ", "))
+	}
+	mcpServer, err := openOfflineServer("")
+	if err != nil {
+		return err
+	}
+	defer mcpServer.Close()
+
+	mcpServer.RankQuery(&query)
+	page, err := mcpServer.Searcher().SearchPage(context.Background(), query)
+	if err != nil {
+		return fmt.Errorf("search failed: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(page)
+	}
+	if len(page.Results) == 0 {
+		fmt.Println("No matches")
+		return nil
+	}
+	for _, result := range page.Results {
+		label := result.Type
+		if result.Name != "" {
+			label += " " + result.Name
+		}
+		fmt.Printf("%s/%s:%d  %s\n", result.Repository, result.FilePath, result.StartLine, label)
+		if line := firstLine(result.Content, result.Snippet); line != "" {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+	fmt.Printf("\n%d of %d match(es)\n", len(page.Results), page.Total)
+	return nil
+}
+
+// firstLine returns the first non-blank line of content, or of snippet when
+// the index does not return content
+func firstLine(content, snippet string) string {
+	if content == "" {
+		content = snippet
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func statsCmd() *cobra.Command {
+	var jsonOutput bool
+
+	cmd := &cobra.Command{
+		Use:          "stats",
+		Short:        "Print index statistics without an MCP client",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStats(jsonOutput)
+		},
+	}
+
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the statistics as JSON")
+
+	return cmd
+}
+
+func runStats(jsonOutput bool) error {
+	mcpServer, err := openOfflineServer("")
+	if err != nil {
+		return err
+	}
+	defer mcpServer.Close()
+
+	stats, err := mcpServer.Indexer().GetIndexStats(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get index statistics: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(stats)
+	}
+	fmt.Printf("Repositories: %d\n", stats.TotalRepositories)
+	fmt.Printf("Files:        %d\n", stats.TotalFiles)
+	fmt.Printf("Functions:    %d\n", stats.TotalFunctions)
+	fmt.Printf("Classes:      %d\n", stats.TotalClasses)
+	fmt.Printf("Variables:    %d\n", stats.TotalVariables)
+	if unknown := stats.UnknownLanguages; unknown.Files > 0 {
+		extensions := make([]string, 0, len(unknown.Extensions))
+		for extension, count := range unknown.Extensions {
+			extensions = append(extensions, fmt.Sprintf("%s (%d)", extension, count))
+		}
+		sort.Strings(extensions)
+		fmt.Printf("Unknown:      %d files: %s\n", unknown.Files, strings.Join(extensions, ", "))
+	}
+
+	names := make([]string, 0, len(stats.RepositoryStats))
+	for name := range stats.RepositoryStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		fmt.Printf("\n%-30s %8s  %s\n", "REPOSITORY", "FILES", "LANGUAGES")
+	}
+	for _, name := range names {
+		repo := stats.RepositoryStats[name]
+		fmt.Printf("%-30s %8d  %s\n", name, repo.FileCount, strings.Join(repo.Languages, ", "))
+	}
+	return nil
+}
+
+func exportCmd() *cobra.Command {
+	var (
+		repositories []string
+		jsonOutput   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export <archive>",
+		Short: "Export repository indexes to an archive file",
+		Long: `Export the indexes of the configured index directory, with their repository
+records, settings and indexing history, to a gzipped tar archive that the
+import command or the import_index tool loads on another machine. "-" writes
+the archive to stdout.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(args[0], repositories, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&repositories, "repository", nil, "Only export these repositories, by name or ID (default: all)")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the archive manifest as JSON")
+
+	return cmd
+}
+
+func runExport(path string, repositories []string, jsonOutput bool) error {
+	mcpServer, err := openOfflineServer("")
+	if err != nil {
+		return err
+	}
+	defer mcpServer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// stdout carries the archive, so there is nothing to print
+	if path == "-" {
+		if _, err := mcpServer.Indexer().ExportIndex(ctx, os.Stdout, repositories); err != nil {
+			return fmt.Errorf("failed to export index: %w", err)
+		}
+		return nil
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	archive, err := mcpServer.Indexer().ExportIndex(ctx, file, repositories)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to export index: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(archive)
+	}
+	fmt.Printf("Exported %d repositories to %s\n", len(archive.Repositories), path)
+	for _, repo := range archive.Repositories {
+		fmt.Printf("  %-30s %8d documents\n", repo.Name, repo.Documents)
+	}
+	return nil
+}
+
+func importCmd() *cobra.Command {
+	var (
+		options    indexer.ImportOptions
+		jsonOutput bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import <archive>",
+		Short: "Import repository indexes from an archive file",
+		Long: `Import the repository indexes of an archive written by the export command or
+the export_index tool into the configured index directory. The archive must
+come from a version with the same index mapping. Repositories indexed here
+already are skipped unless --overwrite is given. "-" reads the archive from
+stdin.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(args[0], options, jsonOutput)
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&options.Repositories, "repository", nil, "Only import these repositories, by name or ID (default: all)")
+	cmd.Flags().StringVar(&options.RepositoryRoot, "repository-root", "", "Directory the repositories are checked out under on this machine")
+	cmd.Flags().BoolVar(&options.Overwrite, "overwrite", false, "Replace repositories indexed here already")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the import summary as JSON")
+
+	return cmd
+}
+
+func runImport(path string, options indexer.ImportOptions, jsonOutput bool) error {
+	if options.RepositoryRoot != "" {
+		root, err := filepath.Abs(options.RepositoryRoot)
+		if err != nil {
+			return fmt.Errorf("invalid repository root %s: %w", options.RepositoryRoot, err)
+		}
+		options.RepositoryRoot = root
+	}
+
+	input := os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	mcpServer, err := openOfflineServer("")
+	if err != nil {
+		return err
+	}
+	defer mcpServer.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	imported, err := mcpServer.Indexer().ImportIndex(ctx, input, options)
+	if err != nil {
+		return fmt.Errorf("failed to import index: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(imported)
+	}
+	for _, repo := range imported.Imported {
+		fmt.Printf("Imported %s (%s) at %s, %d documents\n", repo.Name, repo.ID, repo.Path, repo.Documents)
+	}
+	for _, name := range imported.Skipped {
+		fmt.Printf("Skipped %s, indexed here already (--overwrite replaces it)\n", name)
+	}
+	fmt.Printf("Took %s\n", time.Duration(imported.ElapsedSeconds*float64(time.Second)).Round(time.Millisecond))
+	return nil
+}
+
+// openOfflineServer loads the configuration and opens the index for the
+// index, search, stats, export and import commands. Logs go to stderr, warnings and errors
+// only unless --log-level is debug, so stdout carries just the output.
+// allowed is a local directory that may be indexed besides the configured
+// paths, or empty.
+func openOfflineServer(allowed string) (*server.MCPServer, error) {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Nothing but the index is needed
+	cfg.Models.Enabled = false
+	cfg.Server.MultiSession.Enabled = false
+	cfg.Server.MultiIDE.Enabled = false
+	if logLevel != "" {
+		cfg.Logging.Level = logLevel
+	}
+	if allowed != "" {
+		cfg.Server.AllowedPaths = append(cfg.Server.AllowedPaths, allowed)
+	}
+
+	logger, err := initLoggerForUVX(cfg.Logging)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize logger: %w", err)
+	}
+
+	mcpServer, err := server.New(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index: %w", err)
+	}
+	return mcpServer, nil
+}
+
+// printJSON writes value to stdout as indented JSON
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
 func runServer() error {
 	// Load configuration
 	cfg, err := config.Load(configPath)
@@ -214,6 +852,9 @@ func runServer() error {
 		cancel()
 	}()
 
+	// Index the configured repositories in the background
+	mcpServer.StartConfiguredRepositories()
+
 	// Start server in a goroutine
 	serverErr := make(chan error, 1)
 	go func() {
@@ -229,6 +870,9 @@ func runServer() error {
 		}
 		return nil
 	case err := <-serverErr:
+		if closeErr := mcpServer.Close(); closeErr != nil {
+			logger.Error("Error during server shutdown", zap.Error(closeErr))
+		}
 		if err != nil {
 			logger.Error("Server error", zap.Error(err))
 			return err
@@ -238,11 +882,18 @@ func runServer() error {
 }
 
 func runDaemon() error {
+	if err := config.ValidatePort(port); err != nil {
+		return err
+	}
+
 	// Load configuration
 	cfg, err := config.Load(configPath)
 	if err != nil {
 		return fmt.Errorf("failed to load configuration: %w", err)
 	}
+	if err := applyTLSFlags(cfg); err != nil {
+		return err
+	}
 
 	// Override log level if specified
 	if logLevel != "" {
@@ -282,6 +933,9 @@ func runDaemon() error {
 		cancel()
 	}()
 
+	// Index the configured repositories in the background
+	mcpServer.StartConfiguredRepositories()
+
 	// Start daemon server in a goroutine
 	serverErr := make(chan error, 1)
 	go func() {
@@ -297,7 +951,10 @@ func runDaemon() error {
 		}
 		return nil
 	case err := <-serverErr:
-		if err != nil {
+		if closeErr := mcpServer.Close(); closeErr != nil {
+			logger.Error("Error during daemon shutdown", zap.Error(closeErr))
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.Error("Daemon error", zap.Error(err))
 			return err
 		}
@@ -305,6 +962,69 @@ func runDaemon() error {
 	}
 }
 
+func runHTTPServer() error {
+	if err := config.ValidatePort(port); err != nil {
+		return err
+	}
+	if err := server.ValidateHTTPEndpointPath(httpPath); err != nil {
+		return fmt.Errorf("invalid --path: %w", err)
+	}
+
+	// Load configuration
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := applyTLSFlags(cfg); err != nil {
+		return err
+	}
+
+	// Override log level if specified
+	if logLevel != "" {
+		cfg.Logging.Level = logLevel
+	}
+
+	// Initialize logger
+	logger, err := initLogger(cfg.Logging)
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %w", err)
+	}
+	defer logger.Sync()
+
+	logger.Info("Starting MCP Code Indexer HTTP server",
+		zap.String("version", "1.0.0"),
+		zap.String("host", host),
+		zap.Int("port", port),
+		zap.String("path", httpPath),
+		zap.String("log_level", cfg.Logging.Level))
+
+	// Create MCP server
+	mcpServer, err := server.New(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create MCP server: %w", err)
+	}
+
+	// Stop serving on shutdown signals
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Index the configured repositories in the background
+	mcpServer.StartConfiguredRepositories()
+
+	serveErr := mcpServer.ServeHTTPTransport(ctx, host, port, httpPath)
+	if ctx.Err() != nil {
+		logger.Info("Shutting down HTTP server...")
+	}
+	if err := mcpServer.Close(); err != nil {
+		logger.Error("Error during HTTP server shutdown", zap.Error(err))
+	}
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+		logger.Error("HTTP server error", zap.Error(serveErr))
+		return serveErr
+	}
+	return nil
+}
+
 func initLogger(cfg config.LoggingConfig) (*zap.Logger, error) {
 	// Parse log level
 	level, err := zapcore.ParseLevel(cfg.Level)
@@ -329,18 +1049,17 @@ func initLogger(cfg config.LoggingConfig) (*zap.Logger, error) {
 	// Create writer syncer
 	var writeSyncer zapcore.WriteSyncer
 	if cfg.OutputPath != "" && cfg.OutputPath != "stdout" {
-		// TODO: Add file rotation support using lumberjack
-		file, err := os.OpenFile(cfg.OutputPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		writeSyncer, err = logging.FileWriter(cfg.OutputPath, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open log file: %w", err)
 		}
-		writeSyncer = zapcore.AddSync(file)
 	} else {
 		writeSyncer = zapcore.AddSync(os.Stdout)
 	}
 
-	// Create core
-	core := zapcore.NewCore(encoder, writeSyncer, level)
+	// Create core; set_log_level changes the level while the server runs
+	logging.Level.SetLevel(level)
+	core := zapcore.NewCore(encoder, writeSyncer, logging.Level)
 
 	// Create logger
 	logger := zap.New(core, zap.AddCaller(), zap.AddStacktrace(zapcore.ErrorLevel))
@@ -367,11 +1086,12 @@ func initLoggerForUVX(cfg config.LoggingConfig) (*zap.Logger, error) {
 	// Only log errors and warnings to stderr, debug/info to file if specified
 	var cores []zapcore.Core
 
-	// Always add a stderr core for errors and warnings
-	stderrLevel := zapcore.WarnLevel
-	if level == zapcore.DebugLevel {
-		stderrLevel = zapcore.DebugLevel
-	}
+	// Always add a stderr core for errors and warnings, and everything else
+	// at debug level; set_log_level changes the level while the server runs
+	logging.Level.SetLevel(level)
+	stderrLevel := zap.LevelEnablerFunc(func(l zapcore.Level) bool {
+		return l >= zapcore.WarnLevel || (logging.Level.Level() == zapcore.DebugLevel && l >= zapcore.DebugLevel)
+	})
 
 	stderrCore := zapcore.NewCore(
 		zapcore.NewConsoleEncoder(encoderConfig),
@@ -382,7 +1102,7 @@ func initLoggerForUVX(cfg config.LoggingConfig) (*zap.Logger, error) {
 
 	// Add file core if file logging is enabled
 	if cfg.File != "" {
-		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		file, err := logging.FileWriter(cfg.File, cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open log file: %w", err)
 		}
@@ -396,8 +1116,8 @@ func initLoggerForUVX(cfg config.LoggingConfig) (*zap.Logger, error) {
 
 		fileCore := zapcore.NewCore(
 			encoder,
-			zapcore.AddSync(file),
-			level,
+			file,
+			logging.Level,
 		)
 		cores = append(cores, fileCore)
 	}
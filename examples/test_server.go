@@ -62,6 +62,9 @@ func main() {
 	if err := os.MkdirAll(testRepoPath, 0755); err != nil {
 		log.Fatalf("Failed to create test repo: %v", err)
 	}
+	if err := repoMgr.AllowLocalRepositories(tempDir); err != nil {
+		log.Fatalf("Failed to allow test repo: %v", err)
+	}
 
 	// Create test files
 	testFiles := map[string]string{
@@ -1,377 +0,0 @@
-package analysis
-
-import (
-	"context"
-	"time"
-
-	"go.uber.org/zap"
-
-	"github.com/my-mcp/code-indexer/internal/config"
-	"github.com/my-mcp/code-indexer/internal/indexer"
-	"github.com/my-mcp/code-indexer/pkg/types"
-)
-
-// ComplexityAnalyzer implements complexity analysis
-type ComplexityAnalyzer struct {
-	logger  *zap.Logger
-	enabled bool
-}
-
-func NewComplexityAnalyzer(logger *zap.Logger) *ComplexityAnalyzer {
-	return &ComplexityAnalyzer{logger: logger, enabled: true}
-}
-
-func (c *ComplexityAnalyzer) Name() string { return "complexity" }
-func (c *ComplexityAnalyzer) IsEnabled() bool { return c.enabled }
-
-func (c *ComplexityAnalyzer) Analyze(ctx context.Context, target interface{}) (interface{}, error) {
-	request := target.(*types.ComplexityRequest)
-	
-	// Mock complexity analysis
-	result := &types.ComplexityAnalysis{
-		Target: request.Target,
-		Metrics: types.ComplexityMetrics{
-			Cyclomatic:      8,
-			Cognitive:       12,
-			Halstead:        15.5,
-			Maintainability: 75.2,
-		},
-		Functions: []types.FunctionComplexity{
-			{
-				Name:      "processData",
-				StartLine: 10,
-				EndLine:   45,
-				Metrics: types.ComplexityMetrics{
-					Cyclomatic: 8,
-					Cognitive:  12,
-				},
-				Severity: "medium",
-			},
-		},
-		Summary: types.ComplexitySummary{
-			AverageComplexity: 8.5,
-			MaxComplexity:     12,
-			Distribution: map[string]int{
-				"low":    2,
-				"medium": 3,
-				"high":   1,
-			},
-			Recommendations: []string{
-				"Consider breaking down complex functions",
-				"Add unit tests for high complexity functions",
-			},
-		},
-		Suggestions: []string{
-			"Function 'processData' has high cognitive complexity",
-			"Consider extracting helper methods",
-		},
-	}
-	
-	return result, nil
-}
-
-// SecurityAnalyzer implements security vulnerability detection
-type SecurityAnalyzer struct {
-	config  *config.AnalysisConfig
-	logger  *zap.Logger
-	enabled bool
-}
-
-func NewSecurityAnalyzer(config *config.AnalysisConfig, logger *zap.Logger) *SecurityAnalyzer {
-	return &SecurityAnalyzer{config: config, logger: logger, enabled: true}
-}
-
-func (s *SecurityAnalyzer) Name() string { return "security" }
-func (s *SecurityAnalyzer) IsEnabled() bool { return s.enabled }
-
-func (s *SecurityAnalyzer) Analyze(ctx context.Context, target interface{}) (interface{}, error) {
-	request := target.(*types.SecurityRequest)
-	
-	// Mock security analysis
-	result := &types.SecurityReport{
-		FilePath:    request.FilePath,
-		TotalIssues: 2,
-		Issues: []types.SecurityIssue{
-			{
-				Type:        "hardcoded_secrets",
-				Severity:    "high",
-				Location:    types.Location{FilePath: request.FilePath, StartLine: 15, EndLine: 15},
-				Description: "Potential hardcoded API key detected",
-				Remediation: "Use environment variables for sensitive data",
-				Confidence:  0.85,
-				CWE:         "CWE-798",
-			},
-			{
-				Type:        "sql_injection",
-				Severity:    "critical",
-				Location:    types.Location{FilePath: request.FilePath, StartLine: 32, EndLine: 34},
-				Description: "SQL query vulnerable to injection",
-				Remediation: "Use parameterized queries",
-				Confidence:  0.92,
-				CWE:         "CWE-89",
-			},
-		},
-		RiskScore: 7.8,
-		Summary: types.SecuritySummary{
-			BySeverity: map[string]int{"high": 1, "critical": 1},
-			ByType:     map[string]int{"hardcoded_secrets": 1, "sql_injection": 1},
-			RiskLevel:  "high",
-		},
-	}
-	
-	return result, nil
-}
-
-// TestCoverageAnalyzer implements test coverage analysis
-type TestCoverageAnalyzer struct {
-	logger  *zap.Logger
-	enabled bool
-}
-
-func NewTestCoverageAnalyzer(logger *zap.Logger) *TestCoverageAnalyzer {
-	return &TestCoverageAnalyzer{logger: logger, enabled: true}
-}
-
-func (t *TestCoverageAnalyzer) Name() string { return "test_coverage" }
-func (t *TestCoverageAnalyzer) IsEnabled() bool { return t.enabled }
-
-func (t *TestCoverageAnalyzer) Analyze(ctx context.Context, target interface{}) (interface{}, error) {
-	request := target.(*types.TestCoverageRequest)
-	
-	// Mock test coverage analysis
-	result := &types.TestCoverageReport{
-		SourceFile:       request.SourceFile,
-		TestDirectory:    request.TestDirectory,
-		CoverageType:     request.CoverageType,
-		OverallCoverage:  78.5,
-		LineCoverage:     82.3,
-		BranchCoverage:   74.1,
-		FunctionCoverage: 85.0,
-		UncoveredLines:   []int{45, 67, 89, 123},
-		TestFiles:        []string{"utils_test.go", "main_test.go"},
-		Suggestions: []string{
-			"Add tests for error handling paths",
-			"Increase branch coverage for conditional logic",
-			"Add integration tests for main workflows",
-		},
-	}
-	
-	return result, nil
-}
-
-// MetricsAnalyzer implements comprehensive metrics analysis
-type MetricsAnalyzer struct {
-	indexer *indexer.Indexer
-	logger  *zap.Logger
-	enabled bool
-}
-
-func NewMetricsAnalyzer(indexer *indexer.Indexer, logger *zap.Logger) *MetricsAnalyzer {
-	return &MetricsAnalyzer{indexer: indexer, logger: logger, enabled: true}
-}
-
-func (m *MetricsAnalyzer) Name() string { return "metrics" }
-func (m *MetricsAnalyzer) IsEnabled() bool { return m.enabled }
-
-func (m *MetricsAnalyzer) Analyze(ctx context.Context, target interface{}) (interface{}, error) {
-	request := target.(*types.MetricsRequest)
-	
-	// Mock metrics analysis
-	result := &types.MetricsReport{
-		Repository:  request.Repository,
-		GeneratedAt: time.Now().Format(time.RFC3339),
-		Summary: types.MetricsSummary{
-			TotalFiles:        45,
-			TotalLines:        12500,
-			TotalFunctions:    234,
-			TotalClasses:      67,
-			AverageComplexity: 6.8,
-			TechnicalDebt:     24.5,
-			Maintainability:   78.2,
-		},
-		FileMetrics: []types.FileMetrics{
-			{
-				FilePath:        "src/main.go",
-				Language:        "go",
-				LinesOfCode:     156,
-				Functions:       8,
-				Classes:         2,
-				Complexity:      12,
-				Maintainability: 82.1,
-				TechnicalDebt:   2.3,
-			},
-			{
-				FilePath:        "src/utils.go",
-				Language:        "go",
-				LinesOfCode:     89,
-				Functions:       5,
-				Classes:         1,
-				Complexity:      6,
-				Maintainability: 88.7,
-				TechnicalDebt:   1.1,
-			},
-		},
-		Format: request.Format,
-	}
-	
-	return result, nil
-}
-
-// EvolutionAnalyzer implements code evolution analysis
-type EvolutionAnalyzer struct {
-	logger  *zap.Logger
-	enabled bool
-}
-
-func NewEvolutionAnalyzer(logger *zap.Logger) *EvolutionAnalyzer {
-	return &EvolutionAnalyzer{logger: logger, enabled: true}
-}
-
-func (e *EvolutionAnalyzer) Name() string { return "evolution" }
-func (e *EvolutionAnalyzer) IsEnabled() bool { return e.enabled }
-
-func (e *EvolutionAnalyzer) Analyze(ctx context.Context, target interface{}) (interface{}, error) {
-	request := target.(*types.EvolutionRequest)
-	
-	// Mock evolution analysis
-	result := &types.EvolutionAnalysis{
-		FilePath:  request.FilePath,
-		TimeRange: request.TimeRange,
-		Changes: []types.EvolutionChange{
-			{
-				Date:         "2024-01-15",
-				Author:       "john.doe",
-				Message:      "Add new feature",
-				LinesAdded:   45,
-				LinesRemoved: 12,
-				Complexity:   8,
-				ChangeType:   "feature",
-			},
-			{
-				Date:         "2024-01-10",
-				Author:       "jane.smith",
-				Message:      "Fix bug in validation",
-				LinesAdded:   8,
-				LinesRemoved: 15,
-				Complexity:   6,
-				ChangeType:   "bugfix",
-			},
-		},
-		Trends: types.EvolutionTrends{
-			ComplexityTrend: "increasing",
-			SizeTrend:      "stable",
-			ChangeFrequency: 2.3,
-			AuthorDiversity: 3,
-		},
-		Hotspots: []types.EvolutionHotspot{
-			{
-				Location: types.Location{
-					FilePath:  request.FilePath,
-					StartLine: 45,
-					EndLine:   67,
-				},
-				ChangeCount: 8,
-				Complexity:  12,
-				Risk:        "high",
-			},
-		},
-		Summary: types.EvolutionSummary{
-			TotalChanges:   15,
-			ActiveAuthors:  3,
-			ChangeVelocity: 2.5,
-			StabilityScore: 0.72,
-		},
-	}
-	
-	return result, nil
-}
-
-// PatternExtractionAnalyzer implements common pattern extraction
-type PatternExtractionAnalyzer struct {
-	logger  *zap.Logger
-	enabled bool
-}
-
-func NewPatternExtractionAnalyzer(logger *zap.Logger) *PatternExtractionAnalyzer {
-	return &PatternExtractionAnalyzer{logger: logger, enabled: true}
-}
-
-func (p *PatternExtractionAnalyzer) Name() string { return "pattern_extraction" }
-func (p *PatternExtractionAnalyzer) IsEnabled() bool { return p.enabled }
-
-func (p *PatternExtractionAnalyzer) Analyze(ctx context.Context, target interface{}) (interface{}, error) {
-	request := target.(*types.PatternExtractionRequest)
-	
-	// Mock pattern extraction
-	result := &types.PatternExtractionResult{
-		Repository:    request.Repository,
-		TotalPatterns: 3,
-		Patterns: []types.ExtractedPattern{
-			{
-				ID:          "pattern_1",
-				Type:        "validation",
-				Occurrences: 5,
-				Locations: []types.Location{
-					{FilePath: "src/user.go", StartLine: 15, EndLine: 20},
-					{FilePath: "src/product.go", StartLine: 25, EndLine: 30},
-				},
-				Pattern:    "if value == nil || value == \"\" { return error }",
-				Similarity: 0.92,
-				Suggestion: "Extract into a common validation function",
-			},
-		},
-		Suggestions: []string{
-			"Consider creating a validation utility package",
-			"Extract common error handling patterns",
-		},
-	}
-	
-	return result, nil
-}
-
-// ImportOptimizerAnalyzer implements import optimization
-type ImportOptimizerAnalyzer struct {
-	logger  *zap.Logger
-	enabled bool
-}
-
-func NewImportOptimizerAnalyzer(logger *zap.Logger) *ImportOptimizerAnalyzer {
-	return &ImportOptimizerAnalyzer{logger: logger, enabled: true}
-}
-
-func (i *ImportOptimizerAnalyzer) Name() string { return "import_optimizer" }
-func (i *ImportOptimizerAnalyzer) IsEnabled() bool { return i.enabled }
-
-func (i *ImportOptimizerAnalyzer) Analyze(ctx context.Context, target interface{}) (interface{}, error) {
-	request := target.(*types.ImportOptimizationRequest)
-	
-	// Mock import optimization
-	result := &types.ImportOptimizationResult{
-		FilePath: request.FilePath,
-		OriginalImports: []types.Import{
-			{Module: "fmt", StartLine: 3},
-			{Module: "strings", StartLine: 4},
-			{Module: "unused_package", StartLine: 5},
-		},
-		OptimizedImports: []types.Import{
-			{Module: "fmt", StartLine: 3},
-			{Module: "strings", StartLine: 4},
-		},
-		Changes: []types.ImportChange{
-			{
-				Type:       "removed",
-				Import:     types.Import{Module: "unused_package", StartLine: 5},
-				Reason:     "Unused import",
-				LineNumber: 5,
-			},
-		},
-		Summary: types.ImportSummary{
-			TotalImports:   3,
-			RemovedImports: 1,
-			SortedImports:  2,
-			OptimizedLines: 1,
-		},
-	}
-	
-	return result, nil
-}
@@ -0,0 +1,197 @@
+// Package auth protects the network endpoints of the daemon and serve-http
+// commands with API keys and limits how fast each client may call them.
+//
+// A key is sent as an X-API-Key header or an "Authorization: Bearer" token.
+// Its scope travels with the request context so tool handlers can refuse
+// the tools that modify files to keys that may only read.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/logging"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Key scopes
+const (
+	ScopeRead  = "read"  // Tools that do not modify files
+	ScopeWrite = "write" // All tools
+)
+
+// Key is an API key accepted by the endpoints
+type Key struct {
+	Name    string
+	Scope   string
+	Profile string // Permission profile named in the configuration, if any
+	secret  []byte
+}
+
+// CanWrite reports whether the key may call the tools that modify files
+func (k *Key) CanWrite() bool {
+	return k.Scope == ScopeWrite
+}
+
+// contextKey stores the authenticated key in a request context
+type contextKey struct{}
+
+// WithKey returns a copy of ctx carrying key
+func WithKey(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the key a request was authenticated with. It reports
+// false when authentication is disabled or the call did not come over HTTP.
+func FromContext(ctx context.Context) (*Key, bool) {
+	key, ok := ctx.Value(contextKey{}).(*Key)
+	return key, ok
+}
+
+// clientContextKey stores the client a request came from in its context
+type clientContextKey struct{}
+
+// WithClient returns a copy of ctx carrying the client a request came from:
+// "key:" and the name of its API key, or "addr:" and its IP address when
+// authentication is disabled
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// ClientFromContext returns the client a request came from, as the rate
+// limiter tells clients apart. It reports false when the call did not come
+// over HTTP.
+func ClientFromContext(ctx context.Context) (string, bool) {
+	client, ok := ctx.Value(clientContextKey{}).(string)
+	return client, ok
+}
+
+// Authenticator checks the credentials and request rate of HTTP requests
+type Authenticator struct {
+	enabled bool
+	keys    []*Key
+	limiter *rateLimiter // nil when rate limiting is disabled
+	logger  *zap.Logger
+}
+
+// New creates an authenticator from the server.auth configuration. Keys
+// given through key_env are read from the environment here, so a missing
+// variable is reported at startup rather than on the first request.
+func New(cfg config.AuthConfig, logger *zap.Logger) (*Authenticator, error) {
+	a := &Authenticator{
+		enabled: cfg.Enabled,
+		logger:  logger,
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		secret := keyCfg.Key
+		if keyCfg.KeyEnv != "" {
+			secret = os.Getenv(keyCfg.KeyEnv)
+			if secret == "" {
+				return nil, fmt.Errorf("API key %q: environment variable %s is not set", keyCfg.Name, keyCfg.KeyEnv)
+			}
+		}
+		a.keys = append(a.keys, &Key{
+			Name:    keyCfg.Name,
+			Scope:   keyCfg.Scope,
+			Profile: keyCfg.Profile,
+			secret:  []byte(secret),
+		})
+	}
+
+	if cfg.RateLimit.RequestsPerMinute > 0 {
+		a.limiter = newRateLimiter(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.Burst)
+	}
+	return a, nil
+}
+
+// Middleware rejects requests without a valid key with 401 and requests
+// over the rate limit with 429, and passes the others on with their key and
+// client in the request context. CORS preflight requests carry no credentials and are
+// passed on unchecked.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		client := clientAddress(r)
+		if a.enabled {
+			key := a.authenticate(r)
+			if key == nil {
+				logging.With(r.Context(), a.logger).Warn("Rejected unauthenticated request",
+					zap.String("path", r.URL.Path),
+					zap.String("remote_addr", r.RemoteAddr))
+				w.Header().Set("WWW-Authenticate", `Bearer realm="code-indexer"`)
+				WriteError(w, http.StatusUnauthorized, types.ErrorUnauthenticated, "Missing or invalid API key")
+				return
+			}
+			client = "key:" + key.Name
+			r = r.WithContext(WithKey(r.Context(), key))
+		}
+
+		if a.limiter != nil && !a.limiter.allow(client) {
+			logging.With(r.Context(), a.logger).Warn("Rate limit exceeded",
+				zap.String("client", client),
+				zap.String("path", r.URL.Path))
+			w.Header().Set("Retry-After", "60")
+			WriteError(w, http.StatusTooManyRequests, types.ErrorRateLimited, "Rate limit exceeded")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithClient(r.Context(), client)))
+	})
+}
+
+// WriteError answers a request to a network endpoint with the error
+// envelope, {"success": false, "error": {...}}, the body of every failed
+// request of the daemon API
+func WriteError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(types.ErrorEnvelope{Error: types.NewToolError(code, message, nil)})
+}
+
+// authenticate returns the key matching the request's credential, or nil
+func (a *Authenticator) authenticate(r *http.Request) *Key {
+	credential := r.Header.Get("X-API-Key")
+	if credential == "" {
+		header := r.Header.Get("Authorization")
+		if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			credential = strings.TrimSpace(token)
+		}
+	}
+	if credential == "" {
+		return nil
+	}
+
+	// Compare against every key so the time taken does not reveal which
+	// one nearly matched
+	var match *Key
+	for _, key := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(credential), key.secret) == 1 {
+			match = key
+		}
+	}
+	return match
+}
+
+// clientAddress identifies an unauthenticated client by its IP address
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "addr:" + r.RemoteAddr
+	}
+	return "addr:" + host
+}
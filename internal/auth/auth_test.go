@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func newTestAuthenticator(t *testing.T, cfg config.AuthConfig) *Authenticator {
+	t.Helper()
+	a, err := New(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return a
+}
+
+func TestMiddlewareAuthentication(t *testing.T) {
+	t.Setenv("TEST_EDITOR_KEY", "editor-secret")
+	a := newTestAuthenticator(t, config.AuthConfig{
+		Enabled: true,
+		Keys: []config.APIKeyConfig{
+			{Name: "ci", Key: "ci-secret", Scope: ScopeRead},
+			{Name: "editor", KeyEnv: "TEST_EDITOR_KEY", Scope: ScopeWrite},
+		},
+	})
+
+	var seen *Key
+	var client string
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = FromContext(r.Context())
+		client, _ = ClientFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		value      string
+		wantStatus int
+		wantKey    string
+	}{
+		{"no credential", http.MethodGet, "", "", http.StatusUnauthorized, ""},
+		{"wrong key", http.MethodGet, "X-API-Key", "guess", http.StatusUnauthorized, ""},
+		{"api key header", http.MethodGet, "X-API-Key", "ci-secret", http.StatusOK, "ci"},
+		{"bearer token", http.MethodPost, "Authorization", "Bearer editor-secret", http.StatusOK, "editor"},
+		{"basic scheme", http.MethodGet, "Authorization", "Basic editor-secret", http.StatusUnauthorized, ""},
+		{"preflight", http.MethodOptions, "", "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen, client = nil, ""
+			req := httptest.NewRequest(tt.method, "/api/call", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			gotKey := ""
+			if seen != nil {
+				gotKey = seen.Name
+			}
+			if gotKey != tt.wantKey {
+				t.Errorf("Expected key %q in context, got %q", tt.wantKey, gotKey)
+			}
+			if tt.wantKey != "" && client != "key:"+tt.wantKey {
+				t.Errorf("Expected the client to be told apart by key, got %q", client)
+			}
+		})
+	}
+}
+
+func TestNewRequiresKeyEnv(t *testing.T) {
+	_, err := New(config.AuthConfig{
+		Enabled: true,
+		Keys:    []config.APIKeyConfig{{Name: "ci", KeyEnv: "TEST_UNSET_KEY_VARIABLE", Scope: ScopeRead}},
+	}, zap.NewNop())
+	if err == nil {
+		t.Fatal("Expected an unset key_env variable to be reported")
+	}
+}
+
+func TestKeyScopes(t *testing.T) {
+	if (&Key{Scope: ScopeRead}).CanWrite() {
+		t.Error("Expected a read key to be refused write access")
+	}
+	if !(&Key{Scope: ScopeWrite}).CanWrite() {
+		t.Error("Expected a write key to be granted write access")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := newRateLimiter(60, 2)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if !limiter.allow("a") {
+			t.Fatalf("Expected request %d within the burst to be allowed", i+1)
+		}
+	}
+	if limiter.allow("a") {
+		t.Error("Expected a request beyond the burst to be refused")
+	}
+	if !limiter.allow("b") {
+		t.Error("Expected another client to have its own bucket")
+	}
+
+	now = now.Add(time.Second)
+	if !limiter.allow("a") {
+		t.Error("Expected a token to be refilled after a second at 60 requests per minute")
+	}
+	if limiter.allow("a") {
+		t.Error("Expected only one token to be refilled")
+	}
+}
+
+func TestMiddlewareRateLimitWithoutAuth(t *testing.T) {
+	a := newTestAuthenticator(t, config.AuthConfig{
+		RateLimit: config.RateLimitConfig{RequestsPerMinute: 1},
+	})
+	var client string
+	handler := a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, _ = ClientFromContext(r.Context())
+	}))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/api/tools", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Request %d: expected status %d, got %d", i+1, want, rec.Code)
+		}
+	}
+	if client != "addr:192.0.2.1" {
+		t.Errorf("Expected the client to be told apart by IP address, got %q", client)
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// CORS headers sent to allowed origins. Authorization and X-API-Key carry
+// the API key; Mcp-Session-Id is used by the Streamable HTTP transport.
+const (
+	corsAllowMethods  = "GET, POST, DELETE, OPTIONS"
+	corsAllowHeaders  = "Content-Type, Authorization, X-API-Key, Mcp-Session-Id, Mcp-Protocol-Version, Last-Event-ID"
+	corsExposeHeaders = "Mcp-Session-Id"
+)
+
+// CORS decides which browser origins may call the endpoints, replacing a
+// blanket Access-Control-Allow-Origin: *. Requests without an Origin header,
+// from IDE extensions and command-line clients, are not affected.
+type CORS struct {
+	any     bool
+	origins map[string]bool
+}
+
+// NewCORS creates a CORS policy from the server.cors.allowed_origins
+// setting. "*" allows every origin; an empty list allows none.
+func NewCORS(allowedOrigins []string) *CORS {
+	c := &CORS{origins: make(map[string]bool)}
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			c.any = true
+			continue
+		}
+		c.origins[normalizeOrigin(origin)] = true
+	}
+	return c
+}
+
+// Allowed reports whether pages from origin may call the endpoints
+func (c *CORS) Allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	return c.any || c.origins[normalizeOrigin(origin)]
+}
+
+// Middleware adds CORS headers to the responses to allowed origins and
+// answers their preflight requests. Requests from other origins, preflights
+// included, are refused with 403 before they reach the endpoint, so a page
+// cannot trigger a call even when the browser would hide the answer.
+// WebSocket handshakes are left to the endpoint, which also admits pages on
+// localhost.
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		if !c.Allowed(origin) {
+			if isWebSocketUpgrade(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			WriteError(w, http.StatusForbidden, types.ErrorPermissionDenied, "Origin not allowed: "+origin)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Expose-Headers", corsExposeHeaders)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", corsAllowMethods)
+			w.Header().Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isWebSocketUpgrade reports whether a request opens a WebSocket
+func isWebSocketUpgrade(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// normalizeOrigin lowercases an origin and drops a trailing slash, as
+// browsers send origins without one
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(origin), "/")
+}
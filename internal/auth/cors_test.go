@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSMiddleware(t *testing.T) {
+	called := false
+	handler := NewCORS([]string{"https://App.example.com/"}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		websocket   bool
+		wantOrigin  string
+		wantStatus  int
+		wantHandler bool
+	}{
+		{"no origin", "GET", "", false, false, "", http.StatusOK, true},
+		{"allowed origin", "POST", "https://app.example.com", false, false, "https://app.example.com", http.StatusOK, true},
+		{"other origin", "POST", "https://evil.example.com", false, false, "", http.StatusForbidden, false},
+		{"allowed preflight", "OPTIONS", "https://app.example.com", true, false, "https://app.example.com", http.StatusNoContent, false},
+		{"refused preflight", "OPTIONS", "https://evil.example.com", true, false, "", http.StatusForbidden, false},
+		{"plain options", "OPTIONS", "https://app.example.com", false, false, "https://app.example.com", http.StatusOK, true},
+		{"websocket from other origin", "GET", "http://localhost:3000", false, true, "", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			r := httptest.NewRequest(tt.method, "/api/call", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", "POST")
+			}
+			if tt.websocket {
+				r.Header.Set("Upgrade", "websocket")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if called != tt.wantHandler {
+				t.Errorf("Expected handler called %v, got %v", tt.wantHandler, called)
+			}
+			if tt.preflight && tt.wantOrigin != "" && w.Header().Get("Access-Control-Allow-Headers") == "" {
+				t.Error("Expected allowed headers on an allowed preflight")
+			}
+		})
+	}
+}
+
+func TestCORSAllowed(t *testing.T) {
+	if NewCORS(nil).Allowed("http://localhost:3000") {
+		t.Error("Expected no origins to be allowed by default")
+	}
+	any := NewCORS([]string{"*"})
+	if !any.Allowed("https://example.com") {
+		t.Error("Expected * to allow any origin")
+	}
+	if any.Allowed("") {
+		t.Error("Expected a missing origin not to count as allowed")
+	}
+}
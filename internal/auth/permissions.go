@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// Built-in permission profiles
+const (
+	ProfileReadOnly = "read-only" // Tools that change neither files nor the index
+	ProfileEditor   = "editor"    // Also the tools that edit files and index repositories
+	ProfileAdmin    = "admin"     // Every tool
+)
+
+// Tool groups, named in profiles with a leading @
+const (
+	GroupRead  = "read"  // Tools that change neither files nor the index
+	GroupWrite = "write" // Tools that edit files or run commands in repositories
+	GroupIndex = "index" // Tools that index repositories or refresh their index
+	GroupAdmin = "admin" // Tools that remove repositories and projects or replace the index
+)
+
+// builtinProfiles are the profiles every server knows; the configuration
+// may redefine them
+var builtinProfiles = map[string]config.PermissionProfileConfig{
+	ProfileReadOnly: {Allow: []string{"@" + GroupRead}},
+	ProfileEditor:   {Allow: []string{"@" + GroupRead, "@" + GroupWrite, "@" + GroupIndex}},
+	ProfileAdmin:    {Allow: []string{"*"}},
+}
+
+// Profile is a named set of tools a caller may use
+type Profile struct {
+	Name  string
+	allow []string
+	deny  []string
+}
+
+// Allows reports whether the profile may call a tool of a group. Deny
+// rules win over allow rules.
+func (p *Profile) Allows(tool, group string) bool {
+	for _, rule := range p.deny {
+		if ruleMatches(rule, tool, group) {
+			return false
+		}
+	}
+	for _, rule := range p.allow {
+		if ruleMatches(rule, tool, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleMatches reports whether a rule, a group such as @read or a tool name
+// glob, matches a tool
+func ruleMatches(rule, tool, group string) bool {
+	if strings.HasPrefix(rule, "@") {
+		return rule[1:] == group
+	}
+	matched, err := path.Match(rule, tool)
+	return err == nil && matched
+}
+
+// Permissions holds the permission profiles of a server
+type Permissions struct {
+	profiles       map[string]*Profile
+	defaultProfile string
+}
+
+// NewPermissions creates the profiles of the server.permissions
+// configuration on top of the built-in ones
+func NewPermissions(cfg config.PermissionsConfig) (*Permissions, error) {
+	p := &Permissions{
+		profiles:       make(map[string]*Profile),
+		defaultProfile: cfg.DefaultProfile,
+	}
+	if p.defaultProfile == "" {
+		p.defaultProfile = ProfileAdmin
+	}
+
+	add := func(name string, profileCfg config.PermissionProfileConfig) {
+		p.profiles[name] = &Profile{Name: name, allow: profileCfg.Allow, deny: profileCfg.Deny}
+	}
+	for name, profileCfg := range builtinProfiles {
+		add(name, profileCfg)
+	}
+	for name, profileCfg := range cfg.Profiles {
+		add(name, profileCfg)
+	}
+
+	if _, ok := p.profiles[p.defaultProfile]; !ok {
+		return nil, fmt.Errorf("unknown default permission profile %q", p.defaultProfile)
+	}
+	return p, nil
+}
+
+// Profile returns the named profile
+func (p *Permissions) Profile(name string) (*Profile, bool) {
+	profile, ok := p.profiles[name]
+	return profile, ok
+}
+
+// Default returns the profile of calls made without an API key
+func (p *Permissions) Default() *Profile {
+	return p.profiles[p.defaultProfile]
+}
+
+// KeyProfile returns the profile of an API key: the one it names, or else
+// read-only for keys of read scope and admin for keys of write scope
+func (p *Permissions) KeyProfile(key *Key) *Profile {
+	if profile, ok := p.profiles[key.Profile]; ok {
+		return profile
+	}
+	if key.Scope == ScopeWrite {
+		return p.profiles[ProfileAdmin]
+	}
+	return p.profiles[ProfileReadOnly]
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func TestPermissionProfiles(t *testing.T) {
+	permissions, err := NewPermissions(config.PermissionsConfig{
+		DefaultProfile: ProfileEditor,
+		Profiles: map[string]config.PermissionProfileConfig{
+			"ci": {Allow: []string{"@read", "run_tests"}, Deny: []string{"lsp_*"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewPermissions failed: %v", err)
+	}
+
+	tests := []struct {
+		profile, tool, group string
+		allowed              bool
+	}{
+		{ProfileReadOnly, "search_code", GroupRead, true},
+		{ProfileReadOnly, "replace_lines", GroupWrite, false},
+		{ProfileReadOnly, "index_repository", GroupIndex, false},
+		{ProfileEditor, "replace_lines", GroupWrite, true},
+		{ProfileEditor, "index_repository", GroupIndex, true},
+		{ProfileEditor, "remove_project", GroupAdmin, false},
+		{ProfileAdmin, "remove_project", GroupAdmin, true},
+		{"ci", "search_code", GroupRead, true},
+		{"ci", "run_tests", GroupWrite, true},
+		{"ci", "replace_lines", GroupWrite, false},
+		{"ci", "lsp_hover", GroupRead, false},
+	}
+	for _, tt := range tests {
+		profile, ok := permissions.Profile(tt.profile)
+		if !ok {
+			t.Fatalf("Profile %q not found", tt.profile)
+		}
+		if got := profile.Allows(tt.tool, tt.group); got != tt.allowed {
+			t.Errorf("%s.Allows(%s) = %v, want %v", tt.profile, tt.tool, got, tt.allowed)
+		}
+	}
+
+	if profile := permissions.Default(); profile.Name != ProfileEditor {
+		t.Errorf("Expected the editor default profile, got %s", profile.Name)
+	}
+	if profile := permissions.KeyProfile(&Key{Scope: ScopeRead}); profile.Name != ProfileReadOnly {
+		t.Errorf("Expected read keys to be read-only, got %s", profile.Name)
+	}
+	if profile := permissions.KeyProfile(&Key{Scope: ScopeWrite}); profile.Name != ProfileAdmin {
+		t.Errorf("Expected write keys to be admin, got %s", profile.Name)
+	}
+	if profile := permissions.KeyProfile(&Key{Scope: ScopeWrite, Profile: "ci"}); profile.Name != "ci" {
+		t.Errorf("Expected the key's own profile, got %s", profile.Name)
+	}
+
+	if _, err := NewPermissions(config.PermissionsConfig{DefaultProfile: "robot"}); err == nil {
+		t.Error("Expected an unknown default profile to be reported")
+	}
+}
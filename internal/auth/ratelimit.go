@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets is the number of client buckets kept before the ones that
+// have refilled completely are dropped
+const maxIdleBuckets = 1024
+
+// rateLimiter is a token bucket per client. Each bucket holds up to burst
+// tokens and refills at the configured rate; a request takes one token.
+type rateLimiter struct {
+	rate    float64 // Tokens added per second
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time // Replaced in tests
+	mutex   sync.Mutex
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates a limiter allowing requestsPerMinute per client,
+// with bursts of up to burst requests (requestsPerMinute when not positive)
+func newRateLimiter(requestsPerMinute, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = requestsPerMinute
+	}
+	return &rateLimiter{
+		rate:    float64(requestsPerMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// allow takes a token from the client's bucket, reporting false when the
+// bucket is empty
+func (l *rateLimiter) allow(client string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[client]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.dropFull(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[client] = b
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// dropFull forgets the clients whose buckets have refilled, since a new
+// bucket starts full anyway
+func (l *rateLimiter) dropFull(now time.Time) {
+	for client, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, client)
+		}
+	}
+}
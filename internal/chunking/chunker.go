@@ -18,17 +18,28 @@ const (
 	LineBasedChunking ChunkingStrategy = "line_based"
 	// HybridChunking combines semantic and line-based approaches
 	HybridChunking ChunkingStrategy = "hybrid"
+	// TokenBasedChunking creates chunks of whole lines filled up to a token
+	// budget
+	TokenBasedChunking ChunkingStrategy = "token_based"
 )
 
-// ChunkingConfig defines configuration for code chunking
+// defaultMaxChunkTokens is the token budget of token-based chunking when
+// MaxChunkTokens is not set
+const defaultMaxChunkTokens = 512
+
+// ChunkingConfig defines configuration for code chunking. When
+// MaxChunkTokens is set, chunks of any strategy taking up more tokens are
+// split into parts that fit, counted by Tokenizer.
 type ChunkingConfig struct {
 	Strategy         ChunkingStrategy `yaml:"strategy"`
 	MaxChunkLines    int              `yaml:"max_chunk_lines"`
 	MinChunkLines    int              `yaml:"min_chunk_lines"`
 	OverlapLines     int              `yaml:"overlap_lines"`
+	MaxChunkTokens   int              `yaml:"max_chunk_tokens"`
 	PreserveContext  bool             `yaml:"preserve_context"`
 	IncludeComments  bool             `yaml:"include_comments"`
 	IncludeImports   bool             `yaml:"include_imports"`
+	Tokenizer        Tokenizer        `yaml:"-"` // ApproximateTokenizer when nil
 }
 
 // DefaultChunkingConfig returns default chunking configuration
@@ -51,6 +62,9 @@ type Chunker struct {
 
 // NewChunker creates a new code chunker
 func NewChunker(config ChunkingConfig) *Chunker {
+	if config.Tokenizer == nil {
+		config.Tokenizer = ApproximateTokenizer{}
+	}
 	return &Chunker{
 		config: config,
 	}
@@ -58,16 +72,34 @@ func NewChunker(config ChunkingConfig) *Chunker {
 
 // ChunkFile creates semantic chunks from a code file
 func (c *Chunker) ChunkFile(file *types.CodeFile) []types.CodeChunk {
+	var chunks []types.CodeChunk
 	switch c.config.Strategy {
 	case SemanticChunking:
-		return c.semanticChunking(file)
+		chunks = c.semanticChunking(file)
 	case LineBasedChunking:
-		return c.lineBasedChunking(file)
+		chunks = c.lineBasedChunking(file)
 	case HybridChunking:
-		return c.hybridChunking(file)
+		chunks = c.hybridChunking(file)
+	case TokenBasedChunking:
+		chunks = c.tokenBasedChunking(file)
 	default:
-		return c.semanticChunking(file)
+		chunks = c.semanticChunking(file)
+	}
+
+	if c.config.MaxChunkTokens > 0 && c.config.Strategy != TokenBasedChunking {
+		var fitted []types.CodeChunk
+		for _, chunk := range chunks {
+			if c.config.Tokenizer.CountTokens(chunk.Content) > c.config.MaxChunkTokens {
+				fitted = append(fitted, c.splitChunkByTokens(chunk)...)
+			} else {
+				fitted = append(fitted, chunk)
+			}
+		}
+		chunks = fitted
 	}
+
+	trackDependencies(file, chunks)
+	return chunks
 }
 
 // semanticChunking creates chunks based on code structure
@@ -408,6 +440,103 @@ func (c *Chunker) splitLargeChunk(chunk types.CodeChunk) []types.CodeChunk {
 	return subChunks
 }
 
+// tokenBasedChunking creates chunks of consecutive lines holding up to
+// MaxChunkTokens tokens each
+func (c *Chunker) tokenBasedChunking(file *types.CodeFile) []types.CodeChunk {
+	var chunks []types.CodeChunk
+	lines := strings.Split(file.Content, "\n")
+
+	for _, window := range c.tokenWindows(lines) {
+		start, end := window[0], window[1]
+		content := strings.Join(lines[start:end], "\n")
+		chunkID := c.generateChunkID(file.ID, "block", "", start)
+
+		context := map[string]interface{}{
+			"language":   file.Language,
+			"file_path":  file.Path,
+			"chunk_type": "token_based",
+			"tokens":     c.config.Tokenizer.CountTokens(content),
+		}
+
+		chunks = append(chunks, types.CodeChunk{
+			ID:        chunkID,
+			FileID:    file.ID,
+			Type:      "block",
+			StartLine: start + 1,
+			EndLine:   end,
+			Content:   content,
+			Context:   context,
+		})
+	}
+
+	return chunks
+}
+
+// splitChunkByTokens splits a chunk taking up more than MaxChunkTokens
+// tokens into parts that fit
+func (c *Chunker) splitChunkByTokens(chunk types.CodeChunk) []types.CodeChunk {
+	var subChunks []types.CodeChunk
+	lines := strings.Split(chunk.Content, "\n")
+
+	for _, window := range c.tokenWindows(lines) {
+		start, end := window[0], window[1]
+		content := strings.Join(lines[start:end], "\n")
+		chunkID := c.generateChunkID(chunk.FileID, chunk.Type+"_part", chunk.Name, chunk.StartLine+start)
+
+		// Copy and update context
+		context := make(map[string]interface{})
+		for k, v := range chunk.Context {
+			context[k] = v
+		}
+		context["is_partial"] = true
+		context["part_number"] = len(subChunks) + 1
+		context["tokens"] = c.config.Tokenizer.CountTokens(content)
+
+		subChunks = append(subChunks, types.CodeChunk{
+			ID:        chunkID,
+			FileID:    chunk.FileID,
+			Type:      chunk.Type,
+			Name:      chunk.Name,
+			StartLine: chunk.StartLine + start,
+			EndLine:   chunk.StartLine + end - 1,
+			Content:   content,
+			Context:   context,
+		})
+	}
+
+	return subChunks
+}
+
+// tokenWindows returns the [start, end) line ranges of lines, in order,
+// holding up to MaxChunkTokens tokens each. A window always takes at least
+// one line, so a line longer than the budget makes a window of its own.
+// Consecutive windows share up to OverlapLines lines.
+func (c *Chunker) tokenWindows(lines []string) [][2]int {
+	budget := c.config.MaxChunkTokens
+	if budget <= 0 {
+		budget = defaultMaxChunkTokens
+	}
+	lineTokens := make([]int, len(lines))
+	for i, line := range lines {
+		lineTokens[i] = c.config.Tokenizer.CountTokens(line + "\n")
+	}
+
+	var windows [][2]int
+	for start := 0; start < len(lines); {
+		end, tokens := start+1, lineTokens[start]
+		for end < len(lines) && tokens+lineTokens[end] <= budget {
+			tokens += lineTokens[end]
+			end++
+		}
+		windows = append(windows, [2]int{start, end})
+		if end == len(lines) {
+			break
+		}
+		start = max(start+1, end-c.config.OverlapLines)
+	}
+	return windows
+}
+
 // generateChunkID generates a unique ID for a chunk
 func (c *Chunker) generateChunkID(fileID, chunkType, name string, startLine int) string {
 	data := fmt.Sprintf("%s:%s:%s:%d", fileID, chunkType, name, startLine)
@@ -209,6 +209,78 @@ func TestHybridChunking(t *testing.T) {
 	}
 }
 
+func TestTokenBasedChunking(t *testing.T) {
+	config := ChunkingConfig{
+		Strategy:       TokenBasedChunking,
+		MaxChunkTokens: 20,
+		OverlapLines:   1,
+	}
+	chunker := NewChunker(config)
+
+	file := &types.CodeFile{
+		ID:       "test-file",
+		Language: "go",
+		Content:  generateLongContent(30),
+	}
+
+	chunks := chunker.ChunkFile(file)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected multiple chunks for long content, got %d", len(chunks))
+	}
+
+	tokenizer := ApproximateTokenizer{}
+	for i, chunk := range chunks {
+		if tokens := tokenizer.CountTokens(chunk.Content); tokens > config.MaxChunkTokens {
+			t.Errorf("Chunk %d exceeds the token budget: %d > %d", i, tokens, config.MaxChunkTokens)
+		}
+		if i > 0 && chunk.StartLine != chunks[i-1].EndLine {
+			t.Errorf("Expected chunk %d to overlap the previous one by a line, got lines %d-%d after %d-%d",
+				i, chunk.StartLine, chunk.EndLine, chunks[i-1].StartLine, chunks[i-1].EndLine)
+		}
+	}
+	if last := chunks[len(chunks)-1]; last.EndLine != 31 {
+		t.Errorf("Expected the chunks to cover the file, last ends at line %d", last.EndLine)
+	}
+}
+
+func TestMaxChunkTokensSplitsChunks(t *testing.T) {
+	config := DefaultChunkingConfig()
+	config.MaxChunkTokens = 30
+	config.PreserveContext = false
+	config.Tokenizer = CharacterTokenizer{}
+	chunker := NewChunker(config)
+
+	file := &types.CodeFile{
+		ID:       "test-file",
+		Language: "go",
+		Content:  generateLongContent(40),
+		Functions: []types.Function{
+			{Name: "small", StartLine: 1, EndLine: 2, Signature: "func small()"},
+			{Name: "large", StartLine: 10, EndLine: 30, Signature: "func large()"},
+		},
+	}
+
+	chunks := chunker.ChunkFile(file)
+	parts := 0
+	for _, chunk := range chunks {
+		if tokens := config.Tokenizer.CountTokens(chunk.Content); tokens > config.MaxChunkTokens {
+			t.Errorf("Chunk %s at lines %d-%d exceeds the token budget: %d", chunk.Name, chunk.StartLine, chunk.EndLine, tokens)
+		}
+		if chunk.Name == "large" {
+			parts++
+			if chunk.Context["is_partial"] != true {
+				t.Errorf("Expected the parts of large to be marked partial, got %v", chunk.Context)
+			}
+		}
+		if chunk.Name == "small" && chunk.Context["is_partial"] != nil {
+			t.Errorf("Expected small to be kept whole, got %v", chunk.Context)
+		}
+	}
+	if parts < 2 {
+		t.Errorf("Expected large to be split, got %d parts", parts)
+	}
+}
+
 func TestChunkIDGeneration(t *testing.T) {
 	config := DefaultChunkingConfig()
 	chunker := NewChunker(config)
@@ -0,0 +1,92 @@
+package chunking
+
+import (
+	"path"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Prefixes of the dependencies recorded for a chunk
+const (
+	DependencyCall   = "call:"   // Function or method called, e.g. "call:Normalize" or "call:strings.ToLower"
+	DependencyType   = "type:"   // Type used, e.g. "type:User"
+	DependencyImport = "import:" // Module imported, e.g. "import:strings"
+)
+
+// trackDependencies records in each chunk the symbols referenced within
+// it, from the calls and type usages the parser found walking the file's
+// syntax tree. Symbols reached through an imported package are qualified by
+// its name and add the module to the chunk's imports, as do the import
+// statements the chunk holds. Function and class chunks only take the
+// references inside their declaration, not those in the surrounding context
+// lines, and leave out references to themselves.
+func trackDependencies(file *types.CodeFile, chunks []types.CodeChunk) {
+	imported := make(map[string]string)
+	for _, imp := range file.Imports {
+		alias := imp.Alias
+		if alias == "" {
+			alias = path.Base(imp.Module)
+		}
+		imported[alias] = imp.Module
+	}
+
+	for idx := range chunks {
+		chunk := &chunks[idx]
+		start, end := declarationRange(file, chunk)
+		seen := make(map[string]bool)
+		add := func(dependency string) {
+			if !seen[dependency] {
+				seen[dependency] = true
+				chunk.Dependencies = append(chunk.Dependencies, dependency)
+			}
+		}
+
+		for _, reference := range file.References {
+			if reference.Line < start || reference.Line > end {
+				continue
+			}
+			prefix := DependencyCall
+			if reference.Kind == "type" {
+				prefix = DependencyType
+			}
+			if module, ok := imported[reference.Qualifier]; ok && reference.Qualifier != "" {
+				add(prefix + reference.Qualifier + "." + reference.Name)
+				add(DependencyImport + module)
+				continue
+			}
+			if reference.Name != chunk.Name {
+				add(prefix + reference.Name)
+			}
+		}
+		for _, imp := range file.Imports {
+			if imp.Module != "" && imp.StartLine >= start && imp.StartLine <= end {
+				add(DependencyImport + imp.Module)
+			}
+		}
+	}
+}
+
+// declarationRange returns the lines of a chunk its dependencies are taken
+// from: for function and class chunks the part of the declaration they
+// hold, for other chunks all of their lines
+func declarationRange(file *types.CodeFile, chunk *types.CodeChunk) (int, int) {
+	start, end := chunk.StartLine, chunk.EndLine
+	clip := func(declStart, declEnd int) (int, int) {
+		return max(start, declStart), min(end, declEnd)
+	}
+	switch chunk.Type {
+	case "function":
+		for _, function := range file.Functions {
+			if function.Name == chunk.Name && function.StartLine <= end && function.EndLine >= start {
+				return clip(function.StartLine, function.EndLine)
+			}
+		}
+	case "class":
+		for _, class := range file.Classes {
+			if class.Name == chunk.Name && class.StartLine <= end && class.EndLine >= start {
+				return clip(class.StartLine, class.EndLine)
+			}
+		}
+	}
+	return start, end
+}
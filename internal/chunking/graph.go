@@ -0,0 +1,156 @@
+package chunking
+
+import (
+	"path"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// GraphNode is a chunk of the dependency graph
+type GraphNode struct {
+	ID        string   `json:"id"` // Chunk ID
+	FilePath  string   `json:"file_path"`
+	Name      string   `json:"name,omitempty"`
+	ChunkType string   `json:"chunk_type"`
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	Imports   []string `json:"imports,omitempty"`  // Modules the chunk uses
+	External  []string `json:"external,omitempty"` // Referenced symbols no chunk of the repository defines
+}
+
+// GraphEdge runs from a chunk to a chunk defining symbols it references
+type GraphEdge struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Symbols []string `json:"symbols"` // Dependencies of From defined by To, e.g. "call:Normalize"
+}
+
+// Graph is the chunk-level dependency graph of a repository
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// BuildGraph resolves the dependencies of chunks to the chunks defining the
+// symbols they reference. A symbol is defined by the function and class
+// chunks of its name, by the first part of those split in several; a chunk
+// in the same file is preferred over chunks elsewhere. A symbol qualified by
+// a package, such as "call:auth.Verify", only resolves to chunks in a
+// directory of the package's name, and is external otherwise.
+func BuildGraph(chunks []types.ChunkDependencies) *Graph {
+	graph := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+
+	// Defining chunks by symbol name, one per file
+	definitions := make(map[string][]int)
+	defined := make(map[[2]string]bool)
+	for idx, chunk := range chunks {
+		if chunk.Name == "" || (chunk.ChunkType != "function" && chunk.ChunkType != "class") {
+			continue
+		}
+		key := [2]string{chunk.FilePath, chunk.Name}
+		if !defined[key] {
+			defined[key] = true
+			definitions[chunk.Name] = append(definitions[chunk.Name], idx)
+		}
+	}
+
+	for idx, chunk := range chunks {
+		node := GraphNode{
+			ID:        chunk.ChunkID,
+			FilePath:  chunk.FilePath,
+			Name:      chunk.Name,
+			ChunkType: chunk.ChunkType,
+			StartLine: chunk.StartLine,
+			EndLine:   chunk.EndLine,
+		}
+		edges := make(map[string]int)
+		for _, dependency := range chunk.Dependencies {
+			if module, ok := strings.CutPrefix(dependency, DependencyImport); ok {
+				node.Imports = append(node.Imports, module)
+				continue
+			}
+			targets := resolveDependency(chunks, definitions, idx, dependency)
+			if len(targets) == 0 {
+				node.External = append(node.External, dependency)
+				continue
+			}
+			for _, target := range targets {
+				to := chunks[target].ChunkID
+				if edge, ok := edges[to]; ok {
+					graph.Edges[edge].Symbols = append(graph.Edges[edge].Symbols, dependency)
+					continue
+				}
+				edges[to] = len(graph.Edges)
+				graph.Edges = append(graph.Edges, GraphEdge{From: chunk.ChunkID, To: to, Symbols: []string{dependency}})
+			}
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return graph
+}
+
+// resolveDependency returns the indexes of the chunks defining the symbol a
+// dependency of chunks[from] references
+func resolveDependency(chunks []types.ChunkDependencies, definitions map[string][]int, from int, dependency string) []int {
+	_, symbol, ok := strings.Cut(dependency, ":")
+	if !ok {
+		return nil
+	}
+	qualifier, name := "", symbol
+	if dot := strings.LastIndex(symbol, "."); dot >= 0 {
+		qualifier, name = symbol[:dot], symbol[dot+1:]
+	}
+
+	source := chunks[from]
+	var sameFile, elsewhere []int
+	for _, idx := range definitions[name] {
+		target := chunks[idx]
+		switch {
+		case target.FilePath == source.FilePath && target.Name == source.Name:
+			// The chunk itself, or another part of it
+		case qualifier != "":
+			if path.Base(path.Dir(target.FilePath)) == qualifier {
+				elsewhere = append(elsewhere, idx)
+			}
+		case target.FilePath == source.FilePath:
+			sameFile = append(sameFile, idx)
+		default:
+			elsewhere = append(elsewhere, idx)
+		}
+	}
+	if len(sameFile) > 0 {
+		return sameFile
+	}
+	return elsewhere
+}
+
+// Around returns the subgraph of the chunks of a file, the chunks they
+// depend on and the chunks depending on them
+func (g *Graph) Around(filePath string) *Graph {
+	keep := make(map[string]bool)
+	for _, node := range g.Nodes {
+		if node.FilePath == filePath {
+			keep[node.ID] = true
+		}
+	}
+	inFile := make(map[string]bool, len(keep))
+	for id := range keep {
+		inFile[id] = true
+	}
+
+	sub := &Graph{Nodes: []GraphNode{}, Edges: []GraphEdge{}}
+	for _, edge := range g.Edges {
+		if inFile[edge.From] || inFile[edge.To] {
+			keep[edge.From] = true
+			keep[edge.To] = true
+			sub.Edges = append(sub.Edges, edge)
+		}
+	}
+	for _, node := range g.Nodes {
+		if keep[node.ID] {
+			sub.Nodes = append(sub.Nodes, node)
+		}
+	}
+	return sub
+}
@@ -0,0 +1,85 @@
+package chunking
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestChunkDependencies(t *testing.T) {
+	chunker := NewChunker(ChunkingConfig{Strategy: SemanticChunking, MaxChunkLines: 100, OverlapLines: 2, PreserveContext: true, IncludeImports: true})
+
+	file := &types.CodeFile{
+		ID:       "greet.go",
+		Language: "go",
+		Content:  "package app\n\nimport \"strings\"\n\nfunc Normalize(name string) string {\n\treturn strings.ToLower(name)\n}\n\nfunc Greet(user User) string {\n\treturn \"hello \" + Normalize(user.Name)\n}\n",
+		Functions: []types.Function{
+			{Name: "Normalize", StartLine: 5, EndLine: 7},
+			{Name: "Greet", StartLine: 9, EndLine: 11},
+		},
+		Imports: []types.Import{{Module: "strings", StartLine: 3}},
+		References: []types.Reference{
+			{Name: "ToLower", Kind: "call", Qualifier: "strings", Caller: "Normalize", Line: 6},
+			{Name: "User", Kind: "type", Caller: "Greet", Line: 9},
+			{Name: "Normalize", Kind: "call", Caller: "Greet", Line: 10},
+		},
+	}
+
+	dependencies := make(map[string][]string)
+	for _, chunk := range chunker.ChunkFile(file) {
+		dependencies[chunk.Name+"/"+chunk.Type] = chunk.Dependencies
+	}
+
+	expected := map[string][]string{
+		"/header":            {"import:strings"},
+		"Normalize/function": {"call:strings.ToLower", "import:strings"},
+		"Greet/function":     {"type:User", "call:Normalize"},
+	}
+	for key, want := range expected {
+		if got := dependencies[key]; !reflect.DeepEqual(got, want) {
+			t.Errorf("Expected the dependencies of %s to be %v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestBuildGraph(t *testing.T) {
+	chunks := []types.ChunkDependencies{
+		{ChunkID: "normalize", FilePath: "app/greet.go", Name: "Normalize", ChunkType: "function", StartLine: 5, EndLine: 7,
+			Dependencies: []string{"call:strings.ToLower", "import:strings"}},
+		{ChunkID: "greet", FilePath: "app/greet.go", Name: "Greet", ChunkType: "function", StartLine: 9, EndLine: 11,
+			Dependencies: []string{"type:User", "call:Normalize", "call:auth.Verify"}},
+		{ChunkID: "verify", FilePath: "auth/verify.go", Name: "Verify", ChunkType: "function", StartLine: 3, EndLine: 5},
+		{ChunkID: "normalize-other", FilePath: "text/normalize.go", Name: "Normalize", ChunkType: "function", StartLine: 1, EndLine: 3},
+		{ChunkID: "run", FilePath: "cmd/run.go", Name: "Run", ChunkType: "function", StartLine: 1, EndLine: 4,
+			Dependencies: []string{"call:Greet"}},
+	}
+
+	graph := BuildGraph(chunks)
+	if len(graph.Nodes) != len(chunks) {
+		t.Fatalf("Expected a node per chunk, got %d", len(graph.Nodes))
+	}
+	edges := make(map[[2]string][]string)
+	for _, edge := range graph.Edges {
+		edges[[2]string{edge.From, edge.To}] = edge.Symbols
+	}
+	expected := map[[2]string][]string{
+		{"greet", "normalize"}: {"call:Normalize"},
+		{"greet", "verify"}:    {"call:auth.Verify"},
+		{"run", "greet"}:       {"call:Greet"},
+	}
+	if !reflect.DeepEqual(edges, expected) {
+		t.Errorf("Expected edges %v, got %v", expected, edges)
+	}
+	if node := graph.Nodes[0]; !reflect.DeepEqual(node.Imports, []string{"strings"}) || !reflect.DeepEqual(node.External, []string{"call:strings.ToLower"}) {
+		t.Errorf("Expected Normalize to use strings from outside, got %+v", node)
+	}
+	if node := graph.Nodes[1]; !reflect.DeepEqual(node.External, []string{"type:User"}) {
+		t.Errorf("Expected User to be external to Greet, got %+v", node)
+	}
+
+	around := graph.Around("cmd/run.go")
+	if len(around.Nodes) != 2 || len(around.Edges) != 1 {
+		t.Errorf("Expected Run and Greet around cmd/run.go, got %+v", around)
+	}
+}
@@ -0,0 +1,90 @@
+package chunking
+
+import (
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a text takes up in the context of a language
+// or embedding model
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// Names of the built-in tokenizers
+const (
+	// ApproximateTokenizerName names ApproximateTokenizer
+	ApproximateTokenizerName = "approximate"
+	// CharacterTokenizerName names CharacterTokenizer
+	CharacterTokenizerName = "characters"
+)
+
+// NewTokenizer returns the built-in tokenizer of the given name, the
+// approximate one when name is empty
+func NewTokenizer(name string) (Tokenizer, error) {
+	switch name {
+	case "", ApproximateTokenizerName:
+		return ApproximateTokenizer{}, nil
+	case CharacterTokenizerName:
+		return CharacterTokenizer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q: use %s or %s", name, ApproximateTokenizerName, CharacterTokenizerName)
+	}
+}
+
+// ApproximateTokenizer estimates the token count of byte pair encodings
+// such as tiktoken's cl100k_base without their vocabulary. Words are split
+// at case changes and take a token per six letters, numbers a token per
+// three digits, and every punctuation mark or non-ASCII character a token of
+// its own. A run of whitespace is one token, and a single space before a
+// word is part of the word.
+type ApproximateTokenizer struct{}
+
+// CountTokens implements Tokenizer
+func (ApproximateTokenizer) CountTokens(text string) int {
+	runes := []rune(text)
+	tokens := 0
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		j := i + 1
+		switch {
+		case r == ' ' && j < len(runes) && isASCIIWordRune(runes[j]):
+			// Merged into the word that follows
+		case unicode.IsSpace(r):
+			for j < len(runes) && unicode.IsSpace(runes[j]) && !(runes[j] == ' ' && j+1 < len(runes) && isASCIIWordRune(runes[j+1])) {
+				j++
+			}
+			tokens++
+		case r < utf8.RuneSelf && unicode.IsDigit(r):
+			for j < len(runes) && runes[j] < utf8.RuneSelf && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens += (j - i + 2) / 3
+		case r < utf8.RuneSelf && unicode.IsLetter(r):
+			for j < len(runes) && runes[j] < utf8.RuneSelf && unicode.IsLetter(runes[j]) &&
+				!(unicode.IsUpper(runes[j]) && unicode.IsLower(runes[j-1])) {
+				j++
+			}
+			tokens += (j - i + 5) / 6
+		default:
+			tokens++
+		}
+		i = j
+	}
+	return tokens
+}
+
+// isASCIIWordRune reports whether r is an ASCII letter or digit
+func isASCIIWordRune(r rune) bool {
+	return r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// CharacterTokenizer counts a token per four bytes, the usual rule of thumb
+// for English text and code
+type CharacterTokenizer struct{}
+
+// CountTokens implements Tokenizer
+func (CharacterTokenizer) CountTokens(text string) int {
+	return (len(text) + 3) / 4
+}
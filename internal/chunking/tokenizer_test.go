@@ -0,0 +1,39 @@
+package chunking
+
+import "testing"
+
+func TestApproximateTokenizer(t *testing.T) {
+	tokenizer := ApproximateTokenizer{}
+
+	tests := []struct {
+		text   string
+		tokens int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"parseConfigFile", 3},
+		{"x := 12345", 6},
+		{"\n\t\treturn nil\n", 4},
+		{"日本", 2},
+	}
+	for _, test := range tests {
+		if got := tokenizer.CountTokens(test.text); got != test.tokens {
+			t.Errorf("CountTokens(%q) = %d, expected %d", test.text, got, test.tokens)
+		}
+	}
+}
+
+func TestNewTokenizer(t *testing.T) {
+	for _, name := range []string{"", ApproximateTokenizerName, CharacterTokenizerName} {
+		if _, err := NewTokenizer(name); err != nil {
+			t.Errorf("Expected tokenizer %q, got: %v", name, err)
+		}
+	}
+	if _, err := NewTokenizer("cl100k"); err == nil {
+		t.Error("Expected an unknown tokenizer to be rejected")
+	}
+
+	if got := (CharacterTokenizer{}).CountTokens("abcdefghi"); got != 3 {
+		t.Errorf("Expected 3 tokens for 9 characters, got %d", got)
+	}
+}
@@ -1,108 +1,429 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Indexer IndexerConfig `mapstructure:"indexer"`
-	Search  SearchConfig  `mapstructure:"search"`
-	Server  ServerConfig  `mapstructure:"server"`
-	Logging LoggingConfig `mapstructure:"logging"`
-	Models  ModelsConfig  `mapstructure:"models"`
+	Indexer      IndexerConfig      `mapstructure:"indexer"`
+	Index        IndexConfig        `mapstructure:"index"`
+	Repositories []RepositoryConfig `mapstructure:"repositories" desc:"Repositories indexed when the server starts and by sync_configured_repositories, each with a name, a path or url, and optional branch, include_patterns, exclude_patterns, chunk_strategy and watch"`
+	Search       SearchConfig       `mapstructure:"search"`
+	Embeddings   EmbeddingsConfig   `mapstructure:"embeddings"`
+	Server       ServerConfig       `mapstructure:"server"`
+	Logging      LoggingConfig      `mapstructure:"logging"`
+	Models       ModelsConfig       `mapstructure:"models"`
+	LSP          LSPConfig          `mapstructure:"lsp"`
+	Diagnostics  DiagnosticsConfig  `mapstructure:"diagnostics"`
+	Tests        TestsConfig        `mapstructure:"tests"`
+	Smells       SmellsConfig       `mapstructure:"smells"`
+	Secrets      SecretsConfig      `mapstructure:"secrets"`
 }
 
 // IndexerConfig represents indexer-specific configuration
 type IndexerConfig struct {
-	SupportedExtensions []string `mapstructure:"supported_extensions"`
-	MaxFileSize         int64    `mapstructure:"max_file_size"`
-	ExcludePatterns     []string `mapstructure:"exclude_patterns"`
-	IndexDir            string   `mapstructure:"index_dir"`
-	RepoDir             string   `mapstructure:"repo_dir"`
+	SupportedExtensions []string            `mapstructure:"supported_extensions" desc:"File extensions (with leading dot) that are indexed"`
+	MaxFileSize         int64               `mapstructure:"max_file_size" desc:"Maximum size in bytes of a file that will be indexed"`
+	ExcludePatterns     []string            `mapstructure:"exclude_patterns" desc:"Glob patterns for files and directories skipped during indexing"`
+	IncludePatterns     []string            `mapstructure:"include_patterns" desc:"Glob patterns limiting indexing to the files matching one of them (empty: every file)"`
+	SkipDirs            []string            `mapstructure:"skip_dirs" desc:"Names of directories holding vendored or generated code, skipped wherever they appear"`
+	SkipBinary          bool                `mapstructure:"skip_binary" desc:"Skip files that look binary, having a NUL byte in their first 8000 bytes"`
+	IgnorePatterns      []string            `mapstructure:"ignore_patterns" desc:"Patterns in .gitignore syntax applied to every repository over its own .gitignore files, e.g. \"!dist/keep.js\" to index a file a repository ignores"`
+	GlobalGitignore     bool                `mapstructure:"global_gitignore" desc:"Also skip the files ignored by the global excludes file of git (core.excludesFile, or ~/.config/git/ignore)"`
+	LanguageOverrides   map[string][]string `mapstructure:"language_overrides" desc:"Languages given to the files matching glob patterns, taking precedence over detection, e.g. html: [\"*.tpl\"]; a pattern such as \".tpl\" stands for an extension"`
+	DataDir             string              `mapstructure:"data_dir" desc:"Directory holding index_dir and repo_dir when they are not set; the user data directory when empty"`
+	IndexDir            string              `mapstructure:"index_dir" desc:"Directory holding the search index; data_dir/index when empty"`
+	RepoDir             string              `mapstructure:"repo_dir" desc:"Directory where remote repositories are cloned; data_dir/repositories when empty"`
+	MemoryIndex         bool                `mapstructure:"memory_index" desc:"Keep the index in memory and clone into a temporary directory removed on exit, ignoring index_dir and repo_dir"`
+	Concurrency         int                 `mapstructure:"concurrency" desc:"Number of files of a repository parsed at the same time (0: one per CPU)"`
+	BatchSize           int64               `mapstructure:"batch_size" desc:"Approximate size in bytes of the documents written to the index at once"`
+	CloneCache          CloneCacheConfig    `mapstructure:"clone_cache"`
+	Clone               CloneConfig         `mapstructure:"clone"`
+	Jobs                JobsConfig          `mapstructure:"jobs"`
+	Chunking            ChunkingConfig      `mapstructure:"chunking"`
+}
+
+// IndexConfig controls what the documents of the search index hold
+type IndexConfig struct {
+	StoreFullContent bool `mapstructure:"store_full_content" desc:"Store the full content of file, chunk and symbol documents; when off only a snippet is stored and content is read from disk for results"`
+}
+
+// RepositoryConfig is a repository the server indexes on its own, with the
+// settings index_repository would otherwise be called with
+type RepositoryConfig struct {
+	Name            string   `mapstructure:"name"`             // Name the repository is indexed under
+	Path            string   `mapstructure:"path"`             // Local directory; "~" and environment variables are expanded
+	URL             string   `mapstructure:"url"`              // Git URL cloned into repo_dir, instead of path
+	Branch          string   `mapstructure:"branch"`           // Branch, tag or commit to index instead of the one checked out
+	IncludePatterns []string `mapstructure:"include_patterns"` // Overrides of indexer.include_patterns
+	ExcludePatterns []string `mapstructure:"exclude_patterns"` // Overrides of indexer.exclude_patterns
+	ChunkStrategy   string   `mapstructure:"chunk_strategy"`   // Overrides indexer.chunking.strategy
+	Watch           bool     `mapstructure:"watch"`            // Re-index the repository when files below path change
+}
+
+// ChunkingConfig controls how indexed files are split into the chunks that
+// are searched and embedded. index_repository can override the strategy and
+// limits per repository.
+type ChunkingConfig struct {
+	Strategy       string `mapstructure:"strategy" desc:"How files are split: semantic (one chunk per function and class), line_based (windows of max_chunk_lines lines), hybrid (semantic, with chunks over max_chunk_lines split) or token_based (windows of whole lines holding up to max_chunk_tokens tokens)"`
+	MaxChunkLines  int    `mapstructure:"max_chunk_lines" desc:"Lines per chunk of the line_based and hybrid strategies"`
+	MinChunkLines  int    `mapstructure:"min_chunk_lines" desc:"Minimum lines of code outside functions and classes that make a chunk of their own"`
+	OverlapLines   int    `mapstructure:"overlap_lines" desc:"Lines shared by consecutive chunks, and of context around function and class chunks"`
+	MaxChunkTokens int    `mapstructure:"max_chunk_tokens" desc:"Token budget of a chunk: token_based windows are filled up to it and chunks of other strategies over it are split (0: no limit; token_based then uses 512)"`
+	Tokenizer      string `mapstructure:"tokenizer" desc:"How tokens are counted: approximate (estimates byte pair encodings such as cl100k_base) or characters (four characters per token)"`
+}
+
+// JobsConfig controls the queue of background indexing jobs started with
+// index_repository async=true
+type JobsConfig struct {
+	Workers   int `mapstructure:"workers" desc:"Number of repositories indexed in the background at the same time (default: 2)"`
+	QueueSize int `mapstructure:"queue_size" desc:"Maximum number of jobs waiting for a worker; further jobs are refused (default: 100)"`
+}
+
+// CloneCacheConfig controls the shared git object cache used when cloning
+// remote repositories
+type CloneCacheConfig struct {
+	Enabled    bool   `mapstructure:"enabled" desc:"Clone remote repositories through a shared local mirror per URL so repeated clones reuse its objects"`
+	Dir        string `mapstructure:"dir" desc:"Directory holding the mirrors (default: .object-cache inside repo_dir)"`
+	MaxAgeDays int    `mapstructure:"max_age_days" desc:"Remove mirrors no clone uses that have been idle this many days (0 keeps them forever)"`
+}
+
+// CloneConfig controls how remote repositories are cloned and the
+// credentials private repositories are cloned with. index_repository can
+// override depth, single_branch and sparse checkout per repository.
+type CloneConfig struct {
+	Depth        int                   `mapstructure:"depth" desc:"Commits fetched from the tip of each branch when cloning (0: the whole history); shallow clones bypass the clone cache"`
+	SingleBranch bool                  `mapstructure:"single_branch" desc:"Fetch only the branch checked out, or the ref asked for, instead of every branch"`
+	Credentials  []GitCredentialConfig `mapstructure:"credentials" desc:"Credentials of private repositories, each for a host with a token, token_env or ssh_key"`
+}
+
+// GitCredentialConfig authenticates clones and fetches from one host: HTTPS
+// URLs with a token, SSH URLs with a private key
+type GitCredentialConfig struct {
+	Host             string `mapstructure:"host"`               // Host name the credential is used for, e.g. github.com
+	Username         string `mapstructure:"username"`           // User sent with the token, "git" when empty
+	Token            string `mapstructure:"token"`              // Access token for HTTPS URLs
+	TokenEnv         string `mapstructure:"token_env"`          // Environment variable holding the token instead of token
+	SSHKey           string `mapstructure:"ssh_key"`            // Private key file for SSH URLs; "~" and environment variables are expanded
+	SSHKeyPassphrase string `mapstructure:"ssh_key_passphrase"` // Passphrase of an encrypted ssh_key
 }
 
 // SearchConfig represents search-specific configuration
 type SearchConfig struct {
-	MaxResults        int     `mapstructure:"max_results"`
-	HighlightSnippets bool    `mapstructure:"highlight_snippets"`
-	SnippetLength     int     `mapstructure:"snippet_length"`
-	FuzzyTolerance    float64 `mapstructure:"fuzzy_tolerance"`
+	MaxResults        int                 `mapstructure:"max_results" desc:"Default maximum number of search results"`
+	HighlightSnippets bool                `mapstructure:"highlight_snippets" desc:"Highlight matched terms in result snippets"`
+	SnippetLength     int                 `mapstructure:"snippet_length" desc:"Maximum length of result snippets in characters"`
+	FuzzyTolerance    float64             `mapstructure:"fuzzy_tolerance" desc:"Fuzzy matching tolerance between 0 (exact) and 1"`
+	PopularityWeight  float64             `mapstructure:"popularity_weight" desc:"How strongly symbol reference counts boost ranking, between 0 (off) and 1"`
+	CacheSize         int                 `mapstructure:"cache_size" desc:"Result pages of recent searches kept in memory and served again until their repositories change; 0 turns the cache off"`
+	Workspaces        map[string][]string `mapstructure:"workspaces" desc:"Named groups of repositories that tools accept as workspace instead of a repository, e.g. backend: [api, worker]"`
+	Storage           StorageConfig       `mapstructure:"storage"`
+	Ranking           RankingConfig       `mapstructure:"ranking"`
+}
+
+// RankingConfig weighs search results. Field boosts weigh where a query
+// matched; type boosts multiply the score of each document type so
+// definitions rank above comments and whole files.
+type RankingConfig struct {
+	Profile             string             `mapstructure:"profile" desc:"Scoring profile of queries that select none: default, symbols, recent or text"`
+	NameBoost           float64            `mapstructure:"name_boost" desc:"Weight of matches in symbol and file names"`
+	ExactNameBoost      float64            `mapstructure:"exact_name_boost" desc:"Extra weight of names matching the whole query"`
+	ContentBoost        float64            `mapstructure:"content_boost" desc:"Weight of matches in content"`
+	PathBoost           float64            `mapstructure:"path_boost" desc:"Weight of matches in file paths"`
+	TypeBoosts          map[string]float64 `mapstructure:"type_boosts" desc:"Score factor of each document type; types not listed keep their score"`
+	RecencyWeight       float64            `mapstructure:"recency_weight" desc:"How strongly recently modified files are boosted, between 0 (off) and 1; the recent profile uses at least 0.5"`
+	RecencyHalfLifeDays int                `mapstructure:"recency_half_life_days" desc:"Days after which the recency boost of a file halves"`
+}
+
+// StorageConfig controls what the search index keeps on disk. Changes only
+// take effect for newly created indexes unless RebuildOnChange is set.
+type StorageConfig struct {
+	SkipContentTypes   []string `mapstructure:"skip_content_types" desc:"Document types whose content is searchable but not stored (file, function, class, variable, comment, chunk)"`
+	DocValueOnlyFields []string `mapstructure:"doc_value_only_fields" desc:"Fields kept only as doc values for sorting and filtering, not returned in results"`
+	TermVectors        bool     `mapstructure:"term_vectors" desc:"Store term vectors for text fields (needed for highlighting)"`
+	DocValues          bool     `mapstructure:"doc_values" desc:"Build doc values for keyword, numeric and date fields"`
+	StoreFullContent   bool     `mapstructure:"-"` // Set from index.store_full_content by SearchStorage
+	RebuildOnChange    bool     `mapstructure:"rebuild_on_change" desc:"Recreate the index when its stored mapping differs from these settings (repositories must be re-indexed)"`
+}
+
+// EmbeddingsConfig controls the vector index used for semantic search
+type EmbeddingsConfig struct {
+	Enabled        bool    `mapstructure:"enabled" desc:"Embed code chunks while indexing and enable semantic and hybrid search"`
+	Provider       string  `mapstructure:"provider" desc:"Embedding backend: local (hashed identifier features, no model files) or openai (any OpenAI-compatible embeddings API)"`
+	Model          string  `mapstructure:"model" desc:"Embedding model requested from the openai provider"`
+	Dimensions     int     `mapstructure:"dimensions" desc:"Vector size of the local provider"`
+	BaseURL        string  `mapstructure:"base_url" desc:"Base URL of the OpenAI-compatible API"`
+	APIKeyEnv      string  `mapstructure:"api_key_env" desc:"Environment variable holding the API key for the openai provider"`
+	BatchSize      int     `mapstructure:"batch_size" desc:"Chunks sent per embeddings API request"`
+	TimeoutSeconds int     `mapstructure:"timeout_seconds" desc:"Seconds before an embeddings API request is abandoned"`
+	HybridWeight   float64 `mapstructure:"hybrid_weight" desc:"Share of the semantic score in hybrid search, between 0 (keyword only) and 1 (semantic only)"`
 }
 
 // ServerConfig represents server-specific configuration
 type ServerConfig struct {
-	Name           string             `mapstructure:"name"`
-	Version        string             `mapstructure:"version"`
-	EnableRecovery bool               `mapstructure:"enable_recovery"`
-	MultiSession   MultiSessionConfig `mapstructure:"multi_session"`
-	MultiIDE       MultiIDEConfig     `mapstructure:"multi_ide"`
+	Name           string               `mapstructure:"name" desc:"Server name reported to MCP clients"`
+	Version        string               `mapstructure:"version" desc:"Server version reported to MCP clients"`
+	EnableRecovery bool                 `mapstructure:"enable_recovery" desc:"Recover from panics inside tool handlers"`
+	ReadOnly       bool                 `mapstructure:"read_only" desc:"Disable the tools that modify files (delete_lines, insert_at_line, replace_lines, replace_symbol_body, insert_after_symbol, insert_before_symbol, rename_symbol, undo_last_edit, redo_edit)"`
+	AllowedPaths   []string             `mapstructure:"allowed_paths" desc:"Directories besides indexed repositories and repo_dir that file tools may access and local repositories may be indexed from; the working directory when empty"`
+	Auth           AuthConfig           `mapstructure:"auth"`
+	Permissions    PermissionsConfig    `mapstructure:"permissions"`
+	TLS            TLSConfig            `mapstructure:"tls"`
+	CORS           CORSConfig           `mapstructure:"cors"`
+	Timeouts       ToolTimeoutsConfig   `mapstructure:"timeouts"`
+	ResponseLimits ResponseLimitsConfig `mapstructure:"response_limits"`
+	MultiSession   MultiSessionConfig   `mapstructure:"multi_session"`
+	MultiIDE       MultiIDEConfig       `mapstructure:"multi_ide"`
+}
+
+// AuthConfig controls access to the network endpoints of the daemon and
+// serve-http commands; the stdio server is not affected
+type AuthConfig struct {
+	Enabled   bool            `mapstructure:"enabled" desc:"Require an API key on every HTTP and WebSocket request"`
+	Keys      []APIKeyConfig  `mapstructure:"keys" desc:"Accepted API keys, each with a name, a key or key_env holding its secret, and a scope of read or write"`
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+}
+
+// APIKeyConfig is a credential accepted by the HTTP endpoints, sent as an
+// X-API-Key header or an Authorization bearer token
+type APIKeyConfig struct {
+	Name    string `mapstructure:"name"`    // Identifies the key in logs
+	Key     string `mapstructure:"key"`     // Secret value
+	KeyEnv  string `mapstructure:"key_env"` // Environment variable holding the secret instead of key
+	Scope   string `mapstructure:"scope"`   // "read" for tools that do not modify files, "write" for all tools
+	Profile string `mapstructure:"profile"` // Permission profile; read-only for scope read and admin for scope write when empty
+}
+
+// PermissionsConfig limits the tools a call may use through permission
+// profiles. A call is allowed when the profile of its API key, or
+// default_profile without one, and the profile of its session, if it was
+// given one, both allow the tool.
+type PermissionsConfig struct {
+	DefaultProfile string                             `mapstructure:"default_profile" desc:"Profile of calls made without an API key, such as over stdio: read-only, editor, admin or one of profiles"`
+	Profiles       map[string]PermissionProfileConfig `mapstructure:"profiles" desc:"Profiles by name, adding to or redefining read-only, editor and admin; allow and deny list tool names, globs such as lsp_*, groups (@read, @write, @index, @admin) or * for every tool, and deny wins"`
+}
+
+// PermissionProfileConfig lists the tools a permission profile may call
+type PermissionProfileConfig struct {
+	Allow []string `mapstructure:"allow"` // Tools, globs or groups the profile may call
+	Deny  []string `mapstructure:"deny"`  // Tools, globs or groups it may not call, overriding allow
+}
+
+// RateLimitConfig limits how fast each client, identified by its API key or
+// else its address, may call the HTTP endpoints
+type RateLimitConfig struct {
+	RequestsPerMinute int `mapstructure:"requests_per_minute" desc:"Requests allowed per client per minute (0 disables rate limiting)"`
+	Burst             int `mapstructure:"burst" desc:"Requests a client may make in a burst above the steady rate (default: requests_per_minute)"`
+}
+
+// TLSConfig serves the network endpoints of the daemon and serve-http
+// commands over HTTPS when both files are set
+type TLSConfig struct {
+	CertFile string `mapstructure:"cert_file" desc:"PEM certificate chain served over HTTPS (requires key_file; overridden by --tls-cert)"`
+	KeyFile  string `mapstructure:"key_file" desc:"PEM private key of the certificate (requires cert_file; overridden by --tls-key)"`
+}
+
+// Enabled reports whether a certificate and key are configured
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// ToolTimeoutsConfig bounds how long a tool call may run. A call past its
+// limit is cancelled and answered with a timeout error.
+type ToolTimeoutsConfig struct {
+	DefaultSeconds int            `mapstructure:"default_seconds" desc:"Seconds a tool call may run before it is cancelled (0 for no limit)"`
+	Tools          map[string]int `mapstructure:"tools" desc:"Seconds individual tools may run, by tool name, overriding default_seconds; 0 lifts the limit of a tool"`
+}
+
+// Timeout returns the time limit of a tool, or 0 when it has none
+func (t ToolTimeoutsConfig) Timeout(tool string) time.Duration {
+	seconds, ok := t.Tools[tool]
+	if !ok {
+		seconds = t.DefaultSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// MinResponseBytes is the smallest size limit of tool results
+const MinResponseBytes = 1024
+
+// ResponseLimitsConfig bounds the size of tool results. A larger result is
+// cut to its top ranked entries and the rest is read with continue_response.
+type ResponseLimitsConfig struct {
+	DefaultBytes           int            `mapstructure:"default_bytes" desc:"Bytes of a tool result before it is truncated and continued (0 for no limit)"`
+	Tools                  map[string]int `mapstructure:"tools" desc:"Bytes of the results of individual tools, by tool name, overriding default_bytes; 0 lifts the limit of a tool"`
+	ContinuationTTLMinutes int            `mapstructure:"continuation_ttl_minutes" desc:"Minutes the rest of a truncated result can be read with its continuation token"`
+	MaxContinuations       int            `mapstructure:"max_continuations" desc:"Continuation tokens kept at once; the oldest expire first"`
+}
+
+// Limit returns the size limit of the results of a tool in bytes, or 0
+// when it has none
+func (r ResponseLimitsConfig) Limit(tool string) int {
+	bytes, ok := r.Tools[tool]
+	if !ok {
+		bytes = r.DefaultBytes
+	}
+	return bytes
+}
+
+// CORSConfig lists the browser origins allowed to call the network endpoints
+type CORSConfig struct {
+	AllowedOrigins []string `mapstructure:"allowed_origins" desc:"Origins, such as https://app.example.com, that browser pages may call the HTTP and WebSocket endpoints from; \"*\" allows any origin. Pages on localhost may always open WebSockets"`
 }
 
 // MultiSessionConfig represents multi-session configuration
 type MultiSessionConfig struct {
-	Enabled                bool `mapstructure:"enabled"`
-	MaxSessions            int  `mapstructure:"max_sessions"`
-	SessionTimeoutMinutes  int  `mapstructure:"session_timeout_minutes"`
-	CleanupIntervalMinutes int  `mapstructure:"cleanup_interval_minutes"`
-	IsolateWorkspaces      bool `mapstructure:"isolate_workspaces"`
-	SharedIndexing         bool `mapstructure:"shared_indexing"`
+	Enabled                bool `mapstructure:"enabled" desc:"Enable multi-session support"`
+	MaxSessions            int  `mapstructure:"max_sessions" desc:"Maximum number of concurrent sessions"`
+	SessionTimeoutMinutes  int  `mapstructure:"session_timeout_minutes" desc:"Minutes of inactivity before a session expires"`
+	CleanupIntervalMinutes int  `mapstructure:"cleanup_interval_minutes" desc:"Minutes between expired session sweeps"`
+	IsolateWorkspaces      bool `mapstructure:"isolate_workspaces" desc:"Confine sessions with a default workspace or workspace directory to its repositories"`
+	SharedIndexing         bool `mapstructure:"shared_indexing" desc:"Share one search index between sessions"`
 }
 
 // MultiIDEConfig represents multi-IDE configuration
 type MultiIDEConfig struct {
-	Enabled                  bool                     `mapstructure:"enabled"`
-	MaxConnections           int                      `mapstructure:"max_connections"`
-	ConnectionTimeoutSeconds int                      `mapstructure:"connection_timeout_seconds"`
-	CleanupIntervalMinutes   int                      `mapstructure:"cleanup_interval_minutes"`
-	TransportTypes           []string                 `mapstructure:"transport_types"`
+	Enabled                  bool                     `mapstructure:"enabled" desc:"Enable multi-IDE connection management"`
+	MaxConnections           int                      `mapstructure:"max_connections" desc:"Maximum number of concurrent IDE connections"`
+	ConnectionTimeoutSeconds int                      `mapstructure:"connection_timeout_seconds" desc:"Seconds of inactivity before a connection is dropped"`
+	CleanupIntervalMinutes   int                      `mapstructure:"cleanup_interval_minutes" desc:"Minutes between stale connection sweeps"`
+	TransportTypes           []string                 `mapstructure:"transport_types" desc:"Transports accepted from IDEs (http, websocket, stdio)"`
 	ResourceManagement       ResourceManagementConfig `mapstructure:"resource_management"`
 	Locking                  LockingConfig            `mapstructure:"locking"`
 	Monitoring               MonitoringConfig         `mapstructure:"monitoring"`
 }
 
-// ResourceManagementConfig represents resource management configuration
+// ResourceManagementConfig represents resource management configuration. Its
+// quotas apply to each WebSocket connection and to each client, by API key
+// or IP address, of /api/call and the HTTP transports.
 type ResourceManagementConfig struct {
-	IsolationMode           string `mapstructure:"isolation_mode"` // shared, workspace, full
-	MaxConcurrentOperations int    `mapstructure:"max_concurrent_operations"`
-	OperationTimeoutMinutes int    `mapstructure:"operation_timeout_minutes"`
-	EnableOperationQueue    bool   `mapstructure:"enable_operation_queue"`
+	IsolationMode           string `mapstructure:"isolation_mode" desc:"Resource isolation between connections (shared, workspace, full)"`
+	MaxConcurrentOperations int    `mapstructure:"max_concurrent_operations" desc:"Maximum concurrent tool calls per connection, 0 for no limit"`
+	OperationTimeoutMinutes int    `mapstructure:"operation_timeout_minutes" desc:"Minutes before a queued operation is abandoned"`
+	EnableOperationQueue    bool   `mapstructure:"enable_operation_queue" desc:"Queue operations that exceed the concurrency limit"`
+	SearchResultsPerMinute  int    `mapstructure:"search_results_per_minute" desc:"Search results a connection may receive per minute, 0 for no limit"`
+	EditBytesPerMinute      int64  `mapstructure:"edit_bytes_per_minute" desc:"Bytes the edit tools may write per minute for a connection, 0 for no limit"`
+	MaxIndexingJobs         int    `mapstructure:"max_indexing_jobs" desc:"Indexing runs and background indexing jobs a connection may have at once, 0 for no limit"`
 }
 
 // LockingConfig represents locking configuration
 type LockingConfig struct {
-	EnableFineGrainedLocks  bool `mapstructure:"enable_fine_grained_locks"`
-	LockTimeoutSeconds      int  `mapstructure:"lock_timeout_seconds"`
-	EnableDeadlockDetection bool `mapstructure:"enable_deadlock_detection"`
+	EnableFineGrainedLocks  bool `mapstructure:"enable_fine_grained_locks" desc:"Lock individual files and repositories instead of the whole index"`
+	LockTimeoutSeconds      int  `mapstructure:"lock_timeout_seconds" desc:"Seconds to wait when acquiring a lock"`
+	EnableDeadlockDetection bool `mapstructure:"enable_deadlock_detection" desc:"Detect and break lock cycles"`
 }
 
 // MonitoringConfig represents monitoring configuration
 type MonitoringConfig struct {
-	EnableMetrics       bool `mapstructure:"enable_metrics"`
-	LogConnections      bool `mapstructure:"log_connections"`
-	PerformanceTracking bool `mapstructure:"performance_tracking"`
+	EnableMetrics       bool `mapstructure:"enable_metrics" desc:"Collect connection and operation metrics"`
+	LogConnections      bool `mapstructure:"log_connections" desc:"Log IDE connects and disconnects"`
+	PerformanceTracking bool `mapstructure:"performance_tracking" desc:"Track per-operation timings"`
 }
 
 // LoggingConfig represents logging configuration
 type LoggingConfig struct {
-	Level      string `mapstructure:"level"`
-	Format     string `mapstructure:"format"`
-	OutputPath string `mapstructure:"output_path"`
-	File       string `mapstructure:"file"`
-	JSONFormat bool   `mapstructure:"json_format"`
+	Level      string `mapstructure:"level" desc:"Log level (debug, info, warn, error)"`
+	Format     string `mapstructure:"format" desc:"Log encoding (json, console)"`
+	OutputPath string `mapstructure:"output_path" desc:"Log destination: stdout or a file path"`
+	File       string `mapstructure:"file" desc:"Additional log file used in stdio mode"`
+	JSONFormat bool   `mapstructure:"json_format" desc:"Use JSON encoding for the log file"`
+	MaxSize    int    `mapstructure:"max_size" desc:"Megabytes a log file may reach before it is rotated (0: 100)"`
+	MaxBackups int    `mapstructure:"max_backups" desc:"Rotated log files kept (0: all of them)"`
+	MaxAge     int    `mapstructure:"max_age" desc:"Days rotated log files are kept (0: no limit)"`
+	Compress   bool   `mapstructure:"compress" desc:"Compress rotated log files with gzip"`
+}
+
+// LSPConfig configures the language servers behind the lsp_hover,
+// lsp_definition, lsp_references and lsp_diagnostics tools. A server is
+// started per repository and language on first use.
+type LSPConfig struct {
+	Enabled        bool                       `mapstructure:"enabled" desc:"Start language servers for the lsp_* tools; the tools fall back to the index when disabled or when a server is not installed"`
+	TimeoutSeconds int                        `mapstructure:"timeout_seconds" desc:"Seconds to wait for a language server to start or answer a request"`
+	Servers        map[string]LSPServerConfig `mapstructure:"servers" desc:"Language servers by language (go, python, javascript, typescript, ...), each with a command, its args and optional initialization_options"`
+}
+
+// LSPServerConfig is the command that starts a language server speaking
+// the protocol over stdio
+type LSPServerConfig struct {
+	Command               string         `mapstructure:"command"`
+	Args                  []string       `mapstructure:"args"`
+	InitializationOptions map[string]any `mapstructure:"initialization_options"`
+}
+
+// DiagnosticsConfig configures the checkers get_diagnostics runs to find
+// compile and lint errors
+type DiagnosticsConfig struct {
+	TimeoutSeconds int                        `mapstructure:"timeout_seconds" desc:"Seconds a checker may run before it is stopped"`
+	Checkers       map[string][]CheckerConfig `mapstructure:"checkers" desc:"Checkers by language, each with a name, command, args, output format (line or tsc) and the severity of what it reports; {package} and {file} in args are replaced by the checked Go package pattern and file"`
+}
+
+// CheckerConfig is a command that checks code and prints one problem per
+// line
+type CheckerConfig struct {
+	Name     string   `mapstructure:"name"`
+	Command  string   `mapstructure:"command"`
+	Args     []string `mapstructure:"args"`
+	Format   string   `mapstructure:"format"`   // "line" (file:line:column: message) or "tsc"
+	Severity string   `mapstructure:"severity"` // Of problems whose line does not name one
+}
+
+// TestsConfig configures the test commands run_tests runs
+type TestsConfig struct {
+	TimeoutSeconds int                         `mapstructure:"timeout_seconds" desc:"Seconds a test run may take before it is stopped; run_tests may ask for less"`
+	Runners        map[string]TestRunnerConfig `mapstructure:"runners" desc:"Test commands by language, each with a command, args, test_args added to run one test, and a report format (go-json, junit, jest-json or exit); {package}, {file}, {test} and {report} in args are replaced by the Go package pattern, the file or directory, the test name and a report file"`
+}
+
+// TestRunnerConfig is a command that runs the tests of a language
+type TestRunnerConfig struct {
+	Command  string   `mapstructure:"command"`
+	Args     []string `mapstructure:"args"`
+	TestArgs []string `mapstructure:"test_args"` // Added when a test name is given
+	Format   string   `mapstructure:"format"`    // "go-json", "junit", "jest-json" or "exit"
+}
+
+// SmellsConfig holds the thresholds detect_code_smells reports code smells
+// past
+type SmellsConfig struct {
+	MaxFunctionLines  int `mapstructure:"max_function_lines" desc:"Lines a function may span before it is reported as long"`
+	MaxParameters     int `mapstructure:"max_parameters" desc:"Parameters a function may take before its parameter list is reported as long"`
+	MaxNesting        int `mapstructure:"max_nesting" desc:"Depth control flow may be nested to inside a function before it is reported"`
+	MaxClassMethods   int `mapstructure:"max_class_methods" desc:"Methods a class or type may declare before it is reported as large"`
+	MaxFileLines      int `mapstructure:"max_file_lines" desc:"Lines a file may have before it is reported as large"`
+	DuplicateMinLines int `mapstructure:"duplicate_min_lines" desc:"Consecutive non-blank lines that must repeat within a file to be reported as duplicate code"`
+}
+
+// SecretsConfig configures the scan for hardcoded secrets run while files
+// are indexed
+type SecretsConfig struct {
+	Enabled          bool     `mapstructure:"enabled" desc:"Scan indexed files for hardcoded secrets such as keys, tokens and passwords"`
+	EntropyThreshold float64  `mapstructure:"entropy_threshold" desc:"Shannon entropy in bits per character above which a long quoted string is reported as a possible secret; 0 turns the entropy check off"`
+	MinEntropyLength int      `mapstructure:"min_entropy_length" desc:"Characters a quoted string needs before its entropy is checked"`
+	IgnorePatterns   []string `mapstructure:"ignore_patterns" desc:"Globs of files not scanned, matched against the path relative to the repository and its trailing sub-paths"`
 }
 
 // ModelsConfig represents AI models configuration
 type ModelsConfig struct {
-	Enabled      bool    `mapstructure:"enabled"`
-	DefaultModel string  `mapstructure:"default_model"`
-	ModelsDir    string  `mapstructure:"models_dir"`
-	MaxTokens    int     `mapstructure:"max_tokens"`
-	Temperature  float64 `mapstructure:"temperature"`
+	Enabled          bool              `mapstructure:"enabled" desc:"Enable the AI models engine"`
+	Provider         string            `mapstructure:"provider" desc:"Model backend: builtin (templates and heuristics, no model), openai (any OpenAI-compatible chat completions API), anthropic, ollama or llamacpp"`
+	DefaultModel     string            `mapstructure:"default_model" desc:"Model used when a tool does not name one"`
+	ToolModels       map[string]string `mapstructure:"tool_models" desc:"Model per tool, e.g. explain_code: a smaller model than default_model"`
+	BaseURL          string            `mapstructure:"base_url" desc:"Base URL of the provider's API; the provider's usual address when empty"`
+	APIKeyEnv        string            `mapstructure:"api_key_env" desc:"Environment variable holding the API key; OPENAI_API_KEY or ANTHROPIC_API_KEY when empty"`
+	TimeoutSeconds   int               `mapstructure:"timeout_seconds" desc:"Seconds before a model request is abandoned"`
+	MaxRetries       int               `mapstructure:"max_retries" desc:"Times a request is sent again after a rate limit, server error or timeout"`
+	RetryDelayMillis int               `mapstructure:"retry_delay_ms" desc:"Milliseconds before the first retry, doubled for each further one"`
+	ModelsDir        string            `mapstructure:"models_dir" desc:"Directory holding model files"`
+	MaxTokens        int               `mapstructure:"max_tokens" desc:"Maximum tokens generated per request"`
+	Temperature      float64           `mapstructure:"temperature" desc:"Sampling temperature between 0 and 2"`
 }
 
 // PatternSearchConfig represents pattern search configuration
@@ -169,7 +490,8 @@ func DefaultConfig() *Config {
 				".go", ".py", ".js", ".ts", ".java", ".cpp", ".c", ".h", ".hpp",
 				".rs", ".rb", ".php", ".cs", ".kt", ".swift", ".scala", ".clj",
 				".hs", ".ml", ".sh", ".bash", ".zsh", ".fish", ".ps1", ".sql",
-				".r", ".m", ".dart", ".lua", ".perl", ".pl",
+				".r", ".m", ".dart", ".lua", ".perl", ".pl", ".md", ".yaml",
+				".yml", ".json", ".proto",
 			},
 			MaxFileSize: 1048576, // 1MB
 			ExcludePatterns: []string{
@@ -179,19 +501,119 @@ func DefaultConfig() *Config {
 				"*.so", "*.dylib", "*.a", "*.lib", "*.o", "*.obj",
 				"*.min.js", "*.min.css",
 			},
-			IndexDir: "./index",
-			RepoDir:  "./repositories",
+			SkipDirs: []string{
+				"node_modules", "vendor", ".venv", "venv", "dist", "build",
+				"target", "__pycache__", ".git",
+			},
+			SkipBinary:      true,
+			GlobalGitignore: true,
+			BatchSize:       8 * 1024 * 1024, // 8MB
+			CloneCache: CloneCacheConfig{
+				Enabled:    true,
+				MaxAgeDays: 30,
+			},
+			Jobs: JobsConfig{
+				Workers:   2,
+				QueueSize: 100,
+			},
+			Chunking: ChunkingConfig{
+				Strategy:      "semantic",
+				MaxChunkLines: 100,
+				MinChunkLines: 5,
+				OverlapLines:  5,
+				Tokenizer:     "approximate",
+			},
+		},
+		Index: IndexConfig{
+			StoreFullContent: true,
 		},
 		Search: SearchConfig{
 			MaxResults:        100,
 			HighlightSnippets: true,
 			SnippetLength:     200,
 			FuzzyTolerance:    0.2,
+			PopularityWeight:  0.1,
+			CacheSize:         256,
+			Storage: StorageConfig{
+				SkipContentTypes:   []string{},
+				DocValueOnlyFields: []string{},
+				TermVectors:        true,
+				DocValues:          true,
+			},
+			Ranking: RankingConfig{
+				Profile:        "default",
+				NameBoost:      3,
+				ExactNameBoost: 2,
+				ContentBoost:   1,
+				PathBoost:      1.5,
+				TypeBoosts: map[string]float64{
+					"function":   1.5,
+					"class":      1.5,
+					"interface":  1.5,
+					"type_alias": 1.3,
+					"message":    1.5,
+					"service":    1.5,
+					"rpc":        1.5,
+					"table":      1.5,
+					"procedure":  1.5,
+					"enum":       1.3,
+					"view":       1.3,
+					"index":      1.1,
+					"variable":   1.1,
+					"config_key": 1.1,
+					"section":    0.9,
+					"chunk":      0.8,
+					"comment":    0.6,
+					"file":       0.5,
+				},
+				RecencyWeight:       0,
+				RecencyHalfLifeDays: 30,
+			},
+		},
+		Embeddings: EmbeddingsConfig{
+			Enabled:        false,
+			Provider:       "local",
+			Model:          "text-embedding-3-small",
+			Dimensions:     256,
+			BaseURL:        "https://api.openai.com/v1",
+			APIKeyEnv:      "OPENAI_API_KEY",
+			BatchSize:      64,
+			TimeoutSeconds: 30,
+			HybridWeight:   0.5,
 		},
 		Server: ServerConfig{
 			Name:           "Code Indexer",
 			Version:        "1.0.0",
 			EnableRecovery: true,
+			AllowedPaths:   []string{},
+			Auth: AuthConfig{
+				Keys: []APIKeyConfig{},
+			},
+			Permissions: PermissionsConfig{
+				DefaultProfile: "admin",
+				Profiles:       map[string]PermissionProfileConfig{},
+			},
+			CORS: CORSConfig{
+				AllowedOrigins: []string{},
+			},
+			Timeouts: ToolTimeoutsConfig{
+				DefaultSeconds: 600,
+				Tools: map[string]int{
+					"index_repository":   3600,
+					"refresh_index":      3600,
+					"switch_ref":         3600,
+					"index_dependencies": 3600,
+					"export_index":       3600,
+					"import_index":       3600,
+					"run_tests":          1800,
+				},
+			},
+			ResponseLimits: ResponseLimitsConfig{
+				DefaultBytes:           262144, // 256 KB
+				Tools:                  map[string]int{},
+				ContinuationTTLMinutes: 10,
+				MaxContinuations:       100,
+			},
 			MultiSession: MultiSessionConfig{
 				Enabled:                true,
 				MaxSessions:            10,
@@ -230,19 +652,96 @@ func DefaultConfig() *Config {
 			OutputPath: "stdout",
 			File:       "",
 			JSONFormat: true,
+			MaxSize:    100,
+			MaxBackups: 3,
+			MaxAge:     30,
 		},
 		Models: ModelsConfig{
-			Enabled:      true,
-			DefaultModel: "code-assistant-v1",
-			ModelsDir:    "./models",
-			MaxTokens:    2048,
-			Temperature:  0.7,
+			Enabled:          true,
+			Provider:         "builtin",
+			DefaultModel:     "code-assistant-v1",
+			TimeoutSeconds:   60,
+			MaxRetries:       2,
+			RetryDelayMillis: 500,
+			ModelsDir:        "./models",
+			MaxTokens:        2048,
+			Temperature:      0.7,
+		},
+		Diagnostics: DiagnosticsConfig{
+			TimeoutSeconds: 120,
+			Checkers: map[string][]CheckerConfig{
+				"go": {
+					{Name: "go build", Command: "go", Args: []string{"build", "-gcflags=-e", "-o", os.DevNull, "{package}"}, Format: "line", Severity: "error"},
+					{Name: "go vet", Command: "go", Args: []string{"vet", "{package}"}, Format: "line", Severity: "warning"},
+				},
+				"python": {
+					{Name: "pyflakes", Command: "pyflakes", Args: []string{"{file}"}, Format: "line", Severity: "warning"},
+				},
+				"typescript": {
+					{Name: "tsc", Command: "tsc", Args: []string{"--noEmit", "--pretty", "false"}, Format: "tsc", Severity: "error"},
+				},
+			},
+		},
+		Tests: TestsConfig{
+			TimeoutSeconds: 600,
+			Runners: map[string]TestRunnerConfig{
+				"go":         {Command: "go", Args: []string{"test", "-json", "{package}"}, TestArgs: []string{"-run", "^{test}$"}, Format: "go-json"},
+				"python":     {Command: "python", Args: []string{"-m", "pytest", "-q", "--junitxml={report}", "{file}"}, TestArgs: []string{"-k", "{test}"}, Format: "junit"},
+				"javascript": {Command: "npx", Args: []string{"--no-install", "jest", "--json", "--outputFile={report}", "{file}"}, TestArgs: []string{"-t", "{test}"}, Format: "jest-json"},
+				"typescript": {Command: "npx", Args: []string{"--no-install", "jest", "--json", "--outputFile={report}", "{file}"}, TestArgs: []string{"-t", "{test}"}, Format: "jest-json"},
+			},
+		},
+		Smells: SmellsConfig{
+			MaxFunctionLines:  60,
+			MaxParameters:     5,
+			MaxNesting:        4,
+			MaxClassMethods:   20,
+			MaxFileLines:      1000,
+			DuplicateMinLines: 6,
+		},
+		Secrets: SecretsConfig{
+			Enabled:          true,
+			EntropyThreshold: 4.5,
+			MinEntropyLength: 20,
+			IgnorePatterns:   []string{"go.sum", "package-lock.json", "yarn.lock", "pnpm-lock.yaml", "poetry.lock", "Cargo.lock", "*.min.js", "*.svg"},
+		},
+		LSP: LSPConfig{
+			Enabled:        true,
+			TimeoutSeconds: 30,
+			Servers: map[string]LSPServerConfig{
+				"go":         {Command: "gopls"},
+				"python":     {Command: "pyright-langserver", Args: []string{"--stdio"}},
+				"javascript": {Command: "typescript-language-server", Args: []string{"--stdio"}},
+				"typescript": {Command: "typescript-language-server", Args: []string{"--stdio"}},
+			},
 		},
 	}
 }
 
 // Load loads configuration from file and environment variables
 func Load(configPath string) (*Config, error) {
+	config, err := Read(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject values that are out of range or contradict each other, and
+	// keys of the file that nothing reads
+	if err := config.ValidateFile(UsedConfigFile()); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	// Fill in defaults and normalize paths
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("config normalization failed: %w", err)
+	}
+
+	return config, nil
+}
+
+// Read reads configuration from file and environment variables on top of the
+// defaults without validating it or touching the filesystem
+func Read(configPath string) (*Config, error) {
 	config := DefaultConfig()
 
 	viper.SetConfigType("yaml")
@@ -274,43 +773,145 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
-	// Validate and normalize paths
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
-	}
-
 	return config, nil
 }
 
-// Validate validates the configuration and normalizes paths
-func (c *Config) Validate() error {
-	// Validate indexer configuration
-	if c.Indexer.IndexDir != "" {
-		absDir, err := filepath.Abs(c.Indexer.IndexDir)
+// dataDirName is the directory below the user data directory that holds the
+// index and cloned repositories by default
+const dataDirName = "code-indexer"
+
+// ResolveDirs expands "~" and environment variables in data_dir, index_dir
+// and repo_dir, places index_dir and repo_dir in data_dir when they are not
+// set, falling back to DefaultDataDir, and makes all three absolute. It does
+// not create them.
+func (c *IndexerConfig) ResolveDirs() error {
+	if c.IndexDir == "" || c.RepoDir == "" {
+		if c.DataDir == "" {
+			dataDir, err := DefaultDataDir()
+			if err != nil {
+				return err
+			}
+			c.DataDir = dataDir
+		}
+		dataDir, err := resolvePath(c.DataDir)
 		if err != nil {
-			return fmt.Errorf("invalid indexer index directory path %s: %w", c.Indexer.IndexDir, err)
+			return fmt.Errorf("invalid indexer data directory path %s: %w", c.DataDir, err)
 		}
-		if err := os.MkdirAll(absDir, 0755); err != nil {
-			return fmt.Errorf("failed to create indexer index directory %s: %w", absDir, err)
+		c.DataDir = dataDir
+		if c.IndexDir == "" {
+			c.IndexDir = filepath.Join(dataDir, "index")
+		}
+		if c.RepoDir == "" {
+			c.RepoDir = filepath.Join(dataDir, "repositories")
 		}
-		c.Indexer.IndexDir = absDir
 	}
 
-	if c.Indexer.RepoDir != "" {
-		absDir, err := filepath.Abs(c.Indexer.RepoDir)
+	indexDir, err := resolvePath(c.IndexDir)
+	if err != nil {
+		return fmt.Errorf("invalid indexer index directory path %s: %w", c.IndexDir, err)
+	}
+	repoDir, err := resolvePath(c.RepoDir)
+	if err != nil {
+		return fmt.Errorf("invalid indexer repo directory path %s: %w", c.RepoDir, err)
+	}
+	c.IndexDir, c.RepoDir = indexDir, repoDir
+	return nil
+}
+
+// DefaultDataDir returns the directory holding the index and cloned
+// repositories when data_dir is not set: $XDG_DATA_HOME/code-indexer, or
+// ~/.local/share/code-indexer without it, on Linux and other Unix systems,
+// ~/Library/Application Support/code-indexer on macOS and
+// %APPDATA%\code-indexer on Windows
+func DefaultDataDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		appData := os.Getenv("APPDATA")
+		if appData == "" {
+			return "", errors.New("%APPDATA% is not set, set indexer.data_dir")
+		}
+		return filepath.Join(appData, dataDirName), nil
+	case "darwin", "ios":
+		home, err := os.UserHomeDir()
 		if err != nil {
-			return fmt.Errorf("invalid indexer repo directory path %s: %w", c.Indexer.RepoDir, err)
+			return "", fmt.Errorf("failed to find the data directory, set indexer.data_dir: %w", err)
+		}
+		return filepath.Join(home, "Library", "Application Support", dataDirName), nil
+	}
+
+	// Relative values are invalid per the XDG base directory specification
+	if dataHome := os.Getenv("XDG_DATA_HOME"); filepath.IsAbs(dataHome) {
+		return filepath.Join(dataHome, dataDirName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the data directory, set indexer.data_dir: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", dataDirName), nil
+}
+
+// ExpandPath expands environment variables in a path and a leading "~" to
+// the home directory of the current user
+func ExpandPath(path string) (string, error) {
+	path = os.ExpandEnv(path)
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// resolvePath expands a path with ExpandPath and makes it absolute
+func resolvePath(path string) (string, error) {
+	expanded, err := ExpandPath(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(expanded)
+}
+
+// UsedConfigFile returns the path of the configuration file picked up by the
+// last Read, or an empty string when only defaults were used
+func UsedConfigFile() string {
+	return viper.ConfigFileUsed()
+}
+
+// validate fills in defaults for unset values and normalizes paths,
+// creating the configured directories if they do not exist yet
+func (c *Config) validate() error {
+	// Validate indexer configuration. In memory index mode nothing is
+	// written to the configured directories, so they are not created.
+	if !c.Indexer.MemoryIndex {
+		if err := c.Indexer.ResolveDirs(); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(c.Indexer.IndexDir, 0755); err != nil {
+			return fmt.Errorf("failed to create indexer index directory %s: %w", c.Indexer.IndexDir, err)
 		}
-		if err := os.MkdirAll(absDir, 0755); err != nil {
-			return fmt.Errorf("failed to create indexer repo directory %s: %w", absDir, err)
+		if err := os.MkdirAll(c.Indexer.RepoDir, 0755); err != nil {
+			return fmt.Errorf("failed to create indexer repo directory %s: %w", c.Indexer.RepoDir, err)
 		}
-		c.Indexer.RepoDir = absDir
 	}
 
 	if c.Indexer.MaxFileSize <= 0 {
 		c.Indexer.MaxFileSize = 10 * 1024 * 1024 // 10MB default
 	}
 
+	for idx, allowed := range c.Server.AllowedPaths {
+		absPath, err := filepath.Abs(allowed)
+		if err != nil {
+			return fmt.Errorf("invalid server allowed path %s: %w", allowed, err)
+		}
+		c.Server.AllowedPaths[idx] = absPath
+	}
+
+	if c.Server.Permissions.DefaultProfile == "" {
+		c.Server.Permissions.DefaultProfile = "admin"
+	}
+
 	// Validate Models configuration
 	if c.Models.Enabled {
 		if c.Models.ModelsDir != "" {
@@ -331,6 +932,17 @@ func (c *Config) Validate() error {
 		if c.Models.Temperature < 0 || c.Models.Temperature > 2 {
 			c.Models.Temperature = 0.7
 		}
+
+		modelDefaults := DefaultConfig().Models
+		if c.Models.Provider == "" {
+			c.Models.Provider = modelDefaults.Provider
+		}
+		if c.Models.TimeoutSeconds <= 0 {
+			c.Models.TimeoutSeconds = modelDefaults.TimeoutSeconds
+		}
+		if c.Models.RetryDelayMillis <= 0 {
+			c.Models.RetryDelayMillis = modelDefaults.RetryDelayMillis
+		}
 	}
 
 	// Validate numeric values
@@ -338,6 +950,58 @@ func (c *Config) Validate() error {
 		c.Indexer.MaxFileSize = 1048576 // 1MB default
 	}
 
+	if c.Indexer.BatchSize <= 0 {
+		c.Indexer.BatchSize = 8 * 1024 * 1024 // 8MB default
+	}
+
+	if c.Indexer.Jobs.Workers <= 0 {
+		c.Indexer.Jobs.Workers = 2
+	}
+
+	if c.Indexer.Jobs.QueueSize <= 0 {
+		c.Indexer.Jobs.QueueSize = 100
+	}
+
+	if c.Indexer.Chunking.MaxChunkLines <= 0 {
+		c.Indexer.Chunking.MaxChunkLines = 100
+	}
+
+	if c.LSP.TimeoutSeconds <= 0 {
+		c.LSP.TimeoutSeconds = 30
+	}
+
+	if c.Diagnostics.TimeoutSeconds <= 0 {
+		c.Diagnostics.TimeoutSeconds = 120
+	}
+
+	if c.Tests.TimeoutSeconds <= 0 {
+		c.Tests.TimeoutSeconds = 600
+	}
+
+	smells := DefaultConfig().Smells
+	if c.Smells.MaxFunctionLines <= 0 {
+		c.Smells.MaxFunctionLines = smells.MaxFunctionLines
+	}
+	if c.Smells.MaxParameters <= 0 {
+		c.Smells.MaxParameters = smells.MaxParameters
+	}
+	if c.Smells.MaxNesting <= 0 {
+		c.Smells.MaxNesting = smells.MaxNesting
+	}
+	if c.Smells.MaxClassMethods <= 0 {
+		c.Smells.MaxClassMethods = smells.MaxClassMethods
+	}
+	if c.Smells.MaxFileLines <= 0 {
+		c.Smells.MaxFileLines = smells.MaxFileLines
+	}
+	if c.Smells.DuplicateMinLines <= 0 {
+		c.Smells.DuplicateMinLines = smells.DuplicateMinLines
+	}
+
+	if c.Secrets.MinEntropyLength <= 0 {
+		c.Secrets.MinEntropyLength = 20
+	}
+
 	if c.Search.MaxResults <= 0 {
 		c.Search.MaxResults = 100
 	}
@@ -350,6 +1014,38 @@ func (c *Config) Validate() error {
 		c.Search.FuzzyTolerance = 0.2
 	}
 
+	if c.Search.Ranking.Profile == "" {
+		c.Search.Ranking.Profile = "default"
+	}
+
+	if c.Search.Ranking.RecencyHalfLifeDays <= 0 {
+		c.Search.Ranking.RecencyHalfLifeDays = 30
+	}
+
+	// Validate embeddings configuration
+	defaults := DefaultConfig().Embeddings
+	if c.Embeddings.Provider == "" {
+		c.Embeddings.Provider = defaults.Provider
+	}
+	if c.Embeddings.Model == "" {
+		c.Embeddings.Model = defaults.Model
+	}
+	if c.Embeddings.Dimensions <= 0 {
+		c.Embeddings.Dimensions = defaults.Dimensions
+	}
+	if c.Embeddings.BaseURL == "" {
+		c.Embeddings.BaseURL = defaults.BaseURL
+	}
+	if c.Embeddings.APIKeyEnv == "" {
+		c.Embeddings.APIKeyEnv = defaults.APIKeyEnv
+	}
+	if c.Embeddings.BatchSize <= 0 {
+		c.Embeddings.BatchSize = defaults.BatchSize
+	}
+	if c.Embeddings.TimeoutSeconds <= 0 {
+		c.Embeddings.TimeoutSeconds = defaults.TimeoutSeconds
+	}
+
 	// Validate log level
 	validLevels := map[string]bool{
 		"debug": true, "info": true, "warn": true, "error": true,
@@ -428,6 +1124,14 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// SearchStorage returns the storage settings of the search index, with
+// the content the documents hold taken from the index section
+func (c *Config) SearchStorage() StorageConfig {
+	storage := c.Search.Storage
+	storage.StoreFullContent = c.Index.StoreFullContent
+	return storage
+}
+
 // IsFileSupported checks if a file extension is supported for indexing
 func (c *Config) IsFileSupported(filename string) bool {
 	ext := filepath.Ext(filename)
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -122,6 +125,9 @@ func TestShouldExcludeFile(t *testing.T) {
 }
 
 func TestConfigValidation(t *testing.T) {
+	// Keep the default data directory out of the home directory
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
 	cfg := DefaultConfig()
 
 	// Test with invalid values
@@ -154,6 +160,9 @@ func TestConfigValidation(t *testing.T) {
 }
 
 func TestLoadConfigFromFile(t *testing.T) {
+	// Keep the default data directory out of the home directory
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+
 	// Create a temporary config file
 	tempDir := t.TempDir()
 	configFile := filepath.Join(tempDir, "test-config.yaml")
@@ -234,6 +243,7 @@ func TestLoadConfigNotFound(t *testing.T) {
 
 	// Create a temporary directory to ensure no config file exists
 	tempDir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", tempDir)
 	originalWd, _ := os.Getwd()
 	defer os.Chdir(originalWd)
 
@@ -273,3 +283,524 @@ func TestConfigDirectoryCreation(t *testing.T) {
 		t.Error("Expected repo directory to be created")
 	}
 }
+
+func TestResolveDirs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("APPDATA", filepath.Join(home, "appdata"))
+	t.Setenv("XDG_DATA_HOME", filepath.Join(home, "xdg"))
+	t.Setenv("INDEXER_TEST_DIR", filepath.Join(home, "env"))
+
+	defaultDataDir, err := DefaultDataDir()
+	if err != nil {
+		t.Fatalf("DefaultDataDir failed: %v", err)
+	}
+	if !strings.HasPrefix(defaultDataDir, home) || filepath.Base(defaultDataDir) != "code-indexer" {
+		t.Errorf("Expected the data directory below the user's directories, got %s", defaultDataDir)
+	}
+
+	tests := []struct {
+		name      string
+		indexer   IndexerConfig
+		wantIndex string
+		wantRepo  string
+	}{
+		{
+			name:      "defaults to the user data directory",
+			wantIndex: filepath.Join(defaultDataDir, "index"),
+			wantRepo:  filepath.Join(defaultDataDir, "repositories"),
+		},
+		{
+			name:      "data directory with a tilde",
+			indexer:   IndexerConfig{DataDir: "~/data"},
+			wantIndex: filepath.Join(home, "data", "index"),
+			wantRepo:  filepath.Join(home, "data", "repositories"),
+		},
+		{
+			name:      "explicit directories with environment variables",
+			indexer:   IndexerConfig{DataDir: "~/data", IndexDir: "$INDEXER_TEST_DIR/index", RepoDir: "${INDEXER_TEST_DIR}/repos"},
+			wantIndex: filepath.Join(home, "env", "index"),
+			wantRepo:  filepath.Join(home, "env", "repos"),
+		},
+		{
+			name:      "only one directory set",
+			indexer:   IndexerConfig{DataDir: "~/data", RepoDir: "~/repos"},
+			wantIndex: filepath.Join(home, "data", "index"),
+			wantRepo:  filepath.Join(home, "repos"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			indexer := tt.indexer
+			if err := indexer.ResolveDirs(); err != nil {
+				t.Fatalf("ResolveDirs failed: %v", err)
+			}
+			if indexer.IndexDir != tt.wantIndex {
+				t.Errorf("Expected index directory %s, got %s", tt.wantIndex, indexer.IndexDir)
+			}
+			if indexer.RepoDir != tt.wantRepo {
+				t.Errorf("Expected repo directory %s, got %s", tt.wantRepo, indexer.RepoDir)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultConfig(t *testing.T) {
+	cfg := DefaultConfig()
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected default config to be valid, got: %v", err)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Search.MaxResults = -5
+	cfg.Logging.Level = "verbose"
+	cfg.Server.MultiIDE.ResourceManagement.IsolationMode = "sandbox"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation to fail")
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Expected *ValidationError, got %T", err)
+	}
+
+	if len(validationErr.Errors) != 3 {
+		t.Fatalf("Expected 3 field errors, got %d: %v", len(validationErr.Errors), err)
+	}
+
+	fields := make(map[string]bool)
+	for _, fieldErr := range validationErr.Errors {
+		fields[fieldErr.Field] = true
+	}
+
+	for _, field := range []string{"search.max_results", "logging.level", "server.multi_ide.resource_management.isolation_mode"} {
+		if !fields[field] {
+			t.Errorf("Expected an error for %s", field)
+		}
+	}
+}
+
+func TestValidateCloneCredentials(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Indexer.Clone.Credentials = []GitCredentialConfig{
+		{Host: "github.com", TokenEnv: "GITHUB_TOKEN"},
+		{Host: "https://gitlab.com", Token: "secret"},
+		{Host: "bitbucket.org"},
+	}
+
+	var validationErr *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &validationErr) || len(validationErr.Errors) != 2 {
+		t.Fatalf("Expected a URL as host and a credential without secret to be rejected, got %v", err)
+	}
+	if field := validationErr.Errors[0].Field; field != "indexer.clone.credentials[1].host" {
+		t.Errorf("Expected the URL to be rejected as host, got %s", field)
+	}
+}
+
+func TestValidateRepositories(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Repositories = []RepositoryConfig{
+		{Name: "api", Path: "~/src/api", ExcludePatterns: []string{"*.pb.go"}, ChunkStrategy: "hybrid", Watch: true},
+		{Name: "docs", URL: "https://github.com/example/docs.git", Branch: "main"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid repositories, got: %v", err)
+	}
+
+	cfg.Repositories = append(cfg.Repositories,
+		RepositoryConfig{Name: "api", Path: "/src/other"},
+		RepositoryConfig{Name: "both", Path: "/src/both", URL: "https://github.com/example/both.git"},
+		RepositoryConfig{Name: "remote", URL: "https://github.com/example/remote.git", Watch: true, IncludePatterns: []string{"[a-"}},
+	)
+	var validationErr *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &validationErr) || len(validationErr.Errors) != 4 {
+		t.Fatalf("Expected a duplicate name, a path with a url, a watched url and a malformed pattern to be rejected, got %v", err)
+	}
+	if field := validationErr.Errors[0].Field; field != "repositories[2].name" {
+		t.Errorf("Expected the duplicate name to be rejected, got %s", field)
+	}
+}
+
+func TestValidateConflictingMultiSessionSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.MultiSession.Enabled = false
+	cfg.Server.MultiIDE.Enabled = true
+	cfg.Server.MultiIDE.ResourceManagement.IsolationMode = "workspace"
+
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected workspace isolation without multi-session to be rejected")
+	}
+
+	cfg.Server.MultiIDE.ResourceManagement.IsolationMode = "shared"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected shared isolation without multi-session to be valid, got: %v", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Server.MultiSession.CleanupIntervalMinutes = 240
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected cleanup interval longer than session timeout to be rejected")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Server.MultiIDE.Enabled = true
+	cfg.Server.MultiIDE.TransportTypes = []string{"stdio"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected multi-IDE support without a network transport to be rejected")
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.yaml")
+	configContent := `indexer:
+  max_file_size: 1024
+  chunking:
+    strategy: paragraphs
+
+search:
+  max_result: 50
+
+repositories:
+  - name: api
+    path: /src/api
+    wtach: true
+
+defaults: &defaults
+  level: debug
+logging:
+  <<: *defaults
+  format: json
+`
+	if err := os.WriteFile(configFile, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config file: %v", err)
+	}
+
+	cfg, err := Read(configFile)
+	if err != nil {
+		t.Fatalf("Failed to read config: %v", err)
+	}
+	var validationErr *ValidationError
+	if err := cfg.ValidateFile(configFile); !errors.As(err, &validationErr) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+
+	// Errors are sorted by the line they refer to
+	expected := []struct {
+		field string
+		line  int
+		hint  string
+	}{
+		{"indexer.chunking.strategy", 4, ""},
+		{"search.max_result", 7, "did you mean search.max_results?"},
+		{"repositories[0].wtach", 12, "did you mean repositories[0].watch?"},
+		{"defaults", 14, ""},
+	}
+	if len(validationErr.Errors) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), validationErr)
+	}
+	for i, want := range expected {
+		got := validationErr.Errors[i]
+		if got.Field != want.field || got.Line != want.line || (want.hint != "" && got.Hint != want.hint) {
+			t.Errorf("Expected %s on line %d (%s), got %s on line %d (%s)", want.field, want.line, want.hint, got.Field, got.Line, got.Hint)
+		}
+	}
+	if msg := validationErr.Errors[1].Error(); !strings.HasPrefix(msg, "line 7: search.max_result: unknown key") {
+		t.Errorf("Expected the line to lead the message, got %q", msg)
+	}
+
+	if _, err := Load(configFile); err == nil {
+		t.Error("Expected Load to refuse a file with unknown keys")
+	}
+	if err := DefaultConfig().ValidateFile(""); err != nil {
+		t.Errorf("Expected the defaults without a file to be valid, got %v", err)
+	}
+}
+
+func TestToolTimeouts(t *testing.T) {
+	cfg := DefaultConfig()
+	timeouts := cfg.Server.Timeouts
+	if got := timeouts.Timeout("search_code"); got != 600*time.Second {
+		t.Errorf("Expected the default limit for search_code, got %s", got)
+	}
+	if got := timeouts.Timeout("index_repository"); got != time.Hour {
+		t.Errorf("Expected an hour for index_repository, got %s", got)
+	}
+	timeouts.Tools["run_tests"] = 0
+	if got := timeouts.Timeout("run_tests"); got != 0 {
+		t.Errorf("Expected no limit for run_tests, got %s", got)
+	}
+
+	cfg.Server.Timeouts.DefaultSeconds = -1
+	cfg.Server.Timeouts.Tools["search_code"] = -5
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 2 {
+		t.Errorf("Expected both negative limits to be rejected, got: %v", err)
+	}
+}
+
+func TestResponseLimits(t *testing.T) {
+	cfg := DefaultConfig()
+	limits := cfg.Server.ResponseLimits
+	if got := limits.Limit("search_code"); got != 262144 {
+		t.Errorf("Expected the default limit for search_code, got %d", got)
+	}
+	limits.Tools["get_file_content"] = 0
+	if got := limits.Limit("get_file_content"); got != 0 {
+		t.Errorf("Expected no limit for get_file_content, got %d", got)
+	}
+
+	cfg.Server.ResponseLimits.DefaultBytes = 100
+	cfg.Server.ResponseLimits.Tools["search_code"] = -1
+	cfg.Server.ResponseLimits.MaxContinuations = -1
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 3 {
+		t.Errorf("Expected the tiny and negative limits to be rejected, got: %v", err)
+	}
+}
+
+func TestValidateStorageSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Search.Storage.SkipContentTypes = []string{"file", "chunk"}
+	cfg.Search.Storage.DocValueOnlyFields = []string{"indexed_at"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid storage settings, got: %v", err)
+	}
+
+	cfg.Search.Storage.SkipContentTypes = []string{"module"}
+	cfg.Search.Storage.DocValueOnlyFields = []string{"file_path"}
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 2 {
+		t.Fatalf("Expected 2 field errors, got: %v", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Search.Storage.DocValues = false
+	cfg.Search.Storage.DocValueOnlyFields = []string{"start_line"}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected doc-value-only fields without doc values to be rejected")
+	}
+}
+
+func TestValidateFileFilterSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Indexer.IncludePatterns = []string{"*.go", "src/*"}
+	cfg.Indexer.LanguageOverrides = map[string][]string{"groovy": {"Jenkinsfile*"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid file filter settings, got: %v", err)
+	}
+
+	cfg.Indexer.IncludePatterns = []string{"[*.go"}
+	cfg.Indexer.SkipDirs = []string{"web/node_modules", ""}
+	cfg.Indexer.LanguageOverrides = map[string][]string{"html": {".tpl", "[*.tmpl"}}
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 4 {
+		t.Fatalf("Expected 4 field errors, got: %v", err)
+	}
+}
+
+func TestValidateChunkingSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Indexer.Chunking.Strategy = "token_based"
+	cfg.Indexer.Chunking.MaxChunkTokens = 256
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid chunking settings, got: %v", err)
+	}
+
+	cfg.Indexer.Chunking.Strategy = "paragraphs"
+	cfg.Indexer.Chunking.Tokenizer = "cl100k"
+	cfg.Indexer.Chunking.OverlapLines = 100
+	cfg.Indexer.Chunking.MaxChunkTokens = -1
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 4 {
+		t.Fatalf("Expected 4 field errors, got: %v", err)
+	}
+}
+
+func TestValidateEmbeddingsSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Embeddings.Enabled = true
+	cfg.Embeddings.Provider = "openai"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid embeddings settings, got: %v", err)
+	}
+
+	cfg.Embeddings.Provider = "word2vec"
+	cfg.Embeddings.HybridWeight = 1.5
+	cfg.Embeddings.BatchSize = -1
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 3 {
+		t.Fatalf("Expected 3 field errors, got: %v", err)
+	}
+}
+
+func TestValidateModelsSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Models.Provider = "ollama"
+	cfg.Models.BaseURL = "http://localhost:11434"
+	cfg.Models.ToolModels = map[string]string{"explain_code": "qwen2.5-coder:1.5b"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid models settings, got: %v", err)
+	}
+
+	cfg.Models.Provider = "gemini"
+	cfg.Models.BaseURL = "localhost:11434"
+	cfg.Models.ToolModels["analyze_code"] = ""
+	cfg.Models.MaxRetries = -1
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 4 {
+		t.Fatalf("Expected 4 field errors, got: %v", err)
+	}
+}
+
+func TestValidateAllowedPaths(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.AllowedPaths = []string{"/srv/shared", " "}
+
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 1 {
+		t.Fatalf("Expected 1 field error, got: %v", err)
+	}
+	if validationErr.Errors[0].Field != "server.allowed_paths" {
+		t.Errorf("Expected an error for server.allowed_paths, got %s", validationErr.Errors[0].Field)
+	}
+}
+
+func TestValidateAuthSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.Auth.Enabled = true
+	cfg.Server.Auth.Keys = []APIKeyConfig{
+		{Name: "ci", Key: "secret", Scope: "read"},
+		{Name: "ci", KeyEnv: "EDITOR_KEY", Scope: "admin"},
+		{Name: "editor", Scope: "write"},
+	}
+
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 3 {
+		t.Fatalf("Expected 3 field errors, got: %v", err)
+	}
+
+	cfg.Server.Auth.Keys = nil
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected enabled authentication without keys to be rejected")
+	}
+}
+
+func TestValidatePermissionSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.Permissions.Profiles = map[string]PermissionProfileConfig{
+		"ci":     {Allow: []string{"@read", "run_tests"}, Deny: []string{"lsp_*"}},
+		"broken": {Allow: []string{"@everything", "[a-"}},
+		"empty":  {},
+	}
+	cfg.Server.Auth.Keys = []APIKeyConfig{
+		{Name: "ci", Key: "secret", Profile: "ci"},
+		{Name: "bot", Key: "secret", Profile: "robot"},
+		{Name: "nobody", Key: "secret"},
+	}
+
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 5 {
+		t.Fatalf("Expected 5 field errors, got: %v", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Server.Permissions.DefaultProfile = "editor"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a built-in default profile to be accepted, got: %v", err)
+	}
+	cfg.Server.Permissions.DefaultProfile = "robot"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected an unknown default profile to be rejected")
+	}
+}
+
+func TestValidateTLSAndCORSSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.TLS.CertFile = "server.crt"
+	cfg.Server.CORS.AllowedOrigins = []string{"*", "https://app.example.com", "http://localhost:3000/", "app.example.com", "https://app.example.com/path"}
+
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Errors) != 3 {
+		t.Fatalf("Expected 3 field errors, got: %v", err)
+	}
+	if validationErr.Errors[0].Field != "server.tls" {
+		t.Errorf("Expected an error for server.tls, got %s", validationErr.Errors[0].Field)
+	}
+
+	cfg.Server.TLS.KeyFile = "server.key"
+	cfg.Server.CORS.AllowedOrigins = []string{"https://app.example.com"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected a certificate with its key and a valid origin to pass, got: %v", err)
+	}
+	if !cfg.Server.TLS.Enabled() {
+		t.Error("Expected TLS to be enabled with a certificate and key")
+	}
+}
+
+func TestValidatePort(t *testing.T) {
+	if err := ValidatePort(8080); err != nil {
+		t.Errorf("Expected port 8080 to be valid, got: %v", err)
+	}
+
+	for _, port := range []int{-1, 0, 70000} {
+		if err := ValidatePort(port); err == nil {
+			t.Errorf("Expected port %d to be rejected", port)
+		}
+	}
+}
+
+func TestValidateEndpointPath(t *testing.T) {
+	for _, endpointPath := range []string{"/mcp", "/api/v1/mcp"} {
+		if err := ValidateEndpointPath(endpointPath, "/sse"); err != nil {
+			t.Errorf("Expected path %s to be valid, got: %v", endpointPath, err)
+		}
+	}
+
+	for _, endpointPath := range []string{"", "mcp", "/", "/mcp/", "/a/../mcp", "//mcp", "/mcp {x}", "/mcp?x=1", "/sse"} {
+		if err := ValidateEndpointPath(endpointPath, "/sse"); err == nil {
+			t.Errorf("Expected path %q to be rejected", endpointPath)
+		}
+	}
+}
+
+func TestOptionsDocumentDefaults(t *testing.T) {
+	docs := Options()
+	if len(docs) == 0 {
+		t.Fatal("Expected documented options")
+	}
+
+	found := false
+	for _, doc := range docs {
+		if doc.Description == "" {
+			t.Errorf("Option %s has no description", doc.Key)
+		}
+		if doc.Key == "search.max_results" {
+			found = true
+			if doc.Default != "100" {
+				t.Errorf("Expected search.max_results default 100, got %s", doc.Default)
+			}
+		}
+	}
+
+	if !found {
+		t.Error("Expected search.max_results to be documented")
+	}
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// OptionDoc documents a single configuration key
+type OptionDoc struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Default     string `json:"default"`
+	Description string `json:"description"`
+}
+
+// Options lists every documented configuration key together with its
+// default value. Keys come from the mapstructure tags and descriptions from
+// the desc tags on the configuration structs.
+func Options() []OptionDoc {
+	var docs []OptionDoc
+	collectOptions(reflect.ValueOf(*DefaultConfig()), "", &docs)
+	return docs
+}
+
+// collectOptions walks a configuration struct recursively
+func collectOptions(value reflect.Value, prefix string, docs *[]OptionDoc) {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		fieldValue := value.Field(i)
+		if fieldValue.Kind() == reflect.Struct {
+			collectOptions(fieldValue, key, docs)
+			continue
+		}
+
+		desc := field.Tag.Get("desc")
+		if desc == "" {
+			continue
+		}
+
+		*docs = append(*docs, OptionDoc{
+			Key:         key,
+			Type:        fieldValue.Type().String(),
+			Default:     formatDefault(fieldValue),
+			Description: desc,
+		})
+	}
+}
+
+// formatDefault renders a default value for documentation
+func formatDefault(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.String:
+		if value.String() == "" {
+			return `""`
+		}
+		return value.String()
+	case reflect.Slice:
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(value.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	default:
+		return fmt.Sprint(value.Interface())
+	}
+}
+
+// WriteDefaults writes the configuration reference as a Markdown table
+func WriteDefaults(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "| Key | Type | Default | Description |"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(w, "|-----|------|---------|-------------|"); err != nil {
+		return err
+	}
+	for _, doc := range Options() {
+		defaultValue := doc.Default
+		if len(defaultValue) > 60 {
+			defaultValue = defaultValue[:57] + "..."
+		}
+		if _, err := fmt.Fprintf(w, "| `%s` | %s | `%s` | %s |\n", doc.Key, doc.Type, defaultValue, doc.Description); err != nil {
+			return err
+		}
+	}
+	return nil
+}
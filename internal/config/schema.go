@@ -0,0 +1,224 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ValidateFile validates the configuration like Validate and checks the
+// configuration file it was read from against the keys the configuration
+// structs declare, reporting keys that would otherwise be ignored without
+// notice, such as misspelled ones. Errors carry the line of the file they
+// refer to and are sorted by it. An empty file only validates the values.
+func (c *Config) ValidateFile(file string) error {
+	var errs []*FieldError
+	if err := c.Validate(); err != nil {
+		validationErr, ok := err.(*ValidationError)
+		if !ok {
+			return err
+		}
+		errs = validationErr.Errors
+	}
+	if file == "" {
+		if len(errs) > 0 {
+			return &ValidationError{Errors: errs}
+		}
+		return nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	for _, fieldErr := range errs {
+		fieldErr.Line = locateField(&root, fieldErr.Field)
+	}
+	v := &validator{}
+	checkKeys(&root, reflect.TypeOf(*c), "", v)
+	errs = append(v.errors, errs...)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// Errors the file cannot be blamed for, such as defaults conflicting
+	// with a value set in it, come last
+	sort.SliceStable(errs, func(a, b int) bool {
+		if (errs[a].Line == 0) != (errs[b].Line == 0) {
+			return errs[b].Line == 0
+		}
+		return errs[a].Line < errs[b].Line
+	})
+	return &ValidationError{Errors: errs}
+}
+
+// checkKeys reports the keys of node that the configuration type t does not
+// declare in its mapstructure tags. Keys are compared case-insensitively, as
+// viper does. Maps accept any key and are checked through their values.
+func checkKeys(node *yaml.Node, t reflect.Type, field string, v *validator) {
+	node = resolveNode(node)
+	if node == nil {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := structKeys(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			name := joinField(field, key.Value)
+			fieldType, ok := fields[strings.ToLower(key.Value)]
+			if !ok {
+				hint := "run \"code-indexer config defaults\" to list the known keys"
+				if suggestion := closestKey(strings.ToLower(key.Value), fields); suggestion != "" {
+					hint = fmt.Sprintf("did you mean %s?", joinField(field, suggestion))
+				}
+				v.errors = append(v.errors, &FieldError{Field: name, Reason: "unknown key, ignored", Hint: hint, Line: key.Line})
+				continue
+			}
+			checkKeys(value, fieldType, name, v)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkKeys(node.Content[i+1], t.Elem(), joinField(field, node.Content[i].Value), v)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			checkKeys(item, t.Elem(), fmt.Sprintf("%s[%d]", field, i), v)
+		}
+	}
+}
+
+// structKeys returns the types of the fields of a configuration struct by
+// their lowercased mapstructure key
+func structKeys(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("mapstructure"), ",")
+		if key != "" && key != "-" {
+			fields[strings.ToLower(key)] = t.Field(i).Type
+		}
+	}
+	return fields
+}
+
+// closestKey returns the key of fields closest to the unknown key, or ""
+// when none is within two edits of it
+func closestKey(key string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for candidate := range fields {
+		distance := editDistance(key, candidate)
+		if distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// locateField returns the line of the configuration file holding field,
+// a dotted key with optional indexes such as "repositories[2].name", or of
+// the deepest part of it the file sets. It returns 0 when the file sets
+// none of it.
+func locateField(root *yaml.Node, field string) int {
+	node, line := resolveNode(root), 0
+	for _, part := range strings.Split(field, ".") {
+		name, indexes, _ := strings.Cut(part, "[")
+		if node == nil || node.Kind != yaml.MappingNode {
+			return line
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if strings.EqualFold(node.Content[i].Value, name) {
+				line, value = node.Content[i].Line, resolveNode(node.Content[i+1])
+				break
+			}
+		}
+		if value == nil {
+			return line
+		}
+		node = value
+
+		for indexes != "" {
+			var index string
+			index, indexes, _ = strings.Cut(indexes, "]")
+			indexes = strings.TrimPrefix(indexes, "[")
+			n, err := strconv.Atoi(index)
+			if err != nil || node.Kind != yaml.SequenceNode || n < 0 || n >= len(node.Content) {
+				return line
+			}
+			node = resolveNode(node.Content[n])
+			line = node.Line
+		}
+	}
+	return line
+}
+
+// resolveNode returns the content of a document node and the node an alias
+// refers to
+func resolveNode(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch {
+		case node.Kind == yaml.DocumentNode && len(node.Content) > 0:
+			node = node.Content[0]
+		case node.Kind == yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+	return nil
+}
+
+// joinField appends key to the dotted field
+func joinField(field, key string) string {
+	if field == "" {
+		return key
+	}
+	return field + "." + key
+}
@@ -0,0 +1,514 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FieldError describes a single invalid configuration value
+type FieldError struct {
+	Field  string      // Dotted configuration key, e.g. "search.max_results"
+	Value  interface{} // Offending value as loaded
+	Reason string      // What is wrong with the value
+	Hint   string      // How to fix it (optional)
+	Line   int         // Line of the configuration file setting it, 0 when unknown
+}
+
+// Error implements the error interface
+func (e *FieldError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Field, e.Reason)
+	if e.Value != nil {
+		msg += fmt.Sprintf(" (got %v)", e.Value)
+	}
+	if e.Line > 0 {
+		msg = fmt.Sprintf("line %d: %s", e.Line, msg)
+	}
+	if e.Hint != "" {
+		msg += "; " + e.Hint
+	}
+	return msg
+}
+
+// ValidationError collects every problem found in a configuration so that
+// all of them can be reported at once instead of one per run
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, fmt.Sprintf("%d invalid configuration values:", len(e.Errors)))
+	for _, fieldErr := range e.Errors {
+		lines = append(lines, "  - "+fieldErr.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Valid option values shared by validation and documentation
+var (
+	validLogLevels          = []string{"debug", "info", "warn", "error"}
+	validLogFormats         = []string{"json", "console"}
+	validTransportTypes     = []string{"http", "websocket", "stdio"}
+	validIsolationModes     = []string{"shared", "workspace", "full"}
+	validDocumentTypes      = []string{"file", "function", "class", "variable", "comment", "chunk"}
+	validDocValueFields     = []string{"repository_id", "language", "start_line", "end_line", "indexed_at"}
+	validEmbeddingProviders = []string{"local", "openai"}
+	validModelProviders     = []string{"builtin", "openai", "anthropic", "ollama", "llamacpp"}
+	validChunkStrategies    = []string{"semantic", "line_based", "hybrid", "token_based"}
+	validTokenizers         = []string{"approximate", "characters"}
+	validAuthScopes         = []string{"read", "write"}
+	builtinProfiles         = []string{"read-only", "editor", "admin"}
+	validPermissionGroups   = []string{"@read", "@write", "@index", "@admin"}
+	validScoringProfiles    = []string{"default", "symbols", "recent", "text"}
+	validRankedTypes        = []string{"file", "function", "class", "interface", "type_alias", "variable", "comment", "chunk", "section", "config_key", "message", "enum", "service", "rpc", "table", "view", "index", "procedure"}
+)
+
+// validator accumulates field errors during a validation pass
+type validator struct {
+	errors []*FieldError
+}
+
+func (v *validator) add(field string, value interface{}, reason, hint string) {
+	v.errors = append(v.errors, &FieldError{Field: field, Value: value, Reason: reason, Hint: hint})
+}
+
+func (v *validator) nonNegative(field string, value int64) {
+	if value < 0 {
+		v.add(field, value, "must not be negative", "use 0 to apply the default")
+	}
+}
+
+func (v *validator) oneOf(field, value string, allowed []string) {
+	if value == "" {
+		return
+	}
+	for _, candidate := range allowed {
+		if value == candidate {
+			return
+		}
+	}
+	v.add(field, value, "unknown value", "expected one of: "+strings.Join(allowed, ", "))
+}
+
+func (v *validator) inRange(field string, value, min, max float64) {
+	if value < min || value > max {
+		v.add(field, value, fmt.Sprintf("must be between %g and %g", min, max), "")
+	}
+}
+
+// Validate checks the configuration for out-of-range values, unknown enum
+// values and conflicting settings. Zero values are accepted because they are
+// replaced with defaults afterwards. The returned error is a *ValidationError.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	// Indexer
+	v.nonNegative("indexer.max_file_size", c.Indexer.MaxFileSize)
+	for _, ext := range c.Indexer.SupportedExtensions {
+		if !strings.HasPrefix(ext, ".") {
+			v.add("indexer.supported_extensions", ext, "extension must start with a dot", fmt.Sprintf("use \".%s\"", ext))
+		}
+	}
+	for _, pattern := range c.Indexer.ExcludePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			v.add("indexer.exclude_patterns", pattern, "malformed glob pattern", "check for unbalanced '[' brackets")
+		}
+	}
+	for _, pattern := range c.Indexer.IncludePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			v.add("indexer.include_patterns", pattern, "malformed glob pattern", "check for unbalanced '[' brackets")
+		}
+	}
+	for _, pattern := range c.Indexer.IgnorePatterns {
+		if strings.TrimSpace(pattern) == "" || strings.HasPrefix(pattern, "#") {
+			v.add("indexer.ignore_patterns", pattern, "pattern matches nothing", "remove empty and comment lines")
+		}
+	}
+	for language, patterns := range c.Indexer.LanguageOverrides {
+		if strings.TrimSpace(language) == "" {
+			v.add("indexer.language_overrides", language, "must name a language", "use a language such as \"html\" as the key")
+		}
+		for _, pattern := range patterns {
+			if _, err := filepath.Match(pattern, ""); err != nil || pattern == "" {
+				v.add("indexer.language_overrides."+language, pattern, "malformed glob pattern", "check for unbalanced '[' brackets")
+			}
+		}
+	}
+	for _, dir := range c.Indexer.SkipDirs {
+		if dir == "" || strings.ContainsAny(dir, "/\\") {
+			v.add("indexer.skip_dirs", dir, "must be a directory name", "list names such as \"node_modules\", not paths")
+		}
+	}
+	v.nonNegative("indexer.clone_cache.max_age_days", int64(c.Indexer.CloneCache.MaxAgeDays))
+	v.nonNegative("indexer.clone.depth", int64(c.Indexer.Clone.Depth))
+	for idx, credential := range c.Indexer.Clone.Credentials {
+		field := fmt.Sprintf("indexer.clone.credentials[%d]", idx)
+		if credential.Host == "" || strings.ContainsAny(credential.Host, "/@") {
+			v.add(field+".host", credential.Host, "must be a host name", "use a name such as \"github.com\", without scheme or path")
+		}
+		if credential.Token != "" && credential.TokenEnv != "" {
+			v.add(field, credential.Host, "token and token_env are both set", "keep one of them")
+		}
+		if credential.Token == "" && credential.TokenEnv == "" && credential.SSHKey == "" {
+			v.add(field, credential.Host, "no token, token_env or ssh_key", "")
+		}
+	}
+	v.nonNegative("indexer.concurrency", int64(c.Indexer.Concurrency))
+	v.nonNegative("indexer.batch_size", c.Indexer.BatchSize)
+	v.nonNegative("indexer.jobs.workers", int64(c.Indexer.Jobs.Workers))
+	v.nonNegative("indexer.jobs.queue_size", int64(c.Indexer.Jobs.QueueSize))
+	chunking := c.Indexer.Chunking
+	v.oneOf("indexer.chunking.strategy", chunking.Strategy, validChunkStrategies)
+	v.oneOf("indexer.chunking.tokenizer", chunking.Tokenizer, validTokenizers)
+	v.nonNegative("indexer.chunking.max_chunk_lines", int64(chunking.MaxChunkLines))
+	v.nonNegative("indexer.chunking.min_chunk_lines", int64(chunking.MinChunkLines))
+	v.nonNegative("indexer.chunking.overlap_lines", int64(chunking.OverlapLines))
+	v.nonNegative("indexer.chunking.max_chunk_tokens", int64(chunking.MaxChunkTokens))
+	if chunking.MaxChunkLines > 0 && chunking.OverlapLines >= chunking.MaxChunkLines {
+		v.add("indexer.chunking.overlap_lines", chunking.OverlapLines,
+			"must be less than max_chunk_lines", "consecutive chunks cannot share all their lines")
+	}
+
+	// Repositories
+	repositoryNames := make(map[string]bool)
+	for idx, repo := range c.Repositories {
+		field := fmt.Sprintf("repositories[%d]", idx)
+		switch {
+		case strings.TrimSpace(repo.Name) == "":
+			v.add(field+".name", repo.Name, "must name the repository", "set the name it is indexed and searched under")
+		case repositoryNames[repo.Name]:
+			v.add(field+".name", repo.Name, "repository listed twice", "give each entry its own name")
+		}
+		repositoryNames[repo.Name] = true
+		if (repo.Path == "") == (repo.URL == "") {
+			v.add(field, repo.Name, "needs either a path or a url", "set one of them")
+		}
+		if repo.Watch && repo.Path == "" {
+			v.add(field+".watch", repo.Watch, "only repositories with a path can be watched", "remove watch, or index a local checkout")
+		}
+		for _, pattern := range append(append([]string{}, repo.IncludePatterns...), repo.ExcludePatterns...) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				v.add(field, pattern, "malformed glob pattern", "check for unbalanced '[' brackets")
+			}
+		}
+		v.oneOf(field+".chunk_strategy", repo.ChunkStrategy, validChunkStrategies)
+	}
+
+	// Search
+	v.nonNegative("search.max_results", int64(c.Search.MaxResults))
+	v.nonNegative("search.snippet_length", int64(c.Search.SnippetLength))
+	v.inRange("search.fuzzy_tolerance", c.Search.FuzzyTolerance, 0, 1)
+	v.inRange("search.popularity_weight", c.Search.PopularityWeight, 0, 1)
+	v.nonNegative("search.cache_size", int64(c.Search.CacheSize))
+	for name, repositories := range c.Search.Workspaces {
+		if len(repositories) == 0 {
+			v.add("search.workspaces."+name, repositories, "must list at least one repository", "name the repositories the workspace groups")
+		}
+	}
+	for _, docType := range c.Search.Storage.SkipContentTypes {
+		v.oneOf("search.storage.skip_content_types", docType, validDocumentTypes)
+	}
+	for _, field := range c.Search.Storage.DocValueOnlyFields {
+		v.oneOf("search.storage.doc_value_only_fields", field, validDocValueFields)
+	}
+	if !c.Search.Storage.DocValues && len(c.Search.Storage.DocValueOnlyFields) > 0 {
+		v.add("search.storage.doc_value_only_fields", c.Search.Storage.DocValueOnlyFields,
+			"requires search.storage.doc_values", "enable doc_values or clear doc_value_only_fields")
+	}
+	ranking := c.Search.Ranking
+	v.oneOf("search.ranking.profile", ranking.Profile, validScoringProfiles)
+	v.inRange("search.ranking.name_boost", ranking.NameBoost, 0, 100)
+	v.inRange("search.ranking.exact_name_boost", ranking.ExactNameBoost, 0, 100)
+	v.inRange("search.ranking.content_boost", ranking.ContentBoost, 0, 100)
+	v.inRange("search.ranking.path_boost", ranking.PathBoost, 0, 100)
+	for docType, boost := range ranking.TypeBoosts {
+		v.oneOf("search.ranking.type_boosts", docType, validRankedTypes)
+		v.inRange("search.ranking.type_boosts."+docType, boost, 0, 100)
+	}
+	v.inRange("search.ranking.recency_weight", ranking.RecencyWeight, 0, 1)
+	v.nonNegative("search.ranking.recency_half_life_days", int64(ranking.RecencyHalfLifeDays))
+
+	// Embeddings
+	v.oneOf("embeddings.provider", c.Embeddings.Provider, validEmbeddingProviders)
+	v.nonNegative("embeddings.dimensions", int64(c.Embeddings.Dimensions))
+	v.nonNegative("embeddings.batch_size", int64(c.Embeddings.BatchSize))
+	v.nonNegative("embeddings.timeout_seconds", int64(c.Embeddings.TimeoutSeconds))
+	v.inRange("embeddings.hybrid_weight", c.Embeddings.HybridWeight, 0, 1)
+
+	// Logging
+	v.oneOf("logging.level", c.Logging.Level, validLogLevels)
+	v.oneOf("logging.format", c.Logging.Format, validLogFormats)
+	v.nonNegative("logging.max_size", int64(c.Logging.MaxSize))
+	v.nonNegative("logging.max_backups", int64(c.Logging.MaxBackups))
+	v.nonNegative("logging.max_age", int64(c.Logging.MaxAge))
+
+	// Tool timeouts
+	v.nonNegative("server.timeouts.default_seconds", int64(c.Server.Timeouts.DefaultSeconds))
+	for tool, seconds := range c.Server.Timeouts.Tools {
+		v.nonNegative("server.timeouts.tools."+tool, int64(seconds))
+	}
+
+	// Response limits; a result must have room for its truncation notice
+	responseLimit := func(field string, bytes int) {
+		if bytes < 0 || (bytes > 0 && bytes < MinResponseBytes) {
+			v.add(field, bytes, fmt.Sprintf("must be 0 or at least %d", MinResponseBytes), "use 0 to lift the limit")
+		}
+	}
+	responseLimit("server.response_limits.default_bytes", c.Server.ResponseLimits.DefaultBytes)
+	for tool, bytes := range c.Server.ResponseLimits.Tools {
+		responseLimit("server.response_limits.tools."+tool, bytes)
+	}
+	v.nonNegative("server.response_limits.continuation_ttl_minutes", int64(c.Server.ResponseLimits.ContinuationTTLMinutes))
+	v.nonNegative("server.response_limits.max_continuations", int64(c.Server.ResponseLimits.MaxContinuations))
+
+	// Models
+	v.oneOf("models.provider", c.Models.Provider, validModelProviders)
+	v.nonNegative("models.max_tokens", int64(c.Models.MaxTokens))
+	v.inRange("models.temperature", c.Models.Temperature, 0, 2)
+	v.nonNegative("models.timeout_seconds", int64(c.Models.TimeoutSeconds))
+	v.nonNegative("models.max_retries", int64(c.Models.MaxRetries))
+	v.nonNegative("models.retry_delay_ms", int64(c.Models.RetryDelayMillis))
+	for tool, model := range c.Models.ToolModels {
+		if strings.TrimSpace(model) == "" {
+			v.add("models.tool_models."+tool, model, "missing model", "name the model, or remove the entry to use default_model")
+		}
+	}
+	if c.Models.BaseURL != "" {
+		if parsed, err := url.Parse(c.Models.BaseURL); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			v.add("models.base_url", c.Models.BaseURL, "must be an absolute URL", "e.g. http://localhost:11434")
+		}
+	}
+
+	// Language servers
+	v.nonNegative("lsp.timeout_seconds", int64(c.LSP.TimeoutSeconds))
+	for language, server := range c.LSP.Servers {
+		if strings.TrimSpace(server.Command) == "" {
+			v.add("lsp.servers."+language+".command", server.Command, "missing command", "set the language server executable, or remove the entry")
+		}
+	}
+
+	// Diagnostics
+	v.nonNegative("diagnostics.timeout_seconds", int64(c.Diagnostics.TimeoutSeconds))
+	for language, checkers := range c.Diagnostics.Checkers {
+		for i, checker := range checkers {
+			field := fmt.Sprintf("diagnostics.checkers.%s[%d]", language, i)
+			if strings.TrimSpace(checker.Command) == "" {
+				v.add(field+".command", checker.Command, "missing command", "set the checker executable, or remove the entry")
+			}
+			switch checker.Format {
+			case "", "line", "tsc":
+			default:
+				v.add(field+".format", checker.Format, "unknown output format", "use line or tsc")
+			}
+			switch checker.Severity {
+			case "", "error", "warning", "information", "hint":
+			default:
+				v.add(field+".severity", checker.Severity, "unknown severity", "use error, warning, information or hint")
+			}
+		}
+	}
+
+	// Tests
+	v.nonNegative("tests.timeout_seconds", int64(c.Tests.TimeoutSeconds))
+	for language, runner := range c.Tests.Runners {
+		field := "tests.runners." + language
+		if strings.TrimSpace(runner.Command) == "" {
+			v.add(field+".command", runner.Command, "missing command", "set the test command, or remove the entry")
+		}
+		switch runner.Format {
+		case "", "go-json", "junit", "jest-json", "exit":
+		default:
+			v.add(field+".format", runner.Format, "unknown report format", "use go-json, junit, jest-json or exit")
+		}
+	}
+
+	// Smells
+	v.nonNegative("smells.max_function_lines", int64(c.Smells.MaxFunctionLines))
+	v.nonNegative("smells.max_parameters", int64(c.Smells.MaxParameters))
+	v.nonNegative("smells.max_nesting", int64(c.Smells.MaxNesting))
+	v.nonNegative("smells.max_class_methods", int64(c.Smells.MaxClassMethods))
+	v.nonNegative("smells.max_file_lines", int64(c.Smells.MaxFileLines))
+	v.nonNegative("smells.duplicate_min_lines", int64(c.Smells.DuplicateMinLines))
+
+	// Secrets
+	if c.Secrets.EntropyThreshold < 0 || c.Secrets.EntropyThreshold > 8 {
+		v.add("secrets.entropy_threshold", c.Secrets.EntropyThreshold, "must be between 0 and 8 bits per character", "use around 4.5, or 0 to turn the entropy check off")
+	}
+	v.nonNegative("secrets.min_entropy_length", int64(c.Secrets.MinEntropyLength))
+	for _, pattern := range c.Secrets.IgnorePatterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			v.add("secrets.ignore_patterns", pattern, "malformed glob pattern", "check for unbalanced '[' brackets")
+		}
+	}
+
+	// Server
+	for _, allowed := range c.Server.AllowedPaths {
+		if strings.TrimSpace(allowed) == "" {
+			v.add("server.allowed_paths", allowed, "empty path", "remove the entry")
+		}
+	}
+
+	// Authentication
+	auth := c.Server.Auth
+	profiles := append([]string{}, builtinProfiles...)
+	for name := range c.Server.Permissions.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles[len(builtinProfiles):])
+	names := make(map[string]bool)
+	for idx, key := range auth.Keys {
+		field := fmt.Sprintf("server.auth.keys[%d]", idx)
+		if key.Name == "" {
+			v.add(field+".name", key.Name, "missing key name", "name each key so it can be told apart in logs")
+		} else if names[key.Name] {
+			v.add(field+".name", key.Name, "duplicate key name", "")
+		}
+		names[key.Name] = true
+		if (key.Key == "") == (key.KeyEnv == "") {
+			v.add(field, key.Name, "exactly one of key and key_env must be set", "")
+		}
+		if key.Scope == "" && key.Profile == "" {
+			v.add(field+".scope", key.Scope, "missing scope", "use read or write, or set a profile")
+		}
+		v.oneOf(field+".scope", key.Scope, validAuthScopes)
+		v.oneOf(field+".profile", key.Profile, profiles)
+	}
+	if auth.Enabled && len(auth.Keys) == 0 {
+		v.add("server.auth.keys", auth.Keys, "authentication is enabled without any keys", "add a key or disable server.auth")
+	}
+	v.nonNegative("server.auth.rate_limit.requests_per_minute", int64(auth.RateLimit.RequestsPerMinute))
+	v.nonNegative("server.auth.rate_limit.burst", int64(auth.RateLimit.Burst))
+
+	// Permission profiles
+	permissions := c.Server.Permissions
+	v.oneOf("server.permissions.default_profile", permissions.DefaultProfile, profiles)
+	for _, name := range profiles[len(builtinProfiles):] {
+		profile := permissions.Profiles[name]
+		field := "server.permissions.profiles." + name
+		if len(profile.Allow) == 0 {
+			v.add(field+".allow", profile.Allow, "the profile allows no tools", "list tools, globs or groups such as @read")
+		}
+		for _, rule := range append(append([]string{}, profile.Allow...), profile.Deny...) {
+			if strings.HasPrefix(rule, "@") {
+				v.oneOf(field, rule, validPermissionGroups)
+			} else if _, err := path.Match(rule, ""); err != nil {
+				v.add(field, rule, "invalid tool pattern", err.Error())
+			}
+		}
+	}
+
+	// TLS
+	tls := c.Server.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		v.add("server.tls", tls.CertFile+tls.KeyFile, "cert_file and key_file must be set together", "set both to serve HTTPS, or neither")
+	}
+
+	// CORS
+	for _, origin := range c.Server.CORS.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			v.add("server.cors.allowed_origins", origin, "not an origin", "use scheme://host[:port], such as https://app.example.com, or \"*\"")
+		}
+	}
+
+	// Multi-session
+	ms := c.Server.MultiSession
+	v.nonNegative("server.multi_session.max_sessions", int64(ms.MaxSessions))
+	v.nonNegative("server.multi_session.session_timeout_minutes", int64(ms.SessionTimeoutMinutes))
+	v.nonNegative("server.multi_session.cleanup_interval_minutes", int64(ms.CleanupIntervalMinutes))
+	if ms.SessionTimeoutMinutes > 0 && ms.CleanupIntervalMinutes > ms.SessionTimeoutMinutes {
+		v.add("server.multi_session.cleanup_interval_minutes", ms.CleanupIntervalMinutes,
+			fmt.Sprintf("exceeds session_timeout_minutes (%d)", ms.SessionTimeoutMinutes),
+			"expired sessions would linger; lower the cleanup interval")
+	}
+
+	// Multi-IDE
+	ide := c.Server.MultiIDE
+	v.nonNegative("server.multi_ide.max_connections", int64(ide.MaxConnections))
+	v.nonNegative("server.multi_ide.connection_timeout_seconds", int64(ide.ConnectionTimeoutSeconds))
+	v.nonNegative("server.multi_ide.cleanup_interval_minutes", int64(ide.CleanupIntervalMinutes))
+	for _, transport := range ide.TransportTypes {
+		v.oneOf("server.multi_ide.transport_types", transport, validTransportTypes)
+	}
+	v.oneOf("server.multi_ide.resource_management.isolation_mode", ide.ResourceManagement.IsolationMode, validIsolationModes)
+	v.nonNegative("server.multi_ide.resource_management.max_concurrent_operations", int64(ide.ResourceManagement.MaxConcurrentOperations))
+	v.nonNegative("server.multi_ide.resource_management.operation_timeout_minutes", int64(ide.ResourceManagement.OperationTimeoutMinutes))
+	v.nonNegative("server.multi_ide.resource_management.search_results_per_minute", int64(ide.ResourceManagement.SearchResultsPerMinute))
+	v.nonNegative("server.multi_ide.resource_management.edit_bytes_per_minute", ide.ResourceManagement.EditBytesPerMinute)
+	v.nonNegative("server.multi_ide.resource_management.max_indexing_jobs", int64(ide.ResourceManagement.MaxIndexingJobs))
+	v.nonNegative("server.multi_ide.locking.lock_timeout_seconds", int64(ide.Locking.LockTimeoutSeconds))
+
+	// Conflicting settings
+	isolation := ide.ResourceManagement.IsolationMode
+	if ide.Enabled && !ms.Enabled && (isolation == "workspace" || isolation == "full") {
+		v.add("server.multi_ide.resource_management.isolation_mode", isolation,
+			"requires server.multi_session.enabled",
+			"enable multi-session support or set isolation_mode to \"shared\"")
+	}
+	if ide.Enabled && len(ide.TransportTypes) > 0 && !containsAny(ide.TransportTypes, "http", "websocket") {
+		v.add("server.multi_ide.transport_types", ide.TransportTypes,
+			"multi-IDE support needs a network transport",
+			"add http or websocket and run \"code-indexer daemon\"; a stdio server serves a single IDE")
+	}
+	if ms.Enabled && ide.Enabled && ms.MaxSessions > 0 && ide.MaxConnections > 0 && ms.MaxSessions > ide.MaxConnections {
+		v.add("server.multi_session.max_sessions", ms.MaxSessions,
+			fmt.Sprintf("exceeds server.multi_ide.max_connections (%d)", ide.MaxConnections),
+			"each session needs a connection; raise max_connections or lower max_sessions")
+	}
+
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+// containsAny reports whether values holds one of candidates
+func containsAny(values []string, candidates ...string) bool {
+	for _, value := range values {
+		for _, candidate := range candidates {
+			if value == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateEndpointPath checks that an HTTP endpoint path given on the
+// command line is a clean absolute path that does not collide with the
+// reserved paths served beside it
+func ValidateEndpointPath(endpointPath string, reserved ...string) error {
+	switch {
+	case !strings.HasPrefix(endpointPath, "/"):
+		return &FieldError{Field: "path", Value: endpointPath, Reason: "must start with /"}
+	case endpointPath == "/" || path.Clean(endpointPath) != endpointPath:
+		return &FieldError{Field: "path", Value: endpointPath, Reason: "must be a clean path below /, without a trailing slash"}
+	case strings.ContainsAny(endpointPath, " \t{}?#%"):
+		return &FieldError{Field: "path", Value: endpointPath, Reason: "must not contain spaces, braces or URL syntax"}
+	}
+	for _, taken := range reserved {
+		if endpointPath == taken {
+			return &FieldError{Field: "path", Value: endpointPath, Reason: "is reserved", Hint: "choose another path, such as /mcp"}
+		}
+	}
+	return nil
+}
+
+// ValidatePort checks that a TCP port given on the command line is usable
+func ValidatePort(port int) error {
+	if port < 1 || port > 65535 {
+		return &FieldError{Field: "port", Value: port, Reason: "must be between 1 and 65535"}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
@@ -11,8 +12,10 @@ import (
 	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 
+	"github.com/my-mcp/code-indexer/internal/auth"
 	"github.com/my-mcp/code-indexer/internal/config"
 	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
 // ConnectionType represents the type of connection
@@ -31,6 +34,8 @@ type Connection struct {
 	RemoteAddr  string         `json:"remote_addr"`
 	UserAgent   string         `json:"user_agent"`
 	SessionID   string         `json:"session_id"`
+	Profile     string         `json:"profile,omitempty"` // Permission profile of the API key the connection authenticated with
+	Client      string         `json:"client,omitempty"`  // Client of the HTTP transports a client connection stands for
 	CreatedAt   time.Time      `json:"created_at"`
 	LastActive  time.Time      `json:"last_active"`
 	Active      bool           `json:"active"`
@@ -39,11 +44,14 @@ type Connection struct {
 	WSConn      *websocket.Conn `json:"-"` // For WebSocket connections
 	HTTPWriter  http.ResponseWriter `json:"-"` // For HTTP connections
 	mutex       sync.RWMutex
+	writeMutex  sync.Mutex // Serializes writes to WSConn
+	quota       *quotaUsage
 }
 
 // Manager manages multiple IDE connections
 type Manager struct {
 	connections    map[string]*Connection
+	clients        map[string]string // IDs of the client connections keyed by client
 	sessionManager *session.Manager
 	config         *config.Config
 	logger         *zap.Logger
@@ -54,6 +62,11 @@ type Manager struct {
 	maxConnections    int
 	connectionTimeout time.Duration
 	cleanupInterval   time.Duration
+
+	// Per-connection quotas and the calls they refused
+	limits        config.ResourceManagementConfig
+	quotaExceeded map[string]int64
+	quotaMutex    sync.Mutex
 	
 	// Shutdown handling
 	shutdown chan struct{}
@@ -64,19 +77,18 @@ type Manager struct {
 func NewManager(cfg *config.Config, sessionMgr *session.Manager, logger *zap.Logger) *Manager {
 	manager := &Manager{
 		connections:       make(map[string]*Connection),
+		clients:           make(map[string]string),
 		sessionManager:    sessionMgr,
 		config:           cfg,
 		logger:           logger,
 		maxConnections:   cfg.Server.MultiIDE.MaxConnections,
 		connectionTimeout: time.Duration(cfg.Server.MultiIDE.ConnectionTimeoutSeconds) * time.Second,
 		cleanupInterval:  time.Duration(cfg.Server.MultiIDE.CleanupIntervalMinutes) * time.Minute,
+		limits:           cfg.Server.MultiIDE.ResourceManagement,
+		quotaExceeded:    make(map[string]int64),
 		shutdown:         make(chan struct{}),
 		upgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool {
-				// Allow connections from any origin for now
-				// In production, this should be more restrictive
-				return true
-			},
+			CheckOrigin: allowedOrigin(auth.NewCORS(cfg.Server.CORS.AllowedOrigins)),
 		},
 	}
 
@@ -93,11 +105,35 @@ func (m *Manager) CreateConnection(connType ConnectionType, remoteAddr, userAgen
 	defer m.mutex.Unlock()
 
 	// Check connection limits
-	if len(m.connections) >= m.maxConnections {
+	if len(m.connections)-len(m.clients) >= m.maxConnections {
 		return nil, fmt.Errorf("maximum connections reached (%d)", m.maxConnections)
 	}
+	return m.addConnection(connType, remoteAddr, userAgent), nil
+}
+
+// ClientConnection returns the connection standing for a client of the
+// HTTP transports, which have no connection of their own, creating it on
+// the client's first call. Clients are told apart by API key, or by IP
+// address without authentication, so the client is held to its quotas
+// across requests. Client connections do not count against the maximum
+// number of connections and are cleaned up like others once inactive.
+func (m *Manager) ClientConnection(client string) *Connection {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if conn, ok := m.connections[m.clients[client]]; ok {
+		conn.Touch()
+		return conn
+	}
+	conn := m.addConnection(ConnectionTypeHTTP, "", "")
+	conn.Client = client
+	m.clients[client] = conn.ID
+	return conn
+}
 
-	// Create connection
+// addConnection creates and registers a connection. The caller holds the
+// manager mutex.
+func (m *Manager) addConnection(connType ConnectionType, remoteAddr, userAgent string) *Connection {
 	ctx, cancel := context.WithCancel(context.Background())
 	conn := &Connection{
 		ID:         uuid.New().String(),
@@ -109,6 +145,7 @@ func (m *Manager) CreateConnection(connType ConnectionType, remoteAddr, userAgen
 		Active:     true,
 		Context:    ctx,
 		Cancel:     cancel,
+		quota:      newQuotaUsage(m.limits),
 	}
 
 	m.connections[conn.ID] = conn
@@ -119,9 +156,78 @@ func (m *Manager) CreateConnection(connType ConnectionType, remoteAddr, userAgen
 		zap.String("remote_addr", remoteAddr),
 		zap.String("user_agent", userAgent))
 
+	return conn
+}
+
+// localOrigin accepts WebSocket handshakes from clients that send no Origin
+// header, such as IDE extensions and command-line tools, and from pages
+// served on the loopback interface. Other browser pages are refused, so a
+// website cannot drive the daemon through the user's browser.
+func localOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// allowedOrigin accepts WebSocket handshakes from local origins and from the
+// origins the CORS policy allows
+func allowedOrigin(cors *auth.CORS) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		return localOrigin(r) || cors.Allowed(r.Header.Get("Origin"))
+	}
+}
+
+// Upgrade upgrades an HTTP request to a WebSocket and registers it as a
+// connection. On failure an error response has already been written.
+func (m *Manager) Upgrade(w http.ResponseWriter, r *http.Request) (*Connection, error) {
+	conn, err := m.CreateConnection(ConnectionTypeWebSocket, r.RemoteAddr, r.UserAgent())
+	if err != nil {
+		auth.WriteError(w, http.StatusServiceUnavailable, types.ErrorUnavailable, err.Error())
+		return nil, err
+	}
+
+	wsConn, err := m.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		m.CloseConnection(conn.ID)
+		return nil, fmt.Errorf("failed to upgrade connection: %w", err)
+	}
+
+	conn.mutex.Lock()
+	conn.WSConn = wsConn
+	conn.mutex.Unlock()
+
 	return conn, nil
 }
 
+// Touch marks a connection as active, so the cleanup loop keeps it
+func (c *Connection) Touch() {
+	c.mutex.Lock()
+	c.LastActive = time.Now()
+	c.mutex.Unlock()
+}
+
+// WriteJSON sends a message over the WebSocket of a connection. It is safe
+// for concurrent use.
+func (c *Connection) WriteJSON(v interface{}) error {
+	if c.WSConn == nil {
+		return fmt.Errorf("connection %s has no WebSocket", c.ID)
+	}
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	return c.WSConn.WriteJSON(v)
+}
+
 // GetConnection retrieves a connection by ID
 func (m *Manager) GetConnection(connectionID string) (*Connection, error) {
 	m.mutex.RLock()
@@ -160,6 +266,39 @@ func (m *Manager) AssociateSession(connectionID, sessionID string) error {
 	return nil
 }
 
+// SessionOf returns the ID of the session associated with a connection, or
+// "" when it has none
+func (m *Manager) SessionOf(connectionID string) string {
+	conn, err := m.GetConnection(connectionID)
+	if err != nil {
+		return ""
+	}
+
+	conn.mutex.RLock()
+	defer conn.mutex.RUnlock()
+	return conn.SessionID
+}
+
+// AssignProfile records the permission profile a connection's tool calls
+// are checked against
+func (m *Manager) AssignProfile(connectionID, profile string) error {
+	conn, err := m.GetConnection(connectionID)
+	if err != nil {
+		return err
+	}
+
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+
+	conn.Profile = profile
+
+	m.logger.Debug("Assigned permission profile to connection",
+		zap.String("connection_id", connectionID),
+		zap.String("profile", profile))
+
+	return nil
+}
+
 // CloseConnection closes a connection
 func (m *Manager) CloseConnection(connectionID string) error {
 	m.mutex.Lock()
@@ -185,6 +324,7 @@ func (m *Manager) CloseConnection(connectionID string) error {
 
 	// Remove from connections map
 	delete(m.connections, connectionID)
+	delete(m.clients, conn.Client)
 
 	m.logger.Info("Closed connection",
 		zap.String("connection_id", connectionID),
@@ -217,12 +357,20 @@ func (m *Manager) GetConnectionStats() map[string]interface{} {
 		"connection_types":  make(map[string]int),
 	}
 
-	// Count by type
+	// Count by type and permission profile
 	typeCounts := make(map[string]int)
+	profileCounts := make(map[string]int)
 	for _, conn := range m.connections {
 		typeCounts[string(conn.Type)]++
+		conn.mutex.RLock()
+		if conn.Profile != "" {
+			profileCounts[conn.Profile]++
+		}
+		conn.mutex.RUnlock()
 	}
 	stats["connection_types"] = typeCounts
+	stats["connection_profiles"] = profileCounts
+	stats["quotas"], stats["quota_usage"], stats["quota_exceeded"] = m.quotaStats()
 
 	return stats
 }
@@ -275,6 +423,7 @@ func (m *Manager) cleanupInactiveConnections() {
 		
 		// Remove from map
 		delete(m.connections, id)
+		delete(m.clients, conn.Client)
 
 		m.logger.Info("Cleaned up inactive connection",
 			zap.String("connection_id", id),
@@ -303,6 +452,7 @@ func (m *Manager) Close() error {
 		}
 		conn.Cancel()
 		delete(m.connections, id)
+		delete(m.clients, conn.Client)
 	}
 	m.mutex.Unlock()
 
@@ -0,0 +1,55 @@
+package connection
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/auth"
+)
+
+func TestLocalOrigin(t *testing.T) {
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://localhost:3000", true},
+		{"http://127.0.0.1:8080", true},
+		{"http://[::1]:8080", true},
+		{"vscode-file://localhost", true},
+		{"https://example.com", false},
+		{"http://localhost.example.com", false},
+		{"http://127.0.0.1.example.com", false},
+		{"null", false},
+		{"%zz", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "http://localhost:8080/ws", nil)
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if got := localOrigin(r); got != tt.want {
+			t.Errorf("Origin %q: expected %v, got %v", tt.origin, tt.want, got)
+		}
+	}
+}
+
+func TestAllowedOrigin(t *testing.T) {
+	check := allowedOrigin(auth.NewCORS([]string{"https://app.example.com"}))
+
+	for origin, want := range map[string]bool{
+		"":                          true,
+		"http://localhost:3000":     true,
+		"https://app.example.com":   true,
+		"https://other.example.com": false,
+	} {
+		r := httptest.NewRequest("GET", "http://localhost:8080/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if got := check(r); got != want {
+			t.Errorf("Origin %q: expected %v, got %v", origin, want, got)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// Quotas a connection may run into, as reported in quota errors and stats
+const (
+	QuotaConcurrentCalls = "concurrent_calls" // Tool calls running at once
+	QuotaSearchResults   = "search_results"   // Search results returned per minute
+	QuotaEditBytes       = "edit_bytes"       // Bytes written by the edit tools per minute
+	QuotaIndexingJobs    = "indexing_jobs"    // Indexing runs and background jobs at once
+)
+
+// quotaWindow is the period of the per-minute quotas
+const quotaWindow = time.Minute
+
+// ErrQuotaExceeded is wrapped by the errors of calls refused by a quota
+var ErrQuotaExceeded = errors.New("connection quota exceeded")
+
+// QuotaError is returned for a call beyond one of its connection's quotas
+type QuotaError struct {
+	Quota      string
+	Limit      int64
+	RetryAfter time.Duration // Until the per-minute window resets; 0 when other calls have to finish first
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%v: %s limit of %d reached", ErrQuotaExceeded, e.Quota, e.Limit)
+}
+
+func (e *QuotaError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// rateWindow counts what a connection used in the current minute
+type rateWindow struct {
+	start time.Time
+	used  int64
+}
+
+// current returns the usage of the window at now, starting a new window
+// when the last one is over
+func (w *rateWindow) current(now time.Time) int64 {
+	if now.Sub(w.start) >= quotaWindow {
+		w.start = now
+		w.used = 0
+	}
+	return w.used
+}
+
+// retryAfter returns the time until the window resets
+func (w *rateWindow) retryAfter(now time.Time) time.Duration {
+	return w.start.Add(quotaWindow).Sub(now)
+}
+
+// quotaUsage is what a connection is using of its quotas. The semaphore is
+// created with the connection; the rest is guarded by the connection mutex.
+type quotaUsage struct {
+	calls         chan struct{} // Holds a token per running tool call
+	indexing      int           // Indexing runs of tool calls in progress
+	indexingJobs  []string      // Background indexing jobs that may still run
+	searchResults rateWindow
+	editBytes     rateWindow
+}
+
+// newQuotaUsage returns the usage of a new connection
+func newQuotaUsage(limits config.ResourceManagementConfig) *quotaUsage {
+	usage := &quotaUsage{}
+	if limits.MaxConcurrentOperations > 0 {
+		usage.calls = make(chan struct{}, limits.MaxConcurrentOperations)
+	}
+	return usage
+}
+
+// refuse counts a refused call and returns its error
+func (m *Manager) refuse(conn *Connection, quota string, limit int64, retryAfter time.Duration) error {
+	m.quotaMutex.Lock()
+	m.quotaExceeded[quota]++
+	m.quotaMutex.Unlock()
+
+	m.logger.Warn("Connection quota exceeded",
+		zap.String("connection_id", conn.ID),
+		zap.String("quota", quota),
+		zap.Int64("limit", limit))
+	return &QuotaError{Quota: quota, Limit: limit, RetryAfter: retryAfter}
+}
+
+// BeginCall takes one of the tool calls a connection may run at once,
+// returning the function that gives it back. Beyond the limit the call
+// waits for a running one to finish when resource_management queues
+// operations, for at most operation_timeout_minutes, and is refused
+// otherwise.
+func (m *Manager) BeginCall(ctx context.Context, connectionID string) (func(), error) {
+	conn, err := m.GetConnection(connectionID)
+	if err != nil {
+		return nil, err
+	}
+	if conn.quota.calls == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-conn.quota.calls }
+	select {
+	case conn.quota.calls <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	limit := int64(cap(conn.quota.calls))
+	if !m.limits.EnableOperationQueue {
+		return nil, m.refuse(conn, QuotaConcurrentCalls, limit, 0)
+	}
+	wait := time.Duration(m.limits.OperationTimeoutMinutes) * time.Minute
+	if wait <= 0 {
+		wait = quotaWindow
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case conn.quota.calls <- struct{}{}:
+		return release, nil
+	case <-timer.C:
+		return nil, m.refuse(conn, QuotaConcurrentCalls, limit, 0)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// CheckSearchResults refuses a search when the connection received its
+// search results of the minute already
+func (m *Manager) CheckSearchResults(connectionID string) error {
+	limit := int64(m.limits.SearchResultsPerMinute)
+	if limit <= 0 {
+		return nil
+	}
+	conn, err := m.GetConnection(connectionID)
+	if err != nil {
+		return err
+	}
+
+	conn.mutex.Lock()
+	now := time.Now()
+	exhausted := conn.quota.searchResults.current(now) >= limit
+	retryAfter := conn.quota.searchResults.retryAfter(now)
+	conn.mutex.Unlock()
+
+	if exhausted {
+		return m.refuse(conn, QuotaSearchResults, limit, retryAfter)
+	}
+	return nil
+}
+
+// ChargeSearchResults counts the results a search returned to a connection.
+// A search is not cut short by the quota; the next one is refused.
+func (m *Manager) ChargeSearchResults(connectionID string, results int) {
+	conn, err := m.GetConnection(connectionID)
+	if err != nil {
+		return
+	}
+
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	conn.quota.searchResults.current(time.Now())
+	conn.quota.searchResults.used += int64(results)
+}
+
+// ChargeEditBytes counts the bytes an edit tool is about to write for a
+// connection, refusing the write if it would exceed the bytes of the minute
+func (m *Manager) ChargeEditBytes(connectionID string, bytes int64) error {
+	conn, err := m.GetConnection(connectionID)
+	if err != nil {
+		return err
+	}
+	limit := m.limits.EditBytesPerMinute
+
+	conn.mutex.Lock()
+	now := time.Now()
+	used := conn.quota.editBytes.current(now)
+	if limit > 0 && used+bytes > limit {
+		retryAfter := conn.quota.editBytes.retryAfter(now)
+		conn.mutex.Unlock()
+		return m.refuse(conn, QuotaEditBytes, limit, retryAfter)
+	}
+	conn.quota.editBytes.used += bytes
+	conn.mutex.Unlock()
+	return nil
+}
+
+// BeginIndexing takes one of the indexing runs a connection may have at
+// once, returning the function that gives it back. Background jobs the
+// connection queued count until jobActive reports them finished.
+func (m *Manager) BeginIndexing(connectionID string, jobActive func(jobID string) bool) (func(), error) {
+	conn, err := m.GetConnection(connectionID)
+	if err != nil {
+		return nil, err
+	}
+	limit := m.limits.MaxIndexingJobs
+
+	conn.mutex.Lock()
+	active := conn.quota.indexingJobs[:0]
+	for _, jobID := range conn.quota.indexingJobs {
+		if jobActive(jobID) {
+			active = append(active, jobID)
+		}
+	}
+	conn.quota.indexingJobs = active
+	if limit > 0 && conn.quota.indexing+len(active) >= limit {
+		conn.mutex.Unlock()
+		return nil, m.refuse(conn, QuotaIndexingJobs, int64(limit), 0)
+	}
+	conn.quota.indexing++
+	conn.mutex.Unlock()
+
+	return func() {
+		conn.mutex.Lock()
+		conn.quota.indexing--
+		conn.mutex.Unlock()
+	}, nil
+}
+
+// TrackIndexingJob counts a background indexing job against the connection
+// that queued it until it finishes
+func (m *Manager) TrackIndexingJob(connectionID, jobID string) {
+	conn, err := m.GetConnection(connectionID)
+	if err != nil {
+		return
+	}
+
+	conn.mutex.Lock()
+	conn.quota.indexingJobs = append(conn.quota.indexingJobs, jobID)
+	conn.mutex.Unlock()
+}
+
+// quotaStats describes the quotas, what each connection uses of them and how
+// many calls they refused
+func (m *Manager) quotaStats() (map[string]interface{}, map[string]interface{}, map[string]int64) {
+	limits := map[string]interface{}{
+		"max_concurrent_calls":      m.limits.MaxConcurrentOperations,
+		"queue_calls":               m.limits.EnableOperationQueue,
+		"search_results_per_minute": m.limits.SearchResultsPerMinute,
+		"edit_bytes_per_minute":     m.limits.EditBytesPerMinute,
+		"max_indexing_jobs":         m.limits.MaxIndexingJobs,
+	}
+
+	now := time.Now()
+	usage := make(map[string]interface{}, len(m.connections))
+	for id, conn := range m.connections {
+		conn.mutex.Lock()
+		usage[id] = map[string]interface{}{
+			QuotaConcurrentCalls: len(conn.quota.calls),
+			QuotaSearchResults:   conn.quota.searchResults.current(now),
+			QuotaEditBytes:       conn.quota.editBytes.current(now),
+			QuotaIndexingJobs:    conn.quota.indexing + len(conn.quota.indexingJobs),
+		}
+		conn.mutex.Unlock()
+	}
+
+	m.quotaMutex.Lock()
+	exceeded := make(map[string]int64, len(m.quotaExceeded))
+	for quota, count := range m.quotaExceeded {
+		exceeded[quota] = count
+	}
+	m.quotaMutex.Unlock()
+
+	return limits, usage, exceeded
+}
@@ -0,0 +1,170 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func newQuotaManager(t *testing.T, limits config.ResourceManagementConfig) (*Manager, *Connection) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Server.MultiIDE.ResourceManagement = limits
+	m := NewManager(cfg, nil, zap.NewNop())
+	t.Cleanup(func() { m.Close() })
+
+	conn, err := m.CreateConnection(ConnectionTypeWebSocket, "127.0.0.1:1234", "test")
+	if err != nil {
+		t.Fatalf("CreateConnection failed: %v", err)
+	}
+	return m, conn
+}
+
+func quotaOf(err error) string {
+	var quotaErr *QuotaError
+	if errors.As(err, &quotaErr) {
+		return quotaErr.Quota
+	}
+	return ""
+}
+
+func TestConcurrentCallQuota(t *testing.T) {
+	m, conn := newQuotaManager(t, config.ResourceManagementConfig{MaxConcurrentOperations: 1})
+
+	release, err := m.BeginCall(context.Background(), conn.ID)
+	if err != nil {
+		t.Fatalf("BeginCall failed: %v", err)
+	}
+	if _, err := m.BeginCall(context.Background(), conn.ID); quotaOf(err) != QuotaConcurrentCalls || !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected a second call to be refused, got %v", err)
+	}
+	release()
+	if release, err := m.BeginCall(context.Background(), conn.ID); err != nil {
+		t.Errorf("Expected a call after the first finished to run, got %v", err)
+	} else {
+		release()
+	}
+
+	// With the operation queue, calls wait for a free slot
+	m, conn = newQuotaManager(t, config.ResourceManagementConfig{MaxConcurrentOperations: 1, EnableOperationQueue: true, OperationTimeoutMinutes: 1})
+	release, _ = m.BeginCall(context.Background(), conn.ID)
+	time.AfterFunc(20*time.Millisecond, release)
+	if release, err := m.BeginCall(context.Background(), conn.ID); err != nil {
+		t.Errorf("Expected a queued call to run once the first finished, got %v", err)
+	} else {
+		release()
+	}
+
+	stats := m.GetConnectionStats()
+	if exceeded := stats["quota_exceeded"].(map[string]int64); exceeded[QuotaConcurrentCalls] != 0 {
+		t.Errorf("Expected no refusals on the queueing manager, got %v", exceeded)
+	}
+}
+
+func TestRateQuotas(t *testing.T) {
+	m, conn := newQuotaManager(t, config.ResourceManagementConfig{SearchResultsPerMinute: 10, EditBytesPerMinute: 100})
+
+	if err := m.CheckSearchResults(conn.ID); err != nil {
+		t.Fatalf("Expected the first search to run, got %v", err)
+	}
+	m.ChargeSearchResults(conn.ID, 12)
+	err := m.CheckSearchResults(conn.ID)
+	var quotaErr *QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Quota != QuotaSearchResults || quotaErr.RetryAfter <= 0 || quotaErr.RetryAfter > time.Minute {
+		t.Errorf("Expected searches to be refused for the rest of the minute, got %v", err)
+	}
+
+	if err := m.ChargeEditBytes(conn.ID, 60); err != nil {
+		t.Errorf("Expected an edit within the quota to be written, got %v", err)
+	}
+	if err := m.ChargeEditBytes(conn.ID, 60); quotaOf(err) != QuotaEditBytes {
+		t.Errorf("Expected an edit over the quota to be refused, got %v", err)
+	}
+	if err := m.ChargeEditBytes(conn.ID, 40); err != nil {
+		t.Errorf("Expected a refused edit not to use the quota, got %v", err)
+	}
+
+	// The window resets after a minute
+	conn.mutex.Lock()
+	conn.quota.searchResults.start = time.Now().Add(-quotaWindow)
+	conn.mutex.Unlock()
+	if err := m.CheckSearchResults(conn.ID); err != nil {
+		t.Errorf("Expected searches to run in the next minute, got %v", err)
+	}
+
+	stats := m.GetConnectionStats()
+	usage := stats["quota_usage"].(map[string]interface{})[conn.ID].(map[string]interface{})
+	if usage[QuotaEditBytes] != int64(100) {
+		t.Errorf("Expected 100 edit bytes used, got %v", usage)
+	}
+	if exceeded := stats["quota_exceeded"].(map[string]int64); exceeded[QuotaSearchResults] != 1 || exceeded[QuotaEditBytes] != 1 {
+		t.Errorf("Unexpected refusal counts %v", exceeded)
+	}
+}
+
+func TestIndexingJobQuota(t *testing.T) {
+	m, conn := newQuotaManager(t, config.ResourceManagementConfig{MaxIndexingJobs: 2})
+	active := map[string]bool{"job-1": true}
+	jobActive := func(id string) bool { return active[id] }
+
+	m.TrackIndexingJob(conn.ID, "job-1")
+	release, err := m.BeginIndexing(conn.ID, jobActive)
+	if err != nil {
+		t.Fatalf("BeginIndexing failed: %v", err)
+	}
+	if _, err := m.BeginIndexing(conn.ID, jobActive); quotaOf(err) != QuotaIndexingJobs {
+		t.Errorf("Expected a third indexing run to be refused, got %v", err)
+	}
+
+	// Finished jobs and runs free their place
+	active["job-1"] = false
+	release2, err := m.BeginIndexing(conn.ID, jobActive)
+	if err != nil {
+		t.Errorf("Expected a run after the job finished, got %v", err)
+	}
+	release()
+	release2()
+	if release, err := m.BeginIndexing(conn.ID, jobActive); err != nil {
+		t.Errorf("Expected a run after the others finished, got %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestClientConnections(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Server.MultiIDE.MaxConnections = 1
+	cfg.Server.MultiIDE.ResourceManagement = config.ResourceManagementConfig{SearchResultsPerMinute: 10}
+	m := NewManager(cfg, nil, zap.NewNop())
+	t.Cleanup(func() { m.Close() })
+
+	// A client keeps its connection, and its quotas, across requests
+	ci := m.ClientConnection("key:ci")
+	if again := m.ClientConnection("key:ci"); again.ID != ci.ID || ci.Type != ConnectionTypeHTTP || ci.Client != "key:ci" {
+		t.Fatalf("Expected the same client connection, got %+v and %+v", ci, again)
+	}
+	m.ChargeSearchResults(ci.ID, 10)
+	if err := m.CheckSearchResults(m.ClientConnection("key:ci").ID); quotaOf(err) != QuotaSearchResults {
+		t.Errorf("Expected the client to have used its search results, got %v", err)
+	}
+	if other := m.ClientConnection("addr:192.0.2.1"); other.ID == ci.ID || m.CheckSearchResults(other.ID) != nil {
+		t.Errorf("Expected another client to have its own quotas, got %+v", other)
+	}
+
+	// Client connections leave room for WebSocket connections
+	if _, err := m.CreateConnection(ConnectionTypeWebSocket, "127.0.0.1:1234", "test"); err != nil {
+		t.Errorf("Expected client connections not to count against max_connections, got %v", err)
+	}
+
+	if err := m.CloseConnection(ci.ID); err != nil {
+		t.Fatalf("CloseConnection failed: %v", err)
+	}
+	if again := m.ClientConnection("key:ci"); again.ID == ci.ID {
+		t.Error("Expected a closed client connection to be replaced")
+	}
+}
@@ -0,0 +1,192 @@
+package coverage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestIsTestFile(t *testing.T) {
+	tests := map[string]bool{
+		"internal/store/store_test.go":          true,
+		"internal/store/store.go":               false,
+		"app/test_models.py":                    true,
+		"tests/integration/helpers.py":          true,
+		"app/models.py":                         false,
+		"src/api/client.spec.ts":                true,
+		"src/api/__tests__/client.js":           true,
+		"src/api/client.ts":                     false,
+		"src/test/java/com/acme/OrderTest.java": true,
+		"src/main/java/com/acme/Order.java":     false,
+	}
+	for path, want := range tests {
+		if got := IsTestFile(path); got != want {
+			t.Errorf("IsTestFile(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	contains := func(candidates []string, want string) bool {
+		for _, candidate := range candidates {
+			if candidate == want {
+				return true
+			}
+		}
+		return false
+	}
+	tests := []struct {
+		source, testDir, want string
+	}{
+		{"internal/store/store.go", "", "internal/store/store_test.go"},
+		{"src/app/models.py", "", "tests/app/test_models.py"},
+		{"app/models.py", "", "app/test_models.py"},
+		{"src/api/client.ts", "", "src/api/client.test.ts"},
+		{"src/api/client.ts", "", "src/api/__tests__/client.ts"},
+		{"src/main/java/com/acme/Order.java", "", "src/test/java/com/acme/OrderTest.java"},
+		{"lib/parse.js", "spec", "spec/parse.spec.js"},
+	}
+	for _, test := range tests {
+		if candidates := Candidates(test.source, test.testDir); !contains(candidates, test.want) {
+			t.Errorf("Expected %s among the test candidates of %s, got %v", test.want, test.source, candidates)
+		}
+	}
+}
+
+func TestExported(t *testing.T) {
+	file := &types.CodeFile{
+		Language: "go",
+		Functions: []types.Function{
+			{Name: "Open", StartLine: 3, EndLine: 10},
+			{Name: "open", StartLine: 12, EndLine: 14},
+			{Name: "Save", IsMethod: true, ClassName: "Store", StartLine: 20, EndLine: 25},
+		},
+		Classes: []types.Class{{Name: "Store", StartLine: 16, EndLine: 18}},
+	}
+	var names []string
+	for _, symbol := range Exported(file, "", false) {
+		names = append(names, symbol.QualifiedName())
+	}
+	if want := []string{"Open", "Store", "Store.Save"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected %v, got %v", want, names)
+	}
+	if all := Exported(file, "", true); len(all) != 4 {
+		t.Errorf("Expected every symbol with all, got %+v", all)
+	}
+
+	js := &types.CodeFile{
+		Language:  "javascript",
+		Functions: []types.Function{{Name: "parse", StartLine: 1}, {Name: "helper", StartLine: 2}, {Name: "format", StartLine: 3}},
+	}
+	content := "export function parse() {}\nfunction helper() {}\nfunction format() {}\nmodule.exports = { format }\n"
+	if symbols := Exported(js, content, false); len(symbols) != 2 || symbols[0].Name != "parse" || symbols[1].Name != "format" {
+		t.Errorf("Expected parse and format to be exported, got %+v", symbols)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	content := "func TestStore_Save(t *testing.T) {\n\ts := Open()\n}\n\nfunc TestSaveAll(t *testing.T) {}\n"
+	if lines := References(content, "Save"); !reflect.DeepEqual(lines, []int{1}) {
+		t.Errorf("Expected Save to be referenced on line 1, got %v", lines)
+	}
+	if lines := References(content, "Open"); !reflect.DeepEqual(lines, []int{2}) {
+		t.Errorf("Expected Open to be referenced on line 2, got %v", lines)
+	}
+	if lines := References("def test_parse_file():\n    pass\n", "parse_file"); !reflect.DeepEqual(lines, []int{1}) {
+		t.Errorf("Expected parse_file to be referenced on line 1, got %v", lines)
+	}
+	if lines := References(content, "Close"); len(lines) != 0 {
+		t.Errorf("Expected no references to Close, got %v", lines)
+	}
+}
+
+func TestDetectFramework(t *testing.T) {
+	tests := []struct {
+		language string
+		sources  []string
+		want     string
+	}{
+		{"go", nil, FrameworkGoTesting},
+		{"go", []string{"require github.com/stretchr/testify v1.9.0"}, FrameworkTestify},
+		{"python", []string{"class TestOrder(unittest.TestCase):"}, FrameworkUnittest},
+		{"python", []string{"class TestOrder(unittest.TestCase):", "[tool.pytest.ini_options]"}, FrameworkPytest},
+		{"typescript", []string{`{"devDependencies": {"vitest": "^1.0.0"}}`}, FrameworkVitest},
+		{"javascript", []string{`{"scripts": {"test": "mocha"}}`}, FrameworkMocha},
+		{"javascript", nil, FrameworkJest},
+		{"java", []string{"import org.junit.Test;"}, FrameworkJUnit4},
+		{"java", []string{"import org.junit.jupiter.api.Test;"}, FrameworkJUnit5},
+		{"rust", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := DetectFramework(tt.language, tt.sources...); got != tt.want {
+			t.Errorf("DetectFramework(%s, %v) = %q, want %q", tt.language, tt.sources, got, tt.want)
+		}
+	}
+}
+
+func TestParseGoProfile(t *testing.T) {
+	profile := `mode: set
+github.com/acme/app/internal/store/store.go:3.20,5.2 2 1
+github.com/acme/app/internal/store/store.go:5.2,8.3 2 0
+github.com/acme/app/internal/store/store.go:12.10,14.2 1 0
+`
+	report, err := Parse([]byte(profile))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	file := report.File("internal/store/store.go")
+	if report.Format != FormatGo || file == nil {
+		t.Fatalf("Expected the store to be covered, got %+v", report)
+	}
+	if covered, coverable := file.LineRate(); covered != 3 || coverable != 9 {
+		t.Errorf("Expected 3 of 9 lines covered, got %d of %d", covered, coverable)
+	}
+
+	functions := file.Functions([]Symbol{
+		{Name: "Open", Kind: KindFunction, StartLine: 3, EndLine: 8},
+		{Name: "close", Kind: KindFunction, StartLine: 12, EndLine: 14},
+		{Name: "Store", Kind: KindClass, StartLine: 1, EndLine: 1},
+	})
+	if len(functions) != 2 {
+		t.Fatalf("Expected 2 functions, got %+v", functions)
+	}
+	if functions[0].Percent != 50 || !reflect.DeepEqual(functions[0].UncoveredLines, []string{"6-8"}) {
+		t.Errorf("Unexpected coverage of Open: %+v", functions[0])
+	}
+	if functions[1].Percent != 0 {
+		t.Errorf("Expected close to be uncovered, got %+v", functions[1])
+	}
+}
+
+func TestParseLCOVAndCobertura(t *testing.T) {
+	lcov := "TN:\nSF:/home/ci/app/src/api/client.ts\nFN:1,get\nDA:1,4\nDA:2,0\nend_of_record\n"
+	report, err := Parse([]byte(lcov))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file := report.File("src/api/client.ts"); report.Format != FormatLCOV || file == nil || file.Lines[1] != 4 || file.Lines[2] != 0 {
+		t.Errorf("Unexpected LCOV report: %+v", report)
+	}
+
+	xml := `<?xml version="1.0" ?>
+<coverage line-rate="0.5">
+  <sources><source>src</source></sources>
+  <packages><package name="app"><classes>
+    <class name="models.py" filename="app/models.py">
+      <lines><line number="1" hits="1"/><line number="2" hits="0"/></lines>
+    </class>
+  </classes></package></packages>
+</coverage>`
+	report, err = Parse([]byte(xml))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file := report.File("src/app/models.py"); report.Format != FormatCobertura || file == nil || len(file.Lines) != 2 {
+		t.Errorf("Unexpected Cobertura report: %+v", report.Files)
+	}
+
+	if _, err := Parse([]byte("not a report")); err == nil {
+		t.Error("Expected an error for an unrecognized report")
+	}
+}
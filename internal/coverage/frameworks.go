@@ -0,0 +1,72 @@
+package coverage
+
+import "strings"
+
+// Test frameworks
+const (
+	FrameworkGoTesting = "go-testing"
+	FrameworkTestify   = "testify"
+	FrameworkPytest    = "pytest"
+	FrameworkUnittest  = "unittest"
+	FrameworkJest      = "jest"
+	FrameworkVitest    = "vitest"
+	FrameworkMocha     = "mocha"
+	FrameworkJUnit5    = "junit5"
+	FrameworkJUnit4    = "junit4"
+)
+
+// Frameworks lists the test frameworks of each supported language, the
+// default first
+var Frameworks = map[string][]string{
+	"go":         {FrameworkGoTesting, FrameworkTestify},
+	"python":     {FrameworkPytest, FrameworkUnittest},
+	"javascript": {FrameworkJest, FrameworkVitest, FrameworkMocha},
+	"typescript": {FrameworkJest, FrameworkVitest, FrameworkMocha},
+	"java":       {FrameworkJUnit5, FrameworkJUnit4},
+}
+
+// DetectFramework returns the test framework of a language that the given
+// sources use, which are existing test files and build manifests such as
+// package.json or pom.xml. It returns the language's default framework when
+// the sources name none, and "" for a language without supported frameworks.
+func DetectFramework(language string, sources ...string) string {
+	frameworks := Frameworks[language]
+	if len(frameworks) == 0 {
+		return ""
+	}
+	uses := func(markers ...string) bool {
+		for _, source := range sources {
+			for _, marker := range markers {
+				if strings.Contains(source, marker) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch language {
+	case "go":
+		if uses("github.com/stretchr/testify") {
+			return FrameworkTestify
+		}
+	case "python":
+		if uses("unittest.TestCase") && !uses("pytest") {
+			return FrameworkUnittest
+		}
+	case "javascript", "typescript":
+		switch {
+		case uses("vitest"):
+			return FrameworkVitest
+		case uses("jest"):
+			return FrameworkJest
+		case uses("mocha"):
+			return FrameworkMocha
+		}
+	case "java":
+		if uses("org.junit.Test", "org.junit.Assert", "junit:junit") && !uses("org.junit.jupiter") {
+			return FrameworkJUnit4
+		}
+	}
+	return frameworks[0]
+}
@@ -0,0 +1,309 @@
+package coverage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coverage report formats
+const (
+	FormatGo        = "go"
+	FormatLCOV      = "lcov"
+	FormatCobertura = "cobertura"
+)
+
+// ReportFiles are the usual names of coverage reports, relative to the
+// repository root, checked in order when no report is given
+var ReportFiles = []string{
+	"coverage.out", "cover.out", "coverage.txt", "c.out", "profile.cov",
+	"lcov.info", "coverage/lcov.info",
+	"coverage.xml", "coverage/cobertura-coverage.xml", "target/site/cobertura/coverage.xml",
+}
+
+// FileCoverage is the coverage a report records for one file
+type FileCoverage struct {
+	Path  string      // As written in the report
+	Lines map[int]int // Executions of each coverable line
+}
+
+// Report is a parsed coverage report
+type Report struct {
+	Format string
+	Files  []*FileCoverage
+}
+
+// Parse parses a coverage report in any of the supported formats,
+// detected from its content
+func Parse(data []byte) (*Report, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("mode:")):
+		return parseGoProfile(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return parseCobertura(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("TN:")) || bytes.HasPrefix(trimmed, []byte("SF:")):
+		return parseLCOV(trimmed)
+	}
+	return nil, fmt.Errorf("unrecognized coverage report: expected a Go coverage profile, LCOV or Cobertura XML")
+}
+
+// File returns the coverage of a file by its slash-separated path relative
+// to the repository, matching the report's paths by their longest common
+// suffix, or nil when the report does not cover it
+func (r *Report) File(relPath string) *FileCoverage {
+	var best *FileCoverage
+	bestLength := 0
+	for _, file := range r.Files {
+		reported := strings.ReplaceAll(file.Path, `\`, "/")
+		matched := 0
+		switch {
+		case reported == relPath:
+			matched = len(relPath) + 1
+		case strings.HasSuffix(reported, "/"+relPath):
+			matched = len(relPath)
+		case strings.HasSuffix(relPath, "/"+reported):
+			matched = len(reported)
+		}
+		if matched > bestLength {
+			best, bestLength = file, matched
+		}
+	}
+	return best
+}
+
+// parseGoProfile parses the profile `go test -coverprofile` writes, whose
+// lines are "file:startLine.startCol,endLine.endCol statements count"
+func parseGoProfile(data []byte) (*Report, error) {
+	files := make(map[string]*FileCoverage)
+	var order []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d of the coverage profile is malformed: %q", lineNumber, line)
+		}
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d of the coverage profile is malformed: %q", lineNumber, line)
+		}
+		span := strings.Split(fields[0], ",")
+		count, err := strconv.Atoi(fields[2])
+		if len(span) != 2 || err != nil {
+			return nil, fmt.Errorf("line %d of the coverage profile is malformed: %q", lineNumber, line)
+		}
+		start, err1 := strconv.Atoi(strings.SplitN(span[0], ".", 2)[0])
+		end, err2 := strconv.Atoi(strings.SplitN(span[1], ".", 2)[0])
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("line %d of the coverage profile is malformed: %q", lineNumber, line)
+		}
+
+		name := line[:colon]
+		file := files[name]
+		if file == nil {
+			file = &FileCoverage{Path: name, Lines: make(map[int]int)}
+			files[name] = file
+			order = append(order, name)
+		}
+		// Blocks share their boundary lines and profiles of several runs
+		// repeat blocks, so a line counts as often as its most run block
+		for n := start; n <= end; n++ {
+			if previous, ok := file.Lines[n]; !ok || count > previous {
+				file.Lines[n] = count
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &Report{Format: FormatGo}
+	for _, name := range order {
+		report.Files = append(report.Files, files[name])
+	}
+	return report, nil
+}
+
+// parseLCOV parses an LCOV tracefile, taking the line records DA:line,count
+// of each SF: source file
+func parseLCOV(data []byte) (*Report, error) {
+	report := &Report{Format: FormatLCOV}
+	var file *FileCoverage
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			file = &FileCoverage{Path: strings.TrimPrefix(line, "SF:"), Lines: make(map[int]int)}
+			report.Files = append(report.Files, file)
+		case strings.HasPrefix(line, "DA:"):
+			fields := strings.Split(strings.TrimPrefix(line, "DA:"), ",")
+			if file == nil || len(fields) < 2 {
+				return nil, fmt.Errorf("line %d of the LCOV report is malformed: %q", lineNumber, line)
+			}
+			n, err1 := strconv.Atoi(fields[0])
+			count, err2 := strconv.Atoi(fields[1])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("line %d of the LCOV report is malformed: %q", lineNumber, line)
+			}
+			file.Lines[n] += count
+		case line == "end_of_record":
+			file = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// cobertura is the part of a Cobertura XML report read, as written by
+// coverage.py, Istanbul, gcovr and the Cobertura Maven plugin
+type cobertura struct {
+	Sources  []string `xml:"sources>source"`
+	Packages []struct {
+		Classes []struct {
+			Filename string `xml:"filename,attr"`
+			Lines    []struct {
+				Number int `xml:"number,attr"`
+				Hits   int `xml:"hits,attr"`
+			} `xml:"lines>line"`
+		} `xml:"classes>class"`
+	} `xml:"packages>package"`
+}
+
+// parseCobertura parses a Cobertura XML report. Its file names are
+// relative to one of its sources; the first source relative to the
+// repository, if any, is prepended.
+func parseCobertura(data []byte) (*Report, error) {
+	var parsed cobertura
+	if err := xml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse the Cobertura report: %w", err)
+	}
+	prefix := ""
+	for _, source := range parsed.Sources {
+		source = strings.TrimSpace(source)
+		if source != "" && source != "." && !path.IsAbs(source) && !strings.Contains(source, ":") {
+			prefix = source
+			break
+		}
+	}
+
+	files := make(map[string]*FileCoverage)
+	for _, pkg := range parsed.Packages {
+		for _, class := range pkg.Classes {
+			name := class.Filename
+			if prefix != "" && !strings.HasPrefix(name, prefix+"/") {
+				name = path.Join(prefix, name)
+			}
+			file := files[name]
+			if file == nil {
+				file = &FileCoverage{Path: name, Lines: make(map[int]int)}
+				files[name] = file
+			}
+			for _, line := range class.Lines {
+				file.Lines[line.Number] += line.Hits
+			}
+		}
+	}
+
+	report := &Report{Format: FormatCobertura}
+	for _, file := range files {
+		report.Files = append(report.Files, file)
+	}
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].Path < report.Files[j].Path
+	})
+	return report, nil
+}
+
+// LineRate returns the covered and coverable lines of a file
+func (f *FileCoverage) LineRate() (covered, coverable int) {
+	for _, count := range f.Lines {
+		coverable++
+		if count > 0 {
+			covered++
+		}
+	}
+	return covered, coverable
+}
+
+// FunctionCoverage is how much of a symbol a coverage report shows run
+type FunctionCoverage struct {
+	Symbol
+	CoveredLines   int      `json:"covered_lines"`
+	CoverableLines int      `json:"coverable_lines"`
+	Percent        float64  `json:"percent"`
+	UncoveredLines []string `json:"uncovered_lines,omitempty"` // Ranges such as "12-15"
+}
+
+// Functions returns the coverage of each function and method among
+// symbols, classes being covered through their methods. Functions without
+// coverable lines in the report are left out.
+func (f *FileCoverage) Functions(symbols []Symbol) []FunctionCoverage {
+	var functions []FunctionCoverage
+	for _, symbol := range symbols {
+		if symbol.Kind == KindClass {
+			continue
+		}
+		function := FunctionCoverage{Symbol: symbol}
+		var uncovered []int
+		for n := symbol.StartLine; n <= symbol.EndLine; n++ {
+			count, ok := f.Lines[n]
+			if !ok {
+				continue
+			}
+			function.CoverableLines++
+			if count > 0 {
+				function.CoveredLines++
+			} else {
+				uncovered = append(uncovered, n)
+			}
+		}
+		if function.CoverableLines == 0 {
+			continue
+		}
+		function.Percent = Percent(function.CoveredLines, function.CoverableLines)
+		function.UncoveredLines = ranges(uncovered)
+		functions = append(functions, function)
+	}
+	return functions
+}
+
+// Percent returns part of total as a percentage, rounded down to one
+// decimal
+func Percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part*1000/total) / 10
+}
+
+// ranges collapses sorted line numbers into ranges
+func ranges(lines []int) []string {
+	var collapsed []string
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && lines[j+1] == lines[j]+1 {
+			j++
+		}
+		if i == j {
+			collapsed = append(collapsed, strconv.Itoa(lines[i]))
+		} else {
+			collapsed = append(collapsed, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return collapsed
+}
@@ -0,0 +1,261 @@
+// Package coverage relates source files to their tests: it finds the test
+// files of a source file by naming conventions, the exported symbols the
+// tests never mention, and the functions coverage reports show untested.
+package coverage
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Symbol kinds
+const (
+	KindFunction = "function"
+	KindMethod   = "method"
+	KindClass    = "class"
+)
+
+// Symbol is a function, method or class a test may exercise
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	ClassName string `json:"class_name,omitempty"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// QualifiedName returns the name of the symbol with its class
+func (s Symbol) QualifiedName() string {
+	if s.ClassName != "" {
+		return s.ClassName + "." + s.Name
+	}
+	return s.Name
+}
+
+// jsExtensions are the extensions JavaScript and TypeScript tests may use
+// for a source file with the given extension
+var jsExtensions = map[string][]string{
+	".js":  {".js", ".jsx"},
+	".jsx": {".jsx", ".js"},
+	".mjs": {".mjs", ".js"},
+	".ts":  {".ts", ".tsx"},
+	".tsx": {".tsx", ".ts"},
+}
+
+// IsTestFile reports whether a slash-separated path is a test file by the
+// conventions of its language
+func IsTestFile(relPath string) bool {
+	dir, base := path.Split(relPath)
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	dirs := "/" + dir
+	switch {
+	case ext == ".go":
+		return strings.HasSuffix(stem, "_test")
+	case ext == ".py":
+		return strings.HasPrefix(stem, "test_") || strings.HasSuffix(stem, "_test") ||
+			strings.Contains(dirs, "/tests/") || strings.Contains(dirs, "/test/")
+	case jsExtensions[ext] != nil:
+		return strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec") ||
+			strings.Contains(dirs, "/__tests__/")
+	case ext == ".java":
+		return strings.HasSuffix(stem, "Test") || strings.HasSuffix(stem, "Tests") ||
+			strings.HasSuffix(stem, "IT") || strings.HasPrefix(stem, "Test") ||
+			strings.Contains(dirs, "/src/test/")
+	}
+	return false
+}
+
+// Candidates returns the slash-separated paths, relative to the repository,
+// where the conventions of its language place the tests of a source file.
+// The paths are not checked to exist. With testDir, paths of the same names
+// in that directory are included.
+func Candidates(relPath, testDir string) []string {
+	dir, base := path.Split(relPath)
+	dir = strings.TrimSuffix(dir, "/")
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+
+	var names []string // Test file names, placed next to the source
+	var paths []string // Test files elsewhere
+	switch {
+	case ext == ".go":
+		names = []string{stem + "_test.go"}
+	case ext == ".py":
+		names = []string{"test_" + stem + ".py", stem + "_test.py"}
+		for _, root := range []string{"tests", "test"} {
+			paths = append(paths, path.Join(dir, root, "test_"+stem+".py"), path.Join(root, "test_"+stem+".py"))
+			// Mirrors of the package layout, without a leading src
+			if sub := strings.TrimPrefix(strings.TrimPrefix(dir, "src"), "/"); sub != "" {
+				paths = append(paths, path.Join(root, sub, "test_"+stem+".py"))
+			}
+		}
+	case jsExtensions[ext] != nil:
+		for _, e := range jsExtensions[ext] {
+			names = append(names, stem+".test"+e, stem+".spec"+e)
+			paths = append(paths, path.Join(dir, "__tests__", stem+e), path.Join(dir, "__tests__", stem+".test"+e))
+			for _, root := range []string{"test", "tests"} {
+				paths = append(paths, path.Join(root, stem+".test"+e), path.Join(root, stem+".spec"+e))
+			}
+		}
+	case ext == ".java":
+		names = []string{stem + "Test.java", stem + "Tests.java", "Test" + stem + ".java", stem + "IT.java"}
+		if strings.Contains("/"+dir+"/", "/src/main/") {
+			testDir := strings.Replace("/"+dir+"/", "/src/main/", "/src/test/", 1)
+			for _, name := range names {
+				paths = append(paths, strings.TrimPrefix(path.Join(testDir, name), "/"))
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var candidates []string
+	add := func(candidate string) {
+		if candidate != relPath && !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	for _, name := range names {
+		add(path.Join(dir, name))
+	}
+	for _, candidate := range paths {
+		add(candidate)
+	}
+	if testDir = strings.Trim(testDir, "/"); testDir != "" {
+		for _, name := range append(names, base) {
+			add(path.Join(testDir, name))
+		}
+	}
+	return candidates
+}
+
+// exportLine matches JavaScript and TypeScript declarations that export
+// what they declare
+var exportLine = regexp.MustCompile(`^\s*export\s`)
+
+// Exported returns the symbols of a parsed file that other packages or
+// modules can use, which are the ones tests are expected to exercise:
+// capitalized names in Go, names without a leading underscore in Python,
+// exported declarations and module.exports members in JavaScript and
+// TypeScript, and public members of public classes in Java. With all, every
+// function, method and class is returned.
+func Exported(file *types.CodeFile, content string, all bool) []Symbol {
+	lines := strings.Split(content, "\n")
+	line := func(n int) string {
+		if n < 1 || n > len(lines) {
+			return ""
+		}
+		return lines[n-1]
+	}
+	exportedClasses := make(map[string]bool)
+	visible := func(name, visibility string, start int, className string) bool {
+		if all {
+			return true
+		}
+		switch file.Language {
+		case "go":
+			return name != "" && unicode.IsUpper([]rune(name)[0])
+		case "python":
+			return !strings.HasPrefix(name, "_")
+		case "javascript", "typescript":
+			if className != "" {
+				return exportedClasses[className] && !strings.HasPrefix(name, "#") && !strings.HasPrefix(name, "_")
+			}
+			return exportLine.MatchString(line(start)) || moduleExports(content, name)
+		case "java":
+			return visibility == "public"
+		}
+		return visibility != "private"
+	}
+
+	var symbols []Symbol
+	for _, class := range file.Classes {
+		if visible(class.Name, class.Visibility, class.StartLine, "") {
+			exportedClasses[class.Name] = true
+			symbols = append(symbols, Symbol{Name: class.Name, Kind: KindClass, StartLine: class.StartLine, EndLine: class.EndLine})
+		}
+	}
+	for _, function := range file.Functions {
+		kind := KindFunction
+		if function.IsMethod || function.ClassName != "" {
+			kind = KindMethod
+		}
+		if function.Name == "" {
+			continue
+		}
+		if visible(function.Name, function.Visibility, function.StartLine, function.ClassName) {
+			symbols = append(symbols, Symbol{
+				Name:      function.Name,
+				Kind:      kind,
+				ClassName: function.ClassName,
+				StartLine: function.StartLine,
+				EndLine:   function.EndLine,
+			})
+		}
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].StartLine < symbols[j].StartLine
+	})
+	return symbols
+}
+
+// moduleExports reports whether a CommonJS module exports name
+func moduleExports(content, name string) bool {
+	pattern := regexp.MustCompile(`(?:module\.)?exports\.` + regexp.QuoteMeta(name) + `\b|module\.exports\s*=\s*\{[^}]*\b` + regexp.QuoteMeta(name) + `\b|module\.exports\s*=\s*` + regexp.QuoteMeta(name) + `\b`)
+	return pattern.MatchString(content)
+}
+
+// testPrefixes start the names of test functions in the supported
+// frameworks
+var testPrefixes = []string{"Test", "Benchmark", "Example", "Fuzz", "test_", "test"}
+
+// identifier matches identifiers in any of the supported languages
+var identifier = regexp.MustCompile(`[A-Za-z_$][A-Za-z0-9_$]*`)
+
+// Reference is a place a test file mentions a symbol
+type Reference struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+}
+
+// References returns the lines of a test file that mention name, either
+// directly or as the subject of a test function such as TestParse,
+// TestStore_Save or test_parse_file
+func References(content, name string) []int {
+	var lines []int
+	lower := strings.ToLower(name)
+	for idx, line := range strings.Split(content, "\n") {
+		if !strings.Contains(strings.ToLower(line), lower) {
+			continue
+		}
+		for _, token := range identifier.FindAllString(line, -1) {
+			if token == name || namesTest(token, lower) {
+				lines = append(lines, idx+1)
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// namesTest reports whether a test function name is about the symbol whose
+// lowercased name is given
+func namesTest(token, lower string) bool {
+	for _, prefix := range testPrefixes {
+		if !strings.HasPrefix(token, prefix) || len(token) == len(prefix) {
+			continue
+		}
+		subject := strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(token, prefix), "_"))
+		if subject == lower || strings.HasPrefix(subject, lower+"_") || strings.HasSuffix(subject, "_"+lower) ||
+			strings.Contains(subject, "_"+lower+"_") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// Package dependencies resolves the third-party dependencies a repository
+// declares to the directories their sources were installed to: Go modules
+// of go.mod in the vendor directory or module cache, packages of
+// package.json in node_modules and distributions of requirements.txt in
+// the site-packages of a virtual environment. Nothing is downloaded; a
+// dependency that is not installed is reported without a directory.
+package dependencies
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Ecosystems
+const (
+	EcosystemGo     = "go"
+	EcosystemNPM    = "npm"
+	EcosystemPython = "python"
+)
+
+// Package is a dependency declared by a repository
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`               // Module path, package name or distribution name
+	Version   string `json:"version,omitempty"`  // Version installed, or required when it is not installed
+	Indirect  bool   `json:"indirect,omitempty"` // Go module required only by other modules
+	Dev       bool   `json:"dev,omitempty"`      // npm package of devDependencies
+	Dir       string `json:"dir,omitempty"`      // Directory of its sources; empty when it is not installed
+}
+
+// RepositoryName returns the name a package is indexed under, its name
+// and version like the name of a repository indexed at a ref
+func (p Package) RepositoryName() string {
+	if p.Version == "" {
+		return p.Name
+	}
+	return p.Name + "@" + p.Version
+}
+
+// Matches reports whether name names the package, by its name or by its
+// name and version as RepositoryName returns them
+func (p Package) Matches(name string) bool {
+	return name == p.Name || name == p.RepositoryName()
+}
+
+// Resolve returns the dependencies declared by the manifests in the root
+// directory of the repository at repoPath, sorted by ecosystem and name.
+// Repositories without any manifest have none.
+func Resolve(repoPath string) ([]Package, error) {
+	var packages []Package
+	for _, resolve := range []func(string) ([]Package, error){resolveGo, resolveNPM, resolvePython} {
+		resolved, err := resolve(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, resolved...)
+	}
+	sort.SliceStable(packages, func(a, b int) bool {
+		if packages[a].Ecosystem != packages[b].Ecosystem {
+			return packages[a].Ecosystem < packages[b].Ecosystem
+		}
+		return packages[a].Name < packages[b].Name
+	})
+	return packages, nil
+}
+
+// within joins the slash-separated name to base, or returns "" when the
+// result would lie outside base, so names read from manifests cannot
+// reach other directories
+func within(base, name string) string {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, `\`) {
+		return ""
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == "" || element == "." || element == ".." {
+			return ""
+		}
+	}
+	return filepath.Join(base, filepath.FromSlash(name))
+}
+
+// isDir reports whether path is an existing directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,168 @@
+package dependencies
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes files below dir, creating their directories
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	repoDir := t.TempDir()
+	cache := t.TempDir()
+	t.Setenv("GOMODCACHE", cache)
+	t.Setenv("VIRTUAL_ENV", "")
+
+	writeFiles(t, repoDir, map[string]string{
+		"go.mod": `module example.com/app
+
+go 1.23
+
+require github.com/pkg/errors v0.9.1
+
+require (
+	github.com/BurntSushi/toml v1.3.2 // indirect
+	example.com/missing v1.0.0
+	example.com/local v0.0.0
+	example.com/forked v1.2.0
+)
+
+replace example.com/local => ../local
+
+replace example.com/forked v1.2.0 => "github.com/acme/forked" v1.2.1
+`,
+		"package.json": `{
+  "name": "app",
+  "dependencies": {"react": "^18.0.0", "@scope/ui": "1.0.0", "left-pad": "1.3.0"},
+  "devDependencies": {"jest": "^29.0.0", "react": "^18.0.0"}
+}`,
+		"node_modules/react/package.json":     `{"version": "18.2.0"}`,
+		"node_modules/@scope/ui/package.json": `{"version": "1.0.0"}`,
+		"node_modules/jest/index.js":          "",
+		"requirements.txt": `# Web
+Flask>=2.0  # framework
+-r dev.txt
+zope.interface==6.0
+https://example.com/archive.zip
+not_installed
+`,
+		".venv/lib/python3.12/site-packages/flask-3.0.0.dist-info/top_level.txt":        "flask\n",
+		".venv/lib/python3.12/site-packages/flask/__init__.py":                          "",
+		".venv/lib/python3.12/site-packages/zope.interface-6.0.dist-info/top_level.txt": "zope\n",
+		".venv/lib/python3.12/site-packages/zope/interface/__init__.py":                 "",
+	})
+	writeFiles(t, cache, map[string]string{
+		"github.com/pkg/errors@v0.9.1/errors.go":        "",
+		"github.com/!burnt!sushi/toml@v1.3.2/decode.go": "",
+		"github.com/acme/forked@v1.2.1/forked.go":       "",
+	})
+
+	packages, err := Resolve(repoDir)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	resolved := make(map[string]Package)
+	for _, pkg := range packages {
+		resolved[pkg.Ecosystem+" "+pkg.Name] = pkg
+	}
+	if len(packages) != len(resolved) {
+		t.Errorf("Expected each package once, got %+v", packages)
+	}
+
+	tests := []struct {
+		key     string
+		version string
+		dir     string // Relative to the repository, or to the cache for Go modules; "" when not installed
+	}{
+		{"go github.com/pkg/errors", "v0.9.1", "github.com/pkg/errors@v0.9.1"},
+		{"go github.com/BurntSushi/toml", "v1.3.2", "github.com/!burnt!sushi/toml@v1.3.2"},
+		{"go example.com/missing", "v1.0.0", ""},
+		{"go example.com/forked", "v1.2.1", "github.com/acme/forked@v1.2.1"},
+		{"npm react", "18.2.0", "node_modules/react"},
+		{"npm @scope/ui", "1.0.0", "node_modules/@scope/ui"},
+		{"npm left-pad", "1.3.0", ""},
+		{"npm jest", "^29.0.0", "node_modules/jest"},
+		{"python Flask", "3.0.0", ".venv/lib/python3.12/site-packages/flask"},
+		{"python zope.interface", "6.0", ".venv/lib/python3.12/site-packages/zope"},
+		{"python not_installed", "", ""},
+	}
+	for _, test := range tests {
+		pkg, ok := resolved[test.key]
+		if !ok {
+			t.Errorf("Expected %s to be resolved, got %+v", test.key, packages)
+			continue
+		}
+		dir := ""
+		if test.dir != "" {
+			base := repoDir
+			if pkg.Ecosystem == EcosystemGo {
+				base = cache
+			}
+			dir = filepath.Join(base, filepath.FromSlash(test.dir))
+		}
+		if pkg.Version != test.version || pkg.Dir != dir {
+			t.Errorf("Expected %s %s in %q, got %s in %q", test.key, test.version, dir, pkg.Version, pkg.Dir)
+		}
+	}
+
+	if _, ok := resolved["go example.com/local"]; ok {
+		t.Error("Expected a module replaced by a local directory to be left out")
+	}
+	if !resolved["go github.com/BurntSushi/toml"].Indirect || !resolved["npm jest"].Dev || resolved["npm react"].Dev {
+		t.Error("Expected indirect modules and dev packages to be marked")
+	}
+	if len(resolved) != len(tests) {
+		t.Errorf("Expected %d packages, got %+v", len(tests), packages)
+	}
+	if pkg := resolved["npm react"]; pkg.RepositoryName() != "react@18.2.0" || !pkg.Matches("react") || !pkg.Matches("react@18.2.0") || pkg.Matches("react@17.0.0") {
+		t.Errorf("Unexpected naming of %+v", pkg)
+	}
+
+	// Vendored modules are preferred over the module cache
+	writeFiles(t, repoDir, map[string]string{
+		"vendor/modules.txt":                "",
+		"vendor/github.com/pkg/errors/a.go": "",
+	})
+	packages, err = Resolve(repoDir)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	for _, pkg := range packages {
+		if pkg.Name == "github.com/pkg/errors" && pkg.Dir != filepath.Join(repoDir, "vendor", "github.com", "pkg", "errors") {
+			t.Errorf("Expected the vendored module, got %q", pkg.Dir)
+		}
+	}
+}
+
+func TestResolveRejectsEscapes(t *testing.T) {
+	repoDir := t.TempDir()
+	writeFiles(t, repoDir, map[string]string{
+		"package.json":         `{"dependencies": {"../outside": "1.0.0"}}`,
+		"outside/package.json": `{}`,
+	})
+	packages, err := Resolve(repoDir)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if len(packages) != 1 || packages[0].Dir != "" {
+		t.Errorf("Expected a package name leaving node_modules not to resolve, got %+v", packages)
+	}
+
+	writeFiles(t, repoDir, map[string]string{"go.mod": "module example.com/app\n\nrequire broken\n"})
+	if _, err := Resolve(repoDir); err == nil {
+		t.Error("Expected a malformed go.mod to be reported")
+	}
+}
@@ -0,0 +1,173 @@
+package dependencies
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// goModule is a module required by go.mod, or the module replacing it
+type goModule struct {
+	path     string
+	version  string
+	indirect bool
+}
+
+// resolveGo resolves the modules required by the go.mod of a repository,
+// in its vendor directory when it has one and in the module cache
+// otherwise. Modules replaced by a local directory are left out, as their
+// sources are not third-party; modules replaced by another module resolve
+// to the replacement.
+func resolveGo(repoPath string) ([]Package, error) {
+	requires, replaces, err := readGoMod(filepath.Join(repoPath, "go.mod"))
+	if err != nil || requires == nil {
+		return nil, err
+	}
+
+	vendored := false
+	if _, err := os.Stat(filepath.Join(repoPath, "vendor", "modules.txt")); err == nil {
+		vendored = true
+	}
+	cache := goModCache()
+
+	var packages []Package
+	for _, module := range requires {
+		pkg := Package{Ecosystem: EcosystemGo, Name: module.path, Version: module.version, Indirect: module.indirect}
+		source := module
+		if replacement, ok := replaces[module.path+"@"+module.version]; ok {
+			source = replacement
+		} else if replacement, ok := replaces[module.path]; ok {
+			source = replacement
+		}
+		if source.version == "" {
+			continue // Replaced by a local directory
+		}
+		pkg.Version = source.version
+
+		var dir string
+		if vendored {
+			// Vendored modules keep the path they are imported by
+			dir = within(filepath.Join(repoPath, "vendor"), module.path)
+		} else if cache != "" {
+			dir = within(cache, escapeModulePath(source.path)+"@"+escapeModulePath(source.version))
+		}
+		if dir != "" && isDir(dir) {
+			pkg.Dir = dir
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// readGoMod reads the require and replace directives of a go.mod file.
+// Replacements are keyed by module path, or by path and version when they
+// replace a single version; a replacement by a local directory has no
+// version. A missing file requires nothing and returns nil.
+func readGoMod(file string) ([]goModule, map[string]goModule, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	requires := []goModule{}
+	replaces := make(map[string]goModule)
+	block := ""
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, comment, _ := strings.Cut(scanner.Text(), "//")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		directive := block
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			directive, fields = fields[0], fields[1:]
+		}
+
+		switch directive {
+		case "require":
+			if len(fields) != 2 {
+				return nil, nil, fmt.Errorf("%s:%d: malformed require directive", file, lineNumber)
+			}
+			requires = append(requires, goModule{
+				path:     unquote(fields[0]),
+				version:  fields[1],
+				indirect: strings.TrimSpace(comment) == "indirect",
+			})
+		case "replace":
+			old, replacement, ok := strings.Cut(strings.Join(fields, " "), "=>")
+			oldFields, newFields := strings.Fields(old), strings.Fields(replacement)
+			if !ok || len(oldFields) == 0 || len(oldFields) > 2 || len(newFields) == 0 || len(newFields) > 2 {
+				return nil, nil, fmt.Errorf("%s:%d: malformed replace directive", file, lineNumber)
+			}
+			key := unquote(oldFields[0])
+			if len(oldFields) == 2 {
+				key += "@" + oldFields[1]
+			}
+			module := goModule{path: unquote(newFields[0])}
+			if len(newFields) == 2 {
+				module.version = newFields[1]
+			}
+			replaces[key] = module
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return requires, replaces, nil
+}
+
+// unquote strips the quotes of a quoted module path
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '`') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// goModCache returns the module cache directory: $GOMODCACHE, or pkg/mod
+// in the first directory of $GOPATH, which defaults to ~/go
+func goModCache() string {
+	if cache := os.Getenv("GOMODCACHE"); cache != "" {
+		return cache
+	}
+	gopath := filepath.SplitList(os.Getenv("GOPATH"))
+	if len(gopath) > 0 && gopath[0] != "" {
+		return filepath.Join(gopath[0], "pkg", "mod")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "go", "pkg", "mod")
+}
+
+// escapeModulePath escapes a module path or version the way the module
+// cache names its directories: each upper-case letter becomes an
+// exclamation mark followed by the letter in lower case
+func escapeModulePath(path string) string {
+	var escaped strings.Builder
+	for _, r := range path {
+		if unicode.IsUpper(r) {
+			escaped.WriteByte('!')
+			r = unicode.ToLower(r)
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
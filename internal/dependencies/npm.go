@@ -0,0 +1,62 @@
+package dependencies
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// packageJSON is the part of a package.json file read here
+type packageJSON struct {
+	Version         string            `json:"version"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+}
+
+// resolveNPM resolves the dependencies and devDependencies of the
+// package.json of a repository in its node_modules directory. Installed
+// packages take the version of their own package.json.
+func resolveNPM(repoPath string) ([]Package, error) {
+	manifest, err := readPackageJSON(filepath.Join(repoPath, "package.json"))
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+
+	modules := filepath.Join(repoPath, "node_modules")
+	var packages []Package
+	add := func(dependencies map[string]string, dev bool) {
+		for name, version := range dependencies {
+			if _, ok := manifest.Dependencies[name]; dev && ok {
+				continue
+			}
+			pkg := Package{Ecosystem: EcosystemNPM, Name: name, Version: version, Dev: dev}
+			if dir := within(modules, name); dir != "" && isDir(dir) {
+				pkg.Dir = dir
+				if installed, err := readPackageJSON(filepath.Join(dir, "package.json")); err == nil && installed != nil && installed.Version != "" {
+					pkg.Version = installed.Version
+				}
+			}
+			packages = append(packages, pkg)
+		}
+	}
+	add(manifest.Dependencies, false)
+	add(manifest.DevDependencies, true)
+	return packages, nil
+}
+
+// readPackageJSON reads a package.json file; a missing file returns nil
+func readPackageJSON(file string) (*packageJSON, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest packageJSON
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return &manifest, nil
+}
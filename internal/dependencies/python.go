@@ -0,0 +1,144 @@
+package dependencies
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// virtualEnvDirs are the directories of a repository looked in for a
+// virtual environment, after $VIRTUAL_ENV
+var virtualEnvDirs = []string{".venv", "venv", "env"}
+
+// requirementName matches the distribution name at the start of a line of
+// requirements.txt
+var requirementName = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)`)
+
+// distribution is a distribution installed in site-packages
+type distribution struct {
+	version string
+	dir     string // Directory of its top-level package, if it has one
+}
+
+// resolvePython resolves the distributions of the requirements.txt of a
+// repository in the site-packages of its virtual environment. Lines naming
+// other files, editable installs and options are skipped.
+func resolvePython(repoPath string) ([]Package, error) {
+	names, err := readRequirements(filepath.Join(repoPath, "requirements.txt"))
+	if err != nil || names == nil {
+		return nil, err
+	}
+
+	installed := make(map[string]distribution)
+	if sitePackages := findSitePackages(repoPath); sitePackages != "" {
+		installed = readSitePackages(sitePackages)
+	}
+
+	var packages []Package
+	for _, name := range names {
+		pkg := Package{Ecosystem: EcosystemPython, Name: name}
+		if dist, ok := installed[normalizeDistribution(name)]; ok {
+			pkg.Version = dist.version
+			pkg.Dir = dist.dir
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// readRequirements reads the distribution names of a requirements.txt
+// file. A missing file returns nil.
+func readRequirements(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := []string{}
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		// Bare URLs name no distribution; "name @ url" does
+		match := requirementName.FindString(line)
+		if match == "" || (strings.Contains(line, "://") && !strings.Contains(line, "@")) {
+			continue
+		}
+		if normalized := normalizeDistribution(match); !seen[normalized] {
+			seen[normalized] = true
+			names = append(names, match)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// findSitePackages returns the site-packages directory of the virtual
+// environment of a repository, or "" when it has none
+func findSitePackages(repoPath string) string {
+	var envs []string
+	if env := os.Getenv("VIRTUAL_ENV"); env != "" {
+		envs = append(envs, env)
+	}
+	for _, dir := range virtualEnvDirs {
+		envs = append(envs, filepath.Join(repoPath, dir))
+	}
+	for _, env := range envs {
+		matches, _ := filepath.Glob(filepath.Join(env, "lib", "python*", "site-packages"))
+		matches = append(matches, filepath.Join(env, "Lib", "site-packages")) // Windows
+		for _, match := range matches {
+			if isDir(match) {
+				return match
+			}
+		}
+	}
+	return ""
+}
+
+// readSitePackages reads the distributions installed in a site-packages
+// directory from their .dist-info directories, keyed by normalized name.
+// The directory of a distribution is that of the first of its top-level
+// packages listed in top_level.txt, or the package named after it.
+func readSitePackages(sitePackages string) map[string]distribution {
+	installed := make(map[string]distribution)
+	infos, _ := filepath.Glob(filepath.Join(sitePackages, "*.dist-info"))
+	for _, info := range infos {
+		name, version, ok := strings.Cut(strings.TrimSuffix(filepath.Base(info), ".dist-info"), "-")
+		if !ok {
+			continue
+		}
+
+		topLevel := []string{strings.ReplaceAll(name, "-", "_")}
+		if data, err := os.ReadFile(filepath.Join(info, "top_level.txt")); err == nil {
+			topLevel = append(strings.Fields(string(data)), topLevel...)
+		}
+		dist := distribution{version: version}
+		for _, module := range topLevel {
+			if dir := within(sitePackages, module); dir != "" && isDir(dir) {
+				dist.dir = dir
+				break
+			}
+		}
+		installed[normalizeDistribution(name)] = dist
+	}
+	return installed
+}
+
+// separatorRuns matches the runs of characters distribution names treat
+// as the same separator
+var separatorRuns = regexp.MustCompile(`[-_.]+`)
+
+// normalizeDistribution normalizes a distribution name so names differing
+// only in case and separators compare equal, as PEP 503 does
+func normalizeDistribution(name string) string {
+	return separatorRuns.ReplaceAllString(strings.ToLower(name), "-")
+}
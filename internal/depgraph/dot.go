@@ -0,0 +1,61 @@
+package depgraph
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DOT writes the graph in the Graphviz DOT language. External modules are
+// dashed, edges inside an import cycle are red and the node focus, when
+// given, is filled.
+func (g *Graph) DOT(name, focus string) string {
+	inCycle := make(map[string]int)
+	for i, cycle := range g.Cycles() {
+		for _, id := range cycle.Nodes {
+			inCycle[id] = i + 1
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "digraph %s {\n", quote(name))
+	out.WriteString("  rankdir=LR;\n")
+	out.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, node := range g.Nodes {
+		var attributes []string
+		if node.Kind == KindExternal {
+			attributes = append(attributes, "style=dashed")
+		}
+		if node.ID == focus {
+			attributes = append(attributes, "style=filled", "fillcolor=lightyellow")
+		}
+		if node.Kind == KindPackage && node.Module != "" && node.Module != node.ID {
+			attributes = append(attributes, "tooltip="+quote(node.Module))
+		}
+		fmt.Fprintf(&out, "  %s%s;\n", quote(node.ID), attributeList(attributes))
+	}
+	for _, edge := range g.Edges {
+		var attributes []string
+		if edge.Weight > 1 {
+			attributes = append(attributes, fmt.Sprintf("label=\"%d\"", edge.Weight))
+		}
+		if cycle := inCycle[edge.From]; cycle != 0 && cycle == inCycle[edge.To] {
+			attributes = append(attributes, "color=red")
+		}
+		fmt.Fprintf(&out, "  %s -> %s%s;\n", quote(edge.From), quote(edge.To), attributeList(attributes))
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// quote writes s as a DOT string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// attributeList writes attributes as a DOT attribute list
+func attributeList(attributes []string) string {
+	if len(attributes) == 0 {
+		return ""
+	}
+	return " [" + strings.Join(attributes, ", ") + "]"
+}
@@ -0,0 +1,497 @@
+// Package depgraph builds the import graph of a repository from the imports
+// the index records, resolving each import to the files it loads. The graph
+// can be aggregated by package, walked in either direction, searched for
+// import cycles and written as Graphviz DOT.
+package depgraph
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/internal/refactor"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Kinds of nodes
+const (
+	KindFile     = "file"
+	KindPackage  = "package"  // Directory of files
+	KindExternal = "external" // Module that is not in the repository
+)
+
+// Node is a file, a package or an external module
+type Node struct {
+	ID       string `json:"id"` // File path, package directory, or module of an external node
+	Kind     string `json:"kind"`
+	Language string `json:"language,omitempty"`
+	Module   string `json:"module,omitempty"` // Module other files import it by
+	Files    int    `json:"files,omitempty"`  // Files of a package
+}
+
+// Edge is an import of one node by another
+type Edge struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Imports []string `json:"imports"` // Modules as written in the import statements
+	Weight  int      `json:"weight"`  // Importing files
+}
+
+// Graph is a directed import graph; an edge runs from the importing node to
+// the imported one
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+
+	nodes map[string]int    // Index of each node by ID
+	edges map[[2]string]int // Index of each edge by its ends
+	out   map[string][]int  // Edges leaving each node
+	in    map[string][]int  // Edges entering each node
+}
+
+func newGraph() *Graph {
+	return &Graph{
+		Nodes: []Node{},
+		Edges: []Edge{},
+		nodes: make(map[string]int),
+		edges: make(map[[2]string]int),
+		out:   make(map[string][]int),
+		in:    make(map[string][]int),
+	}
+}
+
+// Build builds the file graph of a repository. Imports that do not resolve
+// to a file of the repository become external nodes.
+func Build(files []types.FileImports) *Graph {
+	files = append([]types.FileImports(nil), files...)
+	sort.Slice(files, func(a, b int) bool {
+		return files[a].FilePath < files[b].FilePath
+	})
+
+	r := newResolver(files)
+	g := newGraph()
+	for _, file := range files {
+		g.addNode(Node{ID: file.FilePath, Kind: KindFile, Language: file.Language, Module: file.Module})
+	}
+	for _, file := range files {
+		for _, imp := range file.Imports {
+			targets := r.resolve(file, imp)
+			if len(targets) == 0 {
+				g.addNode(Node{ID: imp, Kind: KindExternal})
+				g.addEdge(file.FilePath, imp, imp)
+				continue
+			}
+			for _, target := range targets {
+				if target != file.FilePath {
+					g.addEdge(file.FilePath, target, imp)
+				}
+			}
+		}
+	}
+	return g
+}
+
+// Node returns the node with the given ID
+func (g *Graph) Node(id string) (Node, bool) {
+	idx, ok := g.nodes[id]
+	if !ok {
+		return Node{}, false
+	}
+	return g.Nodes[idx], true
+}
+
+// addNode adds a node unless one with its ID exists, and returns its index
+func (g *Graph) addNode(node Node) int {
+	if idx, ok := g.nodes[node.ID]; ok {
+		return idx
+	}
+	g.nodes[node.ID] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, node)
+	return len(g.Nodes) - 1
+}
+
+// addEdge records that from imports to with the given imports, and returns
+// the index of the edge
+func (g *Graph) addEdge(from, to string, imports ...string) int {
+	key := [2]string{from, to}
+	idx, ok := g.edges[key]
+	if !ok {
+		idx = len(g.Edges)
+		g.edges[key] = idx
+		g.Edges = append(g.Edges, Edge{From: from, To: to, Weight: 1})
+		g.out[from] = append(g.out[from], idx)
+		g.in[to] = append(g.in[to], idx)
+	}
+	edge := &g.Edges[idx]
+	for _, imp := range imports {
+		known := false
+		for _, existing := range edge.Imports {
+			known = known || existing == imp
+		}
+		if !known {
+			edge.Imports = append(edge.Imports, imp)
+		}
+	}
+	return idx
+}
+
+// Packages aggregates a file graph by directory. An edge between two
+// packages joins the imports of their files, and its weight is the number
+// of files importing the other package. Imports within a package are left
+// out.
+func (g *Graph) Packages() *Graph {
+	p := newGraph()
+	packageOf := func(id string) string {
+		if node, _ := g.Node(id); node.Kind == KindFile {
+			return path.Dir(id)
+		}
+		return id
+	}
+
+	for _, node := range g.Nodes {
+		if node.Kind != KindFile {
+			p.addNode(node)
+			continue
+		}
+		id := packageOf(node.ID)
+		if _, ok := p.nodes[id]; !ok {
+			p.addNode(Node{ID: id, Kind: KindPackage, Language: node.Language, Module: node.Module})
+		}
+		pkg := &p.Nodes[p.nodes[id]]
+		pkg.Files++
+		// Only kept when every file agrees, as the files of a Go or Java
+		// package do
+		if pkg.Language != node.Language {
+			pkg.Language = ""
+		}
+		if pkg.Module != node.Module {
+			pkg.Module = ""
+		}
+	}
+
+	importers := make(map[[2]string]map[string]bool)
+	for _, edge := range g.Edges {
+		from, to := packageOf(edge.From), packageOf(edge.To)
+		if from == to {
+			continue
+		}
+		idx := p.addEdge(from, to, edge.Imports...)
+		key := [2]string{from, to}
+		if importers[key] == nil {
+			importers[key] = make(map[string]bool)
+		}
+		importers[key][edge.From] = true
+		p.Edges[idx].Weight = len(importers[key])
+	}
+	return p
+}
+
+// WithoutExternal returns the graph without its external nodes
+func (g *Graph) WithoutExternal() *Graph {
+	return g.filter(func(node Node) bool {
+		return node.Kind != KindExternal
+	})
+}
+
+// Subgraph returns the nodes with the given IDs and the edges between them
+func (g *Graph) Subgraph(ids map[string]bool) *Graph {
+	return g.filter(func(node Node) bool {
+		return ids[node.ID]
+	})
+}
+
+// filter returns the nodes keep accepts and the edges between them
+func (g *Graph) filter(keep func(Node) bool) *Graph {
+	f := newGraph()
+	for _, node := range g.Nodes {
+		if keep(node) {
+			f.addNode(node)
+		}
+	}
+	for _, edge := range g.Edges {
+		_, from := f.nodes[edge.From]
+		_, to := f.nodes[edge.To]
+		if from && to {
+			f.Edges[f.addEdge(edge.From, edge.To, edge.Imports...)].Weight = edge.Weight
+		}
+	}
+	return f
+}
+
+// Reached is a node found by walking the graph from another
+type Reached struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Depth int    `json:"depth"`         // Imports between the start and the node
+	Via   string `json:"via,omitempty"` // Node it was reached through, when not the start
+}
+
+// Dependencies returns the nodes id imports, directly or through other
+// nodes, up to depth imports away; depth 0 has no limit
+func (g *Graph) Dependencies(id string, depth int) []Reached {
+	return g.walk(id, depth, g.out, func(edge Edge) string { return edge.To })
+}
+
+// Dependents returns the nodes importing id, directly or through other
+// nodes, up to depth imports away; depth 0 has no limit
+func (g *Graph) Dependents(id string, depth int) []Reached {
+	return g.walk(id, depth, g.in, func(edge Edge) string { return edge.From })
+}
+
+// walk visits the graph breadth first from start along the given edges
+func (g *Graph) walk(start string, depth int, adjacent map[string][]int, next func(Edge) string) []Reached {
+	visited := map[string]bool{start: true}
+	queue := []Reached{{ID: start}}
+	reached := []Reached{}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if depth > 0 && current.Depth >= depth {
+			continue
+		}
+		for _, idx := range adjacent[current.ID] {
+			id := next(g.Edges[idx])
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			found := Reached{ID: id, Depth: current.Depth + 1}
+			if node, ok := g.Node(id); ok {
+				found.Kind = node.Kind
+			}
+			if current.ID != start {
+				found.Via = current.ID
+			}
+			reached = append(reached, found)
+			queue = append(queue, found)
+		}
+	}
+	sort.SliceStable(reached, func(a, b int) bool {
+		if reached[a].Depth != reached[b].Depth {
+			return reached[a].Depth < reached[b].Depth
+		}
+		return reached[a].ID < reached[b].ID
+	})
+	return reached
+}
+
+// Cycle is a set of nodes that import each other, directly or through the
+// other nodes of the set
+type Cycle struct {
+	Nodes []string `json:"nodes"`
+	Path  []string `json:"path"` // One import loop through the set, ending where it starts
+}
+
+// Cycles returns the import cycles of the graph, the strongly connected
+// components of more than one node, largest first
+func (g *Graph) Cycles() []Cycle {
+	cycles := []Cycle{}
+	for _, component := range g.components() {
+		if len(component) < 2 {
+			continue
+		}
+		sort.Strings(component)
+		cycles = append(cycles, Cycle{Nodes: component, Path: g.loop(component)})
+	}
+	sort.SliceStable(cycles, func(a, b int) bool {
+		if len(cycles[a].Nodes) != len(cycles[b].Nodes) {
+			return len(cycles[a].Nodes) > len(cycles[b].Nodes)
+		}
+		return cycles[a].Nodes[0] < cycles[b].Nodes[0]
+	})
+	return cycles
+}
+
+// components returns the strongly connected components of the graph, by
+// Tarjan's algorithm
+func (g *Graph) components() [][]string {
+	index := make(map[string]int, len(g.Nodes))
+	low := make(map[string]int, len(g.Nodes))
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+	next := 0
+
+	var connect func(id string)
+	connect = func(id string) {
+		index[id] = next
+		low[id] = next
+		next++
+		stack = append(stack, id)
+		onStack[id] = true
+
+		for _, idx := range g.out[id] {
+			to := g.Edges[idx].To
+			if _, seen := index[to]; !seen {
+				connect(to)
+				low[id] = min(low[id], low[to])
+			} else if onStack[to] {
+				low[id] = min(low[id], index[to])
+			}
+		}
+
+		if low[id] == index[id] {
+			var component []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == id {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if _, seen := index[node.ID]; !seen {
+			connect(node.ID)
+		}
+	}
+	return components
+}
+
+// loop returns a shortest import loop from the first node of a component
+// back to itself, staying inside the component
+func (g *Graph) loop(component []string) []string {
+	inside := make(map[string]bool, len(component))
+	for _, id := range component {
+		inside[id] = true
+	}
+	start := component[0]
+	parent := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, idx := range g.out[current] {
+			to := g.Edges[idx].To
+			if !inside[to] {
+				continue
+			}
+			if to == start {
+				loop := []string{start}
+				for id := current; id != start; id = parent[id] {
+					loop = append(loop, id)
+				}
+				for i, j := 1, len(loop)-1; i < j; i, j = i+1, j-1 {
+					loop[i], loop[j] = loop[j], loop[i]
+				}
+				return append(loop, start)
+			}
+			if _, seen := parent[to]; !seen {
+				parent[to] = current
+				queue = append(queue, to)
+			}
+		}
+	}
+	return nil
+}
+
+// resolver maps the imports of a file to the files of the repository they
+// load
+type resolver struct {
+	modules map[string]map[string][]string // Files by module, by language family
+}
+
+// family groups the languages that import each other's modules
+func family(language string) string {
+	if language == "typescript" {
+		return "javascript"
+	}
+	return language
+}
+
+func newResolver(files []types.FileImports) *resolver {
+	r := &resolver{modules: make(map[string]map[string][]string)}
+	for _, file := range files {
+		if file.Module == "" {
+			continue
+		}
+		modules := r.modules[family(file.Language)]
+		if modules == nil {
+			modules = make(map[string][]string)
+			r.modules[family(file.Language)] = modules
+		}
+		modules[file.Module] = append(modules[file.Module], file.FilePath)
+	}
+	return r
+}
+
+// resolve returns the files imp loads when file imports it, or nil when it
+// is not a module of the repository. A Go import loads every file of the
+// package; a Python or Java import loads the longest enclosing module of
+// the repository, as importing a.b.c runs the package a.b.
+func (r *resolver) resolve(file types.FileImports, imp string) []string {
+	modules := r.modules[family(file.Language)]
+	switch family(file.Language) {
+	case "go":
+		return modules[imp]
+	case "python":
+		if strings.HasPrefix(imp, ".") {
+			if imp = pythonAbsolute(file, imp); imp == "" {
+				return nil
+			}
+		}
+		return longestModule(modules, imp)
+	case "java":
+		return longestModule(modules, strings.TrimSuffix(imp, ".*"))
+	case "javascript":
+		// Bare specifiers name packages, not files of the repository
+		if !strings.HasPrefix(imp, "./") && !strings.HasPrefix(imp, "../") && imp != "." && imp != ".." {
+			return nil
+		}
+		target := path.Join(path.Dir(file.FilePath), imp)
+		if strings.HasPrefix(target, "../") || target == ".." {
+			return nil
+		}
+		return modules[refactor.ScriptModulePath(target)]
+	}
+	return nil
+}
+
+// longestModule returns the files of the longest dotted prefix of name
+// that is a module
+func longestModule(modules map[string][]string, name string) []string {
+	for ; name != ""; name = parentModule(name) {
+		if files, ok := modules[name]; ok {
+			return files
+		}
+	}
+	return nil
+}
+
+// pythonAbsolute turns a relative Python import into the module it names,
+// or returns "" when it leaves the top-level package
+func pythonAbsolute(file types.FileImports, imp string) string {
+	dots := len(imp) - len(strings.TrimLeft(imp, "."))
+	base := file.Module
+	if path.Base(file.FilePath) != "__init__.py" {
+		base = parentModule(base)
+	}
+	for i := 1; i < dots; i++ {
+		if base == "" {
+			return ""
+		}
+		base = parentModule(base)
+	}
+	rest := imp[dots:]
+	switch {
+	case base == "":
+		return rest
+	case rest == "":
+		return base
+	}
+	return base + "." + rest
+}
+
+// parentModule returns the package holding a dotted module, or "" for a
+// top-level one
+func parentModule(name string) string {
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		return name[:idx]
+	}
+	return ""
+}
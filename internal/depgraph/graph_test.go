@@ -0,0 +1,171 @@
+package depgraph
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// testFiles is a small repository of Go, Python and JavaScript files in
+// which store and cache import each other
+var testFiles = []types.FileImports{
+	{FilePath: "cmd/main.go", Language: "go", Module: "example.com/app/cmd", Imports: []string{"fmt", "example.com/app/store"}},
+	{FilePath: "store/store.go", Language: "go", Module: "example.com/app/store", Imports: []string{"example.com/app/cache"}},
+	{FilePath: "store/load.go", Language: "go", Module: "example.com/app/store", Imports: []string{"os"}},
+	{FilePath: "cache/cache.go", Language: "go", Module: "example.com/app/cache", Imports: []string{"example.com/app/store"}},
+	{FilePath: "app/__init__.py", Language: "python", Module: "app"},
+	{FilePath: "app/models.py", Language: "python", Module: "app.models", Imports: []string{"os"}},
+	{FilePath: "app/api/__init__.py", Language: "python", Module: "app.api"},
+	{FilePath: "app/api/routes.py", Language: "python", Module: "app.api.routes", Imports: []string{"..models", ".", "app.models.User"}},
+	{FilePath: "web/index.js", Language: "javascript", Module: "web", Imports: []string{"./lib/util", "react"}},
+	{FilePath: "web/lib/util.ts", Language: "typescript", Module: "web/lib/util", Imports: []string{"../../outside"}},
+}
+
+// edgesOf returns the edges of a graph as from -> to strings
+func edgesOf(g *Graph) []string {
+	var edges []string
+	for _, edge := range g.Edges {
+		edges = append(edges, edge.From+" -> "+edge.To)
+	}
+	return edges
+}
+
+func TestBuildResolvesImports(t *testing.T) {
+	g := Build(testFiles)
+
+	expected := []string{
+		"app/api/routes.py -> app/models.py",
+		"app/api/routes.py -> app/api/__init__.py",
+		"app/models.py -> os",
+		"cache/cache.go -> store/load.go",
+		"cache/cache.go -> store/store.go",
+		"cmd/main.go -> fmt",
+		"cmd/main.go -> store/load.go",
+		"cmd/main.go -> store/store.go",
+		"store/load.go -> os",
+		"store/store.go -> cache/cache.go",
+		"web/index.js -> web/lib/util.ts",
+		"web/index.js -> react",
+		"web/lib/util.ts -> ../../outside",
+	}
+	if got := edgesOf(g); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected edges:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	edge := g.Edges[g.edges[[2]string{"app/api/routes.py", "app/models.py"}]]
+	if !reflect.DeepEqual(edge.Imports, []string{"..models", "app.models.User"}) {
+		t.Errorf("Expected both imports of app.models on the edge, got %v", edge.Imports)
+	}
+	if node, ok := g.Node("react"); !ok || node.Kind != KindExternal {
+		t.Errorf("Expected react to be an external node, got %+v", node)
+	}
+}
+
+func TestPackages(t *testing.T) {
+	p := Build(testFiles).WithoutExternal().Packages()
+
+	store, ok := p.Node("store")
+	if !ok || store.Kind != KindPackage || store.Files != 2 || store.Module != "example.com/app/store" || store.Language != "go" {
+		t.Errorf("Unexpected store package: %+v", store)
+	}
+	web, _ := p.Node("web/lib")
+	if web.Files != 1 || web.Language != "typescript" {
+		t.Errorf("Unexpected web/lib package: %+v", web)
+	}
+
+	expected := []string{"app/api -> app", "cache -> store", "cmd -> store", "store -> cache", "web -> web/lib"}
+	if got := edgesOf(p); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected package edges %v, got %v", expected, got)
+	}
+	if edge := p.Edges[p.edges[[2]string{"cache", "store"}]]; edge.Weight != 1 {
+		t.Errorf("Expected one file of cache to import store, got weight %d", edge.Weight)
+	}
+}
+
+func TestDependenciesAndDependents(t *testing.T) {
+	p := Build(testFiles).Packages()
+
+	direct := p.Dependencies("cmd", 1)
+	expected := []Reached{
+		{ID: "fmt", Kind: KindExternal, Depth: 1},
+		{ID: "store", Kind: KindPackage, Depth: 1},
+	}
+	if !reflect.DeepEqual(direct, expected) {
+		t.Errorf("Expected direct dependencies %+v, got %+v", expected, direct)
+	}
+
+	all := p.Dependencies("cmd", 0)
+	if len(all) != 4 || all[2].ID != "cache" || all[2].Via != "store" || all[3].ID != "os" {
+		t.Errorf("Unexpected transitive dependencies: %+v", all)
+	}
+
+	dependents := p.Dependents("store", 0)
+	var ids []string
+	for _, reached := range dependents {
+		ids = append(ids, reached.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"cache", "cmd"}) {
+		t.Errorf("Expected cache and cmd to import store, got %v", ids)
+	}
+}
+
+func TestCycles(t *testing.T) {
+	cycles := Build(testFiles).Packages().Cycles()
+	expected := []Cycle{{Nodes: []string{"cache", "store"}, Path: []string{"cache", "store", "cache"}}}
+	if !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Expected cycles %+v, got %+v", expected, cycles)
+	}
+
+	files := []types.FileImports{
+		{FilePath: "a.py", Language: "python", Module: "a", Imports: []string{"b"}},
+		{FilePath: "b.py", Language: "python", Module: "b", Imports: []string{"c"}},
+		{FilePath: "c.py", Language: "python", Module: "c", Imports: []string{"a"}},
+		{FilePath: "d.py", Language: "python", Module: "d", Imports: []string{"a"}},
+	}
+	cycles = Build(files).Cycles()
+	expected = []Cycle{{Nodes: []string{"a.py", "b.py", "c.py"}, Path: []string{"a.py", "b.py", "c.py", "a.py"}}}
+	if !reflect.DeepEqual(cycles, expected) {
+		t.Errorf("Expected cycles %+v, got %+v", expected, cycles)
+	}
+}
+
+func TestPythonAbsolute(t *testing.T) {
+	tests := []struct {
+		file, module, imp, expected string
+	}{
+		{"app/api/routes.py", "app.api.routes", ".", "app.api"},
+		{"app/api/routes.py", "app.api.routes", ".auth", "app.api.auth"},
+		{"app/api/routes.py", "app.api.routes", "..models", "app.models"},
+		{"app/api/__init__.py", "app.api", ".routes", "app.api.routes"},
+		{"app/api/routes.py", "app.api.routes", "....models", ""},
+		{"main.py", "main", ".util", "util"},
+	}
+	for _, test := range tests {
+		file := types.FileImports{FilePath: test.file, Module: test.module}
+		if got := pythonAbsolute(file, test.imp); got != test.expected {
+			t.Errorf("Expected %s in %s to name %q, got %q", test.imp, test.file, test.expected, got)
+		}
+	}
+}
+
+func TestDOT(t *testing.T) {
+	dot := Build(testFiles).Packages().DOT("app", "cmd")
+
+	for _, expected := range []string{
+		"digraph \"app\" {",
+		"  \"cmd\" [style=filled, fillcolor=lightyellow, tooltip=\"example.com/app/cmd\"];",
+		"  \"fmt\" [style=dashed];",
+		"  \"cache\" -> \"store\" [color=red];",
+		"  \"cmd\" -> \"store\";",
+		"  \"store\" [tooltip=\"example.com/app/store\"];",
+	} {
+		if !strings.Contains(dot, expected+"\n") {
+			t.Errorf("Expected DOT output to contain %q, got:\n%s", expected, dot)
+		}
+	}
+	if got := quote(`a "b" \c`); got != `"a \"b\" \\c"` {
+		t.Errorf("Unexpected quoting: %s", got)
+	}
+}
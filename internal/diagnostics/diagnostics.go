@@ -0,0 +1,276 @@
+// Package diagnostics runs compilers and linters over a repository or a file
+// and reads the problems they print into structured diagnostics.
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// maxOutput bounds the raw output kept from a checker that failed without
+// reporting a problem the parser understood
+const maxOutput = 4000
+
+// ErrNotInstalled is returned for checkers whose command is not installed
+var ErrNotInstalled = errors.New("checker not installed")
+
+// Diagnostic is a problem a checker reported, in 1-based lines and columns;
+// Column is 0 when the checker did not give one
+type Diagnostic struct {
+	FilePath string `json:"file_path"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+	Source   string `json:"source"` // Checker or server that reported it
+}
+
+// Target is what a checker checks: a whole workspace, or one file in it
+type Target struct {
+	Root string // Directory the checker runs in
+	File string // File relative to Root; empty for the whole workspace
+}
+
+// Run is the outcome of running one checker
+type Run struct {
+	Checker     string       `json:"checker"`
+	Command     string       `json:"command"`
+	ExitCode    int          `json:"exit_code"`
+	Duration    float64      `json:"duration_ms"`
+	Count       int          `json:"count"` // Problems reported in the target
+	Diagnostics []Diagnostic `json:"-"`
+	Output      string       `json:"output,omitempty"` // Raw output when it failed without parsed diagnostics
+}
+
+// Check runs checker over target, stopping it after timeout. A checker that
+// finds problems usually exits with an error status; that is not an error
+// here, only failing to run it is.
+func Check(ctx context.Context, checker config.CheckerConfig, target Target, timeout time.Duration) (*Run, error) {
+	if _, err := exec.LookPath(checker.Command); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotInstalled, checker.Command)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := expandArgs(checker.Args, target)
+	cmd := exec.CommandContext(ctx, checker.Command, args...)
+	cmd.Dir = target.Root
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	start := time.Now()
+	err := cmd.Run()
+	run := &Run{
+		Checker:  checkerName(checker),
+		Command:  strings.Join(append([]string{checker.Command}, args...), " "),
+		Duration: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("%s did not finish within %s", run.Checker, timeout)
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		run.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, fmt.Errorf("failed to run %s: %w", run.Checker, err)
+	}
+
+	run.Diagnostics = Parse(checker.Format, output.String(), target.Root, defaultSeverity(checker.Severity), run.Checker)
+	if target.File != "" {
+		run.Diagnostics = onlyFile(run.Diagnostics, target.File)
+	}
+	run.Count = len(run.Diagnostics)
+	if run.ExitCode != 0 && len(run.Diagnostics) == 0 {
+		run.Output = truncate(strings.TrimSpace(output.String()), maxOutput)
+	}
+	return run, nil
+}
+
+// expandArgs replaces {package} with the Go package pattern of the target,
+// ./... for a workspace, and {file} with the file, . for a workspace
+func expandArgs(args []string, target Target) []string {
+	pkg, file := "./...", "."
+	if target.File != "" {
+		file = target.File
+		pkg = "./" + filepath.ToSlash(filepath.Dir(target.File))
+		if pkg == "./." {
+			pkg = "."
+		}
+	}
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, "{package}", pkg)
+		expanded[i] = strings.ReplaceAll(arg, "{file}", file)
+	}
+	return expanded
+}
+
+// checkerName returns the name a checker reports its problems under
+func checkerName(checker config.CheckerConfig) string {
+	if checker.Name != "" {
+		return checker.Name
+	}
+	return checker.Command
+}
+
+// defaultSeverity returns the severity of problems that do not name one
+func defaultSeverity(severity string) string {
+	if severity == "" {
+		return "error"
+	}
+	return severity
+}
+
+var (
+	// linePattern matches file:line:column: message and file:line: message,
+	// as printed by go build, go vet, pyflakes, gcc and most linters
+	linePattern = regexp.MustCompile(`^(?:vet: )?([^\s:][^:]*?):(\d+):(?:(\d+):)?\s*(.+)$`)
+
+	// tscPattern matches file(line,column): error TS1234: message, as
+	// printed by tsc --pretty false
+	tscPattern = regexp.MustCompile(`^(.+?)\((\d+),(\d+)\): (error|warning|message) (TS\d+): (.+)$`)
+
+	// severityPrefix matches a severity leading a message
+	severityPrefix = regexp.MustCompile(`^(?i)(error|warning|note|info)(?:\[[^\]]*\])?:\s*`)
+)
+
+// Parse reads the problems in the output of a checker. format is "line" or
+// "tsc"; paths are made relative to root, and problems without a severity
+// of their own get severity. Lines that are not problems are skipped.
+func Parse(format, output, root, severity, source string) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		var diagnostic Diagnostic
+		if format == "tsc" {
+			match := tscPattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			diagnostic = Diagnostic{
+				FilePath: match[1],
+				Severity: match[4],
+				Code:     match[5],
+				Message:  match[6],
+			}
+			diagnostic.Line, _ = strconv.Atoi(match[2])
+			diagnostic.Column, _ = strconv.Atoi(match[3])
+			if diagnostic.Severity == "message" {
+				diagnostic.Severity = "information"
+			}
+		} else {
+			match := linePattern.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			diagnostic = Diagnostic{FilePath: match[1], Severity: severity, Message: match[4]}
+			diagnostic.Line, _ = strconv.Atoi(match[2])
+			diagnostic.Column, _ = strconv.Atoi(match[3])
+			if prefix := severityPrefix.FindStringSubmatch(diagnostic.Message); prefix != nil {
+				diagnostic.Severity = normalizeSeverity(prefix[1])
+				diagnostic.Message = diagnostic.Message[len(prefix[0]):]
+			}
+		}
+		diagnostic.FilePath = relativePath(root, diagnostic.FilePath)
+		diagnostic.Source = source
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	return diagnostics
+}
+
+// normalizeSeverity maps the severity names checkers print to error,
+// warning or information
+func normalizeSeverity(name string) string {
+	switch strings.ToLower(name) {
+	case "warning":
+		return "warning"
+	case "note", "info":
+		return "information"
+	}
+	return "error"
+}
+
+// relativePath returns path relative to root when it is inside it
+func relativePath(root, path string) string {
+	path = filepath.FromSlash(path)
+	if !filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// onlyFile keeps the diagnostics of file
+func onlyFile(diagnostics []Diagnostic, file string) []Diagnostic {
+	file = filepath.Clean(file)
+	kept := diagnostics[:0]
+	for _, diagnostic := range diagnostics {
+		if diagnostic.FilePath == file {
+			kept = append(kept, diagnostic)
+		}
+	}
+	return kept
+}
+
+// Merge joins the diagnostics of several checkers in file and line order,
+// dropping problems reported more than once, as go vet repeats the type
+// errors go build reports
+func Merge(lists ...[]Diagnostic) []Diagnostic {
+	type key struct {
+		file         string
+		line, column int
+		message      string
+	}
+	seen := make(map[key]bool)
+	var merged []Diagnostic
+	for _, list := range lists {
+		for _, diagnostic := range list {
+			k := key{diagnostic.FilePath, diagnostic.Line, diagnostic.Column, diagnostic.Message}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			merged = append(merged, diagnostic)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return merged
+}
+
+// truncate shortens s to at most n bytes on a line boundary when it can
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n]
+	if idx := strings.LastIndexByte(s, '\n'); idx > 0 {
+		s = s[:idx]
+	}
+	return s + "\n..."
+}
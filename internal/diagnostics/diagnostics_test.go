@@ -0,0 +1,134 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func TestParseLineFormat(t *testing.T) {
+	output := `# example.com/app/store
+store/store.go:12:9: undefined: missing
+vet: store/load.go:3:2: "os" imported and not used
+./cmd/main.go:7: warning: result of Load is unused
+go: downloading example.com/dep v1.0.0
+`
+	got := Parse("line", output, "/work", "error", "go build")
+	want := []Diagnostic{
+		{FilePath: "store/store.go", Line: 12, Column: 9, Severity: "error", Message: "undefined: missing", Source: "go build"},
+		{FilePath: "store/load.go", Line: 3, Column: 2, Severity: "error", Message: `"os" imported and not used`, Source: "go build"},
+		{FilePath: filepath.Join("cmd", "main.go"), Line: 7, Severity: "warning", Message: "result of Load is unused", Source: "go build"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected diagnostics:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseTscFormat(t *testing.T) {
+	output := "src/index.ts(4,7): error TS2322: Type 'string' is not assignable to type 'number'.\nFound 1 error.\n"
+	got := Parse("tsc", output, "/work", "error", "tsc")
+	want := []Diagnostic{{
+		FilePath: filepath.Join("src", "index.ts"),
+		Line:     4,
+		Column:   7,
+		Severity: "error",
+		Code:     "TS2322",
+		Message:  "Type 'string' is not assignable to type 'number'.",
+		Source:   "tsc",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unexpected diagnostics:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseAbsolutePaths(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "work")
+	output := filepath.Join(root, "app.py") + ":2:1: 'os' imported but unused\n"
+	got := Parse("line", output, root, "warning", "pyflakes")
+	if len(got) != 1 || got[0].FilePath != "app.py" || got[0].Severity != "warning" {
+		t.Errorf("Expected a warning in app.py, got %+v", got)
+	}
+}
+
+func TestExpandArgs(t *testing.T) {
+	args := []string{"vet", "{package}", "{file}"}
+	if got := expandArgs(args, Target{Root: "/work"}); !reflect.DeepEqual(got, []string{"vet", "./...", "."}) {
+		t.Errorf("Unexpected workspace args %q", got)
+	}
+	if got := expandArgs(args, Target{Root: "/work", File: filepath.Join("store", "store.go")}); !reflect.DeepEqual(got, []string{"vet", "./store", filepath.Join("store", "store.go")}) {
+		t.Errorf("Unexpected file args %q", got)
+	}
+	if got := expandArgs(args, Target{Root: "/work", File: "main.go"}); !reflect.DeepEqual(got, []string{"vet", ".", "main.go"}) {
+		t.Errorf("Unexpected top-level file args %q", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	build := []Diagnostic{{FilePath: "b.go", Line: 2, Column: 1, Message: "undefined: x", Source: "go build"}}
+	vet := []Diagnostic{
+		{FilePath: "b.go", Line: 2, Column: 1, Message: "undefined: x", Source: "go vet"},
+		{FilePath: "a.go", Line: 9, Message: "unreachable code", Source: "go vet"},
+	}
+	got := Merge(build, vet)
+	if len(got) != 2 || got[0].FilePath != "a.go" || got[1].Source != "go build" {
+		t.Errorf("Expected the duplicate dropped and results sorted, got %+v", got)
+	}
+}
+
+func TestCheckGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":      "module example.com/app\n\ngo 1.21\n",
+		"main.go":     "package main\n\nfunc main() {}\n",
+		"bad/bad.go":  "package bad\n\nvar X int = \"s\"\n",
+		"good/doc.go": "package good\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+
+	checker := config.CheckerConfig{Name: "go build", Command: "go", Args: []string{"build", "-gcflags=-e", "-o", os.DevNull, "{package}"}, Format: "line"}
+	run, err := Check(context.Background(), checker, Target{Root: root}, time.Minute)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if run.ExitCode == 0 || len(run.Diagnostics) != 1 {
+		t.Fatalf("Expected one error from a failing build, got %+v", run)
+	}
+	if got := run.Diagnostics[0]; got.FilePath != filepath.Join("bad", "bad.go") || got.Line != 3 || got.Severity != "error" {
+		t.Errorf("Unexpected diagnostic %+v", got)
+	}
+
+	run, err = Check(context.Background(), checker, Target{Root: root, File: filepath.Join("good", "doc.go")}, time.Minute)
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if run.ExitCode != 0 || len(run.Diagnostics) != 0 {
+		t.Errorf("Expected a clean package, got %+v", run)
+	}
+}
+
+func TestCheckNotInstalled(t *testing.T) {
+	checker := config.CheckerConfig{Command: "code-indexer-no-such-checker"}
+	if _, err := Check(context.Background(), checker, Target{Root: t.TempDir()}, time.Second); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Expected ErrNotInstalled, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package embeddings provides semantic search over code chunks. Chunks are
+// turned into vectors by an Embedder, kept in a Store next to the keyword
+// index and ranked by cosine similarity to an embedded query.
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// Embedding providers
+const (
+	ProviderLocal  = "local"
+	ProviderOpenAI = "openai"
+)
+
+// Embedder turns texts into vectors. Every vector an embedder returns has
+// the same length, and vectors from different models are not comparable.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Model() string
+}
+
+// NewEmbedder creates the embedder selected by the configuration
+func NewEmbedder(cfg *config.EmbeddingsConfig, logger *zap.Logger) (Embedder, error) {
+	switch cfg.Provider {
+	case ProviderLocal, "":
+		return NewLocalEmbedder(cfg.Dimensions), nil
+	case ProviderOpenAI:
+		apiKey := os.Getenv(cfg.APIKeyEnv)
+		if apiKey == "" {
+			logger.Warn("No API key for embeddings provider, sending unauthenticated requests",
+				zap.String("api_key_env", cfg.APIKeyEnv))
+		}
+		timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+		return NewOpenAIEmbedder(cfg.BaseURL, cfg.Model, apiKey, cfg.BatchSize, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown embeddings provider %q", cfg.Provider)
+	}
+}
+
+// normalize scales a vector to unit length so cosine similarity becomes a
+// dot product. Zero vectors are left unchanged.
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
+
+// cosine returns the cosine similarity of two unit vectors
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
@@ -0,0 +1,196 @@
+package embeddings
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"parseHTTPRequest", []string{"parse", "http", "request"}},
+		{"user_id = getUser(42)", []string{"user", "id", "get", "user", "42"}},
+		{"func (s *Server) Close() error", []string{"server", "close", "error"}},
+		{"sha256Sum", []string{"sha", "256", "sum"}},
+	}
+
+	for _, tt := range tests {
+		if got := tokenize(tt.input); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("tokenize(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestLocalEmbedderSimilarity(t *testing.T) {
+	embedder := NewLocalEmbedder(256)
+	vectors, err := embedder.Embed(context.Background(), []string{
+		"authenticate user with password",
+		"func AuthenticateUser(name, password string) error",
+		"func renderChart(points []Point) image.Image",
+	})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	for i, vector := range vectors {
+		if len(vector) != 256 {
+			t.Fatalf("Vector %d has %d dimensions, want 256", i, len(vector))
+		}
+	}
+
+	related := cosine(vectors[0], vectors[1])
+	unrelated := cosine(vectors[0], vectors[2])
+	if related <= unrelated {
+		t.Errorf("Expected related text to be more similar: related=%f unrelated=%f", related, unrelated)
+	}
+	if self := cosine(vectors[1], vectors[1]); self < 0.999 || self > 1.001 {
+		t.Errorf("Expected unit vectors, self similarity = %f", self)
+	}
+}
+
+// countingEmbedder counts the texts it embeds
+type countingEmbedder struct {
+	*LocalEmbedder
+	embedded int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.embedded += len(texts)
+	return e.LocalEmbedder.Embed(ctx, texts)
+}
+
+func testFile(repoID, path, content string, chunks ...types.CodeChunk) *types.CodeFile {
+	return &types.CodeFile{
+		ID:           repoID + ":" + path,
+		RepositoryID: repoID,
+		RelativePath: path,
+		Language:     "go",
+		Lines:        10,
+		Content:      content,
+		Chunks:       chunks,
+	}
+}
+
+func TestIndexSearchAndReuse(t *testing.T) {
+	embedder := &countingEmbedder{LocalEmbedder: NewLocalEmbedder(128)}
+	index, err := NewWithEmbedder(embedder, "", zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewWithEmbedder failed: %v", err)
+	}
+
+	repo := &types.Repository{ID: "r1", Name: "backend"}
+	file := testFile("r1", "auth/login.go", "",
+		types.CodeChunk{ID: "c1", Type: "function", Name: "VerifyPassword", StartLine: 1, EndLine: 5,
+			Content: "func VerifyPassword(hash, password string) bool { return bcrypt.Compare(hash, password) }"},
+		types.CodeChunk{ID: "c2", Type: "block", StartLine: 6, EndLine: 10,
+			Content: "var chartColors = []string{\"red\", \"green\"}"},
+	)
+	if err := index.IndexFile(context.Background(), file, repo); err != nil {
+		t.Fatalf("IndexFile failed: %v", err)
+	}
+	if index.Len() != 2 || embedder.embedded != 2 {
+		t.Fatalf("Expected 2 entries and 2 embedded texts, got %d and %d", index.Len(), embedder.embedded)
+	}
+
+	// Re-indexing unchanged chunks reuses their vectors
+	if err := index.IndexFile(context.Background(), file, repo); err != nil {
+		t.Fatalf("IndexFile failed: %v", err)
+	}
+	if embedder.embedded != 2 {
+		t.Errorf("Expected unchanged chunks to be reused, embedded %d texts", embedder.embedded)
+	}
+
+	results, err := index.Search(context.Background(), types.SearchQuery{Query: "check user password", MaxResults: 5})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Name != "VerifyPassword" {
+		t.Fatalf("Expected VerifyPassword first, got %+v", results)
+	}
+	if results[0].Type != "chunk" || results[0].Repository != "backend" {
+		t.Errorf("Unexpected result metadata: %+v", results[0])
+	}
+
+	results, _ = index.Search(context.Background(), types.SearchQuery{Query: "password", Repository: "frontend"})
+	if len(results) != 0 {
+		t.Errorf("Expected the repository filter to exclude all chunks, got %d", len(results))
+	}
+
+	index.DeleteRepository("r1")
+	if index.Len() != 0 {
+		t.Errorf("Expected no entries after deleting the repository, got %d", index.Len())
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.embeddings")
+	repo := &types.Repository{ID: "r1", Name: "backend"}
+	file := testFile("r1", "main.go", "package main\n\nfunc main() {}\n")
+
+	index, err := NewWithEmbedder(NewLocalEmbedder(64), path, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewWithEmbedder failed: %v", err)
+	}
+	if err := index.IndexFile(context.Background(), file, repo); err != nil {
+		t.Fatalf("IndexFile failed: %v", err)
+	}
+	if err := index.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := NewWithEmbedder(NewLocalEmbedder(64), path, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	if reopened.Len() != 1 {
+		t.Errorf("Expected the whole-file entry to persist, got %d entries", reopened.Len())
+	}
+
+	// Vectors of another model are discarded
+	otherModel, err := NewWithEmbedder(NewLocalEmbedder(32), path, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Reopen with another model failed: %v", err)
+	}
+	if otherModel.Len() != 0 {
+		t.Errorf("Expected an empty store for another model, got %d entries", otherModel.Len())
+	}
+}
+
+func TestFuse(t *testing.T) {
+	keyword := []types.SearchResult{
+		{ID: "k1", Repository: "backend", FilePath: "a.go", StartLine: 10, EndLine: 20, Score: 4},
+		{ID: "k2", Repository: "backend", FilePath: "b.go", StartLine: 1, EndLine: 5, Score: 2},
+	}
+	semantic := []types.SearchResult{
+		{ID: "s1", Repository: "backend", FilePath: "b.go", StartLine: 1, EndLine: 30, Score: 0.9},
+		{ID: "s2", Repository: "backend", FilePath: "c.go", StartLine: 1, EndLine: 10, Score: 0.8},
+	}
+
+	fused := Fuse(keyword, semantic, 0.5, 0)
+	if len(fused) != 3 {
+		t.Fatalf("Expected 3 fused results, got %d", len(fused))
+	}
+
+	// k2: 0.5*0.5 + 0.5*0.9 = 0.7, k1: 0.5*1 = 0.5, s2: 0.5*0.8 = 0.4
+	order := []string{fused[0].ID, fused[1].ID, fused[2].ID}
+	if !reflect.DeepEqual(order, []string{"k2", "k1", "s2"}) {
+		t.Errorf("Unexpected fused order: %v", order)
+	}
+	if fused[0].Context["semantic_score"] != 0.9 || fused[0].Context["keyword_score"] != 0.5 {
+		t.Errorf("Unexpected component scores: %v", fused[0].Context)
+	}
+	if keyword[1].Context != nil {
+		t.Error("Fuse must not modify its input results")
+	}
+
+	if limited := Fuse(keyword, semantic, 0.5, 2); len(limited) != 2 {
+		t.Errorf("Expected the limit to apply, got %d results", len(limited))
+	}
+}
@@ -0,0 +1,333 @@
+package embeddings
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/grep"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Limits on the text kept per chunk, in characters
+const (
+	maxEmbedChars   = 8000 // Sent to the embedder; about 2k tokens
+	maxContentChars = 2000 // Stored and returned with results
+	snippetChars    = 200
+)
+
+// Index embeds the chunks of indexed files and answers semantic queries
+type Index struct {
+	embedder Embedder
+	store    *Store
+	logger   *zap.Logger
+}
+
+// New creates an index using the configured embedder, persisted at
+// storePath. An empty storePath keeps the vectors in memory only.
+func New(cfg *config.EmbeddingsConfig, storePath string, logger *zap.Logger) (*Index, error) {
+	embedder, err := NewEmbedder(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	return NewWithEmbedder(embedder, storePath, logger)
+}
+
+// NewWithEmbedder creates an index around an existing embedder
+func NewWithEmbedder(embedder Embedder, storePath string, logger *zap.Logger) (*Index, error) {
+	store, err := OpenStore(storePath, embedder.Model())
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Embeddings index opened",
+		zap.String("model", embedder.Model()),
+		zap.String("path", storePath),
+		zap.Int("entries", store.Len()))
+
+	return &Index{
+		embedder: embedder,
+		store:    store,
+		logger:   logger,
+	}, nil
+}
+
+// Model returns the embedding model in use
+func (i *Index) Model() string {
+	return i.embedder.Model()
+}
+
+// Len returns the number of embedded chunks
+func (i *Index) Len() int {
+	return i.store.Len()
+}
+
+// IndexFile embeds the chunks of a file, replacing its previous vectors.
+// Chunks whose content did not change since the last run keep their vector,
+// so re-indexing an unchanged file costs no embedding calls. Files without
+// chunks are embedded as a whole.
+func (i *Index) IndexFile(ctx context.Context, file *types.CodeFile, repo *types.Repository) error {
+	chunks := file.Chunks
+	if len(chunks) == 0 && strings.TrimSpace(file.Content) != "" {
+		chunks = []types.CodeChunk{{
+			ID:        file.ID,
+			FileID:    file.ID,
+			Type:      "file",
+			StartLine: 1,
+			EndLine:   file.Lines,
+			Content:   file.Content,
+		}}
+	}
+
+	previous := make(map[string][]float32)
+	for _, entry := range i.store.FileEntries(file.ID) {
+		previous[entry.ContentHash] = entry.Embedding.Vector
+	}
+
+	entries := make([]Entry, 0, len(chunks))
+	var pending []int
+	var texts []string
+	for _, chunk := range chunks {
+		text := embeddingText(file.RelativePath, chunk)
+		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(text)))
+
+		entry := Entry{
+			Embedding: types.CodeEmbedding{
+				ID:        chunk.ID,
+				FileID:    file.ID,
+				ChunkID:   chunk.ID,
+				Model:     i.embedder.Model(),
+				CreatedAt: time.Now(),
+			},
+			ContentHash:  hash,
+			RepositoryID: repo.ID,
+			Repository:   repo.Name,
+			Project:      file.Project,
+			FilePath:     file.RelativePath,
+			Language:     file.Language,
+			ChunkType:    chunk.Type,
+			Name:         chunk.Name,
+			StartLine:    chunk.StartLine,
+			EndLine:      chunk.EndLine,
+			Content:      textpos.Truncate(chunk.Content, maxContentChars),
+		}
+		if vector, ok := previous[hash]; ok {
+			entry.Embedding.Vector = vector
+			entry.Embedding.Dimensions = len(vector)
+		} else {
+			pending = append(pending, len(entries))
+			texts = append(texts, text)
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(texts) > 0 {
+		vectors, err := i.embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed %s: %w", file.RelativePath, err)
+		}
+		for n, idx := range pending {
+			entries[idx].Embedding.Vector = vectors[n]
+			entries[idx].Embedding.Dimensions = len(vectors[n])
+		}
+	}
+
+	i.store.ReplaceFile(file.ID, entries)
+	return nil
+}
+
+// DeleteFile removes the vectors of a file
+func (i *Index) DeleteFile(fileID string) {
+	i.store.ReplaceFile(fileID, nil)
+}
+
+// DeleteRepository removes the vectors of a repository
+func (i *Index) DeleteRepository(repositoryID string) {
+	removed := i.store.DeleteRepository(repositoryID)
+	i.logger.Info("Removed repository embeddings", zap.String("repo_id", repositoryID), zap.Int("entries", removed))
+}
+
+// Search returns the chunks most similar to the query text that pass the
+// query's type, language, repository and path filters. Scores are cosine
+// similarities.
+func (i *Index) Search(ctx context.Context, query types.SearchQuery) ([]types.SearchResult, error) {
+	vectors, err := i.embedder.Embed(ctx, []string{query.Query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	matches := i.store.Search(vectors[0], query.MaxResults, func(entry *Entry) bool {
+		return query.AcceptsType(resultType(entry.ChunkType)) &&
+			query.AcceptsLanguage(entry.Language) &&
+			query.AcceptsRepository(entry.Repository) &&
+			query.AcceptsProject(entry.Project) &&
+			strings.Contains(entry.FilePath, query.FilePath) &&
+			acceptsPath(query, entry.FilePath)
+	})
+
+	results := make([]types.SearchResult, 0, len(matches))
+	for _, match := range matches {
+		entry := match.Entry
+		results = append(results, types.SearchResult{
+			ID:           entry.Embedding.ID,
+			RepositoryID: entry.RepositoryID,
+			Repository:   entry.Repository,
+			Project:      entry.Project,
+			FilePath:     entry.FilePath,
+			Language:     entry.Language,
+			Type:         resultType(entry.ChunkType),
+			Name:         entry.Name,
+			Content:      entry.Content,
+			Snippet:      textpos.Truncate(entry.Content, snippetChars),
+			StartLine:    entry.StartLine,
+			EndLine:      entry.EndLine,
+			Score:        match.Score,
+			Context: map[string]any{
+				"chunk_type":     entry.ChunkType,
+				"semantic_score": match.Score,
+			},
+		})
+	}
+	return results, nil
+}
+
+// Save persists the vectors if they changed
+func (i *Index) Save() error {
+	return i.store.Save()
+}
+
+// Close persists the vectors
+func (i *Index) Close() error {
+	return i.Save()
+}
+
+// embeddingText is the text embedded for a chunk. The path and name carry
+// much of a chunk's meaning, so they are included with the code.
+func embeddingText(path string, chunk types.CodeChunk) string {
+	var b strings.Builder
+	b.WriteString(path)
+	if chunk.Name != "" {
+		b.WriteString(" ")
+		b.WriteString(chunk.Name)
+	}
+	b.WriteString("\n")
+	b.WriteString(chunk.Content)
+	return textpos.Truncate(b.String(), maxEmbedChars)
+}
+
+// resultType maps a chunk type to the document type keyword search uses for
+// the same text, so type filters work the same for both
+func resultType(chunkType string) string {
+	if chunkType == "file" {
+		return "file"
+	}
+	return "chunk"
+}
+
+// Fuse merges keyword and semantic results into one ranking. Keyword scores
+// are divided by the best keyword score so that both kinds lie between 0 and
+// 1, and each result scores (1-weight)*keyword + weight*semantic. A semantic
+// chunk overlapping a keyword result in the same file adds its score to that
+// result instead of being listed on its own.
+func Fuse(keyword, semantic []types.SearchResult, weight float64, limit int) []types.SearchResult {
+	maxKeyword := 0.0
+	for _, result := range keyword {
+		if result.Score > maxKeyword {
+			maxKeyword = result.Score
+		}
+	}
+
+	semanticScores := make([]float64, len(keyword))
+	used := make([]bool, len(semantic))
+	for s, chunk := range semantic {
+		for k, result := range keyword {
+			if overlaps(result, chunk) {
+				used[s] = true
+				if chunk.Score > semanticScores[k] {
+					semanticScores[k] = chunk.Score
+				}
+			}
+		}
+	}
+
+	fused := make([]types.SearchResult, 0, len(keyword)+len(semantic))
+	for k, result := range keyword {
+		keywordScore := 0.0
+		if maxKeyword > 0 {
+			keywordScore = result.Score / maxKeyword
+		}
+		semanticScore := clampScore(semanticScores[k])
+		fused = append(fused, withFusedScore(result, keywordScore, semanticScore, weight))
+	}
+	for s, chunk := range semantic {
+		if !used[s] {
+			fused = append(fused, withFusedScore(chunk, 0, clampScore(chunk.Score), weight))
+		}
+	}
+
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	if limit > 0 && len(fused) > limit {
+		fused = fused[:limit]
+	}
+	return fused
+}
+
+// overlaps reports whether a semantic chunk covers part of a keyword result
+func overlaps(result, chunk types.SearchResult) bool {
+	if result.FilePath != chunk.FilePath || result.Repository != chunk.Repository {
+		return false
+	}
+	if result.StartLine <= 0 || chunk.StartLine <= 0 {
+		return false
+	}
+	resultEnd := result.EndLine
+	if resultEnd < result.StartLine {
+		resultEnd = result.StartLine
+	}
+	return result.StartLine <= chunk.EndLine && chunk.StartLine <= resultEnd
+}
+
+func withFusedScore(result types.SearchResult, keywordScore, semanticScore, weight float64) types.SearchResult {
+	context := make(map[string]any, len(result.Context)+2)
+	for key, value := range result.Context {
+		context[key] = value
+	}
+	context["keyword_score"] = keywordScore
+	context["semantic_score"] = semanticScore
+
+	result.Context = context
+	result.Score = (1-weight)*keywordScore + weight*semanticScore
+	return result
+}
+
+// clampScore keeps negative cosine similarities from penalising results
+func clampScore(score float64) float64 {
+	if score < 0 {
+		return 0
+	}
+	return score
+}
+
+// acceptsPath reports whether a path is under the query's path prefix and
+// matches none of its excluded globs
+func acceptsPath(query types.SearchQuery, relativePath string) bool {
+	prefix := strings.TrimPrefix(strings.TrimRight(query.PathPrefix, "*"), "./")
+	if !strings.HasPrefix(relativePath, prefix) {
+		return false
+	}
+	for _, glob := range query.ExcludePaths {
+		if grep.MatchGlob(glob, relativePath) {
+			return false
+		}
+	}
+	return true
+}
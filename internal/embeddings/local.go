@@ -0,0 +1,132 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// LocalEmbedder embeds text without a model by hashing identifier features
+// into a fixed number of dimensions. Identifiers are split on camelCase and
+// snake_case boundaries, and character trigrams let related words such as
+// "auth" and "authenticate" share dimensions. It captures vocabulary overlap
+// rather than meaning, but needs no network access or model files.
+type LocalEmbedder struct {
+	dimensions int
+}
+
+// NewLocalEmbedder creates a local embedder producing vectors of the given size
+func NewLocalEmbedder(dimensions int) *LocalEmbedder {
+	if dimensions <= 0 {
+		dimensions = 256
+	}
+	return &LocalEmbedder{dimensions: dimensions}
+}
+
+// Model returns a name identifying the feature layout and vector size
+func (e *LocalEmbedder) Model() string {
+	return fmt.Sprintf("local-hash-v1-%d", e.dimensions)
+}
+
+// Embed embeds each text
+func (e *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+// Feature weights relative to whole words
+const (
+	bigramWeight  = 0.5
+	trigramWeight = 0.25
+)
+
+func (e *LocalEmbedder) embed(text string) []float32 {
+	features := make(map[string]float64)
+	tokens := tokenize(text)
+	for i, token := range tokens {
+		features["w:"+token]++
+		if i > 0 {
+			features["b:"+tokens[i-1]+" "+token] += bigramWeight
+		}
+		padded := "^" + token + "$"
+		for j := 0; j+3 <= len(padded); j++ {
+			features["t:"+padded[j:j+3]] += trigramWeight
+		}
+	}
+
+	vector := make([]float32, e.dimensions)
+	hasher := fnv.New32a()
+	for feature, weight := range features {
+		hasher.Reset()
+		hasher.Write([]byte(feature))
+		hash := hasher.Sum32()
+
+		// Signed hashing keeps collisions from only ever adding up
+		sign := float32(1)
+		if hash&(1<<31) != 0 {
+			sign = -1
+		}
+		vector[int(hash%uint32(e.dimensions))] += sign * float32(1+math.Log(weight+1))
+	}
+	return normalize(vector)
+}
+
+// stopWords are keywords and filler words common to most code, which carry
+// no meaning for similarity
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "if": true, "else": true, "return": true,
+	"func": true, "function": true, "def": true, "var": true, "let": true, "const": true,
+	"import": true, "from": true, "package": true, "public": true, "private": true,
+	"static": true, "new": true, "nil": true, "null": true, "none": true, "true": true,
+	"false": true, "self": true, "this": true, "err": true, "to": true, "of": true,
+	"in": true, "is": true, "a": true, "an": true,
+}
+
+// tokenize lowercases text and splits it into words, breaking identifiers at
+// camelCase, snake_case and letter-digit boundaries
+func tokenize(text string) []string {
+	var tokens []string
+	var current []rune
+	flush := func() {
+		if len(current) > 1 {
+			token := strings.ToLower(string(current))
+			if !stopWords[token] {
+				tokens = append(tokens, token)
+			}
+		}
+		current = current[:0]
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if len(current) > 0 {
+			prev := current[len(current)-1]
+			switch {
+			// fooBar -> foo Bar
+			case unicode.IsUpper(r) && unicode.IsLower(prev):
+				flush()
+			// HTTPServer -> HTTP Server
+			case unicode.IsUpper(r) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]):
+				flush()
+			case unicode.IsDigit(r) != unicode.IsDigit(prev):
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return tokens
+}
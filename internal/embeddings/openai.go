@@ -0,0 +1,126 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIEmbedder embeds text through an OpenAI-compatible /embeddings
+// endpoint, which most hosted and self-hosted embedding servers provide
+type OpenAIEmbedder struct {
+	client    *http.Client
+	baseURL   string
+	model     string
+	apiKey    string
+	batchSize int
+}
+
+// NewOpenAIEmbedder creates an embedder for the API at baseURL. Texts are
+// sent in batches of batchSize.
+func NewOpenAIEmbedder(baseURL, model, apiKey string, batchSize int, timeout time.Duration) *OpenAIEmbedder {
+	if batchSize <= 0 {
+		batchSize = 64
+	}
+	return &OpenAIEmbedder{
+		client:    &http.Client{Timeout: timeout},
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		model:     model,
+		apiKey:    apiKey,
+		batchSize: batchSize,
+	}
+}
+
+// Model returns the requested model name
+func (e *OpenAIEmbedder) Model() string {
+	return e.model
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// Embed embeds texts, one request per batch
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += e.batchSize {
+		end := start + e.batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+
+		batch, err := e.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+func (e *OpenAIEmbedder) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(embeddingRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode embeddings request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embeddings response: %w", err)
+	}
+
+	var parsed embeddingResponse
+	if err := json.Unmarshal(data, &parsed); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("failed to parse embeddings response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := strings.TrimSpace(string(data))
+		if parsed.Error != nil && parsed.Error.Message != "" {
+			message = parsed.Error.Message
+		}
+		return nil, fmt.Errorf("embeddings API returned %s: %s", resp.Status, message)
+	}
+	if len(parsed.Data) != len(texts) {
+		return nil, fmt.Errorf("embeddings API returned %d vectors for %d inputs", len(parsed.Data), len(texts))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range parsed.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings API returned out of range index %d", item.Index)
+		}
+		vectors[item.Index] = normalize(item.Embedding)
+	}
+	return vectors, nil
+}
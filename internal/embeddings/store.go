@@ -0,0 +1,212 @@
+package embeddings
+
+import (
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// storeVersion is bumped whenever the persisted layout changes
+const storeVersion = 1
+
+// Entry is an embedded chunk together with what is needed to return it as a
+// search result
+type Entry struct {
+	Embedding    types.CodeEmbedding
+	ContentHash  string // Reuses the vector when a file is re-indexed unchanged
+	RepositoryID string
+	Repository   string
+	Project      string // Sub-project of the file, if any
+	FilePath     string
+	Language     string
+	ChunkType    string
+	Name         string
+	StartLine    int
+	EndLine      int
+	Content      string
+}
+
+// Match is a store entry with its similarity to a query
+type Match struct {
+	Entry Entry
+	Score float64
+}
+
+// Store keeps chunk embeddings in memory, grouped by file, and persists them
+// to a single file. A store holds vectors of one model only.
+type Store struct {
+	path  string
+	model string
+
+	mutex sync.RWMutex
+	files map[string][]Entry // Keyed by file ID
+	dirty bool
+}
+
+// storeFile is the persisted form of a store
+type storeFile struct {
+	Version int
+	Model   string
+	Files   map[string][]Entry
+}
+
+// OpenStore loads the store at path, or starts an empty one if the file does
+// not exist or was written for another model. An empty path keeps the store
+// in memory only.
+func OpenStore(path, model string) (*Store, error) {
+	store := &Store{
+		path:  path,
+		model: model,
+		files: make(map[string][]Entry),
+	}
+	if path == "" {
+		return store, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embeddings store: %w", err)
+	}
+	defer file.Close()
+
+	var persisted storeFile
+	if err := gob.NewDecoder(file).Decode(&persisted); err != nil {
+		return nil, fmt.Errorf("failed to read embeddings store %s: %w", path, err)
+	}
+
+	// Vectors of another model or layout cannot be compared with new ones
+	if persisted.Version != storeVersion || persisted.Model != model {
+		store.dirty = true
+		return store, nil
+	}
+	if persisted.Files != nil {
+		store.files = persisted.Files
+	}
+	return store, nil
+}
+
+// Model returns the model the stored vectors belong to
+func (s *Store) Model() string {
+	return s.model
+}
+
+// FileEntries returns the entries stored for a file
+func (s *Store) FileEntries(fileID string) []Entry {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]Entry(nil), s.files[fileID]...)
+}
+
+// ReplaceFile replaces all entries of a file
+func (s *Store) ReplaceFile(fileID string, entries []Entry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(entries) == 0 {
+		delete(s.files, fileID)
+	} else {
+		s.files[fileID] = entries
+	}
+	s.dirty = true
+}
+
+// DeleteRepository removes the entries of every file in a repository and
+// returns how many were removed
+func (s *Store) DeleteRepository(repositoryID string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	removed := 0
+	for fileID, entries := range s.files {
+		if len(entries) > 0 && entries[0].RepositoryID == repositoryID {
+			removed += len(entries)
+			delete(s.files, fileID)
+		}
+	}
+	if removed > 0 {
+		s.dirty = true
+	}
+	return removed
+}
+
+// Len returns the number of stored entries
+func (s *Store) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	count := 0
+	for _, entries := range s.files {
+		count += len(entries)
+	}
+	return count
+}
+
+// Search returns up to limit entries accepted by accept, most similar to the
+// unit vector query first
+func (s *Store) Search(query []float32, limit int, accept func(*Entry) bool) []Match {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var matches []Match
+	for _, entries := range s.files {
+		for idx := range entries {
+			entry := &entries[idx]
+			if accept != nil && !accept(entry) {
+				continue
+			}
+			matches = append(matches, Match{Entry: *entry, Score: cosine(query, entry.Embedding.Vector)})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Save writes the store to its file if it changed. The file is replaced
+// atomically so a crash never leaves a truncated store behind.
+func (s *Store) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create embeddings store directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".embeddings-*")
+	if err != nil {
+		return fmt.Errorf("failed to create embeddings store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	persisted := storeFile{Version: storeVersion, Model: s.model, Files: s.files}
+	if err := gob.NewEncoder(tmp).Encode(&persisted); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write embeddings store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write embeddings store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace embeddings store: %w", err)
+	}
+
+	s.dirty = false
+	return nil
+}
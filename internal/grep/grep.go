@@ -0,0 +1,210 @@
+// Package grep searches file contents line by line for a literal string or
+// regular expression, with surrounding context lines and glob filters on
+// file paths. It works on content read from disk, so it finds text the
+// search index does not hold yet.
+package grep
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+)
+
+// maxLineColumns bounds the text returned for each matching or context line
+const maxLineColumns = 300
+
+// binaryProbeSize is the number of leading bytes checked for NUL bytes to
+// recognise binary files
+const binaryProbeSize = 8000
+
+// Options controls a search
+type Options struct {
+	Pattern       string
+	Regex         bool // Pattern is a regular expression rather than literal text
+	CaseSensitive bool
+	BeforeContext int // Lines returned before each match
+	AfterContext  int // Lines returned after each match
+	Include       []string
+	Exclude       []string
+}
+
+// Line is a numbered line of a file
+type Line struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// Match is a line containing the pattern. Column and EndColumn are the
+// 1-based columns of the first match on the line; Count is the number of
+// matches on it.
+type Match struct {
+	FilePath  string `json:"file_path"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndColumn int    `json:"end_column"`
+	Count     int    `json:"count"`
+	Text      string `json:"text"`
+	Before    []Line `json:"before,omitempty"`
+	After     []Line `json:"after,omitempty"`
+}
+
+// Matcher finds the lines of a file matching a compiled search
+type Matcher struct {
+	options Options
+	re      *regexp.Regexp
+}
+
+// Compile prepares a search. Literal patterns are quoted, and a
+// case-insensitive search is turned into a (?i) expression.
+func Compile(options Options) (*Matcher, error) {
+	if options.Pattern == "" {
+		return nil, fmt.Errorf("pattern must not be empty")
+	}
+	if options.BeforeContext < 0 || options.AfterContext < 0 {
+		return nil, fmt.Errorf("context lines must not be negative")
+	}
+	for _, glob := range append(append([]string(nil), options.Include...), options.Exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(glob, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+		}
+	}
+
+	expression := options.Pattern
+	if !options.Regex {
+		expression = regexp.QuoteMeta(expression)
+	}
+	if !options.CaseSensitive {
+		expression = "(?i)" + expression
+	}
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return &Matcher{options: options, re: re}, nil
+}
+
+// Selects reports whether a file, given by its slash-separated path relative
+// to the search root, passes the include and exclude globs. A file is
+// selected when it matches any include glob, or there are none, and matches
+// no exclude glob.
+func (m *Matcher) Selects(relativePath string) bool {
+	for _, glob := range m.options.Exclude {
+		if MatchGlob(glob, relativePath) {
+			return false
+		}
+	}
+	if len(m.options.Include) == 0 {
+		return true
+	}
+	for _, glob := range m.options.Include {
+		if MatchGlob(glob, relativePath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns the matching lines of a file's content, reported under
+// filePath. Binary content has no matches.
+func (m *Matcher) Search(filePath string, content []byte) []Match {
+	if IsBinary(content) {
+		return nil
+	}
+
+	lines := textpos.SplitLines(string(content))
+	var matches []Match
+	for idx, line := range lines {
+		locations := m.re.FindAllStringIndex(line, -1)
+		count := 0
+		for _, loc := range locations {
+			if loc[0] != loc[1] {
+				count++
+			}
+		}
+		if count == 0 {
+			continue
+		}
+
+		first := locations[0]
+		for _, loc := range locations {
+			if loc[0] != loc[1] {
+				first = loc
+				break
+			}
+		}
+		match := Match{
+			FilePath:  filePath,
+			Line:      idx + 1,
+			Column:    textpos.Column(line, first[0]),
+			EndColumn: textpos.Column(line, first[1]),
+			Count:     count,
+			Text:      textpos.Truncate(line, maxLineColumns),
+			Before:    contextLines(lines, idx-m.options.BeforeContext, idx),
+			After:     contextLines(lines, idx+1, idx+1+m.options.AfterContext),
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// contextLines returns the lines from start up to end, clamped to the file
+func contextLines(lines []string, start, end int) []Line {
+	start = max(start, 0)
+	end = min(end, len(lines))
+	if start >= end {
+		return nil
+	}
+	context := make([]Line, 0, end-start)
+	for idx := start; idx < end; idx++ {
+		context = append(context, Line{Line: idx + 1, Text: textpos.Truncate(lines[idx], maxLineColumns)})
+	}
+	return context
+}
+
+// IsBinary reports whether content looks binary: it has a NUL byte near the
+// start
+func IsBinary(content []byte) bool {
+	if len(content) > binaryProbeSize {
+		content = content[:binaryProbeSize]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
+
+// MatchGlob matches a slash-separated relative path against a glob. Globs
+// without a slash match the file name at any depth, as in .gitignore; other
+// globs match the whole path, where ** matches any number of directories.
+func MatchGlob(glob, relativePath string) bool {
+	glob = strings.TrimPrefix(glob, "./")
+	if !strings.Contains(glob, "/") {
+		matched, _ := path.Match(glob, path.Base(relativePath))
+		return matched
+	}
+	return matchSegments(strings.Split(glob, "/"), strings.Split(relativePath, "/"))
+}
+
+// matchSegments matches path segments against glob segments
+func matchSegments(glob, segments []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			// ** absorbs zero or more segments
+			for skip := 0; skip <= len(segments); skip++ {
+				if matchSegments(glob[1:], segments[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(glob[0], segments[0]); !matched {
+			return false
+		}
+		glob, segments = glob[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
@@ -0,0 +1,86 @@
+package grep
+
+import (
+	"testing"
+)
+
+const sample = `package main
+
+func main() {
+	fmt.Println("Hello")
+	fmt.Println("hello, hello")
+}
+`
+
+func TestSearchLiteral(t *testing.T) {
+	matcher, err := Compile(Options{Pattern: "Println(", BeforeContext: 1, AfterContext: 1, CaseSensitive: true})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	matches := matcher.Search("main.go", []byte(sample))
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %+v", matches)
+	}
+	first := matches[0]
+	if first.Line != 4 || first.Column != 6 || first.EndColumn != 14 {
+		t.Errorf("Unexpected position %d:%d-%d", first.Line, first.Column, first.EndColumn)
+	}
+	if len(first.Before) != 1 || first.Before[0].Line != 3 || first.Before[0].Text != "func main() {" {
+		t.Errorf("Unexpected before context: %+v", first.Before)
+	}
+	if len(matches[1].After) != 1 || matches[1].After[0].Text != "}" {
+		t.Errorf("Unexpected after context: %+v", matches[1].After)
+	}
+}
+
+func TestSearchCaseAndRegex(t *testing.T) {
+	sensitive, err := Compile(Options{Pattern: "hello", CaseSensitive: true})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if matches := sensitive.Search("main.go", []byte(sample)); len(matches) != 1 || matches[0].Count != 2 {
+		t.Errorf("Expected one line with two matches, got %+v", matches)
+	}
+
+	insensitive, err := Compile(Options{Pattern: `h\w+o`, Regex: true})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+	if matches := insensitive.Search("main.go", []byte(sample)); len(matches) != 2 {
+		t.Errorf("Expected two matching lines, got %+v", matches)
+	}
+
+	if _, err := Compile(Options{Pattern: "(", Regex: true}); err == nil {
+		t.Error("Expected an invalid regular expression to be rejected")
+	}
+	if matches := sensitive.Search("blob.bin", []byte("hello\x00world")); matches != nil {
+		t.Errorf("Expected binary content to be skipped, got %+v", matches)
+	}
+}
+
+func TestSelects(t *testing.T) {
+	matcher, err := Compile(Options{
+		Pattern: "x",
+		Include: []string{"*.go", "docs/**/*.md"},
+		Exclude: []string{"vendor/**", "*_test.go"},
+	})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	tests := map[string]bool{
+		"main.go":                 true,
+		"internal/server/tool.go": true,
+		"internal/tool_test.go":   false,
+		"vendor/pkg/lib.go":       false,
+		"docs/TOOLS.md":           true,
+		"docs/guides/setup.md":    true,
+		"README.md":               false,
+	}
+	for path, expected := range tests {
+		if got := matcher.Selects(path); got != expected {
+			t.Errorf("Selects(%q) = %v, expected %v", path, got, expected)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+package indexer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Layout of an index archive: a gzipped tar holding the manifest first, then
+// the repository metadata, then the files of each repository index below
+// archiveIndexesDir in a directory named after the repository ID
+const (
+	archiveFormat        = "code-indexer-index"
+	archiveFormatVersion = 1
+	archiveManifestName  = "manifest.json"
+	archiveMetadataName  = "metadata.json"
+	archiveIndexesDir    = "repositories"
+)
+
+// ErrInvalidArchive is returned for archives that are not index archives or
+// were written in another layout
+var ErrInvalidArchive = errors.New("invalid index archive")
+
+// ImportOptions select what ImportIndex imports and where
+type ImportOptions struct {
+	// Names or IDs of the repositories to import; all when empty
+	Repositories []string
+
+	// Directory the repositories are checked out under on this machine.
+	// When set, each repository is recorded at the directory of the same
+	// base name below it rather than where it was exported from.
+	RepositoryRoot string
+
+	// Replace repositories indexed here already instead of skipping them
+	Overwrite bool
+}
+
+// ExportIndex writes the indexes of the named repositories, or of all
+// repositories when none are named, to w as an index archive, together with
+// their records, settings and indexing history. The indexes stay searchable
+// and writable while they are copied.
+func (i *Indexer) ExportIndex(ctx context.Context, w io.Writer, repositories []string) (*types.IndexArchive, error) {
+	repos, err := i.exportedRepositories(ctx, repositories)
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("no repositories to export")
+	}
+
+	staging, err := os.MkdirTemp("", "code-indexer-export-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	archive := &types.IndexArchive{
+		Format:         archiveFormat,
+		FormatVersion:  archiveFormatVersion,
+		MappingVersion: i.searcher.MappingVersion(),
+		CreatedAt:      time.Now(),
+	}
+	metadata := metadataFile{
+		Version:      metadataVersion,
+		Repositories: make(map[string]*types.Repository),
+		History:      make(map[string][]*types.IndexingRun),
+		Settings:     make(map[string]types.RepositorySettings),
+		Files:        make(map[string]map[string]types.FileState),
+	}
+	for _, repo := range repos {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := i.searcher.CopyRepositoryIndex(repo.ID, filepath.Join(staging, archiveIndexesDir, repo.ID)); err != nil {
+			return nil, err
+		}
+		documents, err := i.searcher.CountDocuments(ctx, repo.ID)
+		if err != nil {
+			return nil, err
+		}
+		archive.Repositories = append(archive.Repositories, types.ArchivedRepository{
+			ID:        repo.ID,
+			Name:      repo.Name,
+			Path:      repo.Path,
+			Commit:    repo.LastCommit,
+			Documents: documents,
+		})
+
+		record := repo
+		metadata.Repositories[repo.ID] = &record
+		if settings, ok := i.RepositorySettings(repo.ID); ok {
+			metadata.Settings[repo.ID] = settings
+		}
+		i.repositoriesMutex.RLock()
+		if files := i.files[repo.ID]; len(files) > 0 {
+			metadata.Files[repo.ID] = maps.Clone(files)
+		}
+		i.repositoriesMutex.RUnlock()
+		i.historyMutex.RLock()
+		if runs := i.history[repo.Name]; len(runs) > 0 {
+			metadata.History[repo.Name] = runs
+		}
+		i.historyMutex.RUnlock()
+	}
+
+	if err := writeArchive(w, archive, &metadata, staging); err != nil {
+		return nil, err
+	}
+	i.logger.Info("Exported index", zap.Int("repositories", len(archive.Repositories)))
+	return archive, nil
+}
+
+// exportedRepositories resolves the repositories to export by name or ID,
+// or returns all when none are named
+func (i *Indexer) exportedRepositories(ctx context.Context, names []string) ([]types.Repository, error) {
+	if len(names) == 0 {
+		return i.ListRepositories(ctx)
+	}
+	var repos []types.Repository
+	for _, name := range names {
+		repo, err := i.findRepository(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(repos, func(exported types.Repository) bool { return exported.ID == repo.ID }) {
+			repos = append(repos, *repo)
+		}
+	}
+	return repos, nil
+}
+
+// writeArchive writes the manifest, the metadata and the files below dir as
+// a gzipped tar
+func writeArchive(w io.Writer, archive *types.IndexArchive, metadata *metadataFile, dir string) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	writeJSON := func(name string, value interface{}) error {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: archive.CreatedAt}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = tarWriter.Write(data)
+		return err
+	}
+	if err := writeJSON(archiveManifestName, archive); err != nil {
+		return fmt.Errorf("failed to write archive manifest: %w", err)
+	}
+	if err := writeJSON(archiveMetadataName, metadata); err != nil {
+		return fmt.Errorf("failed to write archive metadata: %w", err)
+	}
+
+	err := filepath.WalkDir(dir, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{Name: filepath.ToSlash(rel), Mode: 0644, Size: info.Size(), ModTime: info.ModTime()}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// ImportIndex reads an index archive from r and adds its repositories with
+// their indexes and metadata. The archive must have been exported with the
+// mapping version of this server. Repositories indexed here already are
+// skipped unless options.Overwrite is set. Repository IDs are kept, so
+// refresh_index, rather than index_repository, brings imported repositories
+// up to date incrementally.
+func (i *Indexer) ImportIndex(ctx context.Context, r io.Reader, options ImportOptions) (*types.IndexImport, error) {
+	startTime := time.Now()
+
+	staging, err := os.MkdirTemp("", "code-indexer-import-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	archive, metadata, err := extractArchive(r, staging)
+	if err != nil {
+		return nil, err
+	}
+	if archive.MappingVersion != i.searcher.MappingVersion() {
+		return nil, fmt.Errorf("%w: exported with mapping version %q, this server uses %q; export it again with this version",
+			ErrInvalidArchive, archive.MappingVersion, i.searcher.MappingVersion())
+	}
+
+	result := &types.IndexImport{Archive: *archive}
+	for _, archived := range archive.Repositories {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if len(options.Repositories) > 0 && !slices.Contains(options.Repositories, archived.Name) && !slices.Contains(options.Repositories, archived.ID) {
+			continue
+		}
+		if _, err := i.findRepository(ctx, archived.ID); err == nil && !options.Overwrite {
+			result.Skipped = append(result.Skipped, archived.Name)
+			continue
+		}
+
+		repo := metadata.Repositories[archived.ID]
+		if repo == nil {
+			repo = &types.Repository{ID: archived.ID, Name: archived.Name, Path: archived.Path, LastCommit: archived.Commit}
+		}
+		localPath, err := i.importedPath(repo.Path, options.RepositoryRoot)
+		if err != nil {
+			return result, err
+		}
+
+		if err := i.searcher.ImportRepositoryIndex(archived.ID, filepath.Join(staging, archiveIndexesDir, archived.ID)); err != nil {
+			return result, err
+		}
+
+		var settings *types.RepositorySettings
+		if stored, ok := metadata.Settings[archived.ID]; ok {
+			settings = &stored
+		}
+		if settings != nil && settings.Source == repo.Path {
+			settings.Source = localPath
+		}
+		repo.Path = localPath
+
+		// The imported documents are those of the files the archive
+		// recorded, which refreshes compare the checkout here with
+		i.forgetFiles(repo.ID)
+		i.recordFiles(repo.ID, metadata.Files[archived.ID])
+		i.rememberRepository(repo, settings)
+		if err := i.repoMgr.RegisterRoot(repo.Path); err != nil {
+			i.logger.Warn("Failed to register repository root", zap.String("path", repo.Path), zap.Error(err))
+		}
+		i.historyMutex.Lock()
+		if runs := metadata.History[repo.Name]; len(runs) > 0 && len(i.history[repo.Name]) == 0 {
+			i.history[repo.Name] = runs
+		}
+		i.historyMutex.Unlock()
+
+		archived.Path = repo.Path
+		result.Imported = append(result.Imported, archived)
+	}
+	i.saveMetadata()
+
+	result.ElapsedSeconds = time.Since(startTime).Seconds()
+	i.logger.Info("Imported index",
+		zap.Int("imported", len(result.Imported)),
+		zap.Int("skipped", len(result.Skipped)))
+	return result, nil
+}
+
+// importedPath returns where a repository exported from archivedPath is
+// checked out here: below root when it is given, where it was exported from
+// otherwise. The path comes from the archive, so it is only accepted inside
+// the directories the file tools may already access; registering it as is
+// would let an archive open any directory to them.
+func (i *Indexer) importedPath(archivedPath, root string) (string, error) {
+	localPath := archivedPath
+	if root != "" {
+		base := filepath.Base(archivedPath)
+		if base == "." || base == ".." || base == string(filepath.Separator) {
+			return "", fmt.Errorf("%w: repository path %q has no directory name", ErrInvalidArchive, archivedPath)
+		}
+		localPath = filepath.Join(root, base)
+	}
+	if _, err := i.repoMgr.ResolvePath(localPath); err != nil {
+		return "", fmt.Errorf("cannot import repository at %s: %w", localPath, err)
+	}
+	return localPath, nil
+}
+
+// extractArchive checks the manifest of an index archive and extracts the
+// repository indexes to dir
+func extractArchive(r io.Reader, dir string) (*types.IndexArchive, *metadataFile, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+
+	var archive *types.IndexArchive
+	metadata := &metadataFile{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		// The manifest comes first so anything else is only read from
+		// archives of a known layout
+		if archive == nil {
+			if header.Name != archiveManifestName {
+				return nil, nil, fmt.Errorf("%w: no manifest", ErrInvalidArchive)
+			}
+			archive = &types.IndexArchive{}
+			if err := json.NewDecoder(tarReader).Decode(archive); err != nil {
+				return nil, nil, fmt.Errorf("%w: unreadable manifest: %v", ErrInvalidArchive, err)
+			}
+			if archive.Format != archiveFormat || archive.FormatVersion != archiveFormatVersion {
+				return nil, nil, fmt.Errorf("%w: format %q version %d, expected %q version %d",
+					ErrInvalidArchive, archive.Format, archive.FormatVersion, archiveFormat, archiveFormatVersion)
+			}
+			continue
+		}
+
+		switch {
+		case header.Name == archiveMetadataName:
+			if err := json.NewDecoder(tarReader).Decode(metadata); err != nil {
+				return nil, nil, fmt.Errorf("%w: unreadable metadata: %v", ErrInvalidArchive, err)
+			}
+		case header.Typeflag == tar.TypeReg:
+			if err := extractIndexFile(tarReader, header, archive, dir); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if archive == nil {
+		return nil, nil, fmt.Errorf("%w: empty archive", ErrInvalidArchive)
+	}
+	return archive, metadata, nil
+}
+
+// extractIndexFile writes a file of a repository index in the archive below
+// dir. Only files inside the index of a repository the manifest lists are
+// accepted.
+func extractIndexFile(r io.Reader, header *tar.Header, archive *types.IndexArchive, dir string) error {
+	name := path.Clean(header.Name)
+	rel, ok := strings.CutPrefix(name, archiveIndexesDir+"/")
+	repositoryID, _, _ := strings.Cut(rel, "/")
+	listed := slices.ContainsFunc(archive.Repositories, func(repo types.ArchivedRepository) bool { return repo.ID == repositoryID })
+	if !ok || !filepath.IsLocal(rel) || !listed || !strings.Contains(rel, "/") {
+		return fmt.Errorf("%w: unexpected file %s", ErrInvalidArchive, header.Name)
+	}
+
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, r); err != nil {
+		return fmt.Errorf("failed to extract archive: %w", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,272 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/chunking"
+	"github.com/my-mcp/code-indexer/internal/quality"
+	"github.com/my-mcp/code-indexer/internal/refactor"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// parsedFile is a file parsed by a worker, or the error parsing it failed
+// with
+type parsedFile struct {
+	path string
+	file *types.CodeFile
+	err  error
+}
+
+// concurrency returns the number of files parsed at the same time
+func (i *Indexer) concurrency() int {
+	if i.config.Indexer.Concurrency > 0 {
+		return i.config.Indexer.Concurrency
+	}
+	return runtime.NumCPU()
+}
+
+// indexFiles parses files with a pool of indexer.concurrency workers,
+// splits them into chunks with chunker and writes their documents to the
+// index in batches of about indexer.batch_size bytes. done is called from the calling goroutine for
+// every file, with the parsed file once its documents are batched or with
+// the error it failed with; files that fail are skipped. The time spent in
+// each phase is added to the run's timer, summed over all workers.
+// Cancelling ctx or failing to write a batch stops indexing with an error.
+// The state of each file is recorded once its batch is written.
+func (i *Indexer) indexFiles(ctx context.Context, repo *types.Repository, chunker *chunking.Chunker, files []string, refs referenceCounts, timer phaseTimer, done func(filePath string, file *types.CodeFile, err error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	workers := i.concurrency()
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	paths := make(chan string)
+	go func() {
+		defer close(paths)
+		for _, filePath := range files {
+			select {
+			case paths <- filePath:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	parsed := make(chan parsedFile, workers)
+	workerTimers := make([]phaseTimer, workers)
+	var wg sync.WaitGroup
+	for n := range workerTimers {
+		workerTimers[n] = phaseTimer{}
+		wg.Add(1)
+		go func(workerTimer phaseTimer) {
+			defer wg.Done()
+			for filePath := range paths {
+				file, err := i.parseFile(filePath, repo, chunker, refs, workerTimer)
+				select {
+				case parsed <- parsedFile{path: filePath, file: file, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(workerTimers[n])
+	}
+	go func() {
+		wg.Wait()
+		close(parsed)
+	}()
+
+	batch := i.searcher.NewBatch()
+	batched := make(map[string]types.FileState)
+	flush := func() error {
+		if err := batch.Flush(); err != nil {
+			return fmt.Errorf("failed to write index batch: %w", err)
+		}
+		i.recordFiles(repo.ID, batched)
+		clear(batched)
+		return nil
+	}
+	for result := range parsed {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if result.err != nil {
+			done(result.path, nil, result.err)
+			continue
+		}
+
+		phaseStart := time.Now()
+		batch.Add(result.file, repo)
+		batched[filepath.ToSlash(result.file.RelativePath)] = types.FileState{Hash: result.file.Hash, Size: result.file.Size, Lines: result.file.Lines}
+		if int64(batch.Size()) >= i.config.Indexer.BatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		timer.since(PhaseIndex, phaseStart)
+
+		// A file that cannot be embedded is still searchable by keyword
+		if i.embeddings != nil {
+			phaseStart = time.Now()
+			if err := i.embeddings.IndexFile(ctx, result.file, repo); err != nil {
+				i.logger.Warn("Failed to embed file", zap.String("file", result.path), zap.Error(err))
+			}
+			timer.since(PhaseEmbed, phaseStart)
+		}
+
+		done(result.path, result.file, nil)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	phaseStart := time.Now()
+	err := flush()
+	timer.since(PhaseIndex, phaseStart)
+	if err != nil {
+		return err
+	}
+
+	// Every worker has returned once parsed is closed
+	for _, workerTimer := range workerTimers {
+		for phase, duration := range workerTimer {
+			timer[phase] += duration
+		}
+	}
+	return nil
+}
+
+// parseFile reads and parses a file and splits it into chunks, adding the
+// time spent in each phase to the worker's timer
+func (i *Indexer) parseFile(filePath string, repo *types.Repository, chunker *chunking.Chunker, refs referenceCounts, timer phaseTimer) (*types.CodeFile, error) {
+	// Read file content (counted towards parsing)
+	phaseStart := time.Now()
+	content, err := i.repoMgr.GetFileContent(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file content: %w", err)
+	}
+
+	// Get relative path
+	relativePath, err := i.repoMgr.GetRelativePath(filePath, repo.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	// Determine language
+	language := i.repoMgr.DetectLanguage(filePath, content)
+
+	// Create file hash for change detection
+	hasher := sha256.New()
+	hasher.Write(content)
+	fileHash := fmt.Sprintf("%x", hasher.Sum(nil))
+
+	// Create code file structure
+	codeFile := &types.CodeFile{
+		ID:           fmt.Sprintf("%s:%s", repo.ID, relativePath),
+		RepositoryID: repo.ID,
+		Path:         filePath,
+		RelativePath: relativePath,
+		Language:     language,
+		Extension:    filepath.Ext(filePath),
+		Size:         int64(len(content)),
+		Content:      string(content),
+		Hash:         fileHash,
+		IndexedAt:    time.Now(),
+		Module:       refactor.FileModule(language, repo.Path, filePath, content).Path,
+		Project:      projectOf(repo.Path, filePath),
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		codeFile.ModifiedAt = info.ModTime()
+	}
+
+	// Parse the file to extract metadata
+	parsedFile, err := i.parser.ParseFile(string(content), filePath, language)
+	if err != nil {
+		i.logger.Warn("Failed to parse file",
+			zap.String("file", filePath),
+			zap.String("language", language),
+			zap.Error(err))
+		// Continue with basic file info even if parsing fails
+	} else {
+		// Copy parsed metadata
+		codeFile.Lines = parsedFile.Lines
+		codeFile.Functions = parsedFile.Functions
+		codeFile.Classes = parsedFile.Classes
+		codeFile.TypeDeclarations = parsedFile.TypeDeclarations
+		codeFile.Variables = parsedFile.Variables
+		codeFile.Imports = parsedFile.Imports
+		codeFile.Comments = parsedFile.Comments
+		codeFile.Sections = parsedFile.Sections
+		codeFile.ConfigKeys = parsedFile.ConfigKeys
+		codeFile.References = parsedFile.References
+		refs.apply(codeFile)
+		resolveReferences(codeFile)
+		measureComplexity(codeFile)
+	}
+
+	// Scan for hardcoded secrets whether or not the file parsed
+	if slashPath := filepath.ToSlash(relativePath); i.secrets != nil && !i.secrets.Ignored(slashPath) {
+		codeFile.SecurityFindings = i.secrets.Scan(slashPath, codeFile.Content)
+	}
+
+	// If parsing failed, at least count lines
+	if codeFile.Lines == 0 {
+		codeFile.Lines = textpos.CountLines(string(content))
+	}
+	timer.since(PhaseParse, phaseStart)
+
+	// Create semantic chunks for the file
+	phaseStart = time.Now()
+	codeFile.Chunks = chunker.ChunkFile(codeFile)
+	timer.since(PhaseChunk, phaseStart)
+
+	return codeFile, nil
+}
+
+// throughput returns the files indexed per second over elapsed
+func throughput(files int, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(files) / elapsed.Seconds()
+}
+
+// measureComplexity records the cyclomatic and cognitive complexity of the
+// functions of a file, matching the parsed functions to the measured ones
+// by name and last line, or first line
+func measureComplexity(file *types.CodeFile) {
+	if len(file.Functions) == 0 || !quality.Supported(file.Language) {
+		return
+	}
+	measured, err := quality.Measure(file.Language, file.Content)
+	if err != nil {
+		return
+	}
+	byLine := make(map[string]quality.Complexity, 2*len(measured))
+	for _, complexity := range measured {
+		byLine[fmt.Sprintf("%s:%d", complexity.Name, complexity.StartLine)] = complexity
+		byLine[fmt.Sprintf("%s:%d", complexity.Name, complexity.EndLine)] = complexity
+	}
+	for idx := range file.Functions {
+		function := &file.Functions[idx]
+		complexity, ok := byLine[fmt.Sprintf("%s:%d", function.Name, function.EndLine)]
+		if !ok {
+			complexity, ok = byLine[fmt.Sprintf("%s:%d", function.Name, function.StartLine)]
+		}
+		if ok {
+			function.Cyclomatic = complexity.Cyclomatic
+			function.Cognitive = complexity.Cognitive
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// fileChanges are the files of a repository that changed since it was last
+// indexed, told apart from the unchanged ones by their recorded state
+type fileChanges struct {
+	changed   []string // Absolute paths of new and modified files
+	deleted   []string // Relative paths of files no longer indexed
+	unchanged int
+	lines     int // Lines of the unchanged files
+}
+
+// recordFiles records the state of files indexed for a repository, keyed
+// by slash-separated relative path
+func (i *Indexer) recordFiles(repositoryID string, states map[string]types.FileState) {
+	if len(states) == 0 {
+		return
+	}
+	i.repositoriesMutex.Lock()
+	defer i.repositoriesMutex.Unlock()
+
+	files := i.files[repositoryID]
+	if files == nil {
+		files = make(map[string]types.FileState, len(states))
+		i.files[repositoryID] = files
+	}
+	for relativePath, state := range states {
+		files[relativePath] = state
+	}
+}
+
+// forgetFiles drops the recorded state of files of a repository, given by
+// slash-separated relative path, or of all its files when none are given,
+// so they are parsed again on the next run
+func (i *Indexer) forgetFiles(repositoryID string, relativePaths ...string) {
+	i.repositoriesMutex.Lock()
+	defer i.repositoriesMutex.Unlock()
+
+	if len(relativePaths) == 0 {
+		delete(i.files, repositoryID)
+		return
+	}
+	for _, relativePath := range relativePaths {
+		delete(i.files[repositoryID], relativePath)
+	}
+}
+
+// indexedFiles returns a copy of the recorded state of the files of a
+// repository about to be indexed with settings, or nil when every file has
+// to be parsed: when nothing is recorded, when the chunking overrides
+// changed, which changes every document, or when the index holds no
+// documents of the repository, as after the index directory was removed
+func (i *Indexer) indexedFiles(ctx context.Context, repositoryID string, settings types.RepositorySettings) (map[string]types.FileState, error) {
+	i.repositoriesMutex.RLock()
+	recorded := i.files[repositoryID]
+	previous := i.settings[repositoryID]
+	files := maps.Clone(recorded)
+	i.repositoriesMutex.RUnlock()
+
+	if len(files) == 0 || !reflect.DeepEqual(previous.Chunking, settings.Chunking) {
+		return nil, nil
+	}
+	documents, err := i.searcher.CountDocuments(ctx, repositoryID)
+	if err != nil || documents == 0 {
+		return nil, err
+	}
+	return files, nil
+}
+
+// compareFiles tells the files of a repository that changed since they were
+// indexed from the unchanged ones by their recorded state. A file is
+// unchanged when its size and content hash are; only files of the recorded
+// size are read.
+func (i *Indexer) compareFiles(ctx context.Context, repoPath string, files []string, recorded map[string]types.FileState) (*fileChanges, error) {
+	changes := &fileChanges{}
+	seen := make(map[string]bool, len(files))
+	for _, filePath := range files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		relativePath, err := i.repoMgr.GetRelativePath(filePath, repoPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get relative path of %s: %w", filePath, err)
+		}
+		seen[relativePath] = true
+
+		state, ok := recorded[relativePath]
+		if ok && fileUnchanged(filePath, state) {
+			changes.unchanged++
+			changes.lines += state.Lines
+			continue
+		}
+		changes.changed = append(changes.changed, filePath)
+	}
+
+	for relativePath := range recorded {
+		if !seen[relativePath] {
+			changes.deleted = append(changes.deleted, relativePath)
+		}
+	}
+	return changes, nil
+}
+
+// fileUnchanged reports whether a file still has the recorded size and
+// content hash
+func fileUnchanged(filePath string, state types.FileState) bool {
+	info, err := os.Stat(filePath)
+	if err != nil || info.Size() != state.Size {
+		return false
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return false
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(content)) == state.Hash
+}
@@ -0,0 +1,112 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Indexing phases in the order they run
+const (
+	PhasePrepare    = "prepare" // clone or pull
+	PhaseWalk       = "walk"
+	PhaseReferences = "references"
+	PhaseParse      = "parse"
+	PhaseChunk      = "chunk"
+	PhaseIndex      = "index"
+	PhaseEmbed      = "embed" // only when embeddings are enabled
+)
+
+var indexingPhases = []string{PhasePrepare, PhaseWalk, PhaseReferences, PhaseParse, PhaseChunk, PhaseIndex, PhaseEmbed}
+
+// maxRunsPerRepository bounds the history kept for each repository
+const maxRunsPerRepository = 50
+
+// phaseTimer accumulates time spent in each indexing phase. Per-file phases
+// are summed over all files of a run.
+type phaseTimer map[string]time.Duration
+
+// since adds the time elapsed since start to a phase
+func (t phaseTimer) since(phase string, start time.Time) {
+	t[phase] += time.Since(start)
+}
+
+// timings returns the recorded phases in execution order
+func (t phaseTimer) timings() []types.PhaseTiming {
+	timings := make([]types.PhaseTiming, 0, len(indexingPhases))
+	for _, phase := range indexingPhases {
+		timings = append(timings, types.PhaseTiming{
+			Phase:           phase,
+			DurationSeconds: t[phase].Seconds(),
+		})
+	}
+	return timings
+}
+
+// finishRun completes an indexing run and adds it to the history
+func (i *Indexer) finishRun(run *types.IndexingRun, timer phaseTimer, err error) {
+	run.CompletedAt = time.Now()
+	run.ElapsedSeconds = run.CompletedAt.Sub(run.StartedAt).Seconds()
+	run.Phases = timer.timings()
+
+	switch {
+	case err == nil:
+		run.Status = "completed"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		run.Status = "cancelled"
+		run.Error = err.Error()
+	default:
+		run.Status = "failed"
+		run.Error = err.Error()
+	}
+
+	i.historyMutex.Lock()
+	runs := append(i.history[run.Repository], run)
+	if len(runs) > maxRunsPerRepository {
+		runs = runs[len(runs)-maxRunsPerRepository:]
+	}
+	i.history[run.Repository] = runs
+	i.historyMutex.Unlock()
+	i.saveMetadata()
+
+	fields := []zap.Field{
+		zap.String("repository", run.Repository),
+		zap.String("status", run.Status),
+		zap.Float64("elapsed_seconds", run.ElapsedSeconds),
+	}
+	for _, phase := range run.Phases {
+		fields = append(fields, zap.Float64(phase.Phase+"_seconds", phase.DurationSeconds))
+	}
+	i.logger.Info("Indexing run recorded", fields...)
+}
+
+// IndexingHistory returns past indexing runs, newest first. The repository
+// may be given by name or ID; an empty repository returns runs for all
+// repositories. A limit of zero or less returns every recorded run.
+func (i *Indexer) IndexingHistory(repository string, limit int) []types.IndexingRun {
+	i.historyMutex.RLock()
+	defer i.historyMutex.RUnlock()
+
+	var runs []types.IndexingRun
+	for name, repoRuns := range i.history {
+		for _, run := range repoRuns {
+			if repository == "" || name == repository || run.RepositoryID == repository {
+				runs = append(runs, *run)
+			}
+		}
+	}
+
+	sort.Slice(runs, func(a, b int) bool {
+		return runs[a].StartedAt.After(runs[b].StartedAt)
+	})
+
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs
+}
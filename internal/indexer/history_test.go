@@ -0,0 +1,97 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestIndexingHistoryIsTrimmed(t *testing.T) {
+	idx, _ := newTestIndexer(t)
+
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for n := 0; n < maxRunsPerRepository+5; n++ {
+		run := &types.IndexingRun{
+			RepositoryID: "app-id",
+			Repository:   "app",
+			Path:         fmt.Sprintf("run-%d", n),
+			StartedAt:    started.Add(time.Duration(n) * time.Minute),
+		}
+		idx.finishRun(run, phaseTimer{}, nil)
+	}
+	idx.finishRun(&types.IndexingRun{Repository: "lib", StartedAt: started}, phaseTimer{}, errors.New("disk full"))
+	idx.finishRun(&types.IndexingRun{Repository: "cli", StartedAt: started}, phaseTimer{}, context.Canceled)
+
+	// Only the newest runs of each repository are kept, newest first
+	runs := idx.IndexingHistory("app", 0)
+	if len(runs) != maxRunsPerRepository {
+		t.Fatalf("Expected %d runs to be kept, got %d", maxRunsPerRepository, len(runs))
+	}
+	if runs[0].Path != fmt.Sprintf("run-%d", maxRunsPerRepository+4) || runs[len(runs)-1].Path != "run-5" {
+		t.Errorf("Expected the newest runs down to run-5, got %s to %s", runs[0].Path, runs[len(runs)-1].Path)
+	}
+	if runs[0].Status != "completed" {
+		t.Errorf("Expected a completed run, got %q", runs[0].Status)
+	}
+
+	// Repositories are looked up by name or ID; the limit keeps the newest
+	if runs := idx.IndexingHistory("app-id", 3); len(runs) != 3 || runs[2].Path != fmt.Sprintf("run-%d", maxRunsPerRepository+2) {
+		t.Errorf("Expected the three newest runs by ID, got %+v", runs)
+	}
+	if runs := idx.IndexingHistory("", 0); len(runs) != maxRunsPerRepository+2 {
+		t.Errorf("Expected the runs of every repository, got %d", len(runs))
+	}
+	if runs := idx.IndexingHistory("lib", 0); len(runs) != 1 || runs[0].Status != "failed" || runs[0].Error != "disk full" {
+		t.Errorf("Expected a failed run, got %+v", runs)
+	}
+	if runs := idx.IndexingHistory("cli", 0); len(runs) != 1 || runs[0].Status != "cancelled" {
+		t.Errorf("Expected a cancelled run, got %+v", runs)
+	}
+}
+
+func TestIndexingRunRecordsPhases(t *testing.T) {
+	idx, root := newTestIndexer(t)
+	dir := filepath.Join(root, "app")
+	writeFiles(t, dir, map[string]string{
+		"main.go":     "package main\n\nfunc main() {}\n",
+		"util/str.go": "package util\n\nfunc Upper(s string) string { return s }\n",
+	})
+	if _, err := idx.IndexRepository(context.Background(), dir, "app"); err != nil {
+		t.Fatalf("IndexRepository failed: %v", err)
+	}
+
+	runs := idx.IndexingHistory("app", 0)
+	if len(runs) != 1 {
+		t.Fatalf("Expected one run, got %+v", runs)
+	}
+	run := runs[0]
+	if run.Status != "completed" || run.FilesIndexed != 2 || run.ElapsedSeconds <= 0 {
+		t.Errorf("Unexpected run %+v", run)
+	}
+
+	// Every phase is reported in execution order; the embed phase takes no
+	// time without embeddings
+	if len(run.Phases) != len(indexingPhases) {
+		t.Fatalf("Expected %d phases, got %+v", len(indexingPhases), run.Phases)
+	}
+	for n, phase := range run.Phases {
+		if phase.Phase != indexingPhases[n] {
+			t.Errorf("Expected phase %d to be %s, got %s", n, indexingPhases[n], phase.Phase)
+		}
+		switch phase.Phase {
+		case PhaseWalk, PhaseParse, PhaseIndex:
+			if phase.DurationSeconds <= 0 {
+				t.Errorf("Expected time to be recorded for %s, got %+v", phase.Phase, run.Phases)
+			}
+		case PhaseEmbed:
+			if phase.DurationSeconds != 0 {
+				t.Errorf("Expected no embedding time, got %v", phase.DurationSeconds)
+			}
+		}
+	}
+}
@@ -0,0 +1,531 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/repository"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// maxIncrementalCommits is the number of new commits above which an
+// incremental run falls back to a full re-index
+const maxIncrementalCommits = 500
+
+// ErrNotIndexed is returned for incremental runs on repositories this
+// indexer has no record of
+var ErrNotIndexed = errors.New("repository has not been indexed yet")
+
+// ErrLocalWorkingCopy is returned by SwitchRef for repositories indexed from
+// a local working copy, which the indexer does not check out refs in
+var ErrLocalWorkingCopy = errors.New("repository is a local working copy")
+
+// rememberRepository records a repository and the commit it was indexed at,
+// and persists the metadata. Nil settings keep the recorded ones.
+func (i *Indexer) rememberRepository(repo *types.Repository, settings *types.RepositorySettings) {
+	snapshot := *repo
+
+	i.repositoriesMutex.Lock()
+	i.repositories[repo.ID] = &snapshot
+	if settings != nil {
+		i.settings[repo.ID] = *settings
+	}
+	i.repositoriesMutex.Unlock()
+
+	i.saveMetadata()
+	i.notifyChange(snapshot, false)
+}
+
+// IndexedRepository returns a repository indexed by this indexer, looked up
+// by name or ID
+func (i *Indexer) IndexedRepository(repository string) (*types.Repository, bool) {
+	i.repositoriesMutex.RLock()
+	defer i.repositoriesMutex.RUnlock()
+
+	if repo, ok := i.repositories[repository]; ok {
+		snapshot := *repo
+		return &snapshot, true
+	}
+	for _, repo := range i.repositories {
+		if repo.Name == repository {
+			snapshot := *repo
+			return &snapshot, true
+		}
+	}
+	return nil, false
+}
+
+// IndexIncremental brings the index of a repository up to date with its
+// HEAD commit by re-indexing only the files changed by commits since it was
+// last indexed, and removing the documents of deleted files. Uncommitted
+// changes are not picked up.
+//
+// The repository falls back to a full re-index when ForceRebuild is set,
+// when no previous commit is known, when the previous commit is no longer in
+// the history (for example after a force push) or when too many commits
+// were made since. The result's Mode and FallbackReason tell which happened.
+func (i *Indexer) IndexIncremental(ctx context.Context, req types.IncrementalIndexRequest) (*types.IncrementalIndexResult, error) {
+	return i.indexIncremental(ctx, req, false)
+}
+
+// indexIncremental runs IndexIncremental. With anyCommit the commit checked
+// out need not descend from the one indexed last, as after switching refs;
+// the files that differ between the two are re-indexed all the same.
+func (i *Indexer) indexIncremental(ctx context.Context, req types.IncrementalIndexRequest, anyCommit bool) (result *types.IncrementalIndexResult, err error) {
+	previous, ok := i.IndexedRepository(req.RepositoryID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotIndexed, req.RepositoryID)
+	}
+	ctx, release, err := i.lockRepository(ctx, previous.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock repository: %w", err)
+	}
+	defer release()
+
+	// Remote repositories are pulled again before they are compared
+	source := previous.Path
+	if previous.URL != "" {
+		source = previous.URL
+	}
+
+	fromCommit := req.FromCommit
+	if fromCommit == "" {
+		fromCommit = previous.LastIndexedHash
+	}
+	if req.ForceRebuild {
+		return i.rebuild(ctx, previous, source, fromCommit, "force_rebuild requested")
+	}
+	if fromCommit == "" {
+		return i.rebuild(ctx, previous, source, fromCommit, "no previously indexed commit is known")
+	}
+
+	i.logger.Info("Starting incremental indexing",
+		zap.String("repository", previous.Name),
+		zap.String("from_commit", fromCommit))
+
+	startTime := time.Now()
+	run := &types.IndexingRun{
+		RepositoryID: previous.ID,
+		Repository:   previous.Name,
+		Path:         source,
+		Mode:         "incremental",
+		StartedAt:    startTime,
+	}
+	timer := phaseTimer{}
+	recordRun := true
+	defer func() {
+		if recordRun {
+			i.finishRun(run, timer, err)
+		}
+	}()
+
+	progress := &types.IndexingProgress{
+		RepositoryID: previous.ID,
+		Repository:   previous.Name,
+		Status:       "starting",
+		StartedAt:    startTime,
+	}
+
+	phaseStart := time.Now()
+	settings, _ := i.RepositorySettings(previous.ID)
+	repo, err := i.repoMgr.PrepareRepositoryWithOptions(withCloneProgress(ctx, progress), source, previous.Name, settings.Ref, cloneOptions(settings.Clone))
+	timer.since(PhasePrepare, phaseStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare repository: %w", err)
+	}
+	repo.Namespace = settings.Namespace
+
+	toCommit := repo.LastIndexedHash
+	if toCommit == "" {
+		recordRun = false
+		return i.rebuild(ctx, previous, source, fromCommit, "repository has no commits to compare")
+	}
+	if req.ToCommit != "" && !strings.HasPrefix(toCommit, req.ToCommit) {
+		return nil, fmt.Errorf("to_commit %s is not the checked out HEAD %s", req.ToCommit, toCommit)
+	}
+
+	result = &types.IncrementalIndexResult{
+		RepositoryID: repo.ID,
+		Repository:   repo.Name,
+		Mode:         "incremental",
+		FromCommit:   fromCommit,
+		ToCommit:     toCommit,
+	}
+
+	if toCommit == fromCommit {
+		result.Mode = "unchanged"
+		result.FilesSkipped = previous.FileCount
+		result.DocumentsSkipped, err = i.searcher.CountDocuments(ctx, repo.ID)
+		if err != nil {
+			return nil, err
+		}
+		run.FilesSkipped = result.FilesSkipped
+
+		// A ref switched to names the commit already indexed
+		if repo.Ref != previous.Ref {
+			if err := i.searcher.SetRepositoryRef(repo.ID, repo.Ref); err != nil {
+				return nil, err
+			}
+			previous.Ref = repo.Ref
+			i.rememberRepository(previous, nil)
+		}
+		result.ElapsedSeconds = time.Since(startTime).Seconds()
+		return result, nil
+	}
+
+	// Walk the commits since the last run to check the previous commit is
+	// still in the history and not too far behind
+	phaseStart = time.Now()
+	commits, err := i.repoMgr.GetCommitHistory(repo.Path, fromCommit, maxIncrementalCommits+1)
+	switch {
+	case anyCommit && (errors.Is(err, repository.ErrCommitNotFound) || len(commits) > maxIncrementalCommits):
+		// The trees are compared below however far apart the commits are
+		commits = nil
+	case errors.Is(err, repository.ErrCommitNotFound):
+		recordRun = false
+		return i.rebuild(ctx, previous, source, fromCommit, fmt.Sprintf("commit %s is not in the history of HEAD", fromCommit))
+	case err != nil:
+		return nil, fmt.Errorf("failed to read commit history: %w", err)
+	case len(commits) > maxIncrementalCommits:
+		recordRun = false
+		return i.rebuild(ctx, previous, source, fromCommit, fmt.Sprintf("more than %d commits since the last index", maxIncrementalCommits))
+	}
+	result.Commits = len(commits)
+
+	// Compare the trees rather than the commits in between, so files
+	// changed on merged branches are included
+	changed, err := i.repoMgr.ChangedFiles(repo.Path, fromCommit, toCommit)
+	timer.since(PhaseWalk, phaseStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare commits: %w", err)
+	}
+
+	// Discover the files that are indexed now, so changed paths can be told
+	// apart from deleted or excluded ones
+	phaseStart = time.Now()
+	var filesToIndex []string
+	indexable := make(map[string]string)
+	err = i.repoMgr.WalkFiles(ctx, repo.Path, i.repositoryFilter(repo.ID), func(filePath string, info fs.FileInfo) error {
+		if i.shouldIndexFile(filePath, info) {
+			filesToIndex = append(filesToIndex, filePath)
+			if relativePath, err := i.repoMgr.GetRelativePath(filePath, repo.Path); err == nil {
+				indexable[relativePath] = filePath
+			}
+		}
+		return nil
+	})
+	timer.since(PhaseWalk, phaseStart)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover files: %w", err)
+	}
+
+	documentsBefore, err := i.searcher.CountDocuments(ctx, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var updates []string
+	changedFiles := make(map[string]string, len(changed))
+	for _, relativePath := range changed {
+		filePath, ok := indexable[relativePath]
+		changedFiles[relativePath] = filePath
+		if ok {
+			updates = append(updates, filePath)
+		} else {
+			result.FilesDeleted++
+			if i.embeddings != nil {
+				i.embeddings.DeleteFile(fmt.Sprintf("%s:%s", repo.ID, relativePath))
+			}
+		}
+	}
+
+	// Reference counts stay repository-wide; only the changed files are
+	// counted again
+	phaseStart = time.Now()
+	refs, err := i.updateReferences(ctx, repo, changedFiles, filesToIndex)
+	timer.since(PhaseReferences, phaseStart)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the old documents of every changed file first, so symbols that
+	// were removed from a file do not linger
+	phaseStart = time.Now()
+	i.forgetFiles(repo.ID, changed...)
+	result.DocumentsDeleted, err = i.searcher.DeleteFiles(ctx, repo.ID, changed)
+	timer.since(PhaseIndex, phaseStart)
+	if err != nil {
+		return nil, err
+	}
+
+	progress.Status = "indexing"
+	progress.Message = ""
+	progress.TotalFiles = len(updates)
+	reportProgress(ctx, progress)
+
+	indexStart := time.Now()
+	err = i.indexFiles(ctx, repo, i.repositoryChunker(repo.ID), updates, refs, timer, func(filePath string, file *types.CodeFile, err error) {
+		progress.FilesProcessed++
+		progress.CurrentFile = filePath
+		reportProgress(ctx, progress)
+
+		if err != nil {
+			i.logger.Warn("Failed to index file",
+				zap.String("file", filePath),
+				zap.Error(err))
+			result.FilesFailed++
+			return
+		}
+		result.FilesUpdated++
+		run.TotalLines += file.Lines
+	})
+	if err != nil {
+		return nil, err
+	}
+	run.FilesPerSecond = throughput(result.FilesUpdated, time.Since(indexStart))
+	if _, err := i.reindexOutdatedReferences(ctx, repo, i.repositoryChunker(repo.ID), refs, timer); err != nil {
+		return nil, err
+	}
+
+	if i.embeddings != nil {
+		if err := i.embeddings.Save(); err != nil {
+			i.logger.Warn("Failed to save embeddings", zap.String("repo_id", repo.ID), zap.Error(err))
+		}
+	}
+
+	documentsAfter, err := i.searcher.CountDocuments(ctx, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	result.DocumentsSkipped = documentsBefore - result.DocumentsDeleted
+	result.DocumentsUpdated = documentsAfter - result.DocumentsSkipped
+	result.FilesSkipped = len(filesToIndex) - len(updates)
+
+	// Line totals of unchanged files are not re-read, so the previous total
+	// is kept
+	repo.FileCount = len(filesToIndex)
+	repo.TotalLines = previous.TotalLines
+	repo.Languages = i.languagesOf(filesToIndex)
+	repo.Modules = repositoryModules(repo.Path, filesToIndex)
+	repo.IndexingMode = "incremental"
+	repo.IndexedAt = time.Now()
+	if err := i.searcher.SetRepositoryRef(repo.ID, repo.Ref); err != nil {
+		return nil, err
+	}
+	i.rememberRepository(repo, nil)
+
+	run.FilesIndexed = result.FilesUpdated
+	run.FilesDeleted = result.FilesDeleted
+	run.FilesSkipped = result.FilesSkipped
+	run.FilesFailed = result.FilesFailed
+	result.ElapsedSeconds = time.Since(startTime).Seconds()
+
+	progress.Status = "completed"
+	completedAt := time.Now()
+	progress.CompletedAt = &completedAt
+	progress.ElapsedSeconds = result.ElapsedSeconds
+	reportProgress(ctx, progress)
+
+	i.logger.Info("Incremental indexing completed",
+		zap.String("repository", repo.Name),
+		zap.Int("commits", result.Commits),
+		zap.Int("files_updated", result.FilesUpdated),
+		zap.Int("files_deleted", result.FilesDeleted),
+		zap.Int("files_skipped", result.FilesSkipped),
+		zap.Int("documents_updated", result.DocumentsUpdated),
+		zap.Int("documents_skipped", result.DocumentsSkipped))
+
+	return result, nil
+}
+
+// SwitchRef checks out another branch, tag or commit in the working copy of
+// a repository, given by name or ID, and brings its index up to date by
+// re-indexing only the files that differ between the commit indexed last
+// and the one checked out. The ref is stored with the repository settings,
+// so later incremental runs stay on it. Only clones the indexer made, of a
+// URL or of a repository indexed at a ref, can be switched.
+func (i *Indexer) SwitchRef(ctx context.Context, repository, ref string) (*types.IncrementalIndexResult, error) {
+	repo, ok := i.IndexedRepository(repository)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotIndexed, repository)
+	}
+	if repo.URL == "" {
+		return nil, fmt.Errorf("%w: %s, index it with a ref to get a clone that can be switched", ErrLocalWorkingCopy, repo.Name)
+	}
+
+	i.repositoriesMutex.Lock()
+	settings := i.settings[repo.ID]
+	previousRef := settings.Ref
+	settings.Ref = ref
+	i.settings[repo.ID] = settings
+	i.repositoriesMutex.Unlock()
+
+	i.logger.Info("Switching ref",
+		zap.String("repository", repo.Name),
+		zap.String("from_ref", previousRef),
+		zap.String("to_ref", ref))
+
+	result, err := i.indexIncremental(ctx, types.IncrementalIndexRequest{RepositoryID: repo.ID}, true)
+	if err != nil {
+		// The index still holds the previous ref
+		i.repositoriesMutex.Lock()
+		settings.Ref = previousRef
+		i.settings[repo.ID] = settings
+		i.repositoriesMutex.Unlock()
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReadIndexedFile reads a file of an indexed repository from disk by its
+// slash-separated path relative to the repository. The search engine uses it
+// to load content the index does not store.
+func (i *Indexer) ReadIndexedFile(repositoryID, relativePath string) ([]byte, error) {
+	repo, ok := i.IndexedRepository(repositoryID)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotIndexed, repositoryID)
+	}
+	filePath := filepath.Join(repo.Path, filepath.FromSlash(relativePath))
+	if !strings.HasPrefix(filePath, filepath.Clean(repo.Path)+string(filepath.Separator)) {
+		return nil, fmt.Errorf("path %s is outside repository %s", relativePath, repo.Name)
+	}
+	return i.repoMgr.GetFileContent(filePath)
+}
+
+// ReindexFiles re-indexes files of an indexed repository that changed on
+// disk, such as files rewritten by a refactoring, without waiting for them
+// to be committed. Paths are absolute. It returns how many files were
+// re-indexed; files that fail are logged and skipped.
+func (i *Indexer) ReindexFiles(ctx context.Context, repository string, filePaths []string) (int, error) {
+	repo, ok := i.IndexedRepository(repository)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrNotIndexed, repository)
+	}
+	ctx, release, err := i.lockRepository(ctx, repo.Path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock repository: %w", err)
+	}
+	defer release()
+
+	relativePaths := make([]string, 0, len(filePaths))
+	changed := make(map[string]string, len(filePaths))
+	for _, filePath := range filePaths {
+		relativePath, err := i.repoMgr.GetRelativePath(filePath, repo.Path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get relative path of %s: %w", filePath, err)
+		}
+		relativePaths = append(relativePaths, relativePath)
+		changed[relativePath] = filePath
+	}
+
+	// Reference counts stay repository-wide; only the given files are
+	// counted again
+	refs, err := i.updateReferences(ctx, repo, changed, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	i.forgetFiles(repo.ID, relativePaths...)
+	if _, err := i.searcher.DeleteFiles(ctx, repo.ID, relativePaths); err != nil {
+		return 0, err
+	}
+
+	reindexed := 0
+	err = i.indexFiles(ctx, repo, i.repositoryChunker(repo.ID), filePaths, refs, phaseTimer{}, func(filePath string, file *types.CodeFile, err error) {
+		if err != nil {
+			i.logger.Warn("Failed to index file",
+				zap.String("file", filePath),
+				zap.Error(err))
+			return
+		}
+		reindexed++
+	})
+	if err != nil {
+		return reindexed, err
+	}
+	if _, err := i.reindexOutdatedReferences(ctx, repo, i.repositoryChunker(repo.ID), refs, phaseTimer{}); err != nil {
+		return reindexed, err
+	}
+
+	if i.embeddings != nil {
+		if err := i.embeddings.Save(); err != nil {
+			i.logger.Warn("Failed to save embeddings", zap.String("repo_id", repo.ID), zap.Error(err))
+		}
+	}
+
+	i.logger.Info("Files re-indexed",
+		zap.String("repository", repo.Name),
+		zap.Int("files", reindexed))
+
+	return reindexed, nil
+}
+
+// rebuild removes everything indexed for a repository and indexes it again
+// from scratch
+func (i *Indexer) rebuild(ctx context.Context, previous *types.Repository, source, fromCommit, reason string) (*types.IncrementalIndexResult, error) {
+	i.logger.Info("Falling back to full re-index",
+		zap.String("repository", previous.Name),
+		zap.String("reason", reason))
+
+	startTime := time.Now()
+	if err := i.searcher.DeleteRepository(ctx, previous.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete existing repository data: %w", err)
+	}
+	if i.embeddings != nil {
+		i.embeddings.DeleteRepository(previous.ID)
+	}
+	i.forgetFiles(previous.ID)
+
+	settings, _ := i.RepositorySettings(previous.ID)
+	settings.Source = source
+	settings.Name = previous.Name
+	repo, err := i.IndexRepositoryWithSettings(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	documents, err := i.searcher.CountDocuments(ctx, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.IncrementalIndexResult{
+		RepositoryID:     repo.ID,
+		Repository:       repo.Name,
+		Mode:             "full",
+		FallbackReason:   reason,
+		FromCommit:       fromCommit,
+		ToCommit:         repo.LastIndexedHash,
+		FilesUpdated:     repo.FileCount,
+		DocumentsUpdated: documents,
+		ElapsedSeconds:   time.Since(startTime).Seconds(),
+	}
+	if runs := i.IndexingHistory(repo.ID, 1); len(runs) > 0 {
+		result.FilesUpdated = runs[0].FilesIndexed
+		result.FilesFailed = runs[0].FilesFailed
+	}
+	return result, nil
+}
+
+// languagesOf returns the languages of a set of files
+func (i *Indexer) languagesOf(files []string) []string {
+	seen := make(map[string]bool)
+	languages := []string{}
+	for _, filePath := range files {
+		language := i.repoMgr.GetFileLanguage(filePath)
+		if language != repository.UnknownLanguage && !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages)
+	return languages
+}
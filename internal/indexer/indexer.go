@@ -2,20 +2,21 @@ package indexer
 
 import (
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"io/fs"
 	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 
 	"github.com/my-mcp/code-indexer/internal/chunking"
 	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/embeddings"
 	"github.com/my-mcp/code-indexer/internal/parser"
 	"github.com/my-mcp/code-indexer/internal/repository"
 	"github.com/my-mcp/code-indexer/internal/search"
+	"github.com/my-mcp/code-indexer/internal/secrets"
 	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
@@ -26,218 +27,471 @@ type Indexer struct {
 	searcher   *search.Engine
 	parser     *parser.Registry
 	chunker    *chunking.Chunker
+	embeddings *embeddings.Index // nil unless embeddings are enabled
+	secrets    *secrets.Scanner  // nil unless secret scanning is enabled
+	locker     RepositoryLocker  // nil unless repository locks are enabled
+	onChange   ChangeListener    // nil unless OnRepositoryChange was called
 	logger     *zap.Logger
+
+	// Indexing run history keyed by repository name
+	history      map[string][]*types.IndexingRun
+	historyMutex sync.RWMutex
+
+	// Repositories indexed by this indexer keyed by ID, with the commit
+	// they were last indexed at, their settings, the state of their indexed
+	// files keyed by relative path, and the workspaces grouping them keyed
+	// by name. Guarded by the same mutex.
+	repositories      map[string]*types.Repository
+	settings          map[string]types.RepositorySettings
+	files             map[string]map[string]types.FileState
+	workspaces        map[string]types.Workspace
+	repositoriesMutex sync.RWMutex
+
+	// File the repository metadata is persisted to; empty keeps it in
+	// memory only
+	metadataPath  string
+	metadataMutex sync.Mutex
+
+	// Reference counts of the indexed repositories keyed by ID, updated
+	// file by file on incremental runs
+	references      map[string]*repositoryReferences
+	referencesMutex sync.Mutex
 }
 
 // New creates a new indexer instance
 func New(cfg *config.Config, repoMgr *repository.Manager, searcher *search.Engine, logger *zap.Logger) (*Indexer, error) {
-	// Initialize chunker with default config for now
-	chunkingConfig := chunking.DefaultChunkingConfig()
+	chunker, err := newChunker(cfg.Indexer.Chunking, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	return &Indexer{
+	indexer := &Indexer{
 		config:   cfg,
 		repoMgr:  repoMgr,
 		searcher: searcher,
 		parser:   parser.NewRegistry(),
-		chunker:  chunking.NewChunker(chunkingConfig),
+		chunker:  chunker,
 		logger:   logger,
-	}, nil
+		history:  make(map[string][]*types.IndexingRun),
+
+		repositories: make(map[string]*types.Repository),
+		settings:     make(map[string]types.RepositorySettings),
+		files:        make(map[string]map[string]types.FileState),
+		workspaces:   make(map[string]types.Workspace),
+		references:   make(map[string]*repositoryReferences),
+	}
+	for name, repositories := range cfg.Search.Workspaces {
+		indexer.workspaces[name] = types.Workspace{Name: name, Repositories: repositories}
+	}
+	if cfg.Secrets.Enabled {
+		indexer.secrets = secrets.NewScanner(cfg.Secrets)
+	}
+	return indexer, nil
+}
+
+// EnableEmbeddings embeds the chunks of every file indexed from now on
+func (i *Indexer) EnableEmbeddings(index *embeddings.Index) {
+	i.embeddings = index
+}
+
+// ChangeListener is told about a repository whose index changed: after it
+// was indexed, re-indexed or had its metadata updated, or, with removed set,
+// after it was removed
+type ChangeListener func(repo types.Repository, removed bool)
+
+// OnRepositoryChange calls listener after every change to the index of a
+// repository. Only one listener is kept; it is called on the goroutine that
+// made the change and must not block.
+func (i *Indexer) OnRepositoryChange(listener ChangeListener) {
+	i.onChange = listener
+}
+
+// notifyChange tells the listener, if any, that the index of repo changed
+func (i *Indexer) notifyChange(repo types.Repository, removed bool) {
+	if i.onChange != nil {
+		i.onChange(repo, removed)
+	}
 }
 
-// IndexRepository indexes a complete repository
+// IndexRepository indexes a complete repository. Every run, successful or
+// not, is recorded with per-phase timings in the indexing history.
 func (i *Indexer) IndexRepository(ctx context.Context, path, name string) (*types.Repository, error) {
-	i.logger.Info("Starting repository indexing", zap.String("path", path), zap.String("name", name))
+	return i.IndexRepositoryWithSettings(ctx, types.RepositorySettings{Source: path, Name: name})
+}
+
+// IndexRepositoryWithSettings indexes a complete repository like
+// IndexRepository, applying the file filtering, chunking and clone
+// overrides of settings and checking out its ref, if any, see
+// repository.Manager.PrepareRepositoryWithOptions.
+// The settings are stored so later runs on the repository apply them too.
+func (i *Indexer) IndexRepositoryWithSettings(ctx context.Context, settings types.RepositorySettings) (repo *types.Repository, err error) {
+	path, name := settings.Source, settings.Name
+	i.logger.Info("Starting repository indexing", zap.String("path", path), zap.String("name", name), zap.String("ref", settings.Ref))
+
+	run := &types.IndexingRun{
+		Repository: name,
+		Path:       path,
+		Mode:       "full",
+		StartedAt:  time.Now(),
+	}
+	if run.Repository == "" {
+		run.Repository = path
+	}
+	timer := phaseTimer{}
+	defer func() {
+		i.finishRun(run, timer, err)
+	}()
+
+	startTime := time.Now()
+	progress := &types.IndexingProgress{
+		Repository: run.Repository,
+		Status:     "starting",
+		StartedAt:  startTime,
+	}
 
 	// Prepare the repository (clone if remote, validate if local)
-	repo, err := i.repoMgr.PrepareRepository(ctx, path, name)
+	phaseStart := time.Now()
+	repo, err = i.repoMgr.PrepareRepositoryWithOptions(withCloneProgress(ctx, progress), path, name, settings.Ref, cloneOptions(settings.Clone))
+	timer.since(PhasePrepare, phaseStart)
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare repository: %w", err)
 	}
+	run.RepositoryID = repo.ID
+	run.Repository = repo.Name
+	repo.Namespace = settings.Namespace
 
-	// Start indexing process
-	startTime := time.Now()
-	progress := &types.IndexingProgress{
-		RepositoryID: repo.ID,
-		Repository:   repo.Name,
-		Status:       "starting",
-		StartedAt:    startTime,
+	ctx, release, err := i.lockRepository(ctx, repo.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock repository: %w", err)
 	}
+	defer release()
+
+	// Start indexing process
+	progress.RepositoryID = repo.ID
+	progress.Repository = repo.Name
+	progress.Status = "starting"
+	progress.Message = ""
 
 	i.logger.Info("Repository prepared, starting file discovery", zap.String("repo_id", repo.ID))
 
 	// Discover files to index
 	var filesToIndex []string
-	err = i.repoMgr.WalkFiles(ctx, repo.Path, func(filePath string, info fs.FileInfo) error {
+	phaseStart = time.Now()
+	err = i.repoMgr.WalkFiles(ctx, repo.Path, i.fileFilter(settings.Filter), func(filePath string, info fs.FileInfo) error {
 		// Check if file should be indexed
 		if i.shouldIndexFile(filePath, info) {
 			filesToIndex = append(filesToIndex, filePath)
 		}
 		return nil
 	})
+	timer.since(PhaseWalk, phaseStart)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to discover files: %w", err)
 	}
 
-	progress.TotalFiles = len(filesToIndex)
+	// Files whose size and content hash are unchanged since the repository
+	// was last indexed keep their documents; the documents of changed
+	// files are dropped first, so symbols removed from them do not linger
+	filesToParse := filesToIndex
+	var changedFiles map[string]string
+	var totalLines int
+	phaseStart = time.Now()
+	recorded, err := i.indexedFiles(ctx, repo.ID, settings)
+	if err != nil {
+		return nil, err
+	}
+	if recorded != nil {
+		changes, err := i.compareFiles(ctx, repo.Path, filesToIndex, recorded)
+		if err != nil {
+			return nil, err
+		}
+		filesToParse = changes.changed
+		totalLines = changes.lines
+		run.FilesSkipped = changes.unchanged
+		run.FilesDeleted = len(changes.deleted)
+
+		stale := changes.deleted
+		changedFiles = make(map[string]string, len(changes.changed)+len(changes.deleted))
+		for _, relativePath := range changes.deleted {
+			changedFiles[relativePath] = ""
+		}
+		for _, filePath := range changes.changed {
+			if relativePath, err := i.repoMgr.GetRelativePath(filePath, repo.Path); err == nil {
+				stale = append(stale, relativePath)
+				changedFiles[relativePath] = filePath
+			}
+		}
+		i.forgetFiles(repo.ID, stale...)
+		if _, err := i.searcher.DeleteFiles(ctx, repo.ID, stale); err != nil {
+			return nil, err
+		}
+		if i.embeddings != nil {
+			for _, relativePath := range changes.deleted {
+				i.embeddings.DeleteFile(fmt.Sprintf("%s:%s", repo.ID, relativePath))
+			}
+		}
+	}
+	timer.since(PhaseWalk, phaseStart)
+
+	progress.TotalFiles = len(filesToParse)
+
+	// Count symbol references across the repository before indexing so
+	// every symbol document carries its popularity. The counts kept since
+	// the last run only need the changed files to be counted again.
+	phaseStart = time.Now()
+	var refs referenceCounts
+	if changedFiles != nil {
+		refs, err = i.updateReferences(ctx, repo, changedFiles, filesToIndex)
+	} else {
+		var counted *repositoryReferences
+		if counted, err = i.countReferences(ctx, repo.Path, filesToIndex); err == nil {
+			i.rememberReferences(repo.ID, counted)
+			refs = counted.totals.clone()
+		}
+	}
+	timer.since(PhaseReferences, phaseStart)
+	if err != nil {
+		return nil, err
+	}
+
 	progress.Status = "indexing"
+	reportProgress(ctx, progress)
 
 	i.logger.Info("File discovery completed", 
 		zap.String("repo_id", repo.ID),
-		zap.Int("total_files", len(filesToIndex)))
-
-	// Index each file
-	var totalLines int
-	languages := make(map[string]bool)
+		zap.Int("total_files", len(filesToIndex)),
+		zap.Int("unchanged_files", run.FilesSkipped))
 
-	for _, filePath := range filesToIndex {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
+	// Parse files in parallel and index them in batches
+	indexStart := time.Now()
 
+	chunker := i.chunkerFor(settings.Chunking)
+	err = i.indexFiles(ctx, repo, chunker, filesToParse, refs, timer, func(filePath string, file *types.CodeFile, err error) {
 		progress.FilesProcessed++
 		progress.CurrentFile = filePath
+		reportProgress(ctx, progress)
 
-		// Index the file
-		lines, err := i.indexFile(ctx, filePath, repo)
 		if err != nil {
-			i.logger.Warn("Failed to index file", 
-				zap.String("file", filePath), 
+			i.logger.Warn("Failed to index file",
+				zap.String("file", filePath),
 				zap.Error(err))
-			continue
+			run.FilesFailed++
+			return
 		}
 
-		totalLines += lines
-		
-		// Track language
-		language := i.repoMgr.GetFileLanguage(filePath)
-		if language != "unknown" {
-			languages[language] = true
-		}
+		run.FilesIndexed++
+		totalLines += file.Lines
 
 		// Log progress periodically
 		if progress.FilesProcessed%100 == 0 {
-			i.logger.Info("Indexing progress", 
+			i.logger.Info("Indexing progress",
 				zap.String("repo_id", repo.ID),
 				zap.Int("processed", progress.FilesProcessed),
 				zap.Int("total", progress.TotalFiles))
 		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	filesPerSecond := throughput(run.FilesIndexed, time.Since(indexStart))
+	run.FilesPerSecond = filesPerSecond
+
+	// Unchanged files keep their documents, but the changed files may use
+	// their symbols more or less often than before
+	if changedFiles != nil {
+		recounted, err := i.reindexOutdatedReferences(ctx, repo, chunker, refs, timer)
+		if err != nil {
+			return nil, err
+		}
+		i.logger.Debug("Re-indexed files with outdated reference counts",
+			zap.String("repo_id", repo.ID),
+			zap.Int("files", recounted))
+	}
+
+	if i.embeddings != nil {
+		if err := i.embeddings.Save(); err != nil {
+			i.logger.Warn("Failed to save embeddings", zap.String("repo_id", repo.ID), zap.Error(err))
+		}
 	}
 
 	// Update repository statistics
 	repo.FileCount = len(filesToIndex)
 	repo.TotalLines = totalLines
-	repo.Languages = make([]string, 0, len(languages))
-	for lang := range languages {
-		repo.Languages = append(repo.Languages, lang)
-	}
+	repo.Languages = i.languagesOf(filesToIndex)
+	repo.Modules = repositoryModules(repo.Path, filesToIndex)
 	repo.IndexedAt = time.Now()
+	if err := i.searcher.SetRepositoryRef(repo.ID, repo.Ref); err != nil {
+		return nil, err
+	}
+	if err := i.searcher.SetRepositoryNamespace(repo.ID, repo.Namespace); err != nil {
+		return nil, err
+	}
+	i.rememberRepository(repo, &settings)
 
 	// Complete indexing
 	progress.Status = "completed"
 	completedAt := time.Now()
 	progress.CompletedAt = &completedAt
 	progress.ElapsedSeconds = completedAt.Sub(startTime).Seconds()
+	progress.FilesPerSecond = filesPerSecond
+	reportProgress(ctx, progress)
+	run.TotalLines = totalLines
 
 	i.logger.Info("Repository indexing completed", 
 		zap.String("repo_id", repo.ID),
 		zap.String("repo_name", repo.Name),
 		zap.Int("files_indexed", repo.FileCount),
+		zap.Int("files_parsed", run.FilesIndexed),
+		zap.Int("files_skipped", run.FilesSkipped),
 		zap.Int("total_lines", repo.TotalLines),
 		zap.Strings("languages", repo.Languages),
+		zap.Float64("files_per_second", filesPerSecond),
 		zap.Duration("elapsed", completedAt.Sub(startTime)))
 
 	return repo, nil
 }
 
-// indexFile indexes a single file
-func (i *Indexer) indexFile(ctx context.Context, filePath string, repo *types.Repository) (int, error) {
-	// Read file content
-	content, err := i.repoMgr.GetFileContent(filePath)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read file content: %w", err)
-	}
+// ParseContent parses file contents that do not have to match what is on
+// disk, such as unsaved editor buffers
+func (i *Indexer) ParseContent(filePath, content string) (*types.CodeFile, error) {
+	language := i.repoMgr.DetectLanguage(filePath, []byte(content))
+	return i.parser.ParseFile(content, filePath, language)
+}
 
-	// Get relative path
-	relativePath, err := i.repoMgr.GetRelativePath(filePath, repo.Path)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get relative path: %w", err)
-	}
+// ParseSnippet parses code of a language that does not come from a file,
+// such as code passed to a tool
+func (i *Indexer) ParseSnippet(content, language string) (*types.CodeFile, error) {
+	return i.parser.ParseFile(content, "", language)
+}
 
-	// Determine language
-	language := i.repoMgr.GetFileLanguage(filePath)
+// SearchRegex runs a regular expression search over the indexed file
+// contents
+func (i *Indexer) SearchRegex(ctx context.Context, query types.SearchQuery) (*types.RegexSearchResult, error) {
+	return i.searcher.SearchRegex(ctx, query)
+}
 
-	// Create file hash for change detection
-	hasher := sha256.New()
-	hasher.Write(content)
-	fileHash := fmt.Sprintf("%x", hasher.Sum(nil))
+// ParserImplementations returns the parser implementation used per language
+func (i *Indexer) ParserImplementations() map[string]string {
+	return i.parser.Implementations()
+}
 
-	// Create code file structure
-	codeFile := &types.CodeFile{
-		ID:           fmt.Sprintf("%s:%s", repo.ID, relativePath),
-		RepositoryID: repo.ID,
-		Path:         filePath,
-		RelativePath: relativePath,
-		Language:     language,
-		Extension:    filepath.Ext(filePath),
-		Size:         int64(len(content)),
-		Content:      string(content),
-		Hash:         fileHash,
-		IndexedAt:    time.Now(),
+// fileFilter returns the filter WalkFiles applies to the files of a
+// repository: the configured one, with the overrides of settings if any
+func (i *Indexer) fileFilter(settings *types.FileFilterSettings) *repository.FileFilter {
+	filter := &repository.FileFilter{
+		MaxFileSize:     i.config.Indexer.MaxFileSize,
+		IncludePatterns: i.config.Indexer.IncludePatterns,
+		ExcludePatterns: i.config.Indexer.ExcludePatterns,
+		SkipDirs:        i.config.Indexer.SkipDirs,
+		SkipBinary:      i.config.Indexer.SkipBinary,
+	}
+	if settings == nil {
+		return filter
+	}
+	if settings.MaxFileSize > 0 {
+		filter.MaxFileSize = settings.MaxFileSize
 	}
+	if len(settings.IncludePatterns) > 0 {
+		filter.IncludePatterns = settings.IncludePatterns
+	}
+	if len(settings.ExcludePatterns) > 0 {
+		filter.ExcludePatterns = settings.ExcludePatterns
+	}
+	return filter
+}
 
-	// Parse the file to extract metadata
-	parsedFile, err := i.parser.ParseFile(string(content), filePath, language)
-	if err != nil {
-		i.logger.Warn("Failed to parse file", 
-			zap.String("file", filePath), 
-			zap.String("language", language),
-			zap.Error(err))
-		// Continue with basic file info even if parsing fails
-	} else {
-		// Copy parsed metadata
-		codeFile.Lines = parsedFile.Lines
-		codeFile.Functions = parsedFile.Functions
-		codeFile.Classes = parsedFile.Classes
-		codeFile.Variables = parsedFile.Variables
-		codeFile.Imports = parsedFile.Imports
-		codeFile.Comments = parsedFile.Comments
+// cloneOptions returns the clone options of a repository indexed with
+// settings; unset ones are taken from the configured defaults when cloning
+func cloneOptions(settings *types.CloneSettings) repository.CloneOptions {
+	if settings == nil {
+		return repository.CloneOptions{}
 	}
+	return repository.CloneOptions{
+		Depth:          settings.Depth,
+		SingleBranch:   settings.SingleBranch,
+		SparsePatterns: settings.SparsePatterns,
+	}
+}
 
-	// If parsing failed, at least count lines
-	if codeFile.Lines == 0 {
-		codeFile.Lines = strings.Count(string(content), "\n") + 1
+// newChunker returns a chunker splitting files as configured, with the
+// overrides of settings if any
+func newChunker(cfg config.ChunkingConfig, settings *types.ChunkingSettings) (*chunking.Chunker, error) {
+	chunkingConfig := chunking.DefaultChunkingConfig()
+	if cfg.Strategy != "" {
+		chunkingConfig.Strategy = chunking.ChunkingStrategy(cfg.Strategy)
+	}
+	if cfg.MaxChunkLines > 0 {
+		chunkingConfig.MaxChunkLines = cfg.MaxChunkLines
+	}
+	chunkingConfig.MinChunkLines = cfg.MinChunkLines
+	chunkingConfig.OverlapLines = cfg.OverlapLines
+	chunkingConfig.MaxChunkTokens = cfg.MaxChunkTokens
+	tokenizer, err := chunking.NewTokenizer(cfg.Tokenizer)
+	if err != nil {
+		return nil, err
 	}
+	chunkingConfig.Tokenizer = tokenizer
 
-	// Create semantic chunks for the file
-	chunks := i.chunker.ChunkFile(codeFile)
-	codeFile.Chunks = chunks
+	if settings != nil {
+		if settings.Strategy != "" {
+			chunkingConfig.Strategy = chunking.ChunkingStrategy(settings.Strategy)
+		}
+		if settings.MaxChunkLines > 0 {
+			chunkingConfig.MaxChunkLines = settings.MaxChunkLines
+		}
+		if settings.MaxChunkTokens > 0 {
+			chunkingConfig.MaxChunkTokens = settings.MaxChunkTokens
+		}
+	}
+	if chunkingConfig.OverlapLines >= chunkingConfig.MaxChunkLines {
+		chunkingConfig.OverlapLines = chunkingConfig.MaxChunkLines - 1
+	}
+	return chunking.NewChunker(chunkingConfig), nil
+}
 
-	// Index the file in the search engine
-	if err := i.searcher.IndexFile(ctx, codeFile, repo); err != nil {
-		return 0, fmt.Errorf("failed to index file in search engine: %w", err)
+// chunkerFor returns the chunker of a repository indexed with settings: the
+// configured one, or one with the overrides of settings if any
+func (i *Indexer) chunkerFor(settings *types.ChunkingSettings) *chunking.Chunker {
+	if settings == nil {
+		return i.chunker
 	}
+	chunker, err := newChunker(i.config.Indexer.Chunking, settings)
+	if err != nil {
+		return i.chunker
+	}
+	return chunker
+}
 
-	return codeFile.Lines, nil
+// repositoryChunker returns the chunker of an indexed repository, given by
+// ID, with the overrides it was indexed with
+func (i *Indexer) repositoryChunker(repositoryID string) *chunking.Chunker {
+	i.repositoriesMutex.RLock()
+	settings := i.settings[repositoryID]
+	i.repositoriesMutex.RUnlock()
+	return i.chunkerFor(settings.Chunking)
 }
 
-// shouldIndexFile determines if a file should be indexed
+// repositoryFilter returns the file filter of an indexed repository, given
+// by ID, with the overrides it was indexed with
+func (i *Indexer) repositoryFilter(repositoryID string) *repository.FileFilter {
+	i.repositoriesMutex.RLock()
+	settings := i.settings[repositoryID]
+	i.repositoriesMutex.RUnlock()
+	return i.fileFilter(settings.Filter)
+}
+
+// shouldIndexFile determines if a file should be indexed. Size limits and
+// patterns are applied by the repository's file filter while walking.
 func (i *Indexer) shouldIndexFile(filePath string, info fs.FileInfo) bool {
 	// Skip directories
 	if info.IsDir() {
 		return false
 	}
 
-	// Check file size limit
-	if info.Size() > i.config.Indexer.MaxFileSize {
-		return false
-	}
-
 	// Check if file extension is supported
 	ext := filepath.Ext(filePath)
-	supportedExts := []string{".go", ".py", ".js", ".ts", ".java", ".cpp", ".c", ".h", ".rs", ".rb", ".php", ".cs", ".kt", ".swift", ".scala", ".md", ".txt", ".json", ".yaml", ".yml", ".xml", ".html", ".css", ".sql"}
+	supportedExts := []string{".go", ".py", ".js", ".ts", ".java", ".cpp", ".c", ".h", ".rs", ".rb", ".php", ".cs", ".kt", ".swift", ".scala", ".md", ".txt", ".json", ".yaml", ".yml", ".xml", ".html", ".css", ".sql", ".proto"}
 	supported := false
 	for _, supportedExt := range supportedExts {
 		if ext == supportedExt {
@@ -245,38 +499,37 @@ func (i *Indexer) shouldIndexFile(filePath string, info fs.FileInfo) bool {
 			break
 		}
 	}
-	if !supported {
-		return false
-	}
-
-	// Check exclude patterns
-	for _, pattern := range i.config.Indexer.ExcludePatterns {
-		if matched, _ := filepath.Match(pattern, filePath); matched {
-			return false
-		}
-	}
-
-	return true
+	return supported
 }
 
-// ReindexRepository removes and re-indexes a repository
+// ReindexRepository removes and re-indexes a repository, given by name or
+// ID, from the source it was last indexed from
 func (i *Indexer) ReindexRepository(ctx context.Context, repositoryID string) error {
 	i.logger.Info("Starting repository re-indexing", zap.String("repo_id", repositoryID))
 
+	settings, ok := i.RepositorySettings(repositoryID)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotIndexed, repositoryID)
+	}
+	repo, _ := i.IndexedRepository(repositoryID)
+	ctx, release, err := i.lockRepository(ctx, repo.Path)
+	if err != nil {
+		return fmt.Errorf("failed to lock repository: %w", err)
+	}
+	defer release()
+
 	// Delete existing index data for this repository
-	if err := i.searcher.DeleteRepository(ctx, repositoryID); err != nil {
+	if err := i.searcher.DeleteRepository(ctx, repo.ID); err != nil {
 		return fmt.Errorf("failed to delete existing repository data: %w", err)
 	}
+	if i.embeddings != nil {
+		i.embeddings.DeleteRepository(repo.ID)
+	}
+	i.forgetFiles(repo.ID)
 
-	// TODO: Re-index the repository
-	// This would require storing repository paths/URLs in a persistent store
-	// For now, return an error indicating this feature needs implementation
-	return fmt.Errorf("re-indexing requires repository path information - not yet implemented")
+	if _, err := i.IndexRepositoryWithSettings(ctx, settings); err != nil {
+		return fmt.Errorf("failed to re-index repository: %w", err)
+	}
+	return nil
 }
 
-// GetIndexingProgress returns the current indexing progress (if any)
-// This is a placeholder for future implementation of async indexing with progress tracking
-func (i *Indexer) GetIndexingProgress(repositoryID string) (*types.IndexingProgress, error) {
-	// TODO: Implement progress tracking for async indexing
-	return nil, fmt.Errorf("progress tracking not yet implemented")
-}
@@ -0,0 +1,54 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/repository"
+	"github.com/my-mcp/code-indexer/internal/search"
+)
+
+// newTestIndexer returns an indexer over an in-memory index that may index
+// local repositories below the directory it returns
+func newTestIndexer(t *testing.T) (*Indexer, string) {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	root := t.TempDir()
+
+	repoMgr, err := repository.NewManager(filepath.Join(t.TempDir(), "repositories"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create repository manager: %v", err)
+	}
+	if err := repoMgr.AllowLocalRepositories(root); err != nil {
+		t.Fatalf("Failed to allow %s: %v", root, err)
+	}
+	searcher, err := search.NewMemoryEngine(cfg.SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create search engine: %v", err)
+	}
+	t.Cleanup(func() { searcher.Close() })
+
+	idx, err := New(cfg, repoMgr, searcher, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create indexer: %v", err)
+	}
+	return idx, root
+}
+
+// writeFiles writes files, given by slash-separated relative path, below dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create directory of %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+}
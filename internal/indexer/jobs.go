@@ -0,0 +1,269 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Indexing job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// maxFinishedJobs bounds the finished jobs kept so their outcome can still
+// be looked up; the oldest are forgotten first
+const maxFinishedJobs = 100
+
+var (
+	// ErrJobNotFound is returned for job IDs that are unknown or forgotten
+	ErrJobNotFound = errors.New("indexing job not found")
+
+	// ErrQueueFull is returned by Submit when every worker is busy and the
+	// queue holds as many waiting jobs as it can
+	ErrQueueFull = errors.New("indexing queue is full")
+
+	// ErrQueueClosed is returned by Submit after Close
+	ErrQueueClosed = errors.New("indexing queue is closed")
+
+	// ErrJobFinished is returned when cancelling a job that already ended
+	ErrJobFinished = errors.New("indexing job has already finished")
+)
+
+// JobQueue indexes repositories in the background with a fixed pool of
+// workers. Jobs wait in a bounded queue; each records the progress of its
+// run so it can be polled while it runs and looked up once it ends.
+type JobQueue struct {
+	indexer *Indexer
+	logger  *zap.Logger
+	queue   chan *job
+	ctx     context.Context // Parent of every job's context, cancelled by Close
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+
+	// Jobs by ID and the IDs of finished jobs, oldest first. Guarded by
+	// mutex, as is the state of every job.
+	jobs     map[string]*job
+	finished []string
+	closed   bool
+	mutex    sync.Mutex
+}
+
+// job is a queued or running indexing job with the settings it indexes with
+// and the context that cancels it
+type job struct {
+	types.IndexingJob
+	settings types.RepositorySettings
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewJobQueue starts workers goroutines indexing with indexer. At most
+// queueSize jobs wait for a free worker.
+func NewJobQueue(indexer *Indexer, workers, queueSize int, logger *zap.Logger) *JobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &JobQueue{
+		indexer: indexer,
+		logger:  logger,
+		queue:   make(chan *job, queueSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		jobs:    make(map[string]*job),
+	}
+	for n := 0; n < workers; n++ {
+		q.workers.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// Submit queues the repository of settings, a local path or Git URL, for
+// indexing with those settings and returns the new job
+func (q *JobQueue) Submit(settings types.RepositorySettings) (types.IndexingJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return types.IndexingJob{}, ErrQueueClosed
+	}
+
+	path, name := settings.Source, settings.Name
+	repository := name
+	if repository == "" {
+		repository = path
+	}
+	ctx, cancel := context.WithCancel(q.ctx)
+	j := &job{
+		IndexingJob: types.IndexingJob{
+			ID:       uuid.New().String(),
+			Path:     path,
+			Name:     name,
+			Status:   JobQueued,
+			QueuedAt: time.Now(),
+			Progress: types.IndexingProgress{
+				Repository: repository,
+				Status:     JobQueued,
+			},
+		},
+		settings: settings,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	select {
+	case q.queue <- j:
+	default:
+		cancel()
+		return types.IndexingJob{}, ErrQueueFull
+	}
+	q.jobs[j.ID] = j
+
+	q.logger.Info("Queued indexing job", zap.String("job_id", j.ID), zap.String("path", path))
+	return j.IndexingJob, nil
+}
+
+// Get returns the job with the given ID
+func (q *JobQueue) Get(id string) (types.IndexingJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return types.IndexingJob{}, ErrJobNotFound
+	}
+	return j.IndexingJob, nil
+}
+
+// Cancel stops the job with the given ID. A queued job is cancelled at once;
+// a running one stops at the next file and is marked cancelled when its run
+// returns.
+func (q *JobQueue) Cancel(id string) (types.IndexingJob, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return types.IndexingJob{}, ErrJobNotFound
+	}
+	if j.CompletedAt != nil {
+		return j.IndexingJob, ErrJobFinished
+	}
+
+	j.cancel()
+	if j.Status == JobQueued {
+		q.finish(j, nil, context.Canceled)
+	}
+	q.logger.Info("Cancelled indexing job", zap.String("job_id", id), zap.String("status", j.Status))
+	return j.IndexingJob, nil
+}
+
+// Close refuses new jobs, cancels queued and running ones and waits for
+// the workers to return
+func (q *JobQueue) Close() {
+	q.mutex.Lock()
+	if q.closed {
+		q.mutex.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.queue)
+	q.mutex.Unlock()
+
+	q.cancel()
+	q.workers.Wait()
+}
+
+// work runs queued jobs until the queue is closed and drained
+func (q *JobQueue) work() {
+	defer q.workers.Done()
+	for j := range q.queue {
+		q.run(j)
+	}
+}
+
+// run indexes the repository of a job, recording its progress
+func (q *JobQueue) run(j *job) {
+	defer j.cancel()
+
+	q.mutex.Lock()
+	if j.Status != JobQueued {
+		// Cancelled while it waited
+		q.mutex.Unlock()
+		return
+	}
+	if err := j.ctx.Err(); err != nil {
+		q.finish(j, nil, err)
+		q.mutex.Unlock()
+		return
+	}
+	startedAt := time.Now()
+	j.Status = JobRunning
+	j.StartedAt = &startedAt
+	j.Progress.Status = "starting"
+	j.Progress.StartedAt = startedAt
+	q.mutex.Unlock()
+
+	ctx := WithProgress(j.ctx, func(progress types.IndexingProgress) {
+		q.mutex.Lock()
+		j.Progress = progress
+		q.mutex.Unlock()
+	})
+	repo, err := q.indexer.IndexRepositoryWithSettings(ctx, j.settings)
+
+	q.mutex.Lock()
+	q.finish(j, repo, err)
+	q.mutex.Unlock()
+}
+
+// finish records the outcome of a job and forgets the oldest finished jobs
+// beyond maxFinishedJobs. The caller holds the mutex.
+func (q *JobQueue) finish(j *job, repo *types.Repository, err error) {
+	completedAt := time.Now()
+	j.CompletedAt = &completedAt
+	j.Repository = repo
+
+	switch {
+	case err == nil:
+		j.Status = JobCompleted
+	case j.ctx.Err() != nil:
+		j.Status = JobCancelled
+		j.Error = context.Canceled.Error()
+	default:
+		j.Status = JobFailed
+		j.Error = err.Error()
+	}
+
+	j.Progress.Status = j.Status
+	j.Progress.Error = j.Error
+	j.Progress.CompletedAt = &completedAt
+	if !j.Progress.StartedAt.IsZero() {
+		j.Progress.ElapsedSeconds = completedAt.Sub(j.Progress.StartedAt).Seconds()
+	}
+
+	q.finished = append(q.finished, j.ID)
+	for len(q.finished) > maxFinishedJobs {
+		delete(q.jobs, q.finished[0])
+		q.finished = q.finished[1:]
+	}
+
+	if j.Status == JobFailed {
+		q.logger.Warn("Indexing job failed", zap.String("job_id", j.ID), zap.Error(err))
+	}
+}
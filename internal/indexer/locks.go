@@ -0,0 +1,38 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// RepositoryLocker holds a repository, by its local path, until release is
+// called. It fails when the repository cannot be held, such as when another
+// operation holds it for longer than the lock timeout.
+type RepositoryLocker func(ctx context.Context, repoPath string) (release func(), err error)
+
+// lockedRepositoryKey is the context key of the repository path a run holds
+type lockedRepositoryKey struct{}
+
+// EnableRepositoryLocks holds every repository while it is indexed,
+// re-indexed or removed, so edits and other runs wait for the run to end
+func (i *Indexer) EnableRepositoryLocks(locker RepositoryLocker) {
+	i.locker = locker
+}
+
+// lockRepository holds a repository for the rest of a run. Runs nested in
+// one that already holds the repository, such as the full re-index an
+// incremental run falls back to, do not take it again.
+func (i *Indexer) lockRepository(ctx context.Context, repoPath string) (context.Context, func(), error) {
+	if i.locker == nil || repoPath == "" {
+		return ctx, func() {}, nil
+	}
+	repoPath = filepath.Clean(repoPath)
+	if ctx.Value(lockedRepositoryKey{}) == repoPath {
+		return ctx, func() {}, nil
+	}
+	release, err := i.locker(ctx, repoPath)
+	if err != nil {
+		return ctx, nil, err
+	}
+	return context.WithValue(ctx, lockedRepositoryKey{}, repoPath), release, nil
+}
@@ -0,0 +1,368 @@
+package indexer
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Reasons a repository is reported as orphaned
+const (
+	OrphanPathMissing = "path_missing"
+	OrphanNotInIndex  = "not_in_index"
+)
+
+// Problems VerifyIndex reports for a file
+const (
+	IssueModified = "modified" // The file changed since it was indexed
+	IssueDeleted  = "deleted"  // The file is gone or no longer indexed
+	IssueMissing  = "missing"  // The file is indexable but has no documents
+	IssueNoHash   = "no_hash"  // The file was indexed before hashes were recorded
+)
+
+// RemoveRepository deletes a repository, given by name or ID, from the
+// index together with its embeddings, reference counts, metadata record and
+// indexing history. With deleteClone the working copy is deleted as well
+// when the repository was cloned from a URL; local repositories are never
+// deleted.
+func (i *Indexer) RemoveRepository(ctx context.Context, repository string, deleteClone bool) (*types.RepositoryRemoval, error) {
+	repo, err := i.findRepository(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+	ctx, release, err := i.lockRepository(ctx, repo.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock repository: %w", err)
+	}
+	defer release()
+
+	documents, err := i.searcher.CountDocuments(ctx, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.searcher.DeleteRepository(ctx, repo.ID); err != nil {
+		return nil, fmt.Errorf("failed to delete repository data: %w", err)
+	}
+	if i.embeddings != nil {
+		i.embeddings.DeleteRepository(repo.ID)
+		if err := i.embeddings.Save(); err != nil {
+			i.logger.Warn("Failed to save embeddings", zap.String("repo_id", repo.ID), zap.Error(err))
+		}
+	}
+	i.forgetRepository(repo)
+
+	removal := &types.RepositoryRemoval{
+		RepositoryID:     repo.ID,
+		Repository:       repo.Name,
+		DocumentsRemoved: documents,
+	}
+	if deleteClone && repo.URL != "" && repo.Path != "" {
+		if err := i.repoMgr.RemoveClone(repo.Path); err != nil {
+			return removal, err
+		}
+		removal.CloneDeleted = repo.Path
+	}
+
+	i.logger.Info("Repository removed",
+		zap.String("repo_id", repo.ID),
+		zap.String("repo_name", repo.Name),
+		zap.Int("documents", documents),
+		zap.Bool("clone_deleted", removal.CloneDeleted != ""))
+	return removal, nil
+}
+
+// findRepository looks a repository up by name or ID, first among the
+// records of this indexer and then among the repositories in the index
+func (i *Indexer) findRepository(ctx context.Context, repository string) (*types.Repository, error) {
+	if repo, ok := i.IndexedRepository(repository); ok {
+		return repo, nil
+	}
+
+	indexed, err := i.searcher.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range indexed {
+		if repo.ID == repository || repo.Name == repository {
+			return &repo, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotIndexed, repository)
+}
+
+// forgetRepository drops the metadata record, settings, file states,
+// indexing history and reference counts of a repository and persists the
+// metadata
+func (i *Indexer) forgetRepository(repo *types.Repository) {
+	i.repositoriesMutex.Lock()
+	delete(i.repositories, repo.ID)
+	delete(i.settings, repo.ID)
+	delete(i.files, repo.ID)
+	i.repositoriesMutex.Unlock()
+
+	i.historyMutex.Lock()
+	delete(i.history, repo.Name)
+	i.historyMutex.Unlock()
+
+	i.referencesMutex.Lock()
+	delete(i.references, repo.ID)
+	i.referencesMutex.Unlock()
+
+	i.saveMetadata()
+	i.notifyChange(*repo, true)
+}
+
+// FindOrphans returns the repository records whose source is gone: local
+// repositories whose path no longer exists and records the index holds no
+// documents for. Cloned repositories are not reported when their working
+// copy is missing, as they are cloned again from their URL. Repositories
+// known only from the index carry no path and cannot be checked.
+func (i *Indexer) FindOrphans(ctx context.Context) ([]types.OrphanedRepository, error) {
+	indexed, err := i.searcher.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	inIndex := make(map[string]bool, len(indexed))
+	for _, repo := range indexed {
+		inIndex[repo.ID] = true
+	}
+
+	i.repositoriesMutex.RLock()
+	var orphans []types.OrphanedRepository
+	for _, repo := range i.repositories {
+		orphan := types.OrphanedRepository{
+			RepositoryID: repo.ID,
+			Repository:   repo.Name,
+			Path:         repo.Path,
+		}
+		switch {
+		case !inIndex[repo.ID]:
+			orphan.Reason = OrphanNotInIndex
+		case repo.URL == "" && !pathExists(repo.Path):
+			orphan.Reason = OrphanPathMissing
+		default:
+			continue
+		}
+		orphans = append(orphans, orphan)
+	}
+	i.repositoriesMutex.RUnlock()
+
+	sort.Slice(orphans, func(a, b int) bool {
+		return orphans[a].Repository < orphans[b].Repository
+	})
+	return orphans, nil
+}
+
+// CleanupOrphans removes the repositories FindOrphans reports, unless
+// dryRun is set, and returns them
+func (i *Indexer) CleanupOrphans(ctx context.Context, dryRun bool) ([]types.OrphanedRepository, error) {
+	orphans, err := i.FindOrphans(ctx)
+	if err != nil || dryRun {
+		return orphans, err
+	}
+
+	for idx := range orphans {
+		if _, err := i.RemoveRepository(ctx, orphans[idx].RepositoryID, false); err != nil {
+			return orphans, fmt.Errorf("failed to remove repository %s: %w", orphans[idx].Repository, err)
+		}
+		orphans[idx].Removed = true
+	}
+	return orphans, nil
+}
+
+// pathExists reports whether a path exists. Errors other than the path
+// missing count as existing, so an unreadable path is not cleaned up.
+func pathExists(path string) bool {
+	_, err := os.Stat(path)
+	return !os.IsNotExist(err)
+}
+
+// OptimizeIndex compacts the search index, see search.Engine.Optimize
+func (i *Indexer) OptimizeIndex(ctx context.Context) (*types.IndexOptimization, error) {
+	return i.searcher.Optimize(ctx)
+}
+
+// VerifyIndex compares the file documents of a repository, given by name or
+// ID, or of every repository when it is empty, with the files on disk. Files
+// whose content hash differs, files that are gone and indexable files
+// without documents are reported; with repair they are re-indexed or their
+// documents dropped. Repositories without a local copy are skipped.
+func (i *Indexer) VerifyIndex(ctx context.Context, repository string, repair bool) (*types.IndexVerification, error) {
+	var repositories []types.Repository
+	if repository != "" {
+		repo, err := i.findRepository(ctx, repository)
+		if err != nil {
+			return nil, err
+		}
+		repositories = []types.Repository{*repo}
+	} else {
+		var err error
+		if repositories, err = i.ListRepositories(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &types.IndexVerification{Issues: []types.IndexIssue{}}
+	for idx := range repositories {
+		repo := &repositories[idx]
+		if repo.Path == "" || !pathExists(repo.Path) {
+			result.RepositoriesSkipped = append(result.RepositoriesSkipped, repo.Name)
+			continue
+		}
+
+		issues, indexable, checked, err := i.verifyRepository(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify repository %s: %w", repo.Name, err)
+		}
+		result.RepositoriesChecked++
+		result.FilesChecked += checked
+		result.Issues = append(result.Issues, issues...)
+
+		if repair && len(issues) > 0 {
+			repaired, err := i.repairFiles(ctx, repo, issues, indexable)
+			result.FilesRepaired += repaired
+			if err != nil {
+				return result, fmt.Errorf("failed to repair repository %s: %w", repo.Name, err)
+			}
+		}
+	}
+
+	i.logger.Info("Index verified",
+		zap.Int("repositories", result.RepositoriesChecked),
+		zap.Int("files", result.FilesChecked),
+		zap.Int("issues", len(result.Issues)),
+		zap.Int("repaired", result.FilesRepaired))
+	return result, nil
+}
+
+// verifyRepository checks the file documents of one repository against its
+// files on disk. It returns the issues found, the indexable files keyed by
+// relative path and the number of files checked.
+func (i *Indexer) verifyRepository(ctx context.Context, repo *types.Repository) ([]types.IndexIssue, map[string]string, int, error) {
+	hashes, err := i.searcher.FileHashes(ctx, repo.ID)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	indexable := make(map[string]string)
+	err = i.repoMgr.WalkFiles(ctx, repo.Path, i.repositoryFilter(repo.ID), func(filePath string, info fs.FileInfo) error {
+		if i.shouldIndexFile(filePath, info) {
+			if relativePath, err := i.repoMgr.GetRelativePath(filePath, repo.Path); err == nil {
+				indexable[relativePath] = filePath
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to discover files: %w", err)
+	}
+
+	var issues []types.IndexIssue
+	issue := func(relativePath, problem string) *types.IndexIssue {
+		issues = append(issues, types.IndexIssue{
+			RepositoryID: repo.ID,
+			Repository:   repo.Name,
+			FilePath:     relativePath,
+			Problem:      problem,
+		})
+		return &issues[len(issues)-1]
+	}
+
+	for indexedPath, indexedHash := range hashes {
+		relativePath := filepath.ToSlash(indexedPath)
+		filePath, ok := indexable[relativePath]
+		if !ok {
+			issue(relativePath, IssueDeleted).IndexedHash = indexedHash
+			continue
+		}
+		if indexedHash == "" {
+			issue(relativePath, IssueNoHash)
+			continue
+		}
+		content, err := i.repoMgr.GetFileContent(filePath)
+		if err != nil {
+			issue(relativePath, IssueDeleted).IndexedHash = indexedHash
+			continue
+		}
+		if diskHash := fmt.Sprintf("%x", sha256.Sum256(content)); diskHash != indexedHash {
+			found := issue(relativePath, IssueModified)
+			found.IndexedHash = indexedHash
+			found.DiskHash = diskHash
+		}
+	}
+	for relativePath := range indexable {
+		if _, ok := hashes[relativePath]; !ok {
+			issue(relativePath, IssueMissing)
+		}
+	}
+
+	sort.Slice(issues, func(a, b int) bool {
+		return issues[a].FilePath < issues[b].FilePath
+	})
+	return issues, indexable, len(hashes), nil
+}
+
+// repairFiles re-indexes the files of a repository VerifyIndex reported and
+// drops the documents of those that are gone. It returns how many files
+// were repaired.
+func (i *Indexer) repairFiles(ctx context.Context, repo *types.Repository, issues []types.IndexIssue, indexable map[string]string) (int, error) {
+	changed := make(map[string]string, len(issues))
+	relativePaths := make([]string, 0, len(issues))
+	var updates []string
+	for _, issue := range issues {
+		filePath := ""
+		if issue.Problem != IssueDeleted {
+			filePath = indexable[issue.FilePath]
+		}
+		changed[issue.FilePath] = filePath
+		relativePaths = append(relativePaths, issue.FilePath)
+		if filePath != "" {
+			updates = append(updates, filePath)
+		} else if i.embeddings != nil {
+			i.embeddings.DeleteFile(fmt.Sprintf("%s:%s", repo.ID, issue.FilePath))
+		}
+	}
+
+	// Reference counts stay repository-wide; only the repaired files are
+	// counted again
+	refs, err := i.updateReferences(ctx, repo, changed, nil)
+	if err != nil {
+		return 0, err
+	}
+	i.forgetFiles(repo.ID, relativePaths...)
+	if _, err := i.searcher.DeleteFiles(ctx, repo.ID, relativePaths); err != nil {
+		return 0, err
+	}
+
+	repaired := len(relativePaths) - len(updates)
+	err = i.indexFiles(ctx, repo, i.repositoryChunker(repo.ID), updates, refs, phaseTimer{}, func(filePath string, file *types.CodeFile, err error) {
+		if err != nil {
+			i.logger.Warn("Failed to index file",
+				zap.String("file", filePath),
+				zap.Error(err))
+			return
+		}
+		repaired++
+	})
+	if err != nil {
+		return repaired, err
+	}
+	if _, err := i.reindexOutdatedReferences(ctx, repo, i.repositoryChunker(repo.ID), refs, phaseTimer{}); err != nil {
+		return repaired, err
+	}
+
+	if i.embeddings != nil {
+		if err := i.embeddings.Save(); err != nil {
+			i.logger.Warn("Failed to save embeddings", zap.String("repo_id", repo.ID), zap.Error(err))
+		}
+	}
+	return repaired, nil
+}
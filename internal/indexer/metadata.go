@@ -0,0 +1,217 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// metadataVersion is bumped whenever the persisted layout changes
+const metadataVersion = 1
+
+// metadataFile is the persisted form of the indexer's repository metadata:
+// the full repository records keyed by ID, the indexing history keyed by
+// repository name, the settings and indexed file states keyed by repository
+// ID and the workspaces keyed by name
+type metadataFile struct {
+	Version      int                                   `json:"version"`
+	Repositories map[string]*types.Repository          `json:"repositories"`
+	History      map[string][]*types.IndexingRun       `json:"history"`
+	Settings     map[string]types.RepositorySettings   `json:"settings"`
+	Files        map[string]map[string]types.FileState `json:"files,omitempty"`
+	Workspaces   map[string]types.Workspace            `json:"workspaces,omitempty"`
+}
+
+// EnableMetadataStore loads the repository metadata stored at path and keeps
+// it there from now on, so repository records, indexing history, settings,
+// the state of indexed files and workspaces survive restarts. Stored
+// workspaces replace configured ones of the same name. A missing file starts
+// an empty store.
+func (i *Indexer) EnableMetadataStore(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read repository metadata: %w", err)
+	}
+
+	if err == nil {
+		var persisted metadataFile
+		if err := json.Unmarshal(data, &persisted); err != nil {
+			return fmt.Errorf("failed to decode repository metadata %s: %w", path, err)
+		}
+		if persisted.Version != metadataVersion {
+			return fmt.Errorf("unsupported repository metadata version %d in %s", persisted.Version, path)
+		}
+
+		i.repositoriesMutex.Lock()
+		for id, repo := range persisted.Repositories {
+			i.repositories[id] = repo
+			// Repositories indexed by earlier runs stay open to the file tools
+			if err := i.repoMgr.RegisterRoot(repo.Path); err != nil {
+				i.logger.Warn("Failed to register repository root", zap.String("path", repo.Path), zap.Error(err))
+			}
+		}
+		for id, settings := range persisted.Settings {
+			i.settings[id] = settings
+		}
+		for id, files := range persisted.Files {
+			i.files[id] = files
+		}
+		for name, workspace := range persisted.Workspaces {
+			i.workspaces[name] = workspace
+		}
+		i.repositoriesMutex.Unlock()
+
+		i.historyMutex.Lock()
+		for name, runs := range persisted.History {
+			i.history[name] = runs
+		}
+		i.historyMutex.Unlock()
+	}
+
+	i.metadataMutex.Lock()
+	i.metadataPath = path
+	i.metadataMutex.Unlock()
+
+	i.logger.Info("Repository metadata store enabled",
+		zap.String("path", path),
+		zap.Int("repositories", len(i.repositories)))
+	return nil
+}
+
+// saveMetadata writes the repository metadata to the store, if enabled. The
+// file is replaced atomically so a crash never leaves a truncated store
+// behind. Failures are logged rather than failing the indexing run.
+func (i *Indexer) saveMetadata() {
+	i.metadataMutex.Lock()
+	defer i.metadataMutex.Unlock()
+
+	if i.metadataPath == "" {
+		return
+	}
+
+	i.repositoriesMutex.RLock()
+	i.historyMutex.RLock()
+	data, err := json.MarshalIndent(metadataFile{
+		Version:      metadataVersion,
+		Repositories: i.repositories,
+		History:      i.history,
+		Settings:     i.settings,
+		Files:        i.files,
+		Workspaces:   i.workspaces,
+	}, "", "  ")
+	i.historyMutex.RUnlock()
+	i.repositoriesMutex.RUnlock()
+
+	if err == nil {
+		err = writeFileAtomic(i.metadataPath, data)
+	}
+	if err != nil {
+		i.logger.Warn("Failed to save repository metadata", zap.String("path", i.metadataPath), zap.Error(err))
+	}
+}
+
+// writeFileAtomic replaces a file with data through a temporary file in the
+// same directory
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".repositories-*")
+	if err != nil {
+		return fmt.Errorf("failed to create metadata file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metadata file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace metadata file: %w", err)
+	}
+	return nil
+}
+
+// RepositorySettings returns the stored settings of a repository, looked up
+// by name or ID
+func (i *Indexer) RepositorySettings(repository string) (types.RepositorySettings, bool) {
+	repo, ok := i.IndexedRepository(repository)
+	if !ok {
+		return types.RepositorySettings{}, false
+	}
+
+	i.repositoriesMutex.RLock()
+	defer i.repositoriesMutex.RUnlock()
+	settings, ok := i.settings[repo.ID]
+	return settings, ok
+}
+
+// ListRepositories returns the indexed repositories ordered by name. The
+// search index decides which repositories exist; each is reported with its
+// full record when the indexer has one, and otherwise with what the index
+// documents tell, as for repositories indexed before the metadata store was
+// enabled.
+func (i *Indexer) ListRepositories(ctx context.Context) ([]types.Repository, error) {
+	indexed, err := i.searcher.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	i.repositoriesMutex.RLock()
+	repositories := make([]types.Repository, 0, len(indexed))
+	for _, repo := range indexed {
+		if stored, ok := i.repositories[repo.ID]; ok {
+			repo = *stored
+		}
+		repositories = append(repositories, repo)
+	}
+	i.repositoriesMutex.RUnlock()
+
+	sort.Slice(repositories, func(a, b int) bool {
+		if repositories[a].Name != repositories[b].Name {
+			return repositories[a].Name < repositories[b].Name
+		}
+		return repositories[a].ID < repositories[b].ID
+	})
+	return repositories, nil
+}
+
+// GetIndexStats returns the index statistics with the repository statistics
+// taken from ListRepositories. LastIndexed is the latest time any repository
+// was indexed, or zero if none is known.
+func (i *Indexer) GetIndexStats(ctx context.Context) (*types.IndexStats, error) {
+	stats, err := i.searcher.GetIndexStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	repositories, err := i.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.TotalRepositories = len(repositories)
+	stats.TotalLines = 0
+	stats.LastIndexed = time.Time{}
+	stats.RepositoryStats = make(map[string]types.Repository, len(repositories))
+	for _, repo := range repositories {
+		stats.RepositoryStats[repo.Name] = repo
+		stats.TotalLines += repo.TotalLines
+		if repo.IndexedAt.After(stats.LastIndexed) {
+			stats.LastIndexed = repo.IndexedAt
+		}
+	}
+	return stats, nil
+}
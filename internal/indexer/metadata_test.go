@@ -0,0 +1,75 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestMetadataStoreRoundTrip(t *testing.T) {
+	idx, root := newTestIndexer(t)
+	store := filepath.Join(t.TempDir(), "index.repositories.json")
+	if err := idx.EnableMetadataStore(store); err != nil {
+		t.Fatalf("EnableMetadataStore failed on a missing file: %v", err)
+	}
+
+	dir := filepath.Join(root, "app")
+	writeFiles(t, dir, map[string]string{
+		"main.go":     "package main\n\nfunc main() {}\n",
+		"util/str.go": "package util\n\nfunc Upper(s string) string { return s }\n",
+	})
+	ctx := context.Background()
+	repo, err := idx.IndexRepositoryWithSettings(ctx, types.RepositorySettings{Source: dir, Name: "app", Namespace: "team"})
+	if err != nil {
+		t.Fatalf("IndexRepositoryWithSettings failed: %v", err)
+	}
+	if _, err := idx.SetWorkspace(ctx, types.Workspace{Name: "web", Repositories: []string{"app"}}); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+
+	// A new indexer reading the store knows everything the first one did
+	restored, _ := newTestIndexer(t)
+	if err := restored.EnableMetadataStore(store); err != nil {
+		t.Fatalf("EnableMetadataStore failed: %v", err)
+	}
+	got, ok := restored.IndexedRepository("app")
+	if !ok || got.ID != repo.ID || got.Path != repo.Path || got.FileCount != 2 || got.Namespace != "team" {
+		t.Errorf("Expected repository %+v to be restored, got %+v", repo, got)
+	}
+	if settings, ok := restored.RepositorySettings("app"); !ok || settings.Source != dir || settings.Namespace != "team" {
+		t.Errorf("Expected the settings to be restored, got %+v", settings)
+	}
+	if runs := restored.IndexingHistory("app", 0); len(runs) != 1 || runs[0].Status != "completed" || len(runs[0].Phases) != len(indexingPhases) {
+		t.Errorf("Expected the run to be restored, got %+v", runs)
+	}
+	if workspace, ok := restored.Workspace("web"); !ok || len(workspace.Repositories) != 1 || workspace.Repositories[0] != "app" {
+		t.Errorf("Expected the workspace to be restored, got %+v", workspace)
+	}
+	if files := restored.files[repo.ID]; len(files) != 2 || files["main.go"].Hash != idx.files[repo.ID]["main.go"].Hash {
+		t.Errorf("Expected the file states to be restored, got %+v", files)
+	}
+	if _, err := restored.repoMgr.ResolvePath(filepath.Join(dir, "main.go")); err != nil {
+		t.Errorf("Expected the repository to be open to the file tools, got %v", err)
+	}
+}
+
+func TestMetadataStoreRejectsUnknownFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"version.json": `{"version": 2}`,
+		"corrupt.json": `{"version": 1,`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		idx, _ := newTestIndexer(t)
+		if err := idx.EnableMetadataStore(path); err == nil || !strings.Contains(err.Error(), path) {
+			t.Errorf("Expected %s to be rejected, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package indexer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// repositoryModules returns the modules the repository at root provides to
+// other repositories: the Go modules and npm packages its sub-projects
+// declare in go.mod and package.json, and the top-level Python packages
+// its files are in. Modules are sorted by language and path.
+func repositoryModules(root string, files []string) []types.RepositoryModule {
+	var modules []types.RepositoryModule
+	seen := make(map[types.RepositoryModule]bool)
+	add := func(module types.RepositoryModule) {
+		if module.Path != "" && !seen[module] {
+			seen[module] = true
+			modules = append(modules, module)
+		}
+	}
+
+	projects := map[string]bool{".": true}
+	packages := make(map[string]string) // Directory of Python files -> top-level package
+	for _, file := range files {
+		if project := projectOf(root, file); project != "" {
+			projects[project] = true
+		}
+		if filepath.Ext(file) != ".py" {
+			continue
+		}
+		dir := filepath.Dir(file)
+		top, ok := packages[dir]
+		if !ok {
+			top = topLevelPackage(root, dir)
+			packages[dir] = top
+		}
+		if top != "" {
+			rel, _ := filepath.Rel(root, top)
+			add(types.RepositoryModule{Language: "python", Path: filepath.Base(top), Dir: filepath.ToSlash(rel)})
+		}
+	}
+
+	for project := range projects {
+		dir := filepath.Join(root, filepath.FromSlash(project))
+		if name := projectName(dir, "go.mod"); name != "" {
+			add(types.RepositoryModule{Language: "go", Path: name, Dir: project})
+		}
+		if name := projectName(dir, "package.json"); name != "" {
+			add(types.RepositoryModule{Language: "javascript", Path: name, Dir: project})
+		}
+	}
+
+	sort.Slice(modules, func(a, b int) bool {
+		if modules[a].Language != modules[b].Language {
+			return modules[a].Language < modules[b].Language
+		}
+		if modules[a].Path != modules[b].Path {
+			return modules[a].Path < modules[b].Path
+		}
+		return modules[a].Dir < modules[b].Dir
+	})
+	return modules
+}
+
+// topLevelPackage returns the outermost directory of the Python package
+// dir is in, following __init__.py files up to but not including root, or
+// "" when dir is no package
+func topLevelPackage(root, dir string) string {
+	root = filepath.Clean(root)
+	top := ""
+	for dir != root && strings.HasPrefix(dir, root+string(filepath.Separator)) {
+		if _, err := os.Stat(filepath.Join(dir, "__init__.py")); err != nil {
+			break
+		}
+		top = dir
+		dir = filepath.Dir(dir)
+	}
+	return top
+}
+
+// ModuleRepositories returns the repositories providing the module an
+// import of importPath in a file of language loads. When modules of several
+// paths match, the repositories with the longest one are returned; several
+// may provide it, such as one repository indexed at two refs.
+func (i *Indexer) ModuleRepositories(language, importPath string) []types.Repository {
+	i.repositoriesMutex.RLock()
+	defer i.repositoriesMutex.RUnlock()
+
+	var repositories []types.Repository
+	longest := 0
+	for _, repo := range i.repositories {
+		module, ok := repo.ModuleFor(language, importPath)
+		if !ok || len(module.Path) < longest {
+			continue
+		}
+		if len(module.Path) > longest {
+			repositories, longest = nil, len(module.Path)
+		}
+		repositories = append(repositories, *repo)
+	}
+	sort.Slice(repositories, func(a, b int) bool {
+		if repositories[a].Name != repositories[b].Name {
+			return repositories[a].Name < repositories[b].Name
+		}
+		return repositories[a].ID < repositories[b].ID
+	})
+	return repositories
+}
@@ -0,0 +1,43 @@
+package indexer
+
+import (
+	"context"
+
+	"github.com/my-mcp/code-indexer/internal/repository"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// ProgressFunc receives the progress of an indexing run. It is called from
+// the indexing goroutine, so it should return quickly.
+type ProgressFunc func(progress types.IndexingProgress)
+
+// progressKey is the context key of the ProgressFunc
+type progressKey struct{}
+
+// WithProgress returns a context under which IndexRepository reports its
+// progress to fn: while a remote repository is cloned or pulled, once files
+// are discovered, after each file and when it completes. IndexIncremental
+// reports the files it updates the same way.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress passes a copy of progress to the ProgressFunc of ctx, if any
+func reportProgress(ctx context.Context, progress *types.IndexingProgress) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(*progress)
+	}
+}
+
+// withCloneProgress returns a context under which the clone or pull of a
+// repository is reported as progress with status "cloning"
+func withCloneProgress(ctx context.Context, progress *types.IndexingProgress) context.Context {
+	if _, ok := ctx.Value(progressKey{}).(ProgressFunc); !ok {
+		return ctx
+	}
+	return repository.WithCloneProgress(ctx, func(message string) {
+		progress.Status = "cloning"
+		progress.Message = message
+		reportProgress(ctx, progress)
+	})
+}
@@ -0,0 +1,117 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// projectManifests are the files marking the root directory of a
+// sub-project, such as a module of a monorepo
+var projectManifests = []string{"go.mod", "package.json", "pom.xml", "pyproject.toml"}
+
+var (
+	goModuleNamePattern  = regexp.MustCompile(`(?m)^module\s+(\S+)`)
+	pomParentPattern     = regexp.MustCompile(`(?s)<parent>.*?</parent>`)
+	pomArtifactPattern   = regexp.MustCompile(`<artifactId>\s*([^<\s]+)\s*</artifactId>`)
+	pyprojectNamePattern = regexp.MustCompile(`(?m)^name\s*=\s*["']([^"']+)["']`)
+)
+
+// projectOf returns the sub-project of the file at filePath in the
+// repository at root: the directory of the nearest manifest at or above the
+// file, relative to root with forward slashes and "." for root itself. It
+// returns "" when no directory up to root has a manifest.
+func projectOf(root, filePath string) string {
+	root = filepath.Clean(root)
+	for dir := filepath.Dir(filePath); ; dir = filepath.Dir(dir) {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return ""
+		}
+		if len(projectManifestsIn(dir)) > 0 {
+			return filepath.ToSlash(rel)
+		}
+		if rel == "." {
+			return ""
+		}
+	}
+}
+
+// projectManifestsIn returns the manifests found in a directory
+func projectManifestsIn(dir string) []string {
+	var found []string
+	for _, manifest := range projectManifests {
+		if info, err := os.Stat(filepath.Join(dir, manifest)); err == nil && !info.IsDir() {
+			found = append(found, manifest)
+		}
+	}
+	return found
+}
+
+// projectName returns the name a manifest declares the project under, or ""
+// when it declares none or cannot be read
+func projectName(dir, manifest string) string {
+	data, err := os.ReadFile(filepath.Join(dir, manifest))
+	if err != nil {
+		return ""
+	}
+
+	var match [][]byte
+	switch manifest {
+	case "go.mod":
+		match = goModuleNamePattern.FindSubmatch(data)
+	case "package.json":
+		var pkg struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(data, &pkg) == nil {
+			return pkg.Name
+		}
+	case "pom.xml":
+		match = pomArtifactPattern.FindSubmatch(pomParentPattern.ReplaceAll(data, nil))
+	case "pyproject.toml":
+		match = pyprojectNamePattern.FindSubmatch(data)
+	}
+	if match == nil {
+		return ""
+	}
+	return string(match[1])
+}
+
+// ListProjects returns the sub-projects of a repository, given by name or
+// ID, that its files were indexed under. When the repository is on disk,
+// each project is completed with its manifests and the name the first of
+// them declares.
+func (i *Indexer) ListProjects(ctx context.Context, repository string) (*types.Repository, []types.Project, error) {
+	repo, err := i.findRepository(ctx, repository)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	projects, err := i.searcher.ListProjects(ctx, repo.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for idx := range projects {
+		project := &projects[idx]
+		project.Manifests = []string{}
+		if repo.Path == "" {
+			continue
+		}
+		dir := filepath.Join(repo.Path, filepath.FromSlash(project.Path))
+		if manifests := projectManifestsIn(dir); manifests != nil {
+			project.Manifests = manifests
+		}
+		for _, manifest := range project.Manifests {
+			if project.Name = projectName(dir, manifest); project.Name != "" {
+				break
+			}
+		}
+	}
+	return repo, projects, nil
+}
@@ -0,0 +1,291 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/chunking"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// referenceCounts maps identifiers to the number of times they occur across
+// a repository. Counts are textual: occurrences in comments and strings are
+// included, and symbols sharing a name share a count.
+type referenceCounts map[string]int
+
+// repositoryReferences are the reference counts of a repository together
+// with the counts each file contributed, so files that change can be
+// recounted without reading the rest of the repository
+type repositoryReferences struct {
+	totals referenceCounts
+	files  map[string]referenceCounts // Keyed by slash-separated relative path
+}
+
+// countReferences reads every file of a repository and counts identifier
+// occurrences. It runs before the files are indexed so each symbol document
+// can be stored with its repository-wide reference count.
+func (i *Indexer) countReferences(ctx context.Context, repoPath string, files []string) (*repositoryReferences, error) {
+	refs := &repositoryReferences{
+		totals: make(referenceCounts),
+		files:  make(map[string]referenceCounts),
+	}
+	for _, filePath := range files {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		relativePath, err := i.repoMgr.GetRelativePath(filePath, repoPath)
+		if err != nil {
+			continue
+		}
+		content, err := i.repoMgr.GetFileContent(filePath)
+		if err != nil {
+			i.logger.Debug("Skipping file for reference counting", zap.String("file", filePath), zap.Error(err))
+			continue
+		}
+		refs.update(filepath.ToSlash(relativePath), content)
+	}
+	return refs, nil
+}
+
+// update replaces the counts of a file with those of its new content; nil
+// content removes the file
+func (r *repositoryReferences) update(relativePath string, content []byte) {
+	if old, ok := r.files[relativePath]; ok {
+		for name, count := range old {
+			if r.totals[name] -= count; r.totals[name] <= 0 {
+				delete(r.totals, name)
+			}
+		}
+		delete(r.files, relativePath)
+	}
+	if content == nil {
+		return
+	}
+
+	counts := make(referenceCounts)
+	counts.add(content)
+	for name, count := range counts {
+		r.totals[name] += count
+	}
+	r.files[relativePath] = counts
+}
+
+// updateReferences brings the reference counts of an indexed repository up
+// to date with the files that changed, given as relative paths mapped to
+// their absolute path, or to "" when they were deleted or are no longer
+// indexed. Only those files are read again, unless no counts are kept for
+// the repository yet, as after a restart; then every file is counted, and
+// the files are discovered when none are given. It returns a copy of the
+// totals that later updates leave alone.
+func (i *Indexer) updateReferences(ctx context.Context, repo *types.Repository, changed map[string]string, files []string) (referenceCounts, error) {
+	i.referencesMutex.Lock()
+	defer i.referencesMutex.Unlock()
+
+	refs, ok := i.references[repo.ID]
+	if !ok {
+		if files == nil {
+			err := i.repoMgr.WalkFiles(ctx, repo.Path, i.repositoryFilter(repo.ID), func(filePath string, info fs.FileInfo) error {
+				if i.shouldIndexFile(filePath, info) {
+					files = append(files, filePath)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to discover files: %w", err)
+			}
+		}
+
+		var err error
+		if refs, err = i.countReferences(ctx, repo.Path, files); err != nil {
+			return nil, err
+		}
+		i.references[repo.ID] = refs
+		return refs.totals.clone(), nil
+	}
+
+	for relativePath, filePath := range changed {
+		var content []byte
+		if filePath != "" {
+			var err error
+			if content, err = i.repoMgr.GetFileContent(filePath); err != nil {
+				i.logger.Debug("Skipping file for reference counting", zap.String("file", filePath), zap.Error(err))
+			}
+		}
+		refs.update(relativePath, content)
+	}
+	return refs.totals.clone(), nil
+}
+
+// rememberReferences keeps the reference counts of a freshly indexed
+// repository for later incremental updates
+func (i *Indexer) rememberReferences(repoID string, refs *repositoryReferences) {
+	i.referencesMutex.Lock()
+	i.references[repoID] = refs
+	i.referencesMutex.Unlock()
+}
+
+// reindexOutdatedReferences indexes again the files of a repository whose
+// symbol documents store reference counts other than refs gives: files
+// that did not change but whose symbols the changed files use more or less
+// often. The changed files must be indexed first. It returns how many files
+// were indexed again; files that fail are logged and skipped.
+func (i *Indexer) reindexOutdatedReferences(ctx context.Context, repo *types.Repository, chunker *chunking.Chunker, refs referenceCounts, timer phaseTimer) (int, error) {
+	stored, err := i.searcher.SymbolReferenceCounts(ctx, repo.ID)
+	if err != nil {
+		return 0, err
+	}
+	var outdated []string
+	for relativePath, symbols := range stored {
+		for name, references := range symbols {
+			if refs.referencesTo(name) != references {
+				outdated = append(outdated, relativePath)
+				break
+			}
+		}
+	}
+	if len(outdated) == 0 {
+		return 0, nil
+	}
+	sort.Strings(outdated)
+
+	i.forgetFiles(repo.ID, outdated...)
+	if _, err := i.searcher.DeleteFiles(ctx, repo.ID, outdated); err != nil {
+		return 0, err
+	}
+	filePaths := make([]string, 0, len(outdated))
+	for _, relativePath := range outdated {
+		filePaths = append(filePaths, filepath.Join(repo.Path, filepath.FromSlash(relativePath)))
+	}
+
+	reindexed := 0
+	err = i.indexFiles(ctx, repo, chunker, filePaths, refs, timer, func(filePath string, file *types.CodeFile, err error) {
+		if err != nil {
+			i.logger.Warn("Failed to index file",
+				zap.String("file", filePath),
+				zap.Error(err))
+			return
+		}
+		reindexed++
+	})
+	return reindexed, err
+}
+
+// clone returns a copy of the counts
+func (c referenceCounts) clone() referenceCounts {
+	copied := make(referenceCounts, len(c))
+	for name, count := range c {
+		copied[name] = count
+	}
+	return copied
+}
+
+// add counts the identifiers in a file's content
+func (c referenceCounts) add(content []byte) {
+	start := -1
+	for pos := 0; pos <= len(content); pos++ {
+		if pos < len(content) && isIdentifierByte(content[pos], start >= 0) {
+			if start < 0 {
+				start = pos
+			}
+			continue
+		}
+		if start >= 0 {
+			c[string(content[start:pos])]++
+			start = -1
+		}
+	}
+}
+
+// isIdentifierByte reports whether b can appear in an identifier. Digits are
+// only allowed after the first character.
+func isIdentifierByte(b byte, inIdentifier bool) bool {
+	switch {
+	case b == '_', b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z':
+		return true
+	case b >= '0' && b <= '9':
+		return inIdentifier
+	default:
+		return false
+	}
+}
+
+// referencesTo returns how often a symbol is used, not counting the
+// occurrence in its own declaration
+func (c referenceCounts) referencesTo(name string) int {
+	if count := c[name]; count > 1 {
+		return count - 1
+	}
+	return 0
+}
+
+// apply stores reference counts on the symbols of a parsed file
+func (c referenceCounts) apply(file *types.CodeFile) {
+	if c == nil {
+		return
+	}
+	for idx := range file.Functions {
+		file.Functions[idx].ReferenceCount = c.referencesTo(file.Functions[idx].Name)
+	}
+	for idx := range file.Classes {
+		file.Classes[idx].ReferenceCount = c.referencesTo(file.Classes[idx].Name)
+	}
+	for idx := range file.TypeDeclarations {
+		file.TypeDeclarations[idx].ReferenceCount = c.referencesTo(file.TypeDeclarations[idx].Name)
+	}
+	for idx := range file.Variables {
+		file.Variables[idx].ReferenceCount = c.referencesTo(file.Variables[idx].Name)
+	}
+}
+
+// resolveReferences sets the target of the references a file can resolve on
+// its own: uses of functions and classes it defines, including method calls
+// through self or this, and calls qualified by an imported package or
+// module. References to symbols of other files are resolved when queried.
+func resolveReferences(file *types.CodeFile) {
+	if len(file.References) == 0 {
+		return
+	}
+
+	local := make(map[string]bool)
+	for _, function := range file.Functions {
+		local[function.Name] = function.Name != ""
+	}
+	for _, class := range file.Classes {
+		local[class.Name] = class.Name != ""
+	}
+	for _, declaration := range file.TypeDeclarations {
+		local[declaration.Name] = declaration.Name != ""
+	}
+
+	imported := make(map[string]string)
+	for _, imp := range file.Imports {
+		alias := imp.Alias
+		if alias == "" {
+			alias = path.Base(imp.Module)
+		}
+		imported[alias] = imp.Module
+	}
+
+	for idx := range file.References {
+		reference := &file.References[idx]
+		switch reference.Qualifier {
+		case "", "self", "this":
+			if local[reference.Name] {
+				reference.Target = file.RelativePath + ":" + reference.Name
+			}
+		default:
+			if module, ok := imported[reference.Qualifier]; ok {
+				reference.Target = module + "." + reference.Name
+			}
+		}
+	}
+}
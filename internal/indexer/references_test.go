@@ -0,0 +1,46 @@
+package indexer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestReferenceCountsOfUnchangedFiles(t *testing.T) {
+	idx, root := newTestIndexer(t)
+	dir := filepath.Join(root, "app")
+	writeFiles(t, dir, map[string]string{
+		"lib.go":  "package app\n\nfunc Helper() {}\n",
+		"main.go": "package app\n\nfunc run() {\n\tHelper()\n}\n",
+	})
+
+	ctx := context.Background()
+	repo, err := idx.IndexRepository(ctx, dir, "app")
+	if err != nil {
+		t.Fatalf("IndexRepository failed: %v", err)
+	}
+	counts, err := idx.searcher.SymbolReferenceCounts(ctx, repo.ID)
+	if err != nil {
+		t.Fatalf("SymbolReferenceCounts failed: %v", err)
+	}
+	if counts["lib.go"]["Helper"] != 1 {
+		t.Fatalf("Expected Helper to be referenced once, got %v", counts)
+	}
+
+	// lib.go is unchanged, but main.go now uses Helper three times
+	writeFiles(t, dir, map[string]string{
+		"main.go": "package app\n\nfunc run() {\n\tHelper()\n\tHelper()\n\tHelper()\n}\n",
+	})
+	if repo, err = idx.IndexRepository(ctx, dir, "app"); err != nil {
+		t.Fatalf("IndexRepository failed: %v", err)
+	}
+	if runs := idx.IndexingHistory(repo.ID, 1); len(runs) != 1 || runs[0].FilesSkipped != 1 {
+		t.Fatalf("Expected lib.go to be skipped as unchanged, got %+v", runs)
+	}
+	if counts, err = idx.searcher.SymbolReferenceCounts(ctx, repo.ID); err != nil {
+		t.Fatalf("SymbolReferenceCounts failed: %v", err)
+	}
+	if counts["lib.go"]["Helper"] != 3 || counts["main.go"]["run"] != 0 {
+		t.Errorf("Expected Helper to be referenced three times, got %v", counts)
+	}
+}
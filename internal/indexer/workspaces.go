@@ -0,0 +1,87 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// SetWorkspace stores a workspace, replacing any workspace of the same name,
+// and reports whether one was replaced. Its repositories must be indexed and
+// may be given by name or ID; they are stored by name, sorted.
+func (i *Indexer) SetWorkspace(ctx context.Context, workspace types.Workspace) (bool, error) {
+	if workspace.Name == "" {
+		return false, fmt.Errorf("workspace needs a name")
+	}
+	if len(workspace.Repositories) == 0 {
+		return false, fmt.Errorf("workspace %s needs at least one repository", workspace.Name)
+	}
+
+	indexed, err := i.ListRepositories(ctx)
+	if err != nil {
+		return false, err
+	}
+	names := make([]string, 0, len(workspace.Repositories))
+	for _, repository := range workspace.Repositories {
+		idx := slices.IndexFunc(indexed, func(repo types.Repository) bool {
+			return repo.Name == repository || repo.ID == repository
+		})
+		if idx < 0 {
+			return false, fmt.Errorf("repository %s is not indexed", repository)
+		}
+		names = append(names, indexed[idx].Name)
+	}
+	slices.Sort(names)
+	workspace.Repositories = slices.Compact(names)
+
+	i.repositoriesMutex.Lock()
+	_, replaced := i.workspaces[workspace.Name]
+	i.workspaces[workspace.Name] = workspace
+	i.repositoriesMutex.Unlock()
+
+	i.saveMetadata()
+	return replaced, nil
+}
+
+// DeleteWorkspace removes a workspace and reports whether it existed. The
+// repositories it grouped are not touched.
+func (i *Indexer) DeleteWorkspace(name string) bool {
+	i.repositoriesMutex.Lock()
+	_, ok := i.workspaces[name]
+	delete(i.workspaces, name)
+	i.repositoriesMutex.Unlock()
+
+	if ok {
+		i.saveMetadata()
+	}
+	return ok
+}
+
+// Workspace returns the named workspace
+func (i *Indexer) Workspace(name string) (types.Workspace, bool) {
+	i.repositoriesMutex.RLock()
+	defer i.repositoriesMutex.RUnlock()
+
+	workspace, ok := i.workspaces[name]
+	workspace.Repositories = slices.Clone(workspace.Repositories)
+	return workspace, ok
+}
+
+// Workspaces returns the workspaces ordered by name
+func (i *Indexer) Workspaces() []types.Workspace {
+	i.repositoriesMutex.RLock()
+	defer i.repositoriesMutex.RUnlock()
+
+	workspaces := make([]types.Workspace, 0, len(i.workspaces))
+	for _, workspace := range i.workspaces {
+		workspace.Repositories = slices.Clone(workspace.Repositories)
+		workspaces = append(workspaces, workspace)
+	}
+	sort.Slice(workspaces, func(a, b int) bool {
+		return workspaces[a].Name < workspaces[b].Name
+	})
+	return workspaces
+}
@@ -0,0 +1,252 @@
+// Package journal records the edits made by the file manipulation tools so
+// they can be undone and redone without version control.
+//
+// Every entry keeps the complete file content before and after the edit.
+// Undo and redo only proceed when the file still holds the content the entry
+// expects, so an edit made elsewhere in the meantime is never overwritten.
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultMaxEntries is the number of edits kept when no limit is given
+const DefaultMaxEntries = 100
+
+var (
+	// ErrNothingToUndo is returned when no applied edit matches an undo
+	ErrNothingToUndo = errors.New("no edit to undo")
+
+	// ErrNothingToRedo is returned when no undone edit matches a redo
+	ErrNothingToRedo = errors.New("no undone edit to redo")
+)
+
+// ConflictError reports a file that changed since the journal last touched
+// it, so undoing or redoing an edit would discard that change
+type ConflictError struct {
+	EntryID  int64
+	FilePath string
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s changed after edit %d was recorded; refusing to overwrite it", e.FilePath, e.EntryID)
+}
+
+// Files reads and writes the files the journal restores
+type Files interface {
+	ReadFile(path string) ([]byte, error)
+	WriteFile(path string, data []byte) error
+}
+
+// Entry is one recorded edit
+type Entry struct {
+	ID        int64      `json:"id"`
+	FilePath  string     `json:"file_path"`
+	Tool      string     `json:"tool"`
+	SessionID string     `json:"session_id"`
+	Timestamp time.Time  `json:"timestamp"`
+	Before    string     `json:"-"`
+	After     string     `json:"-"`
+	Undone    bool       `json:"undone"`
+	UndoneAt  *time.Time `json:"undone_at,omitempty"`
+
+	undoSeq int64 // Orders undos so redo picks the most recent one
+}
+
+// Filter selects the entries an operation applies to. Empty fields match
+// every entry.
+type Filter struct {
+	SessionID string
+	FilePath  string
+}
+
+func (f Filter) matches(entry *Entry) bool {
+	return (f.SessionID == "" || entry.SessionID == f.SessionID) &&
+		(f.FilePath == "" || entry.FilePath == f.FilePath)
+}
+
+// Journal is a bounded, in-memory history of edits, oldest first
+type Journal struct {
+	mutex      sync.Mutex
+	files      Files
+	entries    []*Entry
+	nextID     int64
+	undoSeq    int64
+	maxEntries int
+}
+
+// New creates a journal restoring files through files and keeping at most
+// maxEntries edits; zero or less uses DefaultMaxEntries
+func New(files Files, maxEntries int) *Journal {
+	if maxEntries <= 0 {
+		maxEntries = DefaultMaxEntries
+	}
+	return &Journal{files: files, nextID: 1, maxEntries: maxEntries}
+}
+
+// Record adds an applied edit and returns its ID. The oldest entries are
+// dropped once the journal is full.
+func (j *Journal) Record(filePath, tool, sessionID, before, after string) int64 {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry := &Entry{
+		ID:        j.nextID,
+		FilePath:  filePath,
+		Tool:      tool,
+		SessionID: sessionID,
+		Timestamp: time.Now(),
+		Before:    before,
+		After:     after,
+	}
+	j.nextID++
+
+	j.entries = append(j.entries, entry)
+	if excess := len(j.entries) - j.maxEntries; excess > 0 {
+		j.entries = append([]*Entry(nil), j.entries[excess:]...)
+	}
+	return entry.ID
+}
+
+// Undo restores the file content from before the most recent applied edit
+// matching filter and returns a copy of that entry
+func (j *Journal) Undo(filter Filter) (*Entry, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entry := j.undoCandidate(filter)
+	if entry == nil {
+		return nil, ErrNothingToUndo
+	}
+	if err := j.restore(entry, entry.After, entry.Before); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	j.undoSeq++
+	entry.Undone = true
+	entry.UndoneAt = &now
+	entry.undoSeq = j.undoSeq
+	snapshot := *entry
+	return &snapshot, nil
+}
+
+// Redo reapplies the most recently undone edit matching filter and returns a
+// copy of that entry. An undone edit can no longer be redone once a later
+// edit of the same file has been applied.
+func (j *Journal) Redo(filter Filter) (*Entry, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	candidate := j.redoCandidate(filter)
+	if candidate == nil {
+		return nil, ErrNothingToRedo
+	}
+
+	if err := j.restore(candidate, candidate.Before, candidate.After); err != nil {
+		return nil, err
+	}
+	candidate.Undone = false
+	candidate.UndoneAt = nil
+	candidate.undoSeq = 0
+	snapshot := *candidate
+	return &snapshot, nil
+}
+
+// UndoTarget returns the file Undo would restore for filter, so callers can
+// lock it first
+func (j *Journal) UndoTarget(filter Filter) (string, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if entry := j.undoCandidate(filter); entry != nil {
+		return entry.FilePath, true
+	}
+	return "", false
+}
+
+// RedoTarget returns the file Redo would restore for filter, so callers can
+// lock it first
+func (j *Journal) RedoTarget(filter Filter) (string, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if entry := j.redoCandidate(filter); entry != nil {
+		return entry.FilePath, true
+	}
+	return "", false
+}
+
+// undoCandidate returns the most recent applied edit matching filter, or
+// nil. The caller holds the mutex.
+func (j *Journal) undoCandidate(filter Filter) *Entry {
+	for idx := len(j.entries) - 1; idx >= 0; idx-- {
+		if entry := j.entries[idx]; !entry.Undone && filter.matches(entry) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// redoCandidate returns the most recently undone edit matching filter that
+// can still be redone, or nil. The caller holds the mutex.
+func (j *Journal) redoCandidate(filter Filter) *Entry {
+	var candidate *Entry
+	for idx, entry := range j.entries {
+		if !entry.Undone || !filter.matches(entry) || j.superseded(idx) {
+			continue
+		}
+		if candidate == nil || entry.undoSeq > candidate.undoSeq {
+			candidate = entry
+		}
+	}
+	return candidate
+}
+
+// superseded reports whether an applied edit of the same file follows the
+// entry at idx
+func (j *Journal) superseded(idx int) bool {
+	for _, later := range j.entries[idx+1:] {
+		if !later.Undone && later.FilePath == j.entries[idx].FilePath {
+			return true
+		}
+	}
+	return false
+}
+
+// restore replaces the file of an entry with content after checking it
+// still holds expected
+func (j *Journal) restore(entry *Entry, expected, content string) error {
+	current, err := j.files.ReadFile(entry.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", entry.FilePath, err)
+	}
+	if string(current) != expected {
+		return &ConflictError{EntryID: entry.ID, FilePath: entry.FilePath}
+	}
+	if err := j.files.WriteFile(entry.FilePath, []byte(content)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", entry.FilePath, err)
+	}
+	return nil
+}
+
+// History returns copies of the entries matching filter, newest first, at
+// most limit of them when limit is positive
+func (j *Journal) History(filter Filter, limit int) []Entry {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	history := make([]Entry, 0)
+	for idx := len(j.entries) - 1; idx >= 0; idx-- {
+		if limit > 0 && len(history) >= limit {
+			break
+		}
+		if filter.matches(j.entries[idx]) {
+			history = append(history, *j.entries[idx])
+		}
+	}
+	return history
+}
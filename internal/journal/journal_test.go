@@ -0,0 +1,145 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+// memoryFiles is an in-memory Files implementation
+type memoryFiles map[string]string
+
+func (m memoryFiles) ReadFile(path string) ([]byte, error) {
+	content, ok := m[path]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return []byte(content), nil
+}
+
+func (m memoryFiles) WriteFile(path string, data []byte) error {
+	m[path] = string(data)
+	return nil
+}
+
+// edit changes a file and records the edit like the editing tools do
+func edit(j *Journal, files memoryFiles, path, session, content string) int64 {
+	before := files[path]
+	files[path] = content
+	return j.Record(path, "replace_lines", session, before, content)
+}
+
+func TestUndoRedo(t *testing.T) {
+	files := memoryFiles{"a.go": "v1"}
+	j := New(files, 0)
+
+	edit(j, files, "a.go", "s1", "v2")
+	edit(j, files, "a.go", "s1", "v3")
+
+	for _, want := range []string{"v2", "v1"} {
+		if _, err := j.Undo(Filter{SessionID: "s1"}); err != nil {
+			t.Fatalf("Undo failed: %v", err)
+		}
+		if files["a.go"] != want {
+			t.Fatalf("Expected %q after undo, got %q", want, files["a.go"])
+		}
+	}
+	if _, err := j.Undo(Filter{SessionID: "s1"}); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Expected ErrNothingToUndo, got: %v", err)
+	}
+
+	// Redo reapplies the edits in the order they were made
+	for _, want := range []string{"v2", "v3"} {
+		if _, err := j.Redo(Filter{SessionID: "s1"}); err != nil {
+			t.Fatalf("Redo failed: %v", err)
+		}
+		if files["a.go"] != want {
+			t.Fatalf("Expected %q after redo, got %q", want, files["a.go"])
+		}
+	}
+	if _, err := j.Redo(Filter{SessionID: "s1"}); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Expected ErrNothingToRedo, got: %v", err)
+	}
+}
+
+func TestRedoSupersededByNewEdit(t *testing.T) {
+	files := memoryFiles{"a.go": "v1", "b.go": "b1"}
+	j := New(files, 0)
+
+	edit(j, files, "b.go", "s1", "b2")
+	edit(j, files, "a.go", "s1", "v2")
+	if _, err := j.Undo(Filter{FilePath: "a.go"}); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if _, err := j.Undo(Filter{FilePath: "b.go"}); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+
+	// A new edit of a.go drops its undone edit, but b.go can still be redone
+	edit(j, files, "a.go", "s1", "v3")
+	if target, _ := j.UndoTarget(Filter{}); target != "a.go" {
+		t.Errorf("Expected a.go to be the undo target, got %q", target)
+	}
+	if target, _ := j.RedoTarget(Filter{}); target != "b.go" {
+		t.Errorf("Expected b.go to be the redo target, got %q", target)
+	}
+	entry, err := j.Redo(Filter{})
+	if err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if entry.FilePath != "b.go" || files["b.go"] != "b2" {
+		t.Errorf("Expected b.go to be redone, got %s with %q", entry.FilePath, files["b.go"])
+	}
+	if _, err := j.Redo(Filter{}); !errors.Is(err, ErrNothingToRedo) {
+		t.Errorf("Expected ErrNothingToRedo, got: %v", err)
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	files := memoryFiles{"a.go": "v1"}
+	j := New(files, 0)
+	id := edit(j, files, "a.go", "s1", "v2")
+
+	// Changed outside the journal
+	files["a.go"] = "external"
+
+	_, err := j.Undo(Filter{})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) || conflict.EntryID != id {
+		t.Fatalf("Expected a conflict for edit %d, got: %v", id, err)
+	}
+	if files["a.go"] != "external" {
+		t.Errorf("Conflicting undo overwrote the file: %q", files["a.go"])
+	}
+}
+
+func TestSessionsAndHistory(t *testing.T) {
+	files := memoryFiles{"a.go": "a1", "b.go": "b1"}
+	j := New(files, 2)
+
+	edit(j, files, "a.go", "s1", "a2")
+	edit(j, files, "b.go", "s2", "b2")
+	edit(j, files, "a.go", "s1", "a3")
+
+	// Only the two newest edits are kept
+	history := j.History(Filter{}, 0)
+	if len(history) != 2 || history[0].ID != 3 || history[1].ID != 2 {
+		t.Fatalf("Unexpected history: %+v", history)
+	}
+
+	if got := j.History(Filter{SessionID: "s2"}, 0); len(got) != 1 || got[0].FilePath != "b.go" {
+		t.Errorf("Expected one s2 edit of b.go, got %+v", got)
+	}
+	if got := j.History(Filter{}, 1); len(got) != 1 || got[0].ID != 3 {
+		t.Errorf("Expected the newest edit only, got %+v", got)
+	}
+
+	// Undo is scoped to the session
+	entry, err := j.Undo(Filter{SessionID: "s2"})
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if entry.FilePath != "b.go" || files["b.go"] != "b1" || files["a.go"] != "a3" {
+		t.Errorf("Expected only b.go to be restored, got a.go=%q b.go=%q", files["a.go"], files["b.go"])
+	}
+}
@@ -0,0 +1,232 @@
+package locking
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrDeadlock is wrapped by the errors of lock requests aborted to break a
+// deadlock
+var ErrDeadlock = errors.New("deadlock detected")
+
+// DeadlockError is returned for a lock request aborted because its owner
+// and the owners it waits for wait for each other
+type DeadlockError struct {
+	RequestID    string
+	OwnerID      string
+	ResourceType ResourceType
+	ResourceID   string
+	Cycle        []string // Owners in the wait-for cycle, starting with the aborted request's
+}
+
+func (e *DeadlockError) Error() string {
+	return fmt.Sprintf("%v: request of %s for %s:%s aborted, owners %s wait for each other",
+		ErrDeadlock, e.OwnerID, e.ResourceType, e.ResourceID, strings.Join(e.Cycle, " -> "))
+}
+
+func (e *DeadlockError) Unwrap() error {
+	return ErrDeadlock
+}
+
+// DeadlockInfo describes a deadlock that was broken
+type DeadlockInfo struct {
+	DetectedAt   time.Time    `json:"detected_at"`
+	Cycle        []string     `json:"cycle"`         // Owners waiting for each other
+	VictimOwner  string       `json:"victim_owner"`  // Owner of the aborted request
+	ResourceType ResourceType `json:"resource_type"` // Resource the aborted request waited for
+	ResourceID   string       `json:"resource_id"`
+}
+
+// deadlockStats counts the deadlocks the manager broke
+type deadlockStats struct {
+	detected int64
+	last     *DeadlockInfo
+}
+
+// waitForGraph holds who waits for whom: the queued requests of each owner
+// and the owners holding the locks each request waits for
+type waitForGraph struct {
+	waiting  map[string][]*LockRequest // Owner to its queued requests
+	blockers map[*LockRequest][]string // Request to the owners holding conflicting locks
+	queues   map[*LockRequest]*ResourceLock
+}
+
+// blockingOwners returns the owners a queued request waits for: those
+// holding locks on the resource that conflict with it and, as requests are
+// granted in order, those of the conflicting requests queued before it.
+// The caller holds the resource's mutex.
+func blockingOwners(resourceLock *ResourceLock, position int) []string {
+	request := resourceLock.WaitQueue[position]
+	var owners []string
+	if resourceLock.ExclusiveLock != nil {
+		owners = append(owners, resourceLock.ExclusiveLock.OwnerID)
+	}
+	if resourceLock.WriteLock != nil {
+		owners = append(owners, resourceLock.WriteLock.OwnerID)
+	}
+	if request.LockType != LockTypeRead {
+		for _, lock := range resourceLock.ReadLocks {
+			owners = append(owners, lock.OwnerID)
+		}
+	}
+	for _, earlier := range resourceLock.WaitQueue[:position] {
+		if request.LockType != LockTypeRead || earlier.LockType != LockTypeRead {
+			owners = append(owners, earlier.OwnerID)
+		}
+	}
+	return owners
+}
+
+// buildWaitForGraph collects the queued requests of every resource and the
+// owners they wait for
+func (m *Manager) buildWaitForGraph() *waitForGraph {
+	m.resourcesMutex.RLock()
+	resources := make([]*ResourceLock, 0, len(m.resources))
+	for _, resourceLock := range m.resources {
+		resources = append(resources, resourceLock)
+	}
+	m.resourcesMutex.RUnlock()
+
+	graph := &waitForGraph{
+		waiting:  make(map[string][]*LockRequest),
+		blockers: make(map[*LockRequest][]string),
+		queues:   make(map[*LockRequest]*ResourceLock),
+	}
+	for _, resourceLock := range resources {
+		resourceLock.mutex.RLock()
+		for position, request := range resourceLock.WaitQueue {
+			graph.waiting[request.OwnerID] = append(graph.waiting[request.OwnerID], request)
+			graph.blockers[request] = blockingOwners(resourceLock, position)
+			graph.queues[request] = resourceLock
+		}
+		resourceLock.mutex.RUnlock()
+	}
+	return graph
+}
+
+// findCycle returns the requests of a wait-for cycle through an owner, in
+// order, or nil if the owner is not part of one. An owner waiting for a lock
+// it holds itself, such as a read lock it wants to upgrade, is a cycle of
+// one.
+func (g *waitForGraph) findCycle(start string) []*LockRequest {
+	visited := make(map[string]bool)
+	var path []*LockRequest
+	var visit func(owner string) bool
+	visit = func(owner string) bool {
+		visited[owner] = true
+		for _, request := range g.waiting[owner] {
+			path = append(path, request)
+			for _, blocker := range g.blockers[request] {
+				if blocker == start {
+					return true
+				}
+				if !visited[blocker] && visit(blocker) {
+					return true
+				}
+			}
+			path = path[:len(path)-1]
+		}
+		return false
+	}
+	if visit(start) {
+		return path
+	}
+	return nil
+}
+
+// youngestRequest returns the request of a cycle made last
+func youngestRequest(cycle []*LockRequest) *LockRequest {
+	youngest := cycle[0]
+	for _, request := range cycle[1:] {
+		if !request.RequestedAt.Before(youngest.RequestedAt) {
+			youngest = request
+		}
+	}
+	return youngest
+}
+
+// checkDeadlock looks for a wait-for cycle through the owner of a request
+// that was just queued and breaks it by aborting the youngest request in
+// it, which may be the new request itself. It reports whether the new
+// request was the one aborted, with its error.
+func (m *Manager) checkDeadlock(request *LockRequest) (bool, error) {
+	m.deadlockMutex.Lock()
+	defer m.deadlockMutex.Unlock()
+
+	graph := m.buildWaitForGraph()
+	cycle := graph.findCycle(request.OwnerID)
+	if cycle == nil {
+		return false, nil
+	}
+
+	victim := youngestRequest(cycle)
+	owners := make([]string, 0, len(cycle))
+	start := 0
+	for i, waiting := range cycle {
+		if waiting == victim {
+			start = i
+		}
+	}
+	for i := range cycle {
+		owners = append(owners, cycle[(start+i)%len(cycle)].OwnerID)
+	}
+	deadlockErr := &DeadlockError{
+		RequestID:    victim.ID,
+		OwnerID:      victim.OwnerID,
+		ResourceType: victim.ResourceType,
+		ResourceID:   victim.ResourceID,
+		Cycle:        owners,
+	}
+
+	// The victim may have been granted its lock since the graph was built,
+	// in which case the cycle is already gone. Otherwise the requests queued
+	// behind it may be grantable now.
+	queue := graph.queues[victim]
+	if !m.removeFromWaitQueue(queue, victim.ID) {
+		return false, nil
+	}
+	defer m.processWaitQueue(queue)
+
+	m.deadlocks.detected++
+	m.deadlocks.last = &DeadlockInfo{
+		DetectedAt:   time.Now(),
+		Cycle:        owners,
+		VictimOwner:  victim.OwnerID,
+		ResourceType: victim.ResourceType,
+		ResourceID:   victim.ResourceID,
+	}
+	m.logger.Warn("Deadlock detected, aborting youngest lock request",
+		zap.String("request_id", victim.ID),
+		zap.String("owner_id", victim.OwnerID),
+		zap.String("resource_type", string(victim.ResourceType)),
+		zap.String("resource_id", victim.ResourceID),
+		zap.Strings("cycle", owners))
+
+	if victim == request {
+		return true, deadlockErr
+	}
+	select {
+	case victim.ResultChan <- &LockResult{Error: deadlockErr}:
+	default:
+	}
+	return false, nil
+}
+
+// deadlockStatistics describes the deadlocks broken so far
+func (m *Manager) deadlockStatistics() map[string]interface{} {
+	m.deadlockMutex.Lock()
+	defer m.deadlockMutex.Unlock()
+
+	stats := map[string]interface{}{
+		"enabled":  m.config.EnableDeadlockCheck,
+		"detected": m.deadlocks.detected,
+	}
+	if m.deadlocks.last != nil {
+		stats["last"] = *m.deadlocks.last
+	}
+	return stats
+}
@@ -0,0 +1,128 @@
+package locking
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestManager(t *testing.T, deadlockCheck bool) *Manager {
+	t.Helper()
+	m := NewManager(&LockConfig{
+		DefaultTimeout:      5 * time.Second,
+		MaxLockDuration:     time.Minute,
+		CleanupInterval:     time.Hour,
+		EnableDeadlockCheck: deadlockCheck,
+		MaxWaitQueueSize:    10,
+	}, zap.NewNop())
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// acquire takes a lock the test expects to be granted at once
+func acquire(t *testing.T, m *Manager, resourceID string, lockType LockType, owner string) *Lock {
+	t.Helper()
+	lock, err := m.AcquireLock(context.Background(), ResourceTypeFile, resourceID, lockType, owner, time.Second)
+	if err != nil {
+		t.Fatalf("%s failed to lock %s: %v", owner, resourceID, err)
+	}
+	return lock
+}
+
+// waitQueued waits until a resource has n queued requests
+func waitQueued(t *testing.T, m *Manager, resourceID string, n int) {
+	t.Helper()
+	resourceLock := m.getOrCreateResourceLock(string(ResourceTypeFile)+":"+resourceID, ResourceTypeFile, resourceID)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		resourceLock.mutex.RLock()
+		queued := len(resourceLock.WaitQueue)
+		resourceLock.mutex.RUnlock()
+		if queued >= n {
+			return
+		}
+	}
+	t.Fatalf("Expected %d requests to be queued for %s", n, resourceID)
+}
+
+func TestDeadlockDetection(t *testing.T) {
+	m := newTestManager(t, true)
+	acquire(t, m, "a.go", LockTypeWrite, "alice")
+	acquire(t, m, "b.go", LockTypeWrite, "bob")
+
+	// alice waits for bob's file
+	ctx, cancel := context.WithCancel(context.Background())
+	aliceDone := make(chan error, 1)
+	go func() {
+		_, err := m.AcquireLock(ctx, ResourceTypeFile, "b.go", LockTypeWrite, "alice", 5*time.Second)
+		aliceDone <- err
+	}()
+	waitQueued(t, m, "b.go", 1)
+
+	// bob asking for alice's file closes the cycle; his request is the
+	// youngest, so it is the one aborted
+	_, err := m.AcquireLock(context.Background(), ResourceTypeFile, "a.go", LockTypeWrite, "bob", 5*time.Second)
+	var deadlockErr *DeadlockError
+	if !errors.As(err, &deadlockErr) || !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("Expected a deadlock error, got %v", err)
+	}
+	if deadlockErr.OwnerID != "bob" || deadlockErr.ResourceID != "a.go" || !slices.Equal(deadlockErr.Cycle, []string{"bob", "alice"}) {
+		t.Errorf("Unexpected deadlock error %+v", deadlockErr)
+	}
+
+	// alice keeps waiting
+	select {
+	case err := <-aliceDone:
+		t.Fatalf("Expected alice to keep waiting, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+	if err := <-aliceDone; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected alice's request to be cancelled, got %v", err)
+	}
+
+	deadlocks := m.GetLockStats()["deadlocks"].(map[string]interface{})
+	last, _ := deadlocks["last"].(DeadlockInfo)
+	if deadlocks["detected"] != int64(1) || last.VictimOwner != "bob" || last.ResourceID != "a.go" {
+		t.Errorf("Unexpected deadlock stats %+v", deadlocks)
+	}
+}
+
+func TestUpgradeDeadlock(t *testing.T) {
+	m := newTestManager(t, true)
+	acquire(t, m, "a.go", LockTypeRead, "alice")
+
+	// Waiting for a write lock on a file alice reads herself never ends
+	_, err := m.AcquireLock(context.Background(), ResourceTypeFile, "a.go", LockTypeWrite, "alice", 5*time.Second)
+	var deadlockErr *DeadlockError
+	if !errors.As(err, &deadlockErr) || !slices.Equal(deadlockErr.Cycle, []string{"alice"}) {
+		t.Errorf("Expected a deadlock of alice alone, got %v", err)
+	}
+}
+
+func TestWaitingWithoutDeadlock(t *testing.T) {
+	for _, deadlockCheck := range []bool{true, false} {
+		m := newTestManager(t, deadlockCheck)
+		acquire(t, m, "a.go", LockTypeWrite, "alice")
+		acquire(t, m, "b.go", LockTypeWrite, "bob")
+		go m.AcquireLock(context.Background(), ResourceTypeFile, "b.go", LockTypeWrite, "alice", time.Second)
+		waitQueued(t, m, "b.go", 1)
+
+		// carol waits for alice, who waits for bob: a chain, not a cycle. Without
+		// detection, bob closing the cycle waits for the timeout instead.
+		owner, resource := "carol", "a.go"
+		if !deadlockCheck {
+			owner = "bob"
+		}
+		_, err := m.AcquireLock(context.Background(), ResourceTypeFile, resource, LockTypeWrite, owner, 20*time.Millisecond)
+		if err == nil || errors.Is(err, ErrDeadlock) {
+			t.Errorf("Expected %s to time out (deadlock check %v), got %v", owner, deadlockCheck, err)
+		}
+		if detected := m.GetLockStats()["deadlocks"].(map[string]interface{})["detected"]; detected != int64(0) {
+			t.Errorf("Expected no deadlock to be detected (deadlock check %v), got %v", deadlockCheck, detected)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package locking
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -9,6 +10,24 @@ import (
 	"go.uber.org/zap"
 )
 
+var (
+	// ErrLockTimeout is wrapped by the errors of lock requests that waited
+	// longer than their timeout
+	ErrLockTimeout = errors.New("lock acquisition timeout")
+
+	// ErrWaitQueueFull is wrapped by the errors of lock requests refused
+	// because too many requests already wait for the resource
+	ErrWaitQueueFull = errors.New("wait queue full")
+
+	// ErrLockNotFound is wrapped by the errors of releasing or renewing a
+	// lock that was released or has expired
+	ErrLockNotFound = errors.New("lock not found")
+
+	// ErrManagerClosed is returned to lock requests still waiting when the
+	// manager is closed
+	ErrManagerClosed = errors.New("lock manager closed")
+)
+
 // LockType represents different types of locks
 type LockType string
 
@@ -28,28 +47,30 @@ const (
 	ResourceTypeSession    ResourceType = "session"
 )
 
-// Lock represents a resource lock
+// Lock represents a resource lock. Its context is done once the lock is
+// released or expires, or the context it was requested with is done.
 type Lock struct {
-	ID           string       `json:"id"`
-	ResourceType ResourceType `json:"resource_type"`
-	ResourceID   string       `json:"resource_id"`
-	LockType     LockType     `json:"lock_type"`
-	OwnerID      string       `json:"owner_id"` // Connection or session ID
-	AcquiredAt   time.Time    `json:"acquired_at"`
-	ExpiresAt    time.Time    `json:"expires_at"`
-	Context      context.Context `json:"-"`
+	ID           string             `json:"id"`
+	ResourceType ResourceType       `json:"resource_type"`
+	ResourceID   string             `json:"resource_id"`
+	LockType     LockType           `json:"lock_type"`
+	OwnerID      string             `json:"owner_id"` // Connection or session ID
+	AcquiredAt   time.Time          `json:"acquired_at"`
+	ExpiresAt    time.Time          `json:"expires_at"` // Moved by RenewLock; guarded by the manager's locksMutex
+	Context      context.Context    `json:"-"`
 	Cancel       context.CancelFunc `json:"-"`
 }
 
 // ResourceLock manages locks for a specific resource
 type ResourceLock struct {
-	ResourceID   string
-	ResourceType ResourceType
-	ReadLocks    map[string]*Lock // Multiple read locks allowed
-	WriteLock    *Lock            // Only one write lock allowed
-	ExclusiveLock *Lock           // Exclusive lock blocks everything
-	WaitQueue    []*LockRequest   // Queue of waiting lock requests
-	mutex        sync.RWMutex
+	ResourceID    string
+	ResourceType  ResourceType
+	ReadLocks     map[string]*Lock // Multiple read locks allowed
+	WriteLock     *Lock            // Only one write lock allowed
+	ExclusiveLock *Lock            // Exclusive lock blocks everything
+	WaitQueue     []*LockRequest   // Queue of waiting lock requests, granted in order
+	removed       bool             // Dropped from the manager while idle; requests look it up again
+	mutex         sync.RWMutex
 }
 
 // LockRequest represents a pending lock request
@@ -62,7 +83,7 @@ type LockRequest struct {
 	Timeout      time.Duration
 	RequestedAt  time.Time
 	Context      context.Context
-	ResultChan   chan *LockResult
+	ResultChan   chan *LockResult // Receives exactly one result, from whoever takes the request off the queue
 }
 
 // LockResult represents the result of a lock request
@@ -71,24 +92,39 @@ type LockResult struct {
 	Error error
 }
 
-// Manager manages resource locks across the system
+// Manager manages resource locks across the system.
+//
+// The resources and the locks are kept in separate maps, each with its own
+// mutex, and every resource has a mutex of its own. They are only ever
+// taken in the order resourcesMutex, a resource's mutex, locksMutex, and
+// none is held while calling another method that takes one, so releasing
+// works the same from callers, the cleanup loop and Close.
 type Manager struct {
-	resources       map[string]*ResourceLock // resourceType:resourceID -> ResourceLock
-	locks          map[string]*Lock         // lockID -> Lock
-	config         *LockConfig
-	logger         *zap.Logger
-	mutex          sync.RWMutex
-	
+	resources      map[string]*ResourceLock // resourceType:resourceID -> ResourceLock
+	resourcesMutex sync.RWMutex
+	locks          map[string]*Lock // lockID -> Lock
+	expired        int64            // Locks released by the cleanup loop
+	renewed        int64            // Successful RenewLock calls
+	locksMutex     sync.RWMutex     // Guards locks, the counters and each lock's ExpiresAt
+
+	config *LockConfig
+	logger *zap.Logger
+
+	// Deadlock detection, serialized so each check sees a settled graph
+	deadlockMutex sync.Mutex
+	deadlocks     deadlockStats
+
 	// Cleanup and monitoring
 	cleanupInterval time.Duration
 	shutdown        chan struct{}
+	closeOnce       sync.Once
 	wg              sync.WaitGroup
 }
 
 // LockConfig contains locking configuration
 type LockConfig struct {
 	DefaultTimeout      time.Duration
-	MaxLockDuration     time.Duration
+	MaxLockDuration     time.Duration // Locks not renewed for this long expire
 	CleanupInterval     time.Duration
 	EnableDeadlockCheck bool
 	MaxWaitQueueSize    int
@@ -108,9 +144,9 @@ func NewManager(config *LockConfig, logger *zap.Logger) *Manager {
 
 	manager := &Manager{
 		resources:       make(map[string]*ResourceLock),
-		locks:          make(map[string]*Lock),
-		config:         config,
-		logger:         logger,
+		locks:           make(map[string]*Lock),
+		config:          config,
+		logger:          logger,
 		cleanupInterval: config.CleanupInterval,
 		shutdown:        make(chan struct{}),
 	}
@@ -122,7 +158,9 @@ func NewManager(config *LockConfig, logger *zap.Logger) *Manager {
 	return manager
 }
 
-// AcquireLock attempts to acquire a lock on a resource
+// AcquireLock attempts to acquire a lock on a resource. Requests are
+// granted in the order they were made: a request waits while any request
+// queued before it does, so a stream of readers cannot starve a writer.
 func (m *Manager) AcquireLock(ctx context.Context, resourceType ResourceType, resourceID string, lockType LockType, ownerID string, timeout time.Duration) (*Lock, error) {
 	if timeout == 0 {
 		timeout = m.config.DefaultTimeout
@@ -141,92 +179,192 @@ func (m *Manager) AcquireLock(ctx context.Context, resourceType ResourceType, re
 		ResultChan:   make(chan *LockResult, 1),
 	}
 
-	// Get or create resource lock
+	// Acquire the lock immediately or queue the request, looking the
+	// resource up again if the cleanup loop dropped it meanwhile
 	resourceKey := string(resourceType) + ":" + resourceID
-	resourceLock := m.getOrCreateResourceLock(resourceKey, resourceType, resourceID)
-
-	// Try to acquire lock immediately or queue the request
-	if lock := m.tryAcquireLock(resourceLock, request); lock != nil {
-		return lock, nil
+	var resourceLock *ResourceLock
+	for {
+		resourceLock = m.getOrCreateResourceLock(resourceKey, resourceType, resourceID)
+		lock, current, err := m.acquireOrQueue(resourceLock, request)
+		if err != nil {
+			return nil, err
+		}
+		if lock != nil {
+			return lock, nil
+		}
+		if current {
+			break
+		}
 	}
 
-	// Add to wait queue
-	if err := m.addToWaitQueue(resourceLock, request); err != nil {
-		return nil, err
+	// Queueing may close a wait-for cycle; the youngest request in it is
+	// aborted, which may be this one
+	if m.config.EnableDeadlockCheck {
+		if aborted, err := m.checkDeadlock(request); aborted {
+			return nil, err
+		}
 	}
 
 	// Wait for lock or timeout
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
 	select {
 	case result := <-request.ResultChan:
 		return result.Lock, result.Error
-	case <-time.After(timeout):
-		m.removeFromWaitQueue(resourceLock, request.ID)
-		return nil, fmt.Errorf("lock acquisition timeout after %v", timeout)
+	case <-timer.C:
+		err = fmt.Errorf("%w after %v", ErrLockTimeout, timeout)
 	case <-ctx.Done():
-		m.removeFromWaitQueue(resourceLock, request.ID)
-		return nil, ctx.Err()
+		err = ctx.Err()
 	}
+	return nil, m.abandonRequest(resourceLock, request, err)
+}
+
+// abandonRequest takes a request that stopped waiting off the queue and
+// returns err. A request taken off the queue first was granted or aborted
+// in the meantime; a lock granted to it is released again.
+func (m *Manager) abandonRequest(resourceLock *ResourceLock, request *LockRequest, err error) error {
+	if m.removeFromWaitQueue(resourceLock, request.ID) {
+		// Requests queued behind it may be grantable now
+		m.processWaitQueue(resourceLock)
+		return err
+	}
+	result := <-request.ResultChan
+	if result.Lock != nil {
+		m.ReleaseLock(result.Lock.ID)
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	return err
 }
 
 // ReleaseLock releases a previously acquired lock
 func (m *Manager) ReleaseLock(lockID string) error {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
+	m.locksMutex.RLock()
 	lock, exists := m.locks[lockID]
-	if !exists {
-		return fmt.Errorf("lock not found: %s", lockID)
+	m.locksMutex.RUnlock()
+	if !exists || !m.releaseLock(lock, time.Time{}) {
+		return fmt.Errorf("%w: %s", ErrLockNotFound, lockID)
 	}
 
-	// Get resource lock
+	m.logger.Debug("Released lock",
+		zap.String("lock_id", lockID),
+		zap.String("resource_type", string(lock.ResourceType)),
+		zap.String("resource_id", lock.ResourceID),
+		zap.String("lock_type", string(lock.LockType)),
+		zap.String("owner_id", lock.OwnerID))
+	return nil
+}
+
+// releaseLock removes a lock from its resource and the lock map, cancels
+// its context and grants the requests that waited for it. With expiredAt
+// set, a lock renewed past it is kept. It reports whether the lock was
+// released, rather than released before or renewed. The caller holds no
+// manager mutex.
+func (m *Manager) releaseLock(lock *Lock, expiredAt time.Time) bool {
+	// Resources holding locks are never dropped
 	resourceKey := string(lock.ResourceType) + ":" + lock.ResourceID
+	m.resourcesMutex.RLock()
 	resourceLock, exists := m.resources[resourceKey]
+	m.resourcesMutex.RUnlock()
 	if !exists {
-		return fmt.Errorf("resource lock not found: %s", resourceKey)
+		return false
 	}
 
-	// Remove lock from resource
 	resourceLock.mutex.Lock()
-	defer resourceLock.mutex.Unlock()
-
-	switch lock.LockType {
-	case LockTypeRead:
-		delete(resourceLock.ReadLocks, lockID)
-	case LockTypeWrite:
-		if resourceLock.WriteLock != nil && resourceLock.WriteLock.ID == lockID {
-			resourceLock.WriteLock = nil
-		}
-	case LockTypeExclusive:
-		if resourceLock.ExclusiveLock != nil && resourceLock.ExclusiveLock.ID == lockID {
-			resourceLock.ExclusiveLock = nil
+	m.locksMutex.Lock()
+	_, held := m.locks[lock.ID]
+	if held && !expiredAt.IsZero() && lock.ExpiresAt.After(expiredAt) {
+		held = false
+	}
+	if held {
+		delete(m.locks, lock.ID)
+	}
+	m.locksMutex.Unlock()
+	if held {
+		switch lock.LockType {
+		case LockTypeRead:
+			delete(resourceLock.ReadLocks, lock.ID)
+		case LockTypeWrite:
+			if resourceLock.WriteLock == lock {
+				resourceLock.WriteLock = nil
+			}
+		case LockTypeExclusive:
+			if resourceLock.ExclusiveLock == lock {
+				resourceLock.ExclusiveLock = nil
+			}
 		}
 	}
+	resourceLock.mutex.Unlock()
+	if !held {
+		return false
+	}
 
 	// Cancel lock context
 	if lock.Cancel != nil {
 		lock.Cancel()
 	}
 
-	// Remove from global locks map
-	delete(m.locks, lockID)
+	m.processWaitQueue(resourceLock)
+	return true
+}
 
-	m.logger.Debug("Released lock",
-		zap.String("lock_id", lockID),
-		zap.String("resource_type", string(lock.ResourceType)),
-		zap.String("resource_id", lock.ResourceID),
-		zap.String("lock_type", string(lock.LockType)),
-		zap.String("owner_id", lock.OwnerID))
+// RenewLock moves the expiry of a lock duration from now, at most
+// MaxLockDuration; zero renews it for MaxLockDuration. Locks that were
+// released or have expired cannot be renewed.
+func (m *Manager) RenewLock(lockID string, duration time.Duration) (time.Time, error) {
+	if duration <= 0 || duration > m.config.MaxLockDuration {
+		duration = m.config.MaxLockDuration
+	}
 
-	// Process wait queue
-	m.processWaitQueue(resourceLock)
+	m.locksMutex.Lock()
+	defer m.locksMutex.Unlock()
 
-	return nil
+	lock, exists := m.locks[lockID]
+	now := time.Now()
+	if !exists || now.After(lock.ExpiresAt) {
+		return time.Time{}, fmt.Errorf("%w: %s", ErrLockNotFound, lockID)
+	}
+	lock.ExpiresAt = now.Add(duration)
+	m.renewed++
+	return lock.ExpiresAt, nil
+}
+
+// KeepAlive renews a lock every third of MaxLockDuration, for operations
+// that may hold it longer than that, until stop is called, ctx is done or
+// the lock is gone. An operation whose context is done stops renewing, so
+// its locks expire even if it never releases them.
+func (m *Manager) KeepAlive(ctx context.Context, lockID string) (stop func()) {
+	interval := m.config.MaxLockDuration / 3
+	if interval <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := m.RenewLock(lockID, 0); err != nil {
+					return
+				}
+			case <-ctx.Done():
+				return
+			case <-m.shutdown:
+				return
+			}
+		}
+	}()
+	return cancel
 }
 
 // getOrCreateResourceLock gets or creates a resource lock
 func (m *Manager) getOrCreateResourceLock(resourceKey string, resourceType ResourceType, resourceID string) *ResourceLock {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	m.resourcesMutex.Lock()
+	defer m.resourcesMutex.Unlock()
 
 	resourceLock, exists := m.resources[resourceKey]
 	if !exists {
@@ -242,26 +380,40 @@ func (m *Manager) getOrCreateResourceLock(resourceKey string, resourceType Resou
 	return resourceLock
 }
 
-// tryAcquireLock attempts to acquire a lock immediately
-func (m *Manager) tryAcquireLock(resourceLock *ResourceLock, request *LockRequest) *Lock {
+// acquireOrQueue grants a request at once when nothing is queued for the
+// resource and the locks held allow it, and queues it otherwise. It reports
+// whether the resource was still current; one dropped by the cleanup loop
+// has to be looked up again.
+func (m *Manager) acquireOrQueue(resourceLock *ResourceLock, request *LockRequest) (*Lock, bool, error) {
 	resourceLock.mutex.Lock()
 	defer resourceLock.mutex.Unlock()
 
-	// Check if lock can be acquired
-	if !m.canAcquireLock(resourceLock, request.LockType) {
-		return nil
+	if resourceLock.removed {
+		return nil, false, nil
 	}
+	if len(resourceLock.WaitQueue) == 0 && m.canAcquireLock(resourceLock, request.LockType) {
+		return m.grant(resourceLock, request), true, nil
+	}
+	if len(resourceLock.WaitQueue) >= m.config.MaxWaitQueueSize {
+		return nil, true, fmt.Errorf("%w for resource %s:%s", ErrWaitQueueFull, request.ResourceType, request.ResourceID)
+	}
+	resourceLock.WaitQueue = append(resourceLock.WaitQueue, request)
+	return nil, true, nil
+}
 
-	// Create lock
-	ctx, cancel := context.WithTimeout(request.Context, m.config.MaxLockDuration)
+// grant creates the lock of a request and adds it to the resource and the
+// lock map. The caller holds the resource's mutex.
+func (m *Manager) grant(resourceLock *ResourceLock, request *LockRequest) *Lock {
+	ctx, cancel := context.WithCancel(request.Context)
+	now := time.Now()
 	lock := &Lock{
 		ID:           request.ID,
 		ResourceType: request.ResourceType,
 		ResourceID:   request.ResourceID,
 		LockType:     request.LockType,
 		OwnerID:      request.OwnerID,
-		AcquiredAt:   time.Now(),
-		ExpiresAt:    time.Now().Add(m.config.MaxLockDuration),
+		AcquiredAt:   now,
+		ExpiresAt:    now.Add(m.config.MaxLockDuration),
 		Context:      ctx,
 		Cancel:       cancel,
 	}
@@ -277,16 +429,17 @@ func (m *Manager) tryAcquireLock(resourceLock *ResourceLock, request *LockReques
 	}
 
 	// Add to global locks map
-	m.mutex.Lock()
+	m.locksMutex.Lock()
 	m.locks[lock.ID] = lock
-	m.mutex.Unlock()
+	m.locksMutex.Unlock()
 
 	m.logger.Debug("Acquired lock",
 		zap.String("lock_id", lock.ID),
 		zap.String("resource_type", string(lock.ResourceType)),
 		zap.String("resource_id", lock.ResourceID),
 		zap.String("lock_type", string(lock.LockType)),
-		zap.String("owner_id", lock.OwnerID))
+		zap.String("owner_id", lock.OwnerID),
+		zap.Duration("waited", now.Sub(request.RequestedAt)))
 
 	return lock
 }
@@ -313,103 +466,36 @@ func (m *Manager) canAcquireLock(resourceLock *ResourceLock, lockType LockType)
 	return false
 }
 
-// addToWaitQueue adds a lock request to the wait queue
-func (m *Manager) addToWaitQueue(resourceLock *ResourceLock, request *LockRequest) error {
-	resourceLock.mutex.Lock()
-	defer resourceLock.mutex.Unlock()
-
-	if len(resourceLock.WaitQueue) >= m.config.MaxWaitQueueSize {
-		return fmt.Errorf("wait queue full for resource %s:%s", request.ResourceType, request.ResourceID)
-	}
-
-	resourceLock.WaitQueue = append(resourceLock.WaitQueue, request)
-	return nil
-}
-
-// removeFromWaitQueue removes a lock request from the wait queue
-func (m *Manager) removeFromWaitQueue(resourceLock *ResourceLock, requestID string) {
+// removeFromWaitQueue removes a lock request from the wait queue, reporting
+// whether it was still queued
+func (m *Manager) removeFromWaitQueue(resourceLock *ResourceLock, requestID string) bool {
 	resourceLock.mutex.Lock()
 	defer resourceLock.mutex.Unlock()
 
 	for i, req := range resourceLock.WaitQueue {
 		if req.ID == requestID {
 			resourceLock.WaitQueue = append(resourceLock.WaitQueue[:i], resourceLock.WaitQueue[i+1:]...)
-			break
+			return true
 		}
 	}
+	return false
 }
 
-// processWaitQueue processes pending lock requests in the wait queue
+// processWaitQueue grants queued requests in order until one cannot be
+// granted, which holds back those behind it
 func (m *Manager) processWaitQueue(resourceLock *ResourceLock) {
 	resourceLock.mutex.Lock()
 	defer resourceLock.mutex.Unlock()
 
-	for i := 0; i < len(resourceLock.WaitQueue); {
-		request := resourceLock.WaitQueue[i]
-		
-		// Check if request has timed out
-		if time.Since(request.RequestedAt) > request.Timeout {
-			// Remove from queue and send timeout error
-			resourceLock.WaitQueue = append(resourceLock.WaitQueue[:i], resourceLock.WaitQueue[i+1:]...)
-			select {
-			case request.ResultChan <- &LockResult{Error: fmt.Errorf("lock request timeout")}:
-			default:
-			}
-			continue
-		}
-
-		// Try to acquire lock
-		if lock := m.tryAcquireLockFromQueue(resourceLock, request); lock != nil {
-			// Remove from queue and send success
-			resourceLock.WaitQueue = append(resourceLock.WaitQueue[:i], resourceLock.WaitQueue[i+1:]...)
-			select {
-			case request.ResultChan <- &LockResult{Lock: lock}:
-			default:
-			}
-			continue
+	for len(resourceLock.WaitQueue) > 0 {
+		request := resourceLock.WaitQueue[0]
+		if !m.canAcquireLock(resourceLock, request.LockType) {
+			return
 		}
-
-		i++
-	}
-}
-
-// tryAcquireLockFromQueue attempts to acquire a lock from the wait queue
-func (m *Manager) tryAcquireLockFromQueue(resourceLock *ResourceLock, request *LockRequest) *Lock {
-	// This is similar to tryAcquireLock but assumes resourceLock is already locked
-	if !m.canAcquireLock(resourceLock, request.LockType) {
-		return nil
-	}
-
-	// Create lock
-	ctx, cancel := context.WithTimeout(request.Context, m.config.MaxLockDuration)
-	lock := &Lock{
-		ID:           request.ID,
-		ResourceType: request.ResourceType,
-		ResourceID:   request.ResourceID,
-		LockType:     request.LockType,
-		OwnerID:      request.OwnerID,
-		AcquiredAt:   time.Now(),
-		ExpiresAt:    time.Now().Add(m.config.MaxLockDuration),
-		Context:      ctx,
-		Cancel:       cancel,
+		resourceLock.WaitQueue = resourceLock.WaitQueue[1:]
+		// The channel has room for the one result of a request
+		request.ResultChan <- &LockResult{Lock: m.grant(resourceLock, request)}
 	}
-
-	// Add lock to resource
-	switch request.LockType {
-	case LockTypeRead:
-		resourceLock.ReadLocks[lock.ID] = lock
-	case LockTypeWrite:
-		resourceLock.WriteLock = lock
-	case LockTypeExclusive:
-		resourceLock.ExclusiveLock = lock
-	}
-
-	// Add to global locks map
-	m.mutex.Lock()
-	m.locks[lock.ID] = lock
-	m.mutex.Unlock()
-
-	return lock
 }
 
 // cleanupLoop periodically cleans up expired locks
@@ -429,73 +515,134 @@ func (m *Manager) cleanupLoop() {
 	}
 }
 
-// cleanupExpiredLocks removes expired locks
+// cleanupExpiredLocks releases the locks that were not renewed in time and
+// drops the resources nobody holds or waits for
 func (m *Manager) cleanupExpiredLocks() {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
 	now := time.Now()
-	expiredLocks := make([]string, 0)
-
-	for lockID, lock := range m.locks {
+	m.locksMutex.RLock()
+	var expired []*Lock
+	for _, lock := range m.locks {
 		if now.After(lock.ExpiresAt) {
-			expiredLocks = append(expiredLocks, lockID)
+			expired = append(expired, lock)
 		}
 	}
-
-	for _, lockID := range expiredLocks {
-		m.ReleaseLock(lockID)
+	m.locksMutex.RUnlock()
+
+	released := 0
+	for _, lock := range expired {
+		if m.releaseLock(lock, now) {
+			released++
+			m.logger.Warn("Lock expired",
+				zap.String("lock_id", lock.ID),
+				zap.String("resource_type", string(lock.ResourceType)),
+				zap.String("resource_id", lock.ResourceID),
+				zap.String("owner_id", lock.OwnerID))
+		}
+	}
+	if released > 0 {
+		m.locksMutex.Lock()
+		m.expired += int64(released)
+		m.locksMutex.Unlock()
+		m.logger.Info("Cleaned up expired locks", zap.Int("count", released))
 	}
 
-	if len(expiredLocks) > 0 {
-		m.logger.Info("Cleaned up expired locks", zap.Int("count", len(expiredLocks)))
+	m.removeIdleResources()
+}
+
+// removeIdleResources drops the resources without locks or waiting
+// requests, so locking many files does not grow the manager for good
+func (m *Manager) removeIdleResources() {
+	m.resourcesMutex.Lock()
+	defer m.resourcesMutex.Unlock()
+
+	for key, resourceLock := range m.resources {
+		resourceLock.mutex.Lock()
+		if resourceLock.ExclusiveLock == nil && resourceLock.WriteLock == nil &&
+			len(resourceLock.ReadLocks) == 0 && len(resourceLock.WaitQueue) == 0 {
+			resourceLock.removed = true
+			delete(m.resources, key)
+		}
+		resourceLock.mutex.Unlock()
 	}
 }
 
 // GetLockStats returns locking statistics
 func (m *Manager) GetLockStats() map[string]interface{} {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+	deadlocks := m.deadlockStatistics()
 
-	stats := map[string]interface{}{
-		"total_locks":     len(m.locks),
-		"total_resources": len(m.resources),
-		"lock_types":      make(map[string]int),
-		"resource_types":  make(map[string]int),
+	m.resourcesMutex.RLock()
+	resources := make([]*ResourceLock, 0, len(m.resources))
+	for _, resourceLock := range m.resources {
+		resources = append(resources, resourceLock)
+	}
+	m.resourcesMutex.RUnlock()
+
+	waiting := 0
+	for _, resourceLock := range resources {
+		resourceLock.mutex.RLock()
+		waiting += len(resourceLock.WaitQueue)
+		resourceLock.mutex.RUnlock()
 	}
 
+	m.locksMutex.RLock()
+	defer m.locksMutex.RUnlock()
+
 	// Count by lock type
 	lockTypes := make(map[string]int)
 	resourceTypes := make(map[string]int)
-	
 	for _, lock := range m.locks {
 		lockTypes[string(lock.LockType)]++
 		resourceTypes[string(lock.ResourceType)]++
 	}
-	
-	stats["lock_types"] = lockTypes
-	stats["resource_types"] = resourceTypes
 
-	return stats
+	return map[string]interface{}{
+		"total_locks":     len(m.locks),
+		"total_resources": len(resources),
+		"waiting":         waiting,
+		"expired":         m.expired,
+		"renewed":         m.renewed,
+		"lock_types":      lockTypes,
+		"resource_types":  resourceTypes,
+		"deadlocks":       deadlocks,
+	}
 }
 
-// Close shuts down the lock manager
+// Close shuts down the lock manager: waiting requests fail with
+// ErrManagerClosed and every lock is released
 func (m *Manager) Close() error {
-	m.logger.Info("Shutting down lock manager")
+	m.closeOnce.Do(func() {
+		m.logger.Info("Shutting down lock manager")
 
-	// Signal shutdown
-	close(m.shutdown)
+		// Signal shutdown and wait for the cleanup goroutine to finish
+		close(m.shutdown)
+		m.wg.Wait()
 
-	// Release all locks
-	m.mutex.Lock()
-	for lockID := range m.locks {
-		m.ReleaseLock(lockID)
-	}
-	m.mutex.Unlock()
+		m.resourcesMutex.RLock()
+		resources := make([]*ResourceLock, 0, len(m.resources))
+		for _, resourceLock := range m.resources {
+			resources = append(resources, resourceLock)
+		}
+		m.resourcesMutex.RUnlock()
+		for _, resourceLock := range resources {
+			resourceLock.mutex.Lock()
+			for _, request := range resourceLock.WaitQueue {
+				request.ResultChan <- &LockResult{Error: ErrManagerClosed}
+			}
+			resourceLock.WaitQueue = nil
+			resourceLock.mutex.Unlock()
+		}
 
-	// Wait for cleanup goroutine to finish
-	m.wg.Wait()
+		m.locksMutex.RLock()
+		locks := make([]*Lock, 0, len(m.locks))
+		for _, lock := range m.locks {
+			locks = append(locks, lock)
+		}
+		m.locksMutex.RUnlock()
+		for _, lock := range locks {
+			m.releaseLock(lock, time.Time{})
+		}
 
-	m.logger.Info("Lock manager shutdown complete")
+		m.logger.Info("Lock manager shutdown complete")
+	})
 	return nil
 }
@@ -0,0 +1,237 @@
+package locking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// newExpiringManager returns a manager whose locks expire after
+// maxDuration unless renewed, checked every few milliseconds
+func newExpiringManager(t *testing.T, maxDuration time.Duration) *Manager {
+	t.Helper()
+	m := NewManager(&LockConfig{
+		DefaultTimeout:   5 * time.Second,
+		MaxLockDuration:  maxDuration,
+		CleanupInterval:  5 * time.Millisecond,
+		MaxWaitQueueSize: 10,
+	}, zap.NewNop())
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// acquireAsync requests a lock in the background
+func acquireAsync(m *Manager, resourceID string, lockType LockType, owner string, timeout time.Duration) chan LockResult {
+	done := make(chan LockResult, 1)
+	go func() {
+		lock, err := m.AcquireLock(context.Background(), ResourceTypeFile, resourceID, lockType, owner, timeout)
+		done <- LockResult{Lock: lock, Error: err}
+	}()
+	return done
+}
+
+// awaitLock waits for a background request to be granted
+func awaitLock(t *testing.T, done chan LockResult, owner string) *Lock {
+	t.Helper()
+	select {
+	case result := <-done:
+		if result.Error != nil {
+			t.Fatalf("Expected %s to get the lock, got %v", owner, result.Error)
+		}
+		return result.Lock
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Expected %s to get the lock", owner)
+	}
+	return nil
+}
+
+func TestExpiredLockCleanup(t *testing.T) {
+	m := newExpiringManager(t, 30*time.Millisecond)
+	lock := acquire(t, m, "a.go", LockTypeWrite, "alice")
+
+	// The expired lock is released by the cleanup loop, which grants it on
+	bob := awaitLock(t, acquireAsync(m, "a.go", LockTypeWrite, "bob", 2*time.Second), "bob")
+	select {
+	case <-lock.Context.Done():
+	default:
+		t.Error("Expected the expired lock's context to be done")
+	}
+	if err := m.ReleaseLock(lock.ID); !errors.Is(err, ErrLockNotFound) {
+		t.Errorf("Expected releasing the expired lock to fail, got %v", err)
+	}
+	if _, err := m.RenewLock(lock.ID, 0); !errors.Is(err, ErrLockNotFound) {
+		t.Errorf("Expected renewing the expired lock to fail, got %v", err)
+	}
+	if err := m.ReleaseLock(bob.ID); err != nil && !errors.Is(err, ErrLockNotFound) {
+		t.Errorf("ReleaseLock failed: %v", err)
+	}
+	if expired := m.GetLockStats()["expired"].(int64); expired < 1 {
+		t.Errorf("Expected the expired lock to be counted, got %d", expired)
+	}
+
+	// Idle resources are dropped, and locked again from scratch
+	m.cleanupExpiredLocks()
+	if resources := m.GetLockStats()["total_resources"]; resources != 0 {
+		t.Errorf("Expected idle resources to be dropped, got %v", resources)
+	}
+	acquire(t, m, "a.go", LockTypeRead, "carol")
+}
+
+func TestRenewLock(t *testing.T) {
+	m := newExpiringManager(t, 60*time.Millisecond)
+	lock := acquire(t, m, "a.go", LockTypeWrite, "alice")
+
+	expiresAt, err := m.RenewLock(lock.ID, time.Hour)
+	if err != nil || expiresAt.After(time.Now().Add(60*time.Millisecond)) {
+		t.Errorf("Expected a renewal capped at the maximum lock duration, got %v, %v", expiresAt, err)
+	}
+
+	// A kept-alive lock outlives the maximum duration until it is let go
+	stop := m.KeepAlive(context.Background(), lock.ID)
+	time.Sleep(200 * time.Millisecond)
+	if err := lock.Context.Err(); err != nil {
+		t.Fatalf("Expected the kept-alive lock to be held, got %v", err)
+	}
+	if renewed := m.GetLockStats()["renewed"].(int64); renewed < 3 {
+		t.Errorf("Expected the lock to be renewed repeatedly, got %d renewals", renewed)
+	}
+	stop()
+	select {
+	case <-lock.Context.Done():
+	case <-time.After(time.Second):
+		t.Error("Expected the lock to expire once no longer kept alive")
+	}
+
+	// Keeping a lock alive stops with the operation's context
+	lock = acquire(t, m, "b.go", LockTypeWrite, "alice")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer m.KeepAlive(ctx, lock.ID)()
+	cancel()
+	select {
+	case <-lock.Context.Done():
+	case <-time.After(time.Second):
+		t.Error("Expected the lock of a cancelled operation to expire")
+	}
+}
+
+func TestQueueFairness(t *testing.T) {
+	m := newTestManager(t, true)
+	reader := acquire(t, m, "a.go", LockTypeRead, "r1")
+
+	// A reader does not overtake the writer waiting before it
+	writer := acquireAsync(m, "a.go", LockTypeWrite, "w", 2*time.Second)
+	waitQueued(t, m, "a.go", 1)
+	if _, err := m.AcquireLock(context.Background(), ResourceTypeFile, "a.go", LockTypeRead, "r2", 20*time.Millisecond); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("Expected the reader to wait behind the writer, got %v", err)
+	}
+	if err := m.ReleaseLock(reader.ID); err != nil {
+		t.Fatalf("ReleaseLock failed: %v", err)
+	}
+	writeLock := awaitLock(t, writer, "w")
+
+	// Waiting requests are granted in order: both readers together, then
+	// the writer behind them
+	r3 := acquireAsync(m, "a.go", LockTypeRead, "r3", 2*time.Second)
+	waitQueued(t, m, "a.go", 1)
+	r4 := acquireAsync(m, "a.go", LockTypeRead, "r4", 2*time.Second)
+	waitQueued(t, m, "a.go", 2)
+	w2 := acquireAsync(m, "a.go", LockTypeWrite, "w2", 2*time.Second)
+	waitQueued(t, m, "a.go", 3)
+	m.ReleaseLock(writeLock.ID)
+	readLocks := []*Lock{awaitLock(t, r3, "r3"), awaitLock(t, r4, "r4")}
+	select {
+	case result := <-w2:
+		t.Fatalf("Expected w2 to wait for the readers, got %+v", result)
+	default:
+	}
+	for _, lock := range readLocks {
+		m.ReleaseLock(lock.ID)
+	}
+	w2Lock := awaitLock(t, w2, "w2")
+
+	m.ReleaseLock(w2Lock.ID)
+
+	// A writer giving up lets the readers queued behind it in
+	r1 := acquire(t, m, "b.go", LockTypeRead, "r1")
+	w4 := acquireAsync(m, "b.go", LockTypeWrite, "w4", 30*time.Millisecond)
+	waitQueued(t, m, "b.go", 1)
+	r6 := acquireAsync(m, "b.go", LockTypeRead, "r6", 2*time.Second)
+	waitQueued(t, m, "b.go", 2)
+	if result := <-w4; !errors.Is(result.Error, ErrLockTimeout) {
+		t.Fatalf("Expected w4 to time out behind r1, got %+v", result)
+	}
+	m.ReleaseLock(awaitLock(t, r6, "r6").ID)
+	m.ReleaseLock(r1.ID)
+}
+
+func TestLockContention(t *testing.T) {
+	m := newTestManager(t, true)
+
+	var writers, readers atomic.Int32
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for n := 0; n < 10; n++ { // No more than the wait queue holds
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			lockType := LockTypeRead
+			if n%3 == 0 {
+				lockType = LockTypeWrite
+			}
+			lock, err := m.AcquireLock(context.Background(), ResourceTypeFile, "a.go", lockType, fmt.Sprintf("owner%d", n), 5*time.Second)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if lockType == LockTypeWrite {
+				if writers.Add(1) > 1 || readers.Load() > 0 {
+					errs <- errors.New("write lock granted alongside other locks")
+				}
+				time.Sleep(time.Millisecond)
+				writers.Add(-1)
+			} else {
+				readers.Add(1)
+				if writers.Load() > 0 {
+					errs <- errors.New("read lock granted alongside a write lock")
+				}
+				time.Sleep(time.Millisecond)
+				readers.Add(-1)
+			}
+			if err := m.ReleaseLock(lock.ID); err != nil {
+				errs <- err
+			}
+		}(n)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	stats := m.GetLockStats()
+	if stats["total_locks"] != 0 || stats["waiting"] != 0 {
+		t.Errorf("Expected every lock to be released, got %v", stats)
+	}
+}
+
+func TestCloseReleasesLocks(t *testing.T) {
+	m := NewManager(nil, zap.NewNop())
+	lock := acquire(t, m, "a.go", LockTypeExclusive, "alice")
+	waiting := acquireAsync(m, "a.go", LockTypeRead, "bob", 5*time.Second)
+	waitQueued(t, m, "a.go", 1)
+
+	m.Close()
+	if result := <-waiting; !errors.Is(result.Error, ErrManagerClosed) {
+		t.Errorf("Expected the waiting request to fail, got %+v", result)
+	}
+	if lock.Context.Err() == nil {
+		t.Error("Expected the held lock to be released")
+	}
+	m.Close()
+}
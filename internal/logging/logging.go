@@ -0,0 +1,167 @@
+// Package logging correlates the log lines of a request and writes rotated
+// log files.
+//
+// Every HTTP request and tool call carries a request ID in its context,
+// taken from an X-Request-ID header or generated, and loggers returned by
+// With add it to each line as the request_id field. The level of the
+// loggers built on Level can be changed while the server runs.
+package logging
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// RequestIDHeader is the header a request ID is read from and returned in
+const RequestIDHeader = "X-Request-ID"
+
+// Level is the level of the loggers of the process, changed at runtime by
+// the set_log_level tool
+var Level = zap.NewAtomicLevel()
+
+// validRequestID matches the request IDs accepted from clients, so a header
+// cannot inject arbitrary text into the logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// contextKey stores the request ID in a context
+type contextKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// RequestID returns the request ID of ctx, or "" when it has none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// NewRequestID returns a random request ID of 16 hex digits
+func NewRequestID() string {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// With returns logger with the request ID of ctx as its request_id field,
+// or logger itself when ctx has no request ID
+func With(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	if id := RequestID(ctx); id != "" {
+		return logger.With(zap.String("request_id", id))
+	}
+	return logger
+}
+
+// statusRecorder records the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+// Flush passes flushes on, which streamed responses rely on
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack hands the connection over to WebSocket upgrades
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not implement http.Hijacker")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the recorded writer to http.ResponseController
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Middleware gives each request the request ID of its X-Request-ID header,
+// or a new one when it has none or an invalid one, returns it in the same
+// header and logs the request at debug level when it is done, at warn level
+// when it failed with a server error
+func Middleware(logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = NewRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		started := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(WithRequestID(r.Context(), id)))
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK // Nothing written, net/http answers 200
+		}
+
+		level := zapcore.DebugLevel
+		if recorder.status >= http.StatusInternalServerError {
+			level = zapcore.WarnLevel
+		}
+		logger.Check(level, "HTTP request").Write(
+			zap.String("request_id", id),
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Int("status", recorder.status),
+			zap.Duration("duration", time.Since(started)))
+	})
+}
+
+// FileWriter returns a writer appending to the log file at path, which is
+// rotated once it reaches max_size megabytes. Rotated files are kept for
+// max_age days, at most max_backups of them, and compressed with compress.
+func FileWriter(path string, cfg config.LoggingConfig) (zapcore.WriteSyncer, error) {
+	// The file is only opened on the first write; an unwritable path is
+	// reported now rather than lost with the first lines
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	return zapcore.AddSync(&lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    cfg.MaxSize,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge,
+		Compress:   cfg.Compress,
+	}), nil
+}
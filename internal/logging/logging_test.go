@@ -0,0 +1,102 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func TestMiddleware(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	var seen string
+	handler := Middleware(zap.New(core), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+
+	tests := []struct {
+		header string
+		keep   bool
+	}{
+		{"abc-123", true},
+		{"", false},
+		{"two words", false},
+		{strings.Repeat("x", 129), false},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/health", nil)
+		if test.header != "" {
+			req.Header.Set(RequestIDHeader, test.header)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		id := recorder.Header().Get(RequestIDHeader)
+		if id != seen || id == "" || (id == test.header) != test.keep {
+			t.Errorf("Header %q: expected the request ID to be kept %v, got %q (handler saw %q)", test.header, test.keep, id, seen)
+		}
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/fail", nil))
+	failed := logs.FilterField(zap.Int("status", http.StatusInternalServerError)).All()
+	if len(failed) != 1 || failed[0].Level != zapcore.WarnLevel {
+		t.Errorf("Expected a server error to be logged at warn level, got %+v", failed)
+	}
+	if logs.FilterField(zap.Int("status", http.StatusOK)).Len() != len(tests) {
+		t.Errorf("Expected every request to be logged, got %+v", logs.All())
+	}
+}
+
+func TestWith(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(core)
+
+	With(context.Background(), logger).Info("without")
+	With(WithRequestID(context.Background(), "r1"), logger).Info("with")
+	entries := logs.All()
+	if _, ok := entries[0].ContextMap()["request_id"]; ok {
+		t.Errorf("Expected no request_id without a request ID, got %v", entries[0].ContextMap())
+	}
+	if entries[1].ContextMap()["request_id"] != "r1" {
+		t.Errorf("Expected request_id r1, got %v", entries[1].ContextMap())
+	}
+}
+
+func TestFileWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "indexer.log")
+	writer, err := FileWriter(path, config.LoggingConfig{MaxSize: 1, MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("FileWriter failed: %v", err)
+	}
+
+	// Writing past max_size rotates the file
+	line := []byte(strings.Repeat("x", 1023) + "\n")
+	for i := 0; i < 1100; i++ {
+		if _, err := writer.Write(line); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "indexer-*.log"))
+	if len(matches) != 1 {
+		t.Errorf("Expected one rotated file, got %v", matches)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() >= 1024*1024 {
+		t.Errorf("Expected the log file to start over, got %v (%v)", info, err)
+	}
+
+	if _, err := FileWriter(filepath.Join(t.TempDir(), "missing", "indexer.log"), config.LoggingConfig{}); err == nil {
+		t.Error("Expected a log file in a missing directory to be reported")
+	}
+}
@@ -0,0 +1,507 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+)
+
+// shutdownTimeout bounds how long a server gets to shut down before its
+// process is killed
+const shutdownTimeout = 5 * time.Second
+
+// Point is a 1-based line and column in a file, with columns counted in
+// characters as in package textpos
+type Point struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// FileRange is a span of a file in 1-based lines and columns
+type FileRange struct {
+	Path  string `json:"file_path"`
+	Start Point  `json:"start"`
+	End   Point  `json:"end"`
+}
+
+// FileDiagnostic is a diagnostic located in 1-based lines and columns
+type FileDiagnostic struct {
+	FileRange
+	Severity string `json:"severity"`
+	Source   string `json:"source,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Message  string `json:"message"`
+}
+
+// Hover is the documentation a server shows for a position
+type Hover struct {
+	Contents string     `json:"contents"`
+	Range    *FileRange `json:"range,omitempty"`
+}
+
+// document is a file the client has opened on the server
+type document struct {
+	version int
+	content string
+}
+
+// diagnosticsState holds the last diagnostics published for a document and
+// a channel closed when the next ones arrive
+type diagnosticsState struct {
+	diagnostics []Diagnostic
+	published   bool
+	next        chan struct{}
+}
+
+// Client talks to one language server process for one workspace root
+type Client struct {
+	Language string
+	Root     string
+
+	conn     *conn
+	cmd      *exec.Cmd
+	exited   chan struct{} // Closed when the server process exits; nil without a process
+	encoding string        // "utf-8" or "utf-16"
+	timeout  time.Duration
+	logger   *zap.Logger
+	started  time.Time
+
+	mu          sync.Mutex
+	documents   map[string]*document // Open documents by URI
+	diagnostics map[string]*diagnosticsState
+}
+
+// Start spawns the language server of server for the workspace at root and
+// initializes it
+func Start(ctx context.Context, server config.LSPServerConfig, language, root string, timeout time.Duration, logger *zap.Logger) (*Client, error) {
+	cmd := exec.Command(server.Command, server.Args...)
+	cmd.Dir = root
+	cmd.Stderr = zap.NewStdLog(logger.Named("lsp").With(zap.String("language", language))).Writer()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open language server stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open language server stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", server.Command, err)
+	}
+
+	client := newClient(&processStream{ReadCloser: stdout, WriteCloser: stdin}, language, root, timeout, logger)
+	client.cmd = cmd
+	client.exited = make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(client.exited)
+		client.conn.Close()
+	}()
+
+	initCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := client.initialize(initCtx, server.InitializationOptions); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to initialize %s: %w", server.Command, err)
+	}
+	return client, nil
+}
+
+// processStream joins the pipes of a server process into one stream
+type processStream struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+// Close closes both pipes
+func (p *processStream) Close() error {
+	err := p.WriteCloser.Close()
+	if readErr := p.ReadCloser.Close(); err == nil {
+		err = readErr
+	}
+	return err
+}
+
+// newClient wraps a connection to a language server over stream
+func newClient(stream io.ReadWriteCloser, language, root string, timeout time.Duration, logger *zap.Logger) *Client {
+	client := &Client{
+		Language:    language,
+		Root:        root,
+		encoding:    "utf-16",
+		timeout:     timeout,
+		logger:      logger,
+		started:     time.Now(),
+		documents:   make(map[string]*document),
+		diagnostics: make(map[string]*diagnosticsState),
+	}
+	client.conn = newConn(stream, client.handle)
+	return client
+}
+
+// initialize performs the initialize handshake
+func (c *Client) initialize(ctx context.Context, options map[string]any) error {
+	rootURI := PathToURI(c.Root)
+	params := initializeParams{
+		ProcessID:        os.Getpid(),
+		RootURI:          rootURI,
+		RootPath:         c.Root,
+		WorkspaceFolders: []workspaceFolder{{URI: rootURI, Name: filepath.Base(c.Root)}},
+		Capabilities:     clientCapabilities,
+	}
+	if len(options) > 0 {
+		params.InitializationOptions = options
+	}
+
+	var result initializeResult
+	if err := c.conn.Call(ctx, "initialize", params, &result); err != nil {
+		return err
+	}
+	if result.Capabilities.PositionEncoding == "utf-8" {
+		c.encoding = "utf-8"
+	}
+	return c.conn.Notify("initialized", struct{}{})
+}
+
+// handle answers what the server sends: diagnostics are recorded, and
+// requests for configuration, progress and registrations get empty answers
+func (c *Client) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var published publishDiagnosticsParams
+		if err := json.Unmarshal(params, &published); err == nil {
+			c.recordDiagnostics(published)
+		}
+	case "workspace/configuration":
+		var request struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(params, &request)
+		return make([]any, len(request.Items)), nil
+	case "window/logMessage", "window/showMessage":
+		var logged struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(params, &logged) == nil {
+			c.logger.Debug("Language server message", zap.String("language", c.Language), zap.String("message", logged.Message))
+		}
+	}
+	return nil, nil
+}
+
+// recordDiagnostics stores published diagnostics and wakes their waiters
+func (c *Client) recordDiagnostics(published publishDiagnosticsParams) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := c.diagnosticsState(published.URI)
+	state.diagnostics = published.Diagnostics
+	state.published = true
+	close(state.next)
+	state.next = make(chan struct{})
+}
+
+// diagnosticsState returns the diagnostics state of a document; callers
+// hold c.mu
+func (c *Client) diagnosticsState(uri string) *diagnosticsState {
+	state, ok := c.diagnostics[uri]
+	if !ok {
+		state = &diagnosticsState{next: make(chan struct{})}
+		c.diagnostics[uri] = state
+	}
+	return state
+}
+
+// Done is closed when the server exits or the connection breaks
+func (c *Client) Done() <-chan struct{} {
+	return c.conn.Done()
+}
+
+// Uptime returns how long the server has been running
+func (c *Client) Uptime() time.Duration {
+	return time.Since(c.started)
+}
+
+// Close shuts the server down, killing it if it does not exit in time
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	select {
+	case <-c.conn.Done():
+	default:
+		if err := c.conn.Call(ctx, "shutdown", nil, nil); err == nil {
+			c.conn.Notify("exit", nil)
+		}
+	}
+	c.conn.Close()
+
+	if c.exited != nil {
+		select {
+		case <-c.exited:
+		case <-ctx.Done():
+			return c.cmd.Process.Kill()
+		}
+	}
+	return nil
+}
+
+// sync opens a document on the server, or sends its new content if it
+// changed since it was opened, and returns its URI
+func (c *Client) sync(path, content string) (string, bool, error) {
+	uri := PathToURI(path)
+
+	c.mu.Lock()
+	doc, open := c.documents[uri]
+	switch {
+	case !open:
+		c.documents[uri] = &document{version: 1, content: content}
+	case doc.content != content:
+		doc.version++
+		doc.content = content
+	default:
+		c.mu.Unlock()
+		return uri, false, nil
+	}
+	version := c.documents[uri].version
+	c.mu.Unlock()
+
+	if !open {
+		return uri, true, c.conn.Notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{
+			URI:        uri,
+			LanguageID: languageID(c.Language, path),
+			Version:    version,
+			Text:       content,
+		}})
+	}
+	params := didChangeParams{TextDocument: versionedTextDocumentIdentifier{URI: uri, Version: version}}
+	params.ContentChanges = append(params.ContentChanges, struct {
+		Text string `json:"text"`
+	}{Text: content})
+	return uri, true, c.conn.Notify("textDocument/didChange", params)
+}
+
+// call sends a request bounded by the client's timeout
+func (c *Client) call(ctx context.Context, method string, params, result any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.conn.Call(ctx, method, params, result)
+}
+
+// positionParams syncs a document and builds the parameters of a request
+// at a 1-based line and column of it
+func (c *Client) positionParams(path, content string, point Point) (textDocumentPositionParams, error) {
+	uri, _, err := c.sync(path, content)
+	if err != nil {
+		return textDocumentPositionParams{}, err
+	}
+	line, _ := textpos.Split(content).Line(point.Line)
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Position:     Position{Line: point.Line - 1, Character: c.character(line, textpos.Offset(line, point.Column))},
+	}, nil
+}
+
+// Hover returns the documentation of the symbol at a point of a file, or nil
+// when the server has none
+func (c *Client) Hover(ctx context.Context, path, content string, point Point) (*Hover, error) {
+	params, err := c.positionParams(path, content, point)
+	if err != nil {
+		return nil, err
+	}
+	var result *hoverResult
+	if err := c.call(ctx, "textDocument/hover", params, &result); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+
+	hover := &Hover{Contents: hoverText(result.Contents)}
+	if result.Range != nil {
+		fileRange := c.fileRange(Location{URI: params.TextDocument.URI, Range: *result.Range})
+		hover.Range = &fileRange
+	}
+	return hover, nil
+}
+
+// Definition returns where the symbol at a point of a file is defined
+func (c *Client) Definition(ctx context.Context, path, content string, point Point) ([]FileRange, error) {
+	params, err := c.positionParams(path, content, point)
+	if err != nil {
+		return nil, err
+	}
+	var raw json.RawMessage
+	if err := c.call(ctx, "textDocument/definition", params, &raw); err != nil {
+		return nil, err
+	}
+	locations, err := parseLocations(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode definition: %w", err)
+	}
+	return c.fileRanges(locations), nil
+}
+
+// References returns the uses of the symbol at a point of a file, and its
+// declaration when includeDeclaration is set
+func (c *Client) References(ctx context.Context, path, content string, point Point, includeDeclaration bool) ([]FileRange, error) {
+	position, err := c.positionParams(path, content, point)
+	if err != nil {
+		return nil, err
+	}
+	params := referenceParams{textDocumentPositionParams: position}
+	params.Context.IncludeDeclaration = includeDeclaration
+
+	var locations []Location
+	if err := c.call(ctx, "textDocument/references", params, &locations); err != nil {
+		return nil, err
+	}
+	return c.fileRanges(locations), nil
+}
+
+// Diagnostics returns the diagnostics the server publishes for a file. When
+// the file is opened or changed, it waits up to wait for the server to
+// publish them; servers that publish nothing for a clean file leave the
+// result empty after that.
+func (c *Client) Diagnostics(ctx context.Context, path, content string, wait time.Duration) ([]FileDiagnostic, error) {
+	uri := PathToURI(path)
+	c.mu.Lock()
+	next := c.diagnosticsState(uri).next
+	c.mu.Unlock()
+
+	_, changed, err := c.sync(path, content)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	published := c.diagnosticsState(uri).published
+	c.mu.Unlock()
+	if changed || !published {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-next:
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.conn.Done():
+			return nil, ErrClosed
+		}
+	}
+
+	c.mu.Lock()
+	diagnostics := append([]Diagnostic(nil), c.diagnosticsState(uri).diagnostics...)
+	c.mu.Unlock()
+
+	results := make([]FileDiagnostic, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		var code string
+		if len(diagnostic.Code) > 0 {
+			var text string
+			if json.Unmarshal(diagnostic.Code, &text) != nil {
+				text = string(diagnostic.Code)
+			}
+			code = text
+		}
+		results = append(results, FileDiagnostic{
+			FileRange: c.fileRange(Location{URI: uri, Range: diagnostic.Range}),
+			Severity:  SeverityName(diagnostic.Severity),
+			Source:    diagnostic.Source,
+			Code:      code,
+			Message:   diagnostic.Message,
+		})
+	}
+	return results, nil
+}
+
+// fileRanges converts locations to 1-based file ranges
+func (c *Client) fileRanges(locations []Location) []FileRange {
+	ranges := make([]FileRange, 0, len(locations))
+	for _, location := range locations {
+		ranges = append(ranges, c.fileRange(location))
+	}
+	return ranges
+}
+
+// fileRange converts a location to 1-based lines and character columns,
+// reading the file when it is not open on the server
+func (c *Client) fileRange(location Location) FileRange {
+	path := URIToPath(location.URI)
+
+	c.mu.Lock()
+	var content string
+	doc, open := c.documents[location.URI]
+	if open {
+		content = doc.content
+	}
+	c.mu.Unlock()
+	if !open {
+		if data, err := os.ReadFile(path); err == nil {
+			content = string(data)
+		}
+	}
+
+	text := textpos.Split(content)
+	point := func(position Position) Point {
+		line, _ := text.Line(position.Line + 1)
+		return Point{Line: position.Line + 1, Column: textpos.Column(line, c.offset(line, position.Character))}
+	}
+	return FileRange{Path: path, Start: point(location.Range.Start), End: point(location.Range.End)}
+}
+
+// character converts a byte offset in a line to the server's position
+// encoding
+func (c *Client) character(line string, offset int) int {
+	if offset > len(line) {
+		offset = len(line)
+	}
+	if c.encoding == "utf-8" {
+		return offset
+	}
+	units := 0
+	for _, r := range line[:offset] {
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
+
+// offset converts a character in the server's position encoding to a byte
+// offset in a line
+func (c *Client) offset(line string, character int) int {
+	if c.encoding == "utf-8" {
+		return min(character, len(line))
+	}
+	units := 0
+	for pos := 0; pos < len(line); {
+		if units >= character {
+			return pos
+		}
+		r, size := utf8.DecodeRuneInString(line[pos:])
+		units += utf16.RuneLen(r)
+		pos += size
+	}
+	return len(line)
+}
+
+// languageID returns the language identifier of a document
+func languageID(language, path string) string {
+	switch filepath.Ext(path) {
+	case ".tsx":
+		return "typescriptreact"
+	case ".jsx":
+		return "javascriptreact"
+	}
+	return language
+}
@@ -0,0 +1,223 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// fakeServer answers a client over an in-memory pipe like a language server
+type fakeServer struct {
+	t        *testing.T
+	conn     net.Conn
+	reader   *bufio.Reader
+	encoding string
+	received chan *message // Notifications the client sent
+}
+
+func newFakeServer(t *testing.T, encoding string) (*fakeServer, *Client) {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	server := &fakeServer{
+		t:        t,
+		conn:     serverSide,
+		reader:   bufio.NewReader(serverSide),
+		encoding: encoding,
+		received: make(chan *message, 16),
+	}
+	go server.serve()
+
+	client := newClient(clientSide, "go", "/work", 5*time.Second, zap.NewNop())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.initialize(ctx, nil); err != nil {
+		t.Fatalf("Expected initialize to succeed, got %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func (s *fakeServer) send(msg any) {
+	body, _ := json.Marshal(msg)
+	io.WriteString(s.conn, "Content-Length: "+strconv.Itoa(len(body))+"\r\n\r\n")
+	s.conn.Write(body)
+}
+
+func (s *fakeServer) serve() {
+	for {
+		msg, err := readMessage(s.reader)
+		if err != nil {
+			return
+		}
+		if len(msg.ID) == 0 {
+			s.received <- msg
+			if msg.Method == "textDocument/didOpen" {
+				var params didOpenParams
+				json.Unmarshal(msg.Params, &params)
+				s.send(map[string]any{
+					"jsonrpc": "2.0",
+					"method":  "textDocument/publishDiagnostics",
+					"params": map[string]any{
+						"uri": params.TextDocument.URI,
+						"diagnostics": []map[string]any{{
+							"range":    Range{Start: Position{Line: 1, Character: 1}, End: Position{Line: 1, Character: 4}},
+							"severity": 1,
+							"source":   "compiler",
+							"message":  "undefined: foo",
+						}},
+					},
+				})
+			}
+			continue
+		}
+
+		var result any
+		switch msg.Method {
+		case "initialize":
+			result = map[string]any{"capabilities": map[string]any{"positionEncoding": s.encoding}}
+		case "shutdown":
+			result = nil
+		case "textDocument/hover":
+			var params textDocumentPositionParams
+			json.Unmarshal(msg.Params, &params)
+			result = map[string]any{
+				"contents": map[string]any{"kind": "markdown", "value": "func Greet()"},
+				"range":    Range{Start: params.Position, End: Position{Line: params.Position.Line, Character: params.Position.Character + 5}},
+			}
+		case "textDocument/definition":
+			var params textDocumentPositionParams
+			json.Unmarshal(msg.Params, &params)
+			result = []map[string]any{{
+				"targetUri":            params.TextDocument.URI,
+				"targetRange":          Range{},
+				"targetSelectionRange": Range{Start: Position{Line: 0, Character: 5}, End: Position{Line: 0, Character: 10}},
+			}}
+		default:
+			s.send(outgoingError{JSONRPC: "2.0", ID: msg.ID, Error: &ResponseError{Code: -32601, Message: "method not found"}})
+			continue
+		}
+		s.send(outgoingResult{JSONRPC: "2.0", ID: msg.ID, Result: result})
+	}
+}
+
+func TestClientHoverAndDefinition(t *testing.T) {
+	_, client := newFakeServer(t, "utf-16")
+	ctx := context.Background()
+	path := filepath.FromSlash("/work/main.go")
+	content := "func Greet() {}\n// é Greet\n"
+
+	hover, err := client.Hover(ctx, path, content, Point{Line: 2, Column: 6})
+	if err != nil {
+		t.Fatalf("Expected hover to succeed, got %v", err)
+	}
+	if hover == nil || hover.Contents != "func Greet()" {
+		t.Fatalf("Expected hover contents, got %+v", hover)
+	}
+	// Column 6 is after "// é " whatever the encoding; the range round-trips
+	if hover.Range == nil || hover.Range.Start != (Point{Line: 2, Column: 6}) || hover.Range.End != (Point{Line: 2, Column: 11}) {
+		t.Errorf("Expected hover range 2:6-2:11, got %+v", hover.Range)
+	}
+
+	definitions, err := client.Definition(ctx, path, content, Point{Line: 2, Column: 6})
+	if err != nil {
+		t.Fatalf("Expected definition to succeed, got %v", err)
+	}
+	want := FileRange{Path: path, Start: Point{Line: 1, Column: 6}, End: Point{Line: 1, Column: 11}}
+	if len(definitions) != 1 || definitions[0] != want {
+		t.Errorf("Expected definition %+v, got %+v", want, definitions)
+	}
+}
+
+func TestClientDiagnostics(t *testing.T) {
+	server, client := newFakeServer(t, "utf-8")
+	path := filepath.FromSlash("/work/main.go")
+
+	diagnostics, err := client.Diagnostics(context.Background(), path, "package main\n\tfoo()\n", time.Second)
+	if err != nil {
+		t.Fatalf("Expected diagnostics to succeed, got %v", err)
+	}
+	if len(diagnostics) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %+v", diagnostics)
+	}
+	got := diagnostics[0]
+	if got.Severity != "error" || got.Message != "undefined: foo" || got.Start != (Point{Line: 2, Column: 2}) {
+		t.Errorf("Unexpected diagnostic %+v", got)
+	}
+
+	// The document is opened once and not resent while unchanged
+	opened := 0
+	for len(server.received) > 0 {
+		if msg := <-server.received; msg.Method == "textDocument/didOpen" {
+			opened++
+		}
+	}
+	if opened != 1 {
+		t.Errorf("Expected the document to be opened once, got %d", opened)
+	}
+}
+
+func TestClientEncodingConversion(t *testing.T) {
+	client := &Client{encoding: "utf-16"}
+	line := "a😀b"
+	// The emoji takes 4 bytes but 2 UTF-16 code units
+	if got := client.character(line, 5); got != 3 {
+		t.Errorf("Expected UTF-16 character 3, got %d", got)
+	}
+	if got := client.offset(line, 3); got != 5 {
+		t.Errorf("Expected byte offset 5, got %d", got)
+	}
+
+	client.encoding = "utf-8"
+	if got := client.character(line, 5); got != 5 {
+		t.Errorf("Expected UTF-8 character 5, got %d", got)
+	}
+}
+
+func TestClientClosedConnection(t *testing.T) {
+	server, client := newFakeServer(t, "utf-8")
+	server.conn.Close()
+
+	select {
+	case <-client.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the client to notice the closed connection")
+	}
+	_, err := client.Hover(context.Background(), "/work/main.go", "package main\n", Point{Line: 1, Column: 1})
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestManagerWithoutServer(t *testing.T) {
+	manager := NewManager(config.LSPConfig{
+		Enabled: true,
+		Servers: map[string]config.LSPServerConfig{
+			"go": {Command: "code-indexer-no-such-language-server"},
+		},
+	}, zap.NewNop())
+
+	for _, language := range []string{"go", "rust"} {
+		if _, err := manager.Client(context.Background(), "/work", language); !errors.Is(err, ErrNoServer) {
+			t.Errorf("Expected ErrNoServer for %s, got %v", language, err)
+		}
+	}
+
+	manager = NewManager(config.LSPConfig{Enabled: false}, zap.NewNop())
+	if manager.Available("go") {
+		t.Error("Expected no server when language servers are disabled")
+	}
+	if running := manager.Running(); len(running) != 0 {
+		t.Errorf("Expected no running servers, got %+v", running)
+	}
+}
@@ -0,0 +1,258 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned for requests to a language server whose connection
+// has been closed or whose process has exited
+var ErrClosed = errors.New("language server connection closed")
+
+// message is a JSON-RPC 2.0 request, notification or response as read from
+// the connection
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+type outgoingRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      *int64 `json:"id,omitempty"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+type outgoingResult struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type outgoingError struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *ResponseError  `json:"error"`
+}
+
+// handler answers the requests and notifications a server sends. Requests
+// get the returned value as their result, or the error.
+type handler func(method string, params json.RawMessage) (any, error)
+
+// conn is a JSON-RPC connection framed with Content-Length headers, as the
+// Language Server Protocol uses over stdio
+type conn struct {
+	stream  io.ReadWriteCloser
+	handle  handler
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	done    chan struct{}
+	err     error // Why the connection closed
+}
+
+// newConn starts reading messages from stream, passing the server's
+// requests and notifications to handle
+func newConn(stream io.ReadWriteCloser, handle handler) *conn {
+	c := &conn{
+		stream:  stream,
+		handle:  handle,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+// Call sends a request and decodes its result into result, which may be nil
+// to ignore it. A cancelled context cancels the request on the server.
+func (c *conn) Call(ctx context.Context, method string, params, result any) error {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	reply := make(chan *message, 1)
+	c.pending[id] = reply
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(outgoingRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return err
+	}
+
+	select {
+	case response := <-reply:
+		if response.Error != nil {
+			return response.Error
+		}
+		if result == nil || len(response.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("failed to decode %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		c.Notify("$/cancelRequest", map[string]int64{"id": id})
+		return ctx.Err()
+	case <-c.done:
+		return c.closeErr()
+	}
+}
+
+// Notify sends a notification
+func (c *conn) Notify(method string, params any) error {
+	return c.write(outgoingRequest{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// Close closes the stream; pending calls fail with ErrClosed
+func (c *conn) Close() error {
+	err := c.stream.Close()
+	c.shutdown(ErrClosed)
+	return err
+}
+
+// Done is closed when the connection closes
+func (c *conn) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *conn) closeErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// shutdown records why the connection closed, once
+func (c *conn) shutdown(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
+
+// write sends a message with its Content-Length header
+func (c *conn) write(msg any) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.stream, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	if _, err := c.stream.Write(body); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+// readLoop reads messages until the stream ends, delivering responses to
+// their callers and dispatching requests and notifications
+func (c *conn) readLoop() {
+	reader := bufio.NewReader(c.stream)
+	for {
+		msg, err := readMessage(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) {
+				err = ErrClosed
+			} else {
+				err = fmt.Errorf("%w: %v", ErrClosed, err)
+			}
+			c.shutdown(err)
+			return
+		}
+
+		switch {
+		case msg.Method != "" && len(msg.ID) > 0:
+			go c.reply(msg)
+		case msg.Method != "":
+			c.handle(msg.Method, msg.Params)
+		default:
+			id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			reply, ok := c.pending[id]
+			c.mu.Unlock()
+			if ok {
+				reply <- msg
+			}
+		}
+	}
+}
+
+// reply answers a request from the server
+func (c *conn) reply(msg *message) {
+	result, err := c.handle(msg.Method, msg.Params)
+	if err != nil {
+		responseErr, ok := err.(*ResponseError)
+		if !ok {
+			responseErr = &ResponseError{Code: -32603, Message: err.Error()}
+		}
+		c.write(outgoingError{JSONRPC: "2.0", ID: msg.ID, Error: responseErr})
+		return
+	}
+	c.write(outgoingResult{JSONRPC: "2.0", ID: msg.ID, Result: result})
+}
+
+// readMessage reads one framed message
+func readMessage(reader *bufio.Reader) (*message, error) {
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message without Content-Length")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	return &msg, nil
+}
@@ -0,0 +1,213 @@
+// Package lsp runs language servers for indexed repositories and asks them
+// for hover information, definitions, references and diagnostics over the
+// Language Server Protocol. A server is started per workspace root and
+// language the first time it is needed and kept running until it is
+// restarted or the manager is closed.
+package lsp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// ErrNoServer is returned when no language server is enabled, configured or
+// installed for a language
+var ErrNoServer = errors.New("no language server available")
+
+// ServerStatus describes a running language server
+type ServerStatus struct {
+	Language string  `json:"language"`
+	Root     string  `json:"root"`
+	Command  string  `json:"command"`
+	Uptime   float64 `json:"uptime_seconds"`
+}
+
+// serverKey identifies the server of a workspace and language
+type serverKey struct {
+	root     string
+	language string
+}
+
+// Manager starts and keeps the language servers of the configured
+// languages, one per workspace root
+type Manager struct {
+	config config.LSPConfig
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	clients  map[serverKey]*Client
+	starting map[serverKey]chan struct{} // Closed when a server being started is ready or failed
+}
+
+// NewManager creates a manager for the servers in cfg
+func NewManager(cfg config.LSPConfig, logger *zap.Logger) *Manager {
+	return &Manager{
+		config:   cfg,
+		logger:   logger,
+		clients:  make(map[serverKey]*Client),
+		starting: make(map[serverKey]chan struct{}),
+	}
+}
+
+// Available reports whether a server is enabled, configured and installed
+// for language
+func (m *Manager) Available(language string) bool {
+	_, err := m.serverConfig(language)
+	return err == nil
+}
+
+// serverConfig returns the server configured for language, or ErrNoServer
+func (m *Manager) serverConfig(language string) (config.LSPServerConfig, error) {
+	if !m.config.Enabled {
+		return config.LSPServerConfig{}, fmt.Errorf("%w: language servers are disabled (lsp.enabled)", ErrNoServer)
+	}
+	server, ok := m.config.Servers[language]
+	if !ok || server.Command == "" {
+		return config.LSPServerConfig{}, fmt.Errorf("%w: none is configured for %s", ErrNoServer, language)
+	}
+	if _, err := exec.LookPath(server.Command); err != nil {
+		return config.LSPServerConfig{}, fmt.Errorf("%w: %s is not installed", ErrNoServer, server.Command)
+	}
+	return server, nil
+}
+
+// Client returns the running server for language in the workspace at root,
+// starting it if needed. Servers that exited are started again.
+func (m *Manager) Client(ctx context.Context, root, language string) (*Client, error) {
+	server, err := m.serverConfig(language)
+	if err != nil {
+		return nil, err
+	}
+	key := serverKey{root: filepath.Clean(root), language: language}
+
+	for {
+		m.mu.Lock()
+		if client, ok := m.clients[key]; ok {
+			select {
+			case <-client.Done():
+				delete(m.clients, key)
+				m.logger.Warn("Language server exited, restarting", zap.String("language", language), zap.String("root", key.root))
+			default:
+				m.mu.Unlock()
+				return client, nil
+			}
+		}
+		if starting, ok := m.starting[key]; ok {
+			m.mu.Unlock()
+			select {
+			case <-starting:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		starting := make(chan struct{})
+		m.starting[key] = starting
+		m.mu.Unlock()
+
+		m.logger.Info("Starting language server",
+			zap.String("language", language),
+			zap.String("command", server.Command),
+			zap.String("root", key.root))
+		client, err := Start(ctx, server, language, key.root, m.timeout(), m.logger)
+
+		m.mu.Lock()
+		delete(m.starting, key)
+		close(starting)
+		if err == nil {
+			m.clients[key] = client
+		}
+		m.mu.Unlock()
+		return client, err
+	}
+}
+
+// timeout returns how long requests may take
+func (m *Manager) timeout() time.Duration {
+	if m.config.TimeoutSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(m.config.TimeoutSeconds) * time.Second
+}
+
+// Timeout returns how long the servers get to answer a request
+func (m *Manager) Timeout() time.Duration {
+	return m.timeout()
+}
+
+// Restart stops the running servers of a workspace root and language; an
+// empty root or language matches all. Servers start again on their next
+// use. It returns the servers that were stopped.
+func (m *Manager) Restart(root, language string) []ServerStatus {
+	if root != "" {
+		root = filepath.Clean(root)
+	}
+
+	m.mu.Lock()
+	var stopped []*Client
+	for key, client := range m.clients {
+		if (root == "" || key.root == root) && (language == "" || key.language == language) {
+			stopped = append(stopped, client)
+			delete(m.clients, key)
+		}
+	}
+	m.mu.Unlock()
+
+	statuses := make([]ServerStatus, 0, len(stopped))
+	for _, client := range stopped {
+		statuses = append(statuses, m.status(client))
+		if err := client.Close(); err != nil {
+			m.logger.Warn("Failed to stop language server", zap.String("language", client.Language), zap.Error(err))
+		}
+	}
+	sortStatuses(statuses)
+	return statuses
+}
+
+// Running lists the running servers
+func (m *Manager) Running() []ServerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	statuses := make([]ServerStatus, 0, len(m.clients))
+	for _, client := range m.clients {
+		statuses = append(statuses, m.status(client))
+	}
+	sortStatuses(statuses)
+	return statuses
+}
+
+// Close stops every server
+func (m *Manager) Close() {
+	m.Restart("", "")
+}
+
+// status describes a client's server
+func (m *Manager) status(client *Client) ServerStatus {
+	return ServerStatus{
+		Language: client.Language,
+		Root:     client.Root,
+		Command:  m.config.Servers[client.Language].Command,
+		Uptime:   client.Uptime().Seconds(),
+	}
+}
+
+// sortStatuses orders statuses by root, then language
+func sortStatuses(statuses []ServerStatus) {
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Root != statuses[j].Root {
+			return statuses[i].Root < statuses[j].Root
+		}
+		return statuses[i].Language < statuses[j].Language
+	})
+}
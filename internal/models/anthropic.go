@@ -0,0 +1,163 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// anthropicVersion is the Messages API version requests are made against
+const anthropicVersion = "2023-06-01"
+
+// AnthropicProvider completes prompts through the Anthropic Messages API
+type AnthropicProvider struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+// NewAnthropicProvider creates a provider for the API at baseURL
+func NewAnthropicProvider(baseURL, apiKey string, client *http.Client) *AnthropicProvider {
+	return &AnthropicProvider{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+	}
+}
+
+// Name implements Provider
+func (p *AnthropicProvider) Name() string {
+	return ProviderAnthropic
+}
+
+type anthropicRequest struct {
+	Model       string        `json:"model"`
+	System      string        `json:"system,omitempty"`
+	Messages    []chatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens"`
+	Temperature float64       `json:"temperature"`
+	Stream      bool          `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// Complete implements Provider. The Messages API requires a token limit, so
+// requests without one are sent with a limit of 1024.
+func (p *AnthropicProvider) Complete(ctx context.Context, request *CompletionRequest) (*Completion, error) {
+	body, headers := p.request(request)
+
+	var resp anthropicResponse
+	if err := postJSON(ctx, p.client, ProviderAnthropic, p.baseURL+"/v1/messages", headers, body, &resp); err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	model := resp.Model
+	if model == "" {
+		model = request.Model
+	}
+	return &Completion{
+		Text:         text.String(),
+		Model:        model,
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+	}, nil
+}
+
+// Stream implements StreamingProvider, reading the server-sent events of a
+// streamed message: the text of its content_block_delta events, the input
+// tokens of message_start and the output tokens of message_delta
+func (p *AnthropicProvider) Stream(ctx context.Context, request *CompletionRequest, fn StreamFunc) (*Completion, error) {
+	body, headers := p.request(request)
+	body.Stream = true
+	resp, err := post(ctx, p.client, ProviderAnthropic, p.baseURL+"/v1/messages", headers, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	completion := &Completion{Model: request.Model}
+	var text strings.Builder
+	err = readStream(resp.Body, ProviderAnthropic, func(line []byte) error {
+		data, ok := eventData(line)
+		if !ok {
+			return nil
+		}
+		var event struct {
+			Type    string            `json:"type"`
+			Message anthropicResponse `json:"message"`
+			Delta   struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to parse %s stream event: %w", ProviderAnthropic, err)
+		}
+		switch event.Type {
+		case "message_start":
+			if event.Message.Model != "" {
+				completion.Model = event.Message.Model
+			}
+			completion.InputTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				text.WriteString(event.Delta.Text)
+				fn(event.Delta.Text)
+			}
+		case "message_delta":
+			completion.OutputTokens = event.Usage.OutputTokens
+		case "error":
+			return fmt.Errorf("%s API failed while streaming: %s", ProviderAnthropic, event.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	completion.Text = text.String()
+	return completion, nil
+}
+
+// request returns the body and headers of a Messages API request
+func (p *AnthropicProvider) request(request *CompletionRequest) (anthropicRequest, map[string]string) {
+	maxTokens := request.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 1024
+	}
+	body := anthropicRequest{
+		Model:       request.Model,
+		System:      request.System,
+		Messages:    []chatMessage{{Role: "user", Content: request.Prompt}},
+		MaxTokens:   maxTokens,
+		Temperature: request.Temperature,
+	}
+	headers := map[string]string{"anthropic-version": anthropicVersion}
+	if p.apiKey != "" {
+		headers["x-api-key"] = p.apiKey
+	}
+	return body, headers
+}
@@ -13,12 +13,25 @@ import (
 	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
-// Engine represents a simple AI model engine
+// Tools whose model can be chosen in the tool_models configuration
+const (
+	ToolGenerateCode  = "generate_code"
+	ToolAnalyzeCode   = "analyze_code"
+	ToolExplainCode   = "explain_code"
+	ToolSummarizeDiff = "summarize_diff"
+	ToolGenerateTests = "generate_tests"
+	ToolAskCodebase   = "ask_codebase"
+)
+
+// Engine represents a simple AI model engine. Requests go to the language
+// model API of the configured provider; with the builtin provider they are
+// answered by templates and heuristics.
 type Engine struct {
-	config  *config.ModelsConfig
-	logger  *zap.Logger
-	indexer *indexer.Indexer
-	enabled bool
+	config   *config.ModelsConfig
+	logger   *zap.Logger
+	indexer  *indexer.Indexer
+	enabled  bool
+	provider Provider // Nil for the builtin provider
 }
 
 // NewEngine creates a new model engine
@@ -41,8 +54,15 @@ func NewEngine(cfg *config.ModelsConfig, indexer *indexer.Indexer, logger *zap.L
 		indexer: indexer,
 		enabled: true,
 	}
+	if cfg.Provider != "" && cfg.Provider != ProviderBuiltin {
+		provider, err := NewProvider(cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		engine.provider = provider
+	}
 
-	logger.Info("Models engine initialized successfully")
+	logger.Info("Models engine initialized successfully", zap.String("provider", engine.ProviderName()))
 	return engine, nil
 }
 
@@ -51,6 +71,50 @@ func (e *Engine) IsEnabled() bool {
 	return e.enabled
 }
 
+// ProviderName returns the name of the provider answering requests
+func (e *Engine) ProviderName() string {
+	if e.provider == nil {
+		return ProviderBuiltin
+	}
+	return e.provider.Name()
+}
+
+// ModelFor returns the model a tool's requests are sent to: the one
+// configured for the tool, the default model otherwise
+func (e *Engine) ModelFor(tool string) string {
+	if model := e.config.ToolModels[tool]; model != "" {
+		return model
+	}
+	return e.config.DefaultModel
+}
+
+// complete sends a prompt to the provider, with the model, token limit and
+// temperature configured for tool. Under WithStream the answer is streamed.
+func (e *Engine) complete(ctx context.Context, tool, system, prompt string) (*Completion, error) {
+	request := &CompletionRequest{
+		Model:       e.ModelFor(tool),
+		System:      system,
+		Prompt:      prompt,
+		MaxTokens:   e.config.MaxTokens,
+		Temperature: e.config.Temperature,
+	}
+	var completion *Completion
+	var err error
+	if fn := streamFunc(ctx); fn != nil {
+		if streamer, ok := e.provider.(StreamingProvider); ok {
+			completion, err = streamer.Stream(ctx, request, fn)
+		} else if completion, err = e.provider.Complete(ctx, request); err == nil {
+			fn(completion.Text)
+		}
+	} else {
+		completion, err = e.provider.Complete(ctx, request)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", request.Model, err)
+	}
+	return completion, nil
+}
+
 // GenerateCode generates code using AI models
 func (e *Engine) GenerateCode(ctx context.Context, prompt string, language string) (*types.CodeGeneration, error) {
 	if !e.enabled {
@@ -61,6 +125,10 @@ func (e *Engine) GenerateCode(ctx context.Context, prompt string, language strin
 		zap.String("prompt", prompt),
 		zap.String("language", language))
 
+	if e.provider != nil {
+		return e.generateCodeWithProvider(ctx, prompt, language)
+	}
+
 	// Simple model-based code generation
 	code := e.generateCodeFromPrompt(prompt, language)
 
@@ -83,8 +151,10 @@ func (e *Engine) GenerateCode(ctx context.Context, prompt string, language strin
 	return result, nil
 }
 
-// AnalyzeCode analyzes code using AI models
-func (e *Engine) AnalyzeCode(ctx context.Context, code string, language string) (*types.CodeAnalysis, error) {
+// AnalyzeCode analyzes code using AI models. repositoryContext holds code
+// of the repository related to it, in Markdown, and is given to the model
+// along with the code; the builtin provider does not read it.
+func (e *Engine) AnalyzeCode(ctx context.Context, code string, language string, repositoryContext string) (*types.CodeAnalysis, error) {
 	if !e.enabled {
 		return nil, fmt.Errorf("models engine is disabled")
 	}
@@ -93,6 +163,10 @@ func (e *Engine) AnalyzeCode(ctx context.Context, code string, language string)
 		zap.String("language", language),
 		zap.Int("code_length", len(code)))
 
+	if e.provider != nil {
+		return e.analyzeCodeWithProvider(ctx, code, language, repositoryContext)
+	}
+
 	// Simple model-based code analysis
 	analysis := e.analyzeCodeWithModel(code, language)
 
@@ -114,8 +188,9 @@ func (e *Engine) AnalyzeCode(ctx context.Context, code string, language string)
 	return result, nil
 }
 
-// ExplainCode explains code using AI models
-func (e *Engine) ExplainCode(ctx context.Context, code string, language string) (*types.CodeExplanation, error) {
+// ExplainCode explains code using AI models. repositoryContext is given to
+// the model as in AnalyzeCode.
+func (e *Engine) ExplainCode(ctx context.Context, code string, language string, repositoryContext string) (*types.CodeExplanation, error) {
 	if !e.enabled {
 		return nil, fmt.Errorf("models engine is disabled")
 	}
@@ -123,6 +198,10 @@ func (e *Engine) ExplainCode(ctx context.Context, code string, language string)
 	e.logger.Info("Explaining code",
 		zap.String("language", language))
 
+	if e.provider != nil {
+		return e.explainCodeWithProvider(ctx, code, language, repositoryContext)
+	}
+
 	// Simple model-based code explanation
 	explanation := e.explainCodeWithModel(code, language)
 
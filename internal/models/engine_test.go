@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// newProviderEngine returns an engine whose openai provider answers every
+// request with the given text, recording the models requested
+func newProviderEngine(t *testing.T, answer string, models *[]string) *Engine {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body openAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		*models = append(*models, body.Model)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": answer}}},
+			"usage":   map[string]int{"prompt_tokens": 20, "completion_tokens": 10},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.ModelsConfig{
+		Enabled:        true,
+		Provider:       ProviderOpenAI,
+		DefaultModel:   "big-model",
+		ToolModels:     map[string]string{ToolExplainCode: "small-model"},
+		BaseURL:        server.URL,
+		TimeoutSeconds: 5,
+		MaxTokens:      256,
+	}
+	engine, err := NewEngine(cfg, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	return engine
+}
+
+func TestEngineGenerateCodeWithProvider(t *testing.T) {
+	var models []string
+	engine := newProviderEngine(t, "Here you go:\n```go\nfunc Add(a, b int) int {\n\treturn a + b\n}\n```\n", &models)
+
+	result, err := engine.GenerateCode(context.Background(), "add two ints", "go")
+	if err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	if result.GeneratedCode != "func Add(a, b int) int {\n\treturn a + b\n}" {
+		t.Errorf("Expected the fenced code only, got %q", result.GeneratedCode)
+	}
+	if result.Model != "big-model" || result.Metadata["tokens_used"] != 30 || result.Metadata["provider"] != ProviderOpenAI {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
+
+func TestEngineAnalyzeCodeWithProvider(t *testing.T) {
+	var models []string
+	answer := "```json\n" + `{"summary":"Adds numbers","quality_score":9,"suggestions":["Document it"],"issues":[],"complexity":"Low"}` + "\n```"
+	engine := newProviderEngine(t, answer, &models)
+
+	result, err := engine.AnalyzeCode(context.Background(), "func Add(a, b int) int { return a + b }", "go", "")
+	if err != nil {
+		t.Fatalf("AnalyzeCode failed: %v", err)
+	}
+	if result.Summary != "Adds numbers" || result.Quality != 9 || len(result.Suggestions) != 1 || result.Complexity != "Low" {
+		t.Errorf("Unexpected analysis %+v", result)
+	}
+}
+
+func TestEngineExplainCodeWithProvider(t *testing.T) {
+	var models []string
+	engine := newProviderEngine(t, "It adds two numbers.", &models)
+
+	result, err := engine.ExplainCode(context.Background(), "func Add(a, b int) int { return a + b }", "go", "")
+	if err != nil {
+		t.Fatalf("ExplainCode failed: %v", err)
+	}
+	// A prose answer becomes the explanation
+	if result.Explanation != "It adds two numbers." {
+		t.Errorf("Unexpected explanation %q", result.Explanation)
+	}
+	if len(models) != 1 || models[0] != "small-model" || result.Model != "small-model" {
+		t.Errorf("Expected the model configured for explain_code, got %v", models)
+	}
+}
+
+func TestEngineBuiltinProvider(t *testing.T) {
+	cfg := &config.ModelsConfig{Enabled: true, Provider: ProviderBuiltin, DefaultModel: "code-assistant-v1"}
+	engine, err := NewEngine(cfg, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	if engine.ProviderName() != ProviderBuiltin {
+		t.Errorf("Expected the builtin provider, got %s", engine.ProviderName())
+	}
+
+	result, err := engine.GenerateCode(context.Background(), "an http server", "go")
+	if err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	if !strings.Contains(result.GeneratedCode, "ListenAndServe") {
+		t.Errorf("Expected the builtin template, got %q", result.GeneratedCode)
+	}
+}
+
+func TestCodePromptRepositoryContext(t *testing.T) {
+	if got := codePrompt("x := 1\n", "go", ""); got != "```go\nx := 1\n```" {
+		t.Errorf("Unexpected prompt without context %q", got)
+	}
+	got := codePrompt("x := Limit()", "go", "## Referenced: function Limit (limits.go:3-5)")
+	if !strings.HasPrefix(got, "# Repository context\n\n## Referenced: function Limit") || !strings.HasSuffix(got, "# Code\n\n```go\nx := Limit()\n```") {
+		t.Errorf("Expected the context before the code, got %q", got)
+	}
+}
+
+func TestEngineStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"```go\\nfunc \"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"Add() {}\\n```\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	engine, err := NewEngine(&config.ModelsConfig{
+		Enabled:        true,
+		Provider:       ProviderOpenAI,
+		DefaultModel:   "big-model",
+		BaseURL:        server.URL,
+		TimeoutSeconds: 5,
+	}, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+
+	var pieces []string
+	ctx := WithStream(context.Background(), func(text string) { pieces = append(pieces, text) })
+	result, err := engine.GenerateCode(ctx, "add", "go")
+	if err != nil {
+		t.Fatalf("GenerateCode failed: %v", err)
+	}
+	if len(pieces) != 2 || result.GeneratedCode != "func Add() {}" {
+		t.Errorf("Unexpected pieces %q and code %q", pieces, result.GeneratedCode)
+	}
+}
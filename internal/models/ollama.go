@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OllamaProvider completes prompts through the /api/chat endpoint of an
+// Ollama server
+type OllamaProvider struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewOllamaProvider creates a provider for the server at baseURL
+func NewOllamaProvider(baseURL string, client *http.Client) *OllamaProvider {
+	return &OllamaProvider{
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// Name implements Provider
+func (p *OllamaProvider) Name() string {
+	return ProviderOllama
+}
+
+type ollamaRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	Options  ollamaOptions `json:"options"`
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+type ollamaResponse struct {
+	Model           string      `json:"model"`
+	Message         chatMessage `json:"message"`
+	Done            bool        `json:"done"`  // Last line of a stream
+	Error           string      `json:"error"` // Failure in the middle of a stream
+	PromptEvalCount int         `json:"prompt_eval_count"`
+	EvalCount       int         `json:"eval_count"`
+}
+
+// Complete implements Provider
+func (p *OllamaProvider) Complete(ctx context.Context, request *CompletionRequest) (*Completion, error) {
+	body := ollamaRequest{
+		Model:    request.Model,
+		Messages: chatMessages(request),
+		Options: ollamaOptions{
+			Temperature: request.Temperature,
+			NumPredict:  request.MaxTokens,
+		},
+	}
+
+	var resp ollamaResponse
+	if err := postJSON(ctx, p.client, ProviderOllama, p.baseURL+"/api/chat", nil, body, &resp); err != nil {
+		return nil, err
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = request.Model
+	}
+	return &Completion{
+		Text:         resp.Message.Content,
+		Model:        model,
+		InputTokens:  resp.PromptEvalCount,
+		OutputTokens: resp.EvalCount,
+	}, nil
+}
+
+// Stream implements StreamingProvider, reading the JSON lines of a streamed
+// chat until the one marked done, which carries the token counts
+func (p *OllamaProvider) Stream(ctx context.Context, request *CompletionRequest, fn StreamFunc) (*Completion, error) {
+	body := ollamaRequest{
+		Model:    request.Model,
+		Messages: chatMessages(request),
+		Stream:   true,
+		Options: ollamaOptions{
+			Temperature: request.Temperature,
+			NumPredict:  request.MaxTokens,
+		},
+	}
+	resp, err := post(ctx, p.client, ProviderOllama, p.baseURL+"/api/chat", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	completion := &Completion{Model: request.Model}
+	var text strings.Builder
+	err = readStream(resp.Body, ProviderOllama, func(line []byte) error {
+		var event ollamaResponse
+		if err := json.Unmarshal(line, &event); err != nil {
+			return fmt.Errorf("failed to parse %s stream line: %w", ProviderOllama, err)
+		}
+		if event.Error != "" {
+			return fmt.Errorf("%s API failed while streaming: %s", ProviderOllama, event.Error)
+		}
+		if event.Model != "" {
+			completion.Model = event.Model
+		}
+		if event.Message.Content != "" {
+			text.WriteString(event.Message.Content)
+			fn(event.Message.Content)
+		}
+		if event.Done {
+			completion.InputTokens = event.PromptEvalCount
+			completion.OutputTokens = event.EvalCount
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	completion.Text = text.String()
+	return completion, nil
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// OpenAIProvider completes prompts through an OpenAI-compatible
+// /chat/completions endpoint, which hosted APIs as well as local servers
+// such as llama.cpp and vLLM provide
+type OpenAIProvider struct {
+	name    string
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+// NewOpenAIProvider creates a provider for the API at baseURL, reporting
+// itself under name
+func NewOpenAIProvider(name, baseURL, apiKey string, client *http.Client) *OpenAIProvider {
+	return &OpenAIProvider{
+		name:    name,
+		client:  client,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+	}
+}
+
+// Name implements Provider
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	Temperature float64       `json:"temperature"`
+	Stream      bool          `json:"stream,omitempty"`
+	// StreamOptions asks for the token usage in the last event of a stream
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message chatMessage `json:"message"`
+		Delta   chatMessage `json:"delta"` // Text added by an event of a stream
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// Complete implements Provider
+func (p *OpenAIProvider) Complete(ctx context.Context, request *CompletionRequest) (*Completion, error) {
+	body := openAIRequest{
+		Model:       request.Model,
+		Messages:    chatMessages(request),
+		MaxTokens:   request.MaxTokens,
+		Temperature: request.Temperature,
+	}
+
+	var resp openAIResponse
+	if err := postJSON(ctx, p.client, p.name, p.baseURL+"/chat/completions", p.headers(), body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("%s API returned no choices", p.name)
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = request.Model
+	}
+	return &Completion{
+		Text:         resp.Choices[0].Message.Content,
+		Model:        model,
+		InputTokens:  resp.Usage.PromptTokens,
+		OutputTokens: resp.Usage.CompletionTokens,
+	}, nil
+}
+
+// Stream implements StreamingProvider, reading the server-sent events of a
+// streamed chat completion until its [DONE] event
+func (p *OpenAIProvider) Stream(ctx context.Context, request *CompletionRequest, fn StreamFunc) (*Completion, error) {
+	body := openAIRequest{
+		Model:         request.Model,
+		Messages:      chatMessages(request),
+		MaxTokens:     request.MaxTokens,
+		Temperature:   request.Temperature,
+		Stream:        true,
+		StreamOptions: &openAIStreamOptions{IncludeUsage: true},
+	}
+	resp, err := post(ctx, p.client, p.name, p.baseURL+"/chat/completions", p.headers(), body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	completion := &Completion{Model: request.Model}
+	var text strings.Builder
+	err = readStream(resp.Body, p.name, func(line []byte) error {
+		data, ok := eventData(line)
+		if !ok || string(data) == "[DONE]" {
+			return nil
+		}
+		var event struct {
+			openAIResponse
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to parse %s stream event: %w", p.name, err)
+		}
+		if event.Error != nil {
+			return fmt.Errorf("%s API failed while streaming: %s", p.name, event.Error.Message)
+		}
+		if event.Model != "" {
+			completion.Model = event.Model
+		}
+		if event.Usage.PromptTokens > 0 || event.Usage.CompletionTokens > 0 {
+			completion.InputTokens = event.Usage.PromptTokens
+			completion.OutputTokens = event.Usage.CompletionTokens
+		}
+		if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
+			text.WriteString(event.Choices[0].Delta.Content)
+			fn(event.Choices[0].Delta.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	completion.Text = text.String()
+	return completion, nil
+}
+
+// headers returns the headers of a request, with the API key if any
+func (p *OpenAIProvider) headers() map[string]string {
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+	return headers
+}
+
+// chatMessages turns a request into the messages of a chat API, the system
+// instructions first
+func chatMessages(request *CompletionRequest) []chatMessage {
+	var messages []chatMessage
+	if request.System != "" {
+		messages = append(messages, chatMessage{Role: "system", Content: request.System})
+	}
+	return append(messages, chatMessage{Role: "user", Content: request.Prompt})
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Provider-backed implementations of the engine's tools. Structured answers
+// are requested as JSON; a model that answers in prose instead still gets
+// its text returned, as the summary or explanation.
+
+func (e *Engine) generateCodeWithProvider(ctx context.Context, prompt, language string) (*types.CodeGeneration, error) {
+	system := fmt.Sprintf("You are an expert %s programmer. Answer with the requested code only, "+
+		"in a single fenced code block, without explanations.", language)
+	completion, err := e.complete(ctx, ToolGenerateCode, system, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	code := extractCodeBlock(completion.Text)
+	e.logger.Info("Code generation completed",
+		zap.String("model", completion.Model),
+		zap.Int("code_length", len(code)))
+
+	return &types.CodeGeneration{
+		Prompt:        prompt,
+		Language:      language,
+		GeneratedCode: code,
+		Model:         completion.Model,
+		GeneratedAt:   time.Now(),
+		Metadata:      e.completionMetadata(completion),
+	}, nil
+}
+
+// analysisAnswer is the JSON answer requested by analyze_code
+type analysisAnswer struct {
+	Summary     string   `json:"summary"`
+	Quality     float64  `json:"quality_score"`
+	Suggestions []string `json:"suggestions"`
+	Issues      []string `json:"issues"`
+	Complexity  string   `json:"complexity"`
+}
+
+func (e *Engine) analyzeCodeWithProvider(ctx context.Context, code, language, repositoryContext string) (*types.CodeAnalysis, error) {
+	system := fmt.Sprintf("You review %s code. Answer with a JSON object only, with the fields "+
+		"summary (string), quality_score (number from 0 to 10), suggestions (array of strings), "+
+		"issues (array of strings) and complexity (Low, Medium or High).", language)
+	if repositoryContext != "" {
+		system += " " + repositoryContextInstructions
+	}
+	completion, err := e.complete(ctx, ToolAnalyzeCode, system, codePrompt(code, language, repositoryContext))
+	if err != nil {
+		return nil, err
+	}
+
+	var answer analysisAnswer
+	if !decodeJSONAnswer(completion.Text, &answer) {
+		answer = analysisAnswer{Summary: strings.TrimSpace(completion.Text)}
+	}
+	e.logger.Info("Code analysis completed",
+		zap.String("model", completion.Model),
+		zap.Float64("quality_score", answer.Quality))
+
+	return &types.CodeAnalysis{
+		Code:        code,
+		Language:    language,
+		Summary:     answer.Summary,
+		Quality:     answer.Quality,
+		Suggestions: answer.Suggestions,
+		Issues:      answer.Issues,
+		Complexity:  answer.Complexity,
+		Model:       completion.Model,
+		AnalyzedAt:  time.Now(),
+	}, nil
+}
+
+// explanationAnswer is the JSON answer requested by explain_code
+type explanationAnswer struct {
+	Explanation string   `json:"explanation"`
+	KeyConcepts []string `json:"key_concepts"`
+	Purpose     string   `json:"purpose"`
+	Complexity  string   `json:"complexity"`
+}
+
+func (e *Engine) explainCodeWithProvider(ctx context.Context, code, language, repositoryContext string) (*types.CodeExplanation, error) {
+	system := fmt.Sprintf("You explain %s code to developers new to it. Answer with a JSON object only, "+
+		"with the fields explanation (string), key_concepts (array of strings), purpose (one sentence) "+
+		"and complexity (Low, Medium or High).", language)
+	if repositoryContext != "" {
+		system += " " + repositoryContextInstructions
+	}
+	completion, err := e.complete(ctx, ToolExplainCode, system, codePrompt(code, language, repositoryContext))
+	if err != nil {
+		return nil, err
+	}
+
+	var answer explanationAnswer
+	if !decodeJSONAnswer(completion.Text, &answer) {
+		answer = explanationAnswer{Explanation: strings.TrimSpace(completion.Text)}
+	}
+
+	return &types.CodeExplanation{
+		Code:        code,
+		Language:    language,
+		Explanation: answer.Explanation,
+		KeyConcepts: answer.KeyConcepts,
+		Purpose:     answer.Purpose,
+		Complexity:  answer.Complexity,
+		Model:       completion.Model,
+		ExplainedAt: time.Now(),
+	}, nil
+}
+
+// completionMetadata describes how a completion was produced
+func (e *Engine) completionMetadata(completion *Completion) map[string]interface{} {
+	return map[string]interface{}{
+		"provider":      e.ProviderName(),
+		"input_tokens":  completion.InputTokens,
+		"output_tokens": completion.OutputTokens,
+		"tokens_used":   completion.InputTokens + completion.OutputTokens,
+	}
+}
+
+// repositoryContextInstructions tell the model how to use the repository
+// context of a prompt
+const repositoryContextInstructions = "The prompt starts with code from the same repository: definitions of " +
+	"symbols the code uses and places that call it. Rely on it for what those symbols do and how the code " +
+	"is used, but describe only the code that follows it."
+
+// codePrompt puts code in a Markdown code block for a prompt, after the
+// repository context if there is any
+func codePrompt(code, language, repositoryContext string) string {
+	fenced := "```" + language + "\n" + strings.TrimRight(code, "\n") + "\n```"
+	if repositoryContext == "" {
+		return fenced
+	}
+	return "# Repository context\n\n" + repositoryContext + "\n\n# Code\n\n" + fenced
+}
+
+// extractCodeBlock returns the content of the first fenced code block of a
+// model's answer, or the whole answer when it has none
+func extractCodeBlock(text string) string {
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return strings.TrimSpace(text)
+	}
+	body := text[start+3:]
+	// Skip the info string naming the language
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 {
+		body = body[newline+1:]
+	} else {
+		return strings.TrimSpace(text)
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimRight(body, " \t\n")
+}
+
+// decodeJSONAnswer decodes the JSON object of a model's answer into out,
+// ignoring a code fence or text around the object. It reports whether the
+// answer held a valid object.
+func decodeJSONAnswer(text string, out interface{}) bool {
+	start := strings.IndexByte(text, '{')
+	end := strings.LastIndexByte(text, '}')
+	if start < 0 || end < start {
+		return false
+	}
+	return json.Unmarshal([]byte(text[start:end+1]), out) == nil
+}
@@ -0,0 +1,284 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// Model providers
+const (
+	ProviderBuiltin   = "builtin"   // Templates and heuristics, no model behind them
+	ProviderOpenAI    = "openai"    // Any OpenAI-compatible chat completions API
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+	ProviderOllama    = "ollama"    // Local Ollama server
+	ProviderLlamaCpp  = "llamacpp"  // Local llama.cpp server, through its OpenAI-compatible API
+)
+
+// Base URLs and API key variables used when the configuration leaves them empty
+var (
+	defaultBaseURLs = map[string]string{
+		ProviderOpenAI:    "https://api.openai.com/v1",
+		ProviderAnthropic: "https://api.anthropic.com",
+		ProviderOllama:    "http://localhost:11434",
+		ProviderLlamaCpp:  "http://localhost:8080/v1",
+	}
+	defaultAPIKeyEnvs = map[string]string{
+		ProviderOpenAI:    "OPENAI_API_KEY",
+		ProviderAnthropic: "ANTHROPIC_API_KEY",
+	}
+)
+
+// CompletionRequest asks a model to answer a prompt
+type CompletionRequest struct {
+	Model       string
+	System      string // Instructions for the model, sent apart from the prompt where the API allows
+	Prompt      string
+	MaxTokens   int
+	Temperature float64
+}
+
+// Completion is a model's answer to a CompletionRequest
+type Completion struct {
+	Text         string
+	Model        string // Model that answered, as reported by the API
+	InputTokens  int
+	OutputTokens int
+}
+
+// Provider sends completion requests to a language model API
+type Provider interface {
+	Complete(ctx context.Context, request *CompletionRequest) (*Completion, error)
+	Name() string
+}
+
+// APIError is an error response of a model API
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API returned %d %s: %s", e.Provider, e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Temporary reports whether the request may succeed when sent again: the
+// API timed out, limited the request rate or failed on its side
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// NewProvider creates the provider selected by the configuration, retrying
+// failed requests as configured. The builtin provider has no API and is
+// handled by the engine itself.
+func NewProvider(cfg *config.ModelsConfig, logger *zap.Logger) (Provider, error) {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURLs[cfg.Provider]
+	}
+	apiKeyEnv := cfg.APIKeyEnv
+	if apiKeyEnv == "" {
+		apiKeyEnv = defaultAPIKeyEnvs[cfg.Provider]
+	}
+	apiKey := ""
+	if apiKeyEnv != "" {
+		apiKey = os.Getenv(apiKeyEnv)
+		if apiKey == "" {
+			logger.Warn("No API key for models provider, sending unauthenticated requests",
+				zap.String("provider", cfg.Provider), zap.String("api_key_env", apiKeyEnv))
+		}
+	}
+	client := &http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second}
+
+	var provider Provider
+	switch cfg.Provider {
+	case ProviderOpenAI, ProviderLlamaCpp:
+		provider = NewOpenAIProvider(cfg.Provider, baseURL, apiKey, client)
+	case ProviderAnthropic:
+		provider = NewAnthropicProvider(baseURL, apiKey, client)
+	case ProviderOllama:
+		provider = NewOllamaProvider(baseURL, client)
+	default:
+		return nil, fmt.Errorf("unknown models provider %q", cfg.Provider)
+	}
+
+	if cfg.MaxRetries > 0 {
+		delay := time.Duration(cfg.RetryDelayMillis) * time.Millisecond
+		provider = &retryingProvider{Provider: provider, retries: cfg.MaxRetries, delay: delay, logger: logger}
+	}
+	return provider, nil
+}
+
+// retryingProvider sends a request again after temporary failures, waiting
+// twice as long before each new attempt
+type retryingProvider struct {
+	Provider
+	retries int
+	delay   time.Duration
+	logger  *zap.Logger
+}
+
+// Complete implements Provider
+func (p *retryingProvider) Complete(ctx context.Context, request *CompletionRequest) (*Completion, error) {
+	return p.do(ctx, func() (*Completion, error) {
+		return p.Provider.Complete(ctx, request)
+	}, nil)
+}
+
+// Stream implements StreamingProvider. A request is only sent again while
+// no text has been passed to fn, so the text is never repeated; a provider
+// that cannot stream passes the whole answer at once.
+func (p *retryingProvider) Stream(ctx context.Context, request *CompletionRequest, fn StreamFunc) (*Completion, error) {
+	streamer, ok := p.Provider.(StreamingProvider)
+	if !ok {
+		completion, err := p.Complete(ctx, request)
+		if err == nil {
+			fn(completion.Text)
+		}
+		return completion, err
+	}
+
+	streamed := false
+	return p.do(ctx, func() (*Completion, error) {
+		return streamer.Stream(ctx, request, func(text string) {
+			streamed = true
+			fn(text)
+		})
+	}, func() bool { return !streamed })
+}
+
+// do runs send until it succeeds, fails for good or the retries are spent.
+// canRetry, when given, vetoes retries of a failed attempt.
+func (p *retryingProvider) do(ctx context.Context, send func() (*Completion, error), canRetry func() bool) (*Completion, error) {
+	delay := p.delay
+	for attempt := 0; ; attempt++ {
+		completion, err := send()
+		if err == nil || attempt >= p.retries || !retryable(ctx, err) || (canRetry != nil && !canRetry()) {
+			return completion, err
+		}
+
+		p.logger.Warn("Model request failed, retrying",
+			zap.String("provider", p.Name()),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// retryable reports whether a failed request is worth sending again: the
+// API reported a temporary failure or the request did not get an answer,
+// and the caller is still waiting for it
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	var requestErr *requestError
+	return errors.As(err, &requestErr)
+}
+
+// requestError is a request that got no response, from a connection
+// failure or the client's timeout
+type requestError struct {
+	provider string
+	err      error
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("%s request failed: %v", e.provider, e.err)
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// postJSON sends body as JSON to url and decodes the response into out.
+// Error responses become an *APIError carrying the message the API gave,
+// whether as {"error": {"message": ...}} or as {"error": "..."}.
+func postJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body, out interface{}) error {
+	resp, err := post(ctx, client, provider, url, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return &requestError{provider: provider, err: err}
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %w", provider, err)
+	}
+	return nil
+}
+
+// post sends body as JSON to url and returns the successful response, whose
+// body the caller closes. Error responses become an *APIError as in
+// postJSON.
+func post(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s request: %w", provider, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s request: %w", provider, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &requestError{provider: provider, err: err}
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &requestError{provider: provider, err: err}
+	}
+	message := strings.TrimSpace(string(data))
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &parsed) == nil && len(parsed.Error) > 0 {
+		var detail struct {
+			Message string `json:"message"`
+		}
+		var text string
+		if json.Unmarshal(parsed.Error, &detail) == nil && detail.Message != "" {
+			message = detail.Message
+		} else if json.Unmarshal(parsed.Error, &text) == nil && text != "" {
+			message = text
+		}
+	}
+	return nil, &APIError{Provider: provider, StatusCode: resp.StatusCode, Message: message}
+}
@@ -0,0 +1,318 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func testRequest() *CompletionRequest {
+	return &CompletionRequest{Model: "test-model", System: "Be brief.", Prompt: "Say hi", MaxTokens: 64, Temperature: 0.2}
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", got)
+		}
+		var body openAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if body.Model != "test-model" || len(body.Messages) != 2 || body.Messages[0].Role != "system" || body.MaxTokens != 64 {
+			t.Errorf("Unexpected request %+v", body)
+		}
+		w.Write([]byte(`{"model":"test-model-0613","choices":[{"message":{"role":"assistant","content":"hi"}}],"usage":{"prompt_tokens":12,"completion_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(ProviderOpenAI, server.URL+"/v1/", "secret", server.Client())
+	completion, err := provider.Complete(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if completion.Text != "hi" || completion.Model != "test-model-0613" || completion.InputTokens != 12 || completion.OutputTokens != 1 {
+		t.Errorf("Unexpected completion %+v", completion)
+	}
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "secret" || r.Header.Get("anthropic-version") != anthropicVersion {
+			t.Errorf("Missing API headers: %v", r.Header)
+		}
+		var body anthropicRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if body.System != "Be brief." || len(body.Messages) != 1 || body.Messages[0].Role != "user" {
+			t.Errorf("Unexpected request %+v", body)
+		}
+		w.Write([]byte(`{"model":"test-model","content":[{"type":"text","text":"hi"},{"type":"text","text":" there"}],"usage":{"input_tokens":9,"output_tokens":2}}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(server.URL, "secret", server.Client())
+	completion, err := provider.Complete(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if completion.Text != "hi there" || completion.InputTokens != 9 || completion.OutputTokens != 2 {
+		t.Errorf("Unexpected completion %+v", completion)
+	}
+}
+
+func TestOllamaProvider(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var body ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		if body.Stream || body.Options.NumPredict != 64 || len(body.Messages) != 2 {
+			t.Errorf("Unexpected request %+v", body)
+		}
+		w.Write([]byte(`{"model":"test-model","message":{"role":"assistant","content":"hi"},"prompt_eval_count":7,"eval_count":1}`))
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(server.URL, server.Client())
+	completion, err := provider.Complete(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if completion.Text != "hi" || completion.InputTokens != 7 || completion.OutputTokens != 1 {
+		t.Errorf("Unexpected completion %+v", completion)
+	}
+}
+
+func TestProviderErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		message   string
+		temporary bool
+	}{
+		{"openai style", http.StatusBadRequest, `{"error":{"message":"model not found"}}`, "model not found", false},
+		{"ollama style", http.StatusNotFound, `{"error":"model 'x' not found"}`, "model 'x' not found", false},
+		{"rate limited", http.StatusTooManyRequests, `slow down`, "slow down", true},
+		{"server error", http.StatusBadGateway, ``, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := NewOllamaProvider(server.URL, server.Client()).Complete(context.Background(), testRequest())
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Expected an APIError, got %v", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Message != tt.message || apiErr.Temporary() != tt.temporary {
+				t.Errorf("Unexpected error %+v", apiErr)
+			}
+		})
+	}
+}
+
+func TestNewProviderRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.ModelsConfig{
+		Provider:         ProviderLlamaCpp,
+		BaseURL:          server.URL,
+		TimeoutSeconds:   5,
+		MaxRetries:       2,
+		RetryDelayMillis: 1,
+	}
+	provider, err := NewProvider(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	completion, err := provider.Complete(context.Background(), testRequest())
+	if err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if completion.Text != "hi" || completion.Model != "test-model" || calls.Load() != 3 {
+		t.Errorf("Unexpected completion %+v after %d calls", completion, calls.Load())
+	}
+
+	// Errors that cannot go away are not retried
+	calls.Store(0)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	cfg.BaseURL = failing.URL
+	provider, _ = NewProvider(cfg, zap.NewNop())
+	if _, err := provider.Complete(context.Background(), testRequest()); err == nil || calls.Load() != 1 {
+		t.Errorf("Expected a single failed call, got %d calls and error %v", calls.Load(), err)
+	}
+}
+
+func TestProviderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Timeout: 50 * time.Millisecond}
+	_, err := NewOllamaProvider(server.URL, client).Complete(context.Background(), testRequest())
+	var requestErr *requestError
+	if !errors.As(err, &requestErr) || !retryable(context.Background(), err) {
+		t.Errorf("Expected a retryable request error, got %v", err)
+	}
+}
+
+func TestNewProviderUnknown(t *testing.T) {
+	if _, err := NewProvider(&config.ModelsConfig{Provider: "gemini"}, zap.NewNop()); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+}
+
+func TestProviderStreams(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		events   string
+		provider func(url string, client *http.Client) StreamingProvider
+	}{
+		{
+			name: "openai",
+			path: "/chat/completions",
+			events: "data: {\"model\":\"test-model-0613\",\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"hi\"}}]}\n\n" +
+				"data: {\"choices\":[{\"delta\":{\"content\":\" there\"}}]}\n\n" +
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":2}}\n\n" +
+				"data: [DONE]\n\n",
+			provider: func(url string, client *http.Client) StreamingProvider {
+				return NewOpenAIProvider(ProviderOpenAI, url, "", client)
+			},
+		},
+		{
+			name: "anthropic",
+			path: "/v1/messages",
+			events: "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"model\":\"test-model-0613\",\"usage\":{\"input_tokens\":12,\"output_tokens\":1}}}\n\n" +
+				"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0,\"content_block\":{\"type\":\"text\",\"text\":\"\"}}\n\n" +
+				"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"hi\"}}\n\n" +
+				"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\" there\"}}\n\n" +
+				"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":2}}\n\n" +
+				"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+			provider: func(url string, client *http.Client) StreamingProvider {
+				return NewAnthropicProvider(url, "", client)
+			},
+		},
+		{
+			name: "ollama",
+			path: "/api/chat",
+			events: "{\"model\":\"test-model-0613\",\"message\":{\"role\":\"assistant\",\"content\":\"hi\"},\"done\":false}\n" +
+				"{\"model\":\"test-model-0613\",\"message\":{\"role\":\"assistant\",\"content\":\" there\"},\"done\":false}\n" +
+				"{\"model\":\"test-model-0613\",\"message\":{\"role\":\"assistant\",\"content\":\"\"},\"done\":true,\"prompt_eval_count\":12,\"eval_count\":2}\n",
+			provider: func(url string, client *http.Client) StreamingProvider {
+				return NewOllamaProvider(url, client)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("Unexpected path %s", r.URL.Path)
+				}
+				var body struct {
+					Stream bool `json:"stream"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil || !body.Stream {
+					t.Errorf("Expected a streaming request, got %+v (%v)", body, err)
+				}
+				w.Write([]byte(tt.events))
+			}))
+			defer server.Close()
+
+			var pieces []string
+			completion, err := tt.provider(server.URL, server.Client()).Stream(context.Background(), testRequest(), func(text string) {
+				pieces = append(pieces, text)
+			})
+			if err != nil {
+				t.Fatalf("Stream failed: %v", err)
+			}
+			if len(pieces) != 2 || pieces[0] != "hi" || pieces[1] != " there" {
+				t.Errorf("Unexpected pieces %q", pieces)
+			}
+			if completion.Text != "hi there" || completion.Model != "test-model-0613" || completion.InputTokens != 12 || completion.OutputTokens != 2 {
+				t.Errorf("Unexpected completion %+v", completion)
+			}
+		})
+	}
+}
+
+func TestRetryingProviderStream(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	cfg := &config.ModelsConfig{
+		Provider:         ProviderOpenAI,
+		BaseURL:          server.URL,
+		TimeoutSeconds:   5,
+		MaxRetries:       2,
+		RetryDelayMillis: 1,
+	}
+	provider, err := NewProvider(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	streamer, ok := provider.(StreamingProvider)
+	if !ok {
+		t.Fatal("Expected a streaming provider")
+	}
+	var streamed string
+	completion, err := streamer.Stream(context.Background(), testRequest(), func(text string) { streamed += text })
+	if err != nil {
+		t.Fatalf("Expected success after a retry, got %v", err)
+	}
+	if streamed != "hi" || completion.Text != "hi" || calls.Load() != 2 {
+		t.Errorf("Unexpected completion %+v, streamed %q after %d calls", completion, streamed, calls.Load())
+	}
+}
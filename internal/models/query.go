@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/nlquery"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// PlanQuery translates a question about the indexed code into lookups of
+// the index. The builtin provider plans from the question's wording, and so
+// does a provider whose answer holds no valid step.
+func (e *Engine) PlanQuery(ctx context.Context, question string) (*types.QueryPlan, error) {
+	if !e.enabled {
+		return nil, fmt.Errorf("models engine is disabled")
+	}
+	if e.provider == nil {
+		return nlquery.Plan(question), nil
+	}
+
+	completion, err := e.complete(ctx, ToolAskCodebase, querySystemPrompt, question)
+	if err != nil {
+		return nil, err
+	}
+	plan := &types.QueryPlan{}
+	if !decodeJSONAnswer(completion.Text, plan) || !nlquery.Normalize(plan) {
+		e.logger.Warn("Model answered without a valid query plan, planning from the question's wording",
+			zap.String("model", completion.Model))
+		return nlquery.Plan(question), nil
+	}
+	plan.Question = question
+	plan.Planner = completion.Model
+	return plan, nil
+}
+
+// querySystemPrompt asks for the JSON query plan of ask_codebase
+var querySystemPrompt = "You translate questions about a codebase into lookups of its search index. " +
+	"Answer with a JSON object only, with the fields intent (definition, usage, files, pattern or search) and " +
+	"steps, an array of at most " + fmt.Sprint(nlquery.MaxSteps) + " lookups, most useful first. Each step has " +
+	"kind, query and optionally symbol_type (function, class, interface or variable), language (lowercase, " +
+	"e.g. go or python), path_prefix (a directory of the repository) and reason (what the step looks for). " +
+	"The kinds are: " + strings.Join([]string{
+	"symbol (query is the exact name of a function, class or variable to find the definition of)",
+	"references (query is the name of a symbol to find the places that call or use it)",
+	"regex (query is an RE2 regular expression matched against lines of code)",
+	"files (query is a file name or glob such as *.proto)",
+	"search (query is a few words or identifiers for a full-text search)",
+}, ", ") + ". Guess likely identifiers from the question, e.g. RateLimiter for \"the rate limiter\", " +
+	"and end with a search step for the question's key words."
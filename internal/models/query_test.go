@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/nlquery"
+)
+
+func TestPlanQuery(t *testing.T) {
+	var models []string
+	engine := newProviderEngine(t, "```json\n"+`{"intent": "usage", "steps": [
+		{"kind": "references", "query": "RateLimiter", "reason": "Uses of the rate limiter"},
+		{"kind": "lookup", "query": "RateLimiter"},
+		{"kind": "search", "query": "rate limit", "path_prefix": "internal/http/"}
+	]}`+"\n```", &models)
+
+	plan, err := engine.PlanQuery(context.Background(), "what uses the rate limiter?")
+	if err != nil {
+		t.Fatalf("PlanQuery failed: %v", err)
+	}
+	if plan.Planner != "big-model" || plan.Intent != nlquery.IntentUsage || plan.Question != "what uses the rate limiter?" {
+		t.Errorf("Unexpected plan %+v", plan)
+	}
+	if len(plan.Steps) != 2 || plan.Steps[0].Kind != nlquery.StepReferences || plan.Steps[1].PathPrefix != "internal/http" {
+		t.Errorf("Expected the unknown step dropped, got %+v", plan.Steps)
+	}
+
+	// An answer without a valid step falls back to the question's wording
+	engine = newProviderEngine(t, "I cannot help with that", &models)
+	plan, err = engine.PlanQuery(context.Background(), "who calls ParseConfig?")
+	if err != nil {
+		t.Fatalf("PlanQuery failed: %v", err)
+	}
+	if plan.Planner != nlquery.PlannerHeuristic || len(plan.Steps) == 0 || plan.Steps[0].Query != "ParseConfig" {
+		t.Errorf("Expected the heuristic plan, got %+v", plan)
+	}
+}
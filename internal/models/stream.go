@@ -0,0 +1,68 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+// StreamFunc receives the text of a completion as the model writes it, in
+// order and a piece at a time
+type StreamFunc func(text string)
+
+// streamKey is the context key of the StreamFunc
+type streamKey struct{}
+
+// WithStream returns a context under which the engine passes the text of
+// the completions it requests to fn as the model writes it. Results are
+// returned whole as usual once the model is done; the builtin provider
+// writes nothing to fn.
+func WithStream(ctx context.Context, fn StreamFunc) context.Context {
+	return context.WithValue(ctx, streamKey{}, fn)
+}
+
+// streamFunc returns the StreamFunc of ctx, or nil
+func streamFunc(ctx context.Context) StreamFunc {
+	fn, _ := ctx.Value(streamKey{}).(StreamFunc)
+	return fn
+}
+
+// StreamingProvider is a Provider that can pass the text of a completion
+// on as the model writes it
+type StreamingProvider interface {
+	Provider
+	Stream(ctx context.Context, request *CompletionRequest, fn StreamFunc) (*Completion, error)
+}
+
+// maxStreamLine bounds a line of a streamed response
+const maxStreamLine = 1024 * 1024
+
+// readStream passes each non-empty line of a streamed response to fn, which
+// may stop the reading with an error. Server-sent events arrive as
+// "data: ..." lines and JSON lines as they are.
+func readStream(body io.Reader, provider string, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return &requestError{provider: provider, err: fmt.Errorf("reading stream: %w", err)}
+	}
+	return nil
+}
+
+// eventData returns the data of a server-sent event line, and false for
+// other lines such as "event: ..." and comments
+func eventData(line []byte) ([]byte, bool) {
+	data, ok := bytes.CutPrefix(line, []byte("data:"))
+	return bytes.TrimSpace(data), ok
+}
@@ -0,0 +1,346 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Formats of a change summary
+const (
+	SummaryCommit      = "commit"
+	SummaryPullRequest = "pull_request"
+)
+
+// ConventionalTypes are the commit types of the Conventional Commits
+// specification and its common extensions
+var ConventionalTypes = []string{"feat", "fix", "docs", "style", "refactor", "perf", "test", "build", "ci", "chore", "revert"}
+
+// defaultSubjectLength is the subject length summaries are cut to unless
+// asked otherwise, the usual limit of git tooling
+const defaultSubjectLength = 72
+
+// SummaryOptions selects how SummarizeDiff writes its summary
+type SummaryOptions struct {
+	Format           string // SummaryCommit or SummaryPullRequest, SummaryCommit when empty
+	Conventional     bool   // Prefix the subject with type(scope): as in Conventional Commits
+	Type             string // Commit type to use instead of the one inferred
+	Scope            string // Scope to use instead of the one inferred
+	MaxSubjectLength int    // Length the subject is cut to, defaultSubjectLength when zero
+}
+
+// summaryAnswer is the JSON answer requested by summarize_diff
+type summaryAnswer struct {
+	Type           string   `json:"type"`
+	Scope          string   `json:"scope"`
+	Subject        string   `json:"subject"`
+	Body           string   `json:"body"`
+	Changes        []string `json:"changes"`
+	BreakingChange string   `json:"breaking_change"`
+}
+
+// SummarizeDiff writes a commit message or pull request description for a
+// diff. The builtin provider describes the changed files and infers the
+// commit type and scope from their paths.
+func (e *Engine) SummarizeDiff(ctx context.Context, diff *types.GitDiff, options SummaryOptions) (*types.ChangeSummary, error) {
+	if !e.enabled {
+		return nil, fmt.Errorf("models engine is disabled")
+	}
+	if options.Format == "" {
+		options.Format = SummaryCommit
+	}
+	if options.MaxSubjectLength <= 0 {
+		options.MaxSubjectLength = defaultSubjectLength
+	}
+
+	e.logger.Info("Summarizing diff",
+		zap.String("format", options.Format),
+		zap.Int("files_changed", diff.FilesChanged))
+
+	var (
+		answer *summaryAnswer
+		model  = e.config.DefaultModel
+	)
+	if e.provider != nil {
+		completion, err := e.complete(ctx, ToolSummarizeDiff, summarySystemPrompt(options), renderDiff(diff))
+		if err != nil {
+			return nil, err
+		}
+		answer = &summaryAnswer{}
+		if !decodeJSONAnswer(completion.Text, answer) {
+			lines := strings.SplitN(strings.TrimSpace(completion.Text), "\n", 2)
+			answer = &summaryAnswer{Subject: lines[0]}
+			if len(lines) > 1 {
+				answer.Body = strings.TrimSpace(lines[1])
+			}
+		}
+		model = completion.Model
+	} else {
+		answer = summarizeDiffWithTemplates(diff)
+	}
+
+	summary := formatSummary(answer, options)
+	summary.Model = model
+	summary.GeneratedAt = time.Now()
+	return summary, nil
+}
+
+// summarySystemPrompt asks for the JSON answer of summarize_diff
+func summarySystemPrompt(options SummaryOptions) string {
+	var b strings.Builder
+	if options.Format == SummaryPullRequest {
+		b.WriteString("You write pull request descriptions for diffs. The subject is the pull request title, " +
+			"and the body explains in Markdown what changed and why, for a reviewer.")
+	} else {
+		b.WriteString("You write git commit messages for diffs. The subject is an imperative summary such as " +
+			"\"Add retries to the model providers\", and the body explains what changed and why in plain text " +
+			"wrapped at 72 columns.")
+	}
+	fmt.Fprintf(&b, " Keep the subject under %d characters, without a trailing period.", options.MaxSubjectLength)
+	b.WriteString(" Answer with a JSON object only, with the fields type (one of " + strings.Join(ConventionalTypes, ", ") +
+		"), scope (the area of the code changed, one lowercase word, or empty), subject (without a type prefix), " +
+		"body, changes (array of the notable changes, one short sentence each) and breaking_change " +
+		"(what breaks for users, or empty).")
+	if options.Type != "" {
+		fmt.Fprintf(&b, " The type is %s.", options.Type)
+	}
+	if options.Scope != "" {
+		fmt.Fprintf(&b, " The scope is %s.", options.Scope)
+	}
+	return b.String()
+}
+
+// renderDiff formats a diff as a unified diff for a prompt
+func renderDiff(diff *types.GitDiff) string {
+	var b strings.Builder
+	for _, file := range diff.Files {
+		fmt.Fprintf(&b, "diff --git a/%s b/%s (%s, +%d -%d)\n", file.Path, file.Path, file.Status, file.Additions, file.Deletions)
+		if file.Binary {
+			b.WriteString("Binary file\n")
+			continue
+		}
+		for _, hunk := range file.Hunks {
+			b.WriteString(hunk.Header)
+			b.WriteString("\n")
+			for _, line := range hunk.Lines {
+				b.WriteString(line)
+				b.WriteString("\n")
+			}
+		}
+	}
+	if diff.Truncated {
+		b.WriteString("(the rest of the diff was cut off)\n")
+	}
+	return b.String()
+}
+
+// summarizeDiffWithTemplates describes a diff file by file, inferring the
+// commit type from the kinds of files changed and the scope from the
+// directory they share
+func summarizeDiffWithTemplates(diff *types.GitDiff) *summaryAnswer {
+	answer := &summaryAnswer{Type: inferCommitType(diff), Scope: inferScope(diff)}
+
+	for _, file := range diff.Files {
+		switch file.Status {
+		case "added":
+			answer.Changes = append(answer.Changes, fmt.Sprintf("Add %s (+%d)", file.Path, file.Additions))
+		case "deleted":
+			answer.Changes = append(answer.Changes, fmt.Sprintf("Remove %s (-%d)", file.Path, file.Deletions))
+		default:
+			answer.Changes = append(answer.Changes, fmt.Sprintf("Update %s (+%d -%d)", file.Path, file.Additions, file.Deletions))
+		}
+	}
+
+	switch {
+	case len(diff.Files) == 0:
+		answer.Subject = "No changes"
+	case len(diff.Files) == 1:
+		file := diff.Files[0]
+		verb := map[string]string{"added": "Add", "deleted": "Remove"}[file.Status]
+		if verb == "" {
+			verb = "Update"
+		}
+		answer.Subject = verb + " " + file.Path
+	case answer.Scope != "":
+		answer.Subject = fmt.Sprintf("Update %d files in %s", len(diff.Files), answer.Scope)
+	default:
+		answer.Subject = fmt.Sprintf("Update %d files", len(diff.Files))
+	}
+	answer.Body = fmt.Sprintf("%d files changed, %d insertions(+), %d deletions(-)", diff.FilesChanged, diff.Additions, diff.Deletions)
+	return answer
+}
+
+// inferCommitType guesses the commit type of a diff: docs, test, ci or
+// build when all files are of that kind, feat when code files were added,
+// refactor when more lines were removed than added, and fix otherwise
+func inferCommitType(diff *types.GitDiff) string {
+	if len(diff.Files) == 0 {
+		return "chore"
+	}
+	kinds := make(map[string]bool)
+	added := false
+	for _, file := range diff.Files {
+		kind := fileKind(file.Path)
+		kinds[kind] = true
+		if kind == "code" && file.Status == "added" {
+			added = true
+		}
+	}
+	if len(kinds) == 1 {
+		for kind := range kinds {
+			if kind != "code" {
+				return kind
+			}
+		}
+	}
+	switch {
+	case added:
+		return "feat"
+	case diff.Deletions > diff.Additions:
+		return "refactor"
+	default:
+		return "fix"
+	}
+}
+
+// fileKind classifies a changed file as docs, test, ci, build or code
+func fileKind(filePath string) string {
+	base := path.Base(filePath)
+	ext := strings.ToLower(path.Ext(base))
+	switch {
+	case strings.HasPrefix(filePath, ".github/") || strings.HasPrefix(filePath, ".gitlab-ci") || strings.HasPrefix(filePath, ".circleci/"):
+		return "ci"
+	case ext == ".md" || ext == ".rst" || ext == ".txt" || strings.HasPrefix(filePath, "docs/"):
+		return "docs"
+	case strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(base, "test_") || strings.HasPrefix(filePath, "test/") || strings.HasPrefix(filePath, "tests/") ||
+		strings.Contains(filePath, "/test/") || strings.Contains(filePath, "/tests/"):
+		return "test"
+	}
+	switch base {
+	case "go.mod", "go.sum", "Makefile", "Dockerfile", "package.json", "package-lock.json", "pyproject.toml",
+		"requirements.txt", "Cargo.toml", "Cargo.lock", "pom.xml", "build.gradle":
+		return "build"
+	}
+	return "code"
+}
+
+// inferScope returns the last element of the directory all changed files
+// share, or "" when they share none
+func inferScope(diff *types.GitDiff) string {
+	if len(diff.Files) == 0 {
+		return ""
+	}
+	common := path.Dir(diff.Files[0].Path)
+	for _, file := range diff.Files[1:] {
+		dir := path.Dir(file.Path)
+		for common != "." && dir != common && !strings.HasPrefix(dir, common+"/") {
+			common = path.Dir(common)
+		}
+	}
+	if common == "." || common == "/" {
+		return ""
+	}
+	return strings.ToLower(path.Base(common))
+}
+
+// formatSummary applies the options to an answer and assembles the message
+func formatSummary(answer *summaryAnswer, options SummaryOptions) *types.ChangeSummary {
+	summary := &types.ChangeSummary{
+		Format:   options.Format,
+		Type:     strings.ToLower(strings.TrimSpace(answer.Type)),
+		Scope:    strings.ToLower(strings.TrimSpace(answer.Scope)),
+		Breaking: strings.TrimSpace(answer.BreakingChange) != "",
+		Body:     strings.TrimSpace(answer.Body),
+		Changes:  answer.Changes,
+	}
+	if options.Type != "" {
+		summary.Type = options.Type
+	}
+	if options.Scope != "" {
+		summary.Scope = options.Scope
+	}
+
+	subject := strings.TrimSuffix(strings.TrimSpace(answer.Subject), ".")
+	if options.Conventional {
+		prefix := summary.Type
+		if prefix == "" {
+			prefix = "chore"
+		}
+		if summary.Scope != "" {
+			prefix += "(" + summary.Scope + ")"
+		}
+		if summary.Breaking {
+			prefix += "!"
+		}
+		subject = prefix + ": " + lowerFirst(subject)
+	}
+	summary.Subject = truncateSubject(subject, options.MaxSubjectLength)
+
+	var message strings.Builder
+	message.WriteString(summary.Subject)
+	if options.Format == SummaryPullRequest {
+		if summary.Body != "" {
+			message.WriteString("\n\n## Summary\n\n" + summary.Body)
+		}
+		if len(summary.Changes) > 0 {
+			message.WriteString("\n\n## Changes\n")
+			for _, change := range summary.Changes {
+				message.WriteString("\n- " + change)
+			}
+		}
+		if summary.Breaking {
+			message.WriteString("\n\n## Breaking changes\n\n" + strings.TrimSpace(answer.BreakingChange))
+		}
+	} else {
+		if summary.Body != "" {
+			message.WriteString("\n\n" + summary.Body)
+		}
+		if len(summary.Changes) > 0 {
+			message.WriteString("\n")
+			for _, change := range summary.Changes {
+				message.WriteString("\n- " + change)
+			}
+		}
+		if summary.Breaking {
+			message.WriteString("\n\nBREAKING CHANGE: " + strings.TrimSpace(answer.BreakingChange))
+		}
+	}
+	summary.Message = message.String()
+	return summary
+}
+
+// lowerFirst lowercases the first letter of a subject unless it starts an
+// acronym or identifier such as "HTTP" or "URLs"
+func lowerFirst(subject string) string {
+	first, size := utf8.DecodeRuneInString(subject)
+	if first == utf8.RuneError {
+		return subject
+	}
+	if second, _ := utf8.DecodeRuneInString(subject[size:]); unicode.IsUpper(second) {
+		return subject
+	}
+	return string(unicode.ToLower(first)) + subject[size:]
+}
+
+// truncateSubject cuts a subject to at most limit runes, at a word boundary
+// when there is one
+func truncateSubject(subject string, limit int) string {
+	runes := []rune(subject)
+	if len(runes) <= limit {
+		return subject
+	}
+	cut := string(runes[:limit])
+	if space := strings.LastIndexByte(cut, ' '); space > limit/2 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,;:")
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestInferCommitType(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []types.FileDiff
+		want  string
+	}{
+		{"docs only", []types.FileDiff{{Path: "README.md", Status: "modified"}, {Path: "docs/TOOLS.md", Status: "modified"}}, "docs"},
+		{"tests only", []types.FileDiff{{Path: "internal/models/engine_test.go", Status: "added"}}, "test"},
+		{"workflows", []types.FileDiff{{Path: ".github/workflows/ci.yml", Status: "modified"}}, "ci"},
+		{"dependencies", []types.FileDiff{{Path: "go.mod", Status: "modified"}, {Path: "go.sum", Status: "modified"}}, "build"},
+		{"new code", []types.FileDiff{{Path: "internal/models/summarize.go", Status: "added"}, {Path: "README.md", Status: "modified"}}, "feat"},
+		{"removed code", []types.FileDiff{{Path: "internal/server/legacy.go", Status: "modified", Deletions: 40, Additions: 3}}, "refactor"},
+		{"changed code", []types.FileDiff{{Path: "internal/server/server.go", Status: "modified", Deletions: 1, Additions: 3}}, "fix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := &types.GitDiff{Files: tt.files}
+			for _, file := range tt.files {
+				diff.Additions += file.Additions
+				diff.Deletions += file.Deletions
+			}
+			if got := inferCommitType(diff); got != tt.want {
+				t.Errorf("inferCommitType() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferScope(t *testing.T) {
+	diff := &types.GitDiff{Files: []types.FileDiff{{Path: "internal/server/tools.go"}, {Path: "internal/server/auth/keys.go"}}}
+	if got := inferScope(diff); got != "server" {
+		t.Errorf("Expected the shared directory, got %q", got)
+	}
+	diff.Files = append(diff.Files, types.FileDiff{Path: "README.md"})
+	if got := inferScope(diff); got != "" {
+		t.Errorf("Expected no scope for files without a shared directory, got %q", got)
+	}
+}
+
+func TestFormatSummary(t *testing.T) {
+	answer := &summaryAnswer{
+		Type:           "feat",
+		Scope:          "models",
+		Subject:        "Add retries to the model providers.",
+		Body:           "Rate limits no longer fail the AI tools.",
+		Changes:        []string{"Retry 429 and 5xx responses"},
+		BreakingChange: "max_retries defaults to 2",
+	}
+
+	commit := formatSummary(answer, SummaryOptions{Format: SummaryCommit, Conventional: true, MaxSubjectLength: 72})
+	if commit.Subject != "feat(models)!: add retries to the model providers" {
+		t.Errorf("Unexpected subject %q", commit.Subject)
+	}
+	want := "feat(models)!: add retries to the model providers\n\nRate limits no longer fail the AI tools.\n\n" +
+		"- Retry 429 and 5xx responses\n\nBREAKING CHANGE: max_retries defaults to 2"
+	if commit.Message != want {
+		t.Errorf("Unexpected message %q", commit.Message)
+	}
+
+	pullRequest := formatSummary(answer, SummaryOptions{Format: SummaryPullRequest, Scope: "engine", MaxSubjectLength: 20})
+	if pullRequest.Subject != "Add retries to the" || pullRequest.Scope != "engine" {
+		t.Errorf("Expected a subject cut at a word boundary, got %+v", pullRequest)
+	}
+	if !strings.Contains(pullRequest.Message, "## Summary\n\nRate limits") || !strings.Contains(pullRequest.Message, "## Breaking changes") {
+		t.Errorf("Unexpected description %q", pullRequest.Message)
+	}
+
+	// Acronyms keep their case after the prefix
+	answer = &summaryAnswer{Type: "fix", Subject: "HTTP clients time out"}
+	if got := formatSummary(answer, SummaryOptions{Conventional: true, MaxSubjectLength: 72}).Subject; got != "fix: HTTP clients time out" {
+		t.Errorf("Unexpected subject %q", got)
+	}
+}
+
+func TestSummarizeDiffWithProvider(t *testing.T) {
+	var models []string
+	answer := `{"type":"fix","scope":"","subject":"Handle empty names","body":"Normalize returned an error for empty names.","changes":["Return an empty name unchanged"],"breaking_change":""}`
+	engine := newProviderEngine(t, answer, &models)
+
+	diff := &types.GitDiff{
+		Files: []types.FileDiff{{
+			Path: "names.go", Status: "modified", Additions: 1, Deletions: 1,
+			Hunks: []types.DiffHunk{{Header: "@@ -1,1 +1,1 @@", Lines: []string{"-old", "+new"}}},
+		}},
+		FilesChanged: 1, Additions: 1, Deletions: 1,
+	}
+	summary, err := engine.SummarizeDiff(context.Background(), diff, SummaryOptions{Conventional: true, Scope: "names"})
+	if err != nil {
+		t.Fatalf("SummarizeDiff failed: %v", err)
+	}
+	if summary.Subject != "fix(names): handle empty names" || summary.Format != SummaryCommit || summary.Model != "big-model" {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+	if len(models) != 1 || models[0] != "big-model" {
+		t.Errorf("Expected the default model, got %v", models)
+	}
+}
+
+func TestSummarizeDiffBuiltin(t *testing.T) {
+	engine, err := NewEngine(&config.ModelsConfig{Enabled: true, DefaultModel: "code-assistant-v1"}, nil, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	diff := &types.GitDiff{
+		Files:        []types.FileDiff{{Path: "cmd/tool/main.go", Status: "added", Additions: 12}},
+		FilesChanged: 1, Additions: 12,
+	}
+	summary, err := engine.SummarizeDiff(context.Background(), diff, SummaryOptions{})
+	if err != nil {
+		t.Fatalf("SummarizeDiff failed: %v", err)
+	}
+	if summary.Subject != "Add cmd/tool/main.go" || summary.Type != "feat" || summary.Scope != "tool" {
+		t.Errorf("Unexpected summary %+v", summary)
+	}
+}
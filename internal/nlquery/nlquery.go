@@ -0,0 +1,340 @@
+// Package nlquery translates natural language questions about code into
+// lookups of the index: symbol definitions, references, full-text and
+// regular expression searches, and file searches.
+//
+// Plan reads the question's wording alone; a language model can write the
+// plan instead, and Normalize checks such a plan before it is run.
+package nlquery
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Step kinds
+const (
+	StepSymbol     = "symbol"     // Definitions of a symbol by name
+	StepReferences = "references" // Places that call or use a symbol
+	StepSearch     = "search"     // Full-text search of names and content
+	StepRegex      = "regex"      // Regular expression over file contents
+	StepFiles      = "files"      // Files by name or pattern
+)
+
+// StepKinds lists the step kinds in the order plans list them
+var StepKinds = []string{StepSymbol, StepReferences, StepRegex, StepFiles, StepSearch}
+
+// Intents of a question
+const (
+	IntentDefinition = "definition" // Where something is defined or what it does
+	IntentUsage      = "usage"      // Where something is called or used
+	IntentFiles      = "files"      // Which files match
+	IntentPattern    = "pattern"    // Where text or a pattern occurs
+	IntentSearch     = "search"     // Anything else
+)
+
+// PlannerHeuristic names plans written by Plan
+const PlannerHeuristic = "heuristic"
+
+const (
+	// MaxSteps bounds the steps of a plan
+	MaxSteps = 8
+
+	// maxIdentifiers bounds the identifiers of a question looked up
+	maxIdentifiers = 3
+)
+
+var (
+	// quoted matches text in backticks or double quotes
+	quoted = regexp.MustCompile("`([^`]+)`|\"([^\"]+)\"")
+
+	// identifierPattern matches identifiers, optionally qualified, such as
+	// Parse, parse_file or store.Save
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+	// fileName matches file names with a known extension
+	fileName = regexp.MustCompile(`^[\w.-]+\.(go|py|js|jsx|ts|tsx|java|rs|rb|php|cs|kt|swift|c|h|cpp|hpp|sh|sql|proto|ya?ml|json|toml|ini|md|mod|gradle|xml|html|css)$`)
+
+	// regexMeta matches the characters that make quoted text a regular
+	// expression rather than a literal
+	regexMeta = regexp.MustCompile(`\\[bdswBDSW]|[\[\]()|^$+?{}]|\.\*`)
+
+	// intentPatterns recognize the intent of a question, checked in order
+	intentPatterns = []struct {
+		intent  string
+		pattern *regexp.Regexp
+	}{
+		{IntentUsage, regexp.MustCompile(`\b(who|what) calls\b|\bcallers? of\b|\bcalls? (to|of)\b|\bcalled (from|by)\b|\busages? of\b|\buses of\b|\breferences? to\b|\bwhere\b.*\b(used|called|referenced|invoked)\b`)},
+		{IntentDefinition, regexp.MustCompile(`\bdefin(ed|ition|es)\b|\bdeclared?\b|\bimplement(s|ed|ation)?\b|\bwhat (is|are|does|do)\b|\bhow (does|do|is)\b|\bwhere (is|are)\b`)},
+		{IntentFiles, regexp.MustCompile(`\b(which|what|list|find|show)( all)?( the)? files?\b|\bfiles? (named|called)\b`)},
+		{IntentPattern, regexp.MustCompile(`\bregexp?\b|\bpatterns?\b|\bmatching\b|\bcontains?\b|\bmentions?\b|\btodos?\b|\bfixmes?\b`)},
+	}
+
+	// languageNames maps words of a question to the languages of the index.
+	// Go is only recognized in phrases such as "in go" or "go files",
+	// since the word is common.
+	languageNames = map[string]string{
+		"golang":     "go",
+		"python":     "python",
+		"javascript": "javascript",
+		"typescript": "typescript",
+		"java":       "java",
+		"rust":       "rust",
+		"ruby":       "ruby",
+		"php":        "php",
+		"kotlin":     "kotlin",
+		"swift":      "swift",
+		"scala":      "scala",
+		"c++":        "cpp",
+		"c#":         "csharp",
+		"protobuf":   "protobuf",
+		"sql":        "sql",
+	}
+	goLanguage = regexp.MustCompile(`\bin go\b|\bgo (code|files?|functions?|packages?|modules?|structs?|interfaces?|tests?|methods?)\b`)
+
+	// symbolTypes maps words of a question to the symbol types of the index
+	symbolTypes = map[string]string{
+		"function": "function", "functions": "function", "func": "function", "funcs": "function",
+		"method": "function", "methods": "function",
+		"class": "class", "classes": "class", "struct": "class", "structs": "class",
+		"interface": "interface", "interfaces": "interface",
+		"variable": "variable", "variables": "variable", "constant": "variable", "constants": "variable",
+	}
+
+	// stopWords are words of a question that do not help a search
+	stopWords = map[string]bool{}
+)
+
+func init() {
+	for _, word := range strings.Fields(`a an the and or but of in on at to for from by with without into onto
+		about over under is are was were be been being do does did done doing has have had having can could
+		should would will shall may might must i me my we our you your it its this that these those there here
+		which what who whom whose where when why how all any each every some no not only than then so such
+		very just also find show list get give tell me please code codebase repository repo project file files
+		where's what's how's defined definition declared declare implemented implementation implements used use
+		uses usage usages called call calls caller callers invoked referenced reference references happen
+		happens handled handle handles work works working done located live lives look looking search named
+		mention mentions mentioning contain contains containing matching match pattern patterns regex`) {
+		stopWords[word] = true
+	}
+}
+
+// Plan translates a question into lookups by its wording. Identifiers,
+// such as CamelCase, snake_case or qualified names, become symbol lookups,
+// and reference lookups when the question asks what uses them. Quoted text
+// becomes a regular expression search, file names and globs a file search,
+// and the remaining words a full-text search. A path in the question limits
+// every step to it, and so does a language or symbol type it names.
+func Plan(question string) *types.QueryPlan {
+	plan := &types.QueryPlan{Question: question, Intent: IntentSearch, Planner: PlannerHeuristic}
+	lower := strings.ToLower(question)
+	for _, candidate := range intentPatterns {
+		if candidate.pattern.MatchString(lower) {
+			plan.Intent = candidate.intent
+			break
+		}
+	}
+
+	language := ""
+	if goLanguage.MatchString(lower) {
+		language = "go"
+	}
+	symbolType := ""
+
+	var identifiers, patterns, files, keywords []string
+	pathPrefix := ""
+	addUnique := func(list []string, value string) []string {
+		for _, existing := range list {
+			if existing == value {
+				return list
+			}
+		}
+		return append(list, value)
+	}
+
+	// Quoted text is taken as written
+	rest := quoted.ReplaceAllStringFunc(question, func(match string) string {
+		text := strings.TrimSpace(match[1 : len(match)-1])
+		switch {
+		case text == "":
+		case identifierPattern.MatchString(strings.TrimSuffix(text, "()")):
+			identifiers = addUnique(identifiers, strings.TrimSuffix(text, "()"))
+		case regexMeta.MatchString(text):
+			if _, err := regexp.Compile(text); err == nil {
+				patterns = addUnique(patterns, text)
+			} else {
+				patterns = addUnique(patterns, regexp.QuoteMeta(text))
+			}
+		default:
+			patterns = addUnique(patterns, regexp.QuoteMeta(text))
+		}
+		return " "
+	})
+
+	namesSymbols := plan.Intent == IntentDefinition || plan.Intent == IntentUsage
+	for i, word := range strings.Fields(rest) {
+		word = strings.TrimRight(strings.TrimLeft(word, "([{'"), ",.;:!?]}'")
+		call := strings.HasSuffix(word, "()")
+		word = strings.TrimRight(strings.TrimSuffix(word, "()"), ")")
+		lowerWord := strings.ToLower(word)
+		switch {
+		case word == "":
+		case languageNames[lowerWord] != "":
+			language = languageNames[lowerWord]
+		case symbolTypes[lowerWord] != "":
+			if symbolType == "" {
+				symbolType = symbolTypes[lowerWord]
+			}
+		case strings.Contains(word, "*"):
+			files = addUnique(files, word)
+		case strings.Contains(word, "/") && !strings.Contains(word, "://"):
+			if fileName.MatchString(word[strings.LastIndex(word, "/")+1:]) {
+				files = addUnique(files, word)
+			} else if pathPrefix == "" {
+				pathPrefix = strings.Trim(word, "/")
+			}
+		case fileName.MatchString(word) || word == "Dockerfile" || word == "Makefile":
+			files = addUnique(files, word)
+		case lowerWord == "todo" || lowerWord == "todos" || lowerWord == "fixme" || lowerWord == "fixmes":
+			patterns = addUnique(patterns, `\b`+strings.ToUpper(strings.TrimSuffix(lowerWord, "s"))+`\b`)
+		case identifierPattern.MatchString(word) && (call || isIdentifier(word) || (namesSymbols && i > 0 && isTypeName(word))):
+			identifiers = addUnique(identifiers, word)
+		default:
+			if keyword := strings.Trim(lowerWord, `"'`+"`"); len(keyword) > 1 && !stopWords[keyword] && keyword != "go" {
+				keywords = addUnique(keywords, keyword)
+			}
+		}
+	}
+	if len(identifiers) > maxIdentifiers {
+		identifiers = identifiers[:maxIdentifiers]
+	}
+	if len(files) > 0 && plan.Intent == IntentSearch {
+		plan.Intent = IntentFiles
+	}
+	if len(patterns) > 0 && (plan.Intent == IntentSearch || len(identifiers) == 0) {
+		plan.Intent = IntentPattern
+	}
+
+	step := func(kind, query, reason string) types.QueryStep {
+		s := types.QueryStep{Kind: kind, Query: query, Language: language, PathPrefix: pathPrefix, Reason: reason}
+		if kind == StepSymbol {
+			s.SymbolType = symbolType
+		}
+		return s
+	}
+	for _, identifier := range identifiers {
+		name := identifier[strings.LastIndex(identifier, ".")+1:]
+		if plan.Intent == IntentUsage {
+			plan.Steps = append(plan.Steps, step(StepReferences, name, "Places that call or use "+identifier))
+		}
+		plan.Steps = append(plan.Steps, step(StepSymbol, name, "Definition of "+identifier))
+	}
+	for _, pattern := range patterns {
+		plan.Steps = append(plan.Steps, step(StepRegex, pattern, "Lines matching "+pattern))
+	}
+	for _, file := range files {
+		plan.Steps = append(plan.Steps, step(StepFiles, file, "Files named like "+file))
+	}
+
+	// Full-text search for the words of the question, and the identifiers
+	// when the question names what they do
+	terms := keywords
+	if len(keywords) > 0 {
+		terms = append(append([]string(nil), identifiers...), keywords...)
+	}
+	if len(terms) > 0 {
+		plan.Steps = append(plan.Steps, step(StepSearch, strings.Join(terms, " "), "Code mentioning "+strings.Join(terms, ", ")))
+	}
+	if len(plan.Steps) == 0 {
+		plan.Steps = append(plan.Steps, step(StepSearch, strings.TrimSpace(question), "Code mentioning the question's words"))
+	}
+	if symbolType != "" && len(identifiers) == 0 && len(keywords) > 0 {
+		// "which functions parse config" asks for symbols of that type
+		plan.Steps = append([]types.QueryStep{step(StepSymbol, strings.Join(keywords, " "), "Symbols of type "+symbolType)}, plan.Steps...)
+	}
+	if len(plan.Steps) > MaxSteps {
+		plan.Steps = plan.Steps[:MaxSteps]
+	}
+	return plan
+}
+
+// isIdentifier reports whether a word of a question reads as an identifier
+// rather than as prose: it has an underscore, a qualifier or a capital
+// letter after a lowercase one, as in parse_file, store.Save or getUser.
+// Acronyms such as API are prose.
+func isIdentifier(word string) bool {
+	if strings.ContainsAny(word, "_.$") {
+		return true
+	}
+	sawLower := false
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			sawLower = true
+		} else if unicode.IsUpper(r) && sawLower {
+			return true
+		}
+	}
+	return false
+}
+
+// isTypeName reports whether a word is capitalized without being an
+// acronym, as the names of types are; Plan takes such words past the start
+// of a question about definitions or uses for symbol names
+func isTypeName(word string) bool {
+	runes := []rune(word)
+	if len(runes) < 2 || !unicode.IsUpper(runes[0]) {
+		return false
+	}
+	for _, r := range runes[1:] {
+		if unicode.IsLower(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize checks a plan written by a language model before it is run:
+// steps of unknown kinds, without a query or with an invalid regular
+// expression are dropped, fields are trimmed and the steps are capped at
+// MaxSteps. It reports whether any step is left.
+func Normalize(plan *types.QueryPlan) bool {
+	known := false
+	for _, candidate := range []string{IntentDefinition, IntentUsage, IntentFiles, IntentPattern, IntentSearch} {
+		known = known || plan.Intent == candidate
+	}
+	if !known {
+		plan.Intent = IntentSearch
+	}
+
+	steps := plan.Steps[:0]
+	for _, step := range plan.Steps {
+		step.Kind = strings.ToLower(strings.TrimSpace(step.Kind))
+		step.Query = strings.TrimSpace(step.Query)
+		step.SymbolType = strings.ToLower(strings.TrimSpace(step.SymbolType))
+		step.Language = strings.ToLower(strings.TrimSpace(step.Language))
+		step.PathPrefix = strings.Trim(strings.TrimSpace(step.PathPrefix), "/")
+		if step.Query == "" {
+			continue
+		}
+		switch step.Kind {
+		case StepSymbol, StepReferences, StepSearch, StepFiles:
+		case StepRegex:
+			if _, err := regexp.Compile(step.Query); err != nil {
+				continue
+			}
+		default:
+			continue
+		}
+		if step.Kind != StepSymbol {
+			step.SymbolType = ""
+		}
+		steps = append(steps, step)
+		if len(steps) == MaxSteps {
+			break
+		}
+	}
+	plan.Steps = steps
+	return len(steps) > 0
+}
@@ -0,0 +1,110 @@
+package nlquery
+
+import (
+	"testing"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		question string
+		intent   string
+		steps    []types.QueryStep
+	}{
+		{
+			question: "Who calls ParseConfig in internal/server?",
+			intent:   IntentUsage,
+			steps: []types.QueryStep{
+				{Kind: StepReferences, Query: "ParseConfig", PathPrefix: "internal/server"},
+				{Kind: StepSymbol, Query: "ParseConfig", PathPrefix: "internal/server"},
+			},
+		},
+		{
+			question: "Where is the Server struct defined in go?",
+			intent:   IntentDefinition,
+			steps: []types.QueryStep{
+				{Kind: StepSymbol, Query: "Server", SymbolType: "class", Language: "go"},
+			},
+		},
+		{
+			question: "Which python functions parse the config file?",
+			intent:   IntentSearch,
+			steps: []types.QueryStep{
+				{Kind: StepSymbol, Query: "parse config", SymbolType: "function", Language: "python"},
+				{Kind: StepSearch, Query: "parse config", Language: "python"},
+			},
+		},
+		{
+			question: "Find TODO comments mentioning `retry` in *.go",
+			intent:   IntentPattern,
+			steps: []types.QueryStep{
+				{Kind: StepSymbol, Query: "retry"},
+				{Kind: StepRegex, Query: `\bTODO\b`},
+				{Kind: StepFiles, Query: "*.go"},
+				{Kind: StepSearch, Query: "retry comments"},
+			},
+		},
+		{
+			question: "Where are the TODOs?",
+			intent:   IntentPattern,
+			steps: []types.QueryStep{
+				{Kind: StepRegex, Query: `\bTODO\b`},
+			},
+		},
+		{
+			question: "rate limiting of login attempts",
+			intent:   IntentSearch,
+			steps: []types.QueryStep{
+				{Kind: StepSearch, Query: "rate limiting login attempts"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		plan := Plan(tt.question)
+		if plan.Intent != tt.intent || plan.Planner != PlannerHeuristic || plan.Question != tt.question {
+			t.Errorf("Plan(%q) intent = %s, planner = %s, want %s", tt.question, plan.Intent, plan.Planner, tt.intent)
+		}
+		if len(plan.Steps) != len(tt.steps) {
+			t.Errorf("Plan(%q) steps = %+v, want %+v", tt.question, plan.Steps, tt.steps)
+			continue
+		}
+		for i, step := range plan.Steps {
+			want := tt.steps[i]
+			if step.Kind != want.Kind || step.Query != want.Query || step.SymbolType != want.SymbolType ||
+				step.Language != want.Language || step.PathPrefix != want.PathPrefix || step.Reason == "" {
+				t.Errorf("Plan(%q) step %d = %+v, want %+v", tt.question, i, step, want)
+			}
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	plan := &types.QueryPlan{
+		Intent: "lookup",
+		Steps: []types.QueryStep{
+			{Kind: " Symbol ", Query: "Open", SymbolType: "Function", PathPrefix: "/internal/store/"},
+			{Kind: "regex", Query: "("},
+			{Kind: "grep", Query: "Open"},
+			{Kind: "search", Query: "  "},
+			{Kind: "references", Query: "Open", SymbolType: "function"},
+		},
+	}
+	if !Normalize(plan) {
+		t.Fatal("Expected valid steps to be left")
+	}
+	if plan.Intent != IntentSearch {
+		t.Errorf("Expected an unknown intent to become %s, got %s", IntentSearch, plan.Intent)
+	}
+	want := []types.QueryStep{
+		{Kind: StepSymbol, Query: "Open", SymbolType: "function", PathPrefix: "internal/store"},
+		{Kind: StepReferences, Query: "Open"},
+	}
+	if len(plan.Steps) != len(want) || plan.Steps[0] != want[0] || plan.Steps[1] != want[1] {
+		t.Errorf("Normalize steps = %+v, want %+v", plan.Steps, want)
+	}
+
+	if Normalize(&types.QueryPlan{Steps: []types.QueryStep{{Kind: "files"}}}) {
+		t.Error("Expected a plan without valid steps to be rejected")
+	}
+}
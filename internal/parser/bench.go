@@ -0,0 +1,260 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Parser implementations compared by the benchmarks
+const (
+	ImplementationRegex      = "regex"
+	ImplementationTreeSitter = "tree-sitter"
+)
+
+// BenchmarkSample is a representative source file used to measure parser
+// throughput
+type BenchmarkSample struct {
+	Name     string
+	Language string
+	Content  string
+}
+
+// BenchmarkResult is the measured throughput of one parser on one sample
+type BenchmarkResult struct {
+	Name           string  `json:"name"`
+	Language       string  `json:"language"`
+	Implementation string  `json:"implementation"`
+	Bytes          int     `json:"bytes"`
+	Iterations     int     `json:"iterations"`
+	NsPerOp        int64   `json:"ns_per_op"`
+	MBPerSecond    float64 `json:"mb_per_second"`
+}
+
+// BenchmarkRegression reports a result whose throughput dropped by more than
+// the allowed budget
+type BenchmarkRegression struct {
+	Name              string  `json:"name"`
+	BaselineMBPerSec  float64 `json:"baseline_mb_per_second"`
+	CurrentMBPerSec   float64 `json:"current_mb_per_second"`
+	RegressionPercent float64 `json:"regression_percent"`
+}
+
+// BenchmarkBaseline is the stored throughput that later runs are compared
+// against, keyed by result name
+type BenchmarkBaseline struct {
+	CreatedAt time.Time          `json:"created_at"`
+	Results   map[string]float64 `json:"mb_per_second"`
+}
+
+// parserVariant is one parser implementation for a language
+type parserVariant struct {
+	implementation string
+	parser         Parser
+}
+
+// parserVariants returns the regex parser and, when available, the
+// tree-sitter parser for a language
+func parserVariants(language string) []parserVariant {
+	var variants []parserVariant
+	switch language {
+	case "go":
+		variants = append(variants, parserVariant{ImplementationRegex, NewGoParser()})
+	case "python":
+		variants = append(variants, parserVariant{ImplementationRegex, NewPythonParser()})
+	case "javascript":
+		variants = append(variants, parserVariant{ImplementationRegex, NewJavaScriptParser()})
+	case "java":
+		variants = append(variants, parserVariant{ImplementationRegex, NewJavaParser()})
+	}
+	if ts := NewTreeSitterParser(language); ts != nil {
+		variants = append(variants, parserVariant{ImplementationTreeSitter, ts})
+	}
+	return variants
+}
+
+// BenchmarkSamples returns generated sources for every language that has both
+// a regex and a tree-sitter parser. Each sample mixes imports, comments,
+// types, functions and variables in roughly the proportions of real code.
+func BenchmarkSamples() []BenchmarkSample {
+	const symbols = 60
+	return []BenchmarkSample{
+		{Name: "go", Language: "go", Content: generateGoSample(symbols)},
+		{Name: "python", Language: "python", Content: generatePythonSample(symbols)},
+		{Name: "javascript", Language: "javascript", Content: generateJavaScriptSample(symbols)},
+		{Name: "java", Language: "java", Content: generateJavaSample(symbols)},
+	}
+}
+
+// RunBenchmarks parses each sample with every parser for its language,
+// repeating each measurement count times and keeping the fastest, and stops
+// each measurement once it has run for at least minDuration
+func RunBenchmarks(samples []BenchmarkSample, minDuration time.Duration, count int) ([]BenchmarkResult, error) {
+	if count < 1 {
+		count = 1
+	}
+
+	var results []BenchmarkResult
+	for _, sample := range samples {
+		for _, variant := range parserVariants(sample.Language) {
+			best := BenchmarkResult{
+				Name:           sample.Name + "/" + variant.implementation,
+				Language:       sample.Language,
+				Implementation: variant.implementation,
+				Bytes:          len(sample.Content),
+			}
+
+			for run := 0; run < count; run++ {
+				iterations, elapsed, err := measureParse(variant.parser, sample, minDuration)
+				if err != nil {
+					return nil, fmt.Errorf("benchmark %s failed: %w", best.Name, err)
+				}
+
+				nsPerOp := elapsed.Nanoseconds() / int64(iterations)
+				if best.Iterations == 0 || nsPerOp < best.NsPerOp {
+					best.Iterations = iterations
+					best.NsPerOp = nsPerOp
+					best.MBPerSecond = float64(len(sample.Content)) * float64(iterations) / elapsed.Seconds() / 1e6
+				}
+			}
+
+			results = append(results, best)
+		}
+	}
+	return results, nil
+}
+
+// measureParse parses a sample repeatedly until minDuration has passed
+func measureParse(parser Parser, sample BenchmarkSample, minDuration time.Duration) (int, time.Duration, error) {
+	path := "bench/" + sample.Name
+	iterations := 0
+	start := time.Now()
+	for {
+		if _, err := parser.Parse(sample.Content, path); err != nil {
+			return 0, 0, err
+		}
+		iterations++
+
+		if elapsed := time.Since(start); elapsed >= minDuration {
+			return iterations, elapsed, nil
+		}
+	}
+}
+
+// CompareBenchmarks returns the results whose throughput fell more than
+// maxRegression (a fraction, 0.2 = 20%) below the baseline. Results missing
+// from the baseline are not compared.
+func CompareBenchmarks(results []BenchmarkResult, baseline *BenchmarkBaseline, maxRegression float64) []BenchmarkRegression {
+	var regressions []BenchmarkRegression
+	for _, result := range results {
+		base, ok := baseline.Results[result.Name]
+		if !ok || base <= 0 {
+			continue
+		}
+
+		drop := (base - result.MBPerSecond) / base
+		if drop > maxRegression {
+			regressions = append(regressions, BenchmarkRegression{
+				Name:              result.Name,
+				BaselineMBPerSec:  base,
+				CurrentMBPerSec:   result.MBPerSecond,
+				RegressionPercent: drop * 100,
+			})
+		}
+	}
+
+	sort.Slice(regressions, func(i, j int) bool {
+		return regressions[i].RegressionPercent > regressions[j].RegressionPercent
+	})
+	return regressions
+}
+
+// NewBenchmarkBaseline creates a baseline from benchmark results
+func NewBenchmarkBaseline(results []BenchmarkResult) *BenchmarkBaseline {
+	baseline := &BenchmarkBaseline{
+		CreatedAt: time.Now(),
+		Results:   make(map[string]float64, len(results)),
+	}
+	for _, result := range results {
+		baseline.Results[result.Name] = result.MBPerSecond
+	}
+	return baseline
+}
+
+// LoadBenchmarkBaseline reads a baseline written by WriteBenchmarkBaseline
+func LoadBenchmarkBaseline(path string) (*BenchmarkBaseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline BenchmarkBaseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("failed to parse benchmark baseline %s: %w", path, err)
+	}
+	return &baseline, nil
+}
+
+// WriteBenchmarkBaseline stores a baseline as JSON
+func WriteBenchmarkBaseline(path string, baseline *BenchmarkBaseline) error {
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode benchmark baseline: %w", err)
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func generateGoSample(symbols int) string {
+	var b strings.Builder
+	b.WriteString("package sample\n\nimport (\n\t\"context\"\n\t\"fmt\"\n\t\"strings\"\n)\n\n")
+	for i := 0; i < symbols; i++ {
+		fmt.Fprintf(&b, "// Record%d holds one row of sample data\ntype Record%d struct {\n\tID    int\n\tName  string\n\tTags  []string\n}\n\n", i, i)
+		fmt.Fprintf(&b, "var defaultName%d = \"record-%d\"\n\n", i, i)
+		fmt.Fprintf(&b, "// Process%d normalises a record\nfunc (r *Record%d) Process%d(ctx context.Context, prefix string) (string, error) {\n", i, i, i)
+		b.WriteString("\tif r.Name == \"\" {\n\t\treturn \"\", fmt.Errorf(\"record %d has no name\", r.ID)\n\t}\n")
+		b.WriteString("\tparts := make([]string, 0, len(r.Tags))\n\tfor _, tag := range r.Tags {\n\t\tparts = append(parts, strings.ToLower(tag))\n\t}\n")
+		b.WriteString("\treturn prefix + r.Name + strings.Join(parts, \",\"), nil\n}\n\n")
+	}
+	return b.String()
+}
+
+func generatePythonSample(symbols int) string {
+	var b strings.Builder
+	b.WriteString("import os\nimport re\nfrom typing import List, Optional\n\n")
+	for i := 0; i < symbols; i++ {
+		fmt.Fprintf(&b, "DEFAULT_NAME_%d = \"record-%d\"\n\n", i, i)
+		fmt.Fprintf(&b, "class Record%d:\n    \"\"\"One row of sample data\"\"\"\n\n", i)
+		b.WriteString("    def __init__(self, name: str, tags: Optional[List[str]] = None):\n        self.name = name\n        self.tags = tags or []\n\n")
+		fmt.Fprintf(&b, "    def process_%d(self, prefix: str) -> str:\n", i)
+		b.WriteString("        # Normalise the tags before joining them\n        parts = [tag.lower() for tag in self.tags]\n        return prefix + self.name + \",\".join(parts)\n\n\n")
+		fmt.Fprintf(&b, "def load_%d(path: str) -> List[str]:\n    with open(os.path.join(path, \"data\")) as handle:\n        return [line for line in handle if re.match(r\"\\w+\", line)]\n\n\n", i)
+	}
+	return b.String()
+}
+
+func generateJavaScriptSample(symbols int) string {
+	var b strings.Builder
+	b.WriteString("import { readFile } from 'fs/promises';\nimport path from 'path';\n\n")
+	for i := 0; i < symbols; i++ {
+		fmt.Fprintf(&b, "const defaultName%d = 'record-%d';\n\n", i, i)
+		fmt.Fprintf(&b, "/**\n * One row of sample data\n */\nclass Record%d {\n  constructor(name, tags = []) {\n    this.name = name;\n    this.tags = tags;\n  }\n\n", i)
+		fmt.Fprintf(&b, "  process%d(prefix) {\n    // Normalise the tags before joining them\n    const parts = this.tags.map((tag) => tag.toLowerCase());\n    return prefix + this.name + parts.join(',');\n  }\n}\n\n", i)
+		fmt.Fprintf(&b, "async function load%d(dir) {\n  const data = await readFile(path.join(dir, 'data'), 'utf8');\n  return data.split('\\n').filter(Boolean);\n}\n\n", i)
+	}
+	return b.String()
+}
+
+func generateJavaSample(symbols int) string {
+	var b strings.Builder
+	b.WriteString("package sample;\n\nimport java.util.ArrayList;\nimport java.util.List;\n\npublic class Records {\n")
+	for i := 0; i < symbols; i++ {
+		fmt.Fprintf(&b, "    private static final String DEFAULT_NAME_%d = \"record-%d\";\n\n", i, i)
+		fmt.Fprintf(&b, "    /** One row of sample data */\n    public static class Record%d {\n        private String name;\n        private List<String> tags = new ArrayList<>();\n\n", i)
+		fmt.Fprintf(&b, "        public String process%d(String prefix) {\n            // Normalise the tags before joining them\n            List<String> parts = new ArrayList<>();\n            for (String tag : tags) {\n                parts.add(tag.toLowerCase());\n            }\n            return prefix + name + String.join(\",\", parts);\n        }\n    }\n\n", i)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,333 @@
+package parser
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// maxOutlineSignature caps the length of outline signatures, which are cut
+// from the declaration header and can be long for functions with many
+// parameters
+const maxOutlineSignature = 200
+
+// OutlineNode is a declaration in the outline of a file, holding the
+// declarations nested in it: the methods and fields of a class, the
+// functions declared inside a function
+type OutlineNode struct {
+	Name      string        `json:"name"`
+	Kind      string        `json:"kind"`
+	StartLine int           `json:"start_line"` // Line of the declaration, below its doc comment
+	EndLine   int           `json:"end_line"`
+	Signature string        `json:"signature,omitempty"`
+	DocString string        `json:"doc_string,omitempty"`
+	Children  []OutlineNode `json:"children,omitempty"`
+
+	container string
+}
+
+// memberKinds maps the node types of fields and member signatures, which
+// are not symbols of their own, to outline kinds
+var memberKinds = map[string]string{
+	"field_declaration":         "field",
+	"field_definition":          "field",
+	"public_field_definition":   "field",
+	"property_signature":        "field",
+	"property_declaration":      "property",
+	"method_spec":               "method",
+	"method_elem":               "method",
+	"method_signature":          "method",
+	"abstract_method_signature": "method",
+}
+
+// Outline returns the declarations of content as a tree. Go methods and
+// C++ member functions defined outside their type are nested under the
+// type when it is declared in the same file.
+func (p *TreeSitterParser) Outline(content string) ([]OutlineNode, error) {
+	tree, err := p.ParseTree(content)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	source := []byte(content)
+	outline := p.collectOutline(tree.RootNode(), source, "", nil)
+	return nestMembers(outline), nil
+}
+
+// collectOutline walks node, appending the declarations it finds to nodes
+func (p *TreeSitterParser) collectOutline(node *sitter.Node, source []byte, container string, nodes []OutlineNode) []OutlineNode {
+	if symbol, ok := p.symbolAt(node, source, container); ok {
+		outer := outermostDeclaration(node)
+		entry := OutlineNode{
+			Name:      symbol.Name,
+			Kind:      symbol.Kind,
+			StartLine: int(outer.StartPoint().Row) + 1,
+			EndLine:   symbol.EndLine,
+			Signature: outlineSignature(source, symbol),
+			DocString: commentText(string(source[symbol.Start:symbol.DeclStart])),
+			container: symbol.Container,
+		}
+		if entry.DocString == "" {
+			entry.DocString = p.pythonDocString(node.ChildByFieldName("body"), source)
+		}
+
+		inner := symbol.Name
+		if symbol.Kind != "impl" {
+			inner = symbol.QualifiedName()
+		}
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			entry.Children = p.collectOutline(node.NamedChild(i), source, inner, entry.Children)
+		}
+		// Functions declared inside functions are not methods
+		if entry.Kind == "function" || entry.Kind == "method" {
+			for i := range entry.Children {
+				if entry.Children[i].Kind == "method" {
+					entry.Children[i].Kind = "function"
+				}
+			}
+		}
+		return append(nodes, entry)
+	}
+
+	if members := p.membersAt(node, source); members != nil {
+		return append(nodes, members...)
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		nodes = p.collectOutline(node.NamedChild(i), source, container, nodes)
+	}
+	return nodes
+}
+
+// membersAt returns the outline entries of a field declaration or member
+// signature, one per declared name, or nil if node is neither
+func (p *TreeSitterParser) membersAt(node *sitter.Node, source []byte) []OutlineNode {
+	kind, ok := memberKinds[node.Type()]
+	if !ok {
+		if !isPythonClassAttribute(node) {
+			return nil
+		}
+		kind = "field"
+	}
+
+	names := p.memberNames(node, source)
+	if len(names) == 0 {
+		return nil
+	}
+	doc := commentText(string(source[attachedStart(node, source).StartByte():node.StartByte()]))
+	signature := collapseSignature(p.getNodeText(node, source))
+
+	members := make([]OutlineNode, 0, len(names))
+	for _, name := range names {
+		members = append(members, OutlineNode{
+			Name:      name,
+			Kind:      kind,
+			StartLine: p.getLineNumber(node),
+			EndLine:   p.getEndLineNumber(node),
+			Signature: signature,
+			DocString: doc,
+		})
+	}
+	return members
+}
+
+// memberNames returns the names a field declaration or member signature
+// declares. Go fields may declare several names at once; embedded Go
+// fields are named after their type.
+func (p *TreeSitterParser) memberNames(node *sitter.Node, source []byte) []string {
+	var names []string
+	switch node.Type() {
+	case "field_declaration":
+		if p.language == "go" {
+			for i := 0; i < int(node.NamedChildCount()); i++ {
+				if child := node.NamedChild(i); child.Type() == "field_identifier" {
+					names = append(names, p.getNodeText(child, source))
+				}
+			}
+			if len(names) == 0 {
+				if typeName := p.getFieldText(node, "type", source); typeName != "" {
+					typeName = strings.TrimLeft(typeName, "*")
+					names = append(names, typeName[strings.LastIndex(typeName, ".")+1:])
+				}
+			}
+			return names
+		}
+	case "assignment":
+		if left := node.ChildByFieldName("left"); left != nil && left.Type() == "identifier" {
+			names = append(names, p.getNodeText(left, source))
+		}
+		return names
+	}
+
+	if name := p.getFieldText(node, "name", source); name != "" {
+		return append(names, name)
+	}
+	if name := p.getFieldText(node, "property", source); name != "" {
+		return append(names, name)
+	}
+	// Java, C# and C++ fields name their variables in declarators
+	var collect func(n *sitter.Node)
+	collect = func(n *sitter.Node) {
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			child := n.NamedChild(i)
+			switch child.Type() {
+			case "variable_declarator":
+				if name := p.getFieldText(child, "name", source); name != "" {
+					names = append(names, name)
+				} else if identifier := firstChildOfType(child, "identifier"); identifier != nil {
+					names = append(names, p.getNodeText(identifier, source))
+				}
+			case "field_identifier", "simple_identifier":
+				names = append(names, p.getNodeText(child, source))
+			case "variable_declaration", "variable_declarator_list", "pointer_declarator":
+				collect(child)
+			}
+		}
+	}
+	collect(node)
+	return names
+}
+
+// isPythonClassAttribute reports whether node is an assignment directly in
+// the body of a Python class
+func isPythonClassAttribute(node *sitter.Node) bool {
+	if node.Type() != "assignment" {
+		return false
+	}
+	statement := node.Parent()
+	if statement == nil || statement.Type() != "expression_statement" {
+		return false
+	}
+	block := statement.Parent()
+	return block != nil && block.Type() == "block" && block.Parent() != nil && block.Parent().Type() == "class_definition"
+}
+
+// pythonDocString returns the docstring opening a Python function or class
+// body, or an empty string
+func (p *TreeSitterParser) pythonDocString(body *sitter.Node, source []byte) string {
+	if p.language != "python" || body == nil || body.NamedChildCount() == 0 {
+		return ""
+	}
+	statement := body.NamedChild(0)
+	if statement.Type() != "expression_statement" || statement.NamedChildCount() == 0 || statement.NamedChild(0).Type() != "string" {
+		return ""
+	}
+	text := p.getNodeText(statement.NamedChild(0), source)
+	text = strings.TrimLeft(text, "rRbBuU")
+	for _, quote := range []string{`"""`, `'''`, `"`, `'`} {
+		if strings.HasPrefix(text, quote) && strings.HasSuffix(text, quote) && len(text) >= 2*len(quote) {
+			text = text[len(quote) : len(text)-len(quote)]
+			break
+		}
+	}
+	return dedentDocString(text)
+}
+
+// dedentDocString trims a docstring and removes the indentation its
+// continuation lines share
+func dedentDocString(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimLeft(line, " \t"); trimmed != "" {
+			if width := len(line) - len(trimmed); indent < 0 || width < indent {
+				indent = width
+			}
+		}
+	}
+	for i, line := range lines {
+		if i > 0 && len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// commentText returns the text of the comments attached above a
+// declaration without their comment markers. Decorators and attributes in
+// between are left out.
+func commentText(attached string) string {
+	var lines []string
+	for _, line := range strings.Split(attached, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "", strings.HasPrefix(line, "@"), strings.HasPrefix(line, "#["), strings.HasPrefix(line, "["):
+			continue
+		case strings.HasPrefix(line, "///"), strings.HasPrefix(line, "//!"):
+			line = line[3:]
+		case strings.HasPrefix(line, "//"):
+			line = line[2:]
+		case strings.HasPrefix(line, "/**"):
+			line = line[3:]
+		case strings.HasPrefix(line, "/*"):
+			line = line[2:]
+		case strings.HasPrefix(line, "*/"):
+			line = line[2:]
+		case strings.HasPrefix(line, "*"), strings.HasPrefix(line, "#"):
+			line = line[1:]
+		}
+		line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), "*/"))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// SymbolSignature returns the header of a declaration in content, from its
+// start to its body, on one line
+func SymbolSignature(content string, symbol SymbolRange) string {
+	return outlineSignature([]byte(content), symbol)
+}
+
+// outlineSignature is SymbolSignature for parsed source
+func outlineSignature(source []byte, symbol SymbolRange) string {
+	end := symbol.End
+	if symbol.BodyStart > symbol.DeclStart {
+		end = symbol.BodyStart
+	}
+	signature := collapseSignature(string(source[symbol.DeclStart:end]))
+	return strings.TrimSpace(strings.TrimSuffix(signature, ":"))
+}
+
+// collapseSignature joins declaration text onto one line and shortens it to
+// maxOutlineSignature characters
+func collapseSignature(text string) string {
+	signature := strings.Join(strings.Fields(text), " ")
+	if runes := []rune(signature); len(runes) > maxOutlineSignature {
+		signature = string(runes[:maxOutlineSignature]) + "..."
+	}
+	return signature
+}
+
+// nestMembers moves top-level declarations that belong to a type declared
+// at the top level, such as Go methods, under that type
+func nestMembers(outline []OutlineNode) []OutlineNode {
+	owners := make(map[string]int)
+	for i, node := range outline {
+		if node.container == "" && node.Kind != "function" && node.Kind != "method" {
+			owners[node.Name] = i
+		}
+	}
+
+	nested := make([]bool, len(outline))
+	for i, node := range outline {
+		if node.container == "" {
+			continue
+		}
+		if owner, ok := owners[node.container]; ok {
+			outline[owner].Children = append(outline[owner].Children, node)
+			nested[i] = true
+		}
+	}
+
+	result := make([]OutlineNode, 0, len(outline))
+	for i, node := range outline {
+		if !nested[i] {
+			result = append(result, node)
+		}
+	}
+	return result
+}
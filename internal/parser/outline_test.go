@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"testing"
+)
+
+func outline(t *testing.T, language, content string) []OutlineNode {
+	t.Helper()
+	parser := NewTreeSitterParser(language)
+	if parser == nil {
+		t.Skipf("Tree-sitter %s parser not available", language)
+	}
+	nodes, err := parser.Outline(content)
+	if err != nil {
+		t.Fatalf("Outline failed: %v", err)
+	}
+	return nodes
+}
+
+func TestOutlineGo(t *testing.T) {
+	nodes := outline(t, "go", symbolsGoSource)
+	if len(nodes) != 2 {
+		t.Fatalf("Expected Store and Load at the top level, got %+v", nodes)
+	}
+
+	store := nodes[0]
+	if store.Name != "Store" || store.Kind != "type" || store.StartLine != 4 || store.EndLine != 6 {
+		t.Errorf("Unexpected type entry: %+v", store)
+	}
+	if store.DocString != "Store keeps values" || store.Signature != "type Store struct" {
+		t.Errorf("Unexpected doc string %q or signature %q", store.DocString, store.Signature)
+	}
+	if len(store.Children) != 2 {
+		t.Fatalf("Expected the field and the method under Store, got %+v", store.Children)
+	}
+	if field := store.Children[0]; field.Name != "values" || field.Kind != "field" || field.Signature != "values map[string]int" {
+		t.Errorf("Unexpected field entry: %+v", field)
+	}
+	if method := store.Children[1]; method.Name != "Load" || method.Kind != "method" || method.StartLine != 9 || method.Signature != "func (s *Store) Load(key string) int" {
+		t.Errorf("Unexpected method entry: %+v", method)
+	}
+
+	if function := nodes[1]; function.Name != "Load" || function.Kind != "function" || function.DocString != "" {
+		t.Errorf("Unexpected function entry: %+v", function)
+	}
+}
+
+func TestOutlinePython(t *testing.T) {
+	source := `class Cache(Base):
+    """Keeps recent values.
+
+    Entries expire after a minute.
+    """
+
+    limit = 100
+
+    # Look up a value
+    @cached
+    def get(self, key):
+        def load():
+            return None
+        return load()
+`
+	nodes := outline(t, "python", source)
+	if len(nodes) != 1 {
+		t.Fatalf("Expected one class, got %+v", nodes)
+	}
+
+	class := nodes[0]
+	if class.Signature != "class Cache(Base)" || class.DocString != "Keeps recent values.\n\nEntries expire after a minute." {
+		t.Errorf("Unexpected class signature %q or doc string %q", class.Signature, class.DocString)
+	}
+	if len(class.Children) != 2 || class.Children[0].Name != "limit" || class.Children[0].Kind != "field" {
+		t.Fatalf("Expected the class attribute and the method, got %+v", class.Children)
+	}
+
+	method := class.Children[1]
+	if method.Name != "get" || method.Kind != "method" || method.DocString != "Look up a value" || method.StartLine != 10 {
+		t.Errorf("Unexpected method entry: %+v", method)
+	}
+	if len(method.Children) != 1 || method.Children[0].Name != "load" || method.Children[0].Kind != "function" {
+		t.Errorf("Expected the nested function, got %+v", method.Children)
+	}
+}
+
+func TestOutlineJava(t *testing.T) {
+	source := `/** A user account */
+public class Account {
+    private int id, version;
+
+    /**
+     * Returns the id
+     */
+    public int getId() {
+        return id;
+    }
+}
+`
+	nodes := outline(t, "java", source)
+	if len(nodes) != 1 || nodes[0].DocString != "A user account" {
+		t.Fatalf("Expected the documented class, got %+v", nodes)
+	}
+
+	children := nodes[0].Children
+	if len(children) != 3 || children[0].Name != "id" || children[1].Name != "version" || children[1].Kind != "field" {
+		t.Fatalf("Expected both fields and the method, got %+v", children)
+	}
+	if method := children[2]; method.Name != "getId" || method.DocString != "Returns the id" || method.Signature != "public int getId()" {
+		t.Errorf("Unexpected method entry: %+v", method)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/my-mcp/code-indexer/pkg/textpos"
 	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
@@ -49,6 +50,23 @@ func NewRegistry() *Registry {
 		registry.Register(NewJavaParser())
 	}
 
+	// Languages without a regex parser use the generic parser when their
+	// grammar is unavailable
+	for _, language := range []string{"typescript", "rust", "c", "cpp", "csharp", "kotlin", "ruby"} {
+		if tsParser := NewTreeSitterParser(language); tsParser != nil {
+			registry.Register(tsParser)
+		}
+	}
+
+	// Documentation and configuration files are split into sections and keys
+	registry.Register(NewMarkdownParser())
+	registry.Register(NewConfigParser("yaml"))
+	registry.Register(NewConfigParser("json"))
+
+	// API and database schemas are split into their definitions
+	registry.Register(NewProtobufParser())
+	registry.Register(NewSQLParser())
+
 	// Register generic parser as fallback
 	registry.Register(NewGenericParser())
 
@@ -69,6 +87,26 @@ func (r *Registry) GetParser(language string) Parser {
 	return r.parsers["generic"]
 }
 
+// Implementations returns the parser implementation used for each language:
+// ImplementationTreeSitter, ImplementationRegex, ImplementationStructured or
+// "generic" for the fallback
+func (r *Registry) Implementations() map[string]string {
+	implementations := make(map[string]string, len(r.parsers))
+	for language, parser := range r.parsers {
+		switch parser.(type) {
+		case *TreeSitterParser:
+			implementations[language] = ImplementationTreeSitter
+		case *MarkdownParser, *ConfigParser, *ProtobufParser, *SQLParser:
+			implementations[language] = ImplementationStructured
+		case *GenericParser:
+			implementations[language] = "generic"
+		default:
+			implementations[language] = ImplementationRegex
+		}
+	}
+	return implementations
+}
+
 // ParseFile parses a file and extracts metadata
 func (r *Registry) ParseFile(content string, filePath, language string) (*types.CodeFile, error) {
 	parser := r.GetParser(language)
@@ -88,7 +126,7 @@ func (p *BaseParser) GetLanguage() string {
 // extractComments extracts comments from source code
 func (p *BaseParser) extractComments(content string, lineCommentPrefix, blockCommentStart, blockCommentEnd string) []types.Comment {
 	var comments []types.Comment
-	lines := strings.Split(content, "\n")
+	lines := textpos.SplitLines(content)
 
 	inBlockComment := false
 	blockCommentStartLine := 0
@@ -171,10 +209,7 @@ func (p *BaseParser) extractBlockCommentText(lines []string, startLine, endLine
 
 // countLines counts the number of lines in content
 func (p *BaseParser) countLines(content string) int {
-	if content == "" {
-		return 0
-	}
-	return strings.Count(content, "\n") + 1
+	return textpos.CountLines(content)
 }
 
 // findLineNumber finds the line number of a substring in content
@@ -184,7 +219,7 @@ func (p *BaseParser) findLineNumber(content, substring string) int {
 		return 1
 	}
 	
-	return strings.Count(content[:index], "\n") + 1
+	return textpos.LineAt(content, index)
 }
 
 // GenericParser provides basic parsing for any text file
@@ -503,7 +538,7 @@ func (p *PythonParser) extractPythonVariables(content string) []types.Variable {
 	var variables []types.Variable
 
 	varRe := regexp.MustCompile(`^(\w+)\s*=`)
-	lines := strings.Split(content, "\n")
+	lines := textpos.SplitLines(content)
 
 	for i, line := range lines {
 		line = strings.TrimSpace(line)
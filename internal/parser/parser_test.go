@@ -260,6 +260,17 @@ func TestParserRegistry(t *testing.T) {
 	if unknownParser.GetLanguage() != "generic" {
 		t.Errorf("Expected generic parser for unknown language, got %s", unknownParser.GetLanguage())
 	}
+
+	// Test implementation reporting
+	implementations := registry.Implementations()
+	for _, language := range []string{"go", "python", "javascript", "java"} {
+		if implementations[language] != ImplementationTreeSitter {
+			t.Errorf("Expected %s to use %s, got %q", language, ImplementationTreeSitter, implementations[language])
+		}
+	}
+	if implementations["generic"] != "generic" {
+		t.Errorf("Expected generic fallback, got %q", implementations["generic"])
+	}
 }
 
 func TestBaseParserHelpers(t *testing.T) {
@@ -295,3 +306,42 @@ code here`
 		t.Errorf("Expected at least 2 comments, got %d", len(comments))
 	}
 }
+
+func TestCompareBenchmarks(t *testing.T) {
+	baseline := &BenchmarkBaseline{Results: map[string]float64{
+		"go/regex":       10,
+		"go/tree-sitter": 4,
+	}}
+	results := []BenchmarkResult{
+		{Name: "go/regex", MBPerSecond: 9},       // 10% slower, within budget
+		{Name: "go/tree-sitter", MBPerSecond: 2}, // 50% slower
+		{Name: "java/regex", MBPerSecond: 1},     // not in baseline
+	}
+
+	regressions := CompareBenchmarks(results, baseline, 0.2)
+	if len(regressions) != 1 {
+		t.Fatalf("Expected 1 regression, got %d", len(regressions))
+	}
+	if regressions[0].Name != "go/tree-sitter" || regressions[0].RegressionPercent != 50 {
+		t.Errorf("Unexpected regression: %+v", regressions[0])
+	}
+}
+
+// BenchmarkParsers compares the regex and tree-sitter parsers on the shared
+// samples. Run with: go test -bench Parsers ./internal/parser/
+func BenchmarkParsers(b *testing.B) {
+	for _, sample := range BenchmarkSamples() {
+		for _, variant := range parserVariants(sample.Language) {
+			sample, variant := sample, variant
+			b.Run(sample.Name+"/"+variant.implementation, func(b *testing.B) {
+				b.SetBytes(int64(len(sample.Content)))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := variant.parser.Parse(sample.Content, "bench/"+sample.Name); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package parser
+
+import (
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// builtinNames are language builtins that are never defined in a repository,
+// so references to them are left out of the reference index
+var builtinNames = map[string]map[string]bool{
+	"go": setOf("append", "cap", "clear", "close", "complex", "copy", "delete", "imag", "len", "make",
+		"max", "min", "new", "panic", "print", "println", "real", "recover",
+		"any", "bool", "byte", "comparable", "complex64", "complex128", "error", "float32", "float64",
+		"int", "int8", "int16", "int32", "int64", "rune", "string",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr"),
+	"python": setOf("abs", "all", "any", "bool", "dict", "enumerate", "filter", "float", "getattr",
+		"hasattr", "int", "isinstance", "issubclass", "iter", "len", "list", "map", "max", "min",
+		"next", "open", "print", "range", "repr", "reversed", "set", "setattr", "sorted", "str",
+		"sum", "super", "tuple", "type", "zip"),
+	"kotlin": setOf("check", "error", "listOf", "mapOf", "mutableListOf", "mutableMapOf", "mutableSetOf",
+		"print", "println", "require", "setOf"),
+	"ruby": setOf("attr_accessor", "attr_reader", "attr_writer", "extend", "include", "load", "p",
+		"print", "private", "protected", "public", "puts", "raise", "require", "require_relative"),
+}
+
+// setOf builds a set of names
+func setOf(names ...string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+// extractReferences collects the call sites and type uses of a file, each
+// with the function it appears in
+func (p *TreeSitterParser) extractReferences(node *sitter.Node, source []byte) []types.Reference {
+	var references []types.Reference
+	p.collectReferences(node, source, "", &references)
+	return references
+}
+
+// collectReferences walks the tree, tracking the enclosing function
+func (p *TreeSitterParser) collectReferences(node *sitter.Node, source []byte, caller string, references *[]types.Reference) {
+	if name := p.definedFunctionName(node, source); name != "" {
+		caller = name
+	}
+
+	for _, reference := range p.referencesAt(node, source) {
+		if builtinNames[p.language][reference.Name] {
+			continue
+		}
+		reference.Caller = caller
+		*references = append(*references, reference)
+	}
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		p.collectReferences(node.Child(i), source, caller, references)
+	}
+}
+
+// definedFunctionName returns the name of the function or method a node
+// declares, or "" for any other node. Anonymous functions keep the name of
+// the function they are nested in.
+func (p *TreeSitterParser) definedFunctionName(node *sitter.Node, source []byte) string {
+	switch node.Type() {
+	case "function_declaration", "method_declaration", "function_definition",
+		"method_definition", "constructor_declaration", "function_item", "method", "singleton_method":
+		if name := node.ChildByFieldName("name"); name != nil {
+			return p.getNodeText(name, source)
+		}
+		// C functions are named by their declarator, Kotlin ones by their
+		// first identifier
+		switch p.language {
+		case "c", "cpp":
+			name, _ := p.cDeclaratorName(node.ChildByFieldName("declarator"), source)
+			return name
+		case "kotlin":
+			if name := firstChildOfType(node, "simple_identifier"); name != nil {
+				return p.getNodeText(name, source)
+			}
+		}
+	}
+	return ""
+}
+
+// referencesAt returns the references a node makes as a call or a type use
+// in the file's language
+func (p *TreeSitterParser) referencesAt(node *sitter.Node, source []byte) []types.Reference {
+	switch p.language {
+	case "go":
+		switch node.Type() {
+		case "call_expression":
+			return p.callReference(node.ChildByFieldName("function"), source)
+		case "type_identifier":
+			parent := node.Parent()
+			if parent != nil && parent.Type() == "type_spec" && sameNode(parent.ChildByFieldName("name"), node) {
+				return nil
+			}
+			reference := p.newReference(node, source, "type")
+			if parent != nil && parent.Type() == "qualified_type" {
+				if pkg := parent.ChildByFieldName("package"); pkg != nil {
+					reference.Qualifier = p.getNodeText(pkg, source)
+				}
+			}
+			return []types.Reference{reference}
+		}
+
+	case "python":
+		switch node.Type() {
+		case "call":
+			return p.callReference(node.ChildByFieldName("function"), source)
+		case "argument_list":
+			// Base classes of a class definition
+			if parent := node.Parent(); parent != nil && parent.Type() == "class_definition" {
+				return p.typeReferences(node, source, "identifier")
+			}
+		}
+
+	case "javascript":
+		switch node.Type() {
+		case "call_expression":
+			return p.callReference(node.ChildByFieldName("function"), source)
+		case "new_expression":
+			return p.callReference(node.ChildByFieldName("constructor"), source)
+		case "class_heritage":
+			return p.typeReferences(node, source, "identifier")
+		}
+
+	case "typescript":
+		switch node.Type() {
+		case "call_expression":
+			return p.callReference(node.ChildByFieldName("function"), source)
+		case "new_expression":
+			return p.callReference(node.ChildByFieldName("constructor"), source)
+		case "extends_clause":
+			// The superclass is an expression rather than a type
+			return p.typeReferences(node, source, "identifier")
+		case "type_identifier":
+			parent := node.Parent()
+			if parent != nil && sameNode(parent.ChildByFieldName("name"), node) {
+				switch parent.Type() {
+				case "interface_declaration", "type_alias_declaration", "class_declaration",
+					"abstract_class_declaration", "type_parameter":
+					return nil
+				}
+			}
+			return []types.Reference{p.newReference(node, source, "type")}
+		}
+
+	case "java":
+		switch node.Type() {
+		case "method_invocation":
+			name := node.ChildByFieldName("name")
+			if name == nil {
+				return nil
+			}
+			reference := p.newReference(name, source, "call")
+			if object := node.ChildByFieldName("object"); object != nil {
+				reference.Qualifier = p.getNodeText(object, source)
+			}
+			return []types.Reference{reference}
+		case "object_creation_expression":
+			if typeNode := node.ChildByFieldName("type"); typeNode != nil && typeNode.Type() == "type_identifier" {
+				return []types.Reference{p.newReference(typeNode, source, "call")}
+			}
+		case "type_identifier":
+			// Instantiations are recorded as calls of the constructor
+			if parent := node.Parent(); parent != nil && parent.Type() == "object_creation_expression" {
+				return nil
+			}
+			return []types.Reference{p.newReference(node, source, "type")}
+		}
+
+	case "rust", "c", "cpp":
+		if node.Type() == "call_expression" {
+			return p.callReference(node.ChildByFieldName("function"), source)
+		}
+
+	case "csharp":
+		switch node.Type() {
+		case "invocation_expression":
+			return p.callReference(node.ChildByFieldName("function"), source)
+		case "object_creation_expression":
+			return p.callReference(node.ChildByFieldName("type"), source)
+		}
+
+	case "kotlin":
+		// Kotlin calls have no named fields; the callee comes first
+		if node.Type() == "call_expression" && node.NamedChildCount() > 0 {
+			return p.callReference(node.NamedChild(0), source)
+		}
+
+	case "ruby":
+		if node.Type() == "call" {
+			method := node.ChildByFieldName("method")
+			if method == nil {
+				return nil
+			}
+			reference := p.newReference(method, source, "call")
+			reference.Qualifier = p.getFieldText(node, "receiver", source)
+			return []types.Reference{reference}
+		}
+	}
+
+	return nil
+}
+
+// memberAccessFields names the object and member fields of the member
+// access expressions of each grammar. C and Rust field expressions name
+// their object differently, so the object has candidates.
+var memberAccessFields = map[string]struct {
+	objects []string
+	member  string
+}{
+	"selector_expression":      {[]string{"operand"}, "field"},
+	"attribute":                {[]string{"object"}, "attribute"},
+	"member_expression":        {[]string{"object"}, "property"},
+	"field_expression":         {[]string{"value", "argument"}, "field"},
+	"scoped_identifier":        {[]string{"path"}, "name"},
+	"qualified_identifier":     {[]string{"scope"}, "name"},
+	"member_access_expression": {[]string{"expression"}, "name"},
+}
+
+// callReference builds a call reference from the callee expression of a
+// call. Plain identifiers are called by name; member accesses are split into
+// the qualifier and the member. Other callees, such as calls of call
+// results, are not recorded.
+func (p *TreeSitterParser) callReference(callee *sitter.Node, source []byte) []types.Reference {
+	if callee == nil {
+		return nil
+	}
+
+	switch callee.Type() {
+	case "identifier", "simple_identifier", "type_identifier":
+		return []types.Reference{p.newReference(callee, source, "call")}
+
+	case "navigation_expression":
+		// Kotlin member access: the receiver, then a suffix naming the member
+		suffix := firstChildOfType(callee, "navigation_suffix")
+		if suffix == nil || callee.NamedChildCount() == 0 {
+			return nil
+		}
+		member := firstChildOfType(suffix, "simple_identifier")
+		if member == nil {
+			return nil
+		}
+		reference := p.newReference(member, source, "call")
+		reference.Qualifier = p.getNodeText(callee.NamedChild(0), source)
+		return []types.Reference{reference}
+	}
+
+	fields, ok := memberAccessFields[callee.Type()]
+	if !ok {
+		return nil
+	}
+	member := callee.ChildByFieldName(fields.member)
+	if member == nil {
+		return nil
+	}
+	reference := p.newReference(member, source, "call")
+	for _, field := range fields.objects {
+		if object := callee.ChildByFieldName(field); object != nil {
+			reference.Qualifier = p.getNodeText(object, source)
+			break
+		}
+	}
+	return []types.Reference{reference}
+}
+
+// typeReferences returns the plain type names listed under a node, such as
+// the base classes of a class
+func (p *TreeSitterParser) typeReferences(node *sitter.Node, source []byte, nameType string) []types.Reference {
+	var references []types.Reference
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if child := node.NamedChild(i); child.Type() == nameType {
+			references = append(references, p.newReference(child, source, "type"))
+		}
+	}
+	return references
+}
+
+// newReference creates a reference to the identifier a node spans
+func (p *TreeSitterParser) newReference(node *sitter.Node, source []byte, kind string) types.Reference {
+	return types.Reference{
+		Name:   p.getNodeText(node, source),
+		Kind:   kind,
+		Line:   p.getLineNumber(node),
+		Column: int(node.StartPoint().Column) + 1,
+	}
+}
+
+// sameNode reports whether two nodes span the same source range
+func sameNode(a, b *sitter.Node) bool {
+	return a != nil && b != nil && a.StartByte() == b.StartByte() && a.EndByte() == b.EndByte()
+}
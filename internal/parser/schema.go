@@ -0,0 +1,724 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Token kinds of schema files
+const (
+	schemaWord   = iota // Keyword, identifier or number, quoted identifiers unquoted
+	schemaString        // String literal, without its quotes
+	schemaPunct         // Any other single character
+)
+
+// schemaToken is a word, string or punctuation character of a Protocol
+// Buffers or SQL file
+type schemaToken struct {
+	kind       int
+	text       string
+	start, end int // Byte offsets in the file
+	line       int
+}
+
+// is reports whether the token is the keyword or punctuation, ignoring case
+func (t schemaToken) is(text string) bool {
+	return t.kind != schemaString && strings.EqualFold(t.text, text)
+}
+
+// schemaSyntax describes the lexical rules of a schema language
+type schemaSyntax struct {
+	lineComment        string
+	stringQuotes       string
+	identifierQuotes   string // Besides [ and ] when bracketIdentifiers is set
+	bracketIdentifiers bool
+	dollarQuotes       bool // PostgreSQL $tag$ ... $tag$ strings
+	backslashEscapes   bool // Otherwise a doubled quote escapes the quote
+}
+
+var (
+	protobufSyntax = schemaSyntax{lineComment: "//", stringQuotes: `"'`, backslashEscapes: true}
+	sqlSyntax      = schemaSyntax{lineComment: "--", stringQuotes: "'", identifierQuotes: "\"`", bracketIdentifiers: true, dollarQuotes: true}
+
+	dollarQuotePattern = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+)
+
+// scanSchema splits content into tokens, skipping comments. Offsets and
+// lines are counted from offset and line, where content starts in its file.
+func scanSchema(content string, syntax schemaSyntax, offset, line int) []schemaToken {
+	var tokens []schemaToken
+	add := func(kind int, text string, start, end, startLine int) {
+		tokens = append(tokens, schemaToken{kind: kind, text: text, start: offset + start, end: offset + end, line: startLine})
+	}
+
+	for i := 0; i < len(content); {
+		c := content[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case strings.HasPrefix(content[i:], syntax.lineComment):
+			if end := strings.IndexByte(content[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(content)
+			}
+		case strings.HasPrefix(content[i:], "/*"):
+			end := len(content)
+			if close := strings.Index(content[i+2:], "*/"); close >= 0 {
+				end = i + 2 + close + 2
+			}
+			line += strings.Count(content[i:end], "\n")
+			i = end
+		case strings.IndexByte(syntax.stringQuotes, c) >= 0, strings.IndexByte(syntax.identifierQuotes, c) >= 0,
+			c == '[' && syntax.bracketIdentifiers:
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			end := quotedEnd(content, i, closing, syntax.backslashEscapes)
+			kind := schemaWord
+			if strings.IndexByte(syntax.stringQuotes, c) >= 0 {
+				kind = schemaString
+			}
+			add(kind, content[i+1:min(end, len(content))], i, min(end+1, len(content)), line)
+			line += strings.Count(content[i:min(end, len(content))], "\n")
+			i = min(end+1, len(content))
+		case c == '$' && syntax.dollarQuotes && dollarQuotePattern.MatchString(content[i:]):
+			tag := dollarQuotePattern.FindString(content[i:])
+			bodyEnd, end := dollarQuoteEnd(content, i, tag)
+			add(schemaString, content[i+len(tag):bodyEnd], i, end, line)
+			line += strings.Count(content[i:end], "\n")
+			i = end
+		case isSchemaWordByte(c):
+			end := i + 1
+			for end < len(content) && isSchemaWordByte(content[end]) {
+				end++
+			}
+			add(schemaWord, content[i:end], i, end, line)
+			i = end
+		default:
+			add(schemaPunct, content[i:i+1], i, i+1, line)
+			i++
+		}
+	}
+	return tokens
+}
+
+// quotedEnd returns the offset of the quote closing the one at start, or
+// len(content) when it is not closed. Without backslash escapes, a doubled
+// quote stands for the quote itself.
+func quotedEnd(content string, start int, closing byte, backslashEscapes bool) int {
+	for end := start + 1; end < len(content); end++ {
+		switch {
+		case content[end] == '\\' && backslashEscapes:
+			end++
+		case content[end] == closing && !backslashEscapes && end+1 < len(content) && content[end+1] == closing:
+			end++
+		case content[end] == closing:
+			return end
+		}
+	}
+	return len(content)
+}
+
+// dollarQuoteEnd returns the offsets of the end of the body and of the
+// closing tag of the dollar-quoted string opened by tag at start
+func dollarQuoteEnd(content string, start int, tag string) (int, int) {
+	if close := strings.Index(content[start+len(tag):], tag); close >= 0 {
+		bodyEnd := start + len(tag) + close
+		return bodyEnd, bodyEnd + len(tag)
+	}
+	return len(content), len(content)
+}
+
+// isSchemaWordByte reports whether c belongs to a keyword, identifier,
+// number or SQL variable
+func isSchemaWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '_' || c == '@' || c == '#' || c == '$' || c >= 0x80
+}
+
+// collapseSpace joins the words of s with single spaces
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// sourceOf returns the text of the file spanned by tokens
+func sourceOf(content string, tokens []schemaToken) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	return content[tokens[0].start:tokens[len(tokens)-1].end]
+}
+
+// splitTopLevel splits tokens at the commas outside parentheses
+func splitTopLevel(tokens []schemaToken) [][]schemaToken {
+	var (
+		parts [][]schemaToken
+		depth int
+		start int
+	)
+	for i, token := range tokens {
+		switch {
+		case token.is("(") || token.is("<"):
+			depth++
+		case token.is(")") || token.is(">"):
+			depth--
+		case token.is(",") && depth == 0:
+			parts = append(parts, tokens[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		parts = append(parts, tokens[start:])
+	}
+	return parts
+}
+
+// closingParen returns the index of the parenthesis closing the one at
+// open, or len(tokens) when it is not closed
+func closingParen(tokens []schemaToken, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		if tokens[i].is("(") {
+			depth++
+		} else if tokens[i].is(")") {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// ProtobufParser extracts the messages, enums, services and RPC methods of
+// Protocol Buffers files
+type ProtobufParser struct {
+	BaseParser
+}
+
+// NewProtobufParser creates a new Protocol Buffers parser
+func NewProtobufParser() *ProtobufParser {
+	return &ProtobufParser{
+		BaseParser: BaseParser{language: "protobuf"},
+	}
+}
+
+// protobufMemberSkips are the statements of messages and enums that do not
+// declare fields or values
+var protobufMemberSkips = map[string]bool{"option": true, "reserved": true, "extensions": true}
+
+// Parse declares a "message", "enum", "service" or "rpc" type declaration
+// for each definition. Fields and enum values are the members of their
+// message or enum and RPC signatures those of their service. Nested
+// definitions have the dotted name of their enclosing message as parent,
+// RPC methods their service.
+func (p *ProtobufParser) Parse(content string, filePath string) (*types.CodeFile, error) {
+	file := &types.CodeFile{
+		Path:     filePath,
+		Language: "protobuf",
+		Lines:    p.countLines(content),
+		Content:  content,
+		Comments: p.extractComments(content, "//", "/*", "*/"),
+	}
+
+	type scope struct {
+		kind        string // "message", "enum", "service", "rpc", "oneof" or "" for other blocks
+		declaration int    // Index in file.TypeDeclarations of the definition, or of the message of a oneof; -1 for none
+		start       int    // Offset of the definition
+	}
+	var scopes []scope
+	owner := func() (scope, bool) {
+		if len(scopes) == 0 || scopes[len(scopes)-1].declaration < 0 {
+			return scope{}, false
+		}
+		return scopes[len(scopes)-1], true
+	}
+	declare := func(kind string, name schemaToken, start schemaToken) int {
+		declaration := types.TypeDeclaration{Name: name.text, Kind: kind, StartLine: start.line, EndLine: start.line}
+		if enclosing, ok := owner(); ok {
+			parent := file.TypeDeclarations[enclosing.declaration]
+			switch {
+			case kind == "rpc":
+				declaration.Parent = parent.Name
+			case parent.Kind == "message" && parent.Parent != "":
+				declaration.Parent = parent.Parent + "." + parent.Name
+			case parent.Kind == "message":
+				declaration.Parent = parent.Name
+			}
+		}
+		file.TypeDeclarations = append(file.TypeDeclarations, declaration)
+		return len(file.TypeDeclarations) - 1
+	}
+	// rpc declares the RPC method of a statement, listing its signature
+	// with the methods of the service
+	rpc := func(statement []schemaToken, end schemaToken) int {
+		if len(statement) < 2 {
+			return -1
+		}
+		signature := collapseSpace(sourceOf(content, statement))
+		service, inService := owner()
+		index := declare("rpc", statement[1], statement[0])
+		file.TypeDeclarations[index].Definition = signature
+		file.TypeDeclarations[index].EndLine = end.line
+		if inService {
+			file.TypeDeclarations[service.declaration].Members = append(file.TypeDeclarations[service.declaration].Members, signature)
+		}
+		return index
+	}
+
+	tokens := scanSchema(content, protobufSyntax, 0, 1)
+	statementStart := 0
+	for i, token := range tokens {
+		if token.kind != schemaPunct || (token.text != ";" && token.text != "{" && token.text != "}") {
+			continue
+		}
+		statement := tokens[statementStart:i]
+		statementStart = i + 1
+
+		switch token.text {
+		case "{":
+			block := scope{declaration: -1}
+			if len(statement) > 0 {
+				block.start = statement[0].start
+				keyword := statement[0].text
+				switch {
+				case (keyword == "message" || keyword == "enum" || keyword == "service") && len(statement) >= 2:
+					block.kind = keyword
+					block.declaration = declare(keyword, statement[1], statement[0])
+				case keyword == "rpc":
+					block.kind = keyword
+					block.declaration = rpc(statement, token)
+				case keyword == "oneof":
+					if enclosing, ok := owner(); ok && enclosing.kind == "message" {
+						block.kind = keyword
+						block.declaration = enclosing.declaration
+					}
+				}
+			}
+			scopes = append(scopes, block)
+		case "}":
+			if len(scopes) == 0 {
+				continue
+			}
+			block := scopes[len(scopes)-1]
+			scopes = scopes[:len(scopes)-1]
+			if block.declaration >= 0 && block.kind != "oneof" {
+				declaration := &file.TypeDeclarations[block.declaration]
+				declaration.EndLine = token.line
+				if block.kind != "rpc" {
+					declaration.Definition = content[block.start:token.end]
+				}
+			}
+		case ";":
+			if len(statement) == 0 {
+				continue
+			}
+			enclosing, ok := owner()
+			switch {
+			case len(scopes) == 0 && statement[0].text == "import":
+				if last := statement[len(statement)-1]; last.kind == schemaString {
+					file.Imports = append(file.Imports, types.Import{Module: last.text, StartLine: statement[0].line})
+				}
+			case !ok:
+			case enclosing.kind == "service" && statement[0].text == "rpc":
+				rpc(statement, token)
+			case (enclosing.kind == "message" || enclosing.kind == "oneof" || enclosing.kind == "enum") && !protobufMemberSkips[statement[0].text]:
+				declaration := &file.TypeDeclarations[enclosing.declaration]
+				declaration.Members = append(declaration.Members, collapseSpace(sourceOf(content, statement)))
+			}
+		}
+	}
+
+	// Definitions left open by a truncated file end with it
+	for _, block := range scopes {
+		if block.declaration >= 0 && block.kind != "oneof" && block.kind != "rpc" {
+			declaration := &file.TypeDeclarations[block.declaration]
+			declaration.EndLine = file.Lines
+			declaration.Definition = content[block.start:]
+		}
+	}
+
+	return file, nil
+}
+
+// SQLParser extracts the tables, views, indexes, procedures and functions
+// created by SQL files
+type SQLParser struct {
+	BaseParser
+}
+
+// NewSQLParser creates a new SQL parser
+func NewSQLParser() *SQLParser {
+	return &SQLParser{
+		BaseParser: BaseParser{language: "sql"},
+	}
+}
+
+var (
+	sqlDelimiterPattern = regexp.MustCompile(`(?i)^[ \t]*delimiter[ \t]+(\S+)`)
+	sqlGoPattern        = regexp.MustCompile(`(?i)^[ \t]*go[ \t]*(?:--.*)?\r?$`)
+)
+
+// sqlCreatedKinds maps the objects of CREATE statements to declaration kinds
+var sqlCreatedKinds = map[string]string{
+	"TABLE":     "table",
+	"VIEW":      "view",
+	"INDEX":     "index",
+	"PROCEDURE": "procedure",
+	"PROC":      "procedure",
+	"FUNCTION":  "function",
+}
+
+// sqlConstraintWords start the table elements that are not columns
+var sqlConstraintWords = map[string]bool{
+	"CONSTRAINT": true, "PRIMARY": true, "FOREIGN": true, "UNIQUE": true, "CHECK": true, "KEY": true,
+	"INDEX": true, "EXCLUDE": true, "FULLTEXT": true, "SPATIAL": true, "LIKE": true, "PERIOD": true,
+}
+
+// sqlRoutineBodyWords end the signature of procedures and functions
+var sqlRoutineBodyWords = map[string]bool{
+	"AS": true, "IS": true, "BEGIN": true, "LANGUAGE": true, "RETURN": true, "IMMUTABLE": true,
+	"STABLE": true, "VOLATILE": true, "DETERMINISTIC": true, "SECURITY": true, "COMMENT": true,
+	"READS": true, "MODIFIES": true, "CONTAINS": true, "NO": true, "NOT": true, "SQL": true,
+	"SET": true, "WITH": true, "STRICT": true, "PARALLEL": true, "COST": true, "CALLED": true,
+}
+
+// Parse declares a "table", "view", "index" or "procedure" type
+// declaration for each CREATE statement and a function for each CREATE
+// FUNCTION. Column definitions are the members of their table and the
+// table of an index is its parent. Statements end at semicolons outside
+// BEGIN ... END blocks, at delimiters set with DELIMITER and at GO lines.
+func (p *SQLParser) Parse(content string, filePath string) (*types.CodeFile, error) {
+	file := &types.CodeFile{
+		Path:     filePath,
+		Language: "sql",
+		Lines:    p.countLines(content),
+		Content:  content,
+		Comments: p.extractComments(content, "--", "/*", "*/"),
+	}
+
+	for _, statement := range splitSQLStatements(content) {
+		p.parseStatement(file, content, statement)
+	}
+	return file, nil
+}
+
+// sqlStatement is the span of a statement, without its delimiter
+type sqlStatement struct {
+	start, end int
+	line       int
+}
+
+// splitSQLStatements splits content into statements
+func splitSQLStatements(content string) []sqlStatement {
+	var (
+		statements []sqlStatement
+		delimiter  = ";"
+		depth      int // Open BEGIN and CASE blocks
+		start      int
+		line       = 1
+		startLine  = 1
+	)
+	finish := func(end, next int) {
+		if strings.TrimSpace(content[start:end]) != "" {
+			statements = append(statements, sqlStatement{start: start, end: end, line: startLine})
+		}
+		line += strings.Count(content[end:next], "\n")
+		start, startLine, depth = next, line, 0
+	}
+	lineEnd := func(i int) int {
+		if end := strings.IndexByte(content[i:], '\n'); end >= 0 {
+			return i + end
+		}
+		return len(content)
+	}
+
+	for i := 0; i < len(content); {
+		if i == 0 || content[i-1] == '\n' {
+			if match := sqlDelimiterPattern.FindStringSubmatch(content[i:lineEnd(i)]); match != nil {
+				finish(i, lineEnd(i))
+				delimiter = match[1]
+				i = start
+				continue
+			}
+			if sqlGoPattern.MatchString(content[i:lineEnd(i)]) {
+				finish(i, lineEnd(i))
+				i = start
+				continue
+			}
+		}
+		if strings.HasPrefix(content[i:], delimiter) && (delimiter != ";" || depth == 0) {
+			finish(i, i+len(delimiter))
+			i = start
+			continue
+		}
+
+		// Skip comments, strings and quoted identifiers whole
+		if skip := sqlSkip(content, i); skip > i {
+			line += strings.Count(content[i:skip], "\n")
+			i = skip
+			continue
+		}
+
+		if isSchemaWordByte(content[i]) && (i == 0 || !isSchemaWordByte(content[i-1])) {
+			end := i
+			for end < len(content) && isSchemaWordByte(content[end]) {
+				end++
+			}
+			if delimiter == ";" {
+				depth = sqlBlockDepth(content, i, end, depth)
+			}
+			i = end
+			continue
+		}
+		if content[i] == '\n' {
+			line++
+		}
+		i++
+	}
+	finish(len(content), len(content))
+	return statements
+}
+
+// sqlSkip returns the offset after the comment, string, quoted identifier
+// or dollar-quoted string at i, or i when there is none
+func sqlSkip(content string, i int) int {
+	switch c := content[i]; {
+	case strings.HasPrefix(content[i:], "--"):
+		if end := strings.IndexByte(content[i:], '\n'); end >= 0 {
+			return i + end
+		}
+		return len(content)
+	case strings.HasPrefix(content[i:], "/*"):
+		if end := strings.Index(content[i+2:], "*/"); end >= 0 {
+			return i + 2 + end + 2
+		}
+		return len(content)
+	case c == '\'' || c == '"' || c == '`':
+		return min(quotedEnd(content, i, c, false)+1, len(content))
+	case c == '[':
+		return min(quotedEnd(content, i, ']', false)+1, len(content))
+	case c == '$' && dollarQuotePattern.MatchString(content[i:]):
+		_, end := dollarQuoteEnd(content, i, dollarQuotePattern.FindString(content[i:]))
+		return end
+	}
+	return i
+}
+
+// sqlBlockDepth returns the depth of BEGIN ... END and CASE ... END blocks
+// after the word at content[start:end]
+func sqlBlockDepth(content string, start, end, depth int) int {
+	next := nextSQLWord(content, end)
+	switch strings.ToUpper(content[start:end]) {
+	case "BEGIN":
+		// BEGIN; and BEGIN TRANSACTION start transactions, not blocks
+		switch strings.ToUpper(next) {
+		case "TRANSACTION", "TRAN", "WORK", "DEFERRED", "IMMEDIATE", "EXCLUSIVE":
+			return depth
+		case "":
+			if rest := strings.TrimSpace(content[end:]); rest == "" || strings.HasPrefix(rest, ";") {
+				return depth
+			}
+		}
+		return depth + 1
+	case "CASE":
+		if strings.EqualFold(previousSQLWord(content, start), "END") {
+			return depth
+		}
+		return depth + 1
+	case "END":
+		switch strings.ToUpper(next) {
+		case "IF", "LOOP", "WHILE", "REPEAT", "FOR":
+			return depth
+		}
+		return max(depth-1, 0)
+	}
+	return depth
+}
+
+// nextSQLWord returns the word following offset, skipping spaces
+func nextSQLWord(content string, offset int) string {
+	start := offset
+	for start < len(content) && (content[start] == ' ' || content[start] == '\t' || content[start] == '\r' || content[start] == '\n') {
+		start++
+	}
+	end := start
+	for end < len(content) && isSchemaWordByte(content[end]) {
+		end++
+	}
+	return content[start:end]
+}
+
+// previousSQLWord returns the word before offset, skipping spaces
+func previousSQLWord(content string, offset int) string {
+	end := offset
+	for end > 0 && (content[end-1] == ' ' || content[end-1] == '\t' || content[end-1] == '\r' || content[end-1] == '\n') {
+		end--
+	}
+	start := end
+	for start > 0 && isSchemaWordByte(content[start-1]) {
+		start--
+	}
+	return content[start:end]
+}
+
+// parseStatement adds the declaration of a CREATE statement to file
+func (p *SQLParser) parseStatement(file *types.CodeFile, content string, statement sqlStatement) {
+	tokens := scanSchema(content[statement.start:statement.end], sqlSyntax, statement.start, statement.line)
+	if len(tokens) < 3 || !tokens[0].is("CREATE") {
+		return
+	}
+
+	// Find the kind of object after modifiers such as OR REPLACE, UNIQUE or
+	// DEFINER = user
+	kind, pos := "", 1
+	for ; pos < len(tokens) && !tokens[pos].is("(") && !tokens[pos].is("AS"); pos++ {
+		if tokens[pos].kind == schemaWord {
+			if created, ok := sqlCreatedKinds[strings.ToUpper(tokens[pos].text)]; ok {
+				kind = created
+				pos++
+				break
+			}
+		}
+	}
+	if kind == "" {
+		return
+	}
+	accept := func(words ...string) bool {
+		for offset, word := range words {
+			if pos+offset >= len(tokens) || !tokens[pos+offset].is(word) {
+				return false
+			}
+		}
+		pos += len(words)
+		return true
+	}
+	accept("CONCURRENTLY")
+	accept("IF", "NOT", "EXISTS")
+
+	var name string
+	if !(kind == "index" && pos < len(tokens) && tokens[pos].is("ON")) {
+		name, pos = sqlName(tokens, pos)
+		if name == "" {
+			return
+		}
+	}
+
+	last := tokens[len(tokens)-1]
+	declaration := types.TypeDeclaration{
+		Name:       name,
+		Kind:       kind,
+		StartLine:  tokens[0].line,
+		EndLine:    last.line + strings.Count(last.text, "\n"),
+		Definition: sourceOf(content, tokens),
+	}
+
+	switch kind {
+	case "table":
+		if pos < len(tokens) && tokens[pos].is("(") {
+			for _, element := range splitTopLevel(tokens[pos+1 : closingParen(tokens, pos)]) {
+				if len(element) > 0 && !sqlConstraintWords[strings.ToUpper(element[0].text)] {
+					declaration.Members = append(declaration.Members, collapseSpace(sourceOf(content, element)))
+				}
+			}
+		}
+	case "index":
+		if accept("ON") {
+			accept("ONLY")
+			declaration.Parent, pos = sqlName(tokens, pos)
+			if pos < len(tokens) && tokens[pos].is("USING") {
+				pos += 2
+			}
+			if pos < len(tokens) && tokens[pos].is("(") {
+				for _, column := range splitTopLevel(tokens[pos+1 : closingParen(tokens, pos)]) {
+					declaration.Members = append(declaration.Members, collapseSpace(sourceOf(content, column)))
+				}
+			}
+		}
+		if declaration.Name == "" {
+			// PostgreSQL names unnamed indexes after their table
+			declaration.Name = declaration.Parent + "_idx"
+		}
+	case "procedure", "function":
+		parameters, returnType, signature := sqlRoutineSignature(content, tokens, pos)
+		if kind == "function" {
+			file.Functions = append(file.Functions, types.Function{
+				Name:       name,
+				StartLine:  declaration.StartLine,
+				EndLine:    declaration.EndLine,
+				Parameters: parameters,
+				ReturnType: returnType,
+				Signature:  signature,
+			})
+			return
+		}
+		declaration.Members = parameters
+		declaration.Definition = signature
+	}
+
+	file.TypeDeclarations = append(file.TypeDeclarations, declaration)
+}
+
+// sqlName reads the possibly qualified name at pos, as in schema.table,
+// and returns its last part with the position after it
+func sqlName(tokens []schemaToken, pos int) (string, int) {
+	name := ""
+	for pos < len(tokens) && tokens[pos].kind == schemaWord {
+		name = tokens[pos].text
+		pos++
+		if pos+1 < len(tokens) && tokens[pos].is(".") && tokens[pos+1].kind == schemaWord {
+			pos++
+			continue
+		}
+		break
+	}
+	return name, pos
+}
+
+// sqlRoutineSignature returns the parameters, return type and signature of
+// the procedure or function whose parameters start at pos
+func sqlRoutineSignature(content string, tokens []schemaToken, pos int) ([]string, string, string) {
+	var parameterTokens []schemaToken
+	end := pos
+	if pos < len(tokens) && tokens[pos].is("(") {
+		end = min(closingParen(tokens, pos), len(tokens)-1)
+		parameterTokens = tokens[pos+1 : end]
+		end++
+	} else {
+		// Parameters of SQL Server procedures are not parenthesized
+		for end < len(tokens) && !(tokens[end].kind == schemaWord && sqlRoutineBodyWords[strings.ToUpper(tokens[end].text)]) {
+			end++
+		}
+		parameterTokens = tokens[pos:end]
+	}
+
+	var parameters []string
+	for _, parameter := range splitTopLevel(parameterTokens) {
+		if len(parameter) > 0 {
+			parameters = append(parameters, collapseSpace(sourceOf(content, parameter)))
+		}
+	}
+
+	var returnType string
+	if end < len(tokens) && tokens[end].is("RETURNS") {
+		returnStart := end + 1
+		end = returnStart
+		for end < len(tokens) && tokens[end].kind != schemaString &&
+			!(tokens[end].kind == schemaWord && sqlRoutineBodyWords[strings.ToUpper(tokens[end].text)]) {
+			if tokens[end].is("(") {
+				end = closingParen(tokens, end)
+			}
+			end++
+		}
+		returnType = collapseSpace(sourceOf(content, tokens[returnStart:min(end, len(tokens))]))
+	}
+
+	return parameters, returnType, collapseSpace(sourceOf(content, tokens[:min(end, len(tokens))]))
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestProtobufParser(t *testing.T) {
+	content := strings.Join([]string{
+		`syntax = "proto3";`,
+		`import "google/protobuf/timestamp.proto";`,
+		"",
+		"// A user account",
+		"message User {",
+		"  string id = 1;",
+		"  map<string, string> labels = 2 [deprecated = true];",
+		"  oneof contact { string phone = 3; }",
+		"  message Address { string city = 1; }",
+		"  reserved 9;",
+		"}",
+		"",
+		"service UserService {",
+		`  option (acme.api) = { version: "1" };`,
+		"  rpc GetUser(GetUserRequest) returns (User);",
+		"  rpc WatchUsers (stream WatchRequest) returns (stream User) {",
+		`    option (google.api.http) = { get: "/v1/users" };`,
+		"  }",
+		"}",
+	}, "\n")
+
+	file, err := NewProtobufParser().Parse(content, "users.proto")
+	if err != nil {
+		t.Fatalf("Failed to parse Protocol Buffers: %v", err)
+	}
+
+	want := []types.TypeDeclaration{
+		{Name: "User", Kind: "message", StartLine: 5, EndLine: 11,
+			Members: []string{"string id = 1", "map<string, string> labels = 2 [deprecated = true]", "string phone = 3"}},
+		{Name: "Address", Kind: "message", StartLine: 9, EndLine: 9, Members: []string{"string city = 1"}, Parent: "User",
+			Definition: "message Address { string city = 1; }"},
+		{Name: "UserService", Kind: "service", StartLine: 13, EndLine: 19,
+			Members: []string{"rpc GetUser(GetUserRequest) returns (User)", "rpc WatchUsers (stream WatchRequest) returns (stream User)"}},
+		{Name: "GetUser", Kind: "rpc", StartLine: 15, EndLine: 15, Parent: "UserService",
+			Definition: "rpc GetUser(GetUserRequest) returns (User)"},
+		{Name: "WatchUsers", Kind: "rpc", StartLine: 16, EndLine: 18, Parent: "UserService",
+			Definition: "rpc WatchUsers (stream WatchRequest) returns (stream User)"},
+	}
+	if len(file.TypeDeclarations) != len(want) {
+		t.Fatalf("Expected %d declarations, got %+v", len(want), file.TypeDeclarations)
+	}
+	for i, expected := range want {
+		got := file.TypeDeclarations[i]
+		if expected.Definition == "" {
+			// Whole definitions are only compared where they are short
+			expected.Definition = got.Definition
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Declaration %d:\nexpected %+v\ngot      %+v", i, expected, got)
+		}
+	}
+	if len(file.Imports) != 1 || file.Imports[0].Module != "google/protobuf/timestamp.proto" {
+		t.Errorf("Expected the timestamp import, got %+v", file.Imports)
+	}
+}
+
+func TestSQLParser(t *testing.T) {
+	content := strings.Join([]string{
+		"CREATE TABLE IF NOT EXISTS public.users (",
+		"  id BIGSERIAL PRIMARY KEY,",
+		`  "created at" TIMESTAMPTZ DEFAULT now(),`,
+		"  CONSTRAINT id_positive CHECK (id > 0)",
+		");",
+		"CREATE UNIQUE INDEX users_email_idx ON users USING btree (lower(email), id);",
+		"CREATE OR REPLACE VIEW active_users AS SELECT * FROM users WHERE active;",
+		"CREATE FUNCTION add(a integer, b integer) RETURNS integer AS $$",
+		"  SELECT a + b;",
+		"$$ LANGUAGE sql;",
+		"DELIMITER //",
+		"CREATE PROCEDURE archive_users(IN cutoff DATE)",
+		"BEGIN",
+		"  DELETE FROM users WHERE created < cutoff;",
+		"  IF found THEN SELECT 1; END IF;",
+		"END //",
+		"DELIMITER ;",
+		"CREATE PROCEDURE dbo.GetUser @id INT, @name NVARCHAR(50) AS",
+		"BEGIN",
+		"  SELECT CASE WHEN @id > 0 THEN 1 ELSE 0 END;",
+		"END",
+		"GO",
+		"CREATE TABLE [dbo].[Orders] ([id] INT, [note] NVARCHAR(MAX));",
+	}, "\n")
+
+	file, err := NewSQLParser().Parse(content, "schema.sql")
+	if err != nil {
+		t.Fatalf("Failed to parse SQL: %v", err)
+	}
+
+	type declaration struct {
+		name, kind string
+		start, end int
+		members    []string
+		parent     string
+	}
+	want := []declaration{
+		{"users", "table", 1, 5, []string{"id BIGSERIAL PRIMARY KEY", `"created at" TIMESTAMPTZ DEFAULT now()`}, ""},
+		{"users_email_idx", "index", 6, 6, []string{"lower(email)", "id"}, "users"},
+		{"active_users", "view", 7, 7, nil, ""},
+		{"archive_users", "procedure", 12, 16, []string{"IN cutoff DATE"}, ""},
+		{"GetUser", "procedure", 18, 21, []string{"@id INT", "@name NVARCHAR(50)"}, ""},
+		{"Orders", "table", 23, 23, []string{"[id] INT", "[note] NVARCHAR(MAX)"}, ""},
+	}
+	if len(file.TypeDeclarations) != len(want) {
+		t.Fatalf("Expected %d declarations, got %+v", len(want), file.TypeDeclarations)
+	}
+	for i, expected := range want {
+		got := file.TypeDeclarations[i]
+		if got.Name != expected.name || got.Kind != expected.kind || got.StartLine != expected.start || got.EndLine != expected.end ||
+			!reflect.DeepEqual(got.Members, expected.members) || got.Parent != expected.parent {
+			t.Errorf("Declaration %d: expected %+v, got %+v", i, expected, got)
+		}
+	}
+	if got := file.TypeDeclarations[3].Definition; got != "CREATE PROCEDURE archive_users(IN cutoff DATE)" {
+		t.Errorf("Expected the procedure's signature as its definition, got %q", got)
+	}
+
+	wantFunction := types.Function{
+		Name: "add", StartLine: 8, EndLine: 10, Parameters: []string{"a integer", "b integer"}, ReturnType: "integer",
+		Signature: "CREATE FUNCTION add(a integer, b integer) RETURNS integer",
+	}
+	if len(file.Functions) != 1 || !reflect.DeepEqual(file.Functions[0], wantFunction) {
+		t.Errorf("Expected %+v, got %+v", wantFunction, file.Functions)
+	}
+}
@@ -0,0 +1,416 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// ImplementationStructured is reported for the parsers of documentation,
+// configuration and schema files, which read the structure of formats that
+// have no tree-sitter grammar here
+const ImplementationStructured = "structured"
+
+// maxConfigKeys bounds the keys extracted from one file, so generated files
+// such as lock files do not flood the index
+const maxConfigKeys = 5000
+
+// maxConfigValueLength bounds the length of the values kept with keys
+const maxConfigValueLength = 500
+
+var (
+	markdownHeadingPattern = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	markdownFencePattern   = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^ \t`]*)")
+	markdownSetextPattern  = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+)
+
+// MarkdownParser extracts the headings and fenced code blocks of Markdown
+// documents as sections
+type MarkdownParser struct {
+	BaseParser
+}
+
+// NewMarkdownParser creates a new Markdown parser
+func NewMarkdownParser() *MarkdownParser {
+	return &MarkdownParser{
+		BaseParser: BaseParser{language: "markdown"},
+	}
+}
+
+// Parse splits a Markdown document into a section per heading, holding the
+// text up to the next heading, and a section per fenced code block. Text
+// before the first heading is a section without a title. YAML front matter
+// is skipped.
+func (p *MarkdownParser) Parse(content string, filePath string) (*types.CodeFile, error) {
+	file := &types.CodeFile{
+		Path:     filePath,
+		Language: "markdown",
+		Lines:    p.countLines(content),
+		Content:  content,
+	}
+
+	lines := strings.Split(content, "\n")
+	var (
+		sections []types.Section
+		trail    []types.Section // Enclosing headings, outermost first
+		current  = types.Section{Kind: "heading", StartLine: 1}
+		fence    string // Opening fence of the code block being read
+		block    types.Section
+	)
+
+	closeSection := func(endLine int) {
+		current.EndLine = endLine
+		current.Content = strings.TrimSpace(strings.Join(lines[current.StartLine-1:max(endLine, current.StartLine-1)], "\n"))
+		if current.Content != "" {
+			sections = append(sections, current)
+		}
+	}
+	titles := func() []string {
+		var parents []string
+		for _, heading := range trail {
+			parents = append(parents, heading.Title)
+		}
+		return parents
+	}
+
+	start := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for i := 1; i < len(lines); i++ {
+			if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+				start = i + 1
+				current.StartLine = i + 2
+				break
+			}
+		}
+	}
+
+	for i := start; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		lineNumber := i + 1
+
+		if fence != "" {
+			if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == "" {
+				block.EndLine = lineNumber
+				block.Content = strings.Join(lines[block.StartLine:i], "\n")
+				sections = append(sections, block)
+				fence = ""
+			}
+			continue
+		}
+		if match := markdownFencePattern.FindStringSubmatch(line); match != nil {
+			fence = match[1]
+			block = types.Section{
+				Kind:      "code_block",
+				Title:     current.Title,
+				Parents:   titles(),
+				Language:  strings.ToLower(match[2]),
+				StartLine: lineNumber,
+			}
+			if current.Title != "" {
+				block.Parents = append(block.Parents, current.Title)
+			}
+			continue
+		}
+
+		level, title := 0, ""
+		if match := markdownHeadingPattern.FindStringSubmatch(line); match != nil {
+			level, title = len(match[1]), strings.TrimSpace(match[2])
+		} else if match := markdownSetextPattern.FindStringSubmatch(line); match != nil && i > start && lineNumber-1 > current.StartLine {
+			// An underlined heading: the paragraph line above becomes the title
+			previous := strings.TrimSpace(lines[i-1])
+			if previous != "" && !markdownHeadingPattern.MatchString(lines[i-1]) && !markdownFencePattern.MatchString(lines[i-1]) {
+				level, title = 1, previous
+				if match[1][0] == '-' {
+					level = 2
+				}
+				lineNumber--
+			}
+		}
+		if level == 0 {
+			continue
+		}
+
+		closeSection(lineNumber - 1)
+		if current.Level > 0 {
+			trail = append(trail, current)
+		}
+		for len(trail) > 0 && trail[len(trail)-1].Level >= level {
+			trail = trail[:len(trail)-1]
+		}
+		current = types.Section{Kind: "heading", Title: title, Level: level, Parents: titles(), StartLine: lineNumber}
+	}
+	closeSection(len(lines))
+
+	// An unterminated code block runs to the end of the document
+	if fence != "" {
+		block.EndLine = len(lines)
+		block.Content = strings.Join(lines[block.StartLine:], "\n")
+		sections = append(sections, block)
+	}
+
+	sort.SliceStable(sections, func(a, b int) bool {
+		return sections[a].StartLine < sections[b].StartLine
+	})
+	file.Sections = sections
+	return file, nil
+}
+
+// ConfigParser extracts the keys and values of YAML or JSON files
+type ConfigParser struct {
+	BaseParser
+}
+
+// NewConfigParser creates a parser for "yaml" or "json" files
+func NewConfigParser(language string) *ConfigParser {
+	return &ConfigParser{
+		BaseParser: BaseParser{language: language},
+	}
+}
+
+// Parse extracts every key of the file with its path from the root. Files
+// that are not valid YAML or JSON keep the keys read before the error, so a
+// JSON file with comments is still partly indexed.
+func (p *ConfigParser) Parse(content string, filePath string) (*types.CodeFile, error) {
+	file := &types.CodeFile{
+		Path:     filePath,
+		Language: p.language,
+		Lines:    p.countLines(content),
+		Content:  content,
+	}
+
+	collector := &configKeys{}
+	if p.language == "json" {
+		collector.readJSON(content)
+	} else {
+		collector.readYAML(content)
+		file.Comments = p.extractComments(content, "#", "", "")
+	}
+	file.ConfigKeys = collector.keys
+	return file, nil
+}
+
+// configKeys collects the keys of a configuration file up to maxConfigKeys
+type configKeys struct {
+	keys     []types.ConfigKey
+	document int
+}
+
+// add records a key unless the limit is reached, and reports whether more
+// keys are wanted
+func (c *configKeys) add(key types.ConfigKey) bool {
+	if len(c.keys) >= maxConfigKeys {
+		return false
+	}
+	key.Document = c.document
+	key.Value = textpos.Truncate(key.Value, maxConfigValueLength)
+	c.keys = append(c.keys, key)
+	return true
+}
+
+// childPath joins a key to the path of its parent
+func childPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+// readYAML collects the keys of every document of a YAML stream
+func (c *configKeys) readYAML(content string) {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for c.document = 0; ; c.document++ {
+		var document yaml.Node
+		if err := decoder.Decode(&document); err != nil {
+			return
+		}
+		if len(document.Content) > 0 && !c.yamlNode(document.Content[0], "", "", 0) {
+			return
+		}
+	}
+}
+
+// yamlNode records a YAML value under a key and the keys below it. Keys
+// are recorded at the line of the key, and list items at their own line.
+func (c *configKeys) yamlNode(node *yaml.Node, path, key string, line int) bool {
+	if path != "" {
+		entry := types.ConfigKey{Path: path, Key: key, ValueType: yamlValueType(node), StartLine: line, EndLine: yamlEndLine(node)}
+		switch node.Kind {
+		case yaml.ScalarNode:
+			entry.Value = node.Value
+		case yaml.AliasNode:
+			entry.Value = "*" + node.Value
+		}
+		if !c.add(entry) {
+			return false
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valueNode := node.Content[i], node.Content[i+1]
+			if !c.yamlNode(valueNode, childPath(path, keyNode.Value), keyNode.Value, keyNode.Line) {
+				return false
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			itemKey := "[" + strconv.Itoa(i) + "]"
+			if !c.yamlNode(item, path+itemKey, itemKey, item.Line) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// yamlValueType names the type of a YAML value
+func yamlValueType(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.AliasNode:
+		return "alias"
+	}
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return "null"
+	}
+	return "string"
+}
+
+// yamlEndLine returns the last line of a YAML value
+func yamlEndLine(node *yaml.Node) int {
+	end := node.Line + strings.Count(strings.TrimRight(node.Value, "\n"), "\n")
+	if node.Kind == yaml.ScalarNode && (node.Style == yaml.LiteralStyle || node.Style == yaml.FoldedStyle) {
+		end++ // The value starts on the line after the indicator
+	}
+	for _, child := range node.Content {
+		end = max(end, yamlEndLine(child))
+	}
+	return end
+}
+
+// jsonReader walks the tokens of a JSON document, tracking the line each
+// starts on
+type jsonReader struct {
+	decoder    *json.Decoder
+	content    string
+	lineStarts []int // Offsets of the first byte of each line
+}
+
+// readJSON collects the keys of a JSON document
+func (c *configKeys) readJSON(content string) {
+	reader := &jsonReader{decoder: json.NewDecoder(strings.NewReader(content)), content: content, lineStarts: []int{0}}
+	reader.decoder.UseNumber()
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			reader.lineStarts = append(reader.lineStarts, i+1)
+		}
+	}
+	c.jsonValue(reader, "", "")
+}
+
+// token returns the next token and the line it starts on
+func (r *jsonReader) token() (json.Token, int, error) {
+	offset := int(r.decoder.InputOffset())
+	for offset < len(r.content) && strings.IndexByte(" \t\r\n,:", r.content[offset]) >= 0 {
+		offset++
+	}
+	token, err := r.decoder.Token()
+	line := sort.Search(len(r.lineStarts), func(i int) bool { return r.lineStarts[i] > offset })
+	return token, line, err
+}
+
+// errKeyLimit stops the walk of a JSON document once maxConfigKeys keys
+// were collected
+var errKeyLimit = errors.New("key limit reached")
+
+// jsonValue records the value read next under a key and the keys below it.
+// It returns an error when the document ends, is malformed or the key limit
+// is reached.
+func (c *configKeys) jsonValue(r *jsonReader, path, key string) error {
+	token, line, err := r.token()
+	if err != nil {
+		return err
+	}
+	return c.jsonToken(r, token, line, path, key)
+}
+
+// jsonToken records a value whose first token was already read
+func (c *configKeys) jsonToken(r *jsonReader, token json.Token, line int, path, key string) error {
+	entry := types.ConfigKey{Path: path, Key: key, StartLine: line, EndLine: line}
+	index := -1
+	if path != "" {
+		switch value := token.(type) {
+		case json.Delim:
+			entry.ValueType = "mapping"
+			if value == '[' {
+				entry.ValueType = "list"
+			}
+		case string:
+			entry.ValueType, entry.Value = "string", value
+		case json.Number:
+			entry.ValueType, entry.Value = "number", value.String()
+		case bool:
+			entry.ValueType, entry.Value = "boolean", strconv.FormatBool(value)
+		case nil:
+			entry.ValueType, entry.Value = "null", "null"
+		}
+		if !c.add(entry) {
+			return errKeyLimit
+		}
+		index = len(c.keys) - 1
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+	for item := 0; r.decoder.More(); item++ {
+		if delim == '{' {
+			keyToken, keyLine, err := r.token()
+			if err != nil {
+				return err
+			}
+			name, _ := keyToken.(string)
+			valueToken, _, err := r.token()
+			if err != nil {
+				return err
+			}
+			if err := c.jsonToken(r, valueToken, keyLine, childPath(path, name), name); err != nil {
+				return err
+			}
+			continue
+		}
+		itemKey := "[" + strconv.Itoa(item) + "]"
+		if err := c.jsonValue(r, path+itemKey, itemKey); err != nil {
+			return err
+		}
+	}
+
+	// The closing delimiter ends the value
+	_, end, err := r.token()
+	if index >= 0 {
+		c.keys[index].EndLine = end
+	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestMarkdownParser(t *testing.T) {
+	content := strings.Join([]string{
+		"---",
+		"title: Guide",
+		"---",
+		"Intro text.",
+		"",
+		"# Setup",
+		"Install it.",
+		"",
+		"## Retries",
+		"The retry limit is set in config.yaml.",
+		"```yaml",
+		"# heading-like comment",
+		"retry:",
+		"  limit: 5",
+		"```",
+		"",
+		"Usage",
+		"=====",
+		"Run it.",
+	}, "\n")
+
+	file, err := NewMarkdownParser().Parse(content, "README.md")
+	if err != nil {
+		t.Fatalf("Failed to parse Markdown: %v", err)
+	}
+
+	type section struct {
+		kind, title string
+		level       int
+		parents     []string
+		start, end  int
+	}
+	want := []section{
+		{"heading", "", 0, nil, 4, 5},
+		{"heading", "Setup", 1, nil, 6, 8},
+		{"heading", "Retries", 2, []string{"Setup"}, 9, 16},
+		{"code_block", "Retries", 0, []string{"Setup", "Retries"}, 11, 15},
+		{"heading", "Usage", 1, nil, 17, 19},
+	}
+	if len(file.Sections) != len(want) {
+		t.Fatalf("Expected %d sections, got %+v", len(want), file.Sections)
+	}
+	for i, expected := range want {
+		got := file.Sections[i]
+		if got.Kind != expected.kind || got.Title != expected.title || got.Level != expected.level ||
+			!reflect.DeepEqual(got.Parents, expected.parents) || got.StartLine != expected.start || got.EndLine != expected.end {
+			t.Errorf("Section %d: expected %+v, got %+v", i, expected, got)
+		}
+	}
+	if block := file.Sections[3]; block.Language != "yaml" || block.Content != "# heading-like comment\nretry:\n  limit: 5" {
+		t.Errorf("Expected the code block with its language, got %+v", block)
+	}
+}
+
+func TestConfigParser(t *testing.T) {
+	tests := []struct {
+		language string
+		content  string
+		want     []types.ConfigKey
+	}{
+		{
+			language: "yaml",
+			content:  "# service settings\nretry:\n  limit: 5\n  enabled: true\nhosts:\n  - a.example.com\n---\nname: second\n",
+			want: []types.ConfigKey{
+				{Path: "retry", Key: "retry", ValueType: "mapping", StartLine: 2, EndLine: 4},
+				{Path: "retry.limit", Key: "limit", Value: "5", ValueType: "number", StartLine: 3, EndLine: 3},
+				{Path: "retry.enabled", Key: "enabled", Value: "true", ValueType: "boolean", StartLine: 4, EndLine: 4},
+				{Path: "hosts", Key: "hosts", ValueType: "list", StartLine: 5, EndLine: 6},
+				{Path: "hosts[0]", Key: "[0]", Value: "a.example.com", ValueType: "string", StartLine: 6, EndLine: 6},
+				{Path: "name", Key: "name", Value: "second", ValueType: "string", Document: 1, StartLine: 8, EndLine: 8},
+			},
+		},
+		{
+			language: "json",
+			content:  "{\n  \"name\": \"api\",\n  \"retry\": {\n    \"limit\": 5,\n    \"backoff\": null\n  },\n  \"tags\": [\"a\", {\"id\": 1}]\n}\n",
+			want: []types.ConfigKey{
+				{Path: "name", Key: "name", Value: "api", ValueType: "string", StartLine: 2, EndLine: 2},
+				{Path: "retry", Key: "retry", ValueType: "mapping", StartLine: 3, EndLine: 6},
+				{Path: "retry.limit", Key: "limit", Value: "5", ValueType: "number", StartLine: 4, EndLine: 4},
+				{Path: "retry.backoff", Key: "backoff", Value: "null", ValueType: "null", StartLine: 5, EndLine: 5},
+				{Path: "tags", Key: "tags", ValueType: "list", StartLine: 7, EndLine: 7},
+				{Path: "tags[0]", Key: "[0]", Value: "a", ValueType: "string", StartLine: 7, EndLine: 7},
+				{Path: "tags[1]", Key: "[1]", ValueType: "mapping", StartLine: 7, EndLine: 7},
+				{Path: "tags[1].id", Key: "id", Value: "1", ValueType: "number", StartLine: 7, EndLine: 7},
+			},
+		},
+		{
+			// Keys before a syntax error are kept
+			language: "json",
+			content:  "{\n  \"compilerOptions\": {\n    \"strict\": true,\n    // comment\n  }\n}\n",
+			want: []types.ConfigKey{
+				{Path: "compilerOptions", Key: "compilerOptions", ValueType: "mapping", StartLine: 2, EndLine: 2},
+				{Path: "compilerOptions.strict", Key: "strict", Value: "true", ValueType: "boolean", StartLine: 3, EndLine: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		file, err := NewConfigParser(tt.language).Parse(tt.content, "config."+tt.language)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", tt.language, err)
+		}
+		if !reflect.DeepEqual(file.ConfigKeys, tt.want) {
+			t.Errorf("%s keys:\nexpected %+v\ngot      %+v", tt.language, tt.want, file.ConfigKeys)
+		}
+	}
+}
@@ -0,0 +1,552 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Symbol ranges locate declarations by byte offset in the tree-sitter AST,
+// so the symbol editing tools can rewrite a function or type without the
+// caller counting lines.
+
+// ErrSymbolNotFound is returned when no declaration matches a symbol name
+var ErrSymbolNotFound = errors.New("symbol not found")
+
+// AmbiguousSymbolError reports a symbol name that matches several
+// declarations
+type AmbiguousSymbolError struct {
+	Name       string
+	Candidates []SymbolRange
+}
+
+// Error implements the error interface
+func (e *AmbiguousSymbolError) Error() string {
+	locations := make([]string, 0, len(e.Candidates))
+	for _, candidate := range e.Candidates {
+		locations = append(locations, fmt.Sprintf("%s %s at line %d", candidate.Kind, candidate.QualifiedName(), candidate.StartLine))
+	}
+	return fmt.Sprintf("%q matches %d declarations (%s); qualify the name or give a line", e.Name, len(e.Candidates), strings.Join(locations, ", "))
+}
+
+// SymbolRange is the location of a declaration. Offsets are byte offsets
+// into the parsed content; End and BodyEnd are exclusive.
+type SymbolRange struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Container string `json:"container,omitempty"`
+	NameStart int    `json:"name_start"`
+	NameEnd   int    `json:"name_end"`
+	Start     int    `json:"start"`      // Includes attached comments, decorators and attributes
+	DeclStart int    `json:"decl_start"` // Start of the declaration itself
+	End       int    `json:"end"`
+	BodyStart int    `json:"body_start"` // -1 if the declaration has no body
+	BodyEnd   int    `json:"body_end"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// QualifiedName returns the name prefixed with its container, such as
+// Store.Load for a method
+func (s SymbolRange) QualifiedName() string {
+	if s.Container == "" {
+		return s.Name
+	}
+	return s.Container + "." + s.Name
+}
+
+// symbolKinds maps the declaration node types of the supported grammars to
+// symbol kinds
+var symbolKinds = map[string]string{
+	"function_declaration":           "function",
+	"generator_function_declaration": "function",
+	"function_definition":            "function",
+	"function_item":                  "function",
+	"method_declaration":             "method",
+	"method_definition":              "method",
+	"method":                         "method",
+	"singleton_method":               "method",
+	"constructor_declaration":        "constructor",
+	"class_declaration":              "class",
+	"abstract_class_declaration":     "class",
+	"class_definition":               "class",
+	"class_specifier":                "class",
+	"class":                          "class",
+	"object_declaration":             "object",
+	"interface_declaration":          "interface",
+	"trait_item":                     "trait",
+	"struct_specifier":               "struct",
+	"struct_item":                    "struct",
+	"struct_declaration":             "struct",
+	"record_declaration":             "record",
+	"enum_declaration":               "enum",
+	"enum_item":                      "enum",
+	"impl_item":                      "impl",
+	"module":                         "module",
+	"mod_item":                       "module",
+	"namespace_definition":           "namespace",
+	"type_spec":                      "type",
+	"type_alias_declaration":         "type",
+	"variable_declarator":            "function",
+}
+
+// Symbols returns the range of every named declaration in content, outer
+// declarations before the ones nested in them
+func (p *TreeSitterParser) Symbols(content string) ([]SymbolRange, error) {
+	tree, err := p.ParseTree(content)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	var symbols []SymbolRange
+	p.collectSymbols(tree.RootNode(), []byte(content), "", &symbols)
+	return symbols, nil
+}
+
+// ParseTree returns the tree-sitter syntax tree of content, for callers that
+// work on the AST directly. The caller must close the tree.
+func (p *TreeSitterParser) ParseTree(content string) (*sitter.Tree, error) {
+	parser := sitter.NewParser()
+	parser.SetLanguage(p.tsLanguage)
+
+	tree, err := parser.ParseCtx(context.Background(), nil, []byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse with tree-sitter: %w", err)
+	}
+	return tree, nil
+}
+
+// collectSymbols walks node, adding a range for each declaration it finds
+// with the name of the declaration it is nested in as container
+func (p *TreeSitterParser) collectSymbols(node *sitter.Node, source []byte, container string, symbols *[]SymbolRange) {
+	if symbol, ok := p.symbolAt(node, source, container); ok {
+		*symbols = append(*symbols, symbol)
+		container = symbol.Name
+		if symbol.Kind != "impl" {
+			container = symbol.QualifiedName()
+		}
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		p.collectSymbols(node.NamedChild(i), source, container, symbols)
+	}
+}
+
+// symbolAt returns the range of the declaration node is, if it is a named one
+func (p *TreeSitterParser) symbolAt(node *sitter.Node, source []byte, container string) (SymbolRange, bool) {
+	kind, ok := symbolKinds[node.Type()]
+	if !ok {
+		return SymbolRange{}, false
+	}
+
+	name := p.getFieldText(node, "name", source)
+	body := node.ChildByFieldName("body")
+	switch node.Type() {
+	case "function_definition", "class_specifier", "struct_specifier":
+		if name == "" {
+			var scope string
+			name, scope = p.cDeclaratorName(node.ChildByFieldName("declarator"), source)
+			if scope != "" {
+				container = scope
+			}
+		}
+	case "method_declaration":
+		// Go methods belong to their receiver type
+		if receiver := node.ChildByFieldName("receiver"); receiver != nil {
+			container = receiverTypeName(p.getNodeText(receiver, source))
+		}
+	case "impl_item":
+		name = p.getFieldText(node, "type", source)
+	case "type_spec":
+		body = goTypeBody(node.ChildByFieldName("type"))
+	case "variable_declarator":
+		// Only functions assigned to variables are symbols
+		value := node.ChildByFieldName("value")
+		if value == nil {
+			return SymbolRange{}, false
+		}
+		switch value.Type() {
+		case "arrow_function", "function", "function_expression", "generator_function":
+			body = value.ChildByFieldName("body")
+		default:
+			return SymbolRange{}, false
+		}
+	}
+	if name == "" && p.language == "kotlin" {
+		for _, nameType := range []string{"simple_identifier", "type_identifier"} {
+			if child := firstChildOfType(node, nameType); child != nil {
+				name = p.getNodeText(child, source)
+				break
+			}
+		}
+		for _, bodyType := range []string{"function_body", "class_body", "enum_class_body"} {
+			if child := firstChildOfType(node, bodyType); child != nil {
+				body = child
+				break
+			}
+		}
+	}
+	if name == "" {
+		return SymbolRange{}, false
+	}
+	if kind == "function" && container != "" && node.Type() != "variable_declarator" {
+		kind = "method"
+	}
+
+	outer := outermostDeclaration(node)
+	start := attachedStart(outer, source)
+	symbol := SymbolRange{
+		Name:      name,
+		Kind:      kind,
+		Container: container,
+		NameStart: -1,
+		NameEnd:   -1,
+		Start:     int(start.StartByte()),
+		DeclStart: int(outer.StartByte()),
+		End:       int(outer.EndByte()),
+		BodyStart: -1,
+		BodyEnd:   -1,
+		StartLine: int(start.StartPoint().Row) + 1,
+		EndLine:   int(outer.EndPoint().Row) + 1,
+	}
+	if body != nil {
+		symbol.BodyStart = int(body.StartByte())
+		symbol.BodyEnd = int(body.EndByte())
+	}
+	if nameNode := findNamed(node, name, body, source); nameNode != nil {
+		symbol.NameStart = int(nameNode.StartByte())
+		symbol.NameEnd = int(nameNode.EndByte())
+	}
+	// The interface body of a Go type starts at its brace
+	if node.Type() == "type_spec" && body != nil && body.Type() == "interface_type" {
+		if brace := firstChildOfType(body, "{"); brace != nil {
+			symbol.BodyStart = int(brace.StartByte())
+		}
+	}
+	return symbol, true
+}
+
+// findNamed returns the first node in the header of a declaration, before
+// its body, whose text is name
+func findNamed(node *sitter.Node, name string, body *sitter.Node, source []byte) *sitter.Node {
+	if body != nil && sameNode(node, body) {
+		return nil
+	}
+	if node.NamedChildCount() == 0 && node.Content(source) == name {
+		return node
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if found := findNamed(node.NamedChild(i), name, body, source); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// receiverTypeName returns the type of a Go method receiver, such as Store
+// for (s *Store[T])
+func receiverTypeName(receiver string) string {
+	receiver = strings.Trim(receiver, "()")
+	if fields := strings.Fields(receiver); len(fields) > 0 {
+		receiver = fields[len(fields)-1]
+	}
+	receiver = strings.TrimLeft(receiver, "*")
+	if idx := strings.Index(receiver, "["); idx >= 0 {
+		receiver = receiver[:idx]
+	}
+	return receiver
+}
+
+// goTypeBody returns the braced part of a Go type definition, or nil for
+// types without one
+func goTypeBody(typeNode *sitter.Node) *sitter.Node {
+	if typeNode == nil {
+		return nil
+	}
+	switch typeNode.Type() {
+	case "struct_type":
+		return firstChildOfType(typeNode, "field_declaration_list")
+	case "interface_type":
+		return typeNode
+	}
+	return nil
+}
+
+// outermostDeclaration widens a declaration node to the statement that
+// holds it alone: the type keyword of a Go type, the const of a JavaScript
+// arrow function, decorators and export keywords
+func outermostDeclaration(node *sitter.Node) *sitter.Node {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		switch parent.Type() {
+		case "type_declaration", "lexical_declaration", "variable_declaration":
+			if parent.NamedChildCount() != 1 {
+				return node
+			}
+		case "decorated_definition", "export_statement":
+		default:
+			return node
+		}
+		node = parent
+	}
+	return node
+}
+
+// attachedStart returns the first of the comments, attributes and
+// decorators directly above node that belong to it, or node itself
+func attachedStart(node *sitter.Node, source []byte) *sitter.Node {
+	start := node
+	for sibling := node.PrevSibling(); sibling != nil; sibling = sibling.PrevSibling() {
+		switch {
+		case strings.HasSuffix(sibling.Type(), "comment"), sibling.Type() == "attribute_item",
+			sibling.Type() == "decorator", sibling.Type() == "attribute_list":
+		default:
+			return start
+		}
+		// Trailing comments of the previous line and comments separated by
+		// a blank line stand on their own
+		if sibling.EndPoint().Row+1 < start.StartPoint().Row || !startsLine(source, int(sibling.StartByte())) {
+			return start
+		}
+		start = sibling
+	}
+	return start
+}
+
+// startsLine reports whether only indentation precedes offset on its line
+func startsLine(source []byte, offset int) bool {
+	for i := offset - 1; i >= 0 && source[i] != '\n'; i-- {
+		if source[i] != ' ' && source[i] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// LocateSymbol picks the declaration named name, which may be qualified
+// with its container as in Store.Load or Store::Load. When line is positive,
+// only declarations spanning that line are considered and the innermost one
+// wins.
+func LocateSymbol(symbols []SymbolRange, name string, line int) (SymbolRange, error) {
+	name = strings.ReplaceAll(name, "::", ".")
+
+	var matches []SymbolRange
+	for _, symbol := range symbols {
+		if symbol.Name != name && symbol.QualifiedName() != name {
+			continue
+		}
+		if line > 0 && (line < symbol.StartLine || line > symbol.EndLine) {
+			continue
+		}
+		matches = append(matches, symbol)
+	}
+
+	switch {
+	case len(matches) == 0:
+		if line > 0 {
+			return SymbolRange{}, fmt.Errorf("%w: %s at line %d", ErrSymbolNotFound, name, line)
+		}
+		return SymbolRange{}, fmt.Errorf("%w: %s", ErrSymbolNotFound, name)
+	case len(matches) == 1:
+		return matches[0], nil
+	case line > 0:
+		innermost := matches[0]
+		for _, match := range matches[1:] {
+			if match.End-match.Start < innermost.End-innermost.Start {
+				innermost = match
+			}
+		}
+		return innermost, nil
+	default:
+		return SymbolRange{}, &AmbiguousSymbolError{Name: name, Candidates: matches}
+	}
+}
+
+// ReplaceSymbolBody returns content with the body of symbol replaced. For
+// braced bodies the text goes between the braces, unless it brings its own;
+// other bodies, such as Python blocks, are replaced whole. The text is
+// re-indented to the body's indentation and uses the file's line endings.
+func ReplaceSymbolBody(content string, symbol SymbolRange, text string) (string, error) {
+	if symbol.BodyStart < 0 {
+		return "", fmt.Errorf("%s %s has no body to replace", symbol.Kind, symbol.QualifiedName())
+	}
+
+	ending := lineEnding(content)
+	declIndent := indentationAt(content, symbol.DeclStart)
+	body := content[symbol.BodyStart:symbol.BodyEnd]
+	lines := dedentLines(text)
+	trimmed := strings.TrimSpace(text)
+
+	var replacement string
+	start := symbol.BodyStart
+	switch {
+	case strings.HasPrefix(body, "{") && strings.HasSuffix(body, "}") &&
+		strings.HasPrefix(trimmed, "{") && strings.HasSuffix(trimmed, "}"):
+		// The first line continues the declaration line
+		replacement = lines[0]
+		if len(lines) > 1 {
+			replacement += ending + indentLines(lines[1:], declIndent, ending)
+		}
+	case strings.HasPrefix(body, "{") && strings.HasSuffix(body, "}"):
+		indent := innerIndentation(body)
+		if indent == "" {
+			indent = declIndent + indentUnit(content)
+		}
+		replacement = "{" + ending
+		if len(lines) > 0 {
+			replacement += indentLines(lines, indent, ending) + ending
+		}
+		replacement += declIndent + "}"
+	case len(lines) == 0:
+		return "", fmt.Errorf("%s %s needs a non-empty body", symbol.Kind, symbol.QualifiedName())
+	case startsLine([]byte(content), symbol.BodyStart):
+		// Re-indent from the start of the body's first line
+		indent := indentationAt(content, symbol.BodyStart)
+		start = symbol.BodyStart - len(indent)
+		replacement = indentLines(lines, indent, ending)
+	case len(lines) == 1:
+		// A single line body, such as Kotlin's = expr, stays on its line
+		replacement = lines[0]
+	default:
+		replacement = ending + indentLines(lines, declIndent+indentUnit(content), ending)
+	}
+
+	return content[:start] + replacement + content[symbol.BodyEnd:], nil
+}
+
+// InsertBeforeSymbol returns content with text inserted above symbol and its
+// attached comments, separated by a blank line and indented like the symbol
+func InsertBeforeSymbol(content string, symbol SymbolRange, text string) (string, error) {
+	lines := dedentLines(text)
+	if len(lines) == 0 {
+		return "", errors.New("nothing to insert")
+	}
+
+	ending := lineEnding(content)
+	indent := indentationAt(content, symbol.Start)
+	lineStart := symbol.Start - len(indent)
+	insertion := indentLines(lines, indent, ending) + ending + ending
+	return content[:lineStart] + insertion + content[lineStart:], nil
+}
+
+// InsertAfterSymbol returns content with text inserted below the last line
+// of symbol, separated by a blank line and indented like the symbol
+func InsertAfterSymbol(content string, symbol SymbolRange, text string) (string, error) {
+	lines := dedentLines(text)
+	if len(lines) == 0 {
+		return "", errors.New("nothing to insert")
+	}
+
+	ending := lineEnding(content)
+	indent := indentationAt(content, symbol.DeclStart)
+	lineEnd := len(content)
+	if idx := strings.IndexByte(content[symbol.End:], '\n'); idx >= 0 {
+		lineEnd = symbol.End + idx
+		if lineEnd > 0 && content[lineEnd-1] == '\r' {
+			lineEnd--
+		}
+	}
+	insertion := ending + ending + indentLines(lines, indent, ending)
+	return content[:lineEnd] + insertion + content[lineEnd:], nil
+}
+
+// lineEnding returns the line ending content uses, \r\n or \n
+func lineEnding(content string) string {
+	if idx := strings.IndexByte(content, '\n'); idx > 0 && content[idx-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// indentationAt returns the whitespace that starts the line holding offset,
+// up to offset
+func indentationAt(content string, offset int) string {
+	lineStart := strings.LastIndexByte(content[:offset], '\n') + 1
+	line := content[lineStart:offset]
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// innerIndentation returns the indentation of the first non-blank line
+// inside a braced body, or "" if the body has no such line
+func innerIndentation(body string) string {
+	lines := strings.Split(body, "\n")
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || trimmed == "}" {
+			continue
+		}
+		return line[:len(line)-len(trimmed)]
+	}
+	return ""
+}
+
+// indentUnit guesses one level of indentation: a tab if any line is
+// indented with one, otherwise the smallest space indentation in use
+func indentUnit(content string) string {
+	smallest := 0
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "\t") {
+			return "\t"
+		}
+		spaces := len(line) - len(strings.TrimLeft(line, " "))
+		if spaces > 0 && spaces < len(line) && strings.TrimSpace(line) != "" && (smallest == 0 || spaces < smallest) {
+			smallest = spaces
+		}
+	}
+	if smallest == 0 {
+		smallest = 4
+	}
+	return strings.Repeat(" ", smallest)
+}
+
+// dedentLines splits text into lines without their common indentation and
+// without leading and trailing blank lines
+func dedentLines(text string) []string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	common := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			common, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	for idx, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			lines[idx] = ""
+			continue
+		}
+		lines[idx] = strings.TrimRight(line[len(common):], " \t")
+	}
+	return lines
+}
+
+// indentLines joins lines with ending, prefixing the non-blank ones with
+// indent
+func indentLines(lines []string, indent, ending string) string {
+	indented := make([]string, len(lines))
+	for idx, line := range lines {
+		if line != "" {
+			indented[idx] = indent + line
+		}
+	}
+	return strings.Join(indented, ending)
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const symbolsGoSource = `package store
+
+// Store keeps values
+type Store struct {
+	values map[string]int
+}
+
+// Load returns a value
+func (s *Store) Load(key string) int {
+	return s.values[key]
+}
+
+func Load() {}
+`
+
+func locate(t *testing.T, language, content, name string, line int) SymbolRange {
+	t.Helper()
+	parser := NewTreeSitterParser(language)
+	if parser == nil {
+		t.Skipf("Tree-sitter %s parser not available", language)
+	}
+	symbols, err := parser.Symbols(content)
+	if err != nil {
+		t.Fatalf("Symbols failed: %v", err)
+	}
+	symbol, err := LocateSymbol(symbols, name, line)
+	if err != nil {
+		t.Fatalf("LocateSymbol(%q) failed: %v", name, err)
+	}
+	return symbol
+}
+
+func TestLocateSymbol(t *testing.T) {
+	method := locate(t, "go", symbolsGoSource, "Store.Load", 0)
+	if method.Kind != "method" || method.StartLine != 8 || method.EndLine != 11 {
+		t.Errorf("Unexpected method range: %+v", method)
+	}
+	if got := symbolsGoSource[method.Start:method.DeclStart]; got != "// Load returns a value\n" {
+		t.Errorf("Expected the doc comment to be attached, got %q", got)
+	}
+	if got := symbolsGoSource[method.BodyStart:method.BodyEnd]; got != "{\n\treturn s.values[key]\n}" {
+		t.Errorf("Unexpected body %q", got)
+	}
+
+	if function := locate(t, "go", symbolsGoSource, "Load", 13); function.Container != "" || function.StartLine != 13 {
+		t.Errorf("Expected the function at line 13, got %+v", function)
+	}
+
+	parser := NewTreeSitterParser("go")
+	symbols, err := parser.Symbols(symbolsGoSource)
+	if err != nil {
+		t.Fatalf("Symbols failed: %v", err)
+	}
+	var ambiguous *AmbiguousSymbolError
+	if _, err := LocateSymbol(symbols, "Load", 0); !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 {
+		t.Errorf("Expected two candidates for Load, got: %v", err)
+	}
+	if _, err := LocateSymbol(symbols, "Save", 0); !errors.Is(err, ErrSymbolNotFound) {
+		t.Errorf("Expected ErrSymbolNotFound, got: %v", err)
+	}
+}
+
+func TestReplaceSymbolBody(t *testing.T) {
+	method := locate(t, "go", symbolsGoSource, "Store.Load", 0)
+	edited, err := ReplaceSymbolBody(symbolsGoSource, method, "value, ok := s.values[key]\nif !ok {\n\treturn -1\n}\nreturn value\n")
+	if err != nil {
+		t.Fatalf("ReplaceSymbolBody failed: %v", err)
+	}
+	expected := "func (s *Store) Load(key string) int {\n\tvalue, ok := s.values[key]\n\tif !ok {\n\t\treturn -1\n\t}\n\treturn value\n}\n"
+	if got := edited[method.DeclStart : method.DeclStart+len(expected)]; got != expected {
+		t.Errorf("Unexpected method:\n%s", got)
+	}
+
+	pythonSource := "class Greeter:\n    def greet(self, name):\n        \"\"\"Say hello\"\"\"\n        return 'hi ' + name\n\n    def leave(self):\n        pass\n"
+	greet := locate(t, "python", pythonSource, "Greeter.greet", 0)
+	edited, err = ReplaceSymbolBody(pythonSource, greet, "    if not name:\n        return 'hi'\n    return 'hello ' + name")
+	if err != nil {
+		t.Fatalf("ReplaceSymbolBody failed: %v", err)
+	}
+	expected = "class Greeter:\n    def greet(self, name):\n        if not name:\n            return 'hi'\n        return 'hello ' + name\n\n    def leave(self):\n        pass\n"
+	if edited != expected {
+		t.Errorf("Unexpected Python edit:\n%s", edited)
+	}
+}
+
+func TestInsertAroundSymbol(t *testing.T) {
+	method := locate(t, "go", symbolsGoSource, "Store.Load", 0)
+
+	edited, err := InsertBeforeSymbol(symbolsGoSource, method, "const missing = -1\n")
+	if err != nil {
+		t.Fatalf("InsertBeforeSymbol failed: %v", err)
+	}
+	if expected := "}\n\nconst missing = -1\n\n// Load returns a value\n"; !strings.Contains(edited, expected) {
+		t.Errorf("Expected the insertion above the doc comment, got:\n%s", edited)
+	}
+
+	edited, err = InsertAfterSymbol(symbolsGoSource, method, "func (s *Store) Len() int {\n\treturn len(s.values)\n}")
+	if err != nil {
+		t.Fatalf("InsertAfterSymbol failed: %v", err)
+	}
+	if expected := "\treturn s.values[key]\n}\n\nfunc (s *Store) Len() int {\n\treturn len(s.values)\n}\n\nfunc Load() {}\n"; !strings.Contains(edited, expected) {
+		t.Errorf("Expected the insertion below the method, got:\n%s", edited)
+	}
+
+	// Nested declarations keep their indentation and the file's line endings
+	tsSource := "class Cart {\r\n  total() {\r\n    return 0;\r\n  }\r\n}\r\n"
+	total := locate(t, "typescript", tsSource, "Cart.total", 0)
+	edited, err = InsertAfterSymbol(tsSource, total, "count() {\n  return 0;\n}")
+	if err != nil {
+		t.Fatalf("InsertAfterSymbol failed: %v", err)
+	}
+	expected := "class Cart {\r\n  total() {\r\n    return 0;\r\n  }\r\n\r\n  count() {\r\n    return 0;\r\n  }\r\n}\r\n"
+	if edited != expected {
+		t.Errorf("Unexpected TypeScript edit: %q", edited)
+	}
+}
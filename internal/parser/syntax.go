@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+)
+
+// maxSyntaxErrorText bounds the unexpected text quoted in a syntax error
+const maxSyntaxErrorText = 40
+
+// SyntaxError is a span of a file tree-sitter could not parse, in 1-based
+// lines and character columns; the end is exclusive
+type SyntaxError struct {
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line"`
+	EndColumn int    `json:"end_column"`
+	Message   string `json:"message"`
+}
+
+// SyntaxErrors returns the syntax errors in content: the text the parser
+// had to skip and the tokens it had to assume were there. Nested errors are
+// reported once, at the outermost span.
+func (p *TreeSitterParser) SyntaxErrors(content string) ([]SyntaxError, error) {
+	tree, err := p.ParseTree(content)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	root := tree.RootNode()
+	if !root.HasError() {
+		return nil, nil
+	}
+	var errors []SyntaxError
+	collectSyntaxErrors(root, []byte(content), textpos.Split(content), &errors)
+	return errors, nil
+}
+
+// collectSyntaxErrors walks the subtrees of node that contain errors
+func collectSyntaxErrors(node *sitter.Node, source []byte, text *textpos.Text, errors *[]SyntaxError) {
+	switch {
+	case node.IsMissing():
+		*errors = append(*errors, syntaxError(node, text, fmt.Sprintf("missing %s", node.Type())))
+		return
+	case node.IsError():
+		message := "syntax error"
+		if unexpected := strings.TrimSpace(firstLine(node.Content(source))); unexpected != "" {
+			message = fmt.Sprintf("unexpected %q", textpos.Truncate(unexpected, maxSyntaxErrorText))
+		}
+		*errors = append(*errors, syntaxError(node, text, message))
+		return
+	}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if child := node.Child(i); child.HasError() {
+			collectSyntaxErrors(child, source, text, errors)
+		}
+	}
+}
+
+// syntaxError locates a node in lines and character columns
+func syntaxError(node *sitter.Node, text *textpos.Text, message string) SyntaxError {
+	column := func(point sitter.Point) int {
+		line, _ := text.Line(int(point.Row) + 1)
+		return textpos.Column(line, int(point.Column))
+	}
+	start, end := node.StartPoint(), node.EndPoint()
+	return SyntaxError{
+		Line:      int(start.Row) + 1,
+		Column:    column(start),
+		EndLine:   int(end.Row) + 1,
+		EndColumn: column(end),
+		Message:   message,
+	}
+}
+
+// firstLine returns s up to its first line break
+func firstLine(s string) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
@@ -0,0 +1,32 @@
+package parser
+
+import "testing"
+
+func TestSyntaxErrors(t *testing.T) {
+	parser := NewTreeSitterParser("go")
+	if parser == nil {
+		t.Skip("Tree-sitter go parser not available")
+	}
+
+	errors, err := parser.SyntaxErrors("package main\n\nfunc main() {\n\tx := \n}\n")
+	if err != nil {
+		t.Fatalf("SyntaxErrors failed: %v", err)
+	}
+	if len(errors) == 0 {
+		t.Fatal("Expected a syntax error for the incomplete assignment")
+	}
+	if errors[0].Line < 4 || errors[0].Line > 5 {
+		t.Errorf("Expected the error on line 4 or 5, got %+v", errors[0])
+	}
+	if errors[0].Message == "" {
+		t.Error("Expected the error to have a message")
+	}
+
+	errors, err = parser.SyntaxErrors("package main\n\nfunc main() {}\n")
+	if err != nil {
+		t.Fatalf("SyntaxErrors failed: %v", err)
+	}
+	if len(errors) != 0 {
+		t.Errorf("Expected no syntax errors, got %+v", errors)
+	}
+}
@@ -6,10 +6,17 @@ import (
 	"strings"
 
 	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/c"
+	"github.com/smacker/go-tree-sitter/cpp"
+	"github.com/smacker/go-tree-sitter/csharp"
 	"github.com/smacker/go-tree-sitter/golang"
 	"github.com/smacker/go-tree-sitter/java"
 	"github.com/smacker/go-tree-sitter/javascript"
+	"github.com/smacker/go-tree-sitter/kotlin"
 	"github.com/smacker/go-tree-sitter/python"
+	"github.com/smacker/go-tree-sitter/ruby"
+	"github.com/smacker/go-tree-sitter/rust"
+	"github.com/smacker/go-tree-sitter/typescript/typescript"
 
 	"github.com/my-mcp/code-indexer/pkg/types"
 )
@@ -29,10 +36,24 @@ func NewTreeSitterParser(lang string) *TreeSitterParser {
 		language = golang.GetLanguage()
 	case "python":
 		language = python.GetLanguage()
-	case "javascript", "typescript":
+	case "javascript":
 		language = javascript.GetLanguage()
+	case "typescript":
+		language = typescript.GetLanguage()
 	case "java":
 		language = java.GetLanguage()
+	case "rust":
+		language = rust.GetLanguage()
+	case "c":
+		language = c.GetLanguage()
+	case "cpp":
+		language = cpp.GetLanguage()
+	case "csharp":
+		language = csharp.GetLanguage()
+	case "kotlin":
+		language = kotlin.GetLanguage()
+	case "ruby":
+		language = ruby.GetLanguage()
 	default:
 		return nil // Unsupported language
 	}
@@ -73,11 +94,24 @@ func (p *TreeSitterParser) Parse(content string, filePath string) (*types.CodeFi
 		p.parseGoCode(tree.RootNode(), sourceCode, file)
 	case "python":
 		p.parsePythonCode(tree.RootNode(), sourceCode, file)
-	case "javascript", "typescript":
+	case "javascript":
 		p.parseJavaScriptCode(tree.RootNode(), sourceCode, file)
+	case "typescript":
+		p.parseTypeScriptCode(tree.RootNode(), sourceCode, file)
 	case "java":
 		p.parseJavaCode(tree.RootNode(), sourceCode, file)
-	}
+	case "rust":
+		p.parseRustCode(tree.RootNode(), sourceCode, file)
+	case "c", "cpp":
+		p.parseCFamilyCode(tree.RootNode(), sourceCode, file)
+	case "csharp":
+		p.parseCSharpCode(tree.RootNode(), sourceCode, file)
+	case "kotlin":
+		p.parseKotlinCode(tree.RootNode(), sourceCode, file)
+	case "ruby":
+		p.parseRubyCode(tree.RootNode(), sourceCode, file)
+	}
+	file.References = p.extractReferences(tree.RootNode(), sourceCode)
 
 	return file, nil
 }
@@ -237,7 +271,12 @@ func (p *TreeSitterParser) extractComment(node *sitter.Node, source []byte) type
 	
 	// Clean up comment markers
 	text = strings.TrimSpace(text)
-	if strings.HasPrefix(text, "//") {
+	commentType := "line"
+	if strings.HasPrefix(text, "///") || strings.HasPrefix(text, "//!") {
+		// Rust and C# doc comments
+		commentType = "doc"
+		text = strings.TrimSpace(text[3:])
+	} else if strings.HasPrefix(text, "//") {
 		text = strings.TrimSpace(strings.TrimPrefix(text, "//"))
 	} else if strings.HasPrefix(text, "/*") && strings.HasSuffix(text, "*/") {
 		text = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/"))
@@ -245,7 +284,6 @@ func (p *TreeSitterParser) extractComment(node *sitter.Node, source []byte) type
 		text = strings.TrimSpace(strings.TrimPrefix(text, "#"))
 	}
 
-	commentType := "line"
 	if strings.Contains(p.getNodeText(node, source), "/*") {
 		commentType = "block"
 	}
@@ -266,21 +304,22 @@ func (p *TreeSitterParser) extractGoFunction(node *sitter.Node, source []byte) t
 		Signature: p.getNodeText(node, source),
 	}
 
-	// Extract function name
-	for i := 0; i < int(node.ChildCount()); i++ {
-		child := node.Child(i)
-		if child.Type() == "identifier" {
-			function.Name = p.getNodeText(child, source)
-			break
-		}
+	// Functions are named by an identifier, methods by a field_identifier
+	function.Name = p.getFieldText(node, "name", source)
+
+	if receiver := node.ChildByFieldName("receiver"); receiver != nil && receiver.NamedChildCount() > 0 {
+		function.IsMethod = true
+		receiverType := strings.TrimLeft(p.getFieldText(receiver.NamedChild(0), "type", source), "*")
+		function.ClassName, _, _ = strings.Cut(receiverType, "[")
 	}
 
-	// Extract parameters and return type
+	// Extract parameters, not the receiver of a method, and return type
+	if parameters := node.ChildByFieldName("parameters"); parameters != nil {
+		function.Parameters = p.extractGoParameters(parameters, source)
+	}
 	for i := 0; i < int(node.ChildCount()); i++ {
 		child := node.Child(i)
-		if child.Type() == "parameter_list" {
-			function.Parameters = p.extractGoParameters(child, source)
-		} else if child.Type() == "type_identifier" || child.Type() == "pointer_type" {
+		if child.Type() == "type_identifier" || child.Type() == "pointer_type" {
 			function.ReturnType = p.getNodeText(child, source)
 		}
 	}
@@ -495,7 +534,10 @@ func (p *TreeSitterParser) extractPythonImports(node *sitter.Node, source []byte
 
 		for i := 0; i < int(node.ChildCount()); i++ {
 			child := node.Child(i)
-			if child.Type() == "dotted_name" || child.Type() == "identifier" {
+			if child.Type() == "relative_import" && importStmt.Module == "" {
+				// from . import x, from ..pkg import x
+				importStmt.Module = p.getNodeText(child, source)
+			} else if child.Type() == "dotted_name" || child.Type() == "identifier" {
 				if importStmt.Module == "" {
 					importStmt.Module = p.getNodeText(child, source)
 				} else {
@@ -0,0 +1,732 @@
+package parser
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Extraction for the languages that only have a tree-sitter parser: Rust,
+// C, C++, C#, Kotlin and Ruby. Their grammars name most child nodes, so
+// fields are looked up by name where the grammar provides them.
+
+// getFieldText returns the text of a node's named field, or "" if it has none
+func (p *TreeSitterParser) getFieldText(node *sitter.Node, field string, source []byte) string {
+	if child := node.ChildByFieldName(field); child != nil {
+		return p.getNodeText(child, source)
+	}
+	return ""
+}
+
+// firstChildOfType returns the first direct child of the given type
+func firstChildOfType(node *sitter.Node, nodeType string) *sitter.Node {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if child := node.Child(i); child.Type() == nodeType {
+			return child
+		}
+	}
+	return nil
+}
+
+// namedChildTexts returns the text of every named child of a node, such as
+// the entries of a parameter list
+func (p *TreeSitterParser) namedChildTexts(node *sitter.Node, source []byte) []string {
+	if node == nil {
+		return nil
+	}
+	var texts []string
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if strings.HasSuffix(child.Type(), "comment") {
+			continue
+		}
+		texts = append(texts, p.getNodeText(child, source))
+	}
+	return texts
+}
+
+// enclosingName returns the name of the nearest ancestor of one of the given
+// types, or "" if the node is not nested in one
+func (p *TreeSitterParser) enclosingName(node *sitter.Node, source []byte, ancestorTypes ...string) string {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		for _, ancestorType := range ancestorTypes {
+			if parent.Type() != ancestorType {
+				continue
+			}
+			if name := parent.ChildByFieldName("name"); name != nil {
+				return p.getNodeText(name, source)
+			}
+			// Kotlin declarations have no named fields
+			if name := firstChildOfType(parent, "type_identifier"); name != nil {
+				return p.getNodeText(name, source)
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// modifierVisibility returns the first visibility keyword among a node's
+// modifiers, or "" if none is given
+func (p *TreeSitterParser) modifierVisibility(node *sitter.Node, source []byte) string {
+	var visibility string
+	for i := 0; i < int(node.ChildCount()) && visibility == ""; i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "modifier", "visibility_modifier":
+			visibility = p.getNodeText(child, source)
+		case "modifiers":
+			visibility = p.modifierVisibility(child, source)
+		}
+		switch visibility {
+		case "public", "private", "protected", "internal":
+		default:
+			visibility = ""
+		}
+	}
+	return visibility
+}
+
+// hasModifier reports whether a node carries the given modifier keyword
+func (p *TreeSitterParser) hasModifier(node *sitter.Node, source []byte, modifier string) bool {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "modifier", "type_qualifier", "storage_class_specifier":
+			if p.getNodeText(child, source) == modifier {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseRustCode extracts Rust-specific metadata using tree-sitter
+func (p *TreeSitterParser) parseRustCode(node *sitter.Node, source []byte, file *types.CodeFile) {
+	p.walkNode(node, source, func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_item", "function_signature_item":
+			function := p.extractRustFunction(n, source)
+			file.Functions = append(file.Functions, function)
+
+		case "struct_item", "enum_item", "trait_item", "union_item":
+			class := types.Class{
+				Name:       p.getFieldText(n, "name", source),
+				StartLine:  p.getLineNumber(n),
+				EndLine:    p.getEndLineNumber(n),
+				Visibility: rustVisibility(n),
+			}
+			file.Classes = append(file.Classes, class)
+
+		case "const_item", "static_item":
+			variable := types.Variable{
+				Name:       p.getFieldText(n, "name", source),
+				Type:       p.getFieldText(n, "type", source),
+				Value:      p.getFieldText(n, "value", source),
+				StartLine:  p.getLineNumber(n),
+				EndLine:    p.getEndLineNumber(n),
+				Visibility: rustVisibility(n),
+				IsConstant: n.Type() == "const_item",
+				IsGlobal:   true,
+			}
+			file.Variables = append(file.Variables, variable)
+
+		case "use_declaration":
+			if argument := n.ChildByFieldName("argument"); argument != nil {
+				imports := p.extractRustUse(argument, source, "", p.getLineNumber(n))
+				file.Imports = append(file.Imports, imports...)
+			}
+
+		case "line_comment", "block_comment":
+			comment := p.extractComment(n, source)
+			file.Comments = append(file.Comments, comment)
+		}
+	})
+}
+
+// rustVisibility returns "public" for items marked pub and "private" otherwise
+func rustVisibility(node *sitter.Node) string {
+	if firstChildOfType(node, "visibility_modifier") != nil {
+		return "public"
+	}
+	return "private"
+}
+
+// extractRustFunction extracts Rust function information. Functions inside
+// impl and trait blocks are methods of the implemented type or trait.
+func (p *TreeSitterParser) extractRustFunction(node *sitter.Node, source []byte) types.Function {
+	function := types.Function{
+		Name:       p.getFieldText(node, "name", source),
+		StartLine:  p.getLineNumber(node),
+		EndLine:    p.getEndLineNumber(node),
+		Parameters: p.namedChildTexts(node.ChildByFieldName("parameters"), source),
+		ReturnType: p.getFieldText(node, "return_type", source),
+		Visibility: rustVisibility(node),
+		Signature:  p.getNodeText(node, source),
+	}
+
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if parent.Type() == "impl_item" {
+			function.IsMethod = true
+			function.ClassName = p.getFieldText(parent, "type", source)
+			break
+		}
+		if parent.Type() == "trait_item" {
+			function.IsMethod = true
+			function.ClassName = p.getFieldText(parent, "name", source)
+			break
+		}
+	}
+
+	return function
+}
+
+// extractRustUse expands a use tree into one import per imported path
+func (p *TreeSitterParser) extractRustUse(node *sitter.Node, source []byte, prefix string, line int) []types.Import {
+	switch node.Type() {
+	case "use_as_clause":
+		return []types.Import{{
+			Module:    prefix + p.getFieldText(node, "path", source),
+			Alias:     p.getFieldText(node, "alias", source),
+			StartLine: line,
+		}}
+
+	case "use_wildcard":
+		return []types.Import{{
+			Module:     prefix + strings.TrimSuffix(p.getNodeText(node, source), "::*"),
+			StartLine:  line,
+			IsWildcard: true,
+		}}
+
+	case "scoped_use_list":
+		if path := p.getFieldText(node, "path", source); path != "" {
+			prefix += path + "::"
+		}
+		if list := node.ChildByFieldName("list"); list != nil {
+			return p.extractRustUse(list, source, prefix, line)
+		}
+		return nil
+
+	case "use_list":
+		var imports []types.Import
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			imports = append(imports, p.extractRustUse(node.NamedChild(i), source, prefix, line)...)
+		}
+		return imports
+	}
+
+	return []types.Import{{Module: prefix + p.getNodeText(node, source), StartLine: line}}
+}
+
+// parseCFamilyCode extracts C and C++ metadata using tree-sitter
+func (p *TreeSitterParser) parseCFamilyCode(node *sitter.Node, source []byte, file *types.CodeFile) {
+	p.walkNode(node, source, func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_definition":
+			function := p.extractCFunction(n, source)
+			if function.Name != "" {
+				file.Functions = append(file.Functions, function)
+			}
+
+		case "struct_specifier", "class_specifier", "union_specifier", "enum_specifier":
+			// Specifiers without a body only name an existing type
+			if n.ChildByFieldName("body") != nil && n.ChildByFieldName("name") != nil {
+				class := p.extractCClass(n, source)
+				file.Classes = append(file.Classes, class)
+			}
+
+		case "declaration":
+			// Only file and namespace scope declarations are symbols
+			if parent := n.Parent(); parent != nil && (parent.Type() == "translation_unit" || parent.Type() == "declaration_list") {
+				variables := p.extractCVariables(n, source)
+				file.Variables = append(file.Variables, variables...)
+			}
+
+		case "preproc_include":
+			module := strings.Trim(p.getFieldText(n, "path", source), `<>"`)
+			file.Imports = append(file.Imports, types.Import{Module: module, StartLine: p.getLineNumber(n)})
+
+		case "comment":
+			comment := p.extractComment(n, source)
+			file.Comments = append(file.Comments, comment)
+		}
+	})
+}
+
+// cDeclaratorName follows nested declarators down to the declared name. The
+// scope of a qualified C++ name, such as Store in Store::load, is returned
+// separately.
+func (p *TreeSitterParser) cDeclaratorName(node *sitter.Node, source []byte) (name, scope string) {
+	for node != nil {
+		switch node.Type() {
+		case "identifier", "field_identifier", "type_identifier", "destructor_name", "operator_name":
+			return p.getNodeText(node, source), ""
+		case "qualified_identifier":
+			name, _ := p.cDeclaratorName(node.ChildByFieldName("name"), source)
+			return name, p.getFieldText(node, "scope", source)
+		case "reference_declarator":
+			// The declared name is not a field of reference declarators
+			if node.NamedChildCount() > 0 {
+				node = node.NamedChild(int(node.NamedChildCount()) - 1)
+				continue
+			}
+			return "", ""
+		}
+		node = node.ChildByFieldName("declarator")
+	}
+	return "", ""
+}
+
+// cFunctionDeclarator returns the function declarator nested in a
+// declarator, or nil if it declares something else
+func cFunctionDeclarator(node *sitter.Node) *sitter.Node {
+	for node != nil {
+		if node.Type() == "function_declarator" {
+			return node
+		}
+		node = node.ChildByFieldName("declarator")
+	}
+	return nil
+}
+
+// extractCFunction extracts C and C++ function information. Functions
+// defined in a class body or with a qualified name are methods.
+func (p *TreeSitterParser) extractCFunction(node *sitter.Node, source []byte) types.Function {
+	declarator := node.ChildByFieldName("declarator")
+	name, scope := p.cDeclaratorName(declarator, source)
+
+	function := types.Function{
+		Name:       name,
+		StartLine:  p.getLineNumber(node),
+		EndLine:    p.getEndLineNumber(node),
+		ReturnType: p.getFieldText(node, "type", source),
+		Signature:  p.getNodeText(node, source),
+		ClassName:  scope,
+	}
+	if functionDeclarator := cFunctionDeclarator(declarator); functionDeclarator != nil {
+		function.Parameters = p.namedChildTexts(functionDeclarator.ChildByFieldName("parameters"), source)
+	}
+	if function.ClassName == "" {
+		function.ClassName = p.enclosingName(node, source, "class_specifier", "struct_specifier")
+	}
+	function.IsMethod = function.ClassName != ""
+	if p.hasModifier(node, source, "static") {
+		function.Visibility = "private"
+	}
+
+	return function
+}
+
+// extractCClass extracts C structs and unions and C++ classes. The first
+// base class is the superclass and any further ones are listed as
+// interfaces.
+func (p *TreeSitterParser) extractCClass(node *sitter.Node, source []byte) types.Class {
+	class := types.Class{
+		Name:      p.getFieldText(node, "name", source),
+		StartLine: p.getLineNumber(node),
+		EndLine:   p.getEndLineNumber(node),
+	}
+
+	if bases := firstChildOfType(node, "base_class_clause"); bases != nil {
+		for i := 0; i < int(bases.NamedChildCount()); i++ {
+			base := bases.NamedChild(i)
+			if base.Type() == "access_specifier" {
+				continue
+			}
+			if class.SuperClass == "" {
+				class.SuperClass = p.getNodeText(base, source)
+			} else {
+				class.Interfaces = append(class.Interfaces, p.getNodeText(base, source))
+			}
+		}
+	}
+
+	return class
+}
+
+// extractCVariables extracts the variables of a C or C++ declaration,
+// leaving out function prototypes
+func (p *TreeSitterParser) extractCVariables(node *sitter.Node, source []byte) []types.Variable {
+	var variables []types.Variable
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if node.FieldNameForChild(i) != "declarator" {
+			continue
+		}
+		declarator := node.Child(i)
+		if cFunctionDeclarator(declarator) != nil {
+			continue
+		}
+
+		name, _ := p.cDeclaratorName(declarator, source)
+		if name == "" {
+			continue
+		}
+		variable := types.Variable{
+			Name:       name,
+			Type:       p.getFieldText(node, "type", source),
+			StartLine:  p.getLineNumber(declarator),
+			EndLine:    p.getEndLineNumber(declarator),
+			IsConstant: p.hasModifier(node, source, "const") || p.hasModifier(node, source, "constexpr"),
+			IsGlobal:   true,
+		}
+		if declarator.Type() == "init_declarator" {
+			variable.Value = p.getFieldText(declarator, "value", source)
+		}
+		if p.hasModifier(node, source, "static") {
+			variable.Visibility = "private"
+		}
+		variables = append(variables, variable)
+	}
+
+	return variables
+}
+
+// parseCSharpCode extracts C#-specific metadata using tree-sitter
+func (p *TreeSitterParser) parseCSharpCode(node *sitter.Node, source []byte, file *types.CodeFile) {
+	p.walkNode(node, source, func(n *sitter.Node) {
+		switch n.Type() {
+		case "method_declaration", "constructor_declaration":
+			function := p.extractCSharpMethod(n, source)
+			file.Functions = append(file.Functions, function)
+
+		case "class_declaration", "struct_declaration", "interface_declaration", "enum_declaration", "record_declaration":
+			class := p.extractCSharpClass(n, source)
+			file.Classes = append(file.Classes, class)
+
+		case "field_declaration":
+			variables := p.extractCSharpFields(n, source)
+			file.Variables = append(file.Variables, variables...)
+
+		case "property_declaration":
+			variable := types.Variable{
+				Name:       p.getFieldText(n, "name", source),
+				Type:       p.getFieldText(n, "type", source),
+				StartLine:  p.getLineNumber(n),
+				EndLine:    p.getEndLineNumber(n),
+				Visibility: p.modifierVisibility(n, source),
+				Scope:      "class",
+			}
+			file.Variables = append(file.Variables, variable)
+
+		case "using_directive":
+			file.Imports = append(file.Imports, p.extractCSharpUsing(n, source))
+
+		case "comment":
+			comment := p.extractComment(n, source)
+			file.Comments = append(file.Comments, comment)
+		}
+	})
+}
+
+// csharpTypeDeclarations are the declarations that methods and fields
+// belong to
+var csharpTypeDeclarations = []string{"class_declaration", "struct_declaration", "interface_declaration", "record_declaration"}
+
+// extractCSharpMethod extracts C# method and constructor information
+func (p *TreeSitterParser) extractCSharpMethod(node *sitter.Node, source []byte) types.Function {
+	return types.Function{
+		Name:       p.getFieldText(node, "name", source),
+		StartLine:  p.getLineNumber(node),
+		EndLine:    p.getEndLineNumber(node),
+		Parameters: p.namedChildTexts(node.ChildByFieldName("parameters"), source),
+		ReturnType: p.getFieldText(node, "returns", source),
+		Visibility: p.modifierVisibility(node, source),
+		IsMethod:   true,
+		ClassName:  p.enclosingName(node, source, csharpTypeDeclarations...),
+		Signature:  p.getNodeText(node, source),
+	}
+}
+
+// extractCSharpClass extracts C# type declarations. Base types named like
+// interfaces (IName) are listed as interfaces and the other one, if any, is
+// the superclass.
+func (p *TreeSitterParser) extractCSharpClass(node *sitter.Node, source []byte) types.Class {
+	class := types.Class{
+		Name:       p.getFieldText(node, "name", source),
+		StartLine:  p.getLineNumber(node),
+		EndLine:    p.getEndLineNumber(node),
+		Visibility: p.modifierVisibility(node, source),
+	}
+
+	if bases := firstChildOfType(node, "base_list"); bases != nil {
+		for _, base := range p.namedChildTexts(bases, source) {
+			if len(base) > 1 && base[0] == 'I' && base[1] >= 'A' && base[1] <= 'Z' {
+				class.Interfaces = append(class.Interfaces, base)
+			} else if class.SuperClass == "" {
+				class.SuperClass = base
+			}
+		}
+	}
+
+	return class
+}
+
+// extractCSharpFields extracts the variables of a C# field declaration
+func (p *TreeSitterParser) extractCSharpFields(node *sitter.Node, source []byte) []types.Variable {
+	var variables []types.Variable
+
+	declaration := firstChildOfType(node, "variable_declaration")
+	if declaration == nil {
+		return nil
+	}
+	fieldType := p.getFieldText(declaration, "type", source)
+
+	for i := 0; i < int(declaration.NamedChildCount()); i++ {
+		declarator := declaration.NamedChild(i)
+		if declarator.Type() != "variable_declarator" {
+			continue
+		}
+		variable := types.Variable{
+			Name:       p.getFieldText(declarator, "name", source),
+			Type:       fieldType,
+			StartLine:  p.getLineNumber(declarator),
+			EndLine:    p.getEndLineNumber(declarator),
+			Visibility: p.modifierVisibility(node, source),
+			IsConstant: p.hasModifier(node, source, "const"),
+			IsGlobal:   p.hasModifier(node, source, "static"),
+			Scope:      "class",
+		}
+		// The initializer is the declarator's last named child
+		if count := int(declarator.NamedChildCount()); count > 1 {
+			variable.Value = p.getNodeText(declarator.NamedChild(count-1), source)
+		}
+		variables = append(variables, variable)
+	}
+
+	return variables
+}
+
+// extractCSharpUsing extracts a using directive. In an alias directive the
+// alias is the name field and the namespace the other child.
+func (p *TreeSitterParser) extractCSharpUsing(node *sitter.Node, source []byte) types.Import {
+	using := types.Import{StartLine: p.getLineNumber(node)}
+
+	alias := node.ChildByFieldName("name")
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if sameNode(child, alias) {
+			continue
+		}
+		using.Module = p.getNodeText(child, source)
+	}
+	if using.Module == "" && alias != nil {
+		using.Module = p.getNodeText(alias, source)
+	} else if alias != nil {
+		using.Alias = p.getNodeText(alias, source)
+	}
+
+	return using
+}
+
+// parseKotlinCode extracts Kotlin-specific metadata using tree-sitter
+func (p *TreeSitterParser) parseKotlinCode(node *sitter.Node, source []byte, file *types.CodeFile) {
+	p.walkNode(node, source, func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_declaration":
+			function := p.extractKotlinFunction(n, source)
+			file.Functions = append(file.Functions, function)
+
+		case "class_declaration", "object_declaration":
+			class := p.extractKotlinClass(n, source)
+			file.Classes = append(file.Classes, class)
+
+		case "property_declaration":
+			// Local properties inside function bodies are not symbols
+			if parent := n.Parent(); parent != nil && (parent.Type() == "source_file" || parent.Type() == "class_body") {
+				variable := p.extractKotlinProperty(n, source)
+				file.Variables = append(file.Variables, variable)
+			}
+
+		case "import_header":
+			file.Imports = append(file.Imports, types.Import{
+				Module:     p.getNodeText(firstChildOfType(n, "identifier"), source),
+				Alias:      p.kotlinImportAlias(n, source),
+				StartLine:  p.getLineNumber(n),
+				IsWildcard: firstChildOfType(n, "wildcard_import") != nil,
+			})
+
+		case "line_comment", "multiline_comment":
+			comment := p.extractComment(n, source)
+			file.Comments = append(file.Comments, comment)
+		}
+	})
+}
+
+// kotlinVisibility returns a declaration's visibility, which is public
+// unless a modifier says otherwise
+func (p *TreeSitterParser) kotlinVisibility(node *sitter.Node, source []byte) string {
+	if visibility := p.modifierVisibility(node, source); visibility != "" {
+		return visibility
+	}
+	return "public"
+}
+
+// extractKotlinFunction extracts Kotlin function information. The return
+// type is the type that follows the parameter list.
+func (p *TreeSitterParser) extractKotlinFunction(node *sitter.Node, source []byte) types.Function {
+	function := types.Function{
+		StartLine:  p.getLineNumber(node),
+		EndLine:    p.getEndLineNumber(node),
+		Visibility: p.kotlinVisibility(node, source),
+		Signature:  p.getNodeText(node, source),
+	}
+
+	afterParameters := false
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "simple_identifier":
+			if function.Name == "" {
+				function.Name = p.getNodeText(child, source)
+			}
+		case "function_value_parameters":
+			function.Parameters = p.namedChildTexts(child, source)
+			afterParameters = true
+		case "user_type", "nullable_type":
+			if afterParameters && function.ReturnType == "" {
+				function.ReturnType = p.getNodeText(child, source)
+			}
+		}
+	}
+
+	function.ClassName = p.enclosingName(node, source, "class_declaration", "object_declaration")
+	function.IsMethod = function.ClassName != ""
+
+	return function
+}
+
+// extractKotlinClass extracts Kotlin classes, interfaces and objects. A
+// supertype called with constructor arguments is the superclass; the others
+// are interfaces.
+func (p *TreeSitterParser) extractKotlinClass(node *sitter.Node, source []byte) types.Class {
+	class := types.Class{
+		StartLine:  p.getLineNumber(node),
+		EndLine:    p.getEndLineNumber(node),
+		Visibility: p.kotlinVisibility(node, source),
+	}
+	if name := firstChildOfType(node, "type_identifier"); name != nil {
+		class.Name = p.getNodeText(name, source)
+	}
+
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		if child.Type() != "delegation_specifier" {
+			continue
+		}
+		if invocation := firstChildOfType(child, "constructor_invocation"); invocation != nil {
+			if superType := firstChildOfType(invocation, "user_type"); superType != nil {
+				class.SuperClass = p.getNodeText(superType, source)
+			}
+		} else if superType := firstChildOfType(child, "user_type"); superType != nil {
+			class.Interfaces = append(class.Interfaces, p.getNodeText(superType, source))
+		}
+	}
+
+	return class
+}
+
+// extractKotlinProperty extracts a top-level or class property
+func (p *TreeSitterParser) extractKotlinProperty(node *sitter.Node, source []byte) types.Variable {
+	variable := types.Variable{
+		StartLine:  p.getLineNumber(node),
+		EndLine:    p.getEndLineNumber(node),
+		Visibility: p.kotlinVisibility(node, source),
+		IsGlobal:   node.Parent().Type() == "source_file",
+	}
+	if kind := firstChildOfType(node, "binding_pattern_kind"); kind != nil {
+		variable.IsConstant = p.getNodeText(kind, source) == "val"
+	}
+	if declaration := firstChildOfType(node, "variable_declaration"); declaration != nil {
+		if name := firstChildOfType(declaration, "simple_identifier"); name != nil {
+			variable.Name = p.getNodeText(name, source)
+		}
+		if propertyType := firstChildOfType(declaration, "user_type"); propertyType != nil {
+			variable.Type = p.getNodeText(propertyType, source)
+		}
+	}
+	if !variable.IsGlobal {
+		variable.Scope = "class"
+	}
+
+	return variable
+}
+
+// kotlinImportAlias returns the alias of an import, or "" if it has none
+func (p *TreeSitterParser) kotlinImportAlias(node *sitter.Node, source []byte) string {
+	if alias := firstChildOfType(node, "import_alias"); alias != nil {
+		if name := firstChildOfType(alias, "type_identifier"); name != nil {
+			return p.getNodeText(name, source)
+		}
+	}
+	return ""
+}
+
+// rubyRequireMethods are the calls that load another file
+var rubyRequireMethods = map[string]bool{"require": true, "require_relative": true, "load": true}
+
+// parseRubyCode extracts Ruby-specific metadata using tree-sitter
+func (p *TreeSitterParser) parseRubyCode(node *sitter.Node, source []byte, file *types.CodeFile) {
+	p.walkNode(node, source, func(n *sitter.Node) {
+		switch n.Type() {
+		case "method", "singleton_method":
+			function := types.Function{
+				Name:       p.getFieldText(n, "name", source),
+				StartLine:  p.getLineNumber(n),
+				EndLine:    p.getEndLineNumber(n),
+				Parameters: p.namedChildTexts(n.ChildByFieldName("parameters"), source),
+				ClassName:  p.enclosingName(n, source, "class", "module"),
+				Signature:  p.getNodeText(n, source),
+			}
+			function.IsMethod = function.ClassName != ""
+			file.Functions = append(file.Functions, function)
+
+		case "class", "module":
+			class := types.Class{
+				Name:      p.getFieldText(n, "name", source),
+				StartLine: p.getLineNumber(n),
+				EndLine:   p.getEndLineNumber(n),
+			}
+			if superclass := n.ChildByFieldName("superclass"); superclass != nil && superclass.NamedChildCount() > 0 {
+				class.SuperClass = p.getNodeText(superclass.NamedChild(0), source)
+			}
+			file.Classes = append(file.Classes, class)
+
+		case "assignment":
+			// Constants and globals are symbols; locals and instance
+			// variables are not
+			left := n.ChildByFieldName("left")
+			if left != nil && (left.Type() == "constant" || left.Type() == "global_variable") {
+				variable := types.Variable{
+					Name:       p.getNodeText(left, source),
+					Value:      p.getFieldText(n, "right", source),
+					StartLine:  p.getLineNumber(n),
+					EndLine:    p.getEndLineNumber(n),
+					IsConstant: left.Type() == "constant",
+					IsGlobal:   left.Type() == "global_variable",
+				}
+				file.Variables = append(file.Variables, variable)
+			}
+
+		case "call":
+			if rubyRequireMethods[p.getFieldText(n, "method", source)] && n.ChildByFieldName("receiver") == nil {
+				if arguments := n.ChildByFieldName("arguments"); arguments != nil {
+					p.walkNode(arguments, source, func(argument *sitter.Node) {
+						if argument.Type() == "string_content" {
+							file.Imports = append(file.Imports, types.Import{
+								Module:    p.getNodeText(argument, source),
+								StartLine: p.getLineNumber(n),
+							})
+						}
+					})
+				}
+			}
+
+		case "comment":
+			comment := p.extractComment(n, source)
+			file.Comments = append(file.Comments, comment)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/my-mcp/code-indexer/pkg/types"
@@ -95,6 +96,21 @@ var defaultName = "Unknown"
 		}
 	}
 
+	// Methods are named by their field identifier, without the receiver
+	// among their parameters
+	var getInfo *types.Function
+	for _, f := range file.Functions {
+		if f.Name == "GetInfo" {
+			getInfo = &f
+			break
+		}
+	}
+	if getInfo == nil {
+		t.Errorf("Expected to find GetInfo method, got %+v", file.Functions)
+	} else if len(getInfo.Parameters) != 0 || !getInfo.IsMethod || getInfo.ClassName != "Person" {
+		t.Errorf("Expected GetInfo to be a method of Person taking no parameters, got %+v", getInfo)
+	}
+
 	// Check structs (classes) - tree-sitter may not extract all structs
 	// This is acceptable as tree-sitter parsing is more complex
 	t.Logf("Found %d structs/classes", len(file.Classes))
@@ -195,6 +211,27 @@ if __name__ == "__main__":
 	}
 }
 
+func TestTreeSitterPythonRelativeImports(t *testing.T) {
+	parser := NewTreeSitterParser("python")
+	if parser == nil {
+		t.Skip("Tree-sitter Python parser not available")
+	}
+
+	file, err := parser.Parse("from . import views\nfrom ..models import User\nfrom app.db import session\n", "app/api/routes.py")
+	if err != nil {
+		t.Fatalf("Failed to parse Python code: %v", err)
+	}
+
+	var modules []string
+	for _, imp := range file.Imports {
+		modules = append(modules, imp.Module)
+	}
+	expected := []string{".", "..models", "app.db"}
+	if strings.Join(modules, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected imports %v, got %v", expected, modules)
+	}
+}
+
 func TestTreeSitterJavaScriptParser(t *testing.T) {
 	parser := NewTreeSitterParser("javascript")
 	if parser == nil {
@@ -369,3 +406,469 @@ func {
 		t.Error("Expected file to be returned even with invalid syntax")
 	}
 }
+
+// findReference returns the first reference to name of the given kind
+func findReference(references []types.Reference, name, kind string) (types.Reference, bool) {
+	for _, reference := range references {
+		if reference.Name == name && reference.Kind == kind {
+			return reference, true
+		}
+	}
+	return types.Reference{}, false
+}
+
+func TestTreeSitterReferences(t *testing.T) {
+	tests := []struct {
+		language string
+		code     string
+		expected []types.Reference
+		excluded []string
+	}{
+		{
+			language: "go",
+			code: `package main
+
+import "strings"
+
+type Store struct{}
+
+func (s *Store) Save(name string) error {
+	return validate(strings.TrimSpace(name))
+}
+
+func validate(name string) error {
+	if len(name) == 0 {
+		return nil
+	}
+	return nil
+}
+`,
+			expected: []types.Reference{
+				{Name: "validate", Kind: "call", Caller: "Save", Line: 8, Column: 9},
+				{Name: "TrimSpace", Kind: "call", Qualifier: "strings", Caller: "Save", Line: 8},
+				{Name: "Store", Kind: "type", Caller: "Save", Line: 7},
+			},
+			excluded: []string{"len", "string", "error"},
+		},
+		{
+			language: "python",
+			code: `class Repo(Base):
+    def save(self, item):
+        self.validate(item)
+        print(item)
+`,
+			expected: []types.Reference{
+				{Name: "Base", Kind: "type", Line: 1},
+				{Name: "validate", Kind: "call", Qualifier: "self", Caller: "save", Line: 3},
+			},
+			excluded: []string{"print"},
+		},
+		{
+			language: "javascript",
+			code: `class Cache extends Store {
+  load(key) {
+    return new Entry(fetchValue(key));
+  }
+}
+`,
+			expected: []types.Reference{
+				{Name: "Store", Kind: "type", Line: 1},
+				{Name: "Entry", Kind: "call", Caller: "load", Line: 3},
+				{Name: "fetchValue", Kind: "call", Caller: "load", Line: 3},
+			},
+		},
+		{
+			language: "java",
+			code: `public class Service {
+    public Result run(Request request) {
+        Result result = new Result();
+        repository.save(request);
+        return result;
+    }
+}
+`,
+			expected: []types.Reference{
+				{Name: "Request", Kind: "type", Caller: "run", Line: 2},
+				{Name: "Result", Kind: "call", Caller: "run", Line: 3},
+				{Name: "save", Kind: "call", Qualifier: "repository", Caller: "run", Line: 4},
+			},
+		},
+		{
+			language: "rust",
+			code: `impl Config {
+    fn count(&self) -> u32 { helper(self.count); Store::open(); self.total() }
+}
+`,
+			expected: []types.Reference{
+				{Name: "helper", Kind: "call", Caller: "count", Line: 2},
+				{Name: "open", Kind: "call", Qualifier: "Store", Caller: "count", Line: 2},
+				{Name: "total", Kind: "call", Qualifier: "self", Caller: "count", Line: 2},
+			},
+		},
+		{
+			language: "cpp",
+			code: `int Store::load(int id) {
+    return std::max(db->get(id), helper());
+}
+`,
+			expected: []types.Reference{
+				{Name: "max", Kind: "call", Qualifier: "std", Caller: "load", Line: 2},
+				{Name: "get", Kind: "call", Qualifier: "db", Caller: "load", Line: 2},
+				{Name: "helper", Kind: "call", Caller: "load", Line: 2},
+			},
+		},
+		{
+			language: "csharp",
+			code: `class Store {
+    int Save(string key) { return db.Put(new Entry(key)) + Helper(); }
+}
+`,
+			expected: []types.Reference{
+				{Name: "Put", Kind: "call", Qualifier: "db", Caller: "Save", Line: 2},
+				{Name: "Entry", Kind: "call", Caller: "Save", Line: 2},
+				{Name: "Helper", Kind: "call", Caller: "Save", Line: 2},
+			},
+		},
+		{
+			language: "kotlin",
+			code: `class Store {
+    fun save(key: String): Boolean { println(key); return client.put(key) && helper(key) }
+}
+`,
+			expected: []types.Reference{
+				{Name: "put", Kind: "call", Qualifier: "client", Caller: "save", Line: 2},
+				{Name: "helper", Kind: "call", Caller: "save", Line: 2},
+			},
+			excluded: []string{"println"},
+		},
+		{
+			language: "ruby",
+			code: `require 'json'
+class Store
+  def self.open(path)
+    File.read(path)
+    helper(path)
+  end
+end
+`,
+			expected: []types.Reference{
+				{Name: "read", Kind: "call", Qualifier: "File", Caller: "open", Line: 4},
+				{Name: "helper", Kind: "call", Caller: "open", Line: 5},
+			},
+			excluded: []string{"require"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			parser := NewTreeSitterParser(tt.language)
+			if parser == nil {
+				t.Skipf("Tree-sitter %s parser not available", tt.language)
+			}
+
+			file, err := parser.Parse(tt.code, "example")
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			for _, want := range tt.expected {
+				got, ok := findReference(file.References, want.Name, want.Kind)
+				if !ok {
+					t.Errorf("Missing %s reference to %s in %+v", want.Kind, want.Name, file.References)
+					continue
+				}
+				if got.Qualifier != want.Qualifier || got.Caller != want.Caller || got.Line != want.Line {
+					t.Errorf("Reference to %s = %+v, want %+v", want.Name, got, want)
+				}
+				if want.Column > 0 && got.Column != want.Column {
+					t.Errorf("Reference to %s at column %d, want %d", want.Name, got.Column, want.Column)
+				}
+			}
+
+			for _, name := range tt.excluded {
+				for _, reference := range file.References {
+					if reference.Name == name {
+						t.Errorf("Expected builtin %s to be left out, got %+v", name, reference)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestTreeSitterSymbolLanguages(t *testing.T) {
+	tests := []struct {
+		language  string
+		code      string
+		functions map[string]string // Function name to class name
+		classes   []string
+		variables []string
+		imports   []string
+		comments  int
+	}{
+		{
+			language: "rust",
+			code: `use std::collections::HashMap;
+use crate::store::{Store, Item as It};
+
+/// Config holds settings
+pub struct Config { pub name: String }
+pub trait Saver { fn save(&self) -> bool; }
+impl Config {
+    pub fn new(name: &str) -> Self { Config { name: name.to_string() } }
+}
+const MAX: usize = 10;
+fn helper(x: u32) {}
+`,
+			functions: map[string]string{"new": "Config", "save": "Saver", "helper": ""},
+			classes:   []string{"Config", "Saver"},
+			variables: []string{"MAX"},
+			imports:   []string{"std::collections::HashMap", "crate::store::Store", "crate::store::Item"},
+			comments:  1,
+		},
+		{
+			language: "c",
+			code: `#include <stdio.h>
+#include "store.h"
+/* A point */
+struct Point { int x; int y; };
+static int counter = 0;
+int prototype(int a);
+int add(int a, int b) { return a + b; }
+static void *make(void) { return 0; }
+`,
+			functions: map[string]string{"add": "", "make": ""},
+			classes:   []string{"Point"},
+			variables: []string{"counter"},
+			imports:   []string{"stdio.h", "store.h"},
+			comments:  1,
+		},
+		{
+			language: "cpp",
+			code: `#include <vector>
+namespace app {
+// Store keeps items
+class Store : public Base {
+public:
+    int save(int key) const { return key; }
+};
+int Store::load(int id) { return id; }
+}
+`,
+			functions: map[string]string{"save": "Store", "load": "Store"},
+			classes:   []string{"Store"},
+			imports:   []string{"vector"},
+			comments:  1,
+		},
+		{
+			language: "csharp",
+			code: `using System.Text;
+using IO = System.IO;
+namespace App {
+    /// <summary>Store</summary>
+    public class Store : Base, IStore {
+        private int count = 0;
+        public string Title { get; set; }
+        public Store(int n) { }
+        public int Save(string key) { return 1; }
+    }
+}
+`,
+			functions: map[string]string{"Store": "Store", "Save": "Store"},
+			classes:   []string{"Store"},
+			variables: []string{"count", "Title"},
+			imports:   []string{"System.Text", "System.IO"},
+			comments:  1,
+		},
+		{
+			language: "kotlin",
+			code: `package app
+import kotlin.math.max
+// Store keeps items
+class Store(private val client: Client) : Base(), Saver {
+    val count: Int = 0
+    fun save(key: String): Boolean { val local = 1; return true }
+}
+fun helper(k: String): Boolean = true
+`,
+			functions: map[string]string{"save": "Store", "helper": ""},
+			classes:   []string{"Store"},
+			variables: []string{"count"},
+			imports:   []string{"kotlin.math.max"},
+			comments:  1,
+		},
+		{
+			language: "ruby",
+			code: `require 'json'
+# Store keeps items
+module App
+  class Store < Base
+    MAX = 10
+    def initialize(name)
+      @name = name
+    end
+  end
+end
+`,
+			functions: map[string]string{"initialize": "Store"},
+			classes:   []string{"App", "Store"},
+			variables: []string{"MAX"},
+			imports:   []string{"json"},
+			comments:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			parser := NewTreeSitterParser(tt.language)
+			if parser == nil {
+				t.Skipf("Tree-sitter %s parser not available", tt.language)
+			}
+
+			file, err := parser.Parse(tt.code, "example")
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+
+			functions := make(map[string]string)
+			for _, function := range file.Functions {
+				functions[function.Name] = function.ClassName
+			}
+			for name, className := range tt.functions {
+				got, ok := functions[name]
+				if !ok {
+					t.Errorf("Expected function %s, got %v", name, functions)
+				} else if got != className {
+					t.Errorf("Expected %s to belong to %q, got %q", name, className, got)
+				}
+			}
+			if _, ok := functions["prototype"]; ok {
+				t.Error("Expected prototypes to be left out")
+			}
+
+			names := func(count int, name func(int) string) map[string]bool {
+				set := make(map[string]bool, count)
+				for i := 0; i < count; i++ {
+					set[name(i)] = true
+				}
+				return set
+			}
+			classes := names(len(file.Classes), func(i int) string { return file.Classes[i].Name })
+			for _, name := range tt.classes {
+				if !classes[name] {
+					t.Errorf("Expected class %s, got %v", name, classes)
+				}
+			}
+			variables := names(len(file.Variables), func(i int) string { return file.Variables[i].Name })
+			for _, name := range tt.variables {
+				if !variables[name] {
+					t.Errorf("Expected variable %s, got %v", name, variables)
+				}
+			}
+			if variables["local"] {
+				t.Error("Expected local variables to be left out")
+			}
+			imports := names(len(file.Imports), func(i int) string { return file.Imports[i].Module })
+			for _, module := range tt.imports {
+				if !imports[module] {
+					t.Errorf("Expected import %s, got %v", module, imports)
+				}
+			}
+			if len(file.Comments) < tt.comments {
+				t.Errorf("Expected at least %d comments, got %d", tt.comments, len(file.Comments))
+			}
+		})
+	}
+}
+
+func TestTreeSitterTypeScriptParser(t *testing.T) {
+	parser := NewTreeSitterParser("typescript")
+	if parser == nil {
+		t.Skip("Tree-sitter TypeScript parser not available")
+	}
+
+	tsCode := `import * as path from 'path';
+/** A node */
+export interface Node<T> extends Base {
+  id: string;
+  visit(v: Visitor): void;
+}
+export type Id = string | number;
+export enum Color { Red, Green = 2 }
+@Injectable()
+export class Store<T> extends Base implements Saver {
+  private count: number = 0;
+  public save<K>(key: K): boolean { return this.db.put(key); }
+}
+export const handler = async (req: Request): Promise<void> => {};
+const MAX: number = 10;
+`
+
+	file, err := parser.Parse(tsCode, "store.ts")
+	if err != nil {
+		t.Fatalf("Failed to parse TypeScript code: %v", err)
+	}
+
+	if len(file.TypeDeclarations) != 2 {
+		t.Fatalf("Expected 2 type declarations, got %+v", file.TypeDeclarations)
+	}
+	node := file.TypeDeclarations[0]
+	if node.Name != "Node" || node.Kind != "interface" || node.TypeParameters != "<T>" || !node.IsExported {
+		t.Errorf("Unexpected interface: %+v", node)
+	}
+	if len(node.Extends) != 1 || node.Extends[0] != "Base" || len(node.Members) != 2 {
+		t.Errorf("Expected Node to extend Base with 2 members, got %+v", node)
+	}
+	if alias := file.TypeDeclarations[1]; alias.Name != "Id" || alias.Kind != "type_alias" {
+		t.Errorf("Unexpected type alias: %+v", alias)
+	}
+
+	classes := make(map[string]types.Class)
+	for _, class := range file.Classes {
+		classes[class.Name] = class
+	}
+	if color := classes["Color"]; len(color.Fields) != 2 || color.Fields[1].Value != "2" {
+		t.Errorf("Expected Color enum with 2 members, got %+v", color)
+	}
+	store := classes["Store"]
+	if store.SuperClass != "Base" || len(store.Interfaces) != 1 || store.Interfaces[0] != "Saver" {
+		t.Errorf("Unexpected Store heritage: %+v", store)
+	}
+	if len(store.Annotations) != 1 || store.Annotations[0] != "Injectable()" {
+		t.Errorf("Expected the Injectable decorator, got %v", store.Annotations)
+	}
+
+	functions := make(map[string]types.Function)
+	for _, function := range file.Functions {
+		functions[function.Name] = function
+	}
+	if save := functions["save"]; !save.IsMethod || save.ClassName != "Store" || save.ReturnType != "boolean" {
+		t.Errorf("Unexpected save method: %+v", save)
+	}
+	if handler, ok := functions["handler"]; !ok || handler.ReturnType != "Promise<void>" {
+		t.Errorf("Expected the handler arrow function, got %+v", handler)
+	}
+
+	var foundMax bool
+	for _, variable := range file.Variables {
+		if variable.Name == "MAX" {
+			foundMax = variable.IsConstant && variable.Type == "number"
+		}
+	}
+	if !foundMax {
+		t.Errorf("Expected constant MAX of type number, got %+v", file.Variables)
+	}
+
+	if len(file.Imports) != 1 || file.Imports[0].Alias != "path" || !file.Imports[0].IsWildcard {
+		t.Errorf("Unexpected imports: %+v", file.Imports)
+	}
+
+	if _, ok := findReference(file.References, "Visitor", "type"); !ok {
+		t.Errorf("Expected a type reference to Visitor, got %+v", file.References)
+	}
+	for _, reference := range file.References {
+		if reference.Kind == "type" && (reference.Name == "Node" || reference.Name == "T") && reference.Line == 3 {
+			t.Errorf("Declared names must not be references: %+v", reference)
+		}
+	}
+}
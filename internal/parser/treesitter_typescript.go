@@ -0,0 +1,273 @@
+package parser
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// parseTypeScriptCode extracts TypeScript-specific metadata using
+// tree-sitter. Besides what JavaScript files yield, interfaces and type
+// aliases become type declarations, enums become classes with their members
+// as fields, and decorators are kept as annotations.
+func (p *TreeSitterParser) parseTypeScriptCode(node *sitter.Node, source []byte, file *types.CodeFile) {
+	p.walkNode(node, source, func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_declaration", "generator_function_declaration":
+			function := p.extractTypeScriptFunction(n, source)
+			file.Functions = append(file.Functions, function)
+
+		case "method_definition", "abstract_method_signature":
+			function := p.extractTypeScriptFunction(n, source)
+			function.IsMethod = true
+			function.ClassName = p.enclosingName(n, source, "class_declaration", "abstract_class_declaration", "class")
+			function.Visibility = p.typeScriptAccessibility(n, source)
+			file.Functions = append(file.Functions, function)
+
+		case "class_declaration", "abstract_class_declaration":
+			class := p.extractTypeScriptClass(n, source)
+			file.Classes = append(file.Classes, class)
+
+		case "enum_declaration":
+			class := p.extractTypeScriptEnum(n, source)
+			file.Classes = append(file.Classes, class)
+
+		case "interface_declaration", "type_alias_declaration":
+			declaration := p.extractTypeScriptTypeDeclaration(n, source)
+			file.TypeDeclarations = append(file.TypeDeclarations, declaration)
+
+		case "lexical_declaration", "variable_declaration":
+			functions, variables := p.extractTypeScriptDeclarations(n, source)
+			file.Functions = append(file.Functions, functions...)
+			file.Variables = append(file.Variables, variables...)
+
+		case "public_field_definition":
+			variable := types.Variable{
+				Name:       p.getFieldText(n, "name", source),
+				Type:       typeAnnotation(p.getFieldText(n, "type", source)),
+				Value:      p.getFieldText(n, "value", source),
+				StartLine:  p.getLineNumber(n),
+				EndLine:    p.getEndLineNumber(n),
+				Visibility: p.typeScriptAccessibility(n, source),
+				IsConstant: firstChildOfType(n, "readonly") != nil,
+				Scope:      "class",
+			}
+			file.Variables = append(file.Variables, variable)
+
+		case "import_statement":
+			imports := p.extractJavaScriptImports(n, source)
+			if clause := firstChildOfType(n, "import_clause"); clause != nil && len(imports) > 0 {
+				if namespace := firstChildOfType(clause, "namespace_import"); namespace != nil {
+					imports[0].IsWildcard = true
+					if alias := firstChildOfType(namespace, "identifier"); alias != nil {
+						imports[0].Alias = p.getNodeText(alias, source)
+					}
+				} else if defaultImport := firstChildOfType(clause, "identifier"); defaultImport != nil {
+					imports[0].Alias = p.getNodeText(defaultImport, source)
+				}
+			}
+			file.Imports = append(file.Imports, imports...)
+
+		case "comment":
+			comment := p.extractComment(n, source)
+			file.Comments = append(file.Comments, comment)
+		}
+	})
+}
+
+// typeAnnotation strips the leading colon of a type annotation
+func typeAnnotation(annotation string) string {
+	return strings.TrimSpace(strings.TrimPrefix(annotation, ":"))
+}
+
+// isExported reports whether a declaration is wrapped in an export statement
+func isExported(node *sitter.Node) bool {
+	parent := node.Parent()
+	return parent != nil && parent.Type() == "export_statement"
+}
+
+// typeScriptAccessibility returns a class member's accessibility modifier,
+// which defaults to public
+func (p *TreeSitterParser) typeScriptAccessibility(node *sitter.Node, source []byte) string {
+	if modifier := firstChildOfType(node, "accessibility_modifier"); modifier != nil {
+		return p.getNodeText(modifier, source)
+	}
+	return "public"
+}
+
+// decorators returns the decorators of a declaration without their @. The
+// decorators of an exported class belong to its export statement.
+func (p *TreeSitterParser) decorators(node *sitter.Node, source []byte) []string {
+	var annotations []string
+	collect := func(holder *sitter.Node) {
+		for i := 0; i < int(holder.ChildCount()); i++ {
+			if child := holder.Child(i); child.Type() == "decorator" {
+				annotations = append(annotations, strings.TrimPrefix(p.getNodeText(child, source), "@"))
+			}
+		}
+	}
+
+	if isExported(node) {
+		collect(node.Parent())
+	}
+	collect(node)
+	return annotations
+}
+
+// extractTypeScriptFunction extracts a TypeScript function or method. Type
+// parameters are part of the signature.
+func (p *TreeSitterParser) extractTypeScriptFunction(node *sitter.Node, source []byte) types.Function {
+	function := types.Function{
+		Name:        p.getFieldText(node, "name", source),
+		StartLine:   p.getLineNumber(node),
+		EndLine:     p.getEndLineNumber(node),
+		Parameters:  p.namedChildTexts(node.ChildByFieldName("parameters"), source),
+		ReturnType:  typeAnnotation(p.getFieldText(node, "return_type", source)),
+		Signature:   p.getNodeText(node, source),
+		Annotations: p.decorators(node, source),
+	}
+	if isExported(node) {
+		function.Visibility = "public"
+	}
+	return function
+}
+
+// extractTypeScriptClass extracts a TypeScript class with its superclass,
+// implemented interfaces and decorators
+func (p *TreeSitterParser) extractTypeScriptClass(node *sitter.Node, source []byte) types.Class {
+	class := types.Class{
+		Name:        p.getFieldText(node, "name", source),
+		StartLine:   p.getLineNumber(node),
+		EndLine:     p.getEndLineNumber(node),
+		Annotations: p.decorators(node, source),
+	}
+	if isExported(node) {
+		class.Visibility = "public"
+	}
+
+	if heritage := firstChildOfType(node, "class_heritage"); heritage != nil {
+		if extends := firstChildOfType(heritage, "extends_clause"); extends != nil {
+			class.SuperClass = p.getFieldText(extends, "value", source)
+		}
+		if implements := firstChildOfType(heritage, "implements_clause"); implements != nil {
+			class.Interfaces = p.namedChildTexts(implements, source)
+		}
+	}
+
+	return class
+}
+
+// extractTypeScriptEnum extracts an enum as a class whose fields are its
+// members
+func (p *TreeSitterParser) extractTypeScriptEnum(node *sitter.Node, source []byte) types.Class {
+	class := types.Class{
+		Name:      p.getFieldText(node, "name", source),
+		StartLine: p.getLineNumber(node),
+		EndLine:   p.getEndLineNumber(node),
+	}
+	if isExported(node) {
+		class.Visibility = "public"
+	}
+
+	if body := node.ChildByFieldName("body"); body != nil {
+		for i := 0; i < int(body.NamedChildCount()); i++ {
+			member := body.NamedChild(i)
+			field := types.Variable{
+				StartLine:  p.getLineNumber(member),
+				EndLine:    p.getEndLineNumber(member),
+				IsConstant: true,
+				Scope:      "class",
+			}
+			switch member.Type() {
+			case "property_identifier", "string":
+				field.Name = p.getNodeText(member, source)
+			case "enum_assignment":
+				field.Name = p.getFieldText(member, "name", source)
+				field.Value = p.getFieldText(member, "value", source)
+			default:
+				continue
+			}
+			class.Fields = append(class.Fields, field)
+		}
+	}
+
+	return class
+}
+
+// extractTypeScriptTypeDeclaration extracts an interface or type alias
+func (p *TreeSitterParser) extractTypeScriptTypeDeclaration(node *sitter.Node, source []byte) types.TypeDeclaration {
+	declaration := types.TypeDeclaration{
+		Name:           p.getFieldText(node, "name", source),
+		Kind:           "type_alias",
+		StartLine:      p.getLineNumber(node),
+		EndLine:        p.getEndLineNumber(node),
+		TypeParameters: p.getFieldText(node, "type_parameters", source),
+		Definition:     p.getNodeText(node, source),
+		IsExported:     isExported(node),
+	}
+
+	if node.Type() == "interface_declaration" {
+		declaration.Kind = "interface"
+		if extends := firstChildOfType(node, "extends_type_clause"); extends != nil {
+			declaration.Extends = p.namedChildTexts(extends, source)
+		}
+		declaration.Members = p.namedChildTexts(node.ChildByFieldName("body"), source)
+	}
+
+	return declaration
+}
+
+// extractTypeScriptDeclarations extracts the module-level variables of a
+// declaration. Variables holding arrow functions or function expressions are
+// recorded as functions named after the variable.
+func (p *TreeSitterParser) extractTypeScriptDeclarations(node *sitter.Node, source []byte) ([]types.Function, []types.Variable) {
+	parent := node.Parent()
+	if isExported(node) {
+		parent = parent.Parent()
+	}
+	if parent == nil || parent.Type() != "program" {
+		return nil, nil
+	}
+
+	var functions []types.Function
+	var variables []types.Variable
+	isConstant := node.ChildCount() > 0 && node.Child(0).Type() == "const"
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		declarator := node.NamedChild(i)
+		if declarator.Type() != "variable_declarator" {
+			continue
+		}
+		name := p.getFieldText(declarator, "name", source)
+
+		if value := declarator.ChildByFieldName("value"); value != nil &&
+			(value.Type() == "arrow_function" || value.Type() == "function_expression" || value.Type() == "function") {
+			function := p.extractTypeScriptFunction(value, source)
+			function.Name = name
+			function.StartLine = p.getLineNumber(declarator)
+			if isExported(node) {
+				function.Visibility = "public"
+			}
+			functions = append(functions, function)
+			continue
+		}
+
+		variable := types.Variable{
+			Name:       name,
+			Type:       typeAnnotation(p.getFieldText(declarator, "type", source)),
+			Value:      p.getFieldText(declarator, "value", source),
+			StartLine:  p.getLineNumber(declarator),
+			EndLine:    p.getEndLineNumber(declarator),
+			IsConstant: isConstant,
+			IsGlobal:   true,
+		}
+		if isExported(node) {
+			variable.Visibility = "public"
+		}
+		variables = append(variables, variable)
+	}
+
+	return functions, variables
+}
@@ -0,0 +1,343 @@
+package quality
+
+import (
+	"math"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Complexity ratings by cyclomatic complexity
+const (
+	RatingLow      = "low"       // 1 to 5
+	RatingModerate = "moderate"  // 6 to 10
+	RatingHigh     = "high"      // 11 to 20
+	RatingVeryHigh = "very_high" // More than 20
+)
+
+// Rate rates a cyclomatic complexity
+func Rate(cyclomatic int) string {
+	switch {
+	case cyclomatic <= 5:
+		return RatingLow
+	case cyclomatic <= 10:
+		return RatingModerate
+	case cyclomatic <= 20:
+		return RatingHigh
+	default:
+		return RatingVeryHigh
+	}
+}
+
+// Halstead holds the Halstead measures of a function, computed from its
+// tokens: identifiers and literals are operands, everything else but
+// comments and closing brackets is an operator
+type Halstead struct {
+	DistinctOperators int     `json:"distinct_operators"`
+	DistinctOperands  int     `json:"distinct_operands"`
+	Operators         int     `json:"operators"`
+	Operands          int     `json:"operands"`
+	Vocabulary        int     `json:"vocabulary"`
+	Length            int     `json:"length"`
+	Volume            float64 `json:"volume"`
+	Difficulty        float64 `json:"difficulty"`
+	Effort            float64 `json:"effort"`
+	TimeSeconds       float64 `json:"time_seconds"`
+	Bugs              float64 `json:"bugs"`
+}
+
+// Complexity holds the complexity measures of a function
+type Complexity struct {
+	Function
+	Cyclomatic      int      `json:"cyclomatic"`
+	Cognitive       int      `json:"cognitive"`
+	MaxNesting      int      `json:"max_nesting"`
+	Halstead        Halstead `json:"halstead"`
+	Maintainability float64  `json:"maintainability_index"` // 0 to 100, higher is easier to maintain
+	Rating          string   `json:"rating"`
+}
+
+// decisionTypes are the node types that add a path through a function, by
+// language. Boolean operators and non-default switch cases are counted
+// separately.
+var decisionTypes = map[string]map[string]bool{
+	"go": {
+		"if_statement": true, "for_statement": true, "expression_case": true, "type_case": true,
+		"communication_case": true,
+	},
+	"python": {
+		"if_statement": true, "elif_clause": true, "for_statement": true, "while_statement": true,
+		"except_clause": true, "case_clause": true, "conditional_expression": true,
+		"for_in_clause": true, "if_clause": true,
+	},
+	"javascript": {
+		"if_statement": true, "for_statement": true, "for_in_statement": true, "while_statement": true,
+		"do_statement": true, "switch_case": true, "catch_clause": true, "ternary_expression": true,
+	},
+	"typescript": {
+		"if_statement": true, "for_statement": true, "for_in_statement": true, "while_statement": true,
+		"do_statement": true, "switch_case": true, "catch_clause": true, "ternary_expression": true,
+	},
+	"java": {
+		"if_statement": true, "for_statement": true, "enhanced_for_statement": true, "while_statement": true,
+		"do_statement": true, "switch_label": true, "catch_clause": true, "ternary_expression": true,
+	},
+}
+
+// structureTypes are the node types cognitive complexity charges for
+// according to how deeply they are nested, besides if statements
+var structureTypes = map[string]bool{
+	"for_statement": true, "for_in_statement": true, "enhanced_for_statement": true, "while_statement": true,
+	"do_statement": true, "expression_switch_statement": true, "type_switch_statement": true,
+	"select_statement": true, "switch_statement": true, "switch_expression": true, "match_statement": true,
+	"catch_clause": true, "except_clause": true, "ternary_expression": true, "conditional_expression": true,
+}
+
+// lambdaTypes are anonymous functions, which nest the code inside them
+var lambdaTypes = map[string]bool{
+	"func_literal": true, "arrow_function": true, "function_expression": true, "function": true,
+	"lambda": true, "lambda_expression": true,
+}
+
+// stringTypes are literals Halstead counts as one operand
+var stringTypes = map[string]bool{
+	"interpreted_string_literal": true, "raw_string_literal": true, "rune_literal": true, "string": true,
+	"template_string": true, "string_literal": true, "character_literal": true, "text_block": true,
+}
+
+// constantTypes are keyword literals Halstead counts as operands
+var constantTypes = map[string]bool{
+	"true": true, "false": true, "nil": true, "null": true, "none": true, "undefined": true,
+	"null_literal": true, "iota": true,
+}
+
+// Measure returns the complexity of every function declared in content, in
+// the order they appear. Anonymous functions count towards the function
+// they are written in; named functions nested in another are measured on
+// their own.
+func Measure(language, content string) ([]Complexity, error) {
+	src, err := parse(language, content)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+
+	functions := src.functions()
+	measured := make([]Complexity, 0, len(functions))
+	for _, function := range functions {
+		complexity := Complexity{
+			Function:   function,
+			Cyclomatic: 1 + src.decisions(function.node),
+			Cognitive:  src.cognitive(function.node, 0),
+			Halstead:   src.halstead(function.node),
+		}
+		if function.body != nil {
+			complexity.MaxNesting, _ = src.nesting(function.body, 0)
+		}
+		complexity.Maintainability = maintainability(complexity.Halstead.Volume, complexity.Cyclomatic, function.Lines)
+		complexity.Rating = Rate(complexity.Cyclomatic)
+		measured = append(measured, complexity)
+	}
+	return measured, nil
+}
+
+// nested reports whether node is a named function inside the one being
+// measured
+func (s *source) nested(node *sitter.Node) bool {
+	if node.Type() == "variable_declarator" {
+		value := node.ChildByFieldName("value")
+		return value != nil && lambdaTypes[value.Type()]
+	}
+	return functionTypes[s.language][node.Type()]
+}
+
+// logicalOperator returns the operator of a boolean && or || expression,
+// or "" for any other node
+func (s *source) logicalOperator(node *sitter.Node) string {
+	if node.Type() != "binary_expression" && node.Type() != "boolean_operator" {
+		return ""
+	}
+	operator := node.ChildByFieldName("operator")
+	if operator == nil {
+		return ""
+	}
+	switch op := operator.Type(); op {
+	case "&&", "||", "and", "or", "??":
+		return op
+	}
+	return ""
+}
+
+// decisions counts the decision points under node
+func (s *source) decisions(node *sitter.Node) int {
+	count := 0
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if s.nested(child) {
+			continue
+		}
+		switch {
+		case child.Type() == "switch_label" && strings.HasPrefix(child.Content(s.content), "default"):
+		case decisionTypes[s.language][child.Type()], s.logicalOperator(child) != "":
+			count++
+		}
+		count += s.decisions(child)
+	}
+	return count
+}
+
+// cognitive computes the cognitive complexity of the code under node at a
+// nesting level: each if, loop, switch, catch and conditional costs one
+// plus its nesting, else branches and jumps to labels cost one, and so does
+// each run of the same boolean operator
+func (s *source) cognitive(node *sitter.Node, nesting int) int {
+	total := 0
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if s.nested(child) {
+			continue
+		}
+		childNesting := nesting
+		switch typ := child.Type(); {
+		case typ == "if_statement":
+			if s.isNesting(child) {
+				total += 1 + nesting
+				childNesting++
+			} else {
+				total++ // An else if continues the chain at its level
+			}
+			if alternative := child.ChildByFieldName("alternative"); alternative != nil && s.language != "python" {
+				if alternative.Type() != "if_statement" && alternative.Type() != "else_clause" {
+					total++ // A Go or Java else block
+				}
+			}
+		case typ == "elif_clause":
+			total++
+		case typ == "else_clause":
+			parent := child.Parent()
+			first := child.NamedChild(0)
+			if parent != nil && parent.Type() == "if_statement" && (first == nil || first.Type() != "if_statement") {
+				total++
+			}
+		case structureTypes[typ]:
+			total += 1 + nesting
+			childNesting++
+		case lambdaTypes[typ]:
+			childNesting++
+		case typ == "goto_statement":
+			total++
+		case typ == "break_statement" || typ == "continue_statement":
+			if child.NamedChildCount() > 0 {
+				total++ // Jumps to a label
+			}
+		default:
+			if operator := s.logicalOperator(child); operator != "" {
+				if parent := child.Parent(); parent == nil || s.logicalOperator(parent) != operator {
+					total++
+				}
+			}
+		}
+		total += s.cognitive(child, childNesting)
+	}
+	return total
+}
+
+// halstead counts the operators and operands of the tokens under node
+func (s *source) halstead(node *sitter.Node) Halstead {
+	operators := make(map[string]int)
+	operands := make(map[string]int)
+	var walk func(node *sitter.Node, root bool)
+	walk = func(node *sitter.Node, root bool) {
+		typ := node.Type()
+		if !root && s.nested(node) {
+			return
+		}
+		switch {
+		case typ == "comment" || typ == "line_comment" || typ == "block_comment":
+			return
+		case stringTypes[typ] || numberTypes[s.language][typ] || constantTypes[typ]:
+			operands[node.Content(s.content)]++
+			return
+		case node.ChildCount() == 0:
+			text := node.Content(s.content)
+			switch {
+			case text == "" || text == ")" || text == "]" || text == "}":
+			case strings.Contains(typ, "identifier"):
+				operands[text]++
+			default:
+				operators[text]++
+			}
+			return
+		}
+		for i := 0; i < int(node.ChildCount()); i++ {
+			walk(node.Child(i), false)
+		}
+	}
+	walk(node, true)
+
+	h := Halstead{DistinctOperators: len(operators), DistinctOperands: len(operands)}
+	for _, count := range operators {
+		h.Operators += count
+	}
+	for _, count := range operands {
+		h.Operands += count
+	}
+	h.Vocabulary = h.DistinctOperators + h.DistinctOperands
+	h.Length = h.Operators + h.Operands
+	if h.Vocabulary > 0 {
+		h.Volume = float64(h.Length) * math.Log2(float64(h.Vocabulary))
+	}
+	if h.DistinctOperands > 0 {
+		h.Difficulty = float64(h.DistinctOperators) / 2 * float64(h.Operands) / float64(h.DistinctOperands)
+	}
+	h.Effort = h.Difficulty * h.Volume
+	h.TimeSeconds = round(h.Effort / 18)
+	h.Bugs = round(h.Volume / 3000)
+	h.Volume, h.Difficulty, h.Effort = round(h.Volume), round(h.Difficulty), round(h.Effort)
+	return h
+}
+
+// maintainability computes the maintainability index of a function from
+// its Halstead volume, cyclomatic complexity and lines, scaled to 0-100
+func maintainability(volume float64, cyclomatic, lines int) float64 {
+	index := 171 - 5.2*math.Log(math.Max(volume, 1)) - 0.23*float64(cyclomatic) - 16.2*math.Log(math.Max(float64(lines), 1))
+	return round(math.Max(0, index*100/171))
+}
+
+// round rounds to two decimals
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// Summary aggregates the complexity of a set of functions
+type Summary struct {
+	Functions         int            `json:"functions"`
+	TotalCyclomatic   int            `json:"total_cyclomatic"`
+	AverageCyclomatic float64        `json:"average_cyclomatic"`
+	MaxCyclomatic     int            `json:"max_cyclomatic"`
+	TotalCognitive    int            `json:"total_cognitive"`
+	AverageCognitive  float64        `json:"average_cognitive"`
+	MaxCognitive      int            `json:"max_cognitive"`
+	Ratings           map[string]int `json:"ratings"` // Functions by rating
+}
+
+// NewSummary returns an empty summary
+func NewSummary() *Summary {
+	return &Summary{Ratings: map[string]int{RatingLow: 0, RatingModerate: 0, RatingHigh: 0, RatingVeryHigh: 0}}
+}
+
+// Add adds a function to the summary
+func (s *Summary) Add(cyclomatic, cognitive int) {
+	s.Functions++
+	s.TotalCyclomatic += cyclomatic
+	s.TotalCognitive += cognitive
+	if cyclomatic > s.MaxCyclomatic {
+		s.MaxCyclomatic = cyclomatic
+	}
+	if cognitive > s.MaxCognitive {
+		s.MaxCognitive = cognitive
+	}
+	s.AverageCyclomatic = round(float64(s.TotalCyclomatic) / float64(s.Functions))
+	s.AverageCognitive = round(float64(s.TotalCognitive) / float64(s.Functions))
+	s.Ratings[Rate(cyclomatic)]++
+}
@@ -0,0 +1,135 @@
+package quality
+
+import "testing"
+
+func measure(t *testing.T, language, content string) []Complexity {
+	t.Helper()
+	measured, err := Measure(language, content)
+	if err != nil {
+		t.Skipf("Tree-sitter %s parser not available: %v", language, err)
+	}
+	return measured
+}
+
+func TestMeasureGo(t *testing.T) {
+	source := `package main
+
+func classify(n int, ok bool) string {
+	if n < 0 && ok {
+		return "negative"
+	} else if n == 0 {
+		return "zero"
+	} else {
+		for i := 0; i < n; i++ {
+			if i%2 == 0 || i%3 == 0 {
+				continue
+			}
+		}
+	}
+	switch n {
+	case 1:
+		return "one"
+	case 2:
+		return "two"
+	default:
+		return "many"
+	}
+}
+
+func add(a, b int) int { return a + b }
+`
+	measured := measure(t, "go", source)
+	if len(measured) != 2 {
+		t.Fatalf("Expected 2 functions, got %+v", measured)
+	}
+
+	classify := measured[0]
+	if classify.Cyclomatic != 9 {
+		t.Errorf("Expected cyclomatic complexity 9 for classify, got %d", classify.Cyclomatic)
+	}
+	// if 1, && 1, else if 1, else 1, for 1+1, nested if 1+2, || 1, switch 1
+	if classify.Cognitive != 11 {
+		t.Errorf("Expected cognitive complexity 11 for classify, got %d", classify.Cognitive)
+	}
+	if classify.MaxNesting != 3 || classify.Rating != RatingModerate {
+		t.Errorf("Unexpected nesting or rating: %+v", classify)
+	}
+
+	add := measured[1]
+	if add.Cyclomatic != 1 || add.Cognitive != 0 || add.Rating != RatingLow {
+		t.Errorf("Unexpected complexity for add: %+v", add)
+	}
+	h := add.Halstead
+	if h.DistinctOperators != 6 || h.Operators != 6 || h.DistinctOperands != 4 || h.Operands != 7 {
+		t.Errorf("Unexpected Halstead counts for add: %+v", h)
+	}
+	if h.Vocabulary != 10 || h.Length != 13 || h.Volume != 43.19 {
+		t.Errorf("Unexpected Halstead measures for add: %+v", h)
+	}
+	if add.Maintainability <= classify.Maintainability || add.Maintainability > 100 {
+		t.Errorf("Expected add to be more maintainable than classify, got %.2f and %.2f", add.Maintainability, classify.Maintainability)
+	}
+}
+
+func TestMeasurePython(t *testing.T) {
+	source := `def check(items):
+    for item in items:
+        if item and item.ok:
+            pass
+        elif item is None:
+            pass
+        else:
+            pass
+    return [x for x in items if x]
+`
+	measured := measure(t, "python", source)
+	if len(measured) != 1 {
+		t.Fatalf("Expected 1 function, got %+v", measured)
+	}
+	if measured[0].Cyclomatic != 7 || measured[0].Cognitive != 6 {
+		t.Errorf("Expected cyclomatic 7 and cognitive 6, got %d and %d", measured[0].Cyclomatic, measured[0].Cognitive)
+	}
+}
+
+func TestMeasureJavaScript(t *testing.T) {
+	source := `const pick = (a) => a ? 1 : 2;
+
+function load(url) {
+  const ready = url && url.length > 0;
+  try {
+    return fetch(url).then((res) => res.ok ? res.json() : null);
+  } catch (err) {
+    return null;
+  }
+}
+`
+	measured := measure(t, "javascript", source)
+	if len(measured) != 2 {
+		t.Fatalf("Expected 2 functions, got %+v", measured)
+	}
+	if measured[0].Name != "pick" || measured[0].Cyclomatic != 2 || measured[0].Cognitive != 1 {
+		t.Errorf("Unexpected complexity for pick: %+v", measured[0])
+	}
+	// &&, the ternary in the callback and the catch; the callback nests the
+	// ternary one level deeper
+	if measured[1].Cyclomatic != 4 || measured[1].Cognitive != 1+2+1 {
+		t.Errorf("Unexpected complexity for load: cyclomatic %d, cognitive %d", measured[1].Cyclomatic, measured[1].Cognitive)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	summary := NewSummary()
+	summary.Add(1, 0)
+	summary.Add(12, 20)
+	summary.Add(4, 3)
+
+	if summary.Functions != 3 || summary.TotalCyclomatic != 17 || summary.MaxCyclomatic != 12 || summary.MaxCognitive != 20 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+	if summary.AverageCyclomatic != 5.67 || summary.AverageCognitive != 7.67 {
+		t.Errorf("Unexpected averages: %+v", summary)
+	}
+	if summary.Ratings[RatingLow] != 2 || summary.Ratings[RatingHigh] != 1 || summary.Ratings[RatingVeryHigh] != 0 {
+		t.Errorf("Unexpected ratings: %v", summary.Ratings)
+	}
+}
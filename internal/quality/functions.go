@@ -0,0 +1,297 @@
+// Package quality measures source files from their tree-sitter syntax
+// trees: the functions they declare, how long and deeply nested those are,
+// and the code smells that follow from it.
+package quality
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/my-mcp/code-indexer/internal/parser"
+)
+
+// Function is a function, method or constructor declared in a file
+type Function struct {
+	Name       string `json:"name"`
+	Container  string `json:"container,omitempty"` // Class or receiver type of a method
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Lines      int    `json:"lines"`
+	Parameters int    `json:"parameters"`
+
+	node *sitter.Node // Declaration, valid while the tree is open
+	body *sitter.Node
+}
+
+// QualifiedName returns the name prefixed with its container, such as
+// Store.Load for a method
+func (f Function) QualifiedName() string {
+	if f.Container == "" {
+		return f.Name
+	}
+	return f.Container + "." + f.Name
+}
+
+// Class is a class, or a Go type with methods, and the methods declared
+// in it
+type Class struct {
+	Name      string `json:"name"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Methods   int    `json:"methods"`
+}
+
+// Supported reports whether files of a language can be analyzed
+func Supported(language string) bool {
+	_, ok := functionTypes[language]
+	return ok
+}
+
+// Languages returns the languages that can be analyzed, sorted
+func Languages() []string {
+	languages := make([]string, 0, len(functionTypes))
+	for language := range functionTypes {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// functionTypes are the node types of function declarations by language
+var functionTypes = map[string]map[string]bool{
+	"go":         {"function_declaration": true, "method_declaration": true},
+	"python":     {"function_definition": true},
+	"javascript": {"function_declaration": true, "generator_function_declaration": true, "method_definition": true, "variable_declarator": true},
+	"typescript": {"function_declaration": true, "generator_function_declaration": true, "method_definition": true, "variable_declarator": true},
+	"java":       {"method_declaration": true, "constructor_declaration": true},
+}
+
+// classTypes are the node types of class declarations by language. Go
+// methods belong to the type named by their receiver instead.
+var classTypes = map[string]map[string]bool{
+	"python":     {"class_definition": true},
+	"javascript": {"class_declaration": true, "class": true},
+	"typescript": {"class_declaration": true, "abstract_class_declaration": true, "class": true},
+	"java":       {"class_declaration": true, "interface_declaration": true, "enum_declaration": true, "record_declaration": true},
+}
+
+// parameterSkips are the parameter list children that are not parameters
+var parameterSkips = map[string]bool{
+	"comment":              true,
+	"receiver_parameter":   true, // Java's explicit this
+	"keyword_separator":    true, // Python's bare *
+	"positional_separator": true, // Python's /
+}
+
+// source is a parsed file
+type source struct {
+	language string
+	tree     *sitter.Tree
+	content  []byte
+	lines    int
+}
+
+// parse parses content with the tree-sitter grammar of language; the
+// caller must close the tree
+func parse(language, content string) (*source, error) {
+	if !Supported(language) {
+		return nil, fmt.Errorf("%s files are not supported", language)
+	}
+	p := parser.NewTreeSitterParser(language)
+	if p == nil {
+		return nil, fmt.Errorf("no tree-sitter grammar for %s", language)
+	}
+	tree, err := p.ParseTree(content)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	return &source{language: language, tree: tree, content: []byte(content), lines: lines}, nil
+}
+
+// close releases the syntax tree
+func (s *source) close() {
+	s.tree.Close()
+}
+
+// Functions returns the functions and methods declared in content, in the
+// order they appear. Anonymous functions are part of the function they are
+// written in.
+func Functions(language, content string) ([]Function, error) {
+	src, err := parse(language, content)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+	return src.functions(), nil
+}
+
+// functions walks the tree for function declarations
+func (s *source) functions() []Function {
+	functions := []Function{}
+	var walk func(node *sitter.Node, container string)
+	walk = func(node *sitter.Node, container string) {
+		if classTypes[s.language][node.Type()] {
+			if name := node.ChildByFieldName("name"); name != nil {
+				container = name.Content(s.content)
+			}
+		}
+		if functionTypes[s.language][node.Type()] {
+			if function, ok := s.function(node, container); ok {
+				functions = append(functions, function)
+			}
+		}
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			walk(node.NamedChild(i), container)
+		}
+	}
+	walk(s.tree.RootNode(), "")
+	return functions
+}
+
+// function describes a declaration node, reporting false for variables
+// that do not hold a function
+func (s *source) function(node *sitter.Node, container string) (Function, bool) {
+	declaration := node
+	if node.Type() == "variable_declarator" {
+		value := node.ChildByFieldName("value")
+		if value == nil || (value.Type() != "arrow_function" && value.Type() != "function_expression" && value.Type() != "function") {
+			return Function{}, false
+		}
+		declaration = value
+	}
+	name := node.ChildByFieldName("name")
+	if name == nil {
+		return Function{}, false
+	}
+	if s.language == "go" && node.Type() == "method_declaration" {
+		container = goReceiverType(node.ChildByFieldName("receiver"), s.content)
+	}
+
+	start, end := int(node.StartPoint().Row)+1, int(node.EndPoint().Row)+1
+	function := Function{
+		Name:      name.Content(s.content),
+		Container: container,
+		StartLine: start,
+		EndLine:   end,
+		Lines:     end - start + 1,
+		node:      declaration,
+		body:      declaration.ChildByFieldName("body"),
+	}
+	if parameters := declaration.ChildByFieldName("parameters"); parameters != nil {
+		function.Parameters = s.countParameters(parameters, container != "")
+	} else if declaration.ChildByFieldName("parameter") != nil {
+		function.Parameters = 1 // An arrow function's single bare parameter
+	}
+	return function, true
+}
+
+// countParameters counts the parameters in a parameter list. Go lists
+// several names in one declaration; a Python method's self or cls is not
+// counted.
+func (s *source) countParameters(list *sitter.Node, method bool) int {
+	count := 0
+	for i := 0; i < int(list.NamedChildCount()); i++ {
+		parameter := list.NamedChild(i)
+		if parameterSkips[parameter.Type()] {
+			continue
+		}
+		if s.language == "go" {
+			names := 0
+			for j := 0; j < int(parameter.ChildCount()); j++ {
+				if parameter.FieldNameForChild(j) == "name" {
+					names++
+				}
+			}
+			if names == 0 {
+				names = 1 // An unnamed parameter
+			}
+			count += names
+			continue
+		}
+		if s.language == "python" && method && count == 0 && i == 0 && parameter.Type() == "identifier" {
+			if name := parameter.Content(s.content); name == "self" || name == "cls" {
+				continue
+			}
+		}
+		count++
+	}
+	return count
+}
+
+// goReceiverType returns the type a Go method's receiver names, without
+// pointer or type parameters
+func goReceiverType(receiver *sitter.Node, source []byte) string {
+	if receiver == nil || receiver.NamedChildCount() == 0 {
+		return ""
+	}
+	declaration := receiver.NamedChild(0)
+	typ := declaration.ChildByFieldName("type")
+	if typ == nil {
+		return ""
+	}
+	name := strings.TrimPrefix(typ.Content(source), "*")
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSpace(name)
+}
+
+// Classes returns the classes declared in content with the number of
+// methods each declares. In Go a class is a type with methods.
+func Classes(language, content string) ([]Class, error) {
+	src, err := parse(language, content)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+	return src.classes(src.functions()), nil
+}
+
+// classes finds the class declarations and counts their methods
+func (s *source) classes(functions []Function) []Class {
+	methods := make(map[string]int)
+	for _, function := range functions {
+		if function.Container != "" {
+			methods[function.Container]++
+		}
+	}
+
+	classes := []Class{}
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		declared := classTypes[s.language][node.Type()] || (s.language == "go" && node.Type() == "type_spec")
+		if declared {
+			if name := node.ChildByFieldName("name"); name != nil {
+				classes = append(classes, Class{
+					Name:      name.Content(s.content),
+					StartLine: int(node.StartPoint().Row) + 1,
+					EndLine:   int(node.EndPoint().Row) + 1,
+					Methods:   methods[name.Content(s.content)],
+				})
+			}
+		}
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			walk(node.NamedChild(i))
+		}
+	}
+	walk(s.tree.RootNode())
+
+	if s.language == "go" {
+		// Only types with methods behave like classes
+		withMethods := classes[:0]
+		for _, class := range classes {
+			if class.Methods > 0 {
+				withMethods = append(withMethods, class)
+			}
+		}
+		classes = withMethods
+	}
+	return classes
+}
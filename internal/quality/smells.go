@@ -0,0 +1,388 @@
+package quality
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// Smell types
+const (
+	SmellLongFunction      = "long_function"
+	SmellLongParameterList = "long_parameter_list"
+	SmellDeepNesting       = "deep_nesting"
+	SmellLargeClass        = "large_class"
+	SmellLargeFile         = "large_file"
+	SmellDuplicateCode     = "duplicate_code"
+	SmellMagicNumber       = "magic_number"
+)
+
+// SmellTypes lists every smell type Detect reports
+var SmellTypes = []string{
+	SmellLongFunction, SmellLongParameterList, SmellDeepNesting, SmellLargeClass,
+	SmellLargeFile, SmellDuplicateCode, SmellMagicNumber,
+}
+
+// SmellDescriptions describes each smell type
+var SmellDescriptions = map[string]string{
+	SmellLongFunction:      "Function longer than the configured number of lines",
+	SmellLongParameterList: "Function taking more than the configured number of parameters",
+	SmellDeepNesting:       "Control flow nested deeper than the configured depth",
+	SmellLargeClass:        "Class or type with more than the configured number of methods",
+	SmellLargeFile:         "File longer than the configured number of lines",
+	SmellDuplicateCode:     "Consecutive lines repeating earlier lines of the file",
+	SmellMagicNumber:       "Unnamed numeric literals in a function",
+}
+
+// Severities from least to most severe
+var Severities = []string{"low", "medium", "high", "critical"}
+
+// SeverityRank orders a severity among Severities, returning -1 for an
+// unknown one
+func SeverityRank(severity string) int {
+	for i, known := range Severities {
+		if known == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Smell is a code smell found in a file
+type Smell struct {
+	Type       string `json:"type"`
+	Severity   string `json:"severity"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Symbol     string `json:"symbol,omitempty"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+	Value      int    `json:"value"`     // What was measured, such as the lines of a function
+	Threshold  int    `json:"threshold"` // The configured limit it exceeds
+}
+
+// nestingTypes are the control flow statements that nest the code inside
+// them, by language
+var nestingTypes = map[string]map[string]bool{
+	"go": {
+		"if_statement": true, "for_statement": true, "expression_switch_statement": true,
+		"type_switch_statement": true, "select_statement": true,
+	},
+	"python": {
+		"if_statement": true, "for_statement": true, "while_statement": true, "try_statement": true,
+		"with_statement": true, "match_statement": true,
+	},
+	"javascript": {
+		"if_statement": true, "for_statement": true, "for_in_statement": true, "while_statement": true,
+		"do_statement": true, "switch_statement": true, "try_statement": true,
+	},
+	"typescript": {
+		"if_statement": true, "for_statement": true, "for_in_statement": true, "while_statement": true,
+		"do_statement": true, "switch_statement": true, "try_statement": true,
+	},
+	"java": {
+		"if_statement": true, "for_statement": true, "enhanced_for_statement": true, "while_statement": true,
+		"do_statement": true, "switch_expression": true, "try_statement": true, "try_with_resources_statement": true,
+	},
+}
+
+// numberTypes are the numeric literal node types by language
+var numberTypes = map[string]map[string]bool{
+	"go":         {"int_literal": true, "float_literal": true},
+	"python":     {"integer": true, "float": true},
+	"javascript": {"number": true},
+	"typescript": {"number": true},
+	"java": {
+		"decimal_integer_literal": true, "hex_integer_literal": true, "octal_integer_literal": true,
+		"binary_integer_literal": true, "decimal_floating_point_literal": true,
+	},
+}
+
+// plainNumbers are the literals too common to be magic
+var plainNumbers = map[string]bool{"0": true, "1": true, "2": true, "0.0": true, "1.0": true}
+
+// Detect returns the code smells in content, ordered by line
+func Detect(language, content string, thresholds config.SmellsConfig) ([]Smell, error) {
+	src, err := parse(language, content)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+
+	smells := []Smell{}
+	if src.lines > thresholds.MaxFileLines && thresholds.MaxFileLines > 0 {
+		smells = append(smells, Smell{
+			Type:       SmellLargeFile,
+			Severity:   severity(src.lines, thresholds.MaxFileLines),
+			StartLine:  1,
+			EndLine:    src.lines,
+			Message:    fmt.Sprintf("File has %d lines, more than %d", src.lines, thresholds.MaxFileLines),
+			Suggestion: "Split the file by responsibility",
+			Value:      src.lines,
+			Threshold:  thresholds.MaxFileLines,
+		})
+	}
+
+	functions := src.functions()
+	for _, function := range functions {
+		name := function.QualifiedName()
+		if function.Lines > thresholds.MaxFunctionLines && thresholds.MaxFunctionLines > 0 {
+			smells = append(smells, Smell{
+				Type:       SmellLongFunction,
+				Severity:   severity(function.Lines, thresholds.MaxFunctionLines),
+				StartLine:  function.StartLine,
+				EndLine:    function.EndLine,
+				Symbol:     name,
+				Message:    fmt.Sprintf("%s spans %d lines, more than %d", name, function.Lines, thresholds.MaxFunctionLines),
+				Suggestion: "Extract the steps of the function into smaller functions",
+				Value:      function.Lines,
+				Threshold:  thresholds.MaxFunctionLines,
+			})
+		}
+		if function.Parameters > thresholds.MaxParameters && thresholds.MaxParameters > 0 {
+			smells = append(smells, Smell{
+				Type:       SmellLongParameterList,
+				Severity:   severity(function.Parameters, thresholds.MaxParameters),
+				StartLine:  function.StartLine,
+				EndLine:    function.StartLine,
+				Symbol:     name,
+				Message:    fmt.Sprintf("%s takes %d parameters, more than %d", name, function.Parameters, thresholds.MaxParameters),
+				Suggestion: "Group related parameters into a struct or object",
+				Value:      function.Parameters,
+				Threshold:  thresholds.MaxParameters,
+			})
+		}
+		if function.body == nil {
+			continue
+		}
+		if depth, line := src.nesting(function.body, 0); depth > thresholds.MaxNesting && thresholds.MaxNesting > 0 {
+			smells = append(smells, Smell{
+				Type:       SmellDeepNesting,
+				Severity:   severity(depth, thresholds.MaxNesting),
+				StartLine:  line,
+				EndLine:    line,
+				Symbol:     name,
+				Message:    fmt.Sprintf("%s nests control flow %d deep, more than %d", name, depth, thresholds.MaxNesting),
+				Suggestion: "Return early or extract the inner blocks into functions",
+				Value:      depth,
+				Threshold:  thresholds.MaxNesting,
+			})
+		}
+		if numbers, line := src.magicNumbers(function.body); len(numbers) > 0 {
+			smells = append(smells, Smell{
+				Type:       SmellMagicNumber,
+				Severity:   "low",
+				StartLine:  line,
+				EndLine:    line,
+				Symbol:     name,
+				Message:    fmt.Sprintf("%s uses unnamed numbers: %s", name, strings.Join(numbers, ", ")),
+				Suggestion: "Name the numbers as constants",
+				Value:      len(numbers),
+			})
+		}
+	}
+
+	for _, class := range src.classes(functions) {
+		if class.Methods > thresholds.MaxClassMethods && thresholds.MaxClassMethods > 0 {
+			smells = append(smells, Smell{
+				Type:       SmellLargeClass,
+				Severity:   severity(class.Methods, thresholds.MaxClassMethods),
+				StartLine:  class.StartLine,
+				EndLine:    class.EndLine,
+				Symbol:     class.Name,
+				Message:    fmt.Sprintf("%s declares %d methods, more than %d", class.Name, class.Methods, thresholds.MaxClassMethods),
+				Suggestion: "Split the class by responsibility",
+				Value:      class.Methods,
+				Threshold:  thresholds.MaxClassMethods,
+			})
+		}
+	}
+
+	if thresholds.DuplicateMinLines > 0 {
+		smells = append(smells, duplicates(content, thresholds.DuplicateMinLines)...)
+	}
+
+	sort.SliceStable(smells, func(i, j int) bool {
+		return smells[i].StartLine < smells[j].StartLine
+	})
+	return smells, nil
+}
+
+// severity grades a measurement by how far past its threshold it is
+func severity(value, threshold int) string {
+	switch {
+	case value >= threshold*4:
+		return "critical"
+	case value >= threshold*2:
+		return "high"
+	default:
+		return "medium"
+	}
+}
+
+// isNesting reports whether node nests the code inside it. An if
+// statement that is the else branch of another continues its chain
+// rather than nesting.
+func (s *source) isNesting(node *sitter.Node) bool {
+	if !nestingTypes[s.language][node.Type()] {
+		return false
+	}
+	if node.Type() == "if_statement" {
+		if parent := node.Parent(); parent != nil && (parent.Type() == "if_statement" || parent.Type() == "else_clause") {
+			return false
+		}
+	}
+	return true
+}
+
+// nesting returns the deepest control flow nesting under node and the line
+// where it is reached. Nested function declarations are measured on their
+// own.
+func (s *source) nesting(node *sitter.Node, depth int) (int, int) {
+	deepest, line := depth, int(node.StartPoint().Row)+1
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if s.nested(child) {
+			continue
+		}
+		childDepth := depth
+		if s.isNesting(child) {
+			childDepth++
+		}
+		if d, l := s.nesting(child, childDepth); d > deepest {
+			deepest, line = d, l
+		}
+	}
+	return deepest, line
+}
+
+// magicNumbers returns the distinct unnamed numbers used under node and
+// the line of the first. Numbers that initialize a constant are named.
+func (s *source) magicNumbers(node *sitter.Node) ([]string, int) {
+	var numbers []string
+	seen := make(map[string]bool)
+	line := 0
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		if s.isConstant(node) {
+			return
+		}
+		if numberTypes[s.language][node.Type()] {
+			text := node.Content(s.content)
+			if !plainNumbers[text] && !seen[text] {
+				seen[text] = true
+				numbers = append(numbers, text)
+				if line == 0 {
+					line = int(node.StartPoint().Row) + 1
+				}
+			}
+			return
+		}
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			walk(node.NamedChild(i))
+		}
+	}
+	walk(node)
+	return numbers, line
+}
+
+// isConstant reports whether node declares constants: a Go const, a
+// JavaScript const, a Java final local or an upper-case Python name
+func (s *source) isConstant(node *sitter.Node) bool {
+	switch node.Type() {
+	case "const_declaration":
+		return true
+	case "lexical_declaration":
+		return node.ChildCount() > 0 && node.Child(0).Type() == "const"
+	case "local_variable_declaration":
+		for i := 0; i < int(node.NamedChildCount()); i++ {
+			if child := node.NamedChild(i); child.Type() == "modifiers" && strings.Contains(child.Content(s.content), "final") {
+				return true
+			}
+		}
+	case "assignment":
+		if s.language == "python" {
+			if left := node.ChildByFieldName("left"); left != nil && left.Type() == "identifier" {
+				name := left.Content(s.content)
+				return name == strings.ToUpper(name)
+			}
+		}
+	}
+	return false
+}
+
+// duplicates finds runs of at least minLines significant lines that repeat
+// an earlier run in the file. Lines are compared with their indentation
+// removed; blank lines and lines of only punctuation are skipped.
+func duplicates(content string, minLines int) []Smell {
+	type line struct {
+		number int
+		text   string
+	}
+	var lines []line
+	for i, text := range strings.Split(content, "\n") {
+		text = strings.TrimSpace(text)
+		if strings.IndexFunc(text, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			continue
+		}
+		lines = append(lines, line{number: i + 1, text: text})
+	}
+
+	smells := []Smell{}
+	first := make(map[string]int)
+	// A duplicate run being extended: where it starts, where the original
+	// starts and how many significant lines it covers
+	start, original, length := -1, -1, 0
+	flush := func() {
+		if start < 0 {
+			return
+		}
+		from, to := lines[start], lines[start+length-1]
+		copied, copiedEnd := lines[original], lines[original+length-1]
+		smells = append(smells, Smell{
+			Type:       SmellDuplicateCode,
+			Severity:   severity(length, minLines*2),
+			StartLine:  from.number,
+			EndLine:    to.number,
+			Message:    fmt.Sprintf("Lines %d-%d repeat lines %d-%d", from.number, to.number, copied.number, copiedEnd.number),
+			Suggestion: "Extract the repeated lines into a function",
+			Value:      length,
+			Threshold:  minLines,
+		})
+		start, original, length = -1, -1, 0
+	}
+	for i := 0; i+minLines <= len(lines); i++ {
+		texts := make([]string, minLines)
+		for j := range texts {
+			texts[j] = lines[i+j].text
+		}
+		key := strings.Join(texts, "\n")
+		earlier, ok := first[key]
+		if !ok {
+			first[key] = i
+		}
+		// The window must not overlap the one it repeats
+		if !ok || earlier+minLines > i {
+			if start >= 0 && i >= start+length {
+				flush()
+			}
+			continue
+		}
+		switch {
+		case start >= 0 && earlier == original+(i-start):
+			length = i - start + minLines
+		case start >= 0 && i < start+length:
+			// Inside the run being extended, repeating something else
+		default:
+			flush()
+			start, original, length = i, earlier, minLines
+		}
+	}
+	flush()
+	return smells
+}
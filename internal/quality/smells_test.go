@@ -0,0 +1,200 @@
+package quality
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// thresholds are small limits the test sources exceed
+var thresholds = config.SmellsConfig{
+	MaxFunctionLines:  10,
+	MaxParameters:     3,
+	MaxNesting:        2,
+	MaxClassMethods:   2,
+	MaxFileLines:      1000,
+	DuplicateMinLines: 4,
+}
+
+const goSource = `package store
+
+type Store struct{}
+
+func (s *Store) Load(a, b string, c int, d ...bool) error {
+	for i := 0; i < c; i++ {
+		if a == b {
+			if d[0] {
+				return nil
+			}
+		} else if a == "" {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *Store) Save() error { return nil }
+
+func (s *Store) Close() error { return nil }
+
+func wait() {
+	const timeout = 30
+	retry(timeout, 3600)
+}
+`
+
+func smellsOf(t *testing.T, language, content string) []Smell {
+	t.Helper()
+	if !Supported(language) {
+		t.Fatalf("Expected %s to be supported", language)
+	}
+	smells, err := Detect(language, content, thresholds)
+	if err != nil {
+		t.Skipf("Tree-sitter %s parser not available: %v", language, err)
+	}
+	return smells
+}
+
+// find returns the smell of a type reported for a symbol
+func find(smells []Smell, smellType, symbol string) (Smell, bool) {
+	for _, smell := range smells {
+		if smell.Type == smellType && smell.Symbol == symbol {
+			return smell, true
+		}
+	}
+	return Smell{}, false
+}
+
+func TestFunctionsGo(t *testing.T) {
+	functions, err := Functions("go", goSource)
+	if err != nil {
+		t.Skipf("Tree-sitter go parser not available: %v", err)
+	}
+	if len(functions) != 4 {
+		t.Fatalf("Expected 4 functions, got %+v", functions)
+	}
+	load := functions[0]
+	if load.QualifiedName() != "Store.Load" || load.Parameters != 4 || load.StartLine != 5 || load.Lines != 12 {
+		t.Errorf("Unexpected Load: %+v", load)
+	}
+	if functions[3].Name != "wait" || functions[3].Container != "" || functions[3].Parameters != 0 {
+		t.Errorf("Unexpected wait: %+v", functions[3])
+	}
+}
+
+func TestDetectGo(t *testing.T) {
+	smells := smellsOf(t, "go", goSource)
+
+	if smell, ok := find(smells, SmellLongFunction, "Store.Load"); !ok || smell.Value != 12 || smell.Severity != "medium" {
+		t.Errorf("Expected Store.Load to be reported as long, got %+v", smells)
+	}
+	if smell, ok := find(smells, SmellLongParameterList, "Store.Load"); !ok || smell.Value != 4 {
+		t.Errorf("Expected Store.Load's 4 parameters to be reported, got %+v", smells)
+	}
+	// The else-if continues the outer if, so the deepest nesting is the
+	// inner if at 3
+	if smell, ok := find(smells, SmellDeepNesting, "Store.Load"); !ok || smell.Value != 3 || smell.StartLine != 8 {
+		t.Errorf("Expected nesting of 3 at line 8 in Store.Load, got %+v", smells)
+	}
+	if smell, ok := find(smells, SmellLargeClass, "Store"); !ok || smell.Value != 3 {
+		t.Errorf("Expected Store's 3 methods to be reported, got %+v", smells)
+	}
+	if smell, ok := find(smells, SmellMagicNumber, "wait"); !ok || smell.Message != "wait uses unnamed numbers: 3600" {
+		t.Errorf("Expected only 3600 to be reported as magic in wait, got %+v", smells)
+	}
+	if _, ok := find(smells, SmellLongFunction, "wait"); ok {
+		t.Error("Expected wait not to be reported as long")
+	}
+}
+
+func TestDetectPython(t *testing.T) {
+	source := `class Repo:
+    def find(self, name, kind, limit, offset):
+        for item in self.items:
+            while item:
+                with open(item) as f:
+                    return f
+        return None
+
+    def save(self):
+        pass
+
+    def close(self):
+        pass
+`
+	smells := smellsOf(t, "python", source)
+	if smell, ok := find(smells, SmellLongParameterList, "Repo.find"); !ok || smell.Value != 4 {
+		t.Errorf("Expected Repo.find's 4 parameters, without self, to be reported, got %+v", smells)
+	}
+	if smell, ok := find(smells, SmellDeepNesting, "Repo.find"); !ok || smell.Value != 3 {
+		t.Errorf("Expected nesting of 3 in Repo.find, got %+v", smells)
+	}
+	if smell, ok := find(smells, SmellLargeClass, "Repo"); !ok || smell.Value != 3 {
+		t.Errorf("Expected Repo's 3 methods to be reported, got %+v", smells)
+	}
+}
+
+func TestDetectJavaScriptAndJava(t *testing.T) {
+	js := `const handler = (req, res, next, options) => {
+  return next(req, res, options);
+};
+
+class Api {
+  get(path) { return path; }
+}
+`
+	smells := smellsOf(t, "javascript", js)
+	if smell, ok := find(smells, SmellLongParameterList, "handler"); !ok || smell.Value != 4 {
+		t.Errorf("Expected the handler arrow function's parameters to be reported, got %+v", smells)
+	}
+	functions, _ := Functions("javascript", js)
+	if len(functions) != 2 || functions[1].QualifiedName() != "Api.get" || functions[1].Parameters != 1 {
+		t.Errorf("Unexpected JavaScript functions: %+v", functions)
+	}
+
+	java := `public class Order {
+    public Order(String id, int count, double price, String note) {}
+
+    public double total() {
+        final double tax = 0.19;
+        return price * count * 1.07 * tax;
+    }
+}
+`
+	smells = smellsOf(t, "java", java)
+	if smell, ok := find(smells, SmellLongParameterList, "Order.Order"); !ok || smell.Value != 4 {
+		t.Errorf("Expected the constructor's parameters to be reported, got %+v", smells)
+	}
+	if smell, ok := find(smells, SmellMagicNumber, "Order.total"); !ok || smell.Message != "Order.total uses unnamed numbers: 1.07" {
+		t.Errorf("Expected only 1.07 to be reported as magic, got %+v", smells)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	block := "total := 0\nfor _, item := range items {\n\ttotal += item.Price\n}\nlog.Printf(\"%d\", total)\nreturn total\n"
+	content := "func a() int {\n" + block + "}\n\nfunc b() int {\n" + strings.ReplaceAll(block, "\t", "    ") + "}\n"
+
+	smells := duplicates(content, 4)
+	if len(smells) != 1 {
+		t.Fatalf("Expected one duplicate block, got %+v", smells)
+	}
+	expected := fmt.Sprintf("Lines %d-%d repeat lines %d-%d", 11, 16, 2, 7)
+	if smells[0].Message != expected || smells[0].Value != 5 {
+		t.Errorf("Expected %q covering 5 significant lines, got %+v", expected, smells[0])
+	}
+
+	if smells := duplicates("a := 1\nb := 2\nc := 3\n", 2); len(smells) != 0 {
+		t.Errorf("Expected no duplicates, got %+v", smells)
+	}
+}
+
+func TestSeverityRank(t *testing.T) {
+	if SeverityRank("low") >= SeverityRank("high") || SeverityRank("unknown") != -1 {
+		t.Error("Unexpected severity order")
+	}
+	if severity(12, 10) != "medium" || severity(20, 10) != "high" || severity(45, 10) != "critical" {
+		t.Error("Unexpected severity grading")
+	}
+}
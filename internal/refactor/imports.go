@@ -0,0 +1,336 @@
+package refactor
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/my-mcp/code-indexer/internal/parser"
+)
+
+// importTypes are the node types of import statements and package
+// declarations; identifiers in them are only renamed when they import the
+// target by name
+var importTypes = map[string]bool{
+	"import_declaration":    true,
+	"import_statement":      true,
+	"import_from_statement": true,
+	"package_clause":        true,
+	"package_declaration":   true,
+}
+
+// imports is what a file imports from the target's package or module
+type imports struct {
+	qualifiers map[string]bool   // Qualifiers naming the package or module, such as store or pkg.store
+	names      map[string]string // Names imported from it directly, by the local name they are bound to
+	wildcard   bool              // All of its names are in scope, as after a Go dot import
+	targets    map[int]bool      // Offsets of imported names that are the target's own; renamed with it
+}
+
+// ModuleImports is how a file refers to the declarations of a module
+type ModuleImports struct {
+	Qualifiers map[string]bool   // Qualifiers naming the module, such as store in store.Load
+	Names      map[string]string // Names imported from it directly, by the local name they are bound to
+	Wildcard   bool              // All of its names are in scope
+}
+
+// ImportsOf reports how content, a file of module scope, imports module, so
+// a name used in the file can be matched to the module declaring it
+func ImportsOf(language, content string, module, scope Module) (*ModuleImports, error) {
+	p := parser.NewTreeSitterParser(language)
+	if p == nil {
+		return nil, fmt.Errorf("imports are not supported for %s files", language)
+	}
+	tree, err := p.ParseTree(content)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	r := &renamer{
+		language: language,
+		source:   []byte(content),
+		target:   Target{Module: module},
+		scope:    Scope{Module: scope},
+		imports: imports{
+			qualifiers: make(map[string]bool),
+			names:      make(map[string]string),
+			targets:    make(map[int]bool),
+		},
+	}
+	if language == "java" && module.Path != "" {
+		// Fully qualified names
+		r.imports.qualifiers[module.Path] = true
+	}
+	r.collectImports(tree.RootNode())
+
+	return &ModuleImports{
+		Qualifiers: r.imports.qualifiers,
+		Names:      r.imports.names,
+		Wildcard:   r.imports.wildcard,
+	}, nil
+}
+
+// collectImports records the imports of the target's module below node
+func (r *renamer) collectImports(node *sitter.Node) {
+	modulePath := r.target.Module.Path
+	if modulePath == "" {
+		return
+	}
+
+	switch r.language {
+	case "go":
+		if node.Type() == "import_spec" {
+			r.goImport(node)
+			return
+		}
+	case "python":
+		switch node.Type() {
+		case "import_statement":
+			for _, name := range fieldChildren(node, "name") {
+				if name.Type() == "aliased_import" {
+					if r.text(name.ChildByFieldName("name")) == modulePath {
+						r.imports.qualifiers[r.text(name.ChildByFieldName("alias"))] = true
+					}
+				} else if r.text(name) == modulePath {
+					r.imports.qualifiers[r.text(name)] = true
+				}
+			}
+			return
+		case "import_from_statement":
+			r.pythonFromImport(node)
+			return
+		}
+	case "javascript", "typescript":
+		switch node.Type() {
+		case "import_statement":
+			r.scriptImport(node)
+			return
+		case "variable_declarator":
+			// const store = require('./store')
+			value := node.ChildByFieldName("value")
+			name := node.ChildByFieldName("name")
+			if value != nil && name != nil && name.Type() == "identifier" && value.Type() == "call_expression" &&
+				r.text(value.ChildByFieldName("function")) == "require" {
+				if args := value.ChildByFieldName("arguments"); args != nil && args.NamedChildCount() > 0 &&
+					r.resolveScript(args.NamedChild(0)) == modulePath {
+					r.imports.qualifiers[r.text(name)] = true
+				}
+			}
+		}
+	case "java":
+		if node.Type() == "import_declaration" {
+			r.javaImport(node)
+			return
+		}
+	}
+
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		r.collectImports(node.NamedChild(i))
+	}
+}
+
+// goImport records an import spec of the target's package
+func (r *renamer) goImport(spec *sitter.Node) {
+	importPath := strings.Trim(r.text(spec.ChildByFieldName("path")), "\"`")
+	if importPath != r.target.Module.Path {
+		return
+	}
+	name := spec.ChildByFieldName("name")
+	switch {
+	case name == nil:
+		qualifier := r.target.Module.Name
+		if qualifier == "" {
+			qualifier = path.Base(importPath)
+		}
+		r.imports.qualifiers[qualifier] = true
+	case name.Type() == "dot":
+		r.imports.wildcard = true
+	case name.Type() == "package_identifier":
+		r.imports.qualifiers[r.text(name)] = true
+	}
+}
+
+// pythonFromImport records a from ... import statement naming the target's
+// module, or importing from it
+func (r *renamer) pythonFromImport(statement *sitter.Node) {
+	module := r.resolvePython(statement.ChildByFieldName("module_name"))
+	for i := 0; i < int(statement.NamedChildCount()); i++ {
+		if statement.NamedChild(i).Type() == "wildcard_import" && module == r.target.Module.Path {
+			r.imports.wildcard = true
+		}
+	}
+
+	for _, imported := range fieldChildren(statement, "name") {
+		name, local := imported, imported
+		if imported.Type() == "aliased_import" {
+			name, local = imported.ChildByFieldName("name"), imported.ChildByFieldName("alias")
+		}
+		if name == nil || local == nil {
+			continue
+		}
+		if module+"."+r.text(name) == r.target.Module.Path {
+			r.imports.qualifiers[r.text(local)] = true
+		}
+		if module == r.target.Module.Path {
+			r.importName(name, r.text(local), r.text(name))
+		}
+	}
+}
+
+// resolvePython returns the dotted module a module_name node refers to,
+// resolving relative imports against the package of the file
+func (r *renamer) resolvePython(node *sitter.Node) string {
+	if node == nil {
+		return ""
+	}
+	if node.Type() != "relative_import" {
+		return r.text(node)
+	}
+
+	var dots int
+	var name string
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		switch child.Type() {
+		case "import_prefix":
+			dots = strings.Count(r.text(child), ".")
+		case "dotted_name":
+			name = r.text(child)
+		}
+	}
+
+	base := r.scope.Module.Dir
+	for ; dots > 1; dots-- {
+		if idx := strings.LastIndex(base, "."); idx >= 0 {
+			base = base[:idx]
+		} else {
+			base = ""
+		}
+	}
+	switch {
+	case base == "":
+		return name
+	case name == "":
+		return base
+	}
+	return base + "." + name
+}
+
+// scriptImport records an ES module import of the target's module
+func (r *renamer) scriptImport(statement *sitter.Node) {
+	if r.resolveScript(statement.ChildByFieldName("source")) != r.target.Module.Path {
+		return
+	}
+	for i := 0; i < int(statement.NamedChildCount()); i++ {
+		clause := statement.NamedChild(i)
+		if clause.Type() != "import_clause" {
+			continue
+		}
+		for j := 0; j < int(clause.NamedChildCount()); j++ {
+			child := clause.NamedChild(j)
+			switch child.Type() {
+			case "identifier":
+				r.imports.qualifiers[r.text(child)] = true
+			case "namespace_import":
+				if child.NamedChildCount() > 0 {
+					r.imports.qualifiers[r.text(child.NamedChild(0))] = true
+				}
+			case "named_imports":
+				for k := 0; k < int(child.NamedChildCount()); k++ {
+					specifier := child.NamedChild(k)
+					name := specifier.ChildByFieldName("name")
+					if name == nil {
+						continue
+					}
+					local := name
+					if alias := specifier.ChildByFieldName("alias"); alias != nil {
+						local = alias
+					}
+					r.importName(name, r.text(local), r.text(name))
+				}
+			}
+		}
+	}
+}
+
+// resolveScript returns the module path a string literal imports, relative
+// specifiers resolved against the directory of the file
+func (r *renamer) resolveScript(literal *sitter.Node) string {
+	if literal == nil {
+		return ""
+	}
+	specifier := strings.Trim(r.text(literal), "'\"`")
+	if !strings.HasPrefix(specifier, ".") {
+		return specifier
+	}
+	return ScriptModulePath(path.Join(r.scope.Module.Dir, specifier))
+}
+
+// javaImport records a single-type or on-demand import from the target's
+// package
+func (r *renamer) javaImport(declaration *sitter.Node) {
+	var imported *sitter.Node
+	wildcard := false
+	for i := 0; i < int(declaration.NamedChildCount()); i++ {
+		switch child := declaration.NamedChild(i); child.Type() {
+		case "scoped_identifier":
+			imported = child
+		case "asterisk":
+			wildcard = true
+		}
+	}
+	if imported == nil {
+		return
+	}
+	if wildcard {
+		if r.text(imported) == r.target.Module.Path {
+			r.imports.wildcard = true
+		}
+		return
+	}
+	if r.text(imported.ChildByFieldName("scope")) == r.target.Module.Path {
+		name := imported.ChildByFieldName("name")
+		r.importName(name, r.text(name), r.text(name))
+	}
+}
+
+// importName records a name imported from the target's module under local.
+// An import of a top-level target is itself an occurrence.
+func (r *renamer) importName(name *sitter.Node, local, original string) {
+	r.imports.names[local] = original
+	if r.target.Container == "" && original == r.target.Name {
+		for identifier := name; identifier != nil; identifier = identifier.NamedChild(0) {
+			if identifierTypes[identifier.Type()] {
+				r.imports.targets[int(identifier.StartByte())] = true
+				return
+			}
+			if identifier.NamedChildCount() == 0 {
+				return
+			}
+		}
+	}
+}
+
+// fieldChildren returns the children of node in a field that may repeat
+func fieldChildren(node *sitter.Node, field string) []*sitter.Node {
+	var children []*sitter.Node
+	for i := 0; i < int(node.ChildCount()); i++ {
+		if node.FieldNameForChild(i) == field {
+			children = append(children, node.Child(i))
+		}
+	}
+	return children
+}
+
+// inImport reports whether node lies in an import statement
+func inImport(node *sitter.Node) bool {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if importTypes[parent.Type()] {
+			return true
+		}
+	}
+	return false
+}
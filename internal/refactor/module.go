@@ -0,0 +1,141 @@
+package refactor
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Module identifies the package or module holding a file by the path other
+// files import it with. Imports are resolved for Go, Python, JavaScript,
+// TypeScript and Java; other languages have an empty Module.
+type Module struct {
+	Path string // Go import path, dotted Python module, Java package, or slash-separated script path without extension
+	Name string // Name the Go package declares, the qualifier of imports without an alias
+	Dir  string // Python package or script directory that relative imports in the file start from
+}
+
+var (
+	goPackagePattern   = regexp.MustCompile(`(?m)^package\s+(\w+)`)
+	goModulePattern    = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+	javaPackagePattern = regexp.MustCompile(`(?m)^\s*package\s+([\w.]+)\s*;`)
+)
+
+// scriptExtensions are stripped from JavaScript and TypeScript module paths
+var scriptExtensions = []string{".d.ts", ".tsx", ".ts", ".jsx", ".mjs", ".cjs", ".js"}
+
+// FileModule determines the module of the file at path, with content, in the
+// repository at root. Go import paths come from the nearest go.mod, Python
+// modules from the enclosing packages and script paths from the location
+// under root.
+func FileModule(language, root, filePath string, content []byte) Module {
+	switch language {
+	case "go":
+		return goFileModule(root, filePath, content)
+	case "python":
+		return pythonFileModule(root, filePath)
+	case "javascript", "typescript":
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return Module{}
+		}
+		rel = filepath.ToSlash(rel)
+		return Module{Path: ScriptModulePath(rel), Dir: path.Dir(rel)}
+	case "java":
+		if match := javaPackagePattern.FindSubmatch(content); match != nil {
+			return Module{Path: string(match[1])}
+		}
+	}
+	return Module{}
+}
+
+// SamePackage reports whether two files are in the same package or module,
+// so the bare names of one refer to the declarations of the other. Python,
+// JavaScript and TypeScript modules are single files; in other languages a
+// package is a directory.
+func SamePackage(language, a, b string) bool {
+	switch language {
+	case "python", "javascript", "typescript":
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return filepath.Dir(a) == filepath.Dir(b)
+}
+
+// goFileModule builds the import path of a Go file from the module path of
+// the nearest go.mod at or below root
+func goFileModule(root, filePath string, content []byte) Module {
+	var module Module
+	if match := goPackagePattern.FindSubmatch(content); match != nil {
+		module.Name = string(match[1])
+	}
+
+	dir := filepath.Dir(filePath)
+	for modDir := dir; ; modDir = filepath.Dir(modDir) {
+		if data, err := os.ReadFile(filepath.Join(modDir, "go.mod")); err == nil {
+			match := goModulePattern.FindSubmatch(data)
+			if match == nil {
+				return module
+			}
+			rel, err := filepath.Rel(modDir, dir)
+			if err != nil {
+				return module
+			}
+			module.Path = path.Join(string(match[1]), filepath.ToSlash(rel))
+			return module
+		}
+		if modDir == filepath.Clean(root) || filepath.Dir(modDir) == modDir {
+			return module
+		}
+	}
+}
+
+// pythonFileModule builds the dotted module of a Python file from the
+// packages, directories with an __init__.py, around it. Outside a package
+// the path under root is used, without a leading src directory.
+func pythonFileModule(root, filePath string) Module {
+	name := strings.TrimSuffix(filepath.Base(filePath), ".py")
+	dir := filepath.Dir(filePath)
+
+	var packages []string
+	for pkgDir := dir; pkgDir != filepath.Dir(pkgDir); pkgDir = filepath.Dir(pkgDir) {
+		if _, err := os.Stat(filepath.Join(pkgDir, "__init__.py")); err != nil {
+			break
+		}
+		packages = append([]string{filepath.Base(pkgDir)}, packages...)
+	}
+	if len(packages) == 0 {
+		rel, err := filepath.Rel(root, dir)
+		if err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			packages = strings.Split(filepath.ToSlash(rel), "/")
+			if packages[0] == "src" {
+				packages = packages[1:]
+			}
+		}
+	}
+
+	pkg := strings.Join(packages, ".")
+	if name == "__init__" {
+		return Module{Path: pkg, Dir: pkg}
+	}
+	if pkg == "" {
+		return Module{Path: name}
+	}
+	return Module{Path: pkg + "." + name, Dir: pkg}
+}
+
+// ScriptModulePath strips the extension and index file name from a slash
+// separated script path, so it compares equal to the imports that load it
+func ScriptModulePath(p string) string {
+	for _, ext := range scriptExtensions {
+		if strings.HasSuffix(p, ext) {
+			p = strings.TrimSuffix(p, ext)
+			break
+		}
+	}
+	if p == "index" {
+		return "."
+	}
+	return strings.TrimSuffix(p, "/index")
+}
@@ -0,0 +1,116 @@
+package refactor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileModule(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":                  "module example.com/m\n\ngo 1.22\n",
+		"internal/store/store.go": "// Package store keeps values\npackage store\n",
+		"tools/go.mod":            "module example.com/m/tools\n",
+		"tools/gen/main.go":       "package main\n",
+		"pkg/__init__.py":         "",
+		"pkg/db/__init__.py":      "",
+		"pkg/db/store.py":         "",
+		"src/scripts/run.py":      "",
+		"web/lib/index.ts":        "",
+		"web/app.js":              "",
+		"java/com/x/Store.java":   "package com.x;\n\nclass Store {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		language string
+		file     string
+		want     Module
+	}{
+		{"go", "internal/store/store.go", Module{Path: "example.com/m/internal/store", Name: "store"}},
+		{"go", "tools/gen/main.go", Module{Path: "example.com/m/tools/gen", Name: "main"}},
+		{"python", "pkg/db/store.py", Module{Path: "pkg.db.store", Dir: "pkg.db"}},
+		{"python", "pkg/db/__init__.py", Module{Path: "pkg.db", Dir: "pkg.db"}},
+		{"python", "src/scripts/run.py", Module{Path: "scripts.run", Dir: "scripts"}},
+		{"typescript", "web/lib/index.ts", Module{Path: "web/lib", Dir: "web/lib"}},
+		{"javascript", "web/app.js", Module{Path: "web/app", Dir: "web"}},
+		{"java", "java/com/x/Store.java", Module{Path: "com.x"}},
+		{"rust", "web/app.js", Module{}},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(root, tt.file)
+		content, _ := os.ReadFile(path)
+		if got := FileModule(tt.language, root, path, content); got != tt.want {
+			t.Errorf("%s: expected %+v, got %+v", tt.file, tt.want, got)
+		}
+	}
+}
+
+func TestSamePackage(t *testing.T) {
+	tests := []struct {
+		language string
+		a, b     string
+		want     bool
+	}{
+		{"go", "/r/store/a.go", "/r/store/b.go", true},
+		{"go", "/r/store/a.go", "/r/app/a.go", false},
+		{"java", "/r/com/x/A.java", "/r/com/x/B.java", true},
+		{"python", "/r/pkg/a.py", "/r/pkg/b.py", false},
+		{"python", "/r/pkg/a.py", "/r/pkg/./a.py", true},
+		{"typescript", "/r/src/a.ts", "/r/src/b.ts", false},
+	}
+
+	for _, tt := range tests {
+		if got := SamePackage(tt.language, tt.a, tt.b); got != tt.want {
+			t.Errorf("%s %s and %s: expected %v, got %v", tt.language, tt.a, tt.b, tt.want, got)
+		}
+	}
+}
+
+func TestImportsOf(t *testing.T) {
+	tests := []struct {
+		language   string
+		content    string
+		module     Module
+		scope      Module
+		qualifier  string
+		name       string
+		importedAs string
+	}{
+		{"go", "package app\n\nimport (\n\t\"fmt\"\n\tdb \"example.com/m/internal/store\"\n)\n",
+			Module{Path: "example.com/m/internal/store", Name: "store"}, Module{}, "db", "", ""},
+		{"python", "from .db.store import load as fetch\nimport pkg.util\n",
+			Module{Path: "pkg.db.store", Dir: "pkg.db"}, Module{Path: "pkg.app", Dir: "pkg"}, "", "load", "fetch"},
+		{"typescript", "import { Store } from '../lib';\nimport * as util from './util';\n",
+			Module{Path: "web/lib", Dir: "web/lib"}, Module{Path: "web/app/main", Dir: "web/app"}, "", "Store", "Store"},
+		{"typescript", "import { Store } from '../lib';\nimport * as util from './util';\n",
+			Module{Path: "web/app/util", Dir: "web/app"}, Module{Path: "web/app/main", Dir: "web/app"}, "util", "", ""},
+	}
+
+	for _, tt := range tests {
+		imports, err := ImportsOf(tt.language, tt.content, tt.module, tt.scope)
+		if err != nil {
+			t.Fatalf("ImportsOf failed: %v", err)
+		}
+		if tt.qualifier != "" && !imports.Qualifiers[tt.qualifier] {
+			t.Errorf("%s: expected %s to qualify %s, got %v", tt.language, tt.qualifier, tt.module.Path, imports.Qualifiers)
+		}
+		if tt.name != "" && imports.Names[tt.importedAs] != tt.name {
+			t.Errorf("%s: expected %s to be imported as %s, got %v", tt.language, tt.name, tt.importedAs, imports.Names)
+		}
+	}
+
+	if imports, err := ImportsOf("go", "package app\n\nimport \"fmt\"\n", Module{Path: "example.com/m/store"}, Module{}); err != nil || len(imports.Qualifiers) != 0 {
+		t.Errorf("Expected no qualifiers for a module that is not imported, got %+v (%v)", imports, err)
+	}
+}
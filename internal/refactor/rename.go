@@ -0,0 +1,568 @@
+// Package refactor rewrites source files through their tree-sitter syntax
+// trees, so refactorings only touch code and never the same word in
+// comments or strings.
+//
+// Renames are scope-aware and resolve imports, but are not fully
+// type-aware. In the target's own package or module a top-level symbol is
+// renamed wherever its bare name is used unless a function-local
+// declaration, such as a parameter or local variable, shadows it. In other
+// files it is renamed where it is qualified by an import of its package or
+// module, and at its bare name only where it is imported by name. A method
+// or field is renamed at member accesses on self or this inside its class,
+// on the class itself, and on variables and parameters declared with its
+// class as their type; member accesses on receivers of unknown type are
+// reported without being renamed.
+package refactor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+
+	"github.com/my-mcp/code-indexer/internal/parser"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+)
+
+// ErrInvalidName is returned for new names that are not identifiers
+var ErrInvalidName = errors.New("not a valid identifier")
+
+// identifierPattern matches the identifiers a rename accepts; $ is allowed
+// for JavaScript
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Target is the symbol a rename applies to
+type Target struct {
+	Name      string
+	Container string // Type or class of a method or field; empty for top-level symbols
+	Module    Module // Package or module declaring the symbol
+}
+
+// Scope relates a file being renamed to the target
+type Scope struct {
+	Local  bool   // The file is in the target's package or module
+	Module Module // Module of the file itself, to resolve relative imports
+}
+
+// Occurrence is a renamed identifier. Line and Column are 1-based; Start and
+// End are byte offsets into the original content.
+type Occurrence struct {
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Start  int    `json:"-"`
+	End    int    `json:"-"`
+	Kind   string `json:"kind"` // "definition", "reference" or "member"
+}
+
+// ValidateName checks that a new name is an identifier
+func ValidateName(name string) error {
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("%w: %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// FileRename is the outcome of a rename in one file
+type FileRename struct {
+	Content     string               // Content with the occurrences renamed
+	Occurrences []Occurrence         // Renamed identifiers, in file order
+	Unresolved  []Occurrence         // Member accesses with the target's name on receivers of unknown type, left unchanged
+	Homonyms    []parser.SymbolRange // Other declarations with the target's name
+	Collisions  []parser.SymbolRange // Declarations beside the target that already use the new name
+}
+
+// Rename renames the occurrences of target in content, a file related to
+// the target by scope, to newName. Content without occurrences is returned
+// unchanged.
+func Rename(language, content string, target Target, scope Scope, newName string) (*FileRename, error) {
+	if err := ValidateName(newName); err != nil {
+		return nil, err
+	}
+	p := parser.NewTreeSitterParser(language)
+	if p == nil {
+		return nil, fmt.Errorf("renaming is not supported for %s files", language)
+	}
+
+	symbols, err := p.Symbols(content)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := p.ParseTree(content)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	result := &FileRename{}
+
+	r := &renamer{
+		language: language,
+		source:   []byte(content),
+		target:   target,
+		scope:    scope,
+		root:     tree.RootNode(),
+		bindings: make(map[scopeKey]map[string]string),
+		declared: make(map[int]parser.SymbolRange),
+		imports: imports{
+			qualifiers: make(map[string]bool),
+			names:      make(map[string]string),
+			targets:    make(map[int]bool),
+		},
+	}
+	if language == "java" && target.Module.Path != "" {
+		// Fully qualified names
+		r.imports.qualifiers[target.Module.Path] = true
+	}
+	for _, symbol := range symbols {
+		if symbol.NameStart >= 0 {
+			r.declared[symbol.NameStart] = symbol
+		}
+		if scope.Local && target.Container != "" && symbol.QualifiedName() == target.Container {
+			r.containers = append(r.containers, symbol)
+		}
+		switch {
+		case symbol.Name == target.Name && symbol.Container != target.Container:
+			result.Homonyms = append(result.Homonyms, symbol)
+		case symbol.Name == newName && symbol.Container == target.Container:
+			result.Collisions = append(result.Collisions, symbol)
+		}
+	}
+	r.collectImports(r.root)
+	r.collectBindings(r.root)
+	r.collectOccurrences(r.root)
+
+	// Names declared in another package only collide where the target's
+	// name is in scope too
+	if !scope.Local && (target.Container != "" || !r.bareNamesVisible()) {
+		result.Collisions = nil
+	}
+
+	sort.Slice(r.occurrences, func(a, b int) bool { return r.occurrences[a].Start < r.occurrences[b].Start })
+	edited := make([]byte, 0, len(content))
+	last := 0
+	for _, occurrence := range r.occurrences {
+		edited = append(edited, content[last:occurrence.Start]...)
+		edited = append(edited, newName...)
+		last = occurrence.End
+	}
+	edited = append(edited, content[last:]...)
+
+	result.Content = string(edited)
+	result.Occurrences = r.occurrences
+	result.Unresolved = r.unresolved
+	return result, nil
+}
+
+// identifierTypes are the node types that name something in the supported
+// grammars
+var identifierTypes = map[string]bool{
+	"identifier":           true,
+	"type_identifier":      true,
+	"field_identifier":     true,
+	"property_identifier":  true,
+	"simple_identifier":    true,
+	"constant":             true,
+	"namespace_identifier": true,
+}
+
+// memberIdentifierTypes only name members, never top-level symbols, unless
+// they are part of a qualified member access
+var memberIdentifierTypes = map[string]bool{
+	"field_identifier":    true,
+	"property_identifier": true,
+}
+
+// memberAccesses maps member access node types to the fields holding the
+// member name and the accessed object
+var memberAccesses = map[string]struct{ name, object string }{
+	"selector_expression":      {"field", "operand"},
+	"qualified_type":           {"name", "package"},
+	"member_expression":        {"property", "object"},
+	"attribute":                {"attribute", "object"},
+	"field_expression":         {"field", "value"},
+	"field_access":             {"field", "object"},
+	"method_invocation":        {"name", "object"},
+	"member_access_expression": {"name", "expression"},
+	"call":                     {"method", "receiver"},
+	"scoped_identifier":        {"name", "path"},
+	"qualified_identifier":     {"name", "scope"},
+}
+
+// scopeTypes are the node types whose declarations are local to them
+var scopeTypes = map[string]bool{
+	"function_declaration":    true,
+	"method_declaration":      true,
+	"func_literal":            true,
+	"function_definition":     true,
+	"method_definition":       true,
+	"arrow_function":          true,
+	"function":                true,
+	"function_expression":     true,
+	"lambda":                  true,
+	"lambda_expression":       true,
+	"function_item":           true,
+	"closure_expression":      true,
+	"constructor_declaration": true,
+	"method":                  true,
+	"singleton_method":        true,
+	"anonymous_function":      true,
+}
+
+// bindingFields maps the node types that declare local names to the fields
+// holding the declared name; no fields accept any identifier child
+var bindingFields = map[string][]string{
+	"parameter_declaration":          {"name", "declarator"},
+	"variadic_parameter_declaration": {"name"},
+	"short_var_declaration":          {"left"},
+	"var_spec":                       {"name"},
+	"const_spec":                     {"name"},
+	"range_clause":                   {"left"},
+	"parameters":                     nil,
+	"default_parameter":              {"name"},
+	"typed_parameter":                nil,
+	"typed_default_parameter":        {"name"},
+	"assignment":                     {"left"},
+	"for_statement":                  {"left"},
+	"for_in_clause":                  {"left"},
+	"variable_declarator":            {"name"},
+	"formal_parameters":              nil,
+	"required_parameter":             {"pattern"},
+	"optional_parameter":             {"pattern"},
+	"formal_parameter":               {"name"},
+	"catch_formal_parameter":         {"name"},
+	"enhanced_for_statement":         {"name"},
+	"parameter":                      {"pattern", "name"},
+	"let_declaration":                {"pattern"},
+	"closure_parameters":             nil,
+	"for_expression":                 {"pattern"},
+	"init_declarator":                {"declarator"},
+	"method_parameters":              nil,
+	"lambda_parameters":              nil,
+}
+
+// bindingWrappers are nodes between a declaration and the names it declares
+var bindingWrappers = map[string]bool{
+	"expression_list":      true,
+	"pattern_list":         true,
+	"tuple_pattern":        true,
+	"list_pattern":         true,
+	"pointer_declarator":   true,
+	"reference_declarator": true,
+	"array_declarator":     true,
+}
+
+// scopeKey identifies a scope node across node handles
+type scopeKey struct {
+	start, end uint32
+	nodeType   string
+}
+
+func keyOf(node *sitter.Node) scopeKey {
+	return scopeKey{start: node.StartByte(), end: node.EndByte(), nodeType: node.Type()}
+}
+
+// renamer finds the occurrences of a rename target in one file
+type renamer struct {
+	language    string
+	source      []byte
+	target      Target
+	scope       Scope
+	root        *sitter.Node
+	bindings    map[scopeKey]map[string]string // Names declared locally in each scope, with their declared type
+	declared    map[int]parser.SymbolRange     // Declarations by the offset of their name
+	containers  []parser.SymbolRange           // Declarations of the target's container
+	imports     imports
+	occurrences []Occurrence
+	unresolved  []Occurrence
+}
+
+// text returns the source of node, or "" for a missing node
+func (r *renamer) text(node *sitter.Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.Content(r.source)
+}
+
+// collectBindings records the names declared by each scope below node
+func (r *renamer) collectBindings(node *sitter.Node) {
+	if identifierTypes[node.Type()] {
+		if declaration := bindingDeclaration(node); declaration != nil {
+			key := keyOf(r.enclosingScope(node))
+			if r.bindings[key] == nil {
+				r.bindings[key] = make(map[string]string)
+			}
+			r.bindings[key][node.Content(r.source)] = r.declaredType(declaration)
+		}
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		r.collectBindings(node.NamedChild(i))
+	}
+}
+
+// bindingDeclaration returns the declaration an identifier declares a local
+// name in, or nil if it declares none
+func bindingDeclaration(node *sitter.Node) *sitter.Node {
+	child := node
+	parent := node.Parent()
+	for parent != nil && bindingWrappers[parent.Type()] {
+		child, parent = parent, parent.Parent()
+	}
+	if parent == nil {
+		return nil
+	}
+	fields, ok := bindingFields[parent.Type()]
+	if !ok {
+		return nil
+	}
+	if len(fields) == 0 {
+		return parent
+	}
+	for _, field := range fields {
+		if sameNode(parent.ChildByFieldName(field), child) {
+			return parent
+		}
+	}
+	return nil
+}
+
+// declaredType returns the source of the type a declaration gives its
+// names, or "" if it gives none. Java declarators take the type of the
+// declaration around them.
+func (r *renamer) declaredType(declaration *sitter.Node) string {
+	if typ := declaration.ChildByFieldName("type"); typ != nil {
+		return typ.Content(r.source)
+	}
+	if declaration.Type() == "variable_declarator" && declaration.Parent() != nil {
+		return r.text(declaration.Parent().ChildByFieldName("type"))
+	}
+	return ""
+}
+
+// lookupBinding returns the declared type of the local declaration of name
+// nearest to node, and whether there is one. Declarations at file scope
+// count only when includeFile is set.
+func (r *renamer) lookupBinding(node *sitter.Node, name string, includeFile bool) (string, bool) {
+	for scope := r.enclosingScope(node); ; scope = r.enclosingScope(scope) {
+		atRoot := sameNode(scope, r.root)
+		if !atRoot || includeFile {
+			if typ, ok := r.bindings[keyOf(scope)][name]; ok {
+				return typ, true
+			}
+		}
+		if atRoot {
+			return "", false
+		}
+	}
+}
+
+// enclosingScope returns the innermost scope node holds, or the root. The
+// name of a function belongs to the scope around it.
+func (r *renamer) enclosingScope(node *sitter.Node) *sitter.Node {
+	for parent := node.Parent(); parent != nil; parent = parent.Parent() {
+		if scopeTypes[parent.Type()] && !sameNode(parent.ChildByFieldName("name"), node) {
+			return parent
+		}
+		node = parent
+	}
+	return r.root
+}
+
+// shadowed reports whether a local declaration between node and the file
+// scope hides the target's name
+func (r *renamer) shadowed(node *sitter.Node) bool {
+	_, ok := r.lookupBinding(node, r.target.Name, false)
+	return ok
+}
+
+// memberObject returns the object a member access identifier is accessed
+// on, and whether node is the member name of an access at all
+func memberObject(node *sitter.Node) (*sitter.Node, bool) {
+	parent := node.Parent()
+	if parent == nil {
+		return nil, false
+	}
+	if parent.Type() == "navigation_suffix" {
+		// Kotlin: the object is the first child of the navigation expression
+		if expression := parent.Parent(); expression != nil && expression.NamedChildCount() > 0 {
+			return expression.NamedChild(0), true
+		}
+		return nil, true
+	}
+	access, ok := memberAccesses[parent.Type()]
+	if !ok || !sameNode(parent.ChildByFieldName(access.name), node) {
+		return nil, false
+	}
+	object := parent.ChildByFieldName(access.object)
+	if object == nil {
+		// Unqualified calls, such as load() in Java, are bare names
+		return nil, false
+	}
+	return object, true
+}
+
+// collectOccurrences adds every occurrence of the target below node
+func (r *renamer) collectOccurrences(node *sitter.Node) {
+	if identifierTypes[node.Type()] && node.Content(r.source) == r.target.Name {
+		if kind, rename := r.classify(node); kind != "" {
+			start := int(node.StartByte())
+			lineStart := start - int(node.StartPoint().Column)
+			occurrence := Occurrence{
+				Line:   int(node.StartPoint().Row) + 1,
+				Column: textpos.Column(string(r.source[lineStart:node.EndByte()]), start-lineStart),
+				Start:  start,
+				End:    int(node.EndByte()),
+				Kind:   kind,
+			}
+			if rename {
+				r.occurrences = append(r.occurrences, occurrence)
+			} else {
+				r.unresolved = append(r.unresolved, occurrence)
+			}
+		}
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		r.collectOccurrences(node.NamedChild(i))
+	}
+}
+
+// classify decides whether an identifier with the target's name refers to
+// the target, and how. Member accesses that may refer to it on a receiver
+// of unknown type have a kind but are not renamed.
+func (r *renamer) classify(node *sitter.Node) (kind string, rename bool) {
+	start := int(node.StartByte())
+	if r.imports.targets[start] {
+		return "reference", true
+	}
+	if inImport(node) {
+		return "", false
+	}
+	if symbol, ok := r.declared[start]; ok {
+		if r.scope.Local && symbol.Name == r.target.Name && symbol.Container == r.target.Container {
+			return "definition", true
+		}
+		return "", false
+	}
+
+	object, isMember := memberObject(node)
+	if r.target.Container != "" {
+		if isMember {
+			return "member", r.isContainerValue(object, node)
+		}
+		if r.insideContainer(node) && !r.shadowed(node) {
+			return "reference", true
+		}
+		return "", false
+	}
+
+	if isMember {
+		if r.qualifiesTarget(object) {
+			return "reference", true
+		}
+		return "", false
+	}
+	if memberIdentifierTypes[node.Type()] || !r.bareNamesVisible() || r.shadowed(node) {
+		return "", false
+	}
+	return "reference", true
+}
+
+// bareNamesVisible reports whether the bare name of a top-level target
+// refers to it in this file: it is declared in the same package or module,
+// or imported by its name
+func (r *renamer) bareNamesVisible() bool {
+	return r.scope.Local || r.imports.wildcard || r.imports.names[r.target.Name] == r.target.Name
+}
+
+// insideContainer reports whether node lies in a declaration of the
+// target's container
+func (r *renamer) insideContainer(node *sitter.Node) bool {
+	start := int(node.StartByte())
+	for _, container := range r.containers {
+		if start >= container.DeclStart && start < container.End {
+			return true
+		}
+	}
+	return false
+}
+
+// qualifiesTarget reports whether a member access object is an import of
+// the target's package or module that no local declaration hides
+func (r *renamer) qualifiesTarget(object *sitter.Node) bool {
+	if object == nil || !r.imports.qualifiers[object.Content(r.source)] {
+		return false
+	}
+	leftmost := object
+	for leftmost.NamedChildCount() > 0 {
+		leftmost = leftmost.NamedChild(0)
+	}
+	_, shadowed := r.lookupBinding(object, leftmost.Content(r.source), false)
+	return !shadowed
+}
+
+// isContainerValue reports whether a member access object is known to be
+// the target's container or a value of it: self or this inside the
+// container, the container itself, or a variable declared with its type
+func (r *renamer) isContainerValue(object *sitter.Node, node *sitter.Node) bool {
+	if object == nil {
+		return false
+	}
+	switch object.Type() {
+	case "this", "self", "this_expression":
+		return r.insideContainer(node)
+	case "identifier", "simple_identifier", "constant", "type_identifier":
+	default:
+		return false
+	}
+
+	name := object.Content(r.source)
+	if (name == "self" || name == "this") && r.insideContainer(node) {
+		return true
+	}
+	if typ, ok := r.lookupBinding(object, name, true); ok {
+		return r.isContainerType(typ)
+	}
+	return r.namesContainer("", name)
+}
+
+// isContainerType reports whether the source of a declared type, such as
+// *Store, store.Store or List<Store>, is the target's container. Element
+// types of slices and generics do not count.
+func (r *renamer) isContainerType(typ string) bool {
+	typ = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(typ), ":"))
+	typ = strings.TrimLeft(typ, "*&? ")
+	if idx := strings.IndexAny(typ, "<["); idx >= 0 {
+		typ = typ[:idx]
+	}
+	qualifier, name := "", typ
+	if idx := strings.LastIndex(typ, "::"); idx >= 0 {
+		qualifier, name = typ[:idx], typ[idx+2:]
+	} else if idx := strings.LastIndex(typ, "."); idx >= 0 {
+		qualifier, name = typ[:idx], typ[idx+1:]
+	}
+	return r.namesContainer(qualifier, strings.TrimSpace(name))
+}
+
+// namesContainer reports whether a possibly qualified type name refers to
+// the target's container in this file
+func (r *renamer) namesContainer(qualifier, name string) bool {
+	container := r.target.Container
+	if idx := strings.LastIndex(container, "."); idx >= 0 {
+		container = container[idx+1:]
+	}
+	if qualifier != "" {
+		return name == container && r.imports.qualifiers[qualifier]
+	}
+	if original, ok := r.imports.names[name]; ok {
+		return original == container
+	}
+	return name == container && (r.scope.Local || r.imports.wildcard)
+}
+
+// sameNode reports whether two nodes span the same bytes
+func sameNode(a, b *sitter.Node) bool {
+	return a != nil && b != nil && a.StartByte() == b.StartByte() && a.EndByte() == b.EndByte() && a.Type() == b.Type()
+}
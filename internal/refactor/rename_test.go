@@ -0,0 +1,301 @@
+package refactor
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRenameTopLevelFunction(t *testing.T) {
+	content := `package store
+
+// Load reads a value; "Load" in strings and comments stays
+func Load(key string) int {
+	return 0
+}
+
+func use(Load int) int {
+	return Load + 1
+}
+
+func call(s *Store) int {
+	fmt.Println("Load")
+	return Load("a") + store.Load("b") + s.Load()
+}
+`
+	result, err := Rename("go", content, Target{Name: "Load"}, Scope{Local: true}, "Fetch")
+	if err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	expected := `package store
+
+// Load reads a value; "Load" in strings and comments stays
+func Fetch(key string) int {
+	return 0
+}
+
+func use(Load int) int {
+	return Load + 1
+}
+
+func call(s *Store) int {
+	fmt.Println("Load")
+	return Fetch("a") + store.Load("b") + s.Load()
+}
+`
+	if result.Content != expected {
+		t.Errorf("Unexpected rename:\n%s", result.Content)
+	}
+	if len(result.Occurrences) != 2 || result.Occurrences[0].Kind != "definition" || result.Occurrences[0].Line != 4 || result.Occurrences[0].Column != 6 {
+		t.Errorf("Unexpected occurrences: %+v", result.Occurrences)
+	}
+}
+
+func TestRenameMethod(t *testing.T) {
+	content := `class Store:
+    def load(self, key):
+        return self.cache.get(key)
+
+    def refresh(self):
+        load = self.load
+        return self.load("a")
+
+def load(path):
+    return open(path)
+`
+	result, err := Rename("python", content, Target{Name: "load", Container: "Store"}, Scope{Local: true}, "fetch")
+	if err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	expected := `class Store:
+    def fetch(self, key):
+        return self.cache.get(key)
+
+    def refresh(self):
+        load = self.fetch
+        return self.fetch("a")
+
+def load(path):
+    return open(path)
+`
+	if result.Content != expected {
+		t.Errorf("Unexpected rename:\n%s", result.Content)
+	}
+	if len(result.Homonyms) != 1 || result.Homonyms[0].Container != "" {
+		t.Errorf("Expected the top-level load as a homonym, got %+v", result.Homonyms)
+	}
+}
+
+func TestRenameChecks(t *testing.T) {
+	content := "function load() {}\nfunction fetch() {}\nload();\n"
+
+	result, err := Rename("javascript", content, Target{Name: "load"}, Scope{Local: true}, "fetch")
+	if err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if len(result.Collisions) != 1 || result.Collisions[0].StartLine != 2 {
+		t.Errorf("Expected a collision with fetch, got %+v", result.Collisions)
+	}
+
+	if _, err := Rename("javascript", content, Target{Name: "load"}, Scope{Local: true}, "fetch-all"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Expected ErrInvalidName, got: %v", err)
+	}
+}
+
+func TestRenameInImportingFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		target   Target
+		scope    Scope
+		content  string
+		expected string
+	}{
+		{
+			name:     "go qualified by the import",
+			language: "go",
+			target:   Target{Name: "Load", Module: Module{Path: "example.com/m/store", Name: "store"}},
+			content: `package app
+
+import (
+	"example.com/m/store"
+	legacy "example.com/m/legacy/store"
+)
+
+func run(s *Store) {
+	store.Load()
+	legacy.Load()
+	s.Load()
+	Load()
+}
+`,
+			expected: `package app
+
+import (
+	"example.com/m/store"
+	legacy "example.com/m/legacy/store"
+)
+
+func run(s *Store) {
+	store.Fetch()
+	legacy.Load()
+	s.Load()
+	Load()
+}
+`,
+		},
+		{
+			name:     "go import alias shadowed by a parameter",
+			language: "go",
+			target:   Target{Name: "Load", Module: Module{Path: "example.com/m/store", Name: "store"}},
+			content:  "package app\n\nimport db \"example.com/m/store\"\n\nfunc a() { db.Load() }\n\nfunc b(db *DB) { db.Load() }\n",
+			expected: "package app\n\nimport db \"example.com/m/store\"\n\nfunc a() { db.Fetch() }\n\nfunc b(db *DB) { db.Load() }\n",
+		},
+		{
+			name:     "python imported by name",
+			language: "python",
+			target:   Target{Name: "load", Module: Module{Path: "pkg.store"}},
+			scope:    Scope{Module: Module{Path: "pkg.app", Dir: "pkg"}},
+			content:  "from .store import load\nfrom pkg.other import load as other\n\nload()\nother()\n",
+			expected: "from .store import fetch\nfrom pkg.other import load as other\n\nfetch()\nother()\n",
+		},
+		{
+			name:     "python module import",
+			language: "python",
+			target:   Target{Name: "load", Module: Module{Path: "pkg.store"}},
+			scope:    Scope{Module: Module{Path: "app"}},
+			content:  "import pkg.store\nfrom pkg import store as s\n\npkg.store.load()\ns.load()\nload()\n",
+			expected: "import pkg.store\nfrom pkg import store as s\n\npkg.store.fetch()\ns.fetch()\nload()\n",
+		},
+		{
+			name:     "python aliased import keeps the alias",
+			language: "python",
+			target:   Target{Name: "load", Module: Module{Path: "pkg.store"}},
+			content:  "from pkg.store import load as ld\n\nld()\nload()\n",
+			expected: "from pkg.store import fetch as ld\n\nld()\nload()\n",
+		},
+		{
+			name:     "javascript relative imports",
+			language: "javascript",
+			target:   Target{Name: "load", Module: Module{Path: "src/store"}},
+			scope:    Scope{Module: Module{Path: "src/app/main", Dir: "src/app"}},
+			content:  "import { load } from '../store';\nimport * as other from './store';\nconst store = require('../store.js');\n\nload();\nother.load();\nstore.load();\n",
+			expected: "import { fetch } from '../store';\nimport * as other from './store';\nconst store = require('../store.js');\n\nfetch();\nother.load();\nstore.fetch();\n",
+		},
+		{
+			name:     "file without an import",
+			language: "go",
+			target:   Target{Name: "Load", Module: Module{Path: "example.com/m/store", Name: "store"}},
+			content:  "package app\n\nfunc Load() {}\n\nfunc run() { Load(); store.Load() }\n",
+			expected: "package app\n\nfunc Load() {}\n\nfunc run() { Load(); store.Load() }\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newName := "fetch"
+			if tt.language == "go" {
+				newName = "Fetch"
+			}
+			result, err := Rename(tt.language, tt.content, tt.target, tt.scope, newName)
+			if err != nil {
+				t.Fatalf("Rename failed: %v", err)
+			}
+			if result.Content != tt.expected {
+				t.Errorf("Unexpected rename:\n%s", result.Content)
+			}
+			if len(result.Collisions) != 0 {
+				t.Errorf("Expected no collisions outside the target's package, got %+v", result.Collisions)
+			}
+		})
+	}
+}
+
+func TestRenameMethodReceivers(t *testing.T) {
+	content := `package store
+
+type Store struct{}
+
+func (s *Store) Load() {}
+
+func (s *Store) Refresh() {
+	s.Load()
+}
+
+func use(st *Store, c *Cache, items []Store) {
+	st.Load()
+	c.Load()
+	get().Load()
+	items[0].Load()
+}
+`
+	result, err := Rename("go", content, Target{Name: "Load", Container: "Store"}, Scope{Local: true}, "Fetch")
+	if err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	expected := `package store
+
+type Store struct{}
+
+func (s *Store) Fetch() {}
+
+func (s *Store) Refresh() {
+	s.Fetch()
+}
+
+func use(st *Store, c *Cache, items []Store) {
+	st.Fetch()
+	c.Load()
+	get().Load()
+	items[0].Load()
+}
+`
+	if result.Content != expected {
+		t.Errorf("Unexpected rename:\n%s", result.Content)
+	}
+	var unresolved []int
+	for _, occurrence := range result.Unresolved {
+		unresolved = append(unresolved, occurrence.Line)
+	}
+	if !slices.Equal(unresolved, []int{13, 14, 15}) {
+		t.Errorf("Expected the accesses on unknown receivers to be reported, got lines %v", unresolved)
+	}
+}
+
+func TestRenameMethodFromImportingFile(t *testing.T) {
+	content := `from pkg.store import Store
+from pkg import other
+
+
+def run(store: Store, cache: other.Store, value):
+    Store.load()
+    store.load()
+    cache.load()
+    value.load()
+`
+	target := Target{Name: "load", Container: "Store", Module: Module{Path: "pkg.store"}}
+	result, err := Rename("python", content, target, Scope{Module: Module{Path: "pkg.app", Dir: "pkg"}}, "fetch")
+	if err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	expected := `from pkg.store import Store
+from pkg import other
+
+
+def run(store: Store, cache: other.Store, value):
+    Store.fetch()
+    store.fetch()
+    cache.load()
+    value.load()
+`
+	if result.Content != expected {
+		t.Errorf("Unexpected rename:\n%s", result.Content)
+	}
+	if len(result.Unresolved) != 2 {
+		t.Errorf("Expected 2 unresolved accesses, got %+v", result.Unresolved)
+	}
+}
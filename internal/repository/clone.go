@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidSparsePattern is returned for sparse checkout patterns that are
+// not a directory inside the repository
+var ErrInvalidSparsePattern = errors.New("invalid sparse checkout pattern")
+
+// CloneOptions control how a remote repository is cloned. Options given
+// for one repository take precedence over the defaults set with
+// SetCloneOptions where they are set.
+type CloneOptions struct {
+	Depth          int      // Commits fetched from the tip of each branch, 0 for the whole history
+	SingleBranch   bool     // Fetch only the branch or ref checked out
+	SparsePatterns []string // Directories checked out, relative to the repository root; all files when empty
+}
+
+// partial reports whether a clone leaves out history, branches or files.
+// Such clones do not go through the object cache, whose mirrors always
+// hold everything.
+func (o CloneOptions) partial() bool {
+	return o.Depth > 0 || o.SingleBranch || len(o.SparsePatterns) > 0
+}
+
+// withDefaults returns the options with the unset ones taken from defaults
+func (o CloneOptions) withDefaults(defaults CloneOptions) CloneOptions {
+	if o.Depth == 0 {
+		o.Depth = defaults.Depth
+	}
+	o.SingleBranch = o.SingleBranch || defaults.SingleBranch
+	if len(o.SparsePatterns) == 0 {
+		o.SparsePatterns = defaults.SparsePatterns
+	}
+	return o
+}
+
+// Credential authenticates clones and fetches from one host: HTTPS URLs
+// with a token, SSH URLs with a private key file
+type Credential struct {
+	Host             string
+	Username         string // Sent with the token, "git" when empty
+	Token            string
+	SSHKey           string // Path of the private key
+	SSHKeyPassphrase string
+}
+
+// hostAuth holds the authentication methods of a host, nil where none
+// was configured
+type hostAuth struct {
+	http transport.AuthMethod
+	ssh  transport.AuthMethod
+}
+
+// SetCloneOptions sets the options remote repositories are cloned with and
+// the credentials they are cloned and fetched with. SSH keys are read here,
+// so a missing key or wrong passphrase is reported at startup rather than
+// on the first clone.
+func (m *Manager) SetCloneOptions(defaults CloneOptions, credentials []Credential) error {
+	patterns, err := SparseDirectories(defaults.SparsePatterns)
+	if err != nil {
+		return err
+	}
+	defaults.SparsePatterns = patterns
+
+	auth := make(map[string]hostAuth, len(credentials))
+	for _, credential := range credentials {
+		host := strings.ToLower(credential.Host)
+		methods := auth[host]
+		if credential.Token != "" {
+			username := credential.Username
+			if username == "" {
+				username = "git"
+			}
+			methods.http = &http.BasicAuth{Username: username, Password: credential.Token}
+		}
+		if credential.SSHKey != "" {
+			keys, err := ssh.NewPublicKeysFromFile("git", credential.SSHKey, credential.SSHKeyPassphrase)
+			if err != nil {
+				return fmt.Errorf("failed to load SSH key %s for %s: %w", credential.SSHKey, credential.Host, err)
+			}
+			methods.ssh = keys
+		}
+		auth[host] = methods
+	}
+
+	m.cloneDefaults = defaults
+	m.auth = auth
+	m.logger.Debug("Clone options set",
+		zap.Int("depth", defaults.Depth),
+		zap.Bool("single_branch", defaults.SingleBranch),
+		zap.Int("credentials", len(auth)))
+	return nil
+}
+
+// authFor returns the credentials to clone or fetch repoURL with, nil when
+// none are configured for its host
+func (m *Manager) authFor(repoURL string) transport.AuthMethod {
+	remote, ok := parseRemote(repoURL)
+	if !ok {
+		return nil
+	}
+	methods := m.auth[strings.ToLower(remote.host)]
+	if remote.scheme == "ssh" {
+		return methods.ssh
+	}
+	if remote.scheme == "http" || remote.scheme == "https" {
+		return methods.http
+	}
+	return nil
+}
+
+// remoteURL is a repository URL taken apart
+type remoteURL struct {
+	scheme string // "http", "https", "git" or "ssh"
+	host   string
+	path   string
+}
+
+// scpURL matches the scp-like syntax of SSH URLs, user@host:path
+var scpURL = regexp.MustCompile(`^[\w.-]+@([\w.-]+):(.+)$`)
+
+// IsRemote reports whether a repository path is a URL to clone rather than
+// a local directory
+func IsRemote(path string) bool {
+	_, ok := parseRemote(path)
+	return ok
+}
+
+// parseRemote takes apart a repository URL. ok is false for local paths
+// and URLs of other schemes.
+func parseRemote(repoURL string) (remoteURL, bool) {
+	if match := scpURL.FindStringSubmatch(repoURL); match != nil {
+		return remoteURL{scheme: "ssh", host: match[1], path: match[2]}, true
+	}
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return remoteURL{}, false
+	}
+	switch u.Scheme {
+	case "http", "https", "git", "ssh":
+		return remoteURL{scheme: u.Scheme, host: u.Hostname(), path: u.Path}, true
+	}
+	return remoteURL{}, false
+}
+
+// SparseDirectories cleans sparse checkout patterns into the directory
+// prefixes checked out, each ending in a slash. Patterns are directories
+// relative to the repository root, such as "services/api"; files outside
+// all of them, those in the root directory included, are not checked out.
+func SparseDirectories(patterns []string) ([]string, error) {
+	var dirs []string
+	for _, pattern := range patterns {
+		cleaned := path.Clean(strings.ReplaceAll(strings.TrimSpace(pattern), `\`, "/"))
+		if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") || path.IsAbs(cleaned) || strings.ContainsAny(cleaned, "*?[") {
+			return nil, fmt.Errorf("%w: %q, use a directory relative to the repository root", ErrInvalidSparsePattern, pattern)
+		}
+		if dir := cleaned + "/"; !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs, nil
+}
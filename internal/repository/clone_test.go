@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"go.uber.org/zap"
+)
+
+func TestPartialClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "project")
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = sourceDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(name, content string) {
+		path := filepath.Join(sourceDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+
+	write("README.md", "# project\n")
+	write("services/api/main.go", "package main // v1\n")
+	write("services/web/main.go", "package main\n")
+	git("init", "--quiet", "--initial-branch=main")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	first := git("rev-parse", "HEAD")
+	write("services/api/main.go", "package main // v2\n")
+	git("commit", "--quiet", "-am", "second")
+	git("branch", "other")
+
+	manager, err := NewManager(filepath.Join(tempDir, "repositories"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := manager.AllowLocalRepositories(tempDir); err != nil {
+		t.Fatalf("Failed to allow local repositories: %v", err)
+	}
+
+	ctx := context.Background()
+	opts := CloneOptions{Depth: 1, SingleBranch: true, SparsePatterns: []string{"services/api"}}
+	repo, err := manager.PrepareRepositoryWithOptions(ctx, sourceDir, "api", "main", opts)
+	if err != nil {
+		t.Fatalf("PrepareRepositoryWithOptions failed: %v", err)
+	}
+	if repo.IndexingMode != "sparse" || len(repo.SparsePatterns) != 1 || repo.SparsePatterns[0] != "services/api/" {
+		t.Errorf("Expected a sparse repository, got %s with %v", repo.IndexingMode, repo.SparsePatterns)
+	}
+	if !exists(filepath.Join(repo.Path, "services", "api", "main.go")) {
+		t.Error("Expected the sparse directory to be checked out")
+	}
+	if exists(filepath.Join(repo.Path, "services", "web")) || exists(filepath.Join(repo.Path, "README.md")) {
+		t.Error("Expected files outside the sparse directory to be left out")
+	}
+	if !exists(filepath.Join(repo.Path, ".git", "shallow")) {
+		t.Error("Expected a shallow clone")
+	}
+	if _, err := manager.GetCommitHistory(repo.Path, first, 10); !errors.Is(err, ErrCommitNotFound) {
+		t.Errorf("Expected the first commit to be left out of a clone of depth 1, got %v", err)
+	}
+
+	// Updates keep the clone sparse
+	write("services/api/main.go", "package main // v3\n")
+	write("services/web/main.go", "package main // changed\n")
+	git("commit", "--quiet", "-am", "third")
+	updated, err := manager.PrepareRepositoryWithOptions(ctx, sourceDir, "api", "main", opts)
+	if err != nil {
+		t.Fatalf("PrepareRepositoryWithOptions failed: %v", err)
+	}
+	content, _ := os.ReadFile(filepath.Join(updated.Path, "services", "api", "main.go"))
+	if string(content) != "package main // v3\n" || updated.LastIndexedHash != git("rev-parse", "HEAD") {
+		t.Errorf("Expected the latest commit to be checked out, got %q at %s", content, updated.LastIndexedHash)
+	}
+	if exists(filepath.Join(updated.Path, "services", "web", "main.go")) {
+		t.Error("Expected the update to keep files outside the sparse directory out")
+	}
+
+	// A commit cannot be fetched on its own, so the whole history is
+	commit, err := manager.PrepareRepositoryWithOptions(ctx, sourceDir, "first", first, CloneOptions{Depth: 1, SingleBranch: true})
+	if err != nil {
+		t.Fatalf("PrepareRepositoryWithOptions failed: %v", err)
+	}
+	if commit.LastIndexedHash != first || commit.IndexingMode != "full" {
+		t.Errorf("Expected %s to be checked out in full, got %+v", first, commit)
+	}
+
+	// Defaults apply to clones that set nothing
+	if err := manager.SetCloneOptions(CloneOptions{SingleBranch: true}, nil); err != nil {
+		t.Fatalf("SetCloneOptions failed: %v", err)
+	}
+	single, err := manager.PrepareRepositoryAt(ctx, sourceDir, "single", "main")
+	if err != nil {
+		t.Fatalf("PrepareRepositoryAt failed: %v", err)
+	}
+	if _, err := resolveBranch(t, single.Path, "other"); err == nil {
+		t.Error("Expected a single-branch clone to leave out other branches")
+	}
+
+	if _, err := manager.PrepareRepositoryWithOptions(ctx, sourceDir, "bad", "main", CloneOptions{SparsePatterns: []string{"../outside"}}); !errors.Is(err, ErrInvalidSparsePattern) {
+		t.Errorf("Expected ErrInvalidSparsePattern, got %v", err)
+	}
+}
+
+// resolveBranch resolves a branch fetched from origin in a clone
+func resolveBranch(t *testing.T, repoPath, branch string) (string, error) {
+	t.Helper()
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch)
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	return strings.TrimSpace(string(output)), err
+}
+
+func TestSparseDirectories(t *testing.T) {
+	dirs, err := SparseDirectories([]string{"services/api", `docs\guide\`, "./services/api/"})
+	if err != nil {
+		t.Fatalf("SparseDirectories failed: %v", err)
+	}
+	if strings.Join(dirs, ",") != "services/api/,docs/guide/" {
+		t.Errorf("Expected cleaned, deduplicated directories, got %v", dirs)
+	}
+
+	for _, pattern := range []string{".", "..", "../x", "/abs", "src/*.go", ""} {
+		if _, err := SparseDirectories([]string{pattern}); !errors.Is(err, ErrInvalidSparsePattern) {
+			t.Errorf("Expected %q to be refused, got %v", pattern, err)
+		}
+	}
+}
+
+func TestCloneCredentials(t *testing.T) {
+	manager, err := NewManager(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	err = manager.SetCloneOptions(CloneOptions{}, []Credential{{Host: "GitHub.com", Token: "secret"}})
+	if err != nil {
+		t.Fatalf("SetCloneOptions failed: %v", err)
+	}
+
+	auth, ok := manager.authFor("https://github.com/acme/private.git").(*http.BasicAuth)
+	if !ok || auth.Username != "git" || auth.Password != "secret" {
+		t.Errorf("Expected the token for github.com, got %v", manager.authFor("https://github.com/acme/private.git"))
+	}
+	for _, repoURL := range []string{"https://gitlab.com/acme/private.git", "git@github.com:acme/private.git", "/local/path"} {
+		if auth := manager.authFor(repoURL); auth != nil {
+			t.Errorf("Expected no credentials for %s, got %v", repoURL, auth)
+		}
+	}
+
+	if err := manager.SetCloneOptions(CloneOptions{}, []Credential{{Host: "github.com", SSHKey: "/missing/id_ed25519"}}); err == nil {
+		t.Error("Expected a missing SSH key to be reported")
+	}
+
+	// SSH URLs in scp syntax are remote and named like other URLs
+	if name := manager.generateRepoName("git@github.com:acme/private.git"); name != "acme-private" {
+		t.Errorf("Expected acme-private, got %s", name)
+	}
+	if _, ok := parseRemote(`C:\repos\project`); ok {
+		t.Error("Expected a Windows path to be local")
+	}
+}
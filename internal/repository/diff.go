@@ -0,0 +1,413 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Sides of a diff that are not commits
+const (
+	DiffWorkingTree = "working_tree"
+	DiffIndex       = "index"
+)
+
+// DiffOptions selects what Diff compares and how much of it is returned
+type DiffOptions struct {
+	From     string   // Commit, branch or tag the diff starts from, HEAD when empty
+	To       string   // Commit, branch or tag to compare with; when empty the working tree, or the index with Staged
+	Staged   bool     // Compare with the staged changes instead of the working tree
+	Paths    []string // Only files at or below these slash-separated paths; all files when empty
+	Context  int      // Unchanged lines shown around each change
+	Summary  bool     // Only count added and removed lines, leaving out the hunks
+	MaxLines int      // Hunk lines returned at most, unlimited when zero
+}
+
+// fileVersion is the content of a file on one side of a diff; ok is false
+// when the file does not exist there
+type fileVersion struct {
+	content []byte
+	ok      bool
+}
+
+// Diff compares two versions of the repository at repoPath and returns the
+// files that differ with their hunks. Untracked files are not part of the
+// working tree diff, as with git diff. Renamed files show up as deleted
+// under the old path and added under the new one.
+func (m *Manager) Diff(repoPath string, options DiffOptions) (*types.GitDiff, error) {
+	if options.To != "" && options.Staged {
+		return nil, errors.New("a diff compares with either a commit or the staged changes")
+	}
+
+	repo, err := openRepository(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	from := options.From
+	if from == "" {
+		from = "HEAD"
+	}
+	fromCommit, err := resolveCommit(repo, from)
+	if err != nil {
+		return nil, err
+	}
+	fromTree, err := fromCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of %s: %w", from, err)
+	}
+
+	result := &types.GitDiff{From: fromCommit.Hash.String(), Files: []types.FileDiff{}}
+	var paths []string
+	var newVersion func(path string) (fileVersion, error)
+
+	if options.To != "" {
+		toCommit, err := resolveCommit(repo, options.To)
+		if err != nil {
+			return nil, err
+		}
+		toTree, err := toCommit.Tree()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tree of %s: %w", options.To, err)
+		}
+		result.To = toCommit.Hash.String()
+		if paths, err = changedPaths(fromTree, toTree); err != nil {
+			return nil, err
+		}
+		newVersion = func(path string) (fileVersion, error) {
+			return treeVersion(toTree, path)
+		}
+	} else {
+		// Files that differ from the start commit differ from HEAD or
+		// changed since HEAD, so comparing those is enough
+		if paths, err = pathsChangedSinceHead(repo, fromTree, options.Staged); err != nil {
+			return nil, err
+		}
+		if options.Staged {
+			result.To = DiffIndex
+			newVersion = func(path string) (fileVersion, error) {
+				return indexVersion(repo, path)
+			}
+		} else {
+			result.To = DiffWorkingTree
+			newVersion = func(path string) (fileVersion, error) {
+				return diskVersion(repoPath, path)
+			}
+		}
+	}
+
+	linesLeft := options.MaxLines
+	for _, path := range paths {
+		if !selectsPath(options.Paths, path) {
+			continue
+		}
+		before, err := treeVersion(fromTree, path)
+		if err != nil {
+			return nil, err
+		}
+		after, err := newVersion(path)
+		if err != nil {
+			return nil, err
+		}
+		if before.ok == after.ok && bytes.Equal(before.content, after.content) {
+			continue
+		}
+
+		file := types.FileDiff{Path: path, Status: "modified"}
+		switch {
+		case !before.ok:
+			file.Status = "added"
+		case !after.ok:
+			file.Status = "deleted"
+		}
+		if isBinaryContent(before.content) || isBinaryContent(after.content) {
+			file.Binary = true
+		} else {
+			ops := lineOps(string(before.content), string(after.content))
+			for _, op := range ops {
+				switch op.kind {
+				case '+':
+					file.Additions++
+				case '-':
+					file.Deletions++
+				}
+			}
+			if !options.Summary && !result.Truncated {
+				file.Hunks = buildHunks(ops, options.Context)
+				if options.MaxLines > 0 {
+					file.Hunks, linesLeft = limitHunks(file.Hunks, linesLeft)
+					result.Truncated = linesLeft < 0
+				}
+			}
+		}
+
+		result.Files = append(result.Files, file)
+		result.Additions += file.Additions
+		result.Deletions += file.Deletions
+	}
+	result.FilesChanged = len(result.Files)
+	return result, nil
+}
+
+// resolveCommit returns the commit a branch, tag or commit hash names
+func resolveCommit(repo *git.Repository, ref string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCommitNotFound, ref)
+	}
+	return commit, nil
+}
+
+// changedPaths returns the sorted paths of the files that differ between two
+// trees
+func changedPaths(fromTree, toTree *object.Tree) ([]string, error) {
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare trees: %w", err)
+	}
+	seen := make(map[string]bool)
+	var paths []string
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				paths = append(paths, name)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// pathsChangedSinceHead returns the sorted paths of the files that differ
+// between fromTree and HEAD together with those changed in the index, or
+// with staged false in the index or the working tree. Untracked files are
+// left out.
+func pathsChangedSinceHead(repo *git.Repository, fromTree *object.Tree, staged bool) ([]string, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD: %w", err)
+	}
+	headCommit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HEAD commit: %w", err)
+	}
+	headTree, err := headCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of HEAD: %w", err)
+	}
+	paths, err := changedPaths(fromTree, headTree)
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree status: %w", err)
+	}
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		seen[path] = true
+	}
+	for path, fileStatus := range status {
+		if fileStatus.Staging == git.Untracked || seen[path] {
+			continue
+		}
+		if fileStatus.Staging != git.Unmodified || (!staged && fileStatus.Worktree != git.Unmodified) {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// treeVersion returns the content of a file in a tree
+func treeVersion(tree *object.Tree, path string) (fileVersion, error) {
+	file, err := tree.File(path)
+	if errors.Is(err, object.ErrFileNotFound) {
+		return fileVersion{}, nil
+	}
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return blobVersion(&file.Blob, path)
+}
+
+// indexVersion returns the content of a file in the index
+func indexVersion(repo *git.Repository, path string) (fileVersion, error) {
+	index, err := repo.Storer.Index()
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to read index: %w", err)
+	}
+	entry, err := index.Entry(path)
+	if err != nil {
+		return fileVersion{}, nil
+	}
+	blob, err := repo.BlobObject(entry.Hash)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to read staged %s: %w", path, err)
+	}
+	return blobVersion(blob, path)
+}
+
+// blobVersion returns the content of a blob
+func blobVersion(blob *object.Blob, path string) (fileVersion, error) {
+	reader, err := blob.Reader()
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return fileVersion{content: content, ok: true}, nil
+}
+
+// diskVersion returns the content of a file in the working tree
+func diskVersion(repoPath, path string) (fileVersion, error) {
+	content, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(path)))
+	if errors.Is(err, os.ErrNotExist) {
+		return fileVersion{}, nil
+	}
+	if err != nil {
+		return fileVersion{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return fileVersion{content: content, ok: true}, nil
+}
+
+// selectsPath reports whether path is at or below one of the given paths
+func selectsPath(selected []string, path string) bool {
+	if len(selected) == 0 {
+		return true
+	}
+	for _, prefix := range selected {
+		prefix = strings.Trim(filepath.ToSlash(prefix), "/")
+		if prefix == "" || prefix == "." || path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// lineOp is one line of a line diff: kind is ' ' for an unchanged line, '+'
+// for an added and '-' for a removed one
+type lineOp struct {
+	kind byte
+	text string
+}
+
+// lineOps diffs two texts line by line
+func lineOps(before, after string) []lineOp {
+	var ops []lineOp
+	for _, d := range diff.Do(before, after) {
+		kind := byte(' ')
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			kind = '+'
+		case diffmatchpatch.DiffDelete:
+			kind = '-'
+		}
+		for _, line := range strings.SplitAfter(d.Text, "\n") {
+			if line != "" {
+				ops = append(ops, lineOp{kind: kind, text: strings.TrimSuffix(line, "\n")})
+			}
+		}
+	}
+	return ops
+}
+
+// buildHunks groups the changed lines of a line diff into hunks with up to
+// context unchanged lines around them. Changes closer than twice the
+// context share a hunk.
+func buildHunks(ops []lineOp, context int) []types.DiffHunk {
+	// The old and new line numbers of every op
+	oldLines := make([]int, len(ops))
+	newLines := make([]int, len(ops))
+	oldLine, newLine := 1, 1
+	for idx, op := range ops {
+		oldLines[idx], newLines[idx] = oldLine, newLine
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+
+	var hunks []types.DiffHunk
+	for idx := 0; idx < len(ops); {
+		if ops[idx].kind == ' ' {
+			idx++
+			continue
+		}
+
+		// Extend the hunk over changes separated by few unchanged lines
+		end := idx + 1
+		for next := end; next < len(ops); next++ {
+			if ops[next].kind != ' ' {
+				end = next + 1
+			} else if next-end+1 > 2*context {
+				break
+			}
+		}
+		start := max(0, idx-context)
+		stop := min(len(ops), end+context)
+
+		hunk := types.DiffHunk{OldStart: oldLines[start], NewStart: newLines[start]}
+		for _, op := range ops[start:stop] {
+			hunk.Lines = append(hunk.Lines, string(op.kind)+op.text)
+			if op.kind != '+' {
+				hunk.OldLines++
+			}
+			if op.kind != '-' {
+				hunk.NewLines++
+			}
+		}
+		// Like git, an empty side starts at the line before it
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+		hunk.Header = fmt.Sprintf("@@ -%d,%d +%d,%d @@", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)
+		hunks = append(hunks, hunk)
+		idx = stop
+	}
+	return hunks
+}
+
+// limitHunks keeps the hunks that fit in linesLeft lines and returns the
+// lines left, which turn negative once a hunk was dropped
+func limitHunks(hunks []types.DiffHunk, linesLeft int) ([]types.DiffHunk, int) {
+	for idx, hunk := range hunks {
+		if len(hunk.Lines) > linesLeft {
+			return hunks[:idx], -1
+		}
+		linesLeft -= len(hunk.Lines)
+	}
+	return hunks, linesLeft
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repoDir := t.TempDir()
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(repoDir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var lines []string
+	for n := 1; n <= 20; n++ {
+		lines = append(lines, fmt.Sprintf("line %d", n))
+	}
+	original := strings.Join(lines, "\n") + "\n"
+
+	git("init", "--quiet")
+	write("main.go", original)
+	write("old.go", "package old\n")
+	write("docs/readme.md", "# Title\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	first := git("rev-parse", "HEAD")
+
+	lines[1] = "second"
+	lines[17] = "eighteenth"
+	write("main.go", strings.Join(lines, "\n")+"\n")
+	git("rm", "--quiet", "old.go")
+	write("new.go", "package new\n\nfunc New() {}\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "change")
+
+	manager, err := NewManager(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	diff, err := manager.Diff(repoDir, DiffOptions{From: first, To: "HEAD", Context: 3})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, file := range diff.Files {
+		statuses[file.Path] = file.Status
+	}
+	if len(statuses) != 3 || statuses["main.go"] != "modified" || statuses["new.go"] != "added" || statuses["old.go"] != "deleted" {
+		t.Fatalf("Expected main.go, new.go and old.go to differ, got %v", statuses)
+	}
+	if diff.Additions != 5 || diff.Deletions != 3 {
+		t.Errorf("Expected 5 lines added and 3 removed, got +%d -%d", diff.Additions, diff.Deletions)
+	}
+
+	// Changes far apart get a hunk each
+	main := diff.Files[0]
+	if len(main.Hunks) != 2 {
+		t.Fatalf("Expected 2 hunks for main.go, got %+v", main.Hunks)
+	}
+	if header := main.Hunks[0].Header; header != "@@ -1,5 +1,5 @@" {
+		t.Errorf("Expected the first hunk to cover lines 1-5, got %s", header)
+	}
+	if header := main.Hunks[1].Header; header != "@@ -15,6 +15,6 @@" {
+		t.Errorf("Expected the second hunk to cover lines 15-20, got %s", header)
+	}
+	if got := strings.Join(main.Hunks[0].Lines, "|"); got != " line 1|-line 2|+second| line 3| line 4| line 5" {
+		t.Errorf("Unexpected hunk lines: %s", got)
+	}
+	if header := diff.Files[1].Hunks[0].Header; header != "@@ -0,0 +1,3 @@" {
+		t.Errorf("Expected the added file to start at line 0 on the old side, got %s", header)
+	}
+
+	// Working tree and staged changes
+	write("docs/readme.md", "# Title\n\nMore.\n")
+	write("staged.go", "package staged\n")
+	git("add", "staged.go")
+	write("untracked.go", "package untracked\n")
+
+	diff, err = manager.Diff(repoDir, DiffOptions{})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(diff.Files) != 2 || diff.Files[0].Path != "docs/readme.md" || diff.Files[1].Path != "staged.go" || diff.To != DiffWorkingTree {
+		t.Errorf("Expected the modified and the staged file, got %+v", diff.Files)
+	}
+
+	diff, err = manager.Diff(repoDir, DiffOptions{Staged: true, Summary: true})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(diff.Files) != 1 || diff.Files[0].Path != "staged.go" || diff.Files[0].Additions != 1 || diff.Files[0].Hunks != nil {
+		t.Errorf("Expected only the staged file without hunks, got %+v", diff.Files)
+	}
+
+	diff, err = manager.Diff(repoDir, DiffOptions{From: first, Paths: []string{"docs"}})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(diff.Files) != 1 || diff.Files[0].Path != "docs/readme.md" {
+		t.Errorf("Expected only the files below docs, got %+v", diff.Files)
+	}
+
+	diff, err = manager.Diff(repoDir, DiffOptions{From: first, To: "HEAD", MaxLines: 4})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if !diff.Truncated || diff.Additions != 5 {
+		t.Errorf("Expected hunks to be truncated with the counts kept, got %+v", diff)
+	}
+
+	if _, err := manager.Diff(repoDir, DiffOptions{From: "missing"}); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("Expected ErrRefNotFound for an unknown ref, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// binarySniffLength is how much of a file is read to tell whether it is
+// binary, the same amount git looks at
+const binarySniffLength = 8000
+
+// FileFilter selects the files WalkFiles passes on, besides skipping those
+// ignored by .gitignore. Patterns are globs matched against the
+// slash-separated path relative to the repository root and against each of
+// its trailing sub-paths, so "*.pyc" and "*/generated/*" match at any depth.
+type FileFilter struct {
+	MaxFileSize     int64    // Larger files are skipped; 0 for no limit
+	IncludePatterns []string // When set, only files matching one of them are kept
+	ExcludePatterns []string // Files and directories matching one of them are skipped
+	SkipDirs        []string // Names of directories skipped wherever they appear, such as node_modules
+	SkipBinary      bool     // Skip files with a NUL byte in their first 8000 bytes
+}
+
+// skipDir reports whether the directory at relPath is skipped
+func (f *FileFilter) skipDir(relPath string) bool {
+	name := path.Base(relPath)
+	for _, dir := range f.SkipDirs {
+		if name == dir {
+			return true
+		}
+	}
+	return matchesAny(f.ExcludePatterns, relPath)
+}
+
+// keepFile reports whether the file at filePath, relPath within the
+// repository, is passed on
+func (f *FileFilter) keepFile(filePath, relPath string, info fs.FileInfo) bool {
+	if f.MaxFileSize > 0 && info.Size() > f.MaxFileSize {
+		return false
+	}
+	if len(f.IncludePatterns) > 0 && !matchesAny(f.IncludePatterns, relPath) {
+		return false
+	}
+	if matchesAny(f.ExcludePatterns, relPath) {
+		return false
+	}
+	return !f.SkipBinary || !isBinary(filePath)
+}
+
+// MatchesAny reports whether the slash-separated relPath matches one of the
+// patterns the way FileFilter patterns match
+func MatchesAny(patterns []string, relPath string) bool {
+	return matchesAny(patterns, relPath)
+}
+
+// matchesAny reports whether relPath or one of its trailing sub-paths
+// matches one of the patterns
+func matchesAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		for sub := relPath; ; {
+			if matched, _ := path.Match(pattern, sub); matched {
+				return true
+			}
+			idx := strings.Index(sub, "/")
+			if idx < 0 {
+				break
+			}
+			sub = sub[idx+1:]
+		}
+	}
+	return false
+}
+
+// isBinary reports whether a file looks binary: it has a NUL byte near its
+// start. Files that cannot be read are treated as binary.
+func isBinary(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return true
+	}
+	defer file.Close()
+
+	head := make([]byte, binarySniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return true
+	}
+	return isBinaryContent(head[:n])
+}
+
+// isBinaryContent reports whether content looks binary like isBinary does
+func isBinaryContent(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLength)], 0) >= 0
+}
+
+// relativeSlashPath returns filePath relative to root with forward slashes
+func relativeSlashPath(root, filePath string) string {
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil {
+		return filepath.ToSlash(filePath)
+	}
+	return filepath.ToSlash(rel)
+}
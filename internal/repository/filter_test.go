@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.pyc", "app.pyc", true},
+		{"*.pyc", "pkg/sub/app.pyc", true},
+		{"*/generated/*", "api/generated/types.go", true},
+		{"*/generated/*", "generated/types.go", false},
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/pkg/main.go", false},
+		{"*.min.js", "web/app.js", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchesAny([]string{tt.pattern}, tt.path); got != tt.want {
+			t.Errorf("Pattern %q on %q: expected %v, got %v", tt.pattern, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestWalkFilesFilter(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":                     "package main\n",
+		"main_test.go":                "package main\n",
+		"big.go":                      strings.Repeat("x", 2048),
+		"logo.png":                    "\x89PNG\x00\x00",
+		"docs/guide.md":               "# Guide\n",
+		"node_modules/pkg/index.js":   "module.exports = {}\n",
+		"web/node_modules/x/index.js": "module.exports = {}\n",
+		"vendor/lib/lib.go":           "package lib\n",
+		"api/generated/types.go":      "package generated\n",
+		"api/handler.go":              "package api\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager, err := NewManager(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	walk := func(filter *FileFilter) []string {
+		var found []string
+		err := manager.WalkFiles(context.Background(), root, filter, func(filePath string, info fs.FileInfo) error {
+			found = append(found, relativeSlashPath(root, filePath))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to walk files: %v", err)
+		}
+		sort.Strings(found)
+		return found
+	}
+
+	tests := []struct {
+		name   string
+		filter *FileFilter
+		want   []string
+	}{
+		{
+			name: "defaults",
+			filter: &FileFilter{
+				MaxFileSize:     1024,
+				ExcludePatterns: []string{"*/generated/*"},
+				SkipDirs:        []string{"node_modules", "vendor"},
+				SkipBinary:      true,
+			},
+			want: []string{"api/handler.go", "docs/guide.md", "main.go", "main_test.go"},
+		},
+		{
+			name: "include",
+			filter: &FileFilter{
+				IncludePatterns: []string{"*.go"},
+				ExcludePatterns: []string{"*_test.go"},
+				SkipDirs:        []string{"node_modules", "vendor"},
+			},
+			want: []string{"api/generated/types.go", "api/handler.go", "big.go", "main.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		got := walk(tt.filter)
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	if got := walk(nil); len(got) != len(files) {
+		t.Errorf("Expected every file without a filter, got %v", got)
+	}
+}
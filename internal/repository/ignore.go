@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"go.uber.org/zap"
+)
+
+// gitignoreFile is the name of the per-directory ignore files
+const gitignoreFile = ".gitignore"
+
+// SetIgnoreRules sets the rules WalkFiles applies besides those of each
+// repository. patterns use .gitignore syntax, are matched from the
+// repository root and take precedence over every rule of the repository, so
+// "!dist/keep.js" brings back a file a repository ignores. With
+// globalExcludes, the global excludes file of git is read as well: the file
+// named by core.excludesFile, or $XDG_CONFIG_HOME/git/ignore; failing to
+// read it is logged rather than returned.
+func (m *Manager) SetIgnoreRules(patterns []string, globalExcludes bool) error {
+	var overrides []gitignore.Pattern
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" || strings.HasPrefix(pattern, "#") {
+			return fmt.Errorf("invalid ignore pattern %q", pattern)
+		}
+		overrides = append(overrides, gitignore.ParsePattern(pattern, nil))
+	}
+
+	var global []gitignore.Pattern
+	if globalExcludes {
+		file, err := globalExcludesFile()
+		if err == nil {
+			global, err = readIgnoreFile(file, nil)
+		}
+		if err != nil {
+			// Like git, carry on without the global excludes
+			m.logger.Warn("Failed to read the global excludes file of git", zap.String("path", file), zap.Error(err))
+		} else {
+			m.logger.Debug("Global git excludes loaded", zap.String("path", file), zap.Int("patterns", len(global)))
+		}
+	}
+
+	m.ignoreOverrides = overrides
+	m.globalExcludes = global
+	return nil
+}
+
+// globalExcludesFile returns the path of the global excludes file of git,
+// whether or not it exists
+func globalExcludesFile() (string, error) {
+	cfg, err := gitconfig.LoadConfig(gitconfig.GlobalScope)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the global git config: %w", err)
+	}
+	if file := cfg.Raw.Section("core").Option("excludesfile"); file != "" {
+		if rest, ok := strings.CutPrefix(file, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return "", err
+			}
+			file = filepath.Join(home, rest)
+		}
+		return file, nil
+	}
+
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "git", "ignore"), nil
+}
+
+// readIgnoreFile reads the patterns of an ignore file, which apply to the
+// directory domain. A missing file holds none.
+func readIgnoreFile(file string, domain []string) ([]gitignore.Pattern, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []gitignore.Pattern
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, gitignore.ParsePattern(line, domain))
+	}
+	return patterns, scanner.Err()
+}
+
+// ignoreRules evaluates the ignore rules of one repository while it is
+// walked. Like git, it reads the global excludes, .git/info/exclude and the
+// .gitignore file of every directory walked into, later and deeper rules
+// taking precedence; the configured overrides come last of all.
+type ignoreRules struct {
+	root      string
+	logger    *zap.Logger
+	overrides []gitignore.Pattern
+	dirs      map[string][]gitignore.Pattern // Rules applying inside each directory entered, by slash-separated relative path
+}
+
+// newIgnoreRules returns the rules of the repository at root, with its
+// root directory entered
+func (m *Manager) newIgnoreRules(root string) *ignoreRules {
+	rules := &ignoreRules{
+		root:      root,
+		logger:    m.logger,
+		overrides: m.ignoreOverrides,
+		dirs:      make(map[string][]gitignore.Pattern),
+	}
+	exclude, err := readIgnoreFile(filepath.Join(root, ".git", "info", "exclude"), nil)
+	if err != nil {
+		m.logger.Warn("Failed to read .git/info/exclude", zap.String("repository", root), zap.Error(err))
+	}
+	rules.dirs["."] = slices.Concat(m.globalExcludes, exclude, rules.read(".", nil))
+	return rules
+}
+
+// read returns the patterns of the .gitignore file in the directory relDir
+func (r *ignoreRules) read(relDir string, domain []string) []gitignore.Pattern {
+	file := filepath.Join(r.root, filepath.FromSlash(relDir), gitignoreFile)
+	patterns, err := readIgnoreFile(file, domain)
+	if err != nil {
+		r.logger.Warn("Failed to read .gitignore file", zap.String("path", file), zap.Error(err))
+	}
+	return patterns
+}
+
+// enter adds the rules of the directory at relPath, whose parent has been
+// entered and which is not ignored
+func (r *ignoreRules) enter(relPath string) {
+	parts := strings.Split(relPath, "/")
+	patterns := r.read(relPath, parts)
+	if len(patterns) == 0 {
+		r.dirs[relPath] = r.dirs[path.Dir(relPath)]
+		return
+	}
+	r.dirs[relPath] = slices.Concat(r.dirs[path.Dir(relPath)], patterns)
+}
+
+// ignored reports whether the file or directory at the slash-separated
+// relPath is ignored. Its parent directory must have been entered.
+func (r *ignoreRules) ignored(relPath string, isDir bool) bool {
+	parts := strings.Split(relPath, "/")
+	result := lastMatch(r.overrides, parts, isDir)
+	if result == gitignore.NoMatch {
+		result = lastMatch(r.dirs[path.Dir(relPath)], parts, isDir)
+	}
+	return result == gitignore.Exclude
+}
+
+// lastMatch returns the result of the last of the patterns matching the
+// path, which is the one that decides
+func lastMatch(patterns []gitignore.Pattern, parts []string, isDir bool) gitignore.MatchResult {
+	for i := len(patterns) - 1; i >= 0; i-- {
+		if result := patterns[i].Match(parts, isDir); result != gitignore.NoMatch {
+			return result
+		}
+	}
+	return gitignore.NoMatch
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestNestedGitignore(t *testing.T) {
+	repoDir := t.TempDir()
+	files := map[string]string{
+		".gitignore":                          "*.log\n/generated/\n",
+		".git/info/exclude":                   "local.txt\n",
+		"app.log":                             "",
+		"local.txt":                           "",
+		"main.go":                             "",
+		"generated/api.go":                    "",
+		"packages/web/.gitignore":             "node_modules/\n!keep.log\ndist\n",
+		"packages/web/keep.log":               "",
+		"packages/web/index.js":               "",
+		"packages/web/node_modules/dep/a.js":  "",
+		"packages/web/dist/bundle.js":         "",
+		"packages/web/src/generated/types.ts": "",
+		"packages/api/dist/server.js":         "",
+		"packages/api/debug.log":              "",
+		"global.bak":                          "",
+		"vendor/lib.go":                       "",
+		"vendor/patched.go":                   "",
+	}
+	for name, content := range files {
+		path := filepath.Join(repoDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The global excludes file is found through the git config
+	configHome := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	if err := os.MkdirAll(filepath.Join(configHome, "git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configHome, "git", "ignore"), []byte("*.bak\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewManager(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := manager.SetIgnoreRules([]string{"vendor/", "!vendor/patched.go"}, true); err != nil {
+		t.Fatalf("SetIgnoreRules failed: %v", err)
+	}
+
+	walk := func() []string {
+		return walkedFiles(t, manager, repoDir)
+	}
+
+	walked := walk()
+	for _, name := range []string{
+		".gitignore", "main.go", "packages/web/.gitignore", "packages/web/index.js",
+		"packages/web/keep.log", "packages/web/src/generated/types.ts", "packages/api/dist/server.js",
+	} {
+		if !slices.Contains(walked, name) {
+			t.Errorf("Expected %s to be walked, got %v", name, walked)
+		}
+	}
+	for _, name := range []string{
+		"app.log", "local.txt", "generated/api.go", "packages/web/node_modules/dep/a.js",
+		"packages/web/dist/bundle.js", "packages/api/debug.log", "global.bak", "vendor/lib.go",
+	} {
+		if slices.Contains(walked, name) {
+			t.Errorf("Expected %s to be ignored", name)
+		}
+	}
+
+	// A configured pattern cannot bring back a file of an ignored directory,
+	// as in git
+	if slices.Contains(walked, "vendor/patched.go") {
+		t.Error("Expected vendor/patched.go to stay ignored with its directory")
+	}
+	if err := manager.SetIgnoreRules([]string{"!app.log"}, false); err != nil {
+		t.Fatalf("SetIgnoreRules failed: %v", err)
+	}
+	walked = walk()
+	if !slices.Contains(walked, "app.log") || !slices.Contains(walked, "global.bak") {
+		t.Errorf("Expected the overrides to bring back app.log and global excludes to be off, got %v", walked)
+	}
+	if slices.Contains(walked, "packages/web/node_modules/dep/a.js") {
+		t.Error("Expected a file in an ignored directory to stay ignored")
+	}
+
+	// Edits to a nested .gitignore apply to the next walk
+	if err := os.WriteFile(filepath.Join(repoDir, "packages", "web", ".gitignore"), []byte("node_modules/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	walked = walk()
+	if !slices.Contains(walked, "packages/web/dist/bundle.js") || slices.Contains(walked, "packages/web/keep.log") {
+		t.Errorf("Expected the edited rules of packages/web to apply, got %v", walked)
+	}
+
+	if err := manager.SetIgnoreRules([]string{""}, false); err == nil {
+		t.Error("Expected an empty pattern to be refused")
+	}
+}
+
+// walkedFiles returns the slash-separated paths WalkFiles visits below
+// root, sorted
+func walkedFiles(t *testing.T, manager *Manager, root string) []string {
+	t.Helper()
+	var walked []string
+	err := manager.WalkFiles(context.Background(), root, nil, func(filePath string, info fs.FileInfo) error {
+		walked = append(walked, relativeSlashPath(root, filePath))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkFiles failed: %v", err)
+	}
+	slices.Sort(walked)
+	return walked
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// UnknownLanguage is the language of files no rule recognizes; they are
+// indexed with the generic parser
+const UnknownLanguage = "unknown"
+
+// languageExtensions maps lower-case file extensions to languages
+var languageExtensions = map[string]string{
+	".go":       "go",
+	".py":       "python",
+	".js":       "javascript",
+	".ts":       "typescript",
+	".java":     "java",
+	".cpp":      "cpp",
+	".c":        "c",
+	".h":        "c",
+	".hpp":      "cpp",
+	".rs":       "rust",
+	".rb":       "ruby",
+	".php":      "php",
+	".cs":       "csharp",
+	".kt":       "kotlin",
+	".swift":    "swift",
+	".scala":    "scala",
+	".clj":      "clojure",
+	".hs":       "haskell",
+	".ml":       "ocaml",
+	".sh":       "shell",
+	".bash":     "shell",
+	".zsh":      "shell",
+	".fish":     "shell",
+	".ps1":      "powershell",
+	".sql":      "sql",
+	".proto":    "protobuf",
+	".r":        "r",
+	".m":        "matlab",
+	".dart":     "dart",
+	".lua":      "lua",
+	".perl":     "perl",
+	".pl":       "perl",
+	".mk":       "makefile",
+	".cmake":    "cmake",
+	".gradle":   "groovy",
+	".groovy":   "groovy",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".yaml":     "yaml",
+	".yml":      "yaml",
+	".json":     "json",
+}
+
+// languageFileNames maps lower-case file names that tell the language
+// better than their extension, or have none, to languages
+var languageFileNames = map[string]string{
+	"dockerfile":     "dockerfile",
+	"containerfile":  "dockerfile",
+	"makefile":       "makefile",
+	"gnumakefile":    "makefile",
+	"cmakelists.txt": "cmake",
+	"rakefile":       "ruby",
+	"gemfile":        "ruby",
+	"vagrantfile":    "ruby",
+	"podfile":        "ruby",
+	"jenkinsfile":    "groovy",
+	".bashrc":        "shell",
+	".bash_profile":  "shell",
+	".zshrc":         "shell",
+	".profile":       "shell",
+}
+
+// languageFilePrefixes maps lower-case file name prefixes to languages, for
+// variants such as Dockerfile.dev
+var languageFilePrefixes = map[string]string{
+	"dockerfile.":    "dockerfile",
+	"containerfile.": "dockerfile",
+	"makefile.":      "makefile",
+}
+
+// shebangInterpreters maps the interpreters of shebang lines, without
+// version suffixes, to languages
+var shebangInterpreters = map[string]string{
+	"python":     "python",
+	"node":       "javascript",
+	"nodejs":     "javascript",
+	"ts-node":    "typescript",
+	"deno":       "typescript",
+	"sh":         "shell",
+	"bash":       "shell",
+	"zsh":        "shell",
+	"ksh":        "shell",
+	"dash":       "shell",
+	"ash":        "shell",
+	"fish":       "shell",
+	"ruby":       "ruby",
+	"perl":       "perl",
+	"php":        "php",
+	"lua":        "lua",
+	"rscript":    "r",
+	"pwsh":       "powershell",
+	"powershell": "powershell",
+	"groovy":     "groovy",
+}
+
+// interpreterVersionPattern matches version suffixes such as the "3.11" of
+// python3.11
+var interpreterVersionPattern = regexp.MustCompile(`[0-9.]+$`)
+
+// shebangSniffLength bounds how much of a file is read for its shebang line
+const shebangSniffLength = 256
+
+// languageOverride maps the files matching a pattern to a language
+type languageOverride struct {
+	pattern  string
+	language string
+}
+
+// SetLanguageOverrides sets the configured languages of files, which take
+// precedence over every built-in rule. Each language maps to glob patterns
+// matched against the file path and each of its trailing sub-paths, as
+// include patterns are; a pattern such as ".tpl" stands for every file with
+// that extension. When patterns of several languages match, the longest
+// wins.
+func (m *Manager) SetLanguageOverrides(overrides map[string][]string) error {
+	var rules []languageOverride
+	for language, patterns := range overrides {
+		if language = strings.ToLower(strings.TrimSpace(language)); language == "" {
+			return fmt.Errorf("language override names no language")
+		}
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+				return fmt.Errorf("invalid language override pattern %q for %s", pattern, language)
+			}
+			if strings.HasPrefix(pattern, ".") && !strings.ContainsAny(pattern, "*?[/") {
+				pattern = "*" + pattern
+			}
+			rules = append(rules, languageOverride{pattern: pattern, language: language})
+		}
+	}
+	sort.Slice(rules, func(a, b int) bool {
+		if len(rules[a].pattern) != len(rules[b].pattern) {
+			return len(rules[a].pattern) > len(rules[b].pattern)
+		}
+		return rules[a].pattern < rules[b].pattern
+	})
+	m.languageOverrides = rules
+	return nil
+}
+
+// GetFileLanguage determines the language of a file from the configured
+// overrides, its name and extension and, when those tell nothing, the
+// shebang line of the file on disk. It returns UnknownLanguage otherwise.
+func (m *Manager) GetFileLanguage(filename string) string {
+	if language := m.languageByName(filename); language != UnknownLanguage {
+		return language
+	}
+	return shebangLanguage(readShebang(filename))
+}
+
+// DetectLanguage is GetFileLanguage for content that is already read, or
+// that differs from the file on disk, such as an unsaved editor buffer
+func (m *Manager) DetectLanguage(filename string, content []byte) string {
+	if language := m.languageByName(filename); language != UnknownLanguage {
+		return language
+	}
+	line, _, _ := strings.Cut(string(content[:min(len(content), shebangSniffLength)]), "\n")
+	return shebangLanguage(line)
+}
+
+// languageByName determines the language of a file from the configured
+// overrides, its name and its extension
+func (m *Manager) languageByName(filename string) string {
+	slashPath := filepath.ToSlash(filename)
+	for _, override := range m.languageOverrides {
+		if matchesAny([]string{override.pattern}, slashPath) {
+			return override.language
+		}
+	}
+
+	name := strings.ToLower(filepath.Base(filename))
+	if language, ok := languageFileNames[name]; ok {
+		return language
+	}
+	for prefix, language := range languageFilePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return language
+		}
+	}
+	if language, ok := languageExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return language
+	}
+	return UnknownLanguage
+}
+
+// readShebang returns the first line of a regular file, or "" when it
+// cannot be read
+func readShebang(filename string) string {
+	if info, err := os.Stat(filename); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	line, _ := bufio.NewReaderSize(file, shebangSniffLength).ReadSlice('\n')
+	return string(line)
+}
+
+// shebangLanguage returns the language of the interpreter a shebang line
+// names, such as "#!/usr/bin/env python3", or UnknownLanguage
+func shebangLanguage(line string) string {
+	line, ok := strings.CutPrefix(strings.TrimSpace(line), "#!")
+	if !ok {
+		return UnknownLanguage
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return UnknownLanguage
+	}
+
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" {
+		// Skip the options and variable assignments of env
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = path.Base(field)
+				break
+			}
+		}
+	}
+
+	interpreter = interpreterVersionPattern.ReplaceAllString(strings.ToLower(interpreter), "")
+	if language, ok := shebangInterpreters[interpreter]; ok {
+		return language
+	}
+	return UnknownLanguage
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestShebangLanguage(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"#!/bin/bash", "shell"},
+		{"#!/bin/sh -e", "shell"},
+		{"#!/usr/bin/env python3", "python"},
+		{"#!/usr/bin/python3.11", "python"},
+		{"#!/usr/bin/env -S node --no-warnings", "javascript"},
+		{"#!/usr/bin/env LANG=C ruby", "ruby"},
+		{"#! /usr/bin/perl -w", "perl"},
+		{"#!/usr/bin/env unheard-of", UnknownLanguage},
+		{"# just a comment", UnknownLanguage},
+		{"", UnknownLanguage},
+	}
+
+	for _, tt := range tests {
+		if got := shebangLanguage(tt.line); got != tt.want {
+			t.Errorf("Shebang %q: expected %q, got %q", tt.line, tt.want, got)
+		}
+	}
+}
+
+func TestGetFileLanguage(t *testing.T) {
+	manager, err := NewManager(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = manager.SetLanguageOverrides(map[string][]string{
+		"html":   {".tpl"},
+		"groovy": {"ci/*", "check"},
+		"python": {"ci/tools/*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := t.TempDir()
+	files := map[string]string{
+		"bin/deploy":     "#!/usr/bin/env bash\necho deploy\n",
+		"bin/serve":      "#!/usr/bin/env python3\nprint('serve')\n",
+		"bin/notes":      "plain text\n",
+		"ci/tools/check": "",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"main.go", "go"},
+		{"lib/Util.JAVA", "java"},
+		{"Dockerfile", "dockerfile"},
+		{"docker/Dockerfile.dev", "dockerfile"},
+		{"Makefile", "makefile"},
+		{"CMakeLists.txt", "cmake"},
+		{"notes.txt", UnknownLanguage},
+		{"web/page.tpl", "html"},
+		{"ci/release", "groovy"},
+		{"ci/tools/check", "python"}, // The longest pattern wins
+		{"bin/deploy", "shell"},
+		{"bin/serve", "python"},
+		{"bin/notes", UnknownLanguage},
+	}
+
+	for _, tt := range tests {
+		if got := manager.GetFileLanguage(filepath.Join(root, filepath.FromSlash(tt.path))); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.path, tt.want, got)
+		}
+	}
+
+	if got := manager.DetectLanguage("scripts/build", []byte("#!/usr/bin/env node\n")); got != "javascript" {
+		t.Errorf("Expected the shebang of the given content to be used, got %q", got)
+	}
+	if err := manager.SetLanguageOverrides(map[string][]string{"html": {"[*.tpl"}}); err == nil {
+		t.Error("Expected a malformed pattern to be rejected")
+	}
+}
@@ -3,19 +3,24 @@ package repository
 import (
 	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io/fs"
-	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	gitignore "github.com/sabhiram/go-gitignore"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"go.uber.org/zap"
 
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
 	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
@@ -23,44 +28,99 @@ import (
 type Manager struct {
 	repoDir     string
 	logger      *zap.Logger
-	gitignores  map[string]*gitignore.GitIgnore // Cache gitignore patterns per repository
+	objectCache *objectCache // Shared mirrors for clones, nil when disabled
+	sandbox     *sandbox     // Roots the file tools may access
+	localRoots  *sandbox     // Directories local repositories may be prepared from
+
+	languageOverrides []languageOverride // Configured languages of files, longest pattern first
+
+	cloneDefaults CloneOptions        // Options of clones not given their own
+	auth          map[string]hostAuth // Credentials by lower-case host name
+
+	ignoreOverrides []gitignore.Pattern // Configured ignore rules, over those of each repository
+	globalExcludes  []gitignore.Pattern // Rules of the global excludes file of git
 }
 
+// ErrInvalidRepositoryName is returned for clone names that are not a
+// single directory name
+var ErrInvalidRepositoryName = errors.New("invalid repository name")
+
+// ErrNotClone is returned by RemoveClone for paths that are not a clone in
+// the repository directory
+var ErrNotClone = errors.New("path is not a clone in the repository directory")
+
 // NewManager creates a new repository manager
 func NewManager(repoDir string, logger *zap.Logger) (*Manager, error) {
 	if err := os.MkdirAll(repoDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create repository directory: %w", err)
 	}
 
-	return &Manager{
+	manager := &Manager{
 		repoDir:    repoDir,
 		logger:     logger,
-		gitignores: make(map[string]*gitignore.GitIgnore),
-	}, nil
+		sandbox:    &sandbox{},
+		localRoots: &sandbox{},
+	}
+	if err := manager.RegisterRoot(repoDir); err != nil {
+		return nil, err
+	}
+	if err := manager.AllowLocalRepositories(repoDir); err != nil {
+		return nil, err
+	}
+	return manager, nil
 }
 
-// PrepareRepository prepares a repository for indexing (clone if URL, validate if local path)
+// AllowLocalRepositories lets PrepareRepository accept local repositories
+// below dir. It does not open dir to the file tools; a prepared repository
+// is registered as a sandbox root on its own.
+func (m *Manager) AllowLocalRepositories(dir string) error {
+	resolved, err := m.localRoots.add(dir)
+	if err != nil {
+		return fmt.Errorf("invalid local repository root %s: %w", dir, err)
+	}
+	m.logger.Debug("Local repository root allowed", zap.String("root", resolved))
+	return nil
+}
+
+// PrepareRepository prepares a repository for indexing (clone if URL, validate if local path).
+// URLs are cloned into the repository directory under name, which must be a
+// single directory name; local paths must lie below a directory allowed by
+// AllowLocalRepositories.
 func (m *Manager) PrepareRepository(ctx context.Context, path, name string) (*types.Repository, error) {
+	return m.PrepareRepositoryAt(ctx, path, name, "")
+}
+
+// PrepareRepositoryAt prepares a repository like PrepareRepository with ref,
+// a branch, tag or commit, checked out. A repository prepared at a ref is
+// always a clone in the repository directory, local repositories included,
+// so the working copy of a local repository is never switched and several
+// refs of one repository can be indexed side by side. Without a name the
+// clone is named after the repository and the ref, see RefName.
+func (m *Manager) PrepareRepositoryAt(ctx context.Context, path, name, ref string) (*types.Repository, error) {
+	return m.PrepareRepositoryWithOptions(ctx, path, name, ref, CloneOptions{})
+}
+
+// PrepareRepositoryWithOptions prepares a repository like
+// PrepareRepositoryAt, cloning it with opts over the defaults set with
+// SetCloneOptions: shallow, single-branch or with only some directories
+// checked out. Local repositories are only affected when prepared at a ref,
+// which clones them too.
+func (m *Manager) PrepareRepositoryWithOptions(ctx context.Context, path, name, ref string, opts CloneOptions) (*types.Repository, error) {
 	var repoPath string
 	var repoURL string
 	var isRemote bool
 
+	sparse, err := SparseDirectories(opts.SparsePatterns)
+	if err != nil {
+		return nil, err
+	}
+	opts.SparsePatterns = sparse
+	opts = opts.withDefaults(m.cloneDefaults)
+
 	// Check if path is a URL
-	if u, err := url.Parse(path); err == nil && (u.Scheme == "http" || u.Scheme == "https" || u.Scheme == "git") {
+	if _, ok := parseRemote(path); ok {
 		isRemote = true
 		repoURL = path
-		
-		// Generate a directory name for the cloned repo
-		repoName := name
-		if repoName == "" {
-			repoName = m.generateRepoName(path)
-		}
-		repoPath = filepath.Join(m.repoDir, repoName)
-		
-		// Clone or update the repository
-		if err := m.cloneOrUpdateRepo(ctx, repoURL, repoPath); err != nil {
-			return nil, fmt.Errorf("failed to clone repository: %w", err)
-		}
 	} else {
 		// Local path
 		absPath, err := filepath.Abs(path)
@@ -71,8 +131,43 @@ func (m *Manager) PrepareRepository(ctx context.Context, path, name string) (*ty
 		if _, err := os.Stat(absPath); os.IsNotExist(err) {
 			return nil, fmt.Errorf("local repository path does not exist: %s", absPath)
 		}
+		resolved, err := resolvePath(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid local path: %w", err)
+		}
+		if !m.localRoots.contains(resolved) {
+			return nil, fmt.Errorf("%w: local repository %s is not below an allowed path", ErrOutsideSandbox, absPath)
+		}
+		
+		if ref == "" {
+			repoPath = absPath
+		} else {
+			// Cloned like a URL, from the local repository
+			repoURL = absPath
+		}
+	}
+
+	if repoURL != "" {
+		// Generate a directory name for the cloned repo
+		if name == "" {
+			if isRemote {
+				name = m.generateRepoName(repoURL)
+			} else {
+				name = filepath.Base(repoURL)
+			}
+			if ref != "" {
+				name = RefName(name, ref)
+			}
+		}
+		if err := validateRepositoryName(name); err != nil {
+			return nil, err
+		}
+		repoPath = filepath.Join(m.repoDir, name)
 		
-		repoPath = absPath
+		// Clone or update the repository
+		if err := m.cloneOrUpdateRepo(ctx, repoURL, repoPath, ref, opts); err != nil {
+			return nil, fmt.Errorf("failed to clone repository: %w", err)
+		}
 	}
 
 	// Get repository information
@@ -80,23 +175,64 @@ func (m *Manager) PrepareRepository(ctx context.Context, path, name string) (*ty
 	if err != nil {
 		return nil, fmt.Errorf("failed to get repository info: %w", err)
 	}
+	repo.Ref = ref
+	if repoURL != "" && len(opts.SparsePatterns) > 0 {
+		repo.SparsePatterns = opts.SparsePatterns
+		repo.IndexingMode = "sparse"
+	}
+	if err := m.RegisterRoot(repo.Path); err != nil {
+		return nil, err
+	}
 
 	m.logger.Info("Repository prepared", 
 		zap.String("name", repo.Name),
 		zap.String("path", repo.Path),
+		zap.String("ref", ref),
 		zap.Bool("is_remote", isRemote))
 
 	return repo, nil
 }
 
-// cloneOrUpdateRepo clones a repository or updates it if it already exists
-func (m *Manager) cloneOrUpdateRepo(ctx context.Context, repoURL, repoPath string) error {
+// RemoveClone deletes the working copy of a cloned repository. Only
+// directories directly below the repository directory are deleted, so local
+// repositories are never touched.
+func (m *Manager) RemoveClone(repoPath string) error {
+	resolved, err := resolvePath(repoPath)
+	if err != nil {
+		return fmt.Errorf("invalid clone path %s: %w", repoPath, err)
+	}
+	repoDir, err := resolvePath(m.repoDir)
+	if err != nil {
+		return fmt.Errorf("invalid repository directory: %w", err)
+	}
+	if filepath.Dir(resolved) != repoDir {
+		return fmt.Errorf("%w: %s", ErrNotClone, repoPath)
+	}
+
+	if err := os.RemoveAll(resolved); err != nil {
+		return fmt.Errorf("failed to delete clone %s: %w", repoPath, err)
+	}
+	m.logger.Info("Clone deleted", zap.String("path", resolved))
+	return nil
+}
+
+// cloneOrUpdateRepo clones a repository or updates it if it already exists.
+// With a ref, the ref is checked out after cloning or fetching. Sparse
+// clones are always updated by fetching and checking out again, since a
+// pull would check out every file.
+func (m *Manager) cloneOrUpdateRepo(ctx context.Context, repoURL, repoPath, ref string, opts CloneOptions) error {
+	auth := m.authFor(repoURL)
+
 	// Check if repository already exists
-	if _, err := os.Stat(filepath.Join(repoPath, ".git")); err == nil {
+	_, err := os.Stat(filepath.Join(repoPath, ".git"))
+	if err == nil && (ref != "" || len(opts.SparsePatterns) > 0) {
+		return m.fetchAndCheckout(ctx, repoPath, ref, opts, auth)
+	}
+	if err == nil {
 		// Repository exists, try to update it
 		m.logger.Info("Updating existing repository", zap.String("path", repoPath))
 		
-		repo, err := git.PlainOpen(repoPath)
+		repo, err := openRepository(repoPath)
 		if err != nil {
 			return fmt.Errorf("failed to open existing repository: %w", err)
 		}
@@ -106,7 +242,13 @@ func (m *Manager) cloneOrUpdateRepo(ctx context.Context, repoURL, repoPath strin
 			return fmt.Errorf("failed to get worktree: %w", err)
 		}
 		
-		err = worktree.Pull(&git.PullOptions{})
+		reportCloneProgress(ctx, "Pulling updates")
+		err = worktree.PullContext(ctx, &git.PullOptions{
+			Depth:        opts.Depth,
+			SingleBranch: opts.SingleBranch,
+			Auth:         auth,
+			Progress:     cloneProgressWriter(ctx),
+		})
 		if err != nil && err != git.NoErrAlreadyUpToDate {
 			m.logger.Warn("Failed to pull updates, continuing with existing version", zap.Error(err))
 		}
@@ -114,19 +256,69 @@ func (m *Manager) cloneOrUpdateRepo(ctx context.Context, repoURL, repoPath strin
 		return nil
 	}
 
+	// Clone through the shared mirror when the object cache is enabled. The
+	// git command line tool runs without the configured credentials, and
+	// mirrors hold every branch with its whole history, so private and
+	// partial clones are made directly.
+	if m.objectCache != nil && auth == nil && !opts.partial() {
+		m.logger.Info("Cloning repository through object cache", zap.String("url", repoURL), zap.String("path", repoPath))
+		if err := m.objectCache.clone(ctx, repoURL, repoPath); err != nil {
+			os.RemoveAll(repoPath)
+			return err
+		}
+		m.objectCache.prune(m.repoDir)
+		return m.checkoutCloned(ctx, repoPath, ref, nil)
+	}
+
 	// Clone the repository
-	m.logger.Info("Cloning repository", zap.String("url", repoURL), zap.String("path", repoPath))
-	
-	_, err := git.PlainCloneContext(ctx, repoPath, false, &git.CloneOptions{
-		URL:      repoURL,
-		Progress: os.Stdout,
-	})
+	m.logger.Info("Cloning repository",
+		zap.String("url", repoURL),
+		zap.String("path", repoPath),
+		zap.Int("depth", opts.Depth),
+		zap.Bool("single_branch", opts.SingleBranch),
+		zap.Strings("sparse_patterns", opts.SparsePatterns))
 	
-	if err != nil {
+	if err := m.clone(ctx, repoURL, repoPath, ref, opts, auth); err != nil {
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
 
-	return nil
+	return m.checkoutCloned(ctx, repoPath, ref, opts.SparsePatterns)
+}
+
+// clone clones a repository with go-git. Sparse clones are made without
+// checking out, which checkoutCloned does. A shallow or single-branch clone
+// at a ref fetches the ref alone, looked up as a branch and then as a tag;
+// commits cannot be fetched on their own, so for them everything is.
+func (m *Manager) clone(ctx context.Context, repoURL, repoPath, ref string, opts CloneOptions, auth transport.AuthMethod) error {
+	options := &git.CloneOptions{
+		URL:          repoURL,
+		Auth:         auth,
+		Depth:        opts.Depth,
+		SingleBranch: opts.SingleBranch,
+		NoCheckout:   len(opts.SparsePatterns) > 0,
+		Progress:     cloneProgressWriter(ctx),
+		Tags:         git.AllTags, // The default only follows annotated tags
+	}
+	if ref != "" && (opts.Depth > 0 || opts.SingleBranch) {
+		for _, refName := range []plumbing.ReferenceName{plumbing.NewBranchReferenceName(ref), plumbing.NewTagReferenceName(ref)} {
+			options.ReferenceName = refName
+			_, err := git.PlainCloneContext(ctx, repoPath, false, options)
+			if err == nil || !isMissingRef(err) {
+				return err
+			}
+		}
+		m.logger.Debug("Ref is not a branch or tag, cloning every branch", zap.String("url", repoURL), zap.String("ref", ref))
+		options.ReferenceName, options.Depth, options.SingleBranch = "", 0, false
+	}
+	_, err := git.PlainCloneContext(ctx, repoPath, false, options)
+	return err
+}
+
+// isMissingRef reports whether a clone failed because the remote has no
+// reference of the name asked for
+func isMissingRef(err error) bool {
+	var noMatch git.NoMatchingRefSpecError
+	return errors.Is(err, plumbing.ErrReferenceNotFound) || errors.As(err, &noMatch)
 }
 
 // getRepositoryInfo extracts information about a Git repository
@@ -152,7 +344,7 @@ func (m *Manager) getRepositoryInfo(repoPath, repoURL, customName string) (*type
 	}
 
 	// Try to get Git information
-	if gitRepo, err := git.PlainOpen(repoPath); err == nil {
+	if gitRepo, err := openRepository(repoPath); err == nil {
 		// Get current branch
 		if head, err := gitRepo.Head(); err == nil {
 			repo.Branch = head.Name().Short()
@@ -178,14 +370,23 @@ func (m *Manager) getRepositoryInfo(repoPath, repoURL, customName string) (*type
 	return repo, nil
 }
 
+// validateRepositoryName checks that a clone name stays a single directory
+// below the repository directory
+func validateRepositoryName(name string) error {
+	if name == "" || name == "." || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") {
+		return fmt.Errorf("%w: %q", ErrInvalidRepositoryName, name)
+	}
+	return nil
+}
+
 // generateRepoName generates a repository name from a URL
 func (m *Manager) generateRepoName(repoURL string) string {
-	u, err := url.Parse(repoURL)
-	if err != nil {
+	remote, ok := parseRemote(repoURL)
+	if !ok {
 		return "unknown-repo"
 	}
 
-	path := strings.TrimSuffix(u.Path, ".git")
+	path := strings.TrimSuffix(strings.Trim(remote.path, "/"), ".git")
 	parts := strings.Split(path, "/")
 	
 	if len(parts) >= 2 {
@@ -197,8 +398,13 @@ func (m *Manager) generateRepoName(repoURL string) string {
 	return "unknown-repo"
 }
 
-// WalkFiles walks through all files in a repository and calls the callback for each file
-func (m *Manager) WalkFiles(ctx context.Context, repoPath string, callback func(filePath string, info fs.FileInfo) error) error {
+// WalkFiles walks through all files in a repository and calls the callback for each file.
+// Files and directories ignored by git are skipped: those matching the
+// .gitignore file of their directory or of any directory above it,
+// .git/info/exclude, the global excludes file when enabled and the rules set
+// with SetIgnoreRules.
+func (m *Manager) WalkFiles(ctx context.Context, repoPath string, filter *FileFilter, callback func(filePath string, info fs.FileInfo) error) error {
+	rules := m.newIgnoreRules(repoPath)
 	return filepath.WalkDir(repoPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -211,17 +417,26 @@ func (m *Manager) WalkFiles(ctx context.Context, repoPath string, callback func(
 		default:
 		}
 
+		if path == repoPath {
+			return nil
+		}
+		relPath := relativeSlashPath(repoPath, path)
+
 		// Skip directories
 		if d.IsDir() {
 			// Check if directory should be ignored by gitignore
-			if m.isIgnoredByGit(path, repoPath) {
+			if rules.ignored(relPath, true) {
 				return filepath.SkipDir
 			}
+			if filter != nil && filter.skipDir(relPath) {
+				return filepath.SkipDir
+			}
+			rules.enter(relPath)
 			return nil
 		}
 
 		// Check if file should be ignored by gitignore
-		if m.isIgnoredByGit(path, repoPath) {
+		if rules.ignored(relPath, false) {
 			return nil // Skip this file
 		}
 
@@ -232,64 +447,25 @@ func (m *Manager) WalkFiles(ctx context.Context, repoPath string, callback func(
 			return nil // Continue walking
 		}
 
+		if filter != nil && !filter.keepFile(path, relPath, info) {
+			return nil
+		}
+
 		// Call the callback
 		return callback(path, info)
 	})
 }
 
-// GetFileContent reads the content of a file
+// GetFileContent reads the content of a file without checking it against
+// the sandbox; use ReadFile for paths supplied by clients
 func (m *Manager) GetFileContent(filePath string) ([]byte, error) {
 	return os.ReadFile(filePath)
 }
 
-// GetRelativePath returns the relative path of a file within a repository
+// GetRelativePath returns the relative path of a file within a repository,
+// separated by forward slashes on every platform as the index stores it
 func (m *Manager) GetRelativePath(filePath, repoPath string) (string, error) {
-	return filepath.Rel(repoPath, filePath)
-}
-
-// GetFileLanguage determines the programming language of a file based on its extension
-func (m *Manager) GetFileLanguage(filename string) string {
-	ext := strings.ToLower(filepath.Ext(filename))
-	
-	languageMap := map[string]string{
-		".go":     "go",
-		".py":     "python",
-		".js":     "javascript",
-		".ts":     "typescript",
-		".java":   "java",
-		".cpp":    "cpp",
-		".c":      "c",
-		".h":      "c",
-		".hpp":    "cpp",
-		".rs":     "rust",
-		".rb":     "ruby",
-		".php":    "php",
-		".cs":     "csharp",
-		".kt":     "kotlin",
-		".swift":  "swift",
-		".scala":  "scala",
-		".clj":    "clojure",
-		".hs":     "haskell",
-		".ml":     "ocaml",
-		".sh":     "shell",
-		".bash":   "shell",
-		".zsh":    "shell",
-		".fish":   "shell",
-		".ps1":    "powershell",
-		".sql":    "sql",
-		".r":      "r",
-		".m":      "matlab",
-		".dart":   "dart",
-		".lua":    "lua",
-		".perl":   "perl",
-		".pl":     "perl",
-	}
-
-	if lang, exists := languageMap[ext]; exists {
-		return lang
-	}
-
-	return "unknown"
+	return pathutil.Rel(repoPath, filePath)
 }
 
 // ValidateRepository checks if a path contains a valid repository
@@ -313,42 +489,6 @@ func (m *Manager) ValidateRepository(path string) error {
 	return nil
 }
 
-// loadGitignore loads and caches gitignore patterns for a repository
-func (m *Manager) loadGitignore(repoPath string) *gitignore.GitIgnore {
-	if gi, exists := m.gitignores[repoPath]; exists {
-		return gi
-	}
-
-	gitignorePath := filepath.Join(repoPath, ".gitignore")
-	if _, err := os.Stat(gitignorePath); os.IsNotExist(err) {
-		// No .gitignore file, create empty gitignore
-		m.gitignores[repoPath] = gitignore.CompileIgnoreLines()
-		return m.gitignores[repoPath]
-	}
-
-	gi, err := gitignore.CompileIgnoreFile(gitignorePath)
-	if err != nil {
-		m.logger.Warn("Failed to load .gitignore file", zap.String("path", gitignorePath), zap.Error(err))
-		gi = gitignore.CompileIgnoreLines()
-	}
-
-	m.gitignores[repoPath] = gi
-	return gi
-}
-
-// isIgnoredByGit checks if a file should be ignored according to .gitignore rules
-func (m *Manager) isIgnoredByGit(filePath, repoPath string) bool {
-	gi := m.loadGitignore(repoPath)
-
-	// Get relative path from repository root
-	relPath, err := filepath.Rel(repoPath, filePath)
-	if err != nil {
-		return false
-	}
-
-	return gi.MatchesPath(relPath)
-}
-
 // GetSubmodules returns information about Git submodules in a repository
 func (m *Manager) GetSubmodules(repoPath string) ([]types.Submodule, error) {
 	var submodules []types.Submodule
@@ -408,11 +548,25 @@ func (m *Manager) GetSubmodules(repoPath string) ([]types.Submodule, error) {
 	return submodules, nil
 }
 
-// GetCommitHistory returns recent commit history for incremental indexing
+// errLimitReached stops commit iteration once enough commits were collected
+var errLimitReached = errors.New("limit reached")
+
+// ErrCommitNotFound is returned by GetCommitHistory when the start commit is
+// not an ancestor of HEAD, for example after a force push, and by
+// ChangedFiles when a commit does not exist
+var ErrCommitNotFound = errors.New("commit not found in history")
+
+// GetCommitHistory returns up to limit commits reachable from HEAD, newest
+// first, with the files each one changed. When fromCommit is set, history
+// stops before it, so the result holds the commits made since fromCommit.
+// ErrCommitNotFound is returned if the whole history was walked without
+// reaching fromCommit. The files of a merge commit are those it changed
+// relative to its first parent; use ChangedFiles for everything that
+// changed between two commits.
 func (m *Manager) GetCommitHistory(repoPath string, fromCommit string, limit int) ([]types.CommitInfo, error) {
 	var commits []types.CommitInfo
 
-	repo, err := git.PlainOpen(repoPath)
+	repo, err := openRepository(repoPath)
 	if err != nil {
 		return commits, fmt.Errorf("failed to open repository: %w", err)
 	}
@@ -431,18 +585,16 @@ func (m *Manager) GetCommitHistory(repoPath string, fromCommit string, limit int
 	defer commitIter.Close()
 
 	count := 0
-	foundStart := fromCommit == ""
+	reachedStart := false
 
 	err = commitIter.ForEach(func(c *object.Commit) error {
-		if !foundStart {
-			if c.Hash.String() == fromCommit {
-				foundStart = true
-			}
-			return nil
+		if fromCommit != "" && c.Hash.String() == fromCommit {
+			reachedStart = true
+			return storer.ErrStop
 		}
 
 		if count >= limit {
-			return fmt.Errorf("limit reached") // Use error to break iteration
+			return errLimitReached // Use error to break iteration
 		}
 
 		commitInfo := types.CommitInfo{
@@ -483,9 +635,79 @@ func (m *Manager) GetCommitHistory(repoPath string, fromCommit string, limit int
 		return nil
 	})
 
-	if err != nil && err.Error() != "limit reached" {
+	if err == errLimitReached {
+		return commits, nil
+	}
+	if errors.Is(err, plumbing.ErrObjectNotFound) && fromCommit != "" {
+		// The history of a shallow clone ends before the commit
+		return commits, fmt.Errorf("%w: %s", ErrCommitNotFound, fromCommit)
+	}
+	if err != nil {
 		return commits, fmt.Errorf("failed to iterate commits: %w", err)
 	}
+	if fromCommit != "" && !reachedStart {
+		return commits, fmt.Errorf("%w: %s", ErrCommitNotFound, fromCommit)
+	}
 
 	return commits, nil
 }
+
+// ChangedFiles returns the sorted paths of the files that differ between the
+// trees of two commits, renamed files under both names. Comparing trees
+// rather than walking commits also covers files brought in by the other
+// parents of merges. An empty toCommit compares against HEAD.
+// ErrCommitNotFound is returned when either commit is not in the repository.
+func (m *Manager) ChangedFiles(repoPath, fromCommit, toCommit string) ([]string, error) {
+	repo, err := openRepository(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	if toCommit == "" {
+		ref, err := repo.Head()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get HEAD: %w", err)
+		}
+		toCommit = ref.Hash().String()
+	}
+
+	fromTree, err := commitTree(repo, fromCommit)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := commitTree(repo, toCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare %s and %s: %w", fromCommit, toCommit, err)
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				files = append(files, name)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// commitTree returns the tree of a commit given by its full hash
+func commitTree(repo *git.Repository, hash string) (*object.Tree, error) {
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrCommitNotFound, hash)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of %s: %w", hash, err)
+	}
+	return tree, nil
+}
@@ -2,10 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -72,7 +77,7 @@ build/
 
 	// Test file walking with gitignore
 	var discoveredFiles []string
-	err = manager.WalkFiles(context.Background(), tempDir, func(filePath string, info fs.FileInfo) error {
+	err = manager.WalkFiles(context.Background(), tempDir, nil, func(filePath string, info fs.FileInfo) error {
 		relPath, _ := filepath.Rel(tempDir, filePath)
 		discoveredFiles = append(discoveredFiles, relPath)
 		return nil
@@ -237,6 +242,141 @@ func TestCommitHistoryNonGitRepo(t *testing.T) {
 	}
 }
 
+func TestCommitHistorySinceCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repoDir := t.TempDir()
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	git("init", "--quiet")
+	write("a.go", "package a\n")
+	write("b.go", "package b\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	first := git("rev-parse", "HEAD")
+
+	write("a.go", "package a\n\nfunc A() {}\n")
+	git("commit", "--quiet", "-am", "change a")
+
+	git("rm", "--quiet", "b.go")
+	write("c.go", "package c\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "replace b with c")
+
+	manager, err := NewManager(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	commits, err := manager.GetCommitHistory(repoDir, first, 10)
+	if err != nil {
+		t.Fatalf("GetCommitHistory failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Expected the 2 commits made since the first one, got %d", len(commits))
+	}
+	if got := strings.Join(commits[0].Files, ","); got != "b.go,c.go" {
+		t.Errorf("Expected the newest commit to touch b.go and c.go, got %s", got)
+	}
+	if got := strings.Join(commits[1].Files, ","); got != "a.go" {
+		t.Errorf("Expected the second commit to touch a.go, got %s", got)
+	}
+
+	// The limit applies before the start commit is reached
+	if commits, err := manager.GetCommitHistory(repoDir, first, 1); err != nil || len(commits) != 1 {
+		t.Errorf("Expected 1 commit within the limit, got %d (%v)", len(commits), err)
+	}
+
+	_, err = manager.GetCommitHistory(repoDir, strings.Repeat("0", 40), 10)
+	if !errors.Is(err, ErrCommitNotFound) {
+		t.Errorf("Expected ErrCommitNotFound for an unknown commit, got %v", err)
+	}
+}
+
+func TestChangedFilesAcrossMerges(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repoDir := t.TempDir()
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	git("init", "--quiet", "--initial-branch=main")
+	write("a.go", "package a\n")
+	write("b.go", "package b\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	first := git("rev-parse", "HEAD")
+
+	// A file changed only on a merged branch is reached through the
+	// second parent of the merge
+	git("checkout", "--quiet", "-b", "feature")
+	write("feature.go", "package feature\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "add feature")
+
+	git("checkout", "--quiet", "main")
+	write("a.go", "package a\n\nfunc A() {}\n")
+	git("commit", "--quiet", "-am", "change a")
+	git("merge", "--quiet", "--no-ff", "-m", "merge feature", "feature")
+
+	git("mv", "b.go", "renamed.go")
+	git("commit", "--quiet", "-m", "rename b")
+
+	manager, err := NewManager(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	files, err := manager.ChangedFiles(repoDir, first, "")
+	if err != nil {
+		t.Fatalf("ChangedFiles failed: %v", err)
+	}
+	if got := strings.Join(files, ","); got != "a.go,b.go,feature.go,renamed.go" {
+		t.Errorf("Expected a.go, b.go, feature.go and renamed.go to have changed, got %s", got)
+	}
+
+	head := git("rev-parse", "HEAD")
+	if files, err := manager.ChangedFiles(repoDir, head, head); err != nil || len(files) != 0 {
+		t.Errorf("Expected no changes between a commit and itself, got %v (%v)", files, err)
+	}
+
+	_, err = manager.ChangedFiles(repoDir, strings.Repeat("0", 40), "")
+	if !errors.Is(err, ErrCommitNotFound) {
+		t.Errorf("Expected ErrCommitNotFound for an unknown commit, got %v", err)
+	}
+}
+
 func TestGitignoreCache(t *testing.T) {
 	// Create temporary directory
 	tempDir, err := os.MkdirTemp("", "test-repo-*")
@@ -252,38 +392,40 @@ func TestGitignoreCache(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create .gitignore: %v", err)
 	}
+	for _, name := range []string{"main.go", "app.log", "app.tmp"} {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
 
 	// Create manager
 	logger := zap.NewNop()
-	manager, err := NewManager(tempDir, logger)
+	manager, err := NewManager(t.TempDir(), logger)
 	if err != nil {
 		t.Fatalf("Failed to create manager: %v", err)
 	}
 
-	// Load gitignore first time
-	gi1 := manager.loadGitignore(tempDir)
-	if gi1 == nil {
-		t.Error("Expected gitignore to be loaded")
+	// Walking twice reads the same rules
+	first := walkedFiles(t, manager, tempDir)
+	if slices.Contains(first, "app.log") || !slices.Contains(first, "main.go") {
+		t.Errorf("Expected app.log to be ignored, got %v", first)
 	}
-
-	// Load gitignore second time (should use cache)
-	gi2 := manager.loadGitignore(tempDir)
-	if gi2 == nil {
-		t.Error("Expected gitignore to be loaded from cache")
+	if second := walkedFiles(t, manager, tempDir); !slices.Equal(first, second) {
+		t.Errorf("Expected the same files on the second walk, got %v and %v", first, second)
 	}
 
-	// Should be the same instance (cached)
-	if gi1 != gi2 {
-		t.Error("Expected same gitignore instance from cache")
+	// Rules are not kept between walks, so a changed .gitignore applies to
+	// the next one
+	if err := os.WriteFile(gitignorePath, []byte("*.tmp\n"), 0644); err != nil {
+		t.Fatalf("Failed to update .gitignore: %v", err)
 	}
-
-	// Test that cache contains the entry
-	if len(manager.gitignores) != 1 {
-		t.Errorf("Expected 1 cached gitignore, got %d", len(manager.gitignores))
+	walked := walkedFiles(t, manager, tempDir)
+	if !slices.Contains(walked, "app.log") || slices.Contains(walked, "app.tmp") {
+		t.Errorf("Expected the changed .gitignore to apply, got %v", walked)
 	}
 }
 
-func TestIsIgnoredByGit(t *testing.T) {
+func TestWalkFilesGitignore(t *testing.T) {
 	// Create temporary directory
 	tempDir, err := os.MkdirTemp("", "test-repo-*")
 	if err != nil {
@@ -303,15 +445,15 @@ build/
 
 	// Create manager
 	logger := zap.NewNop()
-	manager, err := NewManager(tempDir, logger)
+	manager, err := NewManager(t.TempDir(), logger)
 	if err != nil {
 		t.Fatalf("Failed to create manager: %v", err)
 	}
 
 	// Test ignored files
 	testCases := []struct {
-		file     string
-		ignored  bool
+		file    string
+		ignored bool
 	}{
 		{"main.go", false},
 		{"app.log", true},
@@ -319,12 +461,103 @@ build/
 		{"build/output.js", true},
 		{"src/main.go", false},
 	}
+	for _, tc := range testCases {
+		filePath := filepath.Join(tempDir, filepath.FromSlash(tc.file))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte("data"), 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
 
+	walked := walkedFiles(t, manager, tempDir)
 	for _, tc := range testCases {
-		filePath := filepath.Join(tempDir, tc.file)
-		ignored := manager.isIgnoredByGit(filePath, tempDir)
-		if ignored != tc.ignored {
+		if ignored := !slices.Contains(walked, tc.file); ignored != tc.ignored {
 			t.Errorf("File %s: expected ignored=%v, got %v", tc.file, tc.ignored, ignored)
 		}
 	}
 }
+
+func TestObjectCacheSharesObjects(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	tempDir, err := os.MkdirTemp("", "test-object-cache-*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Create a source repository to clone from
+	sourceDir := filepath.Join(tempDir, "source")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "initial"},
+	} {
+		if err := runGit(context.Background(), sourceDir, args...); err != nil {
+			t.Fatalf("Failed to set up source repository: %v", err)
+		}
+	}
+
+	repoDir := filepath.Join(tempDir, "repositories")
+	manager, err := NewManager(repoDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := manager.EnableObjectCache(filepath.Join(repoDir, ".object-cache"), time.Nanosecond); err != nil {
+		t.Fatalf("Failed to enable object cache: %v", err)
+	}
+
+	// Two clones of the same URL borrow from one mirror
+	mirror := manager.objectCache.mirrorPath(sourceDir)
+	for _, name := range []string{"first", "second"} {
+		clonePath := filepath.Join(repoDir, name)
+		if err := manager.objectCache.clone(context.Background(), sourceDir, clonePath); err != nil {
+			t.Fatalf("Failed to clone %s: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(clonePath, "main.go")); err != nil {
+			t.Errorf("Clone %s is missing main.go: %v", name, err)
+		}
+
+		alternates, err := os.ReadFile(filepath.Join(clonePath, ".git", "objects", "info", "alternates"))
+		if err != nil {
+			t.Fatalf("Clone %s has no alternates: %v", name, err)
+		}
+		if !strings.Contains(string(alternates), mirror) {
+			t.Errorf("Clone %s does not borrow from %s: %s", name, mirror, alternates)
+		}
+	}
+
+	// Git must never delete objects the clones borrow
+	for setting, want := range map[string]string{"gc.auto": "0", "gc.pruneExpire": "never"} {
+		output, err := exec.Command("git", "-C", mirror, "config", setting).Output()
+		if err != nil {
+			t.Fatalf("Mirror has no %s setting: %v", setting, err)
+		}
+		if got := strings.TrimSpace(string(output)); got != want {
+			t.Errorf("Expected mirror %s = %s, got %s", setting, want, got)
+		}
+	}
+
+	// Mirrors are kept while a clone borrows from them
+	manager.objectCache.prune(repoDir)
+	if _, err := os.Stat(mirror); err != nil {
+		t.Fatalf("Mirror was removed while clones use it: %v", err)
+	}
+
+	os.RemoveAll(filepath.Join(repoDir, "first"))
+	os.RemoveAll(filepath.Join(repoDir, "second"))
+	manager.objectCache.prune(repoDir)
+	if _, err := os.Stat(mirror); !os.IsNotExist(err) {
+		t.Errorf("Expected unused mirror to be removed, stat error: %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"go.uber.org/zap"
+)
+
+// objectCache keeps a bare mirror per remote URL. Clones borrow objects from
+// the mirror through git alternates, so cloning a URL a second time only
+// checks out files and every clone of the URL shares one object store.
+type objectCache struct {
+	dir    string
+	maxAge time.Duration
+	logger *zap.Logger
+
+	mutex sync.Mutex
+	locks map[string]*sync.Mutex // Serialises work on each mirror
+}
+
+// EnableObjectCache makes the manager clone remote repositories through
+// shared mirrors in dir. Mirrors no clone depends on any more are removed
+// once unused for maxAge; zero keeps them forever. The cache needs the git
+// command line tool; without it clones fall back to full go-git clones.
+func (m *Manager) EnableObjectCache(dir string, maxAge time.Duration) error {
+	if _, err := exec.LookPath("git"); err != nil {
+		m.logger.Warn("git not found, clone object cache disabled", zap.Error(err))
+		return nil
+	}
+	// Alternates store absolute paths, so the cache must not move with the
+	// working directory
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid object cache directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create object cache directory: %w", err)
+	}
+
+	m.objectCache = &objectCache{
+		dir:    dir,
+		maxAge: maxAge,
+		logger: m.logger,
+		locks:  make(map[string]*sync.Mutex),
+	}
+	m.logger.Info("Clone object cache enabled", zap.String("dir", dir), zap.Duration("max_age", maxAge))
+	return nil
+}
+
+// ObjectCacheEnabled reports whether clones go through the object cache
+func (m *Manager) ObjectCacheEnabled() bool {
+	return m.objectCache != nil
+}
+
+// mirrorPath returns where the mirror of a URL is kept
+func (c *objectCache) mirrorPath(repoURL string) string {
+	hash := sha256.Sum256([]byte(repoURL))
+	return filepath.Join(c.dir, fmt.Sprintf("%x.git", hash[:8]))
+}
+
+// lock returns the mutex guarding a mirror
+func (c *objectCache) lock(mirror string) *sync.Mutex {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.locks[mirror]; !ok {
+		c.locks[mirror] = &sync.Mutex{}
+	}
+	return c.locks[mirror]
+}
+
+// clone clones repoURL into repoPath, creating or refreshing the URL's mirror
+// first and borrowing its objects
+func (c *objectCache) clone(ctx context.Context, repoURL, repoPath string) error {
+	mirror := c.mirrorPath(repoURL)
+	mirrorLock := c.lock(mirror)
+	mirrorLock.Lock()
+	defer mirrorLock.Unlock()
+
+	if _, err := os.Stat(mirror); err == nil {
+		c.logger.Info("Refreshing cached mirror", zap.String("url", repoURL), zap.String("mirror", mirror))
+		reportCloneProgress(ctx, "Refreshing cached mirror")
+		// Mirrors created before objects were protected get the settings
+		// before the fetch can trigger an automatic gc
+		if err := keepBorrowedObjects(ctx, mirror); err != nil {
+			return err
+		}
+		if err := runGit(ctx, mirror, "remote", "update", "--prune"); err != nil {
+			// A stale mirror still saves most of the transfer
+			c.logger.Warn("Failed to refresh cached mirror, using it as is", zap.String("mirror", mirror), zap.Error(err))
+		}
+	} else {
+		c.logger.Info("Creating cached mirror", zap.String("url", repoURL), zap.String("mirror", mirror))
+		reportCloneProgress(ctx, "Creating cached mirror")
+		if err := runGit(ctx, "", "clone", "--mirror", "--quiet", repoURL, mirror); err != nil {
+			os.RemoveAll(mirror)
+			return fmt.Errorf("failed to mirror repository: %w", err)
+		}
+		if err := keepBorrowedObjects(ctx, mirror); err != nil {
+			os.RemoveAll(mirror)
+			return err
+		}
+	}
+
+	// Clone from the mirror with alternates, then point origin back at the
+	// real remote so later pulls do not depend on the cache
+	reportCloneProgress(ctx, "Cloning from cached mirror")
+	if err := runGit(ctx, "", "clone", "--shared", "--quiet", mirror, repoPath); err != nil {
+		return fmt.Errorf("failed to clone from cached mirror: %w", err)
+	}
+	if err := runGit(ctx, repoPath, "remote", "set-url", "origin", repoURL); err != nil {
+		return fmt.Errorf("failed to set clone origin: %w", err)
+	}
+
+	now := time.Now()
+	if err := os.Chtimes(mirror, now, now); err != nil {
+		c.logger.Debug("Failed to update mirror access time", zap.String("mirror", mirror), zap.Error(err))
+	}
+
+	return nil
+}
+
+// openRepository opens the clone at repoPath. go-git looks up the paths in
+// alternates inside the .git directory unless given a file system for
+// them, so clones borrowing from a mirror get the root of the file system
+// and their refs resolve to the mirror's objects.
+func openRepository(repoPath string) (*git.Repository, error) {
+	repoPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(filepath.Join(repoPath, git.GitDirName)); err != nil || !info.IsDir() {
+		// Let go-git report a missing repository or follow a .git file
+		return git.PlainOpen(repoPath)
+	}
+
+	worktree := osfs.New(repoPath)
+	dotGit, err := worktree.Chroot(git.GitDirName)
+	if err != nil {
+		return nil, err
+	}
+	root := osfs.New(filepath.VolumeName(repoPath) + string(filepath.Separator))
+	storage := filesystem.NewStorageWithOptions(dotGit, cache.NewObjectLRUDefault(), filesystem.Options{AlternatesFS: root})
+	return git.Open(storage, worktree)
+}
+
+// keepBorrowedObjects stops git from deleting objects in a mirror. Clones
+// read the mirror's objects through alternates without the mirror knowing,
+// so an object that a pruned branch left unreachable may still be needed by
+// a clone; automatic gc and pruning are therefore disabled.
+func keepBorrowedObjects(ctx context.Context, mirror string) error {
+	for _, setting := range [][2]string{
+		{"gc.auto", "0"},
+		{"gc.pruneExpire", "never"},
+		{"gc.reflogExpireUnreachable", "never"},
+	} {
+		if err := runGit(ctx, mirror, "config", setting[0], setting[1]); err != nil {
+			return fmt.Errorf("failed to configure cached mirror: %w", err)
+		}
+	}
+	return nil
+}
+
+// prune removes mirrors that no clone under repoDirs borrows objects from
+// and that have not been used for maxAge. Mirrors are only removed when no
+// remaining clone lists them in its alternates, since deleting a borrowed
+// object store would corrupt its clones.
+func (c *objectCache) prune(repoDirs ...string) {
+	if c.maxAge <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		c.logger.Warn("Failed to read object cache", zap.String("dir", c.dir), zap.Error(err))
+		return
+	}
+
+	borrowed := borrowedObjectStores(repoDirs, c.dir)
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasSuffix(entry.Name(), ".git") {
+			continue
+		}
+
+		mirror := filepath.Join(c.dir, entry.Name())
+		objectsDir, _ := filepath.Abs(filepath.Join(mirror, "objects"))
+		if borrowed[objectsDir] {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < c.maxAge {
+			continue
+		}
+
+		mirrorLock := c.lock(mirror)
+		mirrorLock.Lock()
+		if err := os.RemoveAll(mirror); err != nil {
+			c.logger.Warn("Failed to remove unused mirror", zap.String("mirror", mirror), zap.Error(err))
+		} else {
+			c.logger.Info("Removed unused mirror", zap.String("mirror", mirror))
+		}
+		mirrorLock.Unlock()
+	}
+}
+
+// borrowedObjectStores returns the object directories that clones below
+// the given directories reference through alternates. Working trees of
+// clones are not descended into.
+func borrowedObjectStores(repoDirs []string, skipDir string) map[string]bool {
+	borrowed := make(map[string]bool)
+	for _, root := range repoDirs {
+		filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if path == skipDir {
+				return filepath.SkipDir
+			}
+
+			gitDir := filepath.Join(path, ".git")
+			if _, err := os.Stat(gitDir); err != nil {
+				return nil
+			}
+
+			if file, err := os.Open(filepath.Join(gitDir, "objects", "info", "alternates")); err == nil {
+				scanner := bufio.NewScanner(file)
+				for scanner.Scan() {
+					if line := strings.TrimSpace(scanner.Text()); line != "" {
+						if !filepath.IsAbs(line) {
+							line = filepath.Join(gitDir, "objects", line)
+						}
+						borrowed[filepath.Clean(line)] = true
+					}
+				}
+				file.Close()
+			}
+			return filepath.SkipDir
+		})
+	}
+	return borrowed
+}
+
+// runGit runs a git command, in dir when it is not empty
+func runGit(ctx context.Context, dir string, args ...string) error {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
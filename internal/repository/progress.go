@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"strings"
+)
+
+// CloneProgressFunc receives the progress messages of a clone or pull, such
+// as "Receiving objects:  45% (450/1000)". It is called from the goroutine
+// doing the transfer, so it should return quickly.
+type CloneProgressFunc func(message string)
+
+// cloneProgressKey is the context key of the CloneProgressFunc
+type cloneProgressKey struct{}
+
+// WithCloneProgress returns a context under which PrepareRepository reports
+// the progress of cloning or pulling a remote repository to fn
+func WithCloneProgress(ctx context.Context, fn CloneProgressFunc) context.Context {
+	return context.WithValue(ctx, cloneProgressKey{}, fn)
+}
+
+// reportCloneProgress passes a message to the CloneProgressFunc of ctx, if any
+func reportCloneProgress(ctx context.Context, message string) {
+	if fn, ok := ctx.Value(cloneProgressKey{}).(CloneProgressFunc); ok && fn != nil {
+		fn(message)
+	}
+}
+
+// cloneProgressWriter returns the writer go-git sends the remote's progress
+// output to. Progress goes to the CloneProgressFunc of ctx one line at a
+// time, and is discarded without one; it must never reach stdout, which
+// carries the stdio transport.
+func cloneProgressWriter(ctx context.Context) io.Writer {
+	if fn, ok := ctx.Value(cloneProgressKey{}).(CloneProgressFunc); ok && fn != nil {
+		return &progressWriter{report: fn}
+	}
+	return io.Discard
+}
+
+// progressWriter splits the remote's progress output into messages. Git
+// ends a line with a carriage return while it updates it in place and with
+// a newline once it is done.
+type progressWriter struct {
+	report  CloneProgressFunc
+	pending strings.Builder
+}
+
+// Write reports every complete line of p, keeping the rest for the next call
+func (w *progressWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		if b != '\r' && b != '\n' {
+			w.pending.WriteByte(b)
+			continue
+		}
+		if message := strings.TrimSpace(w.pending.String()); message != "" {
+			w.report(message)
+		}
+		w.pending.Reset()
+	}
+	return len(p), nil
+}
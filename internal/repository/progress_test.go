@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestCloneProgressWriter(t *testing.T) {
+	if w := cloneProgressWriter(context.Background()); w != io.Discard {
+		t.Errorf("Expected progress to be discarded without a CloneProgressFunc, got %T", w)
+	}
+
+	var messages []string
+	ctx := WithCloneProgress(context.Background(), func(message string) {
+		messages = append(messages, message)
+	})
+	w := cloneProgressWriter(ctx)
+
+	// Lines may be split across writes and updated in place
+	for _, chunk := range []string{"Counting obj", "ects: 50% (1/2)\rCounting objects: 100% (2/2), done.\n", "\r\n", "Receiving"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	want := []string{"Counting objects: 50% (1/2)", "Counting objects: 100% (2/2), done."}
+	if !slices.Equal(messages, want) {
+		t.Errorf("Expected messages %q, got %q", want, messages)
+	}
+}
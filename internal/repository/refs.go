@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"go.uber.org/zap"
+)
+
+// ErrRefNotFound is returned when a branch, tag or commit does not exist in
+// a repository
+var ErrRefNotFound = errors.New("ref not found")
+
+// RefName returns the name a repository prepared at a ref gets by default:
+// the repository name and the ref joined by "@", with the slashes of branch
+// names such as feature/login replaced so the name stays one directory
+func RefName(name, ref string) string {
+	return name + "@" + strings.NewReplacer("/", "-", `\`, "-").Replace(ref)
+}
+
+// CheckoutRef checks out a branch, tag or commit in the working copy at
+// repoPath and returns the hash of the commit checked out. HEAD is
+// detached, and local changes to tracked files are discarded. Branches are
+// looked up among the branches fetched from origin before the local ones,
+// so a fetched branch is checked out at its latest commit.
+func (m *Manager) CheckoutRef(ctx context.Context, repoPath, ref string) (string, error) {
+	return m.checkoutRef(ctx, repoPath, ref, nil)
+}
+
+// checkoutRef checks out ref like CheckoutRef, only the files below the
+// sparse directories when there are any. Without a ref the branch HEAD is
+// on is moved to the latest commit fetched from origin and checked out.
+func (m *Manager) checkoutRef(ctx context.Context, repoPath, ref string, sparse []string) (string, error) {
+	repo, err := openRepository(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open repository: %w", err)
+	}
+
+	options := &git.CheckoutOptions{Force: true}
+	var hash plumbing.Hash
+	if ref == "" {
+		options.Branch, hash, err = headBranch(repo)
+	} else {
+		hash, err = resolveRef(repo, ref)
+	}
+	if err != nil {
+		return "", err
+	}
+	if options.Branch == "" {
+		// Checking out a commit detaches HEAD
+		options.Hash = hash
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get worktree: %w", err)
+	}
+	reportCloneProgress(ctx, fmt.Sprintf("Checking out %s", ref))
+	if err := checkout(repo, worktree, options, hash, sparse); err != nil {
+		return "", fmt.Errorf("failed to check out %s: %w", ref, err)
+	}
+
+	m.logger.Info("Ref checked out",
+		zap.String("path", repoPath),
+		zap.String("ref", ref),
+		zap.String("commit", hash.String()),
+		zap.Strings("sparse_patterns", sparse))
+	return hash.String(), nil
+}
+
+// checkout checks out a commit, only the files below the sparse
+// directories when there are any. go-git marks the files left out of a
+// sparse checkout only among those already in the index, fails on the
+// directories of left out files missing from the working tree, and writes
+// out left out files in directories it creates. So the index is brought to
+// the commit first, the directories of left out files are created, and
+// those files are removed again once the working tree is reset.
+func checkout(repo *git.Repository, worktree *git.Worktree, options *git.CheckoutOptions, hash plumbing.Hash, sparse []string) error {
+	if len(sparse) == 0 {
+		return worktree.Checkout(options)
+	}
+
+	options.Force, options.Keep = false, true
+	if err := worktree.Checkout(options); err != nil {
+		return err
+	}
+	if err := worktree.Reset(&git.ResetOptions{Commit: hash, Mode: git.MixedReset}); err != nil {
+		return err
+	}
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	for _, entry := range idx.Entries {
+		if !inSparseCheckout(entry.Name, sparse) {
+			if err := worktree.Filesystem.MkdirAll(path.Dir(entry.Name), 0755); err != nil {
+				return err
+			}
+		}
+	}
+	if err := worktree.ResetSparsely(&git.ResetOptions{Commit: hash, Mode: git.HardReset}, sparse); err != nil {
+		return err
+	}
+
+	if idx, err = repo.Storer.Index(); err != nil {
+		return err
+	}
+	for _, entry := range idx.Entries {
+		if inSparseCheckout(entry.Name, sparse) {
+			continue
+		}
+		entry.SkipWorktree = true
+		if err := worktree.Filesystem.Remove(entry.Name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		// Directories emptied are removed too; removing one still holding
+		// files fails
+		for dir := path.Dir(entry.Name); dir != "."; dir = path.Dir(dir) {
+			if worktree.Filesystem.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return repo.Storer.SetIndex(idx)
+}
+
+// inSparseCheckout reports whether a file, by its slash-separated path in
+// the repository, lies below one of the sparse directories
+func inSparseCheckout(name string, sparse []string) bool {
+	return slices.ContainsFunc(sparse, func(dir string) bool { return strings.HasPrefix(name, dir) })
+}
+
+// headBranch moves the branch HEAD is on to the latest commit fetched from
+// origin and returns it with the commit. A detached HEAD is returned as its
+// commit with no branch.
+func headBranch(repo *git.Repository) (plumbing.ReferenceName, plumbing.Hash, error) {
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if head.Type() != plumbing.SymbolicReference {
+		return "", head.Hash(), nil
+	}
+
+	branch := head.Target()
+	hash, err := resolveRef(repo, branch.Short())
+	if err != nil {
+		return "", plumbing.ZeroHash, err
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branch, hash)); err != nil {
+		return "", plumbing.ZeroHash, fmt.Errorf("failed to update branch %s: %w", branch.Short(), err)
+	}
+	return branch, hash, nil
+}
+
+// resolveRef returns the commit a branch, tag or commit hash names
+func resolveRef(repo *git.Repository, ref string) (plumbing.Hash, error) {
+	for _, revision := range []string{"refs/remotes/origin/" + ref, ref} {
+		hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+		if err == nil {
+			return *hash, nil
+		}
+	}
+	return plumbing.ZeroHash, fmt.Errorf("%w: %s", ErrRefNotFound, ref)
+}
+
+// checkoutCloned checks out ref in a fresh clone, if one is given, and the
+// sparse directories of a clone made without checking out
+func (m *Manager) checkoutCloned(ctx context.Context, repoPath, ref string, sparse []string) error {
+	if ref == "" && len(sparse) == 0 {
+		return nil
+	}
+	_, err := m.checkoutRef(ctx, repoPath, ref, sparse)
+	return err
+}
+
+// fetchAndCheckout fetches the branches and tags of an existing clone and
+// checks out ref, or the latest commit of its branch without one. A failed
+// fetch is logged and the ref is looked up among what was fetched before,
+// so clones of unreachable sources stay usable.
+func (m *Manager) fetchAndCheckout(ctx context.Context, repoPath, ref string, opts CloneOptions, auth transport.AuthMethod) error {
+	repo, err := openRepository(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to open existing repository: %w", err)
+	}
+
+	reportCloneProgress(ctx, "Fetching updates")
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		Tags:     git.AllTags,
+		Depth:    opts.Depth,
+		Auth:     auth,
+		Progress: cloneProgressWriter(ctx),
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		m.logger.Warn("Failed to fetch updates, continuing with existing refs", zap.String("path", repoPath), zap.Error(err))
+	}
+
+	_, err = m.checkoutRef(ctx, repoPath, ref, opts.SparsePatterns)
+	return err
+}
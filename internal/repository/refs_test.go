@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestPrepareRepositoryAtRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	tempDir := t.TempDir()
+	sourceDir := filepath.Join(tempDir, "project")
+	if err := os.MkdirAll(sourceDir, 0755); err != nil {
+		t.Fatalf("Failed to create source dir: %v", err)
+	}
+	git := func(args ...string) string {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = sourceDir
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+		return strings.TrimSpace(string(output))
+	}
+	write := func(content string) {
+		if err := os.WriteFile(filepath.Join(sourceDir, "main.go"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write main.go: %v", err)
+		}
+	}
+
+	git("init", "--quiet", "--initial-branch=main")
+	write("package main // v1\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	git("tag", "v1")
+	tagged := git("rev-parse", "HEAD")
+	git("checkout", "--quiet", "-b", "feature/next")
+	write("package main // next\n")
+	git("commit", "--quiet", "-am", "next")
+	next := git("rev-parse", "HEAD")
+	git("checkout", "--quiet", "main")
+
+	manager, err := NewManager(filepath.Join(tempDir, "repositories"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := manager.AllowLocalRepositories(tempDir); err != nil {
+		t.Fatalf("Failed to allow local repositories: %v", err)
+	}
+
+	ctx := context.Background()
+	repo, err := manager.PrepareRepositoryAt(ctx, sourceDir, "", "feature/next")
+	if err != nil {
+		t.Fatalf("PrepareRepositoryAt failed: %v", err)
+	}
+	if repo.Name != "project@feature-next" || repo.Ref != "feature/next" {
+		t.Errorf("Expected the clone to be named after the ref, got %s at %s", repo.Name, repo.Ref)
+	}
+	if repo.Path != filepath.Join(tempDir, "repositories", "project@feature-next") {
+		t.Errorf("Expected a clone in the repository directory, got %s", repo.Path)
+	}
+	if repo.LastIndexedHash != next {
+		t.Errorf("Expected the branch to be checked out at %s, got %s", next, repo.LastIndexedHash)
+	}
+	if repo.URL != sourceDir {
+		t.Errorf("Expected the local repository to be recorded as the source, got %s", repo.URL)
+	}
+	if head := git("rev-parse", "--abbrev-ref", "HEAD"); head != "main" {
+		t.Errorf("Expected the local working copy to stay on main, got %s", head)
+	}
+
+	// A second ref of the same repository is kept apart
+	tag, err := manager.PrepareRepositoryAt(ctx, sourceDir, "", "v1")
+	if err != nil {
+		t.Fatalf("PrepareRepositoryAt failed: %v", err)
+	}
+	if tag.ID == repo.ID || tag.LastIndexedHash != tagged {
+		t.Errorf("Expected a separate repository at %s, got %+v", tagged, tag)
+	}
+
+	// An existing clone is switched to another ref in place
+	switched, err := manager.PrepareRepositoryAt(ctx, sourceDir, repo.Name, "v1")
+	if err != nil {
+		t.Fatalf("PrepareRepositoryAt failed: %v", err)
+	}
+	if switched.ID != repo.ID || switched.LastIndexedHash != tagged {
+		t.Errorf("Expected %s to be checked out in the same clone, got %+v", tagged, switched)
+	}
+	content, err := os.ReadFile(filepath.Join(switched.Path, "main.go"))
+	if err != nil || string(content) != "package main // v1\n" {
+		t.Errorf("Expected the working copy to hold the tagged content, got %q (%v)", content, err)
+	}
+
+	if _, err := manager.CheckoutRef(ctx, switched.Path, "missing"); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("Expected ErrRefNotFound for an unknown ref, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ErrOutsideSandbox is returned for paths that resolve outside every
+// registered repository root and allowed path
+var ErrOutsideSandbox = errors.New("path is outside the indexed repositories and allowed paths")
+
+// sandbox confines the file tools to registered roots. Roots and checked
+// paths are compared after resolving symlinks, so a link inside a repository
+// cannot be used to reach files outside it.
+type sandbox struct {
+	mutex sync.RWMutex
+	roots []string
+}
+
+// add registers a root directory, returning its resolved form
+func (sb *sandbox) add(root string) (string, error) {
+	resolved, err := resolvePath(root)
+	if err != nil {
+		return "", err
+	}
+
+	sb.mutex.Lock()
+	defer sb.mutex.Unlock()
+	for _, existing := range sb.roots {
+		if existing == resolved {
+			return resolved, nil
+		}
+	}
+	sb.roots = append(sb.roots, resolved)
+	return resolved, nil
+}
+
+// contains reports whether a resolved path lies within a registered root
+func (sb *sandbox) contains(resolved string) bool {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+	for _, root := range sb.roots {
+		if isWithin(root, resolved) {
+			return true
+		}
+	}
+	return false
+}
+
+// list returns the registered roots
+func (sb *sandbox) list() []string {
+	sb.mutex.RLock()
+	defer sb.mutex.RUnlock()
+	return append([]string(nil), sb.roots...)
+}
+
+// RegisterRoot allows the file tools to access everything below dir.
+// Prepared repositories and the clone directory are registered
+// automatically; this is for configured allowlist entries and repositories
+// indexed by an earlier run.
+func (m *Manager) RegisterRoot(dir string) error {
+	resolved, err := m.sandbox.add(dir)
+	if err != nil {
+		return fmt.Errorf("invalid sandbox root %s: %w", dir, err)
+	}
+	m.logger.Debug("Sandbox root registered", zap.String("root", resolved))
+	return nil
+}
+
+// SandboxRoots returns the resolved directories the file tools may access
+func (m *Manager) SandboxRoots() []string {
+	return m.sandbox.list()
+}
+
+// ResolvePath returns the absolute, symlink-free form of a path, or an error
+// wrapping ErrOutsideSandbox if it lies outside every registered root.
+// Relative paths are taken relative to the working directory. The path does
+// not have to exist; its nearest existing parent is resolved instead.
+func (m *Manager) ResolvePath(path string) (string, error) {
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	if !m.sandbox.contains(resolved) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideSandbox, path)
+	}
+	return resolved, nil
+}
+
+// ReadFile reads a file after checking it against the sandbox. Unlike
+// GetFileContent, which the indexer uses on files it walked itself, it is
+// safe to call with paths supplied by clients.
+func (m *Manager) ReadFile(path string) ([]byte, error) {
+	resolved, err := m.ResolvePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(resolved)
+}
+
+// WriteFile replaces the content of an existing file after checking it
+// against the sandbox. The content goes to a temporary file in the same
+// directory that is renamed over the original, so readers never see a
+// partly written file; the original permissions are kept.
+func (m *Manager) WriteFile(path string, data []byte) error {
+	resolved, err := m.ResolvePath(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(resolved), "."+filepath.Base(resolved)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set file permissions: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), resolved); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
+
+// CreateFile creates a file that does not exist yet, with its missing
+// parent directories, after checking it against the sandbox
+func (m *Manager) CreateFile(path string, data []byte) error {
+	resolved, err := m.ResolvePath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(resolved), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	file, err := os.OpenFile(resolved, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return file.Close()
+}
+
+// resolvePath makes a path absolute and resolves its symlinks. For paths
+// that do not exist yet the nearest existing parent is resolved and the
+// remaining elements are appended unchanged.
+func resolvePath(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	existing := absPath
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return absPath, nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+}
+
+// isWithin reports whether path is root or lies below it. Both must be
+// absolute and clean.
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
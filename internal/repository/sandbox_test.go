@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestResolvePathRejectsTraversal(t *testing.T) {
+	tempDir := t.TempDir()
+	repoDir := filepath.Join(tempDir, "repositories")
+	manager, err := NewManager(repoDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	secret := filepath.Join(tempDir, "secret.txt")
+	if err := os.WriteFile(secret, []byte("secret\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	// The clone directory is a root from the start
+	if _, err := manager.ResolvePath(filepath.Join(repoDir, "project", "main.go")); err != nil {
+		t.Errorf("Expected path inside repo_dir to be allowed, got: %v", err)
+	}
+
+	for _, path := range []string{
+		secret,
+		filepath.Join(repoDir, "..", "secret.txt"),
+		filepath.Join(repoDir, "project", "..", "..", "secret.txt"),
+		repoDir + "-sibling",
+	} {
+		if _, err := manager.ResolvePath(path); !errors.Is(err, ErrOutsideSandbox) {
+			t.Errorf("Expected %s to be rejected, got: %v", path, err)
+		}
+	}
+
+	if _, err := manager.ReadFile(secret); !errors.Is(err, ErrOutsideSandbox) {
+		t.Errorf("Expected ReadFile outside the sandbox to fail, got: %v", err)
+	}
+	if err := manager.WriteFile(secret, []byte("changed\n")); !errors.Is(err, ErrOutsideSandbox) {
+		t.Errorf("Expected WriteFile outside the sandbox to fail, got: %v", err)
+	}
+	if content, _ := os.ReadFile(secret); string(content) != "secret\n" {
+		t.Errorf("File outside the sandbox was modified: %q", content)
+	}
+
+	// Allowlisted directories become accessible
+	if err := manager.RegisterRoot(tempDir); err != nil {
+		t.Fatalf("Failed to register root: %v", err)
+	}
+	if _, err := manager.ReadFile(secret); err != nil {
+		t.Errorf("Expected allowlisted file to be readable, got: %v", err)
+	}
+}
+
+func TestResolvePathFollowsSymlinks(t *testing.T) {
+	tempDir := t.TempDir()
+	outside := filepath.Join(tempDir, "outside")
+	if err := os.MkdirAll(outside, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+
+	localRepo := filepath.Join(tempDir, "local")
+	if err := os.MkdirAll(localRepo, 0755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(localRepo, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(localRepo, "escape")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	manager, err := NewManager(filepath.Join(tempDir, "repositories"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	// Preparing a local repository registers it as a root
+	if _, err := manager.ReadFile(filepath.Join(localRepo, "main.go")); !errors.Is(err, ErrOutsideSandbox) {
+		t.Errorf("Expected unprepared repository to be rejected, got: %v", err)
+	}
+	if err := manager.AllowLocalRepositories(localRepo); err != nil {
+		t.Fatalf("Failed to allow local repositories: %v", err)
+	}
+	if _, err := manager.PrepareRepository(context.Background(), localRepo, "local"); err != nil {
+		t.Fatalf("Failed to prepare repository: %v", err)
+	}
+	if content, err := manager.ReadFile(filepath.Join(localRepo, "main.go")); err != nil || string(content) != "package main\n" {
+		t.Errorf("Expected repository file to be readable, got %q: %v", content, err)
+	}
+
+	// A symlink inside the repository does not lead out of it, even for
+	// files that do not exist yet
+	for _, path := range []string{
+		filepath.Join(localRepo, "escape", "secret.txt"),
+		filepath.Join(localRepo, "escape", "new", "file.txt"),
+	} {
+		if _, err := manager.ResolvePath(path); !errors.Is(err, ErrOutsideSandbox) {
+			t.Errorf("Expected %s to be rejected, got: %v", path, err)
+		}
+	}
+}
+
+func TestWriteFileKeepsPermissions(t *testing.T) {
+	repoDir := t.TempDir()
+	manager, err := NewManager(repoDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	script := filepath.Join(repoDir, "run.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\n"), 0750); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := manager.WriteFile(script, []byte("#!/bin/sh\necho hi\n")); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	info, err := os.Stat(script)
+	if err != nil {
+		t.Fatalf("Failed to stat file: %v", err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("Expected permissions 0750, got %o", info.Mode().Perm())
+	}
+	if content, _ := os.ReadFile(script); string(content) != "#!/bin/sh\necho hi\n" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		t.Fatalf("Failed to read dir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only run.sh in %s, got %d entries", repoDir, len(entries))
+	}
+
+	if err := manager.WriteFile(filepath.Join(repoDir, "missing.go"), []byte("package main\n")); err == nil {
+		t.Error("Expected writing a missing file to fail")
+	}
+}
+
+func TestCreateFile(t *testing.T) {
+	repoDir := t.TempDir()
+	manager, err := NewManager(repoDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	file := filepath.Join(repoDir, "project", "tests", "test_app.py")
+	if err := manager.CreateFile(file, []byte("def test_app():\n    pass\n")); err != nil {
+		t.Fatalf("CreateFile failed: %v", err)
+	}
+	if content, _ := os.ReadFile(file); string(content) != "def test_app():\n    pass\n" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	if err := manager.CreateFile(file, []byte("")); err == nil {
+		t.Error("Expected creating an existing file to fail")
+	}
+	if err := manager.CreateFile(filepath.Join(repoDir, "..", "outside.py"), nil); !errors.Is(err, ErrOutsideSandbox) {
+		t.Errorf("Expected a file outside the sandbox to be rejected, got: %v", err)
+	}
+}
+
+func TestPrepareRepositoryRequiresAllowedPath(t *testing.T) {
+	tempDir := t.TempDir()
+	allowed := filepath.Join(tempDir, "projects")
+	inside := filepath.Join(allowed, "app")
+	outside := filepath.Join(tempDir, "elsewhere")
+	for _, dir := range []string{inside, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(allowed, "link")); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+
+	manager, err := NewManager(filepath.Join(tempDir, "repositories"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	if err := manager.AllowLocalRepositories(allowed); err != nil {
+		t.Fatalf("Failed to allow local repositories: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{inside, true},
+		{allowed, true},
+		{outside, false},
+		{filepath.Join(allowed, "link"), false}, // Resolves outside
+		{filepath.Join(inside, "..", "..", "elsewhere"), false},
+		{tempDir, false},
+	}
+
+	for _, tt := range tests {
+		_, err := manager.PrepareRepository(context.Background(), tt.path, "")
+		if tt.allowed && err != nil {
+			t.Errorf("Expected %s to be prepared, got: %v", tt.path, err)
+		}
+		if !tt.allowed && !errors.Is(err, ErrOutsideSandbox) {
+			t.Errorf("Expected %s to be rejected, got: %v", tt.path, err)
+		}
+	}
+
+	// Rejected paths are not opened to the file tools
+	if _, err := manager.ResolvePath(outside); !errors.Is(err, ErrOutsideSandbox) {
+		t.Errorf("Expected rejected repository to stay outside the sandbox, got: %v", err)
+	}
+}
+
+func TestPrepareRepositoryRejectsUnsafeNames(t *testing.T) {
+	tempDir := t.TempDir()
+	manager, err := NewManager(filepath.Join(tempDir, "repositories"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	// The names are rejected before anything is cloned, so the URL is
+	// never contacted
+	for _, name := range []string{"..", ".", "../escape", "a/b", `a\b`, "x..y"} {
+		_, err := manager.PrepareRepository(context.Background(), "https://example.invalid/org/repo.git", name)
+		if !errors.Is(err, ErrInvalidRepositoryName) {
+			t.Errorf("Expected name %q to be rejected, got: %v", name, err)
+		}
+	}
+	_, err = manager.PrepareRepository(context.Background(), "https://example.invalid/../..", "")
+	if !errors.Is(err, ErrInvalidRepositoryName) {
+		t.Errorf("Expected a generated name with .. to be rejected, got: %v", err)
+	}
+
+	if entries, _ := os.ReadDir(tempDir); len(entries) != 1 {
+		t.Errorf("Expected nothing to be created beside the repository directory, got %d entries", len(entries))
+	}
+}
+
+func TestRemoveClone(t *testing.T) {
+	tempDir := t.TempDir()
+	repoDir := filepath.Join(tempDir, "repositories")
+	manager, err := NewManager(repoDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	clone := filepath.Join(repoDir, "project")
+	local := filepath.Join(tempDir, "local")
+	for _, dir := range []string{filepath.Join(clone, "src"), local} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, path := range []string{local, repoDir, filepath.Join(clone, "src")} {
+		if err := manager.RemoveClone(path); !errors.Is(err, ErrNotClone) {
+			t.Errorf("Expected %s to be refused, got: %v", path, err)
+		}
+	}
+	if _, err := os.Stat(local); err != nil {
+		t.Errorf("Expected the local directory to be kept, got: %v", err)
+	}
+
+	if err := manager.RemoveClone(clone); err != nil {
+		t.Fatalf("Failed to remove clone: %v", err)
+	}
+	if _, err := os.Stat(clone); !os.IsNotExist(err) {
+		t.Errorf("Expected the clone to be deleted, got: %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+// Package sarif writes analysis results in the Static Analysis Results
+// Interchange Format 2.1.0, which GitHub code scanning and other tools
+// import.
+package sarif
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// Version and schema of the logs written
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Result levels
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNote    = "note"
+)
+
+// FingerprintKey is the partial fingerprint results are deduplicated by
+const FingerprintKey = "codeIndexerFingerprint/v1"
+
+// Log is a SARIF log file
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []*Run `json:"runs"`
+}
+
+// Run is the output of one analysis tool
+type Run struct {
+	Tool    Tool     `json:"tool"`
+	Results []Result `json:"results"`
+
+	ruleIndex map[string]int
+}
+
+// Tool describes the analysis tool of a run
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver names the tool and lists the rules it checks
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule is the metadata of a kind of result
+type Rule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name,omitempty"`
+	ShortDescription     Message                `json:"shortDescription"`
+	FullDescription      *Message               `json:"fullDescription,omitempty"`
+	Help                 *Message               `json:"help,omitempty"`
+	DefaultConfiguration Configuration          `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Configuration holds the level a rule reports at by default
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+// Message is plain text shown to users
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is one problem found by a rule
+type Result struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             Message                `json:"message"`
+	Locations           []Location             `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+// Location is where a result was found
+type Location struct {
+	PhysicalLocation PhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []LogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+// PhysicalLocation is a region of a file
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is a file by its slash-separated path relative to the
+// repository root
+type ArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// Region is a range of lines and columns, 1-based; zero values are left
+// out
+type Region struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// LogicalLocation is the symbol a result concerns
+type LogicalLocation struct {
+	Name string `json:"name"`
+}
+
+// NewLog returns a log of the given runs
+func NewLog(runs ...*Run) *Log {
+	return &Log{Schema: Schema, Version: Version, Runs: runs}
+}
+
+// NewRun returns a run of a tool without rules or results
+func NewRun(name, version, informationURI string) *Run {
+	return &Run{
+		Tool:      Tool{Driver: Driver{Name: name, Version: version, InformationURI: informationURI, Rules: []Rule{}}},
+		Results:   []Result{},
+		ruleIndex: make(map[string]int),
+	}
+}
+
+// AddRule adds a rule to the run, ignoring one already added
+func (r *Run) AddRule(rule Rule) {
+	if _, ok := r.ruleIndex[rule.ID]; ok {
+		return
+	}
+	r.ruleIndex[rule.ID] = len(r.Tool.Driver.Rules)
+	r.Tool.Driver.Rules = append(r.Tool.Driver.Rules, rule)
+}
+
+// AddResult adds a result to the run, pointing it at its rule, which must
+// have been added
+func (r *Run) AddResult(result Result) error {
+	index, ok := r.ruleIndex[result.RuleID]
+	if !ok {
+		return fmt.Errorf("result of unknown rule %q", result.RuleID)
+	}
+	result.RuleIndex = index
+	if result.Level == "" {
+		result.Level = r.Tool.Driver.Rules[index].DefaultConfiguration.Level
+	}
+	r.Results = append(r.Results, result)
+	return nil
+}
+
+// Level maps a severity of low, medium, high or critical to a result level
+func Level(severity string) string {
+	switch severity {
+	case "critical", "high":
+		return LevelError
+	case "medium":
+		return LevelWarning
+	default:
+		return LevelNote
+	}
+}
+
+// SecuritySeverity maps a severity to the score GitHub code scanning ranks
+// security results by
+func SecuritySeverity(severity string) string {
+	switch severity {
+	case "critical":
+		return "9.5"
+	case "high":
+		return "8.0"
+	case "medium":
+		return "5.5"
+	default:
+		return "2.0"
+	}
+}
+
+// FileLocation returns the location of a region of a file, with the symbol
+// it concerns when there is one. Backslashes in the path are taken as
+// separators.
+func FileLocation(path string, region Region, symbol string) Location {
+	location := Location{
+		PhysicalLocation: PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: strings.TrimPrefix(strings.ReplaceAll(path, `\`, "/"), "./")}},
+	}
+	if region != (Region{}) {
+		location.PhysicalLocation.Region = &region
+	}
+	if symbol != "" {
+		location.LogicalLocations = []LogicalLocation{{Name: symbol}}
+	}
+	return location
+}
+
+// Fingerprint identifies a result by what it concerns rather than where, so
+// the same problem is recognized after the lines around it change
+func Fingerprint(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return fmt.Sprintf("%x", sum[:8])
+}
@@ -0,0 +1,62 @@
+package sarif
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	run := NewRun("code-indexer", "1.0.0", "")
+	run.AddRule(Rule{ID: "long_function", ShortDescription: Message{Text: "Long function"}, DefaultConfiguration: Configuration{Level: LevelWarning}})
+	run.AddRule(Rule{ID: "private_key", ShortDescription: Message{Text: "Private key"}, DefaultConfiguration: Configuration{Level: LevelError}})
+	run.AddRule(Rule{ID: "long_function", ShortDescription: Message{Text: "Duplicate"}})
+
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %+v", run.Tool.Driver.Rules)
+	}
+	if err := run.AddResult(Result{RuleID: "private_key", Message: Message{Text: "Private key"}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if run.Results[0].RuleIndex != 1 || run.Results[0].Level != LevelError {
+		t.Errorf("Expected the rule index and default level, got %+v", run.Results[0])
+	}
+	if err := run.AddResult(Result{RuleID: "unknown"}); err == nil {
+		t.Error("Expected an error for a result of an unknown rule")
+	}
+}
+
+func TestLogJSON(t *testing.T) {
+	run := NewRun("code-indexer", "", "")
+	run.AddRule(Rule{ID: "jwt", ShortDescription: Message{Text: "JSON web token"}, DefaultConfiguration: Configuration{Level: Level("medium")}})
+	run.AddResult(Result{
+		RuleID:    "jwt",
+		Message:   Message{Text: "JSON web token"},
+		Locations: []Location{FileLocation(`./config\app.js`, Region{StartLine: 3, StartColumn: 7}, "")},
+	})
+
+	data, err := json.Marshal(NewLog(run))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`"$schema":"https://json.schemastore.org/sarif-2.1.0.json"`,
+		`"version":"2.1.0"`,
+		`"uri":"config/app.js"`,
+		`"region":{"startLine":3,"startColumn":7}`,
+		`"level":"warning"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %s in %s", want, data)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	if Fingerprint("a", "bc") == Fingerprint("ab", "c") {
+		t.Error("Expected fingerprints to keep their parts apart")
+	}
+	if Level("critical") != LevelError || Level("low") != LevelNote || SecuritySeverity("high") != "8.0" {
+		t.Error("Unexpected severity mapping")
+	}
+}
@@ -0,0 +1,194 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	regexptokenizer "github.com/blevesearch/bleve/v2/analysis/tokenizer/regexp"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Analyzers of the name and content fields and their identifier variants.
+// All split text into whole identifiers, so `os.Open(path)` is the tokens
+// os, Open and path. The code analyzer also indexes the words of each
+// identifier next to it, and only the case analyzer keeps the case.
+const (
+	codeAnalyzer = "code"      // Identifiers and their words, lowercased
+	wordAnalyzer = "code_word" // Whole identifiers, lowercased
+	caseAnalyzer = "code_case" // Whole identifiers as written
+)
+
+// identifierPattern matches the identifiers the word and case analyzers
+// split text into
+var identifierPattern = regexp.MustCompile(`[\p{L}\p{N}_$]+`)
+
+// codeTokenPattern matches the tokens the code analyzer splits text into:
+// identifiers, joined by single dashes so kebab-case names stay whole
+var codeTokenPattern = regexp.MustCompile(`[\p{L}\p{N}_$]+(?:-[\p{L}\p{N}_$]+)*`)
+
+func init() {
+	registry.RegisterAnalyzer(codeAnalyzer, func(_ map[string]interface{}, cache *registry.Cache) (analysis.Analyzer, error) {
+		stopFilter, err := cache.TokenFilterNamed(en.StopName)
+		if err != nil {
+			return nil, err
+		}
+		return &analysis.DefaultAnalyzer{
+			Tokenizer:    regexptokenizer.NewRegexpTokenizer(codeTokenPattern),
+			TokenFilters: []analysis.TokenFilter{identifierWordsFilter{}, lowercase.NewLowerCaseFilter(), stopFilter},
+		}, nil
+	})
+	registry.RegisterAnalyzer(wordAnalyzer, func(map[string]interface{}, *registry.Cache) (analysis.Analyzer, error) {
+		return &analysis.DefaultAnalyzer{
+			Tokenizer:    regexptokenizer.NewRegexpTokenizer(identifierPattern),
+			TokenFilters: []analysis.TokenFilter{lowercase.NewLowerCaseFilter()},
+		}, nil
+	})
+	registry.RegisterAnalyzer(caseAnalyzer, func(map[string]interface{}, *registry.Cache) (analysis.Analyzer, error) {
+		return &analysis.DefaultAnalyzer{
+			Tokenizer: regexptokenizer.NewRegexpTokenizer(identifierPattern),
+		}, nil
+	})
+}
+
+// identifierWordsFilter follows every identifier made of several words with
+// those words, so HTTPClient is also found as http and client, and
+// http_client and http-client as well. The words take the positions after
+// the identifier's, so phrase queries for them match the identifier.
+type identifierWordsFilter struct{}
+
+func (identifierWordsFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	output := make(analysis.TokenStream, 0, len(input))
+	position := 1
+	for _, token := range input {
+		token.Position = position
+		output = append(output, token)
+
+		words := identifierWords(token.Term)
+		if len(words) == 1 && words[0][1]-words[0][0] == len(token.Term) {
+			words = nil
+		}
+		for idx, word := range words {
+			output = append(output, &analysis.Token{
+				Term:     append([]byte(nil), token.Term[word[0]:word[1]]...),
+				Start:    token.Start + word[0],
+				End:      token.Start + word[1],
+				Position: position + idx,
+				Type:     token.Type,
+			})
+		}
+		position += max(len(words), 1)
+	}
+	return output
+}
+
+// identifierWords returns the byte ranges of the words of an identifier.
+// Words are separated by underscores, dashes and dollar signs, and start at
+// an upper case letter following a lower case letter or digit, or at the
+// last upper case letter of a run followed by a lower case one, so
+// HTTPClient is HTTP and Client. Digits stay in their word, as in utf8.
+func identifierWords(identifier []byte) [][2]int {
+	var words [][2]int
+	start := -1
+	var previous rune
+	for offset, current := range string(identifier) {
+		if current == '_' || current == '-' || current == '$' {
+			if start >= 0 {
+				words = append(words, [2]int{start, offset})
+				start = -1
+			}
+			previous = current
+			continue
+		}
+
+		if start >= 0 && unicode.IsUpper(current) {
+			next, _ := utf8.DecodeRune(identifier[offset+utf8.RuneLen(current):])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) ||
+				(unicode.IsUpper(previous) && unicode.IsLower(next)) {
+				words = append(words, [2]int{start, offset})
+				start = -1
+			}
+		}
+		if start < 0 {
+			start = offset
+		}
+		previous = current
+	}
+	if start >= 0 {
+		words = append(words, [2]int{start, len(identifier)})
+	}
+	return words
+}
+
+// Identifier variants of a text field are indexed under the field name with
+// these suffixes
+const (
+	wordFieldSuffix = "_word"
+	caseFieldSuffix = "_case"
+)
+
+// identifierField maps a searchable identifier variant of a text field.
+// Variants only decide which documents match: they are not stored, so
+// matches in them are not highlighted. Phrases need their term vectors.
+func identifierField(name, analyzer string) *mapping.FieldMapping {
+	fieldMapping := bleve.NewTextFieldMapping()
+	fieldMapping.Name = name
+	fieldMapping.Analyzer = analyzer
+	fieldMapping.Store = false
+	fieldMapping.IncludeInAll = false
+	fieldMapping.IncludeTermVectors = true
+	fieldMapping.DocValues = false
+	return fieldMapping
+}
+
+// exactTextQuery matches the query text in the identifier variants of the
+// name and content fields. With WholeWord every word of the query must be a
+// whole identifier, adjacent and in order; without it, identifiers only need
+// to contain each word. With CaseSensitive the words must match the case of
+// the identifiers, so `Open` no longer finds `open`.
+func exactTextQuery(searchQuery types.SearchQuery, ranking types.Ranking) query.Query {
+	suffix := wordFieldSuffix
+	text := searchQuery.Query
+	if searchQuery.CaseSensitive {
+		suffix = caseFieldSuffix
+	} else {
+		text = strings.ToLower(text)
+	}
+
+	words := identifierPattern.FindAllString(text, -1)
+	if len(words) == 0 {
+		return bleve.NewMatchNoneQuery()
+	}
+
+	fieldQuery := func(field string, boost float64) query.Query {
+		field += suffix
+		if searchQuery.WholeWord {
+			phraseQuery := bleve.NewPhraseQuery(words, field)
+			phraseQuery.SetBoost(boost)
+			return phraseQuery
+		}
+		wordQueries := make([]query.Query, 0, len(words))
+		for _, word := range words {
+			wildcardQuery := bleve.NewWildcardQuery("*" + word + "*")
+			wildcardQuery.SetField(field)
+			wordQueries = append(wordQueries, wildcardQuery)
+		}
+		conjunctionQuery := bleve.NewConjunctionQuery(wordQueries...)
+		conjunctionQuery.SetBoost(boost)
+		return conjunctionQuery
+	}
+
+	return bleve.NewDisjunctionQuery(
+		fieldQuery("name", boostOrOne(ranking.NameBoost)),
+		fieldQuery("content", boostOrOne(ranking.ContentBoost)),
+	)
+}
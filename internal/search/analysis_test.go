@@ -0,0 +1,122 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestCaseSensitiveAndWholeWordSearch(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	repo := &types.Repository{ID: "repo", Name: "repo"}
+	for name, content := range map[string]string{
+		"upper.go":  "f, err := os.Open(path)",
+		"lower.go":  "db.open()",
+		"prefix.go": "openFile(path)",
+	} {
+		file := &types.CodeFile{Path: name, RelativePath: name, Language: "go", Content: content, Lines: 1}
+		if err := engine.IndexFile(context.Background(), file, repo); err != nil {
+			t.Fatalf("Failed to index %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query types.SearchQuery
+		want  []string
+	}{
+		{"case sensitive", types.SearchQuery{Query: "Open", CaseSensitive: true}, []string{"upper.go"}},
+		{"case sensitive substring", types.SearchQuery{Query: "pen", CaseSensitive: true}, []string{"lower.go", "prefix.go", "upper.go"}},
+		{"whole word", types.SearchQuery{Query: "open", WholeWord: true}, []string{"lower.go", "upper.go"}},
+		{"whole word phrase", types.SearchQuery{Query: "os.Open", WholeWord: true}, []string{"upper.go"}},
+		{"whole word and case", types.SearchQuery{Query: "open", WholeWord: true, CaseSensitive: true}, []string{"lower.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Type = "file"
+			tt.query.MaxResults = 10
+			results, err := engine.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			var paths []string
+			for _, result := range results {
+				paths = append(paths, result.FilePath)
+			}
+			sort.Strings(paths)
+			if !slices.Equal(paths, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, paths)
+			}
+		})
+	}
+}
+
+func TestIdentifierWords(t *testing.T) {
+	tests := []struct {
+		identifier string
+		want       []string
+	}{
+		{"HTTPClient", []string{"HTTP", "Client"}},
+		{"http_client", []string{"http", "client"}},
+		{"http-client", []string{"http", "client"}},
+		{"parseJSONBody2", []string{"parse", "JSON", "Body2"}},
+		{"utf8Decoder", []string{"utf8", "Decoder"}},
+		{"_private", []string{"private"}},
+		{"plain", []string{"plain"}},
+	}
+	for _, tt := range tests {
+		var words []string
+		for _, word := range identifierWords([]byte(tt.identifier)) {
+			words = append(words, tt.identifier[word[0]:word[1]])
+		}
+		if !slices.Equal(words, tt.want) {
+			t.Errorf("Expected %s to split into %v, got %v", tt.identifier, tt.want, words)
+		}
+	}
+}
+
+func TestCodeAnalyzerFindsIdentifierWords(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	repo := &types.Repository{ID: "repo", Name: "repo"}
+	for name, content := range map[string]string{
+		"camel.go": "type HTTPClient struct{}",
+		"snake.py": "http_client = None",
+		"other.go": "type Server struct{}",
+	} {
+		file := &types.CodeFile{Path: name, RelativePath: name, Language: "go", Content: content, Lines: 1}
+		if err := engine.IndexFile(context.Background(), file, repo); err != nil {
+			t.Fatalf("Failed to index %s: %v", name, err)
+		}
+	}
+
+	for _, text := range []string{"http client", "HTTPClient"} {
+		results, err := engine.Search(context.Background(), types.SearchQuery{Query: text, Type: "file", MaxResults: 10})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		var paths []string
+		for _, result := range results {
+			paths = append(paths, result.FilePath)
+		}
+		sort.Strings(paths)
+		if !slices.Equal(paths, []string{"camel.go", "snake.py"}) {
+			t.Errorf("Expected %q to find both spellings, got %v", text, paths)
+		}
+	}
+}
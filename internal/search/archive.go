@@ -0,0 +1,109 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/blevesearch/bleve/v2"
+	"go.uber.org/zap"
+)
+
+// ErrCopyUnsupported is returned when copying the index of a repository
+// that is kept in memory or only in the shared index of an earlier version
+var ErrCopyUnsupported = errors.New("index does not support copying")
+
+// MappingVersion returns the mapping version new indexes are created with.
+// An index copied from an engine with another version does not match the
+// queries of this one.
+func (e *Engine) MappingVersion() string {
+	return mappingVersion(e.storage)
+}
+
+// CopyRepositoryIndex writes a consistent copy of the index of a repository
+// to dir, while it stays searchable and writable
+func (e *Engine) CopyRepositoryIndex(repositoryID, dir string) error {
+	e.indexesMutex.RLock()
+	index, ok := e.indexes[repositoryID]
+	e.indexesMutex.RUnlock()
+	if !ok || e.indexDir == "" {
+		return fmt.Errorf("%w: repository %s has no index of its own on disk; re-index it first", ErrCopyUnsupported, repositoryID)
+	}
+
+	copyable, ok := index.(bleve.IndexCopyable)
+	if !ok {
+		return fmt.Errorf("%w: repository %s", ErrCopyUnsupported, repositoryID)
+	}
+	if err := copyable.CopyTo(bleve.FileSystemDirectory(dir)); err != nil {
+		return fmt.Errorf("failed to copy index of repository %s: %w", repositoryID, err)
+	}
+	return nil
+}
+
+// ImportRepositoryIndex replaces the index of a repository with a copy of
+// the index at dir, which must have been created with the same mapping
+// version. The copy is made next to the indexes before the old index is
+// closed, so the repository is only briefly left without one.
+func (e *Engine) ImportRepositoryIndex(repositoryID, dir string) error {
+	if e.indexDir == "" {
+		return fmt.Errorf("%w: indexes kept in memory cannot be imported into", ErrCopyUnsupported)
+	}
+	if !validRepositoryID(repositoryID) {
+		return fmt.Errorf("invalid repository ID %q", repositoryID)
+	}
+
+	source, err := bleve.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open imported index of repository %s: %w", repositoryID, err)
+	}
+	defer source.Close()
+
+	version, err := storedMappingVersion(source)
+	if err != nil {
+		return err
+	}
+	if version != e.MappingVersion() {
+		return fmt.Errorf("imported index of repository %s has mapping version %q, this server uses %q", repositoryID, version, e.MappingVersion())
+	}
+	copyable, ok := source.(bleve.IndexCopyable)
+	if !ok {
+		return fmt.Errorf("%w: imported index of repository %s", ErrCopyUnsupported, repositoryID)
+	}
+
+	staging, err := os.MkdirTemp(e.indexDir, ".import-*")
+	if err != nil {
+		return fmt.Errorf("failed to create import directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+	if err := copyable.CopyTo(bleve.FileSystemDirectory(staging)); err != nil {
+		return fmt.Errorf("failed to copy imported index of repository %s: %w", repositoryID, err)
+	}
+
+	defer e.invalidateCache("")
+	e.indexesMutex.Lock()
+	defer e.indexesMutex.Unlock()
+
+	if previous, ok := e.indexes[repositoryID]; ok {
+		delete(e.indexes, repositoryID)
+		delete(e.refs, repositoryID)
+		delete(e.namespaces, repositoryID)
+		e.alias.Remove(previous)
+		if err := previous.Close(); err != nil {
+			e.logger.Warn("Failed to close repository index", zap.String("repo_id", repositoryID), zap.Error(err))
+		}
+	}
+	target := e.repositoryIndexDir(repositoryID)
+	if err := os.RemoveAll(target); err != nil {
+		return fmt.Errorf("failed to remove index of repository %s: %w", repositoryID, err)
+	}
+	if err := os.Rename(staging, target); err != nil {
+		return fmt.Errorf("failed to move imported index of repository %s into place: %w", repositoryID, err)
+	}
+	index, err := bleve.Open(target)
+	if err != nil {
+		return fmt.Errorf("failed to open imported index of repository %s: %w", repositoryID, err)
+	}
+	e.addIndex(repositoryID, index)
+	e.logger.Info("Imported repository index", zap.String("repo_id", repositoryID), zap.String("path", target))
+	return nil
+}
@@ -0,0 +1,162 @@
+package search
+
+import (
+	"container/list"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// resultCache keeps the pages of recent searches, least recently used
+// first out, so agents repeating a query are answered without searching
+// the index. Pages are dropped when a repository they may contain changes.
+type resultCache struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // Of *cacheEntry, most recently used at the front
+	stats    types.SearchCacheStats
+
+	// generation counts invalidations, so a page searched while its
+	// repositories changed is not cached
+	generation uint64
+	mutex      sync.Mutex
+}
+
+// cacheEntry is a cached page and the repositories it was searched in
+type cacheEntry struct {
+	key          string
+	repositories []string // Names; none when all repositories were searched
+	page         *types.SearchPage
+}
+
+// newResultCache returns a cache of up to capacity pages, or nil when the
+// capacity is zero
+func newResultCache(capacity int) *resultCache {
+	if capacity <= 0 {
+		return nil
+	}
+	return &resultCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// cacheKey identifies a query by its normalized form: the OR-ed filter
+// lists are sorted and deduplicated and the text is trimmed, so the same
+// search spelled differently shares one entry
+func cacheKey(query types.SearchQuery) string {
+	query.Query = strings.TrimSpace(query.Query)
+	query.Type, query.Types = "", sortedSet(query.TypeFilter())
+	query.Language, query.Languages = "", sortedSet(query.LanguageFilter())
+	query.Repository, query.Repositories = "", sortedSet(query.RepositoryFilter())
+	query.Project, query.Projects = "", sortedSet(query.ProjectFilter())
+	query.ExcludePaths = sortedSet(query.ExcludePaths)
+
+	key, _ := json.Marshal(struct {
+		types.SearchQuery
+		Ranking          types.Ranking
+		PopularityWeight float64
+	}{query, query.Ranking, query.PopularityWeight})
+	return string(key)
+}
+
+// sortedSet returns the distinct values sorted
+func sortedSet(values []string) []string {
+	values = slices.Clone(values)
+	slices.Sort(values)
+	return slices.Compact(values)
+}
+
+// get returns a copy of the cached page for a key. On a miss, it returns
+// the generation to pass to put with the page once it is searched.
+func (c *resultCache) get(key string) (*types.SearchPage, uint64, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, c.generation, false
+	}
+	c.stats.Hits++
+	c.order.MoveToFront(element)
+	return copyPage(element.Value.(*cacheEntry).page), c.generation, true
+}
+
+// put caches a copy of the page of a query, evicting the least recently
+// used page when the cache is full. The page is dropped when the cache was
+// invalidated since the generation get returned.
+func (c *resultCache) put(key string, generation uint64, query types.SearchQuery, page *types.SearchPage) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+	entry := &cacheEntry{key: key, repositories: query.RepositoryFilter(), page: copyPage(page)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.stats.Evictions++
+	}
+}
+
+// invalidate drops the pages that may contain documents of the named
+// repository: those searched in it and those searched in all
+// repositories. An empty name drops every page.
+func (c *resultCache) invalidate(repository string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	for key, element := range c.entries {
+		entry := element.Value.(*cacheEntry)
+		if repository != "" && len(entry.repositories) > 0 && !slices.Contains(entry.repositories, repository) {
+			continue
+		}
+		c.order.Remove(element)
+		delete(c.entries, key)
+		c.stats.Invalidations++
+	}
+}
+
+// snapshot returns the cache statistics
+func (c *resultCache) snapshot() *types.SearchCacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := c.stats
+	stats.Capacity = c.capacity
+	stats.Entries = c.order.Len()
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return &stats
+}
+
+// copyPage copies a page deeply enough that callers annotating its results
+// do not change the cached page
+func copyPage(page *types.SearchPage) *types.SearchPage {
+	copied := *page
+	copied.Results = slices.Clone(page.Results)
+	for idx := range copied.Results {
+		result := &copied.Results[idx]
+		result.Highlights = maps.Clone(result.Highlights)
+		result.Context = maps.Clone(result.Context)
+		result.FollowUps = slices.Clone(result.FollowUps)
+	}
+	return &copied
+}
@@ -0,0 +1,94 @@
+package search
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestSearchResultCache(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+	engine.SetCacheSize(2)
+
+	api := &types.Repository{ID: "api-id", Name: "api"}
+	web := &types.Repository{ID: "web-id", Name: "web"}
+	indexFile := func(repo *types.Repository, path string) {
+		t.Helper()
+		file := &types.CodeFile{Path: path, RelativePath: path, Language: "go", Lines: 1, Content: "retry handler"}
+		if err := engine.IndexFile(context.Background(), file, repo); err != nil {
+			t.Fatalf("Failed to index %s: %v", path, err)
+		}
+	}
+	search := func(query types.SearchQuery) int {
+		t.Helper()
+		query.Query, query.Type, query.MaxResults = "retry", "file", 10
+		results, err := engine.Search(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		return len(results)
+	}
+	expectStats := func(hits, misses int64) {
+		t.Helper()
+		stats := engine.cache.snapshot()
+		if stats.Hits != hits || stats.Misses != misses {
+			t.Errorf("Expected %d hits and %d misses, got %+v", hits, misses, stats)
+		}
+	}
+
+	indexFile(api, "api.go")
+	indexFile(web, "web.go")
+
+	apiOnly := types.SearchQuery{Repositories: []string{"api", "api"}}
+	if got := search(apiOnly); got != 1 {
+		t.Fatalf("Expected 1 result, got %d", got)
+	}
+	search(types.SearchQuery{Repository: "api"})
+	expectStats(1, 1)
+
+	// Results are copies, so annotating them leaves the cache unchanged
+	results, _ := engine.Search(context.Background(), types.SearchQuery{Query: "retry", Type: "file", Repository: "api", MaxResults: 10})
+	results[0].Name = "changed"
+	results, _ = engine.Search(context.Background(), types.SearchQuery{Query: "retry", Type: "file", Repository: "api", MaxResults: 10})
+	if results[0].Name != "api.go" {
+		t.Errorf("Expected the cached result unchanged, got %q", results[0].Name)
+	}
+	expectStats(3, 1)
+
+	// Writes to another repository keep the page, writes to api drop it
+	indexFile(web, "web2.go")
+	search(apiOnly)
+	expectStats(4, 1)
+	indexFile(api, "api2.go")
+	if got := search(apiOnly); got != 2 {
+		t.Errorf("Expected the new file to be found, got %d results", got)
+	}
+	expectStats(4, 2)
+
+	// Searches of all repositories are dropped by any write
+	if got := search(types.SearchQuery{}); got != 4 {
+		t.Errorf("Expected 4 results, got %d", got)
+	}
+	if err := engine.DeleteRepository(context.Background(), web.ID); err != nil {
+		t.Fatalf("Failed to delete repository: %v", err)
+	}
+	if got := search(types.SearchQuery{}); got != 2 {
+		t.Errorf("Expected the deleted repository's files to be gone, got %d results", got)
+	}
+	expectStats(4, 4)
+
+	// The least recently used page makes room
+	search(types.SearchQuery{Language: "go"})
+	stats := engine.cache.snapshot()
+	if stats.Entries != 2 || stats.Evictions != 1 {
+		t.Errorf("Expected one page evicted to keep 2, got %+v", stats)
+	}
+}
@@ -0,0 +1,75 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// ChunkDependencies returns the dependencies recorded for every chunk
+// document of a repository, ordered by file and line. Chunks indexed before
+// dependencies were recorded have none.
+func (e *Engine) ChunkDependencies(ctx context.Context, repositoryID string) ([]types.ChunkDependencies, error) {
+	typeQuery := bleve.NewTermQuery("chunk")
+	typeQuery.SetField("type")
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+
+	var chunks []types.ChunkDependencies
+	for from := 0; ; from += fileHashesPageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		searchRequest := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(typeQuery, repoQuery), fileHashesPageSize, from, false)
+		searchRequest.Fields = []string{"file_path", "name", "start_line", "end_line", "metadata.chunk_id", "metadata.chunk_type", "metadata.dependencies"}
+		searchRequest.SortBy([]string{"_id"})
+
+		searchResult, err := e.searchRepository(ctx, repositoryID, searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for chunk documents: %w", err)
+		}
+		for _, hit := range searchResult.Hits {
+			chunk := types.ChunkDependencies{}
+			chunk.FilePath, _ = hit.Fields["file_path"].(string)
+			chunk.Name, _ = hit.Fields["name"].(string)
+			chunk.ChunkID, _ = hit.Fields["metadata.chunk_id"].(string)
+			chunk.ChunkType, _ = hit.Fields["metadata.chunk_type"].(string)
+			if line, ok := hit.Fields["start_line"].(float64); ok {
+				chunk.StartLine = int(line)
+			}
+			if line, ok := hit.Fields["end_line"].(float64); ok {
+				chunk.EndLine = int(line)
+			}
+			// Bleve returns a single value for one-element arrays
+			switch dependencies := hit.Fields["metadata.dependencies"].(type) {
+			case string:
+				chunk.Dependencies = []string{dependencies}
+			case []interface{}:
+				for _, dependency := range dependencies {
+					if name, ok := dependency.(string); ok {
+						chunk.Dependencies = append(chunk.Dependencies, name)
+					}
+				}
+			}
+			if chunk.FilePath != "" && chunk.ChunkID != "" {
+				chunks = append(chunks, chunk)
+			}
+		}
+		if len(searchResult.Hits) < fileHashesPageSize {
+			break
+		}
+	}
+
+	sort.Slice(chunks, func(a, b int) bool {
+		if chunks[a].FilePath != chunks[b].FilePath {
+			return chunks[a].FilePath < chunks[b].FilePath
+		}
+		return chunks[a].StartLine < chunks[b].StartLine
+	})
+	return chunks, nil
+}
@@ -0,0 +1,55 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// FunctionComplexity returns the complexity recorded for every function
+// document of a repository. Functions indexed before complexity was
+// recorded, or in languages it is not measured for, have a complexity of 0.
+func (e *Engine) FunctionComplexity(ctx context.Context, repositoryID string) ([]types.FunctionComplexity, error) {
+	typeQuery := bleve.NewTermQuery("function")
+	typeQuery.SetField("type")
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+
+	var functions []types.FunctionComplexity
+	for from := 0; ; from += fileHashesPageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		searchRequest := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(typeQuery, repoQuery), fileHashesPageSize, from, false)
+		searchRequest.Fields = []string{"file_path", "language", "name", "start_line", "end_line", "metadata.class_name", "metadata.cyclomatic", "metadata.cognitive"}
+		searchRequest.SortBy([]string{"_id"})
+
+		searchResult, err := e.searchRepository(ctx, repositoryID, searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for function documents: %w", err)
+		}
+		for _, hit := range searchResult.Hits {
+			function := types.FunctionComplexity{}
+			function.FilePath, _ = hit.Fields["file_path"].(string)
+			function.Language, _ = hit.Fields["language"].(string)
+			function.Name, _ = hit.Fields["name"].(string)
+			function.ClassName, _ = hit.Fields["metadata.class_name"].(string)
+			startLine, _ := hit.Fields["start_line"].(float64)
+			endLine, _ := hit.Fields["end_line"].(float64)
+			cyclomatic, _ := hit.Fields["metadata.cyclomatic"].(float64)
+			cognitive, _ := hit.Fields["metadata.cognitive"].(float64)
+			function.StartLine, function.EndLine = int(startLine), int(endLine)
+			function.Cyclomatic, function.Cognitive = int(cyclomatic), int(cognitive)
+			if function.FilePath != "" {
+				functions = append(functions, function)
+			}
+		}
+		if len(searchResult.Hits) < fileHashesPageSize {
+			return functions, nil
+		}
+	}
+}
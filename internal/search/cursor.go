@@ -0,0 +1,44 @@
+package search
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cursorPrefix marks the payload of a cursor so that arbitrary strings are
+// rejected rather than read as offsets
+const cursorPrefix = "offset:"
+
+// EncodeCursor returns the opaque cursor that resumes a listing at an offset
+func EncodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+// DecodeCursor returns the offset a cursor resumes at. An empty cursor
+// starts at the beginning.
+func DecodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(payload), cursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(payload), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return offset, nil
+}
+
+// nextCursor returns the cursor of the page following one that started at
+// offset and held returned of total results, or "" after the last page
+func nextCursor(offset, returned, total int) string {
+	if returned == 0 || offset+returned >= total {
+		return ""
+	}
+	return EncodeCursor(offset + returned)
+}
@@ -2,9 +2,14 @@ package search
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"math"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
@@ -13,106 +18,407 @@ import (
 	"github.com/blevesearch/bleve/v2/search/query"
 	"go.uber.org/zap"
 
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
 	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
-// Engine provides search functionality using Bleve
+// Engine provides search functionality using Bleve. Every repository has
+// its own index, so a repository is deleted or rebuilt without touching the
+// others, and queries search all of them in parallel through an index alias.
 type Engine struct {
-	index  bleve.Index
-	logger *zap.Logger
+	indexDir      string // Empty for in-memory indexes
+	storage       config.StorageConfig
+	logger        *zap.Logger
+	fullContent   bool          // Whether file, chunk and symbol documents store their full content
+	contentLoader ContentLoader // Reads content that is not stored; nil leaves it empty
+	cache         *resultCache  // Pages of recent searches; nil when caching is off
+
+	// The index of each repository by repository ID, and the index shared by
+	// all repositories that earlier versions kept in indexDir, nil when there
+	// is none. alias searches all of them. The map is guarded by
+	// indexesMutex; legacy is only set while the engine is created. refs
+	// holds the ref each repository was indexed at, see SetRepositoryRef,
+	// and namespaces the namespace of each, see SetRepositoryNamespace.
+	indexes      map[string]bleve.Index
+	refs         map[string]string
+	namespaces   map[string]string
+	legacy       bleve.Index
+	alias        bleve.IndexAlias
+	indexesMutex sync.RWMutex
 }
 
+// ContentLoader reads a file of an indexed repository from disk, by its path
+// relative to the repository
+type ContentLoader func(repositoryID, filePath string) ([]byte, error)
+
+// storedSnippetLength is the length in characters of the snippet file,
+// chunk and symbol documents store instead of their content when full
+// content is off
+const storedSnippetLength = 200
+
+// contentTypes are the document types that store their content even when
+// full content is off. Their content is not read back from the lines of
+// the file: a reference holds one trimmed line, a secret its redacted
+// value, and comments, sections and configuration keys are small.
+var contentTypes = []string{"comment", "section", "config_key", "reference", "security_finding"}
+
 // Document represents a searchable document in the index
 type Document struct {
 	ID           string                 `json:"id"`
-	Type         string                 `json:"type"` // "file", "function", "class", "variable", "comment", "chunk"
+	Type         string                 `json:"type"` // "file", "function", "class", "interface", "type_alias", schema kinds such as "message" and "table", "variable", "comment", "section", "config_key", "chunk", "reference", "security_finding"
 	RepositoryID string                 `json:"repository_id"`
 	Repository   string                 `json:"repository"`
+	Project      string                 `json:"project,omitempty"` // Directory of the sub-project of the file, if any
 	FilePath     string                 `json:"file_path"`
 	Language     string                 `json:"language"`
 	Name         string                 `json:"name,omitempty"`
 	Content      string                 `json:"content"`
+	Snippet      string                 `json:"snippet,omitempty"` // Stored instead of content when full content is off
 	StartLine    int                    `json:"start_line"`
 	EndLine      int                    `json:"end_line"`
 	Metadata     map[string]interface{} `json:"metadata,omitempty"`
 	IndexedAt    time.Time              `json:"indexed_at"`
+	ModifiedAt   *time.Time             `json:"modified_at,omitempty"`     // Of the file, when the indexer recorded it
+	References   int                    `json:"reference_count,omitempty"` // Symbol documents only
+	Trigrams     []string               `json:"trigrams,omitempty"`        // File documents only, see fileTrigrams
+	Hash         string                 `json:"hash,omitempty"`            // File documents only, SHA-256 of the content indexed
+}
+
+// BleveType selects the document mapping for a document, so storage
+// settings can differ per document type
+func (d Document) BleveType() string {
+	return d.Type
 }
 
-// NewEngine creates a new search engine
+// NewEngine creates a new search engine with the default storage settings
 func NewEngine(indexDir string, logger *zap.Logger) (*Engine, error) {
-	// Create index mapping
-	indexMapping := createIndexMapping()
+	return NewEngineWithStorage(indexDir, config.DefaultConfig().SearchStorage(), logger)
+}
 
-	// Open or create the index
-	index, err := bleve.Open(indexDir)
-	if err != nil {
-		// If index doesn't exist or has issues, create a new one
-		logger.Info("Index not found or corrupted, creating new index", zap.String("path", indexDir), zap.Error(err))
-		index, err = bleve.New(indexDir, indexMapping)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create search index: %w", err)
-		}
-		logger.Info("Created new search index", zap.String("path", indexDir))
-	} else {
-		logger.Info("Opened existing search index", zap.String("path", indexDir))
-	}
+// NewEngineWithStorage creates a new search engine keeping its indexes
+// below indexDir, one per repository in the "repositories" directory. Each
+// index stores fields according to the given storage settings, which are
+// baked into it when it is created together with the version of the
+// mapping. An existing index created by an older schema or with other
+// settings, or before versions were recorded, keeps its mapping unless
+// RebuildOnChange is set, in which case it is removed and its repository
+// must be indexed again. A single index shared by all repositories, as
+// earlier versions kept directly in indexDir, is still searched until its
+// repositories are re-indexed or removed.
+func NewEngineWithStorage(indexDir string, storage config.StorageConfig, logger *zap.Logger) (*Engine, error) {
+	engine := newEngine(indexDir, storage, logger)
+	if err := engine.openIndexes(); err != nil {
+		engine.Close()
+		return nil, err
+	}
+	return engine, nil
+}
 
+// NewMemoryEngine creates a search engine whose indexes live only in memory
+// and are discarded when the engine is closed
+func NewMemoryEngine(storage config.StorageConfig, logger *zap.Logger) (*Engine, error) {
+	logger.Info("Created in-memory search index")
+	return newEngine("", storage, logger), nil
+}
+
+// newEngine returns an engine without indexes
+func newEngine(indexDir string, storage config.StorageConfig, logger *zap.Logger) *Engine {
 	return &Engine{
-		index:  index,
-		logger: logger,
-	}, nil
+		indexDir:    indexDir,
+		storage:     storage,
+		logger:      logger,
+		fullContent: storage.StoreFullContent,
+		indexes:     make(map[string]bleve.Index),
+		refs:        make(map[string]string),
+		namespaces:  make(map[string]string),
+		alias:       bleve.NewIndexAlias(),
+	}
 }
 
-// createIndexMapping creates the Bleve index mapping
-func createIndexMapping() mapping.IndexMapping {
+// createIndexMapping creates the Bleve index mapping for the given storage
+// settings
+func createIndexMapping(storage config.StorageConfig) mapping.IndexMapping {
 	// Create a mapping
 	indexMapping := bleve.NewIndexMapping()
 
-	// Create document mapping
-	docMapping := bleve.NewDocumentMapping()
+	// Set default mapping. Without full content, file, chunk and symbol
+	// documents store a snippet and their content is read from disk when
+	// results are returned; symbols come in many kinds, so every type not
+	// listed in contentTypes is covered by the default mapping.
+	indexMapping.DefaultMapping = createDocumentMapping(storage, storage.StoreFullContent)
+	if !storage.StoreFullContent {
+		for _, docType := range contentTypes {
+			if !slices.Contains(storage.SkipContentTypes, docType) {
+				indexMapping.AddDocumentMapping(docType, createDocumentMapping(storage, true))
+			}
+		}
+	}
+
+	// Document types whose content is searchable but not stored
+	for _, docType := range storage.SkipContentTypes {
+		indexMapping.AddDocumentMapping(docType, createDocumentMapping(storage, false))
+	}
+
+	return indexMapping
+}
+
+// createDocumentMapping creates the field mappings shared by all document
+// types. Every field gets its own mapping so storage settings can differ
+// per field.
+func createDocumentMapping(storage config.StorageConfig, storeContent bool) *mapping.DocumentMapping {
+	docValueOnly := make(map[string]bool, len(storage.DocValueOnlyFields))
+	for _, field := range storage.DocValueOnlyFields {
+		docValueOnly[field] = true
+	}
+
+	configure := func(name string, fieldMapping *mapping.FieldMapping) *mapping.FieldMapping {
+		fieldMapping.Store = true
+		fieldMapping.Index = true
+		fieldMapping.DocValues = storage.DocValues
+		if docValueOnly[name] {
+			fieldMapping.Store = false
+			fieldMapping.DocValues = true
+		}
+		return fieldMapping
+	}
 
 	// Text fields with analysis
-	textFieldMapping := bleve.NewTextFieldMapping()
-	textFieldMapping.Store = true
-	textFieldMapping.Index = true
-	textFieldMapping.IncludeTermVectors = true
+	textField := func(name string) *mapping.FieldMapping {
+		fieldMapping := configure(name, bleve.NewTextFieldMapping())
+		fieldMapping.IncludeTermVectors = storage.TermVectors
+		return fieldMapping
+	}
+
+	// Text fields holding code, whose identifiers are also found by their
+	// words
+	codeField := func(name string) *mapping.FieldMapping {
+		fieldMapping := textField(name)
+		fieldMapping.Analyzer = codeAnalyzer
+		return fieldMapping
+	}
 
 	// Keyword fields (exact match)
-	keywordFieldMapping := bleve.NewKeywordFieldMapping()
-	keywordFieldMapping.Store = true
-	keywordFieldMapping.Index = true
+	keywordField := func(name string) *mapping.FieldMapping {
+		return configure(name, bleve.NewKeywordFieldMapping())
+	}
 
 	// Numeric fields
-	numericFieldMapping := bleve.NewNumericFieldMapping()
-	numericFieldMapping.Store = true
-	numericFieldMapping.Index = true
+	numericField := func(name string) *mapping.FieldMapping {
+		return configure(name, bleve.NewNumericFieldMapping())
+	}
 
 	// Date fields
-	dateFieldMapping := bleve.NewDateTimeFieldMapping()
-	dateFieldMapping.Store = true
-	dateFieldMapping.Index = true
+	dateField := func(name string) *mapping.FieldMapping {
+		return configure(name, bleve.NewDateTimeFieldMapping())
+	}
+
+	contentField := codeField("content")
+	contentField.Store = storeContent
+
+	// Trigrams only narrow down regex candidates and are never returned
+	trigramField := keywordField("trigrams")
+	trigramField.Store = false
+	trigramField.DocValues = false
 
 	// Map fields
-	docMapping.AddFieldMappingsAt("type", keywordFieldMapping)
-	docMapping.AddFieldMappingsAt("repository_id", keywordFieldMapping)
-	docMapping.AddFieldMappingsAt("repository", keywordFieldMapping)
-	docMapping.AddFieldMappingsAt("file_path", textFieldMapping)
-	docMapping.AddFieldMappingsAt("language", keywordFieldMapping)
-	docMapping.AddFieldMappingsAt("name", textFieldMapping)
-	docMapping.AddFieldMappingsAt("content", textFieldMapping)
-	docMapping.AddFieldMappingsAt("start_line", numericFieldMapping)
-	docMapping.AddFieldMappingsAt("end_line", numericFieldMapping)
-	docMapping.AddFieldMappingsAt("indexed_at", dateFieldMapping)
-
-	// Set default mapping
-	indexMapping.DefaultMapping = docMapping
+	docMapping := bleve.NewDocumentMapping()
+	docMapping.AddFieldMappingsAt("type", keywordField("type"))
+	docMapping.AddFieldMappingsAt("repository_id", keywordField("repository_id"))
+	docMapping.AddFieldMappingsAt("repository", keywordField("repository"))
+	docMapping.AddFieldMappingsAt("project", keywordField("project"))
+	docMapping.AddFieldMappingsAt("file_path", textField("file_path"), pathKeywordMapping())
+	docMapping.AddFieldMappingsAt("language", keywordField("language"))
+	docMapping.AddFieldMappingsAt("name", codeField("name"),
+		identifierField("name"+wordFieldSuffix, wordAnalyzer), identifierField("name"+caseFieldSuffix, caseAnalyzer))
+	docMapping.AddFieldMappingsAt("content", contentField,
+		identifierField("content"+wordFieldSuffix, wordAnalyzer), identifierField("content"+caseFieldSuffix, caseAnalyzer))
+	docMapping.AddFieldMappingsAt("start_line", numericField("start_line"))
+	docMapping.AddFieldMappingsAt("end_line", numericField("end_line"))
+	docMapping.AddFieldMappingsAt("indexed_at", dateField("indexed_at"))
+	docMapping.AddFieldMappingsAt("modified_at", dateField("modified_at"))
+	docMapping.AddFieldMappingsAt("reference_count", numericField("reference_count"))
+	docMapping.AddFieldMappingsAt("trigrams", trigramField)
+	docMapping.AddFieldMappingsAt("hash", keywordField("hash"))
+
+	// The snippet is only returned, never searched
+	if !storage.StoreFullContent {
+		snippetField := bleve.NewTextFieldMapping()
+		snippetField.Index = false
+		snippetField.Store = true
+		snippetField.IncludeInAll = false
+		snippetField.IncludeTermVectors = false
+		docMapping.AddFieldMappingsAt("snippet", snippetField)
+	}
+
+	return docMapping
+}
 
-	return indexMapping
+// mappingSchemaVersion is bumped whenever createDocumentMapping changes the
+// fields it maps, so indexes created by older versions are detected
+const mappingSchemaVersion = 7
+
+// mappingVersionKey is the internal key the mapping version of an index is
+// stored under
+var mappingVersionKey = []byte("mapping_version")
+
+// mappingVersion identifies the mapping createIndexMapping builds for the
+// given storage settings: the schema version and a digest of the settings
+// that shape the mapping. It is stored in the index when the index is
+// created and compared when it is opened again.
+func mappingVersion(storage config.StorageConfig) string {
+	skipContent := append([]string(nil), storage.SkipContentTypes...)
+	sort.Strings(skipContent)
+	docValueOnly := append([]string(nil), storage.DocValueOnlyFields...)
+	sort.Strings(docValueOnly)
+
+	digest := sha256.New()
+	fmt.Fprintf(digest, "skip_content_types=%s\n", strings.Join(skipContent, ","))
+	fmt.Fprintf(digest, "doc_value_only_fields=%s\n", strings.Join(docValueOnly, ","))
+	fmt.Fprintf(digest, "term_vectors=%t\n", storage.TermVectors)
+	fmt.Fprintf(digest, "doc_values=%t\n", storage.DocValues)
+	// Only recorded when off, so indexes created before the setting existed
+	// keep their version
+	if !storage.StoreFullContent {
+		fmt.Fprintf(digest, "store_full_content=%t\n", storage.StoreFullContent)
+	}
+	return fmt.Sprintf("%d:%x", mappingSchemaVersion, digest.Sum(nil)[:8])
+}
+
+// storedMappingVersion returns the mapping version recorded in an index, or
+// "" for indexes created before versions were recorded
+func storedMappingVersion(index bleve.Index) (string, error) {
+	version, err := index.GetInternal(mappingVersionKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to read mapping version: %w", err)
+	}
+	return string(version), nil
+}
+
+// newIndex creates an index at indexDir, in memory when it is empty, and
+// records the version of its mapping
+func newIndex(indexDir string, storage config.StorageConfig) (bleve.Index, error) {
+	var index bleve.Index
+	var err error
+	if indexDir == "" {
+		index, err = bleve.NewMemOnly(createIndexMapping(storage))
+	} else {
+		index, err = bleve.New(indexDir, createIndexMapping(storage))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := index.SetInternal(mappingVersionKey, []byte(mappingVersion(storage))); err != nil {
+		index.Close()
+		return nil, fmt.Errorf("failed to record mapping version: %w", err)
+	}
+	return index, nil
 }
 
 // IndexFile indexes a code file and all its components
 func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *types.Repository) error {
-	batch := e.index.NewBatch()
+	batch := e.NewBatch()
+	batch.Add(file, repo)
+	return batch.Flush()
+}
+
+// Batch collects the documents of several files so they are written to the
+// index of their repository together. It is not safe for concurrent use.
+type Batch struct {
+	engine      *Engine
+	batches     map[string]*bleve.Batch // By repository ID
+	names       map[string]string       // Repository names by ID, whose cached searches a flush drops
+	err         error                   // First failure to get a repository index, returned by Flush
+	files       int
+	fullContent bool
+}
+
+// NewBatch returns an empty batch writing to the engine's indexes
+func (e *Engine) NewBatch() *Batch {
+	return &Batch{engine: e, batches: make(map[string]*bleve.Batch), names: make(map[string]string), fullContent: e.fullContent}
+}
+
+// Files returns the number of files added since the last flush
+func (b *Batch) Files() int {
+	return b.files
+}
+
+// Size returns the approximate size in bytes of the documents added since
+// the last flush
+func (b *Batch) Size() uint64 {
+	var size uint64
+	for _, batch := range b.batches {
+		size += batch.TotalDocsSize()
+	}
+	return size
+}
+
+// Flush writes the documents added so far to the indexes of their
+// repositories and empties the batch
+func (b *Batch) Flush() error {
+	if b.files == 0 && b.err == nil {
+		return nil
+	}
+	err := b.err
+	for repositoryID, batch := range b.batches {
+		index, indexErr := b.engine.repositoryIndex(repositoryID)
+		if indexErr == nil {
+			indexErr = index.Batch(batch)
+		}
+		if indexErr != nil && err == nil {
+			err = indexErr
+		}
+		b.engine.invalidateCache(b.names[repositoryID])
+	}
+	b.batches = make(map[string]*bleve.Batch)
+	b.names = make(map[string]string)
+	b.err = nil
+	b.files = 0
+	return err
+}
+
+// repositoryBatch returns the batch collecting documents for the index of a
+// repository, creating the index if needed
+func (b *Batch) repositoryBatch(repositoryID string) (*bleve.Batch, error) {
+	if batch, ok := b.batches[repositoryID]; ok {
+		return batch, nil
+	}
+	index, err := b.engine.repositoryIndex(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	batch := index.NewBatch()
+	b.batches[repositoryID] = batch
+	return batch, nil
+}
+
+// Add adds the documents of a file: the file itself, its symbols,
+// comments, chunks and references
+func (b *Batch) Add(file *types.CodeFile, repo *types.Repository) {
+	batch, err := b.repositoryBatch(repo.ID)
+	if err != nil {
+		if b.err == nil {
+			b.err = err
+		}
+		return
+	}
+	b.names[repo.ID] = repo.Name
+	b.files++
+
+	// Every document carries the modification time of its file, which the
+	// recency boost ranks by, and the sub-project of the file
+	var modifiedAt *time.Time
+	if !file.ModifiedAt.IsZero() {
+		modifiedAt = &file.ModifiedAt
+	}
+	index := func(doc Document) {
+		doc.ModifiedAt = modifiedAt
+		doc.Project = file.Project
+		if !b.fullContent && !slices.Contains(contentTypes, doc.Type) {
+			doc.Snippet = storedSnippet(doc.Content)
+		}
+		batch.Index(doc.ID, doc)
+	}
 
 	// Index the file itself
 	fileDoc := Document{
@@ -127,8 +433,13 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 		StartLine:    1,
 		EndLine:      file.Lines,
 		IndexedAt:    time.Now(),
+		Trigrams:     fileTrigrams(file.Content),
+		Hash:         file.Hash,
 	}
-	batch.Index(fileDoc.ID, fileDoc)
+	if metadata := fileImportsMetadata(file); len(metadata) > 0 {
+		fileDoc.Metadata = metadata
+	}
+	index(fileDoc)
 
 	// Index functions
 	for _, function := range file.Functions {
@@ -143,6 +454,7 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 			Content:      function.Signature,
 			StartLine:    function.StartLine,
 			EndLine:      function.EndLine,
+			References:   function.ReferenceCount,
 			Metadata: map[string]interface{}{
 				"parameters":   function.Parameters,
 				"return_type":  function.ReturnType,
@@ -154,7 +466,11 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 			},
 			IndexedAt: time.Now(),
 		}
-		batch.Index(funcDoc.ID, funcDoc)
+		if function.Cyclomatic > 0 {
+			funcDoc.Metadata["cyclomatic"] = function.Cyclomatic
+			funcDoc.Metadata["cognitive"] = function.Cognitive
+		}
+		index(funcDoc)
 	}
 
 	// Index classes
@@ -170,6 +486,7 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 			Content:      class.Name,
 			StartLine:    class.StartLine,
 			EndLine:      class.EndLine,
+			References:   class.ReferenceCount,
 			Metadata: map[string]interface{}{
 				"visibility":   class.Visibility,
 				"super_class":  class.SuperClass,
@@ -179,7 +496,33 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 			},
 			IndexedAt: time.Now(),
 		}
-		batch.Index(classDoc.ID, classDoc)
+		index(classDoc)
+	}
+
+	// Index interfaces, type aliases and schema definitions under their kind
+	for _, declaration := range file.TypeDeclarations {
+		typeDoc := Document{
+			ID:           fmt.Sprintf("%s:%s:%s:%s:%d", declaration.Kind, repo.ID, file.RelativePath, declaration.Name, declaration.StartLine),
+			Type:         declaration.Kind,
+			RepositoryID: repo.ID,
+			Repository:   repo.Name,
+			FilePath:     file.RelativePath,
+			Language:     file.Language,
+			Name:         declaration.Name,
+			Content:      declaration.Definition,
+			StartLine:    declaration.StartLine,
+			EndLine:      declaration.EndLine,
+			References:   declaration.ReferenceCount,
+			Metadata: map[string]interface{}{
+				"type_parameters": declaration.TypeParameters,
+				"extends":         declaration.Extends,
+				"members":         declaration.Members,
+				"parent":          declaration.Parent,
+				"is_exported":     declaration.IsExported,
+			},
+			IndexedAt: time.Now(),
+		}
+		index(typeDoc)
 	}
 
 	// Index variables
@@ -195,6 +538,7 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 			Content:      fmt.Sprintf("%s %s", variable.Name, variable.Type),
 			StartLine:    variable.StartLine,
 			EndLine:      variable.EndLine,
+			References:   variable.ReferenceCount,
 			Metadata: map[string]interface{}{
 				"type":        variable.Type,
 				"value":       variable.Value,
@@ -205,7 +549,7 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 			},
 			IndexedAt: time.Now(),
 		}
-		batch.Index(varDoc.ID, varDoc)
+		index(varDoc)
 	}
 
 	// Index comments
@@ -225,7 +569,56 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 			},
 			IndexedAt: time.Now(),
 		}
-		batch.Index(commentDoc.ID, commentDoc)
+		index(commentDoc)
+	}
+
+	// Index Markdown sections under their heading
+	for i, section := range file.Sections {
+		sectionDoc := Document{
+			ID:           fmt.Sprintf("section:%s:%s:%d:%d", repo.ID, file.RelativePath, section.StartLine, i),
+			Type:         "section",
+			RepositoryID: repo.ID,
+			Repository:   repo.Name,
+			FilePath:     file.RelativePath,
+			Language:     file.Language,
+			Name:         section.Title,
+			Content:      section.Content,
+			StartLine:    section.StartLine,
+			EndLine:      section.EndLine,
+			Metadata: map[string]interface{}{
+				"kind":     section.Kind,
+				"level":    section.Level,
+				"parents":  section.Parents,
+				"language": section.Language,
+			},
+			IndexedAt: time.Now(),
+		}
+		index(sectionDoc)
+	}
+
+	// Index configuration keys under their path, with the value as content
+	// so both the key and the value are searchable
+	for _, key := range file.ConfigKeys {
+		keyDoc := Document{
+			ID:           fmt.Sprintf("config_key:%s:%s:%d:%s", repo.ID, file.RelativePath, key.Document, key.Path),
+			Type:         "config_key",
+			RepositoryID: repo.ID,
+			Repository:   repo.Name,
+			FilePath:     file.RelativePath,
+			Language:     file.Language,
+			Name:         key.Path,
+			Content:      strings.TrimSpace(key.Path + ": " + key.Value),
+			StartLine:    key.StartLine,
+			EndLine:      key.EndLine,
+			Metadata: map[string]interface{}{
+				"key":        key.Key,
+				"value":      key.Value,
+				"value_type": key.ValueType,
+				"document":   key.Document,
+			},
+			IndexedAt: time.Now(),
+		}
+		index(keyDoc)
 	}
 
 	// Index chunks
@@ -249,24 +642,155 @@ func (e *Engine) IndexFile(ctx context.Context, file *types.CodeFile, repo *type
 			},
 			IndexedAt: time.Now(),
 		}
-		batch.Index(chunkDoc.ID, chunkDoc)
+		index(chunkDoc)
+	}
+
+	// Index references, with the line each one is on as content
+	if len(file.References) > 0 {
+		lines := textpos.SplitLines(file.Content)
+		for _, reference := range file.References {
+			refDoc := Document{
+				ID:           fmt.Sprintf("reference:%s:%s:%d:%d", repo.ID, file.RelativePath, reference.Line, reference.Column),
+				Type:         "reference",
+				RepositoryID: repo.ID,
+				Repository:   repo.Name,
+				FilePath:     file.RelativePath,
+				Language:     file.Language,
+				Name:         reference.Name,
+				StartLine:    reference.Line,
+				EndLine:      reference.Line,
+				Metadata: map[string]interface{}{
+					"kind":      reference.Kind,
+					"qualifier": reference.Qualifier,
+					"caller":    reference.Caller,
+					"target":    reference.Target,
+					"column":    reference.Column,
+				},
+				IndexedAt: time.Now(),
+			}
+			if reference.Line > 0 && reference.Line <= len(lines) {
+				refDoc.Content = strings.TrimSpace(lines[reference.Line-1])
+			}
+			index(refDoc)
+		}
 	}
 
-	// Execute the batch
-	return e.index.Batch(batch)
+	// Index hardcoded secrets, describing each by its redacted value only
+	for _, finding := range file.SecurityFindings {
+		findingDoc := Document{
+			ID:           fmt.Sprintf("security_finding:%s:%s:%d:%s:%d", repo.ID, file.RelativePath, finding.Line, finding.Rule, finding.Column),
+			Type:         "security_finding",
+			RepositoryID: repo.ID,
+			Repository:   repo.Name,
+			FilePath:     file.RelativePath,
+			Language:     file.Language,
+			Name:         finding.Rule,
+			Content:      finding.Description + " " + finding.Redacted,
+			StartLine:    finding.Line,
+			EndLine:      finding.Line,
+			Metadata: map[string]interface{}{
+				"rule":        finding.Rule,
+				"description": finding.Description,
+				"severity":    finding.Severity,
+				"column":      finding.Column,
+				"end_column":  finding.EndColumn,
+				"redacted":    finding.Redacted,
+				"fingerprint": finding.Fingerprint,
+			},
+			IndexedAt: time.Now(),
+		}
+		index(findingDoc)
+	}
 }
 
 // Search performs a search query and returns results
 func (e *Engine) Search(ctx context.Context, query types.SearchQuery) ([]types.SearchResult, error) {
+	page, err := e.SearchPage(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+// SearchPage performs a search query and returns the page of MaxResults
+// results starting at Offset. Ties in score are ordered by document ID so
+// pages do not overlap. The scoring profile's type and recency boosts and
+// the PopularityWeight re-rank the best matches before the page is cut.
+// Pages are served from the result cache when the same query ran since its
+// repositories last changed.
+func (e *Engine) SearchPage(ctx context.Context, query types.SearchQuery) (*types.SearchPage, error) {
+	var key string
+	var generation uint64
+	if e.cache != nil {
+		var page *types.SearchPage
+		var cached bool
+		key = cacheKey(query)
+		if page, generation, cached = e.cache.get(key); cached {
+			e.logger.Debug("Search served from cache", zap.String("query", query.Query), zap.Int("offset", query.Offset))
+			return page, nil
+		}
+	}
+
 	// Build the search query
 	searchQuery := e.buildSearchQuery(query)
 
+	size := query.MaxResults
+	if size <= 0 {
+		size = 100
+	}
+
+	order := []string{"-_score", "_id"}
+	if query.SortBy == "complexity" {
+		order = []string{"-metadata.cyclomatic", "-_score", "_id"}
+	}
+
+	var page *types.SearchPage
+	ranking, popularityWeight := profileRanking(query)
+	if reranks(ranking, popularityWeight) && query.Offset < rankingCandidates && query.SortBy != "complexity" {
+		var err error
+		boost := hitBoost(ranking, popularityWeight, time.Now())
+		if page, err = e.rankedPage(ctx, searchQuery, query.Offset, size, boost); err != nil {
+			return nil, err
+		}
+	} else {
+		results, searchResult, err := e.fetchResults(ctx, searchQuery, query.Offset, size, order...)
+		if err != nil {
+			return nil, err
+		}
+		page = &types.SearchPage{
+			Results:    results,
+			Total:      int(searchResult.Total),
+			NextCursor: nextCursor(query.Offset, len(searchResult.Hits), int(searchResult.Total)),
+		}
+	}
+
+	e.logger.Info("Search completed",
+		zap.String("query", query.Query),
+		zap.Strings("types", query.TypeFilter()),
+		zap.String("profile", query.Profile),
+		zap.Int("total_hits", page.Total),
+		zap.Int("offset", query.Offset),
+		zap.Int("returned", len(page.Results)))
+
+	if e.cache != nil {
+		e.cache.put(key, generation, query, page)
+	}
+	return page, nil
+}
+
+// fetchResults runs a query and converts the size hits starting at from,
+// ordered by score and then document ID unless another order is given,
+// with highlights
+func (e *Engine) fetchResults(ctx context.Context, searchQuery query.Query, from, size int, order ...string) ([]types.SearchResult, *bleve.SearchResult, error) {
+	if len(order) == 0 {
+		order = []string{"-_score", "_id"}
+	}
+
 	// Create search request
 	searchRequest := bleve.NewSearchRequest(searchQuery)
-	searchRequest.Size = query.MaxResults
-	if searchRequest.Size <= 0 {
-		searchRequest.Size = 100
-	}
+	searchRequest.Size = size
+	searchRequest.From = from
+	searchRequest.SortBy(order)
 
 	// Add highlighting
 	searchRequest.Highlight = bleve.NewHighlight()
@@ -277,9 +801,9 @@ func (e *Engine) Search(ctx context.Context, query types.SearchQuery) ([]types.S
 	searchRequest.Fields = []string{"*"}
 
 	// Execute search
-	searchResult, err := e.index.Search(searchRequest)
+	searchResult, err := e.search(ctx, searchRequest)
 	if err != nil {
-		return nil, fmt.Errorf("search failed: %w", err)
+		return nil, nil, fmt.Errorf("search failed: %w", err)
 	}
 
 	// Convert results
@@ -292,14 +816,8 @@ func (e *Engine) Search(ctx context.Context, query types.SearchQuery) ([]types.S
 		}
 		results = append(results, result)
 	}
-
-	e.logger.Info("Search completed",
-		zap.String("query", query.Query),
-		zap.String("type", query.Type),
-		zap.Int("total_hits", int(searchResult.Total)),
-		zap.Int("returned", len(results)))
-
-	return results, nil
+	e.loadContent(results)
+	return results, searchResult, nil
 }
 
 // buildSearchQuery builds a Bleve query from the search parameters
@@ -307,69 +825,230 @@ func (e *Engine) buildSearchQuery(searchQuery types.SearchQuery) query.Query {
 	var queries []query.Query
 
 	// Main content query
+	requestedTypes := searchQuery.TypeFilter()
+	var excluded []query.Query
 	if searchQuery.Query != "" {
-		if searchQuery.Fuzzy {
-			// Fuzzy search
-			fuzzyQuery := bleve.NewFuzzyQuery(searchQuery.Query)
-			queries = append(queries, fuzzyQuery)
+		ranking, _ := profileRanking(searchQuery)
+		if searchQuery.Syntax {
+			must, mustNot, syntaxTypes := e.syntaxQueries(searchQuery, ranking)
+			queries = append(queries, must...)
+			excluded = mustNot
+			requestedTypes = append(requestedTypes, syntaxTypes...)
 		} else {
-			// Regular text search across multiple fields
-			contentMatchQuery := bleve.NewMatchQuery(searchQuery.Query)
-			contentMatchQuery.SetField("content")
-
-			nameMatchQuery := bleve.NewMatchQuery(searchQuery.Query)
-			nameMatchQuery.SetField("name")
-
-			pathMatchQuery := bleve.NewMatchQuery(searchQuery.Query)
-			pathMatchQuery.SetField("file_path")
-
-			contentQuery := bleve.NewDisjunctionQuery(
-				contentMatchQuery,
-				nameMatchQuery,
-				pathMatchQuery,
-			)
-			queries = append(queries, contentQuery)
+			queries = append(queries, mainTextQuery(searchQuery, ranking))
 		}
 	}
 
 	// Type filter
-	if searchQuery.Type != "" {
-		typeQuery := bleve.NewTermQuery(searchQuery.Type)
-		typeQuery.SetField("type")
-		queries = append(queries, typeQuery)
+	if docTypes := searchQuery.TypeFilter(); len(docTypes) > 0 {
+		queries = append(queries, anyTermQuery("type", docTypes))
 	}
 
 	// Language filter
-	if searchQuery.Language != "" {
-		langQuery := bleve.NewTermQuery(searchQuery.Language)
-		langQuery.SetField("language")
-		queries = append(queries, langQuery)
+	if languages := searchQuery.LanguageFilter(); len(languages) > 0 {
+		queries = append(queries, anyTermQuery("language", languages))
 	}
 
 	// Repository filter
-	if searchQuery.Repository != "" {
-		repoQuery := bleve.NewTermQuery(searchQuery.Repository)
-		repoQuery.SetField("repository")
-		queries = append(queries, repoQuery)
+	if repositories := searchQuery.RepositoryFilter(); len(repositories) > 0 {
+		queries = append(queries, anyTermQuery("repository", repositories))
+	}
+
+	// Sub-project filter
+	if projects := searchQuery.ProjectFilter(); len(projects) > 0 {
+		queries = append(queries, anyTermQuery("project", projects))
 	}
 
-	// File path filter
+	// Ref filter, on the repositories indexed at the ref
+	if searchQuery.Ref != "" {
+		if repositoryIDs := e.repositoriesAt(searchQuery.Ref); len(repositoryIDs) > 0 {
+			queries = append(queries, anyTermQuery("repository_id", repositoryIDs))
+		} else {
+			queries = append(queries, bleve.NewMatchNoneQuery())
+		}
+	}
+
+	// Dependency sources are left out unless asked for or named
+	if !searchQuery.IncludeDeps && len(searchQuery.RepositoryFilter()) == 0 {
+		if repositoryIDs := e.repositoriesIn(types.DependencyNamespace); len(repositoryIDs) > 0 {
+			excluded = append(excluded, anyTermQuery("repository_id", repositoryIDs))
+		}
+	}
+
+	// File path filters
 	if searchQuery.FilePath != "" {
-		pathQuery := bleve.NewWildcardQuery("*" + searchQuery.FilePath + "*")
-		pathQuery.SetField("file_path")
-		queries = append(queries, pathQuery)
+		queries = append(queries, pathContainsQuery(searchQuery.FilePath))
+	}
+	if searchQuery.PathPrefix != "" {
+		queries = append(queries, pathPrefixQuery(searchQuery.PathPrefix))
+	}
+	for _, glob := range searchQuery.ExcludePaths {
+		excluded = append(excluded, pathGlobQuery(glob))
+	}
+
+	// Complexity filter, which only function documents can match
+	if searchQuery.MinComplexity > 0 || searchQuery.MaxComplexity > 0 {
+		queries = append(queries, complexityQuery(searchQuery.MinComplexity, searchQuery.MaxComplexity))
 	}
 
 	// Combine all queries
+	var combined query.Query
 	if len(queries) == 0 {
-		return bleve.NewMatchAllQuery()
+		combined = bleve.NewMatchAllQuery()
 	} else if len(queries) == 1 {
-		return queries[0]
+		combined = queries[0]
 	} else {
-		return bleve.NewConjunctionQuery(queries...)
+		combined = bleve.NewConjunctionQuery(queries...)
+	}
+
+	// Reference and security finding documents are only returned when asked
+	// for by type, so call sites do not crowd out the definitions they name
+	if len(requestedTypes) == 0 {
+		for _, docType := range []string{"reference", "security_finding"} {
+			typeQuery := bleve.NewTermQuery(docType)
+			typeQuery.SetField("type")
+			excluded = append(excluded, typeQuery)
+		}
+	}
+	if len(excluded) > 0 {
+		booleanQuery := bleve.NewBooleanQuery()
+		booleanQuery.AddMust(combined)
+		booleanQuery.AddMustNot(excluded...)
+		combined = booleanQuery
+	}
+	return combined
+}
+
+// mainTextQuery matches the query text the way the query asks for: as name
+// completions, fuzzily, as identifiers, or as text across names, paths and
+// content weighed by the field boosts of the scoring profile
+func mainTextQuery(searchQuery types.SearchQuery, ranking types.Ranking) query.Query {
+	switch {
+	case searchQuery.Prefix:
+		return prefixQuery(searchQuery.Query)
+	case searchQuery.Fuzzy:
+		return fuzzyQuery(searchQuery)
+	case searchQuery.CaseSensitive || searchQuery.WholeWord:
+		return exactTextQuery(searchQuery, ranking)
+	default:
+		return textQuery(searchQuery.Query, ranking)
+	}
+}
+
+// complexityQuery matches functions whose recorded cyclomatic complexity
+// is between min and max inclusive; a zero bound is open
+func complexityQuery(min, max int) query.Query {
+	// Every measured function has a complexity of at least 1, so functions
+	// that were not measured never match
+	low := math.Max(float64(min), 1)
+	var high *float64
+	if max > 0 {
+		value := float64(max)
+		high = &value
+	}
+	inclusive := true
+	rangeQuery := bleve.NewNumericRangeInclusiveQuery(&low, high, &inclusive, &inclusive)
+	rangeQuery.SetField("metadata.cyclomatic")
+	return rangeQuery
+}
+
+// anyTermQuery matches documents whose field equals any of the values
+func anyTermQuery(field string, values []string) query.Query {
+	terms := make([]query.Query, 0, len(values))
+	for _, value := range values {
+		termQuery := bleve.NewTermQuery(value)
+		termQuery.SetField(field)
+		terms = append(terms, termQuery)
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return bleve.NewDisjunctionQuery(terms...)
+}
+
+// SetCacheSize keeps the pages of up to size recent searches in memory,
+// replacing the cache and its statistics; zero turns caching off
+func (e *Engine) SetCacheSize(size int) {
+	e.cache = newResultCache(size)
+}
+
+// invalidateCache drops the cached pages that may contain documents of a
+// repository, or every page when its name is not known
+func (e *Engine) invalidateCache(repository string) {
+	if e.cache != nil {
+		e.cache.invalidate(repository)
 	}
 }
 
+// SetContentLoader sets how content that file and chunk documents do not
+// store is read when results are returned
+func (e *Engine) SetContentLoader(loader ContentLoader) {
+	e.contentLoader = loader
+}
+
+// loadContent fills in the content of results from disk when the index
+// does not store it: the whole file for file results and the lines of the
+// chunk or symbol for the others. Each file is read once; results whose file
+// cannot be read keep their stored snippet.
+func (e *Engine) loadContent(results []types.SearchResult) {
+	if e.fullContent || e.contentLoader == nil {
+		return
+	}
+
+	type loadedFile struct {
+		content string
+		lines   []string // Split when a range of lines is first asked for
+	}
+	files := make(map[string]*loadedFile)
+	for idx := range results {
+		result := &results[idx]
+		if result.Content != "" || slices.Contains(contentTypes, result.Type) {
+			continue
+		}
+
+		key := result.RepositoryID + ":" + result.FilePath
+		file, ok := files[key]
+		if !ok {
+			content, err := e.contentLoader(result.RepositoryID, result.FilePath)
+			if err != nil {
+				e.logger.Debug("Failed to load result content", zap.String("file", result.FilePath), zap.Error(err))
+			} else {
+				file = &loadedFile{content: string(content)}
+			}
+			files[key] = file
+		}
+		if file == nil {
+			continue
+		}
+
+		if result.Type == "file" {
+			result.Content = file.content
+			continue
+		}
+		if file.lines == nil {
+			file.lines = textpos.SplitLines(file.content)
+		}
+		start, end := result.StartLine, result.EndLine
+		if start < 1 {
+			start = 1
+		}
+		if end < start || end > len(file.lines) {
+			end = len(file.lines)
+		}
+		if start <= end {
+			result.Content = strings.Join(file.lines[start-1:end], "\n")
+		}
+	}
+}
+
+// storedSnippet returns the start of content stored in place of it
+func storedSnippet(content string) string {
+	if len(content) > storedSnippetLength {
+		return textpos.Truncate(content, storedSnippetLength) + "..."
+	}
+	return content
+}
+
 // convertSearchHit converts a Bleve search hit to our result format
 func (e *Engine) convertSearchHit(hit *search.DocumentMatch) (types.SearchResult, error) {
 	result := types.SearchResult{
@@ -384,6 +1063,10 @@ func (e *Engine) convertSearchHit(hit *search.DocumentMatch) (types.SearchResult
 	if repo, ok := hit.Fields["repository"].(string); ok {
 		result.Repository = repo
 	}
+	result.Ref = e.RepositoryRef(result.RepositoryID)
+	if project, ok := hit.Fields["project"].(string); ok {
+		result.Project = project
+	}
 	if filePath, ok := hit.Fields["file_path"].(string); ok {
 		result.FilePath = filePath
 	}
@@ -402,9 +1085,16 @@ func (e *Engine) convertSearchHit(hit *search.DocumentMatch) (types.SearchResult
 	if startLine, ok := hit.Fields["start_line"].(float64); ok {
 		result.StartLine = int(startLine)
 	}
+	snippet, _ := hit.Fields["snippet"].(string)
 	if endLine, ok := hit.Fields["end_line"].(float64); ok {
 		result.EndLine = int(endLine)
 	}
+	if references, ok := hit.Fields["reference_count"].(float64); ok {
+		result.ReferenceCount = int(references)
+	}
+	if cyclomatic, ok := hit.Fields["metadata.cyclomatic"].(float64); ok {
+		result.Complexity = int(cyclomatic)
+	}
 
 	// Add highlights
 	if len(hit.Fragments) > 0 {
@@ -420,9 +1110,11 @@ func (e *Engine) convertSearchHit(hit *search.DocumentMatch) (types.SearchResult
 	if result.Highlights != nil && result.Highlights["content"] != "" {
 		result.Snippet = result.Highlights["content"]
 	} else if len(result.Content) > 200 {
-		result.Snippet = result.Content[:200] + "..."
-	} else {
+		result.Snippet = textpos.Truncate(result.Content, 200) + "..."
+	} else if result.Content != "" {
 		result.Snippet = result.Content
+	} else {
+		result.Snippet = snippet
 	}
 
 	return result, nil
@@ -457,7 +1149,7 @@ func (e *Engine) GetFileMetadata(ctx context.Context, filePath, repository strin
 	searchRequest.Size = 1
 	searchRequest.Fields = []string{"*"}
 
-	searchResult, err := e.index.Search(searchRequest)
+	searchResult, err := e.search(ctx, searchRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search for file: %w", err)
 	}
@@ -489,6 +1181,11 @@ func (e *Engine) GetFileMetadata(ctx context.Context, filePath, repository strin
 		repoID = id
 		file.RepositoryID = id
 	}
+	if file.Content == "" && !e.fullContent && e.contentLoader != nil && repoID != "" {
+		if content, err := e.contentLoader(repoID, file.RelativePath); err == nil {
+			file.Content = string(content)
+		}
+	}
 
 	// Now get all related components (functions, classes, variables, comments)
 	if repoID != "" {
@@ -500,6 +1197,9 @@ func (e *Engine) GetFileMetadata(ctx context.Context, filePath, repository strin
 	return file, nil
 }
 
+// typeDeclarationKinds are the document types of type declarations
+var typeDeclarationKinds = append([]string{"interface", "type_alias"}, types.SchemaSymbolTypes...)
+
 // enrichFileMetadata adds functions, classes, variables, and comments to a file
 func (e *Engine) enrichFileMetadata(ctx context.Context, file *types.CodeFile, repoID string) error {
 	// Query for all components of this file
@@ -521,7 +1221,8 @@ func (e *Engine) enrichFileMetadata(ctx context.Context, file *types.CodeFile, r
 	commentQuery := bleve.NewTermQuery("comment")
 	commentQuery.SetField("type")
 
-	typeQuery := bleve.NewDisjunctionQuery(funcQuery, classQuery, varQuery, commentQuery)
+	typeQuery := bleve.NewDisjunctionQuery(funcQuery, classQuery, varQuery, commentQuery,
+		anyTermQuery("type", typeDeclarationKinds))
 
 	searchQuery := bleve.NewConjunctionQuery(repoQuery, pathQuery, typeQuery)
 
@@ -529,7 +1230,7 @@ func (e *Engine) enrichFileMetadata(ctx context.Context, file *types.CodeFile, r
 	searchRequest.Size = 1000 // Large number to get all components
 	searchRequest.Fields = []string{"*"}
 
-	searchResult, err := e.index.Search(searchRequest)
+	searchResult, err := e.searchRepository(ctx, repoID, searchRequest)
 	if err != nil {
 		return fmt.Errorf("failed to search for file components: %w", err)
 	}
@@ -551,6 +1252,9 @@ func (e *Engine) enrichFileMetadata(ctx context.Context, file *types.CodeFile, r
 		case "comment":
 			comment := e.extractComment(hit)
 			file.Comments = append(file.Comments, comment)
+		case "interface", "type_alias", "message", "enum", "service", "rpc", "table", "view", "index", "procedure":
+			declaration := e.extractTypeDeclaration(hit)
+			file.TypeDeclarations = append(file.TypeDeclarations, declaration)
 		}
 	}
 
@@ -573,6 +1277,12 @@ func (e *Engine) extractFunction(hit *search.DocumentMatch) types.Function {
 	if endLine, ok := hit.Fields["end_line"].(float64); ok {
 		function.EndLine = int(endLine)
 	}
+	if cyclomatic, ok := hit.Fields["metadata.cyclomatic"].(float64); ok {
+		function.Cyclomatic = int(cyclomatic)
+	}
+	if cognitive, ok := hit.Fields["metadata.cognitive"].(float64); ok {
+		function.Cognitive = int(cognitive)
+	}
 
 	// Extract metadata if available
 	if metadata, ok := hit.Fields["metadata"].(map[string]interface{}); ok {
@@ -633,6 +1343,39 @@ func (e *Engine) extractClass(hit *search.DocumentMatch) types.Class {
 	return class
 }
 
+// extractTypeDeclaration extracts interface and type alias data from a
+// search hit
+func (e *Engine) extractTypeDeclaration(hit *search.DocumentMatch) types.TypeDeclaration {
+	declaration := types.TypeDeclaration{}
+
+	if kind, ok := hit.Fields["type"].(string); ok {
+		declaration.Kind = kind
+	}
+	if name, ok := hit.Fields["name"].(string); ok {
+		declaration.Name = name
+	}
+	if content, ok := hit.Fields["content"].(string); ok {
+		declaration.Definition = content
+	}
+	if startLine, ok := hit.Fields["start_line"].(float64); ok {
+		declaration.StartLine = int(startLine)
+	}
+	if endLine, ok := hit.Fields["end_line"].(float64); ok {
+		declaration.EndLine = int(endLine)
+	}
+	if typeParameters, ok := hit.Fields["metadata.type_parameters"].(string); ok {
+		declaration.TypeParameters = typeParameters
+	}
+	if parent, ok := hit.Fields["metadata.parent"].(string); ok {
+		declaration.Parent = parent
+	}
+	if isExported, ok := hit.Fields["metadata.is_exported"].(bool); ok {
+		declaration.IsExported = isExported
+	}
+
+	return declaration
+}
+
 // extractVariable extracts variable data from a search hit
 func (e *Engine) extractVariable(hit *search.DocumentMatch) types.Variable {
 	variable := types.Variable{}
@@ -706,7 +1449,7 @@ func (e *Engine) ListRepositories(ctx context.Context) ([]types.Repository, erro
 	searchRequest.Size = 10000 // Large number to get all files
 	searchRequest.Fields = []string{"repository_id", "repository", "language"}
 
-	searchResult, err := e.index.Search(searchRequest)
+	searchResult, err := e.search(ctx, searchRequest)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search for repositories: %w", err)
 	}
@@ -729,6 +1472,7 @@ func (e *Engine) ListRepositories(ctx context.Context) ([]types.Repository, erro
 			repoMap[repoID] = &types.Repository{
 				ID:   repoID,
 				Name: repoName,
+				Ref:  e.RepositoryRef(repoID),
 			}
 			languageStats[repoID] = make(map[string]int)
 		}
@@ -773,7 +1517,7 @@ func (e *Engine) GetIndexStats(ctx context.Context) (*types.IndexStats, error) {
 		searchRequest := bleve.NewSearchRequest(typeQuery)
 		searchRequest.Size = 0 // We only want the count
 
-		searchResult, err := e.index.Search(searchRequest)
+		searchResult, err := e.search(ctx, searchRequest)
 		if err != nil {
 			e.logger.Warn("Failed to get stats for type", zap.String("type", docType), zap.Error(err))
 			continue
@@ -805,35 +1549,185 @@ func (e *Engine) GetIndexStats(ctx context.Context) (*types.IndexStats, error) {
 			}
 		}
 	}
+	stats.UnknownLanguages = e.unknownLanguageStats(ctx)
+	if e.cache != nil {
+		stats.Cache = e.cache.snapshot()
+	}
 
 	return stats, nil
 }
 
-// DeleteRepository removes all documents for a repository from the index
+// unknownLanguageStats counts the file documents of an unknown language per
+// extension, or per file name for files without one
+func (e *Engine) unknownLanguageStats(ctx context.Context) types.UnknownLanguageStats {
+	stats := types.UnknownLanguageStats{Extensions: make(map[string]int)}
+
+	fileQuery := bleve.NewTermQuery("file")
+	fileQuery.SetField("type")
+	languageQuery := bleve.NewTermQuery("unknown")
+	languageQuery.SetField("language")
+
+	searchRequest := bleve.NewSearchRequest(bleve.NewConjunctionQuery(fileQuery, languageQuery))
+	searchRequest.Size = 10000 // Large number to get all files
+	searchRequest.Fields = []string{"file_path"}
+
+	searchResult, err := e.search(ctx, searchRequest)
+	if err != nil {
+		e.logger.Warn("Failed to get stats for unknown languages", zap.Error(err))
+		return stats
+	}
+
+	stats.Files = int(searchResult.Total)
+	for _, hit := range searchResult.Hits {
+		filePath, _ := hit.Fields["file_path"].(string)
+		name := filepath.Base(filePath)
+		if ext := strings.ToLower(filepath.Ext(name)); ext != "" {
+			name = ext
+		}
+		stats.Extensions[name]++
+	}
+	return stats
+}
+
+// deleteRepositoryPageSize bounds the number of documents looked up per
+// round when deleting a repository
+const deleteRepositoryPageSize = 10000
+
+// DeleteRepository removes all documents for a repository: its index is
+// deleted, and its documents in the shared index of an earlier version, if
+// any, are deleted one page at a time
 func (e *Engine) DeleteRepository(ctx context.Context, repositoryID string) error {
+	defer e.invalidateCache(e.repositoryName(repositoryID))
+	if err := e.dropRepositoryIndex(repositoryID); err != nil {
+		return err
+	}
+	if e.legacy == nil {
+		return nil
+	}
+
 	// Query for all documents of this repository
 	repoQuery := bleve.NewTermQuery(repositoryID)
 	repoQuery.SetField("repository_id")
 
-	searchRequest := bleve.NewSearchRequest(repoQuery)
-	searchRequest.Size = 10000 // Large number to get all documents
-	searchRequest.Fields = []string{"_id"}
+	// Delete a page of documents at a time until none are left
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-	searchResult, err := e.index.Search(searchRequest)
-	if err != nil {
-		return fmt.Errorf("failed to search for repository documents: %w", err)
+		searchRequest := bleve.NewSearchRequest(repoQuery)
+		searchRequest.Size = deleteRepositoryPageSize
+
+		searchResult, err := e.legacy.SearchInContext(ctx, searchRequest)
+		if err != nil {
+			return fmt.Errorf("failed to search for repository documents: %w", err)
+		}
+		if len(searchResult.Hits) == 0 {
+			return nil
+		}
+
+		batch := e.legacy.NewBatch()
+		for _, hit := range searchResult.Hits {
+			batch.Delete(hit.ID)
+		}
+		if err := e.legacy.Batch(batch); err != nil {
+			return fmt.Errorf("failed to delete repository documents: %w", err)
+		}
 	}
+}
 
-	// Delete documents in batches
-	batch := e.index.NewBatch()
-	for _, hit := range searchResult.Hits {
-		batch.Delete(hit.ID)
+// deleteFilesQuerySize bounds the number of paths matched per query when
+// deleting files
+const deleteFilesQuerySize = 100
+
+// DeleteFiles removes the documents of the given files of a repository and
+// returns how many were removed. Paths are relative to the repository root.
+func (e *Engine) DeleteFiles(ctx context.Context, repositoryID string, relativePaths []string) (int, error) {
+	defer e.invalidateCache(e.repositoryName(repositoryID))
+	deleted := 0
+	for _, index := range e.repositoryIndexes(repositoryID) {
+		count, err := deleteFiles(ctx, index, repositoryID, relativePaths)
+		deleted += count
+		if err != nil {
+			return deleted, err
+		}
 	}
+	return deleted, nil
+}
 
-	return e.index.Batch(batch)
+// deleteFiles removes the documents of the given files of a repository from
+// one index and returns how many were removed
+func deleteFiles(ctx context.Context, index bleve.Index, repositoryID string, relativePaths []string) (int, error) {
+	deleted := 0
+	for start := 0; start < len(relativePaths); start += deleteFilesQuerySize {
+		end := start + deleteFilesQuerySize
+		if end > len(relativePaths) {
+			end = len(relativePaths)
+		}
+
+		wanted := make(map[string]bool, end-start)
+		pathQueries := make([]query.Query, 0, end-start)
+		for _, relativePath := range relativePaths[start:end] {
+			wanted[relativePath] = true
+			pathQuery := bleve.NewMatchPhraseQuery(relativePath)
+			pathQuery.SetField("file_path")
+			pathQueries = append(pathQueries, pathQuery)
+		}
+
+		repoQuery := bleve.NewTermQuery(repositoryID)
+		repoQuery.SetField("repository_id")
+
+		searchRequest := bleve.NewSearchRequest(bleve.NewConjunctionQuery(repoQuery, bleve.NewDisjunctionQuery(pathQueries...)))
+		searchRequest.Size = 10000 // Large number to get all documents
+		searchRequest.Fields = []string{"file_path"}
+
+		searchResult, err := index.SearchInContext(ctx, searchRequest)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to search for file documents: %w", err)
+		}
+
+		batch := index.NewBatch()
+		for _, hit := range searchResult.Hits {
+			// The phrase also matches longer paths ending in a wanted path
+			if filePath, ok := hit.Fields["file_path"].(string); ok && !wanted[filePath] {
+				continue
+			}
+			batch.Delete(hit.ID)
+		}
+		count := batch.Size()
+		if err := index.Batch(batch); err != nil {
+			return deleted, fmt.Errorf("failed to delete file documents: %w", err)
+		}
+		deleted += count
+	}
+	return deleted, nil
+}
+
+// CountDocuments returns the number of documents indexed for a repository
+func (e *Engine) CountDocuments(ctx context.Context, repositoryID string) (int, error) {
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+
+	searchRequest := bleve.NewSearchRequest(repoQuery)
+	searchRequest.Size = 0
+
+	searchResult, err := e.searchRepository(ctx, repositoryID, searchRequest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count repository documents: %w", err)
+	}
+	return int(searchResult.Total), nil
 }
 
-// Close closes the search engine
+// Close closes the search engine and all its indexes
 func (e *Engine) Close() error {
-	return e.index.Close()
+	var err error
+	for _, index := range e.allIndexes() {
+		if closeErr := index.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := e.alias.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
 }
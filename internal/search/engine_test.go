@@ -0,0 +1,277 @@
+package search
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// indexOneFile adds a file document to an engine so tests can tell whether
+// an index was kept or recreated
+func indexOneFile(t *testing.T, engine *Engine) {
+	t.Helper()
+	file := &types.CodeFile{Path: "main.go", RelativePath: "main.go", Language: "go", Content: "package main\n", Lines: 1}
+	if err := engine.IndexFile(context.Background(), file, &types.Repository{ID: "repo", Name: "repo"}); err != nil {
+		t.Fatalf("Failed to index file: %v", err)
+	}
+}
+
+func documentCount(t *testing.T, engine *Engine) uint64 {
+	t.Helper()
+	var total uint64
+	for _, index := range engine.allIndexes() {
+		count, err := index.DocCount()
+		if err != nil {
+			t.Fatalf("Failed to count documents: %v", err)
+		}
+		total += count
+	}
+	return total
+}
+
+func TestMappingVersion(t *testing.T) {
+	storage := config.DefaultConfig().SearchStorage()
+	if mappingVersion(storage) != mappingVersion(storage) {
+		t.Error("Expected the mapping version to be stable")
+	}
+
+	reordered := storage
+	reordered.SkipContentTypes = []string{"file", "chunk"}
+	storage.SkipContentTypes = []string{"chunk", "file"}
+	if mappingVersion(storage) != mappingVersion(reordered) {
+		t.Error("Expected the order of listed types not to change the version")
+	}
+
+	rebuild := storage
+	rebuild.RebuildOnChange = true
+	if mappingVersion(storage) != mappingVersion(rebuild) {
+		t.Error("Expected rebuild_on_change not to be part of the version")
+	}
+
+	withoutVectors := storage
+	withoutVectors.TermVectors = false
+	if mappingVersion(storage) == mappingVersion(withoutVectors) {
+		t.Error("Expected term_vectors to change the version")
+	}
+
+	snippetsOnly := storage
+	snippetsOnly.StoreFullContent = false
+	if mappingVersion(storage) == mappingVersion(snippetsOnly) {
+		t.Error("Expected store_full_content to change the version")
+	}
+}
+
+func TestIndexUpgrade(t *testing.T) {
+	storage := config.DefaultConfig().SearchStorage()
+	logger := zap.NewNop()
+
+	open := func(dir string, storage config.StorageConfig) *Engine {
+		t.Helper()
+		engine, err := NewEngineWithStorage(dir, storage, logger)
+		if err != nil {
+			t.Fatalf("Failed to open index: %v", err)
+		}
+		return engine
+	}
+
+	t.Run("same version is kept", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "index")
+		engine := open(dir, storage)
+		indexOneFile(t, engine)
+		engine.Close()
+
+		rebuild := storage
+		rebuild.RebuildOnChange = true
+		engine = open(dir, rebuild)
+		defer engine.Close()
+		if documentCount(t, engine) == 0 {
+			t.Error("Expected an index with the current mapping version to be kept")
+		}
+	})
+
+	t.Run("older schema is rebuilt when asked", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "index")
+		engine := open(dir, storage)
+		indexOneFile(t, engine)
+		if err := engine.indexes["repo"].SetInternal(mappingVersionKey, []byte("0:legacy")); err != nil {
+			t.Fatalf("Failed to set mapping version: %v", err)
+		}
+		engine.Close()
+
+		// Without rebuild_on_change the old index and its version are kept
+		engine = open(dir, storage)
+		if documentCount(t, engine) == 0 {
+			t.Error("Expected the index to be kept without rebuild_on_change")
+		}
+		if version, _ := storedMappingVersion(engine.indexes["repo"]); version != "0:legacy" {
+			t.Errorf("Expected the old mapping version to be kept, got %q", version)
+		}
+		engine.Close()
+
+		rebuild := storage
+		rebuild.RebuildOnChange = true
+		engine = open(dir, rebuild)
+		defer engine.Close()
+		if count := documentCount(t, engine); count != 0 {
+			t.Errorf("Expected the outdated index to be removed, got %d documents", count)
+		}
+
+		// Indexing the repository again creates an index with the current version
+		indexOneFile(t, engine)
+		if version, _ := storedMappingVersion(engine.indexes["repo"]); version != mappingVersion(storage) {
+			t.Errorf("Expected the new index to record version %q, got %q", mappingVersion(storage), version)
+		}
+	})
+
+	t.Run("index without a version is rebuilt when asked", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "index")
+		index, err := bleve.New(filepath.Join(dir, repositoriesDir, "repo"), createIndexMapping(storage))
+		if err != nil {
+			t.Fatalf("Failed to create index: %v", err)
+		}
+		index.Close()
+
+		rebuild := storage
+		rebuild.RebuildOnChange = true
+		engine := open(dir, rebuild)
+		defer engine.Close()
+		if _, ok := engine.indexes["repo"]; ok {
+			t.Error("Expected an index without a version to be removed")
+		}
+	})
+
+	t.Run("changed storage settings are rebuilt when asked", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "index")
+		engine := open(dir, storage)
+		indexOneFile(t, engine)
+		engine.Close()
+
+		changed := storage
+		changed.SkipContentTypes = []string{"file"}
+		changed.RebuildOnChange = true
+		engine = open(dir, changed)
+		defer engine.Close()
+		if count := documentCount(t, engine); count != 0 {
+			t.Errorf("Expected the index to be rebuilt for new storage settings, got %d documents", count)
+		}
+	})
+}
+
+func TestSnippetOnlyContent(t *testing.T) {
+	storage := config.DefaultConfig().SearchStorage()
+	storage.StoreFullContent = false
+	engine, err := NewMemoryEngine(storage, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	content := "package main\n\n" + strings.Repeat("// padding\n", 30) + "func Serve() {}\n"
+	file := &types.CodeFile{
+		Path: "main.go", RelativePath: "main.go", Language: "go", Content: content, Lines: 33,
+		Chunks:    []types.CodeChunk{{ID: "serve", Name: "Serve", Content: "func Serve() {}", StartLine: 33, EndLine: 33}},
+		Functions: []types.Function{{Name: "Serve", Signature: "func Serve()", StartLine: 33, EndLine: 33}},
+	}
+	if err := engine.IndexFile(context.Background(), file, &types.Repository{ID: "repo", Name: "repo"}); err != nil {
+		t.Fatalf("Failed to index file: %v", err)
+	}
+
+	search := func() map[string]types.SearchResult {
+		t.Helper()
+		results, err := engine.Search(context.Background(), types.SearchQuery{Query: "Serve", Types: []string{"file", "chunk", "function"}, MaxResults: 10})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		byType := make(map[string]types.SearchResult)
+		for _, result := range results {
+			byType[result.Type] = result
+		}
+		return byType
+	}
+
+	// Content is still searchable, but only a snippet is stored
+	results := search()
+	if len(results) != 3 {
+		t.Fatalf("Expected a file, a chunk and a function result, got %v", results)
+	}
+	if results["file"].Content != "" || !strings.HasPrefix(results["file"].Snippet, "package main") {
+		t.Errorf("Expected only the stored snippet without a loader, got %+v", results["file"])
+	}
+	if results["function"].Content != "" || results["function"].Snippet != "func Serve()" {
+		t.Errorf("Expected only the stored snippet of the symbol without a loader, got %+v", results["function"])
+	}
+
+	// With a loader, content is read from disk
+	engine.SetContentLoader(func(repositoryID, filePath string) ([]byte, error) {
+		if repositoryID != "repo" || filePath != "main.go" {
+			t.Errorf("Unexpected load of %s in %s", filePath, repositoryID)
+		}
+		return []byte(content), nil
+	})
+	results = search()
+	if results["file"].Content != content {
+		t.Errorf("Expected the file content to be loaded, got %q", results["file"].Content)
+	}
+	if results["chunk"].Content != "func Serve() {}" {
+		t.Errorf("Expected the chunk's lines to be loaded, got %q", results["chunk"].Content)
+	}
+	if results["function"].Content != "func Serve() {}" {
+		t.Errorf("Expected the symbol's lines to be loaded, got %q", results["function"].Content)
+	}
+}
+
+func TestSectionAndConfigKeyDocuments(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	repo := &types.Repository{ID: "repo", Name: "repo"}
+	files := []*types.CodeFile{
+		{
+			Path: "values.yaml", RelativePath: "values.yaml", Language: "yaml", Lines: 3,
+			Content: "http:\n  retry:\n    limit: 5\n",
+			ConfigKeys: []types.ConfigKey{
+				{Path: "http", Key: "http", ValueType: "mapping", StartLine: 1, EndLine: 3},
+				{Path: "http.retry", Key: "retry", ValueType: "mapping", StartLine: 2, EndLine: 3},
+				{Path: "http.retry.limit", Key: "limit", Value: "5", ValueType: "number", StartLine: 3, EndLine: 3},
+			},
+		},
+		{
+			Path: "README.md", RelativePath: "README.md", Language: "markdown", Lines: 2,
+			Content: "# Deploying\nRun make deploy.\n",
+			Sections: []types.Section{
+				{Kind: "heading", Title: "Deploying", Level: 1, Content: "# Deploying\nRun make deploy.", StartLine: 1, EndLine: 2},
+			},
+		},
+	}
+	for _, file := range files {
+		if err := engine.IndexFile(context.Background(), file, repo); err != nil {
+			t.Fatalf("Failed to index %s: %v", file.RelativePath, err)
+		}
+	}
+
+	results, err := engine.Search(context.Background(), types.SearchQuery{Query: "retry limit", Types: []string{"config_key"}, MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) == 0 || results[0].Name != "http.retry.limit" || results[0].StartLine != 3 || results[0].Content != "http.retry.limit: 5" {
+		t.Errorf("Expected the retry limit key first, got %+v", results)
+	}
+
+	results, err = engine.Search(context.Background(), types.SearchQuery{Query: "deploy", Types: []string{"section"}, MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "Deploying" || results[0].FilePath != "README.md" {
+		t.Errorf("Expected the Deploying section, got %+v", results)
+	}
+}
@@ -0,0 +1,69 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// queryWords returns the lowercased identifiers of a query, the terms the
+// code analyzer indexes them as
+func queryWords(text string) []string {
+	return identifierPattern.FindAllString(strings.ToLower(text), -1)
+}
+
+// fuzzyQuery matches every word of the query within Fuzziness edits.
+// Queries for symbol types only match names, so a misspelt function name is
+// not also found in every comment and file that comes close to it.
+func fuzzyQuery(searchQuery types.SearchQuery) query.Query {
+	words := queryWords(searchQuery.Query)
+	if len(words) == 0 {
+		return bleve.NewMatchNoneQuery()
+	}
+
+	fuzziness := searchQuery.Fuzziness
+	if fuzziness <= 0 {
+		fuzziness = 1
+	}
+	wordQueries := make([]query.Query, 0, len(words))
+	for _, word := range words {
+		wordQuery := bleve.NewFuzzyQuery(word)
+		wordQuery.SetFuzziness(fuzziness)
+		if searchQuery.SymbolsOnly() {
+			wordQuery.SetField("name")
+		}
+		wordQueries = append(wordQueries, wordQuery)
+	}
+	if len(wordQueries) == 1 {
+		return wordQueries[0]
+	}
+	return bleve.NewConjunctionQuery(wordQueries...)
+}
+
+// prefixQuery matches names containing the words of the query, the last
+// one as a prefix, so `parseJ` finds parseJSON and `http cli` finds
+// HTTPClient. Only indexed terms are expanded, which keeps completion fast
+// on large indexes.
+func prefixQuery(text string) query.Query {
+	words := queryWords(text)
+	if len(words) == 0 {
+		return bleve.NewMatchNoneQuery()
+	}
+
+	wordQueries := make([]query.Query, 0, len(words))
+	for _, word := range words[:len(words)-1] {
+		termQuery := bleve.NewTermQuery(word)
+		termQuery.SetField("name")
+		wordQueries = append(wordQueries, termQuery)
+	}
+	lastQuery := bleve.NewPrefixQuery(words[len(words)-1])
+	lastQuery.SetField("name")
+	wordQueries = append(wordQueries, lastQuery)
+	if len(wordQueries) == 1 {
+		return wordQueries[0]
+	}
+	return bleve.NewConjunctionQuery(wordQueries...)
+}
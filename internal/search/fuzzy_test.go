@@ -0,0 +1,67 @@
+package search
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestFuzzyAndPrefixSearch(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	file := &types.CodeFile{
+		Path: "parse.go", RelativePath: "parse.go", Language: "go", Lines: 3,
+		Content: "// parser helpers\nfunc parseJSON() {}\nfunc newHTTPClient() {}",
+		Functions: []types.Function{
+			{Name: "parseJSON", Signature: "func parseJSON()", StartLine: 2, EndLine: 2},
+			{Name: "newHTTPClient", Signature: "func newHTTPClient()", StartLine: 3, EndLine: 3},
+		},
+	}
+	if err := engine.IndexFile(context.Background(), file, &types.Repository{ID: "repo", Name: "repo"}); err != nil {
+		t.Fatalf("Failed to index file: %v", err)
+	}
+
+	search := func(query types.SearchQuery) []string {
+		t.Helper()
+		query.MaxResults = 10
+		results, err := engine.Search(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		var matches []string
+		for _, result := range results {
+			matches = append(matches, result.Type+" "+result.Name)
+		}
+		sort.Strings(matches)
+		return matches
+	}
+
+	tests := []struct {
+		name  string
+		query types.SearchQuery
+		want  []string
+	}{
+		{"fuzzy symbols match names only", types.SearchQuery{Query: "parsejsn", Fuzzy: true, Types: types.SymbolTypes}, []string{"function parseJSON"}},
+		{"fuzzy beyond the edit distance", types.SearchQuery{Query: "prsejsn", Fuzzy: true, Type: "function"}, nil},
+		{"fuzziness 2", types.SearchQuery{Query: "prsejsn", Fuzzy: true, Fuzziness: 2, Type: "function"}, []string{"function parseJSON"}},
+		{"prefix", types.SearchQuery{Query: "parseJ", Prefix: true, Type: "function"}, []string{"function parseJSON"}},
+		{"prefix of a word", types.SearchQuery{Query: "http cli", Prefix: true, Type: "function"}, []string{"function newHTTPClient"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := search(tt.query); !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// fileImportsMetadata returns the metadata a file document records about the
+// module of the file and the modules it imports, each once
+func fileImportsMetadata(file *types.CodeFile) map[string]interface{} {
+	metadata := make(map[string]interface{})
+	if file.Module != "" {
+		metadata["module"] = file.Module
+	}
+	seen := make(map[string]bool, len(file.Imports))
+	var imports []string
+	for _, imp := range file.Imports {
+		if imp.Module != "" && !seen[imp.Module] {
+			seen[imp.Module] = true
+			imports = append(imports, imp.Module)
+		}
+	}
+	if len(imports) > 0 {
+		metadata["imports"] = imports
+	}
+	return metadata
+}
+
+// FileImports returns the module and imports recorded for every file
+// document of a repository. Files indexed before imports were recorded have
+// neither.
+func (e *Engine) FileImports(ctx context.Context, repositoryID string) ([]types.FileImports, error) {
+	typeQuery := bleve.NewTermQuery("file")
+	typeQuery.SetField("type")
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+
+	var files []types.FileImports
+	for from := 0; ; from += fileHashesPageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		searchRequest := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(typeQuery, repoQuery), fileHashesPageSize, from, false)
+		searchRequest.Fields = []string{"file_path", "language", "metadata.module", "metadata.imports"}
+		searchRequest.SortBy([]string{"_id"})
+
+		searchResult, err := e.searchRepository(ctx, repositoryID, searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for file documents: %w", err)
+		}
+		for _, hit := range searchResult.Hits {
+			file := types.FileImports{}
+			file.FilePath, _ = hit.Fields["file_path"].(string)
+			file.Language, _ = hit.Fields["language"].(string)
+			file.Module, _ = hit.Fields["metadata.module"].(string)
+			// Bleve returns a single value for one-element arrays
+			switch imports := hit.Fields["metadata.imports"].(type) {
+			case string:
+				file.Imports = []string{imports}
+			case []interface{}:
+				for _, imp := range imports {
+					if module, ok := imp.(string); ok {
+						file.Imports = append(file.Imports, module)
+					}
+				}
+			}
+			if file.FilePath != "" {
+				files = append(files, file)
+			}
+		}
+		if len(searchResult.Hits) < fileHashesPageSize {
+			return files, nil
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"go.uber.org/zap"
+)
+
+// repositoriesDir is the directory below the index directory holding the
+// index of each repository, in a directory named after its ID
+const repositoriesDir = "repositories"
+
+// refKey is the internal key the ref a repository was indexed at is stored
+// under in its index
+var refKey = []byte("ref")
+
+// namespaceKey is the internal key the namespace of a repository is stored
+// under in its index
+var namespaceKey = []byte("namespace")
+
+// legacyIndexName names the single index shared by all repositories that
+// earlier versions kept directly in the index directory
+const legacyIndexName = "shared"
+
+// openIndexes opens the shared index of an earlier version, if the index
+// directory holds one, and the index of every repository
+func (e *Engine) openIndexes() error {
+	if err := os.MkdirAll(filepath.Join(e.indexDir, repositoriesDir), 0755); err != nil {
+		return fmt.Errorf("failed to create index directory: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(e.indexDir, "index_meta.json")); err == nil {
+		index, err := e.openIndex(e.indexDir, func() error { return removeLegacyIndex(e.indexDir) })
+		switch {
+		case err != nil:
+			// Its documents stay on disk; they are not searched until the
+			// index opens again
+			e.logger.Warn("Failed to open shared search index of an earlier version, skipping it",
+				zap.String("path", e.indexDir), zap.Error(err))
+		case index != nil:
+			e.logger.Info("Opened shared search index of an earlier version, repositories move to their own index when re-indexed",
+				zap.String("path", e.indexDir))
+			index.SetName(legacyIndexName)
+			e.legacy = index
+			e.alias.Add(index)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(e.indexDir, repositoriesDir))
+	if err != nil {
+		return fmt.Errorf("failed to list repository indexes: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		repositoryID := entry.Name()
+		dir := e.repositoryIndexDir(repositoryID)
+		index, err := e.openIndex(dir, func() error { return os.RemoveAll(dir) })
+		if err != nil {
+			e.logger.Warn("Failed to open repository index, it is recreated when the repository is indexed again",
+				zap.String("repo_id", repositoryID), zap.String("path", dir), zap.Error(err))
+			continue
+		}
+		if index != nil {
+			e.addIndex(repositoryID, index)
+		}
+	}
+
+	e.logger.Info("Opened search indexes",
+		zap.String("path", e.indexDir),
+		zap.Int("repositories", len(e.indexes)),
+		zap.Bool("shared_index", e.legacy != nil))
+	return nil
+}
+
+// openIndex opens the index at dir and compares the version of its mapping
+// with the current one. An index whose mapping differs is kept unless
+// RebuildOnChange is set, in which case it is closed and removed with remove
+// and nil is returned.
+func (e *Engine) openIndex(dir string, remove func() error) (bleve.Index, error) {
+	index, err := bleve.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := storedMappingVersion(index)
+	if err != nil {
+		index.Close()
+		return nil, err
+	}
+	desired := mappingVersion(e.storage)
+	if existing == desired {
+		return index, nil
+	}
+
+	if !e.storage.RebuildOnChange {
+		e.logger.Warn("Index mapping differs from this version or its storage settings, keeping existing mapping",
+			zap.String("path", dir),
+			zap.String("index_version", existing),
+			zap.String("desired_version", desired),
+			zap.String("hint", "set search.storage.rebuild_on_change or delete the index directory, then re-index repositories"))
+		return index, nil
+	}
+
+	e.logger.Warn("Index mapping changed, removing index, its repositories must be re-indexed",
+		zap.String("path", dir),
+		zap.String("index_version", existing),
+		zap.String("desired_version", desired))
+	if err := index.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close search index: %w", err)
+	}
+	if err := remove(); err != nil {
+		return nil, fmt.Errorf("failed to remove search index: %w", err)
+	}
+	return nil, nil
+}
+
+// removeLegacyIndex removes the shared index of an earlier version from the
+// index directory, leaving the repository indexes below it in place
+func removeLegacyIndex(indexDir string) error {
+	if err := os.RemoveAll(filepath.Join(indexDir, "store")); err != nil {
+		return err
+	}
+	return os.Remove(filepath.Join(indexDir, "index_meta.json"))
+}
+
+// repositoryIndexDir returns the directory of the index of a repository
+func (e *Engine) repositoryIndexDir(repositoryID string) string {
+	return filepath.Join(e.indexDir, repositoriesDir, repositoryID)
+}
+
+// addIndex makes the index of a repository searchable and reads the ref it
+// was indexed at and its namespace. The caller holds indexesMutex or has not shared the engine
+// yet.
+func (e *Engine) addIndex(repositoryID string, index bleve.Index) {
+	index.SetName(repositoryID)
+	e.indexes[repositoryID] = index
+	e.alias.Add(index)
+	if ref, err := index.GetInternal(refKey); err == nil && len(ref) > 0 {
+		e.refs[repositoryID] = string(ref)
+	}
+	if namespace, err := index.GetInternal(namespaceKey); err == nil && len(namespace) > 0 {
+		e.namespaces[repositoryID] = string(namespace)
+	}
+}
+
+// SetRepositoryRef records the branch, tag or commit a repository was
+// indexed at, or that it was indexed without one when ref is empty. The ref
+// is kept in the index of the repository rather than in its documents, so
+// switching refs does not require re-indexing unchanged files. Repositories
+// without an index of their own are ignored.
+func (e *Engine) SetRepositoryRef(repositoryID, ref string) error {
+	// Searches filtered by ref may now find other repositories
+	defer e.invalidateCache("")
+
+	e.indexesMutex.Lock()
+	defer e.indexesMutex.Unlock()
+
+	index, ok := e.indexes[repositoryID]
+	if !ok {
+		return nil
+	}
+	if err := index.SetInternal(refKey, []byte(ref)); err != nil {
+		return fmt.Errorf("failed to record ref of repository %s: %w", repositoryID, err)
+	}
+	if ref == "" {
+		delete(e.refs, repositoryID)
+	} else {
+		e.refs[repositoryID] = ref
+	}
+	return nil
+}
+
+// RepositoryRef returns the ref a repository was indexed at, or "" if none
+// was recorded
+func (e *Engine) RepositoryRef(repositoryID string) string {
+	e.indexesMutex.RLock()
+	defer e.indexesMutex.RUnlock()
+	return e.refs[repositoryID]
+}
+
+// repositoriesAt returns the IDs of the repositories indexed at ref
+func (e *Engine) repositoriesAt(ref string) []string {
+	e.indexesMutex.RLock()
+	defer e.indexesMutex.RUnlock()
+
+	var repositoryIDs []string
+	for repositoryID, indexedRef := range e.refs {
+		if indexedRef == ref {
+			repositoryIDs = append(repositoryIDs, repositoryID)
+		}
+	}
+	sort.Strings(repositoryIDs)
+	return repositoryIDs
+}
+
+// SetRepositoryNamespace records the namespace of a repository, such as
+// types.DependencyNamespace, or that it has none when namespace is empty.
+// Like the ref, it is kept in the index of the repository. Repositories
+// without an index of their own are ignored.
+func (e *Engine) SetRepositoryNamespace(repositoryID, namespace string) error {
+	// Searches leaving out a namespace may now find other repositories
+	defer e.invalidateCache("")
+
+	e.indexesMutex.Lock()
+	defer e.indexesMutex.Unlock()
+
+	index, ok := e.indexes[repositoryID]
+	if !ok {
+		return nil
+	}
+	if err := index.SetInternal(namespaceKey, []byte(namespace)); err != nil {
+		return fmt.Errorf("failed to record namespace of repository %s: %w", repositoryID, err)
+	}
+	if namespace == "" {
+		delete(e.namespaces, repositoryID)
+	} else {
+		e.namespaces[repositoryID] = namespace
+	}
+	return nil
+}
+
+// repositoriesIn returns the IDs of the repositories in a namespace
+func (e *Engine) repositoriesIn(namespace string) []string {
+	e.indexesMutex.RLock()
+	defer e.indexesMutex.RUnlock()
+
+	var repositoryIDs []string
+	for repositoryID, repositoryNamespace := range e.namespaces {
+		if repositoryNamespace == namespace {
+			repositoryIDs = append(repositoryIDs, repositoryID)
+		}
+	}
+	sort.Strings(repositoryIDs)
+	return repositoryIDs
+}
+
+// repositoryIndex returns the index of a repository, creating it on first
+// use. A directory left by an index that failed to open is replaced.
+func (e *Engine) repositoryIndex(repositoryID string) (bleve.Index, error) {
+	e.indexesMutex.RLock()
+	index, ok := e.indexes[repositoryID]
+	e.indexesMutex.RUnlock()
+	if ok {
+		return index, nil
+	}
+
+	if !validRepositoryID(repositoryID) {
+		return nil, fmt.Errorf("invalid repository ID %q", repositoryID)
+	}
+
+	e.indexesMutex.Lock()
+	defer e.indexesMutex.Unlock()
+	if index, ok := e.indexes[repositoryID]; ok {
+		return index, nil
+	}
+
+	dir := ""
+	if e.indexDir != "" {
+		dir = e.repositoryIndexDir(repositoryID)
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("failed to clear index directory of repository %s: %w", repositoryID, err)
+		}
+	}
+	index, err := newIndex(dir, e.storage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index of repository %s: %w", repositoryID, err)
+	}
+	e.addIndex(repositoryID, index)
+	e.logger.Debug("Created repository index", zap.String("repo_id", repositoryID), zap.String("path", dir))
+	return index, nil
+}
+
+// validRepositoryID reports whether a repository ID can name the directory
+// of its index. IDs name directories, so they must not reach outside.
+func validRepositoryID(repositoryID string) bool {
+	return repositoryID != "" && repositoryID != "." && repositoryID != ".." && !strings.ContainsAny(repositoryID, `/\`)
+}
+
+// dropRepositoryIndex closes the index of a repository, takes it out of
+// searches and deletes its directory. Repositories without an index are
+// ignored.
+func (e *Engine) dropRepositoryIndex(repositoryID string) error {
+	e.indexesMutex.Lock()
+	index, ok := e.indexes[repositoryID]
+	if ok {
+		delete(e.indexes, repositoryID)
+		delete(e.refs, repositoryID)
+		delete(e.namespaces, repositoryID)
+		e.alias.Remove(index)
+	}
+	e.indexesMutex.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if err := index.Close(); err != nil {
+		e.logger.Warn("Failed to close repository index", zap.String("repo_id", repositoryID), zap.Error(err))
+	}
+	if e.indexDir != "" {
+		if err := os.RemoveAll(e.repositoryIndexDir(repositoryID)); err != nil {
+			return fmt.Errorf("failed to remove index of repository %s: %w", repositoryID, err)
+		}
+	}
+	return nil
+}
+
+// allIndexes returns the index of every repository followed by the shared
+// index of an earlier version, if any
+func (e *Engine) allIndexes() []bleve.Index {
+	e.indexesMutex.RLock()
+	defer e.indexesMutex.RUnlock()
+
+	indexes := make([]bleve.Index, 0, len(e.indexes)+1)
+	for _, index := range e.indexes {
+		indexes = append(indexes, index)
+	}
+	if e.legacy != nil {
+		indexes = append(indexes, e.legacy)
+	}
+	return indexes
+}
+
+// repositoryIndexes returns the indexes that can hold documents of a
+// repository: its own index and the shared index of an earlier version
+func (e *Engine) repositoryIndexes(repositoryID string) []bleve.Index {
+	e.indexesMutex.RLock()
+	index, ok := e.indexes[repositoryID]
+	e.indexesMutex.RUnlock()
+
+	var indexes []bleve.Index
+	if ok {
+		indexes = append(indexes, index)
+	}
+	if e.legacy != nil {
+		indexes = append(indexes, e.legacy)
+	}
+	return indexes
+}
+
+// search runs a request against every index in parallel, merging the hits
+// in the order the request sorts by, until ctx is done. Before anything is
+// indexed the result is empty.
+func (e *Engine) search(ctx context.Context, searchRequest *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	searchResult, err := e.alias.SearchInContext(ctx, searchRequest)
+	if err == bleve.ErrorAliasEmpty {
+		return emptyResult(searchRequest), nil
+	}
+	return searchResult, err
+}
+
+// searchRepository runs a request against the indexes that can hold
+// documents of a repository only
+func (e *Engine) searchRepository(ctx context.Context, repositoryID string, searchRequest *bleve.SearchRequest) (*bleve.SearchResult, error) {
+	indexes := e.repositoryIndexes(repositoryID)
+	switch len(indexes) {
+	case 0:
+		return emptyResult(searchRequest), nil
+	case 1:
+		return indexes[0].SearchInContext(ctx, searchRequest)
+	}
+	return bleve.MultiSearch(ctx, searchRequest, indexes...)
+}
+
+// repositoryName returns the name the documents of a repository were
+// indexed under, or "" when it has none or the result cache, which is the
+// only user, is off
+func (e *Engine) repositoryName(repositoryID string) string {
+	if e.cache == nil {
+		return ""
+	}
+
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+	searchRequest := bleve.NewSearchRequest(repoQuery)
+	searchRequest.Size = 1
+	searchRequest.Fields = []string{"repository"}
+
+	searchResult, err := e.searchRepository(context.Background(), repositoryID, searchRequest)
+	if err != nil || len(searchResult.Hits) == 0 {
+		return ""
+	}
+	name, _ := searchResult.Hits[0].Fields["repository"].(string)
+	return name
+}
+
+// emptyResult returns a result without hits for a request
+func emptyResult(searchRequest *bleve.SearchRequest) *bleve.SearchResult {
+	return &bleve.SearchResult{
+		Status:  &bleve.SearchStatus{},
+		Request: searchRequest,
+		Hits:    search.DocumentMatchCollection{},
+	}
+}
@@ -0,0 +1,179 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestRepositoryIndexes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "index")
+	engine, err := NewEngine(dir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, id := range []string{"alpha", "beta"} {
+		file := &types.CodeFile{Path: "main.go", RelativePath: "main.go", Language: "go", Content: "package main // " + id + "\n", Lines: 1}
+		if err := engine.IndexFile(ctx, file, &types.Repository{ID: id, Name: id}); err != nil {
+			t.Fatalf("Failed to index file: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, repositoriesDir, id)); err != nil {
+			t.Errorf("Expected repository %s to have its own index: %v", id, err)
+		}
+	}
+
+	repositories, err := engine.ListRepositories(ctx)
+	if err != nil {
+		t.Fatalf("ListRepositories failed: %v", err)
+	}
+	if len(repositories) != 2 {
+		t.Errorf("Expected both repositories to be searched, got %+v", repositories)
+	}
+
+	if err := engine.DeleteRepository(ctx, "alpha"); err != nil {
+		t.Fatalf("DeleteRepository failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, repositoriesDir, "alpha")); !os.IsNotExist(err) {
+		t.Errorf("Expected the index of the deleted repository to be removed, got %v", err)
+	}
+	engine.Close()
+
+	// The remaining index is opened again
+	engine, err = NewEngine(dir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to reopen engine: %v", err)
+	}
+	defer engine.Close()
+	results, err := engine.Search(ctx, types.SearchQuery{Query: "main", MaxResults: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 1 || results[0].RepositoryID != "beta" {
+		t.Errorf("Expected only the remaining repository to be found, got %+v", results)
+	}
+
+	if err := engine.IndexFile(ctx, &types.CodeFile{RelativePath: "main.go"}, &types.Repository{ID: "../beta"}); err == nil {
+		t.Error("Expected a repository ID naming another directory to be refused")
+	}
+}
+
+func TestRepositoryNamespace(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "index")
+	engine, err := NewEngine(dir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	ctx := context.Background()
+	for _, id := range []string{"app", "lib"} {
+		file := &types.CodeFile{Path: "main.go", RelativePath: "main.go", Language: "go", Content: "package main // " + id + "\n", Lines: 1}
+		if err := engine.IndexFile(ctx, file, &types.Repository{ID: id, Name: id}); err != nil {
+			t.Fatalf("Failed to index file: %v", err)
+		}
+	}
+	if err := engine.SetRepositoryNamespace("lib", types.DependencyNamespace); err != nil {
+		t.Fatalf("SetRepositoryNamespace failed: %v", err)
+	}
+
+	search := func(query types.SearchQuery) []string {
+		query.Query, query.MaxResults = "main", 10
+		results, err := engine.Search(ctx, query)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		var ids []string
+		for _, result := range results {
+			ids = append(ids, result.RepositoryID)
+		}
+		return ids
+	}
+	if ids := search(types.SearchQuery{}); len(ids) != 1 || ids[0] != "app" {
+		t.Errorf("Expected dependency sources to be left out, got %v", ids)
+	}
+	if ids := search(types.SearchQuery{IncludeDeps: true}); len(ids) != 2 {
+		t.Errorf("Expected include_deps to search dependency sources, got %v", ids)
+	}
+	if ids := search(types.SearchQuery{Repository: "lib"}); len(ids) != 1 || ids[0] != "lib" {
+		t.Errorf("Expected a named dependency to be searched, got %v", ids)
+	}
+	engine.Close()
+
+	// The namespace is kept in the index
+	engine, err = NewEngine(dir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to reopen engine: %v", err)
+	}
+	defer engine.Close()
+	if ids := search(types.SearchQuery{}); len(ids) != 1 || ids[0] != "app" {
+		t.Errorf("Expected the namespace to be read back, got %v", ids)
+	}
+	if err := engine.SetRepositoryNamespace("lib", ""); err != nil {
+		t.Fatalf("SetRepositoryNamespace failed: %v", err)
+	}
+	if ids := search(types.SearchQuery{}); len(ids) != 2 {
+		t.Errorf("Expected a repository taken out of the namespace to be searched, got %v", ids)
+	}
+}
+
+func TestEmptyEngineSearch(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	results, err := engine.Search(context.Background(), types.SearchQuery{Query: "main"})
+	if err != nil {
+		t.Fatalf("Expected searching without indexes to succeed, got %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %+v", results)
+	}
+}
+
+func TestLegacyIndex(t *testing.T) {
+	storage := config.DefaultConfig().SearchStorage()
+	dir := filepath.Join(t.TempDir(), "index")
+
+	// A shared index as earlier versions kept in the index directory
+	legacy, err := newIndex(dir, storage)
+	if err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	for _, id := range []string{"alpha", "beta"} {
+		doc := Document{ID: "file:" + id + ":main.go", Type: "file", RepositoryID: id, Repository: id, FilePath: "main.go", Content: "package main", IndexedAt: time.Now()}
+		if err := legacy.Index(doc.ID, doc); err != nil {
+			t.Fatalf("Failed to index document: %v", err)
+		}
+	}
+	legacy.Close()
+
+	engine, err := NewEngineWithStorage(dir, storage, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	ctx := context.Background()
+	if count, err := engine.CountDocuments(ctx, "alpha"); err != nil || count != 1 {
+		t.Errorf("Expected the shared index to be searched, got %d documents (%v)", count, err)
+	}
+	if err := engine.DeleteRepository(ctx, "alpha"); err != nil {
+		t.Fatalf("DeleteRepository failed: %v", err)
+	}
+	if count, _ := engine.CountDocuments(ctx, "alpha"); count != 0 {
+		t.Errorf("Expected the repository to be deleted from the shared index, got %d documents", count)
+	}
+	if count, _ := engine.CountDocuments(ctx, "beta"); count != 1 {
+		t.Errorf("Expected other repositories to stay in the shared index, got %d documents", count)
+	}
+}
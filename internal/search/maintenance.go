@@ -0,0 +1,133 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// ErrCompactionUnsupported is returned by Optimize for indexes without
+// segments to merge, such as in-memory indexes
+var ErrCompactionUnsupported = errors.New("index does not support compaction")
+
+// fileHashesPageSize is the number of file documents read per request when
+// collecting file hashes
+const fileHashesPageSize = 1000
+
+// Optimize merges the segments of every on-disk index into one, which
+// drops the space held by deleted and replaced documents, and reports the
+// number of segments and the size of the index directory before and after
+func (e *Engine) Optimize(ctx context.Context) (*types.IndexOptimization, error) {
+	if e.indexDir == "" {
+		return nil, ErrCompactionUnsupported
+	}
+	var indexes []*scorch.Scorch
+	for _, index := range e.allIndexes() {
+		advanced, err := index.Advanced()
+		if err != nil {
+			return nil, fmt.Errorf("failed to access index internals: %w", err)
+		}
+		if sc, ok := advanced.(*scorch.Scorch); ok {
+			indexes = append(indexes, sc)
+		}
+	}
+
+	startTime := time.Now()
+	result := &types.IndexOptimization{}
+	var err error
+	if result.SizeBefore, err = dirSize(e.indexDir); err != nil {
+		return nil, err
+	}
+
+	for _, sc := range indexes {
+		result.SegmentsBefore += rootSegments(sc)
+		// A nil plan merges everything into a single segment
+		if err := sc.ForceMerge(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to merge index segments: %w", err)
+		}
+		result.SegmentsAfter += rootSegments(sc)
+	}
+
+	if result.SizeAfter, err = dirSize(e.indexDir); err != nil {
+		return nil, err
+	}
+	result.ElapsedSeconds = time.Since(startTime).Seconds()
+	return result, nil
+}
+
+// rootSegments returns the number of file segments the index reads from
+func rootSegments(sc *scorch.Scorch) int {
+	segments, _ := sc.StatsMap()["TotFileSegmentsAtRoot"].(uint64)
+	return int(segments)
+}
+
+// dirSize returns the total size of the files below dir
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			// Segments are removed while merging
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure index size: %w", err)
+	}
+	return size, nil
+}
+
+// FileHashes returns the content hash recorded for every file document of a
+// repository, keyed by path relative to the repository. Files indexed
+// before hashes were recorded map to "".
+func (e *Engine) FileHashes(ctx context.Context, repositoryID string) (map[string]string, error) {
+	typeQuery := bleve.NewTermQuery("file")
+	typeQuery.SetField("type")
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+
+	hashes := make(map[string]string)
+	for from := 0; ; from += fileHashesPageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		searchRequest := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(typeQuery, repoQuery), fileHashesPageSize, from, false)
+		searchRequest.Fields = []string{"file_path", "hash"}
+		searchRequest.SortBy([]string{"_id"})
+
+		searchResult, err := e.searchRepository(ctx, repositoryID, searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for file documents: %w", err)
+		}
+		for _, hit := range searchResult.Hits {
+			filePath, _ := hit.Fields["file_path"].(string)
+			hash, _ := hit.Fields["hash"].(string)
+			if filePath != "" {
+				hashes[filePath] = hash
+			}
+		}
+		if len(searchResult.Hits) < fileHashesPageSize {
+			return hashes, nil
+		}
+	}
+}
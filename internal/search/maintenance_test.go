@@ -0,0 +1,83 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestOptimize(t *testing.T) {
+	engine, err := NewEngine(filepath.Join(t.TempDir(), "index"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	repo := &types.Repository{ID: "repo", Name: "repo"}
+	for n := 0; n < 5; n++ {
+		file := &types.CodeFile{RelativePath: fmt.Sprintf("file%d.go", n), Path: fmt.Sprintf("file%d.go", n), Language: "go", Content: "package main\n", Lines: 1}
+		if err := engine.IndexFile(context.Background(), file, repo); err != nil {
+			t.Fatalf("Failed to index file: %v", err)
+		}
+	}
+
+	result, err := engine.Optimize(context.Background())
+	if err != nil {
+		t.Fatalf("Optimize failed: %v", err)
+	}
+	if result.SizeBefore <= 0 || result.SizeAfter <= 0 {
+		t.Errorf("Expected the index size to be measured, got %+v", result)
+	}
+	if result.SegmentsAfter > 1 {
+		t.Errorf("Expected at most one segment after merging, got %+v", result)
+	}
+
+	memory, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer memory.Close()
+	if _, err := memory.Optimize(context.Background()); !errors.Is(err, ErrCompactionUnsupported) {
+		t.Errorf("Expected in-memory indexes to be refused, got %v", err)
+	}
+}
+
+func TestFileHashes(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	files := map[string]string{"main.go": "abc", "pkg/util.go": "def"}
+	for path, hash := range files {
+		file := &types.CodeFile{RelativePath: path, Path: path, Language: "go", Content: "package main\n", Lines: 1, Hash: hash}
+		if err := engine.IndexFile(context.Background(), file, &types.Repository{ID: "repo", Name: "repo"}); err != nil {
+			t.Fatalf("Failed to index file: %v", err)
+		}
+	}
+	other := &types.CodeFile{RelativePath: "other.go", Path: "other.go", Language: "go", Content: "package other\n", Lines: 1, Hash: "ghi"}
+	if err := engine.IndexFile(context.Background(), other, &types.Repository{ID: "other", Name: "other"}); err != nil {
+		t.Fatalf("Failed to index file: %v", err)
+	}
+
+	hashes, err := engine.FileHashes(context.Background(), "repo")
+	if err != nil {
+		t.Fatalf("FileHashes failed: %v", err)
+	}
+	if len(hashes) != len(files) {
+		t.Fatalf("Expected %d files, got %v", len(files), hashes)
+	}
+	for path, hash := range files {
+		if hashes[path] != hash {
+			t.Errorf("Expected hash %q for %s, got %q", hash, path, hashes[path])
+		}
+	}
+}
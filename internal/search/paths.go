@@ -0,0 +1,140 @@
+package search
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// pathKeywordField indexes the whole relative path of each document as one
+// term, so path filters run as prefix and regexp queries over paths rather
+// than over the words of file_path
+const pathKeywordField = "file_path_keyword"
+
+// pathKeywordMapping maps the keyword variant of file_path. It is only
+// searched; the path is returned from file_path.
+func pathKeywordMapping() *mapping.FieldMapping {
+	fieldMapping := bleve.NewKeywordFieldMapping()
+	fieldMapping.Name = pathKeywordField
+	fieldMapping.Store = false
+	fieldMapping.IncludeInAll = false
+	fieldMapping.IncludeTermVectors = false
+	fieldMapping.DocValues = false
+	return fieldMapping
+}
+
+// pathContainsQuery matches paths containing text anywhere
+func pathContainsQuery(text string) query.Query {
+	wildcardQuery := bleve.NewWildcardQuery("*" + text + "*")
+	wildcardQuery.SetField(pathKeywordField)
+	return wildcardQuery
+}
+
+// pathPrefixQuery matches paths starting with prefix. A trailing ** or *
+// is dropped, so internal/server/** is the prefix internal/server/.
+func pathPrefixQuery(prefix string) query.Query {
+	prefix = strings.TrimPrefix(strings.TrimRight(prefix, "*"), "./")
+	prefixQuery := bleve.NewPrefixQuery(prefix)
+	prefixQuery.SetField(pathKeywordField)
+	return prefixQuery
+}
+
+// pathGlobQuery matches paths against a glob the way grep.MatchGlob does:
+// globs without a slash match the file name at any depth, other globs the
+// whole path, where ** matches any number of directories. A glob naming a
+// directory, such as vendor/**, runs as a prefix query.
+func pathGlobQuery(glob string) query.Query {
+	glob = strings.TrimPrefix(glob, "./")
+	if directory, ok := strings.CutSuffix(glob, "/**"); ok && strings.Contains(glob, "/") && !hasGlobMeta(directory) {
+		directoryQuery := bleve.NewTermQuery(directory)
+		directoryQuery.SetField(pathKeywordField)
+		return bleve.NewDisjunctionQuery(directoryQuery, pathPrefixQuery(directory+"/"))
+	}
+
+	regexpQuery := bleve.NewRegexpQuery(globRegexp(glob))
+	regexpQuery.SetField(pathKeywordField)
+	return regexpQuery
+}
+
+// hasGlobMeta reports whether a glob has wildcards or character classes
+func hasGlobMeta(glob string) bool {
+	return strings.ContainsAny(glob, `*?[\`)
+}
+
+// globRegexp translates a glob into a regular expression matching whole
+// paths
+func globRegexp(glob string) string {
+	if !strings.Contains(glob, "/") {
+		return "(.*/)?" + segmentRegexp(glob)
+	}
+
+	var pattern strings.Builder
+	segments := strings.Split(glob, "/")
+	afterAnyDirectories := false
+	for idx, segment := range segments {
+		last := idx == len(segments)-1
+		switch {
+		case segment == "**" && last:
+			if idx == 0 || afterAnyDirectories {
+				pattern.WriteString(".*")
+			} else {
+				pattern.WriteString("(/.*)?")
+			}
+		case segment == "**":
+			if idx > 0 && !afterAnyDirectories {
+				pattern.WriteString("/")
+			}
+			pattern.WriteString("(.*/)?")
+			afterAnyDirectories = true
+			continue
+		default:
+			if idx > 0 && !afterAnyDirectories {
+				pattern.WriteString("/")
+			}
+			pattern.WriteString(segmentRegexp(segment))
+		}
+		afterAnyDirectories = false
+	}
+	return pattern.String()
+}
+
+// segmentRegexp translates one path segment of a glob, in which * and ?
+// never match a slash
+func segmentRegexp(segment string) string {
+	var pattern strings.Builder
+	for idx := 0; idx < len(segment); {
+		r, size := utf8.DecodeRuneInString(segment[idx:])
+		switch r {
+		case '*':
+			pattern.WriteString("[^/]*")
+		case '?':
+			pattern.WriteString("[^/]")
+		case '\\':
+			if next, nextSize := utf8.DecodeRuneInString(segment[idx+size:]); nextSize > 0 {
+				pattern.WriteString(regexp.QuoteMeta(string(next)))
+				size += nextSize
+			}
+		case '[':
+			end := strings.IndexByte(segment[idx+1:], ']')
+			if end < 0 {
+				pattern.WriteString(`\[`)
+				break
+			}
+			class := segment[idx+1 : idx+1+end]
+			if strings.HasPrefix(class, "^") {
+				// Segments never hold a slash, so neither may a negated class
+				class += "/"
+			}
+			pattern.WriteString("[" + class + "]")
+			size = end + 2
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(r)))
+		}
+		idx += size
+	}
+	return pattern.String()
+}
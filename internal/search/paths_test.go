@@ -0,0 +1,96 @@
+package search
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"sort"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/grep"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestGlobRegexpMatchesLikeMatchGlob(t *testing.T) {
+	globs := []string{
+		"*_test.go", "**/testdata/**", "internal/*/engine.go", "internal/**/*.go",
+		"vendor/**", "docs/**/*.md", "cmd/server/main.go", "[a-c]*.go", "[^a-c]*.go", "?.go",
+	}
+	paths := []string{
+		"engine_test.go", "internal/search/engine_test.go", "internal/search/engine.go",
+		"internal/search/testdata/sample.go", "testdata/sample.go", "vendor/lib/lib.go",
+		"vendor", "docs/TOOLS.md", "docs/api/v1/index.md", "cmd/server/main.go",
+		"a.go", "ab.go", "d.go", "internal/engine.go",
+	}
+	for _, glob := range globs {
+		re := regexp.MustCompile("^(?:" + globRegexp(glob) + ")$")
+		for _, path := range paths {
+			if got, want := re.MatchString(path), grep.MatchGlob(glob, path); got != want {
+				t.Errorf("glob %q on %q: regexp %v, MatchGlob %v", glob, path, got, want)
+			}
+		}
+	}
+}
+
+func TestSearchWithinPathScope(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	paths := []string{
+		"internal/server/handlers.go",
+		"internal/server/handlers_test.go",
+		"internal/server/testdata/sample.go",
+		"internal/search/engine.go",
+		"cmd/internal/server/main.go",
+	}
+	for _, path := range paths {
+		file := &types.CodeFile{
+			Path: path, RelativePath: path, Language: "go", Lines: 1,
+			Content: "package server // handler",
+		}
+		if err := engine.IndexFile(context.Background(), file, &types.Repository{ID: "repo", Name: "repo"}); err != nil {
+			t.Fatalf("Failed to index %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query types.SearchQuery
+		want  []string
+	}{
+		{"prefix", types.SearchQuery{PathPrefix: "internal/server/"}, paths[:3]},
+		{"prefix glob", types.SearchQuery{PathPrefix: "internal/server/**"}, paths[:3]},
+		{"excluded globs", types.SearchQuery{ExcludePaths: []string{"**/testdata/**", "*_test.go"}}, []string{paths[0], paths[3], paths[4]}},
+		{"prefix and excluded", types.SearchQuery{PathPrefix: "internal/", ExcludePaths: []string{"internal/server/**"}}, paths[3:4]},
+		{"path qualifier glob", types.SearchQuery{Query: "handler path:internal/*/engine.go", Syntax: true}, paths[3:4]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Type = "file"
+			if tt.query.Query == "" {
+				tt.query.Query = "handler"
+			}
+			tt.query.MaxResults = 10
+			results, err := engine.Search(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			var found []string
+			for _, result := range results {
+				found = append(found, result.FilePath)
+			}
+			sort.Strings(found)
+			want := slices.Clone(tt.want)
+			sort.Strings(want)
+			if !slices.Equal(found, want) {
+				t.Errorf("Expected %v, got %v", want, found)
+			}
+		})
+	}
+}
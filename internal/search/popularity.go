@@ -0,0 +1,70 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// unreferencedTypes are the document types that carry no reference count
+var unreferencedTypes = []string{"file", "comment", "section", "config_key", "chunk", "reference", "security_finding"}
+
+// popularityBoost returns the factor the score of a symbol referenced
+// references times is multiplied by. The boost grows logarithmically so a
+// handful of heavily used helpers cannot drown out better text matches.
+func popularityBoost(references int, weight float64) float64 {
+	if weight <= 0 || references <= 0 {
+		return 1
+	}
+	return 1 + weight*math.Log1p(float64(references))
+}
+
+// SymbolReferenceCounts returns the reference counts stored on the symbol
+// documents of a repository, keyed by relative file path and symbol name
+func (e *Engine) SymbolReferenceCounts(ctx context.Context, repositoryID string) (map[string]map[string]int, error) {
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+	excluded := make([]query.Query, 0, len(unreferencedTypes))
+	for _, docType := range unreferencedTypes {
+		typeQuery := bleve.NewTermQuery(docType)
+		typeQuery.SetField("type")
+		excluded = append(excluded, typeQuery)
+	}
+	symbolQuery := bleve.NewBooleanQuery()
+	symbolQuery.AddMust(repoQuery)
+	symbolQuery.AddMustNot(excluded...)
+
+	counts := make(map[string]map[string]int)
+	for from := 0; ; from += fileHashesPageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		searchRequest := bleve.NewSearchRequestOptions(symbolQuery, fileHashesPageSize, from, false)
+		searchRequest.Fields = []string{"file_path", "name", "reference_count"}
+		searchRequest.SortBy([]string{"_id"})
+
+		searchResult, err := e.searchRepository(ctx, repositoryID, searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for symbol documents: %w", err)
+		}
+		for _, hit := range searchResult.Hits {
+			filePath, _ := hit.Fields["file_path"].(string)
+			name, _ := hit.Fields["name"].(string)
+			references, _ := hit.Fields["reference_count"].(float64)
+			if filePath == "" || name == "" {
+				continue
+			}
+			if counts[filePath] == nil {
+				counts[filePath] = make(map[string]int)
+			}
+			counts[filePath][name] = int(references)
+		}
+		if len(searchResult.Hits) < fileHashesPageSize {
+			return counts, nil
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestPopularityBoost(t *testing.T) {
+	if boost := popularityBoost(100, 0); boost != 1 {
+		t.Errorf("Expected no boost with a zero weight, got %v", boost)
+	}
+	if boost := popularityBoost(0, 0.5); boost != 1 {
+		t.Errorf("Expected no boost without references, got %v", boost)
+	}
+	if popularityBoost(100, 0.1) <= popularityBoost(10, 0.1) {
+		t.Error("Expected more references to give a larger boost")
+	}
+	if boost := popularityBoost(1000000, 0.1); boost > 3 {
+		t.Errorf("Expected the boost to grow logarithmically, got %v", boost)
+	}
+}
+
+func TestSearchPageRanksByPopularityBeforePaging(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	repo := &types.Repository{ID: "repo", Name: "repo"}
+	references := map[string]int{"a.go": 0, "b.go": 50, "c.go": 5}
+	for path, count := range references {
+		file := &types.CodeFile{
+			Path:         path,
+			RelativePath: path,
+			Language:     "go",
+			Lines:        1,
+			Functions: []types.Function{
+				{Name: "load", Signature: "func load()", StartLine: 1, EndLine: 1, ReferenceCount: count},
+			},
+		}
+		if err := engine.IndexFile(context.Background(), file, repo); err != nil {
+			t.Fatalf("Failed to index %s: %v", path, err)
+		}
+	}
+
+	// One result per page: popularity must order the whole result set, not
+	// each page on its own
+	var order []string
+	query := types.SearchQuery{Query: "load", Type: "function", MaxResults: 1, PopularityWeight: 0.1}
+	for page := 0; page < len(references); page++ {
+		result, err := engine.SearchPage(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		if len(result.Results) != 1 {
+			t.Fatalf("Expected 1 result on page %d, got %d", page, len(result.Results))
+		}
+		order = append(order, result.Results[0].FilePath)
+		if result.NextCursor == "" {
+			break
+		}
+		if query.Offset, err = DecodeCursor(result.NextCursor); err != nil {
+			t.Fatalf("Invalid cursor: %v", err)
+		}
+	}
+	if got := fmt.Sprint(order); got != "[b.go c.go a.go]" {
+		t.Errorf("Expected the most referenced symbols first across pages, got %s", got)
+	}
+}
@@ -0,0 +1,64 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// ListProjects returns the sub-projects the files of a repository were
+// indexed under, ordered by path, with their file counts and languages.
+// Files outside any sub-project are not counted; repositories indexed
+// before sub-projects were recorded have none.
+func (e *Engine) ListProjects(ctx context.Context, repositoryID string) ([]types.Project, error) {
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+	fileQuery := bleve.NewTermQuery("file")
+	fileQuery.SetField("type")
+
+	searchRequest := bleve.NewSearchRequest(bleve.NewConjunctionQuery(repoQuery, fileQuery))
+	searchRequest.Size = 10000 // Large number to get all files
+	searchRequest.Fields = []string{"project", "language"}
+
+	searchResult, err := e.searchRepository(ctx, repositoryID, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for projects: %w", err)
+	}
+
+	projects := make(map[string]*types.Project)
+	languages := make(map[string]map[string]bool)
+	for _, hit := range searchResult.Hits {
+		path, _ := hit.Fields["project"].(string)
+		if path == "" {
+			continue
+		}
+		project, ok := projects[path]
+		if !ok {
+			project = &types.Project{Path: path}
+			projects[path] = project
+			languages[path] = make(map[string]bool)
+		}
+		project.Files++
+		if language, _ := hit.Fields["language"].(string); language != "" {
+			languages[path][language] = true
+		}
+	}
+
+	result := make([]types.Project, 0, len(projects))
+	for path, project := range projects {
+		project.Languages = make([]string, 0, len(languages[path]))
+		for language := range languages[path] {
+			project.Languages = append(project.Languages, language)
+		}
+		sort.Strings(project.Languages)
+		result = append(result, *project)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Path < result[b].Path
+	})
+	return result, nil
+}
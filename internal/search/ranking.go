@@ -0,0 +1,204 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// rankingCandidates is the number of best text matches re-ranked by type,
+// recency and popularity. Matches below them follow in text order, so every
+// page of a query is cut from the same ranking and pages never overlap.
+const rankingCandidates = 1000
+
+// recentProfileWeight is the smallest recency weight of the recent profile
+const recentProfileWeight = 0.5
+
+// nonDefinitionTypes are the document types the symbols profile ranks
+// further below definitions
+var nonDefinitionTypes = map[string]bool{"file": true, "chunk": true, "comment": true, "section": true}
+
+// profileRanking returns the boosts of the query's scoring profile and the
+// popularity weight it ranks with. Unknown profiles rank like the default.
+func profileRanking(searchQuery types.SearchQuery) (types.Ranking, float64) {
+	ranking := searchQuery.Ranking
+	switch searchQuery.Profile {
+	case types.ProfileText:
+		return types.Ranking{}, 0
+	case types.ProfileSymbols:
+		ranking.NameBoost = boostOrOne(ranking.NameBoost) * 2
+		ranking.ExactNameBoost = boostOrOne(ranking.ExactNameBoost) * 2
+		typeBoosts := make(map[string]float64, len(ranking.TypeBoosts)+len(nonDefinitionTypes))
+		for docType, boost := range ranking.TypeBoosts {
+			typeBoosts[docType] = boost
+		}
+		for docType := range nonDefinitionTypes {
+			typeBoosts[docType] = boostOrOne(typeBoosts[docType]) / 2
+		}
+		ranking.TypeBoosts = typeBoosts
+	case types.ProfileRecent:
+		ranking.RecencyWeight = math.Max(ranking.RecencyWeight, recentProfileWeight)
+	}
+	return ranking, searchQuery.PopularityWeight
+}
+
+// boostOrOne returns boost, or 1 when it is not set
+func boostOrOne(boost float64) float64 {
+	if boost <= 0 {
+		return 1
+	}
+	return boost
+}
+
+// textQuery matches the query text in names, paths and content, weighing
+// each field by its boost. Names containing the whole query as a phrase
+// score ExactNameBoost times higher again, so an exact name match ranks
+// above a name that merely shares a word with it.
+func textQuery(text string, ranking types.Ranking) query.Query {
+	contentMatchQuery := bleve.NewMatchQuery(text)
+	contentMatchQuery.SetField("content")
+	contentMatchQuery.SetBoost(boostOrOne(ranking.ContentBoost))
+
+	nameMatchQuery := bleve.NewMatchQuery(text)
+	nameMatchQuery.SetField("name")
+	nameMatchQuery.SetBoost(boostOrOne(ranking.NameBoost))
+
+	pathMatchQuery := bleve.NewMatchQuery(text)
+	pathMatchQuery.SetField("file_path")
+	pathMatchQuery.SetBoost(boostOrOne(ranking.PathBoost))
+
+	fieldQueries := []query.Query{contentMatchQuery, nameMatchQuery, pathMatchQuery}
+	if ranking.ExactNameBoost > 0 {
+		exactNameQuery := bleve.NewMatchPhraseQuery(text)
+		exactNameQuery.SetField("name")
+		exactNameQuery.SetBoost(boostOrOne(ranking.NameBoost) * ranking.ExactNameBoost)
+		fieldQueries = append(fieldQueries, exactNameQuery)
+	}
+	return bleve.NewDisjunctionQuery(fieldQueries...)
+}
+
+// recencyBoost returns the factor the score of a document whose file was
+// modified at modifiedAt is multiplied by. The boost is 1+weight for a file
+// modified now and halves towards 1 every halfLife.
+func recencyBoost(modifiedAt, now time.Time, weight float64, halfLife time.Duration) float64 {
+	if weight <= 0 || halfLife <= 0 || modifiedAt.IsZero() {
+		return 1
+	}
+	age := now.Sub(modifiedAt)
+	if age < 0 {
+		age = 0
+	}
+	return 1 + weight*math.Exp2(-float64(age)/float64(halfLife))
+}
+
+// reranks reports whether results of a query with these boosts are
+// re-ranked after the text search
+func reranks(ranking types.Ranking, popularityWeight float64) bool {
+	return popularityWeight > 0 || len(ranking.TypeBoosts) > 0 || ranking.RecencyWeight > 0
+}
+
+// hitBoost returns the factor a text match is boosted by, from the type,
+// modified_at and reference_count fields of the hit
+func hitBoost(ranking types.Ranking, popularityWeight float64, now time.Time) func(hit *search.DocumentMatch) float64 {
+	return func(hit *search.DocumentMatch) float64 {
+		boost := 1.0
+		if docType, ok := hit.Fields["type"].(string); ok {
+			if typeBoost, ok := ranking.TypeBoosts[docType]; ok {
+				boost *= typeBoost
+			}
+		}
+		if value, ok := hit.Fields["modified_at"].(string); ok {
+			if modifiedAt, err := time.Parse(time.RFC3339, value); err == nil {
+				boost *= recencyBoost(modifiedAt, now, ranking.RecencyWeight, ranking.RecencyHalfLife)
+			}
+		}
+		references, _ := hit.Fields["reference_count"].(float64)
+		return boost * popularityBoost(int(references), popularityWeight)
+	}
+}
+
+// rankedHit is a text match with its boosted score
+type rankedHit struct {
+	id    string
+	score float64
+}
+
+// rankedPage returns the page of size results starting at offset, with the
+// best text matches ranked by their boosted score before the page is cut.
+// Only matching documents are re-ranked, so boosts decide the order among
+// relevant matches rather than pulling in unrelated documents.
+func (e *Engine) rankedPage(ctx context.Context, searchQuery query.Query, offset, size int, boost func(hit *search.DocumentMatch) float64) (*types.SearchPage, error) {
+	// Rank the candidates on their scores and the fields boosts are read
+	// from alone, without loading or highlighting the documents
+	rankRequest := bleve.NewSearchRequestOptions(searchQuery, rankingCandidates, 0, false)
+	rankRequest.SortBy([]string{"-_score", "_id"})
+	rankRequest.Fields = []string{"type", "modified_at", "reference_count"}
+	rankResult, err := e.search(ctx, rankRequest)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	ranked := make([]rankedHit, 0, len(rankResult.Hits))
+	for _, hit := range rankResult.Hits {
+		ranked = append(ranked, rankedHit{id: hit.ID, score: hit.Score * boost(hit)})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+
+	end := offset + size
+	if end > len(ranked) {
+		end = len(ranked)
+	}
+	var pageHits []rankedHit
+	if offset < end {
+		pageHits = ranked[offset:end]
+	}
+
+	page := &types.SearchPage{Total: int(rankResult.Total)}
+	if len(pageHits) > 0 {
+		ids := make([]string, len(pageHits))
+		for idx, hit := range pageHits {
+			ids[idx] = hit.id
+		}
+		results, _, err := e.fetchResults(ctx, bleve.NewConjunctionQuery(searchQuery, bleve.NewDocIDQuery(ids)), 0, len(ids))
+		if err != nil {
+			return nil, err
+		}
+
+		// Put the documents back in ranked order with their boosted scores
+		byID := make(map[string]types.SearchResult, len(results))
+		for _, result := range results {
+			byID[result.ID] = result
+		}
+		for _, hit := range pageHits {
+			if result, ok := byID[hit.id]; ok {
+				result.Score = hit.score
+				page.Results = append(page.Results, result)
+			}
+		}
+	}
+	returned := len(pageHits)
+
+	// A page reaching past the candidates continues in text order
+	if offset+size > rankingCandidates && page.Total > rankingCandidates {
+		from := offset + returned
+		results, searchResult, err := e.fetchResults(ctx, searchQuery, from, offset+size-from)
+		if err != nil {
+			return nil, err
+		}
+		page.Results = append(page.Results, results...)
+		returned += len(searchResult.Hits)
+	}
+
+	page.NextCursor = nextCursor(offset, returned, page.Total)
+	return page, nil
+}
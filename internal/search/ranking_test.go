@@ -0,0 +1,116 @@
+package search
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestRecencyBoost(t *testing.T) {
+	now := time.Now()
+	halfLife := 24 * time.Hour
+	if boost := recencyBoost(now, now, 0, halfLife); boost != 1 {
+		t.Errorf("Expected no boost with a zero weight, got %v", boost)
+	}
+	if boost := recencyBoost(time.Time{}, now, 1, halfLife); boost != 1 {
+		t.Errorf("Expected no boost without a modification time, got %v", boost)
+	}
+	if boost := recencyBoost(now, now, 0.5, halfLife); boost != 1.5 {
+		t.Errorf("Expected a file modified now to be boosted by the full weight, got %v", boost)
+	}
+	if boost := recencyBoost(now.Add(-halfLife), now, 0.5, halfLife); boost != 1.25 {
+		t.Errorf("Expected the boost to halve after a half-life, got %v", boost)
+	}
+}
+
+func TestProfileRanking(t *testing.T) {
+	query := types.SearchQuery{
+		Ranking:          types.Ranking{NameBoost: 3, TypeBoosts: map[string]float64{"file": 0.5}},
+		PopularityWeight: 0.1,
+	}
+
+	query.Profile = types.ProfileText
+	if ranking, weight := profileRanking(query); reranks(ranking, weight) || ranking.NameBoost != 0 {
+		t.Errorf("Expected the text profile to rank without boosts, got %+v and %v", ranking, weight)
+	}
+
+	query.Profile = types.ProfileSymbols
+	ranking, _ := profileRanking(query)
+	if ranking.NameBoost != 6 || ranking.TypeBoosts["file"] != 0.25 || ranking.TypeBoosts["comment"] != 0.5 {
+		t.Errorf("Expected the symbols profile to favour names and definitions, got %+v", ranking)
+	}
+	if query.Ranking.TypeBoosts["file"] != 0.5 {
+		t.Error("Expected the symbols profile not to change the configured type boosts")
+	}
+
+	query.Profile = types.ProfileRecent
+	if ranking, _ := profileRanking(query); ranking.RecencyWeight != recentProfileWeight {
+		t.Errorf("Expected the recent profile to boost recent files, got %v", ranking.RecencyWeight)
+	}
+}
+
+func TestSearchPageRanking(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	repo := &types.Repository{ID: "repo", Name: "repo"}
+	now := time.Now()
+	files := []*types.CodeFile{
+		{
+			Path: "a.go", RelativePath: "a.go", Language: "go", Lines: 2,
+			Content:    "// render the page\nfunc render() {}",
+			ModifiedAt: now.AddDate(-1, 0, 0),
+			Functions:  []types.Function{{Name: "render", Signature: "func render()", StartLine: 2, EndLine: 2}},
+		},
+		{
+			Path: "b.go", RelativePath: "b.go", Language: "go", Lines: 2,
+			Content:    "// render the page\nfunc renderPage() {}",
+			ModifiedAt: now,
+			Functions:  []types.Function{{Name: "renderPage", Signature: "func renderPage()", StartLine: 2, EndLine: 2}},
+		},
+	}
+	for _, file := range files {
+		if err := engine.IndexFile(context.Background(), file, repo); err != nil {
+			t.Fatalf("Failed to index %s: %v", file.Path, err)
+		}
+	}
+
+	ranking := config.DefaultConfig().Search.Ranking
+	query := types.SearchQuery{
+		Query:      "render",
+		MaxResults: 10,
+		Ranking: types.Ranking{
+			NameBoost:       ranking.NameBoost,
+			ExactNameBoost:  ranking.ExactNameBoost,
+			ContentBoost:    ranking.ContentBoost,
+			PathBoost:       ranking.PathBoost,
+			TypeBoosts:      ranking.TypeBoosts,
+			RecencyHalfLife: 30 * 24 * time.Hour,
+		},
+	}
+	page, err := engine.SearchPage(context.Background(), query)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(page.Results) == 0 || page.Results[0].Type != "function" || page.Results[0].Name != "render" {
+		t.Fatalf("Expected the exactly named function first, got %+v", page.Results)
+	}
+
+	// Both files match equally well, so only recency puts b.go first
+	query.Profile = types.ProfileRecent
+	query.Type = "file"
+	if page, err = engine.SearchPage(context.Background(), query); err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(page.Results) == 0 || page.Results[0].FilePath != "b.go" {
+		t.Errorf("Expected the recently modified file first, got %+v", page.Results)
+	}
+}
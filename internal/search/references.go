@@ -0,0 +1,140 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// maxReferenceHits bounds the reference documents read for one query
+const maxReferenceHits = 10000
+
+// FindReferences returns a page of the indexed references to a symbol name,
+// or those made from within a calling function, ordered by file and
+// position. Names are analyzed in the index, so hits are filtered for exact,
+// case-sensitive matches. With a repository, the references of the files
+// listed in Importers are found as well.
+func (e *Engine) FindReferences(ctx context.Context, refQuery types.ReferenceQuery) (*types.ReferencePage, error) {
+	if refQuery.Name == "" && refQuery.Caller == "" {
+		return nil, fmt.Errorf("a symbol name or caller is required")
+	}
+
+	typeQuery := bleve.NewTermQuery("reference")
+	typeQuery.SetField("type")
+	queries := []query.Query{typeQuery}
+
+	if refQuery.Name != "" {
+		nameQuery := bleve.NewMatchQuery(refQuery.Name)
+		nameQuery.SetField("name")
+		nameQuery.SetOperator(query.MatchQueryOperatorAnd)
+		queries = append(queries, nameQuery)
+	}
+	if refQuery.Caller != "" {
+		callerQuery := bleve.NewMatchQuery(refQuery.Caller)
+		callerQuery.SetField("metadata.caller")
+		callerQuery.SetOperator(query.MatchQueryOperatorAnd)
+		queries = append(queries, callerQuery)
+	}
+	if refQuery.Repository != "" {
+		repositories := []string{refQuery.Repository}
+		for repository := range refQuery.Importers {
+			repositories = append(repositories, repository)
+		}
+		queries = append(queries, anyTermQuery("repository", repositories))
+	}
+
+	searchRequest := bleve.NewSearchRequest(bleve.NewConjunctionQuery(queries...))
+	searchRequest.Size = maxReferenceHits
+	searchRequest.Fields = []string{"*"}
+
+	searchResult, err := e.search(ctx, searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search references: %w", err)
+	}
+
+	kinds := make(map[string]bool, len(refQuery.Kinds))
+	for _, kind := range refQuery.Kinds {
+		kinds[kind] = true
+	}
+
+	results := make([]types.ReferenceResult, 0, len(searchResult.Hits))
+	for _, hit := range searchResult.Hits {
+		result := e.extractReference(hit)
+		if refQuery.Name != "" && result.Name != refQuery.Name {
+			continue
+		}
+		if refQuery.Caller != "" && result.Caller != refQuery.Caller {
+			continue
+		}
+		if len(kinds) > 0 && !kinds[result.Kind] {
+			continue
+		}
+		if refQuery.Repository != "" && result.Repository != refQuery.Repository && !slices.Contains(refQuery.Importers[result.Repository], result.FilePath) {
+			continue
+		}
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Repository != results[b].Repository {
+			return results[a].Repository < results[b].Repository
+		}
+		if results[a].FilePath != results[b].FilePath {
+			return results[a].FilePath < results[b].FilePath
+		}
+		if results[a].Line != results[b].Line {
+			return results[a].Line < results[b].Line
+		}
+		return results[a].Column < results[b].Column
+	})
+
+	total := len(results)
+	offset := min(max(refQuery.Offset, 0), total)
+	results = results[offset:]
+	if refQuery.MaxResults > 0 && len(results) > refQuery.MaxResults {
+		results = results[:refQuery.MaxResults]
+	}
+
+	return &types.ReferencePage{
+		References: results,
+		Total:      total,
+		NextCursor: nextCursor(offset, len(results), total),
+	}, nil
+}
+
+// extractReference extracts reference data from a search hit. Metadata of
+// reference documents is read from its flattened field names.
+func (e *Engine) extractReference(hit *search.DocumentMatch) types.ReferenceResult {
+	result := types.ReferenceResult{}
+
+	stringField := func(name string) string {
+		value, _ := hit.Fields[name].(string)
+		return value
+	}
+
+	result.RepositoryID = stringField("repository_id")
+	result.Repository = stringField("repository")
+	result.FilePath = stringField("file_path")
+	result.Language = stringField("language")
+	result.Context = stringField("content")
+	result.Name = stringField("name")
+	result.Kind = stringField("metadata.kind")
+	result.Qualifier = stringField("metadata.qualifier")
+	result.Caller = stringField("metadata.caller")
+	result.Target = stringField("metadata.target")
+	if line, ok := hit.Fields["start_line"].(float64); ok {
+		result.Line = int(line)
+	}
+	if column, ok := hit.Fields["metadata.column"].(float64); ok {
+		result.Column = int(column)
+	}
+
+	return result
+}
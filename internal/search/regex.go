@@ -0,0 +1,312 @@
+package search
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+const (
+	// maxRegexMatches bounds the matches collected for one regex search
+	maxRegexMatches = 10000
+
+	// regexCandidateBatch is the number of candidate files read at a time
+	regexCandidateBatch = 200
+
+	// maxRegexLineColumns bounds the line text returned with each match
+	maxRegexLineColumns = 300
+)
+
+// SearchRegex finds the lines of indexed files matching the regular
+// expression in query.Query, honouring the language, repository, ref and
+// file path filters. Files that cannot contain a match are ruled out with the
+// trigram index; the remaining candidates are matched line by line, so
+// patterns never match across lines. Empty matches are skipped. The page of
+// MaxResults matches starting at Offset is returned.
+func (e *Engine) SearchRegex(ctx context.Context, searchQuery types.SearchQuery) (*types.RegexSearchResult, error) {
+	re, err := regexp.Compile(searchQuery.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	parsed, err := syntax.Parse(searchQuery.Query, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	fileQuery := searchQuery
+	fileQuery.Query = ""
+	fileQuery.Type = "file"
+	fileQuery.Types = nil
+	candidates := e.buildSearchQuery(fileQuery)
+
+	result := &types.RegexSearchResult{Pattern: searchQuery.Query}
+	if filter := regexFilter(parsed.Simplify()); filter != nil && e.trigramsIndexed() {
+		candidates = bleve.NewConjunctionQuery(candidates, filter.query())
+		result.TrigramFiltered = true
+	}
+
+	var matches []types.RegexMatch
+	for from := 0; !result.Truncated; from += regexCandidateBatch {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		searchRequest := bleve.NewSearchRequestOptions(candidates, regexCandidateBatch, from, false)
+		searchRequest.Fields = []string{"repository_id", "repository", "file_path", "language", "content", "end_line"}
+		searchRequest.SortBy([]string{"_id"})
+
+		searchResult, err := e.search(ctx, searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search regex candidates: %w", err)
+		}
+
+		for _, hit := range searchResult.Hits {
+			content, ok := hit.Fields["content"].(string)
+			if !ok {
+				// Empty files have no content to store
+				if lines, _ := hit.Fields["end_line"].(float64); lines > 0 {
+					result.FilesUnverified++
+				}
+				continue
+			}
+			result.FilesScanned++
+
+			file := types.RegexMatch{}
+			file.RepositoryID, _ = hit.Fields["repository_id"].(string)
+			file.Repository, _ = hit.Fields["repository"].(string)
+			file.FilePath, _ = hit.Fields["file_path"].(string)
+			file.Language, _ = hit.Fields["language"].(string)
+
+			matches = appendLineMatches(matches, re, file, content)
+			if len(matches) >= maxRegexMatches {
+				matches = matches[:maxRegexMatches]
+				result.Truncated = true
+				break
+			}
+		}
+
+		if len(searchResult.Hits) < regexCandidateBatch {
+			break
+		}
+	}
+
+	sort.SliceStable(matches, func(a, b int) bool {
+		if matches[a].Repository != matches[b].Repository {
+			return matches[a].Repository < matches[b].Repository
+		}
+		return matches[a].FilePath < matches[b].FilePath
+	})
+
+	result.Total = len(matches)
+	offset := min(max(searchQuery.Offset, 0), len(matches))
+	matches = matches[offset:]
+	if searchQuery.MaxResults > 0 && len(matches) > searchQuery.MaxResults {
+		matches = matches[:searchQuery.MaxResults]
+	}
+	result.Matches = matches
+	result.NextCursor = nextCursor(offset, len(matches), result.Total)
+
+	e.logger.Info("Regex search completed",
+		zap.String("pattern", searchQuery.Query),
+		zap.Bool("trigram_filtered", result.TrigramFiltered),
+		zap.Int("files_scanned", result.FilesScanned),
+		zap.Int("matches", result.Total))
+
+	return result, nil
+}
+
+// appendLineMatches appends the matches of a regular expression on each line
+// of a file's content, with the file fields taken from file
+func appendLineMatches(matches []types.RegexMatch, re *regexp.Regexp, file types.RegexMatch, content string) []types.RegexMatch {
+	for idx, line := range textpos.SplitLines(content) {
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			match := file
+			match.Line = idx + 1
+			match.Column = textpos.Column(line, loc[0])
+			match.EndColumn = textpos.Column(line, loc[1])
+			match.Text = line[loc[0]:loc[1]]
+			match.LineText = textpos.Truncate(line, maxRegexLineColumns)
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// fileTrigrams returns the distinct trigrams of a file's content. Content is
+// lower-cased so case-insensitive patterns can use the same index, and each
+// trigram is hex-encoded so terms stay valid UTF-8 when a trigram splits a
+// multi-byte character.
+func fileTrigrams(content string) []string {
+	lowered := strings.ToLower(content)
+	seen := make(map[string]bool)
+	trigrams := make([]string, 0)
+	for idx := 0; idx+3 <= len(lowered); idx++ {
+		trigram := lowered[idx : idx+3]
+		if !seen[trigram] {
+			seen[trigram] = true
+			trigrams = append(trigrams, hex.EncodeToString([]byte(trigram)))
+		}
+	}
+	return trigrams
+}
+
+// hasTrigramField reports whether an index maps the trigram field. Indexes
+// created before regex search existed lack it and are scanned in full.
+func hasTrigramField(indexMapping mapping.IndexMapping) bool {
+	impl, ok := indexMapping.(*mapping.IndexMappingImpl)
+	if !ok || impl.DefaultMapping == nil {
+		return false
+	}
+	_, ok = impl.DefaultMapping.Properties["trigrams"]
+	return ok
+}
+
+// trigramsIndexed reports whether every index maps the trigrams field, so
+// candidates can be narrowed down by trigram in all of them
+func (e *Engine) trigramsIndexed() bool {
+	indexes := e.allIndexes()
+	for _, index := range indexes {
+		if !hasTrigramField(index.Mapping()) {
+			return false
+		}
+	}
+	return len(indexes) > 0
+}
+
+// trigramFilter is a boolean combination of trigrams that every file
+// matching a pattern contains: all trigrams and sub-filters, or any of the
+// sub-filters. A nil filter rules out no file.
+type trigramFilter struct {
+	any      bool
+	trigrams []string
+	subs     []*trigramFilter
+}
+
+// regexFilter derives the trigram filter of a simplified regular expression
+// from the literal strings every match must contain
+func regexFilter(re *syntax.Regexp) *trigramFilter {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return literalFilter(re.Rune, re.Flags)
+
+	case syntax.OpConcat:
+		// Adjacent literals form one longer string with more trigrams; a run
+		// is treated as case-insensitive if any of its literals is
+		var filters []*trigramFilter
+		var run []rune
+		var runFlags syntax.Flags
+		for _, sub := range re.Sub {
+			if sub.Op == syntax.OpLiteral {
+				run = append(run, sub.Rune...)
+				runFlags |= sub.Flags & syntax.FoldCase
+				continue
+			}
+			filters = append(filters, literalFilter(run, runFlags), regexFilter(sub))
+			run, runFlags = nil, 0
+		}
+		filters = append(filters, literalFilter(run, runFlags))
+		return allOf(filters)
+
+	case syntax.OpAlternate:
+		filters := make([]*trigramFilter, 0, len(re.Sub))
+		for _, sub := range re.Sub {
+			filter := regexFilter(sub)
+			if filter == nil {
+				return nil
+			}
+			filters = append(filters, filter)
+		}
+		return &trigramFilter{any: true, subs: filters}
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return regexFilter(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return regexFilter(re.Sub[0])
+		}
+	}
+	return nil
+}
+
+// literalFilter requires the trigrams of a literal string. Case-insensitive
+// literals are split at runes with more than one other case, such as the
+// Kelvin sign folding to k and K, since lower-casing does not unify them.
+func literalFilter(runes []rune, flags syntax.Flags) *trigramFilter {
+	var filters []*trigramFilter
+	start := 0
+	for idx, r := range runes {
+		if flags&syntax.FoldCase != 0 && unicode.SimpleFold(unicode.SimpleFold(r)) != r {
+			filters = append(filters, stringFilter(string(runes[start:idx])))
+			start = idx + 1
+		}
+	}
+	filters = append(filters, stringFilter(string(runes[start:])))
+	return allOf(filters)
+}
+
+// stringFilter requires the trigrams of a string, or nothing for strings
+// shorter than a trigram
+func stringFilter(literal string) *trigramFilter {
+	trigrams := fileTrigrams(literal)
+	if len(trigrams) == 0 {
+		return nil
+	}
+	return &trigramFilter{trigrams: trigrams}
+}
+
+// allOf combines filters that must all hold, ignoring nil ones
+func allOf(filters []*trigramFilter) *trigramFilter {
+	combined := &trigramFilter{}
+	for _, filter := range filters {
+		switch {
+		case filter == nil:
+		case filter.any:
+			combined.subs = append(combined.subs, filter)
+		default:
+			combined.trigrams = append(combined.trigrams, filter.trigrams...)
+			combined.subs = append(combined.subs, filter.subs...)
+		}
+	}
+	if len(combined.trigrams) == 0 && len(combined.subs) == 0 {
+		return nil
+	}
+	return combined
+}
+
+// query converts a filter into a query on the trigram field
+func (f *trigramFilter) query() query.Query {
+	queries := make([]query.Query, 0, len(f.trigrams)+len(f.subs))
+	for _, trigram := range f.trigrams {
+		termQuery := bleve.NewTermQuery(trigram)
+		termQuery.SetField("trigrams")
+		queries = append(queries, termQuery)
+	}
+	for _, sub := range f.subs {
+		queries = append(queries, sub.query())
+	}
+
+	if len(queries) == 1 {
+		return queries[0]
+	}
+	if f.any {
+		return bleve.NewDisjunctionQuery(queries...)
+	}
+	return bleve.NewConjunctionQuery(queries...)
+}
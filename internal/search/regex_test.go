@@ -0,0 +1,175 @@
+package search
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// parseFilter derives the trigram filter of a pattern as SearchRegex does
+func parseFilter(t *testing.T, pattern string) *trigramFilter {
+	t.Helper()
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %v", pattern, err)
+	}
+	return regexFilter(parsed.Simplify())
+}
+
+// admits reports whether a file with the given content passes a filter, by
+// evaluating it against the trigrams the file would be indexed with
+func admits(f *trigramFilter, content string) bool {
+	if f == nil {
+		return true
+	}
+	present := make(map[string]bool)
+	for _, trigram := range fileTrigrams(content) {
+		present[trigram] = true
+	}
+	return f.admits(present)
+}
+
+func (f *trigramFilter) admits(present map[string]bool) bool {
+	if f.any {
+		for _, sub := range f.subs {
+			if sub.admits(present) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, trigram := range f.trigrams {
+		if !present[trigram] {
+			return false
+		}
+	}
+	for _, sub := range f.subs {
+		if !sub.admits(present) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRegexFilterWithoutTrigrams(t *testing.T) {
+	// Patterns without a literal of three characters every match must
+	// contain cannot rule out any file
+	for _, pattern := range []string{`.*`, `\w+`, `[a-z]{3}`, `ab`, `a.b.c`, `(foo)?bar?`, `x*yz`, `foo|\d+`} {
+		if filter := parseFilter(t, pattern); filter != nil {
+			t.Errorf("Expected no trigram filter for %q, got %+v", pattern, filter)
+		}
+	}
+}
+
+func TestRegexFilterCandidates(t *testing.T) {
+	tests := []struct {
+		pattern string
+		admit   []string
+		reject  []string
+	}{
+		{`handleRequest`, []string{"func handleRequest() {}"}, []string{"func handle() {}", "request"}},
+		{`(?i)NewServer`, []string{"s := newserver()", "NEWSERVER"}, []string{"newsletter"}},
+		{`func \w+Handler\(`, []string{"func fooHandler("}, []string{"func foo(", "fooHandler("}},
+		{`(?:foo|bar)baz`, []string{"foobaz", "barbaz"}, []string{"foo bar", "quxbaz"}},
+		{`alpha|beta|gamma`, []string{"alpha", "x beta y", "gamma"}, []string{"delta"}},
+		{`(abc)+def`, []string{"abcabcdef"}, []string{"abc"}},
+		{`x{2,}yzw`, []string{"xxyzw"}, []string{"yz"}},
+		{"Kelvin", []string{"Kelvin"}, []string{"celsius"}},
+		{"(?i)Kelvin", []string{"kelvin", "KELVIN"}, []string{"celsius"}},
+	}
+
+	for _, tt := range tests {
+		filter := parseFilter(t, tt.pattern)
+		if filter == nil {
+			t.Errorf("Expected a trigram filter for %q", tt.pattern)
+			continue
+		}
+		re := regexp.MustCompile(tt.pattern)
+		for _, content := range tt.admit {
+			if !re.MatchString(content) {
+				t.Fatalf("Test content %q does not match %q", content, tt.pattern)
+			}
+			// The filter must never rule out a file the pattern matches
+			if !admits(filter, content) {
+				t.Errorf("Filter for %q rules out matching content %q", tt.pattern, content)
+			}
+		}
+		for _, content := range tt.reject {
+			if admits(filter, content) {
+				t.Errorf("Expected filter for %q to rule out %q", tt.pattern, content)
+			}
+		}
+	}
+}
+
+func TestRegexFilterAlternation(t *testing.T) {
+	filter := parseFilter(t, `open|close`)
+	if filter == nil || !filter.any || len(filter.subs) != 2 {
+		t.Fatalf("Expected a disjunction of two filters, got %+v", filter)
+	}
+
+	// One branch without trigrams makes the whole alternation unfilterable
+	if filter := parseFilter(t, `open|ok`); filter != nil {
+		t.Errorf("Expected no filter when a branch has no trigrams, got %+v", filter)
+	}
+
+	// Alternations nested in a concatenation are required alongside the
+	// surrounding literals
+	filter = parseFilter(t, `read(File|Dir)Sync`)
+	if filter == nil || filter.any {
+		t.Fatalf("Expected a conjunction, got %+v", filter)
+	}
+	if !admits(filter, "readDirSync") || admits(filter, "readSync") {
+		t.Error("Expected the alternation to be required")
+	}
+}
+
+func TestAppendLineMatches(t *testing.T) {
+	file := types.RegexMatch{Repository: "repo", FilePath: "main.go"}
+	content := "package main\n\nfunc main() {\n\tfmt.Println(\"héllo\", \"hello\")\n}\n"
+
+	matches := appendLineMatches(nil, regexp.MustCompile(`h.llo`), file, content)
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %d: %+v", len(matches), matches)
+	}
+
+	first := matches[0]
+	if first.Line != 4 || first.Column != 15 || first.EndColumn != 20 || first.Text != "héllo" {
+		t.Errorf("Unexpected first match: %+v", first)
+	}
+	if first.FilePath != "main.go" || first.Repository != "repo" {
+		t.Errorf("Expected file fields to be copied, got %+v", first)
+	}
+	if first.LineText != "\tfmt.Println(\"héllo\", \"hello\")" {
+		t.Errorf("Unexpected line text: %q", first.LineText)
+	}
+	if second := matches[1]; second.Line != 4 || second.Column != 24 || second.EndColumn != 29 {
+		t.Errorf("Unexpected second match: %+v", second)
+	}
+}
+
+func TestAppendLineMatchesDoesNotSpanLines(t *testing.T) {
+	file := types.RegexMatch{FilePath: "a.txt"}
+	content := "first line\r\nsecond line\n"
+
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{`line\s+second`, 0},
+		{`first[\s\S]*second`, 0},
+		{`line$`, 2},
+		{`^second`, 1},
+		{`\r`, 0},
+		{`x*`, 0}, // Empty matches are skipped
+	}
+
+	for _, tt := range tests {
+		matches := appendLineMatches(nil, regexp.MustCompile(tt.pattern), file, content)
+		if len(matches) != tt.want {
+			t.Errorf("Pattern %q: expected %d matches, got %d: %+v", tt.pattern, tt.want, len(matches), matches)
+		}
+	}
+}
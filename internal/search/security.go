@@ -0,0 +1,72 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// SecurityFindings returns the hardcoded secrets recorded for a repository,
+// ordered by file and line. Repositories indexed before secrets were
+// scanned, or with scanning disabled, have none.
+func (e *Engine) SecurityFindings(ctx context.Context, repositoryID string) ([]types.SecurityFinding, error) {
+	typeQuery := bleve.NewTermQuery("security_finding")
+	typeQuery.SetField("type")
+	repoQuery := bleve.NewTermQuery(repositoryID)
+	repoQuery.SetField("repository_id")
+
+	var findings []types.SecurityFinding
+	for from := 0; ; from += fileHashesPageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		searchRequest := bleve.NewSearchRequestOptions(bleve.NewConjunctionQuery(typeQuery, repoQuery), fileHashesPageSize, from, false)
+		searchRequest.Fields = []string{
+			"repository", "file_path", "language", "start_line", "metadata.rule", "metadata.description",
+			"metadata.severity", "metadata.column", "metadata.end_column", "metadata.redacted", "metadata.fingerprint",
+		}
+		searchRequest.SortBy([]string{"_id"})
+
+		searchResult, err := e.searchRepository(ctx, repositoryID, searchRequest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search for security finding documents: %w", err)
+		}
+		for _, hit := range searchResult.Hits {
+			finding := types.SecurityFinding{}
+			finding.Repository, _ = hit.Fields["repository"].(string)
+			finding.FilePath, _ = hit.Fields["file_path"].(string)
+			finding.Language, _ = hit.Fields["language"].(string)
+			finding.Rule, _ = hit.Fields["metadata.rule"].(string)
+			finding.Description, _ = hit.Fields["metadata.description"].(string)
+			finding.Severity, _ = hit.Fields["metadata.severity"].(string)
+			finding.Redacted, _ = hit.Fields["metadata.redacted"].(string)
+			finding.Fingerprint, _ = hit.Fields["metadata.fingerprint"].(string)
+			line, _ := hit.Fields["start_line"].(float64)
+			column, _ := hit.Fields["metadata.column"].(float64)
+			endColumn, _ := hit.Fields["metadata.end_column"].(float64)
+			finding.Line, finding.Column, finding.EndColumn = int(line), int(column), int(endColumn)
+			if finding.FilePath != "" {
+				findings = append(findings, finding)
+			}
+		}
+		if len(searchResult.Hits) < fileHashesPageSize {
+			break
+		}
+	}
+
+	sort.SliceStable(findings, func(a, b int) bool {
+		if findings[a].FilePath != findings[b].FilePath {
+			return findings[a].FilePath < findings[b].FilePath
+		}
+		if findings[a].Line != findings[b].Line {
+			return findings[a].Line < findings[b].Line
+		}
+		return findings[a].Column < findings[b].Column
+	})
+	return findings, nil
+}
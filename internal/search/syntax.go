@@ -0,0 +1,199 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// queryFields maps the qualifiers of the query syntax to the field they
+// match
+var queryFields = map[string]string{
+	"repo":       "repository",
+	"repository": "repository",
+	"lang":       "language",
+	"language":   "language",
+	"type":       "type",
+	"ref":        "ref",
+	"project":    "project",
+	"name":       "name",
+	"path":       "path",
+	"file":       "path",
+	"content":    "content",
+}
+
+// Occurrences of a clause of the query syntax
+const (
+	clauseText    = iota // Free text, matched like a query without syntax
+	clauseMust           // Prefixed with +, or a qualified filter
+	clauseMustNot        // Prefixed with -
+)
+
+// queryClause is one term of the query syntax: free text, a +required or
+// -excluded term, or a field:value qualifier
+type queryClause struct {
+	occur  int
+	field  string // Empty for unqualified terms
+	value  string
+	phrase bool // Quoted
+}
+
+// parseQuerySyntax splits a query into clauses. Terms are separated by
+// spaces outside double quotes; a leading + requires a term and a leading -
+// excludes it, and a known qualifier such as lang: or name: restricts it to
+// a field. Anything else is free text, so queries without syntax parse into
+// free text alone.
+func parseQuerySyntax(text string) []queryClause {
+	var clauses []queryClause
+	for _, token := range splitQueryTerms(text) {
+		clause := queryClause{occur: clauseText, value: token}
+		if len(token) > 1 && (token[0] == '+' || token[0] == '-') {
+			clause.occur = clauseMust
+			if token[0] == '-' {
+				clause.occur = clauseMustNot
+			}
+			clause.value = token[1:]
+		}
+		if prefix, value, ok := strings.Cut(clause.value, ":"); ok && value != "" {
+			if field, known := queryFields[strings.ToLower(prefix)]; known {
+				clause.field = field
+				clause.value = value
+				if clause.occur == clauseText {
+					clause.occur = clauseMust
+				}
+			}
+		}
+		if unquoted, ok := strings.CutPrefix(clause.value, `"`); ok {
+			clause.value = strings.TrimSuffix(unquoted, `"`)
+			clause.phrase = true
+		}
+		if clause.value != "" {
+			clauses = append(clauses, clause)
+		}
+	}
+	return clauses
+}
+
+// splitQueryTerms splits text at spaces outside double quotes
+func splitQueryTerms(text string) []string {
+	var terms []string
+	var term strings.Builder
+	quoted := false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			term.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if term.Len() > 0 {
+				terms = append(terms, term.String())
+				term.Reset()
+			}
+		default:
+			term.WriteRune(r)
+		}
+	}
+	if term.Len() > 0 {
+		terms = append(terms, term.String())
+	}
+	return terms
+}
+
+// SyntaxText returns the free text of a query in the query syntax, without
+// qualifiers and excluded terms, for matching text that is not indexed
+func SyntaxText(text string) string {
+	var words []string
+	for _, clause := range parseQuerySyntax(text) {
+		if clause.field == "" && clause.occur != clauseMustNot {
+			words = append(words, clause.value)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// syntaxQueries builds the queries a search in the query syntax must and
+// must not match, and returns the document types its type: qualifiers ask
+// for. Free text is matched like a query without syntax.
+func (e *Engine) syntaxQueries(searchQuery types.SearchQuery, ranking types.Ranking) (must, mustNot []query.Query, docTypes []string) {
+	textQuery := func(text string) query.Query {
+		textSearch := searchQuery
+		textSearch.Query = text
+		return mainTextQuery(textSearch, ranking)
+	}
+
+	var freeText []string
+	for _, clause := range parseQuerySyntax(searchQuery.Query) {
+		var clauseQuery query.Query
+		switch {
+		case clause.field == "" && clause.phrase:
+			clauseQuery = phraseQuery(clause.value, "name", "content")
+		case clause.field == "" && clause.occur == clauseText:
+			freeText = append(freeText, clause.value)
+			continue
+		case clause.field == "":
+			clauseQuery = textQuery(clause.value)
+		default:
+			clauseQuery = e.fieldQuery(clause)
+		}
+
+		if clause.occur == clauseMustNot {
+			mustNot = append(mustNot, clauseQuery)
+			continue
+		}
+		must = append(must, clauseQuery)
+		if clause.field == "type" {
+			docTypes = append(docTypes, clause.value)
+		}
+	}
+	if len(freeText) > 0 {
+		must = append(must, textQuery(strings.Join(freeText, " ")))
+	}
+	return must, mustNot, docTypes
+}
+
+// fieldQuery matches the value of a qualified clause. Filters match their
+// value exactly; names and content match it as a phrase, or as a wildcard
+// pattern when it has * or ?; paths contain it, or match it as a glob.
+func (e *Engine) fieldQuery(clause queryClause) query.Query {
+	switch clause.field {
+	case "repository", "project", "type":
+		return anyTermQuery(clause.field, []string{clause.value})
+	case "language":
+		return anyTermQuery(clause.field, []string{strings.ToLower(clause.value)})
+	case "ref":
+		if repositoryIDs := e.repositoriesAt(clause.value); len(repositoryIDs) > 0 {
+			return anyTermQuery("repository_id", repositoryIDs)
+		}
+		return bleve.NewMatchNoneQuery()
+	case "path":
+		if hasGlobMeta(clause.value) {
+			return pathGlobQuery(clause.value)
+		}
+		return pathContainsQuery(clause.value)
+	}
+
+	if !clause.phrase && strings.ContainsAny(clause.value, "*?") {
+		wildcardQuery := bleve.NewWildcardQuery(strings.ToLower(clause.value))
+		wildcardQuery.SetField(clause.field)
+		return wildcardQuery
+	}
+	return phraseQuery(clause.value, clause.field)
+}
+
+// phraseQuery matches text as a phrase in any of the fields
+func phraseQuery(text string, fields ...string) query.Query {
+	fieldQueries := make([]query.Query, 0, len(fields))
+	for _, field := range fields {
+		matchQuery := bleve.NewMatchPhraseQuery(text)
+		matchQuery.SetField(field)
+		fieldQueries = append(fieldQueries, matchQuery)
+	}
+	if len(fieldQueries) == 1 {
+		return fieldQueries[0]
+	}
+	return bleve.NewDisjunctionQuery(fieldQueries...)
+}
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sort"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestParseQuerySyntax(t *testing.T) {
+	got := parseQuerySyntax(`repo:api lang:go name:Parse* -vendor +"http client" handler std::string -type:comment`)
+	want := []queryClause{
+		{occur: clauseMust, field: "repository", value: "api"},
+		{occur: clauseMust, field: "language", value: "go"},
+		{occur: clauseMust, field: "name", value: "Parse*"},
+		{occur: clauseMustNot, value: "vendor"},
+		{occur: clauseMust, value: "http client", phrase: true},
+		{occur: clauseText, value: "handler"},
+		{occur: clauseText, value: "std::string"},
+		{occur: clauseMustNot, field: "type", value: "comment"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+
+	if text := SyntaxText(`lang:go parse -vendor "json body"`); text != "parse json body" {
+		t.Errorf("Expected the free text, got %q", text)
+	}
+}
+
+func TestSearchWithQuerySyntax(t *testing.T) {
+	engine, err := NewMemoryEngine(config.DefaultConfig().SearchStorage(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	defer engine.Close()
+
+	files := []struct {
+		path, language, function string
+	}{
+		{"parse.go", "go", "ParseConfig"},
+		{"vendor/lib/parse.go", "go", "ParseToken"},
+		{"parse.py", "python", "parse_config"},
+	}
+	for _, f := range files {
+		file := &types.CodeFile{
+			Path: f.path, RelativePath: f.path, Language: f.language, Lines: 1,
+			Content:   "config parser",
+			Functions: []types.Function{{Name: f.function, Signature: f.function + "()", StartLine: 1, EndLine: 1}},
+		}
+		if err := engine.IndexFile(context.Background(), file, &types.Repository{ID: "repo", Name: "repo"}); err != nil {
+			t.Fatalf("Failed to index %s: %v", f.path, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"lang:go type:function name:Parse*", []string{"ParseConfig", "ParseToken"}},
+		{"type:function name:Parse* -vendor", []string{"ParseConfig", "parse_config"}},
+		{"type:function config -lang:python", []string{"ParseConfig"}},
+		{`type:function "parse config"`, []string{"ParseConfig", "parse_config"}},
+		{"type:function path:vendor", []string{"ParseToken"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := engine.Search(context.Background(), types.SearchQuery{Query: tt.query, Syntax: true, MaxResults: 10})
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			var names []string
+			for _, result := range results {
+				names = append(names, result.Name)
+			}
+			sort.Strings(names)
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, names)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Package secrets finds hardcoded secrets in source files: cloud and API
+// keys, private keys, tokens, passwords assigned in code, credentials in
+// URLs and long random-looking strings.
+package secrets
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/repository"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// maxScannedLineLength bounds the lines searched for secrets; longer lines
+// are minified or generated code
+const maxScannedLineLength = 4096
+
+// suppressions mark a line whose secrets are intended, such as test
+// fixtures
+var suppressions = []string{"nosecret", "gitleaks:allow", "pragma: allowlist secret"}
+
+// placeholders are parts of values that are not real secrets
+var placeholders = []string{
+	"example", "changeme", "change_me", "placeholder", "your_", "your-", "xxxx", "dummy", "redacted",
+	"<", "${", "{{", "%s", "secret_here", "password_here", "****",
+}
+
+// Rule describes a kind of secret the scanner finds
+type Rule struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+}
+
+// rule is a Rule with how to find it
+type rule struct {
+	Rule
+	pattern *regexp.Regexp
+	group   int  // Submatch holding the secret, 0 for the whole match
+	generic bool // Values that look like placeholders are skipped
+	reveal  bool // The match is a marker rather than the secret, so it is not redacted
+}
+
+// rules are checked in order; a later rule does not report a secret an
+// earlier one found on the same span
+var rules = []rule{
+	{Rule: Rule{"private_key", "Private key", "critical"}, pattern: regexp.MustCompile(`-----BEGIN (?:RSA |EC |DSA |OPENSSH |PGP |ENCRYPTED )?PRIVATE KEY(?: BLOCK)?-----`), reveal: true},
+	{Rule: Rule{"aws_access_key_id", "AWS access key ID", "critical"}, pattern: regexp.MustCompile(`\b((?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16})\b`), group: 1},
+	{Rule: Rule{"aws_secret_access_key", "AWS secret access key", "critical"}, pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*(?::=|=|:|=>)\s*["']?([A-Za-z0-9/+]{40})\b`), group: 1},
+	{Rule: Rule{"github_token", "GitHub token", "high"}, pattern: regexp.MustCompile(`\b((?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{60,255})\b`), group: 1},
+	{Rule: Rule{"slack_token", "Slack token", "high"}, pattern: regexp.MustCompile(`\b(xox[abposr]-[0-9A-Za-z-]{10,})\b`), group: 1},
+	{Rule: Rule{"slack_webhook", "Slack incoming webhook URL", "medium"}, pattern: regexp.MustCompile(`https://hooks\.slack\.com/services/T[A-Za-z0-9_]+/B[A-Za-z0-9_]+/[A-Za-z0-9_]+`)},
+	{Rule: Rule{"stripe_secret_key", "Stripe live secret key", "critical"}, pattern: regexp.MustCompile(`\b((?:sk|rk)_live_[0-9A-Za-z]{24,})\b`), group: 1},
+	{Rule: Rule{"google_api_key", "Google API key", "high"}, pattern: regexp.MustCompile(`\b(AIza[0-9A-Za-z_\-]{35})`), group: 1},
+	{Rule: Rule{"jwt", "JSON web token", "medium"}, pattern: regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`), group: 1},
+	{Rule: Rule{"credentials_in_url", "Password in a URL", "high"}, pattern: regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@'"]+:([^\s:/@'"]{3,})@[^\s/'"]+`), group: 1, generic: true},
+	{Rule: Rule{"password_assignment", "Password or key assigned in code", "high"}, pattern: regexp.MustCompile(`(?i)\b[\w.-]*(?:password|passwd|pwd|secret|api_?key|access_?token|auth_?token|private_?key)[\w.-]*["']?\s*(?::=|=|:|=>)\s*["'\x60]([^"'\x60\s]{6,})["'\x60]`), group: 1, generic: true},
+}
+
+// entropyRule describes the random-looking strings the entropy check finds
+var entropyRule = Rule{"high_entropy_string", "High entropy string that may be a secret", "medium"}
+
+// Rules lists the rules the scanner reports, the entropy check last
+func Rules() []Rule {
+	list := make([]Rule, 0, len(rules)+1)
+	for _, r := range rules {
+		list = append(list, r.Rule)
+	}
+	return append(list, entropyRule)
+}
+
+// Scanner finds hardcoded secrets in file contents
+type Scanner struct {
+	entropyThreshold float64
+	ignorePatterns   []string
+	quoted           *regexp.Regexp // Quoted strings long enough for the entropy check
+}
+
+// NewScanner returns a scanner with the given settings
+func NewScanner(cfg config.SecretsConfig) *Scanner {
+	minLength := cfg.MinEntropyLength
+	if minLength <= 0 {
+		minLength = 20
+	}
+	return &Scanner{
+		entropyThreshold: cfg.EntropyThreshold,
+		ignorePatterns:   cfg.IgnorePatterns,
+		quoted:           regexp.MustCompile(fmt.Sprintf("[\"'`]([A-Za-z0-9+/=_.\\-]{%d,})[\"'`]", minLength)),
+	}
+}
+
+// Ignored reports whether a file, by its slash-separated path relative to
+// its repository, is not scanned
+func (s *Scanner) Ignored(relPath string) bool {
+	return repository.MatchesAny(s.ignorePatterns, relPath)
+}
+
+// Scan returns the secrets in content, a file at relPath within its
+// repository, in the order they appear
+func (s *Scanner) Scan(relPath, content string) []types.SecurityFinding {
+	var findings []types.SecurityFinding
+	for idx, line := range textpos.SplitLines(content) {
+		if len(line) > maxScannedLineLength || suppressed(line) {
+			continue
+		}
+		var spans [][2]int
+		overlaps := func(start, end int) bool {
+			for _, span := range spans {
+				if start < span[1] && span[0] < end {
+					return true
+				}
+			}
+			return false
+		}
+		report := func(r Rule, start, end int, reveal bool) {
+			secret := line[start:end]
+			spans = append(spans, [2]int{start, end})
+			finding := types.SecurityFinding{
+				Rule:        r.ID,
+				Description: r.Description,
+				Severity:    r.Severity,
+				FilePath:    relPath,
+				Line:        idx + 1,
+				Column:      textpos.Column(line, start),
+				EndColumn:   textpos.Column(line, end),
+				Redacted:    redact(secret),
+				Fingerprint: Fingerprint(r.ID, relPath, secret),
+			}
+			if reveal {
+				finding.Redacted = secret
+			}
+			findings = append(findings, finding)
+		}
+
+		for _, r := range rules {
+			for _, match := range r.pattern.FindAllStringSubmatchIndex(line, -1) {
+				start, end := match[2*r.group], match[2*r.group+1]
+				if start < 0 || overlaps(start, end) || (r.generic && placeholder(line[start:end])) {
+					continue
+				}
+				report(r.Rule, start, end, r.reveal)
+			}
+		}
+		if s.entropyThreshold <= 0 {
+			continue
+		}
+		for _, match := range s.quoted.FindAllStringSubmatchIndex(line, -1) {
+			start, end := match[2], match[3]
+			value := line[start:end]
+			if overlaps(start, end) || placeholder(value) || !mixed(value) || Entropy(value) < s.entropyThreshold {
+				continue
+			}
+			report(entropyRule, start, end, false)
+		}
+	}
+	return findings
+}
+
+// suppressed reports whether a line is marked as holding no real secret
+func suppressed(line string) bool {
+	lower := strings.ToLower(line)
+	for _, marker := range suppressions {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholder reports whether a value is a placeholder rather than a
+// secret
+func placeholder(value string) bool {
+	lower := strings.ToLower(value)
+	for _, part := range placeholders {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return strings.Count(value, value[:1]) == len(value) // Such as "********"
+}
+
+// mixed reports whether a value has both letters and digits, as random
+// keys do and words and identifiers do not
+func mixed(value string) bool {
+	return strings.ContainsAny(value, "0123456789") && strings.IndexFunc(value, func(r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+	}) >= 0
+}
+
+// Entropy returns the Shannon entropy of a string in bits per character
+func Entropy(value string) float64 {
+	if value == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redact masks all but the first characters of a secret
+func redact(secret string) string {
+	keep := 4
+	if len(secret) < 16 {
+		keep = 1
+	}
+	masked := len(secret) - keep
+	if masked > 16 {
+		masked = 16
+	}
+	return secret[:keep] + strings.Repeat("*", masked)
+}
+
+// Fingerprint identifies a secret found by a rule in a file without
+// revealing it, so the same secret is recognized after the lines around it
+// change
+func Fingerprint(ruleID, relPath, secret string) string {
+	sum := sha256.Sum256([]byte(ruleID + "\x00" + relPath + "\x00" + secret))
+	return fmt.Sprintf("%x", sum[:8])
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/connection"
+	"github.com/my-mcp/code-indexer/internal/locking"
+	"github.com/my-mcp/code-indexer/internal/repository"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// toolError returns the result of a tool call that failed with a code: the
+// error envelope, {"success": false, "error": {...}}, as JSON text
+func toolError(code, message string, details map[string]interface{}) *mcp.CallToolResult {
+	return toolErrorResult(types.NewToolError(code, message, details))
+}
+
+// repoNotFound returns the result of a tool call naming a repository that is
+// not indexed
+func repoNotFound(name string) *mcp.CallToolResult {
+	return toolErrorResult(repoNotFoundError(name))
+}
+
+// repoNotFoundError returns the error of a repository that is not indexed
+func repoNotFoundError(name string) *types.ToolError {
+	return types.NewToolError(types.ErrorRepoNotFound, "Repository '"+name+"' not found",
+		map[string]interface{}{"repository": name})
+}
+
+// toolErrorResult returns the result of a tool call that failed with err. A
+// *types.ToolError keeps its code; other errors are classified by their
+// message.
+func toolErrorResult(err error) *mcp.CallToolResult {
+	var toolErr *types.ToolError
+	if !errors.As(err, &toolErr) {
+		toolErr = classifyError(err.Error())
+	}
+	content, marshalErr := json.MarshalIndent(types.ErrorEnvelope{Error: toolErr}, "", "  ")
+	if marshalErr != nil {
+		return mcp.NewToolResultError(toolErr.Message)
+	}
+	return mcp.NewToolResultError(string(content))
+}
+
+// resultError returns the error in the envelope of a failed tool result
+func resultError(result *mcp.CallToolResult) (*types.ToolError, bool) {
+	if result == nil || !result.IsError {
+		return nil, false
+	}
+	var envelope types.ErrorEnvelope
+	if err := json.Unmarshal([]byte(resultText(result)), &envelope); err != nil || envelope.Error == nil || envelope.Error.Code == "" {
+		return nil, false
+	}
+	return envelope.Error, true
+}
+
+// resultText returns the text of the first content of a tool result
+func resultText(result *mcp.CallToolResult) string {
+	if len(result.Content) == 0 {
+		return ""
+	}
+	switch content := result.Content[0].(type) {
+	case mcp.TextContent:
+		return content.Text
+	case *mcp.TextContent:
+		return content.Text
+	}
+	return ""
+}
+
+// withErrorEnvelope answers every failure of a tool handler with the error
+// envelope: free-text error results are classified by their message, and
+// errors returned by the handler become internal error results, so clients
+// parse one format whichever tool failed
+func (s *MCPServer) withErrorEnvelope(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := handler(ctx, request)
+		if err != nil {
+			s.log(ctx).Error("Tool handler failed", zap.String("tool", name), zap.Error(err))
+			return toolErrorResult(err), nil
+		}
+		if result == nil || !result.IsError {
+			return result, nil
+		}
+		if _, ok := resultError(result); ok {
+			return result, nil
+		}
+		return toolErrorResult(errors.New(resultText(result))), nil
+	}
+}
+
+// classifyError gives a free-text error message the code it most likely
+// has. Handlers that know the code of a failure use toolError instead.
+func classifyError(message string) *types.ToolError {
+	lower := strings.ToLower(message)
+	containsAny := func(parts ...string) bool {
+		for _, part := range parts {
+			if strings.Contains(lower, part) {
+				return true
+			}
+		}
+		return false
+	}
+	hasPrefix := func(prefixes ...string) bool {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(message, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case strings.Contains(message, repository.ErrOutsideSandbox.Error()):
+		return types.NewToolError(types.ErrorPathOutsideSandbox, message, nil)
+	case strings.Contains(message, connection.ErrQuotaExceeded.Error()):
+		return types.NewToolError(types.ErrorQuotaExceeded, message, nil)
+	case strings.Contains(message, locking.ErrDeadlock.Error()):
+		return types.NewToolError(types.ErrorDeadlock, message, nil)
+	case strings.Contains(message, locking.ErrLockTimeout.Error()), strings.Contains(message, locking.ErrWaitQueueFull.Error()):
+		return types.NewToolError(types.ErrorLocked, message, nil)
+	case strings.HasPrefix(message, "Repository '") && strings.Contains(message, "' not found"):
+		name := strings.TrimPrefix(message, "Repository '")
+		name = name[:strings.Index(name, "' not found")]
+		return types.NewToolError(types.ErrorRepoNotFound, message, map[string]interface{}{"repository": name})
+	case containsAny(context.DeadlineExceeded.Error()):
+		return types.NewToolError(types.ErrorTimeout, message, nil)
+	case containsAny(context.Canceled.Error()):
+		return types.NewToolError(types.ErrorCancelled, message, nil)
+	case containsAny("read scope", "read-only"):
+		return types.NewToolError(types.ErrorPermissionDenied, message, nil)
+	case containsAny("not enabled", "needs the models engine", "is disabled"):
+		return types.NewToolError(types.ErrorFeatureDisabled, message, nil)
+	case containsAny("not supported", "no test runner is configured", "no test file conventions"):
+		return types.NewToolError(types.ErrorUnsupported, message, nil)
+	case containsAny("changed since"):
+		return types.NewToolError(types.ErrorConflict, message, nil)
+	case hasPrefix("Invalid ", "Unknown ") ||
+		containsAny(" must ", "cannot be", " is required", " are required", "expected "):
+		return types.NewToolError(types.ErrorInvalidArgument, message, nil)
+	case hasPrefix("No ") || containsAny("not found", "no such file", "does not exist"):
+		return types.NewToolError(types.ErrorNotFound, message, nil)
+	case containsAny("language server", "models engine"):
+		return types.NewToolError(types.ErrorUpstreamFailed, message, nil)
+	}
+	return types.NewToolError(types.ErrorInternal, message, nil)
+}
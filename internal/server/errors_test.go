@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		message string
+		code    string
+	}{
+		{"Invalid file_path parameter: path is outside the indexed repositories and allowed paths: /etc/passwd", types.ErrorPathOutsideSandbox},
+		{"Repository 'missing' not found", types.ErrorRepoNotFound},
+		{"Search failed: context deadline exceeded", types.ErrorTimeout},
+		{"Invalid query parameter: required argument \"query\" not found", types.ErrorInvalidArgument},
+		{"start_line must be less than or equal to end_line", types.ErrorInvalidArgument},
+		{"No search is saved as \"todo\"", types.ErrorNotFound},
+		{"Failed to read file: open /repo/a.go: no such file or directory", types.ErrorNotFound},
+		{"Multi-session support not enabled", types.ErrorFeatureDisabled},
+		{"Outlines are not supported for .txt files", types.ErrorUnsupported},
+		{"Language server request failed: EOF", types.ErrorUpstreamFailed},
+		{"Failed to lock a.go: deadlock detected: request of s1 for file:a.go aborted, owners s1 -> s2 wait for each other", types.ErrorDeadlock},
+		{"Failed to refresh index: failed to lock repository: lock acquisition timeout after 30s", types.ErrorLocked},
+		{"Failed to format response", types.ErrorInternal},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.message); got.Code != tt.code {
+			t.Errorf("classifyError(%q) = %s, want %s", tt.message, got.Code, tt.code)
+		}
+	}
+
+	toolErr := classifyError("Repository 'web' not found")
+	if toolErr.Category != types.ErrorCategoryNotFound || toolErr.Retryable || toolErr.Details["repository"] != "web" {
+		t.Errorf("Unexpected repository error %+v", toolErr)
+	}
+}
+
+func TestToolErrorEnvelope(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {})
+
+	decode := func(text string) *types.ToolError {
+		t.Helper()
+		var envelope types.ErrorEnvelope
+		if err := json.Unmarshal([]byte(text), &envelope); err != nil || envelope.Success || envelope.Error == nil {
+			t.Fatalf("Expected an error envelope, got %q", text)
+		}
+		return envelope.Error
+	}
+
+	text, isError := callTool(t, s, "git_diff", map[string]interface{}{"repository": "missing"})
+	if toolErr := decode(text); !isError || toolErr.Code != types.ErrorRepoNotFound || toolErr.Details["repository"] != "missing" {
+		t.Errorf("Expected REPO_NOT_FOUND, got %+v", toolErr)
+	}
+
+	text, _ = callTool(t, s, "get_file_content", map[string]interface{}{"file_path": "/etc/hostname"})
+	if toolErr := decode(text); toolErr.Code != types.ErrorPathOutsideSandbox || toolErr.Category != types.ErrorCategoryPermission {
+		t.Errorf("Expected PATH_OUTSIDE_SANDBOX, got %+v", toolErr)
+	}
+
+	// Handlers returning a Go error are answered with an internal error
+	s.addTool(mcp.NewTool("broken"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return nil, errors.New("disk on fire")
+	})
+	text, isError = callTool(t, s, "broken", nil)
+	if toolErr := decode(text); !isError || toolErr.Code != types.ErrorInternal || toolErr.Message != "disk on fire" {
+		t.Errorf("Expected INTERNAL, got %+v", toolErr)
+	}
+
+	// The daemon API carries the error beside the result
+	w := httptest.NewRecorder()
+	s.handleToolCall(w, apiRequest("/api/call", `{"tool": "git_diff", "arguments": {"repository": "missing"}}`))
+	var response struct {
+		Success bool             `json:"success"`
+		Error   *types.ToolError `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Success || response.Error == nil ||
+		response.Error.Code != types.ErrorRepoNotFound {
+		t.Errorf("Unexpected /api/call response %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	s.handleToolCall(w, apiRequest("/api/call", `{"tool": "missing"}`))
+	if toolErr := decode(w.Body.String()); w.Code != http.StatusNotFound || toolErr.Code != types.ErrorToolNotFound || toolErr.Message != "unknown tool: missing" {
+		t.Errorf("Expected 404 TOOL_NOT_FOUND, got %d %+v", w.Code, toolErr)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleToolCall(w, apiRequest("/api/call", `{`))
+	if toolErr := decode(w.Body.String()); w.Code != http.StatusBadRequest || toolErr.Code != types.ErrorInvalidArgument {
+		t.Errorf("Expected 400 INVALID_ARGUMENT, got %d %+v", w.Code, toolErr)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleToolCall(w, httptest.NewRequest(http.MethodPost, "/api/call", strings.NewReader(`{"tool": "list_repositories"}`)))
+	if toolErr := decode(w.Body.String()); w.Code != http.StatusUnsupportedMediaType || toolErr.Code != types.ErrorUnsupported {
+		t.Errorf("Expected 415 UNSUPPORTED without a JSON content type, got %d %+v", w.Code, toolErr)
+	}
+}
+
+// apiRequest returns a POST request with a JSON body to an API endpoint
+func apiRequest(target, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Follow-up hints let agents chain tools on a search result without
+// re-deriving paths and line numbers themselves
+
+// annotateFollowUps attaches follow-up tool calls to each result. Arguments
+// use the same path conventions the target tools resolve: get_file_snippet
+// and get_file_content read absolute paths, while git_blame takes the path
+// relative to the named repository.
+func (s *MCPServer) annotateFollowUps(ctx context.Context, results []types.SearchResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	repoPaths := make(map[string]string)
+	if repositories, err := s.searcher.ListRepositories(ctx); err == nil {
+		for _, repo := range repositories {
+			repoPaths[repo.Name] = repo.Path
+		}
+	} else {
+		s.log(ctx).Debug("Failed to list repositories for follow-up hints", zap.Error(err))
+	}
+
+	for idx := range results {
+		results[idx].FollowUps = followUpsFor(results[idx], repoPaths)
+	}
+}
+
+// followUpsFor builds the follow-up calls for a single result
+func followUpsFor(result types.SearchResult, repoPaths map[string]string) []types.FollowUp {
+	var followUps []types.FollowUp
+
+	fullPath := result.FilePath
+	repoPath, inRepository := repoPaths[result.Repository]
+	if !filepath.IsAbs(fullPath) && inRepository {
+		fullPath = filepath.Join(repoPath, result.FilePath)
+	}
+
+	startLine, endLine := result.StartLine, result.EndLine
+	if endLine < startLine {
+		endLine = startLine
+	}
+
+	if startLine > 0 {
+		followUps = append(followUps, types.FollowUp{
+			Tool: "get_file_snippet",
+			Arguments: map[string]any{
+				"file_path":       fullPath,
+				"start_line":      startLine,
+				"end_line":        endLine,
+				"include_context": true,
+			},
+			Description: fmt.Sprintf("Show lines %d-%d of %s with surrounding context", startLine, endLine, result.FilePath),
+		})
+	} else {
+		followUps = append(followUps, types.FollowUp{
+			Tool:        "get_file_content",
+			Arguments:   map[string]any{"file_path": fullPath},
+			Description: fmt.Sprintf("Read %s", result.FilePath),
+		})
+	}
+
+	if result.Name != "" {
+		args := map[string]any{"symbol_name": result.Name}
+		if slices.Contains(types.SymbolTypes, result.Type) {
+			args["symbol_type"] = result.Type
+		}
+		if result.Repository != "" {
+			args["repository"] = result.Repository
+		}
+		followUps = append(followUps, types.FollowUp{
+			Tool:        "find_references",
+			Arguments:   args,
+			Description: fmt.Sprintf("Find usages of %s", result.Name),
+		})
+	}
+
+	// git_blame resolves relative paths against the repository, so results
+	// without one (such as unsaved buffers) get no blame hint
+	if inRepository && startLine > 0 && !filepath.IsAbs(result.FilePath) {
+		followUps = append(followUps, types.FollowUp{
+			Tool: "git_blame",
+			Arguments: map[string]any{
+				"file_path":  result.FilePath,
+				"repository": result.Repository,
+				"start_line": startLine,
+				"end_line":   endLine,
+			},
+			Description: fmt.Sprintf("Show who last changed lines %d-%d", startLine, endLine),
+		})
+	}
+
+	return followUps
+}
@@ -4,16 +4,29 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+const (
+	// defaultGroundingTokens is the token budget of the repository context
+	// of analyze_code and explain_code unless asked otherwise
+	defaultGroundingTokens = 2000
+
+	// groundingSymbols bounds the referenced symbols whose definitions are
+	// looked up for the repository context
+	groundingSymbols = 10
 )
 
 // AI model tool handlers for code generation, analysis, and explanation
 
 // handleGenerateCode handles code generation requests
 func (s *MCPServer) handleGenerateCode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling code generation", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling code generation", zap.String("tool", request.Params.Name))
 
 	prompt, err := request.RequireString("prompt")
 	if err != nil {
@@ -25,9 +38,11 @@ func (s *MCPServer) handleGenerateCode(ctx context.Context, request mcp.CallTool
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid language parameter: %v", err)), nil
 	}
 
-	result, err := s.modelsEngine.GenerateCode(ctx, prompt, language)
+	streamCtx, flush := s.withModelStream(ctx, request)
+	result, err := s.modelsEngine.GenerateCode(streamCtx, prompt, language)
+	flush()
 	if err != nil {
-		s.logger.Error("Failed to generate code", zap.Error(err))
+		s.log(ctx).Error("Failed to generate code", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to generate code: %v", err)), nil
 	}
 
@@ -41,7 +56,7 @@ func (s *MCPServer) handleGenerateCode(ctx context.Context, request mcp.CallTool
 
 // handleAnalyzeCode handles code analysis requests
 func (s *MCPServer) handleAnalyzeCode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling code analysis", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling code analysis", zap.String("tool", request.Params.Name))
 
 	code, err := request.RequireString("code")
 	if err != nil {
@@ -53,11 +68,17 @@ func (s *MCPServer) handleAnalyzeCode(ctx context.Context, request mcp.CallToolR
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid language parameter: %v", err)), nil
 	}
 
-	result, err := s.modelsEngine.AnalyzeCode(ctx, code, language)
+	repositoryContext, grounding, err := s.repositoryGrounding(ctx, request, code, language)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid context_tokens parameter: %v", err)), nil
+	}
+
+	result, err := s.modelsEngine.AnalyzeCode(ctx, code, language, repositoryContext)
 	if err != nil {
-		s.logger.Error("Failed to analyze code", zap.Error(err))
+		s.log(ctx).Error("Failed to analyze code", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to analyze code: %v", err)), nil
 	}
+	result.Grounding = grounding
 
 	content, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
@@ -69,7 +90,7 @@ func (s *MCPServer) handleAnalyzeCode(ctx context.Context, request mcp.CallToolR
 
 // handleExplainCode handles code explanation requests
 func (s *MCPServer) handleExplainCode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling code explanation", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling code explanation", zap.String("tool", request.Params.Name))
 
 	code, err := request.RequireString("code")
 	if err != nil {
@@ -81,11 +102,19 @@ func (s *MCPServer) handleExplainCode(ctx context.Context, request mcp.CallToolR
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid language parameter: %v", err)), nil
 	}
 
-	result, err := s.modelsEngine.ExplainCode(ctx, code, language)
+	repositoryContext, grounding, err := s.repositoryGrounding(ctx, request, code, language)
 	if err != nil {
-		s.logger.Error("Failed to explain code", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid context_tokens parameter: %v", err)), nil
+	}
+
+	streamCtx, flush := s.withModelStream(ctx, request)
+	result, err := s.modelsEngine.ExplainCode(streamCtx, code, language, repositoryContext)
+	flush()
+	if err != nil {
+		s.log(ctx).Error("Failed to explain code", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to explain code: %v", err)), nil
 	}
+	result.Grounding = grounding
 
 	content, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
@@ -94,3 +123,96 @@ func (s *MCPServer) handleExplainCode(ctx context.Context, request mcp.CallToolR
 
 	return mcp.NewToolResultText(string(content)), nil
 }
+
+// repositoryGrounding retrieves indexed code related to code when the
+// request sets use_repository_context: the definitions of the symbols it
+// references with their doc strings, then the callers of the functions it
+// defines, as many as fit the context_tokens budget. It returns the context
+// rendered for the model and the documents it holds; the error reports an
+// invalid budget.
+func (s *MCPServer) repositoryGrounding(ctx context.Context, request mcp.CallToolRequest, code, language string) (string, []types.GroundingDocument, error) {
+	if !request.GetBool("use_repository_context", false) {
+		return "", nil, nil
+	}
+	budget := int(request.GetFloat("context_tokens", defaultGroundingTokens))
+	if budget <= 0 || budget > maxContextTokens {
+		return "", nil, fmt.Errorf("must be 1 to %d, got %d", maxContextTokens, budget)
+	}
+	repository := request.GetString("repository", "")
+
+	parsed, err := s.indexer.ParseSnippet(code, language)
+	if err != nil {
+		s.log(ctx).Warn("Failed to parse code for repository context", zap.Error(err))
+		return "", nil, nil
+	}
+	defined := make(map[string]bool)
+	for _, function := range parsed.Functions {
+		defined[function.Name] = true
+	}
+	for _, class := range parsed.Classes {
+		defined[class.Name] = true
+	}
+	imported := make(map[string]bool)
+	for _, imp := range parsed.Imports {
+		alias := imp.Alias
+		if alias == "" {
+			alias = path.Base(imp.Module)
+		}
+		imported[alias] = true
+	}
+
+	var candidates []*contextItem
+	looked := make(map[string]bool)
+	for _, reference := range parsed.References {
+		if len(looked) == groundingSymbols {
+			break
+		}
+		if defined[reference.Name] || looked[reference.Qualifier+"."+reference.Name] {
+			continue
+		}
+		looked[reference.Qualifier+"."+reference.Name] = true
+
+		definitions, err := s.findDefinitions(ctx, reference.Name, "", repository)
+		if err != nil {
+			s.log(ctx).Warn("Failed to find definitions for repository context", zap.Error(err))
+			continue
+		}
+		for _, definition := range definitions {
+			// A symbol of an imported package is only defined in a
+			// directory of the package's name
+			if imported[reference.Qualifier] && path.Base(path.Dir(definition.FilePath)) != reference.Qualifier {
+				continue
+			}
+			if definition.Language != language {
+				continue
+			}
+			item, _ := s.symbolContext(ctx, request, definition)
+			item.Kind = "definition"
+			candidates = append(candidates, item)
+			break
+		}
+	}
+	for _, function := range parsed.Functions {
+		candidates = append(candidates, s.callerContext(ctx, types.SearchResult{Name: function.Name, Repository: repository})...)
+	}
+
+	pack := packContext(candidates, budget, false)
+	return pack.Text, groundingDocuments(pack), nil
+}
+
+// groundingDocuments describes the items of a context pack
+func groundingDocuments(pack *contextPack) []types.GroundingDocument {
+	documents := make([]types.GroundingDocument, 0, len(pack.Included))
+	for _, item := range pack.Included {
+		documents = append(documents, types.GroundingDocument{
+			Kind:       item.Kind,
+			Name:       item.Name,
+			Repository: item.Repository,
+			FilePath:   item.FilePath,
+			StartLine:  item.StartLine,
+			EndLine:    item.EndLine,
+			Tokens:     item.Tokens,
+		})
+	}
+	return documents
+}
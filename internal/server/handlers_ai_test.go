@@ -0,0 +1,263 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestExplainCodeRepositoryContext(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"names.go": `package app
+
+import "strings"
+
+// Normalize trims and lowercases a name.
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+`,
+		"run.go": "package app\n\nfunc Run() {\n\tprintln(Greet(\"World\"))\n}\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+		cfg.Models.Enabled = true
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	code := "func Greet(name string) string {\n\treturn \"hello \" + Normalize(name)\n}\n"
+	text, isError := callTool(t, s, "explain_code", map[string]interface{}{"code": code, "language": "go"})
+	if isError {
+		t.Fatalf("explain_code failed: %s", text)
+	}
+	var plain types.CodeExplanation
+	if err := json.Unmarshal([]byte(text), &plain); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if len(plain.Grounding) != 0 {
+		t.Errorf("Expected no grounding unless asked for, got %+v", plain.Grounding)
+	}
+
+	text, isError = callTool(t, s, "explain_code", map[string]interface{}{
+		"code": code, "language": "go", "use_repository_context": true, "repository": "app",
+	})
+	if isError {
+		t.Fatalf("explain_code failed: %s", text)
+	}
+	var grounded types.CodeExplanation
+	if err := json.Unmarshal([]byte(text), &grounded); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	kinds := make(map[string]types.GroundingDocument)
+	for _, document := range grounded.Grounding {
+		kinds[document.Kind+":"+document.Name] = document
+	}
+	definition, ok := kinds["definition:Normalize"]
+	if !ok || definition.FilePath != "names.go" || definition.StartLine != 6 || definition.Tokens == 0 {
+		t.Errorf("Expected the definition of Normalize, got %+v", grounded.Grounding)
+	}
+	if caller, ok := kinds["caller:Run"]; !ok || caller.FilePath != "run.go" {
+		t.Errorf("Expected the call of Greet in Run, got %+v", grounded.Grounding)
+	}
+
+	text, isError = callTool(t, s, "analyze_code", map[string]interface{}{
+		"code": code, "language": "go", "use_repository_context": true, "context_tokens": 0,
+	})
+	if !isError {
+		t.Errorf("Expected an error for an empty budget, got %s", text)
+	}
+}
+
+func TestSummarizeDiff(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	root := t.TempDir()
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	file := filepath.Join(root, "internal", "app", "run.go")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte("package app\n\nfunc Run() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+		cfg.Models.Enabled = true
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	if text, isError := callTool(t, s, "summarize_diff", map[string]interface{}{"repository": "app"}); !isError {
+		t.Errorf("Expected an error without changes, got %s", text)
+	}
+
+	if err := os.WriteFile(file, []byte("package app\n\nfunc Run() {\n\tprintln(\"running\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	type response struct {
+		Summary types.ChangeSummary `json:"summary"`
+	}
+	text, isError := callTool(t, s, "summarize_diff", map[string]interface{}{"repository": "app", "conventional": true})
+	if isError {
+		t.Fatalf("summarize_diff failed: %s", text)
+	}
+	var commit response
+	if err := json.Unmarshal([]byte(text), &commit); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if commit.Summary.Subject != "fix(app): update internal/app/run.go" {
+		t.Errorf("Unexpected subject %q", commit.Summary.Subject)
+	}
+	if !strings.HasPrefix(commit.Summary.Message, commit.Summary.Subject+"\n\n") {
+		t.Errorf("Expected the subject to start the message, got %q", commit.Summary.Message)
+	}
+
+	text, isError = callTool(t, s, "summarize_diff", map[string]interface{}{"repository": "app", "format": "pull_request", "type": "feat"})
+	if isError {
+		t.Fatalf("summarize_diff failed: %s", text)
+	}
+	var pullRequest response
+	if err := json.Unmarshal([]byte(text), &pullRequest); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if pullRequest.Summary.Type != "feat" || !strings.Contains(pullRequest.Summary.Message, "## Changes\n\n- Update internal/app/run.go") {
+		t.Errorf("Unexpected pull request description %+v", pullRequest.Summary)
+	}
+
+	if text, isError := callTool(t, s, "summarize_diff", map[string]interface{}{"repository": "app", "type": "feature"}); !isError {
+		t.Errorf("Expected an error for an unknown type, got %s", text)
+	}
+}
+
+func TestGenerateTests(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"store.go": `package store
+
+// Store keeps orders.
+type Store struct{}
+
+// Open returns an empty store.
+func Open() *Store {
+	return &Store{}
+}
+
+// Close releases the store.
+func Close(s *Store) error {
+	return nil
+}
+
+// Count returns the number of orders.
+func Count(s *Store) int {
+	return 0
+}
+`,
+		"store_test.go":          "package store\n\nimport \"testing\"\n\nfunc TestOpen(t *testing.T) {\n\tOpen()\n}\n",
+		"app/models.py":          "def parse(text):\n    return text.strip()\n",
+		"tests/test_settings.py": "import pytest\n\n\ndef test_defaults():\n    assert True\n",
+	}
+	for name, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+		cfg.Models.Enabled = true
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	type response struct {
+		TestFileExists bool                 `json:"test_file_exists"`
+		Generation     types.TestGeneration `json:"generation"`
+		Diff           string               `json:"diff"`
+		Written        bool                 `json:"written"`
+	}
+	generate := func(args map[string]interface{}) response {
+		t.Helper()
+		text, isError := callTool(t, s, "generate_tests", args)
+		if isError {
+			t.Fatalf("generate_tests failed: %s", text)
+		}
+		var result response
+		if err := json.Unmarshal([]byte(text), &result); err != nil {
+			t.Fatalf("Failed to parse response: %v", err)
+		}
+		return result
+	}
+
+	// Only the symbols the tests never mention, added to the existing file
+	result := generate(map[string]interface{}{"file_path": "store.go", "repository": "app"})
+	if strings.Join(result.Generation.Targets, ",") != "Close,Count" || !result.TestFileExists {
+		t.Errorf("Expected the untested symbols of store.go, got %+v", result)
+	}
+	if result.Generation.Framework != "go-testing" || result.Generation.TestFile != "store_test.go" {
+		t.Errorf("Unexpected framework or test file %+v", result.Generation)
+	}
+	if !strings.HasPrefix(result.Generation.Content, files["store_test.go"]) || !strings.Contains(result.Generation.Content, "func TestCount(t *testing.T)") {
+		t.Errorf("Expected the new tests after the existing ones, got %q", result.Generation.Content)
+	}
+
+	result = generate(map[string]interface{}{"file_path": "store.go", "repository": "app", "symbol_name": "Close", "write": true, "dry_run": true})
+	if result.Written || !strings.Contains(result.Diff, "+func TestClose(t *testing.T) {") {
+		t.Errorf("Expected the diff only, got %+v", result)
+	}
+	if content, _ := os.ReadFile(filepath.Join(root, "store_test.go")); string(content) != files["store_test.go"] {
+		t.Errorf("Expected a dry run to leave the test file alone, got %q", content)
+	}
+
+	// A new test file goes next to the repository's other tests, in the
+	// framework they use
+	result = generate(map[string]interface{}{"file_path": filepath.Join(root, "app", "models.py"), "write": true})
+	if !result.Written || result.TestFileExists || result.Generation.TestFile != "tests/test_models.py" || result.Generation.Framework != "pytest" {
+		t.Errorf("Unexpected generation %+v", result)
+	}
+	content, err := os.ReadFile(filepath.Join(root, "tests", "test_models.py"))
+	if err != nil {
+		t.Fatalf("Expected the test file to be created: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "from app.models import parse\n\n\ndef test_parse():\n") {
+		t.Errorf("Unexpected test file %q", content)
+	}
+
+	if text, isError := callTool(t, s, "generate_tests", map[string]interface{}{"file_path": "store_test.go", "repository": "app"}); !isError {
+		t.Errorf("Expected an error for a test file, got %s", text)
+	}
+	if text, isError := callTool(t, s, "generate_tests", map[string]interface{}{"file_path": "store.go", "repository": "app", "framework": "pytest"}); !isError {
+		t.Errorf("Expected an error for a framework of another language, got %s", text)
+	}
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/indexer"
+)
+
+// Archive handlers: export indexes to a portable archive and import them
+// into another server, so a team can share an index instead of re-indexing
+
+// handleExportIndex writes the indexes of repositories to an archive file
+func (s *MCPServer) handleExportIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling export index", zap.String("tool", request.Params.Name))
+
+	path, err := request.RequireString("path")
+	if err != nil || path == "" {
+		return mcp.NewToolResultError("Invalid path parameter: the archive needs a path"), nil
+	}
+	resolved, err := s.repoMgr.ResolvePath(path)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	// Write next to the target and rename, so a failed export never leaves
+	// a truncated archive behind
+	tmp, err := os.CreateTemp(filepath.Dir(resolved), "."+filepath.Base(resolved)+".tmp-*")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to create archive: %v", err)), nil
+	}
+	defer os.Remove(tmp.Name())
+
+	archive, err := s.indexer.ExportIndex(ctx, tmp, s.getStringList(request, "repositories"))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), resolved)
+	}
+	if err != nil {
+		s.log(ctx).Error("Failed to export index", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to export index: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success": true,
+		"path":    resolved,
+		"archive": archive,
+		"message": fmt.Sprintf("Exported %d repositories; import_index loads the archive into a server of the same version", len(archive.Repositories)),
+	}
+	if info, err := os.Stat(resolved); err == nil {
+		result["size_bytes"] = info.Size()
+	}
+
+	resultJSON, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// handleImportIndex loads the repositories of an archive file
+func (s *MCPServer) handleImportIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling import index", zap.String("tool", request.Params.Name))
+
+	path, err := request.RequireString("path")
+	if err != nil || path == "" {
+		return mcp.NewToolResultError("Invalid path parameter: the archive needs a path"), nil
+	}
+	resolved, err := s.repoMgr.ResolvePath(path)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	options := indexer.ImportOptions{
+		Repositories: s.getStringList(request, "repositories"),
+		Overwrite:    s.getBooleanValue(request, "overwrite", false),
+	}
+	if root := request.GetString("repository_root", ""); root != "" {
+		if options.RepositoryRoot, err = s.repoMgr.ResolvePath(root); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to open archive: %v", err)), nil
+	}
+	defer file.Close()
+
+	imported, err := s.indexer.ImportIndex(ctx, file, options)
+	if err != nil {
+		s.log(ctx).Error("Failed to import index", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to import index: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success": true,
+		"import":  imported,
+		"message": fmt.Sprintf("Imported %d repositories; refresh_index brings them up to date with their checkouts here", len(imported.Imported)),
+	}
+	if len(imported.Skipped) > 0 {
+		result["message"] = fmt.Sprintf("Imported %d repositories and skipped %d indexed here already; pass overwrite to replace them", len(imported.Imported), len(imported.Skipped))
+	}
+
+	resultJSON, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
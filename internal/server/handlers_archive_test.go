@@ -0,0 +1,202 @@
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestExportAndImportIndex(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "shared")
+	if err := os.MkdirAll(repoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "retry.go"), []byte("package shared\n\nfunc RetryPolicy() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archiveDir := t.TempDir()
+	archivePath := filepath.Join(archiveDir, "shared.tar.gz")
+
+	exporter := newTestServer(t, func(cfg *config.Config) {
+		cfg.Indexer.MemoryIndex = false
+		cfg.Indexer.DataDir = t.TempDir()
+		cfg.Server.AllowedPaths = []string{repoDir, archiveDir}
+	})
+	if text, isError := callTool(t, exporter, "index_repository", map[string]interface{}{"path": repoDir, "name": "shared"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+	if text, isError := callTool(t, exporter, "export_index", map[string]interface{}{"path": archivePath}); isError {
+		t.Fatalf("export_index failed: %s", text)
+	}
+
+	// The checkout lives elsewhere on the importing machine
+	checkoutRoot := t.TempDir()
+	importer := newTestServer(t, func(cfg *config.Config) {
+		cfg.Indexer.MemoryIndex = false
+		cfg.Indexer.DataDir = t.TempDir()
+		cfg.Server.AllowedPaths = []string{archiveDir, checkoutRoot}
+	})
+	text, isError := callTool(t, importer, "import_index", map[string]interface{}{"path": archivePath, "repository_root": checkoutRoot})
+	if isError {
+		t.Fatalf("import_index failed: %s", text)
+	}
+	var imported struct {
+		Import types.IndexImport `json:"import"`
+	}
+	if err := json.Unmarshal([]byte(text), &imported); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if len(imported.Import.Imported) != 1 {
+		t.Fatalf("Expected one imported repository, got %s", text)
+	}
+	if repo := imported.Import.Imported[0]; repo.Name != "shared" || repo.Path != filepath.Join(checkoutRoot, "shared") {
+		t.Errorf("Expected shared to be recorded below the repository root, got %+v", repo)
+	}
+
+	text, isError = callTool(t, importer, "search_code", map[string]interface{}{"query": "RetryPolicy", "repository": "shared"})
+	if isError || !strings.Contains(text, "retry.go") {
+		t.Errorf("Expected the imported index to be searchable, got %s", text)
+	}
+
+	text, isError = callTool(t, importer, "import_index", map[string]interface{}{"path": archivePath})
+	if isError || !strings.Contains(text, `"skipped":["shared"]`) {
+		t.Errorf("Expected an indexed repository to be skipped without overwrite, got %s", text)
+	}
+	if text, isError = callTool(t, importer, "import_index", map[string]interface{}{"path": archivePath, "repository_root": checkoutRoot, "overwrite": true}); isError {
+		t.Errorf("Expected overwrite to replace the repository, got %s", text)
+	}
+
+	bogus := filepath.Join(archiveDir, "bogus.tar.gz")
+	if err := os.WriteFile(bogus, []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if text, isError = callTool(t, importer, "import_index", map[string]interface{}{"path": bogus}); !isError || !strings.Contains(text, "invalid index archive") {
+		t.Errorf("Expected an invalid archive to be rejected, got %s", text)
+	}
+}
+
+func TestImportIndexStaysInSandbox(t *testing.T) {
+	repoDir := filepath.Join(t.TempDir(), "shared")
+	if err := os.MkdirAll(repoDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoDir, "retry.go"), []byte("package shared\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	archiveDir := t.TempDir()
+	archivePath := filepath.Join(archiveDir, "shared.tar.gz")
+	exporter := newTestServer(t, func(cfg *config.Config) {
+		cfg.Indexer.MemoryIndex = false
+		cfg.Indexer.DataDir = t.TempDir()
+		cfg.Server.AllowedPaths = []string{repoDir, archiveDir}
+	})
+	if text, isError := callTool(t, exporter, "index_repository", map[string]interface{}{"path": repoDir, "name": "shared"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+	if text, isError := callTool(t, exporter, "export_index", map[string]interface{}{"path": archivePath}); isError {
+		t.Fatalf("export_index failed: %s", text)
+	}
+
+	checkoutRoot := t.TempDir()
+	importer := newTestServer(t, func(cfg *config.Config) {
+		cfg.Indexer.MemoryIndex = false
+		cfg.Indexer.DataDir = t.TempDir()
+		cfg.Server.AllowedPaths = []string{archiveDir, checkoutRoot}
+	})
+	tests := []struct {
+		name string
+		path string // Repository path the archive is crafted with
+		root string
+	}{
+		{"filesystem root", "/", ""},
+		{"outside the sandbox", repoDir, ""},
+		{"parent directory below the root", "..", checkoutRoot},
+		{"filesystem root below the root", "/", checkoutRoot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crafted := filepath.Join(archiveDir, "crafted.tar.gz")
+			rewriteArchive(t, archivePath, crafted, repoDir, tt.path)
+			args := map[string]interface{}{"path": crafted, "overwrite": true}
+			if tt.root != "" {
+				args["repository_root"] = tt.root
+			}
+			if text, isError := callTool(t, importer, "import_index", args); !isError {
+				t.Fatalf("Expected the import to be refused, got %s", text)
+			}
+			for _, root := range importer.repoMgr.SandboxRoots() {
+				if root == string(filepath.Separator) || root == filepath.Dir(checkoutRoot) || root == repoDir {
+					t.Errorf("Expected %s not to be opened to the file tools", root)
+				}
+			}
+			if text, isError := callTool(t, importer, "get_file_content", map[string]interface{}{"file_path": filepath.Join(repoDir, "retry.go")}); !isError {
+				t.Errorf("Expected files outside the sandbox to stay unreadable, got %s", text)
+			}
+		})
+	}
+}
+
+// rewriteArchive copies an index archive, replacing the repository path
+// from with to in its manifest and metadata
+func rewriteArchive(t *testing.T, src, dst, from, to string) {
+	t.Helper()
+	in, err := os.Open(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	gzipReader, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+
+	var out bytes.Buffer
+	gzipWriter := gzip.NewWriter(&out)
+	tarWriter := tar.NewWriter(gzipWriter)
+	quoted := func(s string) []byte {
+		encoded, _ := json.Marshal(s)
+		return encoded
+	}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasSuffix(header.Name, ".json") && !strings.Contains(header.Name, "/") {
+			data = bytes.ReplaceAll(data, quoted(from), quoted(to))
+		}
+		header.Size = int64(len(data))
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/nlquery"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+const (
+	// askCodebaseDefaultResults and askCodebaseMaxResults bound the evidence
+	// returned by ask_codebase
+	askCodebaseDefaultResults = 10
+	askCodebaseMaxResults     = 50
+
+	// askStepResults is how many hits each step of a query plan reads
+	askStepResults = 20
+
+	// askRankConstant damps the reciprocal rank fusion of the steps' hits,
+	// so the first hits of a step do not outweigh agreement between steps
+	askRankConstant = 10
+)
+
+// askStepWeights weigh the hits of each kind of step: an exact definition
+// answers a question better than a full-text match
+var askStepWeights = map[string]float64{
+	nlquery.StepSymbol:     1.0,
+	nlquery.StepReferences: 0.9,
+	nlquery.StepRegex:      0.9,
+	nlquery.StepFiles:      0.8,
+	nlquery.StepSearch:     0.6,
+}
+
+// handleAskCodebase answers a natural language question about the indexed
+// code: the question is translated into a plan of symbol, reference, regular
+// expression, file and full-text lookups, by its wording or by the models
+// engine, the plan is run, and the places found are ranked by reciprocal
+// rank fusion and cited by file and line
+func (s *MCPServer) handleAskCodebase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling ask codebase", zap.String("tool", request.Params.Name))
+
+	question, err := request.RequireString("question")
+	if err != nil || strings.TrimSpace(question) == "" {
+		return mcp.NewToolResultError("Invalid question parameter: a question is required"), nil
+	}
+	repository := request.GetString("repository", "")
+	language := request.GetString("language", "")
+	maxResults := int(request.GetFloat("max_results", askCodebaseDefaultResults))
+	if maxResults <= 0 || maxResults > askCodebaseMaxResults {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid max_results %d: use 1 to %d", maxResults, askCodebaseMaxResults)), nil
+	}
+
+	var warnings []string
+	plan := nlquery.Plan(question)
+	if s.getBooleanValue(request, "use_model", false) {
+		if !s.modelsEngine.IsEnabled() {
+			return mcp.NewToolResultError("use_model needs the models engine; enable it under models in the configuration"), nil
+		}
+		modelPlan, err := s.modelsEngine.PlanQuery(ctx, question)
+		if err != nil {
+			s.log(ctx).Warn("Failed to plan the question with the models engine", zap.Error(err))
+			warnings = append(warnings, fmt.Sprintf("The models engine failed (%v); the plan follows the question's wording", err))
+		} else {
+			plan = modelPlan
+		}
+	}
+	if language != "" {
+		for i := range plan.Steps {
+			plan.Steps[i].Language = language
+		}
+	}
+
+	fused := make(map[string]*types.Evidence)
+	for i, step := range plan.Steps {
+		evidence, err := s.runQueryStep(ctx, step, repository)
+		if err != nil {
+			s.log(ctx).Warn("Query step failed", zap.String("kind", step.Kind), zap.String("query", step.Query), zap.Error(err))
+			warnings = append(warnings, fmt.Sprintf("Step %d (%s %q) failed: %v", i, step.Kind, step.Query, err))
+			continue
+		}
+		weight := askStepWeights[step.Kind]
+		for rank, found := range evidence {
+			key := fmt.Sprintf("%s|%s|%d", found.Repository, found.FilePath, found.StartLine)
+			existing, ok := fused[key]
+			if !ok {
+				existing = found
+				existing.Score = 0
+				fused[key] = existing
+			}
+			existing.Score += weight / float64(askRankConstant+rank+1)
+			if len(existing.Steps) == 0 || existing.Steps[len(existing.Steps)-1] != i {
+				existing.Steps = append(existing.Steps, i)
+			}
+		}
+	}
+
+	evidence := make([]*types.Evidence, 0, len(fused))
+	for _, found := range fused {
+		evidence = append(evidence, found)
+	}
+	sort.SliceStable(evidence, func(i, j int) bool {
+		if evidence[i].Score != evidence[j].Score {
+			return evidence[i].Score > evidence[j].Score
+		}
+		return evidence[i].Citation < evidence[j].Citation
+	})
+	if len(evidence) > maxResults {
+		evidence = evidence[:maxResults]
+	}
+
+	result := map[string]interface{}{
+		"success":  true,
+		"question": question,
+		"plan":     plan,
+		"evidence": evidence,
+		"count":    len(evidence),
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+	if len(evidence) == 0 {
+		result["message"] = "Nothing in the index answers the question; rephrase it, name a symbol or file, or index the repository"
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// runQueryStep runs one step of a query plan and returns what it found, best
+// first and without scores
+func (s *MCPServer) runQueryStep(ctx context.Context, step types.QueryStep, repository string) ([]*types.Evidence, error) {
+	searchQuery := types.SearchQuery{
+		Query:      step.Query,
+		Language:   step.Language,
+		Repository: repository,
+		MaxResults: askStepResults,
+	}
+	if step.PathPrefix != "" {
+		searchQuery.PathPrefix = step.PathPrefix + "/"
+	}
+
+	switch step.Kind {
+	case nlquery.StepSymbol:
+		searchQuery.Types = types.SymbolTypes
+		if step.SymbolType != "" {
+			searchQuery.Types = []string{step.SymbolType}
+		}
+		searchQuery.Profile = types.ProfileSymbols
+		s.RankQuery(&searchQuery)
+		results, err := s.searcher.Search(ctx, searchQuery)
+		if err != nil {
+			return nil, err
+		}
+		// Exact definitions of the name come first
+		sort.SliceStable(results, func(i, j int) bool {
+			return results[i].Name == step.Query && results[j].Name != step.Query
+		})
+		return searchEvidence(results), nil
+
+	case nlquery.StepReferences:
+		page, err := s.searcher.FindReferences(ctx, types.ReferenceQuery{
+			Name:       step.Query,
+			Repository: repository,
+			MaxResults: askStepResults,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var evidence []*types.Evidence
+		for _, reference := range page.References {
+			if step.Language != "" && reference.Language != step.Language {
+				continue
+			}
+			if step.PathPrefix != "" && !strings.HasPrefix(reference.FilePath, step.PathPrefix+"/") {
+				continue
+			}
+			// The function making the reference names the place
+			name := reference.Caller
+			if name == "" {
+				name = reference.Name
+			}
+			evidence = append(evidence, newEvidence(reference.Repository, reference.FilePath, reference.Language,
+				reference.Line, reference.Line, name, "reference", reference.Context))
+		}
+		return evidence, nil
+
+	case nlquery.StepRegex:
+		regexResult, err := s.searcher.SearchRegex(ctx, searchQuery)
+		if err != nil {
+			return nil, err
+		}
+		var evidence []*types.Evidence
+		for _, match := range regexResult.Matches {
+			evidence = append(evidence, newEvidence(match.Repository, match.FilePath, match.Language,
+				match.Line, match.Line, "", "match", strings.TrimSpace(match.LineText)))
+		}
+		return evidence, nil
+
+	case nlquery.StepFiles:
+		searchQuery.Query = ""
+		searchQuery.FilePath = strings.TrimPrefix(step.Query, "./")
+		searchQuery.Type = "file"
+		page, err := s.searcher.SearchPage(ctx, searchQuery)
+		if err != nil {
+			return nil, err
+		}
+		return searchEvidence(page.Results), nil
+
+	case nlquery.StepSearch:
+		s.RankQuery(&searchQuery)
+		page, err := s.searcher.SearchPage(ctx, searchQuery)
+		if err != nil {
+			return nil, err
+		}
+		return searchEvidence(page.Results), nil
+	}
+	return nil, fmt.Errorf("unknown step kind %q", step.Kind)
+}
+
+// searchEvidence turns search results into evidence
+func searchEvidence(results []types.SearchResult) []*types.Evidence {
+	evidence := make([]*types.Evidence, 0, len(results))
+	for _, result := range results {
+		snippet := result.Snippet
+		if snippet == "" && result.Type != "file" {
+			snippet = firstLines(result.Content, 5)
+		}
+		evidence = append(evidence, newEvidence(result.Repository, result.FilePath, result.Language,
+			result.StartLine, result.EndLine, result.Name, result.Type, snippet))
+	}
+	return evidence
+}
+
+// newEvidence returns the evidence of a place in the code, cited as
+// path:line or path:start-end
+func newEvidence(repository, filePath, language string, startLine, endLine int, name, docType, snippet string) *types.Evidence {
+	citation := filePath
+	switch {
+	case startLine > 0 && endLine > startLine:
+		citation = fmt.Sprintf("%s:%d-%d", filePath, startLine, endLine)
+	case startLine > 0:
+		citation = fmt.Sprintf("%s:%d", filePath, startLine)
+	}
+	return &types.Evidence{
+		Citation:   citation,
+		Repository: repository,
+		FilePath:   filePath,
+		Language:   language,
+		StartLine:  startLine,
+		EndLine:    endLine,
+		Name:       name,
+		Type:       docType,
+		Snippet:    snippet,
+	}
+}
+
+// firstLines returns the first n lines of text
+func firstLines(text string, n int) string {
+	lines := strings.SplitN(text, "\n", n+1)
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
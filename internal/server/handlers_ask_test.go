@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestAskCodebase(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"greet.go": "package app\n\n// Greet greets a user by name.\nfunc Greet(name string) string {\n\treturn \"hello \" + name\n}\n",
+		"run.go":   "package app\n\nfunc Run() {\n\tprintln(Greet(\"World\"))\n\t// TODO: read the name from flags\n}\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	type answer struct {
+		Plan     types.QueryPlan  `json:"plan"`
+		Evidence []types.Evidence `json:"evidence"`
+		Count    int              `json:"count"`
+		Message  string           `json:"message"`
+	}
+	ask := func(args map[string]interface{}) answer {
+		t.Helper()
+		text, isError := callTool(t, s, "ask_codebase", args)
+		if isError {
+			t.Fatalf("ask_codebase failed: %s", text)
+		}
+		var result answer
+		if err := json.Unmarshal([]byte(text), &result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return result
+	}
+	citations := func(result answer) string {
+		var cited []string
+		for _, evidence := range result.Evidence {
+			cited = append(cited, evidence.Citation)
+		}
+		return strings.Join(cited, " ")
+	}
+
+	// Definition and call site, each cited
+	result := ask(map[string]interface{}{"question": "Who calls Greet?"})
+	if !strings.Contains(citations(result), "greet.go:4-6") || !strings.Contains(citations(result), "run.go:4") {
+		t.Errorf("Expected the definition and the call of Greet, got %s", citations(result))
+	}
+	for _, evidence := range result.Evidence {
+		if evidence.FilePath == "run.go" && evidence.Type == "reference" && evidence.Name != "Run" {
+			t.Errorf("Expected the call cited in Run, got %+v", evidence)
+		}
+	}
+
+	result = ask(map[string]interface{}{"question": "where are the TODOs?", "max_results": 1})
+	if result.Count != 1 || result.Evidence[0].Citation != "run.go:5" || result.Evidence[0].Type != "match" {
+		t.Errorf("Expected the TODO line, got %+v", result.Evidence)
+	}
+
+	result = ask(map[string]interface{}{"question": "Who calls Greet?", "language": "python"})
+	if result.Count != 0 || result.Message == "" {
+		t.Errorf("Expected no evidence in python, got %+v", result.Evidence)
+	}
+
+	if _, isError := callTool(t, s, "ask_codebase", map[string]interface{}{"question": "Who calls Greet?", "max_results": 100}); !isError {
+		t.Error("Expected an error for max_results above the limit")
+	}
+}
@@ -0,0 +1,361 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/search"
+	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Editor buffer handlers and helpers that let tools see unsaved changes
+
+// handleSyncBuffer stores or clears the unsaved contents of a file for the
+// calling session
+func (s *MCPServer) handleSyncBuffer(ctx context.Context, request *session.SessionAwareRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling sync buffer", zap.String("tool", request.Request.Params.Name))
+
+	filePath, err := request.Request.RequireString("file_path")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+
+	resolvedPath := request.ResolvePath(filePath)
+	clear := s.getBooleanValueFromSession(request, "clear", false)
+
+	result := map[string]interface{}{
+		"success":    true,
+		"file_path":  filePath,
+		"session_id": request.Session.ID,
+	}
+
+	if clear {
+		removed := request.Session.ClearBuffer(resolvedPath)
+		result["cleared"] = removed
+		result["message"] = fmt.Sprintf("Buffer for %s cleared, reads use the file on disk", filePath)
+		if !removed {
+			result["message"] = fmt.Sprintf("No buffer was held for %s", filePath)
+		}
+	} else {
+		content, err := request.Request.RequireString("content")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid content parameter: %v (pass clear=true to drop a buffer)", err)), nil
+		}
+
+		buffer := request.Session.SetBuffer(resolvedPath, content)
+		result["buffer"] = buffer
+		result["lines"] = textpos.CountLines(content)
+		result["message"] = fmt.Sprintf("Buffer for %s synced (version %d)", filePath, buffer.Version)
+
+		s.log(ctx).Debug("Buffer synced",
+			zap.String("session_id", request.Session.ID),
+			zap.String("path", buffer.Path),
+			zap.Int("version", buffer.Version),
+			zap.Int("size", buffer.Size))
+	}
+
+	result["dirty_buffers"] = len(request.Session.Buffers())
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// readFileContent reads a file for the session behind a request, preferring
+// an unsaved editor buffer over the file on disk. Files on disk must lie
+// inside the repository manager's sandbox.
+func (s *MCPServer) readFileContent(request mcp.CallToolRequest, filePath string) ([]byte, string, error) {
+	if buffer, ok := s.sessionForRequest(request).GetBuffer(filePath); ok {
+		return []byte(buffer.Content), "buffer", nil
+	}
+
+	content, err := s.repoMgr.ReadFile(filePath)
+	if err != nil {
+		return nil, "", err
+	}
+	return content, "disk", nil
+}
+
+// bufferMatchesResult reports whether an index result refers to the file a
+// buffer holds. Index results carry repository-relative paths.
+func bufferMatchesResult(buffer *session.Buffer, result types.SearchResult) bool {
+	return bufferMatchesPath(buffer, result.FilePath)
+}
+
+// bufferMatchesPath reports whether an indexed file path refers to the file
+// a buffer holds
+func bufferMatchesPath(buffer *session.Buffer, filePath string) bool {
+	if filePath == "" {
+		return false
+	}
+	bufferPath := filepath.ToSlash(buffer.Path)
+	indexedPath := filepath.ToSlash(filePath)
+	return bufferPath == indexedPath || strings.HasSuffix(bufferPath, "/"+indexedPath)
+}
+
+// overlayBufferResults replaces index results for files the session has
+// unsaved buffers for with matches computed from the buffer contents.
+// When the query is scoped to a repository or to paths, buffers only
+// contribute if they displaced a result in that scope, since buffers carry
+// no repository-relative path of their own.
+func (s *MCPServer) overlayBufferResults(ctx context.Context, sess *session.Session, query types.SearchQuery, results []types.SearchResult) []types.SearchResult {
+	buffers := sess.Buffers()
+	if len(buffers) == 0 {
+		return results
+	}
+
+	merged := make([]types.SearchResult, 0, len(results))
+	displaced := make(map[string]types.SearchResult)
+	for _, result := range results {
+		shadowed := false
+		for _, buffer := range buffers {
+			if bufferMatchesResult(buffer, result) {
+				displaced[buffer.Path] = result
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			merged = append(merged, result)
+		}
+	}
+
+	for _, buffer := range buffers {
+		previous, wasDisplaced := displaced[buffer.Path]
+		scoped := len(query.RepositoryFilter()) > 0 || len(query.ProjectFilter()) > 0 || query.PathPrefix != "" || len(query.ExcludePaths) > 0
+		if scoped && !wasDisplaced {
+			continue
+		}
+
+		language := s.repoMgr.GetFileLanguage(buffer.Path)
+		if !query.AcceptsLanguage(language) {
+			continue
+		}
+
+		for _, match := range s.searchBuffer(ctx, buffer, language, query) {
+			if wasDisplaced {
+				match.FilePath = previous.FilePath
+				match.Repository = previous.Repository
+				match.RepositoryID = previous.RepositoryID
+				match.Project = previous.Project
+			}
+			merged = append(merged, match)
+		}
+	}
+
+	if query.MaxResults > 0 && len(merged) > query.MaxResults {
+		merged = merged[:query.MaxResults]
+	}
+
+	return merged
+}
+
+// overlayBufferDefinitions overlays the session's unsaved buffers on the
+// definitions of a symbol, keeping exact name matches only
+func (s *MCPServer) overlayBufferDefinitions(ctx context.Context, sess *session.Session, symbolName, symbolType, repository string, definitions []types.SearchResult) []types.SearchResult {
+	merged := s.overlayBufferResults(ctx, sess, definitionQuery(symbolName, symbolType, repository), definitions)
+	exact := make([]types.SearchResult, 0, len(merged))
+	for _, result := range merged {
+		if result.Name == symbolName {
+			exact = append(exact, result)
+		}
+	}
+	return exact
+}
+
+// overlayBufferReferences replaces indexed references in files the session
+// has unsaved buffers for with the references parsed from the buffers, as
+// overlayBufferResults does for search results. Only the page of references
+// is overlaid, so page totals still count the indexed references.
+func (s *MCPServer) overlayBufferReferences(ctx context.Context, sess *session.Session, refQuery types.ReferenceQuery, refs []types.ReferenceResult) []types.ReferenceResult {
+	buffers := sess.Buffers()
+	if len(buffers) == 0 {
+		return refs
+	}
+
+	merged := make([]types.ReferenceResult, 0, len(refs))
+	displaced := make(map[string]types.ReferenceResult)
+	for _, ref := range refs {
+		shadowed := false
+		for _, buffer := range buffers {
+			if bufferMatchesPath(buffer, ref.FilePath) {
+				displaced[buffer.Path] = ref
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			merged = append(merged, ref)
+		}
+	}
+
+	for _, buffer := range buffers {
+		previous, wasDisplaced := displaced[buffer.Path]
+		if refQuery.Repository != "" && !wasDisplaced {
+			continue
+		}
+
+		parsed, err := s.indexer.ParseContent(buffer.Path, buffer.Content)
+		if err != nil {
+			s.log(ctx).Debug("Failed to parse buffer", zap.String("path", buffer.Path), zap.Error(err))
+			continue
+		}
+
+		lines := textpos.SplitLines(buffer.Content)
+		for _, reference := range parsed.References {
+			if reference.Name != refQuery.Name || (len(refQuery.Kinds) > 0 && !slices.Contains(refQuery.Kinds, reference.Kind)) {
+				continue
+			}
+			match := types.ReferenceResult{
+				Reference: reference,
+				FilePath:  buffer.Path,
+				Language:  parsed.Language,
+			}
+			if wasDisplaced {
+				match.FilePath = previous.FilePath
+				match.Repository = previous.Repository
+				match.RepositoryID = previous.RepositoryID
+			}
+			if reference.Line > 0 && reference.Line <= len(lines) {
+				match.Context = strings.TrimSpace(lines[reference.Line-1])
+			}
+			merged = append(merged, match)
+		}
+	}
+
+	return merged
+}
+
+// identifierClass holds the characters of identifiers, as a regexp
+// character class without its brackets
+const identifierClass = `\p{L}\p{N}_$`
+
+// bufferMatcher returns whether a buffer's text matches a query the way the
+// index matches it: anywhere and ignoring case by default, in the query's
+// case with CaseSensitive, and only on identifier boundaries with WholeWord
+func bufferMatcher(query types.SearchQuery) func(text string) bool {
+	if !query.CaseSensitive && !query.WholeWord {
+		needle := strings.ToLower(query.Query)
+		return func(text string) bool {
+			return strings.Contains(strings.ToLower(text), needle)
+		}
+	}
+
+	words := regexp.MustCompile("["+identifierClass+"]+").FindAllString(query.Query, -1)
+	if len(words) == 0 {
+		return func(string) bool { return false }
+	}
+	flags := ""
+	if !query.CaseSensitive {
+		flags = "(?i)"
+	}
+	if query.WholeWord {
+		// The words in order, separated by anything but identifiers
+		quoted := make([]string, len(words))
+		for i, word := range words {
+			quoted[i] = regexp.QuoteMeta(word)
+		}
+		nonIdentifier := "[^" + identifierClass + "]"
+		pattern := regexp.MustCompile(flags + "(?:^|" + nonIdentifier + ")" +
+			strings.Join(quoted, nonIdentifier+"+") + "(?:$|" + nonIdentifier + ")")
+		return pattern.MatchString
+	}
+
+	// Every word anywhere, in the query's case
+	return func(text string) bool {
+		for _, word := range words {
+			if !strings.Contains(text, word) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// searchBuffer finds symbol and line matches for a query inside a buffer
+func (s *MCPServer) searchBuffer(ctx context.Context, buffer *session.Buffer, language string, query types.SearchQuery) []types.SearchResult {
+	var matches []types.SearchResult
+	if query.Syntax {
+		// Buffers are matched by the free text; qualifiers only apply to
+		// the index
+		query.Query = search.SyntaxText(query.Query)
+	}
+	if query.Query == "" {
+		return matches
+	}
+	matchesQuery := bufferMatcher(query)
+
+	newResult := func(resultType, name, content string, startLine, endLine int) types.SearchResult {
+		return types.SearchResult{
+			ID:        fmt.Sprintf("buffer:%s:%s:%d", buffer.Path, resultType, startLine),
+			FilePath:  buffer.Path,
+			Language:  language,
+			Type:      resultType,
+			Name:      name,
+			Content:   content,
+			Snippet:   content,
+			StartLine: startLine,
+			EndLine:   endLine,
+			Score:     1.0,
+			Context: map[string]any{
+				"source":         "buffer",
+				"buffer_version": buffer.Version,
+			},
+		}
+	}
+
+	// Symbol matches come from parsing the buffer
+	wantSymbols := query.AcceptsType("function") || query.AcceptsType("class") || query.AcceptsType("variable")
+	if wantSymbols {
+		if parsed, err := s.indexer.ParseContent(buffer.Path, buffer.Content); err == nil {
+			if query.AcceptsType("function") {
+				for _, function := range parsed.Functions {
+					if matchesQuery(function.Name) {
+						matches = append(matches, newResult("function", function.Name, function.Signature, function.StartLine, function.EndLine))
+					}
+				}
+			}
+			if query.AcceptsType("class") {
+				for _, class := range parsed.Classes {
+					if matchesQuery(class.Name) {
+						matches = append(matches, newResult("class", class.Name, class.Name, class.StartLine, class.EndLine))
+					}
+				}
+			}
+			if query.AcceptsType("variable") {
+				for _, variable := range parsed.Variables {
+					if matchesQuery(variable.Name) {
+						matches = append(matches, newResult("variable", variable.Name, strings.TrimSpace(variable.Name+" "+variable.Type), variable.StartLine, variable.EndLine))
+					}
+				}
+			}
+		} else {
+			s.log(ctx).Debug("Failed to parse buffer", zap.String("path", buffer.Path), zap.Error(err))
+		}
+	}
+
+	// Line matches stand in for content and chunk documents
+	wantContent := query.AcceptsType("content") || query.AcceptsType("chunk") || query.AcceptsType("file")
+	if wantContent {
+		for i, line := range textpos.SplitLines(buffer.Content) {
+			if matchesQuery(line) {
+				matches = append(matches, newResult("content", "", line, i+1, i+1))
+			}
+		}
+	}
+
+	return matches
+}
@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/indexer"
+	"github.com/my-mcp/code-indexer/internal/repository"
+	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// newBufferTestServer creates a server with just the components the buffer
+// overlay uses: language detection and parsing
+func newBufferTestServer(t *testing.T) *MCPServer {
+	t.Helper()
+	logger := zap.NewNop()
+	repoMgr, err := repository.NewManager(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("Failed to create repository manager: %v", err)
+	}
+	idx, err := indexer.New(config.DefaultConfig(), repoMgr, nil, logger)
+	if err != nil {
+		t.Fatalf("Failed to create indexer: %v", err)
+	}
+	return &MCPServer{logger: logger, repoMgr: repoMgr, indexer: idx}
+}
+
+func TestOverlayBufferResults(t *testing.T) {
+	s := newBufferTestServer(t)
+	bufferPath := filepath.Join(t.TempDir(), "repo", "pkg", "a.go")
+	indexed := []types.SearchResult{
+		{ID: "1", FilePath: "pkg/a.go", Repository: "repo", RepositoryID: "r1", Type: "function", Name: "OldHandler", StartLine: 3},
+		{ID: "2", FilePath: "pkg/b.go", Repository: "repo", RepositoryID: "r1", Type: "function", Name: "OtherHandler", StartLine: 5},
+	}
+
+	tests := []struct {
+		name      string
+		content   string // Buffer content, none when empty
+		query     types.SearchQuery
+		wantIDs   []string // Index results kept
+		wantLines []int    // Lines of the buffer's content matches
+	}{
+		{
+			name:    "no buffer",
+			query:   types.SearchQuery{Query: "handler"},
+			wantIDs: []string{"1", "2"},
+		},
+		{
+			name:      "buffer replaces stale result",
+			content:   "package pkg\n\nfunc NewHandler() {}\n\n// handler helpers\n",
+			query:     types.SearchQuery{Query: "handler", Types: []string{"content"}},
+			wantIDs:   []string{"2"},
+			wantLines: []int{3, 5},
+		},
+		{
+			name:      "buffer without matches drops the result",
+			content:   "package pkg\n",
+			query:     types.SearchQuery{Query: "handler", Types: []string{"content"}},
+			wantIDs:   []string{"2"},
+			wantLines: nil,
+		},
+		{
+			name:      "language filter excludes buffer",
+			content:   "package pkg\n\nfunc NewHandler() {}\n",
+			query:     types.SearchQuery{Query: "handler", Types: []string{"content"}, Language: "python"},
+			wantIDs:   []string{"2"},
+			wantLines: nil,
+		},
+		{
+			name:      "case sensitive",
+			content:   "package pkg\n\nfunc NewHandler() {}\n\n// handler helpers\n",
+			query:     types.SearchQuery{Query: "Handler", Types: []string{"content"}, CaseSensitive: true},
+			wantIDs:   []string{"2"},
+			wantLines: []int{3},
+		},
+		{
+			name:      "whole word",
+			content:   "package pkg\n\nfunc NewHandler() {}\n\n// handler helpers\n",
+			query:     types.SearchQuery{Query: "handler", Types: []string{"content"}, WholeWord: true},
+			wantIDs:   []string{"2"},
+			wantLines: []int{5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &session.Session{ID: "s1"}
+			if tt.content != "" {
+				sess.SetBuffer(bufferPath, tt.content)
+			}
+
+			merged := s.overlayBufferResults(context.Background(), sess, tt.query, append([]types.SearchResult(nil), indexed...))
+
+			var ids []string
+			var lines []int
+			for _, result := range merged {
+				if result.Context["source"] != "buffer" {
+					ids = append(ids, result.ID)
+					continue
+				}
+				if result.FilePath != "pkg/a.go" || result.Repository != "repo" || result.RepositoryID != "r1" {
+					t.Errorf("Expected buffer match to take the displaced result's location, got %+v", result)
+				}
+				lines = append(lines, result.StartLine)
+			}
+
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("Expected index results %v, got %v", tt.wantIDs, ids)
+			}
+			if !slices.Equal(lines, tt.wantLines) {
+				t.Errorf("Expected buffer matches on lines %v, got %v", tt.wantLines, lines)
+			}
+		})
+	}
+}
+
+func TestOverlayBufferResultsAfterClear(t *testing.T) {
+	s := newBufferTestServer(t)
+	bufferPath := filepath.Join(t.TempDir(), "a.go")
+	indexed := []types.SearchResult{{ID: "1", FilePath: "a.go", Type: "content", StartLine: 1}}
+	query := types.SearchQuery{Query: "handler", Types: []string{"content"}}
+
+	sess := &session.Session{ID: "s1"}
+	sess.SetBuffer(bufferPath, "package a\n")
+	if merged := s.overlayBufferResults(context.Background(), sess, query, indexed); len(merged) != 0 {
+		t.Fatalf("Expected the buffer to hide the stale result, got %+v", merged)
+	}
+
+	sess.ClearBuffer(bufferPath)
+	merged := s.overlayBufferResults(context.Background(), sess, query, indexed)
+	if len(merged) != 1 || merged[0].ID != "1" {
+		t.Errorf("Expected the index result once the buffer is cleared, got %+v", merged)
+	}
+}
+
+func TestOverlayBufferReferences(t *testing.T) {
+	s := newBufferTestServer(t)
+	bufferPath := filepath.Join(t.TempDir(), "repo", "store.go")
+	indexed := []types.ReferenceResult{
+		{Reference: types.Reference{Name: "validate", Kind: "call", Line: 4}, FilePath: "store.go", Repository: "repo", RepositoryID: "r1"},
+		{Reference: types.Reference{Name: "validate", Kind: "call", Line: 9}, FilePath: "other.go", Repository: "repo", RepositoryID: "r1"},
+	}
+	refQuery := types.ReferenceQuery{Name: "validate"}
+
+	// The unsaved buffer moved the call and added a second one
+	sess := &session.Session{ID: "s1"}
+	sess.SetBuffer(bufferPath, "package main\n\nfunc Save(name string) error {\n\tname = clean(name)\n\tif err := validate(name); err != nil {\n\t\treturn err\n\t}\n\treturn validate(name + \"!\")\n}\n")
+
+	merged := s.overlayBufferReferences(context.Background(), sess, refQuery, indexed)
+
+	var bufferLines []int
+	for _, ref := range merged {
+		switch ref.FilePath {
+		case "other.go":
+			if ref.Line != 9 {
+				t.Errorf("Expected the reference in other.go to be kept, got %+v", ref)
+			}
+		case "store.go":
+			if ref.Repository != "repo" || ref.Kind != "call" || ref.Caller != "Save" {
+				t.Errorf("Unexpected buffer reference: %+v", ref)
+			}
+			bufferLines = append(bufferLines, ref.Line)
+		default:
+			t.Errorf("Unexpected reference: %+v", ref)
+		}
+	}
+	if !slices.Equal(bufferLines, []int{5, 8}) {
+		t.Errorf("Expected buffer references on lines 5 and 8, got %v", bufferLines)
+	}
+	if len(merged) != 3 || merged[1].Context != "if err := validate(name); err != nil {" {
+		t.Errorf("Expected buffer references with their line as context, got %+v", merged)
+	}
+
+	sess.ClearBuffer(bufferPath)
+	if merged := s.overlayBufferReferences(context.Background(), sess, refQuery, indexed); len(merged) != 2 || merged[0].Line != 4 || merged[1].Line != 9 {
+		t.Errorf("Expected the indexed references once the buffer is cleared, got %+v", merged)
+	}
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// configuredSync is what syncing one repository of the configuration did
+type configuredSync struct {
+	Name     string `json:"name"`
+	Source   string `json:"source,omitempty"` // Directory or URL it is indexed from
+	JobID    string `json:"job_id,omitempty"` // Background job indexing it
+	Watching bool   `json:"watching"`         // Whether changes to its files re-index it
+	Error    string `json:"error,omitempty"`  // Why it was not queued or watched
+}
+
+// StartConfiguredRepositories queues the repositories of the configuration
+// for indexing in the background and starts watching those with watch set.
+// Repositories indexed before are brought up to date, re-indexing only the
+// files that changed.
+func (s *MCPServer) StartConfiguredRepositories() {
+	s.mutex.RLock()
+	repositories := s.config.Repositories
+	s.mutex.RUnlock()
+	if len(repositories) == 0 {
+		return
+	}
+
+	s.logger.Info("Indexing configured repositories", zap.Int("repositories", len(repositories)))
+	for _, result := range s.syncConfiguredRepositories(context.Background(), repositories) {
+		if result.Error != "" {
+			s.logger.Warn("Failed to sync configured repository", zap.String("repository", result.Name), zap.String("error", result.Error))
+		}
+	}
+}
+
+// handleSyncConfiguredRepositories reads the repositories section of the
+// configuration file again and applies it: every repository listed is
+// queued for indexing, and watchers are started for those with watch set
+// and stopped for the others
+func (s *MCPServer) handleSyncConfiguredRepositories(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling sync configured repositories", zap.String("tool", request.Params.Name))
+
+	repositories, file, err := s.readConfiguredRepositories()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read the configured repositories: %v", err)), nil
+	}
+	results := s.syncConfiguredRepositories(ctx, repositories)
+
+	queued, failed := 0, 0
+	for _, result := range results {
+		if result.JobID != "" {
+			queued++
+		}
+		if result.Error != "" {
+			failed++
+		}
+	}
+	message := fmt.Sprintf("Queued %d configured repositories for indexing; poll get_indexing_progress with their job IDs", queued)
+	if len(results) == 0 {
+		message = "No repositories are configured; list them under repositories in the configuration file"
+	}
+	result := map[string]interface{}{
+		"success":      failed == 0,
+		"config_file":  file,
+		"repositories": results,
+		"queued":       queued,
+		"failed":       failed,
+		"message":      message,
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// readConfiguredRepositories reads the repositories section of the
+// configuration file the server was started with again and keeps it in the
+// server's configuration; other sections only take effect on a restart.
+// Without a configuration file the repositories loaded at startup are
+// returned.
+func (s *MCPServer) readConfiguredRepositories() ([]config.RepositoryConfig, string, error) {
+	file := config.UsedConfigFile()
+	if file != "" {
+		cfg, err := config.Read(file)
+		if err != nil {
+			return nil, file, err
+		}
+		check := config.DefaultConfig()
+		check.Repositories = cfg.Repositories
+		if err := check.Validate(); err != nil {
+			return nil, file, err
+		}
+		s.mutex.Lock()
+		s.config.Repositories = cfg.Repositories
+		s.mutex.Unlock()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.config.Repositories, file, nil
+}
+
+// syncConfiguredRepositories queues the repositories for indexing, watches
+// those with watch set and stops watching every other repository
+func (s *MCPServer) syncConfiguredRepositories(ctx context.Context, repositories []config.RepositoryConfig) []configuredSync {
+	results := make([]configuredSync, 0, len(repositories))
+	watched := make(map[string]bool)
+	for _, repo := range repositories {
+		result := configuredSync{Name: repo.Name}
+		settings, dir, err := s.configuredSettings(repo)
+		if err == nil {
+			result.Source = settings.Source
+			var job types.IndexingJob
+			if job, err = s.jobs.Submit(settings); err == nil {
+				result.JobID = job.ID
+				s.trackIndexingJob(ctx, job.ID)
+			}
+		}
+		if err == nil && repo.Watch {
+			if err = s.watchRepository(repo.Name, dir, settings); err == nil {
+				result.Watching = true
+				watched[repo.Name] = true
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	s.stopWatchers(func(name string) bool { return !watched[name] })
+	return results
+}
+
+// configuredSettings returns the settings a configured repository is
+// indexed with, and its directory when it is a local one. The directory is
+// allowed for indexing, as the configuration names it.
+func (s *MCPServer) configuredSettings(repo config.RepositoryConfig) (types.RepositorySettings, string, error) {
+	settings := types.RepositorySettings{Source: repo.URL, Name: repo.Name, Ref: repo.Branch}
+	if len(repo.IncludePatterns) > 0 || len(repo.ExcludePatterns) > 0 {
+		settings.Filter = &types.FileFilterSettings{IncludePatterns: repo.IncludePatterns, ExcludePatterns: repo.ExcludePatterns}
+	}
+	if repo.ChunkStrategy != "" {
+		settings.Chunking = &types.ChunkingSettings{Strategy: repo.ChunkStrategy}
+	}
+	if repo.Path == "" {
+		return settings, "", nil
+	}
+
+	expanded, err := config.ExpandPath(repo.Path)
+	if err != nil {
+		return settings, "", fmt.Errorf("invalid path %s: %w", repo.Path, err)
+	}
+	dir, err := filepath.Abs(expanded)
+	if err != nil {
+		return settings, "", fmt.Errorf("invalid path %s: %w", repo.Path, err)
+	}
+	if err := s.repoMgr.AllowLocalRepositories(dir); err != nil {
+		return settings, "", err
+	}
+	settings.Source = dir
+	return settings, dir, nil
+}
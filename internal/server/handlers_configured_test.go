@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/indexer"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestSyncConfiguredRepositories(t *testing.T) {
+	watchDebounce = 50 * time.Millisecond
+	defer func() { watchDebounce = 2 * time.Second }()
+
+	root := t.TempDir()
+	files := map[string]string{
+		"api/main.go":            "package main\n\nfunc serveAPI() {}\n",
+		"api/gen/api.pb.go":      "package gen\n\nfunc generatedHandler() {}\n",
+		"docs/src/guide.py":      "def render_guide():\n    pass\n",
+		"docs/node_modules/x.js": "function vendored() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Repositories = []config.RepositoryConfig{
+			{Name: "api", Path: filepath.Join(root, "api"), ExcludePatterns: []string{"*.pb.go"}, Watch: true},
+			{Name: "docs", Path: filepath.Join(root, "docs"), ChunkStrategy: "line_based"},
+		}
+	})
+
+	waitForJob := func(id string) types.IndexingJob {
+		t.Helper()
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			job, err := s.jobs.Get(id)
+			if err != nil {
+				t.Fatalf("Expected job %s to be found: %v", id, err)
+			}
+			if job.CompletedAt != nil {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for job %s, last seen %+v", id, job)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	search := func(query string) int {
+		t.Helper()
+		text, isError := callTool(t, s, "find_symbols", map[string]interface{}{"symbol_name": query})
+		if isError {
+			t.Fatalf("find_symbols failed: %s", text)
+		}
+		var found struct {
+			Symbols []json.RawMessage `json:"symbols"`
+		}
+		if err := json.Unmarshal([]byte(text), &found); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		return len(found.Symbols)
+	}
+
+	text, isError := callTool(t, s, "sync_configured_repositories", nil)
+	if isError {
+		t.Fatalf("sync_configured_repositories failed: %s", text)
+	}
+	var synced struct {
+		Repositories []configuredSync `json:"repositories"`
+		Queued       int              `json:"queued"`
+	}
+	if err := json.Unmarshal([]byte(text), &synced); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if synced.Queued != 2 || !synced.Repositories[0].Watching || synced.Repositories[1].Watching {
+		t.Fatalf("Expected both repositories to be queued and api to be watched, got %s", text)
+	}
+	for _, result := range synced.Repositories {
+		if job := waitForJob(result.JobID); job.Status != indexer.JobCompleted {
+			t.Fatalf("Expected %s to be indexed, got %+v", result.Name, job)
+		}
+	}
+	if search("serveAPI") == 0 || search("generatedHandler") != 0 {
+		t.Error("Expected api to be indexed without the files its exclude_patterns match")
+	}
+	if settings, ok := s.indexer.RepositorySettings("docs"); !ok || settings.Chunking == nil || settings.Chunking.Strategy != "line_based" {
+		t.Errorf("Expected docs to be indexed with its chunking strategy, got %+v", settings)
+	}
+
+	// A change to a watched repository re-indexes it
+	if err := os.WriteFile(filepath.Join(root, "api", "health.go"), []byte("package main\n\nfunc checkHealth() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for search("checkHealth") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the watched repository to be re-indexed")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Repositories no longer watched are let go
+	s.config.Repositories[0].Watch = false
+	if text, isError := callTool(t, s, "sync_configured_repositories", nil); isError {
+		t.Fatalf("sync_configured_repositories failed: %s", text)
+	}
+	s.mutex.RLock()
+	watching := len(s.watchers)
+	s.mutex.RUnlock()
+	if watching != 0 {
+		t.Errorf("Expected watching to stop, got %d watchers", watching)
+	}
+}
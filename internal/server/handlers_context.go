@@ -0,0 +1,508 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/parser"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+const (
+	// defaultContextTokens is the token budget of get_context_bundle unless
+	// asked otherwise
+	defaultContextTokens = 4000
+
+	// maxContextTokens bounds the token budget of get_context_bundle
+	maxContextTokens = 100000
+
+	// contextNeighbors bounds the callers and callees considered for a
+	// context bundle, and contextChunks the related chunks
+	contextNeighbors = 10
+	contextChunks    = 10
+
+	// contextCallPage is how many call sites are read to find callers and
+	// callees
+	contextCallPage = 200
+)
+
+// contextItem is one piece of a context bundle
+type contextItem struct {
+	Kind       string  `json:"kind"` // "symbol", "imports", "callee", "caller", "chunk" or "definition"
+	Name       string  `json:"name,omitempty"`
+	SymbolType string  `json:"symbol_type,omitempty"`
+	Repository string  `json:"repository,omitempty"`
+	FilePath   string  `json:"file_path"`
+	Language   string  `json:"language,omitempty"`
+	StartLine  int     `json:"start_line,omitempty"`
+	EndLine    int     `json:"end_line,omitempty"`
+	Lines      []int   `json:"lines,omitempty"` // Call sites of a caller
+	DocString  string  `json:"doc_string,omitempty"`
+	Content    string  `json:"content"`
+	Score      float64 `json:"score,omitempty"`
+	Tokens     int     `json:"tokens"`
+	Truncated  bool    `json:"truncated,omitempty"`
+}
+
+// estimateTokens approximates the number of tokens a language model reads
+// for text, at about four characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// render formats the item as a Markdown section of the bundle's prompt text
+func (item *contextItem) render() string {
+	var b strings.Builder
+	location := item.FilePath
+	if item.StartLine > 0 {
+		location = fmt.Sprintf("%s:%d-%d", item.FilePath, item.StartLine, item.EndLine)
+	}
+	switch item.Kind {
+	case "symbol":
+		fmt.Fprintf(&b, "## %s %s (%s)\n", item.SymbolType, item.Name, location)
+	case "imports":
+		fmt.Fprintf(&b, "## Imports of %s\n", item.FilePath)
+	case "callee":
+		fmt.Fprintf(&b, "## Called: %s (%s)\n", item.Name, location)
+	case "definition":
+		fmt.Fprintf(&b, "## Referenced: %s %s (%s)\n", item.SymbolType, item.Name, location)
+	case "caller":
+		fmt.Fprintf(&b, "## Caller: %s (%s)\n", item.Name, location)
+	default:
+		fmt.Fprintf(&b, "## Related: %s\n", location)
+	}
+	if item.DocString != "" {
+		b.WriteString(item.DocString)
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "```%s\n%s\n```\n", item.Language, strings.TrimRight(item.Content, "\n"))
+	return b.String()
+}
+
+// handleGetContextBundle assembles the context a language model needs to
+// work on a symbol or answer a question: the symbol's code and doc string,
+// the imports it uses, what it calls and what calls it, and related chunks,
+// in that order of priority until the token budget is spent
+func (s *MCPServer) handleGetContextBundle(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling get context bundle", zap.String("tool", request.Params.Name))
+
+	symbolName := request.GetString("symbol_name", "")
+	question := request.GetString("query", "")
+	if symbolName == "" && question == "" {
+		return mcp.NewToolResultError("Either symbol_name or query is required"), nil
+	}
+	symbolType := request.GetString("symbol_type", "")
+	repository := request.GetString("repository", "")
+	language := request.GetString("language", "")
+	budget := int(request.GetFloat("max_tokens", defaultContextTokens))
+	if budget <= 0 || budget > maxContextTokens {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid max_tokens %d: use 1 to %d", budget, maxContextTokens)), nil
+	}
+
+	target, err := s.contextTarget(ctx, symbolName, symbolType, question, repository, language)
+	if err != nil {
+		s.log(ctx).Error("Failed to find the symbol", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+	if target == nil && question == "" {
+		return mcp.NewToolResultError(fmt.Sprintf("No definition of %s found; check the name or index its repository", symbolName)), nil
+	}
+
+	// Candidates in order of priority
+	var candidates []*contextItem
+	if target != nil {
+		symbol, imports := s.symbolContext(ctx, request, *target)
+		candidates = append(candidates, symbol)
+		if imports != nil {
+			candidates = append(candidates, imports)
+		}
+		candidates = append(candidates, s.calleeContext(ctx, *target)...)
+		candidates = append(candidates, s.callerContext(ctx, *target)...)
+	}
+	if question == "" {
+		question = symbolName
+	}
+	candidates = append(candidates, s.chunkContext(ctx, question, repository, language, target)...)
+
+	// The symbol itself is cut to the budget; everything else is taken
+	// whole if it fits
+	pack := packContext(candidates, budget, target != nil)
+
+	result := map[string]interface{}{
+		"symbol_name":   symbolName,
+		"query":         request.GetString("query", ""),
+		"token_budget":  budget,
+		"tokens_used":   pack.Tokens,
+		"items":         pack.Included,
+		"item_count":    len(pack.Included),
+		"omitted":       pack.Omitted,
+		"omitted_count": len(pack.Omitted),
+		"context":       pack.Text,
+	}
+	if target == nil {
+		result["message"] = "No symbol matches the query; the bundle holds related chunks only"
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// contextTarget returns the best ranked definition of the symbol, or the
+// best symbol matching the question when no name is given, or nil
+func (s *MCPServer) contextTarget(ctx context.Context, symbolName, symbolType, question, repository, language string) (*types.SearchResult, error) {
+	if symbolName != "" {
+		definitions, err := s.findDefinitions(ctx, symbolName, symbolType, repository)
+		if err != nil {
+			return nil, err
+		}
+		for _, definition := range definitions {
+			if language == "" || definition.Language == language {
+				return &definition, nil
+			}
+		}
+		return nil, nil
+	}
+
+	searchQuery := types.SearchQuery{
+		Query:      question,
+		Types:      types.SymbolTypes,
+		Repository: repository,
+		Language:   language,
+		MaxResults: 1,
+		Profile:    types.ProfileSymbols,
+	}
+	if symbolType != "" {
+		searchQuery.Types = []string{symbolType}
+	}
+	s.RankQuery(&searchQuery)
+	results, err := s.searcher.Search(ctx, searchQuery)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return &results[0], nil
+}
+
+// symbolContext returns the code and doc string of the target, read from
+// the session's buffer or from disk, and the imports of its file that the
+// code uses. The indexed signature stands in when the file cannot be read.
+func (s *MCPServer) symbolContext(ctx context.Context, request mcp.CallToolRequest, target types.SearchResult) (*contextItem, *contextItem) {
+	symbol := &contextItem{
+		Kind:       "symbol",
+		Name:       target.Name,
+		SymbolType: target.Type,
+		Repository: target.Repository,
+		FilePath:   target.FilePath,
+		Language:   target.Language,
+		StartLine:  target.StartLine,
+		EndLine:    target.EndLine,
+		Content:    target.Content,
+		Score:      target.Score,
+	}
+
+	fullPath, err := s.repositoryPath(target.Repository, target.FilePath)
+	if err != nil {
+		return symbol, nil
+	}
+	content, _, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Debug("Failed to read the symbol's file", zap.String("file", fullPath), zap.Error(err))
+		return symbol, nil
+	}
+	text := textpos.Split(string(content))
+	if lines, err := text.Lines(target.StartLine, max(target.EndLine, target.StartLine)); err == nil {
+		symbol.Content = strings.Join(lines, "\n")
+	}
+
+	symbol.DocString = declarationDocString(string(content), target)
+
+	parsed, err := s.indexer.ParseContent(fullPath, string(content))
+	if err != nil {
+		return symbol, nil
+	}
+
+	// Imports are relevant when the code names them by their alias or the
+	// last element of their path
+	var used []string
+	for _, imp := range parsed.Imports {
+		name := imp.Alias
+		if name == "" || name == "_" || name == "." {
+			name = path.Base(strings.ReplaceAll(imp.Module, ".", "/"))
+		}
+		if pattern, err := regexp.Compile(`\b` + regexp.QuoteMeta(name) + `\b`); err == nil && pattern.MatchString(symbol.Content) {
+			if line, ok := text.Line(imp.StartLine); ok {
+				used = append(used, strings.TrimSpace(line))
+			}
+		}
+	}
+	if len(used) == 0 {
+		return symbol, nil
+	}
+	return symbol, &contextItem{
+		Kind:       "imports",
+		Repository: target.Repository,
+		FilePath:   target.FilePath,
+		Language:   target.Language,
+		Content:    strings.Join(dedupeStrings(used), "\n"),
+	}
+}
+
+// declarationDocString returns the doc string of the declaration of the
+// target in its file's outline, the one named like it that starts closest
+// to it
+func declarationDocString(content string, target types.SearchResult) string {
+	outlineParser := parser.NewTreeSitterParser(target.Language)
+	if outlineParser == nil {
+		return ""
+	}
+	outline, err := outlineParser.Outline(content)
+	if err != nil {
+		return ""
+	}
+
+	doc, distance := "", -1
+	var visit func(nodes []parser.OutlineNode)
+	visit = func(nodes []parser.OutlineNode) {
+		for _, node := range nodes {
+			if node.Name == target.Name {
+				d := node.StartLine - target.StartLine
+				if d < 0 {
+					d = -d
+				}
+				if distance < 0 || d < distance {
+					doc, distance = node.DocString, d
+				}
+			}
+			visit(node.Children)
+		}
+	}
+	visit(outline)
+	return doc
+}
+
+// calleeContext returns the signatures of the symbols the target calls,
+// most called first
+func (s *MCPServer) calleeContext(ctx context.Context, target types.SearchResult) []*contextItem {
+	page, err := s.searcher.FindReferences(ctx, types.ReferenceQuery{
+		Caller:     target.Name,
+		Kinds:      []string{"call"},
+		Repository: target.Repository,
+		MaxResults: contextCallPage,
+	})
+	if err != nil {
+		s.log(ctx).Warn("Failed to find callees", zap.Error(err))
+		return nil
+	}
+
+	calls := make(map[string]int)
+	var names []string
+	for _, ref := range page.References {
+		if ref.FilePath != target.FilePath || ref.Line < target.StartLine || ref.Line > max(target.EndLine, target.StartLine) || ref.Name == target.Name {
+			continue
+		}
+		if calls[ref.Name] == 0 {
+			names = append(names, ref.Name)
+		}
+		calls[ref.Name]++
+	}
+	sort.SliceStable(names, func(i, j int) bool { return calls[names[i]] > calls[names[j]] })
+
+	var items []*contextItem
+	for _, name := range names {
+		if len(items) == contextNeighbors {
+			break
+		}
+		definitions, err := s.findDefinitions(ctx, name, "", target.Repository)
+		if err != nil || len(definitions) == 0 {
+			continue
+		}
+		definition := definitions[0]
+		items = append(items, &contextItem{
+			Kind:       "callee",
+			Name:       name,
+			SymbolType: definition.Type,
+			Repository: definition.Repository,
+			FilePath:   definition.FilePath,
+			Language:   definition.Language,
+			StartLine:  definition.StartLine,
+			EndLine:    definition.EndLine,
+			Content:    definition.Content,
+			Score:      definition.Score,
+		})
+	}
+	return items
+}
+
+// callerContext returns the call sites of the target grouped by calling
+// function, the callers with the most calls first
+func (s *MCPServer) callerContext(ctx context.Context, target types.SearchResult) []*contextItem {
+	page, err := s.searcher.FindReferences(ctx, types.ReferenceQuery{
+		Name:       target.Name,
+		Kinds:      []string{"call"},
+		Repository: target.Repository,
+		MaxResults: contextCallPage,
+	})
+	if err != nil {
+		s.log(ctx).Warn("Failed to find callers", zap.Error(err))
+		return nil
+	}
+
+	var items []*contextItem
+	index := make(map[string]*contextItem)
+	for _, ref := range page.References {
+		key := ref.Repository + "\x00" + ref.FilePath + "\x00" + ref.Caller
+		item, ok := index[key]
+		if !ok {
+			item = &contextItem{
+				Kind:       "caller",
+				Name:       ref.Caller,
+				Repository: ref.Repository,
+				FilePath:   ref.FilePath,
+				Language:   ref.Language,
+				StartLine:  ref.Line,
+			}
+			index[key] = item
+			items = append(items, item)
+		}
+		item.Lines = append(item.Lines, ref.Line)
+		item.EndLine = ref.Line
+		if item.Content != "" {
+			item.Content += "\n"
+		}
+		item.Content += strings.TrimSpace(ref.Context)
+	}
+	sort.SliceStable(items, func(i, j int) bool { return len(items[i].Lines) > len(items[j].Lines) })
+	if len(items) > contextNeighbors {
+		items = items[:contextNeighbors]
+	}
+	return items
+}
+
+// chunkContext returns the chunks most related to the question, by
+// embedding similarity when embeddings are enabled and by keywords
+// otherwise. Chunks within the target are left out.
+func (s *MCPServer) chunkContext(ctx context.Context, question, repository, language string, target *types.SearchResult) []*contextItem {
+	searchQuery := types.SearchQuery{
+		Query:      question,
+		Repository: repository,
+		Language:   language,
+		MaxResults: contextChunks,
+	}
+	var (
+		results []types.SearchResult
+		err     error
+	)
+	if s.embeddings != nil {
+		results, err = s.embeddings.Search(ctx, searchQuery)
+	} else {
+		searchQuery.Types = []string{"chunk"}
+		s.RankQuery(&searchQuery)
+		results, err = s.searcher.Search(ctx, searchQuery)
+	}
+	if err != nil {
+		s.log(ctx).Warn("Failed to find related chunks", zap.Error(err))
+		return nil
+	}
+
+	var items []*contextItem
+	for _, result := range results {
+		if target != nil && result.Repository == target.Repository && result.FilePath == target.FilePath &&
+			result.StartLine >= target.StartLine && result.EndLine <= max(target.EndLine, target.StartLine) {
+			continue
+		}
+		items = append(items, &contextItem{
+			Kind:       "chunk",
+			Name:       result.Name,
+			Repository: result.Repository,
+			FilePath:   result.FilePath,
+			Language:   result.Language,
+			StartLine:  result.StartLine,
+			EndLine:    result.EndLine,
+			Content:    result.Content,
+			Score:      result.Score,
+		})
+	}
+	return items
+}
+
+// contextPack is the part of the candidate items of a context that fits
+// its token budget
+type contextPack struct {
+	Included []*contextItem
+	Omitted  []map[string]interface{}
+	Tokens   int
+	Text     string // Renderings of the included items
+}
+
+// packContext takes the candidates, in order of priority, that fit the
+// budget whole. With truncateFirst the first candidate is cut to the budget
+// instead of being left out.
+func packContext(candidates []*contextItem, budget int, truncateFirst bool) *contextPack {
+	pack := &contextPack{}
+	var text strings.Builder
+	for i, item := range candidates {
+		rendered := item.render()
+		item.Tokens = estimateTokens(rendered)
+		if i == 0 && truncateFirst && item.Tokens > budget {
+			rendered = truncateContextItem(item, budget)
+		}
+		if pack.Tokens+item.Tokens > budget {
+			pack.Omitted = append(pack.Omitted, map[string]interface{}{
+				"kind": item.Kind, "name": item.Name, "file_path": item.FilePath, "tokens": item.Tokens,
+			})
+			continue
+		}
+		pack.Tokens += item.Tokens
+		pack.Included = append(pack.Included, item)
+		text.WriteString(rendered)
+		text.WriteString("\n")
+	}
+	pack.Text = strings.TrimRight(text.String(), "\n")
+	return pack
+}
+
+// truncateContextItem drops trailing lines of the item's content until its
+// rendering fits the budget, and returns the rendering
+func truncateContextItem(item *contextItem, budget int) string {
+	lines := strings.Split(item.Content, "\n")
+	item.Truncated = true
+	for len(lines) > 0 {
+		lines = lines[:len(lines)-1]
+		item.Content = strings.Join(lines, "\n")
+		if len(lines) > 0 {
+			item.Content += "\n…"
+		}
+		text := item.render()
+		if item.Tokens = estimateTokens(text); item.Tokens <= budget {
+			return text
+		}
+	}
+	item.DocString = ""
+	text := item.render()
+	item.Tokens = estimateTokens(text)
+	return text
+}
+
+// dedupeStrings returns values without repeats, in their first order
+func dedupeStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	unique := values[:0]
+	for _, value := range values {
+		if !seen[value] {
+			seen[value] = true
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
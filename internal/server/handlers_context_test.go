@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func TestGetContextBundle(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"greet.go": `package app
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Normalize trims and lowercases a name.
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Greet greets a user by name.
+func Greet(name string) string {
+	return fmt.Sprintf("hello %s", Normalize(name))
+}
+`,
+		"run.go": "package app\n\nfunc Run() {\n\tprintln(Greet(\"World\"))\n}\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	type bundle struct {
+		TokensUsed int `json:"tokens_used"`
+		Items      []struct {
+			Kind      string `json:"kind"`
+			Name      string `json:"name"`
+			DocString string `json:"doc_string"`
+			Content   string `json:"content"`
+			Truncated bool   `json:"truncated"`
+		} `json:"items"`
+		OmittedCount int    `json:"omitted_count"`
+		Context      string `json:"context"`
+	}
+	text, isError := callTool(t, s, "get_context_bundle", map[string]interface{}{"symbol_name": "Greet"})
+	if isError {
+		t.Fatalf("get_context_bundle failed: %s", text)
+	}
+	var full bundle
+	if err := json.Unmarshal([]byte(text), &full); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	kinds := make(map[string]string)
+	for _, item := range full.Items {
+		kinds[item.Kind] = item.Name
+		switch item.Kind {
+		case "symbol":
+			if !strings.Contains(item.DocString, "greets a user") || !strings.Contains(item.Content, "Normalize(name)") {
+				t.Errorf("Expected the code and doc string of Greet, got %+v", item)
+			}
+		case "imports":
+			if item.Content != `"fmt"` {
+				t.Errorf("Expected only the fmt import Greet uses, got %q", item.Content)
+			}
+		}
+	}
+	if kinds["symbol"] != "Greet" || kinds["callee"] != "Normalize" || kinds["caller"] != "Run" {
+		t.Errorf("Expected Greet with its callee Normalize and caller Run, got %s", text)
+	}
+	if !strings.HasPrefix(full.Context, "## function Greet (greet.go:14-16)") {
+		t.Errorf("Expected the rendering to start with Greet, got %q", full.Context)
+	}
+
+	text, isError = callTool(t, s, "get_context_bundle", map[string]interface{}{"symbol_name": "Greet", "max_tokens": 20})
+	if isError {
+		t.Fatalf("get_context_bundle failed: %s", text)
+	}
+	var small bundle
+	if err := json.Unmarshal([]byte(text), &small); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if len(small.Items) != 1 || !small.Items[0].Truncated || small.OmittedCount == 0 {
+		t.Errorf("Expected only the truncated symbol within 20 tokens, got %s", text)
+	}
+
+	if _, isError := callTool(t, s, "get_context_bundle", map[string]interface{}{"symbol_name": "Missing"}); !isError {
+		t.Error("Expected an unknown symbol to be rejected")
+	}
+}
@@ -4,16 +4,28 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"go.uber.org/zap"
 
+	"github.com/my-mcp/code-indexer/internal/chunking"
+	"github.com/my-mcp/code-indexer/internal/indexer"
+	"github.com/my-mcp/code-indexer/internal/repository"
 	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
 	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
 // Core tool handlers for indexing, search, and metadata operations
 
+// defaultSearchMaxResults is the search_code result limit when max_results
+// is not given
+const defaultSearchMaxResults = 100
+
 // handleIndexRepository handles repository indexing requests
 func (s *MCPServer) handleIndexRepository(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	path, err := request.RequireString("path")
@@ -22,13 +34,41 @@ func (s *MCPServer) handleIndexRepository(ctx context.Context, request mcp.CallT
 	}
 
 	name := request.GetString("name", "")
+	filter, err := s.getFileFilterSettings(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	chunkSettings, err := s.getChunkingSettings(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	cloneSettings, err := s.getCloneSettings(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	settings := types.RepositorySettings{Source: path, Name: name, Ref: request.GetString("ref", ""), Filter: filter, Chunking: chunkSettings, Clone: cloneSettings}
+
+	if s.getBooleanValue(request, "async", false) {
+		job, err := s.jobs.Submit(settings)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to queue indexing job: %v", err)), nil
+		}
+		s.trackIndexingJob(ctx, job.ID)
+		result := map[string]interface{}{
+			"success": true,
+			"job":     job,
+			"message": "Indexing job queued, poll get_indexing_progress with its ID",
+		}
+		resultJSON, _ := json.Marshal(result)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	}
 
-	s.logger.Info("Indexing repository", zap.String("path", path), zap.String("name", name))
+	s.log(ctx).Info("Indexing repository", zap.String("path", path), zap.String("name", name))
 
 	// Index the repository
-	repo, err := s.indexer.IndexRepository(ctx, path, name)
+	repo, err := s.indexer.IndexRepositoryWithSettings(s.withIndexingProgress(ctx, request), settings)
 	if err != nil {
-		s.logger.Error("Failed to index repository", zap.Error(err))
+		s.log(ctx).Error("Failed to index repository", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to index repository: %v", err)), nil
 	}
 
@@ -37,11 +77,79 @@ func (s *MCPServer) handleIndexRepository(ctx context.Context, request mcp.CallT
 		"repository": repo,
 		"message":    "Repository indexed successfully",
 	}
+	if runs := s.indexer.IndexingHistory(repo.ID, 1); len(runs) > 0 {
+		result["files_per_second"] = runs[0].FilesPerSecond
+	}
 
 	resultJSON, _ := json.Marshal(result)
 	return mcp.NewToolResultText(string(resultJSON)), nil
 }
 
+// getFileFilterSettings reads the per-repository file filtering overrides
+// of index_repository, returning nil when none are given
+func (s *MCPServer) getFileFilterSettings(request mcp.CallToolRequest) (*types.FileFilterSettings, error) {
+	filter := &types.FileFilterSettings{
+		MaxFileSize:     int64(request.GetFloat("max_file_size", 0)),
+		IncludePatterns: s.getStringList(request, "include_patterns"),
+		ExcludePatterns: s.getStringList(request, "exclude_patterns"),
+	}
+	if filter.MaxFileSize < 0 {
+		return nil, fmt.Errorf("Invalid max_file_size parameter: must not be negative")
+	}
+	for _, pattern := range append(append([]string{}, filter.IncludePatterns...), filter.ExcludePatterns...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid pattern %q: %v", pattern, err)
+		}
+	}
+	if filter.MaxFileSize == 0 && len(filter.IncludePatterns) == 0 && len(filter.ExcludePatterns) == 0 {
+		return nil, nil
+	}
+	return filter, nil
+}
+
+// getChunkingSettings reads the per-repository chunking overrides of
+// index_repository, returning nil when none are given
+func (s *MCPServer) getChunkingSettings(request mcp.CallToolRequest) (*types.ChunkingSettings, error) {
+	settings := &types.ChunkingSettings{
+		Strategy:       request.GetString("chunk_strategy", ""),
+		MaxChunkLines:  int(request.GetFloat("max_chunk_lines", 0)),
+		MaxChunkTokens: int(request.GetFloat("max_chunk_tokens", 0)),
+	}
+	switch chunking.ChunkingStrategy(settings.Strategy) {
+	case "", chunking.SemanticChunking, chunking.LineBasedChunking, chunking.HybridChunking, chunking.TokenBasedChunking:
+	default:
+		return nil, fmt.Errorf("Invalid chunk_strategy %q: use semantic, line_based, hybrid or token_based", settings.Strategy)
+	}
+	if settings.MaxChunkLines < 0 || settings.MaxChunkTokens < 0 {
+		return nil, fmt.Errorf("Invalid chunking parameters: max_chunk_lines and max_chunk_tokens must not be negative")
+	}
+	if settings.Strategy == "" && settings.MaxChunkLines == 0 && settings.MaxChunkTokens == 0 {
+		return nil, nil
+	}
+	return settings, nil
+}
+
+// getCloneSettings reads the per-repository clone overrides of
+// index_repository, returning nil when none are given
+func (s *MCPServer) getCloneSettings(request mcp.CallToolRequest) (*types.CloneSettings, error) {
+	settings := &types.CloneSettings{
+		Depth:        int(request.GetFloat("depth", 0)),
+		SingleBranch: s.getBooleanValue(request, "single_branch", false),
+	}
+	if settings.Depth < 0 {
+		return nil, fmt.Errorf("Invalid depth parameter: must not be negative")
+	}
+	sparse, err := repository.SparseDirectories(s.getStringList(request, "sparse_patterns"))
+	if err != nil {
+		return nil, fmt.Errorf("Invalid sparse_patterns parameter: %v", err)
+	}
+	settings.SparsePatterns = sparse
+	if settings.Depth == 0 && !settings.SingleBranch && len(settings.SparsePatterns) == 0 {
+		return nil, nil
+	}
+	return settings, nil
+}
+
 // handleIndexRepositorySession handles session-aware repository indexing requests
 func (s *MCPServer) handleIndexRepositorySession(ctx context.Context, request *session.SessionAwareRequest) (*mcp.CallToolResult, error) {
 	path, err := request.Request.RequireString("path")
@@ -50,20 +158,51 @@ func (s *MCPServer) handleIndexRepositorySession(ctx context.Context, request *s
 	}
 
 	name := request.Request.GetString("name", "")
+	filter, err := s.getFileFilterSettings(request.Request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	chunkSettings, err := s.getChunkingSettings(request.Request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	cloneSettings, err := s.getCloneSettings(request.Request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	// Resolve path relative to session workspace if needed
 	resolvedPath := request.ResolvePath(path)
+	settings := types.RepositorySettings{Source: resolvedPath, Name: name, Ref: request.Request.GetString("ref", ""), Filter: filter, Chunking: chunkSettings, Clone: cloneSettings}
 
-	s.logger.Info("Indexing repository (session-aware)",
+	s.log(ctx).Info("Indexing repository (session-aware)",
 		zap.String("path", path),
 		zap.String("resolved_path", resolvedPath),
 		zap.String("name", name),
 		zap.String("session_id", request.Session.ID))
 
+	if s.getBooleanValueFromSession(request, "async", false) {
+		job, err := s.jobs.Submit(settings)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to queue indexing job: %v", err)), nil
+		}
+		s.trackIndexingJob(ctx, job.ID)
+		result := map[string]interface{}{
+			"success":    true,
+			"job":        job,
+			"message":    "Indexing job queued, poll get_indexing_progress with its ID",
+			"session_id": request.Session.ID,
+			"workspace":  request.Session.WorkspaceDir,
+		}
+		resultJSON, _ := json.Marshal(result)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	}
+
 	// Index the repository using session-specific configuration
-	repo, err := s.indexer.IndexRepository(ctx, resolvedPath, name)
+	repo, err := s.indexer.IndexRepositoryWithSettings(s.withIndexingProgress(ctx, request.Request), settings)
 	if err != nil {
-		s.logger.Error("Failed to index repository", zap.Error(err))
+		s.log(ctx).Error("Failed to index repository", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to index repository: %v", err)), nil
 	}
 
@@ -74,13 +213,27 @@ func (s *MCPServer) handleIndexRepositorySession(ctx context.Context, request *s
 		"session_id": request.Session.ID,
 		"workspace":  request.Session.WorkspaceDir,
 	}
+	if runs := s.indexer.IndexingHistory(repo.ID, 1); len(runs) > 0 {
+		result["files_per_second"] = runs[0].FilesPerSecond
+	}
 
 	resultJSON, _ := json.Marshal(result)
 	return mcp.NewToolResultText(string(resultJSON)), nil
 }
 
-// handleSearchCode handles code search requests
+// handleSearchCode handles code search requests, recording them in the
+// history of the calling session
 func (s *MCPServer) handleSearchCode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.sessionForRequest(request).RecordSearch(session.SearchRecord{
+		Tool:      "search_code",
+		Arguments: s.searchArguments(request),
+		At:        time.Now(),
+	})
+	return s.searchCode(ctx, request)
+}
+
+// searchCode runs a search_code request
+func (s *MCPServer) searchCode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	query, err := request.RequireString("query")
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid query parameter: %v", err)), nil
@@ -89,35 +242,168 @@ func (s *MCPServer) handleSearchCode(ctx context.Context, request mcp.CallToolRe
 	searchType := request.GetString("type", "")
 	language := request.GetString("language", "")
 	repository := request.GetString("repository", "")
-	maxResults := int(request.GetFloat("max_results", 100))
+	maxResults := int(request.GetFloat("max_results", defaultSearchMaxResults))
+	includeFollowUps := s.getBooleanValue(request, "follow_ups", true)
+	hybrid := s.getBooleanValue(request, "hybrid", false)
+	if hybrid && s.embeddings == nil {
+		return mcp.NewToolResultError(errEmbeddingsDisabled), nil
+	}
 
-	s.logger.Info("Searching code", 
-		zap.String("query", query), 
-		zap.String("type", searchType),
-		zap.String("language", language),
-		zap.String("repository", repository),
-		zap.Int("max_results", maxResults))
+	minComplexity := int(request.GetFloat("min_complexity", 0))
+	maxComplexity := int(request.GetFloat("max_complexity", 0))
+	if minComplexity < 0 || maxComplexity < 0 || (maxComplexity > 0 && maxComplexity < minComplexity) {
+		return mcp.NewToolResultError("Invalid complexity range: min_complexity and max_complexity cannot be negative, and max_complexity cannot be below min_complexity"), nil
+	}
+	sortBy := request.GetString("sort_by", "relevance")
+	if sortBy != "relevance" && sortBy != "complexity" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid sort_by %q: use relevance or complexity", sortBy)), nil
+	}
+	profile := request.GetString("profile", "")
+	if profile != "" && !slices.Contains(types.ScoringProfiles, profile) {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid profile %q: use %s", profile, strings.Join(types.ScoringProfiles, ", "))), nil
+	}
 
-	// Perform the search
+	// page_size takes precedence over max_results
+	offset, pageSize, err := s.getPage(request, maxResults, 0)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid cursor parameter: %v", err)), nil
+	}
+	if hybrid && offset > 0 {
+		return mcp.NewToolResultError("Hybrid search returns a single page: cursor is not supported with hybrid=true"), nil
+	}
+
+	// Indexed paths use forward slashes, whichever the client sent
+	excludePaths := s.getStringList(request, "exclude_paths")
+	for i, path := range excludePaths {
+		excludePaths[i] = pathutil.ToSlash(path)
+	}
+
+	// Perform the search; list filters are ORed with the single-value ones
 	searchQuery := types.SearchQuery{
-		Query:      query,
-		Type:       searchType,
-		Language:   language,
-		Repository: repository,
-		MaxResults: maxResults,
+		Query:        query,
+		Type:         searchType,
+		Types:        s.getStringList(request, "types"),
+		Language:     language,
+		Languages:    s.getStringList(request, "languages"),
+		Repository:   repository,
+		Repositories: s.getStringList(request, "repositories"),
+		Ref:          request.GetString("ref", ""),
+		Project:      request.GetString("project", ""),
+		Projects:     s.getStringList(request, "projects"),
+		PathPrefix:   pathutil.ToSlash(request.GetString("path_prefix", "")),
+		ExcludePaths: excludePaths,
+		MaxResults:   pageSize,
+		Offset:       offset,
+
+		MinComplexity: minComplexity,
+		MaxComplexity: maxComplexity,
+		SortBy:        sortBy,
+		Profile:       profile,
+
+		CaseSensitive: s.getBooleanValue(request, "case_sensitive", false),
+		WholeWord:     s.getBooleanValue(request, "whole_word", false),
+		IncludeDeps:   s.getBooleanValue(request, "include_deps", false),
 	}
+	workspace, err := s.scopeToWorkspace(ctx, request, &searchQuery)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid workspace parameter: %v", err)), nil
+	}
+	s.RankQuery(&searchQuery)
+
+	if s.getBooleanValue(request, "regex", false) {
+		if hybrid {
+			return mcp.NewToolResultError("regex and hybrid cannot be combined"), nil
+		}
+		return s.searchRegex(ctx, searchQuery)
+	}
+	searchQuery.Syntax = s.getBooleanValue(request, "syntax", true)
 
-	results, err := s.searcher.Search(ctx, searchQuery)
+	s.log(ctx).Info("Searching code", 
+		zap.String("query", query), 
+		zap.Strings("types", searchQuery.TypeFilter()),
+		zap.Strings("languages", searchQuery.LanguageFilter()),
+		zap.Strings("repositories", searchQuery.RepositoryFilter()),
+		zap.Int("page_size", pageSize),
+		zap.Int("offset", offset),
+		zap.Bool("hybrid", hybrid))
+
+	page, err := s.searcher.SearchPage(ctx, searchQuery)
 	if err != nil {
-		s.logger.Error("Failed to search code", zap.Error(err))
+		s.log(ctx).Error("Failed to search code", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 	}
+	results := page.Results
+
+	if hybrid {
+		results, err = s.hybridSearch(ctx, searchQuery, results)
+		if err != nil {
+			s.log(ctx).Error("Failed to run semantic search", zap.Error(err))
+			return mcp.NewToolResultError(fmt.Sprintf("Semantic search failed: %v", err)), nil
+		}
+	}
+
+	// Prefer unsaved editor buffers over the indexed file contents
+	results = s.overlayBufferResults(ctx, s.sessionForRequest(request), searchQuery, results)
+
+	if includeFollowUps {
+		s.annotateFollowUps(ctx, results)
+	}
 
 	result := map[string]interface{}{
 		"query":   query,
 		"results": results,
 		"count":   len(results),
 	}
+	if workspace != "" {
+		result["workspace"] = workspace
+	}
+	if hybrid {
+		result["hybrid"] = true
+	} else {
+		addPage(result, pageSize, page.Total, page.NextCursor)
+	}
+
+	resultJSON, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// searchRegex runs a search_code query as a regular expression over the
+// indexed file contents, returning exact line and column spans
+func (s *MCPServer) searchRegex(ctx context.Context, searchQuery types.SearchQuery) (*mcp.CallToolResult, error) {
+	for _, docType := range searchQuery.TypeFilter() {
+		if docType != "file" {
+			return mcp.NewToolResultError(fmt.Sprintf("Regex search matches file contents only; type %q is not supported", docType)), nil
+		}
+	}
+
+	s.log(ctx).Info("Searching code by regex",
+		zap.String("pattern", searchQuery.Query),
+		zap.Strings("languages", searchQuery.LanguageFilter()),
+		zap.Strings("repositories", searchQuery.RepositoryFilter()),
+		zap.Int("page_size", searchQuery.MaxResults),
+		zap.Int("offset", searchQuery.Offset))
+
+	regexResult, err := s.searcher.SearchRegex(ctx, searchQuery)
+	if err != nil {
+		s.log(ctx).Error("Failed to search code by regex", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Regex search failed: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"query":            searchQuery.Query,
+		"regex":            true,
+		"matches":          regexResult.Matches,
+		"count":            len(regexResult.Matches),
+		"trigram_filtered": regexResult.TrigramFiltered,
+		"files_scanned":    regexResult.FilesScanned,
+	}
+	if regexResult.FilesUnverified > 0 {
+		result["files_unverified"] = regexResult.FilesUnverified
+	}
+	if regexResult.Truncated {
+		result["truncated"] = true
+	}
+	addPage(result, searchQuery.MaxResults, regexResult.Total, regexResult.NextCursor)
 
 	resultJSON, _ := json.Marshal(result)
 	return mcp.NewToolResultText(string(resultJSON)), nil
@@ -132,7 +418,7 @@ func (s *MCPServer) handleGetMetadata(ctx context.Context, request mcp.CallToolR
 
 	repository := request.GetString("repository", "")
 
-	s.logger.Info("Getting file metadata", zap.String("file_path", filePath), zap.String("repository", repository))
+	s.log(ctx).Info("Getting file metadata", zap.String("file_path", filePath), zap.String("repository", repository))
 
 	// Get file metadata (this would be implemented based on your search engine capabilities)
 	result := map[string]interface{}{
@@ -150,11 +436,11 @@ func (s *MCPServer) handleGetMetadata(ctx context.Context, request mcp.CallToolR
 
 // handleListRepositories handles repository listing requests
 func (s *MCPServer) handleListRepositories(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Listing repositories")
+	s.log(ctx).Info("Listing repositories")
 
-	repositories, err := s.searcher.ListRepositories(ctx)
+	repositories, err := s.indexer.ListRepositories(ctx)
 	if err != nil {
-		s.logger.Error("Failed to list repositories", zap.Error(err))
+		s.log(ctx).Error("Failed to list repositories", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to list repositories: %v", err)), nil
 	}
 
@@ -167,20 +453,326 @@ func (s *MCPServer) handleListRepositories(ctx context.Context, request mcp.Call
 	return mcp.NewToolResultText(string(resultJSON)), nil
 }
 
+// handleListProjects lists the sub-projects of a repository
+func (s *MCPServer) handleListProjects(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling list projects", zap.String("tool", request.Params.Name))
+
+	repository, err := request.RequireString("repository")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository parameter: %v", err)), nil
+	}
+
+	repo, projects, err := s.indexer.ListProjects(ctx, repository)
+	if err != nil {
+		s.log(ctx).Error("Failed to list projects", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list projects: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"repository": repo.Name,
+		"projects":   projects,
+		"count":      len(projects),
+	}
+	if len(projects) == 0 {
+		result["message"] = "No sub-projects were recorded: the repository has no go.mod, package.json, pom.xml or pyproject.toml, or was indexed before sub-projects were detected"
+	}
+
+	resultJSON, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
 // handleGetIndexStats handles index statistics requests
 func (s *MCPServer) handleGetIndexStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Getting index statistics")
+	s.log(ctx).Info("Getting index statistics")
 
-	stats, err := s.searcher.GetIndexStats(ctx)
+	stats, err := s.indexer.GetIndexStats(ctx)
 	if err != nil {
-		s.logger.Error("Failed to get index statistics", zap.Error(err))
+		s.log(ctx).Error("Failed to get index statistics", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to get index statistics: %v", err)), nil
 	}
 
 	result := map[string]interface{}{
 		"stats": stats,
 	}
+	if stats.UnknownLanguages.Files > 0 {
+		result["message"] = fmt.Sprintf("%d files have no detected language and were indexed with the generic parser; indexer.language_overrides assigns languages by pattern", stats.UnknownLanguages.Files)
+	}
 
 	resultJSON, _ := json.Marshal(result)
 	return mcp.NewToolResultText(string(resultJSON)), nil
 }
+
+// handleIndexingHistory handles indexing history requests
+func (s *MCPServer) handleIndexingHistory(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling indexing history", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	limit := int(request.GetFloat("limit", 10))
+
+	runs := s.indexer.IndexingHistory(repository, limit)
+
+	result := map[string]interface{}{
+		"repository": repository,
+		"runs":       runs,
+		"count":      len(runs),
+	}
+
+	// Compare the latest run against earlier ones so slowdowns stand out
+	if repository != "" {
+		if trends := phaseTrends(s.indexer.IndexingHistory(repository, 0)); trends != nil {
+			result["phase_trends"] = trends
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// handleGetIndexingProgress handles requests for the state of a background
+// indexing job
+func (s *MCPServer) handleGetIndexingProgress(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jobID, err := request.RequireString("job_id")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid job_id parameter: %v", err)), nil
+	}
+
+	job, err := s.jobs.Get(jobID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get indexing job %s: %v", jobID, err)), nil
+	}
+
+	resultJSON, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// handleCancelIndexing handles requests to cancel a background indexing job
+func (s *MCPServer) handleCancelIndexing(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	jobID, err := request.RequireString("job_id")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid job_id parameter: %v", err)), nil
+	}
+
+	job, err := s.jobs.Cancel(jobID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to cancel indexing job %s: %v", jobID, err)), nil
+	}
+
+	message := "Indexing job cancelled"
+	if job.Status == indexer.JobRunning {
+		message = "Indexing job is stopping; it is marked cancelled once the current file is done"
+	}
+	result := map[string]interface{}{
+		"success": true,
+		"job":     job,
+		"message": message,
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// handleRemoveRepository handles repository removal requests
+func (s *MCPServer) handleRemoveRepository(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := request.RequireString("repository")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository parameter: %v", err)), nil
+	}
+	deleteClone := s.getBooleanValue(request, "delete_clone", false)
+
+	s.log(ctx).Info("Removing repository", zap.String("repository", repository), zap.Bool("delete_clone", deleteClone))
+
+	removal, err := s.indexer.RemoveRepository(ctx, repository, deleteClone)
+	if err != nil {
+		s.log(ctx).Error("Failed to remove repository", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to remove repository %s: %v", repository, err)), nil
+	}
+
+	message := "Repository removed from the index"
+	if removal.CloneDeleted != "" {
+		message = "Repository removed from the index and its clone deleted"
+	} else if deleteClone {
+		message = "Repository removed from the index; it is not a clone, so its files were kept"
+	}
+	result := map[string]interface{}{
+		"success": true,
+		"removal": removal,
+		"message": message,
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// handleCleanupOrphans handles orphaned repository cleanup requests
+func (s *MCPServer) handleCleanupOrphans(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	dryRun := s.getBooleanValue(request, "dry_run", false)
+
+	orphans, err := s.indexer.CleanupOrphans(ctx, dryRun)
+	if err != nil {
+		s.log(ctx).Error("Failed to clean up orphaned repositories", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to clean up orphaned repositories: %v", err)), nil
+	}
+	if orphans == nil {
+		orphans = []types.OrphanedRepository{}
+	}
+
+	removed := 0
+	for _, orphan := range orphans {
+		if orphan.Removed {
+			removed++
+		}
+	}
+	result := map[string]interface{}{
+		"success": true,
+		"orphans": orphans,
+		"found":   len(orphans),
+		"removed": removed,
+		"dry_run": dryRun,
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// handleOptimizeIndex handles index compaction requests
+func (s *MCPServer) handleOptimizeIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Optimizing index")
+
+	optimization, err := s.indexer.OptimizeIndex(ctx)
+	if err != nil {
+		s.log(ctx).Error("Failed to optimize index", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to optimize index: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success":         true,
+		"optimization":    optimization,
+		"bytes_reclaimed": optimization.SizeBefore - optimization.SizeAfter,
+		"message":         "Index segments merged",
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// handleVerifyIndex handles index integrity check requests
+func (s *MCPServer) handleVerifyIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository := request.GetString("repository", "")
+	repair := s.getBooleanValue(request, "repair", false)
+
+	s.log(ctx).Info("Verifying index", zap.String("repository", repository), zap.Bool("repair", repair))
+
+	verification, err := s.indexer.VerifyIndex(ctx, repository, repair)
+	if err != nil {
+		s.log(ctx).Error("Failed to verify index", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to verify index: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success":      true,
+		"verification": verification,
+		"healthy":      len(verification.Issues) == 0,
+		"repaired":     repair && len(verification.Issues) > 0,
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// handleSwitchRef handles ref switching requests
+func (s *MCPServer) handleSwitchRef(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := request.RequireString("repository")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository parameter: %v", err)), nil
+	}
+	ref, err := request.RequireString("ref")
+	if err != nil || ref == "" {
+		return mcp.NewToolResultError("Invalid ref parameter: a branch, tag or commit is required"), nil
+	}
+
+	s.log(ctx).Info("Switching ref", zap.String("repository", repository), zap.String("ref", ref))
+
+	switched, err := s.indexer.SwitchRef(s.withIndexingProgress(ctx, request), repository, ref)
+	if err != nil {
+		s.log(ctx).Error("Failed to switch ref", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to switch %s to %s: %v", repository, ref, err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success": true,
+		"ref":     ref,
+		"result":  switched,
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// phaseTrends compares each phase of the newest completed run with the
+// average of the earlier completed runs that recorded it. Phases are matched
+// by name, as runs recorded by older versions may lack some. Runs are
+// expected newest first. It returns nil when there is nothing to compare
+// against.
+func phaseTrends(runs []types.IndexingRun) []map[string]interface{} {
+	var completed []types.IndexingRun
+	for _, run := range runs {
+		if run.Status == "completed" {
+			completed = append(completed, run)
+		}
+	}
+	if len(completed) < 2 {
+		return nil
+	}
+
+	latest := completed[0]
+	previous := completed[1:]
+
+	trends := make([]map[string]interface{}, 0, len(latest.Phases))
+	for _, phase := range latest.Phases {
+		var total float64
+		var compared int
+		for _, run := range previous {
+			for _, earlier := range run.Phases {
+				if earlier.Phase == phase.Phase {
+					total += earlier.DurationSeconds
+					compared++
+					break
+				}
+			}
+		}
+		if compared == 0 {
+			continue
+		}
+		average := total / float64(compared)
+
+		trend := map[string]interface{}{
+			"phase":            phase.Phase,
+			"latest_seconds":   phase.DurationSeconds,
+			"average_seconds":  average,
+			"compared_to_runs": compared,
+		}
+		if average > 0 {
+			trend["ratio"] = phase.DurationSeconds / average
+		}
+		trends = append(trends, trend)
+	}
+	return trends
+}
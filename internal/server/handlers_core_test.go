@@ -0,0 +1,698 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/indexer"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// callTool calls the handler of a registered tool and returns the text of
+// its result
+func callTool(t *testing.T, s *MCPServer, name string, args map[string]interface{}) (string, bool) {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = args
+	result, err := s.handlers[name](context.Background(), request)
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	return result.Content[0].(mcp.TextContent).Text, result.IsError
+}
+
+func TestIndexRepositoryAsync(t *testing.T) {
+	repoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{repoDir}
+	})
+
+	text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": repoDir, "name": "async", "async": true})
+	if isError {
+		t.Fatalf("Expected the job to be queued, got %s", text)
+	}
+	var queued struct {
+		Job types.IndexingJob `json:"job"`
+	}
+	if err := json.Unmarshal([]byte(text), &queued); err != nil || queued.Job.ID == "" {
+		t.Fatalf("Expected a job in the response, got %s", text)
+	}
+
+	var job types.IndexingJob
+	deadline := time.Now().Add(10 * time.Second)
+	for job.CompletedAt == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for job %s, last seen %+v", queued.Job.ID, job)
+		}
+		time.Sleep(10 * time.Millisecond)
+		text, isError = callTool(t, s, "get_indexing_progress", map[string]interface{}{"job_id": queued.Job.ID})
+		if isError {
+			t.Fatalf("Expected the job to be found, got %s", text)
+		}
+		if err := json.Unmarshal([]byte(text), &job); err != nil {
+			t.Fatalf("Invalid progress response: %v: %s", err, text)
+		}
+	}
+
+	if job.Status != indexer.JobCompleted || job.Repository == nil {
+		t.Fatalf("Expected the job to complete with a repository, got %+v", job)
+	}
+	if job.Progress.TotalFiles != 1 || job.Progress.FilesProcessed != 1 {
+		t.Errorf("Expected progress over one file, got %+v", job.Progress)
+	}
+	if job.Progress.FilesPerSecond <= 0 {
+		t.Errorf("Expected the throughput to be reported, got %+v", job.Progress)
+	}
+
+	if _, isError := callTool(t, s, "cancel_indexing", map[string]interface{}{"job_id": job.ID}); !isError {
+		t.Error("Expected cancelling a finished job to fail")
+	}
+	if _, isError := callTool(t, s, "get_indexing_progress", map[string]interface{}{"job_id": "missing"}); !isError {
+		t.Error("Expected an unknown job to be reported")
+	}
+}
+
+func TestRemoveRepositoryAndCleanupOrphans(t *testing.T) {
+	root := t.TempDir()
+	kept := filepath.Join(root, "kept")
+	gone := filepath.Join(root, "gone")
+	for _, dir := range []string{kept, gone} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	for name, dir := range map[string]string{"kept": kept, "gone": gone} {
+		if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": dir, "name": name}); isError {
+			t.Fatalf("Failed to index %s: %s", name, text)
+		}
+	}
+
+	if err := os.RemoveAll(gone); err != nil {
+		t.Fatal(err)
+	}
+	var cleanup struct {
+		Orphans []types.OrphanedRepository `json:"orphans"`
+		Removed int                        `json:"removed"`
+	}
+	text, isError := callTool(t, s, "cleanup_orphans", map[string]interface{}{"dry_run": true})
+	if err := json.Unmarshal([]byte(text), &cleanup); isError || err != nil {
+		t.Fatalf("Expected orphans to be reported, got %s", text)
+	}
+	if len(cleanup.Orphans) != 1 || cleanup.Orphans[0].Repository != "gone" || cleanup.Orphans[0].Reason != indexer.OrphanPathMissing || cleanup.Removed != 0 {
+		t.Fatalf("Expected only gone to be reported as orphaned, got %s", text)
+	}
+	if _, ok := s.indexer.IndexedRepository("gone"); !ok {
+		t.Fatal("Expected a dry run to keep the repository")
+	}
+
+	text, isError = callTool(t, s, "cleanup_orphans", nil)
+	if err := json.Unmarshal([]byte(text), &cleanup); isError || err != nil || cleanup.Removed != 1 {
+		t.Fatalf("Expected the orphan to be removed, got %s", text)
+	}
+	if _, ok := s.indexer.IndexedRepository("gone"); ok {
+		t.Error("Expected the orphaned repository to be forgotten")
+	}
+
+	var removed struct {
+		Removal types.RepositoryRemoval `json:"removal"`
+	}
+	text, isError = callTool(t, s, "remove_repository", map[string]interface{}{"repository": "kept", "delete_clone": true})
+	if err := json.Unmarshal([]byte(text), &removed); isError || err != nil {
+		t.Fatalf("Expected the repository to be removed, got %s", text)
+	}
+	if removed.Removal.DocumentsRemoved == 0 || removed.Removal.CloneDeleted != "" {
+		t.Errorf("Expected documents to be removed and the local repository kept, got %+v", removed.Removal)
+	}
+	if _, err := os.Stat(filepath.Join(kept, "main.go")); err != nil {
+		t.Errorf("Expected the local repository to be kept on disk, got %v", err)
+	}
+	if count, _ := s.searcher.CountDocuments(context.Background(), removed.Removal.RepositoryID); count != 0 {
+		t.Errorf("Expected no documents left, got %d", count)
+	}
+	if _, isError := callTool(t, s, "remove_repository", map[string]interface{}{"repository": "kept"}); !isError {
+		t.Error("Expected removing an unknown repository to fail")
+	}
+}
+
+func TestVerifyIndexRepairs(t *testing.T) {
+	repoDir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("util.go", "package main\n\nfunc helper() {}\n")
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{repoDir}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": repoDir, "name": "verify"}); isError {
+		t.Fatalf("Failed to index repository: %s", text)
+	}
+
+	var verified struct {
+		Verification types.IndexVerification `json:"verification"`
+		Healthy      bool                    `json:"healthy"`
+	}
+	verify := func(args map[string]interface{}) {
+		t.Helper()
+		text, isError := callTool(t, s, "verify_index", args)
+		verified.Verification = types.IndexVerification{}
+		if err := json.Unmarshal([]byte(text), &verified); isError || err != nil {
+			t.Fatalf("Expected the index to be verified, got %s", text)
+		}
+	}
+
+	verify(nil)
+	if !verified.Healthy || verified.Verification.FilesChecked != 2 {
+		t.Fatalf("Expected a healthy index of 2 files, got %+v", verified.Verification)
+	}
+
+	write("main.go", "package main\n\nfunc main() { helper() }\n")
+	write("new.go", "package main\n\nfunc added() {}\n")
+	if err := os.Remove(filepath.Join(repoDir, "util.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	verify(map[string]interface{}{"repository": "verify", "repair": true})
+	problems := make(map[string]string)
+	for _, issue := range verified.Verification.Issues {
+		problems[issue.FilePath] = issue.Problem
+	}
+	want := map[string]string{"main.go": indexer.IssueModified, "util.go": indexer.IssueDeleted, "new.go": indexer.IssueMissing}
+	for path, problem := range want {
+		if problems[path] != problem {
+			t.Errorf("Expected %s to be %s, got %v", path, problem, problems)
+		}
+	}
+	if verified.Verification.FilesRepaired != 3 {
+		t.Errorf("Expected 3 files repaired, got %+v", verified.Verification)
+	}
+
+	verify(nil)
+	if !verified.Healthy {
+		t.Errorf("Expected the repaired index to be healthy, got %+v", verified.Verification.Issues)
+	}
+
+	if _, isError := callTool(t, s, "optimize_index", nil); !isError {
+		t.Error("Expected optimizing an in-memory index to fail")
+	}
+}
+
+func TestSwitchRef(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	sourceDir := t.TempDir()
+	git := func(args ...string) {
+		args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = sourceDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(sourceDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "--quiet", "--initial-branch=main")
+	write("main.go", "package main\n\nfunc stableFunction() {}\n")
+	write("old.go", "package main\n\nfunc retiredFunction() {}\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "initial")
+	git("tag", "v1")
+	git("rm", "--quiet", "old.go")
+	write("new.go", "package main\n\nfunc introducedFunction() {}\n")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "replace old with new")
+
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{sourceDir}
+		cfg.Indexer.RepoDir = t.TempDir()
+	})
+	for _, ref := range []string{"v1", "main"} {
+		if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": sourceDir, "ref": ref}); isError {
+			t.Fatalf("Failed to index %s: %s", ref, text)
+		}
+	}
+	if head, err := exec.Command("git", "-C", sourceDir, "rev-parse", "--abbrev-ref", "HEAD").Output(); err != nil || string(head) != "main\n" {
+		t.Errorf("Expected the local working copy to stay on main, got %q (%v)", head, err)
+	}
+
+	// search returns the functions named query; the other functions match
+	// on the words their names share
+	search := func(query, ref string) []types.SearchResult {
+		t.Helper()
+		text, isError := callTool(t, s, "search_code", map[string]interface{}{"query": query, "type": "function", "ref": ref, "follow_ups": false})
+		var found struct {
+			Results []types.SearchResult `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(text), &found); isError || err != nil {
+			t.Fatalf("Search failed: %s", text)
+		}
+		var named []types.SearchResult
+		for _, result := range found.Results {
+			if result.Name == query {
+				named = append(named, result)
+			}
+		}
+		return named
+	}
+	if results := search("retiredFunction", "v1"); len(results) == 0 || results[0].Ref != "v1" {
+		t.Errorf("Expected the tagged ref to hold retiredFunction, got %+v", results)
+	}
+	if results := search("retiredFunction", "main"); len(results) != 0 {
+		t.Errorf("Expected main not to hold retiredFunction, got %+v", results)
+	}
+
+	name := filepath.Base(sourceDir) + "@v1"
+	text, isError := callTool(t, s, "switch_ref", map[string]interface{}{"repository": name, "ref": "main"})
+	if isError {
+		t.Fatalf("Failed to switch ref: %s", text)
+	}
+	var switched struct {
+		Result types.IncrementalIndexResult `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(text), &switched); err != nil {
+		t.Fatalf("Unexpected response: %s", text)
+	}
+	if switched.Result.Mode != "incremental" || switched.Result.FilesUpdated != 1 || switched.Result.FilesDeleted != 1 {
+		t.Errorf("Expected new.go to be indexed and old.go dropped, got %+v", switched.Result)
+	}
+	if results := search("retiredFunction", ""); len(results) != 0 {
+		t.Errorf("Expected retiredFunction to be gone after switching, got %+v", results)
+	}
+	if results := search("introducedFunction", "main"); len(results) != 2 {
+		t.Errorf("Expected both clones to be found at main, got %+v", results)
+	}
+
+	if _, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": sourceDir, "name": "local"}); isError {
+		t.Fatal("Failed to index local repository")
+	}
+	if _, isError := callTool(t, s, "switch_ref", map[string]interface{}{"repository": "local", "ref": "v1"}); !isError {
+		t.Error("Expected switching a local working copy to be refused")
+	}
+}
+
+func TestPhaseTrendsMatchesPhasesByName(t *testing.T) {
+	runs := []types.IndexingRun{
+		{Status: "completed", Phases: []types.PhaseTiming{
+			{Phase: "prepare", DurationSeconds: 1},
+			{Phase: "walk", DurationSeconds: 2},
+			{Phase: "references", DurationSeconds: 3},
+			{Phase: "parse", DurationSeconds: 8},
+		}},
+		{Status: "failed", Phases: []types.PhaseTiming{{Phase: "parse", DurationSeconds: 100}}},
+		// Recorded before reference counting was a phase of its own
+		{Status: "completed", Phases: []types.PhaseTiming{
+			{Phase: "prepare", DurationSeconds: 1},
+			{Phase: "walk", DurationSeconds: 2},
+			{Phase: "parse", DurationSeconds: 4},
+		}},
+	}
+
+	trends := phaseTrends(runs)
+	byPhase := make(map[string]map[string]interface{})
+	for _, trend := range trends {
+		byPhase[trend["phase"].(string)] = trend
+	}
+
+	if _, ok := byPhase["references"]; ok {
+		t.Error("Expected no trend for a phase earlier runs did not record")
+	}
+	parse, ok := byPhase["parse"]
+	if !ok {
+		t.Fatal("Expected a trend for the parse phase")
+	}
+	if parse["average_seconds"] != 4.0 || parse["ratio"] != 2.0 || parse["compared_to_runs"] != 1 {
+		t.Errorf("Expected parse to be compared with the earlier parse phase, got %v", parse)
+	}
+
+	if trends := phaseTrends(runs[:2]); trends != nil {
+		t.Errorf("Expected no trends with a single completed run, got %v", trends)
+	}
+}
+
+func TestListProjectsAndProjectFilter(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"tools/lint.go":                         "package tools\n\nfunc Handle() {}\n",
+		"services/api/go.mod":                   "module example.com/api\n\ngo 1.23\n",
+		"services/api/internal/handler.go":      "package internal\n\nfunc Handle() {}\n",
+		"services/web/package.json":             `{"name": "@example/web", "version": "1.0.0"}`,
+		"services/web/src/handle.js":            "function Handle() {}\n",
+		"services/billing/pom.xml":              "<project><parent><artifactId>platform</artifactId></parent><artifactId>billing</artifactId></project>",
+		"services/billing/src/main/Handle.java": "class Billing { void Handle() {} }\n",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "mono"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	text, isError := callTool(t, s, "list_projects", map[string]interface{}{"repository": "mono"})
+	if isError {
+		t.Fatalf("list_projects failed: %s", text)
+	}
+	var listed struct {
+		Projects []types.Project `json:"projects"`
+	}
+	if err := json.Unmarshal([]byte(text), &listed); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	names := make(map[string]string)
+	for _, project := range listed.Projects {
+		names[project.Path] = project.Name
+	}
+	want := map[string]string{"services/api": "example.com/api", "services/web": "@example/web", "services/billing": "billing"}
+	if len(names) != len(want) {
+		t.Errorf("Expected projects %v, got %v", want, names)
+	}
+	for path, name := range want {
+		if names[path] != name {
+			t.Errorf("Expected project %s to be named %q, got %q", path, name, names[path])
+		}
+	}
+
+	text, isError = callTool(t, s, "search_code", map[string]interface{}{
+		"query": "Handle", "projects": []interface{}{"services/api", "services/web"}, "follow_ups": false,
+	})
+	if isError {
+		t.Fatalf("search_code failed: %s", text)
+	}
+	var searched struct {
+		Results []types.SearchResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(text), &searched); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if len(searched.Results) == 0 {
+		t.Fatal("Expected results in the api and web projects")
+	}
+	for _, result := range searched.Results {
+		if result.Project != "services/api" && result.Project != "services/web" {
+			t.Errorf("Expected only results of the filtered projects, got %s in %q", result.FilePath, result.Project)
+		}
+	}
+}
+
+func TestIndexStructuredFiles(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"README.md":   "# Operations\n\n## Retries\nRequests are retried with backoff.\n",
+		"values.yaml": "http:\n  retry:\n    limit: 5\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "docs"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	tests := []struct {
+		query, docType, name, filePath string
+	}{
+		{"retry limit", "config_key", "http.retry.limit", "values.yaml"},
+		{"backoff", "section", "Retries", "README.md"},
+	}
+	for _, tt := range tests {
+		text, isError := callTool(t, s, "search_code", map[string]interface{}{"query": tt.query, "type": tt.docType, "follow_ups": false})
+		if isError {
+			t.Fatalf("search_code failed: %s", text)
+		}
+		var searched struct {
+			Results []types.SearchResult `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(text), &searched); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		if len(searched.Results) == 0 || searched.Results[0].Name != tt.name || searched.Results[0].FilePath != tt.filePath {
+			t.Errorf("Expected %s %q in %s first, got %s", tt.docType, tt.name, tt.filePath, text)
+		}
+	}
+}
+
+func TestIndexRepositoryChunkingOverrides(t *testing.T) {
+	root := t.TempDir()
+	var content strings.Builder
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&content, "// step %d of the rollout\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(root, "rollout.go"), []byte("package rollout\n\n"+content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "chunk_strategy": "paragraphs"}); !isError {
+		t.Fatalf("Expected an unknown chunk strategy to be rejected, got %s", text)
+	}
+	text, isError := callTool(t, s, "index_repository", map[string]interface{}{
+		"path": root, "name": "rollout", "chunk_strategy": "token_based", "max_chunk_tokens": 40,
+	})
+	if isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	text, isError = callTool(t, s, "search_code", map[string]interface{}{"query": "rollout", "type": "chunk", "max_results": 100, "follow_ups": false})
+	if isError {
+		t.Fatalf("search_code failed: %s", text)
+	}
+	var searched struct {
+		Results []types.SearchResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(text), &searched); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if len(searched.Results) < 5 {
+		t.Fatalf("Expected the file to be split into many chunks of at most 40 tokens, got %d", len(searched.Results))
+	}
+	for _, result := range searched.Results {
+		if lines := result.EndLine - result.StartLine + 1; lines > 6 {
+			t.Errorf("Expected chunks of a few lines, got lines %d-%d", result.StartLine, result.EndLine)
+		}
+	}
+
+	settings, ok := s.indexer.RepositorySettings("rollout")
+	if !ok || settings.Chunking == nil || settings.Chunking.Strategy != "token_based" || settings.Chunking.MaxChunkTokens != 40 {
+		t.Errorf("Expected the chunking overrides to be stored with the repository, got %+v", settings)
+	}
+}
+
+func TestIndexRepositorySparseClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	sourceDir := t.TempDir()
+	for name, content := range map[string]string{
+		"api/server.go": "package api\n\nfunc ServeOrders() {}\n",
+		"web/page.go":   "package web\n\nfunc RenderOrders() {}\n",
+	} {
+		path := filepath.Join(sourceDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"init", "--quiet", "--initial-branch=main"}, {"add", "."}, {"commit", "--quiet", "-m", "initial"}} {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = sourceDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{sourceDir}
+		cfg.Indexer.RepoDir = t.TempDir()
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": sourceDir, "ref": "main", "sparse_patterns": []interface{}{"../web"}}); !isError {
+		t.Fatalf("Expected a sparse pattern outside the repository to be rejected, got %s", text)
+	}
+	text, isError := callTool(t, s, "index_repository", map[string]interface{}{
+		"path": sourceDir, "name": "orders", "ref": "main", "depth": 1, "sparse_patterns": []interface{}{"api"},
+	})
+	if isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	text, isError = callTool(t, s, "search_code", map[string]interface{}{"query": "Orders", "type": "function", "follow_ups": false})
+	var found struct {
+		Results []types.SearchResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(text), &found); isError || err != nil {
+		t.Fatalf("Search failed: %s", text)
+	}
+	if len(found.Results) != 1 || found.Results[0].Name != "ServeOrders" {
+		t.Errorf("Expected only the checked out directory to be indexed, got %+v", found.Results)
+	}
+
+	settings, ok := s.indexer.RepositorySettings("orders")
+	if !ok || settings.Clone == nil || settings.Clone.Depth != 1 || len(settings.Clone.SparsePatterns) != 1 {
+		t.Errorf("Expected the clone overrides to be stored with the repository, got %+v", settings)
+	}
+	repo, _ := s.indexer.IndexedRepository("orders")
+	if repo.IndexingMode != "sparse" {
+		t.Errorf("Expected a sparse repository, got %s", repo.IndexingMode)
+	}
+}
+
+func TestFindSchemaSymbols(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"api/users.proto": "syntax = \"proto3\";\n\nmessage User {\n  string id = 1;\n}\n\nservice Users {\n  rpc GetUser(GetUserRequest) returns (User);\n}\n",
+		"db/schema.sql":   "CREATE TABLE users (\n  id BIGINT PRIMARY KEY\n);\n",
+		"client/user.go":  "package client\n\ntype User struct{}\n",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "schemas"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	tests := []struct {
+		name, symbolType, filePath string
+		startLine                  int
+	}{
+		{"User", "message", "api/users.proto", 3},
+		{"GetUser", "rpc", "api/users.proto", 8},
+		{"users", "table", "db/schema.sql", 1},
+	}
+	for _, tt := range tests {
+		text, isError := callTool(t, s, "find_symbols", map[string]interface{}{"symbol_name": tt.name, "symbol_type": tt.symbolType})
+		if isError {
+			t.Fatalf("find_symbols failed: %s", text)
+		}
+		var found struct {
+			Symbols []struct {
+				Name      string `json:"name"`
+				Type      string `json:"type"`
+				FilePath  string `json:"file_path"`
+				StartLine int    `json:"start_line"`
+			} `json:"symbols"`
+		}
+		if err := json.Unmarshal([]byte(text), &found); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		if len(found.Symbols) != 1 || found.Symbols[0].Name != tt.name || found.Symbols[0].Type != tt.symbolType ||
+			found.Symbols[0].FilePath != tt.filePath || found.Symbols[0].StartLine != tt.startLine {
+			t.Errorf("Expected only the %s %s of %s, got %s", tt.symbolType, tt.name, tt.filePath, text)
+		}
+	}
+}
+
+func TestRefreshSkipsUnchangedFiles(t *testing.T) {
+	repoDir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.go", "package main\n\nfunc Alpha() {}\n")
+	write("b.go", "package main\n\nfunc Beta() {}\n")
+	write("c.go", "package main\n\nfunc Gamma() {}\n")
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{repoDir}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": repoDir, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	write("b.go", "package main\n\nfunc Renamed() {}\n")
+	if err := os.Remove(filepath.Join(repoDir, "c.go")); err != nil {
+		t.Fatal(err)
+	}
+	refresh := func(args map[string]interface{}) map[string]int {
+		t.Helper()
+		text, isError := callTool(t, s, "refresh_index", args)
+		var response struct {
+			Files []map[string]interface{} `json:"files"`
+		}
+		if err := json.Unmarshal([]byte(text), &response); isError || err != nil || len(response.Files) != 1 {
+			t.Fatalf("Expected the file counts of one repository, got %s", text)
+		}
+		counts := make(map[string]int)
+		for key, value := range response.Files[0] {
+			if n, ok := value.(float64); ok {
+				counts[key] = int(n)
+			}
+		}
+		return counts
+	}
+
+	counts := refresh(map[string]interface{}{"repository": "app"})
+	if counts["files_parsed"] != 1 || counts["files_skipped"] != 1 || counts["files_deleted"] != 1 {
+		t.Errorf("Expected b.go parsed, a.go skipped and c.go deleted, got %v", counts)
+	}
+	for query, want := range map[string]bool{"Alpha": true, "Renamed": true, "Beta": false, "Gamma": false} {
+		results, err := s.searcher.Search(context.Background(), types.SearchQuery{Query: query, Type: "function", MaxResults: 10})
+		found := err == nil && slices.ContainsFunc(results, func(result types.SearchResult) bool { return result.Name == query })
+		if found != want {
+			t.Errorf("Expected %s to be found: %v, got %v (%v)", query, want, found, err)
+		}
+	}
+	if repo, _ := s.indexer.IndexedRepository("app"); repo.FileCount != 2 || repo.TotalLines != 6 {
+		t.Errorf("Expected 2 files of 6 lines in total, got %d files of %d lines", repo.FileCount, repo.TotalLines)
+	}
+
+	// Nothing changed, and force_rebuild parses every file all the same
+	if counts := refresh(map[string]interface{}{"repository": "app"}); counts["files_parsed"] != 0 || counts["files_skipped"] != 2 {
+		t.Errorf("Expected every file to be skipped, got %v", counts)
+	}
+	if counts := refresh(map[string]interface{}{"repository": "app", "force_rebuild": true}); counts["files_parsed"] != 2 || counts["files_skipped"] != 0 {
+		t.Errorf("Expected every file to be parsed, got %v", counts)
+	}
+}
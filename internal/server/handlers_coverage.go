@@ -0,0 +1,266 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/coverage"
+	"github.com/my-mcp/code-indexer/internal/depgraph"
+	"github.com/my-mcp/code-indexer/internal/parser"
+)
+
+// maxTestReferences bounds the test references listed per symbol
+const maxTestReferences = 5
+
+// relatedTestFile is a test file of a source file, with how it was found
+type relatedTestFile struct {
+	FilePath string `json:"file_path"`
+	Reason   string `json:"reason"` // "naming", "same_package" or "imports"
+}
+
+// testedSymbol is an exported symbol with the test lines that mention it
+type testedSymbol struct {
+	coverage.Symbol
+	Tested     bool                 `json:"tested"`
+	References []coverage.Reference `json:"references,omitempty"`
+}
+
+// handleAnalyzeTestCoverage finds the tests of a source file by naming
+// conventions and imports, the exported symbols they never mention and,
+// from a coverage report, how much of each function the tests run
+func (s *MCPServer) handleAnalyzeTestCoverage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling analyze test coverage", zap.String("tool", request.Params.Name))
+
+	sourceFile, err := request.RequireString("source_file")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid source_file parameter: %v", err)), nil
+	}
+	repository := request.GetString("repository", "")
+	testDirectory := filepath.ToSlash(request.GetString("test_directory", ""))
+	coverageFile := request.GetString("coverage_file", "")
+	includePrivate := s.getBooleanValue(request, "include_private", false)
+
+	fullPath, err := s.repositoryPath(repository, sourceFile)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid source_file parameter: %v", err)), nil
+	}
+	repo, ok := s.owningRepository(ctx, repository, fullPath)
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("%s is not in an indexed repository", sourceFile)), nil
+	}
+	root, err := s.repoMgr.ResolvePath(repo.Path)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+	}
+	relPath, err := filepath.Rel(root, fullPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid source_file parameter: %v", err)), nil
+	}
+	relPath = filepath.ToSlash(relPath)
+	if coverage.IsTestFile(relPath) {
+		return mcp.NewToolResultError(fmt.Sprintf("%s is a test file; pass the source file it tests", sourceFile)), nil
+	}
+
+	contentBytes, source, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for test coverage", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+	content := string(contentBytes)
+	language := s.repoMgr.GetFileLanguage(fullPath)
+	parsed, err := parser.NewRegistry().ParseFile(content, fullPath, language)
+	if err != nil {
+		s.log(ctx).Error("Failed to parse file for test coverage", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse file: %v", err)), nil
+	}
+	parsed.Language = language
+	symbols := coverage.Exported(parsed, content, includePrivate)
+
+	var warnings []string
+	testFiles := s.relatedTestFiles(ctx, repo.ID, root, relPath, testDirectory, &warnings)
+
+	// Mentions of each symbol in the test files
+	tested := make([]testedSymbol, 0, len(symbols))
+	for _, symbol := range symbols {
+		tested = append(tested, testedSymbol{Symbol: symbol})
+	}
+	for _, testFile := range testFiles {
+		testContent, err := s.repoMgr.ReadFile(filepath.Join(root, filepath.FromSlash(testFile.FilePath)))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Failed to read %s: %v", testFile.FilePath, err))
+			continue
+		}
+		for i := range tested {
+			for _, line := range coverage.References(string(testContent), tested[i].Name) {
+				tested[i].Tested = true
+				if len(tested[i].References) < maxTestReferences {
+					tested[i].References = append(tested[i].References, coverage.Reference{FilePath: testFile.FilePath, Line: line})
+				}
+			}
+		}
+	}
+	untested := []string{}
+	for _, symbol := range tested {
+		if !symbol.Tested {
+			untested = append(untested, symbol.QualifiedName())
+		}
+	}
+
+	result := map[string]interface{}{
+		"success":     true,
+		"repository":  repo.Name,
+		"source_file": relPath,
+		"language":    language,
+		"source":      source,
+		"test_files":  testFiles,
+		"symbols":     tested,
+		"untested":    untested,
+		"summary": map[string]interface{}{
+			"symbols":          len(tested),
+			"tested":           len(tested) - len(untested),
+			"untested":         len(untested),
+			"percent_tested":   coverage.Percent(len(tested)-len(untested), len(tested)),
+			"test_files_found": len(testFiles),
+		},
+	}
+
+	report, reportPath, err := s.coverageReport(ctx, root, coverageFile)
+	switch {
+	case err != nil:
+		return mcp.NewToolResultError(err.Error()), nil
+	case report != nil:
+		reportResult := map[string]interface{}{
+			"file":   reportPath,
+			"format": report.Format,
+		}
+		if file := report.File(relPath); file != nil {
+			covered, coverable := file.LineRate()
+			functions := file.Functions(symbols)
+			gaps := []coverage.FunctionCoverage{}
+			for _, function := range functions {
+				if function.CoveredLines < function.CoverableLines {
+					gaps = append(gaps, function)
+				}
+			}
+			sort.SliceStable(gaps, func(i, j int) bool {
+				return gaps[i].Percent < gaps[j].Percent
+			})
+			reportResult["covered_lines"] = covered
+			reportResult["coverable_lines"] = coverable
+			reportResult["percent"] = coverage.Percent(covered, coverable)
+			reportResult["functions"] = functions
+			reportResult["gaps"] = gaps
+		} else {
+			warnings = append(warnings, fmt.Sprintf("%s has no coverage for %s", reportPath, relPath))
+		}
+		result["coverage"] = reportResult
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// relatedTestFiles returns the test files of a source file: those named
+// after it, for Go the tests of its package, and the test files whose
+// recorded imports resolve to it
+func (s *MCPServer) relatedTestFiles(ctx context.Context, repositoryID, root, relPath, testDirectory string, warnings *[]string) []relatedTestFile {
+	seen := make(map[string]bool)
+	var testFiles []relatedTestFile
+	add := func(filePath, reason string) {
+		if !seen[filePath] {
+			seen[filePath] = true
+			testFiles = append(testFiles, relatedTestFile{FilePath: filePath, Reason: reason})
+		}
+	}
+	exists := func(filePath string) bool {
+		info, err := os.Stat(filepath.Join(root, filepath.FromSlash(filePath)))
+		return err == nil && !info.IsDir()
+	}
+
+	for _, candidate := range coverage.Candidates(relPath, testDirectory) {
+		if exists(candidate) {
+			add(candidate, "naming")
+		}
+	}
+	if path.Ext(relPath) == ".go" {
+		dir := path.Dir(relPath)
+		matches, _ := filepath.Glob(filepath.Join(root, filepath.FromSlash(dir), "*_test.go"))
+		sort.Strings(matches)
+		for _, match := range matches {
+			add(path.Join(dir, filepath.Base(match)), "same_package")
+		}
+	}
+
+	files, err := s.searcher.FileImports(ctx, repositoryID)
+	if err != nil {
+		s.log(ctx).Warn("Failed to read imports for test discovery", zap.Error(err))
+		*warnings = append(*warnings, fmt.Sprintf("Test files were not found by imports: %v", err))
+		return testFiles
+	}
+	graph := depgraph.Build(files)
+	if _, ok := graph.Node(relPath); ok {
+		for _, dependent := range graph.Dependents(relPath, 1) {
+			if coverage.IsTestFile(dependent.ID) && exists(dependent.ID) {
+				add(dependent.ID, "imports")
+			}
+		}
+	}
+	return testFiles
+}
+
+// coverageReport reads the coverage report at coverageFile, relative to
+// the repository root, or the first report found under one of the usual
+// names. It returns nil without an error when there is none.
+func (s *MCPServer) coverageReport(ctx context.Context, root, coverageFile string) (*coverage.Report, string, error) {
+	candidates := coverage.ReportFiles
+	if coverageFile != "" {
+		candidates = []string{filepath.ToSlash(coverageFile)}
+	}
+	for _, candidate := range candidates {
+		fullPath := filepath.Join(root, filepath.FromSlash(candidate))
+		if filepath.IsAbs(candidate) {
+			fullPath = candidate
+		}
+		resolved, err := s.repoMgr.ResolvePath(fullPath)
+		if err != nil {
+			if coverageFile != "" {
+				return nil, "", fmt.Errorf("invalid coverage_file parameter: %v", err)
+			}
+			continue
+		}
+		data, err := os.ReadFile(resolved)
+		if err != nil {
+			if coverageFile != "" {
+				return nil, "", fmt.Errorf("failed to read coverage report: %v", err)
+			}
+			continue
+		}
+		report, err := coverage.Parse(data)
+		if err != nil {
+			if coverageFile != "" {
+				return nil, "", fmt.Errorf("failed to parse coverage report %s: %v", candidate, err)
+			}
+			s.log(ctx).Debug("Skipping unrecognized coverage report", zap.String("path", resolved), zap.Error(err))
+			continue
+		}
+		return report, candidate, nil
+	}
+	return nil, "", nil
+}
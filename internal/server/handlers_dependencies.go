@@ -0,0 +1,389 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/chunking"
+	"github.com/my-mcp/code-indexer/internal/dependencies"
+	"github.com/my-mcp/code-indexer/internal/depgraph"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// findDependenciesMaxEdges bounds the edges find_dependencies returns for a
+// whole repository
+const findDependenciesMaxEdges = 2000
+
+// chunkGraphMaxEdges bounds the edges get_chunk_graph returns
+const chunkGraphMaxEdges = 2000
+
+// installCommands tell how the dependencies of each ecosystem are
+// installed, for packages index_dependencies finds no sources of
+var installCommands = map[string]string{
+	dependencies.EcosystemGo:     "go mod download",
+	dependencies.EcosystemNPM:    "npm install",
+	dependencies.EcosystemPython: "pip install -r requirements.txt in a virtual environment",
+}
+
+// handleFindDependencies builds the import graph of a repository from the
+// imports recorded in the index and returns it whole, or what a file or
+// package imports and is imported by, with the import cycles
+func (s *MCPServer) handleFindDependencies(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling find dependencies", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	filePath := request.GetString("file_path", "")
+	if repository == "" && filePath == "" {
+		return mcp.NewToolResultError("Either repository or file_path is required"), nil
+	}
+	format := request.GetString("format", "json")
+	if format != "json" && format != "dot" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid format %q: use json or dot", format)), nil
+	}
+	direction := request.GetString("direction", "both")
+	if direction != "both" && direction != "dependencies" && direction != "dependents" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid direction %q: use dependencies, dependents or both", direction)), nil
+	}
+	depth := int(request.GetFloat("depth", 1))
+	if depth < 0 {
+		return mcp.NewToolResultError("depth must be 0 (no limit) or more"), nil
+	}
+	includeExternal := s.getBooleanValue(request, "include_external", false)
+
+	// The node to focus on, relative to the repository
+	focus, isDir := "", false
+	if filePath != "" {
+		fullPath, err := s.repositoryPath(repository, filePath)
+		if err != nil {
+			return toolErrorResult(err), nil
+		}
+		if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+		}
+		repo, ok := s.owningRepository(ctx, repository, fullPath)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is not in an indexed repository", filePath)), nil
+		}
+		root, err := s.repoMgr.ResolvePath(repo.Path)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+		repository = repo.Name
+		focus, _ = filepath.Rel(root, fullPath)
+		focus = filepath.ToSlash(focus)
+		isDir = info.IsDir()
+	}
+
+	level := request.GetString("level", "")
+	switch {
+	case level == "" && (filePath == "" || isDir):
+		level = depgraph.KindPackage
+	case level == "":
+		level = depgraph.KindFile
+	case level != depgraph.KindFile && level != depgraph.KindPackage:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid level %q: use file or package", level)), nil
+	case level == depgraph.KindFile && isDir:
+		return mcp.NewToolResultError(fmt.Sprintf("%s is a directory; use level=package", filePath)), nil
+	}
+	if level == depgraph.KindPackage && focus != "" && !isDir {
+		focus = path.Dir(focus)
+	}
+
+	repo, ok := s.indexer.IndexedRepository(repository)
+	if !ok {
+		return repoNotFound(repository), nil
+	}
+	files, err := s.searcher.FileImports(ctx, repo.ID)
+	if err != nil {
+		s.log(ctx).Error("Failed to read imports", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read imports: %v", err)), nil
+	}
+
+	var warnings []string
+	recorded := false
+	for _, file := range files {
+		recorded = recorded || file.Module != "" || len(file.Imports) > 0
+	}
+	if len(files) > 0 && !recorded {
+		warnings = append(warnings, fmt.Sprintf("No imports are recorded for %s; it was indexed by an older version, re-index it with index_repository to build its graph", repo.Name))
+	}
+
+	graph := depgraph.Build(files)
+	if !includeExternal {
+		graph = graph.WithoutExternal()
+	}
+	if level == depgraph.KindPackage {
+		graph = graph.Packages()
+	}
+	cycles := graph.Cycles()
+
+	result := map[string]interface{}{
+		"success":    true,
+		"repository": repo.Name,
+		"level":      level,
+		"format":     format,
+	}
+	if focus != "" {
+		if _, ok := graph.Node(focus); !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is not in the import graph of %s; only indexed source files are", filePath, repo.Name)), nil
+		}
+		nodes := map[string]bool{focus: true}
+		result["node"] = focus
+		result["direction"] = direction
+		result["depth"] = depth
+		if direction != "dependents" {
+			dependencies := graph.Dependencies(focus, depth)
+			for _, reached := range dependencies {
+				nodes[reached.ID] = true
+			}
+			result["dependencies"] = dependencies
+		}
+		if direction != "dependencies" {
+			dependents := graph.Dependents(focus, depth)
+			for _, reached := range dependents {
+				nodes[reached.ID] = true
+			}
+			result["dependents"] = dependents
+		}
+		involved := []depgraph.Cycle{}
+		for _, cycle := range cycles {
+			for _, id := range cycle.Nodes {
+				if id == focus {
+					involved = append(involved, cycle)
+					break
+				}
+			}
+		}
+		result["cycles"] = involved
+		graph = graph.Subgraph(nodes)
+	} else {
+		result["cycles"] = cycles
+		counts := map[string]int{"edges": len(graph.Edges), "cycles": len(cycles)}
+		for _, node := range graph.Nodes {
+			counts[node.Kind]++
+		}
+		result["counts"] = counts
+	}
+
+	if format == "dot" {
+		result["dot"] = graph.DOT(repo.Name, focus)
+	} else {
+		if len(graph.Edges) > findDependenciesMaxEdges {
+			graph.Edges = graph.Edges[:findDependenciesMaxEdges]
+			result["truncated"] = true
+		}
+		result["graph"] = graph
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// handleGetChunkGraph returns the chunk-level dependency graph of a
+// repository, or the part of it around the chunks of a file, from the
+// symbols recorded for each chunk when it was indexed
+func (s *MCPServer) handleGetChunkGraph(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling get chunk graph", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	filePath := request.GetString("file_path", "")
+	if repository == "" && filePath == "" {
+		return mcp.NewToolResultError("Either repository or file_path is required"), nil
+	}
+
+	// The file to focus on, relative to the repository
+	focus := ""
+	if filePath != "" {
+		fullPath, err := s.repositoryPath(repository, filePath)
+		if err != nil {
+			return toolErrorResult(err), nil
+		}
+		if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+		}
+		repo, ok := s.owningRepository(ctx, repository, fullPath)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is not in an indexed repository", filePath)), nil
+		}
+		root, err := s.repoMgr.ResolvePath(repo.Path)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+		repository = repo.Name
+		focus, _ = filepath.Rel(root, fullPath)
+		focus = filepath.ToSlash(focus)
+	}
+
+	repo, ok := s.indexer.IndexedRepository(repository)
+	if !ok {
+		return repoNotFound(repository), nil
+	}
+	chunks, err := s.searcher.ChunkDependencies(ctx, repo.ID)
+	if err != nil {
+		s.log(ctx).Error("Failed to read chunk dependencies", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read chunk dependencies: %v", err)), nil
+	}
+
+	var warnings []string
+	recorded := false
+	for _, chunk := range chunks {
+		recorded = recorded || len(chunk.Dependencies) > 0
+	}
+	if len(chunks) > 0 && !recorded {
+		warnings = append(warnings, fmt.Sprintf("No chunk dependencies are recorded for %s; it was indexed by an older version, re-index it with index_repository to build its graph", repo.Name))
+	}
+
+	graph := chunking.BuildGraph(chunks)
+	result := map[string]interface{}{
+		"success":    true,
+		"repository": repo.Name,
+	}
+	if focus != "" {
+		graph = graph.Around(focus)
+		inFile := false
+		for _, node := range graph.Nodes {
+			inFile = inFile || node.FilePath == focus
+		}
+		if !inFile {
+			return mcp.NewToolResultError(fmt.Sprintf("%s has no indexed chunks in %s", filePath, repo.Name)), nil
+		}
+		result["file_path"] = focus
+	}
+	result["counts"] = map[string]int{"nodes": len(graph.Nodes), "edges": len(graph.Edges)}
+	if len(graph.Edges) > chunkGraphMaxEdges {
+		graph.Edges = graph.Edges[:chunkGraphMaxEdges]
+		result["truncated"] = true
+	}
+	result["graph"] = graph
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// handleIndexDependencies resolves the dependencies a repository declares
+// to their installed sources and lists them, or indexes the packages asked
+// for into the dependency namespace, which searches leave out unless
+// include_deps is set
+func (s *MCPServer) handleIndexDependencies(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	repository, err := request.RequireString("repository")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository parameter: %v", err)), nil
+	}
+	selected := s.getStringList(request, "packages")
+
+	repo, ok := s.indexer.IndexedRepository(repository)
+	if !ok {
+		return repoNotFound(repository), nil
+	}
+	packages, err := dependencies.Resolve(repo.Path)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read the dependencies of %s: %v", repo.Name, err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success":    true,
+		"repository": repo.Name,
+		"namespace":  types.DependencyNamespace,
+	}
+	if len(selected) == 0 {
+		installed := 0
+		for _, pkg := range packages {
+			if pkg.Dir != "" {
+				installed++
+			}
+		}
+		if packages == nil {
+			packages = []dependencies.Package{}
+		}
+		result["packages"] = packages
+		result["counts"] = map[string]int{"packages": len(packages), "installed": installed}
+		result["message"] = "Name the packages to index in packages"
+		response, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError("Failed to format response"), nil
+		}
+		return mcp.NewToolResultText(string(response)), nil
+	}
+
+	s.log(ctx).Info("Indexing dependencies", zap.String("repository", repo.Name), zap.Strings("packages", selected))
+
+	indexed := []map[string]interface{}{}
+	var unknown []string
+	notifier := s.newProgressNotifier(ctx, request)
+	for _, name := range selected {
+		found := false
+		for _, pkg := range packages {
+			if !pkg.Matches(name) {
+				continue
+			}
+			found = true
+			entry := map[string]interface{}{
+				"ecosystem":  pkg.Ecosystem,
+				"package":    pkg.Name,
+				"version":    pkg.Version,
+				"repository": pkg.RepositoryName(),
+			}
+			indexed = append(indexed, entry)
+			if pkg.Dir == "" {
+				entry["status"] = "not_installed"
+				entry["error"] = fmt.Sprintf("No sources found; install the dependencies of %s with %s", repo.Name, installCommands[pkg.Ecosystem])
+				continue
+			}
+
+			// The sources lie outside the allowed roots, in the module cache
+			// or the repository's own node_modules or virtual environment
+			settings := types.RepositorySettings{Source: pkg.Dir, Name: pkg.RepositoryName(), Namespace: types.DependencyNamespace}
+			err := s.repoMgr.AllowLocalRepositories(pkg.Dir)
+			var dependency *types.Repository
+			if err == nil {
+				dependency, err = s.indexer.IndexRepositoryWithSettings(notifier.repository(ctx), settings)
+			}
+			if err != nil {
+				s.log(ctx).Warn("Failed to index dependency", zap.String("package", pkg.Name), zap.Error(err))
+				entry["status"] = "failed"
+				entry["error"] = err.Error()
+				continue
+			}
+			entry["status"] = "indexed"
+			entry["repository_id"] = dependency.ID
+			entry["file_count"] = dependency.FileCount
+		}
+		if !found {
+			unknown = append(unknown, name)
+		}
+	}
+
+	result["packages"] = indexed
+	if len(unknown) > 0 {
+		result["unknown"] = unknown
+		result["message"] = fmt.Sprintf("%d of the packages are not dependencies of %s; call without packages to list them", len(unknown), repo.Name)
+	}
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/chunking"
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func TestGetChunkGraph(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"greet.go": "package app\n\nimport \"strings\"\n\nfunc Normalize(name string) string {\n\treturn strings.ToLower(name)\n}\n\nfunc Greet(name string) string {\n\treturn \"hello \" + Normalize(name)\n}\n",
+		"run.go":   "package app\n\nfunc Run() {\n\tprintln(Greet(\"World\"))\n}\n",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	type response struct {
+		Graph chunking.Graph `json:"graph"`
+	}
+	edgesOf := func(graph chunking.Graph) map[[2]string]bool {
+		names := make(map[string]string)
+		for _, node := range graph.Nodes {
+			names[node.ID] = node.Name
+		}
+		edges := make(map[[2]string]bool)
+		for _, edge := range graph.Edges {
+			edges[[2]string{names[edge.From], names[edge.To]}] = true
+		}
+		return edges
+	}
+
+	text, isError := callTool(t, s, "get_chunk_graph", map[string]interface{}{"repository": "app"})
+	if isError {
+		t.Fatalf("get_chunk_graph failed: %s", text)
+	}
+	var whole response
+	if err := json.Unmarshal([]byte(text), &whole); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	edges := edgesOf(whole.Graph)
+	if !edges[[2]string{"Greet", "Normalize"}] || !edges[[2]string{"Run", "Greet"}] {
+		t.Errorf("Expected Greet to depend on Normalize and Run on Greet, got %s", text)
+	}
+	for _, node := range whole.Graph.Nodes {
+		if node.Name == "Normalize" && (len(node.Imports) != 1 || node.Imports[0] != "strings") {
+			t.Errorf("Expected Normalize to use strings, got %+v", node)
+		}
+	}
+
+	text, isError = callTool(t, s, "get_chunk_graph", map[string]interface{}{"repository": "app", "file_path": "run.go"})
+	if isError {
+		t.Fatalf("get_chunk_graph failed: %s", text)
+	}
+	var around response
+	if err := json.Unmarshal([]byte(text), &around); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if edges := edgesOf(around.Graph); len(edges) != 1 || !edges[[2]string{"Run", "Greet"}] {
+		t.Errorf("Expected only Run's dependency on Greet around run.go, got %s", text)
+	}
+
+	if _, isError := callTool(t, s, "get_chunk_graph", map[string]interface{}{}); !isError {
+		t.Error("Expected an error without repository or file_path")
+	}
+}
+
+func TestIndexDependencies(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"package.json":                      `{"dependencies": {"leftpad": "^1.0.0", "missing": "2.0.0"}}`,
+		"index.js":                          "function renderPage() {\n  return leftPad('x', 3)\n}\n",
+		"node_modules/leftpad/package.json": `{"version": "1.3.0"}`,
+		"node_modules/leftpad/index.js":     "function leftPadImplementation(s, n) {\n  return s.padStart(n)\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	type response struct {
+		Packages []struct {
+			Name    string `json:"name"`
+			Package string `json:"package"`
+			Version string `json:"version"`
+			Dir     string `json:"dir"`
+			Status  string `json:"status"`
+		} `json:"packages"`
+		Unknown []string `json:"unknown"`
+	}
+	call := func(args map[string]interface{}) response {
+		t.Helper()
+		text, isError := callTool(t, s, "index_dependencies", args)
+		if isError {
+			t.Fatalf("index_dependencies failed: %s", text)
+		}
+		var parsed response
+		if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		return parsed
+	}
+
+	// Without packages the dependencies are listed
+	listed := call(map[string]interface{}{"repository": "app"})
+	if len(listed.Packages) != 2 || listed.Packages[0].Name != "leftpad" || listed.Packages[0].Version != "1.3.0" || listed.Packages[0].Dir == "" || listed.Packages[1].Dir != "" {
+		t.Errorf("Expected leftpad to be installed and missing not, got %+v", listed.Packages)
+	}
+
+	indexed := call(map[string]interface{}{"repository": "app", "packages": []interface{}{"leftpad", "missing", "other"}})
+	statuses := make(map[string]string)
+	for _, pkg := range indexed.Packages {
+		statuses[pkg.Package] = pkg.Status
+	}
+	if statuses["leftpad"] != "indexed" || statuses["missing"] != "not_installed" || len(indexed.Unknown) != 1 || indexed.Unknown[0] != "other" {
+		t.Errorf("Expected leftpad to be indexed, missing not installed and other unknown, got %+v", indexed)
+	}
+
+	search := func(args map[string]interface{}) string {
+		t.Helper()
+		args["query"] = "leftPadImplementation"
+		text, isError := callTool(t, s, "search_code", args)
+		if isError {
+			t.Fatalf("search_code failed: %s", text)
+		}
+		return text
+	}
+	if text := search(map[string]interface{}{}); strings.Contains(text, "leftpad@1.3.0") {
+		t.Errorf("Expected dependency sources to be left out of searches, got %s", text)
+	}
+	if text := search(map[string]interface{}{"include_deps": true}); !strings.Contains(text, "leftpad@1.3.0") {
+		t.Errorf("Expected include_deps to find the dependency sources, got %s", text)
+	}
+
+	if _, isError := callTool(t, s, "index_dependencies", map[string]interface{}{"repository": "unknown"}); !isError {
+		t.Error("Expected an unknown repository to be reported")
+	}
+}
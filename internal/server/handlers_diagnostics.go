@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/diagnostics"
+	"github.com/my-mcp/code-indexer/internal/lsp"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+)
+
+// getDiagnosticsMaxResults bounds the diagnostics get_diagnostics returns
+const getDiagnosticsMaxResults = 500
+
+// handleGetDiagnostics runs the configured compilers and linters, or the
+// language server, over a repository or a file and returns the problems
+// they report
+func (s *MCPServer) handleGetDiagnostics(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling get diagnostics", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	filePath := request.GetString("file_path", "")
+	if repository == "" && filePath == "" {
+		return mcp.NewToolResultError("Either repository or file_path is required"), nil
+	}
+	source := request.GetString("source", "auto")
+	switch source {
+	case "auto", "checkers", "lsp":
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid source %q: use auto, checkers or lsp", source)), nil
+	}
+	language := request.GetString("language", "")
+
+	// The workspace the checkers run in, and the file they are limited to
+	var target diagnostics.Target
+	var fullPath string
+	var languages []string
+	if filePath != "" {
+		var err error
+		if fullPath, err = s.repositoryPath(repository, filePath); err != nil {
+			return toolErrorResult(err), nil
+		}
+		if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+		}
+		target.Root = filepath.Dir(fullPath)
+		if repo, ok := s.owningRepository(ctx, repository, fullPath); ok {
+			if root, err := s.repoMgr.ResolvePath(repo.Path); err == nil {
+				repository = repo.Name
+				target.Root = root
+			}
+		}
+		target.File, _ = pathutil.Rel(target.Root, fullPath)
+		if language == "" {
+			language = s.repoMgr.GetFileLanguage(fullPath)
+		}
+		languages = []string{language}
+	} else {
+		repo, ok := s.indexer.IndexedRepository(repository)
+		if !ok {
+			return repoNotFound(repository), nil
+		}
+		root, err := s.repoMgr.ResolvePath(repo.Path)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+		target.Root = root
+		if language != "" {
+			languages = []string{language}
+		} else {
+			for _, repoLanguage := range repo.Languages {
+				if len(s.config.Diagnostics.Checkers[repoLanguage]) > 0 {
+					languages = append(languages, repoLanguage)
+				}
+			}
+			sort.Strings(languages)
+		}
+		if source == "lsp" {
+			return mcp.NewToolResultError("Language server diagnostics need a file_path"), nil
+		}
+	}
+
+	timeout := time.Duration(s.config.Diagnostics.TimeoutSeconds) * time.Second
+	var runs []*diagnostics.Run
+	var lists [][]diagnostics.Diagnostic
+	var skipped, warnings []string
+	for _, checkLanguage := range languages {
+		ran := false
+		checkers := s.config.Diagnostics.Checkers[checkLanguage]
+		if source != "lsp" && len(checkers) == 0 {
+			skipped = append(skipped, fmt.Sprintf("no checker is configured for %s", checkLanguage))
+		}
+		if source != "lsp" {
+			for _, checker := range checkers {
+				run, err := diagnostics.Check(ctx, checker, target, timeout)
+				if errors.Is(err, diagnostics.ErrNotInstalled) {
+					skipped = append(skipped, err.Error())
+					continue
+				}
+				if err != nil {
+					s.log(ctx).Warn("Checker failed", zap.String("command", checker.Command), zap.Error(err))
+					warnings = append(warnings, err.Error())
+					continue
+				}
+				ran = true
+				runs = append(runs, run)
+				lists = append(lists, run.Diagnostics)
+			}
+		}
+		if ran || source == "checkers" || fullPath == "" {
+			continue
+		}
+
+		found, err := s.lspFileDiagnostics(ctx, request, target, fullPath, checkLanguage)
+		if errors.Is(err, lsp.ErrNoServer) {
+			skipped = append(skipped, err.Error())
+			continue
+		}
+		if err != nil {
+			s.log(ctx).Warn("Language server diagnostics failed", zap.Error(err))
+			warnings = append(warnings, fmt.Sprintf("language server: %v", err))
+			continue
+		}
+		runs = append(runs, &diagnostics.Run{Checker: "lsp", Command: s.config.LSP.Servers[checkLanguage].Command, Count: len(found)})
+		lists = append(lists, found)
+	}
+	if len(runs) == 0 {
+		reasons := append(skipped, warnings...)
+		if len(reasons) == 0 {
+			reasons = []string{"no checker is configured for the repository's languages"}
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("No diagnostics could be collected: %s", strings.Join(reasons, "; "))), nil
+	}
+	if fullPath != "" && source != "lsp" {
+		if _, buffered := s.sessionForRequest(request).GetBuffer(fullPath); buffered {
+			warnings = append(warnings, "Checkers read the file on disk, not its unsaved buffer; use source=lsp to check the buffer")
+		}
+	}
+
+	found := diagnostics.Merge(lists...)
+	counts := make(map[string]int)
+	for _, diagnostic := range found {
+		counts[diagnostic.Severity]++
+	}
+	result := map[string]interface{}{
+		"success":     true,
+		"repository":  repository,
+		"file_path":   filePath,
+		"root":        target.Root,
+		"clean":       len(found) == 0,
+		"count":       len(found),
+		"by_severity": counts,
+		"checkers":    runs,
+	}
+	if len(found) > getDiagnosticsMaxResults {
+		result["truncated"] = true
+		found = found[:getDiagnosticsMaxResults]
+	}
+	result["diagnostics"] = found
+	if len(skipped) > 0 {
+		result["skipped"] = skipped
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// lspFileDiagnostics asks the language server for the diagnostics of a
+// file, reading the session's unsaved buffer when there is one
+func (s *MCPServer) lspFileDiagnostics(ctx context.Context, request mcp.CallToolRequest, target diagnostics.Target, fullPath, language string) ([]diagnostics.Diagnostic, error) {
+	client, err := s.lsp.Client(ctx, target.Root, language)
+	if err != nil {
+		return nil, err
+	}
+	content, _, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	reported, err := client.Diagnostics(ctx, fullPath, string(content), lspDiagnosticsWait)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]diagnostics.Diagnostic, 0, len(reported))
+	for _, diagnostic := range reported {
+		path := diagnostic.Path
+		if rel, err := pathutil.Rel(target.Root, path); err == nil && pathutil.Within(rel) {
+			path = rel
+		}
+		source := diagnostic.Source
+		if source == "" {
+			source = "lsp"
+		}
+		found = append(found, diagnostics.Diagnostic{
+			FilePath: path,
+			Line:     diagnostic.Start.Line,
+			Column:   diagnostic.Start.Column,
+			Severity: diagnostic.Severity,
+			Code:     diagnostic.Code,
+			Message:  diagnostic.Message,
+			Source:   source,
+		})
+	}
+	return found, nil
+}
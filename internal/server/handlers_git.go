@@ -0,0 +1,162 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/models"
+	"github.com/my-mcp/code-indexer/internal/repository"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Limits of git_diff, reported by get_capabilities
+const (
+	gitDiffDefaultContext = 3
+	gitDiffMaxContext     = 20
+	gitDiffMaxLines       = 2000
+)
+
+// handleGitDiff returns the structured diff of an indexed repository between
+// two commits, a commit and the working tree, or a commit and the index
+func (s *MCPServer) handleGitDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling git diff", zap.String("tool", request.Params.Name))
+
+	name, err := request.RequireString("repository")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository parameter: %v", err)), nil
+	}
+	repo, ok := s.indexer.IndexedRepository(name)
+	if !ok {
+		return repoNotFound(name), nil
+	}
+
+	contextLines := int(request.GetFloat("context_lines", gitDiffDefaultContext))
+	if contextLines < 0 {
+		return mcp.NewToolResultError("Invalid context_lines parameter: must not be negative"), nil
+	}
+	options := s.diffOptions(request)
+	options.Context = min(contextLines, gitDiffMaxContext)
+	options.Summary = s.getBooleanValue(request, "summary", false)
+
+	diff, err := s.repoMgr.Diff(repo.Path, options)
+	if err != nil {
+		s.log(ctx).Error("Failed to diff repository", zap.String("repository", repo.Name), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to diff %s: %v", repo.Name, err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success":    true,
+		"repository": repo.Name,
+		"diff":       diff,
+		"summary":    diffSummary(diff),
+	}
+	if diff.Truncated {
+		result["message"] = fmt.Sprintf("Hunks were cut off after %d lines; narrow the diff with paths or use summary", gitDiffMaxLines)
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// diffOptions returns the sides and paths of the diff a request selects
+// with from, to, staged and paths
+func (s *MCPServer) diffOptions(request mcp.CallToolRequest) repository.DiffOptions {
+	return repository.DiffOptions{
+		From:     request.GetString("from", ""),
+		To:       request.GetString("to", ""),
+		Staged:   s.getBooleanValue(request, "staged", false),
+		Paths:    s.getStringList(request, "paths"),
+		Context:  gitDiffDefaultContext,
+		MaxLines: gitDiffMaxLines,
+	}
+}
+
+// handleSummarizeDiff writes a commit message or pull request description
+// for a diff of an indexed repository with the models engine
+func (s *MCPServer) handleSummarizeDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling summarize diff", zap.String("tool", request.Params.Name))
+
+	name, err := request.RequireString("repository")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository parameter: %v", err)), nil
+	}
+	repo, ok := s.indexer.IndexedRepository(name)
+	if !ok {
+		return repoNotFound(name), nil
+	}
+
+	options := models.SummaryOptions{
+		Format:           request.GetString("format", models.SummaryCommit),
+		Conventional:     s.getBooleanValue(request, "conventional", false),
+		Type:             request.GetString("type", ""),
+		Scope:            request.GetString("scope", ""),
+		MaxSubjectLength: int(request.GetFloat("max_subject_length", 0)),
+	}
+	if options.Format != models.SummaryCommit && options.Format != models.SummaryPullRequest {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid format %q: use %s or %s", options.Format, models.SummaryCommit, models.SummaryPullRequest)), nil
+	}
+	if options.Type != "" && !slices.Contains(models.ConventionalTypes, options.Type) {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid type %q: use one of %s", options.Type, strings.Join(models.ConventionalTypes, ", "))), nil
+	}
+	if options.MaxSubjectLength < 0 {
+		return mcp.NewToolResultError("Invalid max_subject_length parameter: must not be negative"), nil
+	}
+
+	diff, err := s.repoMgr.Diff(repo.Path, s.diffOptions(request))
+	if err != nil {
+		s.log(ctx).Error("Failed to diff repository", zap.String("repository", repo.Name), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to diff %s: %v", repo.Name, err)), nil
+	}
+	if len(diff.Files) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("No changes between %s and %s in %s", diff.From, diff.To, repo.Name)), nil
+	}
+
+	summary, err := s.modelsEngine.SummarizeDiff(ctx, diff, options)
+	if err != nil {
+		s.log(ctx).Error("Failed to summarize diff", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to summarize diff: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success":    true,
+		"repository": repo.Name,
+		"from":       diff.From,
+		"to":         diff.To,
+		"stats":      diffSummary(diff),
+		"summary":    summary,
+	}
+	if diff.Truncated {
+		result["message"] = fmt.Sprintf("Only the first %d lines of the diff were summarized; narrow it with paths", gitDiffMaxLines)
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// diffSummary describes a diff in a few lines like git diff --stat: one line
+// per file with its status and line counts, then the totals
+func diffSummary(diff *types.GitDiff) string {
+	var summary strings.Builder
+	for _, file := range diff.Files {
+		status := strings.ToUpper(file.Status[:1])
+		if file.Binary {
+			fmt.Fprintf(&summary, "%s %s (binary)\n", status, file.Path)
+			continue
+		}
+		fmt.Fprintf(&summary, "%s %s (+%d -%d)\n", status, file.Path, file.Additions, file.Deletions)
+	}
+	fmt.Fprintf(&summary, "%d files changed, %d insertions(+), %d deletions(-)", diff.FilesChanged, diff.Additions, diff.Deletions)
+	return summary.String()
+}
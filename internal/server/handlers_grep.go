@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/grep"
+	"github.com/my-mcp/code-indexer/internal/search"
+)
+
+// Result limits of grep_repository, reported by get_capabilities
+const (
+	grepDefaultResults   = 50
+	grepMaxResults       = 500
+	grepMaxContextLines  = 20
+	grepMaxFilesSearched = 20000
+)
+
+// errGrepPageFull stops the file walk once a page and its successor are found
+var errGrepPageFull = errors.New("page full")
+
+// handleGrepRepository searches repository files on disk line by line,
+// bypassing the index
+func (s *MCPServer) handleGrepRepository(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling grep repository", zap.String("tool", request.Params.Name))
+
+	pattern, err := request.RequireString("pattern")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid pattern parameter: %v", err)), nil
+	}
+	repository := request.GetString("repository", "")
+	searchPath := request.GetString("path", "")
+	if repository == "" && searchPath == "" {
+		return mcp.NewToolResultError("Either repository or path must be specified"), nil
+	}
+	if searchPath == "" {
+		searchPath = "."
+	}
+
+	options := grep.Options{
+		Pattern:       pattern,
+		Regex:         s.getBooleanValue(request, "regex", false),
+		CaseSensitive: s.getBooleanValue(request, "case_sensitive", true),
+		BeforeContext: min(int(request.GetFloat("before_context", 0)), grepMaxContextLines),
+		AfterContext:  min(int(request.GetFloat("after_context", 0)), grepMaxContextLines),
+		Include:       s.getStringList(request, "include"),
+		Exclude:       s.getStringList(request, "exclude"),
+	}
+	matcher, err := grep.Compile(options)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid search: %v", err)), nil
+	}
+	offset, pageSize, err := s.getPage(request, grepDefaultResults, grepMaxResults)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid cursor parameter: %v", err)), nil
+	}
+
+	root, err := s.repositoryPath(repository, searchPath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	root, err = s.repoMgr.ResolvePath(root)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid path parameter: %v", err)), nil
+	}
+
+	// Collect one match past the page, so the last page is known without
+	// walking the rest of the tree
+	wanted := offset + pageSize + 1
+	var matches []grep.Match
+	filesScanned, filesSkipped := 0, 0
+	truncated := false
+	err = s.repoMgr.WalkFiles(ctx, root, nil, func(filePath string, info fs.FileInfo) error {
+		relativePath, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return nil
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		if relativePath == ".git" || strings.HasPrefix(relativePath, ".git/") || !matcher.Selects(relativePath) {
+			return nil
+		}
+		if filesScanned >= grepMaxFilesSearched {
+			truncated = true
+			return filepath.SkipAll
+		}
+		if info.Size() > s.config.Indexer.MaxFileSize {
+			filesSkipped++
+			return nil
+		}
+
+		content, err := s.repoMgr.ReadFile(filePath)
+		if err != nil {
+			s.log(ctx).Debug("Skipping unreadable file", zap.String("path", filePath), zap.Error(err))
+			filesSkipped++
+			return nil
+		}
+		if grep.IsBinary(content) {
+			filesSkipped++
+			return nil
+		}
+		filesScanned++
+
+		matches = append(matches, matcher.Search(relativePath, content)...)
+		if len(matches) >= wanted {
+			return errGrepPageFull
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errGrepPageFull) {
+		s.log(ctx).Error("Failed to search repository files", zap.String("root", root), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+
+	page := []grep.Match{}
+	if offset < len(matches) {
+		page = matches[offset:min(offset+pageSize, len(matches))]
+	}
+	nextCursor := ""
+	if len(matches) > offset+pageSize {
+		nextCursor = search.EncodeCursor(offset + pageSize)
+	}
+
+	response := map[string]interface{}{
+		"pattern":       pattern,
+		"root":          root,
+		"matches":       page,
+		"total_matches": len(page),
+		"files_scanned": filesScanned,
+		"files_skipped": filesSkipped,
+	}
+	if repository != "" {
+		response["repository"] = repository
+	}
+	if truncated {
+		response["truncated"] = true
+		response["message"] = fmt.Sprintf("Stopped after searching %d files; narrow the path or include globs", grepMaxFilesSearched)
+	}
+	response["page_size"] = pageSize
+	response["has_more"] = nextCursor != ""
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+
+	content, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/journal"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+)
+
+// Edit journal handlers that undo and redo the edits of the file
+// manipulation tools
+
+// defaultEditHistoryLimit is the number of entries list_edit_history returns
+// when no limit is given
+const defaultEditHistoryLimit = 20
+
+// recordEdit adds an edit written by one of the file manipulation tools to
+// the journal, attributed to the calling session
+func (s *MCPServer) recordEdit(ctx context.Context, request mcp.CallToolRequest, filePath, before, after string) {
+	resolved, err := s.repoMgr.ResolvePath(pathutil.Local(filePath))
+	if err != nil {
+		s.log(ctx).Warn("Edit not journaled", zap.String("path", filePath), zap.Error(err))
+		return
+	}
+	s.journal.Record(resolved, request.Params.Name, s.sessionForRequest(request).ID, before, after)
+}
+
+// journalFilter scopes a journal operation to the calling session and, when
+// file_path is given, to that file
+func (s *MCPServer) journalFilter(request mcp.CallToolRequest) (journal.Filter, error) {
+	filter := journal.Filter{SessionID: s.sessionForRequest(request).ID}
+	if filePath := request.GetString("file_path", ""); filePath != "" {
+		resolved, err := s.repoMgr.ResolvePath(pathutil.Local(filePath))
+		if err != nil {
+			return filter, err
+		}
+		filter.FilePath = resolved
+	}
+	return filter, nil
+}
+
+// journalEntry describes a journal entry, with the diff of the edit it
+// recorded
+func journalEntry(entry journal.Entry) map[string]interface{} {
+	result := map[string]interface{}{
+		"id":         entry.ID,
+		"file_path":  entry.FilePath,
+		"tool":       entry.Tool,
+		"session_id": entry.SessionID,
+		"timestamp":  entry.Timestamp,
+		"undone":     entry.Undone,
+		"diff":       textpos.UnifiedDiff(entry.FilePath, entry.Before, entry.After, editDiffContext),
+	}
+	if entry.UndoneAt != nil {
+		result["undone_at"] = entry.UndoneAt
+	}
+	return result
+}
+
+// journalError turns a failed undo or redo into a tool error
+func journalError(action string, err error) *mcp.CallToolResult {
+	var conflict *journal.ConflictError
+	switch {
+	case errors.Is(err, journal.ErrNothingToUndo), errors.Is(err, journal.ErrNothingToRedo):
+		return mcp.NewToolResultError(fmt.Sprintf("Nothing to %s: %v", action, err))
+	case errors.As(err, &conflict):
+		return mcp.NewToolResultError(fmt.Sprintf("Cannot %s edit %d: %v", action, conflict.EntryID, err))
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to %s edit: %v", action, err))
+	}
+}
+
+// handleUndoLastEdit restores the file content from before the calling
+// session's most recent edit
+func (s *MCPServer) handleUndoLastEdit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling undo last edit", zap.String("tool", request.Params.Name))
+
+	filter, err := s.journalFilter(request)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+
+	// Hold the file the undo restores: file_path, or the file of the
+	// session's edit it picks
+	if filter.FilePath == "" {
+		filter.FilePath, _ = s.journal.UndoTarget(filter)
+	}
+	if err := s.lockFiles(ctx, []string{filter.FilePath}, false); err != nil {
+		return lockFailed(err), nil
+	}
+
+	// A call answered at its time limit must not change the file
+	if err := ctx.Err(); err != nil {
+		return journalError("undo", err), nil
+	}
+	entry, err := s.journal.Undo(filter)
+	if err != nil {
+		return journalError("undo", err), nil
+	}
+
+	s.log(ctx).Info("Edit undone",
+		zap.Int64("edit_id", entry.ID),
+		zap.String("file", entry.FilePath),
+		zap.String("tool", entry.Tool))
+
+	result := map[string]interface{}{
+		"success": true,
+		"edit":    journalEntry(*entry),
+		"diff":    textpos.UnifiedDiff(entry.FilePath, entry.After, entry.Before, editDiffContext),
+		"message": fmt.Sprintf("Undid %s edit %d of %s", entry.Tool, entry.ID, entry.FilePath),
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// handleRedoEdit reapplies the calling session's most recently undone edit
+func (s *MCPServer) handleRedoEdit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling redo edit", zap.String("tool", request.Params.Name))
+
+	filter, err := s.journalFilter(request)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+
+	// Hold the file the redo restores: file_path, or the file of the
+	// session's edit it picks
+	if filter.FilePath == "" {
+		filter.FilePath, _ = s.journal.RedoTarget(filter)
+	}
+	if err := s.lockFiles(ctx, []string{filter.FilePath}, false); err != nil {
+		return lockFailed(err), nil
+	}
+
+	// A call answered at its time limit must not change the file
+	if err := ctx.Err(); err != nil {
+		return journalError("redo", err), nil
+	}
+	entry, err := s.journal.Redo(filter)
+	if err != nil {
+		return journalError("redo", err), nil
+	}
+
+	s.log(ctx).Info("Edit redone",
+		zap.Int64("edit_id", entry.ID),
+		zap.String("file", entry.FilePath),
+		zap.String("tool", entry.Tool))
+
+	result := map[string]interface{}{
+		"success": true,
+		"edit":    journalEntry(*entry),
+		"diff":    textpos.UnifiedDiff(entry.FilePath, entry.Before, entry.After, editDiffContext),
+		"message": fmt.Sprintf("Redid %s edit %d of %s", entry.Tool, entry.ID, entry.FilePath),
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// handleListEditHistory lists the calling session's journaled edits, newest
+// first
+func (s *MCPServer) handleListEditHistory(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling list edit history", zap.String("tool", request.Params.Name))
+
+	filter, err := s.journalFilter(request)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+
+	limit := int(request.GetFloat("limit", defaultEditHistoryLimit))
+	if limit < 0 {
+		return mcp.NewToolResultError("limit must not be negative"), nil
+	}
+
+	history := s.journal.History(filter, limit)
+	edits := make([]map[string]interface{}, 0, len(history))
+	for _, entry := range history {
+		edits = append(edits, journalEntry(entry))
+	}
+
+	result := map[string]interface{}{
+		"session_id": filter.SessionID,
+		"edits":      edits,
+		"count":      len(edits),
+	}
+	if filter.FilePath != "" {
+		result["file_path"] = filter.FilePath
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
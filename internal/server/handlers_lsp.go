@@ -0,0 +1,455 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/lsp"
+	"github.com/my-mcp/code-indexer/internal/parser"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Language server handlers. Each asks the language server configured for
+// the file's language (see the lsp config section) and falls back to the
+// index and the tree-sitter parsers when none is available, reporting which
+// answered in "source".
+
+// lspDiagnosticsWait is how long lsp_diagnostics waits for a server to
+// publish the diagnostics of a file it was just given
+const lspDiagnosticsWait = 5 * time.Second
+
+// lspTarget is the file and position an lsp_* tool asks about
+type lspTarget struct {
+	filePath   string // As given
+	fullPath   string
+	repository string // Name of the indexed repository holding the file, if any
+	root       string // Workspace root the language server runs in
+	language   string
+	content    string
+	point      lsp.Point
+	symbol     string // Identifier at point
+}
+
+// lspLocation is a range a language server or the index pointed to
+type lspLocation struct {
+	FilePath  string `json:"file_path"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndLine   int    `json:"end_line"`
+	EndColumn int    `json:"end_column"`
+	Text      string `json:"text,omitempty"` // The line the range starts on
+}
+
+// handleLSPHover returns the type and documentation of the symbol at a
+// position of a file
+func (s *MCPServer) handleLSPHover(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling LSP hover", zap.String("tool", request.Params.Name))
+
+	target, err := s.lspTarget(ctx, request, true)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	result := target.result()
+
+	client, err := s.lsp.Client(ctx, target.root, target.language)
+	switch {
+	case errors.Is(err, lsp.ErrNoServer):
+		definitions, indexErr := s.lspIndexDefinitions(ctx, target)
+		if indexErr != nil {
+			return mcp.NewToolResultError(indexErr.Error()), nil
+		}
+		result["source"] = "index"
+		result["lsp_unavailable"] = err.Error()
+		result["found"] = len(definitions) > 0
+		if len(definitions) > 0 {
+			definition := definitions[0]
+			result["contents"] = fmt.Sprintf("```%s\n%s\n```", target.language, definition.Signature)
+			result["definition"] = definition
+		}
+		return lspResponse(result)
+	case err != nil:
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to start language server: %v", err)), nil
+	}
+
+	hover, err := client.Hover(ctx, target.fullPath, target.content, target.point)
+	if err != nil {
+		s.log(ctx).Error("Language server hover failed", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Language server request failed: %v", err)), nil
+	}
+	result["source"] = "lsp"
+	result["found"] = hover != nil && hover.Contents != ""
+	if hover != nil {
+		result["contents"] = hover.Contents
+		if hover.Range != nil {
+			result["range"] = s.lspLocations(target, []lsp.FileRange{*hover.Range}, false)[0]
+		}
+	}
+	return lspResponse(result)
+}
+
+// handleLSPDefinition returns where the symbol at a position of a file is
+// defined
+func (s *MCPServer) handleLSPDefinition(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling LSP definition", zap.String("tool", request.Params.Name))
+
+	target, err := s.lspTarget(ctx, request, true)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	result := target.result()
+
+	client, err := s.lsp.Client(ctx, target.root, target.language)
+	switch {
+	case errors.Is(err, lsp.ErrNoServer):
+		definitions, indexErr := s.lspIndexDefinitions(ctx, target)
+		if indexErr != nil {
+			return mcp.NewToolResultError(indexErr.Error()), nil
+		}
+		result["source"] = "index"
+		result["lsp_unavailable"] = err.Error()
+		result["definitions"] = definitions
+		result["count"] = len(definitions)
+		return lspResponse(result)
+	case err != nil:
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to start language server: %v", err)), nil
+	}
+
+	definitions, err := client.Definition(ctx, target.fullPath, target.content, target.point)
+	if err != nil {
+		s.log(ctx).Error("Language server definition failed", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Language server request failed: %v", err)), nil
+	}
+	result["source"] = "lsp"
+	result["definitions"] = s.lspLocations(target, definitions, true)
+	result["count"] = len(definitions)
+	return lspResponse(result)
+}
+
+// handleLSPReferences returns the uses of the symbol at a position of a
+// file across its workspace
+func (s *MCPServer) handleLSPReferences(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling LSP references", zap.String("tool", request.Params.Name))
+
+	target, err := s.lspTarget(ctx, request, true)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	includeDeclaration := s.getBooleanValue(request, "include_declaration", true)
+	result := target.result()
+
+	client, err := s.lsp.Client(ctx, target.root, target.language)
+	switch {
+	case errors.Is(err, lsp.ErrNoServer):
+		refQuery := types.ReferenceQuery{
+			Name:       target.symbol,
+			Repository: target.repository,
+			MaxResults: findReferencesMaxResults,
+		}
+		indexed, page, searchErr := s.findReferences(ctx, s.sessionForRequest(request), refQuery, "", includeDeclaration)
+		if searchErr != nil {
+			s.log(ctx).Error("Failed to search for references", zap.Error(searchErr))
+			return mcp.NewToolResultError(fmt.Sprintf("Reference search failed: %v", searchErr)), nil
+		}
+		for key, value := range indexed {
+			result[key] = value
+		}
+		result["source"] = "index"
+		result["lsp_unavailable"] = err.Error()
+		result["count"] = page.Total
+		return lspResponse(result)
+	case err != nil:
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to start language server: %v", err)), nil
+	}
+
+	references, err := client.References(ctx, target.fullPath, target.content, target.point, includeDeclaration)
+	if err != nil {
+		s.log(ctx).Error("Language server references failed", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Language server request failed: %v", err)), nil
+	}
+	if len(references) > findReferencesMaxResults {
+		result["truncated"] = true
+		references = references[:findReferencesMaxResults]
+	}
+	result["source"] = "lsp"
+	result["references"] = s.lspLocations(target, references, true)
+	result["count"] = len(references)
+	return lspResponse(result)
+}
+
+// handleLSPDiagnostics returns the errors and warnings of a file
+func (s *MCPServer) handleLSPDiagnostics(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling LSP diagnostics", zap.String("tool", request.Params.Name))
+
+	target, err := s.lspTarget(ctx, request, false)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	result := map[string]interface{}{
+		"success":    true,
+		"file_path":  target.filePath,
+		"repository": target.repository,
+		"language":   target.language,
+	}
+
+	client, err := s.lsp.Client(ctx, target.root, target.language)
+	switch {
+	case errors.Is(err, lsp.ErrNoServer):
+		fileParser := parser.NewTreeSitterParser(target.language)
+		if fileParser == nil {
+			return mcp.NewToolResultError(fmt.Sprintf("No diagnostics available for %s: %v", target.filePath, err)), nil
+		}
+		syntaxErrors, parseErr := fileParser.SyntaxErrors(target.content)
+		if parseErr != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to parse file: %v", parseErr)), nil
+		}
+		diagnostics := make([]map[string]interface{}, 0, len(syntaxErrors))
+		for _, syntaxError := range syntaxErrors {
+			diagnostics = append(diagnostics, map[string]interface{}{
+				"file_path":  target.filePath,
+				"line":       syntaxError.Line,
+				"column":     syntaxError.Column,
+				"end_line":   syntaxError.EndLine,
+				"end_column": syntaxError.EndColumn,
+				"severity":   "error",
+				"source":     "syntax",
+				"message":    syntaxError.Message,
+			})
+		}
+		result["source"] = "syntax"
+		result["lsp_unavailable"] = err.Error()
+		result["diagnostics"] = diagnostics
+		result["count"] = len(diagnostics)
+		return lspResponse(result)
+	case err != nil:
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to start language server: %v", err)), nil
+	}
+
+	diagnostics, err := client.Diagnostics(ctx, target.fullPath, target.content, lspDiagnosticsWait)
+	if err != nil {
+		s.log(ctx).Error("Language server diagnostics failed", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Language server request failed: %v", err)), nil
+	}
+	entries := make([]map[string]interface{}, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		location := s.lspLocations(target, []lsp.FileRange{diagnostic.FileRange}, false)[0]
+		entry := map[string]interface{}{
+			"file_path":  location.FilePath,
+			"line":       location.Line,
+			"column":     location.Column,
+			"end_line":   location.EndLine,
+			"end_column": location.EndColumn,
+			"severity":   diagnostic.Severity,
+			"message":    diagnostic.Message,
+		}
+		if diagnostic.Source != "" {
+			entry["source"] = diagnostic.Source
+		}
+		if diagnostic.Code != "" {
+			entry["code"] = diagnostic.Code
+		}
+		entries = append(entries, entry)
+	}
+	result["source"] = "lsp"
+	result["diagnostics"] = entries
+	result["count"] = len(entries)
+	return lspResponse(result)
+}
+
+// lspTarget reads the file and, with position, the line and column an lsp_*
+// tool asks about. The column may be given directly or found from the
+// symbol_name used on the line.
+func (s *MCPServer) lspTarget(ctx context.Context, request mcp.CallToolRequest, position bool) (*lspTarget, error) {
+	filePath, err := request.RequireString("file_path")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid file_path parameter: %v", err)
+	}
+	repository := request.GetString("repository", "")
+
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+		return nil, fmt.Errorf("Invalid file_path parameter: %v", err)
+	}
+	content, _, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for language server", zap.String("path", fullPath), zap.Error(err))
+		return nil, fmt.Errorf("Failed to read file: %v", err)
+	}
+
+	target := &lspTarget{
+		filePath: filePath,
+		fullPath: fullPath,
+		root:     filepath.Dir(fullPath),
+		language: s.repoMgr.GetFileLanguage(fullPath),
+		content:  string(content),
+	}
+	if repo, ok := s.owningRepository(ctx, repository, fullPath); ok {
+		if root, err := s.repoMgr.ResolvePath(repo.Path); err == nil {
+			target.repository = repo.Name
+			target.root = root
+		}
+	}
+	if !position {
+		return target, nil
+	}
+
+	line := int(request.GetFloat("line", 0))
+	text, ok := textpos.Split(target.content).Line(line)
+	if !ok {
+		return nil, fmt.Errorf("Invalid line parameter: %s has no line %d", filePath, line)
+	}
+	column := int(request.GetFloat("column", 0))
+	if column < 1 {
+		symbolName := request.GetString("symbol_name", "")
+		if symbolName == "" {
+			return nil, errors.New("Either column or symbol_name is required")
+		}
+		_, name := splitQualifiedName(symbolName)
+		if column = symbolColumn(text, name); column == 0 {
+			return nil, fmt.Errorf("%s is not used on line %d of %s", symbolName, line, filePath)
+		}
+	}
+	target.point = lsp.Point{Line: line, Column: column}
+	target.symbol = identifierAt(text, column)
+	return target, nil
+}
+
+// result starts the response to a request at the target's position
+func (t *lspTarget) result() map[string]interface{} {
+	return map[string]interface{}{
+		"success":    true,
+		"file_path":  t.filePath,
+		"repository": t.repository,
+		"language":   t.language,
+		"line":       t.point.Line,
+		"column":     t.point.Column,
+		"symbol":     t.symbol,
+	}
+}
+
+// lspIndexDefinitions resolves the identifier at the target's position the
+// way goto_definition does, for when no language server is available
+func (s *MCPServer) lspIndexDefinitions(ctx context.Context, target *lspTarget) ([]definitionLocation, error) {
+	if target.symbol == "" {
+		return nil, fmt.Errorf("No identifier at line %d, column %d of %s", target.point.Line, target.point.Column, target.filePath)
+	}
+	fileParser := parser.NewTreeSitterParser(target.language)
+	if fileParser == nil {
+		return nil, fmt.Errorf("No language server or parser is available for %s files", target.filePath)
+	}
+	_, _, definitions, err := s.resolveDefinitions(ctx, fileParser, target.repository, target.filePath, target.fullPath, target.language, target.content, target.symbol, target.point.Line)
+	if err != nil {
+		s.log(ctx).Error("Failed to resolve definition", zap.Error(err))
+		return nil, err
+	}
+	return definitions, nil
+}
+
+// lspLocations converts ranges a language server returned to locations
+// relative to the target's workspace root, with the text of their first
+// line when withText is set
+func (s *MCPServer) lspLocations(target *lspTarget, ranges []lsp.FileRange, withText bool) []lspLocation {
+	files := make(map[string]*textpos.Text)
+	locations := make([]lspLocation, 0, len(ranges))
+	for _, fileRange := range ranges {
+		location := lspLocation{
+			FilePath:  fileRange.Path,
+			Line:      fileRange.Start.Line,
+			Column:    fileRange.Start.Column,
+			EndLine:   fileRange.End.Line,
+			EndColumn: fileRange.End.Column,
+		}
+		if rel, err := pathutil.Rel(target.root, fileRange.Path); err == nil && pathutil.Within(rel) {
+			location.FilePath = rel
+		}
+
+		if withText {
+			text, ok := files[fileRange.Path]
+			if !ok {
+				if fileRange.Path == target.fullPath {
+					text = textpos.Split(target.content)
+				} else if content, err := s.repoMgr.ReadFile(fileRange.Path); err == nil {
+					text = textpos.Split(string(content))
+				}
+				files[fileRange.Path] = text
+			}
+			if text != nil {
+				line, _ := text.Line(fileRange.Start.Line)
+				location.Text = strings.TrimSpace(line)
+			}
+		}
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+// lspResponse formats the result of an lsp_* tool
+func lspResponse(result map[string]interface{}) (*mcp.CallToolResult, error) {
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// isIdentifierRune reports whether r can be part of an identifier
+func isIdentifierRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// identifierAt returns the identifier at a 1-based column of a line, or ""
+// when the column is not on one
+func identifierAt(line string, column int) string {
+	offset := textpos.Offset(line, column)
+	start, end := offset, offset
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(line[:start])
+		if !isIdentifierRune(r) {
+			break
+		}
+		start -= size
+	}
+	for _, r := range line[end:] {
+		if !isIdentifierRune(r) {
+			break
+		}
+		end += utf8.RuneLen(r)
+	}
+	return line[start:end]
+}
+
+// symbolColumn returns the 1-based column of the first use of name as a
+// whole word on a line, or 0 when it is not used there
+func symbolColumn(line, name string) int {
+	if name == "" {
+		return 0
+	}
+	for from := 0; from < len(line); {
+		idx := strings.Index(line[from:], name)
+		if idx < 0 {
+			return 0
+		}
+		start, end := from+idx, from+idx+len(name)
+		before, _ := utf8.DecodeLastRuneInString(line[:start])
+		after, _ := utf8.DecodeRuneInString(line[end:])
+		if (start == 0 || !isIdentifierRune(before)) && !isIdentifierRune(after) {
+			return textpos.Column(line, start)
+		}
+		from = end
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package server
+
+import "testing"
+
+func TestIdentifierAt(t *testing.T) {
+	tests := []struct {
+		line   string
+		column int
+		want   string
+	}{
+		{"\treturn store.Load(key)", 9, "store"},
+		{"\treturn store.Load(key)", 15, "Load"},
+		{"\treturn store.Load(key)", 18, "Load"},
+		{"\treturn store.Load(key)", 20, "key"},
+		{"\ta + b", 4, ""},
+		{"café := naïve", 1, "café"},
+		{"café := naïve", 10, "naïve"},
+	}
+	for _, tt := range tests {
+		if got := identifierAt(tt.line, tt.column); got != tt.want {
+			t.Errorf("identifierAt(%q, %d) = %q, want %q", tt.line, tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestSymbolColumn(t *testing.T) {
+	tests := []struct {
+		line string
+		name string
+		want int
+	}{
+		{"\treturn LoadAll() + Load()", "Load", 21},
+		{"\treturn store.Load(key)", "store", 9},
+		{"é := Load", "Load", 6},
+		{"\treturn Loader()", "Load", 0},
+	}
+	for _, tt := range tests {
+		if got := symbolColumn(tt.line, tt.name); got != tt.want {
+			t.Errorf("symbolColumn(%q, %q) = %d, want %d", tt.line, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/quality"
+	"github.com/my-mcp/code-indexer/internal/secrets"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// metricsReportDefaultMaxComplexity is the cyclomatic complexity above
+// which generate_metrics_report lists a function by default, the top of the
+// moderate rating
+const metricsReportDefaultMaxComplexity = 10
+
+// handleGenerateMetricsReport reports the size, complexity, code smells and
+// hardcoded secrets of a file, or the size, complexity and secrets the
+// index records for a repository
+func (s *MCPServer) handleGenerateMetricsReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling generate metrics report", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	filePath := request.GetString("file_path", "")
+	if repository == "" && filePath == "" {
+		return mcp.NewToolResultError("Either repository or file_path is required"), nil
+	}
+	maxComplexity := int(request.GetFloat("max_complexity", metricsReportDefaultMaxComplexity))
+	if maxComplexity < 0 {
+		return mcp.NewToolResultError("max_complexity cannot be negative"), nil
+	}
+	format, err := getReportFormat(request)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+
+	if filePath != "" {
+		return s.fileMetricsReport(ctx, request, repository, filePath, maxComplexity, format)
+	}
+
+	repo, ok := s.indexer.IndexedRepository(repository)
+	if !ok {
+		return repoNotFound(repository), nil
+	}
+	functions, err := s.searcher.FunctionComplexity(ctx, repo.ID)
+	if err != nil {
+		s.log(ctx).Error("Failed to read function complexity", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read function complexity: %v", err)), nil
+	}
+	findings, err := s.searcher.SecurityFindings(ctx, repo.ID)
+	if err != nil {
+		s.log(ctx).Error("Failed to read security findings", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read security findings: %v", err)), nil
+	}
+	for i := range findings {
+		findings[i].Repository = repo.Name
+	}
+	sortFindings(findings)
+
+	if format == "sarif" {
+		run := s.newSARIFRun()
+		addComplexityResults(run, functions, maxComplexity)
+		addSecurityResults(run, findings)
+		return sarifResult(run)
+	}
+
+	summary := quality.NewSummary()
+	complex := []types.FunctionComplexity{}
+	for _, function := range functions {
+		if function.Cyclomatic == 0 {
+			continue
+		}
+		summary.Add(function.Cyclomatic, function.Cognitive)
+		if function.Cyclomatic > maxComplexity {
+			complex = append(complex, function)
+		}
+	}
+	sort.SliceStable(complex, func(i, j int) bool {
+		return complex[i].Cyclomatic > complex[j].Cyclomatic
+	})
+	complexCount := len(complex)
+	if len(complex) > analyzeComplexityDefaultLimit {
+		complex = complex[:analyzeComplexityDefaultLimit]
+	}
+
+	result := map[string]interface{}{
+		"success":    true,
+		"repository": repo.Name,
+		"indexed_at": repo.IndexedAt,
+		"size": map[string]interface{}{
+			"files":     repo.FileCount,
+			"lines":     repo.TotalLines,
+			"languages": repo.Languages,
+			"functions": len(functions),
+		},
+		"complexity": map[string]interface{}{
+			"summary":        summary,
+			"max_complexity": maxComplexity,
+			"over_threshold": complexCount,
+			"functions":      complex,
+		},
+		"security": summarizeFindings(findings),
+	}
+	var warnings []string
+	if len(functions) > 0 && summary.Functions == 0 {
+		warnings = append(warnings, fmt.Sprintf("No complexity is recorded for %s; re-index it with index_repository", repo.Name))
+	}
+	if len(findings) == 0 {
+		warnings = append(warnings, s.scanningWarnings()...)
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// fileMetricsReport reports on one file, reading the session's unsaved
+// buffer when there is one. Complexity and smells are left out for
+// languages they are not measured in.
+func (s *MCPServer) fileMetricsReport(ctx context.Context, request mcp.CallToolRequest, repository, filePath string, maxComplexity int, format string) (*mcp.CallToolResult, error) {
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	contentBytes, source, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for metrics report", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+	content := string(contentBytes)
+	language := s.repoMgr.GetFileLanguage(filePath)
+	relPath := filepath.ToSlash(filePath)
+
+	findings := secrets.NewScanner(s.config.Secrets).Scan(relPath, content)
+	for i := range findings {
+		findings[i].Repository = repository
+		findings[i].Language = language
+	}
+	sortFindings(findings)
+
+	var measured []quality.Complexity
+	var smells []quality.Smell
+	if quality.Supported(language) {
+		if measured, err = quality.Measure(language, content); err == nil {
+			smells, err = quality.Detect(language, content, s.config.Smells)
+		}
+		if err != nil {
+			s.log(ctx).Error("Failed to analyze file for metrics report", zap.String("path", fullPath), zap.Error(err))
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to analyze file: %v", err)), nil
+		}
+	}
+
+	if format == "sarif" {
+		functions := make([]types.FunctionComplexity, 0, len(measured))
+		for _, function := range measured {
+			functions = append(functions, types.FunctionComplexity{
+				FilePath:   relPath,
+				Language:   language,
+				Name:       function.QualifiedName(),
+				StartLine:  function.StartLine,
+				EndLine:    function.EndLine,
+				Cyclomatic: function.Cyclomatic,
+				Cognitive:  function.Cognitive,
+			})
+		}
+		run := s.newSARIFRun()
+		addComplexityResults(run, functions, maxComplexity)
+		addSmellResults(run, relPath, smells)
+		addSecurityResults(run, findings)
+		return sarifResult(run)
+	}
+
+	blank := 0
+	for _, line := range textpos.SplitLines(content) {
+		if strings.TrimSpace(line) == "" {
+			blank++
+		}
+	}
+	result := map[string]interface{}{
+		"success":   true,
+		"file_path": filePath,
+		"language":  language,
+		"source":    source,
+		"size": map[string]interface{}{
+			"lines":       textpos.CountLines(content),
+			"blank_lines": blank,
+			"bytes":       len(contentBytes),
+			"functions":   len(measured),
+		},
+	}
+	security := summarizeFindings(findings)
+	security["findings"] = findings
+	result["security"] = security
+	if quality.Supported(language) {
+		summary := quality.NewSummary()
+		complex := []quality.Complexity{}
+		maintainability := 0.0
+		for _, function := range measured {
+			summary.Add(function.Cyclomatic, function.Cognitive)
+			maintainability += function.Maintainability
+			if function.Cyclomatic > maxComplexity {
+				complex = append(complex, function)
+			}
+		}
+		complexity := map[string]interface{}{
+			"summary":        summary,
+			"max_complexity": maxComplexity,
+			"functions":      complex,
+		}
+		if len(measured) > 0 {
+			complexity["average_maintainability_index"] = math.Round(maintainability/float64(len(measured))*100) / 100
+		}
+		result["complexity"] = complexity
+
+		byType := make(map[string]int)
+		bySeverity := make(map[string]int)
+		for _, smell := range smells {
+			byType[smell.Type]++
+			bySeverity[smell.Severity]++
+		}
+		result["smells"] = map[string]interface{}{
+			"total":       len(smells),
+			"by_type":     byType,
+			"by_severity": bySeverity,
+		}
+	} else {
+		result["warnings"] = []string{fmt.Sprintf("Complexity and code smells are not measured for %s files; supported languages are %s", language, strings.Join(quality.Languages(), ", "))}
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
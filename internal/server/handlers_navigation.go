@@ -0,0 +1,519 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/parser"
+	"github.com/my-mcp/code-indexer/internal/refactor"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Navigation handlers that read the structure of a file from its
+// tree-sitter AST, so agents can find their way around without fetching
+// whole files
+
+// implicitMemberLanguages are the languages in which a bare name inside a
+// class refers to the members of that class
+var implicitMemberLanguages = map[string]bool{
+	"java":   true,
+	"csharp": true,
+	"kotlin": true,
+	"cpp":    true,
+}
+
+// handleGetFileOutline returns the declarations of a file as a tree with
+// their line ranges, signatures and doc strings
+func (s *MCPServer) handleGetFileOutline(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling get file outline", zap.String("tool", request.Params.Name))
+
+	filePath, err := request.RequireString("file_path")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	repository := request.GetString("repository", "")
+
+	language := s.repoMgr.GetFileLanguage(filePath)
+	outlineParser := parser.NewTreeSitterParser(language)
+	if outlineParser == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Outlines are not supported for %s files", filePath)), nil
+	}
+
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	contentBytes, source, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for outline", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+
+	outline, err := outlineParser.Outline(string(contentBytes))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse file: %v", err)), nil
+	}
+
+	result := map[string]interface{}{
+		"success":      true,
+		"file_path":    filePath,
+		"full_path":    fullPath,
+		"repository":   repository,
+		"language":     language,
+		"source":       source,
+		"symbols":      outline,
+		"symbol_count": countOutline(outline),
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// countOutline returns the number of declarations in an outline, nested
+// ones included
+func countOutline(outline []parser.OutlineNode) int {
+	count := len(outline)
+	for _, node := range outline {
+		count += countOutline(node.Children)
+	}
+	return count
+}
+
+// definitionLocation is a declaration goto_definition resolved a name to
+type definitionLocation struct {
+	FilePath   string `json:"file_path"`
+	Repository string `json:"repository,omitempty"`
+	Line       int    `json:"line"` // Line of the declaration, below its doc comment
+	EndLine    int    `json:"end_line"`
+	Kind       string `json:"kind"`
+	Container  string `json:"container,omitempty"`
+	Signature  string `json:"signature,omitempty"`
+}
+
+// handleGotoDefinition resolves a name used on a line of a file to its
+// declaration: in the file itself, through the file's imports among the
+// indexed definitions, or in the file's own package
+func (s *MCPServer) handleGotoDefinition(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling goto definition", zap.String("tool", request.Params.Name))
+
+	filePath, err := request.RequireString("file_path")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	symbolName, err := request.RequireString("symbol_name")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid symbol_name parameter: %v", err)), nil
+	}
+	line := int(request.GetFloat("line", 0))
+	if line < 1 {
+		return mcp.NewToolResultError("Invalid line parameter: must be a 1-based line number"), nil
+	}
+	repository := request.GetString("repository", "")
+
+	language := s.repoMgr.GetFileLanguage(filePath)
+	fileParser := parser.NewTreeSitterParser(language)
+	if fileParser == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Go to definition is not supported for %s files", filePath)), nil
+	}
+
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	contentBytes, _, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for goto definition", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+
+	qualifier, resolvedBy, definitions, err := s.resolveDefinitions(ctx, fileParser, repository, filePath, fullPath, language, string(contentBytes), symbolName, line)
+	if err != nil {
+		s.log(ctx).Error("Failed to resolve definition", zap.Error(err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if len(definitions) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("No definition of %s found", symbolName)), nil
+	}
+
+	result := map[string]interface{}{
+		"success":         true,
+		"symbol_name":     symbolName,
+		"qualifier":       qualifier,
+		"file_path":       filePath,
+		"line":            line,
+		"resolved_by":     resolvedBy,
+		"definition":      definitions[0],
+		"candidate_count": len(definitions),
+	}
+	if len(definitions) > 1 {
+		result["candidates"] = definitions
+		result["message"] = fmt.Sprintf("%s resolves to %d declarations; the first is returned as the definition", symbolName, len(definitions))
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// resolveDefinitions resolves symbolName used on a line of a file to its
+// declarations: in the file itself first, then through the file's imports
+// among the indexed definitions or in its own package. It returns the
+// qualifier of the use and how the definitions were resolved.
+func (s *MCPServer) resolveDefinitions(ctx context.Context, fileParser *parser.TreeSitterParser, repository, filePath, fullPath, language, content, symbolName string, line int) (string, string, []definitionLocation, error) {
+	qualifier, name := splitQualifiedName(symbolName)
+	if qualifier == "" {
+		var err error
+		if qualifier, err = referenceQualifier(fileParser, content, fullPath, name, line); err != nil {
+			return "", "", nil, fmt.Errorf("Failed to parse file: %v", err)
+		}
+	}
+	symbols, err := fileParser.Symbols(content)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("Failed to parse file: %v", err)
+	}
+
+	resolvedBy := "file"
+	definitions := s.fileDefinitions(content, filePath, repository, fileDeclarations(symbols, language, name, qualifier, line, false))
+	if len(definitions) == 0 {
+		resolvedBy, definitions, err = s.indexedDefinitions(ctx, repository, fullPath, language, content, name, qualifier)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("Definition search failed: %v", err)
+		}
+	}
+	if resolvedBy == "index" && qualifier != "" {
+		// A member access on a variable whose type is unknown; members
+		// declared in the file come first
+		if members := fileDeclarations(symbols, language, name, qualifier, line, true); len(members) > 0 {
+			resolvedBy = "file"
+			definitions = s.fileDefinitions(content, filePath, repository, members)
+		}
+	}
+	return qualifier, resolvedBy, definitions, nil
+}
+
+// splitQualifiedName splits a name such as store.Load or Store::Load into
+// its qualifier and the name itself
+func splitQualifiedName(symbolName string) (qualifier, name string) {
+	symbolName = strings.ReplaceAll(symbolName, "::", ".")
+	if idx := strings.LastIndex(symbolName, "."); idx >= 0 {
+		return symbolName[:idx], symbolName[idx+1:]
+	}
+	return "", symbolName
+}
+
+// referenceQualifier returns the qualifier of the first use of name on a
+// line, such as the package in store.Load(), or "" for a bare use
+func referenceQualifier(fileParser *parser.TreeSitterParser, content, filePath, name string, line int) (string, error) {
+	file, err := fileParser.Parse(content, filePath)
+	if err != nil {
+		return "", err
+	}
+	for _, reference := range file.References {
+		if reference.Line == line && reference.Name == name {
+			return reference.Qualifier, nil
+		}
+	}
+	return "", nil
+}
+
+// fileDeclarations returns the declarations of name in a file that a use
+// with qualifier on line can refer to. Bare names refer to top-level
+// declarations, and to members of the enclosing class in languages where
+// members are in scope; self and this refer to the enclosing class, other
+// qualifiers to the class they name. With members, any member named name
+// qualifies, for member accesses on variables.
+func fileDeclarations(symbols []parser.SymbolRange, language, name, qualifier string, line int, members bool) []parser.SymbolRange {
+	enclosing := ""
+	var innermost *parser.SymbolRange
+	for idx := range symbols {
+		symbol := &symbols[idx]
+		if line >= symbol.StartLine && line <= symbol.EndLine && (innermost == nil || symbol.Start >= innermost.Start) {
+			innermost = symbol
+		}
+	}
+	if innermost != nil {
+		enclosing = innermost.QualifiedName()
+		if innermost.Kind == "function" || innermost.Kind == "method" || innermost.Kind == "constructor" {
+			enclosing = innermost.Container
+		}
+	}
+
+	var declarations []parser.SymbolRange
+	for _, symbol := range symbols {
+		if symbol.Name != name {
+			continue
+		}
+		var matches bool
+		switch {
+		case members:
+			matches = symbol.Container != ""
+		case qualifier == "":
+			matches = symbol.Container == "" || (implicitMemberLanguages[language] && enclosing != "" && symbol.Container == enclosing)
+		case qualifier == "self" || qualifier == "this":
+			matches = enclosing != "" && symbol.Container == enclosing
+		default:
+			matches = symbol.Container == qualifier || strings.HasSuffix(symbol.Container, "."+qualifier)
+		}
+		if matches {
+			declarations = append(declarations, symbol)
+		}
+	}
+	return declarations
+}
+
+// fileDefinitions describes declarations of a file with content
+func (s *MCPServer) fileDefinitions(content, filePath, repository string, declarations []parser.SymbolRange) []definitionLocation {
+	definitions := make([]definitionLocation, 0, len(declarations))
+	for _, symbol := range declarations {
+		definitions = append(definitions, definitionLocation{
+			FilePath:   filePath,
+			Repository: repository,
+			Line:       strings.Count(content[:symbol.DeclStart], "\n") + 1,
+			EndLine:    symbol.EndLine,
+			Kind:       symbol.Kind,
+			Container:  symbol.Container,
+			Signature:  parser.SymbolSignature(content, symbol),
+		})
+	}
+	return definitions
+}
+
+// indexedDefinitions looks up the indexed declarations of name and keeps
+// those the file at fullPath can refer to with qualifier: declarations in a
+// module the file imports under that qualifier, or imports name from, and
+// for bare names declarations in the file's own package. Imports of modules
+// other indexed repositories provide are followed into those repositories.
+// When none is reachable that way, every other declaration of the name in
+// the file's language is returned. It reports how the definitions were
+// resolved.
+func (s *MCPServer) indexedDefinitions(ctx context.Context, repository, fullPath, language, content, name, qualifier string) (string, []definitionLocation, error) {
+	repo, indexed := s.owningRepository(ctx, repository, fullPath)
+	if !indexed {
+		return "index", nil, nil
+	}
+	root, err := s.repoMgr.ResolvePath(repo.Path)
+	if err != nil {
+		return "", nil, err
+	}
+	results, err := s.findDefinitions(ctx, name, "", repo.Name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	scope := refactor.FileModule(language, root, fullPath, []byte(content))
+	imports := make(map[string]*refactor.ModuleImports)
+	var imported, packaged, others []definitionLocation
+	for _, result := range results {
+		if result.Language != language {
+			continue
+		}
+		candidatePath := filepath.Join(root, result.FilePath)
+		if candidatePath == fullPath {
+			continue
+		}
+		candidateContent, err := s.repoMgr.ReadFile(candidatePath)
+		if err != nil {
+			s.log(ctx).Debug("Skipping definition candidate", zap.String("path", candidatePath), zap.Error(err))
+			continue
+		}
+		definition := s.indexedDefinition(string(candidateContent), repo.Name, result)
+
+		module := refactor.FileModule(language, root, candidatePath, candidateContent)
+		moduleImports, ok := imports[module.Path]
+		if !ok && module.Path != "" {
+			if moduleImports, err = refactor.ImportsOf(language, content, module, scope); err != nil {
+				return "", nil, err
+			}
+			imports[module.Path] = moduleImports
+		}
+
+		switch {
+		case moduleImports != nil && qualifier != "" && moduleImports.Qualifiers[qualifier]:
+			imported = append(imported, definition)
+		case moduleImports != nil && qualifier == "" && (moduleImports.Wildcard || moduleImports.Names[name] == name):
+			imported = append(imported, definition)
+		case qualifier == "" && refactor.SamePackage(language, fullPath, candidatePath):
+			packaged = append(packaged, definition)
+		default:
+			others = append(others, definition)
+		}
+	}
+
+	if len(imported) == 0 {
+		if imported, err = s.importedDefinitions(ctx, repo, scope, fullPath, language, content, name, qualifier); err != nil {
+			return "", nil, err
+		}
+	}
+
+	switch {
+	case len(imported) > 0:
+		return "import", imported, nil
+	case len(packaged) > 0:
+		return "package", packaged, nil
+	}
+	return "index", others, nil
+}
+
+// importedDefinitions looks up the declarations of name in the other
+// indexed repositories providing modules the file at fullPath imports, and
+// keeps those the file refers to with qualifier
+func (s *MCPServer) importedDefinitions(ctx context.Context, repo *types.Repository, scope refactor.Module, fullPath, language, content, name, qualifier string) ([]definitionLocation, error) {
+	file, err := parser.NewTreeSitterParser(language).Parse(content, fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	searched := map[string]bool{repo.ID: true}
+	var definitions []definitionLocation
+	for _, imp := range file.Imports {
+		for _, other := range s.indexer.ModuleRepositories(language, imp.Module) {
+			if searched[other.ID] {
+				continue
+			}
+			searched[other.ID] = true
+			found, err := s.repositoryImportedDefinitions(ctx, &other, scope, language, content, name, qualifier)
+			if err != nil {
+				return nil, err
+			}
+			definitions = append(definitions, found...)
+		}
+	}
+	return definitions, nil
+}
+
+// repositoryImportedDefinitions returns the declarations of name in another
+// repository that a file of language, with content, imports under
+// qualifier, or imports name from
+func (s *MCPServer) repositoryImportedDefinitions(ctx context.Context, repo *types.Repository, scope refactor.Module, language, content, name, qualifier string) ([]definitionLocation, error) {
+	root, err := s.repoMgr.ResolvePath(repo.Path)
+	if err != nil {
+		s.log(ctx).Debug("Skipping imported repository", zap.String("repository", repo.Name), zap.Error(err))
+		return nil, nil
+	}
+	results, err := s.findDefinitions(ctx, name, "", repo.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := make(map[string]*refactor.ModuleImports)
+	var definitions []definitionLocation
+	for _, result := range results {
+		if types.ModuleLanguage(result.Language) != types.ModuleLanguage(language) {
+			continue
+		}
+		candidatePath := filepath.Join(root, result.FilePath)
+		candidateContent, err := s.repoMgr.ReadFile(candidatePath)
+		if err != nil {
+			s.log(ctx).Debug("Skipping definition candidate", zap.String("path", candidatePath), zap.Error(err))
+			continue
+		}
+		module := importedModule(repo, root, result.Language, candidatePath, candidateContent)
+		if module.Path == "" {
+			continue
+		}
+		moduleImports, ok := imports[module.Path]
+		if !ok {
+			if moduleImports, err = refactor.ImportsOf(language, content, module, scope); err != nil {
+				return nil, err
+			}
+			imports[module.Path] = moduleImports
+		}
+		if (qualifier != "" && moduleImports.Qualifiers[qualifier]) || (qualifier == "" && (moduleImports.Wildcard || moduleImports.Names[name] == name)) {
+			definitions = append(definitions, s.indexedDefinition(string(candidateContent), repo.Name, result))
+		}
+	}
+	return definitions, nil
+}
+
+// importedModule returns the module of a file of another repository as the
+// files importing it name it. Go and Python modules are named alike
+// everywhere; a script is named by the npm package it is in followed by its
+// path in the package, or by the package alone for the package's main
+// script.
+func importedModule(repo *types.Repository, root, language, filePath string, content []byte) refactor.Module {
+	if types.ModuleLanguage(language) != "javascript" {
+		return refactor.FileModule(language, root, filePath, content)
+	}
+
+	// The innermost package the script is in
+	var pkg types.RepositoryModule
+	scriptPath := ""
+	for _, module := range repo.Modules {
+		if module.Language != "javascript" {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Join(root, filepath.FromSlash(module.Dir)), filePath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if rel = filepath.ToSlash(rel); scriptPath == "" || len(rel) < len(scriptPath) {
+			pkg, scriptPath = module, rel
+		}
+	}
+	if scriptPath == "" {
+		return refactor.Module{}
+	}
+	scriptPath = refactor.ScriptModulePath(scriptPath)
+	if scriptPath == "." || scriptPath == packageMain(filepath.Join(root, filepath.FromSlash(pkg.Dir))) {
+		return refactor.Module{Path: pkg.Path}
+	}
+	return refactor.Module{Path: pkg.Path + "/" + scriptPath}
+}
+
+// packageMain returns the module path of the main script the package.json
+// in dir declares, or "" when it declares none
+func packageMain(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var manifest struct {
+		Main string `json:"main"`
+	}
+	if json.Unmarshal(data, &manifest) != nil || manifest.Main == "" {
+		return ""
+	}
+	return refactor.ScriptModulePath(path.Clean(manifest.Main))
+}
+
+// indexedDefinition describes an indexed declaration, taking its range and
+// signature from the current content of its file when it is still there
+func (s *MCPServer) indexedDefinition(content, repository string, result types.SearchResult) definitionLocation {
+	definition := definitionLocation{
+		FilePath:   result.FilePath,
+		Repository: repository,
+		Line:       result.StartLine,
+		EndLine:    result.EndLine,
+		Kind:       result.Type,
+	}
+	symbolParser := parser.NewTreeSitterParser(result.Language)
+	if symbolParser == nil {
+		return definition
+	}
+	symbols, err := symbolParser.Symbols(content)
+	if err != nil {
+		return definition
+	}
+	symbol, err := parser.LocateSymbol(symbols, result.Name, result.StartLine)
+	if err != nil {
+		return definition
+	}
+	located := s.fileDefinitions(content, result.FilePath, repository, []parser.SymbolRange{symbol})
+	return located[0]
+}
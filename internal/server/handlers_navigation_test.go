@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/parser"
+)
+
+func TestFileDeclarations(t *testing.T) {
+	source := `package store
+
+type Store struct{}
+
+func (s *Store) Load() int {
+	return Load() + s.Load()
+}
+
+func Load() int { return 1 }
+`
+	symbols, err := parser.NewTreeSitterParser("go").Symbols(source)
+	if err != nil {
+		t.Fatalf("Symbols failed: %v", err)
+	}
+
+	if declarations := fileDeclarations(symbols, "go", "Load", "", 6, false); len(declarations) != 1 || declarations[0].Container != "" {
+		t.Errorf("Expected the bare name to resolve to the function, got %+v", declarations)
+	}
+	if declarations := fileDeclarations(symbols, "go", "Load", "s", 6, false); len(declarations) != 0 {
+		t.Errorf("Expected no declaration named by the receiver variable, got %+v", declarations)
+	}
+	if declarations := fileDeclarations(symbols, "go", "Load", "s", 6, true); len(declarations) != 1 || declarations[0].Container != "Store" {
+		t.Errorf("Expected the member access to fall back to the method, got %+v", declarations)
+	}
+	if declarations := fileDeclarations(symbols, "go", "Load", "Store", 0, false); len(declarations) != 1 || declarations[0].Container != "Store" {
+		t.Errorf("Expected the qualified name to resolve to the method, got %+v", declarations)
+	}
+
+	if qualifier, name := splitQualifiedName("store::Store.Load"); qualifier != "store.Store" || name != "Load" {
+		t.Errorf("Unexpected split of a qualified name: %q %q", qualifier, name)
+	}
+}
+
+func TestCrossRepositoryDefinitions(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"shared/go.mod":         "module example.com/shared\n\ngo 1.23\n",
+		"shared/store/store.go": "package store\n\nfunc Load() int {\n\treturn 1\n}\n",
+		"app/go.mod":            "module example.com/app\n\ngo 1.23\n",
+		"app/main.go":           "package main\n\nimport \"example.com/shared/store\"\n\nfunc main() {\n\tstore.Load()\n}\n",
+		"app/local.go":          "package main\n\nfunc Load() int {\n\treturn 2\n}\n",
+		"app/lock.go":           "package main\n\ntype Manager struct{}\n\nfunc (m *Manager) AcquireLock(id string) error {\n\treturn nil\n}\n",
+		"app/lock_test.go":      "package main\n\nfunc acquire(m *Manager) {\n\tm.AcquireLock(\"a\")\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	for _, name := range []string{"shared", "app"} {
+		if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": filepath.Join(root, name), "name": name}); isError {
+			t.Fatalf("Failed to index %s: %s", name, text)
+		}
+	}
+
+	text, isError := callTool(t, s, "goto_definition", map[string]interface{}{"file_path": "main.go", "repository": "app", "symbol_name": "Load", "line": 6})
+	if isError {
+		t.Fatalf("goto_definition failed: %s", text)
+	}
+	var definition struct {
+		ResolvedBy string `json:"resolved_by"`
+		Definition struct {
+			FilePath   string `json:"file_path"`
+			Repository string `json:"repository"`
+		} `json:"definition"`
+		CandidateCount int `json:"candidate_count"`
+	}
+	if err := json.Unmarshal([]byte(text), &definition); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if definition.ResolvedBy != "import" || definition.Definition.Repository != "shared" || definition.Definition.FilePath != "store/store.go" || definition.CandidateCount != 1 {
+		t.Errorf("Expected store.Load to resolve into the shared repository, got %s", text)
+	}
+
+	// Methods are found in the other files of their package
+	text, isError = callTool(t, s, "goto_definition", map[string]interface{}{"file_path": "lock_test.go", "repository": "app", "symbol_name": "AcquireLock", "line": 4})
+	if isError {
+		t.Fatalf("goto_definition of a method failed: %s", text)
+	}
+	if err := json.Unmarshal([]byte(text), &definition); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if definition.Definition.Repository != "app" || definition.Definition.FilePath != "lock.go" {
+		t.Errorf("Expected AcquireLock to resolve to lock.go, got %s", text)
+	}
+	text, isError = callTool(t, s, "search_code", map[string]interface{}{"query": "AcquireLock", "repository": "app", "follow_ups": false})
+	var search struct {
+		Results []struct {
+			Name string `json:"name"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(text), &search); isError || err != nil || len(search.Results) == 0 || search.Results[0].Name != "AcquireLock" {
+		t.Errorf("Expected the AcquireLock method to rank first, got %s", text)
+	}
+	if text, isError = callTool(t, s, "complete_symbol", map[string]interface{}{"prefix": "Acq", "repository": "app"}); isError || !strings.Contains(text, `"AcquireLock"`) {
+		t.Errorf("Expected AcquireLock to be completed, got %s", text)
+	}
+
+	text, isError = callTool(t, s, "find_references", map[string]interface{}{"symbol_name": "Load", "repository": "shared"})
+	if isError {
+		t.Fatalf("find_references failed: %s", text)
+	}
+	var references struct {
+		References []struct {
+			FilePath         string `json:"file_path"`
+			Repository       string `json:"repository"`
+			Target           string `json:"target"`
+			TargetRepository string `json:"target_repository"`
+		} `json:"references"`
+	}
+	if err := json.Unmarshal([]byte(text), &references); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if len(references.References) != 1 {
+		t.Fatalf("Expected the reference from the importing repository, got %s", text)
+	}
+	if ref := references.References[0]; ref.Repository != "app" || ref.FilePath != "main.go" || ref.Target != "store/store.go:Load" || ref.TargetRepository != "shared" {
+		t.Errorf("Expected the reference to target the shared repository, got %+v", ref)
+	}
+}
@@ -5,19 +5,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
 	"runtime"
+	"sort"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/my-mcp/code-indexer/internal/logging"
+	"github.com/my-mcp/code-indexer/internal/parser"
+	"github.com/my-mcp/code-indexer/internal/quality"
+	"github.com/my-mcp/code-indexer/internal/stacktrace"
 	"github.com/my-mcp/code-indexer/pkg/types"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 // Project management tool handlers for configuration and project operations
 
 // handleGetCurrentConfig handles current configuration requests
 func (s *MCPServer) handleGetCurrentConfig(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling get current config", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling get current config", zap.String("tool", request.Params.Name))
 
 	// Get current working directory
 	cwd, err := os.Getwd()
@@ -26,19 +33,19 @@ func (s *MCPServer) handleGetCurrentConfig(ctx context.Context, request mcp.Call
 	}
 
 	// Get repository statistics
-	repoStats, err := s.searcher.GetIndexStats(ctx)
+	repoStats, err := s.indexer.GetIndexStats(ctx)
 	var statsInterface interface{}
 	if err != nil {
-		s.logger.Warn("Failed to get repository stats", zap.Error(err))
+		s.log(ctx).Warn("Failed to get repository stats", zap.Error(err))
 		statsInterface = map[string]interface{}{"error": "Failed to retrieve stats"}
 	} else {
 		statsInterface = repoStats
 	}
 
 	// Get available repositories
-	repositories, err := s.searcher.ListRepositories(ctx)
+	repositories, err := s.indexer.ListRepositories(ctx)
 	if err != nil {
-		s.logger.Warn("Failed to list repositories", zap.Error(err))
+		s.log(ctx).Warn("Failed to list repositories", zap.Error(err))
 		repositories = []types.Repository{}
 	}
 
@@ -58,7 +65,7 @@ func (s *MCPServer) handleGetCurrentConfig(ctx context.Context, request mcp.Call
 			"categories": map[string]interface{}{
 				"core":    5,
 				"utility": 7, // Updated to include file manipulation tools
-				"ai":      3,
+				"ai":      5,
 				"project": 5, // New category
 			},
 		},
@@ -68,6 +75,7 @@ func (s *MCPServer) handleGetCurrentConfig(ctx context.Context, request mcp.Call
 		},
 		"models": map[string]interface{}{
 			"enabled":       s.modelsEngine.IsEnabled(),
+			"provider":      s.modelsEngine.ProviderName(),
 			"default_model": s.config.Models.DefaultModel,
 		},
 		"system": map[string]interface{}{
@@ -86,12 +94,155 @@ func (s *MCPServer) handleGetCurrentConfig(ctx context.Context, request mcp.Call
 	return mcp.NewToolResultText(string(content)), nil
 }
 
+// capabilitiesSchemaVersion is bumped whenever get_capabilities changes shape
+const capabilitiesSchemaVersion = 1
+
+// writeTools are the tools that modify files on disk
+var writeTools = []string{
+	"delete_lines", "insert_at_line", "replace_lines",
+	"replace_symbol_body", "insert_after_symbol", "insert_before_symbol", "rename_symbol",
+	"undo_last_edit", "redo_edit",
+}
+
+// isWriteTool reports whether a tool modifies files on disk
+func isWriteTool(name string) bool {
+	for _, tool := range writeTools {
+		if tool == name {
+			return true
+		}
+	}
+	return false
+}
+
+// handleGetCapabilities handles capability requests. The response describes
+// what this deployment actually supports so clients do not have to infer it
+// from the server version.
+func (s *MCPServer) handleGetCapabilities(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling get capabilities", zap.String("tool", request.Params.Name))
+
+	// Map each indexed extension to its language and parser
+	implementations := s.indexer.ParserImplementations()
+	languages := make(map[string]map[string]interface{})
+	for _, ext := range s.config.Indexer.SupportedExtensions {
+		language := s.repoMgr.GetFileLanguage(ext)
+		entry, ok := languages[language]
+		if !ok {
+			implementation, known := implementations[language]
+			if !known {
+				implementation = implementations["generic"]
+			}
+			entry = map[string]interface{}{
+				"extensions":  []string{},
+				"parser":      implementation,
+				"tree_sitter": implementation == parser.ImplementationTreeSitter,
+			}
+			languages[language] = entry
+		}
+		entry["extensions"] = append(entry["extensions"].([]string), ext)
+	}
+
+	var treeSitterLanguages []string
+	for language, implementation := range implementations {
+		if implementation == parser.ImplementationTreeSitter {
+			treeSitterLanguages = append(treeSitterLanguages, language)
+		}
+	}
+	sort.Strings(treeSitterLanguages)
+
+	_, gitErr := exec.LookPath("git")
+
+	embeddingsFeature := map[string]interface{}{
+		"enabled": s.embeddings != nil,
+	}
+	if s.embeddings != nil {
+		embeddingsFeature["provider"] = s.config.Embeddings.Provider
+		embeddingsFeature["model"] = s.embeddings.Model()
+		embeddingsFeature["hybrid_weight"] = s.config.Embeddings.HybridWeight
+	}
+
+	capabilities := map[string]interface{}{
+		"schema_version": capabilitiesSchemaVersion,
+		"server": map[string]interface{}{
+			"name":    s.config.Server.Name,
+			"version": s.config.Server.Version,
+		},
+		"languages": languages,
+		"parsers": map[string]interface{}{
+			"tree_sitter": treeSitterLanguages,
+			"fallback":    implementations["generic"],
+		},
+		"features": map[string]interface{}{
+			"embeddings":      embeddingsFeature,
+			"semantic_search": s.embeddings != nil,
+			"lsp":             false,
+			"models": map[string]interface{}{
+				"enabled":       s.modelsEngine.IsEnabled(),
+				"provider":      s.modelsEngine.ProviderName(),
+				"default_model": s.config.Models.DefaultModel,
+				"tool_models":   s.config.Models.ToolModels,
+				"max_tokens":    s.config.Models.MaxTokens,
+			},
+			"multi_session":  s.config.Server.MultiSession.Enabled,
+			"multi_ide":      s.config.Server.MultiIDE.Enabled,
+			"memory_index":   s.config.Indexer.MemoryIndex,
+			"clone_cache":    s.repoMgr.ObjectCacheEnabled(),
+			"git":            gitErr == nil,
+			"editor_buffers": true,
+			"follow_ups":     true,
+			"call_graph":     treeSitterLanguages,
+			"stack_traces":   []string{stacktrace.LanguageGo, stacktrace.LanguagePython, stacktrace.LanguageJavaScript},
+			"code_smells":    quality.Languages(),
+			"complexity":     quality.Languages(),
+			"secrets":        s.config.Secrets.Enabled,
+		},
+		"write_tools": map[string]interface{}{
+			"enabled": !s.config.Server.ReadOnly,
+			"tools":   writeTools,
+		},
+		"file_access": map[string]interface{}{
+			"sandboxed": true,
+			"roots":     s.repoMgr.SandboxRoots(),
+		},
+		"limits": map[string]interface{}{
+			"search_code_default_results":     defaultSearchMaxResults,
+			"semantic_search_default_results": defaultSemanticMaxResults,
+			"find_files_max_results":          findFilesMaxResults,
+			"find_symbols_max_results":        findSymbolsMaxResults,
+			"complete_symbol_max_limit":       completeSymbolMaxLimit,
+			"find_references_max_results":     findReferencesMaxResults,
+			"list_directory_default_limit":    defaultListDirectoryLimit,
+			"list_directory_max_limit":        maxListDirectoryLimit,
+			"grep_repository_max_results":     grepMaxResults,
+			"grep_repository_max_files":       grepMaxFilesSearched,
+			"git_diff_max_lines":              gitDiffMaxLines,
+			"get_diagnostics_max_results":     getDiagnosticsMaxResults,
+			"find_dependencies_max_edges":     findDependenciesMaxEdges,
+			"get_chunk_graph_max_edges":       chunkGraphMaxEdges,
+			"generate_tests_max_targets":      maxTestTargets,
+			"ask_codebase_max_results":        askCodebaseMaxResults,
+			"analyze_complexity_limit":        analyzeComplexityDefaultLimit,
+			"security_findings_max_results":   listSecurityFindingsMaxResults,
+			"max_file_size_bytes":             s.config.Indexer.MaxFileSize,
+			"snippet_length":                  s.config.Search.SnippetLength,
+			"max_sessions":                    s.config.Server.MultiSession.MaxSessions,
+			"response_max_bytes":              s.config.Server.ResponseLimits.DefaultBytes,
+		},
+	}
+
+	content, err := json.MarshalIndent(capabilities, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format capabilities"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
+
 // handleInitialInstructions handles initial instructions requests
 func (s *MCPServer) handleInitialInstructions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling initial instructions", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling initial instructions", zap.String("tool", request.Params.Name))
 
 	instructions := map[string]interface{}{
-		"title": "MCP Code Indexer - Initial Instructions",
+		"title":       "MCP Code Indexer - Initial Instructions",
 		"description": "Welcome to the MCP Code Indexer! This tool provides intelligent code analysis and assistance.",
 		"instructions": []string{
 			"1. Start by indexing your repositories using 'index_repository' tool",
@@ -109,27 +260,50 @@ func (s *MCPServer) handleInitialInstructions(ctx context.Context, request mcp.C
 				"get_metadata - Get detailed metadata for specific files",
 				"list_repositories - List all indexed repositories",
 				"get_index_stats - Get indexing statistics",
+				"indexing_history - Get per-phase timings of past indexing runs",
 			},
 			"utility_tools": []string{
 				"find_files - Find files matching patterns",
-				"find_symbols - Find symbols (functions, classes, variables)",
+				"find_symbols - Find symbols (functions, classes, interfaces, type aliases, variables)",
+				"complete_symbol - Complete a partial symbol name from the indexed names",
+				"save_search / run_saved_search - Save a search under a name and run it again",
+				"list_saved_searches - List saved searches and the searches run in this session",
+				"set_workspace / list_workspaces - Group repositories into named workspaces",
+				"use_workspace - Scope this session's searches to a workspace",
+				"list_projects - List the sub-projects of a monorepo, for the project filter",
+				"export_index / import_index - Share indexes between servers as archive files",
 				"get_file_content - Get full content of specific files",
 				"list_directory - List files and directories",
 				"delete_lines - Delete a range of lines from a file",
 				"insert_at_line - Insert content at a specific line",
 				"replace_lines - Replace a range of lines with new content",
+				"replace_symbol_body - Replace the body of a function, method or type by name",
+				"insert_after_symbol / insert_before_symbol - Insert code next to a named declaration",
+				"rename_symbol - Rename a symbol at its definition and every reference in its repository",
+				"undo_last_edit / redo_edit - Undo or redo edits made by the file manipulation tools",
+				"list_edit_history - List the edits made in this session",
+				"sync_buffer - Share unsaved editor contents with the indexer",
+				"resolve_stacktrace - Map a stack trace to files, symbols and snippets",
+				"semantic_search - Find code by meaning when embeddings are enabled",
+				"get_context_bundle - Assemble an LLM-ready context of a symbol within a token budget",
+				"ask_codebase - Ask a question in plain words and get cited places in the code",
+				"get_chunk_graph - Map which indexed chunks depend on which",
+				"grep_repository - Search files on disk with context lines when the index is stale",
 			},
 			"ai_tools": []string{
 				"generate_code - Generate code from natural language",
 				"analyze_code - Analyze code quality and get suggestions",
 				"explain_code - Get AI explanation of code functionality",
+				"summarize_diff - Write a commit message or pull request description for a diff",
+				"generate_tests - Write unit tests in the repository's test framework",
 			},
 			"project_tools": []string{
 				"get_current_config - Get current configuration and status",
 				"initial_instructions - Get these initial instructions",
 				"remove_project - Remove a project from configuration",
-				"restart_language_server - Restart the language server",
+				"restart_language_server - Restart the running language servers",
 				"summarize_changes - Get instructions for summarizing changes",
+				"get_capabilities - Get the languages, features and limits this deployment supports",
 			},
 		},
 		"tips": []string{
@@ -158,7 +332,7 @@ func (s *MCPServer) handleInitialInstructions(ctx context.Context, request mcp.C
 
 // handleRemoveProject handles project removal requests
 func (s *MCPServer) handleRemoveProject(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling remove project", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling remove project", zap.String("tool", request.Params.Name))
 
 	projectName, err := request.RequireString("project_name")
 	if err != nil {
@@ -168,7 +342,7 @@ func (s *MCPServer) handleRemoveProject(ctx context.Context, request mcp.CallToo
 	// Check if project exists in repositories
 	repositories, err := s.searcher.ListRepositories(ctx)
 	if err != nil {
-		s.logger.Error("Failed to list repositories", zap.Error(err))
+		s.log(ctx).Error("Failed to list repositories", zap.Error(err))
 		return mcp.NewToolResultError("Failed to access repository list"), nil
 	}
 
@@ -194,7 +368,7 @@ func (s *MCPServer) handleRemoveProject(ctx context.Context, request mcp.CallToo
 		"timestamp":    time.Now().Format(time.RFC3339),
 	}
 
-	s.logger.Info("Project removal requested", zap.String("project", projectName))
+	s.log(ctx).Info("Project removal requested", zap.String("project", projectName))
 
 	content, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
@@ -204,29 +378,76 @@ func (s *MCPServer) handleRemoveProject(ctx context.Context, request mcp.CallToo
 	return mcp.NewToolResultText(string(content)), nil
 }
 
-// handleRestartLanguageServer handles language server restart requests
+// handleRestartLanguageServer stops the running language servers, all of
+// them or those of a repository or language; they start again on their
+// next use
 func (s *MCPServer) handleRestartLanguageServer(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling restart language server", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling restart language server", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	language := request.GetString("language", "")
+	root := ""
+	if repository != "" {
+		repo, ok := s.indexer.IndexedRepository(repository)
+		if !ok {
+			return repoNotFound(repository), nil
+		}
+		resolved, err := s.repoMgr.ResolvePath(repo.Path)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+		root = resolved
+	}
+
+	stopped := s.lsp.Restart(root, language)
+	message := fmt.Sprintf("Stopped %d language server(s); they restart on their next use", len(stopped))
+	if len(stopped) == 0 {
+		message = "No matching language server was running"
+	}
+	s.log(ctx).Info("Language servers restarted", zap.Int("stopped", len(stopped)))
 
-	// In a real implementation, this would restart the language server
-	// For now, we'll simulate the restart and provide useful information
 	result := map[string]interface{}{
-		"success": true,
-		"message": "Language server restart initiated",
-		"details": map[string]interface{}{
-			"reason":     "External file changes detected or manual restart requested",
-			"action":     "Simulated restart - in production this would restart the Go language server",
-			"timestamp":  time.Now().Format(time.RFC3339),
-			"suggestion": "If you're experiencing issues with code completion or analysis, this restart should resolve them",
-		},
-		"next_steps": []string{
-			"Wait a few seconds for the language server to fully restart",
-			"Try using code completion or analysis features",
-			"If issues persist, check the language server logs",
-		},
+		"success":    true,
+		"message":    message,
+		"repository": repository,
+		"language":   language,
+		"stopped":    stopped,
+		"running":    s.lsp.Running(),
 	}
 
-	s.logger.Info("Language server restart simulated")
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// handleSetLogLevel changes the level of the server's logs until it exits,
+// so a live session can be debugged without a restart
+func (s *MCPServer) handleSetLogLevel(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, err := request.RequireString("level")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	level, err := zapcore.ParseLevel(name)
+	if err != nil || level < zapcore.DebugLevel || level > zapcore.ErrorLevel {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid level %q: expected debug, info, warn or error", name)), nil
+	}
+
+	previous := logging.Level.Level()
+	logging.Level.SetLevel(level)
+	s.mutex.Lock()
+	s.config.Logging.Level = level.String()
+	s.mutex.Unlock()
+	s.log(ctx).Info("Log level changed", zap.Stringer("previous", previous), zap.Stringer("level", level))
+
+	result := map[string]interface{}{
+		"success":        true,
+		"level":          level.String(),
+		"previous_level": previous.String(),
+		"message":        fmt.Sprintf("Logging at %s level until the server exits or the level is set again", level),
+	}
 
 	content, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
@@ -238,10 +459,10 @@ func (s *MCPServer) handleRestartLanguageServer(ctx context.Context, request mcp
 
 // handleSummarizeChanges handles change summarization requests
 func (s *MCPServer) handleSummarizeChanges(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling summarize changes", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling summarize changes", zap.String("tool", request.Params.Name))
 
 	instructions := map[string]interface{}{
-		"title": "Codebase Change Summarization Instructions",
+		"title":       "Codebase Change Summarization Instructions",
 		"description": "Guidelines for effectively summarizing changes made to the codebase",
 		"summarization_framework": map[string]interface{}{
 			"structure": []string{
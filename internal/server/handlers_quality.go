@@ -0,0 +1,307 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/quality"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// analyzeComplexityDefaultLimit is how many of the most complex functions
+// and files analyze_complexity lists for a repository by default
+const analyzeComplexityDefaultLimit = 20
+
+// handleDetectCodeSmells analyzes the syntax tree of a file for long
+// functions and parameter lists, deep nesting, large classes and files,
+// duplicated lines and unnamed numbers
+func (s *MCPServer) handleDetectCodeSmells(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling detect code smells", zap.String("tool", request.Params.Name))
+
+	filePath, err := request.RequireString("file_path")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	repository := request.GetString("repository", "")
+	threshold := request.GetString("severity_threshold", "medium")
+	if quality.SeverityRank(threshold) < 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid severity_threshold %q: use %s", threshold, strings.Join(quality.Severities, ", "))), nil
+	}
+	types := make(map[string]bool)
+	for _, smellType := range s.getStringList(request, "smell_types") {
+		known := false
+		for _, candidate := range quality.SmellTypes {
+			known = known || candidate == smellType
+		}
+		if !known {
+			return mcp.NewToolResultError(fmt.Sprintf("Unknown smell type %q: use %s", smellType, strings.Join(quality.SmellTypes, ", "))), nil
+		}
+		types[smellType] = true
+	}
+	format, err := getReportFormat(request)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+
+	language := s.repoMgr.GetFileLanguage(filePath)
+	if !quality.Supported(language) {
+		return mcp.NewToolResultError(fmt.Sprintf("Code smells are not detected in %s files; supported languages are %s", language, strings.Join(quality.Languages(), ", "))), nil
+	}
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	contentBytes, source, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for code smells", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+
+	found, err := quality.Detect(language, string(contentBytes), s.config.Smells)
+	if err != nil {
+		s.log(ctx).Error("Failed to detect code smells", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to analyze file: %v", err)), nil
+	}
+
+	smells := []quality.Smell{}
+	byType := make(map[string]int)
+	bySeverity := make(map[string]int)
+	for _, smell := range found {
+		if quality.SeverityRank(smell.Severity) < quality.SeverityRank(threshold) {
+			continue
+		}
+		if len(types) > 0 && !types[smell.Type] {
+			continue
+		}
+		smells = append(smells, smell)
+		byType[smell.Type]++
+		bySeverity[smell.Severity]++
+	}
+
+	if format == "sarif" {
+		run := s.newSARIFRun()
+		addSmellResults(run, filePath, smells)
+		return sarifResult(run)
+	}
+
+	result := map[string]interface{}{
+		"success":            true,
+		"file_path":          filePath,
+		"language":           language,
+		"source":             source,
+		"severity_threshold": threshold,
+		"smells":             smells,
+		"summary": map[string]interface{}{
+			"total":       len(smells),
+			"by_type":     byType,
+			"by_severity": bySeverity,
+		},
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// handleAnalyzeComplexity measures the cyclomatic, cognitive and Halstead
+// complexity of the functions of a file, or aggregates the complexity the
+// index records for the functions of a repository
+func (s *MCPServer) handleAnalyzeComplexity(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling analyze complexity", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	filePath := request.GetString("file_path", "")
+	if repository == "" && filePath == "" {
+		return mcp.NewToolResultError("Either repository or file_path is required"), nil
+	}
+	minComplexity := int(request.GetFloat("min_complexity", 0))
+	limit := int(request.GetFloat("limit", analyzeComplexityDefaultLimit))
+	if minComplexity < 0 || limit < 0 {
+		return mcp.NewToolResultError("min_complexity and limit cannot be negative"), nil
+	}
+	sortBy := request.GetString("sort_by", "cyclomatic")
+	if sortBy != "cyclomatic" && sortBy != "cognitive" && sortBy != "line" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid sort_by %q: use cyclomatic, cognitive or line", sortBy)), nil
+	}
+
+	if filePath != "" {
+		return s.analyzeFileComplexity(ctx, request, repository, filePath, minComplexity, sortBy)
+	}
+
+	repo, ok := s.indexer.IndexedRepository(repository)
+	if !ok {
+		return repoNotFound(repository), nil
+	}
+	functions, err := s.searcher.FunctionComplexity(ctx, repo.ID)
+	if err != nil {
+		s.log(ctx).Error("Failed to read function complexity", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read function complexity: %v", err)), nil
+	}
+
+	// Per file totals of the measured functions
+	type fileComplexity struct {
+		FilePath        string `json:"file_path"`
+		Language        string `json:"language"`
+		Functions       int    `json:"functions"`
+		TotalCyclomatic int    `json:"total_cyclomatic"`
+		MaxCyclomatic   int    `json:"max_cyclomatic"`
+		TotalCognitive  int    `json:"total_cognitive"`
+	}
+	summary := quality.NewSummary()
+	byLanguage := make(map[string]*quality.Summary)
+	byFile := make(map[string]*fileComplexity)
+	measured := []types.FunctionComplexity{}
+	unmeasured := 0
+	for _, function := range functions {
+		if function.Cyclomatic == 0 {
+			unmeasured++
+			continue
+		}
+		summary.Add(function.Cyclomatic, function.Cognitive)
+		if byLanguage[function.Language] == nil {
+			byLanguage[function.Language] = quality.NewSummary()
+		}
+		byLanguage[function.Language].Add(function.Cyclomatic, function.Cognitive)
+		file := byFile[function.FilePath]
+		if file == nil {
+			file = &fileComplexity{FilePath: function.FilePath, Language: function.Language}
+			byFile[function.FilePath] = file
+		}
+		file.Functions++
+		file.TotalCyclomatic += function.Cyclomatic
+		file.TotalCognitive += function.Cognitive
+		if function.Cyclomatic > file.MaxCyclomatic {
+			file.MaxCyclomatic = function.Cyclomatic
+		}
+		if function.Cyclomatic >= minComplexity {
+			measured = append(measured, function)
+		}
+	}
+
+	sort.SliceStable(measured, func(i, j int) bool {
+		a, b := measured[i], measured[j]
+		switch {
+		case sortBy == "line" && a.FilePath != b.FilePath:
+			return a.FilePath < b.FilePath
+		case sortBy == "line":
+			return a.StartLine < b.StartLine
+		case sortBy == "cognitive" && a.Cognitive != b.Cognitive:
+			return a.Cognitive > b.Cognitive
+		case a.Cyclomatic != b.Cyclomatic:
+			return a.Cyclomatic > b.Cyclomatic
+		case a.Cognitive != b.Cognitive:
+			return a.Cognitive > b.Cognitive
+		}
+		return a.FilePath+":"+a.Name < b.FilePath+":"+b.Name
+	})
+	files := make([]*fileComplexity, 0, len(byFile))
+	for _, file := range byFile {
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].TotalCyclomatic != files[j].TotalCyclomatic {
+			return files[i].TotalCyclomatic > files[j].TotalCyclomatic
+		}
+		return files[i].FilePath < files[j].FilePath
+	})
+
+	result := map[string]interface{}{
+		"success":        true,
+		"repository":     repo.Name,
+		"summary":        summary,
+		"by_language":    byLanguage,
+		"matching":       len(measured),
+		"unmeasured":     unmeasured,
+		"min_complexity": minComplexity,
+		"sort_by":        sortBy,
+	}
+	if limit > 0 && len(measured) > limit {
+		measured = measured[:limit]
+	}
+	if limit > 0 && len(files) > limit {
+		files = files[:limit]
+	}
+	result["functions"] = measured
+	result["files"] = files
+	if len(functions) > 0 && summary.Functions == 0 {
+		result["warnings"] = []string{fmt.Sprintf("No complexity is recorded for %s; it was indexed by an older version or holds no %s files, re-index it with index_repository", repo.Name, strings.Join(quality.Languages(), ", "))}
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// analyzeFileComplexity measures the functions of one file, reading the
+// session's unsaved buffer when there is one
+func (s *MCPServer) analyzeFileComplexity(ctx context.Context, request mcp.CallToolRequest, repository, filePath string, minComplexity int, sortBy string) (*mcp.CallToolResult, error) {
+	language := s.repoMgr.GetFileLanguage(filePath)
+	if !quality.Supported(language) {
+		return mcp.NewToolResultError(fmt.Sprintf("Complexity is not measured for %s files; supported languages are %s", language, strings.Join(quality.Languages(), ", "))), nil
+	}
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	contentBytes, source, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for complexity", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+
+	measured, err := quality.Measure(language, string(contentBytes))
+	if err != nil {
+		s.log(ctx).Error("Failed to measure complexity", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to analyze file: %v", err)), nil
+	}
+
+	summary := quality.NewSummary()
+	functions := []quality.Complexity{}
+	for _, function := range measured {
+		summary.Add(function.Cyclomatic, function.Cognitive)
+		if function.Cyclomatic >= minComplexity {
+			functions = append(functions, function)
+		}
+	}
+	if sortBy != "line" {
+		sort.SliceStable(functions, func(i, j int) bool {
+			if sortBy == "cognitive" && functions[i].Cognitive != functions[j].Cognitive {
+				return functions[i].Cognitive > functions[j].Cognitive
+			}
+			return functions[i].Cyclomatic > functions[j].Cyclomatic
+		})
+	}
+
+	result := map[string]interface{}{
+		"success":        true,
+		"file_path":      filePath,
+		"language":       language,
+		"source":         source,
+		"summary":        summary,
+		"functions":      functions,
+		"min_complexity": minComplexity,
+		"sort_by":        sortBy,
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
@@ -0,0 +1,355 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/grep"
+	"github.com/my-mcp/code-indexer/internal/parser"
+	"github.com/my-mcp/code-indexer/internal/refactor"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Refactoring handlers that rewrite several files of a repository at once
+
+// renameFile is a file a rename rewrites
+type renameFile struct {
+	path     string
+	original []byte
+	rename   *refactor.FileRename
+}
+
+// handleRenameSymbol renames a symbol at its definition and every reference
+// in the repository that holds it, then re-indexes the rewritten files
+func (s *MCPServer) handleRenameSymbol(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling rename symbol", zap.String("tool", request.Params.Name))
+
+	symbolName, err := request.RequireString("symbol_name")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid symbol_name parameter: %v", err)), nil
+	}
+	newName, err := request.RequireString("new_name")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid new_name parameter: %v", err)), nil
+	}
+	if err := refactor.ValidateName(newName); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid new_name parameter: %v", err)), nil
+	}
+	repository := request.GetString("repository", "")
+	filePath := request.GetString("file_path", "")
+	line := int(request.GetFloat("line", 0))
+	dryRun := s.getBooleanValue(request, "dry_run", false)
+
+	// The definition is taken from file_path, or looked up in the index
+	fromIndex := filePath == ""
+	name := symbolName
+	if idx := strings.LastIndexAny(name, ".:"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	if filePath == "" {
+		definitions, err := s.findDefinitions(ctx, name, "", repository)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Definition search failed: %v", err)), nil
+		}
+		switch len(definitions) {
+		case 0:
+			return mcp.NewToolResultError(fmt.Sprintf("No indexed definition of %s found; pass file_path to name the file that defines it", symbolName)), nil
+		case 1:
+			repository = definitions[0].Repository
+			filePath = definitions[0].FilePath
+			if line == 0 {
+				line = definitions[0].StartLine
+			}
+		default:
+			locations := make([]string, 0, len(definitions))
+			for _, definition := range definitions {
+				locations = append(locations, fmt.Sprintf("%s/%s:%d", definition.Repository, definition.FilePath, definition.StartLine))
+			}
+			return mcp.NewToolResultError(fmt.Sprintf("%s is defined %d times (%s); pass file_path and line to pick one",
+				symbolName, len(definitions), strings.Join(locations, ", "))), nil
+		}
+	}
+
+	definitionPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	definitionPath, err = s.repoMgr.ResolvePath(definitionPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	language := s.repoMgr.GetFileLanguage(definitionPath)
+	symbolParser := parser.NewTreeSitterParser(language)
+	if symbolParser == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Renaming is not supported for %s files", definitionPath)), nil
+	}
+
+	content, err := s.repoMgr.ReadFile(definitionPath)
+	if errors.Is(err, fs.ErrNotExist) && fromIndex {
+		return toolError(types.ErrorIndexStale,
+			fmt.Sprintf("The index places %s in %s, which no longer exists; refresh the index of %s and retry", symbolName, filePath, repository),
+			map[string]interface{}{"repository": repository, "file_path": filePath, "symbol_name": symbolName}), nil
+	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+	symbols, err := symbolParser.Symbols(string(content))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse file: %v", err)), nil
+	}
+	symbol, err := parser.LocateSymbol(symbols, symbolName, line)
+	if err != nil && fromIndex {
+		// The index placed the definition where the file no longer has it
+		return toolError(types.ErrorIndexStale,
+			fmt.Sprintf("The index places %s at %s:%d, but the file no longer defines it there; refresh the index of %s and retry", symbolName, filePath, line, repository),
+			map[string]interface{}{"repository": repository, "file_path": filePath, "line": line, "symbol_name": symbolName}), nil
+	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to locate symbol in %s: %v", definitionPath, err)), nil
+	}
+	if symbol.Name == newName {
+		return mcp.NewToolResultError(fmt.Sprintf("%s is already named %s", symbol.QualifiedName(), newName)), nil
+	}
+
+	repo, indexed := s.owningRepository(ctx, repository, definitionPath)
+	root := filepath.Dir(definitionPath)
+	if indexed {
+		if root, err = s.repoMgr.ResolvePath(repo.Path); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+	}
+	target := refactor.Target{
+		Name:      symbol.Name,
+		Container: symbol.Container,
+		Module:    refactor.FileModule(language, root, definitionPath, content),
+	}
+
+	candidates, warnings, err := s.renameCandidates(ctx, root, indexed, definitionPath, language, symbol.Name)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Reference search failed: %v", err)), nil
+	}
+	if target.Module.Path == "" && len(candidates) > 1 {
+		warnings = append(warnings, fmt.Sprintf("Imports of %s cannot be resolved, so only files in its own package were renamed", definitionPath))
+	}
+
+	// Hold every candidate before reading it, so no edit lands between the
+	// rename and the write
+	if err := s.lockFiles(ctx, candidates, dryRun); err != nil {
+		return lockFailed(err), nil
+	}
+
+	// Rename in memory first, so nothing is written if any file is refused
+	var files []renameFile
+	var unresolved []map[string]interface{}
+	for _, candidate := range candidates {
+		original, err := s.repoMgr.ReadFile(candidate)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Skipped %s: %v", candidate, err))
+			continue
+		}
+		candidateLanguage := s.repoMgr.GetFileLanguage(candidate)
+		scope := refactor.Scope{
+			Local:  refactor.SamePackage(language, definitionPath, candidate),
+			Module: refactor.FileModule(candidateLanguage, root, candidate, original),
+		}
+		renamed, err := refactor.Rename(candidateLanguage, string(original), target, scope, newName)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Skipped %s: %v", candidate, err))
+			continue
+		}
+		if len(renamed.Collisions) > 0 {
+			collision := renamed.Collisions[0]
+			return mcp.NewToolResultError(fmt.Sprintf("Cannot rename %s to %s: %s %s already exists at %s:%d",
+				symbol.QualifiedName(), newName, collision.Kind, collision.QualifiedName(), candidate, collision.StartLine)), nil
+		}
+		for _, occurrence := range renamed.Unresolved {
+			unresolved = append(unresolved, map[string]interface{}{
+				"file_path": candidate,
+				"line":      occurrence.Line,
+				"column":    occurrence.Column,
+			})
+		}
+		if len(renamed.Occurrences) > 0 {
+			files = append(files, renameFile{path: candidate, original: original, rename: renamed})
+		}
+	}
+	if len(unresolved) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d member accesses of %s are on values of unknown type and were left unchanged; see unresolved and rename those that refer to %s by hand",
+			len(unresolved), symbol.Name, symbol.QualifiedName()))
+	}
+
+	var written []string
+	fileResults := make([]map[string]interface{}, 0, len(files))
+	occurrences := 0
+	for _, file := range files {
+		diff, err := s.applyEdit(ctx, request, file.path, file.original, file.rename.Content, dryRun)
+		if err != nil {
+			s.log(ctx).Error("Failed to write file during rename", zap.String("path", file.path), zap.Error(err))
+			if len(written) == 0 {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to write %s: %v; no file was changed", file.path, err)), nil
+			}
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to write %s: %v; %d files were already renamed (%s). Each is journaled separately: call undo_last_edit with file_path set to each of them to restore it",
+				file.path, err, len(written), strings.Join(written, ", "))), nil
+		}
+		if !dryRun {
+			written = append(written, file.path)
+		}
+		occurrences += len(file.rename.Occurrences)
+		fileResults = append(fileResults, map[string]interface{}{
+			"file_path":   file.path,
+			"occurrences": file.rename.Occurrences,
+			"diff":        diff,
+		})
+	}
+
+	result := map[string]interface{}{
+		"success":          true,
+		"dry_run":          dryRun,
+		"symbol":           symbol.QualifiedName(),
+		"kind":             symbol.Kind,
+		"new_name":         newName,
+		"definition":       fmt.Sprintf("%s:%d", definitionPath, symbol.StartLine),
+		"files":            fileResults,
+		"file_count":       len(fileResults),
+		"occurrence_count": occurrences,
+		"warnings":         warnings,
+	}
+	if len(unresolved) > 0 {
+		result["unresolved"] = unresolved
+	}
+	if indexed {
+		result["repository"] = repo.Name
+	}
+
+	if dryRun {
+		result["message"] = fmt.Sprintf("Dry run: would rename %s to %s at %d occurrences in %d files", symbol.QualifiedName(), newName, occurrences, len(fileResults))
+	} else {
+		result["message"] = fmt.Sprintf("Renamed %s to %s at %d occurrences in %d files", symbol.QualifiedName(), newName, occurrences, len(fileResults))
+		if indexed && len(written) > 0 {
+			reindexed, err := s.indexer.ReindexFiles(ctx, repo.ID, written)
+			if err != nil {
+				s.log(ctx).Warn("Failed to re-index renamed files", zap.Error(err))
+				warnings = append(warnings, fmt.Sprintf("Re-indexing failed: %v; run refresh_index", err))
+				result["warnings"] = warnings
+			}
+			result["reindexed_files"] = reindexed
+		}
+		s.log(ctx).Info("Symbol renamed successfully",
+			zap.String("symbol", symbol.QualifiedName()),
+			zap.String("new_name", newName),
+			zap.Int("files", len(written)),
+			zap.Int("occurrences", occurrences))
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// owningRepository returns the indexed repository a rename works in: the
+// named one, or else the one holding the definition file
+func (s *MCPServer) owningRepository(ctx context.Context, repository, definitionPath string) (*types.Repository, bool) {
+	if repository != "" {
+		return s.indexer.IndexedRepository(repository)
+	}
+	repositories, err := s.indexer.ListRepositories(ctx)
+	if err != nil {
+		s.log(ctx).Warn("Failed to list repositories", zap.Error(err))
+		return nil, false
+	}
+	for _, repo := range repositories {
+		root, err := s.repoMgr.ResolvePath(repo.Path)
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, definitionPath); err == nil && !strings.HasPrefix(rel, "..") {
+			return &repo, true
+		}
+	}
+	return nil, false
+}
+
+// renameCandidates returns the files that may refer to name: the
+// definition file and every file under root in a language that can
+// reference the symbol and holds the name as a whole word. Files are read
+// from disk rather than the index, so edits made since the repository was
+// last indexed are seen.
+func (s *MCPServer) renameCandidates(ctx context.Context, root string, indexed bool, definitionPath, language, name string) ([]string, []string, error) {
+	candidates := []string{definitionPath}
+	var warnings []string
+	if !indexed {
+		warnings = append(warnings, "The definition is not in an indexed repository; only its own file is renamed")
+		return candidates, warnings, nil
+	}
+
+	matcher, err := grep.Compile(grep.Options{
+		Pattern:       `\b` + regexp.QuoteMeta(name) + `\b`,
+		Regex:         true,
+		CaseSensitive: true,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filesScanned := 0
+	err = s.repoMgr.WalkFiles(ctx, root, nil, func(filePath string, info fs.FileInfo) error {
+		relativePath, err := filepath.Rel(root, filePath)
+		if err != nil || filePath == definitionPath {
+			return nil
+		}
+		relativePath = filepath.ToSlash(relativePath)
+		if strings.HasPrefix(relativePath, ".git/") || !sameLanguageFamily(language, s.repoMgr.GetFileLanguage(filePath)) {
+			return nil
+		}
+		if filesScanned >= grepMaxFilesSearched {
+			warnings = append(warnings, fmt.Sprintf("Stopped after searching %d files; some references may not have been renamed", grepMaxFilesSearched))
+			return filepath.SkipAll
+		}
+		if info.Size() > s.config.Indexer.MaxFileSize {
+			warnings = append(warnings, fmt.Sprintf("Skipped %s: larger than the maximum file size", filePath))
+			return nil
+		}
+
+		content, err := s.repoMgr.ReadFile(filePath)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Skipped %s: %v", filePath, err))
+			return nil
+		}
+		filesScanned++
+		if len(matcher.Search(relativePath, content)) > 0 {
+			candidates = append(candidates, filePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(candidates[1:])
+	return candidates, warnings, nil
+}
+
+// sameLanguageFamily reports whether a symbol of one language can be
+// referenced from files of the other
+func sameLanguageFamily(a, b string) bool {
+	families := map[string]string{"typescript": "javascript", "cpp": "c"}
+	if family, ok := families[a]; ok {
+		a = family
+	}
+	if family, ok := families[b]; ok {
+		b = family
+	}
+	return a == b
+}
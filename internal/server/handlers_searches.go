@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/session"
+)
+
+// Saved search handlers and the per-session search history
+
+// searchHistoryDefaultLimit is the number of past searches
+// list_saved_searches returns unless asked otherwise
+const searchHistoryDefaultLimit = 20
+
+// savedSearchRunArguments are the run_saved_search arguments passed on to
+// search_code in place of the saved ones
+var savedSearchRunArguments = []string{"cursor", "page_size", "max_results", "follow_ups"}
+
+// searchArguments returns the arguments of a search request worth keeping
+// in the history, leaving out the session they were sent in
+func (s *MCPServer) searchArguments(request mcp.CallToolRequest) map[string]interface{} {
+	arguments := maps.Clone(s.getArguments(request))
+	delete(arguments, "session_id")
+	return arguments
+}
+
+// handleSaveSearch saves search_code arguments under a name, or deletes a
+// saved search
+func (s *MCPServer) handleSaveSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling save search", zap.String("tool", request.Params.Name))
+
+	sess := s.sessionForRequest(request)
+
+	name, err := request.RequireString("name")
+	if err != nil || name == "" {
+		return mcp.NewToolResultError("Invalid name parameter: a saved search needs a name"), nil
+	}
+
+	result := map[string]interface{}{
+		"success":    true,
+		"name":       name,
+		"session_id": sess.ID,
+	}
+
+	if s.getBooleanValue(request, "delete", false) {
+		deleted := sess.DeleteSavedSearch(name)
+		result["deleted"] = deleted
+		result["message"] = fmt.Sprintf("Saved search %q deleted", name)
+		if !deleted {
+			result["message"] = fmt.Sprintf("No search was saved as %q", name)
+		}
+	} else {
+		arguments, ok := s.getArguments(request)["arguments"].(map[string]interface{})
+		if !ok {
+			history := sess.SearchHistory(1)
+			if len(history) == 0 {
+				return mcp.NewToolResultError("Invalid arguments parameter: pass the search_code arguments to save, or run search_code first to save the last search"), nil
+			}
+			arguments = history[0].Arguments
+		}
+		arguments = maps.Clone(arguments)
+		if query, _ := arguments["query"].(string); query == "" {
+			return mcp.NewToolResultError("Invalid arguments parameter: a saved search needs a query"), nil
+		}
+		delete(arguments, "session_id")
+		delete(arguments, "cursor")
+
+		saved, replaced := sess.SaveSearch(name, request.GetString("description", ""), arguments)
+		result["saved_search"] = saved
+		result["replaced"] = replaced
+		result["message"] = fmt.Sprintf("Search saved as %q; run it with run_saved_search", name)
+	}
+
+	result["saved_searches"] = len(sess.SavedSearches())
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// handleListSavedSearches lists the saved searches of a session and its
+// most recent searches
+func (s *MCPServer) handleListSavedSearches(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling list saved searches", zap.String("tool", request.Params.Name))
+
+	sess := s.sessionForRequest(request)
+
+	limit := int(request.GetFloat("history_limit", searchHistoryDefaultLimit))
+	if limit < 0 {
+		return mcp.NewToolResultError("Invalid history_limit parameter: must be zero or more"), nil
+	}
+
+	saved := sess.SavedSearches()
+	result := map[string]interface{}{
+		"session_id":     sess.ID,
+		"saved_searches": saved,
+		"count":          len(saved),
+	}
+	if limit > 0 {
+		history := sess.SearchHistory(limit)
+		result["history"] = history
+		result["history_count"] = len(history)
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// handleRunSavedSearch runs a saved search through search_code, with the
+// paging arguments of the request in place of the saved ones
+func (s *MCPServer) handleRunSavedSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling run saved search", zap.String("tool", request.Params.Name))
+
+	sess := s.sessionForRequest(request)
+
+	name, err := request.RequireString("name")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid name parameter: %v", err)), nil
+	}
+
+	saved, ok := sess.RunSavedSearch(name)
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("No search is saved as %q; list_saved_searches lists the saved searches", name)), nil
+	}
+
+	arguments := saved.Arguments
+	requestArguments := s.getArguments(request)
+	for _, key := range savedSearchRunArguments {
+		if value, ok := requestArguments[key]; ok {
+			arguments[key] = value
+		}
+	}
+
+	sess.RecordSearch(session.SearchRecord{
+		Tool:        "search_code",
+		Arguments:   arguments,
+		SavedSearch: name,
+		At:          time.Now(),
+	})
+
+	searchRequest := mcp.CallToolRequest{}
+	searchRequest.Params.Name = "search_code"
+	searchRequest.Params.Arguments = arguments
+	arguments["session_id"] = sess.ID
+
+	s.log(ctx).Debug("Running saved search",
+		zap.String("session_id", sess.ID),
+		zap.String("name", name),
+		zap.Int("runs", saved.Runs))
+
+	return s.searchCode(ctx, searchRequest)
+}
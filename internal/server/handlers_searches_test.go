@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/session"
+)
+
+func TestSaveAndRunSearch(t *testing.T) {
+	repoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoDir, "main.go"), []byte("package main\n\nfunc ParseConfig() {}\n\nfunc ParseFlags() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{repoDir}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": repoDir, "name": "saved"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	if text, isError := callTool(t, s, "save_search", map[string]interface{}{"name": "parsers"}); !isError {
+		t.Fatalf("Expected saving without a search to run first to fail, got %s", text)
+	}
+	if text, isError := callTool(t, s, "search_code", map[string]interface{}{"query": "name:Parse*", "type": "function", "follow_ups": false}); isError {
+		t.Fatalf("Search failed: %s", text)
+	}
+	if text, isError := callTool(t, s, "save_search", map[string]interface{}{"name": "parsers", "description": "Parse functions"}); isError {
+		t.Fatalf("Failed to save the last search: %s", text)
+	}
+
+	text, isError := callTool(t, s, "run_saved_search", map[string]interface{}{"name": "parsers", "page_size": 1})
+	if isError {
+		t.Fatalf("Failed to run the saved search: %s", text)
+	}
+	var page struct {
+		Count   int  `json:"count"`
+		HasMore bool `json:"has_more"`
+	}
+	if err := json.Unmarshal([]byte(text), &page); err != nil || page.Count != 1 || !page.HasMore {
+		t.Errorf("Expected a first page of one of the two functions, got %s", text)
+	}
+	if _, isError := callTool(t, s, "run_saved_search", map[string]interface{}{"name": "missing"}); !isError {
+		t.Error("Expected running an unknown search to fail")
+	}
+
+	text, isError = callTool(t, s, "list_saved_searches", map[string]interface{}{})
+	if isError {
+		t.Fatalf("Failed to list saved searches: %s", text)
+	}
+	var listed struct {
+		SavedSearches []session.SavedSearch  `json:"saved_searches"`
+		History       []session.SearchRecord `json:"history"`
+	}
+	if err := json.Unmarshal([]byte(text), &listed); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+	if len(listed.SavedSearches) != 1 || listed.SavedSearches[0].Runs != 1 || listed.SavedSearches[0].Arguments["query"] != "name:Parse*" {
+		t.Errorf("Expected the saved search run once, got %+v", listed.SavedSearches)
+	}
+	if len(listed.History) != 2 || listed.History[0].SavedSearch != "parsers" || listed.History[1].Arguments["query"] != "name:Parse*" {
+		t.Errorf("Expected the saved search run after the original search, got %+v", listed.History)
+	}
+}
@@ -0,0 +1,338 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/quality"
+	"github.com/my-mcp/code-indexer/internal/search"
+	"github.com/my-mcp/code-indexer/internal/secrets"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// listSecurityFindingsMaxResults caps the findings list_security_findings
+// returns per page
+const listSecurityFindingsMaxResults = 200
+
+// findingFilter selects security findings by least severity, rule and path
+type findingFilter struct {
+	severity string
+	rules    map[string]bool
+	path     string // File or directory, slash-separated; empty for all
+}
+
+// getFindingFilter reads the severity_threshold, rules and file_path
+// arguments of the security tools
+func (s *MCPServer) getFindingFilter(request mcp.CallToolRequest, defaultSeverity string) (findingFilter, error) {
+	filter := findingFilter{
+		severity: request.GetString("severity_threshold", defaultSeverity),
+		rules:    make(map[string]bool),
+		path:     strings.Trim(pathutil.ToSlash(request.GetString("file_path", "")), "/"),
+	}
+	if quality.SeverityRank(filter.severity) < 0 {
+		return filter, fmt.Errorf("invalid severity_threshold %q: use %s", filter.severity, strings.Join(quality.Severities, ", "))
+	}
+
+	known := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, rule := range secrets.Rules() {
+		known[rule.ID] = true
+		ids = append(ids, rule.ID)
+	}
+	for _, rule := range s.getStringList(request, "rules") {
+		if !known[rule] {
+			return filter, fmt.Errorf("unknown rule %q: use %s", rule, strings.Join(ids, ", "))
+		}
+		filter.rules[rule] = true
+	}
+	return filter, nil
+}
+
+// matches reports whether a finding passes the filter
+func (f findingFilter) matches(finding types.SecurityFinding) bool {
+	if quality.SeverityRank(finding.Severity) < quality.SeverityRank(f.severity) {
+		return false
+	}
+	if len(f.rules) > 0 && !f.rules[finding.Rule] {
+		return false
+	}
+	return f.path == "" || finding.FilePath == f.path || strings.HasPrefix(finding.FilePath, f.path+"/")
+}
+
+// sortFindings orders findings from the most severe, then by file and line
+func sortFindings(findings []types.SecurityFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if rankA, rankB := quality.SeverityRank(a.Severity), quality.SeverityRank(b.Severity); rankA != rankB {
+			return rankA > rankB
+		}
+		if a.Repository != b.Repository {
+			return a.Repository < b.Repository
+		}
+		if a.FilePath != b.FilePath {
+			return a.FilePath < b.FilePath
+		}
+		return a.Line < b.Line
+	})
+}
+
+// summarizeFindings counts findings by severity and rule
+func summarizeFindings(findings []types.SecurityFinding) map[string]interface{} {
+	bySeverity := make(map[string]int)
+	byRule := make(map[string]int)
+	for _, finding := range findings {
+		bySeverity[finding.Severity]++
+		byRule[finding.Rule]++
+	}
+	return map[string]interface{}{
+		"total":       len(findings),
+		"by_severity": bySeverity,
+		"by_rule":     byRule,
+	}
+}
+
+// indexedFindings returns the security findings recorded for a repository,
+// or for every indexed repository when repository is empty
+func (s *MCPServer) indexedFindings(ctx context.Context, repository string) ([]types.SecurityFinding, []string, error) {
+	var repos []types.Repository
+	if repository != "" {
+		repo, ok := s.indexer.IndexedRepository(repository)
+		if !ok {
+			return nil, nil, fmt.Errorf("repository '%s' not found", repository)
+		}
+		repos = append(repos, *repo)
+	} else {
+		var err error
+		if repos, err = s.indexer.ListRepositories(ctx); err != nil {
+			return nil, nil, fmt.Errorf("failed to list repositories: %w", err)
+		}
+	}
+
+	var findings []types.SecurityFinding
+	names := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		found, err := s.searcher.SecurityFindings(ctx, repo.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read security findings of %s: %w", repo.Name, err)
+		}
+		for i := range found {
+			found[i].Repository = repo.Name
+		}
+		findings = append(findings, found...)
+		names = append(names, repo.Name)
+	}
+	return findings, names, nil
+}
+
+// scanningWarnings explains why an index may hold no security findings
+func (s *MCPServer) scanningWarnings() []string {
+	if !s.config.Secrets.Enabled {
+		return []string{"Secret scanning is disabled (secrets.enabled); files indexed from now on are not scanned"}
+	}
+	return []string{"Repositories indexed before secret scanning existed have no findings; re-index them with index_repository"}
+}
+
+// handleListSecurityFindings lists the hardcoded secrets found while
+// repositories were indexed
+func (s *MCPServer) handleListSecurityFindings(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling list security findings", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	filter, err := s.getFindingFilter(request, "low")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+	offset, pageSize, err := s.getPage(request, listSecurityFindingsMaxResults, listSecurityFindingsMaxResults)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid cursor parameter: %v", err)), nil
+	}
+
+	all, repositories, err := s.indexedFindings(ctx, repository)
+	if err != nil {
+		s.log(ctx).Error("Failed to read security findings", zap.Error(err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	findings := []types.SecurityFinding{}
+	for _, finding := range all {
+		if filter.matches(finding) {
+			findings = append(findings, finding)
+		}
+	}
+	sortFindings(findings)
+
+	result := map[string]interface{}{
+		"success":            true,
+		"repositories":       repositories,
+		"severity_threshold": filter.severity,
+		"summary":            summarizeFindings(findings),
+	}
+	total := len(findings)
+	if offset > total {
+		offset = total
+	}
+	end := offset + pageSize
+	if end > total {
+		end = total
+	}
+	nextCursor := ""
+	if end < total {
+		nextCursor = search.EncodeCursor(end)
+	}
+	result["findings"] = findings[offset:end]
+	addPage(result, pageSize, total, nextCursor)
+	if len(all) == 0 {
+		result["warnings"] = s.scanningWarnings()
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// handleDetectSecurityIssues scans a file for hardcoded secrets, reading the
+// session's unsaved buffer when there is one, or summarizes the findings
+// recorded for a repository by severity, rule and file
+func (s *MCPServer) handleDetectSecurityIssues(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling detect security issues", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	filePath := request.GetString("file_path", "")
+	if repository == "" && filePath == "" {
+		return mcp.NewToolResultError("Either repository or file_path is required"), nil
+	}
+	filter, err := s.getFindingFilter(request, "medium")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+	format, err := getReportFormat(request)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid parameters: %v", err)), nil
+	}
+
+	if filePath != "" {
+		return s.detectFileSecurityIssues(ctx, request, repository, filePath, filter, format)
+	}
+
+	all, _, err := s.indexedFindings(ctx, repository)
+	if err != nil {
+		s.log(ctx).Error("Failed to read security findings", zap.Error(err))
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	findings := []types.SecurityFinding{}
+	byFile := make(map[string]int)
+	for _, finding := range all {
+		if filter.matches(finding) {
+			findings = append(findings, finding)
+			byFile[finding.FilePath]++
+		}
+	}
+	sortFindings(findings)
+	if format == "sarif" {
+		run := s.newSARIFRun()
+		addSecurityResults(run, findings)
+		return sarifResult(run)
+	}
+
+	// Files with the most findings first
+	type fileFindings struct {
+		FilePath string `json:"file_path"`
+		Findings int    `json:"findings"`
+	}
+	files := make([]fileFindings, 0, len(byFile))
+	for path, count := range byFile {
+		files = append(files, fileFindings{FilePath: path, Findings: count})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Findings != files[j].Findings {
+			return files[i].Findings > files[j].Findings
+		}
+		return files[i].FilePath < files[j].FilePath
+	})
+
+	summary := summarizeFindings(findings)
+	summary["files"] = len(files)
+	result := map[string]interface{}{
+		"success":            true,
+		"repository":         repository,
+		"severity_threshold": filter.severity,
+		"summary":            summary,
+		"files":              files,
+	}
+	if len(findings) > listSecurityFindingsMaxResults {
+		findings = findings[:listSecurityFindingsMaxResults]
+		result["truncated"] = true
+	}
+	result["findings"] = findings
+	if len(all) == 0 {
+		result["warnings"] = s.scanningWarnings()
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// detectFileSecurityIssues scans one file for hardcoded secrets
+func (s *MCPServer) detectFileSecurityIssues(ctx context.Context, request mcp.CallToolRequest, repository, filePath string, filter findingFilter, format string) (*mcp.CallToolResult, error) {
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	contentBytes, source, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for security issues", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+
+	// The path filter is the scanned file itself
+	filter.path = ""
+	relPath := filepath.ToSlash(filePath)
+	scanner := secrets.NewScanner(s.config.Secrets)
+	findings := []types.SecurityFinding{}
+	for _, finding := range scanner.Scan(relPath, string(contentBytes)) {
+		if filter.matches(finding) {
+			finding.Repository = repository
+			finding.Language = s.repoMgr.GetFileLanguage(filePath)
+			findings = append(findings, finding)
+		}
+	}
+	sortFindings(findings)
+	if format == "sarif" {
+		run := s.newSARIFRun()
+		addSecurityResults(run, findings)
+		return sarifResult(run)
+	}
+
+	result := map[string]interface{}{
+		"success":            true,
+		"file_path":          filePath,
+		"source":             source,
+		"severity_threshold": filter.severity,
+		"findings":           findings,
+		"summary":            summarizeFindings(findings),
+	}
+	if scanner.Ignored(relPath) {
+		result["warnings"] = []string{"The file matches secrets.ignore_patterns and is not scanned when indexed"}
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
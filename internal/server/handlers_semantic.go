@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/embeddings"
+	"github.com/my-mcp/code-indexer/internal/search"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// defaultSemanticMaxResults is the number of chunks semantic_search returns
+// unless asked otherwise
+const defaultSemanticMaxResults = 20
+
+// errEmbeddingsDisabled is returned by tools that need embeddings when they
+// are turned off
+const errEmbeddingsDisabled = "Embeddings are disabled; set embeddings.enabled to true and re-index the repositories"
+
+// handleSemanticSearch handles embedding similarity search requests
+func (s *MCPServer) handleSemanticSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling semantic search", zap.String("tool", request.Params.Name))
+
+	query, err := request.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid query parameter: %v", err)), nil
+	}
+	if s.embeddings == nil {
+		return mcp.NewToolResultError(errEmbeddingsDisabled), nil
+	}
+
+	maxResults := int(request.GetFloat("max_results", defaultSemanticMaxResults))
+	minScore := request.GetFloat("min_score", 0)
+	includeFollowUps := s.getBooleanValue(request, "follow_ups", true)
+
+	searchQuery := types.SearchQuery{
+		Query:        query,
+		Language:     request.GetString("language", ""),
+		Languages:    s.getStringList(request, "languages"),
+		Repository:   request.GetString("repository", ""),
+		Repositories: s.getStringList(request, "repositories"),
+		Project:      request.GetString("project", ""),
+		Projects:     s.getStringList(request, "projects"),
+		FilePath:     pathutil.ToSlash(request.GetString("file_path", "")),
+		MaxResults:   maxResults,
+	}
+	if _, err := s.scopeToWorkspace(ctx, request, &searchQuery); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid workspace parameter: %v", err)), nil
+	}
+
+	results, err := s.embeddings.Search(ctx, searchQuery)
+	if err != nil {
+		s.log(ctx).Error("Failed to run semantic search", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Semantic search failed: %v", err)), nil
+	}
+
+	filtered := results[:0]
+	for _, result := range results {
+		if result.Score >= minScore {
+			filtered = append(filtered, result)
+		}
+	}
+	results = filtered
+
+	if includeFollowUps {
+		s.annotateFollowUps(ctx, results)
+	}
+
+	result := map[string]interface{}{
+		"query":   query,
+		"model":   s.embeddings.Model(),
+		"results": results,
+		"count":   len(results),
+	}
+
+	resultJSON, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// hybridSearch fuses keyword results with the chunks most similar to the
+// query, using the configured weight for the semantic score. Chunks are
+// looked up regardless of the type filter so they can boost overlapping
+// function and class matches; only the fused list is filtered by type.
+func (s *MCPServer) hybridSearch(ctx context.Context, searchQuery types.SearchQuery, keyword []types.SearchResult) ([]types.SearchResult, error) {
+	semanticQuery := searchQuery
+	semanticQuery.Type = ""
+	semanticQuery.Types = nil
+	if searchQuery.Syntax {
+		semanticQuery.Query = search.SyntaxText(searchQuery.Query)
+	}
+
+	semantic, err := s.embeddings.Search(ctx, semanticQuery)
+	if err != nil {
+		return nil, err
+	}
+
+	fused := embeddings.Fuse(keyword, semantic, s.config.Embeddings.HybridWeight, 0)
+	results := make([]types.SearchResult, 0, len(fused))
+	for _, result := range fused {
+		if !searchQuery.AcceptsType(result.Type) {
+			continue
+		}
+		results = append(results, result)
+		if searchQuery.MaxResults > 0 && len(results) == searchQuery.MaxResults {
+			break
+		}
+	}
+	return results, nil
+}
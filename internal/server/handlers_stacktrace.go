@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/stacktrace"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// frameLocation is where a stack frame's file lives in an indexed repository
+type frameLocation struct {
+	repository   string
+	relativePath string
+	fullPath     string
+}
+
+// handleResolveStacktrace handles stack trace resolution requests. Each frame
+// is mapped to a file in an indexed repository and the symbol enclosing its
+// line, and the innermost frames in project code get source snippets.
+func (s *MCPServer) handleResolveStacktrace(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling resolve stacktrace", zap.String("tool", request.Params.Name))
+
+	traceText, err := request.RequireString("trace")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid trace parameter: %v", err)), nil
+	}
+
+	repository := request.GetString("repository", "")
+	maxSnippets := int(request.GetFloat("max_snippets", 3))
+	contextLines := int(request.GetFloat("context_lines", 3))
+	maxFrames := int(request.GetFloat("max_frames", 50))
+	if contextLines < 0 {
+		contextLines = 0
+	}
+
+	trace, ok := stacktrace.Parse(traceText)
+	if !ok {
+		return mcp.NewToolResultError("No stack frames recognised; expected a Go panic, Python traceback or JavaScript stack"), nil
+	}
+
+	repositories, err := s.searcher.ListRepositories(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list repositories: %v", err)), nil
+	}
+	if repository != "" {
+		var scoped []types.Repository
+		for _, repo := range repositories {
+			if repo.Name == repository {
+				scoped = append(scoped, repo)
+			}
+		}
+		if len(scoped) == 0 {
+			return repoNotFound(repository), nil
+		}
+		repositories = scoped
+	}
+
+	repoPaths := make(map[string]string, len(repositories))
+	for _, repo := range repositories {
+		repoPaths[repo.Name] = repo.Path
+	}
+
+	frames := trace.Frames
+	truncated := false
+	if maxFrames > 0 && len(frames) > maxFrames {
+		frames = frames[:maxFrames]
+		truncated = true
+	}
+
+	metadata := make(map[string]*types.CodeFile)
+	annotated := make([]map[string]interface{}, 0, len(frames))
+	resolvedCount, snippetCount := 0, 0
+	originFrame := -1
+
+	for idx, frame := range frames {
+		entry := map[string]interface{}{
+			"index":    idx,
+			"function": frame.Function,
+			"file":     frame.File,
+			"line":     frame.Line,
+			"library":  frame.Library,
+			"resolved": false,
+		}
+		if frame.Column > 0 {
+			entry["column"] = frame.Column
+		}
+		if frame.Source != "" {
+			entry["source"] = frame.Source
+		}
+
+		location, found := resolveFrameLocation(frame.File, repositories)
+		if !found {
+			annotated = append(annotated, entry)
+			continue
+		}
+
+		resolvedCount++
+		entry["resolved"] = true
+		entry["repository"] = location.repository
+		entry["file_path"] = location.relativePath
+		entry["full_path"] = location.fullPath
+		entry["language"] = s.repoMgr.GetFileLanguage(location.fullPath)
+
+		key := location.repository + ":" + location.relativePath
+		file, cached := metadata[key]
+		if !cached {
+			file, err = s.searcher.GetFileMetadata(ctx, location.relativePath, location.repository)
+			if err != nil {
+				s.log(ctx).Debug("No indexed metadata for stack frame", zap.String("file", location.relativePath), zap.Error(err))
+				file = nil
+			}
+			metadata[key] = file
+		}
+
+		symbol := enclosingSymbol(file, frame.Line)
+		if symbol != nil {
+			entry["symbol"] = symbol
+		}
+
+		if !frame.Library && originFrame < 0 {
+			originFrame = idx
+			result := types.SearchResult{
+				FilePath:   location.relativePath,
+				Repository: location.repository,
+				StartLine:  frame.Line,
+				EndLine:    frame.Line,
+			}
+			if symbol != nil {
+				result.Name, _ = symbol["name"].(string)
+				result.Type, _ = symbol["type"].(string)
+			}
+			entry["follow_ups"] = followUpsFor(result, repoPaths)
+		}
+
+		if !frame.Library && snippetCount < maxSnippets {
+			snippet, err := s.frameSnippet(request, location.fullPath, frame.Line, contextLines)
+			if err != nil {
+				entry["snippet_error"] = err.Error()
+			} else {
+				entry["snippet"] = snippet
+				snippetCount++
+			}
+		}
+
+		annotated = append(annotated, entry)
+	}
+
+	result := map[string]interface{}{
+		"success":         true,
+		"language":        trace.Language,
+		"message":         trace.Message,
+		"frame_count":     len(trace.Frames),
+		"resolved_frames": resolvedCount,
+		"frames":          annotated,
+	}
+	if originFrame >= 0 {
+		result["origin_frame"] = originFrame
+	}
+	if truncated {
+		result["truncated"] = true
+	}
+
+	s.log(ctx).Info("Stack trace resolved",
+		zap.String("language", trace.Language),
+		zap.Int("frames", len(trace.Frames)),
+		zap.Int("resolved", resolvedCount))
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// frameSnippet returns the lines around a frame's line, preferring an
+// unsaved editor buffer
+func (s *MCPServer) frameSnippet(request mcp.CallToolRequest, fullPath string, line, contextLines int) (map[string]interface{}, error) {
+	contentBytes, source, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	text := textpos.Split(string(contentBytes))
+	if _, ok := text.Line(line); !ok {
+		return nil, &textpos.RangeError{Start: line, End: line, Lines: text.LineCount()}
+	}
+
+	start := line - contextLines
+	if start < 1 {
+		start = 1
+	}
+	end := line + contextLines
+	if end > text.LineCount() {
+		end = text.LineCount()
+	}
+	lines, err := text.Lines(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"start_line": start,
+		"end_line":   end,
+		"code":       strings.Join(lines, text.Ending()),
+		"source":     source,
+	}, nil
+}
+
+// resolveFrameLocation finds the indexed file a frame refers to. Traces are
+// often captured on another machine or inside a container, so besides paths
+// inside a repository the longest suffix of the path that exists in a
+// repository is accepted.
+func resolveFrameLocation(file string, repositories []types.Repository) (frameLocation, bool) {
+	if parsed, err := url.Parse(file); err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") {
+		file = parsed.Path
+	}
+	file = filepath.FromSlash(strings.ReplaceAll(file, "\\", "/"))
+
+	if filepath.IsAbs(file) {
+		for _, repo := range repositories {
+			if rel, err := filepath.Rel(repo.Path, file); err == nil && !strings.HasPrefix(rel, "..") && isRegularFile(file) {
+				return frameLocation{repository: repo.Name, relativePath: filepath.ToSlash(rel), fullPath: file}, true
+			}
+		}
+	}
+
+	segments := strings.FieldsFunc(filepath.ToSlash(file), func(r rune) bool { return r == '/' })
+	for i := range segments {
+		candidate := filepath.Join(segments[i:]...)
+		for _, repo := range repositories {
+			fullPath := filepath.Join(repo.Path, candidate)
+			if isRegularFile(fullPath) {
+				return frameLocation{repository: repo.Name, relativePath: filepath.ToSlash(candidate), fullPath: fullPath}, true
+			}
+		}
+	}
+	return frameLocation{}, false
+}
+
+// isRegularFile reports whether path exists and is not a directory
+func isRegularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// enclosingSymbol returns the innermost function or class of an indexed file
+// that contains line
+func enclosingSymbol(file *types.CodeFile, line int) map[string]interface{} {
+	if file == nil {
+		return nil
+	}
+
+	var best map[string]interface{}
+	bestSpan := -1
+	consider := func(name, symbolType, signature string, startLine, endLine int) {
+		if line < startLine || line > endLine {
+			return
+		}
+		if span := endLine - startLine; bestSpan < 0 || span < bestSpan {
+			bestSpan = span
+			best = map[string]interface{}{
+				"name":       name,
+				"type":       symbolType,
+				"start_line": startLine,
+				"end_line":   endLine,
+			}
+			if signature != "" {
+				best["signature"] = signature
+			}
+		}
+	}
+
+	for _, function := range file.Functions {
+		consider(function.Name, "function", function.Signature, function.StartLine, function.EndLine)
+	}
+	for _, class := range file.Classes {
+		consider(class.Name, "class", "", class.StartLine, class.EndLine)
+		for _, method := range class.Methods {
+			consider(method.Name, "function", method.Signature, method.StartLine, method.EndLine)
+		}
+	}
+	return best
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/parser"
+)
+
+// Symbol editing handlers that locate the declaration to edit in the
+// tree-sitter AST instead of taking line numbers
+
+// symbolEdit rewrites a file's content around a located symbol
+type symbolEdit func(content string, symbol parser.SymbolRange, text string) (string, error)
+
+// handleReplaceSymbolBody replaces the body of a function, method or type
+func (s *MCPServer) handleReplaceSymbolBody(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling replace symbol body", zap.String("tool", request.Params.Name))
+	return s.editSymbol(ctx, request, "new_body", "replace the body of", parser.ReplaceSymbolBody)
+}
+
+// handleInsertAfterSymbol inserts content below a declaration
+func (s *MCPServer) handleInsertAfterSymbol(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling insert after symbol", zap.String("tool", request.Params.Name))
+	return s.editSymbol(ctx, request, "content", "insert after", parser.InsertAfterSymbol)
+}
+
+// handleInsertBeforeSymbol inserts content above a declaration and its
+// doc comment
+func (s *MCPServer) handleInsertBeforeSymbol(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling insert before symbol", zap.String("tool", request.Params.Name))
+	return s.editSymbol(ctx, request, "content", "insert before", parser.InsertBeforeSymbol)
+}
+
+// editSymbol locates the symbol a request names and applies edit to it with
+// the text of textParam, describing the edit as action in messages
+func (s *MCPServer) editSymbol(ctx context.Context, request mcp.CallToolRequest, textParam, action string, edit symbolEdit) (*mcp.CallToolResult, error) {
+	symbolName, err := request.RequireString("symbol_name")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid symbol_name parameter: %v", err)), nil
+	}
+	filePath, err := request.RequireString("file_path")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	text, err := request.RequireString(textParam)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid %s parameter: %v", textParam, err)), nil
+	}
+	line := int(request.GetFloat("line", 0))
+	dryRun := s.getBooleanValue(request, "dry_run", false)
+
+	language := s.repoMgr.GetFileLanguage(filePath)
+	symbolParser := parser.NewTreeSitterParser(language)
+	if symbolParser == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Symbol editing is not supported for %s files", filePath)), nil
+	}
+
+	contentBytes, err := s.repoMgr.ReadFile(filePath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for symbol edit", zap.String("path", filePath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+	original := string(contentBytes)
+
+	symbols, err := symbolParser.Symbols(original)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse file: %v", err)), nil
+	}
+	symbol, err := parser.LocateSymbol(symbols, symbolName, line)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to locate symbol in %s: %v", filePath, err)), nil
+	}
+
+	edited, err := edit(original, symbol, text)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to %s %s: %v", action, symbol.QualifiedName(), err)), nil
+	}
+
+	diff, err := s.applyEdit(ctx, request, filePath, contentBytes, edited, dryRun)
+	if err != nil {
+		s.log(ctx).Error("Failed to write file after symbol edit", zap.String("path", filePath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
+	}
+
+	message := fmt.Sprintf("Successfully applied %s to %s %s in %s", request.Params.Name, symbol.Kind, symbol.QualifiedName(), filePath)
+	if dryRun {
+		message = fmt.Sprintf("Dry run: would %s %s %s in %s", action, symbol.Kind, symbol.QualifiedName(), filePath)
+	}
+
+	result := map[string]interface{}{
+		"success":   true,
+		"dry_run":   dryRun,
+		"file_path": filePath,
+		"symbol": map[string]interface{}{
+			"name":       symbol.QualifiedName(),
+			"kind":       symbol.Kind,
+			"start_line": symbol.StartLine,
+			"end_line":   symbol.EndLine,
+		},
+		"diff":    diff,
+		"message": message,
+	}
+
+	if !dryRun {
+		s.log(ctx).Info("Symbol edited successfully",
+			zap.String("file", filePath),
+			zap.String("symbol", symbol.QualifiedName()),
+			zap.String("tool", request.Params.Name))
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
@@ -0,0 +1,565 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/auth"
+	"github.com/my-mcp/code-indexer/internal/coverage"
+	"github.com/my-mcp/code-indexer/internal/models"
+	"github.com/my-mcp/code-indexer/internal/parser"
+	"github.com/my-mcp/code-indexer/internal/testrun"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// testLanguageMarkers are the files whose presence at a repository root
+// tells which language's test runner to use, checked in order
+var testLanguageMarkers = []struct {
+	language string
+	files    []string
+}{
+	{"go", []string{"go.mod"}},
+	{"python", []string{"pytest.ini", "pyproject.toml", "setup.py", "setup.cfg", "tox.ini", "conftest.py"}},
+	{"typescript", []string{"tsconfig.json"}},
+	{"javascript", []string{"package.json"}},
+}
+
+// handleRunTests runs the tests of a repository, a package or directory, or
+// a file, optionally only those matching a name, and returns the result of
+// each test
+func (s *MCPServer) handleRunTests(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling run tests", zap.String("tool", request.Params.Name))
+
+	repository := request.GetString("repository", "")
+	filePath := request.GetString("file_path", "")
+	pkg := request.GetString("package", "")
+	if repository == "" && filePath == "" {
+		return mcp.NewToolResultError("Either repository or file_path is required"), nil
+	}
+	if filePath != "" && pkg != "" {
+		return mcp.NewToolResultError("Give either file_path or package, not both"), nil
+	}
+	language := request.GetString("language", "")
+	onlyFailures := s.getBooleanValue(request, "only_failures", false)
+
+	timeout := time.Duration(s.config.Tests.TimeoutSeconds) * time.Second
+	if seconds := int(request.GetFloat("timeout_seconds", 0)); seconds > 0 && time.Duration(seconds)*time.Second < timeout {
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	target := testrun.Target{Test: request.GetString("test_name", "")}
+	if filePath != "" {
+		fullPath, err := s.repositoryPath(repository, filePath)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+		}
+		repo, ok := s.owningRepository(ctx, repository, fullPath)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("%s is not in an indexed repository", filePath)), nil
+		}
+		if target.Root, err = s.repoMgr.ResolvePath(repo.Path); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+		repository = repo.Name
+		target.Path, _ = filepath.Rel(target.Root, fullPath)
+		target.Dir = info.IsDir()
+		if language == "" && !target.Dir {
+			language = s.repoMgr.GetFileLanguage(fullPath)
+		}
+	} else {
+		repo, ok := s.indexer.IndexedRepository(repository)
+		if !ok {
+			return repoNotFound(repository), nil
+		}
+		root, err := s.repoMgr.ResolvePath(repo.Path)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+		}
+		target.Root = root
+		if pkg != "" {
+			dir := filepath.Clean(filepath.FromSlash(strings.TrimSuffix(pkg, "/...")))
+			if filepath.IsAbs(dir) || strings.HasPrefix(dir, "..") {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid package parameter: %s is not a directory of the repository", pkg)), nil
+			}
+			if info, err := os.Stat(filepath.Join(root, dir)); err != nil || !info.IsDir() {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid package parameter: %s is not a directory of the repository", pkg)), nil
+			}
+			if dir != "." {
+				target.Path = dir
+				target.Dir = true
+			}
+		}
+	}
+	if language == "" {
+		language = detectTestLanguage(target.Root)
+	}
+	if language == "" {
+		return mcp.NewToolResultError("Could not tell the repository's test runner; give the language parameter"), nil
+	}
+	runner, ok := s.config.Tests.Runners[language]
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("No test runner is configured for %s (tests.runners)", language)), nil
+	}
+
+	s.log(ctx).Info("Running tests",
+		zap.String("repository", repository),
+		zap.String("language", language),
+		zap.String("path", target.Path),
+		zap.String("test", target.Test),
+		zap.Duration("timeout", timeout))
+	run, err := testrun.Run(ctx, runner, target, timeout)
+	if errors.Is(err, testrun.ErrNotInstalled) {
+		return mcp.NewToolResultError(fmt.Sprintf("Cannot run %s tests: %v", language, err)), nil
+	}
+	if err != nil {
+		s.log(ctx).Error("Failed to run tests", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to run tests: %v", err)), nil
+	}
+	if onlyFailures {
+		failed := run.Tests[:0]
+		for _, test := range run.Tests {
+			if test.Status == testrun.StatusFail || test.Status == testrun.StatusTimeout {
+				failed = append(failed, test)
+			}
+		}
+		run.Tests = failed
+	}
+
+	result := map[string]interface{}{
+		"success":         true,
+		"repository":      repository,
+		"language":        language,
+		"path":            filepath.ToSlash(target.Path),
+		"test_name":       target.Test,
+		"timeout_seconds": timeout.Seconds(),
+		"result":          run,
+	}
+	switch {
+	case run.TimedOut:
+		result["message"] = fmt.Sprintf("Tests were stopped after %s; %d test(s) had not finished", timeout, run.Summary.TimedOut)
+	case run.Passed:
+		result["message"] = fmt.Sprintf("%d test(s) passed, %d skipped", run.Summary.Passed, run.Summary.Skipped)
+	default:
+		result["message"] = fmt.Sprintf("%d test(s) failed, %d passed", run.Summary.Failed+len(run.Failures), run.Summary.Passed)
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// detectTestLanguage returns the language whose test runner a repository
+// uses, judging by the build files at its root, or "" when none is found
+func detectTestLanguage(root string) string {
+	for _, marker := range testLanguageMarkers {
+		for _, file := range marker.files {
+			if _, err := os.Stat(filepath.Join(root, file)); err == nil {
+				return marker.language
+			}
+		}
+	}
+	return ""
+}
+
+const (
+	// maxTestTargets bounds the symbols generate_tests writes tests for in
+	// one call
+	maxTestTargets = 10
+
+	// exampleTestLines bounds the lines of an example test file given to
+	// the model, and exampleTestScan the files walked to find one
+	exampleTestLines = 150
+	exampleTestScan  = 5000
+)
+
+// testManifests are the build files at a repository root whose
+// dependencies tell which test framework it uses
+var testManifests = []string{"go.mod", "package.json", "pyproject.toml", "setup.cfg", "requirements-dev.txt", "pom.xml", "build.gradle"}
+
+// packageClause matches the package clause of Go and Java files
+var packageClause = regexp.MustCompile(`(?m)^package\s+([\w.]+)`)
+
+// handleGenerateTests writes unit tests for a symbol of a source file, or
+// for the exported symbols its tests never mention, from their code, doc
+// strings and dependencies, in the framework and conventions of the
+// repository's tests. The tests are added to the file's test file, which
+// is written when write is set.
+func (s *MCPServer) handleGenerateTests(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling generate tests", zap.String("tool", request.Params.Name))
+
+	filePath, err := request.RequireString("file_path")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	repository := request.GetString("repository", "")
+	symbolName := request.GetString("symbol_name", "")
+	testDirectory := filepath.ToSlash(request.GetString("test_directory", ""))
+	framework := request.GetString("framework", "")
+	write := s.getBooleanValue(request, "write", false)
+	dryRun := s.getBooleanValue(request, "dry_run", false)
+	budget := int(request.GetFloat("context_tokens", defaultGroundingTokens))
+	if budget <= 0 || budget > maxContextTokens {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid context_tokens parameter: must be 1 to %d, got %d", maxContextTokens, budget)), nil
+	}
+	if write && !dryRun {
+		if s.config.Server.ReadOnly {
+			return mcp.NewToolResultError("The server is read-only; generate the tests without write, or with dry_run"), nil
+		}
+		// Writing makes generate_tests a write tool
+		if profile := s.deniedBy(ctx, request, request.Params.Name, auth.GroupWrite); profile != nil {
+			return toolError(types.ErrorPermissionDenied,
+				fmt.Sprintf("Writing tests modifies files, which permission profile %q does not allow; generate the tests without write, or with dry_run", profile.Name),
+				map[string]interface{}{"tool": request.Params.Name, "group": auth.GroupWrite, "profile": profile.Name}), nil
+		}
+	}
+
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if fullPath, err = s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	repo, ok := s.owningRepository(ctx, repository, fullPath)
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("%s is not in an indexed repository", filePath)), nil
+	}
+	root, err := s.repoMgr.ResolvePath(repo.Path)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid repository path: %v", err)), nil
+	}
+	relPath, err := filepath.Rel(root, fullPath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path parameter: %v", err)), nil
+	}
+	relPath = filepath.ToSlash(relPath)
+	if coverage.IsTestFile(relPath) {
+		return mcp.NewToolResultError(fmt.Sprintf("%s is a test file; pass the source file it tests", filePath)), nil
+	}
+
+	contentBytes, _, err := s.readFileContent(request, fullPath)
+	if err != nil {
+		s.log(ctx).Error("Failed to read file for test generation", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
+	content := string(contentBytes)
+	language := s.repoMgr.GetFileLanguage(fullPath)
+	if frameworks := coverage.Frameworks[language]; framework != "" && len(frameworks) > 0 && !slices.Contains(frameworks, framework) {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid framework parameter: %s tests use one of %s", language, strings.Join(frameworks, ", "))), nil
+	}
+	candidates := coverage.Candidates(relPath, testDirectory)
+	if len(candidates) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("No test file conventions are known for %s files", language)), nil
+	}
+	parsed, err := parser.NewRegistry().ParseFile(content, fullPath, language)
+	if err != nil {
+		s.log(ctx).Error("Failed to parse file for test generation", zap.String("path", fullPath), zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse file: %v", err)), nil
+	}
+	parsed.Language = language
+
+	var warnings []string
+	testFiles := s.relatedTestFiles(ctx, repo.ID, root, relPath, testDirectory, &warnings)
+	testContents := make(map[string]string, len(testFiles))
+	for _, testFile := range testFiles {
+		testContent, err := s.repoMgr.ReadFile(filepath.Join(root, filepath.FromSlash(testFile.FilePath)))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("Failed to read %s: %v", testFile.FilePath, err))
+			continue
+		}
+		testContents[testFile.FilePath] = string(testContent)
+	}
+
+	// The named symbol, or the exported symbols no test mentions
+	var selected []coverage.Symbol
+	symbols := coverage.Exported(parsed, content, symbolName != "")
+	for _, symbol := range symbols {
+		if symbolName != "" {
+			if symbol.Name == symbolName || symbol.QualifiedName() == symbolName {
+				selected = append(selected, symbol)
+			}
+			continue
+		}
+		tested := false
+		for _, testContent := range testContents {
+			if len(coverage.References(testContent, symbol.Name)) > 0 {
+				tested = true
+				break
+			}
+		}
+		if !tested {
+			selected = append(selected, symbol)
+		}
+	}
+	switch {
+	case symbolName != "" && len(selected) == 0:
+		return mcp.NewToolResultError(fmt.Sprintf("%s has no function, method or class named %s", relPath, symbolName)), nil
+	case len(symbols) == 0:
+		return mcp.NewToolResultError(fmt.Sprintf("%s has no exported functions, methods or classes; name one with symbol_name", relPath)), nil
+	case len(selected) == 0:
+		return mcp.NewToolResultError(fmt.Sprintf("The tests of %s mention all its exported symbols; name one with symbol_name", relPath)), nil
+	case len(selected) > maxTestTargets:
+		warnings = append(warnings, fmt.Sprintf("Tests were generated for the first %d of %d untested symbols", maxTestTargets, len(selected)))
+		selected = selected[:maxTestTargets]
+	}
+
+	// The test file named after the source file when there is one, and the
+	// closest other test file as an example of the repository's tests
+	testPath, examplePath := "", ""
+	for _, testFile := range testFiles {
+		if _, ok := testContents[testFile.FilePath]; !ok {
+			continue
+		}
+		if testFile.Reason == "naming" && testPath == "" {
+			testPath = testFile.FilePath
+		} else if examplePath == "" {
+			examplePath = testFile.FilePath
+		}
+	}
+	example := testContents[examplePath]
+	if testPath == "" && examplePath == "" {
+		if examplePath = exampleTestFile(root, relPath); examplePath != "" {
+			if data, err := s.repoMgr.ReadFile(filepath.Join(root, filepath.FromSlash(examplePath))); err == nil {
+				example = string(data)
+			}
+		}
+	}
+	if lines := strings.SplitAfter(example, "\n"); len(lines) > exampleTestLines {
+		example = strings.Join(lines[:exampleTestLines], "") + "…\n"
+	}
+	existing, exists := testContents[testPath]
+	if !exists {
+		testPath = testFilePath(candidates, testDirectory, examplePath)
+	}
+
+	if framework == "" {
+		sources := []string{existing, example}
+		for _, manifest := range testManifests {
+			if data, err := os.ReadFile(filepath.Join(root, manifest)); err == nil {
+				sources = append(sources, string(data))
+			}
+		}
+		framework = coverage.DetectFramework(language, sources...)
+	}
+
+	// The code and doc string of each symbol, then what they call and
+	// import for the repository context
+	testRequest := &models.TestRequest{
+		Language:    language,
+		Framework:   framework,
+		SourcePath:  relPath,
+		TestPath:    testPath,
+		Existing:    existing,
+		ExamplePath: examplePath,
+		Example:     example,
+	}
+	if match := packageClause.FindStringSubmatch(content); match != nil && (language == "go" || language == "java") {
+		testRequest.Package = match[1]
+	}
+	var contextCandidates []*contextItem
+	seen := make(map[string]bool)
+	addContext := func(item *contextItem) {
+		key := fmt.Sprintf("%s:%s:%d:%s", item.Kind, item.FilePath, item.StartLine, item.Content)
+		if !seen[key] {
+			seen[key] = true
+			contextCandidates = append(contextCandidates, item)
+		}
+	}
+	for _, symbol := range selected {
+		target := types.SearchResult{
+			Name:       symbol.Name,
+			Type:       symbol.Kind,
+			Repository: repo.Name,
+			FilePath:   relPath,
+			Language:   language,
+			StartLine:  symbol.StartLine,
+			EndLine:    symbol.EndLine,
+		}
+		item, imports := s.symbolContext(ctx, request, target)
+		testRequest.Targets = append(testRequest.Targets, models.TestTarget{
+			Name:      symbol.Name,
+			Kind:      symbol.Kind,
+			ClassName: symbol.ClassName,
+			Code:      item.Content,
+			DocString: item.DocString,
+		})
+		if imports != nil {
+			addContext(imports)
+		}
+		for _, callee := range s.calleeContext(ctx, target) {
+			addContext(callee)
+		}
+	}
+	pack := packContext(contextCandidates, budget, false)
+	testRequest.Context = pack.Text
+
+	generation, err := s.modelsEngine.GenerateTests(ctx, testRequest)
+	if err != nil {
+		s.log(ctx).Error("Failed to generate tests", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to generate tests: %v", err)), nil
+	}
+	generation.Grounding = groundingDocuments(pack)
+	switch {
+	case exists:
+		generation.Grounding = append(generation.Grounding, types.GroundingDocument{
+			Kind: "tests", Repository: repo.Name, FilePath: testPath, Tokens: estimateTokens(existing),
+		})
+	case examplePath != "":
+		generation.Grounding = append(generation.Grounding, types.GroundingDocument{
+			Kind: "tests", Repository: repo.Name, FilePath: examplePath, Tokens: estimateTokens(example),
+		})
+	}
+
+	result := map[string]interface{}{
+		"success":          true,
+		"repository":       repo.Name,
+		"test_file_exists": exists,
+		"generation":       generation,
+	}
+	if write {
+		testFullPath := filepath.Join(root, filepath.FromSlash(testPath))
+
+		// The test file is only held while it is written, not while the
+		// tests are generated, so it may have changed in between
+		if err := s.lockFiles(ctx, []string{testFullPath}, dryRun); err != nil {
+			return lockFailed(err), nil
+		}
+		if current, err := s.repoMgr.ReadFile(testFullPath); exists && !dryRun && (err != nil || string(current) != existing) {
+			return mcp.NewToolResultError(fmt.Sprintf("%s changed since the tests were generated; generate them again", testPath)), nil
+		}
+
+		var diff string
+		if exists {
+			diff, err = s.applyEdit(ctx, request, testFullPath, []byte(existing), generation.Content, dryRun)
+		} else {
+			diff = textpos.UnifiedDiff(filepath.ToSlash(testFullPath), "", generation.Content, editDiffContext)
+			if !dryRun {
+				if err = s.chargeEditBytes(ctx, len(generation.Content)); err == nil {
+					err = s.repoMgr.CreateFile(testFullPath, []byte(generation.Content))
+				}
+				if err == nil {
+					s.recordEdit(ctx, request, testFullPath, "", generation.Content)
+				}
+			}
+		}
+		if err != nil {
+			s.log(ctx).Error("Failed to write tests", zap.String("path", testFullPath), zap.Error(err))
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to write tests: %v", err)), nil
+		}
+		result["diff"] = diff
+		result["dry_run"] = dryRun
+		result["written"] = !dryRun
+	}
+	if len(warnings) > 0 {
+		result["warnings"] = warnings
+	}
+
+	response, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
+
+// testFilePath picks the test file for a source file among its candidate
+// paths: the one in the test directory when one is given, else the one
+// next to the example test file or under the same top directory, else the
+// one the language's conventions put first
+func testFilePath(candidates []string, testDirectory, examplePath string) string {
+	if testDirectory = strings.Trim(testDirectory, "/"); testDirectory != "" {
+		for _, candidate := range candidates {
+			if strings.HasPrefix(candidate, testDirectory+"/") {
+				return candidate
+			}
+		}
+	}
+	if examplePath != "" {
+		for _, candidate := range candidates {
+			if path.Dir(candidate) == path.Dir(examplePath) {
+				return candidate
+			}
+		}
+		top := strings.SplitN(examplePath, "/", 2)[0]
+		for _, candidate := range candidates {
+			if strings.Contains(examplePath, "/") && strings.HasPrefix(candidate, top+"/") {
+				return candidate
+			}
+		}
+	}
+	return candidates[0]
+}
+
+// exampleTestFile returns the test file of the repository with the
+// extension of relPath that is closest to it in the directory tree, or ""
+// when there is none
+func exampleTestFile(root, relPath string) string {
+	ext := path.Ext(relPath)
+	best, bestDistance, scanned := "", -1, 0
+	filepath.WalkDir(root, func(walked string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if name := d.Name(); walked != root && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if scanned++; scanned > exampleTestScan {
+			return filepath.SkipAll
+		}
+		rel, err := filepath.Rel(root, walked)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if path.Ext(rel) != ext || !coverage.IsTestFile(rel) {
+			return nil
+		}
+		if distance := directoryDistance(path.Dir(rel), path.Dir(relPath)); bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = rel, distance
+		}
+		return nil
+	})
+	return best
+}
+
+// directoryDistance counts the steps between two slash-separated
+// directories through their common parent
+func directoryDistance(a, b string) int {
+	split := func(dir string) []string {
+		if dir == "." {
+			return nil
+		}
+		return strings.Split(dir, "/")
+	}
+	as, bs := split(a), split(b)
+	common := 0
+	for common < len(as) && common < len(bs) && as[common] == bs[common] {
+		common++
+	}
+	return len(as) + len(bs) - 2*common
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -14,14 +15,33 @@ import (
 	"github.com/mark3labs/mcp-go/mcp"
 	"go.uber.org/zap"
 
+	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+	"github.com/my-mcp/code-indexer/pkg/textpos"
 	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
 // Utility tool handlers for file operations and symbol finding
 
+// Result limits of the lookup tools, reported by get_capabilities
+const (
+	findFilesMaxResults       = 100
+	findSymbolsMaxResults     = 100
+	findReferencesMaxResults  = 200
+	findDefinitionsMaxResults = 50
+
+	completeSymbolDefaultLimit = 20
+	completeSymbolMaxLimit     = 100
+)
+
+// completeSymbolCandidates is how many matching symbol documents are read
+// per completion returned, since overloads, methods of several types and
+// repeated declarations share a name
+const completeSymbolCandidates = 5
+
 // handleFindFiles handles file finding requests
 func (s *MCPServer) handleFindFiles(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling find files", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling find files", zap.String("tool", request.Params.Name))
 
 	pattern, err := request.RequireString("pattern")
 	if err != nil {
@@ -30,20 +50,31 @@ func (s *MCPServer) handleFindFiles(ctx context.Context, request mcp.CallToolReq
 
 	repository := request.GetString("repository", "")
 	includeContent := s.getBooleanValue(request, "include_content", false)
+	offset, pageSize, err := s.getPage(request, findFilesMaxResults, findFilesMaxResults)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid cursor parameter: %v", err)), nil
+	}
 
 	// Use the search engine to find files matching the pattern
 	searchQuery := types.SearchQuery{
 		Query:      pattern,
 		Type:       "file",
 		Repository: repository,
-		MaxResults: 100,
+		Project:    request.GetString("project", ""),
+		Projects:   s.getStringList(request, "projects"),
+		MaxResults: pageSize,
+		Offset:     offset,
+	}
+	if _, err := s.scopeToWorkspace(ctx, request, &searchQuery); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid workspace parameter: %v", err)), nil
 	}
 
-	searchResults, err := s.searcher.Search(ctx, searchQuery)
+	page, err := s.searcher.SearchPage(ctx, searchQuery)
 	if err != nil {
-		s.logger.Error("Failed to search files", zap.Error(err))
+		s.log(ctx).Error("Failed to search files", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 	}
+	searchResults := page.Results
 
 	files := make([]map[string]interface{}, 0, len(searchResults))
 	for _, result := range searchResults {
@@ -86,6 +117,7 @@ func (s *MCPServer) handleFindFiles(ctx context.Context, request mcp.CallToolReq
 		"files":         files,
 		"total_matches": len(files),
 	}
+	addPage(response, pageSize, page.Total, page.NextCursor)
 
 	content, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
@@ -97,7 +129,7 @@ func (s *MCPServer) handleFindFiles(ctx context.Context, request mcp.CallToolReq
 
 // handleFindSymbols handles symbol finding requests
 func (s *MCPServer) handleFindSymbols(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling find symbols", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling find symbols", zap.String("tool", request.Params.Name))
 
 	symbolName, err := request.RequireString("symbol_name")
 	if err != nil {
@@ -107,22 +139,48 @@ func (s *MCPServer) handleFindSymbols(ctx context.Context, request mcp.CallToolR
 	symbolType := request.GetString("symbol_type", "")
 	language := request.GetString("language", "")
 	repository := request.GetString("repository", "")
+	offset, pageSize, err := s.getPage(request, findSymbolsMaxResults, findSymbolsMaxResults)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid cursor parameter: %v", err)), nil
+	}
+	fuzziness := int(request.GetFloat("fuzziness", 1))
+	if fuzziness < 0 || fuzziness > 2 {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid fuzziness %d: use 0 for exact names, 1 or 2", fuzziness)), nil
+	}
 
 	// Use the search engine to find symbols
 	searchQuery := types.SearchQuery{
-		Query:      symbolName,
-		Type:       symbolType, // If empty, will search all symbol types
-		Language:   language,
-		Repository: repository,
-		MaxResults: 100,
-		Fuzzy:      true, // Enable fuzzy matching for symbol names
-	}
-
-	searchResults, err := s.searcher.Search(ctx, searchQuery)
+		Query:        symbolName,
+		Type:         symbolType, // If empty, will search all symbol types
+		Types:        s.getStringList(request, "symbol_types"),
+		Language:     language,
+		Languages:    s.getStringList(request, "languages"),
+		Repository:   repository,
+		Repositories: s.getStringList(request, "repositories"),
+		Project:      request.GetString("project", ""),
+		Projects:     s.getStringList(request, "projects"),
+		MaxResults:   pageSize,
+		Offset:       offset,
+		Fuzzy:        fuzziness > 0, // Enable fuzzy matching for symbol names
+		Fuzziness:    fuzziness,
+		IncludeDeps:  s.getBooleanValue(request, "include_deps", false),
+
+		// Names and definitions first, most used symbols first among
+		// equally relevant matches
+		Profile: types.ProfileSymbols,
+	}
+	if _, err := s.scopeToWorkspace(ctx, request, &searchQuery); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid workspace parameter: %v", err)), nil
+	}
+	s.RankQuery(&searchQuery)
+
+	page, err := s.searcher.SearchPage(ctx, searchQuery)
 	if err != nil {
-		s.logger.Error("Failed to search symbols", zap.Error(err))
+		s.log(ctx).Error("Failed to search symbols", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 	}
+	searchResults := s.overlayBufferResults(ctx, s.sessionForRequest(request), searchQuery, page.Results)
+	s.annotateFollowUps(ctx, searchResults)
 
 	symbols := make([]map[string]interface{}, 0, len(searchResults))
 	for _, result := range searchResults {
@@ -132,14 +190,16 @@ func (s *MCPServer) handleFindSymbols(ctx context.Context, request mcp.CallToolR
 		}
 
 		symbolInfo := map[string]interface{}{
-			"name":       result.Name,
-			"type":       result.Type,
-			"file_path":  result.FilePath,
-			"repository": result.Repository,
-			"language":   result.Language,
-			"start_line": result.StartLine,
-			"end_line":   result.EndLine,
-			"score":      result.Score,
+			"name":            result.Name,
+			"type":            result.Type,
+			"file_path":       result.FilePath,
+			"repository":      result.Repository,
+			"language":        result.Language,
+			"start_line":      result.StartLine,
+			"end_line":        result.EndLine,
+			"score":           result.Score,
+			"reference_count": result.ReferenceCount,
+			"follow_ups":      result.FollowUps,
 		}
 
 		// Add content/signature if available
@@ -165,9 +225,13 @@ func (s *MCPServer) handleFindSymbols(ctx context.Context, request mcp.CallToolR
 		"symbol_type":   symbolType,
 		"language":      language,
 		"repository":    repository,
+		"symbol_types":  searchQuery.TypeFilter(),
+		"languages":     searchQuery.LanguageFilter(),
+		"repositories":  searchQuery.RepositoryFilter(),
 		"symbols":       symbols,
 		"total_matches": len(symbols),
 	}
+	addPage(response, pageSize, page.Total, page.NextCursor)
 
 	content, err := json.MarshalIndent(response, "", "  ")
 	if err != nil {
@@ -177,9 +241,87 @@ func (s *MCPServer) handleFindSymbols(ctx context.Context, request mcp.CallToolR
 	return mcp.NewToolResultText(string(content)), nil
 }
 
+// handleCompleteSymbol completes a partial symbol name from the names in
+// the index, most relevant and most used first
+func (s *MCPServer) handleCompleteSymbol(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	prefix, err := request.RequireString("prefix")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid prefix parameter: %v", err)), nil
+	}
+	limit := int(request.GetFloat("limit", completeSymbolDefaultLimit))
+	if limit <= 0 || limit > completeSymbolMaxLimit {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid limit %d: use 1 to %d", limit, completeSymbolMaxLimit)), nil
+	}
+
+	searchQuery := types.SearchQuery{
+		Query:        prefix,
+		Type:         request.GetString("symbol_type", ""),
+		Types:        s.getStringList(request, "symbol_types"),
+		Language:     request.GetString("language", ""),
+		Languages:    s.getStringList(request, "languages"),
+		Repository:   request.GetString("repository", ""),
+		Repositories: s.getStringList(request, "repositories"),
+		Project:      request.GetString("project", ""),
+		Projects:     s.getStringList(request, "projects"),
+		MaxResults:   limit * completeSymbolCandidates,
+		Prefix:       true,
+		Profile:      types.ProfileSymbols,
+	}
+	if len(searchQuery.TypeFilter()) == 0 {
+		searchQuery.Types = types.SymbolTypes
+	}
+	if !searchQuery.SymbolsOnly() {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid symbol types %v: use %s", searchQuery.TypeFilter(), strings.Join(types.SymbolTypes, ", "))), nil
+	}
+	if _, err := s.scopeToWorkspace(ctx, request, &searchQuery); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid workspace parameter: %v", err)), nil
+	}
+	s.RankQuery(&searchQuery)
+
+	page, err := s.searcher.SearchPage(ctx, searchQuery)
+	if err != nil {
+		s.log(ctx).Error("Failed to complete symbol", zap.Error(err))
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+
+	// One completion per name and type, at its best ranked declaration
+	completions := make([]map[string]interface{}, 0, limit)
+	seen := make(map[string]bool)
+	for _, result := range page.Results {
+		key := result.Type + ":" + result.Name
+		if result.Name == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		completions = append(completions, map[string]interface{}{
+			"name":       result.Name,
+			"type":       result.Type,
+			"file_path":  result.FilePath,
+			"repository": result.Repository,
+			"language":   result.Language,
+			"start_line": result.StartLine,
+		})
+		if len(completions) == limit {
+			break
+		}
+	}
+
+	response := map[string]interface{}{
+		"prefix":      prefix,
+		"completions": completions,
+		"count":       len(completions),
+	}
+	content, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+
+	return mcp.NewToolResultText(string(content)), nil
+}
+
 // handleGetFileContent handles file content retrieval requests
 func (s *MCPServer) handleGetFileContent(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling get file content", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling get file content", zap.String("tool", request.Params.Name))
 
 	filePath, err := request.RequireString("file_path")
 	if err != nil {
@@ -190,26 +332,20 @@ func (s *MCPServer) handleGetFileContent(ctx context.Context, request mcp.CallTo
 	startLine := int(request.GetFloat("start_line", 0))
 	endLine := int(request.GetFloat("end_line", 0))
 
-	// Try to resolve the full file path
-	var fullPath string
-	if repository != "" {
-		// If repository is specified, look for the file in that repository
-		// For now, we'll search in the repositories directory
-		repoPath := filepath.Join("./repositories", repository)
-		fullPath = filepath.Join(repoPath, filePath)
-	} else {
-		// Try the file path as-is first
-		fullPath = filePath
+	// Resolve the full file path; reading checks it against the sandbox
+	fullPath, err := s.repositoryPath(repository, filePath)
+	if err != nil {
+		return toolErrorResult(err), nil
 	}
 
-	// Read the file content
-	contentBytes, err := s.repoMgr.GetFileContent(fullPath)
+	// Read the file content, preferring an unsaved editor buffer
+	contentBytes, source, err := s.readFileContent(request, fullPath)
 	if err != nil {
 		// If that fails and no repository was specified, try searching for the file
 		if repository == "" {
 			// Search for the file in indexed repositories
 			searchQuery := types.SearchQuery{
-				Query:      filepath.Base(filePath),
+				Query:      filepath.Base(fullPath),
 				Type:       "file",
 				MaxResults: 1,
 			}
@@ -218,38 +354,46 @@ func (s *MCPServer) handleGetFileContent(ctx context.Context, request mcp.CallTo
 			if searchErr == nil && len(searchResults) > 0 {
 				// Try to read from the first match
 				fullPath = searchResults[0].FilePath
-				contentBytes, err = s.repoMgr.GetFileContent(fullPath)
+				contentBytes, source, err = s.readFileContent(request, fullPath)
 			}
 		}
 
 		if err != nil {
-			s.logger.Error("Failed to read file content", zap.String("path", fullPath), zap.Error(err))
+			s.log(ctx).Error("Failed to read file content", zap.String("path", fullPath), zap.Error(err))
 			return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
 		}
 	}
 
 	content := string(contentBytes)
-	lines := strings.Split(content, "\n")
+	text := textpos.Split(content)
 
-	// Apply line range if specified
-	if startLine > 0 && endLine > 0 && startLine <= len(lines) && endLine <= len(lines) && startLine <= endLine {
-		lines = lines[startLine-1 : endLine]
-		content = strings.Join(lines, "\n")
+	// Apply line range if specified; a start line without an end line reads
+	// to the end of the file
+	if startLine > 0 {
+		if endLine <= 0 {
+			endLine = text.LineCount()
+		}
+		lines, err := text.Lines(startLine, endLine)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid line range: %v", err)), nil
+		}
+		content = strings.Join(lines, text.Ending())
 	}
 
 	// Detect language from file extension
 	language := s.repoMgr.GetFileLanguage(filePath)
 
 	result := map[string]interface{}{
-		"file_path":   filePath,
+		"file_path":   pathutil.ToSlash(filePath),
 		"full_path":   fullPath,
 		"repository":  repository,
 		"content":     content,
-		"total_lines": len(strings.Split(string(contentBytes), "\n")),
+		"total_lines": text.LineCount(),
 		"start_line":  startLine,
 		"end_line":    endLine,
 		"language":    language,
 		"size":        len(contentBytes),
+		"source":      source,
 	}
 
 	responseContent, err := json.MarshalIndent(result, "", "  ")
@@ -260,9 +404,15 @@ func (s *MCPServer) handleGetFileContent(ctx context.Context, request mcp.CallTo
 	return mcp.NewToolResultText(string(responseContent)), nil
 }
 
+// Page size limits for list_directory
+const (
+	defaultListDirectoryLimit = 500
+	maxListDirectoryLimit     = 5000
+)
+
 // handleListDirectory handles directory listing requests
 func (s *MCPServer) handleListDirectory(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling list directory", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling list directory", zap.String("tool", request.Params.Name))
 
 	directoryPath, err := request.RequireString("directory_path")
 	if err != nil {
@@ -270,33 +420,81 @@ func (s *MCPServer) handleListDirectory(ctx context.Context, request mcp.CallToo
 	}
 
 	repository := request.GetString("repository", "")
-	recursive := s.getBooleanValue(request, "recursive", false)
-	fileFilter := request.GetString("file_filter", "")
+	opts := directoryListOptions{
+		Recursive:       s.getBooleanValue(request, "recursive", false),
+		FileFilter:      request.GetString("file_filter", ""),
+		MaxDepth:        int(request.GetFloat("max_depth", 0)),
+		MaxPerDirectory: int(request.GetFloat("max_entries_per_directory", 0)),
+		DirectoriesOnly: s.getBooleanValue(request, "directories_only", false),
+		SortBy:          request.GetString("sort_by", "name"),
+		Descending:      s.getBooleanValue(request, "descending", false),
+	}
+	offset := int(request.GetFloat("offset", 0))
+	limit := int(request.GetFloat("limit", defaultListDirectoryLimit))
 
-	// Resolve the full directory path
-	var fullPath string
-	if repository != "" {
-		repoPath := filepath.Join("./repositories", repository)
-		fullPath = filepath.Join(repoPath, directoryPath)
-	} else {
-		fullPath = directoryPath
+	switch opts.SortBy {
+	case "name", "size", "modified":
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid sort_by %q: expected name, size or modified", opts.SortBy)), nil
+	}
+	if offset < 0 || limit < 0 || opts.MaxDepth < 0 || opts.MaxPerDirectory < 0 {
+		return mcp.NewToolResultError("offset, limit, max_depth and max_entries_per_directory must not be negative"), nil
+	}
+	if limit == 0 || limit > maxListDirectoryLimit {
+		limit = maxListDirectoryLimit
+	}
+
+	// Resolve the full directory path and keep it inside the sandbox
+	fullPath, err := s.repositoryPath(repository, directoryPath)
+	if err != nil {
+		return toolErrorResult(err), nil
+	}
+	if _, err := s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list directory: %v", err)), nil
 	}
 
 	// List directory contents
-	entries, err := s.listDirectoryContents(fullPath, recursive, fileFilter)
+	listing, err := s.listDirectoryContents(fullPath, opts)
 	if err != nil {
-		s.logger.Error("Failed to list directory", zap.String("path", fullPath), zap.Error(err))
+		s.log(ctx).Error("Failed to list directory", zap.String("path", fullPath), zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to list directory: %v", err)), nil
 	}
 
+	// Paginate
+	total := len(listing.Entries)
+	start := offset
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if end > total {
+		end = total
+	}
+	entries := listing.Entries[start:end]
+
 	result := map[string]interface{}{
-		"directory_path": directoryPath,
-		"full_path":      fullPath,
-		"repository":     repository,
-		"recursive":      recursive,
-		"file_filter":    fileFilter,
-		"entries":        entries,
-		"total_entries":  len(entries),
+		"directory_path":   pathutil.ToSlash(directoryPath),
+		"full_path":        fullPath,
+		"repository":       repository,
+		"recursive":        opts.Recursive,
+		"file_filter":      opts.FileFilter,
+		"directories_only": opts.DirectoriesOnly,
+		"sort_by":          opts.SortBy,
+		"entries":          entries,
+		"total_entries":    total,
+		"returned":         len(entries),
+		"offset":           start,
+		"limit":            limit,
+		"has_more":         end < total,
+	}
+	if end < total {
+		result["next_offset"] = end
+	}
+	if opts.MaxDepth > 0 {
+		result["max_depth"] = opts.MaxDepth
+	}
+	if len(listing.Omitted) > 0 {
+		result["truncated_directories"] = listing.Omitted
 	}
 
 	content, err := json.MarshalIndent(result, "", "  ")
@@ -309,9 +507,41 @@ func (s *MCPServer) handleListDirectory(ctx context.Context, request mcp.CallToo
 
 // File manipulation tool handlers for direct file editing
 
+// editDiffContext is the number of unchanged lines shown around an edit
+const editDiffContext = 3
+
+// applyLineEdit returns the unified diff of a line edit and, unless dryRun is
+// set, writes the edited text back to the file and journals the edit
+func (s *MCPServer) applyLineEdit(ctx context.Context, request mcp.CallToolRequest, filePath string, original []byte, text *textpos.Text, dryRun bool) (string, error) {
+	return s.applyEdit(ctx, request, filePath, original, text.String(), dryRun)
+}
+
+// applyEdit returns the unified diff between the original and edited file
+// content and, unless dryRun is set, writes and journals the edited content.
+// The bytes written count against the edit quota of the caller's connection.
+// Nothing is written once the call timed out or was cancelled, as it has been
+// answered as failed already.
+func (s *MCPServer) applyEdit(ctx context.Context, request mcp.CallToolRequest, filePath string, original []byte, edited string, dryRun bool) (string, error) {
+	diff := textpos.UnifiedDiff(filepath.ToSlash(filePath), string(original), edited, editDiffContext)
+	if dryRun {
+		return diff, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return diff, err
+	}
+	if err := s.chargeEditBytes(ctx, len(edited)); err != nil {
+		return diff, err
+	}
+	if err := s.repoMgr.WriteFile(filePath, []byte(edited)); err != nil {
+		return diff, err
+	}
+	s.recordEdit(ctx, request, filePath, string(original), edited)
+	return diff, nil
+}
+
 // handleDeleteLines handles line deletion requests
 func (s *MCPServer) handleDeleteLines(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling delete lines", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling delete lines", zap.String("tool", request.Params.Name))
 
 	filePath, err := request.RequireString("file_path")
 	if err != nil {
@@ -320,6 +550,7 @@ func (s *MCPServer) handleDeleteLines(ctx context.Context, request mcp.CallToolR
 
 	startLine := int(request.GetFloat("start_line", 0))
 	endLine := int(request.GetFloat("end_line", 0))
+	dryRun := s.getBooleanValue(request, "dry_run", false)
 
 	if startLine <= 0 || endLine <= 0 {
 		return mcp.NewToolResultError("start_line and end_line must be positive integers"), nil
@@ -330,45 +561,51 @@ func (s *MCPServer) handleDeleteLines(ctx context.Context, request mcp.CallToolR
 	}
 
 	// Read the file content
-	contentBytes, err := s.repoMgr.GetFileContent(filePath)
+	contentBytes, err := s.repoMgr.ReadFile(filePath)
 	if err != nil {
-		s.logger.Error("Failed to read file for line deletion", zap.String("path", filePath), zap.Error(err))
+		s.log(ctx).Error("Failed to read file for line deletion", zap.String("path", filePath), zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
 	}
 
-	lines := strings.Split(string(contentBytes), "\n")
-	totalLines := len(lines)
+	text := textpos.Split(string(contentBytes))
+	totalLines := text.LineCount()
 
-	if startLine > totalLines || endLine > totalLines {
-		return mcp.NewToolResultError(fmt.Sprintf("Line numbers exceed file length (%d lines)", totalLines)), nil
+	// Delete the specified lines, keeping the file's line endings
+	if err := text.Delete(startLine, endLine); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Line numbers exceed file length: %v", err)), nil
 	}
 
-	// Delete the specified lines (convert to 0-based indexing)
-	newLines := append(lines[:startLine-1], lines[endLine:]...)
-	newContent := strings.Join(newLines, "\n")
-
-	// Write the modified content back to the file
-	err = os.WriteFile(filePath, []byte(newContent), 0644)
+	// Write the modified content back to the file unless previewing
+	diff, err := s.applyLineEdit(ctx, request, filePath, contentBytes, text, dryRun)
 	if err != nil {
-		s.logger.Error("Failed to write file after line deletion", zap.String("path", filePath), zap.Error(err))
+		s.log(ctx).Error("Failed to write file after line deletion", zap.String("path", filePath), zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
 	}
 
+	message := fmt.Sprintf("Successfully deleted lines %d-%d from %s", startLine, endLine, filePath)
+	if dryRun {
+		message = fmt.Sprintf("Dry run: would delete lines %d-%d from %s", startLine, endLine, filePath)
+	}
+
 	result := map[string]interface{}{
-		"success":       true,
-		"file_path":     filePath,
-		"start_line":    startLine,
-		"end_line":      endLine,
-		"lines_deleted": endLine - startLine + 1,
+		"success":        true,
+		"dry_run":        dryRun,
+		"file_path":      filePath,
+		"start_line":     startLine,
+		"end_line":       endLine,
+		"lines_deleted":  endLine - startLine + 1,
 		"original_lines": totalLines,
-		"new_lines":     len(newLines),
-		"message":       fmt.Sprintf("Successfully deleted lines %d-%d from %s", startLine, endLine, filePath),
+		"new_lines":      text.LineCount(),
+		"diff":           diff,
+		"message":        message,
 	}
 
-	s.logger.Info("Lines deleted successfully",
-		zap.String("file", filePath),
-		zap.Int("start", startLine),
-		zap.Int("end", endLine))
+	if !dryRun {
+		s.log(ctx).Info("Lines deleted successfully",
+			zap.String("file", filePath),
+			zap.Int("start", startLine),
+			zap.Int("end", endLine))
+	}
 
 	content, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
@@ -380,7 +617,7 @@ func (s *MCPServer) handleDeleteLines(ctx context.Context, request mcp.CallToolR
 
 // handleInsertAtLine handles line insertion requests
 func (s *MCPServer) handleInsertAtLine(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling insert at line", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling insert at line", zap.String("tool", request.Params.Name))
 
 	filePath, err := request.RequireString("file_path")
 	if err != nil {
@@ -388,6 +625,7 @@ func (s *MCPServer) handleInsertAtLine(ctx context.Context, request mcp.CallTool
 	}
 
 	lineNumber := int(request.GetFloat("line_number", 0))
+	dryRun := s.getBooleanValue(request, "dry_run", false)
 	content, err := request.RequireString("content")
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid content parameter: %v", err)), nil
@@ -398,58 +636,57 @@ func (s *MCPServer) handleInsertAtLine(ctx context.Context, request mcp.CallTool
 	}
 
 	// Read the file content
-	contentBytes, err := s.repoMgr.GetFileContent(filePath)
+	contentBytes, err := s.repoMgr.ReadFile(filePath)
 	if err != nil {
-		s.logger.Error("Failed to read file for line insertion", zap.String("path", filePath), zap.Error(err))
+		s.log(ctx).Error("Failed to read file for line insertion", zap.String("path", filePath), zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
 	}
 
-	lines := strings.Split(string(contentBytes), "\n")
-	totalLines := len(lines)
+	text := textpos.Split(string(contentBytes))
+	totalLines := text.LineCount()
 
 	if lineNumber > totalLines+1 {
 		return mcp.NewToolResultError(fmt.Sprintf("Line number %d exceeds file length (%d lines)", lineNumber, totalLines)), nil
 	}
 
-	// Insert the content at the specified line (convert to 0-based indexing)
-	insertIndex := lineNumber - 1
-	if insertIndex > len(lines) {
-		insertIndex = len(lines)
+	// Insert the content before the specified line; multi-line content uses
+	// the file's line ending
+	inserted, err := text.Replace(lineNumber, lineNumber-1, content)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid line number: %v", err)), nil
 	}
 
-	// Split content by newlines to handle multi-line insertions
-	contentLines := strings.Split(content, "\n")
-
-	// Insert the new lines
-	newLines := make([]string, 0, len(lines)+len(contentLines))
-	newLines = append(newLines, lines[:insertIndex]...)
-	newLines = append(newLines, contentLines...)
-	newLines = append(newLines, lines[insertIndex:]...)
-
-	newContent := strings.Join(newLines, "\n")
-
-	// Write the modified content back to the file
-	err = os.WriteFile(filePath, []byte(newContent), 0644)
+	// Write the modified content back to the file unless previewing
+	diff, err := s.applyLineEdit(ctx, request, filePath, contentBytes, text, dryRun)
 	if err != nil {
-		s.logger.Error("Failed to write file after line insertion", zap.String("path", filePath), zap.Error(err))
+		s.log(ctx).Error("Failed to write file after line insertion", zap.String("path", filePath), zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
 	}
 
+	message := fmt.Sprintf("Successfully inserted %d lines at line %d in %s", inserted, lineNumber, filePath)
+	if dryRun {
+		message = fmt.Sprintf("Dry run: would insert %d lines at line %d in %s", inserted, lineNumber, filePath)
+	}
+
 	result := map[string]interface{}{
 		"success":        true,
+		"dry_run":        dryRun,
 		"file_path":      filePath,
 		"line_number":    lineNumber,
-		"lines_inserted": len(contentLines),
+		"lines_inserted": inserted,
 		"original_lines": totalLines,
-		"new_lines":      len(newLines),
+		"new_lines":      text.LineCount(),
 		"content":        content,
-		"message":        fmt.Sprintf("Successfully inserted %d lines at line %d in %s", len(contentLines), lineNumber, filePath),
+		"diff":           diff,
+		"message":        message,
 	}
 
-	s.logger.Info("Lines inserted successfully",
-		zap.String("file", filePath),
-		zap.Int("line", lineNumber),
-		zap.Int("inserted", len(contentLines)))
+	if !dryRun {
+		s.log(ctx).Info("Lines inserted successfully",
+			zap.String("file", filePath),
+			zap.Int("line", lineNumber),
+			zap.Int("inserted", inserted))
+	}
 
 	responseContent, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
@@ -461,7 +698,7 @@ func (s *MCPServer) handleInsertAtLine(ctx context.Context, request mcp.CallTool
 
 // handleReplaceLines handles line replacement requests
 func (s *MCPServer) handleReplaceLines(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling replace lines", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling replace lines", zap.String("tool", request.Params.Name))
 
 	filePath, err := request.RequireString("file_path")
 	if err != nil {
@@ -470,6 +707,7 @@ func (s *MCPServer) handleReplaceLines(ctx context.Context, request mcp.CallTool
 
 	startLine := int(request.GetFloat("start_line", 0))
 	endLine := int(request.GetFloat("end_line", 0))
+	dryRun := s.getBooleanValue(request, "dry_run", false)
 	newContent, err := request.RequireString("new_content")
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid new_content parameter: %v", err)), nil
@@ -484,55 +722,56 @@ func (s *MCPServer) handleReplaceLines(ctx context.Context, request mcp.CallTool
 	}
 
 	// Read the file content
-	contentBytes, err := s.repoMgr.GetFileContent(filePath)
+	contentBytes, err := s.repoMgr.ReadFile(filePath)
 	if err != nil {
-		s.logger.Error("Failed to read file for line replacement", zap.String("path", filePath), zap.Error(err))
+		s.log(ctx).Error("Failed to read file for line replacement", zap.String("path", filePath), zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
 	}
 
-	lines := strings.Split(string(contentBytes), "\n")
-	totalLines := len(lines)
+	text := textpos.Split(string(contentBytes))
+	totalLines := text.LineCount()
 
-	if startLine > totalLines || endLine > totalLines {
-		return mcp.NewToolResultError(fmt.Sprintf("Line numbers exceed file length (%d lines)", totalLines)), nil
+	// Replace the specified lines; multi-line content uses the file's line
+	// ending
+	newLineCount, err := text.Replace(startLine, endLine, newContent)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Line numbers exceed file length: %v", err)), nil
 	}
 
-	// Split new content by newlines to handle multi-line replacements
-	newContentLines := strings.Split(newContent, "\n")
-
-	// Replace the specified lines (convert to 0-based indexing)
-	newLines := make([]string, 0, len(lines)-((endLine-startLine)+1)+len(newContentLines))
-	newLines = append(newLines, lines[:startLine-1]...)
-	newLines = append(newLines, newContentLines...)
-	newLines = append(newLines, lines[endLine:]...)
-
-	finalContent := strings.Join(newLines, "\n")
-
-	// Write the modified content back to the file
-	err = os.WriteFile(filePath, []byte(finalContent), 0644)
+	// Write the modified content back to the file unless previewing
+	diff, err := s.applyLineEdit(ctx, request, filePath, contentBytes, text, dryRun)
 	if err != nil {
-		s.logger.Error("Failed to write file after line replacement", zap.String("path", filePath), zap.Error(err))
+		s.log(ctx).Error("Failed to write file after line replacement", zap.String("path", filePath), zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to write file: %v", err)), nil
 	}
 
+	message := fmt.Sprintf("Successfully replaced lines %d-%d in %s with %d new lines", startLine, endLine, filePath, newLineCount)
+	if dryRun {
+		message = fmt.Sprintf("Dry run: would replace lines %d-%d in %s with %d new lines", startLine, endLine, filePath, newLineCount)
+	}
+
 	result := map[string]interface{}{
 		"success":         true,
+		"dry_run":         dryRun,
 		"file_path":       filePath,
 		"start_line":      startLine,
 		"end_line":        endLine,
 		"lines_replaced":  endLine - startLine + 1,
-		"new_lines_count": len(newContentLines),
+		"new_lines_count": newLineCount,
 		"original_lines":  totalLines,
-		"final_lines":     len(newLines),
+		"final_lines":     text.LineCount(),
 		"new_content":     newContent,
-		"message":         fmt.Sprintf("Successfully replaced lines %d-%d in %s with %d new lines", startLine, endLine, filePath, len(newContentLines)),
+		"diff":            diff,
+		"message":         message,
 	}
 
-	s.logger.Info("Lines replaced successfully",
-		zap.String("file", filePath),
-		zap.Int("start", startLine),
-		zap.Int("end", endLine),
-		zap.Int("new_lines", len(newContentLines)))
+	if !dryRun {
+		s.log(ctx).Info("Lines replaced successfully",
+			zap.String("file", filePath),
+			zap.Int("start", startLine),
+			zap.Int("end", endLine),
+			zap.Int("new_lines", newLineCount))
+	}
 
 	responseContent, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
@@ -546,7 +785,7 @@ func (s *MCPServer) handleReplaceLines(ctx context.Context, request mcp.CallTool
 
 // handleGetFileSnippet handles file snippet extraction requests
 func (s *MCPServer) handleGetFileSnippet(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling get file snippet", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling get file snippet", zap.String("tool", request.Params.Name))
 
 	filePath, err := request.RequireString("file_path")
 	if err != nil {
@@ -565,23 +804,23 @@ func (s *MCPServer) handleGetFileSnippet(ctx context.Context, request mcp.CallTo
 		return mcp.NewToolResultError("start_line must be less than or equal to end_line"), nil
 	}
 
-	// Read the file content
-	contentBytes, err := s.repoMgr.GetFileContent(filePath)
+	// Read the file content, preferring an unsaved editor buffer
+	contentBytes, source, err := s.readFileContent(request, filePath)
 	if err != nil {
-		s.logger.Error("Failed to read file for snippet extraction", zap.String("path", filePath), zap.Error(err))
+		s.log(ctx).Error("Failed to read file for snippet extraction", zap.String("path", filePath), zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
 	}
 
-	lines := strings.Split(string(contentBytes), "\n")
-	totalLines := len(lines)
-
-	if startLine > totalLines || endLine > totalLines {
-		return mcp.NewToolResultError(fmt.Sprintf("Line numbers exceed file length (%d lines)", totalLines)), nil
-	}
+	text := textpos.Split(string(contentBytes))
+	totalLines := text.LineCount()
 
 	// Extract the snippet
-	snippetLines := lines[startLine-1 : endLine]
-	snippet := strings.Join(snippetLines, "\n")
+	snippetLines, err := text.Lines(startLine, endLine)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Line numbers exceed file length: %v", err)), nil
+	}
+	lineEnding := text.Ending()
+	snippet := strings.Join(snippetLines, lineEnding)
 
 	// Add context if requested
 	var contextBefore, contextAfter []string
@@ -594,7 +833,7 @@ func (s *MCPServer) handleGetFileSnippet(ctx context.Context, request mcp.CallTo
 			contextStart = 0
 		}
 		if contextStart < startLine-1 {
-			contextBefore = lines[contextStart : startLine-1]
+			contextBefore, _ = text.Lines(contextStart+1, startLine-1)
 		}
 
 		// Get context after
@@ -603,7 +842,7 @@ func (s *MCPServer) handleGetFileSnippet(ctx context.Context, request mcp.CallTo
 			contextEnd = totalLines
 		}
 		if contextEnd > endLine {
-			contextAfter = lines[endLine:contextEnd]
+			contextAfter, _ = text.Lines(endLine+1, contextEnd)
 		}
 	}
 
@@ -616,16 +855,17 @@ func (s *MCPServer) handleGetFileSnippet(ctx context.Context, request mcp.CallTo
 		"snippet_lines": len(snippetLines),
 		"total_lines":   totalLines,
 		"language":      s.repoMgr.GetFileLanguage(filePath),
+		"source":        source,
 	}
 
 	if includeContext {
-		result["context_before"] = strings.Join(contextBefore, "\n")
-		result["context_after"] = strings.Join(contextAfter, "\n")
+		result["context_before"] = strings.Join(contextBefore, lineEnding)
+		result["context_after"] = strings.Join(contextAfter, lineEnding)
 		result["context_before_lines"] = len(contextBefore)
 		result["context_after_lines"] = len(contextAfter)
 	}
 
-	s.logger.Info("File snippet extracted successfully",
+	s.log(ctx).Info("File snippet extracted successfully",
 		zap.String("file", filePath),
 		zap.Int("start", startLine),
 		zap.Int("end", endLine),
@@ -639,9 +879,12 @@ func (s *MCPServer) handleGetFileSnippet(ctx context.Context, request mcp.CallTo
 	return mcp.NewToolResultText(string(content)), nil
 }
 
-// handleFindReferences handles symbol reference finding requests
+// handleFindReferences handles symbol reference finding requests. References
+// come from the reference index built while parsing, so they are exact call
+// sites and type uses rather than text matches. In references mode, files
+// with unsaved buffers are searched in the buffer instead.
 func (s *MCPServer) handleFindReferences(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling find references", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling find references", zap.String("tool", request.Params.Name))
 
 	symbolName, err := request.RequireString("symbol_name")
 	if err != nil {
@@ -651,123 +894,401 @@ func (s *MCPServer) handleFindReferences(ctx context.Context, request mcp.CallTo
 	symbolType := request.GetString("symbol_type", "")
 	repository := request.GetString("repository", "")
 	includeDefinitions := s.getBooleanValue(request, "include_definitions", true)
-
-	// Search for the symbol in code content
-	searchQuery := types.SearchQuery{
-		Query:      symbolName,
-		Type:       "content", // Search in file content for references
-		Language:   "",
+	mode := request.GetString("mode", "references")
+	offset, pageSize, err := s.getPage(request, findReferencesMaxResults, findReferencesMaxResults)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid cursor parameter: %v", err)), nil
+	}
+	refQuery := types.ReferenceQuery{
+		Name:       symbolName,
 		Repository: repository,
-		MaxResults: 200, // Higher limit for references
-		Fuzzy:      false, // Exact matches for references
+		MaxResults: pageSize,
+		Offset:     offset,
+	}
+
+	var result map[string]interface{}
+	var page *types.ReferencePage
+	switch mode {
+	case "references":
+		result, page, err = s.findReferences(ctx, s.sessionForRequest(request), refQuery, symbolType, includeDefinitions)
+	case "callers_of":
+		result, page, err = s.findCallers(ctx, refQuery)
+	case "callees_of":
+		result, page, err = s.findCallees(ctx, refQuery)
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid mode %q: use references, callers_of or callees_of", mode)), nil
 	}
-
-	searchResults, err := s.searcher.Search(ctx, searchQuery)
 	if err != nil {
-		s.logger.Error("Failed to search for references", zap.Error(err))
+		s.log(ctx).Error("Failed to search for references", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Reference search failed: %v", err)), nil
 	}
+	result["symbol_name"] = symbolName
+	result["repository"] = repository
+	result["mode"] = mode
+	addPage(result, pageSize, page.Total, page.NextCursor)
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
 
-	// Also search for symbol definitions if requested
-	var definitionResults []types.SearchResult
-	if includeDefinitions {
-		defQuery := types.SearchQuery{
-			Query:      symbolName,
-			Type:       symbolType, // Search for actual symbol definitions
-			Language:   "",
-			Repository: repository,
-			MaxResults: 50,
-			Fuzzy:      false,
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// findReferences lists a page of the indexed references to a symbol, with
+// the session's unsaved buffers overlaid. Its definitions are listed with
+// the first page only.
+func (s *MCPServer) findReferences(ctx context.Context, sess *session.Session, refQuery types.ReferenceQuery, symbolType string, includeDefinitions bool) (map[string]interface{}, *types.ReferencePage, error) {
+	if refQuery.Repository != "" && refQuery.Importers == nil {
+		refQuery.Importers = s.importingFiles(ctx, refQuery.Repository)
+	}
+	page, err := s.searcher.FindReferences(ctx, refQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	refs := s.overlayBufferReferences(ctx, sess, refQuery, page.References)
+
+	// Definitions also resolve references the parser could not
+	definitionResults, err := s.findDefinitions(ctx, refQuery.Name, symbolType, refQuery.Repository)
+	if err != nil {
+		s.log(ctx).Warn("Failed to search for definitions", zap.Error(err))
+		// Continue without definitions
+	}
+	definitionResults = s.overlayBufferDefinitions(ctx, sess, refQuery.Name, symbolType, refQuery.Repository, definitionResults)
+	resolveReferenceTargets(refs, definitionResults)
+	s.resolveImportedTargets(ctx, refs, definitionResults)
+	s.annotateReferenceFollowUps(ctx, refs)
+
+	references := make([]map[string]interface{}, 0, len(refs))
+	for _, ref := range refs {
+		references = append(references, map[string]interface{}{
+			"file_path":         ref.FilePath,
+			"repository":        ref.Repository,
+			"language":          ref.Language,
+			"line_number":       ref.Line,
+			"column":            ref.Column,
+			"context":           ref.Context,
+			"kind":              ref.Kind,
+			"qualifier":         ref.Qualifier,
+			"caller":            ref.Caller,
+			"target":            ref.Target,
+			"target_repository": ref.TargetRepository,
+			"type":              "reference",
+			"follow_ups":        ref.FollowUps,
+		})
+	}
+
+	definitions := make([]map[string]interface{}, 0)
+	if includeDefinitions && refQuery.Offset == 0 {
+		s.annotateFollowUps(ctx, definitionResults)
+		for _, result := range definitionResults {
+			definitions = append(definitions, map[string]interface{}{
+				"file_path":   result.FilePath,
+				"repository":  result.Repository,
+				"language":    result.Language,
+				"line_number": result.StartLine,
+				"end_line":    result.EndLine,
+				"context":     result.Snippet,
+				"content":     result.Content,
+				"symbol_type": result.Type,
+				"score":       result.Score,
+				"type":        "definition",
+				"follow_ups":  result.FollowUps,
+			})
 		}
+	}
 
-		definitionResults, err = s.searcher.Search(ctx, defQuery)
-		if err != nil {
-			s.logger.Warn("Failed to search for definitions", zap.Error(err))
-			// Continue without definitions
+	s.log(ctx).Info("References found successfully",
+		zap.String("symbol", refQuery.Name),
+		zap.Int("references", len(references)),
+		zap.Int("definitions", len(definitions)))
+
+	return map[string]interface{}{
+		"symbol_type":         symbolType,
+		"include_definitions": includeDefinitions,
+		"references":          references,
+		"definitions":         definitions,
+		"reference_count":     len(references),
+		"definition_count":    len(definitions),
+		"total_matches":       len(references) + len(definitions),
+	}, page, nil
+}
+
+// findDefinitions returns the symbol documents named exactly like a symbol
+func (s *MCPServer) findDefinitions(ctx context.Context, symbolName, symbolType, repository string) ([]types.SearchResult, error) {
+	results, err := s.searcher.Search(ctx, definitionQuery(symbolName, symbolType, repository))
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := make([]types.SearchResult, 0, len(results))
+	for _, result := range results {
+		if result.Name == symbolName {
+			definitions = append(definitions, result)
 		}
 	}
+	return definitions, nil
+}
 
-	references := make([]map[string]interface{}, 0)
-	definitions := make([]map[string]interface{}, 0)
+// definitionQuery returns the query for the symbol documents of a symbol,
+// of any symbol type when symbolType is empty
+func definitionQuery(symbolName, symbolType, repository string) types.SearchQuery {
+	defQuery := types.SearchQuery{
+		Query:      symbolName,
+		Type:       symbolType,
+		Repository: repository,
+		MaxResults: findDefinitionsMaxResults,
+	}
+	if symbolType == "" {
+		defQuery.Types = types.SymbolTypes
+	}
+	return defQuery
+}
 
-	// Process content references
-	for _, result := range searchResults {
-		// Skip if this looks like a definition rather than a reference
-		if !includeDefinitions && (strings.Contains(result.Content, "func "+symbolName) ||
-			strings.Contains(result.Content, "class "+symbolName) ||
-			strings.Contains(result.Content, "var "+symbolName) ||
-			strings.Contains(result.Content, "const "+symbolName)) {
+// findCallers lists the functions that call a symbol, one entry per calling
+// function and file. Pages are taken over call sites, so a caller may be
+// continued on the next page.
+func (s *MCPServer) findCallers(ctx context.Context, refQuery types.ReferenceQuery) (map[string]interface{}, *types.ReferencePage, error) {
+	refQuery.Kinds = []string{"call"}
+	page, err := s.searcher.FindReferences(ctx, refQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	refs := page.References
+	s.annotateReferenceFollowUps(ctx, refs)
+
+	callers := groupReferences(refs, func(ref types.ReferenceResult) string { return ref.Caller },
+		func(ref types.ReferenceResult) map[string]interface{} {
+			return map[string]interface{}{"caller": ref.Caller}
+		})
+	for _, caller := range callers {
+		addGraphFollowUp(caller, caller["caller"].(string), "callers_of", refQuery.Repository)
+	}
+
+	return map[string]interface{}{
+		"callers":      callers,
+		"caller_count": len(callers),
+		"call_count":   len(refs),
+	}, page, nil
+}
+
+// findCallees lists the symbols a function calls, one entry per callee and
+// file. Pages are taken over call sites, so a callee may be continued on the
+// next page.
+func (s *MCPServer) findCallees(ctx context.Context, refQuery types.ReferenceQuery) (map[string]interface{}, *types.ReferencePage, error) {
+	refQuery.Caller, refQuery.Name = refQuery.Name, ""
+	refQuery.Kinds = []string{"call"}
+	page, err := s.searcher.FindReferences(ctx, refQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+	refs := page.References
+	s.annotateReferenceFollowUps(ctx, refs)
+
+	callees := groupReferences(refs, func(ref types.ReferenceResult) string { return ref.Qualifier + "." + ref.Name },
+		func(ref types.ReferenceResult) map[string]interface{} {
+			return map[string]interface{}{
+				"callee":    ref.Name,
+				"qualifier": ref.Qualifier,
+				"target":    ref.Target,
+			}
+		})
+	for _, callee := range callees {
+		addGraphFollowUp(callee, callee["callee"].(string), "callees_of", refQuery.Repository)
+	}
+
+	return map[string]interface{}{
+		"callees":      callees,
+		"callee_count": len(callees),
+		"call_count":   len(refs),
+	}, page, nil
+}
+
+// groupReferences merges references that share a key within a file into a
+// single entry listing their lines. References are expected in file order;
+// entries keep the order in which they first appear, and the follow-ups of
+// their first reference.
+func groupReferences(refs []types.ReferenceResult, key func(types.ReferenceResult) string,
+	entry func(types.ReferenceResult) map[string]interface{}) []map[string]interface{} {
+	groups := make([]map[string]interface{}, 0)
+	index := make(map[string]int)
+
+	for _, ref := range refs {
+		groupKey := ref.Repository + "\x00" + ref.FilePath + "\x00" + key(ref)
+		if idx, ok := index[groupKey]; ok {
+			groups[idx]["lines"] = append(groups[idx]["lines"].([]int), ref.Line)
+			groups[idx]["call_count"] = groups[idx]["call_count"].(int) + 1
 			continue
 		}
 
-		refInfo := map[string]interface{}{
-			"file_path":    result.FilePath,
-			"repository":   result.Repository,
-			"language":     result.Language,
-			"line_number":  result.StartLine,
-			"context":      result.Snippet,
-			"content":      result.Content,
-			"score":        result.Score,
-			"type":         "reference",
-		}
+		group := entry(ref)
+		group["file_path"] = ref.FilePath
+		group["repository"] = ref.Repository
+		group["language"] = ref.Language
+		group["lines"] = []int{ref.Line}
+		group["call_count"] = 1
+		group["follow_ups"] = ref.FollowUps
+		index[groupKey] = len(groups)
+		groups = append(groups, group)
+	}
+	return groups
+}
 
-		if result.Highlights != nil {
-			refInfo["highlights"] = result.Highlights
-		}
+// addGraphFollowUp adds a find_references follow-up that takes the call
+// graph one step further from a caller or callee entry
+func addGraphFollowUp(entry map[string]interface{}, symbolName, mode, repository string) {
+	if symbolName == "" {
+		return
+	}
+
+	args := map[string]any{"symbol_name": symbolName, "mode": mode}
+	if repository != "" {
+		args["repository"] = repository
+	}
+	description := fmt.Sprintf("Find the callers of %s", symbolName)
+	if mode == "callees_of" {
+		description = fmt.Sprintf("Find the functions %s calls", symbolName)
+	}
+
+	followUps, _ := entry["follow_ups"].([]types.FollowUp)
+	entry["follow_ups"] = append(followUps, types.FollowUp{
+		Tool:        "find_references",
+		Arguments:   args,
+		Description: description,
+	})
+}
 
-		references = append(references, refInfo)
-	}
-
-	// Process definitions
-	for _, result := range definitionResults {
-		defInfo := map[string]interface{}{
-			"file_path":    result.FilePath,
-			"repository":   result.Repository,
-			"language":     result.Language,
-			"line_number":  result.StartLine,
-			"end_line":     result.EndLine,
-			"context":      result.Snippet,
-			"content":      result.Content,
-			"symbol_type":  result.Type,
-			"score":        result.Score,
-			"type":         "definition",
+// resolveReferenceTargets sets the target of references the parser could
+// not resolve when their repository defines the symbol exactly once
+func resolveReferenceTargets(refs []types.ReferenceResult, definitions []types.SearchResult) {
+	targets := referenceTargets(definitions)
+	for idx := range refs {
+		if refs[idx].Target == "" {
+			refs[idx].Target = targets[refs[idx].Repository]
 		}
+	}
+}
 
-		if result.Highlights != nil {
-			defInfo["highlights"] = result.Highlights
+// referenceTargets returns the target of the single definition of each
+// repository, by repository name; repositories defining the name more than
+// once map to ""
+func referenceTargets(definitions []types.SearchResult) map[string]string {
+	targets := make(map[string]string)
+	for _, definition := range definitions {
+		target := definition.FilePath + ":" + definition.Name
+		if existing, ok := targets[definition.Repository]; ok && existing != target {
+			targets[definition.Repository] = ""
+			continue
 		}
+		targets[definition.Repository] = target
+	}
+	return targets
+}
 
-		definitions = append(definitions, defInfo)
+// importingFiles returns the files of other indexed repositories, by
+// repository name, that import a module the repository provides, so the
+// references they make to its symbols are found with its own
+func (s *MCPServer) importingFiles(ctx context.Context, repository string) map[string][]string {
+	repo, ok := s.indexer.IndexedRepository(repository)
+	if !ok || len(repo.Modules) == 0 {
+		return nil
+	}
+	repositories, err := s.indexer.ListRepositories(ctx)
+	if err != nil {
+		s.log(ctx).Warn("Failed to list importing repositories", zap.Error(err))
+		return nil
 	}
 
-	result := map[string]interface{}{
-		"symbol_name":         symbolName,
-		"symbol_type":         symbolType,
-		"repository":          repository,
-		"include_definitions": includeDefinitions,
-		"references":          references,
-		"definitions":         definitions,
-		"reference_count":     len(references),
-		"definition_count":    len(definitions),
-		"total_matches":       len(references) + len(definitions),
+	importers := make(map[string][]string)
+	for _, other := range repositories {
+		if other.ID == repo.ID || other.Name == repo.Name {
+			continue
+		}
+		files, err := s.searcher.FileImports(ctx, other.ID)
+		if err != nil {
+			s.log(ctx).Warn("Failed to read imports", zap.String("repository", other.Name), zap.Error(err))
+			continue
+		}
+		for _, file := range files {
+			if slices.ContainsFunc(file.Imports, func(module string) bool { return s.importsFrom(repo.ID, file.Language, module) }) {
+				importers[other.Name] = append(importers[other.Name], file.FilePath)
+			}
+		}
 	}
+	return importers
+}
 
-	s.logger.Info("References found successfully",
-		zap.String("symbol", symbolName),
-		zap.Int("references", len(references)),
-		zap.Int("definitions", len(definitions)))
+// importsFrom reports whether importing module in a file of language loads
+// a module of the repository with ID repositoryID
+func (s *MCPServer) importsFrom(repositoryID, language, module string) bool {
+	return slices.ContainsFunc(s.indexer.ModuleRepositories(language, module), func(repo types.Repository) bool {
+		return repo.ID == repositoryID
+	})
+}
 
-	content, err := json.MarshalIndent(result, "", "  ")
-	if err != nil {
-		return mcp.NewToolResultError("Failed to format response"), nil
+// resolveImportedTargets resolves references to the definition in another
+// repository whose module their file imports, when that repository defines
+// the name once: references the index left without a target, and those it
+// resolved to a name of an imported module
+func (s *MCPServer) resolveImportedTargets(ctx context.Context, refs []types.ReferenceResult, definitions []types.SearchResult) {
+	targets := referenceTargets(definitions)
+	imports := make(map[string]map[string][]string) // Repository ID -> file -> imported modules
+	for idx := range refs {
+		ref := &refs[idx]
+		var modules []string
+		switch {
+		case strings.HasSuffix(ref.Target, "."+ref.Name) && !strings.Contains(ref.Target, ":"):
+			modules = []string{strings.TrimSuffix(ref.Target, "."+ref.Name)}
+		case ref.Target == "":
+			files, ok := imports[ref.RepositoryID]
+			if !ok {
+				files = make(map[string][]string)
+				recorded, err := s.searcher.FileImports(ctx, ref.RepositoryID)
+				if err != nil {
+					s.log(ctx).Warn("Failed to read imports", zap.String("repository", ref.Repository), zap.Error(err))
+				}
+				for _, file := range recorded {
+					files[file.FilePath] = file.Imports
+				}
+				imports[ref.RepositoryID] = files
+			}
+			modules = files[ref.FilePath]
+		}
+
+	resolve:
+		for _, module := range modules {
+			for _, other := range s.indexer.ModuleRepositories(ref.Language, module) {
+				if target := targets[other.Name]; target != "" && other.ID != ref.RepositoryID {
+					ref.Target, ref.TargetRepository = target, other.Name
+					break resolve
+				}
+			}
+		}
 	}
+}
 
-	return mcp.NewToolResultText(string(content)), nil
+// annotateReferenceFollowUps attaches the follow-ups of a search result on
+// the reference's line to each reference
+func (s *MCPServer) annotateReferenceFollowUps(ctx context.Context, refs []types.ReferenceResult) {
+	results := make([]types.SearchResult, len(refs))
+	for idx, ref := range refs {
+		results[idx] = types.SearchResult{
+			Repository: ref.Repository,
+			FilePath:   ref.FilePath,
+			StartLine:  ref.Line,
+			EndLine:    ref.Line,
+			Type:       "reference",
+		}
+	}
+	s.annotateFollowUps(ctx, results)
+	for idx := range refs {
+		refs[idx].FollowUps = results[idx].FollowUps
+	}
 }
 
 // handleGitBlame handles Git blame requests
 func (s *MCPServer) handleGitBlame(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling git blame", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling git blame", zap.String("tool", request.Params.Name))
 
 	filePath, err := request.RequireString("file_path")
 	if err != nil {
@@ -800,7 +1321,7 @@ func (s *MCPServer) handleGitBlame(ctx context.Context, request mcp.CallToolRequ
 		}
 
 		if !repoFound {
-			return mcp.NewToolResultError(fmt.Sprintf("Repository '%s' not found", repository)), nil
+			return repoNotFound(repository), nil
 		}
 	} else {
 		// Try to find the file in any repository
@@ -809,11 +1330,26 @@ func (s *MCPServer) handleGitBlame(ctx context.Context, request mcp.CallToolRequ
 		repoPath = "."
 	}
 
-	// Check if file exists
+	// Keep blame inside the sandbox, then check the file exists
+	if _, err := s.repoMgr.ResolvePath(fullPath); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+	}
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
 		return mcp.NewToolResultError(fmt.Sprintf("File not found: %s", fullPath)), nil
 	}
 
+	// Validate the line range against the file so git reports no confusing
+	// errors for ranges past the end
+	if startLine > 0 && endLine > 0 {
+		contentBytes, err := s.repoMgr.ReadFile(fullPath)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to read file: %v", err)), nil
+		}
+		if err := textpos.Split(string(contentBytes)).CheckRange(startLine, endLine); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid line range: %v", err)), nil
+		}
+	}
+
 	// Execute git blame command
 	var gitArgs []string
 	if startLine > 0 && endLine > 0 {
@@ -822,25 +1358,14 @@ func (s *MCPServer) handleGitBlame(ctx context.Context, request mcp.CallToolRequ
 		gitArgs = []string{"blame", "--porcelain", filePath}
 	}
 
-	// Change to repository directory for git command
-	originalDir, err := os.Getwd()
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get current directory: %v", err)), nil
-	}
-
+	// Run git blame in the repository directory, stopping it with the call
+	cmd := exec.CommandContext(ctx, "git", gitArgs...)
 	if repoPath != "." {
-		err = os.Chdir(repoPath)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to change to repository directory: %v", err)), nil
-		}
-		defer os.Chdir(originalDir)
+		cmd.Dir = repoPath
 	}
-
-	// Execute git blame
-	cmd := exec.Command("git", gitArgs...)
 	output, err := cmd.Output()
 	if err != nil {
-		s.logger.Error("Git blame command failed", zap.Error(err))
+		s.log(ctx).Error("Git blame command failed", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Git blame failed: %v", err)), nil
 	}
 
@@ -849,7 +1374,7 @@ func (s *MCPServer) handleGitBlame(ctx context.Context, request mcp.CallToolRequ
 
 	result := map[string]interface{}{
 		"success":     true,
-		"file_path":   filePath,
+		"file_path":   pathutil.ToSlash(filePath),
 		"full_path":   fullPath,
 		"repository":  repository,
 		"start_line":  startLine,
@@ -858,7 +1383,7 @@ func (s *MCPServer) handleGitBlame(ctx context.Context, request mcp.CallToolRequ
 		"total_lines": len(blameLines),
 	}
 
-	s.logger.Info("Git blame completed successfully",
+	s.log(ctx).Info("Git blame completed successfully",
 		zap.String("file", filePath),
 		zap.Int("lines", len(blameLines)))
 
@@ -872,17 +1397,66 @@ func (s *MCPServer) handleGitBlame(ctx context.Context, request mcp.CallToolRequ
 
 // handleRefreshIndex handles index refresh requests
 func (s *MCPServer) handleRefreshIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling refresh index", zap.String("tool", request.Params.Name))
+	s.log(ctx).Info("Handling refresh index", zap.String("tool", request.Params.Name))
 
 	repository := request.GetString("repository", "")
 	forceRebuild := s.getBooleanValue(request, "force_rebuild", false)
+	mode := request.GetString("mode", "full")
+	if mode != "full" && mode != "incremental" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid mode '%s': expected full or incremental", mode)), nil
+	}
 
 	var refreshedRepos []string
 	var errors []string
+	var incrementalResults []*types.IncrementalIndexResult
+	var fullResults []map[string]interface{}
+
+	// refresh re-indexes one repository in the requested mode, reporting
+	// progress over all repositories refreshed by the call
+	notifier := s.newProgressNotifier(ctx, request)
+	refresh := func(name, path string) error {
+		indexCtx := notifier.repository(ctx)
+		if mode == "incremental" {
+			incremental, err := s.indexer.IndexIncremental(indexCtx, types.IncrementalIndexRequest{
+				RepositoryID: name,
+				ForceRebuild: forceRebuild,
+			})
+			if err != nil {
+				return err
+			}
+			incrementalResults = append(incrementalResults, incremental)
+			return nil
+		}
+		// Keep the source and the file filtering overrides the repository
+		// was indexed with
+		settings, known := s.indexer.RepositorySettings(name)
+		if settings.Source == "" {
+			settings.Source = path
+		}
+		settings.Name = name
+		if forceRebuild && known {
+			// Every file is parsed again, unchanged or not
+			if err := s.indexer.ReindexRepository(indexCtx, name); err != nil {
+				return err
+			}
+		} else if _, err := s.indexer.IndexRepositoryWithSettings(indexCtx, settings); err != nil {
+			return err
+		}
+		if runs := s.indexer.IndexingHistory(name, 1); len(runs) > 0 {
+			fullResults = append(fullResults, map[string]interface{}{
+				"repository":    name,
+				"files_parsed":  runs[0].FilesIndexed,
+				"files_skipped": runs[0].FilesSkipped,
+				"files_deleted": runs[0].FilesDeleted,
+				"files_failed":  runs[0].FilesFailed,
+			})
+		}
+		return nil
+	}
 
 	if repository != "" {
 		// Refresh specific repository
-		s.logger.Info("Refreshing specific repository", zap.String("repository", repository))
+		s.log(ctx).Info("Refreshing specific repository", zap.String("repository", repository))
 
 		// Check if repository exists
 		repositories, err := s.searcher.ListRepositories(ctx)
@@ -901,20 +1475,20 @@ func (s *MCPServer) handleRefreshIndex(ctx context.Context, request mcp.CallTool
 		}
 
 		if !repoFound {
-			return mcp.NewToolResultError(fmt.Sprintf("Repository '%s' not found in indexed repositories", repository)), nil
+			return repoNotFound(repository), nil
 		}
 
 		// Re-index the specific repository
-		_, err = s.indexer.IndexRepository(ctx, repoPath, repository)
+		err = refresh(repository, repoPath)
 		if err != nil {
-			s.logger.Error("Failed to refresh repository", zap.String("repository", repository), zap.Error(err))
+			s.log(ctx).Error("Failed to refresh repository", zap.String("repository", repository), zap.Error(err))
 			errors = append(errors, fmt.Sprintf("Failed to refresh %s: %v", repository, err))
 		} else {
 			refreshedRepos = append(refreshedRepos, repository)
 		}
 	} else {
 		// Refresh all repositories
-		s.logger.Info("Refreshing all repositories", zap.Bool("force_rebuild", forceRebuild))
+		s.log(ctx).Info("Refreshing all repositories", zap.Bool("force_rebuild", forceRebuild), zap.String("mode", mode))
 
 		repositories, err := s.searcher.ListRepositories(ctx)
 		if err != nil {
@@ -922,11 +1496,11 @@ func (s *MCPServer) handleRefreshIndex(ctx context.Context, request mcp.CallTool
 		}
 
 		for _, repo := range repositories {
-			s.logger.Info("Refreshing repository", zap.String("name", repo.Name), zap.String("path", repo.Path))
+			s.log(ctx).Info("Refreshing repository", zap.String("name", repo.Name), zap.String("path", repo.Path))
 
-			_, err := s.indexer.IndexRepository(ctx, repo.Path, repo.Name)
+			err := refresh(repo.Name, repo.Path)
 			if err != nil {
-				s.logger.Error("Failed to refresh repository", zap.String("repository", repo.Name), zap.Error(err))
+				s.log(ctx).Error("Failed to refresh repository", zap.String("repository", repo.Name), zap.Error(err))
 				errors = append(errors, fmt.Sprintf("Failed to refresh %s: %v", repo.Name, err))
 			} else {
 				refreshedRepos = append(refreshedRepos, repo.Name)
@@ -935,10 +1509,10 @@ func (s *MCPServer) handleRefreshIndex(ctx context.Context, request mcp.CallTool
 	}
 
 	// Get updated index statistics
-	stats, err := s.searcher.GetIndexStats(ctx)
+	stats, err := s.indexer.GetIndexStats(ctx)
 	var statsInterface interface{}
 	if err != nil {
-		s.logger.Warn("Failed to get updated index stats", zap.Error(err))
+		s.log(ctx).Warn("Failed to get updated index stats", zap.Error(err))
 		statsInterface = map[string]interface{}{"error": "Failed to retrieve updated stats"}
 	} else {
 		statsInterface = stats
@@ -956,11 +1530,18 @@ func (s *MCPServer) handleRefreshIndex(ctx context.Context, request mcp.CallTool
 		"message":           fmt.Sprintf("Refreshed %d repositories", len(refreshedRepos)),
 	}
 
+	if mode == "incremental" {
+		result["mode"] = mode
+		result["incremental"] = incrementalResults
+	} else {
+		result["files"] = fullResults
+	}
+
 	if len(errors) > 0 {
 		result["message"] = fmt.Sprintf("Refreshed %d repositories with %d errors", len(refreshedRepos), len(errors))
 	}
 
-	s.logger.Info("Index refresh completed",
+	s.log(ctx).Info("Index refresh completed",
 		zap.Int("refreshed", len(refreshedRepos)),
 		zap.Int("errors", len(errors)))
 
@@ -1016,7 +1597,7 @@ func (s *MCPServer) parseGitBlameOutput(output string) []map[string]interface{}
 				currentCommit["summary"] = strings.TrimPrefix(line, "summary ")
 			} else if strings.HasPrefix(line, "\t") {
 				// This is the actual code line
-				currentCommit["code"] = strings.TrimPrefix(line, "\t")
+				currentCommit["code"] = textpos.TrimLineEnding(strings.TrimPrefix(line, "\t"))
 				blameLines = append(blameLines, currentCommit)
 				currentCommit = nil
 			}
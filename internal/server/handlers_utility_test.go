@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestFileToolsAcceptBothSeparators(t *testing.T) {
+	root := t.TempDir()
+	fullPath := filepath.Join(root, "src", "pkg", "util.go")
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte("package pkg\n\nfunc Helper() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	for _, path := range []string{"src/pkg/util.go", `src\pkg\util.go`, `src\pkg/util.go`} {
+		text, isError := callTool(t, s, "get_file_content", map[string]interface{}{"file_path": path, "repository": "app"})
+		if isError {
+			t.Fatalf("get_file_content %q failed: %s", path, text)
+		}
+		var file struct {
+			FilePath string `json:"file_path"`
+			FullPath string `json:"full_path"`
+		}
+		if err := json.Unmarshal([]byte(text), &file); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		if file.FilePath != "src/pkg/util.go" || file.FullPath != fullPath {
+			t.Errorf("get_file_content %q: expected src/pkg/util.go at %s, got %+v", path, fullPath, file)
+		}
+	}
+
+	listed := func(args map[string]interface{}) []string {
+		t.Helper()
+		args["repository"] = "app"
+		text, isError := callTool(t, s, "list_directory", args)
+		if isError {
+			t.Fatalf("list_directory failed: %s", text)
+		}
+		var listing struct {
+			Entries []struct {
+				RelativePath string `json:"relative_path"`
+			} `json:"entries"`
+		}
+		if err := json.Unmarshal([]byte(text), &listing); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		var paths []string
+		for _, entry := range listing.Entries {
+			paths = append(paths, entry.RelativePath)
+		}
+		return paths
+	}
+	if paths := listed(map[string]interface{}{"directory_path": `src\pkg`}); len(paths) != 1 || paths[0] != "util.go" {
+		t.Errorf("Expected a backslash directory path to be listed, got %v", paths)
+	}
+	if paths := listed(map[string]interface{}{"directory_path": ".", "recursive": true}); len(paths) != 3 || paths[2] != "src/pkg/util.go" {
+		t.Errorf("Expected relative paths with forward slashes, got %v", paths)
+	}
+
+	// Paths are stored with forward slashes and filtered with either
+	for _, prefix := range []string{"src/pkg", `src\pkg`} {
+		text, isError := callTool(t, s, "search_code", map[string]interface{}{"query": "Helper", "path_prefix": prefix, "follow_ups": false})
+		if isError {
+			t.Fatalf("search_code failed: %s", text)
+		}
+		var searched struct {
+			Results []types.SearchResult `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(text), &searched); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		if len(searched.Results) == 0 {
+			t.Fatalf("Expected results under path_prefix %q", prefix)
+		}
+		for _, result := range searched.Results {
+			if result.FilePath != "src/pkg/util.go" {
+				t.Errorf("Expected the stored path src/pkg/util.go, got %q", result.FilePath)
+			}
+		}
+	}
+	text, isError := callTool(t, s, "search_code", map[string]interface{}{"query": "Helper", "exclude_paths": []interface{}{`src\pkg\util.go`}, "follow_ups": false})
+	if isError {
+		t.Fatalf("search_code failed: %s", text)
+	}
+	if strings.Contains(text, "src/pkg/util.go") {
+		t.Errorf("Expected a backslash exclude path to exclude the file, got %s", text)
+	}
+}
+
+func TestEditAfterInterruption(t *testing.T) {
+	root := t.TempDir()
+	file := filepath.Join(root, "main.go")
+	original := []byte("package main\n")
+	if err := os.WriteFile(file, original, 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+
+	// A call answered as timed out or cancelled writes nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var request mcp.CallToolRequest
+	request.Params.Name = "replace_lines"
+	if _, err := s.applyEdit(ctx, request, file, original, "package edited\n", false); err == nil {
+		t.Error("Expected the edit of a cancelled call to fail")
+	}
+	if content, _ := os.ReadFile(file); string(content) != string(original) {
+		t.Errorf("Expected the file to be unchanged, got %q", content)
+	}
+	if text, isError := callTool(t, s, "undo_last_edit", nil); !isError {
+		t.Errorf("Expected no edit to be journaled, got %s", text)
+	}
+}
+
+func TestListDirectory(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"a.go":            "package a\n",
+		"b.txt":           "notes\n",
+		"c.go":            "package c\n",
+		"docs/api.md":     "# API reference\n",
+		"docs/guide.md":   "# G\n",
+		"docs/readme.md":  "# Read me before anything else\n",
+		"pkg/sub/deep.go": "package sub\n",
+		"pkg/x.go":        "package pkg\n",
+		"pkg/y.go":        "package pkg\n",
+		"pkg/z.go":        "package pkg\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+
+	type listing struct {
+		Entries []struct {
+			RelativePath string `json:"relative_path"`
+		} `json:"entries"`
+		TotalEntries         int            `json:"total_entries"`
+		Returned             int            `json:"returned"`
+		Offset               int            `json:"offset"`
+		HasMore              bool           `json:"has_more"`
+		NextOffset           *int           `json:"next_offset"`
+		MaxDepth             int            `json:"max_depth"`
+		TruncatedDirectories map[string]int `json:"truncated_directories"`
+	}
+	list := func(args map[string]interface{}) (listing, string) {
+		t.Helper()
+		if _, ok := args["directory_path"]; !ok {
+			args["directory_path"] = root
+		}
+		text, isError := callTool(t, s, "list_directory", args)
+		if isError {
+			t.Fatalf("list_directory failed: %s", text)
+		}
+		var result listing
+		if err := json.Unmarshal([]byte(text), &result); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		var paths []string
+		for _, entry := range result.Entries {
+			paths = append(paths, entry.RelativePath)
+		}
+		return result, strings.Join(paths, " ")
+	}
+
+	all := "a.go b.txt c.go docs docs/api.md docs/guide.md docs/readme.md pkg pkg/sub pkg/sub/deep.go pkg/x.go pkg/y.go pkg/z.go"
+	if result, paths := list(map[string]interface{}{"recursive": true}); paths != all || result.TotalEntries != 13 || result.HasMore {
+		t.Errorf("Expected every entry in path order, got %q (%+v)", paths, result)
+	}
+
+	// Pages end at limit and the last one reports no next offset
+	result, paths := list(map[string]interface{}{"recursive": true, "limit": 5.0})
+	if paths != "a.go b.txt c.go docs docs/api.md" || !result.HasMore || result.NextOffset == nil || *result.NextOffset != 5 {
+		t.Errorf("Unexpected first page %q (%+v)", paths, result)
+	}
+	result, paths = list(map[string]interface{}{"recursive": true, "offset": 10.0, "limit": 5.0})
+	if paths != "pkg/x.go pkg/y.go pkg/z.go" || result.Returned != 3 || result.HasMore || result.NextOffset != nil {
+		t.Errorf("Unexpected last page %q (%+v)", paths, result)
+	}
+	result, paths = list(map[string]interface{}{"recursive": true, "offset": 99.0})
+	if paths != "" || result.Offset != 13 || result.TotalEntries != 13 || result.HasMore {
+		t.Errorf("Expected an empty page past the end, got %q (%+v)", paths, result)
+	}
+
+	// max_depth stops the walk; without recursive only the top level is listed
+	if result, paths := list(map[string]interface{}{"recursive": true, "max_depth": 1.0}); paths != "a.go b.txt c.go docs pkg" || result.MaxDepth != 1 {
+		t.Errorf("Unexpected listing at depth 1: %q (%+v)", paths, result)
+	}
+	if _, paths := list(map[string]interface{}{"recursive": true, "max_depth": 2.0}); strings.Contains(paths, "deep.go") || !strings.Contains(paths, "pkg/sub") {
+		t.Errorf("Expected pkg/sub but not its files at depth 2, got %q", paths)
+	}
+	if _, paths := list(map[string]interface{}{}); paths != "a.go b.txt c.go docs pkg" {
+		t.Errorf("Expected only the top level without recursive, got %q", paths)
+	}
+
+	// The cap applies to each directory on its own and counts the rest
+	result, paths = list(map[string]interface{}{"directory_path": filepath.Join(root, "pkg"), "recursive": true, "max_entries_per_directory": 2.0})
+	if paths != "sub sub/deep.go x.go" {
+		t.Errorf("Expected two entries of pkg and the file of pkg/sub, got %q", paths)
+	}
+	if omitted := result.TruncatedDirectories; len(omitted) != 1 || omitted[filepath.Join(root, "pkg")] != 2 {
+		t.Errorf("Expected pkg to be reported with two entries left out, got %v", omitted)
+	}
+	result, paths = list(map[string]interface{}{"recursive": true, "max_entries_per_directory": 2.0})
+	if paths != "a.go b.txt" || result.TruncatedDirectories[root] != 3 {
+		t.Errorf("Expected the directories past the cap to be left out with their contents, got %q (%v)", paths, result.TruncatedDirectories)
+	}
+	if _, paths := list(map[string]interface{}{"recursive": true, "max_entries_per_directory": 5.0}); paths != all {
+		t.Errorf("Expected no entries left out below the cap, got %q", paths)
+	}
+
+	if _, paths := list(map[string]interface{}{"recursive": true, "directories_only": true}); paths != "docs pkg pkg/sub" {
+		t.Errorf("Expected only directories, got %q", paths)
+	}
+
+	// Descending sorts reverse the order, paged after sorting
+	if _, paths := list(map[string]interface{}{"directory_path": filepath.Join(root, "pkg"), "descending": true}); paths != "z.go y.go x.go sub" {
+		t.Errorf("Expected names in reverse order, got %q", paths)
+	}
+	if _, paths := list(map[string]interface{}{"directory_path": filepath.Join(root, "docs"), "sort_by": "size", "descending": true}); paths != "readme.md api.md guide.md" {
+		t.Errorf("Expected the largest files first, got %q", paths)
+	}
+	if _, paths := list(map[string]interface{}{"directory_path": filepath.Join(root, "docs"), "sort_by": "size", "descending": true, "offset": 1.0, "limit": 1.0}); paths != "api.md" {
+		t.Errorf("Expected the second largest file on the second page, got %q", paths)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Workspace handlers: named groups of repositories searches can be scoped to
+
+// scopeToWorkspace narrows a query to the repositories of the workspace the
+// request names or, when it names neither a workspace nor a repository, to
+// the default workspace of its session, or the repositories below the
+// workspace directory of an isolated session. Repositories named together
+// with a workspace must belong to it. It returns the workspace applied, if
+// any.
+func (s *MCPServer) scopeToWorkspace(ctx context.Context, request mcp.CallToolRequest, query *types.SearchQuery) (string, error) {
+	name := request.GetString("workspace", "")
+	if name == "" {
+		if len(query.RepositoryFilter()) > 0 {
+			return "", nil
+		}
+		sess := s.sessionForRequest(request)
+		if name = sess.DefaultWorkspaceName(); name == "" {
+			if s.workspaceIsolated(sess) {
+				if query.Repositories = s.sessionWorkspace(ctx, sess).names(); len(query.Repositories) == 0 {
+					return "", fmt.Errorf("no indexed repository is below the workspace directory of session %q", sess.Name)
+				}
+			}
+			return "", nil
+		}
+	}
+
+	workspace, ok := s.indexer.Workspace(name)
+	if !ok {
+		return "", fmt.Errorf("unknown workspace %q; list_workspaces lists the workspaces", name)
+	}
+	if requested := query.RepositoryFilter(); len(requested) > 0 {
+		for _, repository := range requested {
+			if !slices.Contains(workspace.Repositories, repository) {
+				return "", fmt.Errorf("repository %q is not in workspace %q", repository, name)
+			}
+		}
+		return name, nil
+	}
+	query.Repositories = workspace.Repositories
+	return name, nil
+}
+
+// handleSetWorkspace creates, replaces or deletes a workspace
+func (s *MCPServer) handleSetWorkspace(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling set workspace", zap.String("tool", request.Params.Name))
+
+	name, err := request.RequireString("name")
+	if err != nil || name == "" {
+		return mcp.NewToolResultError("Invalid name parameter: a workspace needs a name"), nil
+	}
+
+	result := map[string]interface{}{
+		"success": true,
+		"name":    name,
+	}
+
+	if s.getBooleanValue(request, "delete", false) {
+		deleted := s.indexer.DeleteWorkspace(name)
+		result["deleted"] = deleted
+		result["message"] = fmt.Sprintf("Workspace %q deleted; its repositories stay indexed", name)
+		if !deleted {
+			result["message"] = fmt.Sprintf("No workspace is named %q", name)
+		}
+	} else {
+		workspace := types.Workspace{
+			Name:         name,
+			Description:  request.GetString("description", ""),
+			Repositories: s.getStringList(request, "repositories"),
+		}
+		replaced, err := s.indexer.SetWorkspace(ctx, workspace)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to set workspace: %v", err)), nil
+		}
+		workspace, _ = s.indexer.Workspace(name)
+		result["workspace"] = workspace
+		result["replaced"] = replaced
+		result["message"] = fmt.Sprintf("Workspace %q groups %d repositories", name, len(workspace.Repositories))
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// handleListWorkspaces lists the workspaces and the session's default
+func (s *MCPServer) handleListWorkspaces(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling list workspaces", zap.String("tool", request.Params.Name))
+
+	workspaces := s.indexer.Workspaces()
+	result := map[string]interface{}{
+		"workspaces": workspaces,
+		"count":      len(workspaces),
+	}
+	if name := s.sessionForRequest(request).DefaultWorkspaceName(); name != "" {
+		result["default_workspace"] = name
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
+
+// handleUseWorkspace binds the session to a default workspace, or unbinds it
+func (s *MCPServer) handleUseWorkspace(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling use workspace", zap.String("tool", request.Params.Name))
+
+	name := request.GetString("name", "")
+	if name != "" {
+		if _, ok := s.indexer.Workspace(name); !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Unknown workspace %q; list_workspaces lists the workspaces", name)), nil
+		}
+	}
+
+	sess := s.sessionForRequest(request)
+	if current := sess.DefaultWorkspaceName(); current != "" && current != name && s.workspaceIsolated(sess) {
+		return toolError(types.ErrorPermissionDenied,
+			fmt.Sprintf("Session %q is isolated to workspace %q; create another session to use another workspace", sess.Name, current),
+			map[string]interface{}{"session_id": sess.ID, "workspace": current}), nil
+	}
+	sess.SetDefaultWorkspace(name)
+
+	result := map[string]interface{}{
+		"success":    true,
+		"session_id": sess.ID,
+		"message":    "Searches are no longer scoped to a workspace",
+	}
+	if name != "" {
+		result["default_workspace"] = name
+		result["message"] = fmt.Sprintf("Searches that name no repository are scoped to workspace %q", name)
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(content)), nil
+}
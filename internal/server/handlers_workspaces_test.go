@@ -0,0 +1,227 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestWorkspaceScopedSearch(t *testing.T) {
+	root := t.TempDir()
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	for _, name := range []string{"api", "worker", "web"} {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "retry.go"), []byte("package main\n\nfunc Retry() {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": dir, "name": name}); isError {
+			t.Fatalf("Failed to index %s: %s", name, text)
+		}
+	}
+
+	if _, isError := callTool(t, s, "set_workspace", map[string]interface{}{"name": "backend", "repositories": []interface{}{"api", "missing"}}); !isError {
+		t.Error("Expected a workspace of an unknown repository to be rejected")
+	}
+	if text, isError := callTool(t, s, "set_workspace", map[string]interface{}{"name": "backend", "repositories": []interface{}{"worker", "api"}}); isError {
+		t.Fatalf("Failed to set workspace: %s", text)
+	}
+
+	searchRepositories := func(args map[string]interface{}) ([]string, bool) {
+		t.Helper()
+		args["query"], args["type"], args["follow_ups"] = "Retry", "function", false
+		text, isError := callTool(t, s, "search_code", args)
+		if isError {
+			return nil, true
+		}
+		var response struct {
+			Results []types.SearchResult `json:"results"`
+		}
+		if err := json.Unmarshal([]byte(text), &response); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		var repositories []string
+		for _, result := range response.Results {
+			repositories = append(repositories, result.Repository)
+		}
+		sort.Strings(repositories)
+		return repositories, false
+	}
+
+	tests := []struct {
+		name    string
+		args    map[string]interface{}
+		want    []string
+		wantErr bool
+	}{
+		{"no scope", map[string]interface{}{}, []string{"api", "web", "worker"}, false},
+		{"workspace", map[string]interface{}{"workspace": "backend"}, []string{"api", "worker"}, false},
+		{"repository in workspace", map[string]interface{}{"workspace": "backend", "repository": "api"}, []string{"api"}, false},
+		{"repository outside workspace", map[string]interface{}{"workspace": "backend", "repository": "web"}, nil, true},
+		{"unknown workspace", map[string]interface{}{"workspace": "frontend"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, isError := searchRepositories(tt.args)
+			if isError != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, isError)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected results from %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	// The session's default workspace applies until a repository is named
+	if text, isError := callTool(t, s, "use_workspace", map[string]interface{}{"name": "backend"}); isError {
+		t.Fatalf("Failed to use workspace: %s", text)
+	}
+	if got, _ := searchRepositories(map[string]interface{}{}); !slices.Equal(got, []string{"api", "worker"}) {
+		t.Errorf("Expected the default workspace to scope the search, got %v", got)
+	}
+	if got, _ := searchRepositories(map[string]interface{}{"repository": "web"}); !slices.Equal(got, []string{"web"}) {
+		t.Errorf("Expected a named repository to override the default workspace, got %v", got)
+	}
+	callTool(t, s, "use_workspace", map[string]interface{}{})
+	if got, _ := searchRepositories(map[string]interface{}{}); len(got) != 3 {
+		t.Errorf("Expected all repositories after unbinding, got %v", got)
+	}
+}
+
+func TestSessionWorkspaceIsolation(t *testing.T) {
+	root := t.TempDir()
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	for _, name := range []string{"api", "worker", "web"} {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".go"), []byte("package main\n\nfunc Retry() {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": dir, "name": name}); isError {
+			t.Fatalf("Failed to index %s: %s", name, text)
+		}
+	}
+	for name, repositories := range map[string][]interface{}{"backend": {"api", "worker"}, "frontend": {"web"}} {
+		if text, isError := callTool(t, s, "set_workspace", map[string]interface{}{"name": name, "repositories": repositories}); isError {
+			t.Fatalf("Failed to set workspace: %s", text)
+		}
+	}
+
+	createSession := func(args map[string]interface{}) string {
+		t.Helper()
+		text, isError := callTool(t, s, "create_session", args)
+		if isError {
+			t.Fatalf("Failed to create session: %s", text)
+		}
+		var created struct {
+			Session struct {
+				ID string `json:"id"`
+			} `json:"session"`
+		}
+		if err := json.Unmarshal([]byte(text), &created); err != nil {
+			t.Fatalf("Invalid response: %v: %s", err, text)
+		}
+		return created.Session.ID
+	}
+	call := func(sessionID, name string, args map[string]interface{}) (string, *types.ToolError) {
+		t.Helper()
+		args["session_id"] = sessionID
+		text, isError := callTool(t, s, name, args)
+		if !isError {
+			return text, nil
+		}
+		var envelope types.ErrorEnvelope
+		if err := json.Unmarshal([]byte(text), &envelope); err != nil || envelope.Error == nil {
+			t.Fatalf("Expected an error envelope, got %q", text)
+		}
+		return text, envelope.Error
+	}
+
+	backend := createSession(map[string]interface{}{"name": "backend-dev", "workspace": "backend"})
+	text, toolErr := call(backend, "find_files", map[string]interface{}{"pattern": "*.go"})
+	if toolErr != nil || !strings.Contains(text, "api.go") || strings.Contains(text, "web.go") {
+		t.Errorf("Expected files of the backend workspace only, got %s", text)
+	}
+	if _, toolErr := call(backend, "find_files", map[string]interface{}{"pattern": "*.go", "repository": "web"}); toolErr == nil || toolErr.Code != types.ErrorPermissionDenied {
+		t.Errorf("Expected a repository outside the workspace to be denied, got %+v", toolErr)
+	}
+	if _, toolErr := call(backend, "find_files", map[string]interface{}{"pattern": "*.go", "workspace": "frontend"}); toolErr == nil || toolErr.Code != types.ErrorPermissionDenied {
+		t.Errorf("Expected another workspace to be denied, got %+v", toolErr)
+	}
+
+	// Relative paths are found in the workspace repositories
+	if text, toolErr := call(backend, "get_file_content", map[string]interface{}{"file_path": "worker.go"}); toolErr != nil || !strings.Contains(text, "Retry") {
+		t.Errorf("Expected worker.go to be read from the workspace, got %s", text)
+	}
+	webFile := filepath.Join(root, "web", "web.go")
+	if _, toolErr := call(backend, "get_file_content", map[string]interface{}{"file_path": webFile}); toolErr == nil || toolErr.Code != types.ErrorPathOutsideSandbox {
+		t.Errorf("Expected a file outside the workspace to be refused, got %+v", toolErr)
+	}
+	// Every path argument is checked, not only those of the file tools
+	for _, tt := range []struct {
+		tool string
+		args map[string]interface{}
+	}{
+		{"grep_repository", map[string]interface{}{"pattern": "Retry", "path": filepath.Join(root, "web")}},
+		{"grep_repository", map[string]interface{}{"pattern": "Retry", "repository": "api", "path": "../web"}},
+		{"analyze_test_coverage", map[string]interface{}{"source_file": "api.go", "coverage_file": filepath.Join(root, "web", "web.go")}},
+		{"analyze_test_coverage", map[string]interface{}{"source_file": "api.go", "test_directory": "../web"}},
+		{"export_index", map[string]interface{}{"path": filepath.Join(root, "web", "index.tar.gz")}},
+		{"create_session", map[string]interface{}{"name": "escape", "workspace_dir": root}},
+	} {
+		if text, toolErr := call(backend, tt.tool, tt.args); toolErr == nil || toolErr.Code != types.ErrorPathOutsideSandbox {
+			t.Errorf("Expected %s with %v to be refused, got %s", tt.tool, tt.args, text)
+		}
+	}
+	if text, toolErr := call(backend, "grep_repository", map[string]interface{}{"pattern": "Retry", "path": filepath.Join(root, "api")}); toolErr != nil || !strings.Contains(text, "api.go") {
+		t.Errorf("Expected grep inside the workspace to succeed, got %s", text)
+	}
+
+	editArgs := func(path string) map[string]interface{} {
+		return map[string]interface{}{"file_path": path, "start_line": 3.0, "end_line": 3.0, "new_content": "func Retry() { panic(0) }"}
+	}
+	if _, toolErr := call(backend, "replace_lines", editArgs(webFile)); toolErr == nil || toolErr.Code != types.ErrorPathOutsideSandbox {
+		t.Errorf("Expected an edit outside the workspace to be refused, got %+v", toolErr)
+	}
+	if content, _ := os.ReadFile(webFile); strings.Contains(string(content), "panic") {
+		t.Error("Expected web.go to be unchanged")
+	}
+	if text, toolErr := call(backend, "replace_lines", editArgs(filepath.Join(root, "api", "api.go"))); toolErr != nil {
+		t.Errorf("Expected an edit inside the workspace to succeed, got %s", text)
+	}
+
+	// The session cannot move to another workspace
+	if _, toolErr := call(backend, "use_workspace", map[string]interface{}{"name": "frontend"}); toolErr == nil || toolErr.Code != types.ErrorPermissionDenied {
+		t.Errorf("Expected an isolated session to keep its workspace, got %+v", toolErr)
+	}
+
+	// A workspace directory confines the session to the repositories below it
+	web := createSession(map[string]interface{}{"name": "web-dev", "workspace_dir": filepath.Join(root, "web")})
+	if text, toolErr := call(web, "find_files", map[string]interface{}{"pattern": "*.go"}); toolErr != nil || !strings.Contains(text, "web.go") || strings.Contains(text, "api.go") {
+		t.Errorf("Expected files below the workspace directory only, got %s", text)
+	}
+
+	// Calls naming no session, and sessions when isolation is off, are not confined
+	if text, isError := callTool(t, s, "get_file_content", map[string]interface{}{"file_path": webFile}); isError {
+		t.Errorf("Expected web.go to be readable without a session, got %s", text)
+	}
+	s.config.Server.MultiSession.IsolateWorkspaces = false
+	if text, toolErr := call(backend, "get_file_content", map[string]interface{}{"file_path": webFile}); toolErr != nil {
+		t.Errorf("Expected web.go to be readable without isolation, got %s", text)
+	}
+}
@@ -2,11 +2,16 @@ package server
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/search"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
 )
 
 // Helper methods and utilities for MCP server operations
@@ -22,6 +27,75 @@ func (s *MCPServer) getBooleanValue(request mcp.CallToolRequest, key string, def
 	return defaultValue
 }
 
+// repositoryPath joins a path given to a file tool onto the named indexed
+// repository, or returns it unchanged when no repository is named or the
+// path is absolute. Either separator is accepted in path. Callers check the
+// result against the sandbox.
+func (s *MCPServer) repositoryPath(repository, path string) (string, error) {
+	path = pathutil.Local(path)
+	if repository == "" || filepath.IsAbs(path) {
+		return path, nil
+	}
+	repo, ok := s.indexer.IndexedRepository(repository)
+	if !ok {
+		return "", repoNotFoundError(repository)
+	}
+	return filepath.Join(repo.Path, path), nil
+}
+
+// getStringList reads a list argument given either as an array of strings or
+// as a single, possibly comma-separated, string
+func (s *MCPServer) getStringList(request mcp.CallToolRequest, key string) []string {
+	var values []string
+	switch value := s.getArguments(request)[key].(type) {
+	case string:
+		values = strings.Split(value, ",")
+	case []interface{}:
+		for _, item := range value {
+			if str, ok := item.(string); ok {
+				values = append(values, str)
+			}
+		}
+	}
+
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// getPage reads the cursor and page_size arguments of a paginated search
+// tool, returning the offset the page starts at and its size. The size
+// defaults to defaultSize and is capped at maxSize when that is positive.
+func (s *MCPServer) getPage(request mcp.CallToolRequest, defaultSize, maxSize int) (offset, size int, err error) {
+	offset, err = search.DecodeCursor(request.GetString("cursor", ""))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: pass the next_cursor of a previous page", err)
+	}
+
+	size = int(request.GetFloat("page_size", float64(defaultSize)))
+	if size <= 0 {
+		size = defaultSize
+	}
+	if maxSize > 0 && size > maxSize {
+		size = maxSize
+	}
+	return offset, size, nil
+}
+
+// addPage adds the paging fields of a search page to a tool response
+func addPage(response map[string]interface{}, pageSize, total int, nextCursor string) {
+	response["page_size"] = pageSize
+	response["total_hits"] = total
+	response["has_more"] = nextCursor != ""
+	if nextCursor != "" {
+		response["next_cursor"] = nextCursor
+	}
+}
+
 // getArguments extracts arguments from MCP request
 func (s *MCPServer) getArguments(request mcp.CallToolRequest) map[string]interface{} {
 	if args, ok := request.Params.Arguments.(map[string]interface{}); ok {
@@ -30,9 +104,30 @@ func (s *MCPServer) getArguments(request mcp.CallToolRequest) map[string]interfa
 	return make(map[string]interface{})
 }
 
+// directoryListOptions controls how listDirectoryContents walks a directory
+type directoryListOptions struct {
+	Recursive       bool
+	FileFilter      string // File name suffix, e.g. ".go"
+	MaxDepth        int    // Deepest level listed when recursive, 0 for no limit
+	MaxPerDirectory int    // Entries listed per directory, 0 for no limit
+	DirectoriesOnly bool
+	SortBy          string // "name" (default), "size" or "modified"
+	Descending      bool
+}
+
+// directoryListing is the full, unpaginated result of a directory walk
+type directoryListing struct {
+	Entries []map[string]interface{}
+	// Entries left out per directory because of MaxPerDirectory
+	Omitted map[string]int
+}
+
 // listDirectoryContents lists the contents of a directory with optional filtering
-func (s *MCPServer) listDirectoryContents(dirPath string, recursive bool, fileFilter string) ([]map[string]interface{}, error) {
-	var entries []map[string]interface{}
+func (s *MCPServer) listDirectoryContents(dirPath string, opts directoryListOptions) (*directoryListing, error) {
+	listing := &directoryListing{
+		Entries: []map[string]interface{}{},
+		Omitted: make(map[string]int),
+	}
 
 	// Check if directory exists
 	info, err := os.Stat(dirPath)
@@ -44,8 +139,16 @@ func (s *MCPServer) listDirectoryContents(dirPath string, recursive bool, fileFi
 		return nil, fmt.Errorf("path is not a directory: %s", dirPath)
 	}
 
+	maxDepth := opts.MaxDepth
+	if !opts.Recursive {
+		maxDepth = 1
+	}
+
+	// Entries listed so far per parent directory
+	listed := make(map[string]int)
+
 	// Walk the directory
-	walkFunc := func(path string, info os.FileInfo, err error) error {
+	walkFunc := func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -55,27 +158,49 @@ func (s *MCPServer) listDirectoryContents(dirPath string, recursive bool, fileFi
 			return nil
 		}
 
-		// If not recursive, skip subdirectories
-		if !recursive && info.IsDir() {
-			relPath, _ := filepath.Rel(dirPath, path)
-			if strings.Contains(relPath, string(filepath.Separator)) {
+		relPath, _ := filepath.Rel(dirPath, path)
+		depth := strings.Count(relPath, string(filepath.Separator)) + 1
+		if maxDepth > 0 && depth > maxDepth {
+			if d.IsDir() {
 				return filepath.SkipDir
 			}
+			return nil
 		}
 
-		// Apply file filter if specified
-		if fileFilter != "" && !info.IsDir() {
-			if !strings.HasSuffix(info.Name(), fileFilter) {
+		// Apply directory-only and file filters
+		if !d.IsDir() {
+			if opts.DirectoriesOnly {
 				return nil
 			}
+			if opts.FileFilter != "" && !strings.HasSuffix(d.Name(), opts.FileFilter) {
+				return nil
+			}
+		}
+
+		// Cap the entries listed per directory
+		parent := filepath.Dir(path)
+		if opts.MaxPerDirectory > 0 && listed[parent] >= opts.MaxPerDirectory {
+			listing.Omitted[parent]++
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		listed[parent]++
+
+		info, err := d.Info()
+		if err != nil {
+			return err
 		}
 
 		// Create entry
 		entry := map[string]interface{}{
-			"name":     info.Name(),
-			"path":     path,
-			"size":     info.Size(),
-			"modified": info.ModTime().Format("2006-01-02T15:04:05Z"),
+			"name":          info.Name(),
+			"path":          path,
+			"relative_path": filepath.ToSlash(relPath),
+			"size":          info.Size(),
+			"modified":      info.ModTime().Format("2006-01-02T15:04:05Z"),
+			"depth":         depth,
 		}
 
 		if info.IsDir() {
@@ -85,14 +210,62 @@ func (s *MCPServer) listDirectoryContents(dirPath string, recursive bool, fileFi
 			entry["language"] = s.repoMgr.GetFileLanguage(info.Name())
 		}
 
-		entries = append(entries, entry)
+		listing.Entries = append(listing.Entries, entry)
+
+		// Entries below the depth limit are not needed
+		if info.IsDir() && maxDepth > 0 && depth == maxDepth {
+			return filepath.SkipDir
+		}
 		return nil
 	}
 
-	err = filepath.Walk(dirPath, walkFunc)
+	err = filepath.WalkDir(dirPath, walkFunc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to walk directory: %w", err)
 	}
 
-	return entries, nil
+	sortDirectoryEntries(listing.Entries, opts.SortBy, opts.Descending)
+	return listing, nil
+}
+
+// sortDirectoryEntries orders listing entries by name (path order), size or
+// modification time. Ties keep path order so pages are stable.
+func sortDirectoryEntries(entries []map[string]interface{}, sortBy string, descending bool) {
+	compare := func(a, b map[string]interface{}) int {
+		switch sortBy {
+		case "size":
+			return compareInt64(a["size"].(int64), b["size"].(int64))
+		case "modified":
+			// Timestamps share one format and zone, so they order lexically
+			return strings.Compare(a["modified"].(string), b["modified"].(string))
+		default:
+			return 0
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if cmp := compare(entries[i], entries[j]); cmp != 0 {
+			if descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		cmp := strings.Compare(entries[i]["path"].(string), entries[j]["path"].(string))
+		if descending && sortBy == "name" {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+}
+
+// compareInt64 returns -1, 0 or 1 like strings.Compare
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
 }
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/my-mcp/code-indexer/internal/locking"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// indexResourceID is the resource ID of the search index
+const indexResourceID = "index"
+
+// indexReadTools are the tools that read the search index; they hold it
+// for reading while they run
+var indexReadTools = []string{
+	"search_code", "semantic_search", "find_files", "find_symbols", "complete_symbol",
+	"find_references", "run_saved_search", "export_index",
+}
+
+// indexWriteTools are the tools that replace or rewrite the index as a
+// whole; they hold it for writing while they run
+var indexWriteTools = []string{"import_index", "optimize_index", "cleanup_orphans"}
+
+// callLocksKey is the context key of the locks of a tool call
+type callLocksKey struct{}
+
+// callLocks are the locks one tool call or background run holds, all
+// under one owner so calls waiting for each other can be told apart.
+// Everything still held is released when the call returns.
+type callLocks struct {
+	owner   string
+	manager *locking.Manager
+	held    map[string]heldLock // By resource type and ID
+	mutex   sync.Mutex
+}
+
+// heldLock is a lock a call holds, renewed until it is released so long
+// calls do not lose it to expiry
+type heldLock struct {
+	lock *locking.Lock
+	stop func()
+}
+
+// newCallLocks returns the locks of a new call by owner, a connection or
+// session ID
+func (s *MCPServer) newCallLocks(owner, name string) *callLocks {
+	return &callLocks{
+		owner:   fmt.Sprintf("%s/%s#%d", owner, name, s.lockCalls.Add(1)),
+		manager: s.lockManager,
+		held:    make(map[string]heldLock),
+	}
+}
+
+// acquire takes a lock and returns the function releasing it early. A call
+// already holding the resource is not given a second lock on it, which it
+// would otherwise wait for forever.
+func (c *callLocks) acquire(ctx context.Context, resourceType locking.ResourceType, resourceID string, lockType locking.LockType) (func(), error) {
+	key := string(resourceType) + ":" + resourceID
+	c.mutex.Lock()
+	_, held := c.held[key]
+	c.mutex.Unlock()
+	if held {
+		return func() {}, nil
+	}
+
+	lock, err := c.manager.AcquireLock(ctx, resourceType, resourceID, lockType, c.owner, 0)
+	if err != nil {
+		return nil, &lockError{resourceType: resourceType, resourceID: resourceID, err: err}
+	}
+	c.mutex.Lock()
+	c.held[key] = heldLock{lock: lock, stop: c.manager.KeepAlive(ctx, lock.ID)}
+	c.mutex.Unlock()
+	return func() { c.release(key) }, nil
+}
+
+// release releases the lock held on a resource, if any
+func (c *callLocks) release(key string) {
+	c.mutex.Lock()
+	held, ok := c.held[key]
+	delete(c.held, key)
+	c.mutex.Unlock()
+	if ok {
+		held.stop()
+		c.manager.ReleaseLock(held.lock.ID)
+	}
+}
+
+// releaseAll releases every lock still held
+func (c *callLocks) releaseAll() {
+	c.mutex.Lock()
+	keys := make([]string, 0, len(c.held))
+	for key := range c.held {
+		keys = append(keys, key)
+	}
+	c.mutex.Unlock()
+	for _, key := range keys {
+		c.release(key)
+	}
+}
+
+// lockError is a lock that could not be taken
+type lockError struct {
+	resourceType locking.ResourceType
+	resourceID   string
+	err          error
+}
+
+func (e *lockError) Error() string {
+	if e.resourceType == locking.ResourceTypeIndex {
+		return fmt.Sprintf("failed to lock the index: %v", e.err)
+	}
+	return fmt.Sprintf("failed to lock %s %s: %v", e.resourceType, e.resourceID, e.err)
+}
+
+func (e *lockError) Unwrap() error {
+	return e.err
+}
+
+// lockFailed returns the result of a call that could not take a lock
+func lockFailed(err error) *mcp.CallToolResult {
+	var lockErr *lockError
+	if !errors.As(err, &lockErr) {
+		return toolErrorResult(err)
+	}
+	details := map[string]interface{}{
+		"resource_type": string(lockErr.resourceType),
+		"resource_id":   lockErr.resourceID,
+	}
+	var deadlockErr *locking.DeadlockError
+	switch {
+	case errors.As(err, &deadlockErr):
+		details["cycle"] = deadlockErr.Cycle
+		return toolError(types.ErrorDeadlock, err.Error(), details)
+	case errors.Is(err, locking.ErrLockTimeout), errors.Is(err, locking.ErrWaitQueueFull):
+		return toolError(types.ErrorLocked, err.Error(), details)
+	}
+	return toolErrorResult(err)
+}
+
+// lockResource maps a resource to the one locked for it: itself with
+// fine-grained locks, the whole index without them
+func (s *MCPServer) lockResource(resourceType locking.ResourceType, resourceID string) (locking.ResourceType, string) {
+	if s.config.Server.MultiIDE.Locking.EnableFineGrainedLocks {
+		return resourceType, resourceID
+	}
+	return locking.ResourceTypeIndex, indexResourceID
+}
+
+// lock takes a lock for the tool call of ctx, which holds it until it
+// returns unless it calls the returned function first. Runs outside tool
+// calls, such as background indexing jobs, hold the lock on their own
+// until they call it.
+func (s *MCPServer) lock(ctx context.Context, resourceType locking.ResourceType, resourceID string, lockType locking.LockType) (func(), error) {
+	if s.lockManager == nil {
+		return func() {}, nil
+	}
+	locks, ok := ctx.Value(callLocksKey{}).(*callLocks)
+	if !ok {
+		locks = s.newCallLocks("background", string(resourceType))
+	}
+	resourceType, resourceID = s.lockResource(resourceType, resourceID)
+	return locks.acquire(ctx, resourceType, resourceID, lockType)
+}
+
+// lockRepository holds an indexed repository for an indexing run, see
+// indexer.RepositoryLocker
+func (s *MCPServer) lockRepository(ctx context.Context, repoPath string) (func(), error) {
+	return s.lock(ctx, locking.ResourceTypeRepository, repoPath, locking.LockTypeWrite)
+}
+
+// lockFiles holds files for an edit: each file for writing, or for reading
+// on a dry run, and the repositories holding them for reading, so indexing
+// runs wait for the edit. Files are locked in path order, so edits of the
+// same files do not wait for each other in a cycle.
+func (s *MCPServer) lockFiles(ctx context.Context, paths []string, dryRun bool) error {
+	if s.lockManager == nil {
+		return nil
+	}
+	lockType := locking.LockTypeWrite
+	if dryRun {
+		lockType = locking.LockTypeRead
+	}
+
+	resolved := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if resolvedPath, err := s.repoMgr.ResolvePath(path); err == nil {
+			path = resolvedPath
+		}
+		resolved = append(resolved, filepath.Clean(path))
+	}
+	sort.Strings(resolved)
+
+	var repositories []string
+	for _, path := range slices.Compact(resolved) {
+		if _, err := s.lock(ctx, locking.ResourceTypeFile, path, lockType); err != nil {
+			return err
+		}
+		if repo, indexed := s.owningRepository(ctx, "", path); indexed && !slices.Contains(repositories, repo.Path) {
+			repositories = append(repositories, repo.Path)
+		}
+	}
+	for _, repoPath := range repositories {
+		if _, err := s.lock(ctx, locking.ResourceTypeRepository, filepath.Clean(repoPath), locking.LockTypeRead); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withLocks runs a tool call holding the locks it needs: the file it
+// edits, for the edit tools given a file_path, and the index, for the
+// tools that search or rewrite it. Handlers take further locks through
+// the call's context, such as rename_symbol on every file it renames in,
+// and all are released when the call returns. Runs inside withTimeout, so
+// a call answered at its time limit keeps its locks until it stops.
+func (s *MCPServer) withLocks(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if s.lockManager == nil {
+		return handler
+	}
+	isWrite := isWriteTool(name)
+	var indexLock locking.LockType
+	switch {
+	case slices.Contains(indexReadTools, name):
+		indexLock = locking.LockTypeRead
+	case slices.Contains(indexWriteTools, name):
+		indexLock = locking.LockTypeWrite
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		owner := connectionID(ctx)
+		if owner == "" {
+			owner = s.sessionForRequest(request).ID
+		}
+		locks := s.newCallLocks(owner, name)
+		defer locks.releaseAll()
+		ctx = context.WithValue(ctx, callLocksKey{}, locks)
+
+		if isWrite {
+			if filePath := request.GetString("file_path", ""); filePath != "" {
+				if path, err := s.repositoryPath(request.GetString("repository", ""), filePath); err == nil {
+					if err := s.lockFiles(ctx, []string{path}, s.getBooleanValue(request, "dry_run", false)); err != nil {
+						return lockFailed(err), nil
+					}
+				}
+			}
+		}
+		if indexLock != "" {
+			if _, err := s.lock(ctx, locking.ResourceTypeIndex, indexResourceID, indexLock); err != nil {
+				return lockFailed(err), nil
+			}
+		}
+		return handler(ctx, request)
+	}
+}
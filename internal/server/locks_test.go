@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/locking"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// newLockTestServer returns a server with a one second lock timeout and a
+// repository "app" holding a.go
+func newLockTestServer(t *testing.T, fineGrained bool) (*MCPServer, string) {
+	t.Helper()
+	repoDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoDir, "a.go"), []byte("package main\n\nfunc Retry() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{repoDir}
+		cfg.Server.MultiIDE.Locking.EnableFineGrainedLocks = fineGrained
+		cfg.Server.MultiIDE.Locking.LockTimeoutSeconds = 1
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": repoDir, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+	return s, repoDir
+}
+
+// callToolError calls a tool and returns its response and error, if any
+func callToolError(t *testing.T, s *MCPServer, name string, args map[string]interface{}) (string, *types.ToolError) {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = args
+	result, err := s.handlers[name](context.Background(), request)
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	toolErr, _ := resultError(result)
+	return resultText(result), toolErr
+}
+
+// holdLock takes a lock for another owner until the test ends or the
+// returned function is called
+func holdLock(t *testing.T, s *MCPServer, resourceType locking.ResourceType, resourceID string, lockType locking.LockType) func() {
+	t.Helper()
+	lock, err := s.lockManager.AcquireLock(context.Background(), resourceType, resourceID, lockType, "other", 0)
+	if err != nil {
+		t.Fatalf("Failed to lock %s %s: %v", resourceType, resourceID, err)
+	}
+	released := false
+	release := func() {
+		if !released {
+			released = true
+			s.lockManager.ReleaseLock(lock.ID)
+		}
+	}
+	t.Cleanup(release)
+	return release
+}
+
+func TestToolLocks(t *testing.T) {
+	s, repoDir := newLockTestServer(t, true)
+	file, err := s.repoMgr.ResolvePath(filepath.Join(repoDir, "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, _ := s.indexer.IndexedRepository("app")
+	editArgs := map[string]interface{}{"file_path": file, "start_line": 3.0, "end_line": 3.0, "new_content": "func Retry() { panic(0) }"}
+
+	// An edit waits for the file until the lock timeout
+	release := holdLock(t, s, locking.ResourceTypeFile, file, locking.LockTypeWrite)
+	_, toolErr := callToolError(t, s, "replace_lines", editArgs)
+	if toolErr == nil || toolErr.Code != types.ErrorLocked || toolErr.Details["resource_type"] != string(locking.ResourceTypeFile) || !toolErr.Retryable {
+		t.Fatalf("Expected LOCKED for the file, got %+v", toolErr)
+	}
+	release()
+	if text, toolErr := callToolError(t, s, "replace_lines", editArgs); toolErr != nil {
+		t.Fatalf("Expected the edit to be written once the file was released, got %s", text)
+	}
+	if total := s.lockManager.GetLockStats()["total_locks"]; total != 0 {
+		t.Errorf("Expected the edit to release its locks, got %v held", total)
+	}
+
+	// Indexing waits for edits of the repository, and edits for indexing
+	release = holdLock(t, s, locking.ResourceTypeRepository, filepath.Clean(repo.Path), locking.LockTypeRead)
+	if _, toolErr := callToolError(t, s, "index_repository", map[string]interface{}{"path": repoDir, "name": "app"}); toolErr == nil || toolErr.Code != types.ErrorLocked {
+		t.Errorf("Expected indexing to time out waiting for the repository, got %+v", toolErr)
+	}
+	release()
+	holdLock(t, s, locking.ResourceTypeRepository, filepath.Clean(repo.Path), locking.LockTypeWrite)
+	if _, toolErr := callToolError(t, s, "delete_lines", map[string]interface{}{"file_path": file, "start_line": 1.0, "end_line": 1.0}); toolErr == nil || toolErr.Details["resource_type"] != string(locking.ResourceTypeRepository) {
+		t.Errorf("Expected LOCKED for the repository, got %+v", toolErr)
+	}
+	if _, toolErr := callToolError(t, s, "delete_lines", map[string]interface{}{"file_path": file, "start_line": 1.0, "end_line": 1.0, "dry_run": true}); toolErr == nil {
+		t.Error("Expected dry runs to wait for the repository as well")
+	}
+
+	// Searches read the index while nothing rewrites it
+	holdLock(t, s, locking.ResourceTypeIndex, indexResourceID, locking.LockTypeWrite)
+	if _, toolErr := callToolError(t, s, "search_code", map[string]interface{}{"query": "Retry"}); toolErr == nil || toolErr.Code != types.ErrorLocked {
+		t.Errorf("Expected LOCKED for the index, got %+v", toolErr)
+	}
+}
+
+func TestCoarseToolLocks(t *testing.T) {
+	s, repoDir := newLockTestServer(t, false)
+
+	// Without fine-grained locks, edits take the whole index
+	holdLock(t, s, locking.ResourceTypeIndex, indexResourceID, locking.LockTypeRead)
+	_, toolErr := callToolError(t, s, "insert_at_line", map[string]interface{}{"file_path": filepath.Join(repoDir, "a.go"), "line": 1.0, "content": "// a"})
+	if toolErr == nil || toolErr.Code != types.ErrorLocked || toolErr.Details["resource_type"] != string(locking.ResourceTypeIndex) {
+		t.Errorf("Expected LOCKED for the index, got %+v", toolErr)
+	}
+	if _, toolErr := callToolError(t, s, "search_code", map[string]interface{}{"query": "Retry"}); toolErr != nil {
+		t.Errorf("Expected searches to share the index, got %+v", toolErr)
+	}
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/logging"
+)
+
+// log returns the server's logger with the request ID of ctx
+func (s *MCPServer) log(ctx context.Context) *zap.Logger {
+	return logging.With(ctx, s.logger)
+}
+
+// withRequestID gives each tool call a request ID, the one of the HTTP
+// request that carried it or a new one over stdio and WebSocket, so the log
+// lines of a call can be told apart from those of calls running beside it,
+// and logs the call when it is done
+func (s *MCPServer) withRequestID(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if logging.RequestID(ctx) == "" {
+			ctx = logging.WithRequestID(ctx, logging.NewRequestID())
+		}
+		logger := s.log(ctx)
+		logger.Debug("Tool call started", zap.String("tool", name))
+
+		started := time.Now()
+		result, err := handler(ctx, request)
+		fields := []zap.Field{zap.String("tool", name), zap.Duration("duration", time.Since(started))}
+		if toolErr, ok := resultError(result); ok {
+			fields = append(fields, zap.String("error_code", toolErr.Code))
+		}
+		logger.Debug("Tool call finished", fields...)
+		return result, err
+	}
+}
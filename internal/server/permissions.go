@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/auth"
+	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// commandTools are the tools that run commands in a repository; profiles
+// treat them like the tools that modify files
+var commandTools = []string{"run_tests"}
+
+// indexTools are the tools that index repositories or refresh their index
+var indexTools = []string{
+	"index_repository", "refresh_index", "switch_ref", "index_dependencies", "sync_configured_repositories", "cancel_indexing", "verify_index",
+}
+
+// adminTools are the tools that remove repositories and projects, replace
+// the index, restart the language servers or change the log level
+var adminTools = []string{
+	"remove_repository", "remove_project", "cleanup_orphans", "optimize_index",
+	"export_index", "import_index", "restart_language_server", "set_log_level",
+}
+
+// toolGroup returns the permission group of a tool, which profiles name as
+// @read, @write, @index or @admin
+func toolGroup(name string) string {
+	switch {
+	case isWriteTool(name), slices.Contains(commandTools, name):
+		return auth.GroupWrite
+	case slices.Contains(indexTools, name):
+		return auth.GroupIndex
+	case slices.Contains(adminTools, name):
+		return auth.GroupAdmin
+	}
+	return auth.GroupRead
+}
+
+// callProfiles returns the permission profiles a tool call must satisfy:
+// the profile of its API key, or the default profile without one, and the
+// profile of the session it names, if the session was given one. Sessions
+// are bound to the key that created them: calls with the key that do not
+// name one of its sessions must satisfy the profiles of all of them, so
+// leaving out session_id cannot shed a session's profile
+func (s *MCPServer) callProfiles(ctx context.Context, request mcp.CallToolRequest) []*auth.Profile {
+	profile := s.permissions.Default()
+	key, authenticated := auth.FromContext(ctx)
+	if authenticated {
+		profile = s.permissions.KeyProfile(key)
+	}
+	profiles := []*auth.Profile{profile}
+	if s.sessionManager == nil {
+		return profiles
+	}
+
+	var named *session.Session
+	if sessionID := request.GetString("session_id", ""); sessionID != "" {
+		if sess, err := s.sessionManager.GetSession(sessionID); err == nil {
+			named = sess
+			profiles = s.appendSessionProfile(profiles, sess)
+		}
+	}
+	if authenticated && key.Name != "" && (named == nil || named.Owner != key.Name) {
+		for _, sess := range s.sessionManager.ListSessions() {
+			if sess.Owner == key.Name {
+				profiles = s.appendSessionProfile(profiles, sess)
+			}
+		}
+	}
+	return profiles
+}
+
+// appendSessionProfile adds the profile of a session, if it was given one
+func (s *MCPServer) appendSessionProfile(profiles []*auth.Profile, sess *session.Session) []*auth.Profile {
+	if sessionProfile, ok := s.permissions.Profile(sess.ProfileName()); ok {
+		return append(profiles, sessionProfile)
+	}
+	return profiles
+}
+
+// deniedBy returns the first profile of a call that does not allow a tool
+// of a group, or nil when all of them do
+func (s *MCPServer) deniedBy(ctx context.Context, request mcp.CallToolRequest, name, group string) *auth.Profile {
+	for _, profile := range s.callProfiles(ctx, request) {
+		if !profile.Allows(name, group) {
+			return profile
+		}
+	}
+	return nil
+}
+
+// permissionDenied returns the result of a call refused by a profile
+func permissionDenied(name, group string, profile *auth.Profile) *mcp.CallToolResult {
+	return toolError(types.ErrorPermissionDenied,
+		fmt.Sprintf("%s is not allowed by permission profile %q", name, profile.Name),
+		map[string]interface{}{"tool": name, "group": group, "profile": profile.Name})
+}
+
+// withPermissions refuses a tool to calls whose API key or session profile
+// does not allow it
+func (s *MCPServer) withPermissions(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	group := toolGroup(name)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if profile := s.deniedBy(ctx, request, name, group); profile != nil {
+			s.log(ctx).Warn("Tool call denied by permission profile",
+				zap.String("tool", name),
+				zap.String("group", group),
+				zap.String("profile", profile.Name))
+			return permissionDenied(name, group, profile), nil
+		}
+		return handler(ctx, request)
+	}
+}
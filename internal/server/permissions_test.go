@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/auth"
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestPermissionProfiles(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.Permissions.Profiles = map[string]config.PermissionProfileConfig{
+			"ci": {Allow: []string{"@read", "run_tests"}},
+		}
+	})
+
+	call := func(ctx context.Context, name string, args map[string]interface{}) *types.ToolError {
+		t.Helper()
+		var request mcp.CallToolRequest
+		request.Params.Name = name
+		request.Params.Arguments = args
+		result, err := s.handlers[name](ctx, request)
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		toolErr, _ := resultError(result)
+		return toolErr
+	}
+	denied := func(toolErr *types.ToolError) bool {
+		return toolErr != nil && toolErr.Code == types.ErrorPermissionDenied
+	}
+
+	// A key's profile refuses edits and removals but not searches
+	ci := auth.WithKey(context.Background(), &auth.Key{Name: "ci", Scope: auth.ScopeWrite, Profile: "ci"})
+	editArgs := map[string]interface{}{"file_path": "main.go", "start_line": 1.0, "end_line": 1.0, "new_content": "x"}
+	if toolErr := call(ci, "replace_lines", editArgs); !denied(toolErr) || toolErr.Details["profile"] != "ci" {
+		t.Errorf("Expected replace_lines to be denied to ci, got %+v", toolErr)
+	}
+	if toolErr := call(ci, "remove_project", map[string]interface{}{"name": "web"}); !denied(toolErr) {
+		t.Errorf("Expected remove_project to be denied to ci, got %+v", toolErr)
+	}
+	if toolErr := call(ci, "list_repositories", nil); toolErr != nil {
+		t.Errorf("Expected list_repositories to be allowed to ci, got %+v", toolErr)
+	}
+
+	// Read keys without a profile are read-only; calls without a key get
+	// the default profile, admin
+	reader := auth.WithKey(context.Background(), &auth.Key{Name: "reader", Scope: auth.ScopeRead})
+	if toolErr := call(reader, "replace_lines", editArgs); !denied(toolErr) {
+		t.Errorf("Expected replace_lines to be denied to a read key, got %+v", toolErr)
+	}
+	if toolErr := call(context.Background(), "replace_lines", editArgs); denied(toolErr) {
+		t.Errorf("Expected replace_lines to be allowed without a key, got %+v", toolErr)
+	}
+
+	// A session's profile narrows the caller's
+	sess, err := s.sessionManager.CreateSession("ci", "")
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	sess.SetProfile(auth.ProfileReadOnly)
+	sessionArgs := map[string]interface{}{"session_id": sess.ID, "file_path": "main.go", "start_line": 1.0, "end_line": 1.0, "new_content": "x"}
+	if toolErr := call(context.Background(), "replace_lines", sessionArgs); !denied(toolErr) || toolErr.Details["profile"] != auth.ProfileReadOnly {
+		t.Errorf("Expected replace_lines to be denied in a read-only session, got %+v", toolErr)
+	}
+
+	// A session is bound to the key that created it: leaving out its
+	// session_id does not shed its profile
+	deploy := auth.WithKey(context.Background(), &auth.Key{Name: "deploy", Scope: auth.ScopeWrite})
+	w := httptest.NewRecorder()
+	s.handleSessionsAPI(w, apiRequest("/api/sessions", `{"name": "deploy", "profile": "read-only"}`).WithContext(deploy))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the session to be created, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	s.handleToolCall(w, apiRequest("/api/call", `{"tool": "remove_project", "arguments": {"project_name": "web"}}`).WithContext(deploy))
+	var callResponse struct {
+		Error *types.ToolError `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &callResponse); err != nil {
+		t.Fatalf("Failed to decode /api/call: %v", err)
+	}
+	if !denied(callResponse.Error) || callResponse.Error.Details["profile"] != auth.ProfileReadOnly {
+		t.Errorf("Expected remove_project without session_id to be denied to deploy, got %s", w.Body.String())
+	}
+	if toolErr := call(ci, "list_repositories", nil); toolErr != nil {
+		t.Errorf("Expected other keys to keep their profile, got %+v", toolErr)
+	}
+
+	text, isError := callTool(t, s, "create_session", map[string]interface{}{"name": "robot", "profile": "robot"})
+	if !isError {
+		t.Errorf("Expected an unknown session profile to be rejected, got %s", text)
+	}
+
+	// /api/tools lists only the tools the key may call
+	w = httptest.NewRecorder()
+	s.handleToolsAPI(w, httptest.NewRequest(http.MethodGet, "/api/tools", nil).WithContext(ci))
+	var response struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode /api/tools: %v", err)
+	}
+	listed := make(map[string]bool)
+	for _, tool := range response.Tools {
+		listed[tool.Name] = true
+	}
+	if !listed["search_code"] || !listed["run_tests"] || listed["replace_lines"] || listed["remove_project"] {
+		t.Errorf("Unexpected tools listed for ci: %v", listed)
+	}
+}
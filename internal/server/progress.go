@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/indexer"
+	"github.com/my-mcp/code-indexer/internal/models"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// progressInterval is the shortest time between two progress notifications
+// of a tool call
+const progressInterval = 250 * time.Millisecond
+
+// progressNotifier pushes the indexing progress of one tool call to the
+// client as notifications/progress. The protocol requires the progress
+// value to increase with every notification, so it counts on across the
+// repositories a call indexes: each clone message advances it by one and
+// each file indexed by one, and total is the value the current repository
+// ends at.
+type progressNotifier struct {
+	s         *MCPServer
+	mcpServer *server.MCPServer
+	ctx       context.Context
+	token     mcp.ProgressToken
+
+	mutex    sync.Mutex
+	lastSent time.Time
+	base     float64 // Value reached before the current repository
+	progress float64 // Last value sent
+}
+
+// newProgressNotifier returns a notifier for the tool call, or nil when it
+// carries no progress token or the transport cannot push notifications.
+// Stdio, Streamable HTTP and the daemon WebSocket can.
+func (s *MCPServer) newProgressNotifier(ctx context.Context, request mcp.CallToolRequest) *progressNotifier {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return nil
+	}
+	return &progressNotifier{s: s, mcpServer: mcpServer, ctx: ctx, token: request.Params.Meta.ProgressToken}
+}
+
+// withIndexingProgress returns a context under which indexing progress of a
+// single repository is pushed to the client, when it asked for progress
+func (s *MCPServer) withIndexingProgress(ctx context.Context, request mcp.CallToolRequest) context.Context {
+	return s.newProgressNotifier(ctx, request).repository(ctx)
+}
+
+// repository returns a context under which the progress of indexing the
+// next repository is pushed, counting on from the previous ones. A nil
+// notifier returns ctx unchanged.
+func (n *progressNotifier) repository(ctx context.Context) context.Context {
+	if n == nil {
+		return ctx
+	}
+	n.mutex.Lock()
+	n.base = n.progress
+	n.mutex.Unlock()
+
+	cloneMessages := 0
+	return indexer.WithProgress(ctx, func(progress types.IndexingProgress) {
+		n.mutex.Lock()
+		defer n.mutex.Unlock()
+
+		var value, total float64
+		message := fmt.Sprintf("%s: %s %d/%d files", progress.Repository, progress.Status, progress.FilesProcessed, progress.TotalFiles)
+		if progress.Status == "cloning" {
+			cloneMessages++
+			value = n.base + float64(cloneMessages)
+			message = fmt.Sprintf("%s: %s", progress.Repository, progress.Message)
+		} else {
+			value = n.base + float64(cloneMessages+progress.FilesProcessed)
+			if progress.TotalFiles > 0 {
+				total = n.base + float64(cloneMessages+progress.TotalFiles)
+			}
+		}
+
+		if value <= n.progress || (progress.Status != "completed" && time.Since(n.lastSent) < progressInterval) {
+			return
+		}
+		n.lastSent = time.Now()
+		n.progress = value
+		n.send(value, total, message)
+	})
+}
+
+// withModelStream returns a context under which the text a model writes
+// for the tool call is pushed to the client as it is written, when it asked
+// for progress. Each notifications/progress carries the text written since
+// the previous one as its message, at most one every progressInterval, and
+// counts the characters written so far as its progress. The returned
+// function pushes the text still pending and is called once the model is
+// done, before the result is returned.
+func (s *MCPServer) withModelStream(ctx context.Context, request mcp.CallToolRequest) (context.Context, func()) {
+	n := s.newProgressNotifier(ctx, request)
+	if n == nil {
+		return ctx, func() {}
+	}
+
+	var pending strings.Builder
+	written := 0
+	flush := func() {
+		if pending.Len() == 0 {
+			return
+		}
+		written += utf8.RuneCountInString(pending.String())
+		n.lastSent = time.Now()
+		n.progress = float64(written)
+		n.send(n.progress, 0, pending.String())
+		pending.Reset()
+	}
+	ctx = models.WithStream(ctx, func(text string) {
+		n.mutex.Lock()
+		defer n.mutex.Unlock()
+		pending.WriteString(text)
+		if time.Since(n.lastSent) >= progressInterval {
+			flush()
+		}
+	})
+	return ctx, func() {
+		n.mutex.Lock()
+		defer n.mutex.Unlock()
+		flush()
+	}
+}
+
+// send pushes one notifications/progress to the client. The caller holds
+// the mutex.
+func (n *progressNotifier) send(value, total float64, message string) {
+	params := map[string]any{
+		"progressToken": n.token,
+		"progress":      value,
+		"message":       message,
+	}
+	if total > 0 {
+		params["total"] = total
+	}
+	if err := n.mcpServer.SendNotificationToClient(n.ctx, "notifications/progress", params); err != nil {
+		n.s.logger.Debug("Failed to send progress notification", zap.Error(err))
+	}
+}
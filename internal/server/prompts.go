@@ -0,0 +1,429 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/parser"
+	"github.com/my-mcp/code-indexer/internal/quality"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// MCP prompts are ready-made requests for common codebase workflows, filled
+// with context read from the indexed repositories. The context is gathered
+// by the tools themselves, with the same permissions, sandbox, quotas and
+// locks as a direct call.
+
+// Limits of the context a prompt is filled with
+const (
+	promptMaxTokens      = 8000 // Token budget of the context of a prompt
+	promptOutlineFiles   = 10   // Files of a directory outlined by explain_module
+	promptListingEntries = 200  // Entries of a directory listed by explain_module
+	promptListingDepth   = 3
+)
+
+// registerPrompts registers the prompt templates
+func (s *MCPServer) registerPrompts() {
+	s.server.AddPrompt(mcp.NewPrompt("review_file",
+		mcp.WithPromptDescription("Review a file for bugs, readability and security, given its content and the code smells and hardcoded secrets found in it"),
+		mcp.WithArgument("file_path",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("File to review"),
+		),
+		mcp.WithArgument("repository",
+			mcp.ArgumentDescription("Repository the path is relative to (optional)"),
+		),
+		mcp.WithArgument("focus",
+			mcp.ArgumentDescription("What to pay most attention to, such as error handling or performance (optional)"),
+		),
+	), s.handleReviewFilePrompt)
+
+	s.server.AddPrompt(mcp.NewPrompt("explain_module",
+		mcp.WithPromptDescription("Explain what a directory or file does and how it is organized, given its layout, symbol outlines and README"),
+		mcp.WithArgument("path",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("Directory or file to explain"),
+		),
+		mcp.WithArgument("repository",
+			mcp.ArgumentDescription("Repository the path is relative to (optional)"),
+		),
+	), s.handleExplainModulePrompt)
+
+	s.server.AddPrompt(mcp.NewPrompt("write_tests",
+		mcp.WithPromptDescription("Write unit tests for a symbol, given its code, what it calls, its callers and the existing tests of its file"),
+		mcp.WithArgument("symbol_name",
+			mcp.RequiredArgument(),
+			mcp.ArgumentDescription("Function, method or class to test"),
+		),
+		mcp.WithArgument("repository",
+			mcp.ArgumentDescription("Repository to look the symbol up in (optional)"),
+		),
+		mcp.WithArgument("framework",
+			mcp.ArgumentDescription("Test framework to use (default: the one the existing tests use)"),
+		),
+	), s.handleWriteTestsPrompt)
+}
+
+// handleReviewFilePrompt fills the review_file prompt
+func (s *MCPServer) handleReviewFilePrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args := request.Params.Arguments
+	filePath, err := requirePromptArgument(args, "file_path")
+	if err != nil {
+		return nil, err
+	}
+	repository := args["repository"]
+
+	var file struct {
+		FilePath string `json:"file_path"`
+		Content  string `json:"content"`
+		Language string `json:"language"`
+	}
+	if err := s.callToolJSON(ctx, "get_file_content", map[string]interface{}{
+		"file_path":  filePath,
+		"repository": repository,
+	}, &file); err != nil {
+		return nil, err
+	}
+
+	prompt := newPromptBuilder(promptMaxTokens)
+	prompt.instructions(fmt.Sprintf("Review %s. Point out bugs, unclear code and security problems, citing line numbers, and suggest concrete fixes. Say so when the code looks fine rather than inventing issues.", file.FilePath))
+	if focus := strings.TrimSpace(args["focus"]); focus != "" {
+		prompt.instructions("Focus on " + focus + ".")
+	}
+
+	// Findings are context only; languages the detectors do not support
+	// are reviewed without them
+	var smells struct {
+		Smells []quality.Smell `json:"smells"`
+	}
+	if err := s.callToolJSON(ctx, "detect_code_smells", map[string]interface{}{
+		"file_path":  filePath,
+		"repository": repository,
+	}, &smells); err != nil {
+		s.log(ctx).Debug("No code smells for the review prompt", zap.String("file", filePath), zap.Error(err))
+	}
+	if len(smells.Smells) > 0 {
+		var lines []string
+		for _, smell := range smells.Smells {
+			lines = append(lines, fmt.Sprintf("- Lines %d-%d, %s %s: %s", smell.StartLine, smell.EndLine, smell.Severity, smell.Type, smell.Message))
+		}
+		prompt.section("Code smells found", strings.Join(lines, "\n"), "")
+	}
+
+	var secrets struct {
+		Findings []types.SecurityFinding `json:"findings"`
+	}
+	if err := s.callToolJSON(ctx, "detect_security_issues", map[string]interface{}{
+		"file_path":  filePath,
+		"repository": repository,
+	}, &secrets); err != nil {
+		s.log(ctx).Debug("No security findings for the review prompt", zap.String("file", filePath), zap.Error(err))
+	}
+	if len(secrets.Findings) > 0 {
+		var lines []string
+		for _, finding := range secrets.Findings {
+			lines = append(lines, fmt.Sprintf("- Line %d, %s %s: %s (%s)", finding.Line, finding.Severity, finding.Rule, finding.Description, finding.Redacted))
+		}
+		prompt.section("Hardcoded secrets found", strings.Join(lines, "\n"), "")
+	}
+
+	prompt.section(file.FilePath, numberLines(file.Content), file.Language)
+	return prompt.result(fmt.Sprintf("Review of %s", file.FilePath)), nil
+}
+
+// handleExplainModulePrompt fills the explain_module prompt
+func (s *MCPServer) handleExplainModulePrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args := request.Params.Arguments
+	modulePath, err := requirePromptArgument(args, "path")
+	if err != nil {
+		return nil, err
+	}
+	repository := args["repository"]
+
+	fullPath, err := s.repositoryPath(repository, modulePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, types.NewToolError(types.ErrorNotFound, fmt.Sprintf("%s does not exist", modulePath),
+			map[string]interface{}{"path": modulePath})
+	}
+	modulePath = pathutil.ToSlash(modulePath)
+
+	prompt := newPromptBuilder(promptMaxTokens)
+	prompt.instructions(fmt.Sprintf("Explain what %s does: its purpose, its main types and functions and how they fit together, and where a newcomer should start reading. Base the explanation on the context below and say what it does not show.", modulePath))
+	if !info.IsDir() {
+		s.outlineSection(ctx, prompt, repository, modulePath)
+		var file struct {
+			Content  string `json:"content"`
+			Language string `json:"language"`
+		}
+		if err := s.callToolJSON(ctx, "get_file_content", map[string]interface{}{
+			"file_path":  modulePath,
+			"repository": repository,
+		}, &file); err != nil {
+			return nil, err
+		}
+		prompt.section(modulePath, file.Content, file.Language)
+		return prompt.result(fmt.Sprintf("Explanation of %s", modulePath)), nil
+	}
+
+	var listing struct {
+		Entries []struct {
+			Name         string `json:"name"`
+			RelativePath string `json:"relative_path"`
+			Type         string `json:"type"`
+			Language     string `json:"language"`
+		} `json:"entries"`
+		HasMore bool `json:"has_more"`
+	}
+	if err := s.callToolJSON(ctx, "list_directory", map[string]interface{}{
+		"directory_path": modulePath,
+		"repository":     repository,
+		"recursive":      true,
+		"max_depth":      float64(promptListingDepth),
+		"limit":          float64(promptListingEntries),
+	}, &listing); err != nil {
+		return nil, err
+	}
+
+	var layout, readme string
+	var sources []string
+	for _, entry := range listing.Entries {
+		if entry.Type == "directory" {
+			layout += entry.RelativePath + "/\n"
+			continue
+		}
+		layout += entry.RelativePath + "\n"
+		switch {
+		case readme == "" && !strings.Contains(entry.RelativePath, "/") && strings.HasPrefix(strings.ToLower(entry.Name), "readme"):
+			readme = entry.RelativePath
+		case entry.Language != "" && parser.NewTreeSitterParser(entry.Language) != nil:
+			sources = append(sources, entry.RelativePath)
+		}
+	}
+	if listing.HasMore {
+		layout += "... (more entries not listed)\n"
+	}
+	prompt.section("Layout of "+modulePath, strings.TrimSuffix(layout, "\n"), "")
+
+	if readme != "" {
+		var file struct {
+			Content string `json:"content"`
+		}
+		if err := s.callToolJSON(ctx, "get_file_content", map[string]interface{}{
+			"file_path":  path.Join(modulePath, readme),
+			"repository": repository,
+		}, &file); err == nil {
+			prompt.section(path.Join(modulePath, readme), file.Content, "markdown")
+		}
+	}
+
+	// Outlines of the files nearest the top of the module
+	if len(sources) > promptOutlineFiles {
+		sources = sources[:promptOutlineFiles]
+	}
+	for _, source := range sources {
+		s.outlineSection(ctx, prompt, repository, path.Join(modulePath, source))
+	}
+	return prompt.result(fmt.Sprintf("Explanation of %s", modulePath)), nil
+}
+
+// outlineSection adds the symbol outline of a file to a prompt, if it has
+// one
+func (s *MCPServer) outlineSection(ctx context.Context, prompt *promptBuilder, repository, filePath string) {
+	var outline struct {
+		Symbols []parser.OutlineNode `json:"symbols"`
+	}
+	if err := s.callToolJSON(ctx, "get_file_outline", map[string]interface{}{
+		"file_path":  filePath,
+		"repository": repository,
+	}, &outline); err != nil {
+		s.log(ctx).Debug("No outline for the prompt", zap.String("file", filePath), zap.Error(err))
+		return
+	}
+	if len(outline.Symbols) == 0 {
+		return
+	}
+	var lines []string
+	var add func(nodes []parser.OutlineNode, depth int)
+	add = func(nodes []parser.OutlineNode, depth int) {
+		for _, node := range nodes {
+			line := fmt.Sprintf("%s- %s %s (lines %d-%d)", strings.Repeat("  ", depth), node.Kind, node.Name, node.StartLine, node.EndLine)
+			if node.DocString != "" {
+				line += ": " + firstLine(node.DocString)
+			}
+			lines = append(lines, line)
+			add(node.Children, depth+1)
+		}
+	}
+	add(outline.Symbols, 0)
+	prompt.section("Outline of "+filePath, strings.Join(lines, "\n"), "")
+}
+
+// handleWriteTestsPrompt fills the write_tests prompt
+func (s *MCPServer) handleWriteTestsPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args := request.Params.Arguments
+	symbolName, err := requirePromptArgument(args, "symbol_name")
+	if err != nil {
+		return nil, err
+	}
+	repository := args["repository"]
+
+	// Half the budget for the symbol and its neighbours, the rest for the
+	// existing tests
+	var bundle struct {
+		Items []contextItem `json:"items"`
+		Text  string        `json:"context"`
+	}
+	if err := s.callToolJSON(ctx, "get_context_bundle", map[string]interface{}{
+		"symbol_name": symbolName,
+		"repository":  repository,
+		"max_tokens":  float64(promptMaxTokens / 2),
+	}, &bundle); err != nil {
+		return nil, err
+	}
+	var target contextItem
+	for _, item := range bundle.Items {
+		if item.Kind == "symbol" {
+			target = item
+			break
+		}
+	}
+
+	framework := strings.TrimSpace(args["framework"])
+	if framework == "" {
+		framework = "the test framework and style of the existing tests"
+	}
+	prompt := newPromptBuilder(promptMaxTokens)
+	prompt.instructions(fmt.Sprintf("Write unit tests for %s using %s. Cover its normal behaviour, edge cases and error paths, place the tests where this repository keeps them and only use functions the context shows exist.", symbolName, framework))
+	prompt.section("Context of "+symbolName, bundle.Text, "")
+
+	if target.FilePath == "" {
+		return prompt.result(fmt.Sprintf("Tests for %s", symbolName)), nil
+	}
+	var coverage struct {
+		TestFiles []struct {
+			FilePath string `json:"file_path"`
+		} `json:"test_files"`
+	}
+	if err := s.callToolJSON(ctx, "analyze_test_coverage", map[string]interface{}{
+		"source_file": target.FilePath,
+		"repository":  target.Repository,
+	}, &coverage); err != nil {
+		s.log(ctx).Debug("No test coverage for the prompt", zap.String("file", target.FilePath), zap.Error(err))
+	}
+	if len(coverage.TestFiles) == 0 {
+		prompt.instructions(fmt.Sprintf("%s has no tests yet.", target.FilePath))
+		return prompt.result(fmt.Sprintf("Tests for %s", symbolName)), nil
+	}
+	var tests struct {
+		FilePath string `json:"file_path"`
+		Content  string `json:"content"`
+		Language string `json:"language"`
+	}
+	if err := s.callToolJSON(ctx, "get_file_content", map[string]interface{}{
+		"file_path":  coverage.TestFiles[0].FilePath,
+		"repository": target.Repository,
+	}, &tests); err == nil {
+		prompt.section("Existing tests in "+tests.FilePath, tests.Content, tests.Language)
+	}
+	return prompt.result(fmt.Sprintf("Tests for %s", symbolName)), nil
+}
+
+// requirePromptArgument returns a required argument of a prompt
+func requirePromptArgument(args map[string]string, name string) (string, error) {
+	value := strings.TrimSpace(args[name])
+	if value == "" {
+		return "", types.NewToolError(types.ErrorInvalidArgument, fmt.Sprintf("%s is required", name),
+			map[string]interface{}{"argument": name})
+	}
+	return value, nil
+}
+
+// promptBuilder writes the text of a prompt: instructions first, then
+// sections of context cut to a token budget, in the order they are added
+type promptBuilder struct {
+	instructionText []string
+	sections        []string
+	tokens          int      // Left in the budget
+	omitted         []string // Titles of the sections that did not fit
+}
+
+// newPromptBuilder returns a builder for a prompt whose context is limited
+// to budget tokens
+func newPromptBuilder(budget int) *promptBuilder {
+	return &promptBuilder{tokens: budget}
+}
+
+// instructions adds a sentence to the instructions of the prompt
+func (b *promptBuilder) instructions(text string) {
+	b.instructionText = append(b.instructionText, text)
+}
+
+// section adds a titled section of context, fenced as code in language
+// when that is given. A section larger than the budget left is cut at a
+// line; one that does not fit at all is left out and noted.
+func (b *promptBuilder) section(title, body, language string) {
+	const minTokens = 50 // Smaller remains are not worth a cut section
+	body = strings.TrimRight(body, "\n")
+	if body == "" {
+		return
+	}
+	if b.tokens < minTokens {
+		b.omitted = append(b.omitted, title)
+		return
+	}
+	if estimateTokens(body) > b.tokens {
+		cut := body[:b.tokens*4]
+		if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+			cut = cut[:i]
+		}
+		body = cut + "\n... (cut to fit the prompt)"
+	}
+	b.tokens -= estimateTokens(body)
+
+	text := "## " + title + "\n\n"
+	if language != "" {
+		text += "```" + language + "\n" + body + "\n```"
+	} else {
+		text += body
+	}
+	b.sections = append(b.sections, text)
+}
+
+// result returns the prompt as a single user message
+func (b *promptBuilder) result(description string) *mcp.GetPromptResult {
+	parts := append([]string{strings.Join(b.instructionText, " ")}, b.sections...)
+	if len(b.omitted) > 0 {
+		parts = append(parts, fmt.Sprintf("Left out to fit the prompt: %s. Read them with the file tools if they matter.", strings.Join(b.omitted, ", ")))
+	}
+	return mcp.NewGetPromptResult(description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(strings.Join(parts, "\n\n"))),
+	})
+}
+
+// numberLines prefixes every line of content with its number, so a review
+// can cite them
+func numberLines(content string) string {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	width := len(fmt.Sprint(len(lines)))
+	for i, line := range lines {
+		lines[i] = fmt.Sprintf("%*d  %s", width, i+1, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// firstLine returns the first line of text
+func firstLine(text string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	return line
+}
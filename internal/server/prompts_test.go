@@ -0,0 +1,115 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// getPrompt fills a prompt and returns the text of its message
+func getPrompt(t *testing.T, s *MCPServer, name string, args map[string]string) string {
+	t.Helper()
+	result, errMessage := resourceRequest(t, s, "prompts/get", map[string]interface{}{"name": name, "arguments": args})
+	if errMessage != "" {
+		t.Fatalf("Getting prompt %s failed: %s", name, errMessage)
+	}
+	var prompt struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(result, &prompt); err != nil || len(prompt.Messages) != 1 || prompt.Messages[0].Role != "user" {
+		t.Fatalf("Invalid prompt %s: %v: %s", name, err, result)
+	}
+	return prompt.Messages[0].Content.Text
+}
+
+func TestPrompts(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"src/README.md":      "# Utilities\n\nSmall helpers.\n",
+		"src/util.go":        "package src\n\n// Helper doubles n\nfunc Helper(n int) int {\n\treturn n * 2\n}\n",
+		"src/util_test.go":   "package src\n\nimport \"testing\"\n\nfunc TestExisting(t *testing.T) {}\n",
+		"src/deep/config.go": "package deep\n\n// Load reads the config\nfunc Load() {}\n",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	result, errMessage := resourceRequest(t, s, "prompts/list", nil)
+	if errMessage != "" {
+		t.Fatalf("prompts/list failed: %s", errMessage)
+	}
+	for _, name := range []string{"review_file", "explain_module", "write_tests"} {
+		if !strings.Contains(string(result), `"name":"`+name+`"`) {
+			t.Errorf("Expected prompt %s to be listed, got %s", name, result)
+		}
+	}
+
+	text := getPrompt(t, s, "review_file", map[string]string{"file_path": `src\util.go`, "repository": "app", "focus": "overflow"})
+	for _, want := range []string{"Review src/util.go", "Focus on overflow.", "## src/util.go", "```go", "5  \treturn n * 2"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the review prompt to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	text = getPrompt(t, s, "explain_module", map[string]string{"path": "src", "repository": "app"})
+	for _, want := range []string{"## Layout of src", "deep/\n", "deep/config.go", "# Utilities", "## Outline of src/util.go", "Helper (lines 4-6): Helper doubles n", "## Outline of src/deep/config.go"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the module prompt to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	text = getPrompt(t, s, "write_tests", map[string]string{"symbol_name": "Helper", "repository": "app"})
+	for _, want := range []string{"Write unit tests for Helper", "return n * 2", "## Existing tests in src/util_test.go", "TestExisting"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected the tests prompt to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	for _, get := range []map[string]interface{}{
+		{"name": "review_file", "arguments": map[string]string{"repository": "app"}},
+		{"name": "explain_module", "arguments": map[string]string{"path": "missing", "repository": "app"}},
+		{"name": "write_tests", "arguments": map[string]string{"symbol_name": "Missing", "repository": "app"}},
+	} {
+		if _, errMessage := resourceRequest(t, s, "prompts/get", get); errMessage == "" {
+			t.Errorf("Expected %v to fail", get)
+		}
+	}
+
+	// Context beyond the budget is cut at a line, and what does not fit is
+	// left out
+	prompt := newPromptBuilder(60)
+	prompt.instructions("Read this.")
+	prompt.section("big.txt", strings.Repeat("0123456789\n", 100), "")
+	prompt.section("small.txt", "tiny", "")
+	content, ok := prompt.result("test").Messages[0].Content.(mcp.TextContent)
+	if !ok {
+		t.Fatal("Expected a text message")
+	}
+	if text = content.Text; !strings.HasSuffix(text, "Left out to fit the prompt: small.txt. Read them with the file tools if they matter.") ||
+		!strings.Contains(text, "0123456789\n... (cut to fit the prompt)") || estimateTokens(text) > 100 {
+		t.Errorf("Expected big.txt to be cut and small.txt left out, got:\n%s", text)
+	}
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+
+	"github.com/my-mcp/code-indexer/internal/auth"
+	"github.com/my-mcp/code-indexer/internal/connection"
+	"github.com/my-mcp/code-indexer/internal/indexer"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// searchResultFields are the response fields holding the results of each
+// search tool, counted against the search results quota
+var searchResultFields = map[string][]string{
+	"search_code":     {"results", "matches"},
+	"semantic_search": {"results"},
+	"find_files":      {"files"},
+	"find_symbols":    {"symbols"},
+	"complete_symbol": {"completions"},
+	"grep_repository": {"matches"},
+}
+
+// indexingTools are the tools counted against the indexing jobs quota
+var indexingTools = []string{"index_repository", "refresh_index", "switch_ref", "index_dependencies", "sync_configured_repositories"}
+
+// connectionID returns the ID of the managed connection a tool call came
+// in on, or "" for calls over transports without connection management
+func connectionID(ctx context.Context) string {
+	if clientSession, ok := server.ClientSessionFromContext(ctx).(*wsClientSession); ok {
+		return clientSession.SessionID()
+	}
+	return ""
+}
+
+// quotaConnectionID returns the ID of the connection whose quotas a tool
+// call is held to: the WebSocket connection it came in on or, for calls over
+// /api/call and the HTTP transports, the client connection of its API key
+// or IP address. Calls over stdio have none.
+func (s *MCPServer) quotaConnectionID(ctx context.Context) string {
+	if id := connectionID(ctx); id != "" || s.connectionManager == nil {
+		return id
+	}
+	if client, ok := auth.ClientFromContext(ctx); ok {
+		return s.connectionManager.ClientConnection(client).ID
+	}
+	return ""
+}
+
+// quotaExceeded returns the result of a call refused by a connection quota
+func quotaExceeded(err error) *mcp.CallToolResult {
+	var quotaErr *connection.QuotaError
+	if !errors.As(err, &quotaErr) {
+		return toolErrorResult(err)
+	}
+	details := map[string]interface{}{"quota": quotaErr.Quota, "limit": quotaErr.Limit}
+	if quotaErr.RetryAfter > 0 {
+		details["retry_after_seconds"] = int(math.Ceil(quotaErr.RetryAfter.Seconds()))
+	}
+	return toolError(types.ErrorQuotaExceeded, quotaErr.Error(), details)
+}
+
+// jobActive reports whether a background indexing job is queued or running
+func (s *MCPServer) jobActive(jobID string) bool {
+	job, err := s.jobs.Get(jobID)
+	return err == nil && (job.Status == indexer.JobQueued || job.Status == indexer.JobRunning)
+}
+
+// trackIndexingJob counts a background indexing job against the quota of
+// the connection that queued it
+func (s *MCPServer) trackIndexingJob(ctx context.Context, jobID string) {
+	if id := s.quotaConnectionID(ctx); id != "" {
+		s.connectionManager.TrackIndexingJob(id, jobID)
+	}
+}
+
+// chargeEditBytes counts the bytes an edit is about to write against the
+// quota of the connection it came in on
+func (s *MCPServer) chargeEditBytes(ctx context.Context, bytes int) error {
+	if id := s.quotaConnectionID(ctx); id != "" {
+		return s.connectionManager.ChargeEditBytes(id, int64(bytes))
+	}
+	return nil
+}
+
+// countResults returns the number of results in the response of a search
+// tool
+func countResults(result *mcp.CallToolResult, fields []string) int {
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(resultText(result)), &response); err != nil {
+		return 0
+	}
+	count := 0
+	for _, field := range fields {
+		var results []json.RawMessage
+		if json.Unmarshal(response[field], &results) == nil {
+			count += len(results)
+		}
+	}
+	return count
+}
+
+// withQuotas holds the tool calls of a connection, or of a client of the
+// HTTP transports, to its quotas: the calls it may run at once, the search results it may receive per
+// minute and the indexing runs it may have at once. The edit bytes quota is
+// charged where edits are written. It runs below withTimeout, so a call
+// answered at its time limit keeps its slots until its handler returns;
+// time spent queued for a slot counts against the limit.
+func (s *MCPServer) withQuotas(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	resultFields, isSearch := searchResultFields[name]
+	isIndexing := slices.Contains(indexingTools, name)
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := s.quotaConnectionID(ctx)
+		if id == "" {
+			return handler(ctx, request)
+		}
+
+		release, err := s.connectionManager.BeginCall(ctx, id)
+		if err != nil {
+			return quotaExceeded(err), nil
+		}
+		defer release()
+
+		if isIndexing {
+			releaseIndexing, err := s.connectionManager.BeginIndexing(id, s.jobActive)
+			if err != nil {
+				return quotaExceeded(err), nil
+			}
+			defer releaseIndexing()
+		}
+		if isSearch {
+			if err := s.connectionManager.CheckSearchResults(id); err != nil {
+				return quotaExceeded(err), nil
+			}
+		}
+
+		result, err := handler(ctx, request)
+		if isSearch && err == nil && result != nil && !result.IsError {
+			s.connectionManager.ChargeSearchResults(id, countResults(result, resultFields))
+		}
+		return result, err
+	}
+}
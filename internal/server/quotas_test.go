@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/auth"
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/connection"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+func TestConnectionQuotas(t *testing.T) {
+	repoDir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		if err := os.WriteFile(filepath.Join(repoDir, name), []byte("package main\n\nfunc Retry() {}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{repoDir}
+		cfg.Server.MultiIDE.ResourceManagement.SearchResultsPerMinute = 2
+		cfg.Server.MultiIDE.ResourceManagement.EditBytesPerMinute = 10
+		cfg.Server.MultiIDE.ResourceManagement.MaxConcurrentOperations = 1
+		cfg.Server.MultiIDE.ResourceManagement.EnableOperationQueue = false
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": repoDir, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	conn, err := s.connectionManager.CreateConnection(connection.ConnectionTypeWebSocket, "127.0.0.1:1234", "test")
+	if err != nil {
+		t.Fatalf("CreateConnection failed: %v", err)
+	}
+	ctx := s.server.WithContext(context.Background(), &wsClientSession{id: conn.ID, notifications: make(chan mcp.JSONRPCNotification, 1)})
+	call := func(ctx context.Context, name string, args map[string]interface{}) (string, *types.ToolError) {
+		t.Helper()
+		var request mcp.CallToolRequest
+		request.Params.Name = name
+		request.Params.Arguments = args
+		result, err := s.handlers[name](ctx, request)
+		if err != nil {
+			t.Fatalf("%s failed: %v", name, err)
+		}
+		toolErr, _ := resultError(result)
+		return resultText(result), toolErr
+	}
+
+	// The search over the quota still returns its results; the next is refused
+	findArgs := map[string]interface{}{"pattern": "*.go", "repository": "app"}
+	if text, toolErr := call(ctx, "find_files", findArgs); toolErr != nil || strings.Count(text, ".go\"") < 3 {
+		t.Fatalf("Expected the first search to return all files, got %s", text)
+	}
+	_, toolErr := call(ctx, "find_files", findArgs)
+	if toolErr == nil || toolErr.Code != types.ErrorQuotaExceeded || toolErr.Details["quota"] != connection.QuotaSearchResults || toolErr.Details["retry_after_seconds"] == nil {
+		t.Errorf("Expected QUOTA_EXCEEDED for the search results, got %+v", toolErr)
+	}
+	if _, toolErr := call(context.Background(), "find_files", findArgs); toolErr != nil {
+		t.Errorf("Expected calls over stdio to be unlimited, got %+v", toolErr)
+	}
+
+	// Calls over /api/call are held to the quotas of their client
+	apiCall := func(client string) *types.ToolError {
+		t.Helper()
+		w := httptest.NewRecorder()
+		body := `{"tool": "find_files", "arguments": {"pattern": "*.go", "repository": "app"}}`
+		s.handleToolCall(w, apiRequest("/api/call", body).WithContext(auth.WithClient(context.Background(), client)))
+		var response struct {
+			Error *types.ToolError `json:"error"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode /api/call: %v", err)
+		}
+		return response.Error
+	}
+	if toolErr := apiCall("key:ci"); toolErr != nil {
+		t.Fatalf("Expected the first search of the client to run, got %+v", toolErr)
+	}
+	if toolErr := apiCall("key:ci"); toolErr == nil || toolErr.Code != types.ErrorQuotaExceeded || toolErr.Details["quota"] != connection.QuotaSearchResults {
+		t.Errorf("Expected QUOTA_EXCEEDED for the client's search results, got %+v", toolErr)
+	}
+	if toolErr := apiCall("addr:192.0.2.1"); toolErr != nil {
+		t.Errorf("Expected another client to have its own quotas, got %+v", toolErr)
+	}
+
+	// Edits writing more than the quota are refused before the file changes
+	file := filepath.Join(repoDir, "a.go")
+	editArgs := map[string]interface{}{"file_path": file, "start_line": 3.0, "end_line": 3.0, "new_content": "func Retry() { panic(0) }"}
+	if _, toolErr := call(ctx, "replace_lines", editArgs); toolErr == nil || toolErr.Code != types.ErrorQuotaExceeded {
+		t.Errorf("Expected QUOTA_EXCEEDED for the edit, got %+v", toolErr)
+	}
+	if content, _ := os.ReadFile(file); strings.Contains(string(content), "panic") {
+		t.Error("Expected a.go to be unchanged")
+	}
+
+	// A call answered at its time limit holds its slot until its handler
+	// returns
+	release := make(chan struct{})
+	s.config.Server.Timeouts.Tools = map[string]int{"stuck": 1}
+	s.addTool(mcp.NewTool("stuck"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-release
+		return mcp.NewToolResultText("done"), nil
+	})
+	limited, err := s.connectionManager.CreateConnection(connection.ConnectionTypeWebSocket, "127.0.0.1:1235", "test")
+	if err != nil {
+		t.Fatalf("CreateConnection failed: %v", err)
+	}
+	limitedCtx := s.server.WithContext(context.Background(), &wsClientSession{id: limited.ID, notifications: make(chan mcp.JSONRPCNotification, 1)})
+	if _, toolErr := call(limitedCtx, "stuck", nil); toolErr == nil || toolErr.Code != types.ErrorTimeout {
+		t.Fatalf("Expected TIMEOUT, got %+v", toolErr)
+	}
+	if _, toolErr := call(limitedCtx, "find_files", findArgs); toolErr == nil || toolErr.Code != types.ErrorQuotaExceeded || toolErr.Details["quota"] != connection.QuotaConcurrentCalls {
+		t.Errorf("Expected QUOTA_EXCEEDED while the timed out handler runs, got %+v", toolErr)
+	}
+	close(release)
+
+	// /api/health reports the quotas and their refusals
+	w := httptest.NewRecorder()
+	s.handleHealthCheck(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	var health struct {
+		Connections struct {
+			Quotas        map[string]interface{} `json:"quotas"`
+			QuotaExceeded map[string]int64       `json:"quota_exceeded"`
+		} `json:"connections"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Invalid health response: %v", err)
+	}
+	if health.Connections.Quotas["search_results_per_minute"] != 2.0 ||
+		health.Connections.QuotaExceeded[connection.QuotaSearchResults] != 2 || health.Connections.QuotaExceeded[connection.QuotaEditBytes] != 1 {
+		t.Errorf("Unexpected quota stats %s", w.Body.String())
+	}
+}
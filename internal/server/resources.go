@@ -0,0 +1,353 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// MCP resources expose the files and directories of the indexed
+// repositories under repo://<repository>/<path> URIs, so clients can browse
+// them without calling the file tools. Reads are served by the tools
+// themselves, with the same permissions, sandbox, quotas and locks.
+
+// resourceScheme starts the URI of every resource
+const resourceScheme = "repo://"
+
+// resourceTemplate matches the URIs of the files and directories of every
+// indexed repository. view=outline reads the symbol outline of a file;
+// cursor pages through a directory.
+const resourceTemplate = "repo://{repository}/{+path}{?view,cursor}"
+
+// resourcePageSize is the number of entries of a directory read at once
+var resourcePageSize = defaultListDirectoryLimit
+
+// resourceTarget is a file or directory named by a resource URI
+type resourceTarget struct {
+	repository string
+	path       string // Slash-separated, relative to the repository; "" for its root
+	view       string // "" for the content, "outline" for the symbol outline
+	offset     int    // First directory entry read
+}
+
+// registerResources lists the root directory of every indexed repository as
+// a resource, registers the template reaching the files below them and
+// keeps the list up to date as repositories are indexed and removed
+func (s *MCPServer) registerResources() {
+	s.server.AddResourceTemplate(
+		mcp.NewResourceTemplate(resourceTemplate, "Indexed repository files",
+			mcp.WithTemplateDescription("A file or directory of an indexed repository. Files are read as text, directories as JSON listings paged with cursor, and view=outline reads the symbol outline of a file."),
+		),
+		s.handleReadResource,
+	)
+	s.hooks.AddAfterListResources(s.filterListedResources)
+	s.indexer.OnRepositoryChange(s.repositoryChanged)
+	s.refreshResources()
+}
+
+// refreshResources replaces the listed resources with the root directories
+// of the indexed repositories. Clients are sent
+// notifications/resources/list_changed when the list changed.
+func (s *MCPServer) refreshResources() {
+	s.resourcesMutex.Lock()
+	defer s.resourcesMutex.Unlock()
+
+	repositories, err := s.indexer.ListRepositories(context.Background())
+	if err != nil {
+		s.logger.Warn("Failed to list repositories for resources", zap.Error(err))
+		return
+	}
+
+	uris := make([]string, 0, len(repositories))
+	resources := make([]server.ServerResource, 0, len(repositories))
+	for _, repo := range repositories {
+		if repo.Path == "" {
+			continue // Known only from the index, its files cannot be read
+		}
+		uri := repositoryURI(repo.Name, "")
+		uris = append(uris, uri)
+		resources = append(resources, server.ServerResource{
+			Resource: mcp.NewResource(uri, repo.Name,
+				mcp.WithResourceDescription(fmt.Sprintf("Root directory of the indexed repository %s", repo.Name)),
+				mcp.WithMIMEType("application/json"),
+			),
+			Handler: s.handleReadResource,
+		})
+	}
+
+	if listed := strings.Join(uris, "\n"); listed != s.resourceURIs {
+		s.resourceURIs = listed
+		s.server.SetResources(resources...)
+	}
+}
+
+// filterListedResources leaves a session confined to its workspace only the
+// resources of the workspace's repositories
+func (s *MCPServer) filterListedResources(ctx context.Context, id any, request *mcp.ListResourcesRequest, result *mcp.ListResourcesResult) {
+	sess := s.sessionForContext(ctx)
+	if !s.workspaceIsolated(sess) {
+		return
+	}
+	workspace := s.sessionWorkspace(ctx, sess)
+	resources := result.Resources[:0]
+	for _, resource := range result.Resources {
+		if target, err := parseResourceURI(resource.URI); err == nil && workspace.allows(s, target.repository) {
+			resources = append(resources, resource)
+		}
+	}
+	result.Resources = resources
+}
+
+// repositoryChanged updates the resources after the index of a repository
+// changed and tells clients its root resource was updated, so those
+// mounting it re-read the files they show
+func (s *MCPServer) repositoryChanged(repo types.Repository, removed bool) {
+	s.refreshResources()
+	if !removed && repo.Path != "" {
+		s.server.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{
+			"uri": repositoryURI(repo.Name, ""),
+		})
+	}
+}
+
+// handleReadResource reads the file, directory listing or outline a
+// resource URI names
+func (s *MCPServer) handleReadResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri := request.Params.URI
+	target, err := parseResourceURI(uri)
+	if err != nil {
+		return nil, types.NewToolError(types.ErrorInvalidArgument, fmt.Sprintf("Invalid resource URI %s: %v", uri, err),
+			map[string]interface{}{"uri": uri})
+	}
+	// Sessions confined to their workspace learn nothing about the files of
+	// other repositories, not even whether they exist
+	if sess := s.sessionForContext(ctx); s.workspaceIsolated(sess) && !s.sessionWorkspace(ctx, sess).allows(s, target.repository) {
+		return nil, types.NewToolError(types.ErrorPermissionDenied,
+			fmt.Sprintf("Repository %q is outside the workspace of session %q", target.repository, sess.Name),
+			map[string]interface{}{"uri": uri, "session_id": sess.ID})
+	}
+	repo, ok := s.indexer.IndexedRepository(target.repository)
+	if !ok {
+		return nil, repoNotFoundError(target.repository)
+	}
+
+	info, err := os.Stat(filepath.Join(repo.Path, filepath.FromSlash(target.path)))
+	if err != nil {
+		return nil, types.NewToolError(types.ErrorNotFound, fmt.Sprintf("Resource %s does not exist", uri),
+			map[string]interface{}{"uri": uri})
+	}
+	switch {
+	case info.IsDir() && target.view != "":
+		return nil, types.NewToolError(types.ErrorInvalidArgument, fmt.Sprintf("Directories have no %s view", target.view),
+			map[string]interface{}{"uri": uri})
+	case info.IsDir():
+		return s.readDirectoryResource(ctx, repo.Name, target)
+	case target.view == "outline":
+		return s.readOutlineResource(ctx, repo.Name, target)
+	default:
+		return s.readFileResource(ctx, repo.Name, target)
+	}
+}
+
+// readFileResource reads a file through get_file_content
+func (s *MCPServer) readFileResource(ctx context.Context, repository string, target resourceTarget) ([]mcp.ResourceContents, error) {
+	var file struct {
+		Content string `json:"content"`
+	}
+	if err := s.callToolJSON(ctx, "get_file_content", map[string]interface{}{
+		"repository": repository,
+		"file_path":  target.path,
+	}, &file); err != nil {
+		return nil, err
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(target.path))
+	if mimeType == "" || !strings.HasPrefix(mimeType, "text/") {
+		mimeType = "text/plain"
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      repositoryURI(repository, target.path),
+		MIMEType: mimeType,
+		Text:     file.Content,
+	}}, nil
+}
+
+// readOutlineResource reads the symbol outline of a file through
+// get_file_outline
+func (s *MCPServer) readOutlineResource(ctx context.Context, repository string, target resourceTarget) ([]mcp.ResourceContents, error) {
+	var outline map[string]interface{}
+	if err := s.callToolJSON(ctx, "get_file_outline", map[string]interface{}{
+		"repository": repository,
+		"file_path":  target.path,
+	}, &outline); err != nil {
+		return nil, err
+	}
+	outline["uri"] = repositoryURI(repository, target.path)
+	return jsonResourceContents(repositoryURI(repository, target.path)+"?view=outline", outline)
+}
+
+// readDirectoryResource reads a page of the entries of a directory through
+// list_directory, each with the URI it is read from
+func (s *MCPServer) readDirectoryResource(ctx context.Context, repository string, target resourceTarget) ([]mcp.ResourceContents, error) {
+	directory := target.path
+	if directory == "" {
+		directory = "."
+	}
+	var listing struct {
+		Entries []struct {
+			Name         string `json:"name"`
+			RelativePath string `json:"relative_path"`
+			Type         string `json:"type"`
+			Size         int64  `json:"size"`
+			Language     string `json:"language"`
+		} `json:"entries"`
+		TotalEntries int  `json:"total_entries"`
+		HasMore      bool `json:"has_more"`
+		NextOffset   int  `json:"next_offset"`
+	}
+	if err := s.callToolJSON(ctx, "list_directory", map[string]interface{}{
+		"repository":     repository,
+		"directory_path": directory,
+		"offset":         float64(target.offset),
+		"limit":          float64(resourcePageSize),
+	}, &listing); err != nil {
+		return nil, err
+	}
+
+	entries := make([]map[string]interface{}, 0, len(listing.Entries))
+	for _, entry := range listing.Entries {
+		item := map[string]interface{}{
+			"uri":  repositoryURI(repository, path.Join(target.path, entry.RelativePath)),
+			"name": entry.Name,
+			"type": entry.Type,
+			"size": entry.Size,
+		}
+		if entry.Language != "" {
+			item["language"] = entry.Language
+		}
+		entries = append(entries, item)
+	}
+
+	uri := repositoryURI(repository, target.path)
+	result := map[string]interface{}{
+		"uri":           uri,
+		"repository":    repository,
+		"path":          target.path,
+		"entries":       entries,
+		"total_entries": listing.TotalEntries,
+	}
+	if listing.HasMore {
+		cursor := strconv.Itoa(listing.NextOffset)
+		result["next_cursor"] = cursor
+		result["next_uri"] = uri + "?cursor=" + cursor
+	}
+	if target.offset > 0 {
+		uri += "?cursor=" + strconv.Itoa(target.offset)
+	}
+	return jsonResourceContents(uri, result)
+}
+
+// callToolJSON calls a tool for a resource read or a prompt and decodes its
+// whole result into v, whatever its size. The tool runs in the session of
+// the caller's connection. A failed call returns its error.
+func (s *MCPServer) callToolJSON(ctx context.Context, name string, args map[string]interface{}, v interface{}) error {
+	if sess := s.sessionForContext(ctx); sess != s.getDefaultSession() {
+		args["session_id"] = sess.ID
+	}
+	var request mcp.CallToolRequest
+	request.Params.Name = name
+	request.Params.Arguments = args
+	result, err := s.executeToolCall(withFullResult(ctx), request)
+	if err != nil {
+		return err
+	}
+	if toolErr, ok := resultError(result); ok {
+		return toolErr
+	}
+	if result.IsError {
+		return errors.New(resultText(result))
+	}
+	return json.Unmarshal([]byte(resultText(result)), v)
+}
+
+// jsonResourceContents returns value as the JSON contents of a resource
+func jsonResourceContents(uri string, value interface{}) ([]mcp.ResourceContents, error) {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{
+		URI:      uri,
+		MIMEType: "application/json",
+		Text:     string(content),
+	}}, nil
+}
+
+// repositoryURI returns the URI of a file or directory of a repository,
+// given by its slash-separated relative path; "" is the root directory
+func repositoryURI(repository, relativePath string) string {
+	segments := strings.Split(relativePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return resourceScheme + url.PathEscape(repository) + "/" + strings.Join(segments, "/")
+}
+
+// parseResourceURI returns the file or directory a resource URI names.
+// Paths may use either separator but must stay within the repository.
+func parseResourceURI(uri string) (resourceTarget, error) {
+	var target resourceTarget
+	rest, ok := strings.CutPrefix(uri, resourceScheme)
+	if !ok {
+		return target, fmt.Errorf("expected a %s URI", resourceScheme)
+	}
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	name, rawPath, _ := strings.Cut(rest, "/")
+
+	var err error
+	if target.repository, err = url.PathUnescape(name); err != nil || target.repository == "" {
+		return target, errors.New("missing or invalid repository name")
+	}
+	relativePath, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return target, fmt.Errorf("invalid path: %w", err)
+	}
+	relativePath = path.Clean(strings.TrimLeft(pathutil.ToSlash(relativePath), "/"))
+	if !pathutil.Within(relativePath) {
+		return target, errors.New("path leaves the repository")
+	}
+	if relativePath != "." {
+		target.path = relativePath
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return target, fmt.Errorf("invalid query: %w", err)
+	}
+	switch target.view = query.Get("view"); target.view {
+	case "", "outline":
+	default:
+		return target, fmt.Errorf("unknown view %q: use outline", target.view)
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if target.offset, err = strconv.Atoi(cursor); err != nil || target.offset < 0 {
+			return target, fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+	return target, nil
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/connection"
+)
+
+// resourceRequest sends a resources or prompts request to the MCP server
+// and returns its result, or the message of the error it was answered with
+func resourceRequest(t *testing.T, s *MCPServer, method string, params map[string]interface{}) (json.RawMessage, string) {
+	t.Helper()
+	return resourceRequestContext(t, s, context.Background(), method, params)
+}
+
+// resourceRequestContext sends a resources or prompts request in ctx, such
+// as that of a connection
+func resourceRequestContext(t *testing.T, s *MCPServer, ctx context.Context, method string, params map[string]interface{}) (json.RawMessage, string) {
+	t.Helper()
+	message, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := json.Marshal(s.server.HandleMessage(ctx, message))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(response, &decoded); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, response)
+	}
+	if decoded.Error != nil {
+		return nil, decoded.Error.Message
+	}
+	return decoded.Result, ""
+}
+
+// readResource reads a resource and returns the text and MIME type of its
+// contents
+func readResource(t *testing.T, s *MCPServer, uri string) (string, string) {
+	t.Helper()
+	result, errMessage := resourceRequest(t, s, "resources/read", map[string]interface{}{"uri": uri})
+	if errMessage != "" {
+		t.Fatalf("Reading %s failed: %s", uri, errMessage)
+	}
+	var read struct {
+		Contents []struct {
+			URI      string `json:"uri"`
+			MIMEType string `json:"mimeType"`
+			Text     string `json:"text"`
+		} `json:"contents"`
+	}
+	if err := json.Unmarshal(result, &read); err != nil || len(read.Contents) != 1 {
+		t.Fatalf("Invalid contents of %s: %v: %s", uri, err, result)
+	}
+	return read.Contents[0].Text, read.Contents[0].MIMEType
+}
+
+func TestRepositoryResources(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"README.md":       "# App\n",
+		"src/pkg/util.go": "package pkg\n\n// Helper helps\nfunc Helper() {}\n",
+		"src/a.go":        "package src\n",
+		"src/b.go":        "package src\n",
+	}
+	for path, content := range files {
+		fullPath := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "my app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	result, errMessage := resourceRequest(t, s, "resources/list", nil)
+	if errMessage != "" {
+		t.Fatalf("resources/list failed: %s", errMessage)
+	}
+	if !strings.Contains(string(result), `"uri":"repo://my%20app/"`) {
+		t.Errorf("Expected the repository root to be listed, got %s", result)
+	}
+	result, _ = resourceRequest(t, s, "resources/templates/list", nil)
+	if !strings.Contains(string(result), resourceTemplate) {
+		t.Errorf("Expected the repository template to be listed, got %s", result)
+	}
+
+	// Files are read as text, with either separator
+	for _, uri := range []string{"repo://my%20app/src/pkg/util.go", "repo://my%20app/src%5Cpkg%5Cutil.go"} {
+		if text, mimeType := readResource(t, s, uri); text != files["src/pkg/util.go"] || !strings.HasPrefix(mimeType, "text/") {
+			t.Errorf("Expected the content of %s, got %q (%s)", uri, text, mimeType)
+		}
+	}
+
+	text, mimeType := readResource(t, s, "repo://my%20app/src/pkg/util.go?view=outline")
+	if mimeType != "application/json" || !strings.Contains(text, `"Helper"`) {
+		t.Errorf("Expected the outline of util.go, got %s", text)
+	}
+
+	// Directories are listed page by page, each entry with its URI
+	resourcePageSize = 2
+	defer func() { resourcePageSize = defaultListDirectoryLimit }()
+	var uris []string
+	next := "repo://my%20app/src"
+	for pages := 0; next != ""; pages++ {
+		if pages > 3 {
+			t.Fatalf("Expected the listing to end, got %v", uris)
+		}
+		text, _ := readResource(t, s, next)
+		var listing struct {
+			Entries []struct {
+				URI string `json:"uri"`
+			} `json:"entries"`
+			TotalEntries int    `json:"total_entries"`
+			NextURI      string `json:"next_uri"`
+		}
+		if err := json.Unmarshal([]byte(text), &listing); err != nil {
+			t.Fatalf("Invalid listing: %v: %s", err, text)
+		}
+		if listing.TotalEntries != 3 {
+			t.Errorf("Expected 3 entries in src, got %d", listing.TotalEntries)
+		}
+		for _, entry := range listing.Entries {
+			uris = append(uris, entry.URI)
+		}
+		next = listing.NextURI
+	}
+	want := "repo://my%20app/src/a.go repo://my%20app/src/b.go repo://my%20app/src/pkg"
+	if got := strings.Join(uris, " "); got != want {
+		t.Errorf("Expected entries %s, got %s", want, got)
+	}
+
+	for _, uri := range []string{"repo://my%20app/../etc/passwd", "repo://other/README.md", "repo://my%20app/missing.go", "repo://my%20app/src?view=outline"} {
+		if _, errMessage := resourceRequest(t, s, "resources/read", map[string]interface{}{"uri": uri}); errMessage == "" {
+			t.Errorf("Expected reading %s to fail", uri)
+		}
+	}
+
+	// Removed repositories are no longer listed
+	if text, isError := callTool(t, s, "remove_repository", map[string]interface{}{"repository": "my app"}); isError {
+		t.Fatalf("remove_repository failed: %s", text)
+	}
+	result, _ = resourceRequest(t, s, "resources/list", nil)
+	if strings.Contains(string(result), "repo://") {
+		t.Errorf("Expected the removed repository to be unlisted, got %s", result)
+	}
+	if _, errMessage := resourceRequest(t, s, "resources/read", map[string]interface{}{"uri": "repo://my%20app/README.md"}); !strings.Contains(errMessage, "not found") {
+		t.Errorf("Expected the removed repository not to be found, got %q", errMessage)
+	}
+}
+
+func TestResourceWorkspaceIsolation(t *testing.T) {
+	root := t.TempDir()
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+	})
+	for _, name := range []string{"api", "web"} {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+".go"), []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": dir, "name": name}); isError {
+			t.Fatalf("Failed to index %s: %s", name, text)
+		}
+	}
+	if text, isError := callTool(t, s, "set_workspace", map[string]interface{}{"name": "backend", "repositories": []interface{}{"api"}}); isError {
+		t.Fatalf("Failed to set workspace: %s", text)
+	}
+	text, isError := callTool(t, s, "create_session", map[string]interface{}{"name": "backend-dev", "workspace": "backend"})
+	if isError {
+		t.Fatalf("Failed to create session: %s", text)
+	}
+	var created struct {
+		Session struct {
+			ID string `json:"id"`
+		} `json:"session"`
+	}
+	if err := json.Unmarshal([]byte(text), &created); err != nil {
+		t.Fatalf("Invalid response: %v: %s", err, text)
+	}
+
+	// A connection of the session sees only the repositories of its workspace
+	conn, err := s.connectionManager.CreateConnection(connection.ConnectionTypeWebSocket, "127.0.0.1:1234", "test")
+	if err != nil {
+		t.Fatalf("CreateConnection failed: %v", err)
+	}
+	if err := s.connectionManager.AssociateSession(conn.ID, created.Session.ID); err != nil {
+		t.Fatalf("AssociateSession failed: %v", err)
+	}
+	ctx := s.server.WithContext(context.Background(), &wsClientSession{id: conn.ID, notifications: make(chan mcp.JSONRPCNotification, 1)})
+
+	result, errMessage := resourceRequestContext(t, s, ctx, "resources/list", nil)
+	if errMessage != "" {
+		t.Fatalf("resources/list failed: %s", errMessage)
+	}
+	if !strings.Contains(string(result), `"uri":"repo://api/"`) || strings.Contains(string(result), "repo://web/") {
+		t.Errorf("Expected only the api repository to be listed, got %s", result)
+	}
+	if _, errMessage := resourceRequestContext(t, s, ctx, "resources/read", map[string]interface{}{"uri": "repo://api/api.go"}); errMessage != "" {
+		t.Errorf("Expected api.go to be readable, got %s", errMessage)
+	}
+	for _, uri := range []string{"repo://web/web.go", "repo://web/missing.go", "repo://web/"} {
+		if _, errMessage := resourceRequestContext(t, s, ctx, "resources/read", map[string]interface{}{"uri": uri}); !strings.Contains(errMessage, "outside the workspace") {
+			t.Errorf("Expected %s to be refused alike, got %q", uri, errMessage)
+		}
+	}
+
+	// Other clients still see every repository
+	result, _ = resourceRequest(t, s, "resources/list", nil)
+	if !strings.Contains(string(result), "repo://web/") {
+		t.Errorf("Expected the web repository to be listed without a session, got %s", result)
+	}
+}
@@ -0,0 +1,405 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Tool results larger than the limit of server.response_limits are cut to
+// fit. The largest list fields keep their first, best ranked entries and the
+// largest text fields are cut at a line; what was cut is kept under a
+// continuation token and read with continue_response, one result-sized page
+// at a time.
+
+// continueResponseTool reads the rest of a truncated result
+const continueResponseTool = "continue_response"
+
+// Defaults of the continuation store when the configuration leaves them 0
+const (
+	defaultContinuationTTL  = 10 * time.Minute
+	defaultMaxContinuations = 100
+)
+
+// continuation is what was cut from a truncated tool result
+type continuation struct {
+	tool    string
+	fields  map[string]json.RawMessage // The rest of each truncated field
+	offsets map[string]int             // Where the rest of each field starts in the full result
+	text    string                     // The rest of a result that is not a JSON object
+	expires time.Time
+}
+
+// continuationStore keeps continuations until they are read or expire. The
+// oldest are dropped first when it is full.
+type continuationStore struct {
+	entries map[string]*continuation
+	order   []string // Tokens, oldest first
+	mutex   sync.Mutex
+}
+
+// add stores a continuation under a token, dropping the oldest when the
+// store holds max of them
+func (c *continuationStore) add(token string, next *continuation, ttl time.Duration, max int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*continuation)
+	}
+	c.expire()
+	for len(c.order) >= max {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	next.expires = time.Now().Add(ttl)
+	c.entries[token] = next
+	c.order = append(c.order, token)
+}
+
+// take removes and returns the continuation of a token
+func (c *continuationStore) take(token string) (*continuation, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.expire()
+	next, ok := c.entries[token]
+	if !ok {
+		return nil, false
+	}
+	delete(c.entries, token)
+	for i, t := range c.order {
+		if t == token {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	return next, true
+}
+
+// expire drops the expired continuations; the caller holds the mutex
+func (c *continuationStore) expire() {
+	now := time.Now()
+	kept := c.order[:0]
+	for _, token := range c.order {
+		if c.entries[token].expires.After(now) {
+			kept = append(kept, token)
+		} else {
+			delete(c.entries, token)
+		}
+	}
+	c.order = kept
+}
+
+// newContinuationToken returns a random token that cannot be guessed, so a
+// continuation can only be read by the caller it was returned to
+func newContinuationToken() string {
+	var token [16]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(token[:])
+}
+
+// truncatedField describes a field of a result that was cut to fit
+type truncatedField struct {
+	Field    string `json:"field"`
+	Unit     string `json:"unit"`     // "items" of a list, "bytes" of a text
+	Offset   int    `json:"offset"`   // Where the returned part starts in the full field
+	Returned int    `json:"returned"` // Items or bytes returned
+	Omitted  int    `json:"omitted"`  // Items or bytes left for continue_response
+}
+
+// fullResultKey is the context key marking tool calls made by the server
+// itself, which read whole results
+type fullResultKey struct{}
+
+// withFullResult returns ctx for a tool call whose result is not limited,
+// used where the server calls tools for resources and prompts and sizes
+// what it sends itself
+func withFullResult(ctx context.Context) context.Context {
+	return context.WithValue(ctx, fullResultKey{}, true)
+}
+
+// withResponseLimit cuts the results of a tool to its size limit. The
+// full result is produced first, so quotas count what the call found.
+func (s *MCPServer) withResponseLimit(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := handler(ctx, request)
+		if err != nil || result == nil || result.IsError || name == continueResponseTool {
+			return result, err
+		}
+		if full, _ := ctx.Value(fullResultKey{}).(bool); full {
+			return result, nil
+		}
+		limit := s.config.Server.ResponseLimits.Limit(name)
+		if limit <= 0 || len(result.Content) != 1 {
+			return result, nil
+		}
+		text, ok := result.Content[0].(mcp.TextContent)
+		if !ok || len(text.Text) <= limit {
+			return result, nil
+		}
+		s.log(ctx).Info("Truncating tool result", zap.String("tool", name), zap.Int("bytes", len(text.Text)), zap.Int("limit", limit))
+		return s.limitResult(name, text.Text, nil, limit), nil
+	}
+}
+
+// limitResult returns the text of a result of a tool cut to limit bytes,
+// storing what was cut under a continuation token. offsets are where the
+// fields of a continued result start in the full result.
+func (s *MCPServer) limitResult(tool, text string, offsets map[string]int, limit int) *mcp.CallToolResult {
+	if len(text) <= limit {
+		return mcp.NewToolResultText(text)
+	}
+	var fields map[string]json.RawMessage
+	if json.Unmarshal([]byte(text), &fields) != nil || fields == nil {
+		return s.limitText(tool, text, limit)
+	}
+
+	token := newContinuationToken()
+	kept, rest, truncated := truncateFields(fields, offsets, limit, func(truncated []truncatedField) interface{} {
+		return truncationNotice(token, limit, truncated)
+	})
+	if len(truncated) == 0 {
+		return mcp.NewToolResultText(text) // Nothing could be cut
+	}
+
+	next := &continuation{tool: tool, fields: rest, offsets: make(map[string]int)}
+	for _, field := range truncated {
+		next.offsets[field.Field] = field.Offset + field.Returned
+	}
+	s.storeContinuation(token, next)
+	kept["truncation"], _ = json.Marshal(truncationNotice(token, limit, truncated))
+	response, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response")
+	}
+	return mcp.NewToolResultText(string(response))
+}
+
+// limitText cuts a result that is not a JSON object at a line, ending it
+// with a note naming the continuation token
+func (s *MCPServer) limitText(tool, text string, limit int) *mcp.CallToolResult {
+	token := newContinuationToken()
+	note := fmt.Sprintf("\n\n[Truncated: %%d more bytes. Call %s with continuation_token %q for the rest]", continueResponseTool, token)
+	room := limit - len(fmt.Sprintf(note, len(text)))
+	kept := cutText(text, room)
+	if kept == "" {
+		kept = firstTextLine(text)
+	}
+	s.storeContinuation(token, &continuation{tool: tool, text: text[len(kept):]})
+	return mcp.NewToolResultText(kept + fmt.Sprintf(note, len(text)-len(kept)))
+}
+
+// storeContinuation keeps a continuation under a token made beforehand, so
+// the token could be measured into the result
+func (s *MCPServer) storeContinuation(token string, next *continuation) {
+	limits := s.config.Server.ResponseLimits
+	ttl := time.Duration(limits.ContinuationTTLMinutes) * time.Minute
+	if ttl <= 0 {
+		ttl = defaultContinuationTTL
+	}
+	max := limits.MaxContinuations
+	if max <= 0 {
+		max = defaultMaxContinuations
+	}
+	s.continuations.add(token, next, ttl, max)
+}
+
+// truncationNotice is the truncation field of a cut result
+func truncationNotice(token string, limit int, truncated []truncatedField) map[string]interface{} {
+	return map[string]interface{}{
+		"continuation_token": token,
+		"limit_bytes":        limit,
+		"fields":             truncated,
+		"message":            fmt.Sprintf("The result was cut to %d bytes; call %s with the continuation_token for the rest", limit, continueResponseTool),
+	}
+}
+
+// truncateFields cuts the list and text fields of a result, largest first,
+// until the result with its notice fits in limit bytes. Lists keep their
+// first entries, texts are cut at a line. The largest field keeps at least
+// one entry or line, so each page makes progress even when a single entry
+// is larger than the limit. It returns the kept fields, the rest of each
+// cut field and what was cut.
+func truncateFields(fields map[string]json.RawMessage, offsets map[string]int, limit int, notice func([]truncatedField) interface{}) (map[string]json.RawMessage, map[string]json.RawMessage, []truncatedField) {
+	kept := make(map[string]json.RawMessage, len(fields)+1)
+	var candidates []string
+	for name, value := range fields {
+		kept[name] = value
+		if len(value) > 0 && (value[0] == '[' || value[0] == '"') && name != "truncation" {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if len(fields[candidates[i]]) != len(fields[candidates[j]]) {
+			return len(fields[candidates[i]]) > len(fields[candidates[j]])
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	rest := make(map[string]json.RawMessage)
+	var truncated []truncatedField
+	size := func(pending []truncatedField) int {
+		kept["truncation"], _ = json.Marshal(notice(pending))
+		response, _ := json.MarshalIndent(kept, "", "  ")
+		delete(kept, "truncation")
+		return len(response)
+	}
+
+	for i, name := range candidates {
+		if size(truncated) <= limit {
+			break
+		}
+		minimum := 0
+		if i == 0 {
+			minimum = 1
+		}
+		var items []json.RawMessage
+		var text string
+		field := truncatedField{Field: name, Offset: offsets[name]}
+		switch {
+		case json.Unmarshal(fields[name], &items) == nil && len(items) > 0:
+			field.Unit = "items"
+		case json.Unmarshal(fields[name], &text) == nil && text != "":
+			field.Unit = "bytes"
+		default:
+			continue
+		}
+
+		// The most entries or bytes that fit, found by bisection
+		pending := append(truncated[:len(truncated):len(truncated)], field)
+		measure := &pending[len(pending)-1]
+		if field.Unit == "items" {
+			n := largestFitting(len(items), minimum, func(n int) bool {
+				kept[name], _ = json.Marshal(items[:n])
+				measure.Returned, measure.Omitted = n, len(items)-n
+				return size(pending) <= limit
+			})
+			kept[name], _ = json.Marshal(items[:n])
+			rest[name], _ = json.Marshal(items[n:])
+			field.Returned, field.Omitted = n, len(items)-n
+		} else {
+			n := largestFitting(len(text), minimum, func(n int) bool {
+				cut := cutText(text, n)
+				kept[name], _ = json.Marshal(cut)
+				measure.Returned, measure.Omitted = len(cut), len(text)-len(cut)
+				return size(pending) <= limit
+			})
+			cut := cutText(text, n)
+			if cut == "" && minimum > 0 {
+				cut = firstTextLine(text)
+			}
+			kept[name], _ = json.Marshal(cut)
+			rest[name], _ = json.Marshal(text[len(cut):])
+			field.Returned, field.Omitted = len(cut), len(text)-len(cut)
+		}
+		if field.Omitted == 0 {
+			kept[name] = fields[name]
+			delete(rest, name)
+			continue
+		}
+		truncated = append(truncated, field)
+	}
+	return kept, rest, truncated
+}
+
+// largestFitting returns the largest n up to max, and at least minimum,
+// for which fits reports true, given that fits holds for every n below one
+// for which it holds
+func largestFitting(max, minimum int, fits func(int) bool) int {
+	low, high := minimum, max
+	if !fits(low) {
+		return low
+	}
+	for low < high {
+		mid := (low + high + 1) / 2
+		if fits(mid) {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
+
+// cutText returns the start of text of at most n bytes, ending after a line
+// break when there is one, and never in the middle of a character
+func cutText(text string, n int) string {
+	if n >= len(text) {
+		return text
+	}
+	if n <= 0 {
+		return ""
+	}
+	if i := strings.LastIndexByte(text[:n], '\n'); i >= 0 {
+		return text[:i+1]
+	}
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n]
+}
+
+// firstTextLine returns the first line of text with its line break, or
+// its first character when even that is too long to be sent
+func firstTextLine(text string) string {
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		return text[:i+1]
+	}
+	_, size := utf8.DecodeRuneInString(text)
+	return text[:size]
+}
+
+// handleContinueResponse returns the next page of a truncated result
+func (s *MCPServer) handleContinueResponse(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.log(ctx).Info("Handling continue response", zap.String("tool", request.Params.Name))
+
+	token, err := request.RequireString("continuation_token")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid continuation_token parameter: %v", err)), nil
+	}
+	next, ok := s.continuations.take(token)
+	if !ok {
+		return toolError(types.ErrorNotFound,
+			fmt.Sprintf("Continuation %s does not exist or has expired; call the tool again", token),
+			map[string]interface{}{"continuation_token": token}), nil
+	}
+
+	limit := s.config.Server.ResponseLimits.Limit(next.tool)
+	if next.fields == nil {
+		if limit <= 0 {
+			return mcp.NewToolResultText(next.text), nil
+		}
+		return s.limitText(next.tool, next.text, limit), nil
+	}
+
+	page := make(map[string]json.RawMessage, len(next.fields)+1)
+	for name, value := range next.fields {
+		page[name] = value
+	}
+	page["continuation_of"], _ = json.Marshal(map[string]interface{}{
+		"tool":    next.tool,
+		"offsets": next.offsets,
+	})
+	text, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	if limit <= 0 {
+		return mcp.NewToolResultText(string(text)), nil
+	}
+	return s.limitResult(next.tool, string(text), next.offsets, limit), nil
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// truncation is the notice of a cut result
+type truncation struct {
+	ContinuationToken string           `json:"continuation_token"`
+	Fields            []truncatedField `json:"fields"`
+}
+
+func TestResponseLimit(t *testing.T) {
+	const limit = 2048
+	root := t.TempDir()
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.AllowedPaths = []string{root}
+		cfg.Server.ResponseLimits.DefaultBytes = limit
+	})
+
+	// Lists keep their first entries and the rest is read page by page
+	items := make([]map[string]interface{}, 100)
+	for i := range items {
+		items[i] = map[string]interface{}{"rank": i, "name": fmt.Sprintf("result-%03d", i)}
+	}
+	full, err := json.MarshalIndent(map[string]interface{}{"success": true, "results": items}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := resultText(s.limitResult("search_code", string(full), nil, limit))
+	var ranks []int
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatalf("Expected the continuations to end, got ranks %v", ranks)
+		}
+		if len(text) > limit {
+			t.Errorf("Expected page %d within %d bytes, got %d", pages, limit, len(text))
+		}
+		var page struct {
+			Results []struct {
+				Rank int `json:"rank"`
+			} `json:"results"`
+			Truncation *truncation `json:"truncation"`
+		}
+		if err := json.Unmarshal([]byte(text), &page); err != nil {
+			t.Fatalf("Invalid page: %v: %s", err, text)
+		}
+		if page.Truncation != nil {
+			field := page.Truncation.Fields[0]
+			if field.Field != "results" || field.Unit != "items" || field.Offset != len(ranks) || field.Omitted != 100-len(ranks)-field.Returned {
+				t.Errorf("Unexpected truncation of page %d: %+v", pages, field)
+			}
+		}
+		for _, result := range page.Results {
+			ranks = append(ranks, result.Rank)
+		}
+		if page.Truncation == nil {
+			break
+		}
+		if pages == 0 && !strings.Contains(text, `"success": true`) {
+			t.Errorf("Expected the fields that were not cut to be kept, got %s", text)
+		}
+		var isError bool
+		text, isError = callTool(t, s, "continue_response", map[string]interface{}{"continuation_token": page.Truncation.ContinuationToken})
+		if isError {
+			t.Fatalf("continue_response failed: %s", text)
+		}
+		if _, isError := callTool(t, s, "continue_response", map[string]interface{}{"continuation_token": page.Truncation.ContinuationToken}); !isError {
+			t.Error("Expected a continuation token to be read once")
+		}
+	}
+	if len(ranks) != 100 {
+		t.Fatalf("Expected all 100 results across the pages, got %d", len(ranks))
+	}
+	for i, rank := range ranks {
+		if rank != i {
+			t.Fatalf("Expected the results in their ranked order, got %v", ranks)
+		}
+	}
+
+	// Texts are cut at a line; tool results go through the limit, while
+	// resources read whole files
+	var content strings.Builder
+	for i := 1; i <= 200; i++ {
+		fmt.Fprintf(&content, "// line %d of the file\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(root, "big.go"), []byte(content.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if text, isError := callTool(t, s, "index_repository", map[string]interface{}{"path": root, "name": "app"}); isError {
+		t.Fatalf("Failed to index: %s", text)
+	}
+
+	var read strings.Builder
+	args := map[string]interface{}{"file_path": "big.go", "repository": "app"}
+	tool := "get_file_content"
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("Expected the continuations to end, read %d bytes", read.Len())
+		}
+		text, isError := callTool(t, s, tool, args)
+		if isError {
+			t.Fatalf("%s failed: %s", tool, text)
+		}
+		var page struct {
+			Content    string      `json:"content"`
+			Truncation *truncation `json:"truncation"`
+		}
+		if err := json.Unmarshal([]byte(text), &page); err != nil {
+			t.Fatalf("Invalid page: %v: %s", err, text)
+		}
+		if page.Truncation != nil && !strings.HasSuffix(page.Content, "\n") {
+			t.Errorf("Expected the content to be cut at a line, got %q", page.Content)
+		}
+		read.WriteString(page.Content)
+		if page.Truncation == nil {
+			break
+		}
+		tool, args = "continue_response", map[string]interface{}{"continuation_token": page.Truncation.ContinuationToken}
+	}
+	if read.String() != content.String() {
+		t.Errorf("Expected the pages to add up to the file, got %d of %d bytes", read.Len(), content.Len())
+	}
+	if text, _ := readResource(t, s, "repo://app/big.go"); text != content.String() {
+		t.Errorf("Expected the resource to read the whole file, got %d of %d bytes", len(text), content.Len())
+	}
+
+	// Results that are not JSON objects are cut with a note
+	plain := strings.Repeat("plain text line\n", 300)
+	text = resultText(s.limitResult("summarize_changes", plain, nil, limit))
+	if len(text) > limit || !strings.Contains(text, "[Truncated: ") {
+		t.Errorf("Expected a cut text with a note, got %d bytes: %s", len(text), text)
+	}
+}
+
+func TestCutText(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"one\ntwo\nthree", 20, "one\ntwo\nthree"},
+		{"one\ntwo\nthree", 9, "one\ntwo\n"},
+		{"one\ntwo\nthree", 3, "one"},
+		{"héllo", 2, "h"},
+		{"one", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := cutText(tt.text, tt.n); got != tt.want {
+			t.Errorf("cutText(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
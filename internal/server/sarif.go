@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/my-mcp/code-indexer/internal/quality"
+	"github.com/my-mcp/code-indexer/internal/sarif"
+	"github.com/my-mcp/code-indexer/internal/secrets"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// sarifInformationURI is the project page SARIF logs link the tool to
+const sarifInformationURI = "https://github.com/my-mcp/code-indexer"
+
+// complexityRuleID is the SARIF rule of functions more complex than a
+// threshold
+const complexityRuleID = "high_complexity"
+
+// getReportFormat reads the format argument of the analysis tools
+func getReportFormat(request mcp.CallToolRequest) (string, error) {
+	format := request.GetString("format", "json")
+	if format != "json" && format != "sarif" {
+		return "", fmt.Errorf("invalid format %q: use json or sarif", format)
+	}
+	return format, nil
+}
+
+// newSARIFRun returns an empty SARIF run of this server
+func (s *MCPServer) newSARIFRun() *sarif.Run {
+	return sarif.NewRun("code-indexer", s.config.Server.Version, sarifInformationURI)
+}
+
+// addSmellResults adds code smells found in a file to a SARIF run, with the
+// rules of every smell type
+func addSmellResults(run *sarif.Run, filePath string, smells []quality.Smell) {
+	for _, smellType := range quality.SmellTypes {
+		run.AddRule(sarif.Rule{
+			ID:                   smellType,
+			ShortDescription:     sarif.Message{Text: quality.SmellDescriptions[smellType]},
+			DefaultConfiguration: sarif.Configuration{Level: sarif.LevelWarning},
+			Properties:           map[string]interface{}{"tags": []string{"maintainability"}},
+		})
+	}
+	for _, smell := range smells {
+		// Smells of a symbol are recognized by it wherever it moves
+		anchor := smell.Symbol
+		if anchor == "" {
+			anchor = strconv.Itoa(smell.StartLine)
+		}
+		run.AddResult(sarif.Result{
+			RuleID:              smell.Type,
+			Level:               sarif.Level(smell.Severity),
+			Message:             sarif.Message{Text: smell.Message + ". " + smell.Suggestion + "."},
+			Locations:           []sarif.Location{sarif.FileLocation(filePath, sarif.Region{StartLine: smell.StartLine, EndLine: smell.EndLine}, smell.Symbol)},
+			PartialFingerprints: map[string]string{sarif.FingerprintKey: sarif.Fingerprint(smell.Type, filePath, anchor)},
+			Properties:          map[string]interface{}{"severity": smell.Severity, "value": smell.Value, "threshold": smell.Threshold},
+		})
+	}
+}
+
+// addSecurityResults adds hardcoded secrets to a SARIF run, with the rules
+// of the secret scanner. The findings' own fingerprints identify them.
+func addSecurityResults(run *sarif.Run, findings []types.SecurityFinding) {
+	for _, rule := range secrets.Rules() {
+		run.AddRule(sarif.Rule{
+			ID:                   rule.ID,
+			ShortDescription:     sarif.Message{Text: rule.Description},
+			Help:                 &sarif.Message{Text: "Remove the secret from the source, rotate it and load it from the environment or a secret store instead"},
+			DefaultConfiguration: sarif.Configuration{Level: sarif.Level(rule.Severity)},
+			Properties: map[string]interface{}{
+				"tags":              []string{"security", "secret"},
+				"security-severity": sarif.SecuritySeverity(rule.Severity),
+			},
+		})
+	}
+	for _, finding := range findings {
+		region := sarif.Region{StartLine: finding.Line, StartColumn: finding.Column, EndLine: finding.Line, EndColumn: finding.EndColumn}
+		run.AddResult(sarif.Result{
+			RuleID:              finding.Rule,
+			Level:               sarif.Level(finding.Severity),
+			Message:             sarif.Message{Text: fmt.Sprintf("%s: %s", finding.Description, finding.Redacted)},
+			Locations:           []sarif.Location{sarif.FileLocation(finding.FilePath, region, "")},
+			PartialFingerprints: map[string]string{sarif.FingerprintKey: finding.Fingerprint},
+			Properties:          map[string]interface{}{"severity": finding.Severity},
+		})
+	}
+}
+
+// complexitySeverity rates a function past the complexity threshold by its
+// complexity rating
+func complexitySeverity(cyclomatic int) string {
+	switch quality.Rate(cyclomatic) {
+	case quality.RatingVeryHigh:
+		return "high"
+	case quality.RatingHigh:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// addComplexityResults adds the functions whose cyclomatic complexity
+// exceeds threshold to a SARIF run
+func addComplexityResults(run *sarif.Run, functions []types.FunctionComplexity, threshold int) {
+	run.AddRule(sarif.Rule{
+		ID:                   complexityRuleID,
+		ShortDescription:     sarif.Message{Text: "Function with a cyclomatic complexity above the threshold"},
+		DefaultConfiguration: sarif.Configuration{Level: sarif.LevelWarning},
+		Properties:           map[string]interface{}{"tags": []string{"maintainability"}, "threshold": threshold},
+	})
+	for _, function := range functions {
+		if function.Cyclomatic <= threshold {
+			continue
+		}
+		name := function.Name
+		if function.ClassName != "" {
+			name = function.ClassName + "." + function.Name
+		}
+		severity := complexitySeverity(function.Cyclomatic)
+		run.AddResult(sarif.Result{
+			RuleID:              complexityRuleID,
+			Level:               sarif.Level(severity),
+			Message:             sarif.Message{Text: fmt.Sprintf("%s has a cyclomatic complexity of %d and a cognitive complexity of %d, more than %d. Split it into smaller functions.", name, function.Cyclomatic, function.Cognitive, threshold)},
+			Locations:           []sarif.Location{sarif.FileLocation(function.FilePath, sarif.Region{StartLine: function.StartLine, EndLine: function.EndLine}, name)},
+			PartialFingerprints: map[string]string{sarif.FingerprintKey: sarif.Fingerprint(complexityRuleID, function.FilePath, name)},
+			Properties:          map[string]interface{}{"severity": severity, "cyclomatic": function.Cyclomatic, "cognitive": function.Cognitive},
+		})
+	}
+}
+
+// sarifResult returns a SARIF log as the text of a tool result
+func sarifResult(runs ...*sarif.Run) (*mcp.CallToolResult, error) {
+	response, err := json.MarshalIndent(sarif.NewLog(runs...), "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError("Failed to format response"), nil
+	}
+	return mcp.NewToolResultText(string(response)), nil
+}
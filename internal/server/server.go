@@ -3,48 +3,87 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
 
+	"github.com/my-mcp/code-indexer/internal/auth"
 	"github.com/my-mcp/code-indexer/internal/config"
 	"github.com/my-mcp/code-indexer/internal/connection"
+	"github.com/my-mcp/code-indexer/internal/embeddings"
 	"github.com/my-mcp/code-indexer/internal/indexer"
+	"github.com/my-mcp/code-indexer/internal/journal"
 	"github.com/my-mcp/code-indexer/internal/locking"
+	"github.com/my-mcp/code-indexer/internal/logging"
+	"github.com/my-mcp/code-indexer/internal/lsp"
 	"github.com/my-mcp/code-indexer/internal/models"
 	"github.com/my-mcp/code-indexer/internal/repository"
 	"github.com/my-mcp/code-indexer/internal/search"
 	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// Timings of the MCP HTTP transport
+const (
+	httpHeartbeatInterval = 30 * time.Second // Keeps idle streams open through proxies
+	httpShutdownTimeout   = 5 * time.Second
 )
 
 // MCPServer wraps the MCP server with our application logic
 type MCPServer struct {
 	server            *server.MCPServer
+	hooks             *server.Hooks // Hooks of server, added to as features are registered
 	config            *config.Config
 	logger            *zap.Logger
 	indexer           *indexer.Indexer
+	jobs              *indexer.JobQueue // Background indexing jobs of index_repository async=true
 	repoMgr           *repository.Manager
 	searcher          *search.Engine
+	embeddings        *embeddings.Index // nil unless embeddings are enabled
+	journal           *journal.Journal  // Edits made by the file manipulation tools
+	lsp               *lsp.Manager      // Language servers behind the lsp_* tools
 	modelsEngine      *models.Engine
 	sessionManager    *session.Manager
 	sessionContext    *session.SessionContext
 	connectionManager *connection.Manager
-	lockManager       *locking.Manager
+	lockManager       *locking.Manager  // nil unless multi-IDE support is enabled
+	lockCalls         atomic.Int64      // Numbers the lock owners of tool calls
+	permissions       *auth.Permissions // Profiles of the tools each caller may use
+	defaultSession    *session.Session
+	tempDir           string                            // Removed on Close; set in memory index mode
+	handlers          map[string]server.ToolHandlerFunc // Registered tool handlers by name, shared with the daemon API
+	utilityTools      int                               // Number of handlers registered by registerUtilityTools
+	startedAt         time.Time                         // Reported as uptime by /api/health
+	httpServer        *http.Server                      // Daemon HTTP server, shut down by Close; nil until ServeDaemon
+	watchers          map[string]*repositoryWatcher     // Watchers of the configured repositories with watch set, by name
+	resourceURIs      string                            // URIs of the listed resources, one per line; guarded by resourcesMutex
+	resourcesMutex    sync.Mutex                        // Serializes refreshes of the listed resources
+	continuations     continuationStore                 // Rest of the results cut to the response size limit
 	mutex             sync.RWMutex
 }
 
 // New creates a new MCP server instance
 func New(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 	// Create MCP server with configuration
+	hooks := &server.Hooks{}
 	opts := []server.ServerOption{
 		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, true),
+		server.WithPromptCapabilities(false),
+		server.WithHooks(hooks),
 	}
 
 	// Always enable recovery for stability
@@ -57,26 +96,49 @@ func New(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 	)
 
 	// Initialize components
-	repoMgr, err := repository.NewManager("./repositories", logger)
+	repoDir, indexDir, err := storageDirs(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create repository manager: %w", err)
+		return nil, err
 	}
-
-	searcher, err := search.NewEngine("./index", logger)
+	repoMgr, searcher, tempDir, err := openStorage(cfg, repoDir, indexDir, logger)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create search engine: %w", err)
+		return nil, err
+	}
+	if err := repoMgr.SetLanguageOverrides(cfg.Indexer.LanguageOverrides); err != nil {
+		return nil, fmt.Errorf("invalid indexer.language_overrides: %w", err)
+	}
+	if err := repoMgr.SetIgnoreRules(cfg.Indexer.IgnorePatterns, cfg.Indexer.GlobalGitignore); err != nil {
+		return nil, fmt.Errorf("invalid indexer.ignore_patterns: %w", err)
 	}
 
 	idx, err := indexer.New(cfg, repoMgr, searcher, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create indexer: %w", err)
 	}
+	if err := enableMetadataStore(idx, cfg, indexDir); err != nil {
+		return nil, err
+	}
+	searcher.SetContentLoader(idx.ReadIndexedFile)
+	searcher.SetCacheSize(cfg.Search.CacheSize)
+
+	embeddingsIndex, err := openEmbeddings(cfg, indexDir, logger)
+	if err != nil {
+		return nil, err
+	}
+	if embeddingsIndex != nil {
+		idx.EnableEmbeddings(embeddingsIndex)
+	}
 
 	modelsEngine, err := models.NewEngine(&cfg.Models, idx, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create models engine: %w", err)
 	}
 
+	permissions, err := auth.NewPermissions(cfg.Server.Permissions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server.permissions: %w", err)
+	}
+
 	// Create session manager if multi-session is enabled
 	var sessionManager *session.Manager
 	var sessionContext *session.SessionContext
@@ -98,9 +160,10 @@ func New(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 			zap.String("isolation_mode", cfg.Server.MultiIDE.ResourceManagement.IsolationMode))
 	}
 
-	// Create lock manager if fine-grained locking is enabled
+	// Create lock manager; without fine-grained locking every lock is
+	// taken on the whole index
 	var lockManager *locking.Manager
-	if cfg.Server.MultiIDE.Enabled && cfg.Server.MultiIDE.Locking.EnableFineGrainedLocks {
+	if cfg.Server.MultiIDE.Enabled {
 		lockConfig := &locking.LockConfig{
 			DefaultTimeout:      time.Duration(cfg.Server.MultiIDE.Locking.LockTimeoutSeconds) * time.Second,
 			MaxLockDuration:     5 * time.Minute,
@@ -110,28 +173,42 @@ func New(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 		}
 		lockManager = locking.NewManager(lockConfig, logger)
 		logger.Info("Resource locking enabled",
+			zap.Bool("fine_grained", cfg.Server.MultiIDE.Locking.EnableFineGrainedLocks),
 			zap.Duration("default_timeout", lockConfig.DefaultTimeout),
 			zap.Bool("deadlock_detection", lockConfig.EnableDeadlockCheck))
 	}
 
 	s := &MCPServer{
 		server:            mcpServer,
+		hooks:             hooks,
 		config:            cfg,
 		logger:            logger,
 		indexer:           idx,
+		jobs:              indexer.NewJobQueue(idx, cfg.Indexer.Jobs.Workers, cfg.Indexer.Jobs.QueueSize, logger),
 		repoMgr:           repoMgr,
 		searcher:          searcher,
+		embeddings:        embeddingsIndex,
+		journal:           journal.New(repoMgr, journal.DefaultMaxEntries),
+		lsp:               lsp.NewManager(cfg.LSP, logger),
 		modelsEngine:      modelsEngine,
 		sessionManager:    sessionManager,
 		sessionContext:    sessionContext,
 		connectionManager: connectionManager,
 		lockManager:       lockManager,
+		permissions:       permissions,
+		tempDir:           tempDir,
+		startedAt:         time.Now(),
+	}
+	if lockManager != nil {
+		idx.EnableRepositoryLocks(s.lockRepository)
 	}
 
 	// Register MCP tools
 	if err := s.registerTools(); err != nil {
 		return nil, fmt.Errorf("failed to register tools: %w", err)
 	}
+	s.registerResources()
+	s.registerPrompts()
 
 	return s, nil
 }
@@ -139,8 +216,12 @@ func New(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 // NewForUVX creates a new MCP server instance optimized for uvx execution
 func NewForUVX(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 	// Create MCP server with uvx-optimized configuration
+	hooks := &server.Hooks{}
 	opts := []server.ServerOption{
 		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, true),
+		server.WithPromptCapabilities(false),
+		server.WithHooks(hooks),
 	}
 
 	// Always enable recovery for stability
@@ -152,33 +233,23 @@ func NewForUVX(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 		opts...,
 	)
 
-	// Use relative paths that work better with uvx execution
-	repoDir := cfg.Indexer.RepoDir
-	if repoDir == "" {
-		repoDir = "./repositories"
-	}
-
-	indexDir := cfg.Indexer.IndexDir
-	if indexDir == "" {
-		indexDir = "./index"
-	}
-
-	// Initialize components with uvx-friendly paths
-	logger.Debug("🗂️ Initializing repository manager...", zap.String("repo_dir", repoDir))
-	repoMgr, err := repository.NewManager(repoDir, logger)
+	repoDir, indexDir, err := storageDirs(cfg)
 	if err != nil {
-		logger.Error("❌ Failed to initialize repository manager", zap.Error(err))
-		return nil, fmt.Errorf("failed to create repository manager: %w", err)
+		logger.Error("❌ Failed to resolve data directories", zap.Error(err))
+		return nil, err
 	}
-	logger.Debug("✅ Repository manager initialized successfully")
 
-	logger.Debug("🔍 Initializing search engine...", zap.String("index_dir", indexDir))
-	searcher, err := search.NewEngine(indexDir, logger)
+	// Initialize components with uvx-friendly paths
+	logger.Debug("🗂️ Initializing repository manager and search engine...",
+		zap.String("repo_dir", repoDir),
+		zap.String("index_dir", indexDir),
+		zap.Bool("memory_index", cfg.Indexer.MemoryIndex))
+	repoMgr, searcher, tempDir, err := openStorage(cfg, repoDir, indexDir, logger)
 	if err != nil {
-		logger.Error("❌ Failed to initialize search engine", zap.Error(err))
-		return nil, fmt.Errorf("failed to create search engine: %w", err)
+		logger.Error("❌ Failed to initialize storage", zap.Error(err))
+		return nil, err
 	}
-	logger.Debug("✅ Search engine initialized successfully")
+	logger.Debug("✅ Repository manager and search engine initialized successfully")
 
 	logger.Debug("📇 Initializing code indexer...")
 	idx, err := indexer.New(cfg, repoMgr, searcher, logger)
@@ -186,8 +257,23 @@ func NewForUVX(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 		logger.Error("❌ Failed to initialize code indexer", zap.Error(err))
 		return nil, fmt.Errorf("failed to create indexer: %w", err)
 	}
+	if err := enableMetadataStore(idx, cfg, indexDir); err != nil {
+		logger.Error("❌ Failed to load repository metadata", zap.Error(err))
+		return nil, err
+	}
+	searcher.SetContentLoader(idx.ReadIndexedFile)
+	searcher.SetCacheSize(cfg.Search.CacheSize)
 	logger.Debug("✅ Code indexer initialized successfully")
 
+	embeddingsIndex, err := openEmbeddings(cfg, indexDir, logger)
+	if err != nil {
+		logger.Error("❌ Failed to initialize embeddings", zap.Error(err))
+		return nil, err
+	}
+	if embeddingsIndex != nil {
+		idx.EnableEmbeddings(embeddingsIndex)
+	}
+
 	// Initialize models engine with safe defaults for uvx mode
 	// Force disable models for uvx to avoid initialization issues
 	cfg.Models.Enabled = false
@@ -212,18 +298,31 @@ func NewForUVX(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 
 	logger.Debug("UVX mode: Multi-session and multi-IDE features disabled for process isolation")
 
+	permissions, err := auth.NewPermissions(cfg.Server.Permissions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server.permissions: %w", err)
+	}
+
 	s := &MCPServer{
 		server:            mcpServer,
+		hooks:             hooks,
 		config:            cfg,
 		logger:            logger,
 		indexer:           idx,
+		jobs:              indexer.NewJobQueue(idx, cfg.Indexer.Jobs.Workers, cfg.Indexer.Jobs.QueueSize, logger),
 		repoMgr:           repoMgr,
 		searcher:          searcher,
+		embeddings:        embeddingsIndex,
+		journal:           journal.New(repoMgr, journal.DefaultMaxEntries),
+		lsp:               lsp.NewManager(cfg.LSP, logger),
 		modelsEngine:      modelsEngine,
 		sessionManager:    sessionManager,
 		sessionContext:    sessionContext,
 		connectionManager: connectionManager,
 		lockManager:       lockManager,
+		permissions:       permissions,
+		tempDir:           tempDir,
+		startedAt:         time.Now(),
 	}
 
 	// Register MCP tools
@@ -232,6 +331,8 @@ func NewForUVX(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 		return nil, fmt.Errorf("failed to register tools: %w", err)
 	}
 	logger.Debug("MCP tools registered successfully")
+	s.registerResources()
+	s.registerPrompts()
 
 	// Register MCP protocol handlers
 	if err := s.registerMCPHandlers(); err != nil {
@@ -241,6 +342,230 @@ func NewForUVX(cfg *config.Config, logger *zap.Logger) (*MCPServer, error) {
 	return s, nil
 }
 
+// Indexer returns the indexer of the server, for command line tools that
+// work on the index without an MCP client
+func (s *MCPServer) Indexer() *indexer.Indexer {
+	return s.indexer
+}
+
+// Searcher returns the search engine of the server
+func (s *MCPServer) Searcher() *search.Engine {
+	return s.searcher
+}
+
+// RankQuery sets the boosts a search query is ranked with from
+// search.ranking and search.popularity_weight, and its scoring profile to
+// search.ranking.profile unless the query selects one
+func (s *MCPServer) RankQuery(query *types.SearchQuery) {
+	ranking := s.config.Search.Ranking
+	query.Ranking = types.Ranking{
+		NameBoost:       ranking.NameBoost,
+		ExactNameBoost:  ranking.ExactNameBoost,
+		ContentBoost:    ranking.ContentBoost,
+		PathBoost:       ranking.PathBoost,
+		TypeBoosts:      ranking.TypeBoosts,
+		RecencyWeight:   ranking.RecencyWeight,
+		RecencyHalfLife: time.Duration(ranking.RecencyHalfLifeDays) * 24 * time.Hour,
+	}
+	query.PopularityWeight = s.config.Search.PopularityWeight
+	if query.Profile == "" {
+		query.Profile = ranking.Profile
+	}
+}
+
+// storageDirs returns the repository and index directories of the
+// configuration, resolved against the data directory unless the index lives
+// in memory
+func storageDirs(cfg *config.Config) (string, string, error) {
+	if cfg.Indexer.MemoryIndex {
+		return cfg.Indexer.RepoDir, cfg.Indexer.IndexDir, nil
+	}
+	if err := cfg.Indexer.ResolveDirs(); err != nil {
+		return "", "", fmt.Errorf("failed to resolve data directories: %w", err)
+	}
+	return cfg.Indexer.RepoDir, cfg.Indexer.IndexDir, nil
+}
+
+// openStorage creates the repository manager and search engine. In memory
+// index mode the index lives only in memory and repositories are cloned into
+// a temporary directory, returned as tempDir, that Close removes.
+func openStorage(cfg *config.Config, repoDir, indexDir string, logger *zap.Logger) (*repository.Manager, *search.Engine, string, error) {
+	if !cfg.Indexer.MemoryIndex {
+		repoMgr, err := repository.NewManager(repoDir, logger)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to create repository manager: %w", err)
+		}
+
+		if err := enableCloneCache(repoMgr, cfg, repoDir); err != nil {
+			return nil, nil, "", err
+		}
+		if err := configureClones(repoMgr, cfg); err != nil {
+			return nil, nil, "", err
+		}
+		if err := allowConfiguredPaths(repoMgr, cfg); err != nil {
+			return nil, nil, "", err
+		}
+
+		searcher, err := search.NewEngineWithStorage(indexDir, cfg.SearchStorage(), logger)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to create search engine: %w", err)
+		}
+		return repoMgr, searcher, "", nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "code-indexer-")
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to create temporary repository directory: %w", err)
+	}
+
+	repoDir = filepath.Join(tempDir, "repositories")
+	repoMgr, err := repository.NewManager(repoDir, logger)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, nil, "", fmt.Errorf("failed to create repository manager: %w", err)
+	}
+	if err := enableCloneCache(repoMgr, cfg, repoDir); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, nil, "", err
+	}
+	if err := configureClones(repoMgr, cfg); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, nil, "", err
+	}
+	if err := allowConfiguredPaths(repoMgr, cfg); err != nil {
+		os.RemoveAll(tempDir)
+		return nil, nil, "", err
+	}
+
+	searcher, err := search.NewMemoryEngine(cfg.SearchStorage(), logger)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, nil, "", fmt.Errorf("failed to create search engine: %w", err)
+	}
+
+	logger.Info("Memory index mode enabled, nothing is kept after exit", zap.String("temp_dir", tempDir))
+	return repoMgr, searcher, tempDir, nil
+}
+
+// openEmbeddings creates the embeddings index when embeddings are enabled.
+// Vectors are stored next to the keyword index, or only in memory in memory
+// index mode.
+func openEmbeddings(cfg *config.Config, indexDir string, logger *zap.Logger) (*embeddings.Index, error) {
+	if !cfg.Embeddings.Enabled {
+		return nil, nil
+	}
+
+	storePath := filepath.Clean(indexDir) + ".embeddings"
+	if cfg.Indexer.MemoryIndex {
+		storePath = ""
+	}
+
+	index, err := embeddings.New(&cfg.Embeddings, storePath, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embeddings index: %w", err)
+	}
+	return index, nil
+}
+
+// enableMetadataStore persists repository records, indexing history and
+// settings next to the search index. In memory index mode they are kept in
+// memory only.
+func enableMetadataStore(idx *indexer.Indexer, cfg *config.Config, indexDir string) error {
+	if cfg.Indexer.MemoryIndex {
+		return nil
+	}
+
+	path := filepath.Clean(indexDir) + ".repositories.json"
+	if err := idx.EnableMetadataStore(path); err != nil {
+		return fmt.Errorf("failed to enable repository metadata store: %w", err)
+	}
+	return nil
+}
+
+// allowConfiguredPaths opens the directories in server.allowed_paths to the
+// file tools in addition to the indexed repositories, and lets local
+// repositories below them be indexed. Without allowed paths, local
+// repositories may be indexed from the working directory, unless that is
+// the filesystem root.
+func allowConfiguredPaths(repoMgr *repository.Manager, cfg *config.Config) error {
+	for _, allowed := range cfg.Server.AllowedPaths {
+		if err := repoMgr.RegisterRoot(allowed); err != nil {
+			return fmt.Errorf("failed to allow path: %w", err)
+		}
+		if err := repoMgr.AllowLocalRepositories(allowed); err != nil {
+			return fmt.Errorf("failed to allow path: %w", err)
+		}
+	}
+	if len(cfg.Server.AllowedPaths) > 0 {
+		return nil
+	}
+
+	wd, err := os.Getwd()
+	if err != nil || filepath.Dir(wd) == wd {
+		return nil
+	}
+	return repoMgr.AllowLocalRepositories(wd)
+}
+
+// enableCloneCache turns on the shared git object cache when configured. In
+// memory index mode the configured directory is ignored so nothing outlives
+// the temporary repository directory.
+func enableCloneCache(repoMgr *repository.Manager, cfg *config.Config, repoDir string) error {
+	cache := cfg.Indexer.CloneCache
+	if !cache.Enabled {
+		return nil
+	}
+
+	dir := cache.Dir
+	if dir == "" || cfg.Indexer.MemoryIndex {
+		dir = filepath.Join(repoDir, ".object-cache")
+	}
+	maxAge := time.Duration(cache.MaxAgeDays) * 24 * time.Hour
+	if err := repoMgr.EnableObjectCache(dir, maxAge); err != nil {
+		return fmt.Errorf("failed to enable clone cache: %w", err)
+	}
+	return nil
+}
+
+// configureClones sets how remote repositories are cloned and the
+// credentials of indexer.clone. Tokens given through token_env are read from
+// the environment here, so a missing variable is reported at startup, and
+// "~" and environment variables are expanded in key paths.
+func configureClones(repoMgr *repository.Manager, cfg *config.Config) error {
+	clone := cfg.Indexer.Clone
+	credentials := make([]repository.Credential, 0, len(clone.Credentials))
+	for _, credential := range clone.Credentials {
+		token := credential.Token
+		if credential.TokenEnv != "" {
+			token = os.Getenv(credential.TokenEnv)
+			if token == "" {
+				return fmt.Errorf("clone credentials for %s: environment variable %s is not set", credential.Host, credential.TokenEnv)
+			}
+		}
+		sshKey := credential.SSHKey
+		if sshKey != "" {
+			expanded, err := config.ExpandPath(sshKey)
+			if err != nil {
+				return fmt.Errorf("clone credentials for %s: invalid ssh_key %s: %w", credential.Host, sshKey, err)
+			}
+			sshKey = expanded
+		}
+		credentials = append(credentials, repository.Credential{
+			Host:             credential.Host,
+			Username:         credential.Username,
+			Token:            token,
+			SSHKey:           sshKey,
+			SSHKeyPassphrase: credential.SSHKeyPassphrase,
+		})
+	}
+
+	defaults := repository.CloneOptions{Depth: clone.Depth, SingleBranch: clone.SingleBranch}
+	if err := repoMgr.SetCloneOptions(defaults, credentials); err != nil {
+		return fmt.Errorf("invalid indexer.clone: %w", err)
+	}
+	return nil
+}
+
 // registerMCPHandlers registers explicit MCP protocol handlers
 func (s *MCPServer) registerMCPHandlers() error {
 	s.logger.Debug("Registering MCP protocol handlers...")
@@ -280,7 +605,8 @@ func (s *MCPServer) Serve() error {
 	return server.ServeStdio(s.server)
 }
 
-// ServeDaemon starts the MCP server as a daemon listening on TCP port
+// ServeDaemon starts the MCP server as a daemon listening on TCP port. It
+// returns http.ErrServerClosed once Close has shut the daemon down.
 func (s *MCPServer) ServeDaemon(host string, port int) error {
 	s.logger.Info("Starting MCP daemon server",
 		zap.String("name", s.config.Server.Name),
@@ -294,25 +620,170 @@ func (s *MCPServer) ServeDaemon(host string, port int) error {
 	// Handle MCP API endpoints
 	mux.HandleFunc("/api/tools", s.handleToolsAPI)
 	mux.HandleFunc("/api/call", s.handleToolCall)
+	mux.HandleFunc("/api/call/stream", s.handleToolCallStream)
 	mux.HandleFunc("/api/health", s.handleHealthCheck)
 	mux.HandleFunc("/api/sessions", s.handleSessionsAPI)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+
+	handler, err := s.protect(mux)
+	if err != nil {
+		return err
+	}
 
 	// Create HTTP server
 	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	httpServer := &http.Server{
 		Addr:    addr,
-		Handler: mux,
+		Handler: handler,
 	}
+	s.mutex.Lock()
+	s.httpServer = httpServer
+	s.mutex.Unlock()
 
-	s.logger.Info("MCP daemon listening", zap.String("address", addr))
+	s.logger.Info("MCP daemon listening",
+		zap.String("address", addr),
+		zap.Bool("tls", s.config.Server.TLS.Enabled()))
 
+	return s.listenAndServe(httpServer)
+}
+
+// protect wraps the handler of a network endpoint with the CORS policy and
+// API key authentication, after giving each request its request ID. CORS
+// comes first so preflight requests, which carry no credentials, are
+// answered.
+func (s *MCPServer) protect(handler http.Handler) (http.Handler, error) {
+	authenticator, err := auth.New(s.config.Server.Auth, s.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure authentication: %w", err)
+	}
+	cors := auth.NewCORS(s.config.Server.CORS.AllowedOrigins)
+	return logging.Middleware(s.logger, cors.Middleware(authenticator.Middleware(handler))), nil
+}
+
+// listenAndServe serves HTTPS when a certificate and key are configured and
+// plain HTTP otherwise
+func (s *MCPServer) listenAndServe(httpServer *http.Server) error {
+	if tls := s.config.Server.TLS; tls.Enabled() {
+		return httpServer.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+	}
 	return httpServer.ListenAndServe()
 }
 
+// ServeHTTPTransport serves the MCP protocol over HTTP until ctx is
+// cancelled: the Streamable HTTP transport at endpointPath, and the older
+// HTTP+SSE transport at /sse and /message for clients that predate it
+func (s *MCPServer) ServeHTTPTransport(ctx context.Context, host string, port int, endpointPath string) error {
+	s.log(ctx).Info("Starting MCP HTTP server",
+		zap.String("name", s.config.Server.Name),
+		zap.String("version", s.config.Server.Version),
+		zap.String("host", host),
+		zap.Int("port", port),
+		zap.String("endpoint", endpointPath))
+
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	httpServer := &http.Server{Addr: addr}
+	handler, sse, err := s.httpTransportHandler(endpointPath, httpServer)
+	if err != nil {
+		return err
+	}
+	httpServer.Handler = handler
+
+	scheme := "http://"
+	if s.config.Server.TLS.Enabled() {
+		scheme = "https://"
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.listenAndServe(httpServer)
+	}()
+	s.log(ctx).Info("MCP HTTP server listening",
+		zap.String("streamable_http", scheme+addr+endpointPath),
+		zap.String("sse", scheme+addr+"/sse"))
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Closing the SSE sessions ends their streams, which would otherwise
+	// keep the shutdown waiting
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := sse.Shutdown(shutdownCtx); err != nil {
+		s.log(ctx).Warn("HTTP server did not shut down cleanly, closing connections", zap.Error(err))
+		return httpServer.Close()
+	}
+	return nil
+}
+
+// httpReservedPaths are served by ServeHTTPTransport besides the Streamable
+// HTTP endpoint
+var httpReservedPaths = []string{"/sse", "/message", "/api/health"}
+
+// ValidateHTTPEndpointPath checks an endpoint path for the Streamable HTTP
+// transport before ServeHTTPTransport is called with it
+func ValidateHTTPEndpointPath(endpointPath string) error {
+	return config.ValidateEndpointPath(endpointPath, httpReservedPaths...)
+}
+
+// httpTransportHandler returns the handler of ServeHTTPTransport. The SSE
+// server is returned so its sessions can be closed when httpServer, which
+// may be nil in tests, shuts down.
+func (s *MCPServer) httpTransportHandler(endpointPath string, httpServer *http.Server) (http.Handler, *server.SSEServer, error) {
+	if err := ValidateHTTPEndpointPath(endpointPath); err != nil {
+		return nil, nil, err
+	}
+
+	streamable := server.NewStreamableHTTPServer(s.server,
+		server.WithHeartbeatInterval(httpHeartbeatInterval),
+	)
+	sseOptions := []server.SSEOption{server.WithKeepAlive(true)}
+	if httpServer != nil {
+		sseOptions = append(sseOptions, server.WithHTTPServer(httpServer))
+	}
+	sse := server.NewSSEServer(s.server, sseOptions...)
+
+	mux := http.NewServeMux()
+	mux.Handle(endpointPath, streamable)
+	mux.Handle("/sse", sse.SSEHandler())
+	mux.Handle("/message", sse.MessageHandler())
+	mux.HandleFunc("/api/health", s.handleHealthCheck)
+
+	handler, err := s.protect(mux)
+	if err != nil {
+		return nil, nil, err
+	}
+	return handler, sse, nil
+}
+
 // Close gracefully shuts down the server
 func (s *MCPServer) Close() error {
 	s.logger.Info("Shutting down MCP server")
 
+	// Stop accepting daemon requests and let running ones finish before the
+	// components they use are closed
+	s.mutex.Lock()
+	httpServer := s.httpServer
+	s.httpServer = nil
+	s.mutex.Unlock()
+	if httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		if err := httpServer.Shutdown(ctx); err != nil {
+			s.logger.Warn("Daemon did not shut down cleanly, closing connections", zap.Error(err))
+			httpServer.Close()
+		}
+		cancel()
+	}
+
+	// Stop re-indexing watched repositories, then cancel background
+	// indexing before the index is closed under it
+	s.stopWatchers(func(string) bool { return true })
+	s.jobs.Close()
+
+	// Shut down the language servers so their processes do not outlive us
+	s.lsp.Close()
+
 	// Close connection manager if enabled
 	if s.connectionManager != nil {
 		if err := s.connectionManager.Close(); err != nil {
@@ -336,21 +807,35 @@ func (s *MCPServer) Close() error {
 		s.logger.Error("Failed to close search engine", zap.Error(err))
 	}
 
+	if s.embeddings != nil {
+		if err := s.embeddings.Close(); err != nil {
+			s.logger.Error("Failed to save embeddings", zap.Error(err))
+		}
+	}
+
 	if err := s.modelsEngine.Close(); err != nil {
 		s.logger.Error("Failed to close models engine", zap.Error(err))
 	}
 
+	if s.tempDir != "" {
+		if err := os.RemoveAll(s.tempDir); err != nil {
+			s.logger.Error("Failed to remove temporary directory", zap.String("path", s.tempDir), zap.Error(err))
+		} else {
+			s.logger.Info("Removed temporary directory", zap.String("path", s.tempDir))
+		}
+	}
+
 	return nil
 }
 
 // HTTP API handlers for daemon mode
 
+// errUnknownTool is returned by executeToolCall for tools that are not registered
+var errUnknownTool = errors.New("unknown tool")
+
 // handleToolsAPI handles the /api/tools endpoint - lists all available tools
 func (s *MCPServer) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
@@ -358,7 +843,7 @@ func (s *MCPServer) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		auth.WriteError(w, http.StatusMethodNotAllowed, types.ErrorInvalidArgument, "Method not allowed")
 		return
 	}
 
@@ -370,31 +855,85 @@ func (s *MCPServer) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 		{"name": "get_metadata", "category": "core", "description": "Get detailed metadata for specific files"},
 		{"name": "list_repositories", "category": "core", "description": "List all indexed repositories with statistics"},
 		{"name": "get_index_stats", "category": "core", "description": "Get indexing statistics and information"},
+		{"name": "indexing_history", "category": "core", "description": "Get per-phase timings of past indexing runs"},
+		{"name": "get_indexing_progress", "category": "core", "description": "Get the progress of a background indexing job"},
+		{"name": "cancel_indexing", "category": "core", "description": "Cancel a background indexing job"},
+		{"name": "remove_repository", "category": "core", "description": "Remove a repository from the index, optionally deleting its clone"},
+		{"name": "cleanup_orphans", "category": "core", "description": "Remove indexed repositories whose source path no longer exists"},
+		{"name": "optimize_index", "category": "core", "description": "Compact the search index and report the space reclaimed"},
+		{"name": "verify_index", "category": "core", "description": "Check indexed files against disk and optionally repair them"},
+		{"name": "switch_ref", "category": "core", "description": "Check out another ref of a cloned repository and re-index the difference"},
+		{"name": "index_dependencies", "category": "core", "description": "List a repository's dependencies and index their sources for include_deps searches"},
+		{"name": "sync_configured_repositories", "category": "core", "description": "Re-read the repositories section of the configuration and index and watch them"},
 
 		// Utility tools
 		{"name": "find_files", "category": "utility", "description": "Find files matching patterns with wildcards"},
-		{"name": "find_symbols", "category": "utility", "description": "Find symbols (functions, classes, variables) by name"},
+		{"name": "find_symbols", "category": "utility", "description": "Find symbols (functions, classes, interfaces, type aliases, variables) by name"},
+		{"name": "complete_symbol", "category": "utility", "description": "Complete a partial symbol name from the indexed names"},
+		{"name": "save_search", "category": "utility", "description": "Save search_code arguments under a name"},
+		{"name": "list_saved_searches", "category": "utility", "description": "List the saved searches and recent searches of the session"},
+		{"name": "run_saved_search", "category": "utility", "description": "Run a saved search"},
+		{"name": "set_workspace", "category": "utility", "description": "Group indexed repositories into a named workspace"},
+		{"name": "list_workspaces", "category": "utility", "description": "List the workspaces and the session's default workspace"},
+		{"name": "use_workspace", "category": "utility", "description": "Scope the session's searches to a workspace"},
+		{"name": "list_projects", "category": "utility", "description": "List the sub-projects of a repository"},
+		{"name": "export_index", "category": "utility", "description": "Export repository indexes and their metadata to an archive file"},
+		{"name": "import_index", "category": "utility", "description": "Import the repository indexes of an archive file"},
+		{"name": "get_file_outline", "category": "utility", "description": "Get the symbol tree of a file with line ranges, signatures and doc strings"},
+		{"name": "goto_definition", "category": "utility", "description": "Resolve a name used in a file to its definition through the file's imports"},
+		{"name": "lsp_hover", "category": "utility", "description": "Get the type and documentation of a symbol from the language server"},
+		{"name": "lsp_definition", "category": "utility", "description": "Find where a symbol is defined using the language server"},
+		{"name": "lsp_references", "category": "utility", "description": "Find the references to a symbol using the language server"},
+		{"name": "lsp_diagnostics", "category": "utility", "description": "Get the errors and warnings the language server reports for a file"},
+		{"name": "get_diagnostics", "category": "utility", "description": "Run the configured compilers and linters over a repository or file and return their errors"},
+		{"name": "run_tests", "category": "utility", "description": "Run a repository's tests and return pass or fail, duration and failure output per test"},
+		{"name": "find_dependencies", "category": "utility", "description": "Map the import graph of a repository, file or package, with reverse dependencies and cycles"},
+		{"name": "get_chunk_graph", "category": "utility", "description": "Map which indexed chunks use the functions and classes other chunks define"},
+		{"name": "detect_code_smells", "category": "utility", "description": "Find long functions, long parameter lists, deep nesting, large classes and duplicated code in a file"},
+		{"name": "analyze_complexity", "category": "utility", "description": "Measure cyclomatic, cognitive and Halstead complexity per function, with repository aggregates"},
+		{"name": "list_security_findings", "category": "utility", "description": "List hardcoded secrets found while indexing, filtered by severity, rule and path"},
+		{"name": "detect_security_issues", "category": "utility", "description": "Scan a file for hardcoded secrets or summarize a repository's findings"},
+		{"name": "generate_metrics_report", "category": "utility", "description": "Report size, complexity, code smells and secrets of a file or repository, as JSON or SARIF"},
+		{"name": "analyze_test_coverage", "category": "utility", "description": "Find a source file's tests, its untested exported symbols and per-function coverage gaps"},
 		{"name": "get_file_content", "category": "utility", "description": "Get full content of specific files with line ranges"},
 		{"name": "list_directory", "category": "utility", "description": "List files and directories in specific paths"},
 		{"name": "delete_lines", "category": "utility", "description": "Delete a range of lines within a file"},
 		{"name": "insert_at_line", "category": "utility", "description": "Insert content at a given line in a file"},
 		{"name": "replace_lines", "category": "utility", "description": "Replace a range of lines with new content"},
+		{"name": "replace_symbol_body", "category": "utility", "description": "Replace the body of a function, method or type by name"},
+		{"name": "insert_after_symbol", "category": "utility", "description": "Insert code after a named declaration"},
+		{"name": "insert_before_symbol", "category": "utility", "description": "Insert code before a named declaration and its doc comment"},
+		{"name": "rename_symbol", "category": "utility", "description": "Rename a symbol at its definition and every reference in its repository"},
+		{"name": "undo_last_edit", "category": "utility", "description": "Undo the most recent edit made by the file manipulation tools"},
+		{"name": "redo_edit", "category": "utility", "description": "Reapply the most recently undone edit"},
+		{"name": "list_edit_history", "category": "utility", "description": "List the edits made by the file manipulation tools"},
 		{"name": "get_file_snippet", "category": "utility", "description": "Extract a specific code snippet from a file"},
 		{"name": "find_references", "category": "utility", "description": "Find all references to a symbol across indexed repositories"},
 		{"name": "refresh_index", "category": "utility", "description": "Refresh the search index for specific repositories or all repositories"},
 		{"name": "git_blame", "category": "utility", "description": "Get Git blame information for a specific file or file range"},
+		{"name": "git_diff", "category": "utility", "description": "Get a structured diff between commits, the working tree or staged changes"},
+		{"name": "sync_buffer", "category": "utility", "description": "Sync unsaved editor contents so tools read the buffer instead of disk"},
+		{"name": "resolve_stacktrace", "category": "utility", "description": "Map a pasted stack trace to indexed files, symbols and snippets"},
+		{"name": "semantic_search", "category": "utility", "description": "Find code by meaning using embeddings of indexed chunks"},
+		{"name": "get_context_bundle", "category": "utility", "description": "Assemble a symbol's code, imports, callees, callers and related chunks within a token budget"},
+		{"name": "ask_codebase", "category": "utility", "description": "Answer a natural language question with ranked, cited evidence from the index"},
+		{"name": "grep_repository", "category": "utility", "description": "Search repository files on disk for text or a regex, with context lines"},
 
 		// Project management tools
 		{"name": "get_current_config", "category": "project", "description": "Get the current configuration of the agent"},
 		{"name": "initial_instructions", "category": "project", "description": "Get the initial instructions for the current project"},
 		{"name": "remove_project", "category": "project", "description": "Remove a project from the configuration"},
-		{"name": "restart_language_server", "category": "project", "description": "Restart the language server"},
+		{"name": "restart_language_server", "category": "project", "description": "Restart the running language servers"},
+		{"name": "set_log_level", "category": "project", "description": "Change the level of the server's logs while it runs"},
 		{"name": "summarize_changes", "category": "project", "description": "Provide instructions for summarizing codebase changes"},
+		{"name": "get_capabilities", "category": "project", "description": "Report the languages, features and limits this deployment supports"},
 
 		// AI tools
 		{"name": "generate_code", "category": "ai", "description": "Generate code from natural language descriptions using AI"},
 		{"name": "analyze_code", "category": "ai", "description": "Analyze code quality and get AI suggestions"},
 		{"name": "explain_code", "category": "ai", "description": "Get AI explanations of code functionality"},
+		{"name": "summarize_diff", "category": "ai", "description": "Write a commit message or pull request description for a diff"},
+		{"name": "generate_tests", "category": "ai", "description": "Write unit tests for a source file in the repository's test framework"},
 	}
 
 	// Add session management tools if enabled
@@ -407,13 +946,28 @@ func (s *MCPServer) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 		tools = append(tools, sessionTools...)
 	}
 
+	// Drop the write tools in read-only mode and the tools the permission
+	// profile of the caller does not allow
+	kept := tools[:0]
+	for _, tool := range tools {
+		name := tool["name"].(string)
+		if s.config.Server.ReadOnly && isWriteTool(name) {
+			continue
+		}
+		if s.deniedBy(r.Context(), mcp.CallToolRequest{}, name, toolGroup(name)) != nil {
+			continue
+		}
+		kept = append(kept, tool)
+	}
+	tools = kept
+
 	response := map[string]interface{}{
 		"tools": tools,
 		"total": len(tools),
 		"categories": map[string]int{
-			"core":    5,
-			"utility": 11,
-			"project": 5,
+			"core":    14,
+			"utility": s.utilityToolCount(),
+			"project": 7,
 			"session": func() int {
 				if s.config.Server.MultiSession.Enabled {
 					return 3
@@ -421,27 +975,25 @@ func (s *MCPServer) handleToolsAPI(w http.ResponseWriter, r *http.Request) {
 					return 0
 				}
 			}(),
-			"ai": 3,
+			"ai": 5,
 		},
 		"server_info": map[string]interface{}{
 			"name":          s.config.Server.Name,
 			"version":       s.config.Server.Version,
 			"multi_session": s.config.Server.MultiSession.Enabled,
+			"read_only":     s.config.Server.ReadOnly,
 		},
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		s.logger.Error("Failed to encode tools response", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		auth.WriteError(w, http.StatusInternalServerError, types.ErrorInternal, "Internal server error")
 	}
 }
 
 // handleToolCall handles the /api/call endpoint - executes MCP tool calls
 func (s *MCPServer) handleToolCall(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
@@ -449,11 +1001,135 @@ func (s *MCPServer) handleToolCall(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		auth.WriteError(w, http.StatusMethodNotAllowed, types.ErrorInvalidArgument, "Method not allowed")
+		return
+	}
+
+	mcpRequest, ok := s.decodeToolCall(w, r)
+	if !ok {
+		return
+	}
+
+	// Execute the tool call; the call outlives a client that disconnects,
+	// but keeps the API key it was authenticated with
+	ctx := context.WithoutCancel(r.Context())
+	result, err := s.executeToolCall(ctx, mcpRequest)
+	if errors.Is(err, errUnknownTool) {
+		auth.WriteError(w, http.StatusNotFound, types.ErrorToolNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Error("Tool call failed", zap.Error(err))
+		auth.WriteError(w, http.StatusInternalServerError, types.ErrorInternal, fmt.Sprintf("Tool execution failed: %v", err))
+		return
+	}
+
+	// Tool errors are reported in the result, as over stdio
+	if err := json.NewEncoder(w).Encode(toolCallResponse(mcpRequest.Params.Name, result)); err != nil {
+		s.logger.Error("Failed to encode tool call response", zap.Error(err))
+		auth.WriteError(w, http.StatusInternalServerError, types.ErrorInternal, "Internal server error")
+	}
+}
+
+// handleToolCallStream handles the /api/call/stream endpoint, the
+// server-sent events variant of /api/call. The text a language model writes
+// for the call arrives as "delta" events while it is written, then the
+// response of /api/call as a "result" event; failures end the stream with
+// an "error" event.
+func (s *MCPServer) handleToolCallStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "OPTIONS" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != "POST" {
+		auth.WriteError(w, http.StatusMethodNotAllowed, types.ErrorInvalidArgument, "Method not allowed")
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		auth.WriteError(w, http.StatusInternalServerError, types.ErrorUnsupported, "Streaming not supported")
+		return
+	}
+
+	mcpRequest, ok := s.decodeToolCall(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := s.handlers[mcpRequest.Params.Name]; !ok {
+		auth.WriteError(w, http.StatusNotFound, types.ErrorToolNotFound, fmt.Sprintf("%v: %s", errUnknownTool, mcpRequest.Params.Name))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var mutex sync.Mutex
+	sendEvent := func(event string, data interface{}) {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			s.logger.Error("Failed to encode stream event", zap.String("event", event), zap.Error(err))
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+		flusher.Flush()
+	}
+
+	// As in /api/call, the call outlives a client that disconnects
+	ctx := models.WithStream(context.WithoutCancel(r.Context()), func(text string) {
+		sendEvent("delta", map[string]string{"text": text})
+	})
+	result, err := s.executeToolCall(ctx, mcpRequest)
+	if err != nil {
+		s.logger.Error("Tool call failed", zap.Error(err))
+		sendEvent("error", types.ErrorEnvelope{Error: types.NewToolError(types.ErrorInternal, fmt.Sprintf("Tool execution failed: %v", err), nil)})
 		return
 	}
 
-	// Parse request body
+	sendEvent("result", toolCallResponse(mcpRequest.Params.Name, result))
+}
+
+// toolCallResponse converts the result of a tool call to the response of
+// /api/call. A failed call carries the error of its envelope beside the
+// result, so clients need not parse the result text.
+func toolCallResponse(name string, result *mcp.CallToolResult) map[string]interface{} {
+	response := map[string]interface{}{
+		"success": !result.IsError,
+		"tool":    name,
+		"result":  result,
+	}
+	if toolErr, ok := resultError(result); ok {
+		response["error"] = toolErr
+	}
+	return response
+}
+
+// requireJSON answers a request whose body is not declared as JSON with
+// 415. Browsers send form and plain text bodies to other origins without a
+// preflight, so requiring JSON keeps pages of other origins from calling
+// the API.
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		auth.WriteError(w, http.StatusUnsupportedMediaType, types.ErrorUnsupported, "Content-Type must be application/json")
+		return false
+	}
+	return true
+}
+
+// decodeToolCall reads the tool call in the body of an /api/call request,
+// answering the request itself when the body is invalid
+func (s *MCPServer) decodeToolCall(w http.ResponseWriter, r *http.Request) (mcp.CallToolRequest, bool) {
+	if !requireJSON(w, r) {
+		return mcp.CallToolRequest{}, false
+	}
+
 	var requestBody struct {
 		Tool      string                 `json:"tool"`
 		Arguments map[string]interface{} `json:"arguments"`
@@ -461,8 +1137,8 @@ func (s *MCPServer) handleToolCall(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
+		auth.WriteError(w, http.StatusBadRequest, types.ErrorInvalidArgument, "Invalid JSON")
+		return mcp.CallToolRequest{}, false
 	}
 
 	// Create MCP request
@@ -485,58 +1161,66 @@ func (s *MCPServer) handleToolCall(w http.ResponseWriter, r *http.Request) {
 		zap.String("tool", requestBody.Tool),
 		zap.String("session_id", requestBody.SessionID),
 		zap.String("remote_addr", r.RemoteAddr))
-
-	// Execute the tool call
-	ctx := context.Background()
-	result, err := s.executeToolCall(ctx, mcpRequest)
-	if err != nil {
-		s.logger.Error("Tool call failed", zap.Error(err))
-		http.Error(w, fmt.Sprintf("Tool execution failed: %v", err), http.StatusInternalServerError)
-		return
-	}
-
-	// Convert MCP result to API response
-	response := map[string]interface{}{
-		"success": true,
-		"tool":    requestBody.Tool,
-		"result":  result,
-	}
-
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		s.logger.Error("Failed to encode tool call response", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-	}
+	return mcpRequest, true
 }
 
 // handleHealthCheck handles the /api/health endpoint
 func (s *MCPServer) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	uptime := time.Since(s.startedAt)
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
 
 	health := map[string]interface{}{
-		"status":    "healthy",
-		"timestamp": time.Now().Format(time.RFC3339),
-		"version":   s.config.Server.Version,
-		"uptime":    time.Since(time.Now()).String(), // This would be calculated from server start time
+		"status":         "healthy",
+		"timestamp":      time.Now().Format(time.RFC3339),
+		"version":        s.config.Server.Version,
+		"started_at":     s.startedAt.Format(time.RFC3339),
+		"uptime":         uptime.Round(time.Second).String(),
+		"uptime_seconds": int64(uptime.Seconds()),
+		"goroutines":     runtime.NumGoroutine(),
+		"memory": map[string]interface{}{
+			"alloc_bytes":  memory.Alloc,
+			"sys_bytes":    memory.Sys,
+			"heap_objects": memory.HeapObjects,
+			"gc_cycles":    memory.NumGC,
+		},
+	}
+
+	if stats, err := s.indexer.GetIndexStats(r.Context()); err != nil {
+		s.logger.Warn("Failed to get index stats for health check", zap.Error(err))
+		health["status"] = "degraded"
+		health["index_error"] = err.Error()
+	} else {
+		health["index"] = map[string]interface{}{
+			"repositories": stats.TotalRepositories,
+			"files":        stats.TotalFiles,
+			"functions":    stats.TotalFunctions,
+			"classes":      stats.TotalClasses,
+			"last_indexed": stats.LastIndexed,
+		}
 	}
 
 	if s.sessionManager != nil {
 		health["sessions"] = s.sessionManager.GetSessionStats()
 	}
+	if s.connectionManager != nil {
+		health["connections"] = s.connectionManager.GetConnectionStats()
+	}
 
 	if err := json.NewEncoder(w).Encode(health); err != nil {
 		s.logger.Error("Failed to encode health response", zap.Error(err))
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		auth.WriteError(w, http.StatusInternalServerError, types.ErrorInternal, "Internal server error")
 	}
 }
 
 // handleSessionsAPI handles the /api/sessions endpoint
 func (s *MCPServer) handleSessionsAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
 
 	if s.sessionManager == nil {
-		http.Error(w, "Multi-session support not enabled", http.StatusServiceUnavailable)
+		auth.WriteError(w, http.StatusServiceUnavailable, types.ErrorFeatureDisabled, "Multi-session support not enabled")
 		return
 	}
 
@@ -553,7 +1237,7 @@ func (s *MCPServer) handleSessionsAPI(w http.ResponseWriter, r *http.Request) {
 
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			s.logger.Error("Failed to encode sessions response", zap.Error(err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			auth.WriteError(w, http.StatusInternalServerError, types.ErrorInternal, "Internal server error")
 		}
 
 	case "POST":
@@ -561,19 +1245,39 @@ func (s *MCPServer) handleSessionsAPI(w http.ResponseWriter, r *http.Request) {
 		var requestBody struct {
 			Name         string `json:"name"`
 			WorkspaceDir string `json:"workspace_dir,omitempty"`
+			Profile      string `json:"profile,omitempty"`
+			Workspace    string `json:"workspace,omitempty"`
 		}
 
+		if !requireJSON(w, r) {
+			return
+		}
 		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			auth.WriteError(w, http.StatusBadRequest, types.ErrorInvalidArgument, "Invalid JSON")
 			return
 		}
 
-		session, err := s.sessionManager.CreateSession(requestBody.Name, requestBody.WorkspaceDir)
+		if _, ok := s.permissions.Profile(requestBody.Profile); requestBody.Profile != "" && !ok {
+			auth.WriteError(w, http.StatusBadRequest, types.ErrorInvalidArgument, fmt.Sprintf("Unknown permission profile %q", requestBody.Profile))
+			return
+		}
+		if _, ok := s.indexer.Workspace(requestBody.Workspace); requestBody.Workspace != "" && !ok {
+			auth.WriteError(w, http.StatusBadRequest, types.ErrorInvalidArgument, fmt.Sprintf("Unknown workspace %q", requestBody.Workspace))
+			return
+		}
+
+		var owner string
+		if key, ok := auth.FromContext(r.Context()); ok {
+			owner = key.Name
+		}
+		session, err := s.sessionManager.CreateOwnedSession(owner, requestBody.Name, requestBody.WorkspaceDir)
 		if err != nil {
 			s.logger.Error("Failed to create session", zap.Error(err))
-			http.Error(w, fmt.Sprintf("Failed to create session: %v", err), http.StatusInternalServerError)
+			auth.WriteError(w, http.StatusInternalServerError, types.ErrorInternal, fmt.Sprintf("Failed to create session: %v", err))
 			return
 		}
+		session.SetProfile(requestBody.Profile)
+		session.SetDefaultWorkspace(requestBody.Workspace)
 
 		response := map[string]interface{}{
 			"success": true,
@@ -583,46 +1287,20 @@ func (s *MCPServer) handleSessionsAPI(w http.ResponseWriter, r *http.Request) {
 
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			s.logger.Error("Failed to encode create session response", zap.Error(err))
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			auth.WriteError(w, http.StatusInternalServerError, types.ErrorInternal, "Internal server error")
 		}
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
-// executeToolCall executes an MCP tool call and returns the result
-func (s *MCPServer) executeToolCall(ctx context.Context, request mcp.CallToolRequest) (interface{}, error) {
-	// This is a simplified version - in a real implementation, you'd route to the appropriate handler
-	// For now, we'll handle a few key tools directly
-
-	switch request.Params.Name {
-	case "list_repositories":
-		return s.handleListRepositories(ctx, request)
-	case "get_index_stats":
-		return s.handleGetIndexStats(ctx, request)
-	case "search_code":
-		return s.handleSearchCode(ctx, request)
-	case "find_files":
-		return s.handleFindFiles(ctx, request)
-	case "get_file_content":
-		return s.handleGetFileContent(ctx, request)
-	case "list_sessions":
-		if s.sessionManager != nil {
-			sessions := s.sessionManager.ListSessions()
-			stats := s.sessionManager.GetSessionStats()
-			return map[string]interface{}{
-				"sessions": sessions,
-				"stats":    stats,
-			}, nil
-		}
-		return nil, fmt.Errorf("multi-session support not enabled")
-	case "get_session_info":
-		return map[string]interface{}{
-			"multi_session_enabled": s.config.Server.MultiSession.Enabled,
-			"session_config":        s.config.Server.MultiSession,
-		}, nil
-	default:
-		return nil, fmt.Errorf("tool not supported in API mode: %s", request.Params.Name)
+		auth.WriteError(w, http.StatusMethodNotAllowed, types.ErrorInvalidArgument, "Method not allowed")
+	}
+}
+
+// executeToolCall executes an MCP tool call through the handler registered
+// for the tool, as the stdio server would
+func (s *MCPServer) executeToolCall(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	handler, ok := s.handlers[request.Params.Name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUnknownTool, request.Params.Name)
 	}
+	return handler(ctx, request)
 }
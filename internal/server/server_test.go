@@ -0,0 +1,374 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/my-mcp/code-indexer/internal/auth"
+	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/logging"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// newTestServer creates a server with every tool registered. The index is
+// kept in memory and repositories in a temporary directory, so nothing is
+// written to the working directory.
+func newTestServer(t *testing.T, configure func(*config.Config)) *MCPServer {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.Indexer.MemoryIndex = true
+	if configure != nil {
+		configure(cfg)
+	}
+
+	s, err := New(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// postJSONRPC sends a JSON-RPC message to the Streamable HTTP endpoint and
+// returns the response with its body
+func postJSONRPC(t *testing.T, url, sessionID, message string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(message))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		req.Header.Set(mcpserver.HeaderKeySessionID, sessionID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestHTTPTransport(t *testing.T) {
+	s := newTestServer(t, nil)
+	handler, _, err := s.httpTransportHandler("/mcp", nil)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	// Health check
+	resp, err := http.Get(ts.URL + "/api/health")
+	if err != nil {
+		t.Fatalf("Health check failed: %v", err)
+	}
+	var health map[string]interface{}
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK || health["status"] != "healthy" {
+		t.Fatalf("Unexpected health response %d: %v (%v)", resp.StatusCode, health, err)
+	}
+
+	// Initializing opens a session
+	resp, body := postJSONRPC(t, ts.URL+"/mcp", "",
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Initialize failed with status %d: %s", resp.StatusCode, body)
+	}
+	sessionID := resp.Header.Get(mcpserver.HeaderKeySessionID)
+	if sessionID == "" {
+		t.Fatal("Expected a session ID from initialize")
+	}
+	if !strings.Contains(body, s.config.Server.Name) {
+		t.Errorf("Expected the server name in the initialize result: %s", body)
+	}
+
+	// Tools are listed over the session
+	resp, body = postJSONRPC(t, ts.URL+"/mcp", sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("tools/list failed with status %d: %s", resp.StatusCode, body)
+	}
+	for _, tool := range []string{"search_code", "grep_repository", "get_capabilities"} {
+		if !strings.Contains(body, `"`+tool+`"`) {
+			t.Errorf("Expected %s in tools/list: %s", tool, body)
+		}
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	s := newTestServer(t, nil)
+	core, logs := observer.New(zap.DebugLevel)
+	s.logger = zap.New(core)
+	handler, _, err := s.httpTransportHandler("/mcp", nil)
+	if err != nil {
+		t.Fatalf("Failed to create handler: %v", err)
+	}
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	resp, body := postJSONRPC(t, ts.URL+"/mcp", "",
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Initialize failed with status %d: %s", resp.StatusCode, body)
+	}
+	if id := resp.Header.Get(logging.RequestIDHeader); len(id) != 16 {
+		t.Errorf("Expected a request ID to be generated, got %q", id)
+	}
+
+	// The ID a client sends is kept for the tool call it carries
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/mcp",
+		strings.NewReader(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"list_repositories","arguments":{}}}`))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set(mcpserver.HeaderKeySessionID, resp.Header.Get(mcpserver.HeaderKeySessionID))
+	req.Header.Set(logging.RequestIDHeader, "client-42")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if id := resp.Header.Get(logging.RequestIDHeader); id != "client-42" {
+		t.Errorf("Expected the client's request ID to be returned, got %q", id)
+	}
+	finished := logs.FilterMessage("Tool call finished").FilterField(zap.String("request_id", "client-42"))
+	if finished.Len() != 1 || finished.All()[0].ContextMap()["tool"] != "list_repositories" {
+		t.Errorf("Expected the tool call to be logged with the request ID, got %+v", logs.All())
+	}
+	if logs.FilterMessage("Listing repositories").FilterField(zap.String("request_id", "client-42")).Len() != 1 {
+		t.Error("Expected the handler to log with the request ID")
+	}
+	if logs.FilterMessage("HTTP request").FilterField(zap.String("request_id", "client-42")).Len() != 1 {
+		t.Error("Expected the HTTP request to be logged with its request ID")
+	}
+
+	// Calls over stdio get an ID of their own
+	callTool(t, s, "list_repositories", nil)
+	calls := logs.FilterMessage("Tool call finished").All()
+	if id, _ := calls[len(calls)-1].ContextMap()["request_id"].(string); id == "" || id == "client-42" {
+		t.Errorf("Expected a new request ID for the call, got %q", id)
+	}
+}
+
+func TestSetLogLevel(t *testing.T) {
+	previous := logging.Level.Level()
+	defer logging.Level.SetLevel(previous)
+	logging.Level.SetLevel(zap.InfoLevel)
+	s := newTestServer(t, nil)
+
+	text, isError := callTool(t, s, "set_log_level", map[string]interface{}{"level": "debug"})
+	var result struct {
+		Level         string `json:"level"`
+		PreviousLevel string `json:"previous_level"`
+	}
+	if err := json.Unmarshal([]byte(text), &result); err != nil || isError {
+		t.Fatalf("Expected the level to be changed, got %s", text)
+	}
+	if result.Level != "debug" || result.PreviousLevel != "info" || logging.Level.Level() != zap.DebugLevel {
+		t.Errorf("Expected debug after info, got %+v at %s", result, logging.Level.Level())
+	}
+
+	if _, isError := callTool(t, s, "set_log_level", map[string]interface{}{"level": "fatal"}); !isError {
+		t.Error("Expected a level that would silence errors to be refused")
+	}
+	if toolGroup("set_log_level") != auth.GroupAdmin {
+		t.Error("Expected set_log_level to need the admin group")
+	}
+}
+
+func TestHTTPTransportRejectsReservedPaths(t *testing.T) {
+	s := newTestServer(t, nil)
+	for _, endpointPath := range []string{"/sse", "/message", "/api/health", "mcp", "/mcp/"} {
+		if _, _, err := s.httpTransportHandler(endpointPath, nil); err == nil {
+			t.Errorf("Expected endpoint path %q to be rejected", endpointPath)
+		}
+	}
+}
+
+func TestHealthCheckReportsUptimeAndResources(t *testing.T) {
+	s := newTestServer(t, nil)
+	s.startedAt = time.Now().Add(-90 * time.Second)
+
+	w := httptest.NewRecorder()
+	s.handleHealthCheck(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+
+	var health map[string]interface{}
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil {
+		t.Fatalf("Invalid health response: %v", err)
+	}
+	if uptime, _ := health["uptime_seconds"].(float64); uptime < 90 {
+		t.Errorf("Expected an uptime of at least 90 seconds, got %v", health["uptime_seconds"])
+	}
+	if goroutines, _ := health["goroutines"].(float64); goroutines < 1 {
+		t.Errorf("Expected a goroutine count, got %v", health["goroutines"])
+	}
+	for _, field := range []string{"memory", "index"} {
+		if _, ok := health[field].(map[string]interface{}); !ok {
+			t.Errorf("Expected %s in the health response: %v", field, health)
+		}
+	}
+}
+
+func TestToolCallStream(t *testing.T) {
+	modelServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"```go\\nfunc \"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"Add() {}\\n```\"}}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer modelServer.Close()
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Models.Enabled = true
+		cfg.Models.Provider = "openai"
+		cfg.Models.BaseURL = modelServer.URL
+	})
+
+	w := httptest.NewRecorder()
+	body := `{"tool": "generate_code", "arguments": {"prompt": "add", "language": "go"}}`
+	s.handleToolCallStream(w, apiRequest("/api/call/stream", body))
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %q: %s", got, w.Body.String())
+	}
+
+	var events []string
+	var deltas strings.Builder
+	var result struct {
+		Success bool   `json:"success"`
+		Tool    string `json:"tool"`
+	}
+	for _, block := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+		event, data, _ := strings.Cut(block, "\n")
+		event = strings.TrimPrefix(event, "event: ")
+		data = strings.TrimPrefix(data, "data: ")
+		events = append(events, event)
+		switch event {
+		case "delta":
+			var delta struct {
+				Text string `json:"text"`
+			}
+			if err := json.Unmarshal([]byte(data), &delta); err != nil {
+				t.Fatalf("Invalid delta %q: %v", data, err)
+			}
+			deltas.WriteString(delta.Text)
+		case "result":
+			if err := json.Unmarshal([]byte(data), &result); err != nil {
+				t.Fatalf("Invalid result %q: %v", data, err)
+			}
+		}
+	}
+	if strings.Join(events, " ") != "delta delta result" || deltas.String() != "```go\nfunc Add() {}\n```" {
+		t.Errorf("Unexpected events %v with text %q", events, deltas.String())
+	}
+	if !result.Success || result.Tool != "generate_code" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleToolCallStream(w, apiRequest("/api/call/stream", `{"tool": "missing"}`))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown tool, got %d", w.Code)
+	}
+}
+
+func TestToolTimeouts(t *testing.T) {
+	s := newTestServer(t, func(cfg *config.Config) {
+		cfg.Server.Timeouts.Tools = map[string]int{"stuck": 1, "quick": 0}
+	})
+	// stuck ignores its context, quick gives up when it is done
+	release := make(chan struct{})
+	defer close(release)
+	s.addTool(mcp.NewTool("stuck"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-release
+		return mcp.NewToolResultText("done"), nil
+	})
+	s.addTool(mcp.NewTool("quick"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		<-ctx.Done()
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", ctx.Err())), nil
+	})
+
+	var response types.ErrorEnvelope
+	started := time.Now()
+	text, isError := callTool(t, s, "stuck", nil)
+	if err := json.Unmarshal([]byte(text), &response); err != nil || !isError || response.Error == nil {
+		t.Fatalf("Expected a timeout error, got %q", text)
+	}
+	if response.Error.Code != types.ErrorTimeout || !response.Error.Retryable ||
+		response.Error.Details["tool"] != "stuck" || response.Error.Details["timeout_seconds"] != 1.0 {
+		t.Errorf("Unexpected timeout error %+v", response.Error)
+	}
+	if elapsed := time.Since(started); elapsed > 3*time.Second {
+		t.Errorf("Expected an answer at the time limit, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	result, err := s.executeToolCall(ctx, mcp.CallToolRequest{Params: mcp.CallToolParams{Name: "quick"}})
+	if err != nil {
+		t.Fatalf("executeToolCall failed: %v", err)
+	}
+	text = result.Content[0].(mcp.TextContent).Text
+	if err := json.Unmarshal([]byte(text), &response); err != nil || !result.IsError || response.Error.Code != types.ErrorCancelled {
+		t.Errorf("Expected a cancellation error, got %q", text)
+	}
+}
+
+func TestCloseShutsDownDaemon(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Indexer.MemoryIndex = true
+	s, err := New(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.ServeDaemon("127.0.0.1", 0)
+	}()
+
+	// Wait for the daemon to start serving
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mutex.RLock()
+		started := s.httpServer != nil
+		s.mutex.RUnlock()
+		if started {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the daemon to start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("Expected ServeDaemon to return http.ErrServerClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected Close to stop the daemon")
+	}
+}
@@ -4,12 +4,24 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
 
+	"github.com/my-mcp/code-indexer/internal/auth"
 	"github.com/my-mcp/code-indexer/internal/config"
+	"github.com/my-mcp/code-indexer/internal/repository"
 	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/pathutil"
+	"github.com/my-mcp/code-indexer/pkg/types"
 )
 
 // SessionAwareHandler wraps tool handlers to provide session isolation
@@ -23,14 +35,7 @@ func (s *MCPServer) wrapWithSession(handler SessionAwareHandler) func(context.Co
 			// Convert to session-aware request with default session
 			sessionRequest := &session.SessionAwareRequest{
 				Request: request,
-				Session: &session.Session{
-					ID:           "default",
-					Name:         "default",
-					WorkspaceDir: "",
-					Config:       s.config,
-					Context:      make(map[string]interface{}),
-					Active:       true,
-				},
+				Session: s.getDefaultSession(),
 				Context: ctx,
 			}
 			return handler(ctx, sessionRequest)
@@ -39,12 +44,12 @@ func (s *MCPServer) wrapWithSession(handler SessionAwareHandler) func(context.Co
 		// Create session-aware request
 		sessionRequest, err := s.sessionContext.NewSessionAwareRequest(ctx, request)
 		if err != nil {
-			s.logger.Error("Failed to create session-aware request", zap.Error(err))
+			s.log(ctx).Error("Failed to create session-aware request", zap.Error(err))
 			return mcp.NewToolResultError(fmt.Sprintf("Session error: %v", err)), nil
 		}
 
 		// Log session information
-		s.logger.Debug("Processing request with session",
+		s.log(ctx).Debug("Processing request with session",
 			zap.String("tool", request.Params.Name),
 			zap.String("session_id", sessionRequest.Session.ID),
 			zap.String("workspace", sessionRequest.Session.WorkspaceDir))
@@ -72,6 +77,325 @@ func (s *MCPServer) wrapWithSession(handler SessionAwareHandler) func(context.Co
 	}
 }
 
+// getDefaultSession returns the session used when multi-session support is
+// disabled or a request does not name a session. It is created once so that
+// per-session state such as editor buffers survives between calls.
+func (s *MCPServer) getDefaultSession() *session.Session {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.defaultSession == nil {
+		s.defaultSession = &session.Session{
+			ID:           "default",
+			Name:         "default",
+			WorkspaceDir: "",
+			CreatedAt:    time.Now(),
+			LastAccess:   time.Now(),
+			Config:       s.config,
+			Context:      make(map[string]interface{}),
+			Active:       true,
+		}
+	}
+
+	return s.defaultSession
+}
+
+// sessionForRequest resolves the session a plain tool request belongs to,
+// using its session_id argument when multi-session support is enabled
+func (s *MCPServer) sessionForRequest(request mcp.CallToolRequest) *session.Session {
+	if s.sessionManager != nil {
+		if sessionID := request.GetString("session_id", ""); sessionID != "" {
+			if sess, err := s.sessionManager.GetSession(sessionID); err == nil {
+				return sess
+			}
+		}
+	}
+	return s.getDefaultSession()
+}
+
+// sessionForContext returns the session of the WebSocket connection a
+// request came in on, for requests such as resource reads that carry no
+// session_id argument, and the default session otherwise
+func (s *MCPServer) sessionForContext(ctx context.Context) *session.Session {
+	if id := connectionID(ctx); id != "" && s.connectionManager != nil && s.sessionManager != nil {
+		if sessionID := s.connectionManager.SessionOf(id); sessionID != "" {
+			if sess, err := s.sessionManager.GetSession(sessionID); err == nil {
+				return sess
+			}
+		}
+	}
+	return s.getDefaultSession()
+}
+
+// workspacePathArguments are the arguments tools take paths in, relative to
+// their repository unless absolute. A relative path naming no repository is
+// looked up in the workspace repositories.
+var workspacePathArguments = []string{"file_path", "directory_path", "source_file", "path"}
+
+// workspaceSubpathArguments are the arguments tools take paths in relative
+// to the repository the other arguments choose, unless absolute
+var workspaceSubpathArguments = []string{"paths", "coverage_file", "test_directory"}
+
+// workspaceLocalPathArguments are the arguments of tools that take paths on
+// this machine, relative to the working directory unless absolute, rather
+// than in a repository
+var workspaceLocalPathArguments = map[string][]string{
+	"index_repository": {"path"},
+	"export_index":     {"path"},
+	"import_index":     {"path", "repository_root"},
+	"create_session":   {"workspace_dir"},
+}
+
+// sessionWorkspace is what an isolated session may reach: the repositories
+// of its default workspace and those below its workspace directory
+type sessionWorkspace struct {
+	session      *session.Session
+	name         string            // Default workspace, if any
+	repositories map[string]string // Repository name to resolved path
+	roots        []string          // Resolved repository paths and workspace directory
+}
+
+// workspaceIsolated reports whether a session is confined to its workspace:
+// workspaces are isolated and the session, one created through the session
+// manager, has a default workspace or a workspace directory
+func (s *MCPServer) workspaceIsolated(sess *session.Session) bool {
+	if !s.config.Server.MultiSession.IsolateWorkspaces || sess == s.getDefaultSession() {
+		return false
+	}
+	return sess.DefaultWorkspaceName() != "" || sess.WorkspaceDir != ""
+}
+
+// sessionWorkspace collects the repositories and directories a session is
+// confined to
+func (s *MCPServer) sessionWorkspace(ctx context.Context, sess *session.Session) *sessionWorkspace {
+	workspace := &sessionWorkspace{
+		session:      sess,
+		name:         sess.DefaultWorkspaceName(),
+		repositories: make(map[string]string),
+	}
+	addRoot := func(dir string) string {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = resolved
+		}
+		workspace.roots = append(workspace.roots, dir)
+		return dir
+	}
+
+	if workspace.name != "" {
+		if ws, ok := s.indexer.Workspace(workspace.name); ok {
+			for _, name := range ws.Repositories {
+				if repo, ok := s.indexer.IndexedRepository(name); ok {
+					workspace.repositories[name] = addRoot(repo.Path)
+				}
+			}
+		}
+	}
+	if sess.WorkspaceDir != "" {
+		dir := addRoot(sess.WorkspaceDir)
+		repositories, err := s.indexer.ListRepositories(ctx)
+		if err != nil {
+			s.log(ctx).Warn("Failed to list repositories of session workspace", zap.Error(err))
+		}
+		for _, repo := range repositories {
+			path := repo.Path
+			if resolved, err := filepath.EvalSymlinks(path); err == nil {
+				path = resolved
+			}
+			if pathWithin(dir, path) {
+				workspace.repositories[repo.Name] = path
+			}
+		}
+	}
+	return workspace
+}
+
+// names returns the names of the workspace's repositories, sorted
+func (w *sessionWorkspace) names() []string {
+	names := make([]string, 0, len(w.repositories))
+	for name := range w.repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// allows reports whether a repository, given by name or ID, belongs to the
+// workspace
+func (w *sessionWorkspace) allows(s *MCPServer, repository string) bool {
+	if _, ok := w.repositories[repository]; ok {
+		return true
+	}
+	repo, ok := s.indexer.IndexedRepository(repository)
+	if !ok {
+		return false
+	}
+	_, ok = w.repositories[repo.Name]
+	return ok
+}
+
+// contains reports whether a resolved path lies within the workspace
+func (w *sessionWorkspace) contains(path string) bool {
+	for _, root := range w.roots {
+		if pathWithin(root, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathWithin reports whether path is root or lies below it
+func pathWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// outsideWorkspace returns the result of a call reaching outside the
+// workspace of its session
+func outsideWorkspace(code, message string, workspace *sessionWorkspace, details map[string]interface{}) *mcp.CallToolResult {
+	details["session_id"] = workspace.session.ID
+	if workspace.name != "" {
+		details["workspace"] = workspace.name
+	}
+	return toolError(code, message, details)
+}
+
+// withSessionWorkspace confines the calls of isolated sessions to their
+// workspace. Repositories and workspaces named outside it are refused, file
+// paths are checked against its repositories and relative paths naming no
+// repository are taken from the workspace repository holding them.
+// scopeToWorkspace scopes the searches naming no repository.
+func (s *MCPServer) withSessionWorkspace(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sess := s.sessionForRequest(request)
+		if !s.workspaceIsolated(sess) {
+			return handler(ctx, request)
+		}
+		workspace := s.sessionWorkspace(ctx, sess)
+		args := maps.Clone(s.getArguments(request))
+		if args == nil {
+			args = make(map[string]interface{})
+		}
+
+		repository := request.GetString("repository", "")
+		named := s.getStringList(request, "repositories")
+		if repository != "" {
+			named = append(named, repository)
+		}
+		for _, name := range named {
+			if !workspace.allows(s, name) {
+				return outsideWorkspace(types.ErrorPermissionDenied,
+					fmt.Sprintf("Repository %q is outside the workspace of session %q", name, sess.Name),
+					workspace, map[string]interface{}{"repository": name}), nil
+			}
+		}
+
+		if name := request.GetString("workspace", ""); name != "" && name != workspace.name {
+			ws, _ := s.indexer.Workspace(name)
+			for _, repo := range ws.Repositories {
+				if !workspace.allows(s, repo) {
+					return outsideWorkspace(types.ErrorPermissionDenied,
+						fmt.Sprintf("Workspace %q reaches outside the workspace of session %q", name, sess.Name),
+						workspace, map[string]interface{}{"requested_workspace": name}), nil
+				}
+			}
+		}
+
+		localArguments := workspaceLocalPathArguments[request.Params.Name]
+		for _, key := range localArguments {
+			path := pathutil.Local(request.GetString(key, ""))
+			if path == "" || (key == "path" && isRemoteRepository(path)) {
+				continue
+			}
+			if result := s.checkWorkspacePath(workspace, path, path); result != nil {
+				return result, nil
+			}
+		}
+
+		for _, key := range workspacePathArguments {
+			path, _ := args[key].(string)
+			if path == "" || slices.Contains(localArguments, key) {
+				continue
+			}
+			path = pathutil.Local(path)
+			if repository == "" && !filepath.IsAbs(path) {
+				repository = workspace.holder(path)
+				if repository != "" {
+					args["repository"] = repository
+				}
+			}
+			fullPath, err := s.repositoryPath(repository, path)
+			if err != nil {
+				return toolErrorResult(err), nil
+			}
+			if result := s.checkWorkspacePath(workspace, path, fullPath); result != nil {
+				return result, nil
+			}
+		}
+
+		for _, key := range workspaceSubpathArguments {
+			for _, path := range s.getStringList(request, key) {
+				path = pathutil.Local(path)
+				if repository == "" && !filepath.IsAbs(path) {
+					// Joined to a workspace repository, so it only has
+					// to stay inside it
+					if !filepath.IsLocal(path) {
+						return outsideWorkspace(types.ErrorPathOutsideSandbox,
+							fmt.Sprintf("Path %s is outside the workspace of session %q", path, sess.Name),
+							workspace, map[string]interface{}{"path": path}), nil
+					}
+					continue
+				}
+				fullPath, err := s.repositoryPath(repository, path)
+				if err != nil {
+					return toolErrorResult(err), nil
+				}
+				if result := s.checkWorkspacePath(workspace, path, fullPath); result != nil {
+					return result, nil
+				}
+			}
+		}
+
+		request.Params.Arguments = args
+		return handler(ctx, request)
+	}
+}
+
+// checkWorkspacePath returns the result refusing a call whose path,
+// found at fullPath, lies outside the workspace, or nil when it is inside
+func (s *MCPServer) checkWorkspacePath(workspace *sessionWorkspace, path, fullPath string) *mcp.CallToolResult {
+	resolved, err := s.repoMgr.ResolvePath(fullPath)
+	if err != nil {
+		return toolErrorResult(err)
+	}
+	if !workspace.contains(resolved) {
+		return outsideWorkspace(types.ErrorPathOutsideSandbox,
+			fmt.Sprintf("Path %s is outside the workspace of session %q", path, workspace.session.Name),
+			workspace, map[string]interface{}{"path": path})
+	}
+	return nil
+}
+
+// isRemoteRepository reports whether the path index_repository is given is
+// a URL to clone rather than a directory on this machine
+func isRemoteRepository(path string) bool {
+	return repository.IsRemote(path)
+}
+
+// holder returns the workspace repository holding a relative path, or the
+// only workspace repository when none does
+func (w *sessionWorkspace) holder(path string) string {
+	names := w.names()
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(w.repositories[name], path)); err == nil {
+			return name
+		}
+	}
+	if len(names) == 1 {
+		return names[0]
+	}
+	return ""
+}
+
 // getSessionFromContext is a helper to extract session from context
 func (s *MCPServer) getSessionFromContext(ctx context.Context) (*session.Session, error) {
 	if s.sessionContext == nil {
@@ -126,7 +450,7 @@ func (s *MCPServer) validateSessionAccess(ctx context.Context, resourcePath stri
 
 // handleListSessions handles session listing requests
 func (s *MCPServer) handleListSessions(ctx context.Context, request *session.SessionAwareRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling list sessions", zap.String("tool", request.Request.Params.Name))
+	s.log(ctx).Info("Handling list sessions", zap.String("tool", request.Request.Params.Name))
 
 	if s.sessionManager == nil {
 		return mcp.NewToolResultError("Multi-session support not enabled"), nil
@@ -151,7 +475,7 @@ func (s *MCPServer) handleListSessions(ctx context.Context, request *session.Ses
 
 // handleCreateSession handles session creation requests
 func (s *MCPServer) handleCreateSession(ctx context.Context, request *session.SessionAwareRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling create session", zap.String("tool", request.Request.Params.Name))
+	s.log(ctx).Info("Handling create session", zap.String("tool", request.Request.Params.Name))
 
 	if s.sessionManager == nil {
 		return mcp.NewToolResultError("Multi-session support not enabled"), nil
@@ -163,12 +487,26 @@ func (s *MCPServer) handleCreateSession(ctx context.Context, request *session.Se
 	}
 
 	workspaceDir := request.Request.GetString("workspace_dir", "")
+	profile := request.Request.GetString("profile", "")
+	if _, ok := s.permissions.Profile(profile); profile != "" && !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid profile parameter: unknown permission profile %q", profile)), nil
+	}
+	workspace := request.Request.GetString("workspace", "")
+	if _, ok := s.indexer.Workspace(workspace); workspace != "" && !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid workspace parameter: unknown workspace %q", workspace)), nil
+	}
 
-	newSession, err := s.sessionManager.CreateSession(name, workspaceDir)
+	var owner string
+	if key, ok := auth.FromContext(ctx); ok {
+		owner = key.Name
+	}
+	newSession, err := s.sessionManager.CreateOwnedSession(owner, name, workspaceDir)
 	if err != nil {
-		s.logger.Error("Failed to create session", zap.Error(err))
+		s.log(ctx).Error("Failed to create session", zap.Error(err))
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to create session: %v", err)), nil
 	}
+	newSession.SetProfile(profile)
+	newSession.SetDefaultWorkspace(workspace)
 
 	result := map[string]interface{}{
 		"success": true,
@@ -186,7 +524,7 @@ func (s *MCPServer) handleCreateSession(ctx context.Context, request *session.Se
 
 // handleGetSessionInfo handles session information requests
 func (s *MCPServer) handleGetSessionInfo(ctx context.Context, request *session.SessionAwareRequest) (*mcp.CallToolResult, error) {
-	s.logger.Info("Handling get session info", zap.String("tool", request.Request.Params.Name))
+	s.log(ctx).Info("Handling get session info", zap.String("tool", request.Request.Params.Name))
 
 	result := map[string]interface{}{
 		"current_session": request.Session,
@@ -198,6 +536,8 @@ func (s *MCPServer) handleGetSessionInfo(ctx context.Context, request *session.S
 		result["session_stats"] = s.sessionManager.GetSessionStats()
 	}
 
+	result["dirty_buffers"] = request.Session.Buffers()
+
 	content, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return mcp.NewToolResultError("Failed to format response"), nil
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// toolOutcome is what a tool handler returned
+type toolOutcome struct {
+	result *mcp.CallToolResult
+	err    error
+}
+
+// withTimeout runs a tool handler under the time limit configured for the
+// tool in server.timeouts. The handler gets a context that is done at the
+// limit, or when the call is cancelled, and the call is answered then even
+// if the handler has not noticed yet; it stops at its next cancellation
+// check. A handler that fails because its context is done is answered the
+// same way, so clients can tell interrupted calls from failed ones.
+func (s *MCPServer) withTimeout(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		timeout := s.config.Server.Timeouts.Timeout(name)
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		started := time.Now()
+		done := make(chan toolOutcome, 1)
+		go func() {
+			// Recovery middleware only sees panics of the calling goroutine
+			defer func() {
+				if r := recover(); r != nil {
+					done <- toolOutcome{err: fmt.Errorf("panic recovered in %s tool handler: %v", name, r)}
+				}
+			}()
+			result, err := handler(ctx, request)
+			done <- toolOutcome{result: result, err: err}
+		}()
+
+		select {
+		case outcome := <-done:
+			if ctx.Err() == nil || (outcome.err == nil && outcome.result != nil && !outcome.result.IsError) {
+				return outcome.result, outcome.err
+			}
+		case <-ctx.Done():
+		}
+		return s.interruptedResult(ctx, name, timeout, time.Since(started)), nil
+	}
+}
+
+// interruptedResult answers a tool call whose context is done: a timeout
+// when its deadline passed, a cancellation otherwise
+func (s *MCPServer) interruptedResult(ctx context.Context, name string, timeout, elapsed time.Duration) *mcp.CallToolResult {
+	details := map[string]interface{}{
+		"tool":            name,
+		"elapsed_seconds": elapsed.Round(time.Millisecond).Seconds(),
+	}
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		s.log(ctx).Info("Tool call cancelled", zap.String("tool", name), zap.Duration("elapsed", elapsed))
+		return toolError(types.ErrorCancelled, fmt.Sprintf("%s was cancelled before it finished", name), details)
+	}
+
+	s.log(ctx).Warn("Tool call timed out", zap.String("tool", name), zap.Duration("timeout", timeout))
+	if timeout <= 0 {
+		return toolError(types.ErrorTimeout, fmt.Sprintf("%s did not finish before the deadline of the request", name), details)
+	}
+	details["timeout_seconds"] = timeout.Seconds()
+	return toolError(types.ErrorTimeout,
+		fmt.Sprintf("%s did not finish within %s; narrow the request or raise server.timeouts.tools.%s", name, timeout, name), details)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
 )
 
@@ -17,7 +18,7 @@ func (s *MCPServer) registerTools() error {
 		s.logger.Error("❌ Failed to register core tools", zap.Error(err))
 		return fmt.Errorf("failed to register core tools: %w", err)
 	}
-	s.logger.Info("✅ Core tools registered successfully", zap.Int("count", 5))
+	s.logger.Info("✅ Core tools registered successfully", zap.Int("count", 6))
 
 	// Register utility tools
 	s.logger.Info("🛠️ Registering utility tools...")
@@ -25,7 +26,7 @@ func (s *MCPServer) registerTools() error {
 		s.logger.Error("❌ Failed to register utility tools", zap.Error(err))
 		return fmt.Errorf("failed to register utility tools: %w", err)
 	}
-	s.logger.Info("✅ Utility tools registered successfully", zap.Int("count", 11))
+	s.logger.Info("✅ Utility tools registered successfully", zap.Int("count", s.utilityToolCount()))
 
 	// Register project management tools
 	s.logger.Info("📋 Registering project management tools...")
@@ -33,7 +34,7 @@ func (s *MCPServer) registerTools() error {
 		s.logger.Error("❌ Failed to register project tools", zap.Error(err))
 		return fmt.Errorf("failed to register project tools: %w", err)
 	}
-	s.logger.Info("✅ Project management tools registered successfully", zap.Int("count", 5))
+	s.logger.Info("✅ Project management tools registered successfully", zap.Int("count", 6))
 
 	// Register session management tools if multi-session is enabled
 	if s.config.Server.MultiSession.Enabled {
@@ -72,16 +73,16 @@ func (s *MCPServer) registerTools() error {
 func (s *MCPServer) logToolsSummary() {
 	// Count tools by category
 	categories := map[string]int{
-		"core":    5,
-		"utility": 11,
-		"project": 5,
-		"ai":      0, // Will be 3 if models enabled
+		"core":    15,
+		"utility": s.utilityToolCount(),
+		"project": 7,
+		"ai":      0, // Will be 5 if models enabled
 		"session": 0, // Will be 3 if multi-session enabled
 	}
 
 	// Adjust counts based on enabled features
 	if s.config.Models.Enabled {
-		categories["ai"] = 3
+		categories["ai"] = 5
 	}
 	if s.config.Server.MultiSession.Enabled {
 		categories["session"] = 3
@@ -101,26 +102,90 @@ func (s *MCPServer) logToolsSummary() {
 		{"category": "core", "name": "get_metadata", "description": "Get detailed metadata for specific files"},
 		{"category": "core", "name": "list_repositories", "description": "List all indexed repositories with statistics"},
 		{"category": "core", "name": "get_index_stats", "description": "Get indexing statistics and information"},
+		{"category": "core", "name": "indexing_history", "description": "Get per-phase timings of past indexing runs"},
+		{"category": "core", "name": "get_indexing_progress", "description": "Get the progress of a background indexing job"},
+		{"category": "core", "name": "cancel_indexing", "description": "Cancel a background indexing job"},
+		{"category": "core", "name": "remove_repository", "description": "Remove a repository from the index, optionally deleting its clone"},
+		{"category": "core", "name": "cleanup_orphans", "description": "Remove indexed repositories whose source path no longer exists"},
+		{"category": "core", "name": "optimize_index", "description": "Compact the search index and report the space reclaimed"},
+		{"category": "core", "name": "verify_index", "description": "Check indexed files against disk and optionally repair them"},
+		{"category": "core", "name": "switch_ref", "description": "Check out another ref of a cloned repository and re-index the difference"},
+		{"category": "core", "name": "index_dependencies", "description": "List a repository's dependencies and index their sources for include_deps searches"},
+		{"category": "core", "name": "sync_configured_repositories", "description": "Re-read the repositories section of the configuration and index and watch them"},
 
 		// Utility tools
 		{"category": "utility", "name": "find_files", "description": "Find files matching patterns with wildcards"},
-		{"category": "utility", "name": "find_symbols", "description": "Find symbols (functions, classes, variables) by name"},
+		{"category": "utility", "name": "find_symbols", "description": "Find symbols (functions, classes, interfaces, type aliases, variables) by name"},
+		{"category": "utility", "name": "complete_symbol", "description": "Complete a partial symbol name from the indexed names"},
+		{"category": "utility", "name": "save_search", "description": "Save search_code arguments under a name"},
+		{"category": "utility", "name": "list_saved_searches", "description": "List the saved searches and recent searches of the session"},
+		{"category": "utility", "name": "run_saved_search", "description": "Run a saved search"},
+		{"category": "utility", "name": "set_workspace", "description": "Group indexed repositories into a named workspace"},
+		{"category": "utility", "name": "list_workspaces", "description": "List the workspaces and the session's default workspace"},
+		{"category": "utility", "name": "use_workspace", "description": "Scope the session's searches to a workspace"},
+		{"category": "utility", "name": "list_projects", "description": "List the sub-projects of a repository"},
+		{"category": "utility", "name": "export_index", "description": "Export repository indexes and their metadata to an archive file"},
+		{"category": "utility", "name": "import_index", "description": "Import the repository indexes of an archive file"},
+		{"category": "utility", "name": "get_file_outline", "description": "Get the symbol tree of a file with line ranges, signatures and doc strings"},
+		{"category": "utility", "name": "goto_definition", "description": "Resolve a name used in a file to its definition through the file's imports"},
+		{"category": "utility", "name": "lsp_hover", "description": "Get the type and documentation of a symbol from the language server"},
+		{"category": "utility", "name": "lsp_definition", "description": "Find where a symbol is defined using the language server"},
+		{"category": "utility", "name": "lsp_references", "description": "Find the references to a symbol using the language server"},
+		{"category": "utility", "name": "lsp_diagnostics", "description": "Get the errors and warnings the language server reports for a file"},
+		{"category": "utility", "name": "get_diagnostics", "description": "Run the configured compilers and linters over a repository or file and return their errors"},
+		{"category": "utility", "name": "run_tests", "description": "Run a repository's tests and return pass or fail, duration and failure output per test"},
+		{"category": "utility", "name": "find_dependencies", "description": "Map the import graph of a repository, file or package, with reverse dependencies and cycles"},
+		{"category": "utility", "name": "get_chunk_graph", "description": "Map which indexed chunks use the functions and classes other chunks define"},
+		{"category": "utility", "name": "detect_code_smells", "description": "Find long functions, long parameter lists, deep nesting, large classes and duplicated code in a file"},
+		{"category": "utility", "name": "analyze_complexity", "description": "Measure cyclomatic, cognitive and Halstead complexity per function, with repository aggregates"},
+		{"category": "utility", "name": "list_security_findings", "description": "List hardcoded secrets found while indexing, filtered by severity, rule and path"},
+		{"category": "utility", "name": "detect_security_issues", "description": "Scan a file for hardcoded secrets or summarize a repository's findings"},
+		{"category": "utility", "name": "generate_metrics_report", "description": "Report size, complexity, code smells and secrets of a file or repository, as JSON or SARIF"},
+		{"category": "utility", "name": "analyze_test_coverage", "description": "Find a source file's tests, its untested exported symbols and per-function coverage gaps"},
 		{"category": "utility", "name": "get_file_content", "description": "Get full content of specific files with line ranges"},
 		{"category": "utility", "name": "list_directory", "description": "List files and directories in specific paths"},
 		{"category": "utility", "name": "delete_lines", "description": "Delete a range of lines within a file"},
 		{"category": "utility", "name": "insert_at_line", "description": "Insert content at a given line in a file"},
 		{"category": "utility", "name": "replace_lines", "description": "Replace a range of lines with new content"},
+		{"category": "utility", "name": "replace_symbol_body", "description": "Replace the body of a function, method or type by name"},
+		{"category": "utility", "name": "insert_after_symbol", "description": "Insert code after a named declaration"},
+		{"category": "utility", "name": "insert_before_symbol", "description": "Insert code before a named declaration and its doc comment"},
+		{"category": "utility", "name": "rename_symbol", "description": "Rename a symbol at its definition and every reference in its repository"},
+		{"category": "utility", "name": "undo_last_edit", "description": "Undo the most recent edit made by the file manipulation tools"},
+		{"category": "utility", "name": "redo_edit", "description": "Reapply the most recently undone edit"},
+		{"category": "utility", "name": "list_edit_history", "description": "List the edits made by the file manipulation tools"},
 		{"category": "utility", "name": "get_file_snippet", "description": "Extract a specific code snippet from a file"},
 		{"category": "utility", "name": "find_references", "description": "Find all references to a symbol across indexed repositories"},
 		{"category": "utility", "name": "refresh_index", "description": "Refresh the search index for specific repositories or all repositories"},
 		{"category": "utility", "name": "git_blame", "description": "Get Git blame information for a specific file or file range"},
+		{"category": "utility", "name": "git_diff", "description": "Get a structured diff between commits, the working tree or staged changes"},
+		{"category": "utility", "name": "sync_buffer", "description": "Sync unsaved editor contents so tools read the buffer instead of disk"},
+		{"category": "utility", "name": "resolve_stacktrace", "description": "Map a pasted stack trace to indexed files, symbols and snippets"},
+		{"category": "utility", "name": "semantic_search", "description": "Find code by meaning using embeddings of indexed chunks"},
+		{"category": "utility", "name": "get_context_bundle", "description": "Assemble a symbol's code, imports, callees, callers and related chunks within a token budget"},
+		{"category": "utility", "name": "ask_codebase", "description": "Answer a natural language question with ranked, cited evidence from the index"},
+		{"category": "utility", "name": "grep_repository", "description": "Search repository files on disk for text or a regex, with context lines"},
+		{"category": "utility", "name": "continue_response", "description": "Read the rest of a result cut to the response size limit"},
 
 		// Project tools
 		{"category": "project", "name": "get_current_config", "description": "Get the current configuration of the agent"},
 		{"category": "project", "name": "initial_instructions", "description": "Get the initial instructions for the current project"},
 		{"category": "project", "name": "remove_project", "description": "Remove a project from the configuration"},
-		{"category": "project", "name": "restart_language_server", "description": "Restart the language server"},
+		{"category": "project", "name": "restart_language_server", "description": "Restart the running language servers"},
+		{"category": "project", "name": "set_log_level", "description": "Change the level of the server's logs while it runs"},
 		{"category": "project", "name": "summarize_changes", "description": "Provide instructions for summarizing codebase changes"},
+		{"category": "project", "name": "get_capabilities", "description": "Report the languages, features and limits this deployment supports"},
+	}
+
+	// Drop the write tools in read-only mode
+	if s.config.Server.ReadOnly {
+		kept := tools[:0]
+		for _, tool := range tools {
+			if !isWriteTool(tool["name"]) {
+				kept = append(kept, tool)
+			}
+		}
+		tools = kept
 	}
 
 	// Add AI tools if enabled
@@ -129,6 +194,8 @@ func (s *MCPServer) logToolsSummary() {
 			{"category": "ai", "name": "generate_code", "description": "Generate code from natural language descriptions using AI"},
 			{"category": "ai", "name": "analyze_code", "description": "Analyze code quality and get AI suggestions"},
 			{"category": "ai", "name": "explain_code", "description": "Get AI explanations of code functionality"},
+			{"category": "ai", "name": "summarize_diff", "description": "Write a commit message or pull request description for a diff"},
+			{"category": "ai", "name": "generate_tests", "description": "Write unit tests for a source file in the repository's test framework"},
 		}
 		tools = append(tools, aiTools...)
 	}
@@ -151,6 +218,7 @@ func (s *MCPServer) logToolsSummary() {
 			"version":       s.config.Server.Version,
 			"multi_session": s.config.Server.MultiSession.Enabled,
 			"models":        s.config.Models.Enabled,
+			"read_only":     s.config.Server.ReadOnly,
 		}),
 		zap.Any("tools", tools),
 		zap.Int("total", total))
@@ -169,6 +237,37 @@ func (s *MCPServer) logToolsSummary() {
 	s.logger.Info("🎯 Total Tools Available", zap.Int("total", total))
 }
 
+// addTool registers a tool with the MCP server and records its handler, so
+// the daemon API can call every tool the stdio server exposes. Calls are
+// given a request ID, checked against the caller's permission profiles, run
+// under the tool's time limit holding the locks they need and fail with the
+// error envelope. Results over the response size limit are cut and
+// continued.
+func (s *MCPServer) addTool(tool mcp.Tool, handler server.ToolHandlerFunc) {
+	if s.handlers == nil {
+		s.handlers = make(map[string]server.ToolHandlerFunc)
+	}
+	handler = s.withRequestID(tool.Name, s.withErrorEnvelope(tool.Name, s.withPermissions(tool.Name, s.withSessionWorkspace(s.withResponseLimit(tool.Name, s.withTimeout(tool.Name, s.withQuotas(tool.Name, s.withLocks(tool.Name, handler))))))))
+	s.handlers[tool.Name] = handler
+	s.server.AddTool(tool, handler)
+}
+
+// addWriteTool registers a tool that modifies files, unless the server runs
+// in read-only mode. Profiles allow it through the @write group.
+func (s *MCPServer) addWriteTool(tool mcp.Tool, handler server.ToolHandlerFunc) {
+	if s.config.Server.ReadOnly {
+		s.logger.Info("Read-only mode, skipping write tool", zap.String("tool", tool.Name))
+		return
+	}
+	s.addTool(tool, handler)
+}
+
+// utilityToolCount returns the number of utility tools registered, which
+// excludes the write tools in read-only mode
+func (s *MCPServer) utilityToolCount() int {
+	return s.utilityTools
+}
+
 // registerCoreTools registers core indexing and search tools
 func (s *MCPServer) registerCoreTools() error {
 	s.logger.Info("Registering core tools...")
@@ -183,12 +282,49 @@ func (s *MCPServer) registerCoreTools() error {
 		mcp.WithString("name",
 			mcp.Description("Custom name for the repository (optional)"),
 		),
+		mcp.WithString("ref",
+			mcp.Description("Branch, tag or commit to index; the repository is cloned into the repository directory as <name>@<ref>, so several refs can be indexed side by side (optional)"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Index in the background and return a job ID at once; poll get_indexing_progress with it (default: false)"),
+		),
+		mcp.WithNumber("max_file_size",
+			mcp.Description("Skip files larger than this many bytes, instead of indexer.max_file_size"),
+		),
+		mcp.WithArray("include_patterns",
+			mcp.Description("Only index files matching any of these globs, e.g. [\"*.go\", \"src/*\"], instead of indexer.include_patterns"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("exclude_patterns",
+			mcp.Description("Skip files and directories matching any of these globs, instead of indexer.exclude_patterns"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("chunk_strategy",
+			mcp.Description("How files are split into chunks, instead of indexer.chunking.strategy"),
+			mcp.Enum("semantic", "line_based", "hybrid", "token_based"),
+		),
+		mcp.WithNumber("max_chunk_lines",
+			mcp.Description("Lines per chunk of the line_based and hybrid strategies, instead of indexer.chunking.max_chunk_lines"),
+		),
+		mcp.WithNumber("max_chunk_tokens",
+			mcp.Description("Token budget of a chunk, instead of indexer.chunking.max_chunk_tokens; larger chunks are split"),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description("Clone only this many commits from the tip of each branch, instead of indexer.clone.depth"),
+		),
+		mcp.WithBoolean("single_branch",
+			mcp.Description("Clone only the default branch, or the ref, instead of every branch (default: indexer.clone.single_branch)"),
+		),
+		mcp.WithArray("sparse_patterns",
+			mcp.Description("Check out only these directories of a clone, relative to the repository root, e.g. [\"services/api\"]"),
+			mcp.WithStringItems(),
+		),
 	)
 	// Use session-aware handler if multi-session is enabled
 	if s.config.Server.MultiSession.Enabled {
-		s.server.AddTool(indexRepoTool, s.wrapWithSession(s.handleIndexRepositorySession))
+		s.addTool(indexRepoTool, s.wrapWithSession(s.handleIndexRepositorySession))
 	} else {
-		s.server.AddTool(indexRepoTool, s.handleIndexRepository)
+		s.addTool(indexRepoTool, s.handleIndexRepository)
 	}
 	s.logger.Debug("Registered tool: index_repository")
 
@@ -200,7 +336,7 @@ func (s *MCPServer) registerCoreTools() error {
 			mcp.Description("Search query"),
 		),
 		mcp.WithString("type",
-			mcp.Description("Search type: function, class, variable, content, file, comment"),
+			mcp.Description("Search type: function, class, variable, content, file, comment, section (Markdown) or config_key (YAML and JSON)"),
 		),
 		mcp.WithString("language",
 			mcp.Description("Filter by programming language"),
@@ -208,11 +344,82 @@ func (s *MCPServer) registerCoreTools() error {
 		mcp.WithString("repository",
 			mcp.Description("Filter by repository name"),
 		),
+		mcp.WithArray("types",
+			mcp.Description("Match any of these search types, e.g. [\"function\", \"class\"]"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("languages",
+			mcp.Description("Match any of these languages, e.g. [\"go\", \"python\"]"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("repositories",
+			mcp.Description("Match any of these repositories"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("workspace",
+			mcp.Description("Only search the repositories of this workspace (default: the session's workspace when no repository is named)"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Only search the files of this sub-project, given by its directory as list_projects returns it, e.g. services/api"),
+		),
+		mcp.WithArray("projects",
+			mcp.Description("Match any of these sub-projects"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("ref",
+			mcp.Description("Only search repositories indexed at this branch, tag or commit"),
+		),
+		mcp.WithBoolean("include_deps",
+			mcp.Description("Also search the dependency sources indexed with index_dependencies; they are searched anyway when named in repository (default: false)"),
+		),
+		mcp.WithString("path_prefix",
+			mcp.Description("Only search files whose repository-relative path starts with this prefix, e.g. internal/server/ or internal/server/**"),
+		),
+		mcp.WithArray("exclude_paths",
+			mcp.Description("Skip files matching any of these globs, e.g. [\"**/testdata/**\", \"*_test.go\"]"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("min_complexity",
+			mcp.Description("Only return functions with at least this cyclomatic complexity"),
+		),
+		mcp.WithNumber("max_complexity",
+			mcp.Description("Only return functions with at most this cyclomatic complexity"),
+		),
+		mcp.WithString("sort_by",
+			mcp.Description("Result order: relevance (default) or complexity, most complex functions first"),
+		),
+		mcp.WithBoolean("syntax",
+			mcp.Description("Parse query qualifiers repo:, lang:, type:, ref:, name:, path: and content:, +required and -excluded terms and \"quoted phrases\", e.g. repo:api lang:go type:function name:Parse* -vendor (default: true)"),
+		),
+		mcp.WithBoolean("case_sensitive",
+			mcp.Description("Match identifiers in names and content as written, so Open does not find open (default: false)"),
+		),
+		mcp.WithBoolean("whole_word",
+			mcp.Description("Only match whole identifiers in names and content, so open does not find openFile; several words must be adjacent (default: false)"),
+		),
+		mcp.WithString("profile",
+			mcp.Description("Scoring profile: default (names above content, definitions above comments and whole files), symbols (names and definitions first), recent (recently modified files first) or text (text relevance alone); defaults to search.ranking.profile"),
+		),
 		mcp.WithNumber("max_results",
 			mcp.Description("Maximum number of results to return (default: 100)"),
 		),
+		mcp.WithNumber("page_size",
+			mcp.Description("Results per page; takes precedence over max_results"),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("Resume after the previous page, use its next_cursor"),
+		),
+		mcp.WithBoolean("follow_ups",
+			mcp.Description("Attach ready-to-use get_file_snippet, find_references and git_blame arguments to each result (default: true)"),
+		),
+		mcp.WithBoolean("hybrid",
+			mcp.Description("Also rank by embedding similarity and fuse both scores; requires embeddings.enabled (default: false)"),
+		),
+		mcp.WithBoolean("regex",
+			mcp.Description("Treat query as a Go regular expression matched line by line against file contents, returning exact line and column spans (default: false)"),
+		),
 	)
-	s.server.AddTool(searchCodeTool, s.handleSearchCode)
+	s.addTool(searchCodeTool, s.handleSearchCode)
 
 	// Get Metadata Tool
 	getMetadataTool := mcp.NewTool("get_metadata",
@@ -225,27 +432,134 @@ func (s *MCPServer) registerCoreTools() error {
 			mcp.Description("Repository name (optional)"),
 		),
 	)
-	s.server.AddTool(getMetadataTool, s.handleGetMetadata)
+	s.addTool(getMetadataTool, s.handleGetMetadata)
 
 	// List Repositories Tool
 	listReposTool := mcp.NewTool("list_repositories",
 		mcp.WithDescription("List all indexed repositories with statistics"),
 	)
-	s.server.AddTool(listReposTool, s.handleListRepositories)
+	s.addTool(listReposTool, s.handleListRepositories)
 
 	// Get Index Stats Tool
 	getStatsTool := mcp.NewTool("get_index_stats",
 		mcp.WithDescription("Get indexing statistics and information"),
 	)
-	s.server.AddTool(getStatsTool, s.handleGetIndexStats)
+	s.addTool(getStatsTool, s.handleGetIndexStats)
+
+	// Indexing History Tool
+	indexingHistoryTool := mcp.NewTool("indexing_history",
+		mcp.WithDescription("Get per-phase timings (prepare, walk, references, parse, chunk, index) of past indexing runs to spot slowdowns"),
+		mcp.WithString("repository",
+			mcp.Description("Repository name or ID (optional, all repositories if not specified)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of runs to return, newest first (default: 10)"),
+		),
+	)
+	s.addTool(indexingHistoryTool, s.handleIndexingHistory)
+
+	// Get Indexing Progress Tool
+	getIndexingProgressTool := mcp.NewTool("get_indexing_progress",
+		mcp.WithDescription("Get the status and progress of a background indexing job started with index_repository async=true"),
+		mcp.WithString("job_id",
+			mcp.Required(),
+			mcp.Description("Job ID returned by index_repository"),
+		),
+	)
+	s.addTool(getIndexingProgressTool, s.handleGetIndexingProgress)
+
+	// Cancel Indexing Tool
+	cancelIndexingTool := mcp.NewTool("cancel_indexing",
+		mcp.WithDescription("Cancel a queued or running background indexing job"),
+		mcp.WithString("job_id",
+			mcp.Required(),
+			mcp.Description("Job ID returned by index_repository"),
+		),
+	)
+	s.addTool(cancelIndexingTool, s.handleCancelIndexing)
+
+	// Remove Repository Tool
+	removeRepositoryTool := mcp.NewTool("remove_repository",
+		mcp.WithDescription("Remove a repository from the index with its metadata, optionally deleting its cloned working copy"),
+		mcp.WithString("repository",
+			mcp.Required(),
+			mcp.Description("Repository name or ID"),
+		),
+		mcp.WithBoolean("delete_clone",
+			mcp.Description("Also delete the working copy of a repository cloned from a URL; local repositories are never deleted (default: false)"),
+		),
+	)
+	s.addTool(removeRepositoryTool, s.handleRemoveRepository)
+
+	// Cleanup Orphans Tool
+	cleanupOrphansTool := mcp.NewTool("cleanup_orphans",
+		mcp.WithDescription("Find and remove indexed repositories whose local source path no longer exists, and records with no documents left in the index"),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Only report the orphaned repositories without removing them (default: false)"),
+		),
+	)
+	s.addTool(cleanupOrphansTool, s.handleCleanupOrphans)
+
+	// Optimize Index Tool
+	optimizeIndexTool := mcp.NewTool("optimize_index",
+		mcp.WithDescription("Merge the search index segments into one, reclaiming the space of deleted documents, and report the index size before and after"),
+	)
+	s.addTool(optimizeIndexTool, s.handleOptimizeIndex)
+
+	// Verify Index Tool
+	verifyIndexTool := mcp.NewTool("verify_index",
+		mcp.WithDescription("Check the indexed files against the repositories on disk, reporting files that changed, disappeared or are not indexed, and optionally repair them"),
+		mcp.WithString("repository",
+			mcp.Description("Repository name or ID to check (optional, checks all if not specified)"),
+		),
+		mcp.WithBoolean("repair",
+			mcp.Description("Re-index the files found out of date and drop the documents of files that are gone (default: false)"),
+		),
+	)
+	s.addTool(verifyIndexTool, s.handleVerifyIndex)
+
+	// Switch Ref Tool
+	switchRefTool := mcp.NewTool("switch_ref",
+		mcp.WithDescription("Check out another branch, tag or commit in a cloned repository and re-index only the files that differ"),
+		mcp.WithString("repository",
+			mcp.Required(),
+			mcp.Description("Repository name or ID; must be cloned from a URL or indexed with a ref"),
+		),
+		mcp.WithString("ref",
+			mcp.Required(),
+			mcp.Description("Branch, tag or commit to check out"),
+		),
+	)
+	s.addTool(switchRefTool, s.handleSwitchRef)
+
+	// Index Dependencies Tool
+	indexDependenciesTool := mcp.NewTool("index_dependencies",
+		mcp.WithDescription("Resolve the third-party dependencies of an indexed repository to their installed sources, Go modules of go.mod in vendor or the module cache, packages of package.json in node_modules and distributions of requirements.txt in its virtual environment, and list them or index the packages named into the deps namespace, which search_code and find_symbols search with include_deps"),
+		mcp.WithString("repository",
+			mcp.Required(),
+			mcp.Description("Repository name or ID whose dependencies to resolve"),
+		),
+		mcp.WithArray("packages",
+			mcp.Description("Packages to index, by name or name@version as listed, e.g. [\"github.com/spf13/cobra\", \"react\"]; without it the dependencies are listed and nothing is indexed"),
+			mcp.WithStringItems(),
+		),
+	)
+	s.addTool(indexDependenciesTool, s.handleIndexDependencies)
+
+	// Sync Configured Repositories Tool
+	syncConfiguredTool := mcp.NewTool("sync_configured_repositories",
+		mcp.WithDescription("Read the repositories section of the configuration file again and apply it: queue every repository listed there for indexing in the background with its configured branch, patterns and chunking, and start watching those with watch set, stopping the watchers of the others"),
+	)
+	s.addTool(syncConfiguredTool, s.handleSyncConfiguredRepositories)
 
-	s.logger.Info("Core tools registered successfully", zap.Int("tool_count", 5))
+	s.logger.Info("Core tools registered successfully", zap.Int("tool_count", 15))
 	return nil
 }
 
 // registerUtilityTools registers utility tools for file operations
 func (s *MCPServer) registerUtilityTools() error {
 	s.logger.Info("Registering utility tools...")
+	registered := len(s.handlers)
 
 	// Find Files Tool
 	findFilesTool := mcp.NewTool("find_files",
@@ -255,32 +569,604 @@ func (s *MCPServer) registerUtilityTools() error {
 			mcp.Description("File name pattern (supports wildcards like *.go, *test*, etc.)"),
 		),
 		mcp.WithString("repository",
-			mcp.Description("Repository name to search in (optional, searches all if not specified)"),
+			mcp.Description("Repository name to search in (optional, searches all if not specified)"),
+		),
+		mcp.WithString("workspace",
+			mcp.Description("Only search the repositories of this workspace (default: the session's workspace when no repository is named)"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Only search the files of this sub-project, given by its directory as list_projects returns it, e.g. services/api"),
+		),
+		mcp.WithArray("projects",
+			mcp.Description("Match any of these sub-projects"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Include file content preview in results"),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description("Files per page (default and max: 100)"),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("Resume after the previous page, use its next_cursor"),
+		),
+	)
+	s.addTool(findFilesTool, s.handleFindFiles)
+
+	// Find Symbols Tool
+	findSymbolsTool := mcp.NewTool("find_symbols",
+		mcp.WithDescription("Find symbols (functions, classes, interfaces, type aliases, variables, Protocol Buffers and SQL definitions) by name"),
+		mcp.WithString("symbol_name",
+			mcp.Required(),
+			mcp.Description("Symbol name or pattern to search for"),
+		),
+		mcp.WithString("symbol_type",
+			mcp.Description("Type of symbol: function, class, variable, interface and type_alias for TypeScript, message, enum, service and rpc for Protocol Buffers, or table, view, index and procedure for SQL"),
+		),
+		mcp.WithString("language",
+			mcp.Description("Programming language to filter by"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name to search in (optional)"),
+		),
+		mcp.WithArray("symbol_types",
+			mcp.Description("Match any of these symbol types, e.g. [\"function\", \"class\"]"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("languages",
+			mcp.Description("Match any of these languages"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("repositories",
+			mcp.Description("Match any of these repositories"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("workspace",
+			mcp.Description("Only search the repositories of this workspace (default: the session's workspace when no repository is named)"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Only search the files of this sub-project, given by its directory as list_projects returns it, e.g. services/api"),
+		),
+		mcp.WithArray("projects",
+			mcp.Description("Match any of these sub-projects"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithBoolean("include_deps",
+			mcp.Description("Also search the dependency sources indexed with index_dependencies (default: false)"),
+		),
+		mcp.WithNumber("fuzziness",
+			mcp.Description("Maximum edits between each word of symbol_name and a name: 0 for exact words, 1 (default) or 2"),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description("Symbols per page (default and max: 100)"),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("Resume after the previous page, use its next_cursor"),
+		),
+	)
+	s.addTool(findSymbolsTool, s.handleFindSymbols)
+
+	// Complete Symbol Tool
+	completeSymbolTool := mcp.NewTool("complete_symbol",
+		mcp.WithDescription("Complete a partial symbol name from the indexed names, most relevant and most used first"),
+		mcp.WithString("prefix",
+			mcp.Required(),
+			mcp.Description("Start of the name, e.g. parseJ; earlier words must match whole words, as in \"http cli\""),
+		),
+		mcp.WithString("symbol_type",
+			mcp.Description("Only complete symbols of this type: function, class, interface, type_alias, variable, or a Protocol Buffers or SQL kind such as message or table"),
+		),
+		mcp.WithArray("symbol_types",
+			mcp.Description("Only complete symbols of any of these types"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("language",
+			mcp.Description("Programming language to filter by"),
+		),
+		mcp.WithArray("languages",
+			mcp.Description("Match any of these languages"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name to complete from (optional)"),
+		),
+		mcp.WithArray("repositories",
+			mcp.Description("Match any of these repositories"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("workspace",
+			mcp.Description("Only search the repositories of this workspace (default: the session's workspace when no repository is named)"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Only search the files of this sub-project, given by its directory as list_projects returns it, e.g. services/api"),
+		),
+		mcp.WithArray("projects",
+			mcp.Description("Match any of these sub-projects"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of completions (default: 20, max: 100)"),
+		),
+	)
+	s.addTool(completeSymbolTool, s.handleCompleteSymbol)
+
+	// Save Search Tool
+	saveSearchTool := mcp.NewTool("save_search",
+		mcp.WithDescription("Save search_code arguments under a name so the search can be run again with run_saved_search; without arguments, the session's last search is saved"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name to save the search as; an existing search of that name is replaced"),
+		),
+		mcp.WithString("description",
+			mcp.Description("What the search finds (optional)"),
+		),
+		mcp.WithObject("arguments",
+			mcp.Description("search_code arguments to save, e.g. {\"query\": \"lang:go TODO\", \"type\": \"comment\"} (default: the last search of the session)"),
+		),
+		mcp.WithBoolean("delete",
+			mcp.Description("Delete the saved search instead (default: false)"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Session the search is saved in (optional)"),
+		),
+	)
+	s.addTool(saveSearchTool, s.handleSaveSearch)
+
+	// List Saved Searches Tool
+	listSavedSearchesTool := mcp.NewTool("list_saved_searches",
+		mcp.WithDescription("List the searches saved in the session and the searches it ran most recently"),
+		mcp.WithNumber("history_limit",
+			mcp.Description("Number of recent searches to list, 0 for none (default: 20, at most 100 are kept)"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Session to list (optional)"),
+		),
+	)
+	s.addTool(listSavedSearchesTool, s.handleListSavedSearches)
+
+	// Run Saved Search Tool
+	runSavedSearchTool := mcp.NewTool("run_saved_search",
+		mcp.WithDescription("Run a search saved with save_search and return the search_code results"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Name of the saved search"),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description("Results per page, in place of the saved value"),
+		),
+		mcp.WithNumber("max_results",
+			mcp.Description("Maximum number of results, in place of the saved value"),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("Resume after the previous page, use its next_cursor"),
+		),
+		mcp.WithBoolean("follow_ups",
+			mcp.Description("Attach follow-up tool calls to each result, in place of the saved value"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Session the search was saved in (optional)"),
+		),
+	)
+	s.addTool(runSavedSearchTool, s.handleRunSavedSearch)
+
+	// Set Workspace Tool
+	setWorkspaceTool := mcp.NewTool("set_workspace",
+		mcp.WithDescription("Create or replace a workspace, a named group of indexed repositories that search tools accept as workspace instead of a repository"),
+		mcp.WithString("name",
+			mcp.Required(),
+			mcp.Description("Workspace name, e.g. backend"),
+		),
+		mcp.WithArray("repositories",
+			mcp.Description("Names of the indexed repositories the workspace groups (required unless delete is true)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("description",
+			mcp.Description("What the workspace holds (optional)"),
+		),
+		mcp.WithBoolean("delete",
+			mcp.Description("Delete the workspace instead; its repositories stay indexed (default: false)"),
+		),
+	)
+	s.addTool(setWorkspaceTool, s.handleSetWorkspace)
+
+	// List Workspaces Tool
+	listWorkspacesTool := mcp.NewTool("list_workspaces",
+		mcp.WithDescription("List the workspaces with their repositories, and the session's default workspace"),
+		mcp.WithString("session_id",
+			mcp.Description("Session whose default workspace to report (optional)"),
+		),
+	)
+	s.addTool(listWorkspacesTool, s.handleListWorkspaces)
+
+	// Use Workspace Tool
+	useWorkspaceTool := mcp.NewTool("use_workspace",
+		mcp.WithDescription("Scope the session's searches to a workspace whenever they name no repository or workspace"),
+		mcp.WithString("name",
+			mcp.Description("Workspace to use; empty to stop scoping searches"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Session to scope (optional)"),
+		),
+	)
+	s.addTool(useWorkspaceTool, s.handleUseWorkspace)
+
+	// List Projects Tool
+	listProjectsTool := mcp.NewTool("list_projects",
+		mcp.WithDescription("List the sub-projects of a repository, such as the modules of a monorepo, found by their go.mod, package.json, pom.xml or pyproject.toml"),
+		mcp.WithString("repository",
+			mcp.Required(),
+			mcp.Description("Repository name or ID"),
+		),
+	)
+	s.addTool(listProjectsTool, s.handleListProjects)
+
+	// Export Index Tool
+	exportIndexTool := mcp.NewTool("export_index",
+		mcp.WithDescription("Export the indexes of repositories with their records, settings and indexing history to a portable archive that import_index loads on another server"),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Archive file to write, e.g. /data/shared/platform.cidx.tar.gz"),
+		),
+		mcp.WithArray("repositories",
+			mcp.Description("Names or IDs of the repositories to export (default: all)"),
+			mcp.WithStringItems(),
+		),
+	)
+	s.addTool(exportIndexTool, s.handleExportIndex)
+
+	// Import Index Tool
+	importIndexTool := mcp.NewTool("import_index",
+		mcp.WithDescription("Import the repository indexes of an archive written by export_index, so they are searchable without indexing the repositories again"),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("Archive file to read"),
+		),
+		mcp.WithArray("repositories",
+			mcp.Description("Names or IDs of the repositories to import (default: all in the archive)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("repository_root",
+			mcp.Description("Directory the repositories are checked out under here; each is recorded at the directory of its name below it (default: the paths they were exported from)"),
+		),
+		mcp.WithBoolean("overwrite",
+			mcp.Description("Replace repositories indexed here already instead of skipping them (default: false)"),
+		),
+	)
+	s.addTool(importIndexTool, s.handleImportIndex)
+
+	// Get File Outline Tool
+	getFileOutlineTool := mcp.NewTool("get_file_outline",
+		mcp.WithDescription("Get the symbol tree of a file: classes and types with their methods and fields, and nested functions, with line ranges, signatures and doc strings"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name (optional)"),
+		),
+	)
+	s.addTool(getFileOutlineTool, s.handleGetFileOutline)
+
+	// Goto Definition Tool
+	gotoDefinitionTool := mcp.NewTool("goto_definition",
+		mcp.WithDescription("Resolve a name used on a line of a file to its definition, following the file's imports (Go packages, Python modules, JavaScript and TypeScript relative imports) among the indexed definitions, including those of other indexed repositories the imports name, and return the defining file, line and signature"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file using the name"),
+		),
+		mcp.WithNumber("line",
+			mcp.Required(),
+			mcp.Description("Line the name is used on (1-based)"),
+		),
+		mcp.WithString("symbol_name",
+			mcp.Required(),
+			mcp.Description("Name to resolve, optionally qualified as used, e.g. store.Load"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name (optional)"),
+		),
+	)
+	s.addTool(gotoDefinitionTool, s.handleGotoDefinition)
+
+	// LSP Hover Tool
+	lspHoverTool := mcp.NewTool("lsp_hover",
+		mcp.WithDescription("Get the type signature and documentation of the symbol at a position of a file from the language server configured for its language (gopls, pyright, typescript-language-server), falling back to the indexed definition when no server is available"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file"),
+		),
+		mcp.WithNumber("line",
+			mcp.Required(),
+			mcp.Description("Line of the symbol (1-based)"),
+		),
+		mcp.WithNumber("column",
+			mcp.Description("Column of the symbol (1-based, in characters); required unless symbol_name is given"),
+		),
+		mcp.WithString("symbol_name",
+			mcp.Description("Name used on the line, to find the column when it is not given"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name (optional)"),
+		),
+	)
+	s.addTool(lspHoverTool, s.handleLSPHover)
+
+	// LSP Definition Tool
+	lspDefinitionTool := mcp.NewTool("lsp_definition",
+		mcp.WithDescription("Find where the symbol at a position of a file is defined using the language server, falling back to goto_definition's index lookup when no server is available"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file"),
+		),
+		mcp.WithNumber("line",
+			mcp.Required(),
+			mcp.Description("Line of the symbol (1-based)"),
+		),
+		mcp.WithNumber("column",
+			mcp.Description("Column of the symbol (1-based, in characters); required unless symbol_name is given"),
+		),
+		mcp.WithString("symbol_name",
+			mcp.Description("Name used on the line, to find the column when it is not given"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name (optional)"),
+		),
+	)
+	s.addTool(lspDefinitionTool, s.handleLSPDefinition)
+
+	// LSP References Tool
+	lspReferencesTool := mcp.NewTool("lsp_references",
+		mcp.WithDescription("Find every reference to the symbol at a position of a file using the language server, falling back to the indexed references when no server is available"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file"),
+		),
+		mcp.WithNumber("line",
+			mcp.Required(),
+			mcp.Description("Line of the symbol (1-based)"),
+		),
+		mcp.WithNumber("column",
+			mcp.Description("Column of the symbol (1-based, in characters); required unless symbol_name is given"),
+		),
+		mcp.WithString("symbol_name",
+			mcp.Description("Name used on the line, to find the column when it is not given"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name (optional)"),
+		),
+		mcp.WithBoolean("include_declaration",
+			mcp.Description("Include the declaration itself (default: true)"),
+		),
+	)
+	s.addTool(lspReferencesTool, s.handleLSPReferences)
+
+	// LSP Diagnostics Tool
+	lspDiagnosticsTool := mcp.NewTool("lsp_diagnostics",
+		mcp.WithDescription("Get the errors and warnings the language server reports for a file, falling back to tree-sitter syntax errors when no server is available"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name (optional)"),
+		),
+	)
+	s.addTool(lspDiagnosticsTool, s.handleLSPDiagnostics)
+
+	// Get Diagnostics Tool
+	getDiagnosticsTool := mcp.NewTool("get_diagnostics",
+		mcp.WithDescription("Check a repository or a file for compile and lint errors by running the configured checkers (go build and go vet, pyflakes, tsc --noEmit) or, when none is installed, the language server, and return each problem with its file, line, column, severity and message; use it to validate edits"),
+		mcp.WithString("repository",
+			mcp.Description("Repository to check; with file_path, the repository the path is relative to"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("File to check; problems elsewhere are left out"),
+		),
+		mcp.WithString("language",
+			mcp.Description("Only run the checkers of this language (default: the file's language, or every language of the repository)"),
+		),
+		mcp.WithString("source",
+			mcp.Description("auto (checkers, else the language server), checkers or lsp (default: auto)"),
+		),
+	)
+	s.addTool(getDiagnosticsTool, s.handleGetDiagnostics)
+
+	// Run Tests Tool
+	runTestsTool := mcp.NewTool("run_tests",
+		mcp.WithDescription("Run the tests of a repository, package or file with the language's test command (go test, pytest, jest, or as configured) and return the status, duration and failure output of each test; use it to verify edits"),
+		mcp.WithString("repository",
+			mcp.Description("Repository whose tests to run; with file_path, the repository the path is relative to"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("Test file or directory to run (for Go, the tests of its package)"),
+		),
+		mcp.WithString("package",
+			mcp.Description("Package or directory of the repository to run, e.g. ./internal/store"),
+		),
+		mcp.WithString("test_name",
+			mcp.Description("Only run the tests matching this name (go test -run, pytest -k, jest -t)"),
+		),
+		mcp.WithString("language",
+			mcp.Description("Test runner language (default: detected from the file or the repository's build files)"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Stop the tests after this many seconds (default and maximum: tests.timeout_seconds)"),
+		),
+		mcp.WithBoolean("only_failures",
+			mcp.Description("Only list the tests that failed or timed out (default: false)"),
+		),
+	)
+	s.addTool(runTestsTool, s.handleRunTests)
+
+	// Find Dependencies Tool
+	findDependenciesTool := mcp.NewTool("find_dependencies",
+		mcp.WithDescription("Build the import graph of a repository from its indexed imports: what a file or package imports and what imports it, aggregated by package, with import cycles; as JSON or Graphviz DOT"),
+		mcp.WithString("repository",
+			mcp.Description("Repository to map; with file_path, the repository the path is relative to"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("File or directory to focus on; without it the whole graph is returned"),
+		),
+		mcp.WithString("level",
+			mcp.Description("Graph nodes: file or package, a directory (default: file for a file_path, package otherwise)"),
+		),
+		mcp.WithString("direction",
+			mcp.Description("With file_path: dependencies (what it imports), dependents (what imports it) or both (default)"),
+		),
+		mcp.WithNumber("depth",
+			mcp.Description("With file_path: how many imports away to follow, 0 for no limit (default: 1)"),
+		),
+		mcp.WithBoolean("include_external",
+			mcp.Description("Include modules outside the repository, such as the standard library and packages (default: false)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Graph format: json (default) or dot"),
+		),
+	)
+	s.addTool(findDependenciesTool, s.handleFindDependencies)
+
+	// Get Chunk Graph Tool
+	getChunkGraphTool := mcp.NewTool("get_chunk_graph",
+		mcp.WithDescription("Build the chunk-level dependency graph of a repository from the calls, type usages and imports recorded for each indexed chunk: which chunks use the functions and classes other chunks define, and what each uses from outside the repository"),
+		mcp.WithString("repository",
+			mcp.Description("Repository to map; with file_path, the repository the path is relative to"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("File whose chunks to focus on, with the chunks they depend on and those depending on them; without it the whole graph is returned"),
+		),
+	)
+	s.addTool(getChunkGraphTool, s.handleGetChunkGraph)
+
+	// Detect Code Smells Tool
+	detectCodeSmellsTool := mcp.NewTool("detect_code_smells",
+		mcp.WithDescription("Analyze the syntax tree of a Go, Python, JavaScript, TypeScript or Java file for code smells: long functions and parameter lists, deep nesting, large classes and files, duplicated lines and unnamed numbers, measured against the configured thresholds"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("File to analyze"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository the path is relative to (optional)"),
+		),
+		mcp.WithString("severity_threshold",
+			mcp.Description("Least severity reported: low, medium (default), high or critical"),
+		),
+		mcp.WithArray("smell_types",
+			mcp.Description("Only report these smells: long_function, long_parameter_list, deep_nesting, large_class, large_file, duplicate_code or magic_number"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: json (default) or sarif, a SARIF 2.1.0 log for code scanning"),
+		),
+	)
+	s.addTool(detectCodeSmellsTool, s.handleDetectCodeSmells)
+
+	// Analyze Complexity Tool
+	analyzeComplexityTool := mcp.NewTool("analyze_complexity",
+		mcp.WithDescription("Measure the cyclomatic, cognitive and Halstead complexity and maintainability index of each function in a file from its syntax tree, or aggregate the complexity recorded at indexing time across a repository with its most complex functions and files"),
+		mcp.WithString("repository",
+			mcp.Description("Repository to aggregate; with file_path, the repository the path is relative to"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("File whose functions to measure; one of repository and file_path is required"),
+		),
+		mcp.WithNumber("min_complexity",
+			mcp.Description("Only list functions with at least this cyclomatic complexity"),
+		),
+		mcp.WithString("sort_by",
+			mcp.Description("Function order: cyclomatic (default), cognitive or line"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("For a repository, how many functions and files to list, 0 for all (default: 20)"),
+		),
+	)
+	s.addTool(analyzeComplexityTool, s.handleAnalyzeComplexity)
+
+	// List Security Findings Tool
+	listSecurityFindingsTool := mcp.NewTool("list_security_findings",
+		mcp.WithDescription("List the hardcoded secrets found while repositories were indexed: cloud and API keys, private keys, tokens, passwords and high entropy strings, with redacted values, most severe first"),
+		mcp.WithString("repository",
+			mcp.Description("Repository to list findings of (default: all indexed repositories)"),
+		),
+		mcp.WithString("severity_threshold",
+			mcp.Description("Least severity listed: low (default), medium, high or critical"),
+		),
+		mcp.WithArray("rules",
+			mcp.Description("Only list findings of these rules, such as aws_access_key_id, private_key or high_entropy_string"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("Only list findings in this file or directory, relative to the repository"),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description("Findings per page (default and maximum: 200)"),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("Resume after the previous page, use its next_cursor"),
+		),
+	)
+	s.addTool(listSecurityFindingsTool, s.handleListSecurityFindings)
+
+	// Detect Security Issues Tool
+	detectSecurityIssuesTool := mcp.NewTool("detect_security_issues",
+		mcp.WithDescription("Scan a file for hardcoded secrets, including unsaved buffers, or summarize the secrets found in a repository when it was indexed by severity, rule and file"),
+		mcp.WithString("file_path",
+			mcp.Description("File to scan; one of file_path and repository is required"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository to summarize; with file_path, the repository the path is relative to"),
 		),
-		mcp.WithBoolean("include_content",
-			mcp.Description("Include file content preview in results"),
+		mcp.WithString("severity_threshold",
+			mcp.Description("Least severity reported: low, medium (default), high or critical"),
+		),
+		mcp.WithArray("rules",
+			mcp.Description("Only report findings of these rules"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: json (default) or sarif, a SARIF 2.1.0 log for code scanning"),
 		),
 	)
-	s.server.AddTool(findFilesTool, s.handleFindFiles)
+	s.addTool(detectSecurityIssuesTool, s.handleDetectSecurityIssues)
 
-	// Find Symbols Tool
-	findSymbolsTool := mcp.NewTool("find_symbols",
-		mcp.WithDescription("Find symbols (functions, classes, variables) by name"),
-		mcp.WithString("symbol_name",
-			mcp.Required(),
-			mcp.Description("Symbol name or pattern to search for"),
+	// Generate Metrics Report Tool
+	generateMetricsReportTool := mcp.NewTool("generate_metrics_report",
+		mcp.WithDescription("Report the size, complexity, code smells and hardcoded secrets of a file, or the size, complexity and secrets recorded for a repository when it was indexed, as JSON or as a SARIF 2.1.0 log for GitHub code scanning"),
+		mcp.WithString("repository",
+			mcp.Description("Repository to report on; with file_path, the repository the path is relative to"),
 		),
-		mcp.WithString("symbol_type",
-			mcp.Description("Type of symbol: function, class, variable, constant, interface"),
+		mcp.WithString("file_path",
+			mcp.Description("File to report on; one of repository and file_path is required"),
 		),
-		mcp.WithString("language",
-			mcp.Description("Programming language to filter by"),
+		mcp.WithNumber("max_complexity",
+			mcp.Description("Cyclomatic complexity above which functions are listed or reported (default: 10)"),
+		),
+		mcp.WithString("format",
+			mcp.Description("Output format: json (default) or sarif"),
+		),
+	)
+	s.addTool(generateMetricsReportTool, s.handleGenerateMetricsReport)
+
+	// Analyze Test Coverage Tool
+	analyzeTestCoverageTool := mcp.NewTool("analyze_test_coverage",
+		mcp.WithDescription("Find the test files of a source file by naming conventions and imports, list its exported functions, methods and classes no test mentions, and read a Go coverage profile, LCOV or Cobertura coverage.xml report for the coverage gaps of each function"),
+		mcp.WithString("source_file",
+			mcp.Required(),
+			mcp.Description("Source file to analyze"),
 		),
 		mcp.WithString("repository",
-			mcp.Description("Repository name to search in (optional)"),
+			mcp.Description("Repository the path is relative to (optional)"),
+		),
+		mcp.WithString("test_directory",
+			mcp.Description("Another directory to look for test files in, relative to the repository"),
+		),
+		mcp.WithString("coverage_file",
+			mcp.Description("Coverage report relative to the repository (default: the first of coverage.out, lcov.info, coverage.xml and other usual names found)"),
+		),
+		mcp.WithBoolean("include_private",
+			mcp.Description("Also check unexported functions, methods and classes (default: false)"),
 		),
 	)
-	s.server.AddTool(findSymbolsTool, s.handleFindSymbols)
+	s.addTool(analyzeTestCoverageTool, s.handleAnalyzeTestCoverage)
 
 	// Get File Content Tool
 	getFileContentTool := mcp.NewTool("get_file_content",
@@ -299,7 +1185,7 @@ func (s *MCPServer) registerUtilityTools() error {
 			mcp.Description("End line number (optional, 1-based)"),
 		),
 	)
-	s.server.AddTool(getFileContentTool, s.handleGetFileContent)
+	s.addTool(getFileContentTool, s.handleGetFileContent)
 
 	// List Directory Tool
 	listDirectoryTool := mcp.NewTool("list_directory",
@@ -317,8 +1203,29 @@ func (s *MCPServer) registerUtilityTools() error {
 		mcp.WithString("file_filter",
 			mcp.Description("File extension filter (e.g., '.go', '.py')"),
 		),
+		mcp.WithNumber("max_depth",
+			mcp.Description("Deepest directory level to list when recursive (default: unlimited)"),
+		),
+		mcp.WithNumber("max_entries_per_directory",
+			mcp.Description("Maximum entries listed per directory; omitted counts are reported (default: unlimited)"),
+		),
+		mcp.WithBoolean("directories_only",
+			mcp.Description("Only list directories, for a quick structural overview (default: false)"),
+		),
+		mcp.WithString("sort_by",
+			mcp.Description("Sort entries by name, size or modified (default: name)"),
+		),
+		mcp.WithBoolean("descending",
+			mcp.Description("Sort in descending order (default: false)"),
+		),
+		mcp.WithNumber("offset",
+			mcp.Description("Number of entries to skip, use next_offset from the previous page (default: 0)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum entries to return (default: 500, max: 5000)"),
+		),
 	)
-	s.server.AddTool(listDirectoryTool, s.handleListDirectory)
+	s.addTool(listDirectoryTool, s.handleListDirectory)
 
 	// File Manipulation Tools
 
@@ -337,8 +1244,11 @@ func (s *MCPServer) registerUtilityTools() error {
 			mcp.Required(),
 			mcp.Description("End line number (1-based, inclusive)"),
 		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the diff of the edit without writing the file (default: false)"),
+		),
 	)
-	s.server.AddTool(deleteLinesTool, s.handleDeleteLines)
+	s.addWriteTool(deleteLinesTool, s.handleDeleteLines)
 
 	// Insert At Line Tool
 	insertAtLineTool := mcp.NewTool("insert_at_line",
@@ -355,8 +1265,11 @@ func (s *MCPServer) registerUtilityTools() error {
 			mcp.Required(),
 			mcp.Description("Content to insert (supports multi-line content)"),
 		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the diff of the edit without writing the file (default: false)"),
+		),
 	)
-	s.server.AddTool(insertAtLineTool, s.handleInsertAtLine)
+	s.addWriteTool(insertAtLineTool, s.handleInsertAtLine)
 
 	// Replace Lines Tool
 	replaceLinesTool := mcp.NewTool("replace_lines",
@@ -377,8 +1290,143 @@ func (s *MCPServer) registerUtilityTools() error {
 			mcp.Required(),
 			mcp.Description("New content to replace the lines (supports multi-line content)"),
 		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the diff of the edit without writing the file (default: false)"),
+		),
+	)
+	s.addWriteTool(replaceLinesTool, s.handleReplaceLines)
+
+	// Symbol Editing Tools
+
+	// Replace Symbol Body Tool
+	replaceSymbolBodyTool := mcp.NewTool("replace_symbol_body",
+		mcp.WithDescription("Replace the body of a function, method or type found by name in the file's syntax tree, without line numbers. Braced bodies keep their braces unless new_body brings its own; the body is re-indented to fit"),
+		mcp.WithString("symbol_name",
+			mcp.Required(),
+			mcp.Description("Name of the declaration, optionally qualified with its type or class (e.g. Store.Load)"),
+		),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file"),
+		),
+		mcp.WithString("new_body",
+			mcp.Required(),
+			mcp.Description("New body (supports multi-line content)"),
+		),
+		mcp.WithNumber("line",
+			mcp.Description("A line within the declaration, to pick one of several with the same name"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the diff of the edit without writing the file (default: false)"),
+		),
+	)
+	s.addWriteTool(replaceSymbolBodyTool, s.handleReplaceSymbolBody)
+
+	// Insert After Symbol Tool
+	insertAfterSymbolTool := mcp.NewTool("insert_after_symbol",
+		mcp.WithDescription("Insert code after a declaration found by name in the file's syntax tree, separated by a blank line and indented like the declaration"),
+		mcp.WithString("symbol_name",
+			mcp.Required(),
+			mcp.Description("Name of the declaration, optionally qualified with its type or class (e.g. Store.Load)"),
+		),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file"),
+		),
+		mcp.WithString("content",
+			mcp.Required(),
+			mcp.Description("Code to insert (supports multi-line content)"),
+		),
+		mcp.WithNumber("line",
+			mcp.Description("A line within the declaration, to pick one of several with the same name"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the diff of the edit without writing the file (default: false)"),
+		),
+	)
+	s.addWriteTool(insertAfterSymbolTool, s.handleInsertAfterSymbol)
+
+	// Insert Before Symbol Tool
+	insertBeforeSymbolTool := mcp.NewTool("insert_before_symbol",
+		mcp.WithDescription("Insert code before a declaration found by name in the file's syntax tree, above its doc comment or decorators, separated by a blank line and indented like the declaration"),
+		mcp.WithString("symbol_name",
+			mcp.Required(),
+			mcp.Description("Name of the declaration, optionally qualified with its type or class (e.g. Store.Load)"),
+		),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file"),
+		),
+		mcp.WithString("content",
+			mcp.Required(),
+			mcp.Description("Code to insert (supports multi-line content)"),
+		),
+		mcp.WithNumber("line",
+			mcp.Description("A line within the declaration, to pick one of several with the same name"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the diff of the edit without writing the file (default: false)"),
+		),
+	)
+	s.addWriteTool(insertBeforeSymbolTool, s.handleInsertBeforeSymbol)
+
+	// Rename Symbol Tool
+	renameSymbolTool := mcp.NewTool("rename_symbol",
+		mcp.WithDescription("Rename a function, method, class or type at its definition and every reference in its repository. Only identifiers in the syntax tree are renamed, never comments or strings, and locals that shadow the name are left alone. Renamed files are re-indexed"),
+		mcp.WithString("symbol_name",
+			mcp.Required(),
+			mcp.Description("Current name, optionally qualified with its type or class (e.g. Store.Load)"),
+		),
+		mcp.WithString("new_name",
+			mcp.Required(),
+			mcp.Description("New name"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository that defines the symbol"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("File that defines the symbol; looked up in the index when omitted"),
+		),
+		mcp.WithNumber("line",
+			mcp.Description("A line within the definition, to pick one of several with the same name"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Return the per-file diffs without writing any file (default: false)"),
+		),
+	)
+	s.addWriteTool(renameSymbolTool, s.handleRenameSymbol)
+
+	// Edit Journal Tools
+
+	// Undo Last Edit Tool
+	undoLastEditTool := mcp.NewTool("undo_last_edit",
+		mcp.WithDescription("Undo the most recent edit made by the line or symbol editing tools in this session, restoring the file content from before it. Refuses if the file changed since"),
+		mcp.WithString("file_path",
+			mcp.Description("Only undo the most recent edit of this file"),
+		),
+	)
+	s.addWriteTool(undoLastEditTool, s.handleUndoLastEdit)
+
+	// Redo Edit Tool
+	redoEditTool := mcp.NewTool("redo_edit",
+		mcp.WithDescription("Reapply the most recently undone edit of this session. Not possible once the file has been edited again"),
+		mcp.WithString("file_path",
+			mcp.Description("Only redo the most recently undone edit of this file"),
+		),
+	)
+	s.addWriteTool(redoEditTool, s.handleRedoEdit)
+
+	// List Edit History Tool
+	listEditHistoryTool := mcp.NewTool("list_edit_history",
+		mcp.WithDescription("List the edits made by the file manipulation tools in this session, newest first, with a diff of each"),
+		mcp.WithString("file_path",
+			mcp.Description("Only list edits of this file"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum edits to return, 0 for all kept edits (default: 20)"),
+		),
 	)
-	s.server.AddTool(replaceLinesTool, s.handleReplaceLines)
+	s.addTool(listEditHistoryTool, s.handleListEditHistory)
 
 	// Advanced Utility Tools
 
@@ -401,7 +1449,7 @@ func (s *MCPServer) registerUtilityTools() error {
 			mcp.Description("Include surrounding context lines"),
 		),
 	)
-	s.server.AddTool(getFileSnippetTool, s.handleGetFileSnippet)
+	s.addTool(getFileSnippetTool, s.handleGetFileSnippet)
 
 	// Find References Tool
 	findReferencesTool := mcp.NewTool("find_references",
@@ -411,16 +1459,26 @@ func (s *MCPServer) registerUtilityTools() error {
 			mcp.Description("Symbol name to search for"),
 		),
 		mcp.WithString("symbol_type",
-			mcp.Description("Type of symbol: function, class, variable, constant, interface"),
+			mcp.Description("Type of symbol: function, class, variable, or interface and type_alias for TypeScript"),
 		),
 		mcp.WithString("repository",
-			mcp.Description("Repository name to search in (optional)"),
+			mcp.Description("Repository name to search in, with the files of other repositories importing its modules (optional)"),
 		),
 		mcp.WithBoolean("include_definitions",
 			mcp.Description("Include symbol definitions in results (default: true)"),
 		),
+		mcp.WithString("mode",
+			mcp.Description("references lists every call site and type use; callers_of lists the functions calling the symbol; callees_of lists the symbols the function calls (default: references)"),
+			mcp.Enum("references", "callers_of", "callees_of"),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description("References per page; callers_of and callees_of page over call sites (default and max: 200)"),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("Resume after the previous page, use its next_cursor"),
+		),
 	)
-	s.server.AddTool(findReferencesTool, s.handleFindReferences)
+	s.addTool(findReferencesTool, s.handleFindReferences)
 
 	// Refresh Index Tool
 	refreshIndexTool := mcp.NewTool("refresh_index",
@@ -431,8 +1489,12 @@ func (s *MCPServer) registerUtilityTools() error {
 		mcp.WithBoolean("force_rebuild",
 			mcp.Description("Force complete rebuild of the index"),
 		),
+		mcp.WithString("mode",
+			mcp.Description("full re-indexes every file; incremental only re-indexes files changed by commits since the last index (default: full)"),
+			mcp.Enum("full", "incremental"),
+		),
 	)
-	s.server.AddTool(refreshIndexTool, s.handleRefreshIndex)
+	s.addTool(refreshIndexTool, s.handleRefreshIndex)
 
 	// Git Blame Tool
 	gitBlameTool := mcp.NewTool("git_blame",
@@ -451,9 +1513,224 @@ func (s *MCPServer) registerUtilityTools() error {
 			mcp.Description("Repository name (optional)"),
 		),
 	)
-	s.server.AddTool(gitBlameTool, s.handleGitBlame)
+	s.addTool(gitBlameTool, s.handleGitBlame)
+
+	// Git Diff Tool
+	gitDiffTool := mcp.NewTool("git_diff",
+		mcp.WithDescription("Get a structured diff of an indexed repository, with per-file hunks and added and removed line counts, between two commits, a commit and the working tree, or a commit and the staged changes"),
+		mcp.WithString("repository",
+			mcp.Required(),
+			mcp.Description("Repository name or ID"),
+		),
+		mcp.WithString("from",
+			mcp.Description("Commit, branch or tag the diff starts from (default: HEAD)"),
+		),
+		mcp.WithString("to",
+			mcp.Description("Commit, branch or tag to compare with (default: the working tree)"),
+		),
+		mcp.WithBoolean("staged",
+			mcp.Description("Compare with the staged changes instead of the working tree; cannot be combined with to (default: false)"),
+		),
+		mcp.WithArray("paths",
+			mcp.Description("Only diff files at or below these paths relative to the repository, e.g. [\"internal/server\"]"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("context_lines",
+			mcp.Description("Unchanged lines around each change (default: 3, max: 20)"),
+		),
+		mcp.WithBoolean("summary",
+			mcp.Description("Only report the changed files and their line counts, without hunks (default: false)"),
+		),
+	)
+	s.addTool(gitDiffTool, s.handleGitDiff)
+
+	// Sync Buffer Tool
+	syncBufferTool := mcp.NewTool("sync_buffer",
+		mcp.WithDescription("Sync the unsaved contents of an open file so searches, snippets and references use the editor buffer instead of the file on disk"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Path to the file the buffer belongs to"),
+		),
+		mcp.WithString("content",
+			mcp.Description("Current buffer contents (required unless clear is true)"),
+		),
+		mcp.WithBoolean("clear",
+			mcp.Description("Drop the buffer so reads fall back to the file on disk (default: false)"),
+		),
+		mcp.WithString("session_id",
+			mcp.Description("Session the buffer belongs to (optional)"),
+		),
+	)
+	s.addTool(syncBufferTool, s.wrapWithSession(s.handleSyncBuffer))
 
-	s.logger.Info("Utility tools registered successfully", zap.Int("tool_count", 11))
+	// Resolve Stacktrace Tool
+	resolveStacktraceTool := mcp.NewTool("resolve_stacktrace",
+		mcp.WithDescription("Parse a pasted stack trace (Go panic, Python traceback or JavaScript stack), map each frame to indexed files, lines and enclosing symbols, and return snippets for the innermost project frames"),
+		mcp.WithString("trace",
+			mcp.Required(),
+			mcp.Description("Stack trace text as printed by the program"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name to resolve frames in (optional)"),
+		),
+		mcp.WithNumber("max_snippets",
+			mcp.Description("Number of project frames to fetch snippets for (default: 3)"),
+		),
+		mcp.WithNumber("context_lines",
+			mcp.Description("Lines of context around each frame line (default: 3)"),
+		),
+		mcp.WithNumber("max_frames",
+			mcp.Description("Maximum number of frames to return (default: 50)"),
+		),
+	)
+	s.addTool(resolveStacktraceTool, s.handleResolveStacktrace)
+
+	// Semantic Search Tool
+	semanticSearchTool := mcp.NewTool("semantic_search",
+		mcp.WithDescription("Find code chunks by meaning rather than exact terms, ranked by cosine similarity of their embeddings to the query. Requires embeddings.enabled in the configuration"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Natural language description or code to search for"),
+		),
+		mcp.WithString("language",
+			mcp.Description("Filter by programming language"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Filter by repository name"),
+		),
+		mcp.WithString("file_path",
+			mcp.Description("Only search files whose path contains this text"),
+		),
+		mcp.WithArray("languages",
+			mcp.Description("Match any of these languages, e.g. [\"go\", \"python\"]"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("repositories",
+			mcp.Description("Match any of these repositories"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("workspace",
+			mcp.Description("Only search the repositories of this workspace (default: the session's workspace when no repository is named)"),
+		),
+		mcp.WithString("project",
+			mcp.Description("Only search the files of this sub-project, given by its directory as list_projects returns it, e.g. services/api"),
+		),
+		mcp.WithArray("projects",
+			mcp.Description("Match any of these sub-projects"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("max_results",
+			mcp.Description("Maximum number of results to return (default: 20)"),
+		),
+		mcp.WithNumber("min_score",
+			mcp.Description("Drop results with a lower similarity, between -1 and 1 (default: 0)"),
+		),
+		mcp.WithBoolean("follow_ups",
+			mcp.Description("Attach ready-to-use get_file_snippet, find_references and git_blame arguments to each result (default: true)"),
+		),
+	)
+	s.addTool(semanticSearchTool, s.handleSemanticSearch)
+
+	// Get Context Bundle Tool
+	getContextBundleTool := mcp.NewTool("get_context_bundle",
+		mcp.WithDescription("Assemble the context a language model needs about a symbol or question: the symbol's code and doc string, the imports it uses, the signatures of what it calls, its call sites and related chunks, ranked and cut to a token budget, with a ready-to-use Markdown rendering"),
+		mcp.WithString("symbol_name",
+			mcp.Description("Name of the symbol to build the context around; either this or query is required"),
+		),
+		mcp.WithString("query",
+			mcp.Description("Question or description; without symbol_name, the best matching symbol is the target, and related chunks are found by it"),
+		),
+		mcp.WithString("symbol_type",
+			mcp.Description("Only consider symbols of this type, e.g. function"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name to search in (optional)"),
+		),
+		mcp.WithString("language",
+			mcp.Description("Programming language to filter by"),
+		),
+		mcp.WithNumber("max_tokens",
+			mcp.Description("Token budget of the bundle, estimated at four characters per token (default: 4000, max: 100000)"),
+		),
+	)
+	s.addTool(getContextBundleTool, s.handleGetContextBundle)
+
+	// Ask Codebase Tool
+	askCodebaseTool := mcp.NewTool("ask_codebase",
+		mcp.WithDescription("Answer a natural language question about the indexed code, such as \"where is the rate limiter defined?\" or \"who calls ParseConfig?\". The question is translated into symbol, reference, regular expression, file and full-text lookups, which are run and merged into evidence ranked by how many lookups found it, each cited by file and line. The plan is returned so the lookups can be refined with the other search tools"),
+		mcp.WithString("question",
+			mcp.Required(),
+			mcp.Description("Question about the code in plain words; name identifiers, files or paths in it, or quote text to match exactly"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name to search in (optional)"),
+		),
+		mcp.WithString("language",
+			mcp.Description("Programming language to filter by, overriding one named in the question"),
+		),
+		mcp.WithNumber("max_results",
+			mcp.Description("Maximum pieces of evidence to return (default: 10, max: 50)"),
+		),
+		mcp.WithBoolean("use_model",
+			mcp.Description("Have the models engine translate the question instead of its wording alone; needs models enabled (default: false)"),
+		),
+	)
+	s.addTool(askCodebaseTool, s.handleAskCodebase)
+
+	// Grep Repository Tool
+	grepRepositoryTool := mcp.NewTool("grep_repository",
+		mcp.WithDescription("Search repository files directly on disk for literal text or a regular expression, bypassing the index. Use it when the index is stale or for files that are not indexed"),
+		mcp.WithString("pattern",
+			mcp.Required(),
+			mcp.Description("Text or regular expression to search for"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository name to search in (required unless path is absolute)"),
+		),
+		mcp.WithString("path",
+			mcp.Description("Directory to search, relative to the repository or absolute (default: the repository root)"),
+		),
+		mcp.WithBoolean("regex",
+			mcp.Description("Treat pattern as a regular expression (default: false)"),
+		),
+		mcp.WithBoolean("case_sensitive",
+			mcp.Description("Match case exactly (default: true)"),
+		),
+		mcp.WithNumber("before_context",
+			mcp.Description("Lines of context before each match (default: 0, max: 20)"),
+		),
+		mcp.WithNumber("after_context",
+			mcp.Description("Lines of context after each match (default: 0, max: 20)"),
+		),
+		mcp.WithArray("include",
+			mcp.Description("Only search files matching any of these globs, e.g. [\"*.go\", \"docs/**/*.md\"]"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("exclude",
+			mcp.Description("Skip files matching any of these globs"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithNumber("page_size",
+			mcp.Description("Matching lines per page (default: 50, max: 500)"),
+		),
+		mcp.WithString("cursor",
+			mcp.Description("Resume after the previous page, use its next_cursor"),
+		),
+	)
+	s.addTool(grepRepositoryTool, s.handleGrepRepository)
+
+	// Continue Response Tool
+	continueResponseTool := mcp.NewTool("continue_response",
+		mcp.WithDescription("Read the rest of a tool result that was cut to the response size limit. A cut result carries a truncation field naming the fields cut, how many items or bytes were omitted and a continuation_token; each call returns the next page of those fields, with a new token while more remain"),
+		mcp.WithString("continuation_token",
+			mcp.Required(),
+			mcp.Description("continuation_token of the truncation field of the cut result; tokens can be read once and expire after a few minutes"),
+		),
+	)
+	s.addTool(continueResponseTool, s.handleContinueResponse)
+
+	s.utilityTools = len(s.handlers) - registered
+	s.logger.Info("Utility tools registered successfully", zap.Int("tool_count", s.utilityToolCount()))
 	return nil
 }
 
@@ -465,13 +1742,13 @@ func (s *MCPServer) registerProjectTools() error {
 	getCurrentConfigTool := mcp.NewTool("get_current_config",
 		mcp.WithDescription("Get the current configuration of the agent, including active projects, tools, contexts, and modes"),
 	)
-	s.server.AddTool(getCurrentConfigTool, s.handleGetCurrentConfig)
+	s.addTool(getCurrentConfigTool, s.handleGetCurrentConfig)
 
 	// Initial Instructions Tool
 	initialInstructionsTool := mcp.NewTool("initial_instructions",
 		mcp.WithDescription("Get the initial instructions for the current project (for environments where system prompt cannot be set)"),
 	)
-	s.server.AddTool(initialInstructionsTool, s.handleInitialInstructions)
+	s.addTool(initialInstructionsTool, s.handleInitialInstructions)
 
 	// Remove Project Tool
 	removeProjectTool := mcp.NewTool("remove_project",
@@ -481,21 +1758,44 @@ func (s *MCPServer) registerProjectTools() error {
 			mcp.Description("Name of the project to remove"),
 		),
 	)
-	s.server.AddTool(removeProjectTool, s.handleRemoveProject)
+	s.addTool(removeProjectTool, s.handleRemoveProject)
 
 	// Restart Language Server Tool
 	restartLanguageServerTool := mcp.NewTool("restart_language_server",
-		mcp.WithDescription("Restart the language server (useful when external edits occur)"),
+		mcp.WithDescription("Stop the running language servers so they start fresh on their next use (useful when external edits occur or a server misbehaves)"),
+		mcp.WithString("repository",
+			mcp.Description("Only restart the servers of this repository (optional)"),
+		),
+		mcp.WithString("language",
+			mcp.Description("Only restart the server of this language (optional)"),
+		),
+	)
+	s.addTool(restartLanguageServerTool, s.handleRestartLanguageServer)
+
+	// Set Log Level Tool
+	setLogLevelTool := mcp.NewTool("set_log_level",
+		mcp.WithDescription("Change the level of the server's logs until it exits, e.g. to debug to trace the tool calls of a live session; each log line of a call carries its request_id"),
+		mcp.WithString("level",
+			mcp.Required(),
+			mcp.Description("New log level"),
+			mcp.Enum("debug", "info", "warn", "error"),
+		),
 	)
-	s.server.AddTool(restartLanguageServerTool, s.handleRestartLanguageServer)
+	s.addTool(setLogLevelTool, s.handleSetLogLevel)
 
 	// Summarize Changes Tool
 	summarizeChangesTool := mcp.NewTool("summarize_changes",
 		mcp.WithDescription("Provide instructions for summarizing codebase changes"),
 	)
-	s.server.AddTool(summarizeChangesTool, s.handleSummarizeChanges)
+	s.addTool(summarizeChangesTool, s.handleSummarizeChanges)
+
+	// Get Capabilities Tool
+	getCapabilitiesTool := mcp.NewTool("get_capabilities",
+		mcp.WithDescription("Get a machine-readable capability matrix of this deployment: languages with tree-sitter grammars, whether embeddings, LSP and AI models are active, result limits and whether write tools are enabled"),
+	)
+	s.addTool(getCapabilitiesTool, s.handleGetCapabilities)
 
-	s.logger.Info("Project management tools registered successfully", zap.Int("tool_count", 5))
+	s.logger.Info("Project management tools registered successfully", zap.Int("tool_count", 7))
 	return nil
 }
 
@@ -507,7 +1807,7 @@ func (s *MCPServer) registerSessionTools() error {
 	listSessionsTool := mcp.NewTool("list_sessions",
 		mcp.WithDescription("List all active VSCode IDE sessions"),
 	)
-	s.server.AddTool(listSessionsTool, s.wrapWithSession(s.handleListSessions))
+	s.addTool(listSessionsTool, s.wrapWithSession(s.handleListSessions))
 
 	// Create Session Tool
 	createSessionTool := mcp.NewTool("create_session",
@@ -519,14 +1819,20 @@ func (s *MCPServer) registerSessionTools() error {
 		mcp.WithString("workspace_dir",
 			mcp.Description("Workspace directory for the session (optional)"),
 		),
+		mcp.WithString("profile",
+			mcp.Description("Permission profile limiting the tools calls naming the session may use, such as read-only or editor; it cannot widen the caller's own profile (optional)"),
+		),
+		mcp.WithString("workspace",
+			mcp.Description("Workspace to scope the session to, as use_workspace does; with isolate_workspaces the session cannot leave it (optional)"),
+		),
 	)
-	s.server.AddTool(createSessionTool, s.wrapWithSession(s.handleCreateSession))
+	s.addTool(createSessionTool, s.wrapWithSession(s.handleCreateSession))
 
 	// Get Session Info Tool
 	getSessionInfoTool := mcp.NewTool("get_session_info",
 		mcp.WithDescription("Get information about the current session and multi-session configuration"),
 	)
-	s.server.AddTool(getSessionInfoTool, s.wrapWithSession(s.handleGetSessionInfo))
+	s.addTool(getSessionInfoTool, s.wrapWithSession(s.handleGetSessionInfo))
 
 	s.logger.Info("Session management tools registered successfully", zap.Int("tool_count", 3))
 	return nil
@@ -553,7 +1859,7 @@ func (s *MCPServer) registerModelTools() error {
 			mcp.Description("Programming language (go, python, javascript, etc.)"),
 		),
 	)
-	s.server.AddTool(generateCodeTool, s.handleGenerateCode)
+	s.addTool(generateCodeTool, s.handleGenerateCode)
 
 	// Register analyze_code tool
 	analyzeCodeTool := mcp.NewTool("analyze_code",
@@ -566,8 +1872,17 @@ func (s *MCPServer) registerModelTools() error {
 			mcp.Required(),
 			mcp.Description("Programming language"),
 		),
+		mcp.WithBoolean("use_repository_context",
+			mcp.Description("Give the model indexed code related to the code: definitions of the symbols it references, with their doc strings, and callers of the functions it defines; the documents used are listed in grounding (default: false)"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository to take the context from (default: all indexed repositories)"),
+		),
+		mcp.WithNumber("context_tokens",
+			mcp.Description("Token budget of the repository context, estimated at four characters per token (default: 2000, max: 100000)"),
+		),
 	)
-	s.server.AddTool(analyzeCodeTool, s.handleAnalyzeCode)
+	s.addTool(analyzeCodeTool, s.handleAnalyzeCode)
 
 	// Register explain_code tool
 	explainCodeTool := mcp.NewTool("explain_code",
@@ -580,9 +1895,87 @@ func (s *MCPServer) registerModelTools() error {
 			mcp.Required(),
 			mcp.Description("Programming language"),
 		),
+		mcp.WithBoolean("use_repository_context",
+			mcp.Description("Give the model indexed code related to the code: definitions of the symbols it references, with their doc strings, and callers of the functions it defines; the documents used are listed in grounding (default: false)"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository to take the context from (default: all indexed repositories)"),
+		),
+		mcp.WithNumber("context_tokens",
+			mcp.Description("Token budget of the repository context, estimated at four characters per token (default: 2000, max: 100000)"),
+		),
+	)
+	s.addTool(explainCodeTool, s.handleExplainCode)
+
+	// Register summarize_diff tool
+	summarizeDiffTool := mcp.NewTool("summarize_diff",
+		mcp.WithDescription("Write a commit message or pull request description for the changes of an indexed repository: the working tree, the staged changes or the difference between two refs"),
+		mcp.WithString("repository",
+			mcp.Required(),
+			mcp.Description("Repository name or ID"),
+		),
+		mcp.WithString("from",
+			mcp.Description("Commit, branch or tag the diff starts from (default: HEAD)"),
+		),
+		mcp.WithString("to",
+			mcp.Description("Commit, branch or tag to compare with (default: the working tree)"),
+		),
+		mcp.WithBoolean("staged",
+			mcp.Description("Summarize the staged changes instead of the working tree; cannot be combined with to (default: false)"),
+		),
+		mcp.WithArray("paths",
+			mcp.Description("Only summarize files at or below these paths relative to the repository"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("format",
+			mcp.Description("commit for a commit message, pull_request for a pull request title and Markdown description (default: commit)"),
+		),
+		mcp.WithBoolean("conventional",
+			mcp.Description("Prefix the subject with type(scope): as in Conventional Commits, with ! for breaking changes (default: false)"),
+		),
+		mcp.WithString("type",
+			mcp.Description("Conventional commit type to use instead of the inferred one: feat, fix, docs, style, refactor, perf, test, build, ci, chore or revert"),
+		),
+		mcp.WithString("scope",
+			mcp.Description("Conventional commit scope to use instead of the inferred one"),
+		),
+		mcp.WithNumber("max_subject_length",
+			mcp.Description("Characters the subject is cut to (default: 72)"),
+		),
+	)
+	s.addTool(summarizeDiffTool, s.handleSummarizeDiff)
+
+	// Register generate_tests tool
+	generateTestsTool := mcp.NewTool("generate_tests",
+		mcp.WithDescription("Write unit tests for a function or the untested exported symbols of a source file, from their code, doc strings and dependencies, in the test framework and conventions of the repository's existing tests; optionally write them to the test file"),
+		mcp.WithString("file_path",
+			mcp.Required(),
+			mcp.Description("Source file to test, absolute or relative to the repository"),
+		),
+		mcp.WithString("repository",
+			mcp.Description("Repository of the file (default: the indexed repository containing it)"),
+		),
+		mcp.WithString("symbol_name",
+			mcp.Description("Function, method (Class.method) or class to test (default: the exported symbols no test mentions)"),
+		),
+		mcp.WithString("framework",
+			mcp.Description("Test framework to use instead of the detected one: go-testing, testify, pytest, unittest, jest, vitest, mocha, junit5 or junit4"),
+		),
+		mcp.WithString("test_directory",
+			mcp.Description("Directory, relative to the repository, holding the tests when they are not next to the source"),
+		),
+		mcp.WithNumber("context_tokens",
+			mcp.Description("Token budget of the definitions of what the code calls, estimated at four characters per token (default: 2000, max: 100000)"),
+		),
+		mcp.WithBoolean("write",
+			mcp.Description("Add the tests to the test file, creating it if needed, and return the diff; requires write access (default: false)"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("With write, return the diff without changing the file (default: false)"),
+		),
 	)
-	s.server.AddTool(explainCodeTool, s.handleExplainCode)
+	s.addTool(generateTestsTool, s.handleGenerateTests)
 
-	s.logger.Info("AI model tools registered successfully", zap.Int("tool_count", 3))
+	s.logger.Info("AI model tools registered successfully", zap.Int("tool_count", 5))
 	return nil
 }
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// watchDebounce is how long the files of a watched repository must be left
+// alone before it is re-indexed, so a burst of changes causes a single run
+var watchDebounce = 2 * time.Second
+
+// repositoryWatcher re-indexes a configured repository when files below its
+// directory change
+type repositoryWatcher struct {
+	dir      string
+	settings types.RepositorySettings
+	skipDirs map[string]bool // Names of directories not watched, wherever they appear
+	watcher  *fsnotify.Watcher
+}
+
+// watchRepository starts watching the directory of a configured repository
+// and its subdirectories, replacing the watcher the repository had
+func (s *MCPServer) watchRepository(name, dir string, settings types.RepositorySettings) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w := &repositoryWatcher{
+		dir:      dir,
+		settings: settings,
+		skipDirs: map[string]bool{".git": true},
+		watcher:  watcher,
+	}
+	for _, skipped := range s.config.Indexer.SkipDirs {
+		w.skipDirs[skipped] = true
+	}
+	if err := w.addTree(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+	go s.runWatcher(name, w)
+
+	s.mutex.Lock()
+	previous := s.watchers[name]
+	if s.watchers == nil {
+		s.watchers = make(map[string]*repositoryWatcher)
+	}
+	s.watchers[name] = w
+	s.mutex.Unlock()
+	if previous != nil {
+		previous.watcher.Close()
+	}
+	s.logger.Info("Watching configured repository", zap.String("repository", name), zap.String("dir", dir))
+	return nil
+}
+
+// stopWatchers stops watching the configured repositories stop returns
+// true for
+func (s *MCPServer) stopWatchers(stop func(name string) bool) {
+	s.mutex.Lock()
+	var stopped []*repositoryWatcher
+	for name, w := range s.watchers {
+		if stop(name) {
+			stopped = append(stopped, w)
+			delete(s.watchers, name)
+		}
+	}
+	s.mutex.Unlock()
+	for _, w := range stopped {
+		w.watcher.Close()
+	}
+}
+
+// runWatcher queues the repository of w for indexing once its files were
+// left alone for watchDebounce after a change, until w is closed. Indexing
+// skips the files whose content did not change.
+func (s *MCPServer) runWatcher(name string, w *repositoryWatcher) {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if w.skipped(event.Name) {
+				continue
+			}
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := w.addTree(event.Name); err != nil {
+						s.logger.Warn("Failed to watch directory", zap.String("dir", event.Name), zap.Error(err))
+					}
+				}
+			}
+			if timer == nil {
+				timer = time.AfterFunc(watchDebounce, func() { s.reindexWatched(name, w.settings) })
+			} else {
+				timer.Reset(watchDebounce)
+			}
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Warn("Error watching configured repository", zap.String("repository", name), zap.Error(err))
+		}
+	}
+}
+
+// reindexWatched queues a watched repository whose files changed for
+// indexing
+func (s *MCPServer) reindexWatched(name string, settings types.RepositorySettings) {
+	job, err := s.jobs.Submit(settings)
+	if err != nil {
+		s.logger.Warn("Failed to queue changed repository for indexing", zap.String("repository", name), zap.Error(err))
+		return
+	}
+	s.logger.Info("Files of watched repository changed, re-indexing", zap.String("repository", name), zap.String("job_id", job.ID))
+}
+
+// addTree watches dir and the directories below it, except skipped ones.
+// Directories that cannot be read below dir are left out.
+func (w *repositoryWatcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if path != dir && w.skipDirs[entry.Name()] {
+			return filepath.SkipDir
+		}
+		return w.watcher.Add(path)
+	})
+}
+
+// skipped reports whether path lies in a directory that is not watched
+func (w *repositoryWatcher) skipped(path string) bool {
+	rel, err := filepath.Rel(w.dir, path)
+	if err != nil {
+		return true
+	}
+	for _, element := range strings.Split(filepath.ToSlash(rel), "/") {
+		if w.skipDirs[element] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/mark3labs/mcp-go/mcp"
+	"go.uber.org/zap"
+
+	"github.com/my-mcp/code-indexer/internal/auth"
+	"github.com/my-mcp/code-indexer/internal/repository"
+	"github.com/my-mcp/code-indexer/internal/session"
+	"github.com/my-mcp/code-indexer/pkg/types"
+)
+
+// MCP over WebSocket for daemon mode. Each text message is a JSON-RPC
+// message; responses and server-initiated notifications are sent back on
+// the same connection.
+
+// wsNotificationBuffer is the number of notifications queued per connection
+// before the MCP server reports the channel as blocked
+const wsNotificationBuffer = 100
+
+// wsClientSession is the MCP client session of a WebSocket connection,
+// through which the MCP server pushes notifications
+type wsClientSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+}
+
+func (c *wsClientSession) SessionID() string { return c.id }
+
+func (c *wsClientSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return c.notifications
+}
+
+func (c *wsClientSession) Initialize() { c.initialized.Store(true) }
+
+func (c *wsClientSession) Initialized() bool { return c.initialized.Load() }
+
+// errSessionNotJoinable is returned when a WebSocket connection asks to join
+// a session it may not use
+var errSessionNotJoinable = errors.New("session cannot be joined by this client")
+
+// handleWebSocket handles the /ws endpoint - serves the MCP protocol over a
+// WebSocket, with a session per connection
+func (s *MCPServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if s.connectionManager == nil {
+		auth.WriteError(w, http.StatusServiceUnavailable, types.ErrorFeatureDisabled, "WebSocket connections require server.multi_ide.enabled")
+		return
+	}
+
+	// The session is settled before upgrading, so a refusal is an ordinary
+	// HTTP error the client can read
+	sess, created, err := s.websocketSession(r)
+	if err != nil {
+		status, code := http.StatusBadRequest, types.ErrorInvalidArgument
+		switch {
+		case errors.Is(err, errSessionNotJoinable):
+			status, code = http.StatusForbidden, types.ErrorPermissionDenied
+		case errors.Is(err, repository.ErrOutsideSandbox):
+			status, code = http.StatusForbidden, types.ErrorPathOutsideSandbox
+		}
+		auth.WriteError(w, status, code, err.Error())
+		return
+	}
+
+	conn, err := s.connectionManager.Upgrade(w, r)
+	if err != nil {
+		s.logger.Warn("WebSocket connection refused", zap.String("remote_addr", r.RemoteAddr), zap.Error(err))
+		if created {
+			s.sessionManager.RemoveSession(sess.ID)
+		}
+		return
+	}
+	defer s.connectionManager.CloseConnection(conn.ID)
+
+	var sessionID string
+	if sess != nil {
+		sessionID = sess.ID
+		if err := s.connectionManager.AssociateSession(conn.ID, sessionID); err != nil {
+			s.logger.Error("Failed to associate session with connection", zap.String("connection_id", conn.ID), zap.Error(err))
+			conn.WSConn.WriteMessage(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "session error"))
+			return
+		}
+	}
+
+	clientSession := &wsClientSession{
+		id:            conn.ID,
+		notifications: make(chan mcp.JSONRPCNotification, wsNotificationBuffer),
+	}
+	if err := s.server.RegisterSession(conn.Context, clientSession); err != nil {
+		s.logger.Error("Failed to register MCP session", zap.String("connection_id", conn.ID), zap.Error(err))
+		return
+	}
+	defer s.server.UnregisterSession(conn.Context, clientSession.id)
+	ctx := s.server.WithContext(conn.Context, clientSession)
+	profile := s.permissions.Default()
+	if key, ok := auth.FromContext(r.Context()); ok {
+		ctx = auth.WithKey(ctx, key)
+		profile = s.permissions.KeyProfile(key)
+	}
+	if err := s.connectionManager.AssignProfile(conn.ID, profile.Name); err != nil {
+		s.logger.Debug("Failed to assign permission profile to connection", zap.String("connection_id", conn.ID), zap.Error(err))
+	}
+
+	s.logger.Info("WebSocket client connected",
+		zap.String("connection_id", conn.ID),
+		zap.String("session_id", sessionID),
+		zap.String("remote_addr", r.RemoteAddr))
+
+	// Forward notifications, such as indexing progress, as they are sent
+	go forwardNotifications(ctx, clientSession.notifications, func(notification mcp.JSONRPCNotification) {
+		if err := conn.WriteJSON(notification); err != nil {
+			s.logger.Debug("Failed to send notification", zap.String("connection_id", conn.ID), zap.Error(err))
+		}
+	})
+
+	// Requests are handled concurrently, so a long tool call such as
+	// index_repository does not hold up the others
+	var handlers sync.WaitGroup
+	for {
+		_, message, err := conn.WSConn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.logger.Warn("WebSocket connection lost", zap.String("connection_id", conn.ID), zap.Error(err))
+			}
+			break
+		}
+		conn.Touch()
+
+		message = withSessionArgument(message, sessionID)
+		handlers.Add(1)
+		go func() {
+			defer handlers.Done()
+			if response := s.server.HandleMessage(ctx, message); response != nil {
+				if err := conn.WriteJSON(response); err != nil {
+					s.logger.Debug("Failed to send response", zap.String("connection_id", conn.ID), zap.Error(err))
+				}
+			}
+		}()
+	}
+
+	// Cancel the tool calls still running for the departed client
+	conn.Cancel()
+	handlers.Wait()
+	s.logger.Info("WebSocket client disconnected", zap.String("connection_id", conn.ID))
+}
+
+// websocketSession returns the session a WebSocket connection works in, and
+// whether it was created for the connection. It is nil when multi-session
+// support is disabled.
+//
+// A session_id query parameter joins an existing session only when the
+// request is authenticated with the API key that created it; anyone else
+// could otherwise read another client's buffers and state. An unknown ID
+// starts a new session. The workspace_dir of a new session must lie inside
+// the sandbox.
+func (s *MCPServer) websocketSession(r *http.Request) (*session.Session, bool, error) {
+	if s.sessionManager == nil {
+		return nil, false, nil
+	}
+
+	query := r.URL.Query()
+	key, authenticated := auth.FromContext(r.Context())
+	if sessionID := query.Get("session_id"); sessionID != "" {
+		if sess, err := s.sessionManager.GetSession(sessionID); err == nil {
+			if !authenticated || sess.Owner == "" || sess.Owner != key.Name {
+				return nil, false, fmt.Errorf("%w: %s", errSessionNotJoinable, sessionID)
+			}
+			return sess, false, nil
+		}
+	}
+
+	workspaceDir := query.Get("workspace_dir")
+	if workspaceDir != "" {
+		resolved, err := s.repoMgr.ResolvePath(workspaceDir)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid workspace_dir: %w", err)
+		}
+		workspaceDir = resolved
+	}
+	name := query.Get("session_name")
+	if name == "" {
+		name = fmt.Sprintf("websocket-%.8s", uuid.New().String())
+	}
+	profile := query.Get("profile")
+	if _, ok := s.permissions.Profile(profile); profile != "" && !ok {
+		return nil, false, fmt.Errorf("unknown permission profile %q", profile)
+	}
+
+	var owner string
+	if authenticated {
+		owner = key.Name
+	}
+	sess, err := s.sessionManager.CreateOwnedSession(owner, name, workspaceDir)
+	if err != nil {
+		return nil, false, err
+	}
+	sess.SetProfile(profile)
+	return sess, true, nil
+}
+
+// forwardNotifications passes the notifications the MCP server queues for a
+// connection to send until ctx is done
+func forwardNotifications(ctx context.Context, notifications <-chan mcp.JSONRPCNotification, send func(mcp.JSONRPCNotification)) {
+	for {
+		select {
+		case notification := <-notifications:
+			send(notification)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// withSessionArgument sets the session_id argument of a tools/call message
+// to the session of the connection, so tools run in that session whatever
+// the client names
+func withSessionArgument(message []byte, sessionID string) []byte {
+	if sessionID == "" {
+		return message
+	}
+
+	var request map[string]interface{}
+	if err := json.Unmarshal(message, &request); err != nil || request["method"] != string(mcp.MethodToolsCall) {
+		return message
+	}
+	params, ok := request["params"].(map[string]interface{})
+	if !ok {
+		return message
+	}
+	arguments, ok := params["arguments"].(map[string]interface{})
+	if !ok {
+		arguments = make(map[string]interface{})
+		params["arguments"] = arguments
+	}
+	if arguments["session_id"] == sessionID {
+		return message
+	}
+	arguments["session_id"] = sessionID
+
+	rewritten, err := json.Marshal(request)
+	if err != nil {
+		return message
+	}
+	return rewritten
+}
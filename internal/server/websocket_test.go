@@ -0,0 +1,148 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestWithSessionArgument(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string // Expected session_id argument, none when empty
+	}{
+		{"tools/call without session", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search_code","arguments":{"query":"x"}}}`, "s1"},
+		{"tools/call without arguments", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"list_sessions"}}`, "s1"},
+		{"tools/call naming another session", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search_code","arguments":{"session_id":"s2"}}}`, "s1"},
+		{"tools/call naming its own session", `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search_code","arguments":{"session_id":"s1"}}}`, "s1"},
+		{"other method", `{"jsonrpc":"2.0","id":1,"method":"tools/list"}`, ""},
+		{"notification", `{"jsonrpc":"2.0","method":"notifications/initialized"}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewritten := withSessionArgument([]byte(tt.message), "s1")
+
+			var request struct {
+				Method string `json:"method"`
+				Params struct {
+					Name      string                 `json:"name"`
+					Arguments map[string]interface{} `json:"arguments"`
+				} `json:"params"`
+			}
+			if err := json.Unmarshal(rewritten, &request); err != nil {
+				t.Fatalf("Rewritten message is not valid JSON: %v: %s", err, rewritten)
+			}
+			if tt.want == "" {
+				if string(rewritten) != tt.message {
+					t.Errorf("Expected the message to be unchanged, got %s", rewritten)
+				}
+				return
+			}
+			if got := request.Params.Arguments["session_id"]; got != tt.want {
+				t.Errorf("Expected session_id %q, got %v", tt.want, got)
+			}
+			if !strings.Contains(tt.message, `"name":"`+request.Params.Name+`"`) {
+				t.Errorf("Expected the tool name to be kept, got %q", request.Params.Name)
+			}
+		})
+	}
+
+	// Messages are left alone without a session or when they are not JSON
+	message := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"search_code"}}`
+	if got := string(withSessionArgument([]byte(message), "")); got != message {
+		t.Errorf("Expected no rewrite without a session, got %s", got)
+	}
+	if got := string(withSessionArgument([]byte("not json"), "s1")); got != "not json" {
+		t.Errorf("Expected invalid JSON to be passed through, got %s", got)
+	}
+}
+
+func TestForwardNotifications(t *testing.T) {
+	notifications := make(chan mcp.JSONRPCNotification, 2)
+	sent := make(chan mcp.JSONRPCNotification, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		forwardNotifications(ctx, notifications, func(n mcp.JSONRPCNotification) { sent <- n })
+		close(done)
+	}()
+
+	for _, method := range []string{"notifications/progress", "notifications/message"} {
+		notifications <- mcp.JSONRPCNotification{JSONRPC: mcp.JSONRPC_VERSION, Notification: mcp.Notification{Method: method}}
+		select {
+		case n := <-sent:
+			if n.Method != method {
+				t.Errorf("Expected %s to be forwarded, got %s", method, n.Method)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s", method)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected forwarding to stop when the context is done")
+	}
+}
+
+func TestWebSocketSessions(t *testing.T) {
+	s := newTestServer(t, nil)
+	ts := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	// Browser pages on other origins are refused
+	header := http.Header{"Origin": {"https://example.com"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, header); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a foreign origin to be refused, got %v", err)
+	}
+
+	// Another client's session cannot be joined without its API key
+	other, err := s.sessionManager.CreateOwnedSession("ide", "other", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+"?session_id="+other.ID, nil); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected joining another client's session to be refused, got %v", err)
+	}
+
+	// Workspaces outside the sandbox are refused
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL+"?workspace_dir=/", nil); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a workspace outside the sandbox to be refused, got %v", err)
+	}
+
+	// A local client gets a session of its own and speaks MCP
+	before := len(s.sessionManager.ListSessions())
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?session_name=ide", http.Header{"Origin": {"http://localhost:3000"}})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	if after := len(s.sessionManager.ListSessions()); after != before+1 {
+		t.Errorf("Expected a session to be created for the connection, got %d sessions (was %d)", after, before)
+	}
+
+	initialize := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{},"clientInfo":{"name":"test","version":"1.0"}}}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(initialize)); err != nil {
+		t.Fatalf("Failed to send initialize: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, response, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read initialize response: %v", err)
+	}
+	if !strings.Contains(string(response), s.config.Server.Name) {
+		t.Errorf("Expected the server name in the initialize result: %s", response)
+	}
+}
@@ -0,0 +1,93 @@
+package session
+
+import (
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Buffer holds the unsaved contents of a file open in a session's editor
+type Buffer struct {
+	Path      string    `json:"path"`
+	Content   string    `json:"-"`
+	Version   int       `json:"version"`
+	Size      int       `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// normalizeBufferPath makes buffer keys independent of how the client
+// spelled the path
+func normalizeBufferPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// SetBuffer stores the in-memory contents for a file, replacing any previous
+// version. The returned buffer carries the new version number.
+func (s *Session) SetBuffer(path, content string) *Buffer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.buffers == nil {
+		s.buffers = make(map[string]*Buffer)
+	}
+
+	key := normalizeBufferPath(path)
+	version := 1
+	if existing, ok := s.buffers[key]; ok {
+		version = existing.Version + 1
+	}
+
+	buffer := &Buffer{
+		Path:      key,
+		Content:   content,
+		Version:   version,
+		Size:      len(content),
+		UpdatedAt: time.Now(),
+	}
+	s.buffers[key] = buffer
+	s.LastAccess = buffer.UpdatedAt
+
+	return buffer
+}
+
+// GetBuffer returns the in-memory contents for a file if the session has one
+func (s *Session) GetBuffer(path string) (*Buffer, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	buffer, ok := s.buffers[normalizeBufferPath(path)]
+	return buffer, ok
+}
+
+// ClearBuffer drops the in-memory contents for a file so reads fall back to
+// disk again. It reports whether a buffer was present.
+func (s *Session) ClearBuffer(path string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := normalizeBufferPath(path)
+	if _, ok := s.buffers[key]; !ok {
+		return false
+	}
+	delete(s.buffers, key)
+	return true
+}
+
+// Buffers returns all dirty buffers of the session ordered by path
+func (s *Session) Buffers() []*Buffer {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	buffers := make([]*Buffer, 0, len(s.buffers))
+	for _, buffer := range s.buffers {
+		buffers = append(buffers, buffer)
+	}
+	sort.Slice(buffers, func(i, j int) bool {
+		return buffers[i].Path < buffers[j].Path
+	})
+
+	return buffers
+}
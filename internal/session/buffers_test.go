@@ -0,0 +1,107 @@
+package session
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSetBuffer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+
+	tests := []struct {
+		name        string
+		path        string
+		content     string
+		wantVersion int
+	}{
+		{"first sync", path, "package main\n", 1},
+		{"second sync", path, "package main\n\nfunc main() {}\n", 2},
+		{"unclean spelling of the same path", filepath.Join(dir, "sub", "..", "main.go"), "package main // edited\n", 3},
+		{"other file", filepath.Join(dir, "other.go"), "package other\n", 1},
+	}
+
+	sess := &Session{ID: "s1"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := sess.SetBuffer(tt.path, tt.content)
+			if buffer.Version != tt.wantVersion {
+				t.Errorf("Expected version %d, got %d", tt.wantVersion, buffer.Version)
+			}
+			if buffer.Size != len(tt.content) {
+				t.Errorf("Expected size %d, got %d", len(tt.content), buffer.Size)
+			}
+
+			stored, ok := sess.GetBuffer(tt.path)
+			if !ok || stored.Content != tt.content {
+				t.Errorf("Expected buffer content %q, got %+v", tt.content, stored)
+			}
+		})
+	}
+
+	buffers := sess.Buffers()
+	if len(buffers) != 2 || buffers[0].Path != path || buffers[1].Path != filepath.Join(dir, "other.go") {
+		t.Errorf("Expected buffers for main.go and other.go ordered by path, got %+v", buffers)
+	}
+}
+
+func TestSetBufferResolvesRelativePaths(t *testing.T) {
+	sess := &Session{ID: "s1"}
+	sess.SetBuffer("main.go", "package main\n")
+
+	abs, err := filepath.Abs("main.go")
+	if err != nil {
+		t.Fatalf("Failed to resolve path: %v", err)
+	}
+	if _, ok := sess.GetBuffer(abs); !ok {
+		t.Error("Expected a buffer synced with a relative path to be found by its absolute path")
+	}
+}
+
+func TestClearBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+
+	tests := []struct {
+		name        string
+		setup       func(*Session)
+		wantRemoved bool
+	}{
+		{"no buffers", func(*Session) {}, false},
+		{"other file buffered", func(s *Session) { s.SetBuffer(path+".bak", "x") }, false},
+		{"file buffered", func(s *Session) { s.SetBuffer(path, "x") }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sess := &Session{ID: "s1"}
+			tt.setup(sess)
+			before := len(sess.Buffers())
+
+			if removed := sess.ClearBuffer(path); removed != tt.wantRemoved {
+				t.Errorf("Expected ClearBuffer to report %v, got %v", tt.wantRemoved, removed)
+			}
+			if _, ok := sess.GetBuffer(path); ok {
+				t.Error("Expected no buffer after clearing")
+			}
+			if want := before - boolToInt(tt.wantRemoved); len(sess.Buffers()) != want {
+				t.Errorf("Expected %d buffers left, got %d", want, len(sess.Buffers()))
+			}
+		})
+	}
+
+	// A cleared file starts over at version 1
+	sess := &Session{ID: "s1"}
+	sess.SetBuffer(path, "a")
+	sess.SetBuffer(path, "b")
+	sess.ClearBuffer(path)
+	if buffer := sess.SetBuffer(path, "c"); buffer.Version != 1 {
+		t.Errorf("Expected version 1 after clearing, got %d", buffer.Version)
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
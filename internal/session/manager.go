@@ -22,9 +22,45 @@ type Session struct {
 	Config      *config.Config         `json:"config"`
 	Context     map[string]interface{} `json:"context"`
 	Active      bool                   `json:"active"`
+	Owner       string                 `json:"owner,omitempty"` // Name of the API key that created the session
+	DefaultWorkspace string            `json:"default_workspace,omitempty"` // Workspace searches are scoped to unless they name repositories
+	Profile     string                 `json:"profile,omitempty"` // Permission profile limiting the tools the session may call, besides the caller's own
+	buffers     map[string]*Buffer
+	history     []SearchRecord
+	savedSearches map[string]*SavedSearch
 	mutex       sync.RWMutex
 }
 
+// SetDefaultWorkspace scopes the session's searches to the named workspace
+// unless they name repositories; an empty name removes the scope
+func (s *Session) SetDefaultWorkspace(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.DefaultWorkspace = name
+}
+
+// DefaultWorkspaceName returns the session's default workspace, if any
+func (s *Session) DefaultWorkspaceName() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.DefaultWorkspace
+}
+
+// SetProfile limits the tools the session may call to those the named
+// permission profile allows; an empty name removes the limit
+func (s *Session) SetProfile(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Profile = name
+}
+
+// ProfileName returns the session's permission profile, if any
+func (s *Session) ProfileName() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.Profile
+}
+
 // Manager manages multiple VSCode IDE sessions
 type Manager struct {
 	sessions    map[string]*Session
@@ -52,6 +88,12 @@ func NewManager(baseConfig *config.Config, logger *zap.Logger) *Manager {
 
 // CreateSession creates a new session for a VSCode IDE instance
 func (m *Manager) CreateSession(name, workspaceDir string) (*Session, error) {
+	return m.CreateOwnedSession("", name, workspaceDir)
+}
+
+// CreateOwnedSession creates a new session on behalf of the named API key.
+// Only a client authenticated with the same key may join it later.
+func (m *Manager) CreateOwnedSession(owner, name, workspaceDir string) (*Session, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
@@ -69,6 +111,7 @@ func (m *Manager) CreateSession(name, workspaceDir string) (*Session, error) {
 		Config:       sessionConfig,
 		Context:      make(map[string]interface{}),
 		Active:       true,
+		Owner:        owner,
 	}
 
 	m.sessions[sessionID] = session
@@ -0,0 +1,128 @@
+package session
+
+import (
+	"maps"
+	"sort"
+	"time"
+)
+
+// maxSearchHistory is the number of searches a session remembers; older
+// ones are forgotten first
+const maxSearchHistory = 100
+
+// SearchRecord is a search run in a session, kept for auditing and for
+// saving it under a name later
+type SearchRecord struct {
+	Tool        string                 `json:"tool"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	SavedSearch string                 `json:"saved_search,omitempty"` // Name of the saved search that was run, if any
+	At          time.Time              `json:"at"`
+}
+
+// SavedSearch is a named set of search_code arguments that can be run again
+type SavedSearch struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Arguments   map[string]interface{} `json:"arguments"`
+	SavedAt     time.Time              `json:"saved_at"`
+	Runs        int                    `json:"runs"`
+	LastRunAt   *time.Time             `json:"last_run_at,omitempty"`
+}
+
+// RecordSearch appends a search to the session's history
+func (s *Session) RecordSearch(record SearchRecord) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record.Arguments = maps.Clone(record.Arguments)
+	s.history = append(s.history, record)
+	if len(s.history) > maxSearchHistory {
+		s.history = append(s.history[:0], s.history[len(s.history)-maxSearchHistory:]...)
+	}
+}
+
+// SearchHistory returns up to limit of the session's searches, most recent
+// first. A limit of zero or less returns them all.
+func (s *Session) SearchHistory(limit int) []SearchRecord {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if limit <= 0 || limit > len(s.history) {
+		limit = len(s.history)
+	}
+	history := make([]SearchRecord, 0, limit)
+	for idx := len(s.history) - 1; idx >= 0 && len(history) < limit; idx-- {
+		history = append(history, s.history[idx])
+	}
+	return history
+}
+
+// SaveSearch stores a search under its name, replacing any search saved
+// under the same name. It reports whether one was replaced.
+func (s *Session) SaveSearch(name, description string, arguments map[string]interface{}) (SavedSearch, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.savedSearches == nil {
+		s.savedSearches = make(map[string]*SavedSearch)
+	}
+
+	_, replaced := s.savedSearches[name]
+	saved := &SavedSearch{
+		Name:        name,
+		Description: description,
+		Arguments:   maps.Clone(arguments),
+		SavedAt:     time.Now(),
+	}
+	s.savedSearches[name] = saved
+
+	return *saved, replaced
+}
+
+// RunSavedSearch counts a run of the named search and returns a copy of it
+func (s *Session) RunSavedSearch(name string) (SavedSearch, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	saved, ok := s.savedSearches[name]
+	if !ok {
+		return SavedSearch{}, false
+	}
+	now := time.Now()
+	saved.Runs++
+	saved.LastRunAt = &now
+
+	run := *saved
+	run.Arguments = maps.Clone(saved.Arguments)
+	return run, true
+}
+
+// DeleteSavedSearch forgets the named search. It reports whether the search
+// existed.
+func (s *Session) DeleteSavedSearch(name string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.savedSearches[name]; !ok {
+		return false
+	}
+	delete(s.savedSearches, name)
+	return true
+}
+
+// SavedSearches returns copies of the session's saved searches ordered by
+// name
+func (s *Session) SavedSearches() []SavedSearch {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	searches := make([]SavedSearch, 0, len(s.savedSearches))
+	for _, saved := range s.savedSearches {
+		searches = append(searches, *saved)
+	}
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].Name < searches[j].Name
+	})
+
+	return searches
+}
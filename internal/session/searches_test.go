@@ -0,0 +1,65 @@
+package session
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSearchHistory(t *testing.T) {
+	sess := &Session{ID: "s1"}
+	for idx := 0; idx < maxSearchHistory+5; idx++ {
+		sess.RecordSearch(SearchRecord{Tool: "search_code", Arguments: map[string]interface{}{"query": fmt.Sprint(idx)}})
+	}
+
+	all := sess.SearchHistory(0)
+	if len(all) != maxSearchHistory {
+		t.Fatalf("Expected %d searches kept, got %d", maxSearchHistory, len(all))
+	}
+	if got := all[0].Arguments["query"]; got != fmt.Sprint(maxSearchHistory+4) {
+		t.Errorf("Expected the most recent search first, got %v", got)
+	}
+	if got := all[len(all)-1].Arguments["query"]; got != "5" {
+		t.Errorf("Expected the oldest kept search last, got %v", got)
+	}
+	if recent := sess.SearchHistory(3); len(recent) != 3 {
+		t.Errorf("Expected 3 searches, got %d", len(recent))
+	}
+}
+
+func TestSavedSearches(t *testing.T) {
+	sess := &Session{ID: "s1"}
+	arguments := map[string]interface{}{"query": "TODO", "type": "comment"}
+
+	if _, replaced := sess.SaveSearch("todos", "", arguments); replaced {
+		t.Error("Expected a new saved search")
+	}
+	arguments["query"] = "changed"
+	if _, replaced := sess.SaveSearch("fixmes", "", map[string]interface{}{"query": "FIXME"}); replaced {
+		t.Error("Expected a new saved search")
+	}
+
+	run, ok := sess.RunSavedSearch("todos")
+	if !ok || run.Arguments["query"] != "TODO" {
+		t.Fatalf("Expected the saved arguments unchanged, got %+v", run)
+	}
+	run.Arguments["query"] = "changed"
+	run, _ = sess.RunSavedSearch("todos")
+	if run.Runs != 2 || run.LastRunAt == nil || run.Arguments["query"] != "TODO" {
+		t.Errorf("Expected a second run of the unchanged search, got %+v", run)
+	}
+
+	saved := sess.SavedSearches()
+	if len(saved) != 2 || saved[0].Name != "fixmes" || saved[1].Name != "todos" {
+		t.Errorf("Expected searches ordered by name, got %+v", saved)
+	}
+
+	if _, replaced := sess.SaveSearch("todos", "all TODOs", map[string]interface{}{"query": "TODO"}); !replaced {
+		t.Error("Expected the saved search to be replaced")
+	}
+	if !sess.DeleteSavedSearch("todos") || sess.DeleteSavedSearch("todos") {
+		t.Error("Expected the saved search to be deleted once")
+	}
+	if _, ok := sess.RunSavedSearch("todos"); ok {
+		t.Error("Expected a deleted search not to run")
+	}
+}
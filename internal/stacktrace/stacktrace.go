@@ -0,0 +1,216 @@
+// Package stacktrace parses stack traces pasted from Go panics, Python
+// tracebacks and JavaScript errors into frames.
+//
+// Frames are always ordered innermost first, so the first frame is where the
+// error was raised. Python tracebacks, which print the innermost call last,
+// are reversed to match.
+package stacktrace
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Trace languages
+const (
+	LanguageGo         = "go"
+	LanguagePython     = "python"
+	LanguageJavaScript = "javascript"
+)
+
+// Frame is one call in a stack trace
+type Frame struct {
+	Function string `json:"function,omitempty"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Source   string `json:"source,omitempty"`  // Source line printed by the trace, if any
+	Library  bool   `json:"library,omitempty"` // Frame belongs to the runtime or a dependency
+}
+
+// Trace is a parsed stack trace
+type Trace struct {
+	Language string  `json:"language"`
+	Message  string  `json:"message,omitempty"`
+	Frames   []Frame `json:"frames"`
+}
+
+var (
+	goFramePattern     = regexp.MustCompile(`^\s+(\S.*?\.\w+):(\d+)(?:\s+\+0x[0-9a-f]+)?\s*$`)
+	pythonFramePattern = regexp.MustCompile(`^\s*File "([^"]+)", line (\d+)(?:, in (.+))?\s*$`)
+	jsCallFramePattern = regexp.MustCompile(`^\s*at (?:async )?(.+?) \((.+?):(\d+)(?::(\d+))?\)\s*$`)
+	jsBareFramePattern = regexp.MustCompile(`^\s*at (?:async )?(.+?):(\d+)(?::(\d+))?\s*$`)
+	jsFirefoxPattern   = regexp.MustCompile(`^\s*([^@\s]*)@(.+?):(\d+)(?::(\d+))?\s*$`)
+)
+
+// pythonMessageMarker starts every Python traceback
+const pythonMessageMarker = "Traceback (most recent call last):"
+
+// Parse parses a stack trace, detecting its language from the frame syntax.
+// It returns false when no frames are recognised.
+func Parse(text string) (*Trace, bool) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for _, parse := range []func([]string) *Trace{parsePython, parseGo, parseJavaScript} {
+		if trace := parse(lines); trace != nil && len(trace.Frames) > 0 {
+			return trace, true
+		}
+	}
+	return nil, false
+}
+
+// parseGo parses a goroutine dump, where each function line is followed by a
+// tab-indented file:line line
+func parseGo(lines []string) *Trace {
+	trace := &Trace{Language: LanguageGo}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trace.Message == "" && (strings.HasPrefix(trimmed, "panic: ") || strings.HasPrefix(trimmed, "fatal error: ")) {
+			trace.Message = trimmed
+			continue
+		}
+
+		match := goFramePattern.FindStringSubmatch(line)
+		if match == nil || i == 0 {
+			continue
+		}
+
+		function := goFunctionName(lines[i-1])
+		lineNumber, _ := strconv.Atoi(match[2])
+		trace.Frames = append(trace.Frames, Frame{
+			Function: function,
+			File:     match[1],
+			Line:     lineNumber,
+			Library:  isGoLibrary(match[1], function),
+		})
+	}
+	return trace
+}
+
+// goFunctionName extracts the function from a goroutine dump line such as
+// "main.(*Server).handle(0xc000010000, {0x1, 0x2})" or "created by main.run
+// in goroutine 1"
+func goFunctionName(line string) string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "created by ")
+	if idx := strings.Index(line, " in goroutine "); idx >= 0 {
+		line = line[:idx]
+	}
+	if strings.HasSuffix(line, ")") {
+		if idx := strings.LastIndex(line, "("); idx > 0 {
+			line = line[:idx]
+		}
+	}
+	if strings.ContainsAny(line, " \t") {
+		return ""
+	}
+	return line
+}
+
+// parsePython parses a traceback. The exception message is the first line
+// after the last frame that is not indented.
+func parsePython(lines []string) *Trace {
+	trace := &Trace{Language: LanguagePython}
+	seenTraceback := false
+	for i, line := range lines {
+		if strings.TrimSpace(line) == pythonMessageMarker {
+			seenTraceback = true
+			continue
+		}
+
+		match := pythonFramePattern.FindStringSubmatch(line)
+		if match == nil {
+			if seenTraceback && len(trace.Frames) > 0 && line != "" && !strings.HasPrefix(line, " ") {
+				trace.Message = strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		lineNumber, _ := strconv.Atoi(match[2])
+		frame := Frame{
+			Function: strings.TrimSpace(match[3]),
+			File:     match[1],
+			Line:     lineNumber,
+			Library:  isPythonLibrary(match[1]),
+		}
+		if i+1 < len(lines) && !pythonFramePattern.MatchString(lines[i+1]) && strings.HasPrefix(lines[i+1], "    ") {
+			frame.Source = strings.TrimSpace(lines[i+1])
+		}
+		trace.Frames = append(trace.Frames, frame)
+	}
+
+	// Tracebacks print the innermost call last
+	for i, j := 0, len(trace.Frames)-1; i < j; i, j = i+1, j-1 {
+		trace.Frames[i], trace.Frames[j] = trace.Frames[j], trace.Frames[i]
+	}
+	return trace
+}
+
+// parseJavaScript parses V8 ("at fn (file:line:col)") and Firefox
+// ("fn@file:line:col") stacks. The message is the first line before the
+// first frame.
+func parseJavaScript(lines []string) *Trace {
+	trace := &Trace{Language: LanguageJavaScript}
+	for _, line := range lines {
+		var function, file, lineText, columnText string
+		if match := jsCallFramePattern.FindStringSubmatch(line); match != nil {
+			function, file, lineText, columnText = match[1], match[2], match[3], match[4]
+		} else if match := jsBareFramePattern.FindStringSubmatch(line); match != nil {
+			file, lineText, columnText = match[1], match[2], match[3]
+		} else if match := jsFirefoxPattern.FindStringSubmatch(line); match != nil {
+			function, file, lineText, columnText = match[1], match[2], match[3], match[4]
+		} else {
+			if len(trace.Frames) == 0 && trace.Message == "" && strings.TrimSpace(line) != "" {
+				trace.Message = strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		lineNumber, _ := strconv.Atoi(lineText)
+		column, _ := strconv.Atoi(columnText)
+		file = strings.TrimPrefix(file, "file://")
+		trace.Frames = append(trace.Frames, Frame{
+			Function: function,
+			File:     file,
+			Line:     lineNumber,
+			Column:   column,
+			Library:  isJavaScriptLibrary(file),
+		})
+	}
+	return trace
+}
+
+// isGoLibrary reports whether a frame is in the Go runtime, the standard
+// library or the module cache. GOPATH projects also live below go/src, but
+// their import paths start with a domain while standard packages do not.
+func isGoLibrary(file, function string) bool {
+	path := strings.ReplaceAll(file, "\\", "/")
+	if strings.Contains(path, "/pkg/mod/") || strings.HasPrefix(function, "runtime.") {
+		return true
+	}
+	if idx := strings.Index(path, "/go/src/"); idx >= 0 {
+		firstSegment, _, _ := strings.Cut(path[idx+len("/go/src/"):], "/")
+		return !strings.Contains(firstSegment, ".")
+	}
+	return false
+}
+
+// isPythonLibrary reports whether a frame is in the standard library or an
+// installed package
+func isPythonLibrary(file string) bool {
+	path := strings.ReplaceAll(file, "\\", "/")
+	return strings.Contains(path, "/site-packages/") ||
+		strings.Contains(path, "/dist-packages/") ||
+		strings.Contains(path, "/lib/python") ||
+		strings.HasPrefix(path, "<")
+}
+
+// isJavaScriptLibrary reports whether a frame is in Node internals or a
+// dependency
+func isJavaScriptLibrary(file string) bool {
+	return strings.HasPrefix(file, "node:") ||
+		strings.HasPrefix(file, "internal/") ||
+		strings.Contains(file, "/node_modules/") ||
+		file == "native" || file == "<anonymous>"
+}
@@ -0,0 +1,118 @@
+package stacktrace
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoPanic(t *testing.T) {
+	trace, ok := Parse(`panic: runtime error: index out of range [3] with length 3
+
+goroutine 1 [running]:
+main.(*Server).handle(0xc000010000, {0x1, 0x2})
+	/home/dev/app/server.go:42 +0x1d
+main.main()
+	/home/dev/app/main.go:8 +0x25
+runtime.goexit()
+	/usr/local/go/src/runtime/asm_amd64.s:1650 +0x1
+created by main.start in goroutine 1
+	/home/dev/go/src/github.com/dev/app/start.go:12 +0x3c
+exit status 2`)
+	if !ok {
+		t.Fatal("Expected the panic to parse")
+	}
+
+	if trace.Language != LanguageGo {
+		t.Errorf("Language = %q, want %q", trace.Language, LanguageGo)
+	}
+	if trace.Message != "panic: runtime error: index out of range [3] with length 3" {
+		t.Errorf("Message = %q", trace.Message)
+	}
+
+	want := []Frame{
+		{Function: "main.(*Server).handle", File: "/home/dev/app/server.go", Line: 42},
+		{Function: "main.main", File: "/home/dev/app/main.go", Line: 8},
+		{Function: "runtime.goexit", File: "/usr/local/go/src/runtime/asm_amd64.s", Line: 1650, Library: true},
+		{Function: "main.start", File: "/home/dev/go/src/github.com/dev/app/start.go", Line: 12},
+	}
+	if !reflect.DeepEqual(trace.Frames, want) {
+		t.Errorf("Frames = %+v, want %+v", trace.Frames, want)
+	}
+}
+
+func TestParsePythonTraceback(t *testing.T) {
+	trace, ok := Parse("Traceback (most recent call last):\r\n" +
+		"  File \"/srv/app/main.py\", line 10, in <module>\r\n" +
+		"    main()\r\n" +
+		"  File \"/srv/app/service.py\", line 5, in main\r\n" +
+		"    return load(path)\r\n" +
+		"  File \"/usr/lib/python3.11/json/__init__.py\", line 293, in load\r\n" +
+		"ValueError: bad input\r\n")
+	if !ok {
+		t.Fatal("Expected the traceback to parse")
+	}
+
+	if trace.Language != LanguagePython || trace.Message != "ValueError: bad input" {
+		t.Errorf("Language = %q, Message = %q", trace.Language, trace.Message)
+	}
+
+	want := []Frame{
+		{Function: "load", File: "/usr/lib/python3.11/json/__init__.py", Line: 293, Library: true},
+		{Function: "main", File: "/srv/app/service.py", Line: 5, Source: "return load(path)"},
+		{Function: "<module>", File: "/srv/app/main.py", Line: 10, Source: "main()"},
+	}
+	if !reflect.DeepEqual(trace.Frames, want) {
+		t.Errorf("Frames = %+v, want %+v", trace.Frames, want)
+	}
+}
+
+func TestParseJavaScriptStack(t *testing.T) {
+	trace, ok := Parse(`TypeError: Cannot read properties of undefined (reading 'id')
+    at getUser (/app/src/users.js:14:22)
+    at async Router.handle (file:///app/src/router.js:30:5)
+    at /app/src/index.js:3:1
+    at Module._compile (node:internal/modules/cjs/loader:1105:14)
+    at next (/app/node_modules/express/lib/router/index.js:280:10)`)
+	if !ok {
+		t.Fatal("Expected the stack to parse")
+	}
+
+	if trace.Language != LanguageJavaScript {
+		t.Errorf("Language = %q, want %q", trace.Language, LanguageJavaScript)
+	}
+	if trace.Message != "TypeError: Cannot read properties of undefined (reading 'id')" {
+		t.Errorf("Message = %q", trace.Message)
+	}
+
+	want := []Frame{
+		{Function: "getUser", File: "/app/src/users.js", Line: 14, Column: 22},
+		{Function: "Router.handle", File: "/app/src/router.js", Line: 30, Column: 5},
+		{File: "/app/src/index.js", Line: 3, Column: 1},
+		{Function: "Module._compile", File: "node:internal/modules/cjs/loader", Line: 1105, Column: 14, Library: true},
+		{Function: "next", File: "/app/node_modules/express/lib/router/index.js", Line: 280, Column: 10, Library: true},
+	}
+	if !reflect.DeepEqual(trace.Frames, want) {
+		t.Errorf("Frames = %+v, want %+v", trace.Frames, want)
+	}
+}
+
+func TestParseFirefoxStack(t *testing.T) {
+	trace, ok := Parse("render@http://localhost:3000/static/app.js:120:9\n@http://localhost:3000/static/app.js:5:1")
+	if !ok {
+		t.Fatal("Expected the stack to parse")
+	}
+
+	want := []Frame{
+		{Function: "render", File: "http://localhost:3000/static/app.js", Line: 120, Column: 9},
+		{File: "http://localhost:3000/static/app.js", Line: 5, Column: 1},
+	}
+	if !reflect.DeepEqual(trace.Frames, want) {
+		t.Errorf("Frames = %+v, want %+v", trace.Frames, want)
+	}
+}
+
+func TestParseRejectsPlainText(t *testing.T) {
+	if _, ok := Parse("something went wrong\nplease try again"); ok {
+		t.Error("Expected text without frames not to parse")
+	}
+}
@@ -0,0 +1,236 @@
+package testrun
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// goEvent is a line of go test -json output, as written by test2json
+type goEvent struct {
+	Action  string  `json:"Action"`
+	Package string  `json:"Package"`
+	Test    string  `json:"Test"`
+	Elapsed float64 `json:"Elapsed"` // Seconds
+	Output  string  `json:"Output"`
+}
+
+// goTestKey identifies a test of a package, or the package itself
+type goTestKey struct{ pkg, test string }
+
+// parseGoJSON reads the test results of go test -json output, and the
+// packages that failed outside any test, such as those that do not build
+func parseGoJSON(data []byte) ([]TestResult, []TestResult) {
+	var order []goTestKey
+	results := make(map[goTestKey]*TestResult)
+	outputs := make(map[goTestKey]*strings.Builder)
+	result := func(k goTestKey) *TestResult {
+		if r, ok := results[k]; ok {
+			return r
+		}
+		r := &TestResult{Name: k.test, Suite: k.pkg}
+		results[k] = r
+		outputs[k] = &strings.Builder{}
+		order = append(order, k)
+		return r
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event goEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil || event.Package == "" {
+			continue
+		}
+		k := goTestKey{event.Package, event.Test}
+		switch event.Action {
+		case "run", "start":
+			result(k)
+		case "output":
+			result(k)
+			outputs[k].WriteString(event.Output)
+		case "pass", "fail", "skip":
+			r := result(k)
+			r.Status = map[string]string{"pass": StatusPass, "fail": StatusFail, "skip": StatusSkip}[event.Action]
+			r.Duration = event.Elapsed * 1000
+		}
+	}
+
+	var tests, failures []TestResult
+	for _, k := range order {
+		r := results[k]
+		if r.Status == StatusFail {
+			r.Output = tail(strings.TrimSpace(outputs[k].String()), maxTestOutput)
+		}
+		switch {
+		case k.test != "":
+			tests = append(tests, *r)
+		case r.Status == StatusFail && !hasFailedTests(results, k.pkg):
+			// The package failed without a failing test of its own: it did
+			// not build, or panicked or exited outside a test
+			failures = append(failures, *r)
+		}
+	}
+	return tests, failures
+}
+
+// hasFailedTests reports whether a test of a package failed
+func hasFailedTests(results map[goTestKey]*TestResult, pkg string) bool {
+	for k, r := range results {
+		if k.pkg == pkg && k.test != "" && r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// goJSONText returns the text output of go test -json output, with the
+// lines that are not events kept as they are
+func goJSONText(data []byte) string {
+	var out strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var event goEvent
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			out.Write(scanner.Bytes())
+			out.WriteByte('\n')
+			continue
+		}
+		out.WriteString(event.Output)
+	}
+	return out.String()
+}
+
+// junitCase is a test case of a JUnit XML report
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"` // Seconds
+	Failures  []junitDetail `xml:"failure"`
+	Errors    []junitDetail `xml:"error"`
+	Skipped   []junitDetail `xml:"skipped"`
+}
+
+type junitDetail struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitSuite is a test suite, which may nest further suites
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+// parseJUnit reads a JUnit XML report, as written by pytest --junitxml and
+// most other test runners, whose root is <testsuites> or a <testsuite>
+func parseJUnit(data []byte) ([]TestResult, error) {
+	var root junitSuite
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("invalid JUnit report: %w", err)
+	}
+	var tests []TestResult
+	collectJUnit(root, &tests)
+	return tests, nil
+}
+
+// collectJUnit adds the cases of a suite and its nested suites
+func collectJUnit(suite junitSuite, tests *[]TestResult) {
+	for _, testCase := range suite.Cases {
+		seconds, _ := strconv.ParseFloat(testCase.Time, 64)
+		result := TestResult{
+			Name:     testCase.Name,
+			Suite:    testCase.ClassName,
+			Status:   StatusPass,
+			Duration: seconds * 1000,
+		}
+		if result.Suite == "" {
+			result.Suite = suite.Name
+		}
+		problems := append(append([]junitDetail(nil), testCase.Failures...), testCase.Errors...)
+		switch {
+		case len(problems) > 0:
+			result.Status = StatusFail
+			var output []string
+			for _, problem := range problems {
+				text := strings.TrimSpace(problem.Text)
+				if text == "" {
+					text = problem.Message
+				}
+				output = append(output, text)
+			}
+			result.Output = tail(strings.Join(output, "\n"), maxTestOutput)
+		case len(testCase.Skipped) > 0:
+			result.Status = StatusSkip
+			result.Output = testCase.Skipped[0].Message
+		}
+		*tests = append(*tests, result)
+	}
+	for _, nested := range suite.Suites {
+		collectJUnit(nested, tests)
+	}
+}
+
+// jestReport is the report jest --json writes
+type jestReport struct {
+	TestResults []struct {
+		Name             string `json:"name"` // Test file
+		Message          string `json:"message"`
+		Status           string `json:"status"`
+		AssertionResults []struct {
+			FullName        string   `json:"fullName"`
+			Title           string   `json:"title"`
+			Status          string   `json:"status"`
+			Duration        *float64 `json:"duration"` // Milliseconds
+			FailureMessages []string `json:"failureMessages"`
+		} `json:"assertionResults"`
+	} `json:"testResults"`
+}
+
+// parseJest reads a jest --json report. Test files that failed without
+// running a test, such as those that do not compile, are reported as a
+// failed test named after the file.
+func parseJest(data []byte) ([]TestResult, error) {
+	var report jestReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("invalid jest report: %w", err)
+	}
+	var tests []TestResult
+	for _, file := range report.TestResults {
+		if len(file.AssertionResults) == 0 && file.Status == "failed" {
+			tests = append(tests, TestResult{
+				Name:   file.Name,
+				Suite:  file.Name,
+				Status: StatusFail,
+				Output: tail(strings.TrimSpace(file.Message), maxTestOutput),
+			})
+			continue
+		}
+		for _, assertion := range file.AssertionResults {
+			result := TestResult{Name: assertion.FullName, Suite: file.Name}
+			if result.Name == "" {
+				result.Name = assertion.Title
+			}
+			if assertion.Duration != nil {
+				result.Duration = *assertion.Duration
+			}
+			switch assertion.Status {
+			case "passed":
+				result.Status = StatusPass
+			case "failed":
+				result.Status = StatusFail
+				result.Output = tail(strings.TrimSpace(strings.Join(assertion.FailureMessages, "\n")), maxTestOutput)
+			default: // pending, skipped, todo, disabled
+				result.Status = StatusSkip
+			}
+			tests = append(tests, result)
+		}
+	}
+	return tests, nil
+}
@@ -0,0 +1,237 @@
+// Package testrun runs the tests of a repository with its language's test
+// command and reads the results into a pass, fail or skip status, duration
+// and failure output per test.
+package testrun
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+// Limits of the output kept in results
+const (
+	maxTestOutput = 4000 // Failure output of one test
+	maxRunOutput  = 8000 // Raw output of a run whose results could not be read
+	maxRunResults = 1000 // Tests reported per run
+)
+
+// Statuses of a test
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusSkip    = "skip"
+	StatusTimeout = "timeout" // Still running when the run was stopped
+)
+
+// ErrNotInstalled is returned when the test command is not installed
+var ErrNotInstalled = errors.New("test command not installed")
+
+// Target is what a run covers: the whole workspace, a directory or file in
+// it, and optionally the tests matching a name
+type Target struct {
+	Root string // Directory the command runs in
+	Path string // File or directory relative to Root; empty for everything
+	Dir  bool   // Whether Path is a directory
+	Test string // Test name or pattern; empty for all tests
+}
+
+// TestResult is the outcome of one test
+type TestResult struct {
+	Name     string  `json:"name"`
+	Suite    string  `json:"suite,omitempty"` // Package, file or class of the test
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration_ms"`
+	Output   string  `json:"output,omitempty"` // Failure output
+}
+
+// Summary counts the tests of a run by status
+type Summary struct {
+	Total    int `json:"total"`
+	Passed   int `json:"passed"`
+	Failed   int `json:"failed"`
+	Skipped  int `json:"skipped"`
+	TimedOut int `json:"timed_out,omitempty"`
+}
+
+// Result is the outcome of a test run
+type Result struct {
+	Command   string       `json:"command"`
+	ExitCode  int          `json:"exit_code"`
+	Duration  float64      `json:"duration_ms"`
+	TimedOut  bool         `json:"timed_out"`
+	Passed    bool         `json:"passed"` // The command succeeded and no test failed
+	Summary   Summary      `json:"summary"`
+	Tests     []TestResult `json:"tests"`
+	Truncated bool         `json:"truncated,omitempty"`
+	Failures  []TestResult `json:"failures,omitempty"` // Failed suites that are not tests, such as packages that do not build
+	Output    string       `json:"output,omitempty"`   // Raw output when no results could be read
+}
+
+// Run runs the tests of target with runner, stopping them after timeout
+func Run(ctx context.Context, runner config.TestRunnerConfig, target Target, timeout time.Duration) (*Result, error) {
+	if _, err := exec.LookPath(runner.Command); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotInstalled, runner.Command)
+	}
+
+	// Runners that write a report file get a fresh one
+	report := ""
+	if usesReport(runner) {
+		file, err := os.CreateTemp("", "code-indexer-tests-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create report file: %w", err)
+		}
+		report = file.Name()
+		file.Close()
+		os.Remove(report)
+		defer os.Remove(report)
+	}
+
+	args := runner.Args
+	if target.Test != "" {
+		args = append(append([]string(nil), args...), runner.TestArgs...)
+	}
+	args = expandArgs(args, target, report)
+
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, runner.Command, args...)
+	cmd.Dir = target.Root
+	cmd.WaitDelay = 5 * time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := cmd.Run()
+	result := &Result{
+		Command:  strings.Join(append([]string{runner.Command}, args...), " "),
+		Duration: milliseconds(time.Since(start)),
+		TimedOut: runCtx.Err() == context.DeadlineExceeded,
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil && !result.TimedOut:
+		return nil, fmt.Errorf("failed to run %s: %w", runner.Command, err)
+	}
+
+	var parseErr error
+	switch runner.Format {
+	case "go-json":
+		result.Tests, result.Failures = parseGoJSON(stdout.Bytes())
+	case "junit":
+		result.Tests, parseErr = readReport(report, parseJUnit)
+	case "jest-json":
+		result.Tests, parseErr = readReport(report, parseJest)
+	}
+	if result.TimedOut {
+		markTimedOut(result.Tests)
+	}
+
+	for _, test := range result.Tests {
+		switch test.Status {
+		case StatusPass:
+			result.Summary.Passed++
+		case StatusFail:
+			result.Summary.Failed++
+		case StatusSkip:
+			result.Summary.Skipped++
+		case StatusTimeout:
+			result.Summary.TimedOut++
+		}
+	}
+	result.Summary.Total = len(result.Tests)
+	result.Passed = result.ExitCode == 0 && !result.TimedOut && result.Summary.Failed == 0 && len(result.Failures) == 0
+	if len(result.Tests) > maxRunResults {
+		result.Tests = result.Tests[:maxRunResults]
+		result.Truncated = true
+	}
+
+	if len(result.Tests) == 0 || parseErr != nil || (!result.Passed && result.Summary.Failed == 0 && len(result.Failures) == 0) {
+		output := stdout.String()
+		if runner.Format == "go-json" {
+			output = goJSONText(stdout.Bytes())
+		}
+		result.Output = tail(strings.TrimSpace(output+"\n"+stderr.String()), maxRunOutput)
+	}
+	return result, nil
+}
+
+// usesReport reports whether a runner writes its results to {report}
+func usesReport(runner config.TestRunnerConfig) bool {
+	for _, arg := range append(append([]string(nil), runner.Args...), runner.TestArgs...) {
+		if strings.Contains(arg, "{report}") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandArgs replaces {package} with the Go package pattern of the target
+// (./... for everything), {file} with its file or directory (. for
+// everything), {test} with the test name and {report} with the report file
+func expandArgs(args []string, target Target, report string) []string {
+	pkg, file := "./...", "."
+	if target.Path != "" {
+		file = filepath.ToSlash(target.Path)
+		dir := file
+		if !target.Dir {
+			dir = filepath.ToSlash(filepath.Dir(target.Path))
+		}
+		pkg = "./" + dir
+		if dir == "." {
+			pkg = "."
+		}
+	}
+	replacer := strings.NewReplacer("{package}", pkg, "{file}", file, "{test}", target.Test, "{report}", report)
+	expanded := make([]string, len(args))
+	for i, arg := range args {
+		expanded[i] = replacer.Replace(arg)
+	}
+	return expanded
+}
+
+// readReport parses the report file a runner wrote
+func readReport(path string, parse func([]byte) ([]TestResult, error)) ([]TestResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no test report was written: %w", err)
+	}
+	return parse(data)
+}
+
+// markTimedOut marks the tests that never finished
+func markTimedOut(tests []TestResult) {
+	for i := range tests {
+		if tests[i].Status == "" {
+			tests[i].Status = StatusTimeout
+		}
+	}
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// tail keeps the last n bytes of s, where failures are usually reported
+func tail(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 && idx < len(s)-1 {
+		s = s[idx+1:]
+	}
+	return "...\n" + s
+}
@@ -0,0 +1,183 @@
+package testrun
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/my-mcp/code-indexer/internal/config"
+)
+
+func TestParseGoJSON(t *testing.T) {
+	output := `{"Action":"start","Package":"example.com/app/store"}
+{"Action":"run","Package":"example.com/app/store","Test":"TestLoad"}
+{"Action":"output","Package":"example.com/app/store","Test":"TestLoad","Output":"=== RUN   TestLoad\n"}
+{"Action":"pass","Package":"example.com/app/store","Test":"TestLoad","Elapsed":0.01}
+{"Action":"run","Package":"example.com/app/store","Test":"TestSave"}
+{"Action":"output","Package":"example.com/app/store","Test":"TestSave","Output":"    store_test.go:12: Expected 2, got 3\n"}
+{"Action":"fail","Package":"example.com/app/store","Test":"TestSave","Elapsed":0.5}
+{"Action":"fail","Package":"example.com/app/store","Elapsed":0.6}
+{"Action":"output","Package":"example.com/app/broken","Output":"broken.go:3:1: syntax error\n"}
+{"Action":"fail","Package":"example.com/app/broken","Elapsed":0}
+`
+	tests, failures := parseGoJSON([]byte(output))
+	want := []TestResult{
+		{Name: "TestLoad", Suite: "example.com/app/store", Status: StatusPass, Duration: 10},
+		{Name: "TestSave", Suite: "example.com/app/store", Status: StatusFail, Duration: 500, Output: "store_test.go:12: Expected 2, got 3"},
+	}
+	if !reflect.DeepEqual(tests, want) {
+		t.Errorf("Unexpected tests:\n got %+v\nwant %+v", tests, want)
+	}
+	if len(failures) != 1 || failures[0].Suite != "example.com/app/broken" || failures[0].Output != "broken.go:3:1: syntax error" {
+		t.Errorf("Expected the package that does not build as a failure, got %+v", failures)
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	report := `<?xml version="1.0" encoding="utf-8"?>
+<testsuites><testsuite name="pytest" tests="3">
+<testcase classname="tests.test_store" name="test_load" time="0.002"/>
+<testcase classname="tests.test_store" name="test_save" time="0.1"><failure message="assert 2 == 3">def test_save():
+&gt;       assert 2 == 3</failure></testcase>
+<testcase classname="tests.test_store" name="test_slow" time="0"><skipped message="too slow"/></testcase>
+</testsuite></testsuites>`
+	tests, err := parseJUnit([]byte(report))
+	if err != nil {
+		t.Fatalf("parseJUnit failed: %v", err)
+	}
+	if len(tests) != 3 {
+		t.Fatalf("Expected 3 tests, got %+v", tests)
+	}
+	if tests[0].Status != StatusPass || tests[0].Duration != 2 {
+		t.Errorf("Unexpected passing test %+v", tests[0])
+	}
+	if tests[1].Status != StatusFail || tests[1].Output != "def test_save():\n>       assert 2 == 3" {
+		t.Errorf("Unexpected failing test %+v", tests[1])
+	}
+	if tests[2].Status != StatusSkip || tests[2].Output != "too slow" {
+		t.Errorf("Unexpected skipped test %+v", tests[2])
+	}
+}
+
+func TestParseJest(t *testing.T) {
+	report := `{"testResults":[
+{"name":"/work/src/store.test.ts","status":"failed","assertionResults":[
+  {"fullName":"store loads","title":"loads","status":"passed","duration":4},
+  {"fullName":"store saves","title":"saves","status":"failed","duration":7,"failureMessages":["Expected: 3\nReceived: 2"]},
+  {"fullName":"store later","title":"later","status":"todo","duration":null}]},
+{"name":"/work/src/broken.test.ts","status":"failed","message":"SyntaxError: Unexpected token","assertionResults":[]}]}`
+	tests, err := parseJest([]byte(report))
+	if err != nil {
+		t.Fatalf("parseJest failed: %v", err)
+	}
+	statuses := make([]string, len(tests))
+	for i, test := range tests {
+		statuses[i] = test.Status
+	}
+	if !reflect.DeepEqual(statuses, []string{StatusPass, StatusFail, StatusSkip, StatusFail}) {
+		t.Fatalf("Unexpected statuses %q in %+v", statuses, tests)
+	}
+	if tests[1].Output != "Expected: 3\nReceived: 2" || tests[1].Duration != 7 {
+		t.Errorf("Unexpected failing test %+v", tests[1])
+	}
+	if tests[3].Name != "/work/src/broken.test.ts" || tests[3].Output != "SyntaxError: Unexpected token" {
+		t.Errorf("Expected the file that failed to load as a failed test, got %+v", tests[3])
+	}
+}
+
+func TestExpandArgs(t *testing.T) {
+	args := []string{"{package}", "{file}", "-run", "^{test}$", "--junitxml={report}"}
+	tests := []struct {
+		target Target
+		want   []string
+	}{
+		{Target{}, []string{"./...", ".", "-run", "^$", "--junitxml=/tmp/r.xml"}},
+		{Target{Path: filepath.Join("store", "store_test.go"), Test: "TestLoad"}, []string{"./store", "store/store_test.go", "-run", "^TestLoad$", "--junitxml=/tmp/r.xml"}},
+		{Target{Path: "store", Dir: true}, []string{"./store", "store", "-run", "^$", "--junitxml=/tmp/r.xml"}},
+		{Target{Path: "main_test.go"}, []string{".", "main_test.go", "-run", "^$", "--junitxml=/tmp/r.xml"}},
+	}
+	for _, tt := range tests {
+		if got := expandArgs(args, tt.target, "/tmp/r.xml"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandArgs(%+v) = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+}
+
+// writeGoModule writes a module with a passing, a failing and a slow test
+func writeGoModule(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.21\n",
+		"calc/calc_test.go": `package calc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdd(t *testing.T) {}
+
+func TestSub(t *testing.T) { t.Fatal("Expected 1, got 2") }
+
+func TestSlow(t *testing.T) { time.Sleep(time.Minute) }
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GOFLAGS", "-mod=mod")
+	t.Setenv("GOPROXY", "off")
+	return root
+}
+
+func TestRunGo(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not available")
+	}
+	root := writeGoModule(t)
+	runner := config.DefaultConfig().Tests.Runners["go"]
+
+	result, err := Run(context.Background(), runner, Target{Root: root, Path: "calc", Dir: true, Test: "TestAdd|TestSub"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if result.Passed || result.ExitCode == 0 {
+		t.Errorf("Expected the run to fail, got %+v", result)
+	}
+	if result.Summary != (Summary{Total: 2, Passed: 1, Failed: 1}) {
+		t.Fatalf("Unexpected summary %+v in %+v", result.Summary, result)
+	}
+	for _, test := range result.Tests {
+		if test.Name == "TestSub" && (test.Status != StatusFail || !strings.Contains(test.Output, "Expected 1, got 2")) {
+			t.Errorf("Expected the failure output of TestSub, got %+v", test)
+		}
+	}
+
+	result, err = Run(context.Background(), runner, Target{Root: root, Path: "calc", Dir: true, Test: "TestSlow"}, 3*time.Second)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if !result.TimedOut || result.Passed || result.Summary.TimedOut != 1 {
+		t.Errorf("Expected the run to time out with TestSlow unfinished, got %+v", result)
+	}
+}
+
+func TestRunNotInstalled(t *testing.T) {
+	runner := config.TestRunnerConfig{Command: "code-indexer-no-such-test-runner"}
+	if _, err := Run(context.Background(), runner, Target{Root: t.TempDir()}, time.Second); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("Expected ErrNotInstalled, got %v", err)
+	}
+}
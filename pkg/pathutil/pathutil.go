@@ -0,0 +1,44 @@
+// Package pathutil converts between the paths clients send to the tools, the
+// relative paths stored in the index and the paths of the local file system.
+//
+// Relative paths are stored and returned with forward slashes on every
+// platform, so an index and the results of the tools read the same wherever
+// the server runs. Clients may send either separator: a backslash in a path
+// given to a tool is read as a separator on every platform, so a Windows
+// client can pass src\main.go to a server running on Linux and the other way
+// around. File names containing a backslash cannot be addressed by tools.
+package pathutil
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ToSlash returns path with every backslash replaced by a forward slash.
+// Unlike filepath.ToSlash it does so on every platform, which makes it the
+// form to compare paths sent by clients against stored ones.
+func ToSlash(path string) string {
+	return strings.ReplaceAll(path, `\`, "/")
+}
+
+// Local returns path with both separators replaced by the separator of the
+// local file system, ready to be joined onto a repository root
+func Local(path string) string {
+	return filepath.FromSlash(ToSlash(path))
+}
+
+// Rel returns the path of target relative to base with forward slashes, the
+// form paths are stored in the index
+func Rel(base, target string) (string, error) {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// Within reports whether the slash-separated relative path rel stays within
+// the directory it is relative to rather than climbing out of it
+func Within(rel string) bool {
+	return rel != ".." && !strings.HasPrefix(rel, "../")
+}
@@ -0,0 +1,61 @@
+package pathutil
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestToSlash(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"src/main.go", "src/main.go"},
+		{`src\main.go`, "src/main.go"},
+		{`src\pkg/util.go`, "src/pkg/util.go"},
+		{`.\src\`, "./src/"},
+		{`C:\repo\main.go`, "C:/repo/main.go"},
+	}
+	for _, tt := range tests {
+		if got := ToSlash(tt.path); got != tt.want {
+			t.Errorf("ToSlash(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	want := filepath.Join("src", "pkg", "util.go")
+	for _, path := range []string{"src/pkg/util.go", `src\pkg\util.go`, `src\pkg/util.go`} {
+		if got := Local(path); got != want {
+			t.Errorf("Local(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRel(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator)+"repo", "app")
+	tests := []struct {
+		target string
+		want   string
+		within bool
+	}{
+		{filepath.Join(root, "src", "main.go"), "src/main.go", true},
+		{root, ".", true},
+		{filepath.Join(root, "..", "other", "main.go"), "../other/main.go", false},
+		{filepath.Join(root, ".."), "..", false},
+		{filepath.Join(root, "..config"), "..config", true},
+	}
+	for _, tt := range tests {
+		got, err := Rel(root, tt.target)
+		if err != nil {
+			t.Fatalf("Rel(%q, %q) failed: %v", root, tt.target, err)
+		}
+		if got != tt.want {
+			t.Errorf("Rel(%q, %q) = %q, want %q", root, tt.target, got, tt.want)
+		}
+		if Within(got) != tt.within {
+			t.Errorf("Within(%q) = %v, want %v", got, !tt.within, tt.within)
+		}
+	}
+}
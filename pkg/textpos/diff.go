@@ -0,0 +1,79 @@
+package textpos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// noNewlineMarker follows a diff line that has no line ending
+const noNewlineMarker = "\\ No newline at end of file\n"
+
+// UnifiedDiff returns a unified diff turning before into after, labelled
+// with name and showing up to context unchanged lines around the change, or
+// an empty string when both are equal. Lines are compared together with
+// their endings, but written without them. The changed lines are assumed to
+// form one block, as they do for the line-editing tools, so the diff has a
+// single hunk spanning everything between the first and last difference.
+func UnifiedDiff(name, before, after string, context int) string {
+	a, b := Split(before), Split(after)
+	if context < 0 {
+		context = 0
+	}
+
+	// Lines shared at the start and end of both texts
+	prefix := 0
+	for prefix < len(a.lines) && prefix < len(b.lines) && a.sameLine(prefix, b, prefix) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a.lines)-prefix && suffix < len(b.lines)-prefix &&
+		a.sameLine(len(a.lines)-1-suffix, b, len(b.lines)-1-suffix) {
+		suffix++
+	}
+	if prefix == len(a.lines) && prefix == len(b.lines) {
+		return ""
+	}
+
+	start := max(prefix-context, 0)
+	aEnd, bEnd := len(a.lines)-suffix, len(b.lines)-suffix
+	trailing := min(context, suffix)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", name, name)
+	fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+		hunkRange(start, aEnd+trailing-start),
+		hunkRange(start, bEnd+trailing-start))
+	a.writeDiffLines(&out, ' ', start, prefix)
+	a.writeDiffLines(&out, '-', prefix, aEnd)
+	b.writeDiffLines(&out, '+', prefix, bEnd)
+	a.writeDiffLines(&out, ' ', aEnd, aEnd+trailing)
+	return out.String()
+}
+
+// sameLine reports whether line i of t equals line j of other, endings
+// included
+func (t *Text) sameLine(i int, other *Text, j int) bool {
+	return t.lines[i] == other.lines[j] && t.endings[i] == other.endings[j]
+}
+
+// writeDiffLines writes the lines from start to end, 0-based and exclusive,
+// each prefixed with marker
+func (t *Text) writeDiffLines(out *strings.Builder, marker byte, start, end int) {
+	for i := start; i < end; i++ {
+		out.WriteByte(marker)
+		out.WriteString(t.lines[i])
+		out.WriteByte('\n')
+		if t.endings[i] == "" {
+			out.WriteString(noNewlineMarker)
+		}
+	}
+}
+
+// hunkRange formats the line range of one side of a hunk starting after
+// skip lines. An empty range names the line before it, as diff does.
+func hunkRange(skip, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", skip)
+	}
+	return fmt.Sprintf("%d,%d", skip+1, count)
+}
@@ -0,0 +1,54 @@
+package textpos
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          string
+	}{
+		{
+			name:   "unchanged",
+			before: "a\nb\n",
+			after:  "a\nb\n",
+			want:   "",
+		},
+		{
+			name:   "replace with context",
+			before: "1\n2\n3\n4\n5\n6\n7\n",
+			after:  "1\n2\n3\nfour\n5\n6\n7\n",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -2,5 +2,5 @@\n 2\n 3\n-4\n+four\n 5\n 6\n",
+		},
+		{
+			name:   "delete first line",
+			before: "a\nb\n",
+			after:  "b\n",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,1 @@\n-a\n b\n",
+		},
+		{
+			name:   "insert into empty file",
+			before: "",
+			after:  "a\n",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			name:   "append without final newline",
+			before: "a\nb",
+			after:  "a\nb\nc",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,3 @@\n a\n-b\n\\ No newline at end of file\n+b\n+c\n\\ No newline at end of file\n",
+		},
+		{
+			name:   "crlf",
+			before: "a\r\nb\r\n",
+			after:  "a\r\nc\r\n",
+			want:   "--- a/f.go\n+++ b/f.go\n@@ -1,2 +1,2 @@\n a\n-b\n+c\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := UnifiedDiff("f.go", tt.before, tt.after, 2); got != tt.want {
+			t.Errorf("%s: UnifiedDiff = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
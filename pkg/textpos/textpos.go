@@ -0,0 +1,260 @@
+// Package textpos provides the line and column semantics shared by every tool
+// that addresses source text by position.
+//
+// Lines are 1-based and end at "\n" or "\r\n". A line ending at the end of
+// the text terminates the last line instead of starting an empty one, so
+// "a\nb\n" has two lines, like wc -l, git and most editors report.
+//
+// Columns are 1-based and count characters rather than bytes. A character is
+// a rune together with any combining marks that follow it, so "é" written as
+// "e" plus U+0301 is a single column. Invalid UTF-8 bytes count as one column
+// each.
+package textpos
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Line endings recognised by Split
+const (
+	LF   = "\n"
+	CRLF = "\r\n"
+)
+
+// RangeError reports a line range that does not fit the text
+type RangeError struct {
+	Start int
+	End   int
+	Lines int
+}
+
+// Error implements the error interface
+func (e *RangeError) Error() string {
+	if e.Start == e.End {
+		return fmt.Sprintf("line %d is out of range (file has %d lines)", e.Start, e.Lines)
+	}
+	return fmt.Sprintf("lines %d-%d are out of range (file has %d lines)", e.Start, e.End, e.Lines)
+}
+
+// Text is source text split into lines. Each line remembers its original
+// ending so untouched lines are written back byte for byte.
+type Text struct {
+	lines        []string
+	endings      []string
+	finalNewline bool
+}
+
+// Split splits content into lines
+func Split(content string) *Text {
+	t := &Text{}
+	for len(content) > 0 {
+		idx := strings.IndexByte(content, '\n')
+		if idx < 0 {
+			t.lines = append(t.lines, content)
+			t.endings = append(t.endings, "")
+			break
+		}
+
+		line, ending := content[:idx], LF
+		if strings.HasSuffix(line, "\r") {
+			line, ending = line[:len(line)-1], CRLF
+		}
+		t.lines = append(t.lines, line)
+		t.endings = append(t.endings, ending)
+		content = content[idx+1:]
+	}
+	t.finalNewline = len(t.endings) > 0 && t.endings[len(t.endings)-1] != ""
+	return t
+}
+
+// LineCount returns the number of lines
+func (t *Text) LineCount() int {
+	return len(t.lines)
+}
+
+// Line returns line n without its ending, or false if n is out of range
+func (t *Text) Line(n int) (string, bool) {
+	if n < 1 || n > len(t.lines) {
+		return "", false
+	}
+	return t.lines[n-1], true
+}
+
+// Lines returns the lines from start to end inclusive, without endings
+func (t *Text) Lines(start, end int) ([]string, error) {
+	if err := t.CheckRange(start, end); err != nil {
+		return nil, err
+	}
+	return append([]string(nil), t.lines[start-1:end]...), nil
+}
+
+// CheckRange validates an inclusive 1-based line range
+func (t *Text) CheckRange(start, end int) error {
+	if start < 1 || end < start || end > len(t.lines) {
+		return &RangeError{Start: start, End: end, Lines: len(t.lines)}
+	}
+	return nil
+}
+
+// Ending returns the line ending new lines should use: the ending of the
+// first line, or LF for text without line endings
+func (t *Text) Ending() string {
+	if len(t.endings) > 0 && t.endings[0] != "" {
+		return t.endings[0]
+	}
+	return LF
+}
+
+// Replace replaces the lines from start to end inclusive with the lines of
+// content and returns the number of lines inserted. Passing end = start-1
+// inserts before line start without removing anything; start may be one
+// past the last line to append. New lines use the text's line ending, and
+// whether the text ends with a line ending is preserved. Empty content
+// counts as one empty line.
+func (t *Text) Replace(start, end int, content string) (int, error) {
+	if start < 1 || start > len(t.lines)+1 || end < start-1 || end > len(t.lines) {
+		return 0, &RangeError{Start: start, End: end, Lines: len(t.lines)}
+	}
+
+	newLines := SplitLines(content)
+	if len(newLines) == 0 {
+		newLines = []string{""}
+	}
+
+	ending := t.Ending()
+	newEndings := make([]string, len(newLines))
+	for i := range newEndings {
+		newEndings[i] = ending
+	}
+
+	t.lines = append(t.lines[:start-1], append(newLines, t.lines[end:]...)...)
+	t.endings = append(t.endings[:start-1], append(newEndings, t.endings[end:]...)...)
+	t.fixEndings()
+
+	return len(newLines), nil
+}
+
+// Delete removes the lines from start to end inclusive
+func (t *Text) Delete(start, end int) error {
+	if err := t.CheckRange(start, end); err != nil {
+		return err
+	}
+	t.lines = append(t.lines[:start-1], t.lines[end:]...)
+	t.endings = append(t.endings[:start-1], t.endings[end:]...)
+	t.fixEndings()
+	return nil
+}
+
+// fixEndings makes sure every line but the last has an ending and the last
+// line matches the original final newline
+func (t *Text) fixEndings() {
+	ending := t.Ending()
+	for i := range t.endings {
+		if t.endings[i] == "" {
+			t.endings[i] = ending
+		}
+	}
+	if n := len(t.endings); n > 0 && !t.finalNewline {
+		t.endings[n-1] = ""
+	}
+}
+
+// String joins the lines with their endings
+func (t *Text) String() string {
+	var b strings.Builder
+	for i, line := range t.lines {
+		b.WriteString(line)
+		b.WriteString(t.endings[i])
+	}
+	return b.String()
+}
+
+// SplitLines splits content into lines without their endings
+func SplitLines(content string) []string {
+	return Split(content).lines
+}
+
+// CountLines returns the number of lines in content
+func CountLines(content string) int {
+	return len(Split(content).lines)
+}
+
+// TrimLineEnding removes a trailing "\n" or "\r\n"
+func TrimLineEnding(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
+
+// LineAt returns the 1-based line containing the byte offset
+func LineAt(content string, offset int) int {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+// Column returns the 1-based column of the character containing the byte
+// offset within a line. Offsets past the end give the column after the last
+// character.
+func Column(line string, offset int) int {
+	column := 0
+	for pos := 0; pos < len(line); {
+		next := nextCharacter(line, pos)
+		column++
+		if offset < next {
+			return column
+		}
+		pos = next
+	}
+	return column + 1
+}
+
+// Offset returns the byte offset where the character at a 1-based column
+// starts. Columns past the end of the line give the line length.
+func Offset(line string, column int) int {
+	pos := 0
+	for current := 1; current < column && pos < len(line); current++ {
+		pos = nextCharacter(line, pos)
+	}
+	return pos
+}
+
+// Truncate shortens s to at most maxColumns characters without splitting a
+// rune or separating a combining mark from its base
+func Truncate(s string, maxColumns int) string {
+	if maxColumns <= 0 {
+		return ""
+	}
+	return s[:Offset(s, maxColumns+1)]
+}
+
+// zeroWidthJoiner glues the runes on either side into one character
+const zeroWidthJoiner = '\u200d'
+
+// nextCharacter returns the byte offset after the character starting at pos
+func nextCharacter(s string, pos int) int {
+	_, size := utf8.DecodeRuneInString(s[pos:])
+	pos += size
+	for pos < len(s) {
+		r, size := utf8.DecodeRuneInString(s[pos:])
+		switch {
+		case r == zeroWidthJoiner:
+			pos += size
+			if pos < len(s) {
+				_, size = utf8.DecodeRuneInString(s[pos:])
+				pos += size
+			}
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r):
+			pos += size
+		default:
+			return pos
+		}
+	}
+	return pos
+}
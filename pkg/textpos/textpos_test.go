@@ -0,0 +1,219 @@
+package textpos
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"empty", "", nil},
+		{"single line without newline", "a", []string{"a"}},
+		{"trailing newline ends the last line", "a\nb\n", []string{"a", "b"}},
+		{"no trailing newline", "a\nb", []string{"a", "b"}},
+		{"crlf", "a\r\nb\r\n", []string{"a", "b"}},
+		{"mixed endings", "a\r\nb\nc", []string{"a", "b", "c"}},
+		{"blank lines are kept", "a\n\n\nb\n", []string{"a", "", "", "b"}},
+		{"only newline", "\n", []string{""}},
+		{"lone carriage return is content", "a\rb\n", []string{"a\rb"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitLines(tt.content)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitLines(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+			if CountLines(tt.content) != len(tt.want) {
+				t.Errorf("CountLines(%q) = %d, want %d", tt.content, CountLines(tt.content), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSplitRoundTrip(t *testing.T) {
+	for _, content := range []string{"", "a", "a\n", "a\r\nb\r\n", "a\r\nb\nc", "\n\n", "héllo\r\nwörld"} {
+		if got := Split(content).String(); got != content {
+			t.Errorf("Split(%q).String() = %q", content, got)
+		}
+	}
+}
+
+func TestLines(t *testing.T) {
+	text := Split("one\r\ntwo\r\nthree\r\n")
+
+	lines, err := text.Lines(2, 3)
+	if err != nil {
+		t.Fatalf("Lines(2, 3) failed: %v", err)
+	}
+	if !reflect.DeepEqual(lines, []string{"two", "three"}) {
+		t.Errorf("Lines(2, 3) = %q", lines)
+	}
+
+	for _, r := range [][2]int{{0, 1}, {2, 1}, {3, 4}} {
+		_, err := text.Lines(r[0], r[1])
+		var rangeErr *RangeError
+		if !errors.As(err, &rangeErr) {
+			t.Errorf("Lines(%d, %d) error = %v, want *RangeError", r[0], r[1], err)
+		} else if rangeErr.Lines != 3 {
+			t.Errorf("RangeError.Lines = %d, want 3", rangeErr.Lines)
+		}
+	}
+
+	if line, ok := text.Line(1); !ok || line != "one" {
+		t.Errorf("Line(1) = %q, %v", line, ok)
+	}
+	if _, ok := text.Line(4); ok {
+		t.Error("Line(4) should be out of range")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		start, end int
+		insert     string
+		want       string
+		inserted   int
+	}{
+		{"replace middle line", "a\nb\nc\n", 2, 2, "B", "a\nB\nc\n", 1},
+		{"replace with several lines", "a\nb\nc\n", 2, 2, "x\ny", "a\nx\ny\nc\n", 2},
+		{"trailing newline in content adds no line", "a\nb\n", 1, 1, "x\n", "x\nb\n", 1},
+		{"insert before first line", "a\nb\n", 1, 0, "x", "x\na\nb\n", 1},
+		{"append after last line", "a\nb\n", 3, 2, "c", "a\nb\nc\n", 1},
+		{"append keeps missing final newline", "a\nb", 3, 2, "c", "a\nb\nc", 1},
+		{"crlf is preserved for new lines", "a\r\nb\r\n", 2, 2, "x\ny", "a\r\nx\r\ny\r\n", 2},
+		{"empty content is one empty line", "a\nb\n", 1, 1, "", "\nb\n", 1},
+		{"insert into empty text", "", 1, 0, "a", "a", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := Split(tt.content)
+			inserted, err := text.Replace(tt.start, tt.end, tt.insert)
+			if err != nil {
+				t.Fatalf("Replace failed: %v", err)
+			}
+			if got := text.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if inserted != tt.inserted {
+				t.Errorf("inserted %d lines, want %d", inserted, tt.inserted)
+			}
+		})
+	}
+
+	if _, err := Split("a\n").Replace(3, 2, "x"); err == nil {
+		t.Error("Expected inserting past the end to fail")
+	}
+}
+
+func TestDelete(t *testing.T) {
+	tests := []struct {
+		content    string
+		start, end int
+		want       string
+	}{
+		{"a\nb\nc\n", 2, 2, "a\nc\n"},
+		{"a\nb\nc", 3, 3, "a\nb"},
+		{"a\r\nb\r\nc\r\n", 1, 2, "c\r\n"},
+		{"a\n", 1, 1, ""},
+	}
+
+	for _, tt := range tests {
+		text := Split(tt.content)
+		if err := text.Delete(tt.start, tt.end); err != nil {
+			t.Fatalf("Delete(%d, %d) on %q failed: %v", tt.start, tt.end, tt.content, err)
+		}
+		if got := text.String(); got != tt.want {
+			t.Errorf("Delete(%d, %d) on %q = %q, want %q", tt.start, tt.end, tt.content, got, tt.want)
+		}
+	}
+
+	if err := Split("a\nb\n").Delete(2, 3); err == nil {
+		t.Error("Expected deleting past the end to fail")
+	}
+}
+
+func TestLineAt(t *testing.T) {
+	content := "ab\r\ncd\nef"
+	tests := []struct {
+		offset int
+		want   int
+	}{
+		{0, 1}, {2, 1}, {3, 1}, {4, 2}, {7, 3}, {100, 3}, {-1, 1},
+	}
+	for _, tt := range tests {
+		if got := LineAt(content, tt.offset); got != tt.want {
+			t.Errorf("LineAt(%d) = %d, want %d", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		offset int
+		column int
+	}{
+		{"ascii", "hello", 4, 5},
+		{"multi-byte rune", "h\u00e9llo", 3, 3},
+		{"inside a multi-byte rune", "h\u00e9llo", 2, 2},
+		{"combining mark belongs to its base", "he\u0301llo", 3, 2},
+		{"after combining mark", "he\u0301llo", 4, 3},
+		{"cjk", "日本語", 6, 3},
+		{"emoji zwj sequence is one column", "a\U0001F468\u200d\U0001F469b", 12, 3},
+		{"end of line", "abc", 3, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Column(tt.line, tt.offset); got != tt.column {
+				t.Errorf("Column(%q, %d) = %d, want %d", tt.line, tt.offset, got, tt.column)
+			}
+		})
+	}
+
+	offsets := []struct {
+		line   string
+		column int
+		want   int
+	}{
+		{"h\u00e9llo", 3, 3},
+		{"he\u0301llo", 3, 4},
+		{"abc", 10, 3},
+		{"abc", 0, 0},
+	}
+	for _, tt := range offsets {
+		if got := Offset(tt.line, tt.column); got != tt.want {
+			t.Errorf("Offset(%q, %d) = %d, want %d", tt.line, tt.column, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"hello", 10, "hello"},
+		{"hello", 3, "hel"},
+		{"h\u00e9llo", 2, "h\u00e9"},
+		{"he\u0301llo", 2, "he\u0301"},
+		{"日本語", 1, "日"},
+		{"abc", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package types
+
+// Error codes of failed tool calls and daemon API requests. Clients branch
+// on the code; the message is for people and may change.
+const (
+	ErrorInvalidArgument    = "INVALID_ARGUMENT"     // A parameter is missing or has a wrong value
+	ErrorUnsupported        = "UNSUPPORTED"          // The tool does not handle the language, format or request
+	ErrorRepoNotFound       = "REPO_NOT_FOUND"       // No indexed repository has the name
+	ErrorNotFound           = "NOT_FOUND"            // A file, symbol, session, job or other named thing does not exist
+	ErrorToolNotFound       = "TOOL_NOT_FOUND"       // No tool has the name
+	ErrorPathOutsideSandbox = "PATH_OUTSIDE_SANDBOX" // A path resolves outside the indexed repositories and allowed paths, or the workspace of an isolated session
+	ErrorPermissionDenied   = "PERMISSION_DENIED"    // The API key, a permission profile or workspace isolation forbids the call
+	ErrorUnauthenticated    = "UNAUTHENTICATED"      // The request carries no valid API key
+	ErrorRateLimited        = "RATE_LIMITED"         // The client called too often
+	ErrorQuotaExceeded      = "QUOTA_EXCEEDED"       // The connection used up one of its resource quotas
+	ErrorIndexStale         = "INDEX_STALE"          // The index disagrees with the files on disk
+	ErrorConflict           = "CONFLICT"             // The files changed since the state the call relies on
+	ErrorLocked             = "LOCKED"               // Another call held a file, repository or the index for longer than the lock timeout
+	ErrorDeadlock           = "DEADLOCK"             // The call was aborted to break a cycle of calls waiting for each other's locks
+	ErrorFeatureDisabled    = "FEATURE_DISABLED"     // The configuration turns off what the call needs
+	ErrorUpstreamFailed     = "UPSTREAM_FAILED"      // A language server, model provider or git failed
+	ErrorUnavailable        = "UNAVAILABLE"          // The server is at capacity
+	ErrorTimeout            = "TIMEOUT"              // The call ran past its time limit
+	ErrorCancelled          = "CANCELLED"            // The call was cancelled before it finished
+	ErrorInternal           = "INTERNAL"             // Anything else
+)
+
+// Error categories group the error codes by what a client can do about them
+const (
+	ErrorCategoryInvalidRequest = "invalid_request" // Change the request
+	ErrorCategoryNotFound       = "not_found"       // Name something that exists, or create it
+	ErrorCategoryPermission     = "permission"      // Use another key, path or server
+	ErrorCategoryState          = "state"           // Refresh the index or re-read the files, then retry
+	ErrorCategoryUnavailable    = "unavailable"     // Retry later or change the configuration
+	ErrorCategoryInterrupted    = "interrupted"     // Retry, possibly with a narrower request
+	ErrorCategoryInternal       = "internal"        // Report it
+)
+
+// errorClasses holds the category of each error code and whether the same
+// call may succeed when retried unchanged
+var errorClasses = map[string]struct {
+	category  string
+	retryable bool
+}{
+	ErrorInvalidArgument:    {ErrorCategoryInvalidRequest, false},
+	ErrorUnsupported:        {ErrorCategoryInvalidRequest, false},
+	ErrorRepoNotFound:       {ErrorCategoryNotFound, false},
+	ErrorNotFound:           {ErrorCategoryNotFound, false},
+	ErrorToolNotFound:       {ErrorCategoryNotFound, false},
+	ErrorPathOutsideSandbox: {ErrorCategoryPermission, false},
+	ErrorPermissionDenied:   {ErrorCategoryPermission, false},
+	ErrorUnauthenticated:    {ErrorCategoryPermission, false},
+	ErrorRateLimited:        {ErrorCategoryUnavailable, true},
+	ErrorQuotaExceeded:      {ErrorCategoryUnavailable, true},
+	ErrorIndexStale:         {ErrorCategoryState, false},
+	ErrorConflict:           {ErrorCategoryState, false},
+	ErrorLocked:             {ErrorCategoryUnavailable, true},
+	ErrorDeadlock:           {ErrorCategoryInterrupted, true},
+	ErrorFeatureDisabled:    {ErrorCategoryUnavailable, false},
+	ErrorUpstreamFailed:     {ErrorCategoryUnavailable, true},
+	ErrorUnavailable:        {ErrorCategoryUnavailable, true},
+	ErrorTimeout:            {ErrorCategoryInterrupted, true},
+	ErrorCancelled:          {ErrorCategoryInterrupted, true},
+	ErrorInternal:           {ErrorCategoryInternal, false},
+}
+
+// ToolError is the machine-readable error of a failed tool call or daemon
+// API request
+type ToolError struct {
+	Code      string                 `json:"code"`
+	Category  string                 `json:"category"`
+	Retryable bool                   `json:"retryable"`
+	Message   string                 `json:"message"`
+	Details   map[string]interface{} `json:"details,omitempty"` // Values the code refers to, e.g. the repository not found
+}
+
+// NewToolError returns the error of a code, with its category and retry
+// flag. Unknown codes are internal errors.
+func NewToolError(code, message string, details map[string]interface{}) *ToolError {
+	class, ok := errorClasses[code]
+	if !ok {
+		code = ErrorInternal
+		class = errorClasses[ErrorInternal]
+	}
+	return &ToolError{
+		Code:      code,
+		Category:  class.category,
+		Retryable: class.retryable,
+		Message:   message,
+		Details:   details,
+	}
+}
+
+// Error returns the message, so a ToolError reads like the free-text
+// errors it replaces
+func (e *ToolError) Error() string {
+	return e.Message
+}
+
+// ErrorEnvelope is the JSON body of a failed tool call or daemon API request
+type ErrorEnvelope struct {
+	Success bool       `json:"success"` // Always false
+	Error   *ToolError `json:"error"`
+}
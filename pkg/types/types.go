@@ -1,26 +1,77 @@
 package types
 
 import (
+	"strings"
 	"time"
 )
 
 // Repository represents a Git repository that has been indexed
 type Repository struct {
-	ID              string            `json:"id"`
-	Name            string            `json:"name"`
-	Path            string            `json:"path"`
-	URL             string            `json:"url,omitempty"`
-	IndexedAt       time.Time         `json:"indexed_at"`
-	FileCount       int               `json:"file_count"`
-	TotalLines      int               `json:"total_lines"`
-	Languages       []string          `json:"languages"`
-	LastCommit      string            `json:"last_commit,omitempty"`
-	Branch          string            `json:"branch,omitempty"`
-	LastIndexedHash string            `json:"last_indexed_hash,omitempty"`
-	Submodules      []Submodule       `json:"submodules,omitempty"`
-	IndexingMode    string            `json:"indexing_mode,omitempty"` // "full", "incremental", "sparse"
-	SparsePatterns  []string          `json:"sparse_patterns,omitempty"`
-	CommitHistory   []CommitInfo      `json:"commit_history,omitempty"`
+	ID              string             `json:"id"`
+	Name            string             `json:"name"`
+	Path            string             `json:"path"`
+	URL             string             `json:"url,omitempty"`
+	IndexedAt       time.Time          `json:"indexed_at"`
+	FileCount       int                `json:"file_count"`
+	TotalLines      int                `json:"total_lines"`
+	Languages       []string           `json:"languages"`
+	LastCommit      string             `json:"last_commit,omitempty"`
+	Branch          string             `json:"branch,omitempty"`
+	Ref             string             `json:"ref,omitempty"` // Branch, tag or commit checked out for indexing, if one was requested
+	LastIndexedHash string             `json:"last_indexed_hash,omitempty"`
+	Submodules      []Submodule        `json:"submodules,omitempty"`
+	IndexingMode    string             `json:"indexing_mode,omitempty"` // "full", "incremental", "sparse"
+	SparsePatterns  []string           `json:"sparse_patterns,omitempty"`
+	CommitHistory   []CommitInfo       `json:"commit_history,omitempty"`
+	Namespace       string             `json:"namespace,omitempty"` // DependencyNamespace for dependency sources, "" otherwise
+	Modules         []RepositoryModule `json:"modules,omitempty"`   // Modules other repositories can import from it
+}
+
+// DependencyNamespace is the namespace of repositories holding the sources
+// of dependencies, which searches leave out unless asked to include them
+const DependencyNamespace = "deps"
+
+// RepositoryModule is a module a repository provides, by the path files of
+// other repositories import it with
+type RepositoryModule struct {
+	Language string `json:"language"` // "go", "python", or "javascript" for JavaScript and TypeScript
+	Path     string `json:"path"`     // Go module path, top-level Python package or npm package name
+	Dir      string `json:"dir"`      // Directory of the module relative to the repository root, "." for the root
+}
+
+// Provides reports whether importing importPath in a file of language
+// loads the module or one of the packages below it
+func (m RepositoryModule) Provides(language, importPath string) bool {
+	if ModuleLanguage(language) != m.Language {
+		return false
+	}
+	separator := "/"
+	if m.Language == "python" {
+		separator = "."
+	}
+	return importPath == m.Path || strings.HasPrefix(importPath, m.Path+separator)
+}
+
+// ModuleFor returns the module of the repository an import of importPath
+// in a file of language loads, the one with the longest path when several
+// match
+func (r *Repository) ModuleFor(language, importPath string) (RepositoryModule, bool) {
+	var found RepositoryModule
+	for _, module := range r.Modules {
+		if module.Provides(language, importPath) && len(module.Path) > len(found.Path) {
+			found = module
+		}
+	}
+	return found, found.Path != ""
+}
+
+// ModuleLanguage returns the language of the modules files of language
+// import; TypeScript files import JavaScript packages
+func ModuleLanguage(language string) string {
+	if language == "typescript" {
+		return "javascript"
+	}
+	return language
 }
 
 // Submodule represents a Git submodule
@@ -42,6 +93,40 @@ type CommitInfo struct {
 	Files     []string  `json:"files,omitempty"`
 }
 
+// GitDiff is a structured diff of a repository between two commits, a
+// commit and the working tree, or a commit and the staged changes
+type GitDiff struct {
+	From         string     `json:"from"` // Commit the diff starts from
+	To           string     `json:"to"`   // Commit compared with, or "working_tree" or "index"
+	Files        []FileDiff `json:"files"`
+	FilesChanged int        `json:"files_changed"`
+	Additions    int        `json:"additions"`
+	Deletions    int        `json:"deletions"`
+	Truncated    bool       `json:"truncated,omitempty"` // Hunks past the line limit were left out
+}
+
+// FileDiff is the diff of one file. Binary files and diffs in summary mode
+// carry no hunks.
+type FileDiff struct {
+	Path      string     `json:"path"`
+	Status    string     `json:"status"` // "added", "deleted", "modified"
+	Binary    bool       `json:"binary,omitempty"`
+	Additions int        `json:"additions"`
+	Deletions int        `json:"deletions"`
+	Hunks     []DiffHunk `json:"hunks,omitempty"`
+}
+
+// DiffHunk is a run of changed lines with the unchanged lines around them,
+// as in a unified diff. Each line starts with " ", "+" or "-".
+type DiffHunk struct {
+	Header   string   `json:"header"` // e.g. "@@ -10,7 +10,8 @@"
+	OldStart int      `json:"old_start"`
+	OldLines int      `json:"old_lines"`
+	NewStart int      `json:"new_start"`
+	NewLines int      `json:"new_lines"`
+	Lines    []string `json:"lines"`
+}
+
 // IncrementalIndexRequest represents a request for incremental indexing
 type IncrementalIndexRequest struct {
 	RepositoryID string `json:"repository_id"`
@@ -50,6 +135,28 @@ type IncrementalIndexRequest struct {
 	ForceRebuild bool   `json:"force_rebuild,omitempty"`
 }
 
+// IncrementalIndexResult reports what an incremental indexing run changed.
+// Document counts cover every search document of a file: the file itself,
+// its symbols, comments and chunks. The old documents of a changed file
+// count as deleted and its new ones as updated.
+type IncrementalIndexResult struct {
+	RepositoryID     string  `json:"repository_id"`
+	Repository       string  `json:"repository"`
+	Mode             string  `json:"mode"` // "incremental", "full" or "unchanged"
+	FallbackReason   string  `json:"fallback_reason,omitempty"`
+	FromCommit       string  `json:"from_commit,omitempty"`
+	ToCommit         string  `json:"to_commit,omitempty"`
+	Commits          int     `json:"commits"`
+	FilesUpdated     int     `json:"files_updated"`
+	FilesDeleted     int     `json:"files_deleted"`
+	FilesSkipped     int     `json:"files_skipped"`
+	FilesFailed      int     `json:"files_failed"`
+	DocumentsUpdated int     `json:"documents_updated"`
+	DocumentsDeleted int     `json:"documents_deleted"`
+	DocumentsSkipped int     `json:"documents_skipped"`
+	ElapsedSeconds   float64 `json:"elapsed_seconds"`
+}
+
 // CodeChunk represents a semantic chunk of code
 type CodeChunk struct {
 	ID           string                 `json:"id"`
@@ -79,54 +186,83 @@ type CodeFile struct {
 	IndexedAt    time.Time   `json:"indexed_at"`
 	Functions    []Function  `json:"functions,omitempty"`
 	Classes      []Class     `json:"classes,omitempty"`
+	TypeDeclarations []TypeDeclaration `json:"type_declarations,omitempty"`
 	Variables    []Variable  `json:"variables,omitempty"`
 	Imports      []Import    `json:"imports,omitempty"`
+	Module       string      `json:"module,omitempty"` // Import path, dotted module or package other files import it by
+	Project      string      `json:"project,omitempty"` // Directory of the enclosing sub-project, "." for the repository root
 	Comments     []Comment   `json:"comments,omitempty"`
+	Sections     []Section   `json:"sections,omitempty"`    // Markdown headings and code blocks
+	ConfigKeys   []ConfigKey `json:"config_keys,omitempty"` // Keys of YAML and JSON files
 	Chunks       []CodeChunk `json:"chunks,omitempty"`
+	References   []Reference `json:"references,omitempty"`
+	SecurityFindings []SecurityFinding `json:"security_findings,omitempty"`
 	TreeSitterAST interface{} `json:"tree_sitter_ast,omitempty"`
 }
 
 // Function represents a function or method definition
 type Function struct {
-	Name        string     `json:"name"`
-	StartLine   int        `json:"start_line"`
-	EndLine     int        `json:"end_line"`
-	Parameters  []string   `json:"parameters,omitempty"`
-	ReturnType  string     `json:"return_type,omitempty"`
-	Visibility  string     `json:"visibility,omitempty"`
-	IsMethod    bool       `json:"is_method"`
-	ClassName   string     `json:"class_name,omitempty"`
-	DocString   string     `json:"doc_string,omitempty"`
-	Signature   string     `json:"signature"`
-	Body        string     `json:"body,omitempty"`
-	Annotations []string   `json:"annotations,omitempty"`
+	Name           string   `json:"name"`
+	StartLine      int      `json:"start_line"`
+	EndLine        int      `json:"end_line"`
+	Parameters     []string `json:"parameters,omitempty"`
+	ReturnType     string   `json:"return_type,omitempty"`
+	Visibility     string   `json:"visibility,omitempty"`
+	IsMethod       bool     `json:"is_method"`
+	ClassName      string   `json:"class_name,omitempty"`
+	DocString      string   `json:"doc_string,omitempty"`
+	Signature      string   `json:"signature"`
+	Body           string   `json:"body,omitempty"`
+	Annotations    []string `json:"annotations,omitempty"`
+	ReferenceCount int      `json:"reference_count,omitempty"` // Uses across the repository
+	Cyclomatic     int      `json:"cyclomatic,omitempty"`      // Cyclomatic complexity, 0 if not measured
+	Cognitive      int      `json:"cognitive,omitempty"`       // Cognitive complexity
 }
 
 // Class represents a class or struct definition
 type Class struct {
-	Name        string     `json:"name"`
-	StartLine   int        `json:"start_line"`
-	EndLine     int        `json:"end_line"`
-	Visibility  string     `json:"visibility,omitempty"`
-	SuperClass  string     `json:"super_class,omitempty"`
-	Interfaces  []string   `json:"interfaces,omitempty"`
-	DocString   string     `json:"doc_string,omitempty"`
-	Methods     []Function `json:"methods,omitempty"`
-	Fields      []Variable `json:"fields,omitempty"`
-	Annotations []string   `json:"annotations,omitempty"`
+	Name           string     `json:"name"`
+	StartLine      int        `json:"start_line"`
+	EndLine        int        `json:"end_line"`
+	Visibility     string     `json:"visibility,omitempty"`
+	SuperClass     string     `json:"super_class,omitempty"`
+	Interfaces     []string   `json:"interfaces,omitempty"`
+	DocString      string     `json:"doc_string,omitempty"`
+	Methods        []Function `json:"methods,omitempty"`
+	Fields         []Variable `json:"fields,omitempty"`
+	Annotations    []string   `json:"annotations,omitempty"`
+	ReferenceCount int        `json:"reference_count,omitempty"` // Uses across the repository
+}
+
+// TypeDeclaration represents a named type that is not a class, such as a
+// TypeScript interface or type alias, a Protocol Buffers message or service,
+// or a SQL table
+type TypeDeclaration struct {
+	Name           string   `json:"name"`
+	Kind           string   `json:"kind"` // "interface", "type_alias", "message", "enum", "service", "rpc", "table", "view", "index" or "procedure"
+	StartLine      int      `json:"start_line"`
+	EndLine        int      `json:"end_line"`
+	TypeParameters string   `json:"type_parameters,omitempty"` // e.g. "<T extends Node>"
+	Extends        []string `json:"extends,omitempty"`
+	Members        []string `json:"members,omitempty"` // Property and method signatures of interfaces, fields of messages, columns of tables
+	Parent         string   `json:"parent,omitempty"`  // Enclosing message or service, table of an index
+	Definition     string   `json:"definition"`
+	IsExported     bool     `json:"is_exported"`
+	ReferenceCount int      `json:"reference_count,omitempty"` // Uses across the repository
 }
 
 // Variable represents a variable or constant declaration
 type Variable struct {
-	Name       string   `json:"name"`
-	Type       string   `json:"type,omitempty"`
-	Value      string   `json:"value,omitempty"`
-	StartLine  int      `json:"start_line"`
-	EndLine    int      `json:"end_line"`
-	Visibility string   `json:"visibility,omitempty"`
-	IsConstant bool     `json:"is_constant"`
-	IsGlobal   bool     `json:"is_global"`
-	Scope      string   `json:"scope,omitempty"`
+	Name           string `json:"name"`
+	Type           string `json:"type,omitempty"`
+	Value          string `json:"value,omitempty"`
+	StartLine      int    `json:"start_line"`
+	EndLine        int    `json:"end_line"`
+	Visibility     string `json:"visibility,omitempty"`
+	IsConstant     bool   `json:"is_constant"`
+	IsGlobal       bool   `json:"is_global"`
+	Scope          string `json:"scope,omitempty"`
+	ReferenceCount int    `json:"reference_count,omitempty"` // Uses across the repository
 }
 
 // Import represents an import or include statement
@@ -137,6 +273,54 @@ type Import struct {
 	IsWildcard bool  `json:"is_wildcard"`
 }
 
+// FileImports is what the index records about the imports of a file
+type FileImports struct {
+	FilePath string   `json:"file_path"`
+	Language string   `json:"language"`
+	Module   string   `json:"module,omitempty"`
+	Imports  []string `json:"imports,omitempty"` // Modules as written in the import statements
+}
+
+// ChunkDependencies is what the index records about a chunk and the symbols
+// it references
+type ChunkDependencies struct {
+	ChunkID      string   `json:"chunk_id"`
+	FilePath     string   `json:"file_path"`
+	Name         string   `json:"name,omitempty"`
+	ChunkType    string   `json:"chunk_type"`
+	StartLine    int      `json:"start_line"`
+	EndLine      int      `json:"end_line"`
+	Dependencies []string `json:"dependencies,omitempty"` // e.g. "call:Normalize", "type:User", "import:strings"
+}
+
+// FunctionComplexity is the complexity the index records for a function
+type FunctionComplexity struct {
+	FilePath   string `json:"file_path"`
+	Language   string `json:"language"`
+	Name       string `json:"name"`
+	ClassName  string `json:"class_name,omitempty"`
+	StartLine  int    `json:"start_line"`
+	EndLine    int    `json:"end_line"`
+	Cyclomatic int    `json:"cyclomatic"` // 0 when the function was not measured
+	Cognitive  int    `json:"cognitive"`
+}
+
+// SecurityFinding is a hardcoded secret found in a file. The secret itself
+// is never kept, only a redacted form and a fingerprint of it.
+type SecurityFinding struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"` // "low", "medium", "high" or "critical"
+	Repository  string `json:"repository,omitempty"`
+	FilePath    string `json:"file_path"`
+	Language    string `json:"language,omitempty"`
+	Line        int    `json:"line"`
+	Column      int    `json:"column"`     // 1-based character column
+	EndColumn   int    `json:"end_column"` // Column just after the secret
+	Redacted    string `json:"redacted"`
+	Fingerprint string `json:"fingerprint"` // Stable across edits that move the line
+}
+
 // Comment represents a comment in the code
 type Comment struct {
 	Text      string `json:"text"`
@@ -145,33 +329,349 @@ type Comment struct {
 	Type      string `json:"type"` // "line", "block", "doc"
 }
 
+// Section is a part of a Markdown document: a heading with the text up to
+// the next heading, or a fenced code block
+type Section struct {
+	Kind      string   `json:"kind"`               // "heading" or "code_block"
+	Title     string   `json:"title"`              // Heading text, or the heading a code block is under
+	Level     int      `json:"level,omitempty"`    // Heading level, 1 for "#"; 0 for text before the first heading
+	Parents   []string `json:"parents,omitempty"`  // Titles of the enclosing headings, outermost first
+	Language  string   `json:"language,omitempty"` // Language of a code block as its fence names it, e.g. "bash"
+	Content   string   `json:"content"`
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+}
+
+// ConfigKey is a key of a YAML or JSON file with its value
+type ConfigKey struct {
+	Path      string `json:"path"`               // Keys from the root joined by dots, list items as [i], e.g. spec.retry.limit
+	Key       string `json:"key"`                // Last key of the path, e.g. limit
+	Value     string `json:"value,omitempty"`    // Scalar value; empty for mappings and lists
+	ValueType string `json:"value_type"`         // "string", "number", "boolean", "null", "mapping", "list" or "alias"
+	Document  int    `json:"document,omitempty"` // Index of the document in a multi-document YAML file
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// Reference is a use of a symbol in a file: a call, or a type named in a
+// declaration or instantiation
+type Reference struct {
+	Name      string `json:"name"`                // Referenced identifier, e.g. "Save"
+	Kind      string `json:"kind"`                // "call" or "type"
+	Qualifier string `json:"qualifier,omitempty"` // Receiver or package, e.g. "repo" in repo.Save()
+	Caller    string `json:"caller,omitempty"`    // Enclosing function, empty at file level
+	Target    string `json:"target,omitempty"`    // Resolved definition, e.g. "auth/login.go:Verify"
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+}
+
+// ReferenceQuery selects entries of the reference index. Name matches the
+// referenced identifier and Caller the enclosing function; at least one of
+// them must be set.
+type ReferenceQuery struct {
+	Name       string   `json:"name,omitempty"`
+	Caller     string   `json:"caller,omitempty"`
+	Kinds      []string `json:"kinds,omitempty"`
+	Repository string   `json:"repository,omitempty"`
+	// Importers are files of other repositories, by repository name, whose
+	// references are searched with those of Repository
+	Importers  map[string][]string `json:"-"`
+	MaxResults int                 `json:"max_results,omitempty"` // Page size
+	Offset     int                 `json:"offset,omitempty"`      // References skipped before the page
+}
+
+// ReferencePage is one page of references. NextCursor resumes the listing
+// after the page and is empty on the last page.
+type ReferencePage struct {
+	References []ReferenceResult `json:"references"`
+	Total      int               `json:"total"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// ReferenceResult is a reference found in the index, with the line it is on
+type ReferenceResult struct {
+	Reference
+	RepositoryID string     `json:"repository_id"`
+	Repository   string     `json:"repository"`
+	FilePath     string     `json:"file_path"`
+	Language     string     `json:"language"`
+	Context      string     `json:"context,omitempty"`
+	FollowUps    []FollowUp `json:"follow_ups,omitempty"`
+	// TargetRepository is the repository of Target when it is another one,
+	// whose module the file imports
+	TargetRepository string `json:"target_repository,omitempty"`
+}
+
 // SearchResult represents a search result
 type SearchResult struct {
-	ID           string            `json:"id"`
-	RepositoryID string            `json:"repository_id"`
-	Repository   string            `json:"repository"`
-	FilePath     string            `json:"file_path"`
-	Language     string            `json:"language"`
-	Type         string            `json:"type"` // "function", "class", "variable", "content", "comment"
-	Name         string            `json:"name,omitempty"`
-	Content      string            `json:"content"`
-	Snippet      string            `json:"snippet,omitempty"`
-	StartLine    int               `json:"start_line"`
-	EndLine      int               `json:"end_line"`
-	Score        float64           `json:"score"`
-	Highlights   map[string]string `json:"highlights,omitempty"`
-	Context      map[string]any    `json:"context,omitempty"`
-}
-
-// SearchQuery represents a search query with filters
+	ID             string            `json:"id"`
+	RepositoryID   string            `json:"repository_id"`
+	Repository     string            `json:"repository"`
+	Ref            string            `json:"ref,omitempty"` // Ref the repository was indexed at, if any
+	Project        string            `json:"project,omitempty"` // Sub-project the file belongs to, if any
+	FilePath       string            `json:"file_path"`
+	Language       string            `json:"language"`
+	Type           string            `json:"type"` // "function", "class", "variable", "content", "comment"
+	Name           string            `json:"name,omitempty"`
+	Content        string            `json:"content"`
+	Snippet        string            `json:"snippet,omitempty"`
+	StartLine      int               `json:"start_line"`
+	EndLine        int               `json:"end_line"`
+	Score          float64           `json:"score"`
+	Highlights     map[string]string `json:"highlights,omitempty"`
+	Context        map[string]any    `json:"context,omitempty"`
+	ReferenceCount int               `json:"reference_count,omitempty"` // Uses of the symbol across its repository
+	Complexity     int               `json:"complexity,omitempty"`      // Cyclomatic complexity of a function
+	FollowUps      []FollowUp        `json:"follow_ups,omitempty"`
+}
+
+// RegexMatch is a regular expression match on one line of an indexed file.
+// Columns are 1-based characters as defined by package textpos; EndColumn is
+// the column just after the match.
+type RegexMatch struct {
+	RepositoryID string `json:"repository_id"`
+	Repository   string `json:"repository"`
+	FilePath     string `json:"file_path"`
+	Language     string `json:"language"`
+	Line         int    `json:"line"`
+	Column       int    `json:"column"`
+	EndColumn    int    `json:"end_column"`
+	Text         string `json:"text"`
+	LineText     string `json:"line_text"`
+}
+
+// RegexSearchResult is one page of regular expression matches, ordered by
+// repository, file and position
+type RegexSearchResult struct {
+	Pattern         string       `json:"pattern"`
+	Matches         []RegexMatch `json:"matches"`
+	Total           int          `json:"total"`
+	NextCursor      string       `json:"next_cursor,omitempty"`
+	TrigramFiltered bool         `json:"trigram_filtered"`           // Candidate files were narrowed by the trigram index
+	FilesScanned    int          `json:"files_scanned"`              // Candidate files verified line by line
+	FilesUnverified int          `json:"files_unverified,omitempty"` // Candidates whose content is not stored in the index
+	Truncated       bool         `json:"truncated,omitempty"`        // Scanning stopped at the match limit
+}
+
+// FollowUp is a ready-to-use tool call for exploring a search result further
+type FollowUp struct {
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments"`
+	Description string         `json:"description"`
+}
+
+// SearchQuery represents a search query with filters. Each filter accepts a
+// single value, a list, or both; all values of a filter are ORed together.
 type SearchQuery struct {
-	Query      string   `json:"query"`
-	Type       string   `json:"type,omitempty"`       // "function", "class", "variable", "content", "file", "comment"
-	Language   string   `json:"language,omitempty"`   // Filter by programming language
-	Repository string   `json:"repository,omitempty"` // Filter by repository name
-	FilePath   string   `json:"file_path,omitempty"`  // Filter by file path pattern
-	MaxResults int      `json:"max_results,omitempty"`
-	Fuzzy      bool     `json:"fuzzy,omitempty"`
+	Query        string   `json:"query"`
+	Type         string   `json:"type,omitempty"`       // "function", "class", "variable", "content", "file", "comment"
+	Types        []string `json:"types,omitempty"`      // Additional accepted types
+	Language     string   `json:"language,omitempty"`   // Filter by programming language
+	Languages    []string `json:"languages,omitempty"`  // Additional accepted languages
+	Repository   string   `json:"repository,omitempty"` // Filter by repository name
+	Repositories []string `json:"repositories,omitempty"`
+	Ref          string   `json:"ref,omitempty"`           // Filter by the ref repositories were indexed at
+	IncludeDeps  bool     `json:"include_deps,omitempty"`  // Also search dependency sources, see DependencyNamespace
+	Project      string   `json:"project,omitempty"` // Filter by sub-project directory, e.g. services/api
+	Projects     []string `json:"projects,omitempty"`
+	FilePath     string   `json:"file_path,omitempty"`     // Filter by file path pattern
+	PathPrefix   string   `json:"path_prefix,omitempty"`   // Only paths under this prefix, e.g. internal/server/**
+	ExcludePaths []string `json:"exclude_paths,omitempty"` // Skip paths matching these globs, e.g. **/testdata/**
+	MaxResults   int      `json:"max_results,omitempty"`   // Page size
+	Offset       int      `json:"offset,omitempty"`        // Results skipped before the page
+	Fuzzy        bool     `json:"fuzzy,omitempty"`
+
+	// Maximum edit distance of each word of a fuzzy query, 1 or 2; zero
+	// is 1. Fuzzy queries for symbol types only match names.
+	Fuzziness int `json:"fuzziness,omitempty"`
+
+	// Match names starting with the query, for completion; takes
+	// precedence over Fuzzy
+	Prefix bool `json:"prefix,omitempty"`
+
+	// Parse Query for qualifiers such as lang:go and name:Parse*, +required
+	// and -excluded terms and "quoted phrases"
+	Syntax bool `json:"syntax,omitempty"`
+
+	// Match identifiers as written rather than ignoring case, and only
+	// whole identifiers rather than identifiers containing the query; either
+	// searches names and content only, and fuzzy queries ignore both
+	CaseSensitive bool `json:"case_sensitive,omitempty"`
+	WholeWord     bool `json:"whole_word,omitempty"`
+
+	// Only functions whose cyclomatic complexity is in this range; zero
+	// leaves a bound open
+	MinComplexity int    `json:"min_complexity,omitempty"`
+	MaxComplexity int    `json:"max_complexity,omitempty"`
+	SortBy        string `json:"sort_by,omitempty"` // "relevance" (default) or "complexity", most complex first
+
+	// How strongly reference counts boost symbols, set by the server from
+	// search.popularity_weight; zero ranks by text relevance alone
+	PopularityWeight float64 `json:"-"`
+
+	// Scoring profile, one of ScoringProfiles; empty is "default"
+	Profile string `json:"profile,omitempty"`
+
+	// Field, type and recency boosts, set by the server from
+	// search.ranking; the zero value ranks by text relevance alone
+	Ranking Ranking `json:"-"`
+}
+
+// QueryPlan is a natural language question translated into lookups of the
+// index
+type QueryPlan struct {
+	Question string      `json:"question"`
+	Intent   string      `json:"intent"` // "definition", "usage", "files", "pattern" or "search"
+	Steps    []QueryStep `json:"steps"`
+	Planner  string      `json:"planner"` // "heuristic", or the model that wrote the plan
+}
+
+// QueryStep is one lookup of a query plan
+type QueryStep struct {
+	Kind       string `json:"kind"`  // "symbol", "references", "search", "regex" or "files"
+	Query      string `json:"query"` // Symbol name, search text, regular expression or file pattern
+	SymbolType string `json:"symbol_type,omitempty"`
+	Language   string `json:"language,omitempty"`
+	PathPrefix string `json:"path_prefix,omitempty"`
+	Reason     string `json:"reason,omitempty"` // What the step looks for
+}
+
+// Evidence is a place in the code that answers a question, with the steps
+// of the query plan that found it
+type Evidence struct {
+	Citation   string  `json:"citation"` // path:line or path:start-end
+	Repository string  `json:"repository"`
+	FilePath   string  `json:"file_path"`
+	Language   string  `json:"language,omitempty"`
+	StartLine  int     `json:"start_line,omitempty"`
+	EndLine    int     `json:"end_line,omitempty"`
+	Name       string  `json:"name,omitempty"`
+	Type       string  `json:"type,omitempty"` // Document type, "reference" or "match"
+	Snippet    string  `json:"snippet,omitempty"`
+	Score      float64 `json:"score"`
+	Steps      []int   `json:"steps"` // Indexes of the plan steps that found it
+}
+
+// Scoring profiles of a search query
+const (
+	ProfileDefault = "default" // Field and type boosts
+	ProfileSymbols = "symbols" // Names and definitions boosted further
+	ProfileRecent  = "recent"  // Field and type boosts, recently modified files first
+	ProfileText    = "text"    // Text relevance alone
+)
+
+// ScoringProfiles lists the scoring profiles a search query may select
+var ScoringProfiles = []string{ProfileDefault, ProfileSymbols, ProfileRecent, ProfileText}
+
+// Ranking holds the boosts search results are scored with. Field boosts
+// weigh where the query matched, type boosts multiply the score of each
+// document type, and the recency boost favours files modified within a few
+// half-lives. Zero field and type boosts count as 1.
+type Ranking struct {
+	NameBoost       float64
+	ExactNameBoost  float64 // Names matching the whole query, on top of NameBoost; zero adds none
+	ContentBoost    float64
+	PathBoost       float64
+	TypeBoosts      map[string]float64
+	RecencyWeight   float64 // Zero turns the recency boost off
+	RecencyHalfLife time.Duration
+}
+
+// SearchPage is one page of search results. NextCursor resumes the listing
+// after the page and is empty on the last page.
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	Total      int            `json:"total"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// SymbolTypes lists the document types of named symbols
+var SymbolTypes = append([]string{"function", "class", "interface", "type_alias", "variable"}, SchemaSymbolTypes...)
+
+// SchemaSymbolTypes lists the document types of the definitions of Protocol
+// Buffers and SQL files
+var SchemaSymbolTypes = []string{"message", "enum", "service", "rpc", "table", "view", "index", "procedure"}
+
+// SymbolsOnly reports whether the query only accepts symbol documents
+func (q SearchQuery) SymbolsOnly() bool {
+	docTypes := q.TypeFilter()
+	if len(docTypes) == 0 {
+		return false
+	}
+	for _, docType := range docTypes {
+		if !filterAccepts(SymbolTypes, docType) {
+			return false
+		}
+	}
+	return true
+}
+
+// TypeFilter returns the accepted document types; empty accepts all
+func (q SearchQuery) TypeFilter() []string {
+	return mergeFilter(q.Type, q.Types)
+}
+
+// LanguageFilter returns the accepted languages; empty accepts all
+func (q SearchQuery) LanguageFilter() []string {
+	return mergeFilter(q.Language, q.Languages)
+}
+
+// RepositoryFilter returns the accepted repositories; empty accepts all
+func (q SearchQuery) RepositoryFilter() []string {
+	return mergeFilter(q.Repository, q.Repositories)
+}
+
+// ProjectFilter returns the accepted sub-projects; empty accepts all
+func (q SearchQuery) ProjectFilter() []string {
+	return mergeFilter(q.Project, q.Projects)
+}
+
+// AcceptsType reports whether results of a document type match the query
+func (q SearchQuery) AcceptsType(docType string) bool {
+	return filterAccepts(q.TypeFilter(), docType)
+}
+
+// AcceptsLanguage reports whether results in a language match the query
+func (q SearchQuery) AcceptsLanguage(language string) bool {
+	return filterAccepts(q.LanguageFilter(), language)
+}
+
+// AcceptsRepository reports whether results from a repository match the query
+func (q SearchQuery) AcceptsRepository(repository string) bool {
+	return filterAccepts(q.RepositoryFilter(), repository)
+}
+
+// AcceptsProject reports whether results from a sub-project match the query
+func (q SearchQuery) AcceptsProject(project string) bool {
+	return filterAccepts(q.ProjectFilter(), project)
+}
+
+// mergeFilter combines a single filter value with a list, dropping empty
+// values and duplicates
+func mergeFilter(value string, values []string) []string {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, v := range append([]string{value}, values...) {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			merged = append(merged, v)
+		}
+	}
+	return merged
+}
+
+// filterAccepts reports whether value is in filter; an empty filter accepts
+// everything
+func filterAccepts(filter []string, value string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, v := range filter {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // IndexStats represents indexing statistics
@@ -183,8 +683,29 @@ type IndexStats struct {
 	TotalClasses      int                    `json:"total_classes"`
 	TotalVariables    int                    `json:"total_variables"`
 	LanguageStats     map[string]int         `json:"language_stats"`
+	UnknownLanguages  UnknownLanguageStats   `json:"unknown_languages"`
 	RepositoryStats   map[string]Repository  `json:"repository_stats"`
 	LastIndexed       time.Time              `json:"last_indexed"`
+	Cache             *SearchCacheStats      `json:"cache,omitempty"` // Nil when the result cache is off
+}
+
+// UnknownLanguageStats counts the indexed files whose language was not
+// detected, which are indexed with the generic parser
+type UnknownLanguageStats struct {
+	Files      int            `json:"files"`
+	Extensions map[string]int `json:"extensions"` // Files per extension, or per name for files without one
+}
+
+// SearchCacheStats counts how often searches were answered from the result
+// cache since the server started
+type SearchCacheStats struct {
+	Capacity      int     `json:"capacity"`
+	Entries       int     `json:"entries"`
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	HitRate       float64 `json:"hit_rate"`
+	Evictions     int64   `json:"evictions"`     // Pages dropped to make room
+	Invalidations int64   `json:"invalidations"` // Pages dropped because their repositories changed
 }
 
 // ParserConfig represents configuration for language parsers
@@ -205,10 +726,193 @@ type IndexingProgress struct {
 	FilesProcessed  int       `json:"files_processed"`
 	TotalFiles      int       `json:"total_files"`
 	CurrentFile     string    `json:"current_file,omitempty"`
+	Message         string    `json:"message,omitempty"` // Clone or pull progress while cloning
 	Error           string    `json:"error,omitempty"`
 	StartedAt       time.Time `json:"started_at"`
 	CompletedAt     *time.Time `json:"completed_at,omitempty"`
 	ElapsedSeconds  float64   `json:"elapsed_seconds"`
+	FilesPerSecond  float64   `json:"files_per_second,omitempty"` // Set once completed
+}
+
+// IndexingJob is a repository indexed in the background, with the progress
+// of its run
+type IndexingJob struct {
+	ID          string           `json:"id"`
+	Path        string           `json:"path"`
+	Name        string           `json:"name,omitempty"`
+	Status      string           `json:"status"` // "queued", "running", "completed", "failed", "cancelled"
+	Progress    IndexingProgress `json:"progress"`
+	Repository  *Repository      `json:"repository,omitempty"` // Set once the job completes
+	Error       string           `json:"error,omitempty"`
+	QueuedAt    time.Time        `json:"queued_at"`
+	StartedAt   *time.Time       `json:"started_at,omitempty"`
+	CompletedAt *time.Time       `json:"completed_at,omitempty"`
+}
+
+// PhaseTiming records how long one phase of an indexing run took
+type PhaseTiming struct {
+	Phase           string  `json:"phase"` // "prepare", "walk", "references", "parse", "chunk", "index"
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// RepositorySettings are the per-repository options kept in the indexer's
+// metadata store, used to index a repository again the way it was indexed
+type RepositorySettings struct {
+	Source   string              `json:"source"`             // Path or URL the repository is indexed from
+	Name     string              `json:"name,omitempty"`     // Name given when indexing, if any
+	Ref      string              `json:"ref,omitempty"`      // Branch, tag or commit to check out, if any
+	Filter   *FileFilterSettings `json:"filter,omitempty"`   // Overrides of the configured file filtering, if any
+	Chunking *ChunkingSettings   `json:"chunking,omitempty"` // Overrides of the configured chunking, if any
+	Clone    *CloneSettings      `json:"clone,omitempty"`    // Overrides of the configured clone options, if any
+
+	// Namespace the repository is searched in, DependencyNamespace for the
+	// sources of a dependency
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// FileState is what is recorded of an indexed file to tell whether it
+// changed since it was indexed
+type FileState struct {
+	Hash  string `json:"hash"` // SHA-256 of the content, as CodeFile.Hash
+	Size  int64  `json:"size"`
+	Lines int    `json:"lines"`
+}
+
+// Workspace is a named group of indexed repositories that searches can be
+// scoped to as a whole
+type Workspace struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	Repositories []string `json:"repositories"` // Repository names
+}
+
+// Project is a sub-project of a repository, such as a module of a monorepo,
+// rooted at the directory of its manifest
+type Project struct {
+	Path      string   `json:"path"`               // Directory relative to the repository root, "." for the root
+	Name      string   `json:"name,omitempty"`     // As declared in the manifest
+	Manifests []string `json:"manifests"`          // Manifest files found in the directory, e.g. go.mod
+	Files     int      `json:"files"`          // Indexed files belonging to the project
+	Languages []string `json:"languages"`
+}
+
+// RepositoryRemoval reports what was deleted with a repository
+type RepositoryRemoval struct {
+	RepositoryID     string `json:"repository_id"`
+	Repository       string `json:"repository"`
+	DocumentsRemoved int    `json:"documents_removed"`
+	CloneDeleted     string `json:"clone_deleted,omitempty"` // Path of the deleted working copy, if any
+}
+
+// OrphanedRepository is a repository record whose source is gone: the local
+// path it was indexed from no longer exists, or the index holds no documents
+// for it any more
+type OrphanedRepository struct {
+	RepositoryID string `json:"repository_id"`
+	Repository   string `json:"repository"`
+	Path         string `json:"path,omitempty"`
+	Reason       string `json:"reason"` // "path_missing" or "not_in_index"
+	Removed      bool   `json:"removed"`
+}
+
+// IndexOptimization reports the outcome of compacting the index
+type IndexOptimization struct {
+	SizeBefore     int64   `json:"size_before_bytes"`
+	SizeAfter      int64   `json:"size_after_bytes"`
+	SegmentsBefore int     `json:"segments_before"`
+	SegmentsAfter  int     `json:"segments_after"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// IndexArchive is the header of an index archive, written first so the
+// archive can be checked before anything is extracted
+type IndexArchive struct {
+	Format         string               `json:"format"`          // Always "code-indexer-index"
+	FormatVersion  int                  `json:"format_version"`  // Of the archive layout
+	MappingVersion string               `json:"mapping_version"` // Of the indexes, which must match the importing server's
+	CreatedAt      time.Time            `json:"created_at"`
+	Repositories   []ArchivedRepository `json:"repositories"`
+}
+
+// ArchivedRepository is a repository whose index is in an archive
+type ArchivedRepository struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Path      string `json:"path"` // Where it was indexed from, or is imported to
+	Commit    string `json:"commit,omitempty"`
+	Documents int    `json:"documents"`
+}
+
+// IndexImport reports the repositories an archive was imported with
+type IndexImport struct {
+	Archive        IndexArchive         `json:"archive"`
+	Imported       []ArchivedRepository `json:"imported"`
+	Skipped        []string             `json:"skipped,omitempty"` // Names of repositories indexed here already
+	ElapsedSeconds float64              `json:"elapsed_seconds"`
+}
+
+// IndexIssue is a file whose documents do not match the repository on disk
+type IndexIssue struct {
+	RepositoryID string `json:"repository_id"`
+	Repository   string `json:"repository"`
+	FilePath     string `json:"file_path"` // Relative to the repository root
+	Problem      string `json:"problem"`   // "modified", "deleted", "missing" or "no_hash"
+	IndexedHash  string `json:"indexed_hash,omitempty"`
+	DiskHash     string `json:"disk_hash,omitempty"`
+}
+
+// IndexVerification reports the outcome of checking the index against the
+// repositories on disk
+type IndexVerification struct {
+	RepositoriesChecked int          `json:"repositories_checked"`
+	RepositoriesSkipped []string     `json:"repositories_skipped,omitempty"` // Without a local copy to compare with
+	FilesChecked        int          `json:"files_checked"`
+	Issues              []IndexIssue `json:"issues"`
+	FilesRepaired       int          `json:"files_repaired"`
+}
+
+// FileFilterSettings override the indexer's file filtering for one
+// repository. Set fields replace the configured values; unset ones keep them.
+type FileFilterSettings struct {
+	MaxFileSize     int64    `json:"max_file_size,omitempty"`
+	IncludePatterns []string `json:"include_patterns,omitempty"`
+	ExcludePatterns []string `json:"exclude_patterns,omitempty"`
+}
+
+// ChunkingSettings override the indexer's chunking for one repository. Set
+// fields replace the configured values; unset ones keep them.
+type ChunkingSettings struct {
+	Strategy       string `json:"strategy,omitempty"`
+	MaxChunkLines  int    `json:"max_chunk_lines,omitempty"`
+	MaxChunkTokens int    `json:"max_chunk_tokens,omitempty"`
+}
+
+// CloneSettings override how a repository is cloned. Set fields replace
+// the configured values; unset ones keep them.
+type CloneSettings struct {
+	Depth          int      `json:"depth,omitempty"`
+	SingleBranch   bool     `json:"single_branch,omitempty"`
+	SparsePatterns []string `json:"sparse_patterns,omitempty"` // Directories checked out, relative to the repository root
+}
+
+// IndexingRun records the outcome and per-phase timings of one indexing run
+type IndexingRun struct {
+	RepositoryID   string        `json:"repository_id,omitempty"`
+	Repository     string        `json:"repository"`
+	Path           string        `json:"path"`
+	Mode           string        `json:"mode,omitempty"` // "full" or "incremental"
+	Status         string        `json:"status"`         // "completed", "failed", "cancelled"
+	Error          string        `json:"error,omitempty"`
+	FilesIndexed   int           `json:"files_indexed"`
+	FilesDeleted   int           `json:"files_deleted,omitempty"`
+	FilesSkipped   int           `json:"files_skipped,omitempty"`
+	FilesFailed    int           `json:"files_failed"`
+	TotalLines     int           `json:"total_lines"`
+	FilesPerSecond float64       `json:"files_per_second,omitempty"` // Files parsed and indexed per second, excluding clone and discovery
+	StartedAt      time.Time     `json:"started_at"`
+	CompletedAt    time.Time     `json:"completed_at"`
+	ElapsedSeconds float64       `json:"elapsed_seconds"`
+	Phases         []PhaseTiming `json:"phases"`
 }
 
 // ML-related types
@@ -332,6 +1036,8 @@ type CodeAnalysis struct {
 	Complexity  string    `json:"complexity"`
 	Model       string    `json:"model"`
 	AnalyzedAt  time.Time `json:"analyzed_at"`
+
+	Grounding []GroundingDocument `json:"grounding,omitempty"` // Indexed code given to the model as context
 }
 
 // CodeExplanation represents AI code explanation
@@ -344,6 +1050,51 @@ type CodeExplanation struct {
 	Complexity  string    `json:"complexity"`
 	Model       string    `json:"model"`
 	ExplainedAt time.Time `json:"explained_at"`
+
+	Grounding []GroundingDocument `json:"grounding,omitempty"` // Indexed code given to the model as context
+}
+
+// GroundingDocument is a piece of indexed code a model request was grounded
+// in, such as the definition of a symbol the code references
+type GroundingDocument struct {
+	Kind       string `json:"kind"` // "definition", "caller", "callee", "imports" or "tests"
+	Name       string `json:"name,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	FilePath   string `json:"file_path"`
+	StartLine  int    `json:"start_line,omitempty"`
+	EndLine    int    `json:"end_line,omitempty"`
+	Tokens     int    `json:"tokens"`
+}
+
+// ChangeSummary is a commit message or pull request description written for
+// a diff
+type ChangeSummary struct {
+	Format      string    `json:"format"`          // "commit" or "pull_request"
+	Type        string    `json:"type,omitempty"`  // Conventional commit type, e.g. "feat"
+	Scope       string    `json:"scope,omitempty"` // Area of the code changed, e.g. "server"
+	Breaking    bool      `json:"breaking,omitempty"`
+	Subject     string    `json:"subject"` // Commit subject line or pull request title
+	Body        string    `json:"body,omitempty"`
+	Changes     []string  `json:"changes,omitempty"` // Notable changes, one per entry
+	Message     string    `json:"message"`           // Subject and body, ready to use
+	Model       string    `json:"model"`
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// TestGeneration is the content of a test file with tests written for
+// symbols of a source file, added to the tests the file already has
+type TestGeneration struct {
+	Language    string                 `json:"language"`
+	Framework   string                 `json:"framework,omitempty"` // e.g. "go-testing", "pytest" or "jest"
+	SourceFile  string                 `json:"source_file"`
+	TestFile    string                 `json:"test_file"`
+	Targets     []string               `json:"targets"` // Qualified names of the symbols tested
+	Content     string                 `json:"content"`
+	Model       string                 `json:"model"`
+	GeneratedAt time.Time              `json:"generated_at"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+
+	Grounding []GroundingDocument `json:"grounding,omitempty"` // Indexed code given to the model as context
 }
 
 
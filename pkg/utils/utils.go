@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/my-mcp/code-indexer/pkg/textpos"
 )
 
 // GenerateID generates a unique ID from a string
@@ -250,10 +252,7 @@ func ExtractSnippet(content string, lineNumber, contextLines int) string {
 
 // CountLines counts the number of lines in a string
 func CountLines(content string) int {
-	if content == "" {
-		return 0
-	}
-	return strings.Count(content, "\n") + 1
+	return textpos.CountLines(content)
 }
 
 // RemoveCommonIndentation removes common leading whitespace from all lines